@@ -15,9 +15,6 @@ const (
 	CmdDel    = "DEL"
 	CmdUpdate = "UPDATE"
 
-	// CNI errors.
-	ErrRuntime = 100
-
 	// DefaultVersion is the CNI version used when no version is specified in a network config file.
 	defaultVersion = "0.2.0"
 )
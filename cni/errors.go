@@ -0,0 +1,137 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package cni
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/cns/cnsclient"
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/store"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+)
+
+// CNI error codes returned in errors, reported in telemetry and used as exit codes.
+// Codes below 100 are reserved by the CNI specification.
+// The codes are stable: new codes are added at the end, and existing codes are never changed or reused.
+const (
+	ErrRuntime              = 100 // Unclassified failure.
+	ErrInvalidNetworkConfig = 101 // The network configuration is invalid or conflicts with the existing network.
+	ErrInvalidArgs          = 102 // The CNI arguments, e.g. the pod name or container ID, are missing or invalid.
+	ErrIpamExhausted        = 103 // No address or address pool is available.
+	ErrIpamFailure          = 104 // IPAM failed for another reason.
+	ErrHnsTimeout           = 105 // An HNS request timed out.
+	ErrHnsFailure           = 106 // An HNS request failed.
+	ErrNetlinkFailure       = 107 // A netlink request was rejected by the kernel.
+	ErrStateCorruption      = 108 // The persisted state of the plugin can not be decoded.
+	ErrStoreLockTimeout     = 109 // The state store lock was not released by its owner.
+	ErrCnsFailure           = 110 // A request to CNS failed.
+	ErrDataplaneFailure     = 111 // Programming the host network failed for another reason.
+	ErrCnsUnavailable       = 112 // CNS could not be reached.
+)
+
+// Names of the error codes, returned in the details of errors.
+var errorCodeNames = map[uint]string{
+	ErrRuntime:              "Runtime",
+	ErrInvalidNetworkConfig: "InvalidNetworkConfig",
+	ErrInvalidArgs:          "InvalidArgs",
+	ErrIpamExhausted:        "IpamExhausted",
+	ErrIpamFailure:          "IpamFailure",
+	ErrHnsTimeout:           "HnsTimeout",
+	ErrHnsFailure:           "HnsFailure",
+	ErrNetlinkFailure:       "NetlinkFailure",
+	ErrStateCorruption:      "StateCorruption",
+	ErrStoreLockTimeout:     "StoreLockTimeout",
+	ErrCnsFailure:           "CnsFailure",
+	ErrDataplaneFailure:     "DataplaneFailure",
+	ErrCnsUnavailable:       "CnsUnavailable",
+}
+
+// GetErrorCodeName returns the name of the given error code.
+func GetErrorCodeName(code uint) string {
+	if name, ok := errorCodeNames[code]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Code%d", code)
+}
+
+// GetErrorCode returns the error code of the given error, or ErrRuntime if its cause is unknown.
+func GetErrorCode(err error) uint {
+	if code, ok := classifyError(err); ok {
+		return code
+	}
+
+	for ; err != nil; err = unwrapError(err) {
+		if cniErr, ok := err.(*cniTypes.Error); ok && cniErr.Code != 0 {
+			return cniErr.Code
+		}
+	}
+
+	return ErrRuntime
+}
+
+// NewError creates a CNI error with the given code.
+func NewError(code uint, msg string) *cniTypes.Error {
+	return &cniTypes.Error{
+		Code:    code,
+		Msg:     msg,
+		Details: GetErrorCodeName(code),
+	}
+}
+
+// Returns the error code for the cause of a failure, if it is known.
+// The cause may be wrapped in other errors.
+func classifyError(err error) (uint, bool) {
+	for ; err != nil; err = unwrapError(err) {
+		switch e := err.(type) {
+		case *cniTypes.Error:
+			// Errors of delegated plugins keep their code unless it is unclassified.
+			return e.Code, e.Code > ErrRuntime
+		case *store.CorruptedError:
+			return ErrStateCorruption, true
+		case *cnsclient.Error:
+			if cnsclient.IsUnavailable(e) {
+				return ErrCnsUnavailable, true
+			}
+			return ErrCnsFailure, true
+		}
+
+		switch {
+		case err == store.ErrTimeoutLockingStore:
+			return ErrStoreLockTimeout, true
+		case ipam.IsAddressExhausted(err):
+			return ErrIpamExhausted, true
+		}
+
+		if code, ok := classifyPlatformError(err); ok {
+			return code, true
+		}
+	}
+
+	return 0, false
+}
+
+// Returns the error wrapped by the given error, or nil if it doesn't wrap one.
+func unwrapError(err error) error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	case interface{ Cause() error }:
+		return e.Cause()
+	}
+
+	return nil
+}
+
+// Returns the first error in the given format arguments.
+func getCause(args []interface{}) error {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package cni
+
+import (
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+const (
+	// Error code of failures programming the host network.
+	ErrDataplane = ErrDataplaneFailure
+)
+
+// Returns the error code for the platform specific cause of a failure, if it is known.
+func classifyPlatformError(err error) (uint, bool) {
+	if _, ok := netlink.GetErrno(err); ok || err == netlink.ErrDumpInterrupted {
+		return ErrNetlinkFailure, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,22 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package cni
+
+import (
+	"github.com/Microsoft/hcsshim"
+)
+
+const (
+	// Error code of failures programming the host network, which is done by HNS.
+	ErrDataplane = ErrHnsFailure
+)
+
+// Returns the error code for the platform specific cause of a failure, if it is known.
+func classifyPlatformError(err error) (uint, bool) {
+	if hcsshim.IsTimeout(err) {
+		return ErrHnsTimeout, true
+	}
+
+	return 0, false
+}
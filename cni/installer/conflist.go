@@ -0,0 +1,302 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Azure/azure-container-networking/cni"
+)
+
+const (
+	// Version of the CNI specification of the generated network configuration.
+	conflistCNIVersion = "0.3.0"
+
+	// Network modes of the generated network configuration.
+	ModeBridge      = "bridge"
+	ModeTransparent = "transparent"
+	ModeTunnel      = "tunnel"
+	ModeOverlay     = "overlay"
+
+	// Name of the CNI network plugin.
+	networkPluginName = "azure-vnet"
+)
+
+// ConflistConfig describes the network configuration generated for the nodes of a cluster.
+type ConflistConfig struct {
+	// Platform of the nodes, defaults to the current platform.
+	OS string
+	// Network mode, bridge, transparent, tunnel, or overlay for VXLAN overlay networks.
+	Mode string
+	// Name of the bridge of bridge, tunnel and overlay mode networks.
+	Bridge string
+	// Whether network containers of multiple tenants are attached to pods.
+	MultiTenancy bool
+	// Whether pods get an IPv6 address besides their IPv4 address.
+	DualStack bool
+	// Pod subnets of the node, allocated by host-local instead of Azure IPAM in dual-stack and overlay networks.
+	// Dual-stack networks have an IPv4 and an IPv6 subnet.
+	PodCIDRs []string
+	// VXLAN network identifier of overlay networks, and the cluster CIDR their traffic isn't masqueraded to.
+	OverlayVNI  int
+	ClusterCIDR string
+	// CNS URL the overlay routes are learned from, empty for the default.
+	CNSURL string
+	// DNS settings of Windows pods.
+	DNSServers []string
+	DNSSearch  []string
+	// Destinations reached from Windows pods without outbound NAT, i.e. the VNET and cluster address spaces.
+	OutboundNATExceptions []string
+}
+
+// conflist is a CNI network configuration list.
+type conflist struct {
+	CNIVersion string            `json:"cniVersion"`
+	Name       string            `json:"name"`
+	Plugins    []json.RawMessage `json:"plugins"`
+}
+
+// pluginType is the type of a plugin of a network configuration list.
+type pluginType struct {
+	Type string `json:"type"`
+}
+
+// GenerateConflist returns the network configuration list of the given configuration.
+func GenerateConflist(cfg ConflistConfig) ([]byte, error) {
+	if cfg.OS == "" {
+		cfg.OS = runtime.GOOS
+	}
+
+	if cfg.Mode == "" {
+		cfg.Mode = ModeBridge
+	}
+
+	if cfg.Bridge == "" && cfg.Mode != ModeTransparent {
+		cfg.Bridge = "azure0"
+	}
+
+	switch cfg.Mode {
+	case ModeBridge, ModeTunnel:
+	case ModeOverlay:
+		if cfg.OS == "windows" || cfg.MultiTenancy {
+			return nil, fmt.Errorf("Network mode %v is not supported on %v or with multitenancy", cfg.Mode, cfg.OS)
+		}
+
+		if cfg.OverlayVNI <= 0 {
+			return nil, fmt.Errorf("Network mode %v requires a VNI", cfg.Mode)
+		}
+	case ModeTransparent:
+		// Windows networks are HNS l2bridge or l2tunnel networks.
+		if cfg.OS == "windows" {
+			return nil, fmt.Errorf("Network mode %v is not supported on %v", cfg.Mode, cfg.OS)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid network mode %v", cfg.Mode)
+	}
+
+	// Azure IPAM allocates VNET IPv4 addresses only, dual-stack and overlay networks use the pod subnets of the node.
+	podCIDRs, err := getPodCIDRs(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ipam := map[string]interface{}{"type": networkPluginName + "-ipam"}
+	if podCIDRs != nil {
+		var ranges []interface{}
+		for _, podCIDR := range podCIDRs {
+			ranges = append(ranges, []interface{}{map[string]interface{}{"subnet": podCIDR}})
+		}
+
+		ipam = map[string]interface{}{"type": "host-local", "ranges": ranges}
+	}
+
+	plugin := map[string]interface{}{
+		"type": networkPluginName,
+		"mode": cfg.Mode,
+		"ipam": ipam,
+	}
+
+	if cfg.Mode == ModeOverlay {
+		overlay := map[string]interface{}{"vni": cfg.OverlayVNI}
+		if cfg.ClusterCIDR != "" {
+			overlay["clusterCIDR"] = cfg.ClusterCIDR
+		}
+
+		plugin["overlay"] = overlay
+		if cfg.CNSURL != "" {
+			plugin["cnsurl"] = cfg.CNSURL
+		}
+	}
+
+	if cfg.Bridge != "" {
+		plugin["bridge"] = cfg.Bridge
+	}
+
+	if cfg.MultiTenancy {
+		plugin["multiTenancy"] = true
+		plugin["enableSnatOnHost"] = true
+	}
+
+	// The plugin maps the host ports of pods itself, with iptables on Linux and HNS on Windows.
+	plugin["capabilities"] = map[string]interface{}{"portMappings": true}
+
+	var plugins []interface{}
+
+	switch cfg.OS {
+	case "linux":
+		plugins = []interface{}{plugin}
+	case "windows":
+		// Windows pods get their DNS settings and policies from the configuration.
+		plugin["dns"] = map[string]interface{}{"Nameservers": cfg.DNSServers, "Search": cfg.DNSSearch}
+
+		if len(cfg.OutboundNATExceptions) > 0 {
+			plugin["AdditionalArgs"] = []interface{}{
+				map[string]interface{}{
+					"Name":  "EndpointPolicy",
+					"Value": map[string]interface{}{"Type": "OutBoundNAT", "ExceptionList": cfg.OutboundNATExceptions},
+				},
+			}
+		}
+
+		plugins = []interface{}{plugin}
+	default:
+		return nil, fmt.Errorf("Unsupported platform %v", cfg.OS)
+	}
+
+	list := map[string]interface{}{
+		"cniVersion": conflistCNIVersion,
+		"name":       "azure",
+		"plugins":    plugins,
+	}
+
+	data, err := json.MarshalIndent(list, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// Returns the pod subnets allocated by host-local, or nil if addresses are allocated by Azure IPAM.
+func getPodCIDRs(cfg ConflistConfig) ([]string, error) {
+	if !cfg.DualStack && cfg.Mode != ModeOverlay {
+		return nil, nil
+	}
+
+	// Standard IPAM plugins can't be used with multitenancy, nor on Windows.
+	if cfg.OS == "windows" || cfg.MultiTenancy {
+		return nil, fmt.Errorf("Dual-stack networks are not supported on %v or with multitenancy", cfg.OS)
+	}
+
+	var ipv4, ipv6 []string
+	for _, podCIDR := range cfg.PodCIDRs {
+		ip, _, err := net.ParseCIDR(podCIDR)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pod CIDR %v: %v", podCIDR, err)
+		}
+
+		if ip.To4() != nil {
+			ipv4 = append(ipv4, podCIDR)
+		} else {
+			ipv6 = append(ipv6, podCIDR)
+		}
+	}
+
+	// The plugin expects an IPv4 address first. Overlay tunnels carry IPv4 traffic only.
+	switch {
+	case len(ipv4) != 1:
+		return nil, fmt.Errorf("Pod CIDRs %v must have one IPv4 subnet", cfg.PodCIDRs)
+	case cfg.DualStack && len(ipv6) != 1:
+		return nil, fmt.Errorf("Pod CIDRs %v of a dual-stack network must have one IPv6 subnet", cfg.PodCIDRs)
+	case !cfg.DualStack && len(ipv6) != 0:
+		return nil, fmt.Errorf("Pod CIDRs %v have IPv6 subnets but dual-stack isn't enabled", cfg.PodCIDRs)
+	case cfg.DualStack && cfg.Mode == ModeOverlay:
+		return nil, fmt.Errorf("Dual-stack networks are not supported in network mode %v", cfg.Mode)
+	}
+
+	return append(ipv4, ipv6...), nil
+}
+
+// ValidateConflist checks that the runtime can load the network configuration list the way kubelet does:
+// the list is well formed, the binaries of its plugins are installed in binDir, and the CNI network
+// plugin parses its configuration and supports the CNI version of the list.
+func ValidateConflist(data []byte, binDir string) error {
+	var list conflist
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("Invalid network configuration list: %v", err)
+	}
+
+	if list.Name == "" {
+		return fmt.Errorf("Network configuration list has no name")
+	}
+
+	if len(list.Plugins) == 0 {
+		return fmt.Errorf("Network configuration list has no plugins")
+	}
+
+	for _, raw := range list.Plugins {
+		var plugin pluginType
+		if err := json.Unmarshal(raw, &plugin); err != nil || plugin.Type == "" {
+			return fmt.Errorf("Network configuration list has a plugin without type")
+		}
+
+		path := filepath.Join(binDir, binaryName(plugin.Type))
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("Plugin %v is not installed: %v", plugin.Type, err)
+		}
+
+		if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+			return fmt.Errorf("Plugin %v is not executable", plugin.Type)
+		}
+
+		if plugin.Type != networkPluginName {
+			continue
+		}
+
+		if _, err := cni.ParseNetworkConfig(raw); err != nil {
+			return fmt.Errorf("Plugin %v can't parse its configuration: %v", plugin.Type, err)
+		}
+
+		if err := checkPluginVersion(path, list.CNIVersion); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks that a plugin supports the given CNI version, by running its VERSION command like the runtime does.
+func checkPluginVersion(path string, cniVersion string) error {
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(), "CNI_COMMAND=VERSION")
+	cmd.Stdin = bytes.NewBufferString(fmt.Sprintf(`{"cniVersion":%q}`, cniVersion))
+
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("Plugin %v failed to report its versions: %v", filepath.Base(path), err)
+	}
+
+	var versions struct {
+		SupportedVersions []string `json:"supportedVersions"`
+	}
+	if err := json.Unmarshal(out, &versions); err != nil {
+		return fmt.Errorf("Plugin %v reported invalid versions %q", filepath.Base(path), out)
+	}
+
+	for _, version := range versions.SupportedVersions {
+		if version == cniVersion {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Plugin %v doesn't support CNI version %v", filepath.Base(path), cniVersion)
+}
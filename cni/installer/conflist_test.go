@@ -0,0 +1,191 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+)
+
+// Tests that the generated network configuration is parsed by the plugins with the requested settings.
+func TestGenerateConflist(t *testing.T) {
+	data, err := GenerateConflist(ConflistConfig{OS: "linux", Mode: ModeTransparent, MultiTenancy: true})
+	if err != nil {
+		t.Fatalf("Failed to generate conflist: %v", err)
+	}
+
+	var list conflist
+	// Host ports are mapped by the network plugin, without chaining the portmap plugin.
+	if err = json.Unmarshal(data, &list); err != nil || len(list.Plugins) != 1 {
+		t.Fatalf("Unexpected conflist %s, err:%v", data, err)
+	}
+
+	var plugin struct {
+		Capabilities map[string]bool `json:"capabilities"`
+	}
+	if err = json.Unmarshal(list.Plugins[0], &plugin); err != nil || !plugin.Capabilities["portMappings"] {
+		t.Errorf("Unexpected capabilities %v, err:%v", plugin.Capabilities, err)
+	}
+
+	nwCfg, err := cni.ParseNetworkConfig(list.Plugins[0])
+	if err != nil {
+		t.Fatalf("Failed to parse network configuration: %v", err)
+	}
+
+	if nwCfg.Type != "azure-vnet" || nwCfg.Mode != ModeTransparent || nwCfg.Bridge != "" ||
+		!nwCfg.MultiTenancy || nwCfg.Ipam.Type != "azure-vnet-ipam" {
+		t.Errorf("Unexpected network configuration %+v", nwCfg)
+	}
+
+	data, err = GenerateConflist(ConflistConfig{OS: "windows", OutboundNATExceptions: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("Failed to generate Windows conflist: %v", err)
+	}
+
+	if err = json.Unmarshal(data, &list); err != nil || len(list.Plugins) != 1 {
+		t.Fatalf("Unexpected Windows conflist %s, err:%v", data, err)
+	}
+
+	if nwCfg, err = cni.ParseNetworkConfig(list.Plugins[0]); err != nil ||
+		nwCfg.Bridge != "azure0" || len(cni.GetPoliciesFromNwCfg(nwCfg.AdditionalArgs)) != 1 {
+		t.Errorf("Unexpected Windows network configuration %+v, err:%v", nwCfg, err)
+	}
+
+	invalid := []ConflistConfig{
+		{OS: "windows", Mode: ModeTransparent},
+		{OS: "linux", Mode: "vxlan"},
+		{OS: "linux", Mode: ModeOverlay, PodCIDRs: []string{"10.244.1.0/24"}},
+		{OS: "linux", Mode: ModeOverlay, OverlayVNI: 4096},
+		{OS: "linux", Mode: ModeOverlay, OverlayVNI: 4096, PodCIDRs: []string{"10.244.1.0/24"}, MultiTenancy: true},
+		{OS: "linux", DualStack: true},
+		{OS: "linux", DualStack: true, PodCIDRs: []string{"10.244.1.0/24"}},
+		{OS: "linux", DualStack: true, PodCIDRs: []string{"fd00:1::/64", "fd00:2::/64"}},
+		{OS: "linux", Mode: ModeOverlay, OverlayVNI: 4096, DualStack: true, PodCIDRs: []string{"10.244.1.0/24", "fd00:1::/64"}},
+		{OS: "windows", DualStack: true, PodCIDRs: []string{"10.244.1.0/24", "fd00:1::/64"}},
+	}
+
+	for _, cfg := range invalid {
+		if _, err = GenerateConflist(cfg); err == nil {
+			t.Errorf("Generating conflist %+v didn't fail", cfg)
+		}
+	}
+}
+
+// Tests that dual-stack and overlay networks get their addresses from the pod subnets of the node.
+func TestGenerateConflistPodCIDRs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ConflistConfig
+		ranges  string
+		overlay *cni.OverlayConfig
+	}{
+		{
+			name:   "dual-stack",
+			cfg:    ConflistConfig{OS: "linux", DualStack: true, PodCIDRs: []string{"fd00:1::/64", "10.244.1.0/24"}},
+			ranges: `[[{"subnet":"10.244.1.0/24"}],[{"subnet":"fd00:1::/64"}]]`,
+		},
+		{
+			name: "overlay",
+			cfg: ConflistConfig{
+				OS:          "linux",
+				Mode:        ModeOverlay,
+				PodCIDRs:    []string{"10.244.1.0/24"},
+				OverlayVNI:  4096,
+				ClusterCIDR: "10.244.0.0/16",
+				CNSURL:      "http://localhost:10090",
+			},
+			ranges:  `[[{"subnet":"10.244.1.0/24"}]]`,
+			overlay: &cni.OverlayConfig{VNI: 4096, ClusterCIDR: "10.244.0.0/16"},
+		},
+	}
+
+	for _, test := range tests {
+		data, err := GenerateConflist(test.cfg)
+		if err != nil {
+			t.Fatalf("%v: failed to generate conflist: %v", test.name, err)
+		}
+
+		var list conflist
+		if err = json.Unmarshal(data, &list); err != nil {
+			t.Fatalf("%v: unexpected conflist %s, err:%v", test.name, data, err)
+		}
+
+		nwCfg, err := cni.ParseNetworkConfig(list.Plugins[0])
+		if err != nil {
+			t.Fatalf("%v: failed to parse network configuration: %v", test.name, err)
+		}
+
+		var plugin struct {
+			Ipam struct {
+				Ranges json.RawMessage `json:"ranges"`
+			} `json:"ipam"`
+		}
+		json.Unmarshal(list.Plugins[0], &plugin)
+
+		var ranges bytes.Buffer
+		json.Compact(&ranges, plugin.Ipam.Ranges)
+
+		if nwCfg.Ipam.Type != "host-local" || ranges.String() != test.ranges {
+			t.Errorf("%v: unexpected IPAM %v %s", test.name, nwCfg.Ipam.Type, ranges.String())
+		}
+
+		if test.overlay != nil && (nwCfg.Overlay == nil || *nwCfg.Overlay != *test.overlay || nwCfg.CNSUrl != test.cfg.CNSURL) {
+			t.Errorf("%v: unexpected overlay %+v at %v", test.name, nwCfg.Overlay, nwCfg.CNSUrl)
+		}
+	}
+}
+
+// Tests that a generated network configuration is installed with executable binaries, and rolled back
+// while the runtime can't load it.
+func TestInstallGeneratedConflist(t *testing.T) {
+	in, cleanup := newTestInstaller(t, "v1.0.0")
+	defer cleanup()
+
+	in.Conflist = &ConflistConfig{OS: "linux", Mode: ModeBridge}
+	in.Validate = true
+
+	if err := os.Chmod(filepath.Join(in.SourceDir, "azure-vnet"), 0644); err != nil {
+		t.Fatalf("Failed to change mode: %v", err)
+	}
+
+	// The network plugin doesn't support the CNI version of the configuration.
+	script := "#!/bin/sh\n" +
+		"if [ \"$CNI_COMMAND\" = VERSION ]; then echo '{\"supportedVersions\":[\"0.2.0\"]}'; exit 0; fi\n" +
+		"echo " + versionPrefix + "v1.0.0\n"
+	if err := ioutil.WriteFile(filepath.Join(in.SourceDir, "azure-vnet"), []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write binary: %v", err)
+	}
+
+	if err := in.Reconcile(); err == nil {
+		t.Fatalf("Installing a configuration that can't be loaded didn't fail")
+	}
+
+	if _, err := os.Stat(in.configPath()); !os.IsNotExist(err) {
+		t.Errorf("Configuration that can't be loaded wasn't rolled back, err:%v", err)
+	}
+
+	writeSource(t, in, "v1.0.0")
+	if err := os.Chmod(filepath.Join(in.SourceDir, "azure-vnet"), 0644); err != nil {
+		t.Fatalf("Failed to change mode: %v", err)
+	}
+
+	if err := in.Reconcile(); err != nil {
+		t.Fatalf("Failed to install: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(in.BinDir, "azure-vnet"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Unexpected binary mode %v, err:%v", info.Mode(), err)
+	}
+
+	if !in.configMatches() {
+		t.Errorf("Installed configuration doesn't match the generated one")
+	}
+}
@@ -0,0 +1,440 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	// Name of the file recording the installed version.
+	stateFileName = "azure-cni-installer.json"
+
+	// Name of the directory holding the previously installed files.
+	backupDirName = "azure-cni-backup"
+
+	// Prefix of the version printed by the CNI plugin.
+	versionPrefix = "Azure CNI Version "
+
+	// Timeout for the CNS health check.
+	cnsRequestTimeout = 10 * time.Second
+)
+
+// State records the installation on the node.
+type State struct {
+	Version         string
+	PreviousVersion string
+	InstallTime     time.Time
+}
+
+// Installer installs the CNI plugin binaries and network configuration on a node.
+type Installer struct {
+	// Directory containing the files to install.
+	SourceDir string
+	// Directory the CNI binaries are installed to.
+	BinDir string
+	// Directory the network configuration is installed to.
+	ConfDir string
+	// Directory the installer keeps its state and backups in.
+	StateDir string
+	// Version of the files in SourceDir.
+	Version string
+	// Name of the network configuration file in SourceDir and ConfDir.
+	ConfigFile string
+	// CNI plugin store, locked while files are swapped so that no plugin invocation runs concurrently.
+	Store store.KeyValueStore
+	// CNS URL, if set the upgrade waits until CNS is healthy.
+	CnsURL string
+	// Network configuration generated for the node instead of installing the one in SourceDir, if set.
+	Conflist *ConflistConfig
+	// Whether the installed network configuration is checked to be loadable by the runtime,
+	// which requires the binaries of all its plugins to be installed.
+	Validate bool
+}
+
+// Binaries installed by the installer.
+var binaries = []string{"azure-vnet", "azure-vnet-ipam", "azure-vnet-telemetry"}
+
+// Returns the file name of a binary on the current platform.
+func binaryName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// Returns the files installed, as source and destination paths.
+func (in *Installer) files() map[string]string {
+	files := make(map[string]string)
+
+	for _, name := range binaries {
+		name = binaryName(name)
+		files[filepath.Join(in.SourceDir, name)] = filepath.Join(in.BinDir, name)
+	}
+
+	files[filepath.Join(in.SourceDir, in.ConfigFile)] = in.configPath()
+
+	return files
+}
+
+// Returns the path of the installed network configuration.
+func (in *Installer) configPath() string {
+	return filepath.Join(in.ConfDir, in.ConfigFile)
+}
+
+// Returns the network configuration to install.
+func (in *Installer) config() ([]byte, error) {
+	if in.Conflist != nil {
+		return GenerateConflist(*in.Conflist)
+	}
+
+	return ioutil.ReadFile(filepath.Join(in.SourceDir, in.ConfigFile))
+}
+
+// GetInstalledVersion returns the version reported by the installed CNI plugin.
+func (in *Installer) GetInstalledVersion() (string, error) {
+	out, err := exec.Command(filepath.Join(in.BinDir, binaryName("azure-vnet")), "-v").Output()
+	if err != nil {
+		return "", err
+	}
+
+	version := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(version, versionPrefix) {
+		return "", fmt.Errorf("Unexpected version output %q", version)
+	}
+
+	return strings.TrimPrefix(version, versionPrefix), nil
+}
+
+// GetState returns the recorded state of the installation.
+func (in *Installer) GetState() (*State, error) {
+	var state State
+
+	data, err := ioutil.ReadFile(filepath.Join(in.StateDir, stateFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// Records the state of the installation.
+func (in *Installer) saveState(state *State) error {
+	data, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(in.StateDir, stateFileName), data, 0644)
+}
+
+// Reconcile installs the files if the installed version differs from the installer version.
+func (in *Installer) Reconcile() error {
+	installed, err := in.GetInstalledVersion()
+	if err == nil && installed == in.Version && in.configMatches() {
+		return nil
+	}
+
+	if err != nil {
+		log.Printf("[installer] No usable CNI plugin is installed, err:%v.", err)
+	} else if installed != in.Version {
+		log.Printf("[installer] Version skew detected, installed:%v expected:%v.", installed, in.Version)
+	} else {
+		log.Printf("[installer] Network configuration differs from version %v.", in.Version)
+	}
+
+	return in.Install(installed)
+}
+
+// Returns whether the installed network configuration matches the source.
+func (in *Installer) configMatches() bool {
+	src, err := in.config()
+	if err != nil {
+		return false
+	}
+
+	dst, err := ioutil.ReadFile(in.configPath())
+	if err != nil {
+		return false
+	}
+
+	return string(src) == string(dst)
+}
+
+// Install replaces the installed files, rolling back to the previous files if the new plugin does not work.
+func (in *Installer) Install(installed string) error {
+	if err := in.waitForCns(); err != nil {
+		return err
+	}
+
+	if in.Store != nil {
+		if err := in.Store.Lock(true); err != nil {
+			return fmt.Errorf("Failed to lock CNI store: %v", err)
+		}
+		defer in.Store.Unlock(false)
+	}
+
+	backupDir := filepath.Join(in.StateDir, backupDirName)
+	if err := in.backup(backupDir); err != nil {
+		return fmt.Errorf("Failed to back up installed files: %v", err)
+	}
+
+	err := in.copyFiles()
+	if err == nil {
+		var version string
+		version, err = in.GetInstalledVersion()
+		if err == nil && version != in.Version {
+			err = fmt.Errorf("Installed plugin reports version %v", version)
+		}
+	}
+
+	if err == nil && in.Validate {
+		err = in.validate()
+	}
+
+	if err != nil {
+		log.Printf("[installer] Failed to install version %v, rolling back, err:%v.", in.Version, err)
+		if rbErr := in.restore(backupDir); rbErr != nil {
+			log.Printf("[installer] Failed to roll back, err:%v.", rbErr)
+		}
+		return err
+	}
+
+	log.Printf("[installer] Installed version %v, previous version:%v.", in.Version, installed)
+
+	return in.saveState(&State{
+		Version:         in.Version,
+		PreviousVersion: installed,
+		InstallTime:     time.Now(),
+	})
+}
+
+// Rollback restores the files installed before the last installation.
+func (in *Installer) Rollback() error {
+	if in.Store != nil {
+		if err := in.Store.Lock(true); err != nil {
+			return fmt.Errorf("Failed to lock CNI store: %v", err)
+		}
+		defer in.Store.Unlock(false)
+	}
+
+	if err := in.restore(filepath.Join(in.StateDir, backupDirName)); err != nil {
+		return err
+	}
+
+	version, err := in.GetInstalledVersion()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[installer] Rolled back to version %v.", version)
+
+	return in.saveState(&State{Version: version, InstallTime: time.Now()})
+}
+
+// Copies the installed files to the backup directory.
+func (in *Installer) backup(backupDir string) error {
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return err
+	}
+
+	for _, dst := range in.files() {
+		backup := filepath.Join(backupDir, filepath.Base(dst))
+
+		if err := copyFile(dst, backup); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			// Nothing was installed, remove any stale backup so a rollback removes the file.
+			os.Remove(backup)
+		}
+	}
+
+	return nil
+}
+
+// Restores the files from the backup directory.
+func (in *Installer) restore(backupDir string) error {
+	for _, dst := range in.files() {
+		backup := filepath.Join(backupDir, filepath.Base(dst))
+
+		err := copyFile(backup, dst)
+		if os.IsNotExist(err) {
+			err = os.Remove(dst)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Copies the source files to their destinations.
+// Binaries are made executable whatever their mode in the source, which may come from an archive or an image layer.
+func (in *Installer) copyFiles() error {
+	for src, dst := range in.files() {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+
+		if dst == in.configPath() {
+			data, err := in.config()
+			if err != nil {
+				return err
+			}
+
+			if err = writeFile(dst, data, 0644); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := copyFileMode(src, dst, 0755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks that the runtime can load the installed network configuration.
+func (in *Installer) validate() error {
+	data, err := ioutil.ReadFile(in.configPath())
+	if err != nil {
+		return err
+	}
+
+	return ValidateConflist(data, in.BinDir)
+}
+
+// Waits until CNS reports it is healthy, so the new plugin does not run against an unavailable CNS.
+func (in *Installer) waitForCns() error {
+	if in.CnsURL == "" {
+		return nil
+	}
+
+	client := &http.Client{Timeout: cnsRequestTimeout}
+
+	res, err := client.Get(in.CnsURL + cns.GetHealthReportPath)
+	if err != nil {
+		return fmt.Errorf("CNS is not reachable: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("CNS returned http status code %v", res.StatusCode)
+	}
+
+	var resp cns.Response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+
+	if resp.ReturnCode != 0 {
+		return fmt.Errorf("CNS is not healthy: %v", resp.Message)
+	}
+
+	return nil
+}
+
+// Copies a file, replacing the destination atomically.
+func copyFile(src string, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	return copyFileMode(src, dst, info.Mode())
+}
+
+// Copies a file with the given mode, replacing the destination atomically.
+func copyFileMode(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	// The mode given at creation is subject to the umask.
+	if err = out.Chmod(perm); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return rename(tmp, dst)
+}
+
+// Writes a file, replacing the destination atomically.
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+
+	return rename(tmp, name)
+}
+
+// Renames a file, replacing the destination.
+func rename(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if err != nil && runtime.GOOS == "windows" {
+		// Running executables cannot be replaced on Windows, but they can be moved aside.
+		os.Remove(dst + ".old")
+		if os.Rename(dst, dst+".old") == nil {
+			err = os.Rename(src, dst)
+		}
+	}
+
+	if err != nil {
+		os.Remove(src)
+	}
+
+	return err
+}
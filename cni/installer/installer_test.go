@@ -0,0 +1,129 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Creates an installer whose source directory holds fake binaries reporting the given version.
+func newTestInstaller(t *testing.T, version string) (*Installer, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake binaries are shell scripts")
+	}
+
+	root, err := ioutil.TempDir("", "installer")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	in := &Installer{
+		SourceDir:  filepath.Join(root, "source"),
+		BinDir:     filepath.Join(root, "bin"),
+		ConfDir:    filepath.Join(root, "conf"),
+		StateDir:   filepath.Join(root, "state"),
+		Version:    version,
+		ConfigFile: "10-azure.conflist",
+	}
+
+	writeSource(t, in, version)
+
+	return in, func() { os.RemoveAll(root) }
+}
+
+// Writes fake binaries reporting the given version to the source directory.
+func writeSource(t *testing.T, in *Installer, version string) {
+	if err := os.MkdirAll(in.SourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	script := "#!/bin/sh\n" +
+		"if [ \"$CNI_COMMAND\" = VERSION ]; then echo '{\"supportedVersions\":[\"0.3.0\"]}'; exit 0; fi\n" +
+		"echo " + versionPrefix + version + "\n"
+	for _, name := range binaries {
+		if err := ioutil.WriteFile(filepath.Join(in.SourceDir, name), []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write binary: %v", err)
+		}
+	}
+
+	conf := []byte(`{"name":"azure","version":"` + version + `"}`)
+	if err := ioutil.WriteFile(filepath.Join(in.SourceDir, in.ConfigFile), conf, 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+}
+
+// Tests that a fresh node gets the files installed and the state recorded.
+func TestReconcileInstalls(t *testing.T) {
+	in, cleanup := newTestInstaller(t, "v1.0.0")
+	defer cleanup()
+
+	if err := in.Reconcile(); err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+
+	if version, err := in.GetInstalledVersion(); err != nil || version != "v1.0.0" {
+		t.Errorf("Unexpected installed version %v, err:%v", version, err)
+	}
+
+	state, err := in.GetState()
+	if err != nil || state.Version != "v1.0.0" || state.PreviousVersion != "" {
+		t.Errorf("Unexpected state %+v, err:%v", state, err)
+	}
+}
+
+// Tests that a version skew is detected and upgraded, and that the upgrade can be rolled back.
+func TestReconcileUpgradesAndRollsBack(t *testing.T) {
+	in, cleanup := newTestInstaller(t, "v1.0.0")
+	defer cleanup()
+
+	if err := in.Reconcile(); err != nil {
+		t.Fatalf("Failed to install: %v", err)
+	}
+
+	in.Version = "v1.1.0"
+	writeSource(t, in, "v1.1.0")
+
+	if err := in.Reconcile(); err != nil {
+		t.Fatalf("Failed to upgrade: %v", err)
+	}
+
+	state, err := in.GetState()
+	if err != nil || state.Version != "v1.1.0" || state.PreviousVersion != "v1.0.0" {
+		t.Errorf("Unexpected state %+v, err:%v", state, err)
+	}
+
+	if err := in.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+
+	if version, err := in.GetInstalledVersion(); err != nil || version != "v1.0.0" {
+		t.Errorf("Unexpected version after rollback %v, err:%v", version, err)
+	}
+}
+
+// Tests that files reporting an unexpected version are rolled back.
+func TestInstallRollsBackBrokenUpgrade(t *testing.T) {
+	in, cleanup := newTestInstaller(t, "v1.0.0")
+	defer cleanup()
+
+	if err := in.Reconcile(); err != nil {
+		t.Fatalf("Failed to install: %v", err)
+	}
+
+	// The source files do not match the installer version.
+	in.Version = "v1.1.0"
+	writeSource(t, in, "v1.0.1")
+
+	if err := in.Reconcile(); err == nil {
+		t.Fatalf("Broken upgrade did not fail")
+	}
+
+	if version, err := in.GetInstalledVersion(); err != nil || version != "v1.0.0" {
+		t.Errorf("Broken upgrade was not rolled back, version:%v err:%v", version, err)
+	}
+}
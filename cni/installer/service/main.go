@@ -0,0 +1,146 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package main
+
+// Entry point of the CNI installer, run as a DaemonSet on each node.
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni/installer"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	name = "azure-cni-installer"
+)
+
+// Version is populated by make during build.
+var version string
+
+func main() {
+	sourceDir := flag.String("source-dir", "/azure-cni", "Directory containing the CNI files to install")
+	binDir := flag.String("bin-dir", "/opt/cni/bin", "Directory to install the CNI binaries to")
+	confDir := flag.String("conf-dir", "/etc/cni/net.d", "Directory to install the CNI network configuration to")
+	stateDir := flag.String("state-dir", "/var/lib/azure-cni-installer", "Directory to keep the installer state and backups in")
+	configFile := flag.String("config-file", "10-azure.conflist", "Name of the CNI network configuration file")
+	cnsURL := flag.String("cns-url", "", "CNS URL to wait for before upgrading, and of the generated overlay network, empty to not wait")
+	interval := flag.Duration("interval", time.Minute, "Interval at which the installation is checked")
+	rollback := flag.Bool("rollback", false, "Restore the previously installed files and exit")
+	once := flag.Bool("once", false, "Install the files if needed and exit, instead of checking the installation periodically")
+	validate := flag.Bool("validate", true, "Check that the runtime can load the installed network configuration, rolling back otherwise")
+	networkMode := flag.String("network-mode", "", "Generate the network configuration for the given mode, bridge, transparent, tunnel or overlay, instead of installing the one in the source directory")
+	bridge := flag.String("bridge", "", "Name of the bridge of the generated network configuration")
+	multiTenancy := flag.Bool("multitenancy", false, "Enable multitenancy in the generated network configuration")
+	dualStack := flag.Bool("dual-stack", false, "Assign IPv6 addresses besides IPv4 addresses in the generated network configuration")
+	podCIDRs := flag.String("pod-cidrs", "", "Comma separated pod subnets of the node allocated by host-local in dual-stack and overlay networks")
+	overlayVNI := flag.Int("overlay-vni", 0, "VXLAN network identifier of the generated overlay network")
+	clusterCIDR := flag.String("cluster-cidr", "", "Cluster CIDR whose traffic isn't masqueraded in the generated overlay network")
+	dnsServers := flag.String("dns-servers", "", "Comma separated DNS servers of Windows pods in the generated network configuration")
+	dnsSearch := flag.String("dns-search", "", "Comma separated DNS search domains of Windows pods in the generated network configuration")
+	natExceptions := flag.String("outbound-nat-exceptions", "", "Comma separated destinations reached without outbound NAT from Windows pods in the generated network configuration")
+	printConflist := flag.Bool("print-conflist", false, "Print the generated network configuration and exit")
+	printVersion := flag.Bool("v", false, "Print version information")
+	flag.Parse()
+
+	if *printVersion {
+		fmt.Printf("Azure CNI Installer Version %v\n", version)
+		return
+	}
+
+	var conflist *installer.ConflistConfig
+	if *networkMode != "" {
+		conflist = &installer.ConflistConfig{
+			Mode:                  *networkMode,
+			Bridge:                *bridge,
+			MultiTenancy:          *multiTenancy,
+			DualStack:             *dualStack,
+			PodCIDRs:              splitList(*podCIDRs),
+			OverlayVNI:            *overlayVNI,
+			ClusterCIDR:           *clusterCIDR,
+			CNSURL:                *cnsURL,
+			DNSServers:            splitList(*dnsServers),
+			DNSSearch:             splitList(*dnsSearch),
+			OutboundNATExceptions: splitList(*natExceptions),
+		}
+
+		data, err := installer.GenerateConflist(*conflist)
+		if err != nil {
+			fmt.Printf("Failed to generate network configuration, err:%v.\n", err)
+			os.Exit(1)
+		}
+
+		if *printConflist {
+			fmt.Print(string(data))
+			return
+		}
+	}
+
+	log.SetName(name)
+	log.SetLevel(log.LevelInfo)
+	if err := log.SetTarget(log.TargetStdout); err != nil {
+		fmt.Printf("Failed to configure logging, err:%v.\n", err)
+		os.Exit(1)
+	}
+
+	kvs, err := store.NewJsonFileStore(platform.CNIRuntimePath + "azure-vnet.json")
+	if err != nil {
+		log.Printf("[installer] Failed to create CNI store, err:%v.", err)
+		os.Exit(1)
+	}
+
+	in := &installer.Installer{
+		SourceDir:  *sourceDir,
+		BinDir:     *binDir,
+		ConfDir:    *confDir,
+		StateDir:   *stateDir,
+		Version:    version,
+		ConfigFile: *configFile,
+		Store:      kvs,
+		CnsURL:     *cnsURL,
+		Conflist:   conflist,
+		Validate:   *validate,
+	}
+
+	if *rollback {
+		if err := in.Rollback(); err != nil {
+			log.Printf("[installer] Failed to roll back, err:%v.", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	log.Printf("[installer] Installer version %v started.", version)
+
+	for {
+		err := in.Reconcile()
+		if err != nil {
+			log.Printf("[installer] Failed to reconcile installation, err:%v.", err)
+		}
+
+		if *once {
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+
+		time.Sleep(*interval)
+	}
+}
+
+// Splits a comma separated list.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-container-networking/ipam"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
 
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
@@ -115,11 +116,27 @@ func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error
 		plugin.SetOption(common.OptIpamQueryInterval, i)
 	}
 
+	// Set query URL.
+	if nwCfg.Ipam.QueryURL != "" {
+		plugin.SetOption(common.OptIpamQueryUrl, nwCfg.Ipam.QueryURL)
+	}
+
+	// Set excluded address ranges.
+	plugin.SetOption(common.OptIpamExcludedRanges, nwCfg.Ipam.ExcludedRanges)
+
 	err = plugin.am.StartSource(plugin.Options)
 	if err != nil {
 		return nil, err
 	}
 
+	// Adopt the addresses already assigned on the node once, before allocating any.
+	if nwCfg.Ipam.Survey && !plugin.am.Surveyed() {
+		err = plugin.adoptAssignedAddresses()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Set default address space if not specified.
 	if nwCfg.Ipam.AddrSpace == "" {
 		nwCfg.Ipam.AddrSpace = ipam.LocalDefaultAddressSpaceId
@@ -146,7 +163,7 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 	// Parse network configuration from stdin.
 	nwCfg, err := plugin.Configure(args.StdinData)
 	if err != nil {
-		err = plugin.Errorf("Failed to parse network configuration: %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v", err)
 		return err
 	}
 
@@ -162,7 +179,7 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 		// Allocate an address pool.
 		poolID, subnet, err = plugin.am.RequestPool(nwCfg.Ipam.AddrSpace, "", "", options, false)
 		if err != nil {
-			err = plugin.Errorf("Failed to allocate pool: %v", err)
+			err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to allocate pool: %v", err)
 			return err
 		}
 
@@ -181,7 +198,7 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 	// Allocate an address for the endpoint.
 	address, err := plugin.am.RequestAddress(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.Subnet, nwCfg.Ipam.Address, nil)
 	if err != nil {
-		err = plugin.Errorf("Failed to allocate address: %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to allocate address: %v", err)
 		return err
 	}
 
@@ -267,7 +284,7 @@ func (plugin *ipamPlugin) Delete(args *cniSkel.CmdArgs) error {
 	// Parse network configuration from stdin.
 	nwCfg, err := plugin.Configure(args.StdinData)
 	if err != nil {
-		err = plugin.Errorf("Failed to parse network configuration: %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v", err)
 		return err
 	}
 
@@ -276,14 +293,14 @@ func (plugin *ipamPlugin) Delete(args *cniSkel.CmdArgs) error {
 		// Release the address.
 		err := plugin.am.ReleaseAddress(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.Subnet, nwCfg.Ipam.Address, nil)
 		if err != nil {
-			err = plugin.Errorf("Failed to release address: %v", err)
+			err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to release address: %v", err)
 			return err
 		}
 	} else {
 		// Release the pool.
 		err := plugin.am.ReleasePool(nwCfg.Ipam.AddrSpace, nwCfg.Ipam.Subnet)
 		if err != nil {
-			err = plugin.Errorf("Failed to release pool: %v", err)
+			err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to release pool: %v", err)
 			return err
 		}
 	}
@@ -295,3 +312,46 @@ func (plugin *ipamPlugin) Delete(args *cniSkel.CmdArgs) error {
 func (plugin *ipamPlugin) Update(args *cniSkel.CmdArgs) error {
 	return nil
 }
+
+// GetIPAMDetails returns the metrics of the address manager reported to telemetry, with the cause of the given
+// failure of the command, that of the last failed operation of the address manager if the error wraps it.
+func (plugin *ipamPlugin) GetIPAMDetails(err error) *telemetry.IPAMInfo {
+	metrics := plugin.am.GetMetrics()
+	details := &telemetry.IPAMInfo{}
+
+	for _, operation := range metrics.Operations {
+		info := telemetry.IPAMOperationInfo{
+			Operation:      operation.Operation,
+			Count:          int(operation.Count),
+			MaxLatencyMs:   operation.MaxLatencyMs,
+			TotalLatencyMs: operation.TotalLatencyMs,
+		}
+
+		for cause, count := range operation.Failures {
+			if info.Failures == nil {
+				info.Failures = make(map[string]int)
+			}
+			info.Failures[cause] = int(count)
+		}
+
+		details.Operations = append(details.Operations, info)
+	}
+
+	for _, pool := range metrics.Pools {
+		details.Pools = append(details.Pools, telemetry.IPAMPoolInfo{
+			PoolID:   pool.PoolId,
+			Capacity: pool.Capacity,
+			InUse:    pool.InUse,
+		})
+	}
+
+	if err != nil {
+		// Errors of the address manager are wrapped in CNI errors, their cause is that of the failed operation.
+		details.FailureCause = ipam.GetFailureCause(err)
+		if details.FailureCause == ipam.FailureCauseOther && metrics.LastFailureCause != "" {
+			details.FailureCause = metrics.LastFailureCause
+		}
+	}
+
+	return details
+}
@@ -6,15 +6,57 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cni/ipam"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+const (
+	// Plugin name.
+	pluginName = "azure-vnet-ipam"
 )
 
 // Version is populated by make during build.
 var version string
 
+// Sends the telemetry report of the command with the metrics of the address manager. The report is queued until
+// the telemetry service runs, it is started by the network plugin.
+func sendReport(details *telemetry.IPAMInfo, err error) {
+	cniReport := &telemetry.CNIReport{
+		Name:          pluginName,
+		Version:       version,
+		Context:       "AzureCNIIPAM",
+		OperationType: os.Getenv("CNI_COMMAND"),
+		CniSucceeded:  err == nil,
+		Timestamp:     time.Now().Format("2006-01-02 15:04:05"),
+		IPAMDetails:   details,
+	}
+
+	if err != nil {
+		cniReport.ErrorMessage = err.Error()
+		cniReport.ErrorCode = cni.GetErrorCode(err)
+		cniReport.ErrorCodeName = cni.GetErrorCodeName(cniReport.ErrorCode)
+	}
+
+	reportManager := &telemetry.ReportManager{
+		ContentType: telemetry.ContentType,
+		QueueDir:    telemetry.ReportQueueDir,
+		Report:      cniReport,
+	}
+
+	tb := telemetry.NewTelemetryBuffer("")
+	if tb.Connect() == nil {
+		tb.Connected = true
+	}
+
+	if err := reportManager.SendReport(tb); err != nil {
+		fmt.Printf("Failed to send telemetry report of ipam plugin, err:%v.\n", err)
+	}
+}
+
 // Main is the entry point for CNI IPAM plugin.
 func main() {
 	var config common.PluginConfig
@@ -28,6 +70,7 @@ func main() {
 
 	if err := ipamPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
 		fmt.Printf("Failed to initialize key-value store of ipam plugin, err:%v.\n", err)
+		sendReport(ipamPlugin.GetIPAMDetails(err), err)
 		os.Exit(1)
 	}
 
@@ -44,10 +87,12 @@ func main() {
 	err = ipamPlugin.Start(&config)
 	if err != nil {
 		fmt.Printf("Failed to start IPAM plugin, err:%v.\n", err)
+		sendReport(ipamPlugin.GetIPAMDetails(err), err)
 		panic("ipam plugin fatal error")
 	}
 
 	err = ipamPlugin.Execute(cni.PluginApi(ipamPlugin))
+	sendReport(ipamPlugin.GetIPAMDetails(err), err)
 
 	ipamPlugin.Stop()
 
@@ -0,0 +1,48 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// adoptAssignedAddresses scans the node for the addresses already assigned to interfaces and containers,
+// and marks them in use, so that reinstalling the plugin on a live node doesn't allocate them twice.
+func (plugin *ipamPlugin) adoptAssignedAddresses() error {
+	addrs, err := getInterfaceAddresses()
+	if err != nil {
+		log.Printf("[cni-ipam] Failed to query host interface addresses, err:%v.", err)
+		return err
+	}
+
+	addrs = append(addrs, getContainerAddresses()...)
+
+	adopted, err := plugin.am.AdoptAddresses(addrs)
+	if err != nil {
+		log.Printf("[cni-ipam] Failed to adopt addresses, err:%v.", err)
+		return err
+	}
+
+	log.Printf("[cni-ipam] Surveyed %v addresses assigned on the node, adopted %v.", len(addrs), adopted)
+	return nil
+}
+
+// getInterfaceAddresses returns the addresses assigned to the interfaces of the current network namespace.
+func getInterfaceAddresses() ([]net.IP, error) {
+	ifAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []net.IP
+	for _, ifAddr := range ifAddrs {
+		if ipNet, ok := ifAddr.(*net.IPNet); ok {
+			addrs = append(addrs, ipNet.IP)
+		}
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+)
+
+// Directories container runtimes bind mount network namespaces in.
+var netnsDirs = []string{"/var/run/netns", "/var/run/docker/netns"}
+
+// getContainerAddresses returns the addresses assigned in the network namespaces of the node other than
+// the host's, found both in the bind mount directories and through the running processes.
+// Namespaces that can't be entered are skipped.
+func getContainerAddresses() []net.IP {
+	hostNs, err := os.Stat(network.GetProcessNamespacePath(os.Getpid()))
+	if err != nil {
+		log.Printf("[cni-ipam] Failed to query host network namespace, err:%v.", err)
+		return nil
+	}
+
+	var nsPaths []string
+	for _, dir := range netnsDirs {
+		files, _ := ioutil.ReadDir(dir)
+		for _, file := range files {
+			nsPaths = append(nsPaths, filepath.Join(dir, file.Name()))
+		}
+	}
+
+	procPaths, _ := filepath.Glob("/proc/[0-9]*/ns/net")
+	nsPaths = append(nsPaths, procPaths...)
+
+	// Namespaces already scanned, identified by their inode.
+	seen := []os.FileInfo{hostNs}
+
+	var addrs []net.IP
+	for _, nsPath := range nsPaths {
+		info, err := os.Stat(nsPath)
+		if err != nil || isSeenNamespace(info, seen) {
+			continue
+		}
+		seen = append(seen, info)
+
+		nsAddrs, err := getNamespaceAddresses(nsPath)
+		if err != nil {
+			log.Printf("[cni-ipam] Skipping network namespace %v, err:%v.", nsPath, err)
+			continue
+		}
+
+		addrs = append(addrs, nsAddrs...)
+	}
+
+	return addrs
+}
+
+// isSeenNamespace returns whether a namespace file refers to one of the given namespaces.
+func isSeenNamespace(info os.FileInfo, seen []os.FileInfo) bool {
+	for _, s := range seen {
+		if os.SameFile(info, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getNamespaceAddresses returns the addresses assigned to the interfaces of a network namespace.
+func getNamespaceAddresses(nsPath string) ([]net.IP, error) {
+	ns, err := network.OpenNamespace(nsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer ns.Close()
+
+	if err = ns.Enter(); err != nil {
+		return nil, err
+	}
+	defer ns.Exit()
+
+	return getInterfaceAddresses()
+}
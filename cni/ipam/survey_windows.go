@@ -0,0 +1,29 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Microsoft/hcsshim"
+)
+
+// getContainerAddresses returns the addresses of the HNS endpoints of the node.
+func getContainerAddresses() []net.IP {
+	endpoints, err := hcsshim.HNSListEndpointRequest()
+	if err != nil {
+		log.Printf("[cni-ipam] Failed to list HNS endpoints, err:%v.", err)
+		return nil
+	}
+
+	var addrs []net.IP
+	for _, endpoint := range endpoints {
+		if endpoint.IPAddress != nil {
+			addrs = append(addrs, endpoint.IPAddress)
+		}
+	}
+
+	return addrs
+}
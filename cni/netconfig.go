@@ -4,7 +4,10 @@
 package cni
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"net"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/network/policy"
@@ -16,6 +19,13 @@ const (
 	PolicyStr string = "Policy"
 )
 
+// Standard CNI IPAM plugins that the network plugin can delegate to instead of Azure IPAM.
+const (
+	IpamHostLocal = "host-local"
+	IpamStatic    = "static"
+	IpamDhcp      = "dhcp"
+)
+
 // KVPair represents a K-V pair of a json object.
 type KVPair struct {
 	Name  string          `json:"name"`
@@ -29,37 +39,150 @@ type PortMapping struct {
 	HostIp        string `json:"hostIP,omitempty"`
 }
 
+// ArpConfig describes the ARP settings of the master interface and of the endpoints.
+type ArpConfig struct {
+	ProxyArp         *bool `json:"proxyArp,omitempty"`
+	Announce         *int  `json:"arpAnnounce,omitempty"`
+	Ignore           *int  `json:"arpIgnore,omitempty"`
+	EndpointProxyArp *bool `json:"endpointProxyArp,omitempty"`
+	Announcements    int   `json:"announcements,omitempty"`
+}
+
+// SriovConfig describes the SR-IOV virtual function passed to pods.
+type SriovConfig struct {
+	VfName string `json:"vf,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// CNSAuthConfig describes how the plugin authenticates to CNS.
+type CNSAuthConfig struct {
+	CAFile    string `json:"caFile,omitempty"`
+	CertFile  string `json:"certFile,omitempty"`
+	KeyFile   string `json:"keyFile,omitempty"`
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// CNSClientConfig describes how the plugin handles failed requests to CNS.
+type CNSClientConfig struct {
+	TimeoutSeconds         int `json:"timeoutSeconds,omitempty"`
+	MaxRetries             int `json:"maxRetries,omitempty"`
+	RetryDelayMs           int `json:"retryDelayMs,omitempty"`
+	BreakerThreshold       int `json:"breakerThreshold,omitempty"`
+	BreakerCooldownSeconds int `json:"breakerCooldownSeconds,omitempty"`
+}
+
+// WarmPoolConfig describes the endpoints prepared ahead of pod creation.
+type WarmPoolConfig struct {
+	Size int `json:"size,omitempty"`
+}
+
+// OverlayConfig describes the VXLAN tunnels of overlay mode networks.
+type OverlayConfig struct {
+	VNI         int    `json:"vni"`
+	Port        int    `json:"port,omitempty"`
+	MTU         int    `json:"mtu,omitempty"`
+	ClusterCIDR string `json:"clusterCIDR,omitempty"`
+}
+
+// Nat64Config describes the NAT64 translator of IPv6-only networks.
+type Nat64Config struct {
+	Prefix      string `json:"prefix,omitempty"`
+	Pool        string `json:"pool,omitempty"`
+	DNS64Server string `json:"dns64Server,omitempty"`
+}
+
+// EgressIPConfig describes the addresses pods may request as the source of their traffic leaving the node, with
+// the egress IP annotation, and the addresses of the pool the pods of each namespace may request.
+// Traffic to the excluded prefixes and to the subnet of the network keeps the pod address.
+type EgressIPConfig struct {
+	Pool       []string            `json:"pool,omitempty"`
+	Namespaces map[string][]string `json:"namespaces,omitempty"`
+	Exclusions []string            `json:"exclusions,omitempty"`
+}
+
+// PodTokensConfig describes the tokens CNS issues to pods for the scopes of the restricted endpoints they may reach,
+// and the directory the tokens are written to for the pods to mount.
+type PodTokensConfig struct {
+	Scopes    []string `json:"scopes,omitempty"`
+	Directory string   `json:"directory,omitempty"`
+}
+
+// RuntimeDNSConfig describes the DNS settings passed by the runtime for a container, with the dns capability.
+type RuntimeDNSConfig struct {
+	Servers  []string `json:"servers,omitempty"`
+	Searches []string `json:"searches,omitempty"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// RuntimeMirrorConfig describes the mirroring of the traffic of a new endpoint to a local capture interface,
+// for a bounded duration, passed by the runtime with the mirror capability.
+type RuntimeMirrorConfig struct {
+	Interface       string `json:"interface"`
+	DurationSeconds int    `json:"durationSeconds,omitempty"`
+}
+
 type RuntimeConfig struct {
-	PortMappings []PortMapping `json:"portMappings,omitempty"`
+	PortMappings          []PortMapping        `json:"portMappings,omitempty"`
+	OutBoundNatExceptions []string             `json:"outBoundNatExceptions,omitempty"`
+	LoopbackDSR           bool                 `json:"loopbackDSR,omitempty"`
+	DNS                   RuntimeDNSConfig     `json:"dns,omitempty"`
+	Mirror                *RuntimeMirrorConfig `json:"mirror,omitempty"`
 }
 
 // NetworkConfig represents Azure CNI plugin network configuration.
 type NetworkConfig struct {
-	CNIVersion                 string   `json:"cniVersion"`
-	Name                       string   `json:"name"`
-	Type                       string   `json:"type"`
-	Mode                       string   `json:"mode"`
-	Master                     string   `json:"master"`
-	Bridge                     string   `json:"bridge,omitempty"`
-	LogLevel                   string   `json:"logLevel,omitempty"`
-	LogTarget                  string   `json:"logTarget,omitempty"`
-	InfraVnetAddressSpace      string   `json:"infraVnetAddressSpace,omitempty"`
-	PodNamespaceForDualNetwork []string `json:"podNamespaceForDualNetwork,omitempty"`
-	MultiTenancy               bool     `json:"multiTenancy,omitempty"`
-	EnableSnatOnHost           bool     `json:"enableSnatOnHost,omitempty"`
-	EnableExactMatchForPodName bool     `json:"enableExactMatchForPodName,omitempty"`
-	CNSUrl                     string   `json:"cnsurl,omitempty"`
+	CNIVersion                 string           `json:"cniVersion"`
+	Name                       string           `json:"name"`
+	Type                       string           `json:"type"`
+	Mode                       string           `json:"mode"`
+	Master                     string           `json:"master"`
+	MasterMac                  string           `json:"masterMac,omitempty"`
+	Bridge                     string           `json:"bridge,omitempty"`
+	LogLevel                   string           `json:"logLevel,omitempty"`
+	LogTarget                  string           `json:"logTarget,omitempty"`
+	InfraVnetAddressSpace      string           `json:"infraVnetAddressSpace,omitempty"`
+	PodNamespaceForDualNetwork []string         `json:"podNamespaceForDualNetwork,omitempty"`
+	MultiTenancy               bool             `json:"multiTenancy,omitempty"`
+	EnableSnatOnHost           bool             `json:"enableSnatOnHost,omitempty"`
+	EnableExactMatchForPodName bool             `json:"enableExactMatchForPodName,omitempty"`
+	StrictMode                 bool             `json:"strictMode,omitempty"`
+	CNSUrl                     string           `json:"cnsurl,omitempty"`
+	CNSAuth                    *CNSAuthConfig   `json:"cnsAuth,omitempty"`
+	CNSClient                  *CNSClientConfig `json:"cnsClient,omitempty"`
+	ThinClient                 bool             `json:"thinClient,omitempty"`
+	ReportPodEvents            bool             `json:"reportPodEvents,omitempty"`
+	Arp                        *ArpConfig       `json:"arp,omitempty"`
+	Dataplane                  string           `json:"dataplane,omitempty"`
+	Sriov                      *SriovConfig     `json:"sriov,omitempty"`
+	SnatExclusions             []string         `json:"snatExclusions,omitempty"`
+	SnatIPBlock                string           `json:"snatIPBlock,omitempty"`
+	EgressIP                   *EgressIPConfig  `json:"egressIP,omitempty"`
+	WarmPool                   *WarmPoolConfig  `json:"warmPool,omitempty"`
+	PolicyRouting              bool             `json:"policyRouting,omitempty"`
+	Nat64                      *Nat64Config     `json:"nat64,omitempty"`
+	Overlay                    *OverlayConfig   `json:"overlay,omitempty"`
+	VerifyRoutes               bool             `json:"verifyRoutes,omitempty"`
+	Verbose                    bool             `json:"verbose,omitempty"`
+	InterfaceNaming            string           `json:"interfaceNaming,omitempty"`
+	PodTokens                  *PodTokensConfig `json:"podTokens,omitempty"`
 	Ipam                       struct {
-		Type          string `json:"type"`
-		Environment   string `json:"environment,omitempty"`
-		AddrSpace     string `json:"addressSpace,omitempty"`
-		Subnet        string `json:"subnet,omitempty"`
-		Address       string `json:"ipAddress,omitempty"`
-		QueryInterval string `json:"queryInterval,omitempty"`
+		Type           string   `json:"type"`
+		Environment    string   `json:"environment,omitempty"`
+		AddrSpace      string   `json:"addressSpace,omitempty"`
+		Subnet         string   `json:"subnet,omitempty"`
+		Address        string   `json:"ipAddress,omitempty"`
+		Gateway        string   `json:"gateway,omitempty"`
+		QueryInterval  string   `json:"queryInterval,omitempty"`
+		QueryURL       string   `json:"queryUrl,omitempty"`
+		ExcludedRanges []string `json:"excludedRanges,omitempty"`
+		Survey         bool     `json:"survey,omitempty"`
 	}
 	DNS            cniTypes.DNS  `json:"dns"`
 	RuntimeConfig  RuntimeConfig `json:"runtimeConfig"`
 	AdditionalArgs []KVPair
+
+	// Raw ipam section, passed unchanged to standard IPAM plugins.
+	rawIpam json.RawMessage
 }
 
 type K8SPodEnvArgs struct {
@@ -93,9 +216,100 @@ func ParseNetworkConfig(b []byte) (*NetworkConfig, error) {
 		nwCfg.CNIVersion = defaultVersion
 	}
 
+	if nwCfg.IsStandardIpam() {
+		if nwCfg.MultiTenancy {
+			return nil, fmt.Errorf("IPAM plugin %v can't be used with multitenancy", nwCfg.Ipam.Type)
+		}
+
+		var rawCfg struct {
+			Ipam json.RawMessage `json:"ipam"`
+		}
+		if err := json.Unmarshal(b, &rawCfg); err != nil {
+			return nil, err
+		}
+
+		nwCfg.rawIpam = rawCfg.Ipam
+	}
+
+	if nwCfg.WarmPool != nil && nwCfg.WarmPool.Size > 0 && (nwCfg.MultiTenancy || nwCfg.IsStandardIpam()) {
+		return nil, fmt.Errorf("Warm pool can't be used with multitenancy or IPAM plugin %v", nwCfg.Ipam.Type)
+	}
+
+	if nwCfg.Mode == "overlay" && (nwCfg.Overlay == nil || nwCfg.MultiTenancy) {
+		return nil, fmt.Errorf("Overlay mode requires the overlay settings and can't be used with multitenancy")
+	}
+
+	// Interface names may change across reboots on nodes with multiple NICs, their MAC addresses don't.
+	if nwCfg.Master == "" && nwCfg.MasterMac != "" {
+		if nwCfg.Master, err = findInterfaceByMac(nwCfg.MasterMac); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, exclusion := range nwCfg.SnatExclusions {
+		if _, _, err := net.ParseCIDR(exclusion); err != nil {
+			return nil, fmt.Errorf("Invalid SNAT exclusion %v: %v", exclusion, err)
+		}
+	}
+
+	if nwCfg.EgressIP != nil {
+		for _, address := range nwCfg.EgressIP.Pool {
+			if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("Invalid egress IP %v in pool", address)
+			}
+		}
+
+		for namespace, addresses := range nwCfg.EgressIP.Namespaces {
+			for _, address := range addresses {
+				if !containsAddress(nwCfg.EgressIP.Pool, address) {
+					return nil, fmt.Errorf("Egress IP %v of namespace %v is not in pool", address, namespace)
+				}
+			}
+		}
+
+		for _, exclusion := range nwCfg.EgressIP.Exclusions {
+			if ip, _, err := net.ParseCIDR(exclusion); err != nil || ip.To4() == nil {
+				return nil, fmt.Errorf("Invalid egress IP exclusion %v", exclusion)
+			}
+		}
+	}
+
 	return &nwCfg, nil
 }
 
+// containsAddress returns whether a list of addresses contains the given address.
+func containsAddress(addresses []string, address string) bool {
+	ip := net.ParseIP(address)
+	for _, a := range addresses {
+		if ip != nil && ip.Equal(net.ParseIP(a)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findInterfaceByMac returns the name of the interface with the given MAC address.
+func findInterfaceByMac(macAddress string) (string, error) {
+	mac, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return "", fmt.Errorf("Invalid master MAC address %v: %v", macAddress, err)
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+
+	for _, iface := range interfaces {
+		if bytes.Equal(iface.HardwareAddr, mac) {
+			return iface.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("Failed to find the interface with MAC address %v", macAddress)
+}
+
 // GetPoliciesFromNwCfg returns network policies from network config.
 func GetPoliciesFromNwCfg(kvp []KVPair) []policy.Policy {
 	var policies []policy.Policy
@@ -112,8 +326,38 @@ func GetPoliciesFromNwCfg(kvp []KVPair) []policy.Policy {
 	return policies
 }
 
+// IsStandardIpam returns whether addresses are allocated by a standard CNI IPAM plugin.
+// Standard IPAM plugins allocate addresses per container and release them by container ID,
+// so they have no address pools and ignore the subnet and address set by the network plugin.
+func (nwcfg *NetworkConfig) IsStandardIpam() bool {
+	switch nwcfg.Ipam.Type {
+	case IpamHostLocal, IpamStatic, IpamDhcp:
+		return true
+	default:
+		return false
+	}
+}
+
 // Serialize marshals a network configuration to bytes.
 func (nwcfg *NetworkConfig) Serialize() []byte {
 	bytes, _ := json.Marshal(nwcfg)
 	return bytes
 }
+
+// serializeForIpam marshals a network configuration to bytes for its IPAM plugin.
+// Standard IPAM plugins get the ipam section as it was configured, including their own settings.
+func (nwcfg *NetworkConfig) serializeForIpam() []byte {
+	if !nwcfg.IsStandardIpam() || nwcfg.rawIpam == nil {
+		return nwcfg.Serialize()
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(nwcfg.Serialize(), &fields); err != nil {
+		return nwcfg.Serialize()
+	}
+
+	delete(fields, "Ipam")
+	fields["ipam"] = nwcfg.rawIpam
+	bytes, _ := json.Marshal(fields)
+	return bytes
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Annotation of the pods requesting an address of the egress IP pool as the source of their traffic leaving
+	// the node, e.g. because external firewalls only allow known addresses.
+	EgressIPAnnotation = "azure.com/egress-ip"
+)
+
+// getEgressIP returns the egress IP requested by a pod, read from its annotations through CNS, and the destinations
+// excluded from its SNAT. Pods without the annotation, or networks without an egress IP pool, have no egress IP.
+// Pods may only request the addresses of the pool allowed to their namespace.
+func (plugin *netPlugin) getEgressIP(nwCfg *cni.NetworkConfig, podName string, podNamespace string) (net.IP, []net.IPNet, error) {
+	if nwCfg.EgressIP == nil || len(nwCfg.EgressIP.Pool) == 0 {
+		return nil, nil, nil
+	}
+
+	cnsClient, err := newCnsClient(nwCfg, nwCfg.CNSUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	annotations, err := cnsClient.GetPodAnnotations(podName, podNamespace)
+	if err != nil {
+		return nil, nil, plugin.ErrorfWithCode(cni.ErrCnsFailure, "Failed to get annotations of pod %v/%v: %v", podNamespace, podName, err)
+	}
+
+	requested, ok := annotations[EgressIPAnnotation]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	egressIP := net.ParseIP(requested)
+	if egressIP == nil || !inEgressIPPool(nwCfg.EgressIP.Pool, egressIP) {
+		return nil, nil, plugin.ErrorfWithCode(cni.ErrInvalidArgs, "Egress IP %v of pod %v/%v is not in the pool of network %v",
+			requested, podNamespace, podName, nwCfg.Name)
+	}
+
+	if !isEgressIPAuthorized(nwCfg.EgressIP, podNamespace, egressIP) {
+		return nil, nil, plugin.ErrorfWithCode(cni.ErrInvalidArgs, "Namespace %v is not allowed egress IP %v of network %v",
+			podNamespace, requested, nwCfg.Name)
+	}
+
+	var exclusions []net.IPNet
+	for _, exclusion := range nwCfg.EgressIP.Exclusions {
+		_, prefix, _ := net.ParseCIDR(exclusion)
+		exclusions = append(exclusions, *prefix)
+	}
+
+	log.Printf("[cni-net] Pod %v/%v requested egress IP %v.", podNamespace, podName, egressIP)
+
+	return egressIP, exclusions, nil
+}
+
+// Returns whether an address is in the egress IP pool.
+func inEgressIPPool(pool []string, ip net.IP) bool {
+	for _, address := range pool {
+		if ip.Equal(net.ParseIP(address)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether the pods of a namespace are allowed an address of the egress IP pool.
+func isEgressIPAuthorized(egressIPCfg *cni.EgressIPConfig, namespace string, ip net.IP) bool {
+	return inEgressIPPool(egressIPCfg.Namespaces[namespace], ip)
+}
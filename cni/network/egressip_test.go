@@ -0,0 +1,36 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+)
+
+func TestIsEgressIPAuthorized(t *testing.T) {
+	egressIPCfg := &cni.EgressIPConfig{
+		Pool: []string{"10.0.0.100", "10.0.0.101"},
+		Namespaces: map[string][]string{
+			"payments": {"10.0.0.100"},
+		},
+	}
+
+	tests := []struct {
+		namespace  string
+		egressIP   string
+		authorized bool
+	}{
+		{"payments", "10.0.0.100", true},
+		{"payments", "10.0.0.101", false},
+		{"default", "10.0.0.100", false},
+	}
+
+	for _, test := range tests {
+		if authorized := isEgressIPAuthorized(egressIPCfg, test.namespace, net.ParseIP(test.egressIP)); authorized != test.authorized {
+			t.Errorf("isEgressIPAuthorized of %v for namespace %v returned %v", test.egressIP, test.namespace, authorized)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+)
+
+const (
+	// Mirroring duration if the runtime or the command line doesn't set one.
+	DefaultMirrorDuration = 5 * time.Minute
+
+	// Environment variables passing the mirrored endpoint to the process stopping the mirroring.
+	mirrorNetworkEnv  = "AZURE_CNI_MIRROR_NETWORK"
+	mirrorEndpointEnv = "AZURE_CNI_MIRROR_ENDPOINT"
+)
+
+// Endpoint whose mirroring is stopped in the background once its deadline passed.
+type mirrorExpiry struct {
+	networkId  string
+	endpointId string
+}
+
+// mirrorEndpoint mirrors the traffic of an endpoint to a capture interface for the given duration, and remembers to
+// stop it in the background after the command completes.
+func (plugin *netPlugin) mirrorEndpoint(networkId string, endpointId string, captureIfName string, duration time.Duration) error {
+	if duration <= 0 {
+		duration = DefaultMirrorDuration
+	}
+
+	mirror := &network.MirrorInfo{Interface: captureIfName, Until: time.Now().Add(duration)}
+	if err := plugin.nm.MirrorEndpoint(networkId, endpointId, mirror); err != nil {
+		return err
+	}
+
+	plugin.mirrorExpiry = &mirrorExpiry{networkId: networkId, endpointId: endpointId}
+	return nil
+}
+
+// Mirrors the traffic of the endpoint created by ADD if the runtime asked for it. Mirroring is a diagnostic aid,
+// so failures are only logged.
+func (plugin *netPlugin) mirrorNewEndpoint(nwCfg *cni.NetworkConfig, networkId string, endpointId string) {
+	mirror := nwCfg.RuntimeConfig.Mirror
+	if mirror == nil || mirror.Interface == "" {
+		return
+	}
+
+	duration := time.Duration(mirror.DurationSeconds) * time.Second
+	if err := plugin.mirrorEndpoint(networkId, endpointId, mirror.Interface, duration); err != nil {
+		log.Printf("[cni-net] Failed to mirror endpoint %v to %v, err:%v.", endpointId, mirror.Interface, err)
+	}
+}
+
+// MirrorInterface mirrors the traffic of the endpoint a host interface belongs to, to debug intermittent connectivity
+// of a running pod. Mirroring stops after the given duration, or immediately without a capture interface.
+func (plugin *netPlugin) MirrorInterface(hostIfName string, captureIfName string, duration time.Duration) error {
+	networkId, epInfo, err := plugin.nm.GetEndpointInfoByHostIfName(hostIfName)
+	if err != nil {
+		return fmt.Errorf("Failed to find endpoint of interface %v: %v", hostIfName, err)
+	}
+
+	if captureIfName == "" {
+		return plugin.nm.StopEndpointMirroring(networkId, epInfo.Id)
+	}
+
+	return plugin.mirrorEndpoint(networkId, epInfo.Id, captureIfName, duration)
+}
+
+// StartMirrorExpiry starts a background process stopping the mirroring started by the last command once its
+// deadline passed.
+func (plugin *netPlugin) StartMirrorExpiry() error {
+	if plugin.mirrorExpiry == nil {
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[cni-net] Starting mirroring expiry process for endpoint %v.", plugin.mirrorExpiry.endpointId)
+
+	args := []string{"-" + common.OptStopMirroring}
+	env := append(os.Environ(),
+		fmt.Sprintf("%v=%s", mirrorNetworkEnv, plugin.mirrorExpiry.networkId),
+		fmt.Sprintf("%v=%s", mirrorEndpointEnv, plugin.mirrorExpiry.endpointId))
+
+	return common.StartProcessWithArgs(path, args, env)
+}
+
+// StopExpiredMirroring stops the mirroring of the endpoint in the environment if its deadline passed, and otherwise
+// returns the time left. Mirroring extended since the process started is waited for too. Zero is returned when
+// there is nothing left to stop, e.g. because the endpoint was deleted.
+func (plugin *netPlugin) StopExpiredMirroring() (time.Duration, error) {
+	networkId := os.Getenv(mirrorNetworkEnv)
+	endpointId := os.Getenv(mirrorEndpointEnv)
+
+	epInfo, err := plugin.nm.GetEndpointInfo(networkId, endpointId)
+	if err != nil || epInfo.Mirror == nil {
+		return 0, nil
+	}
+
+	if remaining := time.Until(epInfo.Mirror.Until); remaining > 0 {
+		return remaining, nil
+	}
+
+	log.Printf("[cni-net] Mirroring of endpoint %v to %v expired.", endpointId, epInfo.Mirror.Interface)
+	return 0, plugin.nm.StopEndpointMirroring(networkId, endpointId)
+}
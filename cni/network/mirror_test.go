@@ -0,0 +1,78 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/network"
+)
+
+// fakeMirrorNetworkManager holds one endpoint and records the mirroring it stops.
+type fakeMirrorNetworkManager struct {
+	network.NetworkManager
+	epInfo  *network.EndpointInfo
+	stopped []string
+}
+
+func (nm *fakeMirrorNetworkManager) GetEndpointInfo(networkId string, endpointId string) (*network.EndpointInfo, error) {
+	if nm.epInfo == nil || endpointId != nm.epInfo.Id {
+		return nil, fmt.Errorf("Endpoint not found")
+	}
+
+	return nm.epInfo, nil
+}
+
+func (nm *fakeMirrorNetworkManager) StopEndpointMirroring(networkId string, endpointId string) error {
+	nm.stopped = append(nm.stopped, endpointId)
+	return nil
+}
+
+func TestStopExpiredMirroring(t *testing.T) {
+	os.Setenv(mirrorNetworkEnv, "azure")
+	os.Setenv(mirrorEndpointEnv, "ep-1")
+	defer os.Unsetenv(mirrorNetworkEnv)
+	defer os.Unsetenv(mirrorEndpointEnv)
+
+	tests := []struct {
+		name      string
+		epInfo    *network.EndpointInfo
+		remaining bool
+		stopped   bool
+	}{
+		{name: "endpoint deleted"},
+		{name: "mirroring stopped", epInfo: &network.EndpointInfo{Id: "ep-1"}},
+		{
+			name:    "expired",
+			epInfo:  &network.EndpointInfo{Id: "ep-1", Mirror: &network.MirrorInfo{Interface: "capture0", Until: time.Now().Add(-time.Second)}},
+			stopped: true,
+		},
+		{
+			name:      "extended",
+			epInfo:    &network.EndpointInfo{Id: "ep-1", Mirror: &network.MirrorInfo{Interface: "capture0", Until: time.Now().Add(time.Hour)}},
+			remaining: true,
+		},
+	}
+
+	for _, test := range tests {
+		nm := &fakeMirrorNetworkManager{epInfo: test.epInfo}
+		plugin := &netPlugin{nm: nm}
+
+		remaining, err := plugin.StopExpiredMirroring()
+		if err != nil {
+			t.Errorf("%v: StopExpiredMirroring failed: %v", test.name, err)
+		}
+
+		if (remaining > 0) != test.remaining {
+			t.Errorf("%v: StopExpiredMirroring returned %v left", test.name, remaining)
+		}
+
+		if (len(nm.stopped) > 0) != test.stopped {
+			t.Errorf("%v: stopped the mirroring of %v", test.name, nm.stopped)
+		}
+	}
+}
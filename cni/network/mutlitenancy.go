@@ -2,10 +2,10 @@ package network
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/platform"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
 )
@@ -29,6 +30,7 @@ func SetupRoutingForMultitenancy(
 		if nwCfg.EnableSnatOnHost {
 			log.Printf("add default route for multitenancy.snat on host enabled")
 			addDefaultRoute(cnsNetworkConfig.LocalIPConfiguration.GatewayIPAddress, epInfo, result)
+			addSnatExclusionRoutes(nwCfg.SnatExclusions, cnsNetworkConfig.IPConfiguration.GatewayIPAddress, epInfo, result)
 		} else {
 			_, defaultIPNet, _ := net.ParseCIDR("0.0.0.0/0")
 			dstIP := net.IPNet{IP: net.ParseIP("0.0.0.0"), Mask: defaultIPNet.Mask}
@@ -56,15 +58,50 @@ func getContainerNetworkConfiguration(
 	}
 
 	log.Printf("Podname without suffix %v", podNameWithoutSuffix)
-	return getContainerNetworkConfigurationInternal(address, podNamespace, podNameWithoutSuffix, ifName)
+	return getContainerNetworkConfigurationInternal(nwCfg, address, podNamespace, podNameWithoutSuffix, ifName)
+}
+
+// Creates a CNS client authenticating and handling failed requests as described by the network configuration.
+// The circuit breaker state is shared by plugin invocations, so that they fail fast while CNS is down.
+func newCnsClient(nwCfg *cni.NetworkConfig, address string) (*cnsclient.CNSClient, error) {
+	return newCnsClientWithTimeout(nwCfg, address, 0)
+}
+
+// Creates a CNS client as described by the network configuration, abandoning requests after the given timeout
+// unless the configuration sets one.
+func newCnsClientWithTimeout(nwCfg *cni.NetworkConfig, address string, timeout time.Duration) (*cnsclient.CNSClient, error) {
+	config := cnsclient.ClientConfig{
+		Timeout:          timeout,
+		BreakerStateFile: platform.CNIRuntimePath + cnsClientStateFileName,
+	}
+
+	if nwCfg.CNSAuth != nil {
+		config.CAFile = nwCfg.CNSAuth.CAFile
+		config.CertFile = nwCfg.CNSAuth.CertFile
+		config.KeyFile = nwCfg.CNSAuth.KeyFile
+		config.TokenFile = nwCfg.CNSAuth.TokenFile
+	}
+
+	if nwCfg.CNSClient != nil {
+		if nwCfg.CNSClient.TimeoutSeconds > 0 {
+			config.Timeout = time.Duration(nwCfg.CNSClient.TimeoutSeconds) * time.Second
+		}
+		config.MaxRetries = nwCfg.CNSClient.MaxRetries
+		config.RetryDelay = time.Duration(nwCfg.CNSClient.RetryDelayMs) * time.Millisecond
+		config.BreakerThreshold = nwCfg.CNSClient.BreakerThreshold
+		config.BreakerCooldown = time.Duration(nwCfg.CNSClient.BreakerCooldownSeconds) * time.Second
+	}
+
+	return cnsclient.NewCnsClientWithConfig(address, config)
 }
 
 func getContainerNetworkConfigurationInternal(
+	nwCfg *cni.NetworkConfig,
 	address string,
 	namespace string,
 	podName string,
 	ifName string) (*cniTypesCurr.Result, *cns.GetNetworkContainerResponse, net.IPNet, error) {
-	cnsClient, err := cnsclient.NewCnsClient(address)
+	cnsClient, err := newCnsClient(nwCfg, address)
 	if err != nil {
 		log.Printf("Initializing CNS client error %v", err)
 		return nil, nil, net.IPNet{}, err
@@ -87,9 +124,9 @@ func getContainerNetworkConfigurationInternal(
 
 	subnetPrefix := common.GetInterfaceSubnetWithSpecificIp(networkConfig.PrimaryInterfaceIdentifier)
 	if subnetPrefix == nil {
-		errBuf := fmt.Sprintf("Interface not found for this ip %v", networkConfig.PrimaryInterfaceIdentifier)
-		log.Printf(errBuf)
-		return nil, nil, net.IPNet{}, fmt.Errorf(errBuf)
+		err := fmt.Errorf("Interface not found for this ip %v", networkConfig.PrimaryInterfaceIdentifier)
+		log.Printf("%v", err)
+		return nil, nil, net.IPNet{}, err
 	}
 
 	return convertToCniResult(networkConfig, ifName), networkConfig, *subnetPrefix, nil
@@ -161,7 +198,7 @@ func getInfraVnetIP(
 		log.Printf("call ipam to allocate ip from subnet %v", nwCfg.Ipam.Subnet)
 		azIpamResult, err := plugin.DelegateAdd(nwCfg.Ipam.Type, nwCfg)
 		if err != nil {
-			err = plugin.Errorf("Failed to allocate address: %v", err)
+			err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to allocate address: %v", err)
 			return nil, err
 		}
 
@@ -224,9 +261,8 @@ func GetMultiTenancyCNIResult(
 		log.Printf("PrimaryInterfaceIdentifier :%v", subnetPrefix.IP.String())
 
 		if checkIfSubnetOverlaps(enableInfraVnet, nwCfg, cnsNetworkConfig) {
-			buf := fmt.Sprintf("InfraVnet %v overlaps with customerVnet %+v", nwCfg.InfraVnetAddressSpace, cnsNetworkConfig.CnetAddressSpace)
-			log.Printf(buf)
-			err = errors.New(buf)
+			err = fmt.Errorf("InfraVnet %v overlaps with customerVnet %+v", nwCfg.InfraVnetAddressSpace, cnsNetworkConfig.CnetAddressSpace)
+			log.Printf("%v", err)
 			return nil, nil, net.IPNet{}, nil, err
 		}
 
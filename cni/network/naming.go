@@ -0,0 +1,81 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Names of host interfaces derived from the container, unique to each incarnation of a pod.
+	interfaceNamingContainerID = "containerId"
+	// Names of host interfaces derived from the pod namespace and name, predictable from outside the node.
+	interfaceNamingPodName = "podName"
+)
+
+// InterfaceOwner describes the endpoint a host interface belongs to.
+type InterfaceOwner struct {
+	HostIfName   string
+	NetworkID    string
+	EndpointID   string
+	ContainerID  string
+	PodName      string `json:",omitempty"`
+	PodNamespace string `json:",omitempty"`
+	NetNsPath    string `json:",omitempty"`
+	IfName       string
+	IPAddresses  []string
+}
+
+// getVethName returns the key the names of the veth pair of an endpoint are derived from,
+// following the interface naming scheme of the network configuration.
+func getVethName(nwCfg *cni.NetworkConfig, networkId, podName, podNamespace, containerID, ifName string) (string, error) {
+	switch nwCfg.InterfaceNaming {
+	case interfaceNamingPodName:
+		return fmt.Sprintf("%s.%s", podNamespace, podName), nil
+	case interfaceNamingContainerID:
+	case "":
+		if nwCfg.Mode == opModeTransparent {
+			// this mechanism of using only namespace and name is not unique for different incarnations of POD/container.
+			// IT will result in unpredictable behavior if API server decides to
+			// reorder DELETE and ADD call for new incarnation of same POD.
+			return fmt.Sprintf("%s.%s", podNamespace, podName), nil
+		}
+	default:
+		return "", fmt.Errorf("Invalid interface naming %v", nwCfg.InterfaceNaming)
+	}
+
+	// A runtime must not call ADD twice (without a corresponding DEL) for the same
+	// (network name, container id, name of the interface inside the container)
+	return fmt.Sprintf("%s%s%s", networkId, containerID, ifName), nil
+}
+
+// LookupInterface returns the endpoint the given host interface belongs to, so that interfaces seen in
+// tcpdump or by monitoring agents can be mapped back to their pods.
+func (plugin *netPlugin) LookupInterface(hostIfName string) (*InterfaceOwner, error) {
+	networkId, epInfo, err := plugin.nm.GetEndpointInfoByHostIfName(hostIfName)
+	if err != nil {
+		log.Printf("[cni-net] Failed to find endpoint of interface %v, err:%v.", hostIfName, err)
+		return nil, err
+	}
+
+	owner := &InterfaceOwner{
+		HostIfName:   hostIfName,
+		NetworkID:    networkId,
+		EndpointID:   epInfo.Id,
+		ContainerID:  epInfo.ContainerID,
+		PodName:      epInfo.PODName,
+		PodNamespace: epInfo.PODNameSpace,
+		NetNsPath:    epInfo.NetNsPath,
+		IfName:       epInfo.IfName,
+	}
+
+	for _, ipAddr := range epInfo.IPAddresses {
+		owner.IPAddresses = append(owner.IPAddresses, ipAddr.String())
+	}
+
+	return owner, nil
+}
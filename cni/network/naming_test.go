@@ -0,0 +1,106 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+)
+
+// fakeLookupNetworkManager holds endpoints by the name of their host interface.
+type fakeLookupNetworkManager struct {
+	network.NetworkManager
+	endpoints map[string]*network.EndpointInfo
+}
+
+func (nm *fakeLookupNetworkManager) GetEndpointInfoByHostIfName(hostIfName string) (string, *network.EndpointInfo, error) {
+	epInfo, ok := nm.endpoints[hostIfName]
+	if !ok {
+		return "", nil, fmt.Errorf("Endpoint not found")
+	}
+
+	return "azure", epInfo, nil
+}
+
+func TestGetVethName(t *testing.T) {
+	tests := []struct {
+		name     string
+		naming   string
+		mode     string
+		vethName string
+		err      bool
+	}{
+		{name: "default", vethName: "azurecontainer-1eth0"},
+		{name: "default transparent", mode: opModeTransparent, vethName: "default.pod-1"},
+		{name: "container ID", naming: interfaceNamingContainerID, vethName: "azurecontainer-1eth0"},
+		{name: "container ID transparent", naming: interfaceNamingContainerID, mode: opModeTransparent, vethName: "azurecontainer-1eth0"},
+		{name: "pod name", naming: interfaceNamingPodName, vethName: "default.pod-1"},
+		{name: "pod name bridge", naming: interfaceNamingPodName, mode: "bridge", vethName: "default.pod-1"},
+		{name: "invalid", naming: "random", err: true},
+	}
+
+	for _, test := range tests {
+		nwCfg := &cni.NetworkConfig{InterfaceNaming: test.naming, Mode: test.mode}
+
+		vethName, err := getVethName(nwCfg, "azure", "pod-1", "default", "container-1", "eth0")
+		if (err != nil) != test.err || vethName != test.vethName {
+			t.Errorf("TestGetVethName failed @ %v: veth name %q, expected %q, err %v", test.name, vethName, test.vethName, err)
+		}
+	}
+}
+
+func TestLookupInterface(t *testing.T) {
+	ip, ipNet, _ := net.ParseCIDR("10.0.0.4/16")
+	ipNet.IP = ip
+
+	nm := &fakeLookupNetworkManager{
+		endpoints: map[string]*network.EndpointInfo{
+			"azv1": {
+				Id:           "container-1-eth0",
+				ContainerID:  "container-1",
+				PODName:      "pod-1",
+				PODNameSpace: "default",
+				NetNsPath:    "/proc/1/ns/net",
+				IfName:       "eth0",
+				IPAddresses:  []net.IPNet{*ipNet},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		hostIfName string
+		owner      *InterfaceOwner
+	}{
+		{
+			name:       "found",
+			hostIfName: "azv1",
+			owner: &InterfaceOwner{
+				HostIfName:   "azv1",
+				NetworkID:    "azure",
+				EndpointID:   "container-1-eth0",
+				ContainerID:  "container-1",
+				PodName:      "pod-1",
+				PodNamespace: "default",
+				NetNsPath:    "/proc/1/ns/net",
+				IfName:       "eth0",
+				IPAddresses:  []string{"10.0.0.4/16"},
+			},
+		},
+		{name: "not found", hostIfName: "azv2"},
+	}
+
+	plugin := &netPlugin{nm: nm}
+	for _, test := range tests {
+		owner, err := plugin.LookupInterface(test.hostIfName)
+		if (err != nil) != (test.owner == nil) || !reflect.DeepEqual(owner, test.owner) {
+			t.Errorf("TestLookupInterface failed @ %v: owner %+v, expected %+v, err %v", test.name, owner, test.owner, err)
+		}
+	}
+}
@@ -6,11 +6,13 @@ package network
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
-	"github.com/Azure/azure-container-networking/cns/cnsclient"
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
@@ -26,8 +28,9 @@ const (
 	name                = "azure-vnet"
 	dockerNetworkOption = "com.docker.network.generic"
 	opModeTransparent   = "transparent"
-	// Supported IP version. Currently support only IPv4
-	ipVersion = "4"
+	opModeOverlay       = "overlay"
+	// File sharing the CNS client circuit breaker state between plugin invocations.
+	cnsClientStateFileName = "azure-vnet-cns-client.json"
 )
 
 // CNI Operation Types
@@ -40,18 +43,37 @@ const (
 // NetPlugin represents the CNI network plugin.
 type netPlugin struct {
 	*cni.Plugin
-	nm     network.NetworkManager
-	report *telemetry.CNIReport
+	nm             network.NetworkManager
+	report         *telemetry.CNIReport
+	auxErrors      []error
+	warmPoolConfig []byte
+	overlayConfig  []byte
+	verifyRoutes   *routeVerification
+	mirrorExpiry   *mirrorExpiry
+	timer          *stageTimer // Measures the stages of the current command in verbose mode.
+	output         io.Writer   // Receives the results of commands executed for thin clients, instead of stdout.
 }
 
 // NewPlugin creates a new netPlugin object.
 func NewPlugin(config *common.PluginConfig) (*netPlugin, error) {
+	var auxErrors []error
+
 	// Setup base plugin.
 	plugin, err := cni.NewPlugin(name, config.Version)
 	if err != nil {
-		return nil, err
+		if plugin == nil {
+			return nil, err
+		}
+
+		// Logging failures are not fatal unless strict mode is requested by the network configuration.
+		auxErrors = append(auxErrors, fmt.Errorf("logging: %v", err))
 	}
 
+	return newPlugin(config, plugin, auxErrors)
+}
+
+// Creates a netPlugin object with the given base plugin.
+func newPlugin(config *common.PluginConfig, plugin *cni.Plugin, auxErrors []error) (*netPlugin, error) {
 	// Setup network manager.
 	nm, err := network.NewNetworkManager()
 	if err != nil {
@@ -61,15 +83,46 @@ func NewPlugin(config *common.PluginConfig) (*netPlugin, error) {
 	config.NetApi = nm
 
 	return &netPlugin{
-		Plugin: plugin,
-		nm:     nm,
+		Plugin:    plugin,
+		nm:        nm,
+		auxErrors: auxErrors,
 	}, nil
 }
 
+// Writes the result of a command to stdout, or to the output of commands executed for thin clients.
+func (plugin *netPlugin) printResult(res cniTypes.Result) error {
+	if plugin.output == nil {
+		return res.Print()
+	}
+
+	return json.NewEncoder(plugin.output).Encode(res)
+}
+
 func (plugin *netPlugin) SetCNIReport(report *telemetry.CNIReport) {
 	plugin.report = report
 }
 
+// SetAuxiliaryError records the failure of an auxiliary subsystem such as telemetry.
+// Such failures only fail ADD commands for networks configured in strict mode.
+func (plugin *netPlugin) SetAuxiliaryError(subsystem string, err error) {
+	log.Printf("[cni-net] Auxiliary subsystem %v failed to initialize, err:%v.", subsystem, err)
+	plugin.auxErrors = append(plugin.auxErrors, fmt.Errorf("%v: %v", subsystem, err))
+}
+
+// Returns an error if any auxiliary subsystem failed to initialize.
+func (plugin *netPlugin) checkAuxiliarySubsystems() error {
+	if len(plugin.auxErrors) == 0 {
+		return nil
+	}
+
+	var msgs []string
+	for _, err := range plugin.auxErrors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Errorf("Auxiliary subsystems failed to initialize: %v", strings.Join(msgs, ", "))
+}
+
 // Starts the plugin.
 func (plugin *netPlugin) Start(config *common.PluginConfig) error {
 	// Initialize base plugin.
@@ -132,6 +185,223 @@ func (plugin *netPlugin) findMasterInterface(nwCfg *cni.NetworkConfig, subnetPre
 	return ""
 }
 
+// getNat64Config returns the NAT64 configuration of the network, or nil if NAT64 is disabled.
+func getNat64Config(nwCfg *cni.NetworkConfig) *network.Nat64Config {
+	if nwCfg.Nat64 == nil {
+		return nil
+	}
+
+	return &network.Nat64Config{
+		Prefix:      nwCfg.Nat64.Prefix,
+		Pool:        nwCfg.Nat64.Pool,
+		DNS64Server: nwCfg.Nat64.DNS64Server,
+	}
+}
+
+// validateRuntimeDNS checks the DNS settings passed by the runtime for the container.
+func validateRuntimeDNS(dns cni.RuntimeDNSConfig) error {
+	for _, server := range dns.Servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("Invalid DNS server %v in runtime config", server)
+		}
+	}
+
+	return nil
+}
+
+// getPortMappings returns the port mappings passed by the runtime for the container.
+func getPortMappings(nwCfg *cni.NetworkConfig) ([]network.PortMapping, error) {
+	var mappings []network.PortMapping
+	for _, mapping := range nwCfg.RuntimeConfig.PortMappings {
+		var hostIP net.IP
+		if mapping.HostIp != "" {
+			if hostIP = net.ParseIP(mapping.HostIp); hostIP == nil {
+				return nil, fmt.Errorf("Invalid host IP %v of port mapping in runtime config", mapping.HostIp)
+			}
+
+			// Unspecified addresses map the port on all addresses of the node.
+			if hostIP.IsUnspecified() {
+				hostIP = nil
+			}
+		}
+
+		mappings = append(mappings, network.PortMapping{
+			HostPort:      mapping.HostPort,
+			ContainerPort: mapping.ContainerPort,
+			Protocol:      mapping.Protocol,
+			HostIP:        hostIP,
+		})
+	}
+
+	return mappings, nil
+}
+
+// RunDNS64Proxy serves the DNS64 proxy of a NAT64 translator, started by the plugin, until it is stopped.
+func RunDNS64Proxy() error {
+	return network.RunDNS64Proxy()
+}
+
+// ReconcileNetworkConfigDrift compares an existing network with the network config. A drifted
+// network without endpoints is deleted so that it gets recreated with the current config,
+// otherwise a config drift error is returned instead of silently using stale parameters.
+func (plugin *netPlugin) reconcileNetworkConfigDrift(networkId string, nwInfo *network.NetworkInfo, nwCfg *cni.NetworkConfig) (bool, error) {
+	requested := &network.NetworkInfo{
+		Mode:             nwCfg.Mode,
+		MasterIfName:     nwCfg.Master,
+		BridgeName:       nwCfg.Bridge,
+		EnableSnatOnHost: nwCfg.EnableSnatOnHost,
+		Dataplane:        nwCfg.Dataplane,
+		SnatIPBlock:      nwCfg.SnatIPBlock,
+		PolicyRouting:    nwCfg.PolicyRouting,
+		Nat64:            getNat64Config(nwCfg),
+		Overlay:          getOverlayConfig(nwCfg),
+	}
+
+	if nwCfg.Ipam.Subnet != "" && !nwCfg.MultiTenancy {
+		_, subnetPrefix, err := net.ParseCIDR(nwCfg.Ipam.Subnet)
+		if err != nil {
+			return false, err
+		}
+
+		requested.Subnets = []network.SubnetInfo{network.SubnetInfo{Prefix: *subnetPrefix}}
+	}
+
+	err := network.CheckConfigDrift(nwInfo, requested)
+	if err == nil {
+		return false, nil
+	}
+
+	log.Printf("[cni-net] Detected %v.", err)
+
+	if nwCfg.MultiTenancy || plugin.nm.GetNumberOfEndpoints(nwInfo.MasterIfName, networkId) != 0 {
+		return false, err
+	}
+
+	log.Printf("[cni-net] Network %v has no endpoints, recreating it with the current config.", networkId)
+
+	// Release the addresses of the warm endpoints before the address pool.
+	plugin.drainWarmPool(networkId, nwInfo, nwCfg)
+
+	if err = plugin.nm.DeleteNetwork(networkId); err != nil {
+		return false, err
+	}
+
+	// Release the address pool allocated to the deleted network.
+	// Standard IPAM plugins have no address pools to release.
+	if nwCfg.IsStandardIpam() {
+		return true, nil
+	}
+
+	ipamCfg := *nwCfg
+	ipamCfg.Ipam.Subnet = nwInfo.Subnets[0].Prefix.String()
+	ipamCfg.Ipam.Address = ""
+	if err = plugin.DelegateDel(nwCfg.Ipam.Type, &ipamCfg); err != nil {
+		log.Printf("[cni-net] Failed to release pool %v, err:%v.", ipamCfg.Ipam.Subnet, err)
+	}
+
+	return true, nil
+}
+
+// ReleaseStandardIpam releases the addresses a standard IPAM plugin allocated to a container
+// without an endpoint, as the plugin keeps them until it is called to delete the container.
+func (plugin *netPlugin) releaseStandardIpam(nwCfg *cni.NetworkConfig) {
+	if nwCfg.MultiTenancy || !nwCfg.IsStandardIpam() {
+		return
+	}
+
+	if err := plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg); err != nil {
+		log.Printf("[cni-net] Failed to release addresses of container without endpoint, err:%v.", err)
+	}
+}
+
+// BridgeIpamResult checks the result of a standard IPAM plugin and fills in what Azure
+// endpoint programming expects from Azure IPAM results.
+func bridgeIpamResult(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result) error {
+	if !nwCfg.IsStandardIpam() {
+		return nil
+	}
+
+	if len(result.IPs) == 0 {
+		return fmt.Errorf("IPAM plugin %v returned no addresses", nwCfg.Ipam.Type)
+	}
+
+	// Only networks with a NAT64 translator have IPv6-only subnets.
+	if result.IPs[0].Version != "4" && (nwCfg.Nat64 == nil || !isIPv6Only(result)) {
+		return fmt.Errorf("IPAM plugin %v returned %v as first address, expected an IPv4 address", nwCfg.Ipam.Type, result.IPs[0].Address.String())
+	}
+
+	var gateway net.IP
+	if nwCfg.Ipam.Gateway != "" {
+		if gateway = net.ParseIP(nwCfg.Ipam.Gateway); gateway == nil {
+			return fmt.Errorf("Invalid IPAM gateway %v", nwCfg.Ipam.Gateway)
+		}
+	}
+
+	// Addresses the plugin returned without a gateway use the configured gateway if it is in their subnet.
+	for _, ipconfig := range result.IPs {
+		if ipconfig.Gateway == nil && gateway != nil && ipconfig.Address.Contains(gateway) {
+			ipconfig.Gateway = gateway
+			log.Printf("[cni-net] Using %v as gateway of address %v.", ipconfig.Gateway, ipconfig.Address.String())
+		}
+	}
+
+	// The gateway of the first address is the gateway of the network.
+	if result.IPs[0].Gateway == nil {
+		return fmt.Errorf("IPAM plugin %v returned no gateway for address %v, and no gateway in its subnet is configured",
+			nwCfg.Ipam.Type, result.IPs[0].Address.String())
+	}
+
+	return nil
+}
+
+// Returns whether the IPAM result only has IPv6 addresses.
+func isIPv6Only(result *cniTypesCurr.Result) bool {
+	for _, ipconfig := range result.IPs {
+		if ipconfig.Address.IP.To4() != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the version of an address in CNI results.
+func getIPVersion(ip net.IP) string {
+	if ip.To4() == nil {
+		return "6"
+	}
+
+	return "4"
+}
+
+// NewEndpointResult returns the result of the ADD command that set up an endpoint.
+func newEndpointResult(nwInfo *network.NetworkInfo, epInfo *network.EndpointInfo) *cniTypesCurr.Result {
+	var gateway net.IP
+	if len(nwInfo.Subnets) > 0 {
+		gateway = nwInfo.Subnets[0].Gateway
+	}
+
+	result := &cniTypesCurr.Result{}
+	for _, address := range epInfo.IPAddresses {
+		result.IPs = append(result.IPs, &cniTypesCurr.IPConfig{
+			Version: getIPVersion(address.IP),
+			Address: address,
+			Gateway: gateway,
+		})
+	}
+
+	for _, route := range epInfo.Routes {
+		result.Routes = append(result.Routes, &cniTypes.Route{Dst: route.Dst, GW: route.Gw})
+	}
+
+	result.DNS.Domain = epInfo.DNS.Suffix
+	result.DNS.Nameservers = epInfo.DNS.Servers
+	result.DNS.Search = epInfo.DNS.Searches
+	result.DNS.Options = epInfo.DNS.Options
+
+	return result
+}
+
 // GetEndpointID returns a unique endpoint ID based on the CNI args.
 func GetEndpointID(args *cniSkel.CmdArgs) string {
 	infraEpId, _ := network.ConstructEndpointID(args.ContainerID, args.Netns, args.IfName)
@@ -149,15 +419,15 @@ func (plugin *netPlugin) getPodInfo(args string) (string, string, error) {
 	k8sNamespace := string(podCfg.K8S_POD_NAMESPACE)
 	if len(k8sNamespace) == 0 {
 		errMsg := "Pod Namespace not specified in CNI Args"
-		log.Printf(errMsg)
-		return "", "", plugin.Errorf(errMsg)
+		log.Printf("%s", errMsg)
+		return "", "", plugin.ErrorfWithCode(cni.ErrInvalidArgs, "%s", errMsg)
 	}
 
 	k8sPodName := string(podCfg.K8S_POD_NAME)
 	if len(k8sPodName) == 0 {
 		errMsg := "Pod Name not specified in CNI Args"
-		log.Printf(errMsg)
-		return "", "", plugin.Errorf(errMsg)
+		log.Printf("%s", errMsg)
+		return "", "", plugin.ErrorfWithCode(cni.ErrInvalidArgs, "%s", errMsg)
 	}
 
 	return k8sPodName, k8sNamespace, nil
@@ -175,6 +445,25 @@ func (plugin *netPlugin) setCNIReportDetails(nwCfg *cni.NetworkConfig, opType st
 	plugin.report.InterfaceDetails.SecondaryCAUsedCount = plugin.nm.GetNumberOfEndpoints("", nwCfg.Name)
 }
 
+// reportHnsRetries adds the HNS requests retried after transient errors during a command to the CNI report.
+func (plugin *netPlugin) reportHnsRetries() {
+	retries := network.TakeHnsRetries()
+	if plugin.report == nil {
+		return
+	}
+
+	plugin.report.HNSRetryDetails = nil
+	for _, retry := range retries {
+		plugin.report.HNSRetryDetails = append(plugin.report.HNSRetryDetails, telemetry.HNSRetryInfo{
+			Operation: retry.Operation,
+			ErrorKind: retry.ErrorKind,
+			Retries:   retry.Retries,
+			Succeeded: retry.Succeeded,
+			Error:     retry.Error,
+		})
+	}
+}
+
 //
 // CNI implementation
 // https://github.com/containernetworking/cni/blob/master/SPEC.md
@@ -182,6 +471,16 @@ func (plugin *netPlugin) setCNIReportDetails(nwCfg *cni.NetworkConfig, opType st
 
 // Add handles CNI add commands.
 func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
+	err := plugin.add(args)
+	if err != nil {
+		plugin.reportAddFailure(args, err)
+	}
+
+	return err
+}
+
+// add sets up the network of a container.
+func (plugin *netPlugin) add(args *cniSkel.CmdArgs) error {
 	var (
 		result           *cniTypesCurr.Result
 		azIpamResult     *cniTypesCurr.Result
@@ -193,22 +492,36 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		subnetPrefix     net.IPNet
 		cnsNetworkConfig *cns.GetNetworkContainerResponse
 		enableInfraVnet  bool
+		warmEpInfo       *network.WarmEndpointInfo
 	)
 
-	log.Printf("[cni-net] Processing ADD command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v}.",
+	opLog := log.WithFields(log.Fields{log.FieldContainerID: args.ContainerID, log.FieldOperation: CNI_ADD})
+	opLog.Printf("[cni-net] Processing ADD command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v}.",
 		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path)
 
 	// Parse network configuration from stdin.
 	nwCfg, err = cni.ParseNetworkConfig(args.StdinData)
 	if err != nil {
-		err = plugin.Errorf("Failed to parse network configuration: %v.", err)
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v.", err)
 		return err
 	}
 
 	log.Printf("[cni-net] Read network configuration %+v.", nwCfg)
 
+	plugin.startTiming(nwCfg)
+	defer func() { plugin.reportTiming(opLog, CNI_ADD) }()
+	defer plugin.reportHnsRetries()
+
 	plugin.setCNIReportDetails(nwCfg, CNI_ADD, "")
 
+	// In strict mode, refuse to set up pods that could not be audited.
+	if nwCfg.StrictMode {
+		if err = plugin.checkAuxiliarySubsystems(); err != nil {
+			err = plugin.Errorf("Strict mode: %v", err)
+			return err
+		}
+	}
+
 	defer func() {
 		// Add Interfaces to result.
 		if result == nil {
@@ -231,11 +544,11 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 
 		if err == nil && res != nil {
-			// Output the result to stdout.
-			res.Print()
+			// Output the result.
+			plugin.printResult(res)
 		}
 
-		log.Printf("[cni-net] ADD command completed with result:%+v err:%v.", result, err)
+		opLog.Printf("[cni-net] ADD command completed with result:%+v err:%v.", result, err)
 	}()
 
 	// Parse Pod arguments.
@@ -244,18 +557,20 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	opLog = opLog.WithFields(log.Fields{log.FieldPodName: k8sPodName, log.FieldPodNamespace: k8sNamespace})
+
 	k8sContainerID := args.ContainerID
 	if len(k8sContainerID) == 0 {
 		errMsg := "Container ID not specified in CNI Args"
-		log.Printf(errMsg)
-		return plugin.Errorf(errMsg)
+		log.Printf("%s", errMsg)
+		return plugin.ErrorfWithCode(cni.ErrInvalidArgs, "%s", errMsg)
 	}
 
 	k8sIfName := args.IfName
 	if len(k8sIfName) == 0 {
 		errMsg := "Interfacename not specified in CNI Args"
-		log.Printf(errMsg)
-		return plugin.Errorf(errMsg)
+		log.Printf("%s", errMsg)
+		return plugin.ErrorfWithCode(cni.ErrInvalidArgs, "%s", errMsg)
 	}
 
 	for _, ns := range nwCfg.PodNamespaceForDualNetwork {
@@ -266,9 +581,27 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 	}
 
+	endpointId := GetEndpointID(args)
+
+	// Records the progress of the ADD, so that an interrupted ADD can be rolled back.
+	tx := &addTransaction{
+		EndpointID:   endpointId,
+		ContainerID:  args.ContainerID,
+		NetNsPath:    args.Netns,
+		IfName:       args.IfName,
+		MultiTenancy: nwCfg.MultiTenancy,
+		StartedAt:    time.Now(),
+	}
+
+	// Runs last, once a failed ADD released what it allocated.
+	defer plugin.endAddTransaction(tx)
+
+	stopIpam := plugin.timer.StartStage(stageIpam)
 	result, cnsNetworkConfig, subnetPrefix, azIpamResult, err = GetMultiTenancyCNIResult(enableInfraVnet, nwCfg, plugin, k8sPodName, k8sNamespace, args.IfName)
+	stopIpam()
 	if err != nil {
 		log.Printf("GetMultiTenancyCNIResult failed with error %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrCnsFailure, "Failed to get network container configuration: %v", err)
 		return err
 	}
 
@@ -278,6 +611,10 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 	}()
 
+	if azIpamResult != nil && len(azIpamResult.IPs) > 0 {
+		tx.InfraVnetIP = azIpamResult.IPs[0].Address
+	}
+
 	log.Printf("Result from multitenancy %+v", result)
 
 	// Initialize values from network config.
@@ -287,10 +624,19 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
-	endpointId := GetEndpointID(args)
+	tx.NetworkID = networkId
+
+	vethName, err = getVethName(nwCfg, networkId, k8sPodName, k8sNamespace, k8sContainerID, k8sIfName)
+	if err != nil {
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to name the host interface: %v", err)
+		return err
+	}
 
 	policies := cni.GetPoliciesFromNwCfg(nwCfg.AdditionalArgs)
 
+	// Release what an earlier ADD of the endpoint, interrupted before it could clean up, left behind.
+	plugin.recoverAddTransaction(networkId, endpointId, nwCfg)
+
 	// Check whether the network already exists.
 	nwInfo, nwInfoErr := plugin.nm.GetNetworkInfo(networkId)
 
@@ -313,6 +659,24 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 				result = resultConsAdd
 				return nil
 			}
+
+			// A repeated ADD of the same container returns the result of the ADD that set up its endpoint.
+			if epInfo.ContainerID == args.ContainerID {
+				log.Printf("[cni-net] Endpoint %v already set up for container %v.", endpointId, args.ContainerID)
+				result = newEndpointResult(nwInfo, epInfo)
+				return nil
+			}
+		}
+
+		// Make sure the network was created with the current network config.
+		recreate, driftErr := plugin.reconcileNetworkConfigDrift(networkId, nwInfo, nwCfg)
+		if driftErr != nil {
+			err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to reconcile network config: %v", driftErr)
+			return err
+		}
+
+		if recreate {
+			nwInfo, nwInfoErr = plugin.nm.GetNetworkInfo(networkId)
 		}
 	}
 
@@ -323,15 +687,28 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 
 		if !nwCfg.MultiTenancy {
 			// Call into IPAM plugin to allocate an address pool for the network.
+			plugin.recordAddStage(tx, addStageAllocatePool)
 			result, err = plugin.DelegateAdd(nwCfg.Ipam.Type, nwCfg)
 			if err != nil {
-				err = plugin.Errorf("Failed to allocate pool: %v", err)
+				err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to allocate pool: %v", err)
+				return err
+			}
+
+			if err = bridgeIpamResult(nwCfg, result); err != nil {
+				plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+				err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Invalid IPAM result: %v", err)
 				return err
 			}
 
 			// Derive the subnet prefix from allocated IP address.
 			subnetPrefix = result.IPs[0].Address
 
+			tx.Address = subnetPrefix.IP.String()
+			tx.Subnet = (&net.IPNet{IP: subnetPrefix.IP.Mask(subnetPrefix.Mask), Mask: subnetPrefix.Mask}).String()
+			if !nwCfg.IsStandardIpam() {
+				tx.PoolSubnet = tx.Subnet
+			}
+
 			iface := &cniTypesCurr.Interface{Name: args.IfName}
 			result.Interfaces = append(result.Interfaces, iface)
 		}
@@ -346,8 +723,10 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 				nwCfg.Ipam.Address = ipconfig.Address.IP.String()
 				plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
 
-				nwCfg.Ipam.Address = ""
-				plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+				if !nwCfg.IsStandardIpam() {
+					nwCfg.Ipam.Address = ""
+					plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+				}
 			}
 		}()
 
@@ -355,19 +734,23 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		// Find the master interface.
 		masterIfName := plugin.findMasterInterface(nwCfg, &subnetPrefix)
 		if masterIfName == "" {
-			err = plugin.Errorf("Failed to find the master interface")
+			err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to find the master interface")
 			return err
 		}
 		log.Printf("[cni-net] Found master interface %v.", masterIfName)
 
 		// Add the master as an external interface.
+		stopDataplane := plugin.timer.StartStage(stageDataplane)
 		err = plugin.nm.AddExternalInterface(masterIfName, subnetPrefix.String())
+		stopDataplane()
 		if err != nil {
-			err = plugin.Errorf("Failed to add external interface: %v", err)
+			err = plugin.ErrorfWithCode(cni.ErrDataplane, "Failed to add external interface: %v", err)
 			return err
 		}
 
+		stopDNS := plugin.timer.StartStage(stageDNS)
 		nwDNSInfo, err := getNetworkDNSSettings(nwCfg, result, k8sNamespace)
+		stopDNS()
 		if err != nil {
 			err = plugin.Errorf("Failed to getDNSSettings: %v", err)
 			return err
@@ -377,6 +760,12 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		// Update subnet prefix for multi-tenant scenario
 		updateSubnetPrefix(cnsNetworkConfig, &subnetPrefix)
 
+		// IPv6-only networks get an IPv6 subnet from IPAM.
+		subnetFamily := platform.AfINET
+		if subnetPrefix.IP.To4() == nil {
+			subnetFamily = platform.AfINET6
+		}
+
 		// Create the network.
 		nwInfo := network.NetworkInfo{
 			Id:           networkId,
@@ -384,23 +773,52 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 			MasterIfName: masterIfName,
 			Subnets: []network.SubnetInfo{
 				network.SubnetInfo{
-					Family:  platform.AfINET,
+					Family:  subnetFamily,
 					Prefix:  subnetPrefix,
 					Gateway: gateway,
 				},
 			},
 			BridgeName:       nwCfg.Bridge,
 			EnableSnatOnHost: nwCfg.EnableSnatOnHost,
+			Dataplane:        nwCfg.Dataplane,
+			SnatIPBlock:      nwCfg.SnatIPBlock,
+			PolicyRouting:    nwCfg.PolicyRouting,
+			Nat64:            getNat64Config(nwCfg),
+			Overlay:          getOverlayConfig(nwCfg),
 			DNS:              nwDNSInfo,
 			Policies:         policies,
 		}
 
+		if nwCfg.Arp != nil {
+			nwInfo.Arp = &network.ArpConfig{
+				ProxyArp:         nwCfg.Arp.ProxyArp,
+				Announce:         nwCfg.Arp.Announce,
+				Ignore:           nwCfg.Arp.Ignore,
+				EndpointProxyArp: nwCfg.Arp.EndpointProxyArp,
+				Announcements:    nwCfg.Arp.Announcements,
+			}
+		}
+
+		if nwCfg.Sriov != nil {
+			nwInfo.Sriov = &network.SriovConfig{
+				VfName: nwCfg.Sriov.VfName,
+				Link:   nwCfg.Sriov.Link,
+			}
+		}
+
+		if nwCfg.Mode == opModeOverlay {
+			nwInfo.OverlayRoutes = getOverlayRoutes(nwCfg)
+		}
+
 		nwInfo.Options = make(map[string]interface{})
 		setNetworkOptions(cnsNetworkConfig, &nwInfo)
 
+		plugin.recordAddStage(tx, addStageCreateNetwork)
+		stopDataplane = plugin.timer.StartStage(stageDataplane)
 		err = plugin.nm.CreateNetwork(&nwInfo)
+		stopDataplane()
 		if err != nil {
-			err = plugin.Errorf("Failed to create network: %v", err)
+			err = plugin.ErrorfWithCode(cni.ErrDataplane, "Failed to create network: %v", err)
 			return err
 		}
 
@@ -410,19 +828,39 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 			// Network already exists.
 			subnetPrefix := nwInfo.Subnets[0].Prefix.String()
 			log.Printf("[cni-net] Found network %v with subnet %v.", networkId, subnetPrefix)
+
 			nwCfg.Ipam.Subnet = subnetPrefix
+			tx.Subnet = subnetPrefix
+
+			plugin.recordAddStage(tx, addStageAllocateAddress)
+			if warmEpInfo = plugin.getWarmEndpoint(networkId, nwCfg); warmEpInfo != nil {
+				// Bind a warm endpoint, whose address is already allocated.
+				log.Printf("[cni-net] Using warm endpoint %v with address %v.", warmEpInfo.Id, warmEpInfo.IPAddress.String())
+				result = newWarmEndpointResult(warmEpInfo)
+			} else {
+				// Call into IPAM plugin to allocate an address for the endpoint.
+				result, err = plugin.DelegateAdd(nwCfg.Ipam.Type, nwCfg)
+				if err != nil {
+					err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to allocate address: %v", err)
+					return err
+				}
 
-			// Call into IPAM plugin to allocate an address for the endpoint.
-			result, err = plugin.DelegateAdd(nwCfg.Ipam.Type, nwCfg)
-			if err != nil {
-				err = plugin.Errorf("Failed to allocate address: %v", err)
-				return err
+				if err = bridgeIpamResult(nwCfg, result); err != nil {
+					plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+					err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Invalid IPAM result: %v", err)
+					return err
+				}
 			}
 
 			ipconfig := result.IPs[0]
 			iface := &cniTypesCurr.Interface{Name: args.IfName}
 			result.Interfaces = append(result.Interfaces, iface)
 
+			tx.Address = ipconfig.Address.IP.String()
+			if warmEpInfo != nil {
+				tx.WarmEndpointID = warmEpInfo.Id
+			}
+
 			// On failure, call into IPAM plugin to release the address.
 			defer func() {
 				if err != nil {
@@ -433,7 +871,9 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 	}
 
+	stopDNS := plugin.timer.StartStage(stageDNS)
 	epDNSInfo, err := getEndpointDNSSettings(nwCfg, result, k8sNamespace)
+	stopDNS()
 	if err != nil {
 		err = plugin.Errorf("Failed to getEndpointDNSSettings: %v", err)
 		return err
@@ -454,14 +894,29 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		PODNameSpace:       k8sNamespace,
 	}
 
-	epPolicies := getPoliciesFromRuntimeCfg(nwCfg)
+	if epInfo.PortMappings, err = getPortMappings(nwCfg); err != nil {
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to get port mappings: %v", err)
+		return err
+	}
+
+	if epInfo.EgressIP, epInfo.EgressIPExclusions, err = plugin.getEgressIP(nwCfg, k8sPodName, k8sNamespace); err != nil {
+		return err
+	}
+
+	epPolicies := getPoliciesFromRuntimeCfg(nwCfg, result)
 	for _, epPolicy := range epPolicies {
 		epInfo.Policies = append(epInfo.Policies, epPolicy)
 	}
 
 	// Populate addresses.
+	var podIPAddresses []string
 	for _, ipconfig := range result.IPs {
 		epInfo.IPAddresses = append(epInfo.IPAddresses, ipconfig.Address)
+		podIPAddresses = append(podIPAddresses, ipconfig.Address.IP.String())
+	}
+
+	if err = plugin.issuePodTokens(nwCfg, args.ContainerID, k8sPodName, k8sNamespace, podIPAddresses); err != nil {
+		return err
 	}
 
 	// Populate routes.
@@ -475,23 +930,27 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 
 	SetupRoutingForMultitenancy(nwCfg, cnsNetworkConfig, azIpamResult, epInfo, result)
 
-	if nwCfg.Mode == opModeTransparent {
-		// this mechanism of using only namespace and name is not unique for different incarnations of POD/container.
-		// IT will result in unpredictable behavior if API server decides to
-		// reorder DELETE and ADD call for new incarnation of same POD.
-		vethName = fmt.Sprintf("%s.%s", k8sNamespace, k8sPodName)
-	} else {
-		// A runtime must not call ADD twice (without a corresponding DEL) for the same
-		// (network name, container id, name of the interface inside the container)
-		vethName = fmt.Sprintf("%s%s%s", networkId, k8sContainerID, k8sIfName)
-	}
 	setEndpointOptions(cnsNetworkConfig, epInfo, vethName)
 
-	// Create the endpoint.
+	if warmEpInfo != nil {
+		epInfo.Data[network.WarmEndpointKey] = warmEpInfo.Id
+	}
+
+	// The network manager measures its SNAT stage within the creation of the endpoint.
+	if plugin.timer != nil {
+		epInfo.Timer = plugin.timer
+	}
+
+	// Create the endpoint, with its interfaces and routes.
+	tx.setEndpointInfo(epInfo, vethName)
+	plugin.recordAddStage(tx, addStageCreateEndpoint)
+
 	log.Printf("[cni-net] Creating endpoint %v.", epInfo.Id)
+	stopDataplane := plugin.timer.StartStage(stageDataplane)
 	err = plugin.nm.CreateEndpoint(networkId, epInfo)
+	stopDataplane()
 	if err != nil {
-		err = plugin.Errorf("Failed to create endpoint: %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrDataplane, "Failed to create endpoint: %v", err)
 		return err
 	}
 
@@ -499,6 +958,12 @@ func (plugin *netPlugin) Add(args *cniSkel.CmdArgs) error {
 		result, epInfo.Data[network.VlanIDKey], k8sPodName, k8sNamespace)
 	plugin.setCNIReportDetails(nwCfg, CNI_ADD, msg)
 
+	plugin.mirrorNewEndpoint(nwCfg, networkId, epInfo.Id)
+
+	plugin.scheduleWarmPoolRefill(networkId, nwCfg, args.StdinData)
+	plugin.scheduleRouteVerification(epInfo.Id, nwCfg, args.StdinData)
+	plugin.scheduleOverlayRoutesWatch(nwCfg, args.StdinData)
+
 	return nil
 }
 
@@ -528,8 +993,8 @@ func (plugin *netPlugin) Get(args *cniSkel.CmdArgs) error {
 			if err != nil {
 				err = plugin.Error(err)
 			}
-			// Output the result to stdout.
-			res.Print()
+			// Output the result.
+			plugin.printResult(res)
 		}
 
 		log.Printf("[cni-net] GET command completed with result:%+v err:%v.", result, err)
@@ -538,7 +1003,7 @@ func (plugin *netPlugin) Get(args *cniSkel.CmdArgs) error {
 	// Parse network configuration from stdin.
 	nwCfg, err = cni.ParseNetworkConfig(args.StdinData)
 	if err != nil {
-		err = plugin.Errorf("Failed to parse network configuration: %v.", err)
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v.", err)
 		return err
 	}
 
@@ -574,7 +1039,7 @@ func (plugin *netPlugin) Get(args *cniSkel.CmdArgs) error {
 
 	for _, ipAddresses := range epInfo.IPAddresses {
 		ipConfig := &cniTypesCurr.IPConfig{
-			Version:   ipVersion,
+			Version:   getIPVersion(ipAddresses.IP),
 			Interface: &epInfo.IfIndex,
 			Address:   ipAddresses,
 		}
@@ -592,6 +1057,8 @@ func (plugin *netPlugin) Get(args *cniSkel.CmdArgs) error {
 
 	result.DNS.Nameservers = epInfo.DNS.Servers
 	result.DNS.Domain = epInfo.DNS.Suffix
+	result.DNS.Search = epInfo.DNS.Searches
+	result.DNS.Options = epInfo.DNS.Options
 
 	return nil
 }
@@ -600,20 +1067,25 @@ func (plugin *netPlugin) Get(args *cniSkel.CmdArgs) error {
 func (plugin *netPlugin) Delete(args *cniSkel.CmdArgs) error {
 	var err error
 
-	log.Printf("[cni-net] Processing DEL command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v}.",
+	opLog := log.WithFields(log.Fields{log.FieldContainerID: args.ContainerID, log.FieldOperation: CNI_DEL})
+	opLog.Printf("[cni-net] Processing DEL command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v}.",
 		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path)
 
-	defer func() { log.Printf("[cni-net] DEL command completed with err:%v.", err) }()
+	defer func() { opLog.Printf("[cni-net] DEL command completed with err:%v.", err) }()
 
 	// Parse network configuration from stdin.
 	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
 	if err != nil {
-		err = plugin.Errorf("Failed to parse network configuration: %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v", err)
 		return err
 	}
 
 	log.Printf("[cni-net] Read network configuration %+v.", nwCfg)
 
+	plugin.startTiming(nwCfg)
+	defer func() { plugin.reportTiming(opLog, CNI_DEL) }()
+	defer plugin.reportHnsRetries()
+
 	plugin.setCNIReportDetails(nwCfg, CNI_DEL, "")
 
 	// Parse Pod arguments.
@@ -622,6 +1094,15 @@ func (plugin *netPlugin) Delete(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	opLog = opLog.WithFields(log.Fields{log.FieldPodName: k8sPodName, log.FieldPodNamespace: k8sNamespace})
+
+	// Revoke the tokens of the sandbox once it is torn down, even if its endpoint was already gone.
+	defer func() {
+		if err == nil {
+			plugin.revokePodTokens(nwCfg, args.ContainerID, k8sPodName, k8sNamespace)
+		}
+	}()
+
 	// Initialize values from network config.
 	networkId, err := getNetworkName(k8sPodName, k8sNamespace, args.IfName, nwCfg)
 	if err != nil {
@@ -630,11 +1111,15 @@ func (plugin *netPlugin) Delete(args *cniSkel.CmdArgs) error {
 
 	endpointId := GetEndpointID(args)
 
+	// Release what an ADD of the endpoint, interrupted before it could clean up, left behind.
+	plugin.recoverAddTransaction(networkId, endpointId, nwCfg)
+
 	// Query the network.
 	nwInfo, err := plugin.nm.GetNetworkInfo(networkId)
 	if err != nil {
 		// Log the error but return success if the endpoint being deleted is not found.
 		plugin.Errorf("Failed to query network: %v", err)
+		plugin.releaseStandardIpam(nwCfg)
 		err = nil
 		return err
 	}
@@ -644,25 +1129,35 @@ func (plugin *netPlugin) Delete(args *cniSkel.CmdArgs) error {
 	if err != nil {
 		// Log the error but return success if the endpoint being deleted is not found.
 		plugin.Errorf("Failed to query endpoint: %v", err)
+		plugin.releaseStandardIpam(nwCfg)
 		err = nil
 		return err
 	}
 
 	// Delete the endpoint.
+	stopDataplane := plugin.timer.StartStage(stageDataplane)
 	err = plugin.nm.DeleteEndpoint(networkId, endpointId)
+	stopDataplane()
 	if err != nil {
-		err = plugin.Errorf("Failed to delete endpoint: %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrDataplane, "Failed to delete endpoint: %v", err)
 		return err
 	}
 
-	if !nwCfg.MultiTenancy {
+	if !nwCfg.MultiTenancy && nwCfg.IsStandardIpam() {
+		// Standard IPAM plugins release all addresses of the container at once.
+		err = plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+		if err != nil {
+			err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to release addresses: %v", err)
+			return err
+		}
+	} else if !nwCfg.MultiTenancy {
 		// Call into IPAM plugin to release the endpoint's addresses.
 		nwCfg.Ipam.Subnet = nwInfo.Subnets[0].Prefix.String()
 		for _, address := range epInfo.IPAddresses {
 			nwCfg.Ipam.Address = address.IP.String()
 			err = plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
 			if err != nil {
-				err = plugin.Errorf("Failed to release address: %v", err)
+				err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to release address: %v", err)
 				return err
 			}
 		}
@@ -671,7 +1166,7 @@ func (plugin *netPlugin) Delete(args *cniSkel.CmdArgs) error {
 		nwCfg.Ipam.Address = epInfo.InfraVnetIP.IP.String()
 		err = plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
 		if err != nil {
-			err = plugin.Errorf("Failed to release address: %v", err)
+			err = plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to release address: %v", err)
 			return err
 		}
 	}
@@ -698,7 +1193,7 @@ func (plugin *netPlugin) Update(args *cniSkel.CmdArgs) error {
 	// Parse network configuration from stdin.
 	nwCfg, err = cni.ParseNetworkConfig(args.StdinData)
 	if err != nil {
-		err = plugin.Errorf("Failed to parse network configuration: %v.", err)
+		err = plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v.", err)
 		return err
 	}
 
@@ -719,8 +1214,8 @@ func (plugin *netPlugin) Update(args *cniSkel.CmdArgs) error {
 		}
 
 		if err == nil && res != nil {
-			// Output the result to stdout.
-			res.Print()
+			// Output the result.
+			plugin.printResult(res)
 		}
 
 		log.Printf("[cni-net] UPDATE command completed with result:%+v err:%v.", result, err)
@@ -736,15 +1231,15 @@ func (plugin *netPlugin) Update(args *cniSkel.CmdArgs) error {
 	k8sNamespace := string(podCfg.K8S_POD_NAMESPACE)
 	if len(k8sNamespace) == 0 {
 		errMsg := "Required parameter Pod Namespace not specified in CNI Args during UPDATE"
-		log.Printf(errMsg)
-		return plugin.Errorf(errMsg)
+		log.Printf("%s", errMsg)
+		return plugin.ErrorfWithCode(cni.ErrInvalidArgs, "%s", errMsg)
 	}
 
 	k8sPodName := string(podCfg.K8S_POD_NAME)
 	if len(k8sPodName) == 0 {
 		errMsg := "Required parameter Pod Name not specified in CNI Args during UPDATE"
-		log.Printf(errMsg)
-		return plugin.Errorf(errMsg)
+		log.Printf("%s", errMsg)
+		return plugin.ErrorfWithCode(cni.ErrInvalidArgs, "%s", errMsg)
 	}
 
 	// Initialize values from network config.
@@ -753,9 +1248,8 @@ func (plugin *netPlugin) Update(args *cniSkel.CmdArgs) error {
 	// Query the network.
 	_, err = plugin.nm.GetNetworkInfo(networkID)
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to query network during CNI UPDATE: %v", err)
-		log.Printf(errMsg)
-		return plugin.Errorf(errMsg)
+		log.Printf("Failed to query network during CNI UPDATE: %v", err)
+		return plugin.Errorf("Failed to query network during CNI UPDATE: %v", err)
 	}
 
 	// Query the existing endpoint since this is an update.
@@ -770,11 +1264,10 @@ func (plugin *netPlugin) Update(args *cniSkel.CmdArgs) error {
 
 	// now query CNS to get the target routes that should be there in the networknamespace (as a result of update)
 	log.Printf("Going to collect target routes for [name=%v, namespace=%v] from CNS.", k8sPodName, k8sNamespace)
-	cnsClient, err := cnsclient.NewCnsClient(nwCfg.CNSUrl)
+	cnsClient, err := newCnsClient(nwCfg, nwCfg.CNSUrl)
 	if err != nil {
-		log.Printf("Initializing CNS client error in CNI Update%v", err)
-		log.Printf(err.Error())
-		return plugin.Errorf(err.Error())
+		log.Printf("Initializing CNS client error in CNI Update %v", err)
+		return plugin.ErrorfWithCode(cni.ErrCnsFailure, "%v", err)
 	}
 
 	// create struct with info for target POD
@@ -782,13 +1275,13 @@ func (plugin *netPlugin) Update(args *cniSkel.CmdArgs) error {
 	orchestratorContext, err := json.Marshal(podInfo)
 	if err != nil {
 		log.Printf("Marshalling KubernetesPodInfo failed with %v", err)
-		return plugin.Errorf(err.Error())
+		return plugin.Errorf("%v", err)
 	}
 
 	targetNetworkConfig, err := cnsClient.GetNetworkConfiguration(orchestratorContext)
 	if err != nil {
 		log.Printf("GetNetworkConfiguration failed with %v", err)
-		return plugin.Errorf(err.Error())
+		return plugin.ErrorfWithCode(cni.ErrCnsFailure, "%v", err)
 	}
 
 	log.Printf("Network config received from cns for [name=%v, namespace=%v] is as follows -> %+v", k8sPodName, k8sNamespace, targetNetworkConfig)
@@ -833,7 +1326,7 @@ func (plugin *netPlugin) Update(args *cniSkel.CmdArgs) error {
 	log.Printf("Now updating existing endpoint %v with targetNetworkConfig %+v.", existingEpInfo.Id, targetNetworkConfig)
 	err = plugin.nm.UpdateEndpoint(networkID, existingEpInfo, targetEpInfo)
 	if err != nil {
-		err = plugin.Errorf("Failed to update endpoint: %v", err)
+		err = plugin.ErrorfWithCode(cni.ErrDataplane, "Failed to update endpoint: %v", err)
 		return err
 	}
 
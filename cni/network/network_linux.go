@@ -6,6 +6,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/ebtables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
 	"github.com/Azure/azure-container-networking/network/policy"
@@ -16,6 +17,12 @@ import (
 const (
 	snatInterface  = "eth1"
 	infraInterface = "eth2"
+
+	// Warm endpoints are prepared for Linux bridge networks.
+	warmPoolSupported = true
+
+	// Stage of programming the interfaces and routes of networks and endpoints, measured in verbose mode.
+	stageDataplane = "Netlink"
 )
 
 // handleConsecutiveAdd is a dummy function for Linux platform.
@@ -31,6 +38,17 @@ func addDefaultRoute(gwIPString string, epInfo *network.EndpointInfo, result *cn
 	result.Routes = append(result.Routes, &cniTypes.Route{Dst: dstIP, GW: gwIP})
 }
 
+// addSnatExclusionRoutes routes traffic to the SNAT exclusions through the container VNET interface,
+// so that it keeps the container IP address, e.g. for on-premises ranges reached over ExpressRoute or VPN.
+func addSnatExclusionRoutes(exclusions []string, gwIPString string, epInfo *network.EndpointInfo, result *cniTypesCurr.Result) {
+	gwIP := net.ParseIP(gwIPString)
+	for _, exclusion := range exclusions {
+		_, dstIP, _ := net.ParseCIDR(exclusion)
+		epInfo.Routes = append(epInfo.Routes, network.RouteInfo{Dst: *dstIP, Gw: gwIP})
+		result.Routes = append(result.Routes, &cniTypes.Route{Dst: *dstIP, GW: gwIP})
+	}
+}
+
 func addInfraRoutes(azIpamResult *cniTypesCurr.Result, result *cniTypesCurr.Result, epInfo *network.EndpointInfo) {
 	for _, route := range azIpamResult.Routes {
 		epInfo.Routes = append(epInfo.Routes, network.RouteInfo{Dst: route.Dst, Gw: route.GW, DevName: infraInterface})
@@ -99,13 +117,37 @@ func getNetworkDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result
 	return nwDNS, nil
 }
 
+// getEndpointDNSSettings returns the DNS settings of the network, overridden by the ones passed by the runtime.
+// They are returned in the result, from which the runtime writes the resolv.conf of the container.
 func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result, namespace string) (network.DNSInfo, error) {
-	return getNetworkDNSSettings(nwCfg, result, namespace)
+	epDNS, err := getNetworkDNSSettings(nwCfg, result, namespace)
+	if err != nil {
+		return epDNS, err
+	}
+
+	runtimeDNS := nwCfg.RuntimeConfig.DNS
+	if err = validateRuntimeDNS(runtimeDNS); err != nil {
+		return epDNS, err
+	}
+
+	if len(runtimeDNS.Servers) > 0 {
+		epDNS.Servers = runtimeDNS.Servers
+	}
+
+	if len(runtimeDNS.Searches) > 0 {
+		epDNS.Searches = runtimeDNS.Searches
+	}
+
+	if len(runtimeDNS.Options) > 0 {
+		epDNS.Options = runtimeDNS.Options
+	}
+
+	return epDNS, nil
 }
 
 // getPoliciesFromRuntimeCfg returns network policies from network config.
 // getPoliciesFromRuntimeCfg is a dummy function for Linux platform.
-func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
+func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result) []policy.Policy {
 	return nil
 }
 
@@ -115,3 +157,8 @@ func updateSubnetPrefix(cnsNetworkConfig *cns.GetNetworkContainerResponse, subne
 func getNetworkName(podName, podNs, ifName string, nwCfg *cni.NetworkConfig) (string, error) {
 	return nwCfg.Name, nil
 }
+
+// RemoveL2Rules deletes the ebtables chains of the plugin and their rules when the plugin is uninstalled.
+func RemoveL2Rules() error {
+	return ebtables.Teardown()
+}
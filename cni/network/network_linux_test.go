@@ -0,0 +1,45 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/network"
+)
+
+func TestAddSnatExclusionRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		exclusions []string
+		routes     []string
+	}{
+		{name: "none"},
+		{name: "single", exclusions: []string{"192.168.0.0/16"}, routes: []string{"192.168.0.0/16"}},
+		{name: "host address", exclusions: []string{"172.16.1.5/24", "10.10.0.0/16"}, routes: []string{"172.16.1.0/24", "10.10.0.0/16"}},
+	}
+
+	for _, test := range tests {
+		epInfo := &network.EndpointInfo{}
+		result := newIpamResult()
+
+		addSnatExclusionRoutes(test.exclusions, "10.0.0.1", epInfo, result)
+		if len(epInfo.Routes) != len(test.routes) || len(result.Routes) != len(test.routes) {
+			t.Errorf("TestAddSnatExclusionRoutes failed @ %v: routes %+v, %+v", test.name, epInfo.Routes, result.Routes)
+			continue
+		}
+
+		// Excluded destinations are routed through the container VNET gateway.
+		for i, route := range test.routes {
+			if epInfo.Routes[i].Dst.String() != route || !epInfo.Routes[i].Gw.Equal(net.ParseIP("10.0.0.1")) {
+				t.Errorf("TestAddSnatExclusionRoutes failed @ %v: endpoint route %+v, expected %v", test.name, epInfo.Routes[i], route)
+			}
+
+			if result.Routes[i].Dst.String() != route || !result.Routes[i].GW.Equal(net.ParseIP("10.0.0.1")) {
+				t.Errorf("TestAddSnatExclusionRoutes failed @ %v: result route %+v, expected %v", test.name, result.Routes[i], route)
+			}
+		}
+	}
+}
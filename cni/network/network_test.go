@@ -0,0 +1,218 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+)
+
+// Returns an IPAM result with the given addresses, each optionally followed by @ and its gateway.
+func newIpamResult(addresses ...string) *cniTypesCurr.Result {
+	result := &cniTypesCurr.Result{}
+	for _, address := range addresses {
+		var gateway net.IP
+		if i := strings.Index(address, "@"); i >= 0 {
+			address, gateway = address[:i], net.ParseIP(address[i+1:])
+		}
+
+		ip, ipNet, _ := net.ParseCIDR(address)
+		ipNet.IP = ip
+		result.IPs = append(result.IPs, &cniTypesCurr.IPConfig{Version: getIPVersion(ip), Address: *ipNet, Gateway: gateway})
+	}
+	return result
+}
+
+func TestBridgeIpamResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		nat64     bool
+		gateway   string
+		addresses []string
+		expected  string
+		valid     bool
+	}{
+		{name: "IPv4", gateway: "10.0.0.1", addresses: []string{"10.0.0.4/24"}, expected: "10.0.0.1", valid: true},
+		{name: "IPv6 without NAT64", gateway: "fd00::1", addresses: []string{"fd00::4/64"}, valid: false},
+		{name: "IPv6 with NAT64", nat64: true, gateway: "fd00::1", addresses: []string{"fd00::4/64"}, expected: "fd00::1", valid: true},
+		{name: "IPv6 first with NAT64", nat64: true, addresses: []string{"fd00::4/64", "10.0.0.4/24"}, valid: false},
+		{name: "no addresses", addresses: nil, valid: false},
+		{name: "no gateway", addresses: []string{"10.0.0.4/24"}, valid: false},
+		{name: "gateway outside subnet", gateway: "10.1.0.1", addresses: []string{"10.0.0.4/24"}, valid: false},
+		{name: "invalid gateway", gateway: "10.0.0", addresses: []string{"10.0.0.4/24"}, valid: false},
+		{name: "gateway from IPAM", gateway: "10.0.0.1", addresses: []string{"10.0.0.4/24@10.0.0.254"}, expected: "10.0.0.254", valid: true},
+	}
+
+	for _, test := range tests {
+		nwCfg := &cni.NetworkConfig{}
+		nwCfg.Ipam.Type = cni.IpamHostLocal
+		nwCfg.Ipam.Gateway = test.gateway
+		if test.nat64 {
+			nwCfg.Nat64 = &cni.Nat64Config{}
+		}
+
+		result := newIpamResult(test.addresses...)
+		err := bridgeIpamResult(nwCfg, result)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: bridgeIpamResult returned %v, expected valid:%v", test.name, err, test.valid)
+			continue
+		}
+
+		if err == nil && !result.IPs[0].Gateway.Equal(net.ParseIP(test.expected)) {
+			t.Errorf("%v: gateway %v, expected %v", test.name, result.IPs[0].Gateway, test.expected)
+		}
+	}
+}
+
+func TestGetPortMappings(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []cni.PortMapping
+		hostIPs  []net.IP
+		valid    bool
+	}{
+		{name: "no mappings", valid: true},
+		{
+			name: "host addresses",
+			mappings: []cni.PortMapping{
+				{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+				{HostPort: 8081, ContainerPort: 80, Protocol: "tcp", HostIp: "10.0.0.4"},
+				{HostPort: 8082, ContainerPort: 80, Protocol: "tcp", HostIp: "::"},
+			},
+			hostIPs: []net.IP{nil, net.ParseIP("10.0.0.4"), nil},
+			valid:   true,
+		},
+		{name: "invalid host address", mappings: []cni.PortMapping{{HostPort: 8080, ContainerPort: 80, HostIp: "10.0.0"}}},
+	}
+
+	for _, test := range tests {
+		nwCfg := &cni.NetworkConfig{}
+		nwCfg.RuntimeConfig.PortMappings = test.mappings
+
+		mappings, err := getPortMappings(nwCfg)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: getPortMappings returned %v, expected valid:%v", test.name, err, test.valid)
+			continue
+		}
+
+		if len(mappings) != len(test.hostIPs) {
+			t.Errorf("%v: %v mappings, expected %v", test.name, len(mappings), len(test.hostIPs))
+			continue
+		}
+
+		for i, mapping := range mappings {
+			if !mapping.HostIP.Equal(test.hostIPs[i]) || mapping.HostPort != test.mappings[i].HostPort {
+				t.Errorf("%v: mapping %v, expected host address %v", test.name, mapping, test.hostIPs[i])
+			}
+		}
+	}
+}
+
+func TestParseSnatExclusions(t *testing.T) {
+	tests := []struct {
+		name       string
+		exclusions string
+		valid      bool
+	}{
+		{name: "none", exclusions: `[]`, valid: true},
+		{name: "prefixes", exclusions: `["192.168.0.0/16", "fd00::/64"]`, valid: true},
+		{name: "address", exclusions: `["192.168.0.1"]`, valid: false},
+		{name: "invalid prefix", exclusions: `["192.168.0.0/16", "10.0.0.0/33"]`, valid: false},
+	}
+
+	for _, test := range tests {
+		_, err := cni.ParseNetworkConfig([]byte(`{"name": "azure", "type": "azure-vnet", "snatExclusions": ` + test.exclusions + `}`))
+		if (err == nil) != test.valid {
+			t.Errorf("TestParseSnatExclusions failed @ %v: err %v", test.name, err)
+		}
+	}
+}
+
+// fakeDriftNetworkManager holds one network with the given number of endpoints, and records its deletion.
+type fakeDriftNetworkManager struct {
+	network.NetworkManager
+	endpoints int
+	deleted   []string
+	deleteErr error
+}
+
+func (nm *fakeDriftNetworkManager) GetNumberOfEndpoints(ifName string, networkId string) int {
+	return nm.endpoints
+}
+
+func (nm *fakeDriftNetworkManager) GetWarmEndpoints(networkId string) ([]*network.WarmEndpointInfo, error) {
+	return nil, nil
+}
+
+func (nm *fakeDriftNetworkManager) DeleteNetwork(networkId string) error {
+	if nm.deleteErr != nil {
+		return nm.deleteErr
+	}
+
+	nm.deleted = append(nm.deleted, networkId)
+	return nil
+}
+
+func TestReconcileNetworkConfigDrift(t *testing.T) {
+	tests := []struct {
+		name         string
+		subnet       string
+		snat         bool
+		multiTenancy bool
+		endpoints    int
+		deleteErr    error
+		recreate     bool
+		driftErr     bool
+		fails        bool
+	}{
+		{name: "no drift", subnet: "10.0.0.0/16"},
+		{name: "drift without endpoints", subnet: "10.1.0.0/16", recreate: true},
+		{name: "snat drift without endpoints", subnet: "10.0.0.0/16", snat: true, recreate: true},
+		{name: "drift with endpoints", subnet: "10.1.0.0/16", endpoints: 2, driftErr: true, fails: true},
+		{name: "drift with multitenancy", snat: true, multiTenancy: true, driftErr: true, fails: true},
+		{name: "deletion failure", subnet: "10.1.0.0/16", deleteErr: fmt.Errorf("delete failed"), fails: true},
+		{name: "invalid subnet", subnet: "10.1.0.0", fails: true},
+	}
+
+	for _, test := range tests {
+		_, subnetPrefix, _ := net.ParseCIDR("10.0.0.0/16")
+		nwInfo := &network.NetworkInfo{
+			Id:           "azure",
+			Mode:         "bridge",
+			MasterIfName: "eth0",
+			Subnets:      []network.SubnetInfo{network.SubnetInfo{Prefix: *subnetPrefix}},
+		}
+
+		nwCfg := &cni.NetworkConfig{Name: "azure", Mode: "bridge", EnableSnatOnHost: test.snat, MultiTenancy: test.multiTenancy}
+		nwCfg.Ipam.Type = cni.IpamHostLocal
+		nwCfg.Ipam.Subnet = test.subnet
+
+		nm := &fakeDriftNetworkManager{endpoints: test.endpoints, deleteErr: test.deleteErr}
+		plugin := &netPlugin{nm: nm}
+
+		recreate, err := plugin.reconcileNetworkConfigDrift("azure", nwInfo, nwCfg)
+		if (err != nil) != test.fails {
+			t.Errorf("TestReconcileNetworkConfigDrift failed @ %v: unexpected error %v", test.name, err)
+			continue
+		}
+
+		if _, ok := err.(*network.ConfigDriftError); ok != test.driftErr {
+			t.Errorf("TestReconcileNetworkConfigDrift failed @ %v: unexpected drift error %v", test.name, err)
+		}
+
+		if recreate != test.recreate {
+			t.Errorf("TestReconcileNetworkConfigDrift failed @ %v: recreate %v", test.name, recreate)
+		}
+
+		if deleted := len(nm.deleted) != 0; deleted != test.recreate {
+			t.Errorf("TestReconcileNetworkConfigDrift failed @ %v: deleted networks %v", test.name, nm.deleted)
+		}
+	}
+}
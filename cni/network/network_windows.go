@@ -18,6 +18,14 @@ import (
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
 )
 
+const (
+	// Warm endpoints are not supported on Windows.
+	warmPoolSupported = false
+
+	// Stage of creating the HNS networks and endpoints, measured in verbose mode.
+	stageDataplane = "HNS"
+)
+
 /* handleConsecutiveAdd handles consecutive add calls for infrastructure containers on Windows platform.
  * This is a temporary work around for issue #57253 of Kubernetes.
  * We can delete this if statement once they fix it.
@@ -29,7 +37,9 @@ func handleConsecutiveAdd(containerId, endpointId string, nwInfo *network.Networ
 		log.Printf("[net] Found existing endpoint through hcsshim: %+v", hnsEndpoint)
 		log.Printf("[net] Attaching ep %v to container %v", hnsEndpoint.Id, containerId)
 
-		err := hcsshim.HotAttachEndpoint(containerId, hnsEndpoint.Id)
+		err := network.RetryHnsRequest("HotAttachEndpoint", func() error {
+			return hcsshim.HotAttachEndpoint(containerId, hnsEndpoint.Id)
+		})
 		if err != nil {
 			log.Printf("[cni-net] Failed to hot attach shared endpoint[%v] to container [%v], err:%v.", hnsEndpoint.Id, containerId, err)
 			return nil, err
@@ -67,6 +77,9 @@ func handleConsecutiveAdd(containerId, endpointId string, nwInfo *network.Networ
 func addDefaultRoute(gwIPString string, epInfo *network.EndpointInfo, result *cniTypesCurr.Result) {
 }
 
+func addSnatExclusionRoutes(exclusions []string, gwIPString string, epInfo *network.EndpointInfo, result *cniTypesCurr.Result) {
+}
+
 func addInfraRoutes(azIpamResult *cniTypesCurr.Result, result *cniTypesCurr.Result, epInfo *network.EndpointInfo) {
 }
 
@@ -166,11 +179,29 @@ func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Resul
 		}
 	}
 
+	// DNS settings passed by the runtime override the ones of the network in the HNS endpoint.
+	runtimeDNS := nwCfg.RuntimeConfig.DNS
+	if err := validateRuntimeDNS(runtimeDNS); err != nil {
+		return epDNS, err
+	}
+
+	if len(runtimeDNS.Servers) > 0 {
+		epDNS.Servers = runtimeDNS.Servers
+	}
+
+	if len(runtimeDNS.Searches) > 0 {
+		epDNS.Suffix = strings.Join(runtimeDNS.Searches, ",")
+	}
+
+	if len(runtimeDNS.Options) > 0 {
+		log.Printf("[cni-net] DNS options are not supported on Windows, ignoring %v.", runtimeDNS.Options)
+	}
+
 	return epDNS, nil
 }
 
 // getPoliciesFromRuntimeCfg returns network policies from network config.
-func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
+func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result) []policy.Policy {
 	log.Printf("[net] RuntimeConfigs: %+v", nwCfg.RuntimeConfig)
 	var policies []policy.Policy
 	for _, mapping := range nwCfg.RuntimeConfig.PortMappings {
@@ -190,5 +221,52 @@ func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 		policies = append(policies, policy)
 	}
 
+	// Destinations reached without outbound NAT, merged with the exceptions of the network config.
+	if len(nwCfg.RuntimeConfig.OutBoundNatExceptions) > 0 {
+		outBoundNatPolicy := hcsshim.OutboundNatPolicy{
+			Exceptions: nwCfg.RuntimeConfig.OutBoundNatExceptions,
+		}
+		outBoundNatPolicy.Type = hcsshim.OutboundNat
+		rawPolicy, _ := json.Marshal(&outBoundNatPolicy)
+
+		policy := policy.Policy{
+			Type: policy.EndpointPolicy,
+			Data: rawPolicy,
+		}
+		log.Printf("[net] Creating outbound NAT exception policy: %+v", policy)
+
+		policies = append(policies, policy)
+	}
+
+	// Loopback DSR encapsulates traffic to the endpoint's own IP address, so that pods
+	// reach their service VIP when the load balancer selects the pod itself (hairpinning).
+	if nwCfg.RuntimeConfig.LoopbackDSR && result != nil {
+		for _, ipconfig := range result.IPs {
+			if ipconfig.Address.IP.To4() == nil {
+				continue
+			}
+
+			routePolicy := hcsshim.RoutePolicy{
+				DestinationPrefix: ipconfig.Address.IP.String() + "/32",
+				EncapEnabled:      true,
+			}
+			routePolicy.Type = hcsshim.Route
+			rawPolicy, _ := json.Marshal(&routePolicy)
+
+			policy := policy.Policy{
+				Type: policy.EndpointPolicy,
+				Data: rawPolicy,
+			}
+			log.Printf("[net] Creating loopback DSR policy: %+v", policy)
+
+			policies = append(policies, policy)
+		}
+	}
+
 	return policies
 }
+
+// RemoveL2Rules is a no-op on Windows, where the plugin programs no ebtables rules.
+func RemoveL2Rules() error {
+	return nil
+}
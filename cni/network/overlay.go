@@ -0,0 +1,129 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+)
+
+const (
+	// Environment variable passing the network configuration to the overlay routes watch process.
+	overlayConfigEnv = "AZURE_CNI_OVERLAY_CONFIG"
+
+	// OverlayRoutesRefreshInterval is how often the watch process refreshes the overlay routes from CNS,
+	// which watches the nodes of the cluster.
+	OverlayRoutesRefreshInterval = 30 * time.Second
+)
+
+// getOverlayConfig returns the overlay configuration of the network, or nil if it is not an overlay network.
+func getOverlayConfig(nwCfg *cni.NetworkConfig) *network.OverlayConfig {
+	if nwCfg.Mode != opModeOverlay || nwCfg.Overlay == nil {
+		return nil
+	}
+
+	return &network.OverlayConfig{
+		VNI:         nwCfg.Overlay.VNI,
+		Port:        nwCfg.Overlay.Port,
+		MTU:         nwCfg.Overlay.MTU,
+		ClusterCIDR: nwCfg.Overlay.ClusterCIDR,
+	}
+}
+
+// getOverlayRoutes returns the pod CIDRs of the nodes of the cluster, learned from CNS.
+// Failures are only logged, the pods of the node can still reach each other and the routes are
+// refreshed by the watch process.
+func getOverlayRoutes(nwCfg *cni.NetworkConfig) []network.OverlayRoute {
+	cnsClient, err := newCnsClient(nwCfg, nwCfg.CNSUrl)
+	if err != nil {
+		log.Printf("[cni-net] Failed to create CNS client for the overlay routes, err:%v.", err)
+		return nil
+	}
+
+	cnsRoutes, err := cnsClient.GetOverlayRoutes()
+	if err != nil {
+		log.Printf("[cni-net] Failed to get the overlay routes from CNS, err:%v.", err)
+		return nil
+	}
+
+	var routes []network.OverlayRoute
+	for _, cnsRoute := range cnsRoutes {
+		nodeIP := net.ParseIP(cnsRoute.NodeIP)
+		_, podCIDR, err := net.ParseCIDR(cnsRoute.PodCIDR)
+		if nodeIP == nil || err != nil {
+			log.Printf("[cni-net] Ignoring invalid overlay route %+v.", cnsRoute)
+			continue
+		}
+
+		routes = append(routes, network.OverlayRoute{NodeIP: nodeIP, PodCIDR: *podCIDR})
+	}
+
+	return routes
+}
+
+// updateOverlayRoutes refreshes the tunnels of an existing overlay network to the other nodes of the cluster.
+func (plugin *netPlugin) updateOverlayRoutes(networkId string, nwCfg *cni.NetworkConfig) {
+	routes := getOverlayRoutes(nwCfg)
+	if routes == nil {
+		return
+	}
+
+	if err := plugin.nm.UpdateOverlayRoutes(networkId, routes); err != nil {
+		log.Printf("[cni-net] Failed to update the overlay routes of network %v, err:%v.", networkId, err)
+	}
+}
+
+// Remembers to start watching the overlay routes of the network after the command completes.
+func (plugin *netPlugin) scheduleOverlayRoutesWatch(nwCfg *cni.NetworkConfig, stdinData []byte) {
+	if nwCfg.Mode != opModeOverlay {
+		return
+	}
+
+	plugin.overlayConfig = stdinData
+}
+
+// StartOverlayRoutesWatch starts a background process keeping the overlay routes of the network of the last
+// command current, if it is an overlay network. The process exits right away if another one already does it.
+func (plugin *netPlugin) StartOverlayRoutesWatch() error {
+	if plugin.overlayConfig == nil {
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[cni-net] Starting overlay routes watch process.")
+
+	args := []string{"-" + common.OptWatchOverlayRoutes}
+	env := append(os.Environ(), fmt.Sprintf("%v=%s", overlayConfigEnv, plugin.overlayConfig))
+
+	return common.StartProcessWithArgs(path, args, env)
+}
+
+// RefreshOverlayRoutes programs the overlay routes learned from CNS on the network in the environment, which
+// restores the tunnels to new nodes and those deleted meanwhile. Refresh is done when the network no longer exists.
+func (plugin *netPlugin) RefreshOverlayRoutes() (done bool, err error) {
+	nwCfg, err := cni.ParseNetworkConfig([]byte(os.Getenv(overlayConfigEnv)))
+	if err != nil {
+		return true, plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v", err)
+	}
+
+	if _, err = plugin.nm.GetNetworkInfo(nwCfg.Name); err != nil {
+		// The network was deleted with its last endpoint.
+		log.Printf("[cni-net] Network %v for overlay routes not found, err:%v.", nwCfg.Name, err)
+		return true, nil
+	}
+
+	plugin.updateOverlayRoutes(nwCfg.Name, nwCfg)
+	return false, nil
+}
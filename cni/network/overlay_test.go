@@ -0,0 +1,43 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+)
+
+func TestScheduleOverlayRoutesWatch(t *testing.T) {
+	tests := []struct {
+		mode      string
+		scheduled bool
+	}{
+		{mode: opModeOverlay, scheduled: true},
+		{mode: "bridge"},
+	}
+
+	for _, test := range tests {
+		plugin := &netPlugin{}
+		plugin.scheduleOverlayRoutesWatch(&cni.NetworkConfig{Mode: test.mode}, []byte("{}"))
+
+		if (plugin.overlayConfig != nil) != test.scheduled {
+			t.Errorf("%v: scheduleOverlayRoutesWatch scheduled %s", test.mode, plugin.overlayConfig)
+		}
+	}
+}
+
+func TestRefreshOverlayRoutesNetworkDeleted(t *testing.T) {
+	defer os.Unsetenv(overlayConfigEnv)
+	os.Setenv(overlayConfigEnv, `{"name":"azure","type":"azure-vnet","mode":"overlay","overlay":{"vni":4096}}`)
+
+	plugin := &netPlugin{Plugin: &cni.Plugin{}, nm: &fakeWarmPoolNetworkManager{nwInfo: &network.NetworkInfo{Id: "other"}}}
+
+	done, err := plugin.RefreshOverlayRoutes()
+	if !done || err != nil {
+		t.Errorf("RefreshOverlayRoutes returned done:%v err:%v", done, err)
+	}
+}
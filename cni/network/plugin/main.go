@@ -17,21 +17,102 @@ import (
 	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/store"
 	"github.com/Azure/azure-container-networking/telemetry"
 	"github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
 )
 
 const (
 	hostNetAgentURL = "http://169.254.169.254/machine/plugins?comp=netagent&type=cnireport"
 	ipamQueryURL    = "http://169.254.169.254/machine/plugins?comp=nmagent&type=getinterfaceinfov1"
 	pluginName      = "CNI"
+
+	// Name of the store locked by the process verifying the routes of all endpoints.
+	routeVerifierName = "azure-vnet-verify-routes"
+
+	// Name of the store locked by the process watching the overlay routes.
+	overlayRoutesWatcherName = "azure-vnet-overlay-routes"
 )
 
 // Version is populated by make during build.
 var version string
 
+// Panic raised to exit with the error code of a failure already reported, unlike crashes of the plugin.
+type pluginFailure string
+
 // Command line arguments for CNI plugin.
 var args = acn.ArgumentList{
+	{
+		Name:         acn.OptRefillWarmPool,
+		Shorthand:    acn.OptRefillWarmPoolAlias,
+		Description:  "Refill the warm pool of the network configuration passed by the plugin",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptVerifyRoutes,
+		Shorthand:    acn.OptVerifyRoutesAlias,
+		Description:  "Verify and restore the routes of the endpoint passed by the plugin",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptWatchOverlayRoutes,
+		Shorthand:    acn.OptWatchOverlayRoutesAlias,
+		Description:  "Keep the overlay routes of the network passed by the plugin current",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptRemoveL2Rules,
+		Shorthand:    acn.OptRemoveL2RulesAlias,
+		Description:  "Remove the ebtables rules of the plugin when uninstalling it",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptLookupInterface,
+		Shorthand:    acn.OptLookupInterfaceAlias,
+		Description:  "Print the endpoint the given host interface belongs to",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptMirrorInterface,
+		Shorthand:    acn.OptMirrorInterfaceAlias,
+		Description:  "Mirror the traffic of the endpoint the given host interface belongs to",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptMirrorTo,
+		Shorthand:    acn.OptMirrorToAlias,
+		Description:  "Set the capture interface receiving the mirrored traffic, or stop mirroring if empty",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptMirrorDuration,
+		Shorthand:    acn.OptMirrorDurationAlias,
+		Description:  "Set the time in seconds to mirror the traffic for",
+		Type:         "int",
+		DefaultValue: int(network.DefaultMirrorDuration / time.Second),
+	},
+	{
+		Name:         acn.OptStopMirroring,
+		Shorthand:    acn.OptStopMirroringAlias,
+		Description:  "Stop the mirroring of the endpoint passed by the plugin once it expires",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptDNS64Proxy,
+		Shorthand:    acn.OptDNS64ProxyAlias,
+		Description:  "Serve the DNS64 proxy of the NAT64 translator started by the plugin",
+		Type:         "bool",
+		DefaultValue: false,
+	},
 	{
 		Name:         acn.OptVersion,
 		Shorthand:    acn.OptVersionAlias,
@@ -39,6 +120,13 @@ var args = acn.ArgumentList{
 		Type:         "bool",
 		DefaultValue: false,
 	},
+	{
+		Name:         acn.OptStoreLockTimeout,
+		Shorthand:    acn.OptStoreLockTimeoutAlias,
+		Description:  "Set the time in seconds to wait for the store lock while its owner does not refresh it",
+		Type:         "int",
+		DefaultValue: 0,
+	},
 }
 
 // Prints version information.
@@ -61,14 +149,281 @@ func markSendReport(reportManager *telemetry.ReportManager, tb *telemetry.Teleme
 // send error report to hostnetagent if CNI encounters any error.
 func reportPluginError(reportManager *telemetry.ReportManager, tb *telemetry.TelemetryBuffer, err error) {
 	log.Printf("Report plugin error")
-	reportManager.Report.(*telemetry.CNIReport).GetReport(pluginName, version, ipamQueryURL)
-	reflect.ValueOf(reportManager.Report).Elem().FieldByName("ErrorMessage").SetString(err.Error())
+	cniReport := reportManager.Report.(*telemetry.CNIReport)
+	cniReport.GetReport(pluginName, version, ipamQueryURL)
+	cniReport.ErrorMessage = err.Error()
+	cniReport.ErrorCode = cni.GetErrorCode(err)
+	cniReport.ErrorCodeName = cni.GetErrorCodeName(cniReport.ErrorCode)
 
 	if err := reportManager.SendReport(tb); err != nil {
 		log.Printf("SendReport failed due to %v", err)
 	}
 }
 
+// Records the wait for the store lock in the report.
+func setStoreLockDetails(cniReport *telemetry.CNIReport, stats store.LockStats) {
+	cniReport.StoreLockDetails = telemetry.StoreLockInfo{
+		WaitTimeMs:       int64(stats.TotalWaitTime / time.Millisecond),
+		Timeouts:         stats.Timeouts,
+		StaleLocksBroken: stats.StaleLocksBroken,
+	}
+}
+
+// Refills the warm pool of a network in the background, after the plugin returned its result.
+// The store is only locked to plan the refill and to add each warm endpoint to the state.
+func refillWarmPool(config *common.PluginConfig) error {
+	netPlugin, err := network.NewPlugin(config)
+	if err != nil {
+		return err
+	}
+
+	if err = netPlugin.Start(config); err != nil {
+		return err
+	}
+	defer netPlugin.Stop()
+
+	return netPlugin.RefillWarmPool(config)
+}
+
+// Verifies the routes of an endpoint in the background a few times after ADD, as some agents flush the tables.
+// The process then keeps verifying the routes of all endpoints of the network every minute, unless another process
+// already does, until the network is deleted. Routes found missing are restored and reported. The store is only
+// locked while verifying.
+func verifyRoutes(config *common.PluginConfig) error {
+	tb := telemetry.NewTelemetryBuffer("")
+	if err := tb.Connect(); err != nil {
+		// Reports are dropped, the plugin starts the telemetry service.
+		log.Printf("Connection to telemetry socket failed: %v", err)
+	} else {
+		tb.Connected = true
+	}
+
+	for _, delay := range network.RouteVerificationDelays {
+		time.Sleep(delay)
+
+		if done, err := verifyRoutesAndReport(config, tb, false); done || err != nil {
+			break
+		}
+	}
+
+	// A single process per node verifies the routes of all endpoints. Its lock is broken when it exits.
+	verifierStore, err := store.NewJsonFileStore(platform.CNIRuntimePath + routeVerifierName + ".json")
+	if err != nil {
+		return err
+	}
+
+	if err = verifierStore.Lock(false); err != nil {
+		log.Printf("Routes are verified by another process: %v", err)
+		return nil
+	}
+	defer verifierStore.Unlock(false)
+
+	for {
+		time.Sleep(network.RouteVerificationInterval)
+
+		done, err := verifyRoutesAndReport(config, tb, true)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// Verifies the routes of the endpoint, or of all endpoints, and reports those found missing.
+func verifyRoutesAndReport(config *common.PluginConfig, tb *telemetry.TelemetryBuffer, allEndpoints bool) (bool, error) {
+	reportManager := &telemetry.ReportManager{
+		HostNetAgentURL: hostNetAgentURL,
+		ContentType:     telemetry.ContentType,
+		QueueDir:        telemetry.ReportQueueDir,
+		SourceID:        pluginName,
+		SequenceFile:    telemetry.CNISequenceFile,
+		Report: &telemetry.CNIReport{
+			Context:          "AzureCNI",
+			SystemDetails:    telemetry.SystemInfo{},
+			InterfaceDetails: telemetry.InterfaceInfo{},
+			BridgeDetails:    telemetry.BridgeInfo{},
+		},
+	}
+
+	drifted, done, err := verifyRoutesOnce(config, reportManager.Report.(*telemetry.CNIReport), allEndpoints)
+	if err != nil {
+		return true, err
+	}
+
+	if drifted && tb.Connected {
+		cniReport := reportManager.Report.(*telemetry.CNIReport)
+		cniReport.Timestamp = time.Now().Format("2006-01-02 15:04:05")
+		cniReport.GetReport(pluginName, version, ipamQueryURL)
+
+		if err = reportManager.SendReport(tb); err != nil {
+			log.Printf("SendReport failed due to %v", err)
+		}
+	}
+
+	return done, nil
+}
+
+// Verifies the routes of the endpoint, or of all endpoints, once, holding the store lock.
+func verifyRoutesOnce(config *common.PluginConfig, cniReport *telemetry.CNIReport, allEndpoints bool) (bool, bool, error) {
+	netPlugin, err := network.NewPlugin(config)
+	if err != nil {
+		return false, true, err
+	}
+
+	netPlugin.SetCNIReport(cniReport)
+
+	if err = netPlugin.Plugin.InitializeKeyValueStore(config); err != nil {
+		return false, true, err
+	}
+	defer netPlugin.Plugin.UninitializeKeyValueStore()
+
+	if err = netPlugin.Start(config); err != nil {
+		return false, true, err
+	}
+	defer netPlugin.Stop()
+
+	return netPlugin.VerifyRoutes(allEndpoints)
+}
+
+// Keeps the overlay routes of the network current until it is deleted, unless another process already does.
+// The store is only locked while the routes are refreshed.
+func watchOverlayRoutes(config *common.PluginConfig) error {
+	// A single process per node watches the overlay routes. Its lock is broken when it exits.
+	watcherStore, err := store.NewJsonFileStore(platform.CNIRuntimePath + overlayRoutesWatcherName + ".json")
+	if err != nil {
+		return err
+	}
+
+	if err = watcherStore.Lock(false); err != nil {
+		log.Printf("Overlay routes are watched by another process: %v", err)
+		return nil
+	}
+	defer watcherStore.Unlock(false)
+
+	for {
+		time.Sleep(network.OverlayRoutesRefreshInterval)
+
+		done, err := refreshOverlayRoutes(config)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// Refreshes the overlay routes once, holding the store lock.
+func refreshOverlayRoutes(config *common.PluginConfig) (bool, error) {
+	netPlugin, err := network.NewPlugin(config)
+	if err != nil {
+		return true, err
+	}
+
+	if err = netPlugin.Plugin.InitializeKeyValueStore(config); err != nil {
+		return true, err
+	}
+	defer netPlugin.Plugin.UninitializeKeyValueStore()
+
+	if err = netPlugin.Start(config); err != nil {
+		return true, err
+	}
+	defer netPlugin.Stop()
+
+	return netPlugin.RefreshOverlayRoutes()
+}
+
+// Prints the endpoint a host interface belongs to, e.g. to find the pod of an interface seen in tcpdump.
+func lookupInterface(config *common.PluginConfig, hostIfName string) error {
+	netPlugin, err := network.NewPlugin(config)
+	if err != nil {
+		return err
+	}
+
+	if err = netPlugin.Plugin.InitializeKeyValueStore(config); err != nil {
+		return err
+	}
+	defer netPlugin.Plugin.UninitializeKeyValueStore()
+
+	if err = netPlugin.Start(config); err != nil {
+		return err
+	}
+	defer netPlugin.Stop()
+
+	owner, err := netPlugin.LookupInterface(hostIfName)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(owner, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(output))
+	return nil
+}
+
+// Mirrors the traffic of the endpoint a host interface belongs to, and starts the process stopping it once it expires.
+func mirrorInterface(config *common.PluginConfig, hostIfName string, captureIfName string, duration time.Duration) error {
+	netPlugin, err := network.NewPlugin(config)
+	if err != nil {
+		return err
+	}
+
+	if err = netPlugin.Plugin.InitializeKeyValueStore(config); err != nil {
+		return err
+	}
+	defer netPlugin.Plugin.UninitializeKeyValueStore()
+
+	if err = netPlugin.Start(config); err != nil {
+		return err
+	}
+	defer netPlugin.Stop()
+
+	if err = netPlugin.MirrorInterface(hostIfName, captureIfName, duration); err != nil {
+		return err
+	}
+
+	return netPlugin.StartMirrorExpiry()
+}
+
+// Stops the mirroring of an endpoint in the background once it expires. The store is only locked while checking.
+func stopMirroring(config *common.PluginConfig) error {
+	for {
+		remaining, err := stopMirroringOnce(config)
+		if err != nil || remaining == 0 {
+			return err
+		}
+
+		time.Sleep(remaining)
+	}
+}
+
+// Stops the mirroring of the endpoint if it expired, holding the store lock.
+func stopMirroringOnce(config *common.PluginConfig) (time.Duration, error) {
+	netPlugin, err := network.NewPlugin(config)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = netPlugin.Plugin.InitializeKeyValueStore(config); err != nil {
+		return 0, err
+	}
+	defer netPlugin.Plugin.UninitializeKeyValueStore()
+
+	if err = netPlugin.Start(config); err != nil {
+		return 0, err
+	}
+	defer netPlugin.Stop()
+
+	return netPlugin.StopExpiredMirroring()
+}
+
 func validateConfig(jsonBytes []byte) error {
 	var conf struct {
 		Name string `json:"name"`
@@ -133,8 +488,54 @@ func handleIfCniUpdate(update func(*skel.CmdArgs) error) (bool, error) {
 	return isupdate, nil
 }
 
+// Replaces stdin with a pipe returning the given data, so that the plugin reads the data already consumed.
+func restoreStdin(data []byte) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+
+	os.Stdin = r
+	return nil
+}
+
+// Forwards ADD and DEL commands for networks configured in thin client mode to CNS, and prints their output.
+// Returns false without forwarding other commands.
+func forwardToCns() (bool, error) {
+	cmd := os.Getenv("CNI_COMMAND")
+	if cmd != cni.CmdAdd && cmd != cni.CmdDel {
+		return false, nil
+	}
+
+	_, cmdArgs, err := getCmdArgsFromEnv()
+	if err != nil {
+		return false, err
+	}
+
+	if !network.IsThinClient(cmdArgs.StdinData) {
+		return false, restoreStdin(cmdArgs.StdinData)
+	}
+
+	cni.InitLogging(pluginName)
+
+	output, err := network.ForwardCommand(cmd, cmdArgs)
+	if err != nil {
+		log.Printf("Failed to execute %v command in CNS, err:%v.\n", cmd, err)
+		return true, err
+	}
+
+	_, err = os.Stdout.Write(output)
+	return true, err
+}
+
 // Main is the entry point for CNI network plugin.
 func main() {
+	defer telemetry.CapturePanic(pluginName, version)
 
 	// Initialize and parse command line arguments.
 	acn.ParseArgs(&args, printVersion)
@@ -151,9 +552,95 @@ func main() {
 	)
 
 	config.Version = version
+	storeLockTimeout, _ := acn.GetArg(acn.OptStoreLockTimeout).(int)
+	config.StoreLockTimeout = time.Duration(storeLockTimeout) * time.Second
+
+	if refill, _ := acn.GetArg(acn.OptRefillWarmPool).(bool); refill {
+		if err = refillWarmPool(&config); err != nil {
+			log.Printf("Failed to refill warm pool, err:%v.\n", err)
+			os.Exit(int(cni.GetErrorCode(err)))
+		}
+		os.Exit(0)
+	}
+
+	if verify, _ := acn.GetArg(acn.OptVerifyRoutes).(bool); verify {
+		if err = verifyRoutes(&config); err != nil {
+			log.Printf("Failed to verify routes, err:%v.\n", err)
+			os.Exit(int(cni.GetErrorCode(err)))
+		}
+		os.Exit(0)
+	}
+
+	if watch, _ := acn.GetArg(acn.OptWatchOverlayRoutes).(bool); watch {
+		if err = watchOverlayRoutes(&config); err != nil {
+			log.Printf("Failed to watch overlay routes, err:%v.\n", err)
+			os.Exit(int(cni.GetErrorCode(err)))
+		}
+		os.Exit(0)
+	}
+
+	if remove, _ := acn.GetArg(acn.OptRemoveL2Rules).(bool); remove {
+		if err = network.RemoveL2Rules(); err != nil {
+			log.Printf("Failed to remove ebtables rules, err:%v.\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if hostIfName, _ := acn.GetArg(acn.OptLookupInterface).(string); hostIfName != "" {
+		if err = lookupInterface(&config, hostIfName); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to look up interface %v: %v\n", hostIfName, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if hostIfName, _ := acn.GetArg(acn.OptMirrorInterface).(string); hostIfName != "" {
+		captureIfName, _ := acn.GetArg(acn.OptMirrorTo).(string)
+		duration, _ := acn.GetArg(acn.OptMirrorDuration).(int)
+		if err = mirrorInterface(&config, hostIfName, captureIfName, time.Duration(duration)*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to mirror interface %v: %v\n", hostIfName, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if stop, _ := acn.GetArg(acn.OptStopMirroring).(bool); stop {
+		if err = stopMirroring(&config); err != nil {
+			log.Printf("Failed to stop mirroring, err:%v.\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if proxy, _ := acn.GetArg(acn.OptDNS64Proxy).(bool); proxy {
+		cni.InitLogging(pluginName)
+		if err = network.RunDNS64Proxy(); err != nil {
+			log.Printf("Failed to serve DNS64 proxy, err:%v.\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// In thin client mode, CNS executes the command.
+	if forwarded, err := forwardToCns(); forwarded || err != nil {
+		if err != nil {
+			cniErr, ok := err.(*cniTypes.Error)
+			if !ok {
+				cniErr = cni.NewError(cni.GetErrorCode(err), err.Error())
+			}
+			cniErr.Print()
+			os.Exit(int(cni.GetErrorCode(err)))
+		}
+		os.Exit(0)
+	}
+
 	reportManager := &telemetry.ReportManager{
 		HostNetAgentURL: hostNetAgentURL,
 		ContentType:     telemetry.ContentType,
+		QueueDir:        telemetry.ReportQueueDir,
+		SourceID:        pluginName,
+		SequenceFile:    telemetry.CNISequenceFile,
 		Report: &telemetry.CNIReport{
 			Context:          "AzureCNI",
 			SystemDetails:    telemetry.SystemInfo{},
@@ -171,10 +658,16 @@ func main() {
 
 	tb := telemetry.NewTelemetryBuffer("")
 
+	var telemetryErr error
 	for attempt := 0; attempt < 2; attempt++ {
-		err = tb.Connect()
-		if err != nil {
-			log.Printf("Connection to telemetry socket failed: %v", err)
+		telemetryErr = tb.Connect()
+		if telemetryErr == telemetry.ErrTelemetryDisabled {
+			// Reports are dropped, and the telemetry service is not started.
+			log.Printf("Telemetry is disabled on this machine")
+			telemetryErr = nil
+			break
+		} else if telemetryErr != nil {
+			log.Printf("Connection to telemetry socket failed: %v", telemetryErr)
 			tb.Cleanup(telemetry.FdName)
 			telemetry.StartTelemetryService()
 		} else {
@@ -210,10 +703,19 @@ func main() {
 
 	netPlugin.SetCNIReport(cniReport)
 
-	if err = netPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
+	if telemetryErr != nil {
+		netPlugin.SetAuxiliaryError("telemetry", telemetryErr)
+	}
+
+	err = netPlugin.Plugin.InitializeKeyValueStore(&config)
+	if netPlugin.Plugin.Store != nil {
+		setStoreLockDetails(cniReport, netPlugin.Plugin.Store.GetLockStats())
+	}
+
+	if err != nil {
 		log.Printf("Failed to initialize key-value store of network plugin, err:%v.\n", err)
 		reportPluginError(reportManager, tb, err)
-		os.Exit(1)
+		os.Exit(int(cni.GetErrorCode(err)))
 	}
 
 	defer func() {
@@ -221,7 +723,15 @@ func main() {
 			log.Printf("Failed to uninitialize key-value store of network plugin, err:%v.\n", err)
 		}
 
-		if recover() != nil {
+		// Exit with the error code of the reported failure.
+		if r := recover(); r != nil {
+			if _, ok := r.(pluginFailure); !ok {
+				telemetry.ReportPanic(pluginName, version, r)
+			}
+
+			if cniReport.ErrorCode != 0 {
+				os.Exit(int(cniReport.ErrorCode))
+			}
 			os.Exit(1)
 		}
 	}()
@@ -229,7 +739,7 @@ func main() {
 	if err = netPlugin.Start(&config); err != nil {
 		log.Printf("Failed to start network plugin, err:%v.\n", err)
 		reportPluginError(reportManager, tb, err)
-		panic("network plugin start fatal error")
+		panic(pluginFailure("network plugin start fatal error"))
 	}
 
 	handled, err := handleIfCniUpdate(netPlugin.Update)
@@ -246,7 +756,23 @@ func main() {
 
 	if err != nil {
 		reportPluginError(reportManager, tb, err)
-		panic("network plugin execute fatal error")
+		panic(pluginFailure("network plugin execute fatal error"))
+	}
+
+	if err = netPlugin.StartWarmPoolRefill(); err != nil {
+		log.Printf("Failed to start warm pool refill, err:%v.\n", err)
+	}
+
+	if err = netPlugin.StartRouteVerification(); err != nil {
+		log.Printf("Failed to start route verification, err:%v.\n", err)
+	}
+
+	if err = netPlugin.StartOverlayRoutesWatch(); err != nil {
+		log.Printf("Failed to start overlay routes watch, err:%v.\n", err)
+	}
+
+	if err = netPlugin.StartMirrorExpiry(); err != nil {
+		log.Printf("Failed to start mirroring expiry, err:%v.\n", err)
 	}
 
 	// Report CNI successfully finished execution.
@@ -0,0 +1,47 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+)
+
+// Reports a failed ADD to CNS, which records it as an event on the pod, if enabled in the network configuration.
+// Failures to report are only logged, the ADD error is returned to the runtime either way.
+func (plugin *netPlugin) reportAddFailure(args *cniSkel.CmdArgs, addErr error) {
+	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
+	if err != nil || !nwCfg.ReportPodEvents {
+		return
+	}
+
+	podName, podNamespace, err := plugin.getPodInfo(args.Args)
+	if err != nil {
+		return
+	}
+
+	req := &cns.ReportPodNetworkFailureRequest{
+		PodName:      podName,
+		PodNamespace: podNamespace,
+		ContainerID:  args.ContainerID,
+		ErrorCode:    cni.GetErrorCode(addErr),
+		Message:      addErr.Error(),
+	}
+
+	if cniErr, ok := addErr.(*cniTypes.Error); ok {
+		req.Message = cniErr.Msg
+	}
+
+	cnsClient, err := newCnsClient(nwCfg, nwCfg.CNSUrl)
+	if err == nil {
+		err = cnsClient.ReportPodNetworkFailure(req)
+	}
+
+	if err != nil {
+		log.Printf("[cni-net] Failed to report ADD failure of pod %v/%v to CNS, err:%v.", podNamespace, podName, err)
+	}
+}
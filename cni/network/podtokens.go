@@ -0,0 +1,100 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Directory the pod tokens are written to if the network configuration doesn't set one.
+	defaultPodTokenDirectory = platform.CNIRuntimePath + "azure-vnet-pod-tokens"
+)
+
+// Returns the directory holding the tokens of a pod, one file per scope.
+func getPodTokenDirectory(nwCfg *cni.NetworkConfig, podName string, podNamespace string) string {
+	directory := nwCfg.PodTokens.Directory
+	if directory == "" {
+		directory = defaultPodTokenDirectory
+	}
+
+	return filepath.Join(directory, podNamespace+"_"+podName)
+}
+
+// issuePodTokens gets the tokens of a pod sandbox for the scopes of the network configuration from CNS, bound to
+// the addresses of the sandbox, and writes them to the token directory of the pod. The tokens are issued once per
+// sandbox and returned again on the next ADD of the sandbox.
+func (plugin *netPlugin) issuePodTokens(nwCfg *cni.NetworkConfig, containerID string, podName string, podNamespace string, ipAddresses []string) error {
+	if nwCfg.PodTokens == nil || len(nwCfg.PodTokens.Scopes) == 0 {
+		return nil
+	}
+
+	for _, scope := range nwCfg.PodTokens.Scopes {
+		if scope == "" || scope == "." || scope == ".." || strings.ContainsAny(scope, `/\`) {
+			return plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Invalid pod token scope %q", scope)
+		}
+	}
+
+	cnsClient, err := newCnsClient(nwCfg, nwCfg.CNSUrl)
+	if err != nil {
+		return plugin.ErrorfWithCode(cni.ErrCnsFailure, "Failed to create CNS client: %v", err)
+	}
+
+	directory := getPodTokenDirectory(nwCfg, podName, podNamespace)
+	if err = os.MkdirAll(directory, 0700); err != nil {
+		return plugin.Errorf("Failed to create token directory of pod %v/%v: %v", podNamespace, podName, err)
+	}
+
+	for _, scope := range nwCfg.PodTokens.Scopes {
+		token, err := cnsClient.IssuePodToken(containerID, podName, podNamespace, ipAddresses, scope)
+		if err != nil {
+			return plugin.ErrorfWithCode(cni.ErrCnsFailure, "Failed to get token for scope %v of pod %v/%v: %v",
+				scope, podNamespace, podName, err)
+		}
+
+		if err = ioutil.WriteFile(filepath.Join(directory, scope), []byte(token), 0600); err != nil {
+			return plugin.Errorf("Failed to write token for scope %v of pod %v/%v: %v", scope, podNamespace, podName, err)
+		}
+	}
+
+	log.Printf("[cni-net] Wrote tokens for scopes %v of pod %v/%v to %v.", nwCfg.PodTokens.Scopes, podNamespace, podName, directory)
+
+	return nil
+}
+
+// revokePodTokens revokes the tokens of a deleted pod sandbox in CNS, and removes the token directory of the pod
+// unless another sandbox of the pod holds tokens. Failures are logged and don't fail the DEL command.
+func (plugin *netPlugin) revokePodTokens(nwCfg *cni.NetworkConfig, containerID string, podName string, podNamespace string) {
+	if nwCfg.PodTokens == nil || len(nwCfg.PodTokens.Scopes) == 0 {
+		return
+	}
+
+	cnsClient, err := newCnsClient(nwCfg, nwCfg.CNSUrl)
+	if err != nil {
+		log.Printf("[cni-net] Failed to create CNS client to revoke tokens of pod %v/%v, err:%v.", podNamespace, podName, err)
+		return
+	}
+
+	remaining, err := cnsClient.RevokePodTokens(containerID, podName, podNamespace)
+	if err != nil {
+		log.Printf("[cni-net] Failed to revoke tokens of sandbox %v of pod %v/%v, err:%v.", containerID, podNamespace, podName, err)
+		return
+	}
+
+	if remaining > 0 {
+		return
+	}
+
+	directory := getPodTokenDirectory(nwCfg, podName, podNamespace)
+	if err = os.RemoveAll(directory); err != nil {
+		log.Printf("[cni-net] Failed to remove token directory %v, err:%v.", directory, err)
+	}
+}
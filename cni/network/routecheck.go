@@ -0,0 +1,128 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+const (
+	// Environment variables passing the network configuration and the endpoint to the route verification process.
+	routeVerificationConfigEnv   = "AZURE_CNI_ROUTE_VERIFICATION_CONFIG"
+	routeVerificationEndpointEnv = "AZURE_CNI_ROUTE_VERIFICATION_ENDPOINT"
+
+	// Operation type of the telemetry events of route verification.
+	routeVerificationOpType = "ROUTE_VERIFICATION"
+)
+
+// RouteVerificationDelays are the waits before each verification of the routes of an endpoint after ADD.
+// Agents flushing tables typically do it right after the endpoint is created, or when they resync a minute later.
+var RouteVerificationDelays = []time.Duration{2 * time.Second, 8 * time.Second, 50 * time.Second}
+
+// RouteVerificationInterval is how often the routes of all endpoints of the network are verified afterwards.
+const RouteVerificationInterval = time.Minute
+
+// Endpoint whose routes are verified after the command completes.
+type routeVerification struct {
+	endpointId string
+	config     []byte
+}
+
+// Remembers to verify the routes of the endpoint created by ADD after the command completes.
+func (plugin *netPlugin) scheduleRouteVerification(endpointId string, nwCfg *cni.NetworkConfig, stdinData []byte) {
+	if !nwCfg.VerifyRoutes {
+		return
+	}
+
+	plugin.verifyRoutes = &routeVerification{endpointId: endpointId, config: stdinData}
+}
+
+// StartRouteVerification starts a background process verifying the routes of the endpoint created by the last
+// command, if it asked for it.
+func (plugin *netPlugin) StartRouteVerification() error {
+	if plugin.verifyRoutes == nil {
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[cni-net] Starting route verification process for endpoint %v.", plugin.verifyRoutes.endpointId)
+
+	args := []string{"-" + common.OptVerifyRoutes}
+	env := append(os.Environ(),
+		fmt.Sprintf("%v=%s", routeVerificationConfigEnv, plugin.verifyRoutes.config),
+		fmt.Sprintf("%v=%s", routeVerificationEndpointEnv, plugin.verifyRoutes.endpointId))
+
+	return common.StartProcessWithArgs(path, args, env)
+}
+
+// VerifyRoutes verifies that the routes and ARP entries of the endpoint in the environment, or of all endpoints of
+// its network, are still programmed, and restores those that are missing. Missing entries are recorded in the CNI
+// report, and drifted is true if there were any. Verification is done when the endpoint, or the network, no longer
+// exists.
+func (plugin *netPlugin) VerifyRoutes(allEndpoints bool) (drifted bool, done bool, err error) {
+	nwCfg, err := cni.ParseNetworkConfig([]byte(os.Getenv(routeVerificationConfigEnv)))
+	if err != nil {
+		return false, true, plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v", err)
+	}
+
+	networkId := nwCfg.Name
+	endpointId := ""
+
+	if allEndpoints {
+		if _, err = plugin.nm.GetNetworkInfo(networkId); err != nil {
+			// The network was deleted with its last endpoint.
+			log.Printf("[cni-net] Network %v for route verification not found, err:%v.", networkId, err)
+			return false, true, nil
+		}
+	} else {
+		endpointId = os.Getenv(routeVerificationEndpointEnv)
+		if _, err = plugin.nm.GetEndpointInfo(networkId, endpointId); err != nil {
+			// The endpoint was deleted by DEL.
+			log.Printf("[cni-net] Endpoint %v for route verification not found, err:%v.", endpointId, err)
+			return false, true, nil
+		}
+	}
+
+	drifts, err := plugin.nm.ReconcileEndpointRoutes(networkId, endpointId)
+	if err != nil {
+		return false, false, plugin.ErrorfWithCode(cni.ErrDataplane, "Failed to verify routes: %v", err)
+	}
+
+	if len(drifts) == 0 {
+		log.Printf("[cni-net] Routes of network %v endpoint %v are programmed.", networkId, endpointId)
+		return false, false, nil
+	}
+
+	var msgs []string
+	plugin.report.RouteDriftDetails = nil
+	for i := range drifts {
+		msg := drifts[i].String()
+		log.Printf("[cni-net] Route drift: %v.", msg)
+		msgs = append(msgs, msg)
+
+		plugin.report.RouteDriftDetails = append(plugin.report.RouteDriftDetails, telemetry.RouteDriftInfo{
+			EndpointID: drifts[i].EndpointId,
+			Kind:       drifts[i].Kind,
+			Entry:      drifts[i].Entry,
+			Repaired:   drifts[i].Repaired,
+			Error:      drifts[i].Error,
+		})
+	}
+
+	plugin.setCNIReportDetails(nwCfg, routeVerificationOpType, strings.Join(msgs, "; "))
+
+	return true, false, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// fakeRouteNetworkManager holds the endpoints of one network and returns the drifts of their routes.
+type fakeRouteNetworkManager struct {
+	network.NetworkManager
+	networkId  string
+	endpointId string
+	drifts     []network.RouteDrift
+	reconciled []string
+}
+
+func (nm *fakeRouteNetworkManager) GetNetworkInfo(networkId string) (*network.NetworkInfo, error) {
+	if networkId != nm.networkId {
+		return nil, fmt.Errorf("Network not found")
+	}
+
+	return &network.NetworkInfo{Id: networkId}, nil
+}
+
+func (nm *fakeRouteNetworkManager) GetEndpointInfo(networkId string, endpointId string) (*network.EndpointInfo, error) {
+	if networkId != nm.networkId || endpointId != nm.endpointId {
+		return nil, fmt.Errorf("Endpoint not found")
+	}
+
+	return &network.EndpointInfo{Id: endpointId}, nil
+}
+
+func (nm *fakeRouteNetworkManager) ReconcileEndpointRoutes(networkId string, endpointId string) ([]network.RouteDrift, error) {
+	nm.reconciled = append(nm.reconciled, endpointId)
+	return nm.drifts, nil
+}
+
+func (nm *fakeRouteNetworkManager) GetNumberOfEndpoints(ifName string, networkId string) int {
+	return 1
+}
+
+func TestVerifyRoutes(t *testing.T) {
+	drift := network.RouteDrift{NetworkId: "azure", EndpointId: "ep-1", Kind: "route", Entry: "10.0.0.4/32", Repaired: true}
+
+	tests := []struct {
+		name         string
+		networkId    string
+		endpointId   string
+		allEndpoints bool
+		drifts       []network.RouteDrift
+		drifted      bool
+		done         bool
+		reconciled   []string
+	}{
+		{name: "programmed", networkId: "azure", endpointId: "ep-1", reconciled: []string{"ep-1"}},
+		{name: "drifted", networkId: "azure", endpointId: "ep-1", drifts: []network.RouteDrift{drift}, drifted: true, reconciled: []string{"ep-1"}},
+		{name: "endpoint deleted", networkId: "azure", done: true},
+		{name: "all endpoints", networkId: "azure", allEndpoints: true, drifts: []network.RouteDrift{drift}, drifted: true, reconciled: []string{""}},
+		{name: "network deleted", allEndpoints: true, done: true},
+	}
+
+	defer os.Unsetenv(routeVerificationConfigEnv)
+	defer os.Unsetenv(routeVerificationEndpointEnv)
+	os.Setenv(routeVerificationConfigEnv, `{"name":"azure","type":"azure-vnet","verifyRoutes":true}`)
+	os.Setenv(routeVerificationEndpointEnv, "ep-1")
+
+	for _, test := range tests {
+		nm := &fakeRouteNetworkManager{networkId: test.networkId, endpointId: test.endpointId, drifts: test.drifts}
+		plugin := &netPlugin{Plugin: &cni.Plugin{}, nm: nm, report: &telemetry.CNIReport{}}
+
+		drifted, done, err := plugin.VerifyRoutes(test.allEndpoints)
+		if err != nil {
+			t.Errorf("%v: VerifyRoutes failed: %v", test.name, err)
+		}
+
+		if drifted != test.drifted || done != test.done {
+			t.Errorf("%v: VerifyRoutes returned drifted:%v done:%v", test.name, drifted, done)
+		}
+
+		if !reflect.DeepEqual(nm.reconciled, test.reconciled) {
+			t.Errorf("%v: reconciled endpoints %v, expected %v", test.name, nm.reconciled, test.reconciled)
+		}
+
+		if test.drifted && (len(plugin.report.RouteDriftDetails) != 1 || plugin.report.OperationType != routeVerificationOpType) {
+			t.Errorf("%v: report %+v", test.name, plugin.report)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/telemetry"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+)
+
+// fakeStrictNetworkManager fails the test if ADD reaches the network setup.
+type fakeStrictNetworkManager struct {
+	network.NetworkManager
+	t *testing.T
+}
+
+func (nm *fakeStrictNetworkManager) GetNumberOfEndpoints(ifName string, networkId string) int {
+	return 0
+}
+
+func (nm *fakeStrictNetworkManager) GetNetworkInfo(networkId string) (*network.NetworkInfo, error) {
+	nm.t.Errorf("ADD looked up network %v in strict mode", networkId)
+	return nil, fmt.Errorf("Network not found")
+}
+
+func TestCheckAuxiliarySubsystems(t *testing.T) {
+	tests := []struct {
+		name      string
+		auxErrors map[string]error
+		message   []string
+	}{
+		{name: "healthy"},
+		{name: "telemetry", auxErrors: map[string]error{"telemetry": fmt.Errorf("socket not found")}, message: []string{"telemetry: socket not found"}},
+		{
+			name:      "telemetry and logging",
+			auxErrors: map[string]error{"telemetry": fmt.Errorf("socket not found"), "logging": fmt.Errorf("disk full")},
+			message:   []string{"telemetry: socket not found", "logging: disk full"},
+		},
+	}
+
+	for _, test := range tests {
+		plugin := &netPlugin{}
+		for subsystem, err := range test.auxErrors {
+			plugin.SetAuxiliaryError(subsystem, err)
+		}
+
+		err := plugin.checkAuxiliarySubsystems()
+		if (err != nil) != (len(test.message) > 0) {
+			t.Errorf("TestCheckAuxiliarySubsystems failed @ %v: err %v", test.name, err)
+			continue
+		}
+
+		for _, msg := range test.message {
+			if !strings.Contains(err.Error(), msg) {
+				t.Errorf("TestCheckAuxiliarySubsystems failed @ %v: %q does not report %q", test.name, err, msg)
+			}
+		}
+	}
+}
+
+func TestAddStrictMode(t *testing.T) {
+	plugin := &netPlugin{
+		Plugin: &cni.Plugin{Plugin: &common.Plugin{Name: name}},
+		nm:     &fakeStrictNetworkManager{t: t},
+		report: &telemetry.CNIReport{},
+	}
+	plugin.SetAuxiliaryError("telemetry", fmt.Errorf("socket not found"))
+
+	args := &cniSkel.CmdArgs{
+		ContainerID: "container-1",
+		Netns:       "/proc/1/ns/net",
+		IfName:      "eth0",
+		StdinData:   []byte(`{"cniVersion": "0.3.0", "name": "azure", "type": "azure-vnet", "strictMode": true}`),
+	}
+
+	// A failed auxiliary subsystem refuses ADD before any network setup.
+	err := plugin.Add(args)
+	if err == nil || !strings.Contains(err.Error(), "Strict mode") || !strings.Contains(err.Error(), "telemetry") {
+		t.Errorf("TestAddStrictMode failed, err %v", err)
+	}
+}
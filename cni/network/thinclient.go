@@ -0,0 +1,187 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	cniInvoke "github.com/containernetworking/cni/pkg/invoke"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+)
+
+const (
+	// Time after which a command forwarded to CNS is abandoned. The plugin executing it waits for the store lock
+	// held by other invocations.
+	thinClientTimeout = 2 * time.Minute
+
+	// Environment variable set for the plugin processes executing the commands forwarded to CNS.
+	executedByCnsEnv = "AZURE_CNI_EXECUTED_BY_CNS"
+)
+
+// IsThinClient returns whether the given network configuration forwards ADD and DEL commands to CNS.
+// It is false for the plugin processes CNS starts to execute the forwarded commands.
+func IsThinClient(stdinData []byte) bool {
+	if os.Getenv(executedByCnsEnv) != "" {
+		return false
+	}
+
+	nwCfg, err := cni.ParseNetworkConfig(stdinData)
+	return err == nil && nwCfg.ThinClient
+}
+
+// ForwardCommand forwards a CNI command to CNS, which executes it in its own process, and returns the output
+// of the command. A failed command returns its CNI error.
+func ForwardCommand(command string, args *cniSkel.CmdArgs) ([]byte, error) {
+	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
+	if err != nil {
+		return nil, cni.NewError(cni.ErrInvalidNetworkConfig, fmt.Sprintf("Failed to parse network configuration: %v.", err))
+	}
+
+	log.Printf("[cni-net] Forwarding %v command for container %v to CNS.", command, args.ContainerID)
+
+	cnsClient, err := newCnsClientWithTimeout(nwCfg, nwCfg.CNSUrl, thinClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cnsClient.ExecuteCNI(&cns.CNIRequest{
+		Command:     command,
+		ContainerID: args.ContainerID,
+		Netns:       args.Netns,
+		IfName:      args.IfName,
+		Args:        args.Args,
+		Path:        args.Path,
+		StdinData:   args.StdinData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ErrorCode != 0 {
+		cniErr := &cniTypes.Error{}
+		if err = json.Unmarshal(resp.Result, cniErr); err != nil {
+			cniErr = cni.NewError(resp.ErrorCode, string(resp.Result))
+		}
+		return nil, cniErr
+	}
+
+	return resp.Result, nil
+}
+
+// Executor executes the ADD and DEL commands forwarded by the plugin in thin client mode on behalf of CNS.
+// Each command runs the plugin in a child process with the environment of a plugin invocation, like a container
+// runtime does, so that it shares its state and its store lock with the plugin invocations of networks not in
+// thin client mode, and sends its telemetry report. Commands for different containers run concurrently.
+type Executor struct {
+	// Path of the plugin executable. Empty to look it up in the CNI path of each command.
+	pluginPath string
+	timeout    time.Duration
+}
+
+// NewExecutor creates an executor of the commands forwarded to CNS.
+func NewExecutor() *Executor {
+	return &Executor{timeout: thinClientTimeout}
+}
+
+// ExecuteCNI executes a forwarded command, and returns its output or its CNI error.
+func (executor *Executor) ExecuteCNI(req *cns.CNIRequest) *cns.CNIResponse {
+	var resp cns.CNIResponse
+
+	output, err := executor.execute(req)
+	if err != nil {
+		cniErr, ok := err.(*cniTypes.Error)
+		if !ok {
+			cniErr = cni.NewError(cni.GetErrorCode(err), err.Error())
+		}
+
+		resp.ErrorCode = cniErr.Code
+		resp.Result, _ = json.Marshal(cniErr)
+		return &resp
+	}
+
+	resp.Result = output
+	return &resp
+}
+
+// Returns the environment of the plugin process executing a forwarded command. The CNI variables of CNS
+// are replaced by those of the command.
+func getExecutorEnv(req *cns.CNIRequest) []string {
+	var env []string
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "CNI_") && !strings.HasPrefix(v, executedByCnsEnv+"=") {
+			env = append(env, v)
+		}
+	}
+
+	return append(env,
+		cni.Cmd+"="+req.Command,
+		"CNI_CONTAINERID="+req.ContainerID,
+		"CNI_NETNS="+req.Netns,
+		"CNI_IFNAME="+req.IfName,
+		"CNI_ARGS="+req.Args,
+		"CNI_PATH="+req.Path,
+		executedByCnsEnv+"=true",
+	)
+}
+
+// Executes a forwarded command in a plugin process.
+func (executor *Executor) execute(req *cns.CNIRequest) ([]byte, error) {
+	if req.Command != cni.CmdAdd && req.Command != cni.CmdDel {
+		return nil, cni.NewError(cni.ErrInvalidArgs, fmt.Sprintf("Unsupported CNI command %v.", req.Command))
+	}
+
+	pluginPath := executor.pluginPath
+	if pluginPath == "" {
+		var err error
+		pluginPath, err = cniInvoke.FindInPath(name, filepath.SplitList(req.Path))
+		if err != nil {
+			return nil, cni.NewError(cni.ErrInvalidArgs, fmt.Sprintf("Failed to find plugin: %v.", err))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), executor.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, pluginPath)
+	cmd.Env = getExecutorEnv(req)
+	cmd.Stdin = bytes.NewReader(req.StdinData)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Printf("[cni-net] Executing %v command for container %v with %v.", req.Command, req.ContainerID, pluginPath)
+
+	err := cmd.Run()
+	if stderr.Len() > 0 {
+		log.Printf("[cni-net] Plugin stderr for container %v: %s", req.ContainerID, stderr.Bytes())
+	}
+
+	if err == nil {
+		return stdout.Bytes(), nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, cni.NewError(cni.ErrRuntime, fmt.Sprintf("Plugin timed out after %v.", executor.timeout))
+	}
+
+	// A failed plugin prints its CNI error.
+	cniErr := &cniTypes.Error{}
+	if json.Unmarshal(stdout.Bytes(), cniErr) == nil && cniErr.Code != 0 {
+		return nil, cniErr
+	}
+
+	return nil, cni.NewError(cni.ErrRuntime, fmt.Sprintf("Plugin failed: %v %s", err, stderr.Bytes()))
+}
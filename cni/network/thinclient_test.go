@@ -0,0 +1,179 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cns"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+)
+
+// Environment variable making the test binary act as the plugin executed by the executor.
+const testPluginEnv = "AZURE_CNI_TEST_PLUGIN"
+
+// Output of the test plugin, with the environment and the configuration it was executed with.
+type testPluginOutput struct {
+	Env        map[string]string
+	StdinData  string
+	ThinClient bool
+}
+
+// Acts as the plugin: fails DEL commands and prints its arguments for the others.
+func runTestPlugin() int {
+	stdinData, _ := ioutil.ReadAll(os.Stdin)
+
+	switch {
+	case os.Getenv("CNI_COMMAND") == cni.CmdDel:
+		(&cniTypes.Error{Code: cni.ErrInvalidArgs, Msg: "test failure"}).Print()
+		return int(cni.ErrInvalidArgs)
+
+	case os.Getenv("CNI_CONTAINERID") == "crash":
+		fmt.Fprintln(os.Stderr, "test crash")
+		return 2
+	}
+
+	output := testPluginOutput{Env: make(map[string]string), StdinData: string(stdinData), ThinClient: IsThinClient(stdinData)}
+	for _, key := range []string{"CNI_COMMAND", "CNI_CONTAINERID", "CNI_NETNS", "CNI_IFNAME", "CNI_ARGS", "CNI_PATH", executedByCnsEnv} {
+		output.Env[key] = os.Getenv(key)
+	}
+
+	json.NewEncoder(os.Stdout).Encode(output)
+	return 0
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) != "" {
+		os.Exit(runTestPlugin())
+	}
+
+	os.Exit(m.Run())
+}
+
+// Returns an executor running the test binary as the plugin.
+func newTestExecutor() (*Executor, func()) {
+	os.Setenv(testPluginEnv, "true")
+	return &Executor{pluginPath: os.Args[0], timeout: 30 * time.Second}, func() { os.Unsetenv(testPluginEnv) }
+}
+
+func TestExecutorAdd(t *testing.T) {
+	executor, restore := newTestExecutor()
+	defer restore()
+
+	// The CNI variables of CNS are not passed to the plugin, nor changed.
+	os.Setenv("CNI_COMMAND", "VERSION")
+	defer os.Unsetenv("CNI_COMMAND")
+
+	stdinData := `{"name":"azure","type":"azure-vnet","thinClient":true}`
+	req := &cns.CNIRequest{
+		Command:     cni.CmdAdd,
+		ContainerID: "container-1",
+		Netns:       "/var/run/netns/test",
+		IfName:      "eth0",
+		Args:        "K8S_POD_NAME=pod-1",
+		Path:        "/opt/cni/bin",
+		StdinData:   []byte(stdinData),
+	}
+
+	resp := executor.ExecuteCNI(req)
+	if resp.ErrorCode != 0 {
+		t.Fatalf("ADD failed: %s", resp.Result)
+	}
+
+	var output testPluginOutput
+	if err := json.Unmarshal(resp.Result, &output); err != nil {
+		t.Fatalf("Invalid output %s: %v", resp.Result, err)
+	}
+
+	expected := map[string]string{
+		"CNI_COMMAND":     cni.CmdAdd,
+		"CNI_CONTAINERID": "container-1",
+		"CNI_NETNS":       "/var/run/netns/test",
+		"CNI_IFNAME":      "eth0",
+		"CNI_ARGS":        "K8S_POD_NAME=pod-1",
+		"CNI_PATH":        "/opt/cni/bin",
+		executedByCnsEnv:  "true",
+	}
+
+	for key, value := range expected {
+		if output.Env[key] != value {
+			t.Errorf("Plugin got %v=%q, expected %q", key, output.Env[key], value)
+		}
+	}
+
+	// The plugin executes the command instead of forwarding it back to CNS.
+	if output.StdinData != stdinData || output.ThinClient {
+		t.Errorf("Plugin got configuration %q, thin client:%v", output.StdinData, output.ThinClient)
+	}
+
+	if os.Getenv("CNI_COMMAND") != "VERSION" || os.Getenv("CNI_CONTAINERID") != "" {
+		t.Errorf("Executing the command changed the environment of CNS")
+	}
+}
+
+func TestExecutorErrors(t *testing.T) {
+	executor, restore := newTestExecutor()
+	defer restore()
+
+	tests := []struct {
+		name string
+		req  cns.CNIRequest
+		code uint
+	}{
+		{name: "plugin error", req: cns.CNIRequest{Command: cni.CmdDel, ContainerID: "container-1"}, code: cni.ErrInvalidArgs},
+		{name: "plugin crash", req: cns.CNIRequest{Command: cni.CmdAdd, ContainerID: "crash"}, code: cni.ErrRuntime},
+		{name: "unsupported command", req: cns.CNIRequest{Command: "CHECK", ContainerID: "container-1"}, code: cni.ErrInvalidArgs},
+	}
+
+	for _, test := range tests {
+		resp := executor.ExecuteCNI(&test.req)
+
+		cniErr := &cniTypes.Error{}
+		if err := json.Unmarshal(resp.Result, cniErr); err != nil || resp.ErrorCode != test.code || cniErr.Code != test.code {
+			t.Errorf("%v: got code %v result %s, expected code %v", test.name, resp.ErrorCode, resp.Result, test.code)
+		}
+	}
+
+	// Without a path, the plugin is looked up in the CNI path of the command.
+	dir, err := ioutil.TempDir("", "cni-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	resp := NewExecutor().ExecuteCNI(&cns.CNIRequest{Command: cni.CmdAdd, ContainerID: "container-1", Path: dir})
+	if resp.ErrorCode != cni.ErrInvalidArgs {
+		t.Errorf("ADD without plugin in the CNI path returned code %v result %s", resp.ErrorCode, resp.Result)
+	}
+}
+
+func TestExecutorConcurrentCommands(t *testing.T) {
+	executor, restore := newTestExecutor()
+	defer restore()
+
+	var wg sync.WaitGroup
+	results := make([]*cns.CNIResponse, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = executor.ExecuteCNI(&cns.CNIRequest{Command: cni.CmdAdd, ContainerID: fmt.Sprintf("container-%d", i)})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, resp := range results {
+		var output testPluginOutput
+		if err := json.Unmarshal(resp.Result, &output); err != nil || output.Env["CNI_CONTAINERID"] != fmt.Sprintf("container-%d", i) {
+			t.Errorf("Command %d got result %s", i, resp.Result)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+)
+
+const (
+	// Environment variable turning on the verbose mode for all networks, e.g. while debugging a node.
+	verboseEnv = "AZURE_CNI_VERBOSE"
+
+	// Stages of the commands measured in verbose mode, along with network.StageSnat and stageDataplane.
+	stageIpam  = "IPAM"
+	stageDNS   = "DNS"
+	stageOther = "Other"
+)
+
+// stageTimer measures the time a command spends in each of its stages. A stage started within another one,
+// e.g. SNAT while the endpoint is created, isn't counted in the outer stage.
+type stageTimer struct {
+	clock     platform.Clock
+	start     time.Time
+	stages    []string // In the order they first started.
+	durations map[string]time.Duration
+	calls     map[string]int
+	active    []string  // Stages started and not stopped yet, innermost last.
+	resumed   time.Time // When the innermost active stage started or resumed.
+}
+
+// newStageTimer creates a timer measuring a command starting now.
+func newStageTimer(clock platform.Clock) *stageTimer {
+	return &stageTimer{
+		clock:     clock,
+		start:     clock.Now(),
+		durations: make(map[string]time.Duration),
+		calls:     make(map[string]int),
+	}
+}
+
+// isVerbose returns whether the commands of a network are measured.
+func isVerbose(nwCfg *cni.NetworkConfig) bool {
+	return nwCfg.Verbose || os.Getenv(verboseEnv) != ""
+}
+
+// StartStage starts measuring a stage, pausing the stage it is started within.
+// It returns the function that stops measuring the stage. A nil timer measures nothing.
+func (timer *stageTimer) StartStage(stage string) func() {
+	if timer == nil {
+		return func() {}
+	}
+
+	timer.pause()
+	if timer.calls[stage] == 0 {
+		timer.stages = append(timer.stages, stage)
+	}
+	timer.calls[stage]++
+	timer.active = append(timer.active, stage)
+
+	stopped := false
+	return func() {
+		if stopped {
+			return
+		}
+		stopped = true
+
+		timer.pause()
+		timer.active = timer.active[:len(timer.active)-1]
+	}
+}
+
+// pause adds the time since the innermost active stage started or resumed to it.
+func (timer *stageTimer) pause() {
+	now := timer.clock.Now()
+	if len(timer.active) > 0 {
+		timer.durations[timer.active[len(timer.active)-1]] += now.Sub(timer.resumed)
+	}
+	timer.resumed = now
+}
+
+// getTimings returns the time spent in each stage so far, and in none of them.
+func (timer *stageTimer) getTimings() []telemetry.StageTimingInfo {
+	var (
+		timings []telemetry.StageTimingInfo
+		staged  time.Duration
+	)
+
+	timer.pause()
+	for _, stage := range timer.stages {
+		staged += timer.durations[stage]
+		timings = append(timings, telemetry.StageTimingInfo{
+			Stage:      stage,
+			Calls:      timer.calls[stage],
+			DurationMs: int64(timer.durations[stage] / time.Millisecond),
+		})
+	}
+
+	return append(timings, telemetry.StageTimingInfo{
+		Stage:      stageOther,
+		DurationMs: int64((timer.clock.Since(timer.start) - staged) / time.Millisecond),
+	})
+}
+
+// startTiming starts measuring the stages of a command if the network is in verbose mode.
+func (plugin *netPlugin) startTiming(nwCfg *cni.NetworkConfig) {
+	plugin.timer = nil
+	if isVerbose(nwCfg) {
+		plugin.timer = newStageTimer(platform.NewClock())
+	}
+}
+
+// reportTiming writes the time a command spent in each stage into the log and the CNI report.
+func (plugin *netPlugin) reportTiming(opLog *log.Entry, command string) {
+	if plugin.timer == nil {
+		return
+	}
+
+	timings := plugin.timer.getTimings()
+	plugin.timer = nil
+
+	var (
+		stages []string
+		total  int64
+	)
+	for _, timing := range timings {
+		stages = append(stages, fmt.Sprintf("%v:%vms", timing.Stage, timing.DurationMs))
+		total += timing.DurationMs
+	}
+
+	opLog.Printf("[cni-net] %v command timing: %v total:%vms.", command, strings.Join(stages, " "), total)
+
+	if plugin.report != nil {
+		plugin.report.StageTimings = timings
+	}
+}
+
+// DelegateAdd calls the given IPAM plugin's ADD command, measured as the IPAM stage.
+func (plugin *netPlugin) DelegateAdd(pluginName string, nwCfg *cni.NetworkConfig) (*cniTypesCurr.Result, error) {
+	defer plugin.timer.StartStage(stageIpam)()
+
+	return plugin.Plugin.DelegateAdd(pluginName, nwCfg)
+}
+
+// DelegateDel calls the given IPAM plugin's DEL command, measured as the IPAM stage.
+func (plugin *netPlugin) DelegateDel(pluginName string, nwCfg *cni.NetworkConfig) error {
+	defer plugin.timer.StartStage(stageIpam)()
+
+	return plugin.Plugin.DelegateDel(pluginName, nwCfg)
+}
@@ -0,0 +1,169 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// timerStep starts a stage, stops the innermost stage started by the test, or advances the clock.
+type timerStep struct {
+	start   string
+	stop    bool
+	advance time.Duration
+}
+
+func TestStageTimer(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []timerStep
+		timings []telemetry.StageTimingInfo
+	}{
+		{
+			name:    "no stages",
+			steps:   []timerStep{{advance: 5 * time.Millisecond}},
+			timings: []telemetry.StageTimingInfo{{Stage: stageOther, DurationMs: 5}},
+		},
+		{
+			name: "sequential",
+			steps: []timerStep{
+				{advance: time.Millisecond},
+				{start: stageIpam}, {advance: 10 * time.Millisecond}, {stop: true},
+				{start: stageDNS}, {advance: 20 * time.Millisecond}, {stop: true},
+				{advance: 2 * time.Millisecond},
+			},
+			timings: []telemetry.StageTimingInfo{
+				{Stage: stageIpam, Calls: 1, DurationMs: 10},
+				{Stage: stageDNS, Calls: 1, DurationMs: 20},
+				{Stage: stageOther, DurationMs: 3},
+			},
+		},
+		{
+			name: "nested",
+			steps: []timerStep{
+				{start: "Endpoint"}, {advance: 10 * time.Millisecond},
+				{start: network.StageSnat}, {advance: 30 * time.Millisecond}, {stop: true},
+				{advance: 5 * time.Millisecond}, {stop: true},
+			},
+			timings: []telemetry.StageTimingInfo{
+				{Stage: "Endpoint", Calls: 1, DurationMs: 15},
+				{Stage: network.StageSnat, Calls: 1, DurationMs: 30},
+				{Stage: stageOther, DurationMs: 0},
+			},
+		},
+		{
+			name: "repeated",
+			steps: []timerStep{
+				{start: stageIpam}, {advance: 10 * time.Millisecond}, {stop: true},
+				{advance: 4 * time.Millisecond},
+				{start: stageIpam}, {advance: 6 * time.Millisecond}, {stop: true},
+			},
+			timings: []telemetry.StageTimingInfo{
+				{Stage: stageIpam, Calls: 2, DurationMs: 16},
+				{Stage: stageOther, DurationMs: 4},
+			},
+		},
+		{
+			name: "still active",
+			steps: []timerStep{
+				{start: stageIpam}, {advance: 7 * time.Millisecond},
+			},
+			timings: []telemetry.StageTimingInfo{
+				{Stage: stageIpam, Calls: 1, DurationMs: 7},
+				{Stage: stageOther, DurationMs: 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		clock := platform.NewFakeClock(time.Unix(1000, 0))
+		timer := newStageTimer(clock)
+
+		var stops []func()
+		for _, step := range test.steps {
+			switch {
+			case step.start != "":
+				stops = append(stops, timer.StartStage(step.start))
+			case step.stop:
+				stop := stops[len(stops)-1]
+				stops = stops[:len(stops)-1]
+				stop()
+				// Stopping a stage twice has no effect.
+				stop()
+			default:
+				clock.Advance(step.advance)
+			}
+		}
+
+		if timings := timer.getTimings(); !reflect.DeepEqual(timings, test.timings) {
+			t.Errorf("TestStageTimer failed @ %v: timings %+v, expected %+v", test.name, timings, test.timings)
+		}
+	}
+}
+
+func TestNilStageTimer(t *testing.T) {
+	var timer *stageTimer
+
+	// Commands of networks not in verbose mode are not measured.
+	stop := timer.StartStage(stageIpam)
+	stop()
+}
+
+func TestIsVerbose(t *testing.T) {
+	tests := []struct {
+		name    string
+		verbose bool
+		env     string
+		result  bool
+	}{
+		{name: "off", result: false},
+		{name: "network", verbose: true, result: true},
+		{name: "environment", env: "1", result: true},
+	}
+
+	defer os.Unsetenv(verboseEnv)
+
+	for _, test := range tests {
+		os.Setenv(verboseEnv, test.env)
+		if result := isVerbose(&cni.NetworkConfig{Verbose: test.verbose}); result != test.result {
+			t.Errorf("TestIsVerbose failed @ %v: verbose %v, expected %v", test.name, result, test.result)
+		}
+	}
+}
+
+func TestReportTiming(t *testing.T) {
+	clock := platform.NewFakeClock(time.Unix(1000, 0))
+	plugin := &netPlugin{report: &telemetry.CNIReport{}, timer: newStageTimer(clock)}
+
+	stop := plugin.timer.StartStage(stageIpam)
+	clock.Advance(10 * time.Millisecond)
+	stop()
+	clock.Advance(time.Millisecond)
+
+	plugin.reportTiming(log.WithFields(log.Fields{}), CNI_ADD)
+
+	expected := []telemetry.StageTimingInfo{
+		{Stage: stageIpam, Calls: 1, DurationMs: 10},
+		{Stage: stageOther, DurationMs: 1},
+	}
+	if !reflect.DeepEqual(plugin.report.StageTimings, expected) || plugin.timer != nil {
+		t.Errorf("TestReportTiming failed, report %+v timer %v", plugin.report.StageTimings, plugin.timer)
+	}
+
+	// Without a timer, the report is left alone.
+	plugin.report.StageTimings = nil
+	plugin.reportTiming(log.WithFields(log.Fields{}), CNI_ADD)
+	if plugin.report.StageTimings != nil {
+		t.Errorf("TestReportTiming failed, report %+v without timer", plugin.report.StageTimings)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	// Store key of the ADD commands in progress, by endpoint.
+	addTransactionsKey = "AddTransactions"
+)
+
+// Stages of an ADD command, recorded before each is started.
+const (
+	addStageAllocatePool    = "AllocatePool"
+	addStageCreateNetwork   = "CreateNetwork"
+	addStageAllocateAddress = "AllocateAddress"
+	addStageCreateEndpoint  = "CreateEndpoint"
+)
+
+// AddTransaction records the progress of an ADD command in the plugin store.
+// An ADD cleans up after itself when it fails. When it is interrupted instead, e.g. by a crash or a timeout
+// killing the plugin, the record lets the next ADD or DEL of the endpoint release what was left behind.
+type addTransaction struct {
+	NetworkID      string
+	EndpointID     string
+	ContainerID    string
+	NetNsPath      string
+	IfName         string
+	Stage          string
+	MultiTenancy   bool
+	PoolSubnet     string // Pool allocated for a new network.
+	Subnet         string
+	Address        string // Address allocated for the endpoint.
+	WarmEndpointID string
+	VethName       string
+	StartedAt      time.Time
+
+	// Set before the endpoint is created, to delete the rules programmed for it.
+	IPAddresses      []net.IPNet
+	InfraVnetIP      net.IPNet // Infrastructure VNET address of a multitenant endpoint.
+	VlanID           int
+	Options          map[string]string // String options of the endpoint, e.g. its SNAT addresses.
+	EnableSnatOnHost bool
+	EnableInfraVnet  bool
+}
+
+// Records the settings of the endpoint that its rules depend on, before the endpoint is created.
+func (tx *addTransaction) setEndpointInfo(epInfo *network.EndpointInfo, vethName string) {
+	tx.VethName = vethName
+	tx.IPAddresses = epInfo.IPAddresses
+	tx.InfraVnetIP = epInfo.InfraVnetIP
+	tx.VlanID, _ = epInfo.Data[network.VlanIDKey].(int)
+	tx.Options = make(map[string]string)
+	for key, value := range epInfo.Data {
+		if option, ok := value.(string); ok {
+			tx.Options[key] = option
+		}
+	}
+	tx.EnableSnatOnHost = epInfo.EnableSnatOnHost
+	tx.EnableInfraVnet = epInfo.EnableInfraVnet
+}
+
+// Returns the endpoint the ADD command was creating, as far as needed to clean it up.
+func (tx *addTransaction) getEndpointInfo() *network.EndpointInfo {
+	epInfo := &network.EndpointInfo{
+		Id:                 tx.EndpointID,
+		ContainerID:        tx.ContainerID,
+		NetNsPath:          tx.NetNsPath,
+		IfName:             tx.IfName,
+		IPAddresses:        tx.IPAddresses,
+		InfraVnetIP:        tx.InfraVnetIP,
+		EnableSnatOnHost:   tx.EnableSnatOnHost,
+		EnableInfraVnet:    tx.EnableInfraVnet,
+		EnableMultiTenancy: tx.MultiTenancy,
+		Data:               make(map[string]interface{}),
+	}
+
+	if tx.VlanID != 0 {
+		epInfo.Data[network.VlanIDKey] = tx.VlanID
+	}
+
+	for key, option := range tx.Options {
+		epInfo.Data[key] = option
+	}
+
+	// Derives the interface names the same way the interrupted ADD did.
+	setEndpointOptions(nil, epInfo, tx.VethName)
+
+	return epInfo
+}
+
+// Reads the ADD commands in progress from the plugin store.
+func (plugin *netPlugin) loadAddTransactions() map[string]*addTransaction {
+	txs := make(map[string]*addTransaction)
+
+	if plugin.Store == nil {
+		return txs
+	}
+
+	if err := plugin.Store.Read(addTransactionsKey, &txs); err != nil && err != store.ErrKeyNotFound {
+		log.Printf("[cni-net] Failed to read ADD transactions, err:%v.", err)
+	}
+
+	return txs
+}
+
+// Writes the ADD commands in progress to the plugin store.
+func (plugin *netPlugin) saveAddTransactions(txs map[string]*addTransaction) {
+	if plugin.Store == nil {
+		return
+	}
+
+	if err := plugin.Store.Write(addTransactionsKey, txs); err != nil {
+		log.Printf("[cni-net] Failed to save ADD transactions, err:%v.", err)
+	}
+}
+
+// Records that the ADD command enters the given stage, before the stage is started.
+func (plugin *netPlugin) recordAddStage(tx *addTransaction, stage string) {
+	if tx == nil {
+		return
+	}
+
+	tx.Stage = stage
+
+	txs := plugin.loadAddTransactions()
+	txs[tx.EndpointID] = tx
+	plugin.saveAddTransactions(txs)
+}
+
+// Drops the record of an ADD command that completed, or failed and released what it allocated.
+func (plugin *netPlugin) endAddTransaction(tx *addTransaction) {
+	if tx == nil || tx.Stage == "" {
+		return
+	}
+
+	txs := plugin.loadAddTransactions()
+	delete(txs, tx.EndpointID)
+	plugin.saveAddTransactions(txs)
+}
+
+// Releases what an interrupted ADD command of the endpoint left behind.
+// An ADD interrupted after its endpoint was created completed, and its endpoint is kept.
+func (plugin *netPlugin) recoverAddTransaction(networkId string, endpointId string, nwCfg *cni.NetworkConfig) {
+	txs := plugin.loadAddTransactions()
+	tx := txs[endpointId]
+	if tx == nil || tx.NetworkID != networkId {
+		return
+	}
+
+	defer func() {
+		delete(txs, endpointId)
+		plugin.saveAddTransactions(txs)
+	}()
+
+	if _, err := plugin.nm.GetEndpointInfo(networkId, endpointId); err == nil {
+		log.Printf("[cni-net] Interrupted ADD of endpoint %v completed.", endpointId)
+		return
+	}
+
+	log.Printf("[cni-net] Rolling back ADD of endpoint %v interrupted at stage %v.", endpointId, tx.Stage)
+
+	if tx.Stage == addStageCreateEndpoint {
+		if err := plugin.nm.CleanupEndpoint(networkId, tx.getEndpointInfo()); err != nil {
+			log.Printf("[cni-net] Failed to clean up endpoint %v, err:%v.", endpointId, err)
+		}
+	}
+
+	// A warm endpoint may have been moved to the container before the ADD was interrupted.
+	if tx.WarmEndpointID != "" {
+		if err := plugin.nm.DeleteWarmEndpoint(networkId, tx.WarmEndpointID); err == nil {
+			log.Printf("[cni-net] Deleted warm endpoint %v of interrupted ADD.", tx.WarmEndpointID)
+		}
+	}
+
+	ipamCfg := *nwCfg
+
+	// CNS owns the addresses of multitenant endpoints, only their infrastructure VNET address is allocated by IPAM.
+	if tx.MultiTenancy {
+		if tx.InfraVnetIP.IP != nil {
+			cleanupInfraVnetIP(true, &tx.InfraVnetIP, &ipamCfg, plugin)
+		}
+		return
+	}
+
+	if nwCfg.IsStandardIpam() {
+		// Standard IPAM plugins release all addresses of the container at once.
+		if tx.Address != "" {
+			plugin.releaseStandardIpam(&ipamCfg)
+		}
+		return
+	}
+
+	ipamCfg.Ipam.Subnet = tx.Subnet
+	if tx.Address != "" {
+		ipamCfg.Ipam.Address = tx.Address
+		if err := plugin.DelegateDel(ipamCfg.Ipam.Type, &ipamCfg); err != nil {
+			log.Printf("[cni-net] Failed to release address %v of interrupted ADD, err:%v.", tx.Address, err)
+		}
+	}
+
+	// The pool of a network that was never created is released too.
+	if tx.PoolSubnet != "" {
+		if _, err := plugin.nm.GetNetworkInfo(networkId); err != nil {
+			ipamCfg.Ipam.Subnet = tx.PoolSubnet
+			ipamCfg.Ipam.Address = ""
+			if err = plugin.DelegateDel(ipamCfg.Ipam.Type, &ipamCfg); err != nil {
+				log.Printf("[cni-net] Failed to release pool %v of interrupted ADD, err:%v.", tx.PoolSubnet, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+// fakeTransactionNetworkManager holds the endpoints of one network and records the endpoints cleaned up in it.
+type fakeTransactionNetworkManager struct {
+	network.NetworkManager
+	networkId  string
+	endpoints  map[string]*network.EndpointInfo
+	cleanedUp  []*network.EndpointInfo
+	cleanupErr error
+}
+
+func (nm *fakeTransactionNetworkManager) GetNetworkInfo(networkId string) (*network.NetworkInfo, error) {
+	if networkId != nm.networkId {
+		return nil, fmt.Errorf("Network not found")
+	}
+
+	return &network.NetworkInfo{Id: networkId}, nil
+}
+
+func (nm *fakeTransactionNetworkManager) GetEndpointInfo(networkId string, endpointId string) (*network.EndpointInfo, error) {
+	if epInfo := nm.endpoints[endpointId]; networkId == nm.networkId && epInfo != nil {
+		return epInfo, nil
+	}
+
+	return nil, fmt.Errorf("Endpoint not found")
+}
+
+func (nm *fakeTransactionNetworkManager) CleanupEndpoint(networkId string, epInfo *network.EndpointInfo) error {
+	nm.cleanedUp = append(nm.cleanedUp, epInfo)
+	return nm.cleanupErr
+}
+
+// Returns a plugin whose store is a JSON file in a temporary directory.
+func newTransactionTestPlugin(t *testing.T, nm network.NetworkManager) (*netPlugin, func()) {
+	dir, err := ioutil.TempDir("", "transaction")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+
+	kvs, err := store.NewJsonFileStore(dir + "/azure-vnet.json")
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	plugin := &netPlugin{Plugin: &cni.Plugin{Plugin: &common.Plugin{Store: kvs}}, nm: nm}
+	return plugin, func() { os.RemoveAll(dir) }
+}
+
+func TestAddTransactionEndpointInfo(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.4/24")
+	epInfo := &network.EndpointInfo{
+		Id:               "12345678-eth0",
+		IPAddresses:      []net.IPNet{*ipNet},
+		EnableSnatOnHost: true,
+		Data: map[string]interface{}{
+			network.VlanIDKey: 2,
+			"localIP":         "169.254.0.4/17",
+		},
+	}
+
+	plugin, cleanup := newTransactionTestPlugin(t, nil)
+	defer cleanup()
+
+	tx := &addTransaction{NetworkID: "azure", EndpointID: epInfo.Id, MultiTenancy: true}
+	tx.setEndpointInfo(epInfo, "pod1-ns1")
+	plugin.recordAddStage(tx, addStageCreateEndpoint)
+
+	// The record is read back the way the next command of the endpoint reads it.
+	loaded := plugin.loadAddTransactions()[epInfo.Id]
+	if loaded == nil {
+		t.Fatalf("TestAddTransactionEndpointInfo failed @ loadAddTransactions")
+	}
+
+	restored := loaded.getEndpointInfo()
+	if vlanid, ok := restored.Data[network.VlanIDKey].(int); !ok || vlanid != 2 {
+		t.Errorf("TestAddTransactionEndpointInfo failed, VLAN %v", restored.Data[network.VlanIDKey])
+	}
+
+	if restored.Data["localIP"] != "169.254.0.4/17" {
+		t.Errorf("TestAddTransactionEndpointInfo failed, options %v", restored.Data)
+	}
+
+	if len(restored.IPAddresses) != 1 || restored.IPAddresses[0].String() != ipNet.String() ||
+		!restored.EnableSnatOnHost || !restored.EnableMultiTenancy {
+		t.Errorf("TestAddTransactionEndpointInfo failed, endpoint %+v", restored)
+	}
+}
+
+func TestRecoverAddTransaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		stage     string
+		completed bool
+		cleanedUp bool
+	}{
+		{name: "interrupted before the endpoint", stage: addStageCreateNetwork},
+		{name: "interrupted creating the endpoint", stage: addStageCreateEndpoint, cleanedUp: true},
+		{name: "completed", stage: addStageCreateEndpoint, completed: true},
+	}
+
+	for _, test := range tests {
+		nm := &fakeTransactionNetworkManager{networkId: "azure", endpoints: make(map[string]*network.EndpointInfo)}
+		if test.completed {
+			nm.endpoints["12345678-eth0"] = &network.EndpointInfo{Id: "12345678-eth0"}
+		}
+
+		plugin, cleanup := newTransactionTestPlugin(t, nm)
+
+		// CNS owns the addresses of multitenant endpoints, so none are released through IPAM.
+		tx := &addTransaction{NetworkID: "azure", EndpointID: "12345678-eth0", MultiTenancy: true}
+		tx.setEndpointInfo(&network.EndpointInfo{Data: map[string]interface{}{network.VlanIDKey: 2}}, "pod1-ns1")
+		plugin.recordAddStage(tx, test.stage)
+
+		plugin.recoverAddTransaction("azure", "12345678-eth0", &cni.NetworkConfig{MultiTenancy: true})
+
+		if (len(nm.cleanedUp) != 0) != test.cleanedUp {
+			t.Errorf("%v: cleaned up %v endpoints, expected cleanup:%v", test.name, len(nm.cleanedUp), test.cleanedUp)
+		} else if test.cleanedUp && nm.cleanedUp[0].Data[network.VlanIDKey] != 2 {
+			t.Errorf("%v: cleaned up endpoint without its VLAN %+v", test.name, nm.cleanedUp[0])
+		}
+
+		if len(plugin.loadAddTransactions()) != 0 {
+			t.Errorf("%v: record of the ADD was not dropped", test.name)
+		}
+
+		cleanup()
+	}
+}
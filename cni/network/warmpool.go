@@ -0,0 +1,301 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+)
+
+const (
+	// Environment variable passing the network configuration to the warm pool refill process.
+	warmPoolConfigEnv = "AZURE_CNI_WARM_POOL_CONFIG"
+)
+
+// Returns whether the network configuration asks for a warm pool.
+func isWarmPoolEnabled(nwCfg *cni.NetworkConfig) bool {
+	return warmPoolSupported && nwCfg.WarmPool != nil && nwCfg.WarmPool.Size > 0 &&
+		!nwCfg.MultiTenancy && !nwCfg.IsStandardIpam()
+}
+
+// Returns the oldest warm endpoint of the network, or nil if the warm pool is empty.
+func (plugin *netPlugin) getWarmEndpoint(networkId string, nwCfg *cni.NetworkConfig) *network.WarmEndpointInfo {
+	if !isWarmPoolEnabled(nwCfg) {
+		return nil
+	}
+
+	warmEpInfos, err := plugin.nm.GetWarmEndpoints(networkId)
+	if err != nil || len(warmEpInfos) == 0 {
+		log.Printf("[cni-net] Warm pool of network %v is empty.", networkId)
+		return nil
+	}
+
+	return warmEpInfos[0]
+}
+
+// Returns the IPAM result of the address allocated to a warm endpoint.
+func newWarmEndpointResult(warmEpInfo *network.WarmEndpointInfo) *cniTypesCurr.Result {
+	_, defaultRoute, _ := net.ParseCIDR("0.0.0.0/0")
+
+	result := &cniTypesCurr.Result{
+		IPs: []*cniTypesCurr.IPConfig{
+			&cniTypesCurr.IPConfig{
+				Version: getIPVersion(warmEpInfo.IPAddress.IP),
+				Address: warmEpInfo.IPAddress,
+				Gateway: warmEpInfo.Gateway,
+			},
+		},
+		Routes: []*cniTypes.Route{
+			&cniTypes.Route{
+				Dst: *defaultRoute,
+				GW:  warmEpInfo.Gateway,
+			},
+		},
+	}
+
+	result.DNS.Domain = warmEpInfo.DNS.Suffix
+	result.DNS.Nameservers = warmEpInfo.DNS.Servers
+
+	return result
+}
+
+// Remembers to refill the warm pool of the network after the command completes.
+func (plugin *netPlugin) scheduleWarmPoolRefill(networkId string, nwCfg *cni.NetworkConfig, stdinData []byte) {
+	if !isWarmPoolEnabled(nwCfg) {
+		return
+	}
+
+	warmEpInfos, err := plugin.nm.GetWarmEndpoints(networkId)
+	if err == nil && len(warmEpInfos) >= nwCfg.WarmPool.Size {
+		return
+	}
+
+	plugin.warmPoolConfig = stdinData
+}
+
+// StartWarmPoolRefill starts a background process refilling the warm pool, if the last command used it.
+func (plugin *netPlugin) StartWarmPoolRefill() error {
+	if plugin.warmPoolConfig == nil {
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[cni-net] Starting warm pool refill process.")
+
+	args := []string{"-" + common.OptRefillWarmPool}
+	env := append(os.Environ(), fmt.Sprintf("%v=%s", warmPoolConfigEnv, plugin.warmPoolConfig))
+
+	return common.StartProcessWithArgs(path, args, env)
+}
+
+// WarmPoolRefill is the plan to refill the warm pool of a network.
+type warmPoolRefill struct {
+	networkId string
+	nwInfo    *network.NetworkInfo
+	nwCfg     *cni.NetworkConfig
+	missing   int
+}
+
+// RefillWarmPool prepares warm endpoints until the warm pool of the network in the environment is full.
+// Warm endpoints above the configured size are deleted. The store is only locked to plan the refill and to add
+// each warm endpoint to the state, the interfaces are created without holding it.
+func (plugin *netPlugin) RefillWarmPool(config *common.PluginConfig) error {
+	nwCfg, err := cni.ParseNetworkConfig([]byte(os.Getenv(warmPoolConfigEnv)))
+	if err != nil {
+		return plugin.ErrorfWithCode(cni.ErrInvalidNetworkConfig, "Failed to parse network configuration: %v", err)
+	}
+
+	if !isWarmPoolEnabled(nwCfg) {
+		log.Printf("[cni-net] Warm pool is not enabled for network %v.", nwCfg.Name)
+		return nil
+	}
+
+	if err = plugin.Plugin.InitializeKeyValueStore(config); err != nil {
+		return err
+	}
+
+	if err = plugin.nm.Initialize(config); err != nil {
+		plugin.Plugin.UninitializeKeyValueStore()
+		return err
+	}
+
+	refill, err := plugin.planWarmPoolRefill(nwCfg)
+	plugin.Plugin.UninitializeKeyValueStore()
+	if err != nil || refill == nil {
+		return err
+	}
+
+	for i := 0; i < refill.missing; i++ {
+		warmEpInfo, err := plugin.prepareWarmEndpoint(refill)
+		if err != nil {
+			return err
+		}
+
+		if err = plugin.Plugin.InitializeKeyValueStore(config); err != nil {
+			plugin.discardWarmEndpoint(refill, warmEpInfo)
+			return err
+		}
+
+		// Read the state again, other commands may have changed it while the warm endpoint was prepared.
+		nm, err := network.NewNetworkManager()
+		if err == nil {
+			err = nm.Initialize(config)
+		}
+
+		var added bool
+		if err == nil {
+			added, err = addWarmEndpoint(nm, refill, warmEpInfo)
+		}
+
+		plugin.Plugin.UninitializeKeyValueStore()
+
+		if !added {
+			plugin.discardWarmEndpoint(refill, warmEpInfo)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the warm endpoints missing from the warm pool of the network, or nil if the warm pool is full or the
+// network doesn't exist. Warm endpoints above the configured size are deleted.
+// This function should only be called when the store is locked.
+func (plugin *netPlugin) planWarmPoolRefill(nwCfg *cni.NetworkConfig) (*warmPoolRefill, error) {
+	networkId := nwCfg.Name
+	nwInfo, err := plugin.nm.GetNetworkInfo(networkId)
+	if err != nil {
+		// The network is created by the first ADD command.
+		log.Printf("[cni-net] Network %v for warm pool not found, err:%v.", networkId, err)
+		return nil, nil
+	}
+
+	warmEpInfos, err := plugin.nm.GetWarmEndpoints(networkId)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[cni-net] Refilling warm pool of network %v from %v to %v endpoints.", networkId, len(warmEpInfos), nwCfg.WarmPool.Size)
+
+	for i := nwCfg.WarmPool.Size; i < len(warmEpInfos); i++ {
+		plugin.deleteWarmEndpoint(networkId, nwInfo, nwCfg, warmEpInfos[i])
+	}
+
+	if len(warmEpInfos) >= nwCfg.WarmPool.Size {
+		return nil, nil
+	}
+
+	return &warmPoolRefill{
+		networkId: networkId,
+		nwInfo:    nwInfo,
+		nwCfg:     nwCfg,
+		missing:   nwCfg.WarmPool.Size - len(warmEpInfos),
+	}, nil
+}
+
+// Allocates an address and creates the interfaces of a warm endpoint for it, without adding it to the state.
+func (plugin *netPlugin) prepareWarmEndpoint(refill *warmPoolRefill) (*network.WarmEndpointInfo, error) {
+	nwCfg := refill.nwCfg
+	nwCfg.Ipam.Subnet = refill.nwInfo.Subnets[0].Prefix.String()
+	nwCfg.Ipam.Address = ""
+
+	result, err := plugin.DelegateAdd(nwCfg.Ipam.Type, nwCfg)
+	if err != nil {
+		return nil, plugin.ErrorfWithCode(cni.ErrIpamFailure, "Failed to allocate address: %v", err)
+	}
+
+	ipconfig := result.IPs[0]
+	warmEpInfo := &network.WarmEndpointInfo{
+		IPAddress: ipconfig.Address,
+		Gateway:   ipconfig.Gateway,
+		DNS: network.DNSInfo{
+			Suffix:  result.DNS.Domain,
+			Servers: result.DNS.Nameservers,
+		},
+	}
+
+	prepared, err := plugin.nm.PrepareWarmEndpoint(refill.networkId, warmEpInfo)
+	if err != nil {
+		nwCfg.Ipam.Address = ipconfig.Address.IP.String()
+		plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg)
+		return nil, plugin.ErrorfWithCode(cni.ErrDataplane, "Failed to create warm endpoint: %v", err)
+	}
+
+	return prepared, nil
+}
+
+// Adds a prepared warm endpoint to the warm pool of the network. It returns false if the warm pool was filled or
+// the network deleted by other commands meanwhile.
+// This function should only be called when the store is locked.
+func addWarmEndpoint(nm network.NetworkManager, refill *warmPoolRefill, warmEpInfo *network.WarmEndpointInfo) (bool, error) {
+	warmEpInfos, err := nm.GetWarmEndpoints(refill.networkId)
+	if err != nil {
+		log.Printf("[cni-net] Network %v of warm endpoint %v not found, err:%v.", refill.networkId, warmEpInfo.Id, err)
+		return false, nil
+	}
+
+	if len(warmEpInfos) >= refill.nwCfg.WarmPool.Size {
+		log.Printf("[cni-net] Warm pool of network %v was filled meanwhile.", refill.networkId)
+		return false, nil
+	}
+
+	if err = nm.AddWarmEndpoint(refill.networkId, warmEpInfo); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Deletes the interfaces of a prepared warm endpoint that was not added to the state, and releases its address.
+func (plugin *netPlugin) discardWarmEndpoint(refill *warmPoolRefill, warmEpInfo *network.WarmEndpointInfo) {
+	if err := plugin.nm.DiscardWarmEndpoint(refill.networkId, warmEpInfo); err != nil {
+		log.Printf("[cni-net] Failed to discard warm endpoint %v, err:%v.", warmEpInfo.Id, err)
+	}
+
+	nwCfg := refill.nwCfg
+	nwCfg.Ipam.Subnet = refill.nwInfo.Subnets[0].Prefix.String()
+	nwCfg.Ipam.Address = warmEpInfo.IPAddress.IP.String()
+	if err := plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg); err != nil {
+		log.Printf("[cni-net] Failed to release address %v of warm endpoint, err:%v.", nwCfg.Ipam.Address, err)
+	}
+}
+
+// Deletes a warm endpoint and releases its address.
+func (plugin *netPlugin) deleteWarmEndpoint(networkId string, nwInfo *network.NetworkInfo, nwCfg *cni.NetworkConfig, warmEpInfo *network.WarmEndpointInfo) {
+	if err := plugin.nm.DeleteWarmEndpoint(networkId, warmEpInfo.Id); err != nil {
+		log.Printf("[cni-net] Failed to delete warm endpoint %v, err:%v.", warmEpInfo.Id, err)
+		return
+	}
+
+	nwCfg.Ipam.Subnet = nwInfo.Subnets[0].Prefix.String()
+	nwCfg.Ipam.Address = warmEpInfo.IPAddress.IP.String()
+	if err := plugin.DelegateDel(nwCfg.Ipam.Type, nwCfg); err != nil {
+		log.Printf("[cni-net] Failed to release address %v of warm endpoint, err:%v.", nwCfg.Ipam.Address, err)
+	}
+}
+
+// Deletes all warm endpoints of the network and releases their addresses.
+func (plugin *netPlugin) drainWarmPool(networkId string, nwInfo *network.NetworkInfo, nwCfg *cni.NetworkConfig) {
+	warmEpInfos, err := plugin.nm.GetWarmEndpoints(networkId)
+	if err != nil {
+		return
+	}
+
+	ipamCfg := *nwCfg
+	for _, warmEpInfo := range warmEpInfos {
+		plugin.deleteWarmEndpoint(networkId, nwInfo, &ipamCfg, warmEpInfo)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+)
+
+// fakeWarmPoolNetworkManager holds the warm pool of one network and records the warm endpoints added to it.
+type fakeWarmPoolNetworkManager struct {
+	network.NetworkManager
+	nwInfo      *network.NetworkInfo
+	warmEpInfos []*network.WarmEndpointInfo
+	addErr      error
+}
+
+func (nm *fakeWarmPoolNetworkManager) GetNetworkInfo(networkId string) (*network.NetworkInfo, error) {
+	if nm.nwInfo == nil || networkId != nm.nwInfo.Id {
+		return nil, fmt.Errorf("Network not found")
+	}
+
+	return nm.nwInfo, nil
+}
+
+func (nm *fakeWarmPoolNetworkManager) GetWarmEndpoints(networkId string) ([]*network.WarmEndpointInfo, error) {
+	if _, err := nm.GetNetworkInfo(networkId); err != nil {
+		return nil, err
+	}
+
+	return nm.warmEpInfos, nil
+}
+
+func (nm *fakeWarmPoolNetworkManager) AddWarmEndpoint(networkId string, warmEpInfo *network.WarmEndpointInfo) error {
+	if nm.addErr != nil {
+		return nm.addErr
+	}
+
+	nm.warmEpInfos = append(nm.warmEpInfos, warmEpInfo)
+	return nil
+}
+
+// Returns a warm pool with the given number of warm endpoints.
+func newWarmEpInfos(count int) []*network.WarmEndpointInfo {
+	warmEpInfos := make([]*network.WarmEndpointInfo, count)
+	for i := range warmEpInfos {
+		warmEpInfos[i] = &network.WarmEndpointInfo{Id: fmt.Sprintf("warm-%d", i)}
+	}
+
+	return warmEpInfos
+}
+
+func TestPlanWarmPoolRefill(t *testing.T) {
+	tests := []struct {
+		name    string
+		nwInfo  *network.NetworkInfo
+		warm    int
+		missing int
+	}{
+		{name: "network not created"},
+		{name: "empty", nwInfo: &network.NetworkInfo{Id: "azure"}, missing: 3},
+		{name: "partially filled", nwInfo: &network.NetworkInfo{Id: "azure"}, warm: 2, missing: 1},
+		{name: "full", nwInfo: &network.NetworkInfo{Id: "azure"}, warm: 3},
+	}
+
+	for _, test := range tests {
+		nm := &fakeWarmPoolNetworkManager{nwInfo: test.nwInfo, warmEpInfos: newWarmEpInfos(test.warm)}
+		plugin := &netPlugin{nm: nm}
+		nwCfg := &cni.NetworkConfig{Name: "azure", WarmPool: &cni.WarmPoolConfig{Size: 3}}
+
+		refill, err := plugin.planWarmPoolRefill(nwCfg)
+		if err != nil {
+			t.Errorf("%v: planWarmPoolRefill failed: %v", test.name, err)
+			continue
+		}
+
+		missing := 0
+		if refill != nil {
+			missing = refill.missing
+		}
+
+		if missing != test.missing {
+			t.Errorf("%v: planned %v warm endpoints, expected %v", test.name, missing, test.missing)
+		}
+	}
+}
+
+// Tests that prepared warm endpoints are only added while the warm pool is not full.
+func TestAddWarmEndpoint(t *testing.T) {
+	tests := []struct {
+		name   string
+		nwInfo *network.NetworkInfo
+		warm   int
+		addErr error
+		added  bool
+		err    bool
+	}{
+		{name: "added", nwInfo: &network.NetworkInfo{Id: "azure"}, warm: 2, added: true},
+		{name: "network deleted meanwhile"},
+		{name: "filled meanwhile", nwInfo: &network.NetworkInfo{Id: "azure"}, warm: 3},
+		{name: "add failed", nwInfo: &network.NetworkInfo{Id: "azure"}, addErr: fmt.Errorf("save failed"), err: true},
+	}
+
+	for _, test := range tests {
+		nm := &fakeWarmPoolNetworkManager{nwInfo: test.nwInfo, warmEpInfos: newWarmEpInfos(test.warm), addErr: test.addErr}
+		refill := &warmPoolRefill{
+			networkId: "azure",
+			nwCfg:     &cni.NetworkConfig{Name: "azure", WarmPool: &cni.WarmPoolConfig{Size: 3}},
+			missing:   1,
+		}
+
+		added, err := addWarmEndpoint(nm, refill, &network.WarmEndpointInfo{Id: "prepared"})
+		if added != test.added || (err != nil) != test.err {
+			t.Errorf("%v: addWarmEndpoint returned added:%v err:%v", test.name, added, err)
+		}
+	}
+}
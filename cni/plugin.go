@@ -12,6 +12,7 @@ import (
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/store"
+	"github.com/Azure/azure-container-networking/telemetry"
 
 	cniInvoke "github.com/containernetworking/cni/pkg/invoke"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
@@ -35,15 +36,20 @@ func NewPlugin(name, version string) (*Plugin, error) {
 	}
 
 	// Initialize logging.
-	log.SetName(plugin.Name)
-	log.SetLevel(log.LevelInfo)
-	err = log.SetTarget(log.TargetLogfile)
+	err = InitLogging(plugin.Name)
+
+	return &Plugin{
+		Plugin:  plugin,
+		version: version,
+	}, err
+}
+
+// NewEmbeddedPlugin creates a CNI plugin running in the process of another component, which keeps its logging
+// configuration.
+func NewEmbeddedPlugin(name, version string) (*Plugin, error) {
+	plugin, err := common.NewPlugin(name, version)
 	if err != nil {
-		log.Printf("[cni] Failed to configure logging, err:%v.\n", err)
-		return &Plugin{
-			Plugin:  plugin,
-			version: version,
-		}, err
+		return nil, err
 	}
 
 	return &Plugin{
@@ -52,6 +58,23 @@ func NewPlugin(name, version string) (*Plugin, error) {
 	}, nil
 }
 
+// InitLogging configures logging to the log file of the plugin with the given name.
+func InitLogging(name string) error {
+	log.SetName(name)
+	log.SetLevel(log.LevelInfo)
+	err := log.SetTarget(log.TargetLogfile)
+	if err != nil {
+		log.Printf("[cni] Failed to configure logging, err:%v.\n", err)
+		return err
+	}
+
+	if err = log.ConfigureFromEnv(); err != nil {
+		log.Printf("[cni] Failed to apply log configuration from environment, err:%v.\n", err)
+	}
+
+	return nil
+}
+
 // Initialize initializes the plugin.
 func (plugin *Plugin) Initialize(config *common.PluginConfig) error {
 	// Initialize the base plugin.
@@ -67,9 +90,11 @@ func (plugin *Plugin) Uninitialize() {
 
 // Execute executes the CNI command.
 func (plugin *Plugin) Execute(api PluginApi) (err error) {
-	// Recover from panics and convert them to CNI errors.
+	// Recover from panics, report them as crashes and convert them to CNI errors.
 	defer func() {
 		if r := recover(); r != nil {
+			telemetry.ReportPanic(plugin.Name, plugin.version, r)
+
 			buf := make([]byte, 1<<12)
 			len := runtime.Stack(buf, false)
 
@@ -108,9 +133,9 @@ func (plugin *Plugin) DelegateAdd(pluginName string, nwCfg *NetworkConfig) (*cni
 
 	os.Setenv(Cmd, CmdAdd)
 
-	res, err := cniInvoke.DelegateAdd(pluginName, nwCfg.Serialize(), nil)
+	res, err := cniInvoke.DelegateAdd(pluginName, nwCfg.serializeForIpam(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to delegate: %v", err)
+		return nil, delegateError(err)
 	}
 
 	result, err = cniTypesCurr.NewResultFromResult(res)
@@ -130,22 +155,35 @@ func (plugin *Plugin) DelegateDel(pluginName string, nwCfg *NetworkConfig) error
 
 	os.Setenv(Cmd, CmdDel)
 
-	err = cniInvoke.DelegateDel(pluginName, nwCfg.Serialize(), nil)
+	err = cniInvoke.DelegateDel(pluginName, nwCfg.serializeForIpam(), nil)
 	if err != nil {
-		return fmt.Errorf("Failed to delegate: %v", err)
+		return delegateError(err)
 	}
 
 	return nil
 }
 
-// Error creates and logs a structured CNI error.
+// Returns the error of a delegated plugin, keeping the code of CNI errors.
+func delegateError(err error) error {
+	if cniErr, ok := err.(*cniTypes.Error); ok {
+		return &cniTypes.Error{
+			Code:    cniErr.Code,
+			Msg:     fmt.Sprintf("Failed to delegate: %v", cniErr.Msg),
+			Details: cniErr.Details,
+		}
+	}
+
+	return fmt.Errorf("Failed to delegate: %v", err)
+}
+
+// Error creates and logs a structured CNI error, with the error code of its cause.
 func (plugin *Plugin) Error(err error) *cniTypes.Error {
 	var cniErr *cniTypes.Error
 	var ok bool
 
 	// Wrap error if necessary.
 	if cniErr, ok = err.(*cniTypes.Error); !ok {
-		cniErr = &cniTypes.Error{Code: 100, Msg: err.Error()}
+		cniErr = NewError(GetErrorCode(err), err.Error())
 	}
 
 	log.Printf("[%v] %+v.", plugin.Name, cniErr.Error())
@@ -154,8 +192,23 @@ func (plugin *Plugin) Error(err error) *cniTypes.Error {
 }
 
 // Errorf creates and logs a custom CNI error according to a format specifier.
+// The error has the error code of the first error argument.
 func (plugin *Plugin) Errorf(format string, args ...interface{}) *cniTypes.Error {
-	return plugin.Error(fmt.Errorf(format, args...))
+	return plugin.ErrorfWithCode(ErrRuntime, format, args...)
+}
+
+// ErrorfWithCode creates and logs a custom CNI error according to a format specifier.
+// The error has the error code of the first error argument if its cause is known, and the given code otherwise.
+func (plugin *Plugin) ErrorfWithCode(code uint, format string, args ...interface{}) *cniTypes.Error {
+	if causeCode, ok := classifyError(getCause(args)); ok {
+		code = causeCode
+	}
+
+	cniErr := NewError(code, fmt.Sprintf(format, args...))
+
+	log.Printf("[%v] %+v.", plugin.Name, cniErr.Error())
+
+	return cniErr
 }
 
 // Initialize key-value store
@@ -185,6 +238,10 @@ func (plugin *Plugin) InitializeKeyValueStore(config *common.PluginConfig) error
 		}
 	}
 
+	if config.StoreLockTimeout > 0 {
+		plugin.Store.SetLockTimeout(config.StoreLockTimeout)
+	}
+
 	// Acquire store lock.
 	if err := plugin.Store.Lock(true); err != nil {
 		log.Printf("[cni] Failed to lock store: %v.", err)
@@ -0,0 +1,65 @@
+package main
+
+// Prometheus endpoint of the metrics of the CNI plugins, which don't run long enough to be scraped
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	// Path of the metrics in the Prometheus text format.
+	metricsPath = "/metrics"
+)
+
+// Store holding the state and the metrics of the azure-vnet-ipam plugin. Replaced by tests.
+var ipamStorePath = platform.CNIRuntimePath + "azure-vnet-ipam.json"
+
+// startMetricsServer serves the metrics persisted by the IPAM plugin on the given address.
+func startMetricsServer(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, handleMetrics)
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("[Telemetry] Metrics server stopped: %v", err)
+		}
+	}()
+
+	log.Printf("[Telemetry] Serving metrics on %v%v", address, metricsPath)
+	return nil
+}
+
+// Handles Prometheus scrapes of the metrics of the IPAM plugin, read from its store on each scrape.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kvs, err := store.NewJsonFileStore(ipamStorePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metrics, err := ipam.ReadMetrics(kvs)
+	if err != nil {
+		log.Printf("[Telemetry] Failed to read IPAM metrics: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.WriteMetrics(w)
+}
@@ -3,21 +3,206 @@ package main
 // Entry point of the telemetry service if started by CNI
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/Azure/azure-container-networking/common/config"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/telemetry"
 )
 
 const (
-	reportToHostIntervalInSeconds = 60 * time.Second
-	azurecnitelemetry             = "azure-vnet-telemetry"
+	azurecnitelemetry = "azure-vnet-telemetry"
+
+	// Configuration file of the telemetry service, next to its binary.
+	configFileName = "azure-vnet-telemetry.config"
+
+	// Interval at which the configuration file is checked for changes.
+	configReloadInterval = time.Minute
+)
+
+// Configuration keys of the telemetry service.
+const (
+	keyAck            = "ack"
+	keySummaryOnly    = "summaryOnly"
+	keyReportInterval = "reportInterval"
+	keyHostReportURL  = "hostReportURL"
+	keyLogLevel       = "logLevel"
+	keyForceTakeover  = "forceTakeover"
+	keyScrubPolicy    = "scrubPolicy"
+	keyMemoryBudget   = "memoryBudget"
+	keyGrpcURL        = "grpcURL"
+	keyFailoverURLs   = "failoverURLs"
+	keyMetricsAddress = "metricsAddress"
 )
 
+// configKeys are the configuration values of the telemetry service.
+var configKeys = []config.Key{
+	{
+		Name:        keyAck,
+		Env:         "AZURE_VNET_TELEMETRY_ACK",
+		Flag:        "ack",
+		Description: "Keep reports until the host acknowledges them, persisting them across restarts",
+		Default:     false,
+	},
+	{
+		Name:        keySummaryOnly,
+		Env:         "AZURE_VNET_TELEMETRY_SUMMARY_ONLY",
+		Flag:        "summary-only",
+		Description: "Send only failed CNI reports besides the interval summaries",
+		Default:     false,
+	},
+	{
+		Name:        keyReportInterval,
+		Env:         "AZURE_VNET_TELEMETRY_REPORT_INTERVAL",
+		Flag:        "report-interval",
+		Description: "Interval at which buffered reports are sent to the host",
+		Default:     60 * time.Second,
+		Validate: func(value interface{}) error {
+			if value.(time.Duration) < time.Second {
+				return fmt.Errorf("must be at least 1s")
+			}
+			return nil
+		},
+	},
+	{
+		Name:        keyHostReportURL,
+		Env:         "AZURE_VNET_TELEMETRY_HOST_REPORT_URL",
+		Flag:        "host-report-url",
+		Description: "URL of the host endpoint receiving the reports, empty for the default",
+		Default:     "",
+	},
+	{
+		Name:        keyLogLevel,
+		Env:         log.EnvLogLevel,
+		Flag:        "log-level",
+		Description: "Log level, and levels of components",
+		Default:     "info",
+		Validate: func(value interface{}) error {
+			_, _, err := log.ParseLevels(value.(string))
+			return err
+		},
+	},
+	{
+		Name:        keyForceTakeover,
+		Env:         "AZURE_VNET_TELEMETRY_FORCE_TAKEOVER",
+		Flag:        "force-takeover",
+		Description: "Take the socket over from the running instance even if it is alive",
+		Default:     false,
+	},
+	{
+		Name:        keyScrubPolicy,
+		Env:         "AZURE_VNET_TELEMETRY_SCRUB_POLICY",
+		Flag:        "scrub-policy",
+		Description: "Path of the JSON policy file of the data scrubbed from the reports, empty to scrub nothing",
+		Default:     "",
+	},
+	{
+		Name:        keyMemoryBudget,
+		Env:         "AZURE_VNET_TELEMETRY_MEMORY_BUDGET",
+		Flag:        "memory-budget",
+		Description: "Bytes of encoded reports buffered before the oldest, least severe ones are evicted, 0 for no limit",
+		Default:     8 << 20,
+		Validate: func(value interface{}) error {
+			if value.(int) < 0 {
+				return fmt.Errorf("must not be negative")
+			}
+			return nil
+		},
+	},
+	{
+		Name:        keyGrpcURL,
+		Env:         "AZURE_VNET_TELEMETRY_GRPC_URL",
+		Flag:        "grpc-url",
+		Description: "URL of the gRPC API receiving reports, e.g. tcp://localhost:10092, empty to serve only the socket",
+		Default:     "",
+	},
+	{
+		Name:        keyFailoverURLs,
+		Env:         "AZURE_VNET_TELEMETRY_FAILOVER_URLS",
+		Flag:        "failover-urls",
+		Description: "URLs the payloads are sent to, in order, while the host report URL fails, e.g. a proxy or a file:// sink",
+		Default:     []string{},
+	},
+	{
+		Name:        keyMetricsAddress,
+		Env:         "AZURE_VNET_TELEMETRY_METRICS_ADDRESS",
+		Flag:        "metrics-address",
+		Description: "Address serving the IPAM metrics of the CNI plugins to Prometheus, e.g. localhost:10093, empty to disable",
+		Default:     "",
+	},
+}
+
+// bufferKeys are the configuration values applied to the running buffer when they change.
+var bufferKeys = map[string]bool{
+	keyHostReportURL:  true,
+	keyReportInterval: true,
+	keySummaryOnly:    true,
+	keyMemoryBudget:   true,
+	keyFailoverURLs:   true,
+	keyScrubPolicy:    true,
+}
+
+// getBufferConfig returns the settings of the buffer from the configuration.
+func getBufferConfig(cfg *config.Config) telemetry.BufferConfig {
+	return telemetry.BufferConfig{
+		HostReportURL:  cfg.GetString(keyHostReportURL),
+		ReportInterval: cfg.GetDuration(keyReportInterval),
+		SummaryOnly:    cfg.GetBool(keySummaryOnly),
+		MemoryBudget:   cfg.GetInt(keyMemoryBudget),
+		FailoverURLs:   cfg.GetStringSlice(keyFailoverURLs),
+		ScrubPolicy:    cfg.GetString(keyScrubPolicy),
+	}
+}
+
+// loadConfig returns the configuration of the telemetry service from its defaults, its configuration file,
+// the environment and the command line, in increasing order of precedence.
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.NewConfig(configKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	configPath := flag.String("config", defaultConfigPath(), "Path of the JSON configuration file")
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	if err = cfg.LoadFile(*configPath); err != nil {
+		return nil, err
+	}
+
+	if err = cfg.LoadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err = cfg.LoadFlags(flag.CommandLine); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// defaultConfigPath returns the path of the configuration file in the directory of the binary.
+func defaultConfigPath() string {
+	path, err := os.Executable()
+	if err != nil {
+		return configFileName
+	}
+
+	return filepath.Join(filepath.Dir(path), configFileName)
+}
+
 func main() {
 	var tb *telemetry.TelemetryBuffer
-	var err error
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
 
 	log.SetName(azurecnitelemetry)
 	log.SetLevel(log.LevelInfo)
@@ -26,9 +211,49 @@ func main() {
 		fmt.Printf("log settarget failed")
 	}
 
+	if err = log.ConfigureFromEnv(); err != nil {
+		log.Printf("[Telemetry] Failed to apply log configuration from environment: %v", err)
+	}
+
+	if err = log.Configure(&log.Config{Level: cfg.GetString(keyLogLevel)}); err != nil {
+		log.Printf("[Telemetry] Failed to apply log level: %v", err)
+	}
+
+	log.Printf("[Telemetry] Configuration: %v", cfg)
+
+	// The log level is applied when it changes, the settings of the buffer once it runs, see bufferKeys,
+	// and the other values when the service restarts.
+	cfg.OnChange(func(name string, value interface{}) {
+		log.Printf("[Telemetry] Configuration %v changed to %v", name, value)
+		if name == keyLogLevel {
+			log.Configure(&log.Config{Level: value.(string)})
+		}
+	})
+
+	go cfg.WatchFile(configReloadInterval, nil, func(err error) {
+		log.Printf("[Telemetry] Failed to reload configuration: %v", err)
+	})
+
+	if telemetry.IsDisabled() {
+		log.Printf("[Telemetry] Telemetry is disabled on this machine, exiting")
+		return
+	}
+
 	log.Printf("[Telemetry] TelemetryBuffer process started")
 	for {
-		tb = telemetry.NewTelemetryBuffer("")
+		tb = telemetry.NewTelemetryBuffer(cfg.GetString(keyHostReportURL))
+		if cfg.GetBool(keyForceTakeover) {
+			tb.EnableForceTakeover()
+		}
+
+		// Reports must not be accepted unscrubbed when a policy is configured.
+		if policyFile := cfg.GetString(keyScrubPolicy); policyFile != "" {
+			if err = tb.EnableScrubbing(policyFile); err != nil {
+				log.Printf("[Telemetry] Failed to load scrub policy: %v, exiting", err)
+				os.Exit(1)
+			}
+		}
+
 		err = tb.StartServer()
 		if err == nil || tb.FdExists {
 			log.Printf("[Telemetry] Server started")
@@ -41,6 +266,47 @@ func main() {
 		time.Sleep(time.Millisecond * 200)
 	}
 
-	tb.BufferAndPushData(reportToHostIntervalInSeconds)
+	if cfg.GetBool(keyAck) {
+		if err = tb.EnableAckMode(telemetry.DeliveryStateFile); err != nil {
+			log.Printf("[Telemetry] Failed to restore delivery state: %v", err)
+		}
+	}
+
+	if cfg.GetBool(keySummaryOnly) {
+		tb.EnableSummaryOnlyMode()
+	}
+
+	tb.EnableMemoryBudget(cfg.GetInt(keyMemoryBudget))
+
+	if failoverURLs := cfg.GetStringSlice(keyFailoverURLs); len(failoverURLs) > 0 {
+		if err = tb.EnableFailover(failoverURLs); err != nil {
+			log.Printf("[Telemetry] Failed to enable failover: %v", err)
+		}
+	}
+
+	// The gRPC API is served by the instance buffering the reports, next to the socket of legacy clients.
+	if grpcURL := cfg.GetString(keyGrpcURL); grpcURL != "" && !tb.FdExists {
+		if err = tb.StartRPCServer(grpcURL); err != nil {
+			log.Printf("[Telemetry] Failed to start gRPC server: %v", err)
+		}
+	}
+
+	if metricsAddress := cfg.GetString(keyMetricsAddress); metricsAddress != "" && !tb.FdExists {
+		if err = startMetricsServer(metricsAddress); err != nil {
+			log.Printf("[Telemetry] Failed to start metrics server: %v", err)
+		}
+	}
+
+	cfg.OnChange(func(name string, value interface{}) {
+		if !bufferKeys[name] {
+			return
+		}
+
+		if err := tb.Reconfigure(getBufferConfig(cfg)); err != nil {
+			log.Printf("[Telemetry] Failed to apply configuration: %v", err)
+		}
+	})
+
+	tb.BufferAndPushData(cfg.GetDuration(keyReportInterval))
 	log.Printf("[Telemetry] TelemetryBuffer process exiting")
 }
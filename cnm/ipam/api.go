@@ -16,6 +16,9 @@ const (
 	RequestAddressPath   = "/IpamDriver.RequestAddress"
 	ReleaseAddressPath   = "/IpamDriver.ReleaseAddress"
 
+	// Address manager metrics in the Prometheus text format
+	MetricsPath = "/metrics"
+
 	// Libnetwork IPAM plugin options
 	OptAddressType        = "RequestAddressType"
 	OptAddressTypeGateway = "com.docker.network.gateway"
@@ -5,6 +5,7 @@ package ipam
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cnm"
 	"github.com/Azure/azure-container-networking/common"
@@ -71,6 +72,18 @@ func (plugin *ipamPlugin) Start(config *common.PluginConfig) error {
 		return err
 	}
 
+	// Start address leases, renewed while the containers holding the addresses exist.
+	if ttl, _ := plugin.GetOption(common.OptIpamLeaseTTL).(int); ttl > 0 {
+		checker, err := newContainerChecker()
+		if err == nil {
+			err = plugin.am.StartLeases(time.Duration(ttl)*time.Second, checker)
+		}
+		if err != nil {
+			log.Printf("[ipam] Failed to start address leases, err:%v.", err)
+			return err
+		}
+	}
+
 	// Add protocol handlers.
 	listener := plugin.Listener
 	listener.AddEndpoint(plugin.EndpointType)
@@ -81,6 +94,7 @@ func (plugin *ipamPlugin) Start(config *common.PluginConfig) error {
 	listener.AddHandler(GetPoolInfoPath, plugin.getPoolInfo)
 	listener.AddHandler(RequestAddressPath, plugin.requestAddress)
 	listener.AddHandler(ReleaseAddressPath, plugin.releaseAddress)
+	listener.AddHandler(MetricsPath, plugin.getMetrics)
 
 	// Plugin is ready to be discovered.
 	err = plugin.EnableDiscovery()
@@ -264,6 +278,7 @@ func (plugin *ipamPlugin) requestAddress(w http.ResponseWriter, r *http.Request)
 	}
 
 	options[ipam.OptAddressID] = req.Options[ipam.OptAddressID]
+	options[ipam.OptAddressOwner] = req.Options[ipam.OptAddressOwner]
 
 	addr, err := plugin.am.RequestAddress(poolId.AsId, poolId.Subnet, req.Address, options)
 	if err != nil {
@@ -311,3 +326,14 @@ func (plugin *ipamPlugin) releaseAddress(w http.ResponseWriter, r *http.Request)
 
 	log.Response(plugin.Name, &resp, returnCode, returnStr, err)
 }
+
+// Handles Prometheus scrapes of the address manager metrics.
+func (plugin *ipamPlugin) getMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	plugin.am.GetMetrics().WriteMetrics(w)
+}
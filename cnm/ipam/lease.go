@@ -0,0 +1,41 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// Timeout of the requests to the container runtime.
+	containerCheckTimeout = 10 * time.Second
+)
+
+// containerChecker reports whether the container holding an address lease exists, by inspecting it
+// through the Docker engine API. Lease owners are the IDs of the containers.
+type containerChecker struct {
+	client *http.Client
+}
+
+// IsAlive returns whether the container with the given ID exists.
+// Only a not found response reports the container gone, any other failure keeps its leases.
+func (c *containerChecker) IsAlive(owner string) (bool, error) {
+	res, err := c.client.Get("http://docker/containers/" + url.PathEscape(owner) + "/json")
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("Docker returned http status code %v for container %v", res.StatusCode, owner)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/Azure/azure-container-networking/ipam"
+)
+
+const (
+	// Docker engine API socket.
+	dockerSocket = "/var/run/docker.sock"
+)
+
+// Creates a checker of the containers holding address leases.
+// Leases need Docker, which nodes running other container runtimes don't have.
+func newContainerChecker() (ipam.LeaseChecker, error) {
+	if _, err := os.Stat(dockerSocket); err != nil {
+		return nil, fmt.Errorf("Address leases need the Docker engine API: %v", err)
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", dockerSocket)
+		},
+	}
+
+	return &containerChecker{
+		client: &http.Client{Transport: transport, Timeout: containerCheckTimeout},
+	}, nil
+}
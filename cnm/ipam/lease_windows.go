@@ -0,0 +1,15 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/ipam"
+)
+
+// Creates a checker of the containers holding address leases.
+func newContainerChecker() (ipam.LeaseChecker, error) {
+	return nil, fmt.Errorf("Address leases are not supported on Windows")
+}
@@ -19,6 +19,11 @@ const (
 
 	// Libnetwork network plugin options
 	modeOption = "com.microsoft.azure.network.mode"
+
+	// Endpoint options of callers managing network namespaces themselves, which the interface is moved into.
+	netNsPathOption = "com.microsoft.azure.network.netns.path"
+	netNsPidOption  = "com.microsoft.azure.network.netns.pid"
+	ifNameOption    = "com.microsoft.azure.network.ifname"
 )
 
 // Request sent by libnetwork when querying plugin capabilities.
@@ -103,9 +108,11 @@ type joinRequest struct {
 }
 
 // Response sent by plugin when an endpoint is joined to a sandbox.
+// InterfaceName is omitted for endpoints whose interface is already in a network namespace,
+// so that libnetwork doesn't look for it in the host namespace.
 type joinResponse struct {
 	Err           string
-	InterfaceName interfaceName
+	InterfaceName *interfaceName `json:",omitempty"`
 	Gateway       string
 	GatewayIPv6   string
 	StaticRoutes  []staticRoute
@@ -4,8 +4,11 @@
 package network
 
 import (
+	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cnm"
 	"github.com/Azure/azure-container-networking/common"
@@ -25,13 +28,17 @@ const (
 	containerInterfacePrefix = "eth"
 	returnCode               = 0
 	returnStr                = "Success"
+
+	// How often routes and ARP entries of endpoints are verified and restored.
+	routeReconcileInterval = time.Minute
 )
 
 // NetPlugin represents a CNM (libnetwork) network plugin.
 type netPlugin struct {
 	*cnm.Plugin
-	scope string
-	nm    network.NetworkManager
+	scope          string
+	nm             network.NetworkManager
+	reconcilerStop chan struct{}
 }
 
 type NetPlugin interface {
@@ -96,6 +103,10 @@ func (plugin *netPlugin) Start(config *common.PluginConfig) error {
 		return err
 	}
 
+	// Restore endpoint routes flushed by other agents.
+	plugin.reconcilerStop = make(chan struct{})
+	go plugin.nm.RunRouteReconciler(routeReconcileInterval, plugin.reconcilerStop, nil)
+
 	log.Printf("[net] Plugin started.")
 
 	return nil
@@ -103,6 +114,11 @@ func (plugin *netPlugin) Start(config *common.PluginConfig) error {
 
 // Stop stops the plugin.
 func (plugin *netPlugin) Stop() {
+	if plugin.reconcilerStop != nil {
+		close(plugin.reconcilerStop)
+		plugin.reconcilerStop = nil
+	}
+
 	plugin.DisableDiscovery()
 	plugin.nm.Uninitialize()
 	plugin.Uninitialize()
@@ -235,6 +251,18 @@ func (plugin *netPlugin) createEndpoint(w http.ResponseWriter, r *http.Request)
 
 	epInfo.Data = make(map[string]interface{})
 
+	// Parse endpoint options.
+	options := plugin.ParseOptions(req.Options)
+	if options != nil {
+		epInfo.NetNsPath, _ = options[netNsPathOption].(string)
+		epInfo.IfName, _ = options[ifNameOption].(string)
+
+		if epInfo.NetNsPid, err = getPidOption(options[netNsPidOption]); err != nil {
+			plugin.SendErrorResponse(w, err)
+			return
+		}
+	}
+
 	err = plugin.nm.CreateEndpoint(req.NetworkID, &epInfo)
 	if err != nil {
 		plugin.SendErrorResponse(w, err)
@@ -249,6 +277,20 @@ func (plugin *netPlugin) createEndpoint(w http.ResponseWriter, r *http.Request)
 	log.Response(plugin.Name, &resp, returnCode, returnStr, err)
 }
 
+// Returns the PID given by an endpoint option, a JSON number or a string, or 0 if the option is not set.
+func getPidOption(value interface{}) (int, error) {
+	switch pid := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return int(pid), nil
+	case string:
+		return strconv.Atoi(pid)
+	default:
+		return 0, fmt.Errorf("Invalid %v option %v", netNsPidOption, value)
+	}
+}
+
 // Handles DeleteEndpoint requests.
 func (plugin *netPlugin) deleteEndpoint(w http.ResponseWriter, r *http.Request) {
 	var req deleteEndpointRequest
@@ -293,13 +335,8 @@ func (plugin *netPlugin) join(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Encode response.
-	ifname := interfaceName{
-		SrcName:   ep.IfName,
-		DstPrefix: containerInterfacePrefix,
-	}
-
 	resp := joinResponse{
-		InterfaceName: ifname,
+		InterfaceName: getJoinInterfaceName(ep.IfName, ep.NetworkNameSpace),
 		Gateway:       ep.Gateways[0].String(),
 	}
 
@@ -308,6 +345,20 @@ func (plugin *netPlugin) join(w http.ResponseWriter, r *http.Request) {
 	log.Response(plugin.Name, &resp, returnCode, returnStr, err)
 }
 
+// Returns the interface libnetwork moves into the sandbox of a joined endpoint, or nil if the interface was
+// moved into the network namespace given when the endpoint was created, and isn't in the host namespace anymore.
+func getJoinInterfaceName(ifName string, netNsPath string) *interfaceName {
+	if netNsPath != "" {
+		log.Printf("[net] Interface %v is already in netns %v.", ifName, netNsPath)
+		return nil
+	}
+
+	return &interfaceName{
+		SrcName:   ifName,
+		DstPrefix: containerInterfacePrefix,
+	}
+}
+
 // Handles Leave requests.
 func (plugin *netPlugin) leave(w http.ResponseWriter, r *http.Request) {
 	var req leaveRequest
@@ -259,3 +259,38 @@ func TestDeleteNetwork(t *testing.T) {
 		t.Errorf("DeleteNetwork response is invalid %+v", resp)
 	}
 }
+
+// Tests parsing the PID of the network namespace endpoint option.
+func TestGetPidOption(t *testing.T) {
+	for value, expected := range map[interface{}]int{nil: 0, float64(1234): 1234, "5678": 5678} {
+		pid, err := getPidOption(value)
+		if err != nil || pid != expected {
+			t.Errorf("getPidOption(%v) returned %v, %v, expected %v", value, pid, err, expected)
+		}
+	}
+
+	for _, value := range []interface{}{"pid", true} {
+		if _, err := getPidOption(value); err == nil {
+			t.Errorf("getPidOption(%v) accepted an invalid PID", value)
+		}
+	}
+}
+
+// Tests that endpoints already moved into a network namespace are joined without an interface to move.
+func TestGetJoinInterfaceName(t *testing.T) {
+	ifName := getJoinInterfaceName("azvethE1xxxx", "")
+	if ifName == nil || ifName.SrcName != "azvethE1xxxx" || ifName.DstPrefix != containerInterfacePrefix {
+		t.Errorf("getJoinInterfaceName returned %+v for an endpoint in the host namespace", ifName)
+	}
+
+	if ifName = getJoinInterfaceName("eth0", "/var/run/netns/vm1"); ifName != nil {
+		t.Errorf("getJoinInterfaceName returned %+v for an endpoint in a namespace", ifName)
+	}
+
+	// libnetwork moves no interface when the response has no interface name.
+	b, _ := json.Marshal(&joinResponse{Gateway: "192.168.1.1"})
+	var resp remoteApi.JoinResponse
+	if err := json.Unmarshal(b, &resp); err != nil || resp.InterfaceName != nil {
+		t.Errorf("Join response %s has an interface name, err:%v", b, err)
+	}
+}
@@ -89,6 +89,13 @@ var args = common.ArgumentList{
 		Type:         "int",
 		DefaultValue: "",
 	},
+	{
+		Name:         common.OptIpamLeaseTTL,
+		Shorthand:    common.OptIpamLeaseTTLAlias,
+		Description:  "Set the TTL in seconds of the leases of addresses allocated to containers, 0 to disable leases",
+		Type:         "int",
+		DefaultValue: "0",
+	},
 	{
 		Name:         common.OptVersion,
 		Shorthand:    common.OptVersionAlias,
@@ -115,6 +122,7 @@ func main() {
 	logTarget := common.GetArg(common.OptLogTarget).(int)
 	ipamQueryUrl, _ := common.GetArg(common.OptIpamQueryUrl).(string)
 	ipamQueryInterval, _ := common.GetArg(common.OptIpamQueryInterval).(int)
+	ipamLeaseTTL, _ := common.GetArg(common.OptIpamLeaseTTL).(int)
 	vers := common.GetArg(common.OptVersion).(bool)
 
 	if vers {
@@ -165,6 +173,10 @@ func main() {
 		return
 	}
 
+	if err = log.ConfigureFromEnv(); err != nil {
+		log.Printf("Failed to apply log configuration from environment: %v", err)
+	}
+
 	// Log platform information.
 	log.Printf("Running on %v", platform.GetOSInfo())
 	common.LogNetworkInterfaces()
@@ -176,6 +188,7 @@ func main() {
 	ipamPlugin.SetOption(common.OptAPIServerURL, url)
 	ipamPlugin.SetOption(common.OptIpamQueryUrl, ipamQueryUrl)
 	ipamPlugin.SetOption(common.OptIpamQueryInterval, ipamQueryInterval)
+	ipamPlugin.SetOption(common.OptIpamLeaseTTL, ipamLeaseTTL)
 
 	// Start plugins.
 	if netPlugin != nil {
@@ -201,7 +214,7 @@ func main() {
 	// Wait until receiving a signal.
 	select {
 	case sig := <-osSignalChannel:
-		log.Printf("Received OS signal <" + sig.String() + ">, shutting down.")
+		log.Printf("Received OS signal <%v>, shutting down.", sig)
 	case err := <-config.ErrChan:
 		log.Printf("Received unhandled plugin error %v, shutting down.", err)
 	}
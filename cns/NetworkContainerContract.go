@@ -10,6 +10,8 @@ const (
 	GetNetworkContainerStatus                = "/network/getnetworkcontainerstatus"
 	GetInterfaceForContainer                 = "/network/getinterfaceforcontainer"
 	GetNetworkContainerByOrchestratorContext = "/network/getnetworkcontainerbyorchestratorcontext"
+	GetNetworkContainerVersion               = "/network/getnetworkcontainerversion"
+	UpdateNetworkContainer                   = "/network/updatenetworkcontainer"
 )
 
 // NetworkContainer Types
@@ -17,6 +19,7 @@ const (
 	AzureContainerInstance = "AzureContainerInstance"
 	WebApps                = "WebApps"
 	ClearContainer         = "ClearContainer"
+	Docker                 = "Docker" // Provisioned through a NodeNetworkConfig.
 )
 
 // Orchestrator Types
@@ -44,6 +47,7 @@ type CreateNetworkContainerRequest struct {
 	MultiTenancyInfo           MultiTenancyInfo
 	CnetAddressSpace           []IPSubnet // To setup SNAT (should include service endpoint vips).
 	Routes                     []Route
+	SecondaryIPAddresses       []string // Addresses of the network container available to pods.
 }
 
 // KubernetesPodInfo is an OrchestratorContext that holds PodName and PodNamespace.
@@ -87,6 +91,16 @@ type SetOrchestratorTypeRequest struct {
 // CreateNetworkContainerResponse specifies response of creating a network container.
 type CreateNetworkContainerResponse struct {
 	Response Response
+	// Set by dry runs: the problems found in the goal state, and the operations creating
+	// the network container would perform on the node, in order.
+	ValidationErrors []string             `json:",omitempty"`
+	Operations       []DataplaneOperation `json:",omitempty"`
+}
+
+// DataplaneOperation describes a change to the node, with the command performing it if any.
+type DataplaneOperation struct {
+	Description string
+	Command     []string `json:",omitempty"`
 }
 
 // GetNetworkContainerStatusRequest specifies the details about the request to retrieve status of a specifc network container.
@@ -102,6 +116,32 @@ type GetNetworkContainerStatusResponse struct {
 	Response           Response
 }
 
+// GetNetworkContainerVersionRequest specifies the network container whose programmed version is compared with the
+// version of its goal state in DNC.
+type GetNetworkContainerVersionRequest struct {
+	NetworkContainerid string
+	GoalVersion        string
+}
+
+// GetNetworkContainerVersionResponse describes the version of a network container programmed by CNS, and whether
+// it must be updated to reach the goal version.
+type GetNetworkContainerVersionResponse struct {
+	NetworkContainerid string
+	Version            string
+	GoalVersion        string
+	UpdateRequired     bool
+	Response           Response
+}
+
+// UpdateNetworkContainerResponse describes the response to update a network container in place.
+// Updated is false if the network container already had the requested version.
+type UpdateNetworkContainerResponse struct {
+	PreviousVersion string
+	Version         string
+	Updated         bool
+	Response        Response
+}
+
 // GetNetworkContainerRequest specifies the details about the request to retrieve a specifc network container.
 type GetNetworkContainerRequest struct {
 	NetworkContainerid  string
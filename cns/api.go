@@ -3,6 +3,11 @@
 
 package cns
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Container Network Service remote API Contract
 const (
 	SetEnvironmentPath          = "/network/environment"
@@ -10,14 +15,81 @@ const (
 	DeleteNetworkPath           = "/network/delete"
 	ReserveIPAddressPath        = "/network/ip/reserve"
 	ReleaseIPAddressPath        = "/network/ip/release"
+	BatchReserveIPAddressPath   = "/network/ip/batchreserve"
+	BatchReleaseIPAddressPath   = "/network/ip/batchrelease"
 	GetHostLocalIPPath          = "/network/ip/hostlocal"
 	GetIPAddressUtilizationPath = "/network/ip/utilization"
 	GetUnhealthyIPAddressesPath = "/network/ipaddresses/unhealthy"
 	GetHealthReportPath         = "/network/health"
+	ReportPodNetworkFailurePath = "/network/pod/failure"
+	GetPodAnnotationsPath       = "/network/pod/annotations"
+	IssuePodTokenPath           = "/network/pod/token"
+	RevokePodTokensPath         = "/network/pod/token/revoke"
+	GetOverlayRoutesPath        = "/network/overlay/routes"
+	GetInterfacesPath           = "/network/interfaces"
+	ExecuteCNIPath              = "/network/cni/execute"
+	GetOperationPath            = "/operations/"
+	GetIPPoolStatePath          = "/debug/ippool"
+	GetHeartbeatStatePath       = "/debug/heartbeat"
+	GetDebugStatePath           = "/debug/state"
+	GetDebugIPAMPath            = "/debug/ipam"
+	GetDebugNCPath              = "/debug/networkcontainers"
+	ExportStatePath             = "/network/state/export"
+	ImportStatePath             = "/network/state/import"
+	DrainModePath               = "/network/drain"
+	AdminLogLevelPath           = "/admin/loglevel"
+	AdminTracePath              = "/admin/trace"
+	AdminGoroutinesPath         = "/admin/goroutines"
+	GetAPIMetricsPath           = "/debug/apimetrics"
 	V1Prefix                    = "/v0.1"
 	V2Prefix                    = "/v0.2"
+
+	// Prefix of the paths of version 2 of the API. The paths without prefix are version 1.
+	V2APIPrefix = "/v2"
+)
+
+// HTTP API versioning.
+const (
+	// APIVersion1 is served on the paths without prefix, and APIVersion2 on the paths with V2APIPrefix.
+	APIVersion1 = "v1"
+	APIVersion2 = "v2"
+
+	// Header carrying the ID of a request. CNS generates one if the client doesn't set it, and returns it
+	// with the response.
+	RequestIDHeader = "X-Request-ID"
+
+	// Media type of version 2 of the API. Clients get version 2 responses on the paths without prefix too
+	// by accepting it.
+	V2MediaType = "application/vnd.azure.cns.v2+json"
 )
 
+// APIEnvelope describes the body of requests and responses of version 2 of the API. Data holds the request or
+// response of version 1 of the same path, and the return code of the response is lifted into the envelope.
+type APIEnvelope struct {
+	APIVersion string          `json:",omitempty"`
+	RequestID  string          `json:",omitempty"`
+	ReturnCode int             `json:",omitempty"`
+	Message    string          `json:",omitempty"`
+	Data       json.RawMessage `json:",omitempty"`
+}
+
+// APIRouteMetrics describes the requests served on a path of a version of the API.
+// Errors counts the requests failed with an HTTP error or a non-zero return code.
+type APIRouteMetrics struct {
+	Path           string
+	APIVersion     string
+	Requests       int
+	Errors         int
+	TotalLatencyMs int64
+	MaxLatencyMs   int64
+}
+
+// GetAPIMetricsResponse describes the requests served by the HTTP API since CNS started.
+type GetAPIMetricsResponse struct {
+	Response Response
+	Routes   []APIRouteMetrics
+}
+
 // SetEnvironmentRequest describes the Request to set the environment in CNS.
 type SetEnvironmentRequest struct {
 	Location    string
@@ -60,6 +132,62 @@ type ReleaseIPAddressRequest struct {
 	ReservationID string
 }
 
+// BatchReserveIPAddressRequest describes request to reserve a batch of IP addresses.
+// One IP address is reserved for each reservation ID.
+type BatchReserveIPAddressRequest struct {
+	ReservationIDs []string
+}
+
+// BatchReserveIPAddressResponse describes response to reserve a batch of IP addresses.
+// Either all or none of the requested IP addresses are reserved.
+type BatchReserveIPAddressResponse struct {
+	Response    Response
+	IPAddresses map[string]string // ReservationID is key.
+}
+
+// BatchReleaseIPAddressRequest describes request to release a batch of IP addresses.
+type BatchReleaseIPAddressRequest struct {
+	ReservationIDs []string
+}
+
+// Asynchronous operation states.
+const (
+	OperationPending   = "Pending"
+	OperationRunning   = "Running"
+	OperationSucceeded = "Succeeded"
+	OperationFailed    = "Failed"
+)
+
+// AsyncQueryParameter is the query parameter requesting a handler to run asynchronously, e.g. "?async=true".
+const AsyncQueryParameter = "async"
+
+// DryRunQueryParameter is the query parameter requesting a handler to validate a request and return
+// the operations it would perform without performing them, e.g. "?dryRun=true".
+const DryRunQueryParameter = "dryRun"
+
+// AsyncOperationResponse describes the response to a request accepted for asynchronous processing.
+// The status of the operation is available at GetOperationPath followed by the operation ID.
+type AsyncOperationResponse struct {
+	Response    Response
+	OperationID string
+}
+
+// OperationStatus describes the progress of an asynchronous operation.
+type OperationStatus struct {
+	OperationID string
+	Type        string
+	State       string
+	Result      Response // Response of the operation once it completed.
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetOperationResponse describes response to a request for the status of an asynchronous operation.
+type GetOperationResponse struct {
+	Response  Response
+	Operation OperationStatus
+}
+
 // IPAddressesUtilizationResponse describes response for ip address utilization.
 type IPAddressesUtilizationResponse struct {
 	Response  Response
@@ -106,3 +234,260 @@ type OptionMap map[string]interface{}
 type errorResponse struct {
 	Err string
 }
+
+// IP pool scaling actions.
+const (
+	IPPoolActionNone    = "None"
+	IPPoolActionRequest = "Request"
+	IPPoolActionRelease = "Release"
+)
+
+// RequestIPBatchRequest asks DNC to allocate additional secondary IP addresses to the node.
+type RequestIPBatchRequest struct {
+	DncPartitionKey            string
+	PrimaryInterfaceIdentifier string
+	Count                      int
+}
+
+// ReleaseIPBatchRequest asks DNC to remove unused secondary IP addresses from the node.
+type ReleaseIPBatchRequest struct {
+	DncPartitionKey            string
+	PrimaryInterfaceIdentifier string
+	IPAddresses                []string
+}
+
+// NodeHeartbeatRequest reports the health of the node, its network containers and its IP pool to DNC.
+type NodeHeartbeatRequest struct {
+	DncPartitionKey            string
+	NodeName                   string
+	PrimaryInterfaceIdentifier string
+	OrchestratorType           string
+	Healthy                    bool
+	NetworkContainers          []NetworkContainerHeartbeat
+	IPPoolCapacity             int
+	IPPoolAvailable            int
+	UnhealthyIPCount           int
+	Timestamp                  time.Time
+}
+
+// NetworkContainerHeartbeat describes the state of a network container on the node.
+// A network container is programmed once its host version caught up with its VM version.
+type NetworkContainerHeartbeat struct {
+	NetworkContainerID string
+	VMVersion          string
+	HostVersion        string
+}
+
+// HeartbeatState describes the recent heartbeats sent to DNC.
+type HeartbeatState struct {
+	Enabled             bool
+	Interval            time.Duration
+	LastAttempt         time.Time
+	LastSuccess         time.Time
+	ConsecutiveFailures int
+	LastError           string
+	NextHeartbeat       time.Time
+}
+
+// GetHeartbeatStateResponse describes response to get the state of the node heartbeat.
+type GetHeartbeatStateResponse struct {
+	Response Response
+	State    HeartbeatState
+}
+
+// IPPoolDecision describes a scaling decision taken by the IP pool manager.
+type IPPoolDecision struct {
+	Time      time.Time
+	Capacity  int
+	Available int
+	Action    string
+	Count     int
+	Error     string `json:",omitempty"`
+}
+
+// IPPoolState describes the configuration and recent decisions of the IP pool manager.
+type IPPoolState struct {
+	Enabled   bool
+	BatchSize int
+	MinFree   int
+	MaxFree   int
+	Decisions []IPPoolDecision // Most recent last.
+}
+
+// GetIPPoolStateResponse describes response to get the IP pool manager state.
+type GetIPPoolStateResponse struct {
+	Response Response
+	State    IPPoolState
+}
+
+// GetDebugStateResponse describes the in-memory and persisted CNS state, with secrets redacted.
+type GetDebugStateResponse struct {
+	Response  Response
+	InMemory  interface{}
+	Persisted interface{}
+}
+
+// GetDebugIPAMResponse describes the address pool of the primary interface.
+type GetDebugIPAMResponse struct {
+	Response           Response
+	AddressSpace       string
+	PoolID             string
+	Capacity           int
+	Available          int
+	UnhealthyAddresses []string
+	SnapshotTime       time.Time // Time the utilization served to read-only queries was last refreshed.
+}
+
+// GetDebugNCResponse describes the network containers known to CNS, with secrets redacted.
+type GetDebugNCResponse struct {
+	Response                         Response
+	NetworkContainers                interface{}
+	ContainerIDByOrchestratorContext map[string]string
+}
+
+// ReportPodNetworkFailureRequest describes a terminal failure to set up the network of a pod,
+// recorded by CNS as a Kubernetes event on the pod.
+type ReportPodNetworkFailureRequest struct {
+	PodName      string
+	PodNamespace string
+	ContainerID  string
+	ErrorCode    uint
+	Message      string
+}
+
+// GetPodAnnotationsRequest identifies the pod whose annotations the CNI plugin reads, e.g. its egress IP.
+type GetPodAnnotationsRequest struct {
+	PodName      string
+	PodNamespace string
+}
+
+// GetPodAnnotationsResponse describes the annotations of a pod.
+type GetPodAnnotationsResponse struct {
+	Response    Response
+	Annotations map[string]string
+}
+
+// IssuePodTokenRequest identifies the pod sandbox and the scope of a token the pod presents to reach restricted
+// endpoints, e.g. wireserver APIs through the HTTP proxy of CNS. The token is only accepted from the addresses
+// of the sandbox.
+type IssuePodTokenRequest struct {
+	ContainerID  string
+	PodName      string
+	PodNamespace string
+	IPAddresses  []string
+	Scope        string
+}
+
+// IssuePodTokenResponse carries the token issued to a pod sandbox. The same token is returned until it is revoked.
+type IssuePodTokenResponse struct {
+	Response Response
+	Token    string
+}
+
+// RevokePodTokensRequest identifies the deleted pod sandbox whose tokens are revoked.
+type RevokePodTokensRequest struct {
+	ContainerID  string
+	PodName      string
+	PodNamespace string
+}
+
+// RevokePodTokensResponse describes response to revoke the tokens of a pod sandbox.
+type RevokePodTokensResponse struct {
+	Response  Response
+	Remaining int // Tokens the pod holds for other sandboxes, e.g. the one replacing the deleted sandbox.
+}
+
+// OverlayRoute is the pod CIDR of a node of the cluster, reached through the overlay tunnel to the node address.
+type OverlayRoute struct {
+	NodeName string
+	NodeIP   string
+	PodCIDR  string
+}
+
+// GetOverlayRoutesResponse describes the pod CIDRs of the nodes of the cluster.
+type GetOverlayRoutesResponse struct {
+	Response Response
+	Routes   []OverlayRoute
+}
+
+// CNIRequest describes a CNI command forwarded by the CNI plugin in thin client mode, with the arguments the
+// runtime passed to the plugin.
+type CNIRequest struct {
+	Command     string
+	ContainerID string
+	Netns       string
+	IfName      string
+	Args        string
+	Path        string
+	StdinData   []byte
+}
+
+// CNIResponse describes the outcome of a CNI command executed by CNS. Result holds the output of the plugin,
+// the CNI result of a successful ADD, or the CNI error of a failed command.
+type CNIResponse struct {
+	Response  Response
+	Result    json.RawMessage `json:",omitempty"`
+	ErrorCode uint            `json:",omitempty"`
+}
+
+// CNIExecutor executes the CNI commands forwarded to CNS.
+type CNIExecutor interface {
+	ExecuteCNI(req *CNIRequest) *CNIResponse
+}
+
+// NodeStateSnapshot describes the IPAM and network container state of a node, portable to a replacement node.
+type NodeStateSnapshot struct {
+	Location          string
+	NetworkType       string
+	OrchestratorType  string
+	NetworkContainers []CreateNetworkContainerRequest
+	IPReservations    map[string]string // ReservationID is key, value is the IP address on the exporting node.
+	Draining          bool
+	TimeStamp         time.Time
+}
+
+// ExportStateResponse describes response to export the state of the node.
+type ExportStateResponse struct {
+	Response Response
+	Snapshot NodeStateSnapshot
+}
+
+// ImportStateRequest describes request to import the state exported by another node.
+type ImportStateRequest struct {
+	Snapshot NodeStateSnapshot
+}
+
+// ImportStateResponse describes response to import the state exported by another node.
+// The reservations are reserved again on this node, and may be served different IP addresses.
+type ImportStateResponse struct {
+	Response    Response
+	IPAddresses map[string]string // ReservationID is key.
+}
+
+// SetDrainModeRequest describes request to start or stop draining the node.
+// A draining node rejects new IP reservations and network containers, and keeps serving releases.
+type SetDrainModeRequest struct {
+	Drain bool
+}
+
+// DrainModeResponse describes the drain mode of the node and the allocations it still holds.
+type DrainModeResponse struct {
+	Response          Response
+	Draining          bool
+	IPReservations    int
+	NetworkContainers int
+}
+
+// SetLogLevelRequest describes request to change the log levels of CNS at runtime, e.g. "debug" or "info,net=debug".
+// The previous levels are restored after DurationSeconds, unless it is 0.
+type SetLogLevelRequest struct {
+	Level           string
+	DurationSeconds int
+}
+
+// LogLevelResponse describes the log levels of CNS, and when they are restored if they were changed for a while.
+type LogLevelResponse struct {
+	Response   Response
+	Level      string
+	RestoredAt *time.Time `json:",omitempty"`
+}
@@ -0,0 +1,119 @@
+package cnsclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// breakerState is the state of a circuit breaker, persisted to share it between processes.
+type breakerState struct {
+	Failures  int       `json:"failures"`
+	OpenUntil time.Time `json:"openUntil"`
+}
+
+// circuitBreaker fails requests fast after consecutive requests found CNS unavailable.
+// Once the cool down expired, a single failed request opens the circuit again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	stateFile string
+	state     breakerState
+	sync.Mutex
+}
+
+// Creates a circuit breaker sharing its state through the given file, unless it is empty.
+func newCircuitBreaker(threshold int, cooldown time.Duration, stateFile string) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		stateFile: stateFile,
+	}
+}
+
+// Returns whether a request may be sent, and otherwise until when the circuit is open.
+func (b *circuitBreaker) allow() (bool, time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.load()
+
+	if clock.Now().Before(b.state.OpenUntil) {
+		return false, b.state.OpenUntil
+	}
+
+	return true, time.Time{}
+}
+
+// Records a request that reached CNS.
+func (b *circuitBreaker) recordSuccess() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state.Failures == 0 && b.state.OpenUntil.IsZero() {
+		return
+	}
+
+	b.state = breakerState{}
+	b.save()
+}
+
+// Records a request that found CNS unavailable.
+func (b *circuitBreaker) recordFailure() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.state.Failures++
+	if b.state.Failures >= b.threshold {
+		b.state.OpenUntil = clock.Now().Add(b.cooldown)
+		log.Printf("[Azure CNSClient] CNS is unavailable after %d failed requests, failing requests until %v.",
+			b.state.Failures, b.state.OpenUntil)
+	}
+
+	b.save()
+}
+
+// Reads the state written by other processes.
+func (b *circuitBreaker) load() {
+	if b.stateFile == "" {
+		return
+	}
+
+	buf, err := ioutil.ReadFile(b.stateFile)
+	if err != nil {
+		return
+	}
+
+	var state breakerState
+	if err := json.Unmarshal(buf, &state); err == nil {
+		b.state = state
+	}
+}
+
+// Writes the state for other processes.
+func (b *circuitBreaker) save() {
+	if b.stateFile == "" {
+		return
+	}
+
+	buf, err := json.Marshal(&b.state)
+	if err != nil {
+		return
+	}
+
+	// Replace the file at once, so that other processes never read a partial state.
+	tmpFile := fmt.Sprintf("%s.%d", b.stateFile, os.Getpid())
+	if err := ioutil.WriteFile(tmpFile, buf, 0644); err != nil {
+		log.Printf("[Azure CNSClient] Failed to save circuit breaker state: %v", err)
+		return
+	}
+
+	if err := os.Rename(tmpFile, b.stateFile); err != nil {
+		log.Printf("[Azure CNSClient] Failed to save circuit breaker state: %v", err)
+	}
+}
@@ -2,77 +2,409 @@ package cnsclient
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/google/uuid"
 )
 
 // CNSClient specifies a client to connect to Ipam Plugin.
 type CNSClient struct {
 	connectionURL string
+	httpc         *http.Client
+	token         string
+	maxRetries    int
+	retryDelay    time.Duration
+	breaker       *circuitBreaker
+}
+
+// ClientConfig specifies how the client authenticates to CNS, and how it handles failed requests.
+// Zero values select the defaults.
+type ClientConfig struct {
+	// PEM file of the CA that issued the CNS certificate, used to verify CNS over https.
+	CAFile string
+	// Client certificate and key files presented to CNS requiring mTLS.
+	CertFile string
+	KeyFile  string
+	// File containing the bearer token presented to CNS.
+	TokenFile string
+
+	// Time after which a request is abandoned.
+	Timeout time.Duration
+	// Number of times a request is retried while CNS is unavailable, and the delay before the first retry.
+	// The delay doubles with each retry.
+	MaxRetries int
+	RetryDelay time.Duration
+	// Number of consecutive failed requests after which requests fail fast for the cool down.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// File sharing the circuit breaker state between processes, e.g. CNI plugin invocations.
+	BreakerStateFile string
 }
 
 const (
 	defaultCnsURL = "http://localhost:10090"
+
+	// Defaults of ClientConfig.
+	defaultTimeout          = 10 * time.Second
+	defaultMaxRetries       = 3
+	defaultRetryDelay       = 200 * time.Millisecond
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
 )
 
+// Clock used to wait between retries and to time the circuit breaker.
+var clock = platform.NewClock()
+
 // NewCnsClient create a new cns client.
 func NewCnsClient(url string) (*CNSClient, error) {
+	return NewCnsClientWithConfig(url, ClientConfig{})
+}
+
+// NewCnsClientWithConfig creates a new cns client authenticating with the given configuration.
+func NewCnsClientWithConfig(url string, config ClientConfig) (*CNSClient, error) {
 	if url == "" {
 		url = defaultCnsURL
 	}
 
-	return &CNSClient{
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+
+	if config.BreakerThreshold <= 0 {
+		config.BreakerThreshold = defaultBreakerThreshold
+	}
+
+	if config.BreakerCooldown <= 0 {
+		config.BreakerCooldown = defaultBreakerCooldown
+	}
+
+	cnsClient := &CNSClient{
 		connectionURL: url,
-	}, nil
+		httpc:         &http.Client{Timeout: config.Timeout},
+		maxRetries:    config.MaxRetries,
+		retryDelay:    config.RetryDelay,
+		breaker:       newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown, config.BreakerStateFile),
+	}
+
+	if config.CAFile != "" || config.CertFile != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if config.CAFile != "" {
+			pool, err := cns.LoadCertPool(config.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		if config.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		cnsClient.httpc.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	if config.TokenFile != "" {
+		token, err := cns.ReadAuthToken(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		cnsClient.token = token
+	}
+
+	return cnsClient, nil
 }
 
-// GetNetworkConfiguration Request to get network config.
-func (cnsClient *CNSClient) GetNetworkConfiguration(orchestratorContext []byte) (*cns.GetNetworkContainerResponse, error) {
-	var body bytes.Buffer
+// Posts a request to CNS once, presenting the token if configured.
+func (cnsClient *CNSClient) post(url string, body []byte, requestID string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
 
-	httpc := &http.Client{}
-	url := cnsClient.connectionURL + cns.GetNetworkContainerByOrchestratorContext
-	log.Printf("GetNetworkConfiguration url %v", url)
+	req.Header.Set("Content-Type", "application/json")
+	if requestID != "" {
+		req.Header.Set(cns.RequestIDHeader, requestID)
+	}
+	if cnsClient.token != "" {
+		req.Header.Set("Authorization", cns.BearerPrefix+cnsClient.token)
+	}
 
-	payload := &cns.GetNetworkContainerRequest{
-		OrchestratorContext: orchestratorContext,
+	return cnsClient.httpc.Do(req)
+}
+
+// Returns whether a request answered with the given HTTP status may succeed when retried.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// Sends a request to CNS and decodes its response, retrying with backoff while CNS is unavailable.
+// Requests fail fast while the circuit breaker is open. Retries keep the request ID, so that CNS logs can be
+// correlated with the operation of the client.
+func (cnsClient *CNSClient) request(op string, path string, payload interface{}, response interface{}) error {
+	url := cnsClient.connectionURL + path
+
+	var requestID string
+	if id, err := uuid.NewUUID(); err == nil {
+		requestID = id.String()
 	}
 
-	err := json.NewEncoder(&body).Encode(payload)
+	log.Printf("%s url %v requestId %v", op, url, requestID)
+
+	body, err := json.Marshal(payload)
 	if err != nil {
 		log.Errorf("encoding json failed with %v", err)
-		return nil, err
+		return err
+	}
+
+	if ok, openUntil := cnsClient.breaker.allow(); !ok {
+		cnsErr := &Error{Op: op, Kind: KindCircuitOpen, OpenUntil: openUntil}
+		log.Printf("%v", cnsErr)
+		return cnsErr
 	}
 
-	res, err := httpc.Post(url, "application/json", &body)
+	delay := cnsClient.retryDelay
+	var cnsErr *Error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			log.Printf("[Azure CNSClient] Retrying %s in %v after: %v", op, delay, cnsErr)
+			clock.Sleep(delay)
+			delay *= 2
+		}
+
+		cnsErr = cnsClient.attempt(op, url, body, requestID, response)
+		if cnsErr == nil || cnsErr.Kind != KindUnavailable || attempt >= cnsClient.maxRetries {
+			break
+		}
+	}
+
+	if cnsErr == nil {
+		cnsClient.breaker.recordSuccess()
+		return nil
+	}
+
+	if cnsErr.Kind == KindUnavailable {
+		cnsClient.breaker.recordFailure()
+	} else {
+		cnsClient.breaker.recordSuccess()
+	}
+
+	log.Errorf("%v", cnsErr)
+
+	return cnsErr
+}
+
+// Sends a request to CNS once and decodes its response.
+func (cnsClient *CNSClient) attempt(op string, url string, body []byte, requestID string, response interface{}) *Error {
+	res, err := cnsClient.post(url, body, requestID)
 	if err != nil {
-		log.Errorf("[Azure CNSClient] HTTP Post returned error %v", err.Error())
-		return nil, err
+		return &Error{Op: op, Kind: KindUnavailable, Err: err}
 	}
 
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		errMsg := fmt.Sprintf("[Azure CNSClient] GetNetworkConfiguration invalid http status code: %v", res.StatusCode)
-		log.Errorf(errMsg)
-		return nil, fmt.Errorf(errMsg)
+		kind := KindRejected
+		if isRetryableStatus(res.StatusCode) {
+			kind = KindUnavailable
+		}
+		return &Error{Op: op, Kind: kind, StatusCode: res.StatusCode}
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+		return &Error{Op: op, Kind: KindInvalidResponse, StatusCode: res.StatusCode, Err: err}
+	}
+
+	return nil
+}
+
+// Returns the error for a response of CNS rejecting a request.
+func newResponseError(op string, response *cns.Response) error {
+	cnsErr := &Error{
+		Op:         op,
+		Kind:       KindRejected,
+		ReturnCode: response.ReturnCode,
+		Message:    response.Message,
+	}
+
+	log.Errorf("[Azure CNSClient] %s received error response :%v", op, response.Message)
+
+	return cnsErr
+}
+
+// GetNetworkConfiguration Request to get network config.
+func (cnsClient *CNSClient) GetNetworkConfiguration(orchestratorContext []byte) (*cns.GetNetworkContainerResponse, error) {
+	payload := &cns.GetNetworkContainerRequest{
+		OrchestratorContext: orchestratorContext,
 	}
 
 	var resp cns.GetNetworkContainerResponse
+	if err := cnsClient.request("GetNetworkConfiguration", cns.GetNetworkContainerByOrchestratorContext, payload, &resp); err != nil {
+		return nil, err
+	}
 
-	err = json.NewDecoder(res.Body).Decode(&resp)
-	if err != nil {
-		log.Errorf("[Azure CNSClient] Error received while parsing GetNetworkConfiguration response resp:%v err:%v", res.Body, err.Error())
+	if resp.Response.ReturnCode != 0 {
+		return nil, newResponseError("GetNetworkConfiguration", &resp.Response)
+	}
+
+	return &resp, nil
+}
+
+// ReserveIPAddresses Request to reserve a batch of IP addresses, one for each reservation ID.
+// Either all or none of the IP addresses are reserved.
+func (cnsClient *CNSClient) ReserveIPAddresses(reservationIDs []string) (map[string]string, error) {
+	payload := &cns.BatchReserveIPAddressRequest{
+		ReservationIDs: reservationIDs,
+	}
+
+	var resp cns.BatchReserveIPAddressResponse
+	if err := cnsClient.request("ReserveIPAddresses", cns.BatchReserveIPAddressPath, payload, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, newResponseError("ReserveIPAddresses", &resp.Response)
+	}
+
+	return resp.IPAddresses, nil
+}
+
+// ReleaseIPAddresses Request to release a batch of reserved IP addresses.
+func (cnsClient *CNSClient) ReleaseIPAddresses(reservationIDs []string) error {
+	payload := &cns.BatchReleaseIPAddressRequest{
+		ReservationIDs: reservationIDs,
+	}
+
+	var resp cns.Response
+	if err := cnsClient.request("ReleaseIPAddresses", cns.BatchReleaseIPAddressPath, payload, &resp); err != nil {
+		return err
+	}
+
+	if resp.ReturnCode != 0 {
+		return newResponseError("ReleaseIPAddresses", &resp)
+	}
+
+	return nil
+}
+
+// ReportPodNetworkFailure Request to record a failure to set up the network of a pod as an event on the pod.
+func (cnsClient *CNSClient) ReportPodNetworkFailure(req *cns.ReportPodNetworkFailureRequest) error {
+	var resp cns.Response
+	if err := cnsClient.request("ReportPodNetworkFailure", cns.ReportPodNetworkFailurePath, req, &resp); err != nil {
+		return err
+	}
+
+	if resp.ReturnCode != 0 {
+		return newResponseError("ReportPodNetworkFailure", &resp)
+	}
+
+	return nil
+}
+
+// GetPodAnnotations Request to get the annotations of a pod.
+func (cnsClient *CNSClient) GetPodAnnotations(podName string, podNamespace string) (map[string]string, error) {
+	payload := &cns.GetPodAnnotationsRequest{
+		PodName:      podName,
+		PodNamespace: podNamespace,
+	}
+
+	var resp cns.GetPodAnnotationsResponse
+	if err := cnsClient.request("GetPodAnnotations", cns.GetPodAnnotationsPath, payload, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, newResponseError("GetPodAnnotations", &resp.Response)
+	}
+
+	return resp.Annotations, nil
+}
+
+// IssuePodToken Request to get the token of a pod sandbox for a scope, issued on the first request.
+func (cnsClient *CNSClient) IssuePodToken(containerID string, podName string, podNamespace string, ipAddresses []string, scope string) (string, error) {
+	payload := &cns.IssuePodTokenRequest{
+		ContainerID:  containerID,
+		PodName:      podName,
+		PodNamespace: podNamespace,
+		IPAddresses:  ipAddresses,
+		Scope:        scope,
+	}
+
+	var resp cns.IssuePodTokenResponse
+	if err := cnsClient.request("IssuePodToken", cns.IssuePodTokenPath, payload, &resp); err != nil {
+		return "", err
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return "", newResponseError("IssuePodToken", &resp.Response)
+	}
+
+	return resp.Token, nil
+}
+
+// RevokePodTokens Request to revoke the tokens of a deleted pod sandbox.
+// Returns how many tokens the pod still holds for other sandboxes.
+func (cnsClient *CNSClient) RevokePodTokens(containerID string, podName string, podNamespace string) (int, error) {
+	payload := &cns.RevokePodTokensRequest{
+		ContainerID:  containerID,
+		PodName:      podName,
+		PodNamespace: podNamespace,
+	}
+
+	var resp cns.RevokePodTokensResponse
+	if err := cnsClient.request("RevokePodTokens", cns.RevokePodTokensPath, payload, &resp); err != nil {
+		return 0, err
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return 0, newResponseError("RevokePodTokens", &resp.Response)
+	}
+
+	return resp.Remaining, nil
+}
+
+// GetOverlayRoutes Request to get the pod CIDRs of the nodes of the cluster, reached through overlay tunnels.
+func (cnsClient *CNSClient) GetOverlayRoutes() ([]cns.OverlayRoute, error) {
+	var resp cns.GetOverlayRoutesResponse
+	if err := cnsClient.request("GetOverlayRoutes", cns.GetOverlayRoutesPath, struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, newResponseError("GetOverlayRoutes", &resp.Response)
+	}
+
+	return resp.Routes, nil
+}
+
+// ExecuteCNI Request to execute a CNI command forwarded by the CNI plugin in thin client mode.
+// The CNI error of a failed command is returned in the response.
+func (cnsClient *CNSClient) ExecuteCNI(req *cns.CNIRequest) (*cns.CNIResponse, error) {
+	var resp cns.CNIResponse
+	if err := cnsClient.request("ExecuteCNI", cns.ExecuteCNIPath, req, &resp); err != nil {
 		return nil, err
 	}
 
 	if resp.Response.ReturnCode != 0 {
-		log.Errorf("[Azure CNSClient] GetNetworkConfiguration received error response :%v", resp.Response.Message)
-		return nil, fmt.Errorf(resp.Response.Message)
+		return nil, newResponseError("ExecuteCNI", &resp.Response)
 	}
 
 	return &resp, nil
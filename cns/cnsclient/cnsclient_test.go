@@ -0,0 +1,138 @@
+package cnsclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// Starts a CNS server answering ReleaseIPAddresses requests with the given status codes in turn,
+// and a successful response once they are used up.
+func newTestServer(statusCodes []int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if len(statusCodes) > 0 {
+			statusCode := statusCodes[0]
+			statusCodes = statusCodes[1:]
+			if statusCode != http.StatusOK {
+				w.WriteHeader(statusCode)
+				return
+			}
+		}
+
+		json.NewEncoder(w).Encode(&cns.Response{})
+	}))
+}
+
+// Uses a fake clock for the duration of a test.
+func useFakeClock() (*platform.FakeClock, func()) {
+	fakeClock := platform.NewFakeClock(time.Now())
+	clock = fakeClock
+	return fakeClock, func() { clock = platform.NewClock() }
+}
+
+// Tests that requests are retried with backoff while CNS is unavailable.
+func TestRequestIsRetriedWithBackoff(t *testing.T) {
+	fakeClock, restore := useFakeClock()
+	defer restore()
+
+	var requests int
+	server := newTestServer([]int{http.StatusServiceUnavailable, http.StatusInternalServerError}, &requests)
+	defer server.Close()
+
+	client, err := NewCnsClientWithConfig(server.URL, ClientConfig{RetryDelay: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	start := fakeClock.Now()
+	if err := client.ReleaseIPAddresses([]string{"id"}); err != nil {
+		t.Fatalf("Failed to release addresses: %v", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("Unexpected number of requests %d", requests)
+	}
+
+	if waited := fakeClock.Since(start); waited != 3*time.Second {
+		t.Errorf("Unexpected time waited between retries %v", waited)
+	}
+}
+
+// Tests that rejected requests are not retried and return a typed error.
+func TestRejectedRequestIsNotRetried(t *testing.T) {
+	_, restore := useFakeClock()
+	defer restore()
+
+	var requests int
+	server := newTestServer([]int{http.StatusBadRequest}, &requests)
+	defer server.Close()
+
+	client, err := NewCnsClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	err = client.ReleaseIPAddresses([]string{"id"})
+	cnsErr, ok := err.(*Error)
+	if !ok || cnsErr.Kind != KindRejected || cnsErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if IsUnavailable(err) {
+		t.Errorf("Rejected request reported as unavailable")
+	}
+
+	if requests != 1 {
+		t.Errorf("Unexpected number of requests %d", requests)
+	}
+}
+
+// Tests that requests fail fast once the circuit breaker opened, until the cool down expired.
+func TestCircuitBreakerFailsFast(t *testing.T) {
+	fakeClock, restore := useFakeClock()
+	defer restore()
+
+	var requests int
+	failures := []int{
+		http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+	}
+	server := newTestServer(failures, &requests)
+	defer server.Close()
+
+	client, err := NewCnsClientWithConfig(server.URL, ClientConfig{
+		MaxRetries:       1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := client.ReleaseIPAddresses([]string{"id"}); !IsUnavailable(err) {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+
+	err = client.ReleaseIPAddresses([]string{"id"})
+	if cnsErr, ok := err.(*Error); !ok || cnsErr.Kind != KindCircuitOpen {
+		t.Errorf("Unexpected error with open circuit %v", err)
+	}
+
+	if requests != 4 {
+		t.Errorf("Unexpected number of requests %d", requests)
+	}
+
+	fakeClock.Advance(time.Minute)
+
+	if err := client.ReleaseIPAddresses([]string{"id"}); err != nil {
+		t.Errorf("Failed to release addresses after cool down: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package cnsclient
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrorKind classifies why a request to CNS failed.
+type ErrorKind int
+
+const (
+	// KindUnavailable is a request that CNS did not answer, or answered with a server error, after all retries.
+	KindUnavailable ErrorKind = iota
+	// KindCircuitOpen is a request that was not sent because recent requests found CNS unavailable.
+	KindCircuitOpen
+	// KindRejected is a request that CNS answered with a client error or a non-zero return code.
+	KindRejected
+	// KindInvalidResponse is a request whose response could not be decoded.
+	KindInvalidResponse
+)
+
+// Error is returned by CNSClient methods when a request to CNS fails.
+type Error struct {
+	Op         string
+	Kind       ErrorKind
+	StatusCode int       // HTTP status code of the response, if any.
+	ReturnCode int       // Return code in the CNS response, if any.
+	Message    string    // Message in the CNS response, if any.
+	OpenUntil  time.Time // End of the cool down of an open circuit.
+	Err        error
+}
+
+// Error returns the description of the error.
+func (e *Error) Error() string {
+	switch {
+	case e.Kind == KindCircuitOpen:
+		return fmt.Sprintf("[Azure CNSClient] %s not sent, CNS is unavailable until %v", e.Op, e.OpenUntil.Format(time.RFC3339))
+	case e.ReturnCode != 0:
+		return e.Message
+	case e.StatusCode != 0 && e.Err == nil:
+		return fmt.Sprintf("[Azure CNSClient] %s invalid http status code: %v", e.Op, e.StatusCode)
+	}
+
+	return fmt.Sprintf("[Azure CNSClient] %s failed: %v", e.Op, e.Err)
+}
+
+// IsUnavailable checks if an error reports that CNS could not be reached.
+func IsUnavailable(err error) bool {
+	cnsErr, ok := err.(*Error)
+	return ok && (cnsErr.Kind == KindUnavailable || cnsErr.Kind == KindCircuitOpen)
+}
@@ -35,6 +35,8 @@ type ServiceConfig struct {
 	Listener *acn.Listener
 	ErrChan  chan error
 	Store    store.KeyValueStore
+	// OnPanic is called with the value of a panic of a request handler.
+	OnPanic func(interface{})
 }
 
 // NewService creates a new Service object.
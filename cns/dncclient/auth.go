@@ -0,0 +1,178 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package dncclient
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Authentication modes of the requests to DNC.
+	AuthModeNone            = "none"
+	AuthModeManagedIdentity = "msi"
+	AuthModeCertificate     = "certificate"
+
+	// IMDS endpoint issuing the tokens of the managed identities of the VM.
+	imdsTokenURL        = "http://169.254.169.254/metadata/identity/oauth2/token"
+	imdsTokenAPIVersion = "2018-02-01"
+
+	// Tokens are refreshed this long before they expire.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// AuthConfig describes how CNS authenticates to DNC.
+type AuthConfig struct {
+	// Mode is none, msi or certificate.
+	Mode string
+	// Resource the managed identity token is requested for, i.e. the application ID URI of DNC.
+	Resource string
+	// Client ID of a user-assigned managed identity, empty for the system-assigned identity.
+	ClientID string
+	// PEM file holding the client certificate and its private key.
+	CertFile string
+}
+
+// authenticator authorizes the requests to DNC.
+type authenticator interface {
+	// authorize adds the credentials to a request.
+	authorize(req *http.Request) error
+	// invalidate drops cached credentials that DNC rejected.
+	invalidate()
+}
+
+// tokenAuthenticator authorizes requests with a token of a managed identity of the VM, requested from IMDS
+// and refreshed before it expires.
+type tokenAuthenticator struct {
+	sync.Mutex
+	tokenURL string
+	resource string
+	clientID string
+	client   *http.Client
+	clock    platform.Clock
+	token    string
+	expiry   time.Time
+}
+
+// imdsToken is a token issued by IMDS.
+type imdsToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresOn   string `json:"expires_on"`
+}
+
+// Returns the token of the managed identity, requesting a new one if it expires soon.
+func (a *tokenAuthenticator) getToken() (string, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.token != "" && a.clock.Now().Add(tokenRefreshMargin).Before(a.expiry) {
+		return a.token, nil
+	}
+
+	query := url.Values{}
+	query.Set("api-version", imdsTokenAPIVersion)
+	query.Set("resource", a.resource)
+	if a.clientID != "" {
+		query.Set("client_id", a.clientID)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, a.tokenURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Failed to request managed identity token: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("IMDS returned http status code %v for the managed identity token", res.StatusCode)
+	}
+
+	var token imdsToken
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	expiresOn, err := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	if err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("IMDS returned an invalid managed identity token")
+	}
+
+	a.token = token.AccessToken
+	a.expiry = time.Unix(expiresOn, 0)
+	log.Printf("[Azure CNS] Refreshed DNC managed identity token, expires at %v.", a.expiry)
+
+	return a.token, nil
+}
+
+func (a *tokenAuthenticator) authorize(req *http.Request) error {
+	token, err := a.getToken()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *tokenAuthenticator) invalidate() {
+	a.Lock()
+	defer a.Unlock()
+
+	a.token = ""
+}
+
+// certificateLoader loads the client certificate from its file, again whenever the file changes,
+// so that renewed certificates are used without restarting CNS.
+type certificateLoader struct {
+	sync.Mutex
+	certFile string
+	modTime  time.Time
+	cert     *tls.Certificate
+}
+
+// Returns the client certificate, reloading it if its file changed.
+func (l *certificateLoader) getCertificate() (*tls.Certificate, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	info, err := os.Stat(l.certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.cert != nil && info.ModTime().Equal(l.modTime) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load DNC client certificate %v: %v", l.certFile, err)
+	}
+
+	l.cert = &cert
+	l.modTime = info.ModTime()
+	log.Printf("[Azure CNS] Loaded DNC client certificate %v.", l.certFile)
+
+	return l.cert, nil
+}
+
+// Returns the client certificate presented to DNC.
+func (l *certificateLoader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return l.getCertificate()
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package dncclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// Tests that the managed identity token is cached, refreshed before it expires,
+// and requested again when DNC rejects it.
+func TestManagedIdentityToken(t *testing.T) {
+	now := time.Now()
+	clock := platform.NewFakeClock(now)
+
+	issued := 0
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != "https://dnc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		issued++
+		json.NewEncoder(w).Encode(imdsToken{
+			AccessToken: fmt.Sprintf("token-%d", issued),
+			ExpiresOn:   fmt.Sprintf("%d", clock.Now().Add(time.Hour).Unix()),
+		})
+	}))
+	defer imds.Close()
+
+	var tokens []string
+	rejected := false
+	dnc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		if rejected {
+			rejected = false
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		json.NewEncoder(w).Encode(cns.Response{})
+	}))
+	defer dnc.Close()
+
+	dc := NewDncClient(dnc.URL)
+	dc.auth = &tokenAuthenticator{
+		tokenURL: imds.URL,
+		resource: "https://dnc",
+		client:   http.DefaultClient,
+		clock:    clock,
+	}
+
+	// The token is reused until it expires soon.
+	for i := 0; i < 2; i++ {
+		if err := dc.SendNodeHeartbeat(cns.NodeHeartbeatRequest{}); err != nil {
+			t.Fatalf("SendNodeHeartbeat failed: %v", err)
+		}
+	}
+
+	clock.Advance(56 * time.Minute)
+	if err := dc.SendNodeHeartbeat(cns.NodeHeartbeatRequest{}); err != nil {
+		t.Fatalf("SendNodeHeartbeat failed: %v", err)
+	}
+
+	// A rejected token is replaced and the request retried.
+	rejected = true
+	if err := dc.SendNodeHeartbeat(cns.NodeHeartbeatRequest{}); err != nil {
+		t.Fatalf("SendNodeHeartbeat failed after a rejected token: %v", err)
+	}
+
+	expected := []string{"Bearer token-1", "Bearer token-1", "Bearer token-2", "Bearer token-2", "Bearer token-3"}
+	if fmt.Sprint(tokens) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected tokens %v, expected %v", tokens, expected)
+	}
+}
+
+// Tests that the client certificate is reloaded when its file changes.
+func TestCertificateReload(t *testing.T) {
+	f, err := ioutil.TempFile("", "dnc-client-cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	writeTestCertificate(t, f.Name(), "cns-1")
+
+	loader := &certificateLoader{certFile: f.Name()}
+	cert, err := loader.getCertificate()
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	if again, _ := loader.getCertificate(); again != cert {
+		t.Errorf("Unchanged certificate was reloaded")
+	}
+
+	writeTestCertificate(t, f.Name(), "cns-2")
+	os.Chtimes(f.Name(), time.Now(), time.Now().Add(time.Minute))
+
+	cert, err = loader.getCertificate()
+	if err != nil {
+		t.Fatalf("Failed to reload certificate: %v", err)
+	}
+
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	if leaf.Subject.CommonName != "cns-2" {
+		t.Errorf("Renewed certificate not loaded, got %v", leaf.Subject.CommonName)
+	}
+
+	if _, err := NewDncClientWithAuth("https://dnc", AuthConfig{Mode: AuthModeCertificate, CertFile: "missing"}); err == nil {
+		t.Errorf("Expected an error for a missing certificate")
+	}
+}
+
+func TestAuthRequiresHTTPS(t *testing.T) {
+	tests := []struct {
+		url   string
+		mode  string
+		valid bool
+	}{
+		{url: "http://dnc", mode: AuthModeNone, valid: true},
+		{url: "http://dnc", mode: "", valid: true},
+		{url: "https://dnc", mode: AuthModeManagedIdentity, valid: true},
+		{url: "http://dnc", mode: AuthModeManagedIdentity, valid: false},
+		{url: "dnc:8080", mode: AuthModeManagedIdentity, valid: false},
+		{url: "http://dnc", mode: AuthModeCertificate, valid: false},
+	}
+
+	for _, test := range tests {
+		_, err := NewDncClientWithAuth(test.url, AuthConfig{Mode: test.mode, Resource: "https://dnc"})
+		if (err == nil) != test.valid {
+			t.Errorf("TestAuthRequiresHTTPS failed @ %v with mode %v: err %v, expected valid:%v", test.url, test.mode, err, test.valid)
+		}
+	}
+}
+
+// Writes a self-signed certificate and its key to the given file.
+func writeTestCertificate(t *testing.T, path string, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})...)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package dncclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// DNC IP batch API paths.
+	requestIPBatchPath = "/ipbatches/request"
+	releaseIPBatchPath = "/ipbatches/release"
+
+	// DNC node heartbeat API path.
+	nodeHeartbeatPath = "/nodes/heartbeat"
+
+	// Timeout for requests to DNC.
+	requestTimeout = 30 * time.Second
+)
+
+// DncClient specifies a client to connect to DNC.
+type DncClient struct {
+	connectionURL string
+	client        *http.Client
+	auth          authenticator
+}
+
+// NewDncClient creates a new DNC client relying on network-level trust.
+func NewDncClient(url string) *DncClient {
+	return &DncClient{
+		connectionURL: url,
+		client:        &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// NewDncClientWithAuth creates a new DNC client authenticating with the managed identity of the VM
+// or with a client certificate. Authenticated clients require an https URL, so that credentials are never sent in clear.
+func NewDncClientWithAuth(dncURL string, config AuthConfig) (*DncClient, error) {
+	if config.Mode != "" && config.Mode != AuthModeNone {
+		if u, err := url.Parse(dncURL); err != nil || u.Scheme != "https" {
+			return nil, fmt.Errorf("DNC authentication mode %v requires an https URL, got %v", config.Mode, dncURL)
+		}
+	}
+
+	dc := NewDncClient(dncURL)
+
+	switch config.Mode {
+	case "", AuthModeNone:
+	case AuthModeManagedIdentity:
+		if config.Resource == "" {
+			return nil, fmt.Errorf("DNC managed identity authentication requires a resource")
+		}
+
+		dc.auth = &tokenAuthenticator{
+			tokenURL: imdsTokenURL,
+			resource: config.Resource,
+			clientID: config.ClientID,
+			client:   &http.Client{Timeout: requestTimeout},
+			clock:    platform.NewClock(),
+		}
+	case AuthModeCertificate:
+		loader := &certificateLoader{certFile: config.CertFile}
+		if _, err := loader.getCertificate(); err != nil {
+			return nil, err
+		}
+
+		dc.client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{GetClientCertificate: loader.getClientCertificate},
+		}
+	default:
+		return nil, fmt.Errorf("Invalid DNC authentication mode %v", config.Mode)
+	}
+
+	log.Printf("[Azure CNS] Authenticating to DNC with mode %v.", config.Mode)
+	return dc, nil
+}
+
+// RequestIPBatch asks DNC to allocate additional secondary IP addresses to the node.
+func (dc *DncClient) RequestIPBatch(req cns.RequestIPBatchRequest) error {
+	log.Printf("[Azure CNS] RequestIPBatch %+v", req)
+	return dc.post(requestIPBatchPath, req)
+}
+
+// ReleaseIPBatch asks DNC to remove secondary IP addresses from the node.
+func (dc *DncClient) ReleaseIPBatch(req cns.ReleaseIPBatchRequest) error {
+	log.Printf("[Azure CNS] ReleaseIPBatch %+v", req)
+	return dc.post(releaseIPBatchPath, req)
+}
+
+// SendNodeHeartbeat reports the health of the node to DNC.
+func (dc *DncClient) SendNodeHeartbeat(req cns.NodeHeartbeatRequest) error {
+	return dc.post(nodeHeartbeatPath, req)
+}
+
+// Posts a request to DNC and checks the response.
+// A request DNC rejects as unauthorized is retried once with fresh credentials.
+func (dc *DncClient) post(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	res, err := dc.send(path, body)
+	if err == nil && res.StatusCode == http.StatusUnauthorized && dc.auth != nil {
+		res.Body.Close()
+		log.Printf("[Azure CNS] DNC rejected the credentials, retrying with fresh ones")
+		dc.auth.invalidate()
+		res, err = dc.send(path, body)
+	}
+
+	if err != nil {
+		log.Printf("[Azure CNS] HTTP Post returned error %v", err.Error())
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("DNC returned http status code %v", res.StatusCode)
+	}
+
+	var resp cns.Response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+
+	if resp.ReturnCode != 0 {
+		return fmt.Errorf("DNC returned code %v: %v", resp.ReturnCode, resp.Message)
+	}
+
+	return nil
+}
+
+// Sends a request to DNC with the credentials of the client.
+func (dc *DncClient) send(path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, dc.connectionURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if dc.auth != nil {
+		if err := dc.auth.authorize(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return dc.client.Do(req)
+}
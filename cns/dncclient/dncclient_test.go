@@ -0,0 +1,93 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package dncclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+)
+
+func TestIPBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		release bool
+		fail    bool
+	}{
+		{name: "request", status: http.StatusOK, body: `{"ReturnCode": 0}`},
+		{name: "release", status: http.StatusOK, body: `{"ReturnCode": 0}`, release: true},
+		{name: "return code", status: http.StatusOK, body: `{"ReturnCode": 23, "Message": "No capacity"}`, fail: true},
+		{name: "http status", status: http.StatusInternalServerError, body: `{"ReturnCode": 0}`, fail: true},
+		{name: "malformed response", status: http.StatusOK, body: `{`, fail: true},
+	}
+
+	for _, test := range tests {
+		var path, contentType string
+		var requested cns.RequestIPBatchRequest
+		var released cns.ReleaseIPBatchRequest
+
+		dnc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+			if r.URL.Path == releaseIPBatchPath {
+				json.NewDecoder(r.Body).Decode(&released)
+			} else {
+				json.NewDecoder(r.Body).Decode(&requested)
+			}
+
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+
+		dc := NewDncClient(dnc.URL)
+
+		var err error
+		if test.release {
+			err = dc.ReleaseIPBatch(cns.ReleaseIPBatchRequest{
+				DncPartitionKey:            "key",
+				PrimaryInterfaceIdentifier: "10.0.0.4",
+				IPAddresses:                []string{"10.0.0.5"},
+			})
+		} else {
+			err = dc.RequestIPBatch(cns.RequestIPBatchRequest{
+				DncPartitionKey:            "key",
+				PrimaryInterfaceIdentifier: "10.0.0.4",
+				Count:                      16,
+			})
+		}
+
+		dnc.Close()
+
+		if (err != nil) != test.fail {
+			t.Errorf("TestIPBatch failed @ %v: err %v", test.name, err)
+		}
+
+		if contentType != "application/json" {
+			t.Errorf("TestIPBatch failed @ %v: content type %v", test.name, contentType)
+		}
+
+		if test.release {
+			if path != releaseIPBatchPath || released.PrimaryInterfaceIdentifier != "10.0.0.4" ||
+				len(released.IPAddresses) != 1 || released.IPAddresses[0] != "10.0.0.5" {
+				t.Errorf("TestIPBatch failed @ %v: %v %+v", test.name, path, released)
+			}
+		} else if path != requestIPBatchPath || requested.DncPartitionKey != "key" || requested.Count != 16 {
+			t.Errorf("TestIPBatch failed @ %v: %v %+v", test.name, path, requested)
+		}
+	}
+}
+
+func TestIPBatchUnreachable(t *testing.T) {
+	dnc := httptest.NewServer(http.NotFoundHandler())
+	dc := NewDncClient(dnc.URL)
+	dnc.Close()
+
+	if err := dc.RequestIPBatch(cns.RequestIPBatchRequest{Count: 1}); err == nil {
+		t.Errorf("TestIPBatchUnreachable failed, request to a closed server succeeded")
+	}
+}
@@ -27,20 +27,17 @@ type DockerClient struct {
 	imdsClient    *imdsclient.ImdsClient
 }
 
-// NewDockerClient create a new docker client.
-func NewDockerClient(url string) (*DockerClient, error) {
+// NewDockerClient create a new docker client connecting to docker at the given URL.
+func NewDockerClient(url string, imdsClient *imdsclient.ImdsClient) (*DockerClient, error) {
 	return &DockerClient{
 		connectionURL: url,
-		imdsClient:    &imdsclient.ImdsClient{},
+		imdsClient:    imdsClient,
 	}, nil
 }
 
 // NewDefaultDockerClient create a new docker client.
 func NewDefaultDockerClient(imdsClient *imdsclient.ImdsClient) (*DockerClient, error) {
-	return &DockerClient{
-		connectionURL: defaultDockerConnectionURL,
-		imdsClient:    imdsClient,
-	}, nil
+	return NewDockerClient(defaultDockerConnectionURL, imdsClient)
 }
 
 // NetworkExists tries to retrieve a network from docker (if it exists).
@@ -177,9 +174,9 @@ func (dockerClient *DockerClient) DeleteNetwork(networkName string) error {
 			return err
 		}
 
-		cmd := fmt.Sprintf("iptables -t nat -D POSTROUTING -m iprange ! --dst-range 168.63.129.16 -m addrtype ! --dst-type local ! -d %v -j MASQUERADE",
-			primaryNic.Subnet)
-		_, err = platform.ExecuteCommand(cmd)
+		_, err = platform.ExecWithTimeout("iptables", "-t", "nat", "-D", "POSTROUTING",
+			"-m", "iprange", "!", "--dst-range", "168.63.129.16", "-m", "addrtype", "!", "--dst-type", "local",
+			"!", "-d", primaryNic.Subnet, "-j", "MASQUERADE")
 		if err != nil {
 			log.Printf("[Azure CNS] Error Removing Outbound SNAT rule %v", err)
 		}
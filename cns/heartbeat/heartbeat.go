@@ -0,0 +1,141 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package heartbeat
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// Config describes how often heartbeats are sent.
+// After a failed heartbeat, the delay doubles up to MaxBackoff until a heartbeat succeeds again.
+type Config struct {
+	Interval   time.Duration
+	MaxBackoff time.Duration
+}
+
+// Source returns the heartbeat to send, or false while there is nothing to report yet.
+type Source func() (cns.NodeHeartbeatRequest, bool)
+
+// Sender delivers heartbeats to DNC.
+type Sender interface {
+	SendNodeHeartbeat(req cns.NodeHeartbeatRequest) error
+}
+
+// Reporter periodically sends the heartbeat of the node.
+type Reporter struct {
+	config  Config
+	source  Source
+	sender  Sender
+	state   cns.HeartbeatState
+	trigger chan struct{}
+	clock   platform.Clock
+	sync.Mutex
+}
+
+// NewReporter creates a new heartbeat reporter.
+func NewReporter(config Config, source Source, sender Sender) (*Reporter, error) {
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("Invalid heartbeat interval %v", config.Interval)
+	}
+
+	if config.MaxBackoff < config.Interval {
+		config.MaxBackoff = config.Interval
+	}
+
+	return &Reporter{
+		config:  config,
+		source:  source,
+		sender:  sender,
+		state:   cns.HeartbeatState{Enabled: true, Interval: config.Interval},
+		trigger: make(chan struct{}, 1),
+		clock:   platform.NewClock(),
+	}, nil
+}
+
+// Run sends heartbeats until stop is closed.
+func (r *Reporter) Run(stop <-chan struct{}) {
+	for {
+		delay := r.Beat()
+
+		select {
+		case <-stop:
+			return
+		case <-r.trigger:
+		case <-r.clock.After(delay):
+		}
+	}
+}
+
+// Trigger sends a heartbeat without waiting for the next one, e.g. once the node registered.
+func (r *Reporter) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Beat sends a heartbeat and returns the delay until the next one.
+func (r *Reporter) Beat() time.Duration {
+	req, ok := r.source()
+	if !ok {
+		return r.schedule(r.config.Interval)
+	}
+
+	err := r.sender.SendNodeHeartbeat(req)
+
+	r.Lock()
+	r.state.LastAttempt = r.clock.Now()
+	if err == nil {
+		r.state.LastSuccess = r.state.LastAttempt
+		r.state.ConsecutiveFailures = 0
+		r.state.LastError = ""
+	} else {
+		r.state.ConsecutiveFailures++
+		r.state.LastError = err.Error()
+	}
+	failures := r.state.ConsecutiveFailures
+	r.Unlock()
+
+	if err != nil {
+		log.Printf("[heartbeat] Failed to send heartbeat, %d consecutive failures, err:%v.", failures, err)
+	}
+
+	return r.schedule(r.backoff(failures))
+}
+
+// GetState returns the state of the recent heartbeats.
+func (r *Reporter) GetState() cns.HeartbeatState {
+	r.Lock()
+	defer r.Unlock()
+	return r.state
+}
+
+// Returns the delay until the next heartbeat after the given number of consecutive failures.
+func (r *Reporter) backoff(failures int) time.Duration {
+	delay := r.config.Interval
+	for i := 0; i < failures && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > r.config.MaxBackoff {
+		delay = r.config.MaxBackoff
+	}
+
+	return delay
+}
+
+// Records when the next heartbeat is due.
+func (r *Reporter) schedule(delay time.Duration) time.Duration {
+	r.Lock()
+	r.state.NextHeartbeat = r.clock.Now().Add(delay)
+	r.Unlock()
+
+	return delay
+}
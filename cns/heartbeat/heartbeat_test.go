@@ -0,0 +1,92 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package heartbeat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// fakeSender records the heartbeats it receives.
+type fakeSender struct {
+	sent []cns.NodeHeartbeatRequest
+	err  error
+}
+
+func (s *fakeSender) SendNodeHeartbeat(req cns.NodeHeartbeatRequest) error {
+	if s.err == nil {
+		s.sent = append(s.sent, req)
+	}
+	return s.err
+}
+
+func newTestReporter(t *testing.T, source Source, sender Sender) *Reporter {
+	r, err := NewReporter(Config{Interval: time.Minute, MaxBackoff: 5 * time.Minute}, source, sender)
+	if err != nil {
+		t.Fatalf("Failed to create reporter: %v", err)
+	}
+	r.clock = platform.NewFakeClock(time.Now())
+	return r
+}
+
+// Tests that the delay doubles while DNC is unreachable and is reset once a heartbeat succeeds.
+func TestBackoff(t *testing.T) {
+	sender := &fakeSender{err: errors.New("connection refused")}
+	r := newTestReporter(t, func() (cns.NodeHeartbeatRequest, bool) {
+		return cns.NodeHeartbeatRequest{NodeName: "node"}, true
+	}, sender)
+
+	expected := []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, delay := range expected {
+		if d := r.Beat(); d != delay {
+			t.Errorf("Unexpected delay %v after %d failures, expected %v", d, i+1, delay)
+		}
+	}
+
+	state := r.GetState()
+	if state.ConsecutiveFailures != 4 || state.LastError == "" || !state.LastSuccess.IsZero() {
+		t.Errorf("Unexpected state %+v", state)
+	}
+
+	sender.err = nil
+	if d := r.Beat(); d != time.Minute {
+		t.Errorf("Unexpected delay %v after success", d)
+	}
+
+	state = r.GetState()
+	if state.ConsecutiveFailures != 0 || state.LastError != "" || state.LastSuccess != state.LastAttempt {
+		t.Errorf("Unexpected state %+v", state)
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0].NodeName != "node" {
+		t.Errorf("Unexpected heartbeats %+v", sender.sent)
+	}
+}
+
+// Tests that nothing is sent while the source has nothing to report.
+func TestNothingToReport(t *testing.T) {
+	sender := &fakeSender{}
+	r := newTestReporter(t, func() (cns.NodeHeartbeatRequest, bool) {
+		return cns.NodeHeartbeatRequest{}, false
+	}, sender)
+
+	if d := r.Beat(); d != time.Minute {
+		t.Errorf("Unexpected delay %v", d)
+	}
+
+	if len(sender.sent) != 0 || !r.GetState().LastAttempt.IsZero() {
+		t.Errorf("Unexpected heartbeats %+v", sender.sent)
+	}
+}
+
+// Tests that a non-positive interval is rejected.
+func TestInvalidInterval(t *testing.T) {
+	if _, err := NewReporter(Config{}, nil, nil); err == nil {
+		t.Errorf("Expected an error for a zero interval")
+	}
+}
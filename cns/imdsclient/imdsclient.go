@@ -4,37 +4,45 @@
 package imdsclient
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/nmagent"
 )
 
-// GetNetworkContainerInfoFromHost retrieves the programmed version of network container from Host.
-func (imdsClient *ImdsClient) GetNetworkContainerInfoFromHost(networkContainerID string, primaryAddress string, authToken string, apiVersion string) (*ContainerVersion, error) {
-	log.Printf("[Azure CNS] GetNetworkContainerInfoFromHost")
-	queryURL := fmt.Sprintf(hostQueryURLForProgrammedVersion,
-		primaryAddress, networkContainerID, authToken, apiVersion)
+// NewImdsClient creates a new client reaching NMAgent with the given configuration.
+func NewImdsClient(config nmagent.Config) *ImdsClient {
+	return &ImdsClient{
+		nmagentClient: nmagent.NewClient(config),
+	}
+}
 
-	log.Printf("[Azure CNS] Going to query Azure Host for container version @\n %v\n", queryURL)
-	jsonResponse, err := http.Get(queryURL)
-	if err != nil {
-		return nil, err
+// Returns the NMAgent client, created on first use.
+func (imdsClient *ImdsClient) client() *nmagent.Client {
+	if imdsClient.nmagentClient == nil {
+		imdsClient.nmagentClient = nmagent.NewClient(nmagent.Config{})
 	}
 
-	defer jsonResponse.Body.Close()
+	return imdsClient.nmagentClient
+}
 
-	log.Printf("[Azure CNS] Response received from Azure Host for NetworkManagement/interfaces: %v", jsonResponse.Body)
+// GetNetworkContainerInfoFromHost retrieves the programmed version of network container from Host.
+func (imdsClient *ImdsClient) GetNetworkContainerInfoFromHost(networkContainerID string, primaryAddress string, authToken string, apiVersion string) (*ContainerVersion, error) {
+	log.Printf("[Azure CNS] GetNetworkContainerInfoFromHost")
 
-	var response containerVersionJsonResponse
-	err = json.NewDecoder(jsonResponse.Body).Decode(&response)
+	response, err := imdsClient.client().GetNetworkContainerVersion(&nmagent.NetworkContainerVersionRequest{
+		NetworkContainerID: networkContainerID,
+		PrimaryAddress:     primaryAddress,
+		AuthToken:          authToken,
+		APIVersion:         apiVersion,
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	log.Printf("[Azure CNS] Response received from Azure Host for NetworkManagement/interfaces: %+v", response)
+
 	ret := &ContainerVersion{
 		NetworkContainerID: response.NetworkContainerID,
 		ProgrammedVersion:  response.ProgrammedVersion,
@@ -43,26 +51,22 @@ func (imdsClient *ImdsClient) GetNetworkContainerInfoFromHost(networkContainerID
 	return ret, nil
 }
 
+// GetInterfaceInfoFromHost retrieves the interfaces of the VM with their subnets and addresses from Host.
+func (imdsClient *ImdsClient) GetInterfaceInfoFromHost() (*nmagent.Interfaces, error) {
+	return imdsClient.client().GetInterfaceInfo()
+}
+
 // GetPrimaryInterfaceInfoFromHost retrieves subnet and gateway of primary NIC from Host.
 func (imdsClient *ImdsClient) GetPrimaryInterfaceInfoFromHost() (*InterfaceInfo, error) {
 	log.Printf("[Azure CNS] GetPrimaryInterfaceInfoFromHost")
 
 	interfaceInfo := &InterfaceInfo{}
-	resp, err := http.Get(hostQueryURL)
+	doc, err := imdsClient.client().GetInterfaceInfo()
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-
-	log.Printf("[Azure CNS] Response received from NMAgent for get interface details: %v", resp.Body)
-
-	var doc xmlDocument
-	decoder := xml.NewDecoder(resp.Body)
-	err = decoder.Decode(&doc)
-	if err != nil {
-		return nil, err
-	}
+	log.Printf("[Azure CNS] Response received from NMAgent for get interface details: %+v", doc)
 
 	foundPrimaryInterface := false
 
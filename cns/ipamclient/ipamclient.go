@@ -6,7 +6,7 @@ package ipamclient
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
+	"errors"
 
 	cnmIpam "github.com/Azure/azure-container-networking/cnm/ipam"
 	ipam "github.com/Azure/azure-container-networking/ipam"
@@ -57,7 +57,7 @@ func (ic *IpamClient) GetAddressSpace() (string, error) {
 
 		if resp.Err != "" {
 			log.Printf("[Azure CNS] GetAddressSpace received error response :%v", resp.Err)
-			return "", fmt.Errorf(resp.Err)
+			return "", errors.New(resp.Err)
 		}
 
 		return resp.LocalDefaultAddressSpace, nil
@@ -103,7 +103,7 @@ func (ic *IpamClient) GetPoolID(asID, subnet string) (string, error) {
 
 		if resp.Err != "" {
 			log.Printf("[Azure CNS] GetPoolID received error response :%v", resp.Err)
-			return "", fmt.Errorf(resp.Err)
+			return "", errors.New(resp.Err)
 		}
 
 		return resp.PoolID, nil
@@ -115,6 +115,11 @@ func (ic *IpamClient) GetPoolID(asID, subnet string) (string, error) {
 
 // ReserveIPAddress request an Ip address for the reservation id.
 func (ic *IpamClient) ReserveIPAddress(poolID string, reservationID string) (string, error) {
+	return ic.ReserveIPAddressAt(poolID, reservationID, "")
+}
+
+// ReserveIPAddressAt request the given Ip address for the reservation id, or any address if it is empty.
+func (ic *IpamClient) ReserveIPAddressAt(poolID string, reservationID string, address string) (string, error) {
 	var body bytes.Buffer
 	log.Printf("[Azure CNS] ReserveIpAddress")
 
@@ -127,7 +132,7 @@ func (ic *IpamClient) ReserveIPAddress(poolID string, reservationID string) (str
 
 	payload := &cnmIpam.RequestAddressRequest{
 		PoolID:  poolID,
-		Address: "",
+		Address: address,
 		Options: make(map[string]string),
 	}
 	payload.Options[ipam.OptAddressID] = reservationID
@@ -152,7 +157,7 @@ func (ic *IpamClient) ReserveIPAddress(poolID string, reservationID string) (str
 
 		if reserveResp.Err != "" {
 			log.Printf("[Azure CNS] ReserveIP received error response :%v", reserveResp.Err)
-			return "", fmt.Errorf(reserveResp.Err)
+			return "", errors.New(reserveResp.Err)
 		}
 
 		return reserveResp.Address, nil
@@ -202,7 +207,7 @@ func (ic *IpamClient) ReleaseIPAddress(poolID string, reservationID string) erro
 
 		if releaseResp.Err != "" {
 			log.Printf("[Azure CNS] ReleaseIP received error response :%v", releaseResp.Err)
-			return fmt.Errorf(releaseResp.Err)
+			return errors.New(releaseResp.Err)
 		}
 
 		return nil
@@ -247,7 +252,7 @@ func (ic *IpamClient) GetIPAddressUtilization(poolID string) (int, int, []string
 
 		if poolInfoResp.Err != "" {
 			log.Printf("[Azure CNS] GetIPUtilization received error response :%v", poolInfoResp.Err)
-			return 0, 0, nil, fmt.Errorf(poolInfoResp.Err)
+			return 0, 0, nil, errors.New(poolInfoResp.Err)
 		}
 
 		return poolInfoResp.Capacity, poolInfoResp.Available, poolInfoResp.UnhealthyAddresses, nil
@@ -0,0 +1,227 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package ippool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Number of recent decisions kept for debugging.
+	maxDecisions = 20
+
+	// Prefix of the reservation IDs holding addresses while they are released.
+	releaseReservationPrefix = "ippool-release-"
+
+	// Released addresses stay reserved until the pool had time to learn they are gone,
+	// otherwise they could be handed to pods in between.
+	releaseHoldTime = 5 * time.Minute
+)
+
+// Config describes how the pool is scaled.
+// The pool grows by BatchSize addresses when fewer than MinFree addresses are available, and
+// shrinks by BatchSize addresses when more than MaxFree addresses are available.
+type Config struct {
+	BatchSize int
+	MinFree   int
+	MaxFree   int
+}
+
+// Pool is the local address pool serving pods.
+type Pool interface {
+	GetUtilization() (capacity int, available int, err error)
+	Reserve(reservationID string) (string, error)
+	Release(reservationID string) error
+}
+
+// Controller allocates addresses to the node.
+type Controller interface {
+	RequestIPBatch(count int) error
+	ReleaseIPBatch(addresses []string) error
+}
+
+// Manager scales the pool to keep the number of available addresses within bounds.
+type Manager struct {
+	config     Config
+	pool       Pool
+	controller Controller
+	decisions  []cns.IPPoolDecision
+	released   map[string]time.Time // Reservation ID is key.
+	clock      platform.Clock
+	sync.Mutex
+}
+
+// NewManager creates a new pool manager.
+func NewManager(config Config, pool Pool, controller Controller) (*Manager, error) {
+	if config.BatchSize <= 0 {
+		return nil, fmt.Errorf("Invalid batch size %v", config.BatchSize)
+	}
+
+	// A window smaller than a batch makes every scaling decision undo the previous one.
+	if config.MinFree < 0 || config.MaxFree < config.MinFree+config.BatchSize {
+		return nil, fmt.Errorf("Maximum free addresses %v must be at least minimum free addresses %v plus batch size %v",
+			config.MaxFree, config.MinFree, config.BatchSize)
+	}
+
+	return &Manager{
+		config:     config,
+		pool:       pool,
+		controller: controller,
+		released:   make(map[string]time.Time),
+		clock:      platform.NewClock(),
+	}, nil
+}
+
+// Scale returns the number of addresses to request when positive or to release when negative,
+// given the number of available addresses.
+func (c Config) Scale(available int) int {
+	if available < c.MinFree {
+		batches := (c.MinFree - available + c.BatchSize - 1) / c.BatchSize
+		return batches * c.BatchSize
+	}
+
+	if available > c.MaxFree {
+		batches := (available - c.MaxFree + c.BatchSize - 1) / c.BatchSize
+
+		// Never release below the minimum.
+		if max := (available - c.MinFree) / c.BatchSize; batches > max {
+			batches = max
+		}
+
+		return -batches * c.BatchSize
+	}
+
+	return 0
+}
+
+// Reconcile compares the pool utilization with the configured bounds and requests or releases addresses.
+func (m *Manager) Reconcile() cns.IPPoolDecision {
+	decision := cns.IPPoolDecision{
+		Time:   m.clock.Now(),
+		Action: cns.IPPoolActionNone,
+	}
+
+	m.releaseHeldAddresses(decision.Time)
+
+	capacity, available, err := m.pool.GetUtilization()
+	if err != nil {
+		decision.Error = err.Error()
+		return m.record(decision)
+	}
+
+	decision.Capacity = capacity
+	decision.Available = available
+
+	count := m.config.Scale(available)
+	switch {
+	case count > 0:
+		decision.Action = cns.IPPoolActionRequest
+		decision.Count = count
+		err = m.controller.RequestIPBatch(count)
+	case count < 0:
+		decision.Action = cns.IPPoolActionRelease
+		decision.Count = -count
+		err = m.release(-count)
+	}
+
+	if err != nil {
+		decision.Error = err.Error()
+	}
+
+	return m.record(decision)
+}
+
+// Releases addresses by reserving them so they are not handed to pods, then returning them to the controller.
+func (m *Manager) release(count int) error {
+	var reservationIDs []string
+	var addresses []string
+
+	// Reservation IDs must not collide with those held by a previous instance.
+	batchID := m.clock.Now().UnixNano()
+
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("%s%d-%d", releaseReservationPrefix, batchID, i)
+
+		address, err := m.pool.Reserve(id)
+		if err != nil {
+			m.releaseReservations(reservationIDs)
+			return err
+		}
+
+		reservationIDs = append(reservationIDs, id)
+		addresses = append(addresses, address)
+	}
+
+	if err := m.controller.ReleaseIPBatch(addresses); err != nil {
+		m.releaseReservations(reservationIDs)
+		return err
+	}
+
+	now := m.clock.Now()
+	for _, id := range reservationIDs {
+		m.released[id] = now
+	}
+
+	return nil
+}
+
+// Drops the reservations of released addresses once they were held long enough.
+func (m *Manager) releaseHeldAddresses(now time.Time) {
+	var reservationIDs []string
+
+	for id, releaseTime := range m.released {
+		if now.Sub(releaseTime) >= releaseHoldTime {
+			reservationIDs = append(reservationIDs, id)
+			delete(m.released, id)
+		}
+	}
+
+	m.releaseReservations(reservationIDs)
+}
+
+// Releases the given reservations.
+func (m *Manager) releaseReservations(reservationIDs []string) {
+	for _, id := range reservationIDs {
+		if err := m.pool.Release(id); err != nil {
+			log.Printf("[Azure CNS] Failed to release reservation %v, err:%v.", id, err)
+		}
+	}
+}
+
+// Records a decision and reports scaling actions and failures.
+func (m *Manager) record(decision cns.IPPoolDecision) cns.IPPoolDecision {
+	m.Lock()
+	m.decisions = append(m.decisions, decision)
+	if len(m.decisions) > maxDecisions {
+		m.decisions = m.decisions[len(m.decisions)-maxDecisions:]
+	}
+	m.Unlock()
+
+	if decision.Action != cns.IPPoolActionNone || decision.Error != "" {
+		log.Errorf("[Azure CNS] IP pool %v of %v addresses, capacity:%v available:%v err:%v.",
+			decision.Action, decision.Count, decision.Capacity, decision.Available, decision.Error)
+	}
+
+	return decision
+}
+
+// GetState returns the configuration and recent decisions of the manager.
+func (m *Manager) GetState() cns.IPPoolState {
+	m.Lock()
+	defer m.Unlock()
+
+	return cns.IPPoolState{
+		Enabled:   true,
+		BatchSize: m.config.BatchSize,
+		MinFree:   m.config.MinFree,
+		MaxFree:   m.config.MaxFree,
+		Decisions: append([]cns.IPPoolDecision(nil), m.decisions...),
+	}
+}
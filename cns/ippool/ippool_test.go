@@ -0,0 +1,177 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package ippool
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// fakePool is a pool of numbered addresses.
+type fakePool struct {
+	capacity     int
+	reservations map[string]string
+}
+
+func newFakePool(capacity int, reserved int) *fakePool {
+	p := &fakePool{capacity: capacity, reservations: make(map[string]string)}
+	for i := 0; i < reserved; i++ {
+		p.Reserve(fmt.Sprintf("pod-%d", i))
+	}
+	return p
+}
+
+func (p *fakePool) GetUtilization() (int, int, error) {
+	return p.capacity, p.capacity - len(p.reservations), nil
+}
+
+func (p *fakePool) Reserve(reservationID string) (string, error) {
+	if len(p.reservations) == p.capacity {
+		return "", fmt.Errorf("Pool is exhausted")
+	}
+	address := fmt.Sprintf("10.0.0.%d", len(p.reservations))
+	p.reservations[reservationID] = address
+	return address, nil
+}
+
+func (p *fakePool) Release(reservationID string) error {
+	delete(p.reservations, reservationID)
+	return nil
+}
+
+// fakeController records the requests it receives.
+type fakeController struct {
+	requested int
+	released  []string
+	err       error
+}
+
+func (c *fakeController) RequestIPBatch(count int) error {
+	c.requested += count
+	return c.err
+}
+
+func (c *fakeController) ReleaseIPBatch(addresses []string) error {
+	if c.err == nil {
+		c.released = append(c.released, addresses...)
+	}
+	return c.err
+}
+
+// Tests the number of addresses requested or released for various utilizations.
+func TestScale(t *testing.T) {
+	config := Config{BatchSize: 10, MinFree: 5, MaxFree: 20}
+
+	tests := []struct {
+		available int
+		expected  int
+	}{
+		{available: 0, expected: 10},
+		{available: 4, expected: 10},
+		{available: 5, expected: 0},
+		{available: 20, expected: 0},
+		{available: 21, expected: -10},
+		{available: 34, expected: -20},
+		// Releasing two batches would go below the minimum.
+		{available: 24, expected: -10},
+	}
+
+	for _, test := range tests {
+		if count := config.Scale(test.available); count != test.expected {
+			t.Errorf("Scale(%v) returned %v, expected %v", test.available, count, test.expected)
+		}
+	}
+}
+
+// Tests that invalid configurations are rejected.
+func TestNewManagerValidatesConfig(t *testing.T) {
+	for _, config := range []Config{
+		{BatchSize: 0, MinFree: 0, MaxFree: 10},
+		{BatchSize: 10, MinFree: 5, MaxFree: 10},
+		{BatchSize: 10, MinFree: -1, MaxFree: 10},
+	} {
+		if _, err := NewManager(config, newFakePool(0, 0), &fakeController{}); err == nil {
+			t.Errorf("Config %+v was not rejected", config)
+		}
+	}
+}
+
+// Tests that the manager requests a batch when the pool runs low.
+func TestReconcileRequestsBatch(t *testing.T) {
+	controller := &fakeController{}
+	m, err := NewManager(Config{BatchSize: 10, MinFree: 5, MaxFree: 20}, newFakePool(10, 8), controller)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	decision := m.Reconcile()
+	if decision.Action != cns.IPPoolActionRequest || decision.Count != 10 || controller.requested != 10 {
+		t.Errorf("Unexpected decision %+v, requested:%v", decision, controller.requested)
+	}
+
+	state := m.GetState()
+	if len(state.Decisions) != 1 || state.Decisions[0].Action != cns.IPPoolActionRequest {
+		t.Errorf("Decision was not recorded, state:%+v", state)
+	}
+}
+
+// Tests that released addresses stay reserved until the hold time expires.
+func TestReconcileReleasesBatch(t *testing.T) {
+	pool := newFakePool(40, 5)
+	controller := &fakeController{}
+	m, err := NewManager(Config{BatchSize: 10, MinFree: 5, MaxFree: 20}, pool, controller)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	clock := platform.NewFakeClock(time.Now())
+	m.clock = clock
+
+	decision := m.Reconcile()
+	if decision.Action != cns.IPPoolActionRelease || decision.Count != 20 || len(controller.released) != 20 {
+		t.Fatalf("Unexpected decision %+v, released:%v", decision, controller.released)
+	}
+
+	if len(pool.reservations) != 25 {
+		t.Errorf("Released addresses are not held, reservations:%v", len(pool.reservations))
+	}
+
+	clock.Advance(releaseHoldTime - time.Second)
+	m.releaseHeldAddresses(clock.Now())
+
+	if len(pool.reservations) != 25 {
+		t.Errorf("Held addresses were released early, reservations:%v", len(pool.reservations))
+	}
+
+	// Expire the hold time.
+	clock.Advance(time.Second)
+	m.releaseHeldAddresses(clock.Now())
+
+	if len(pool.reservations) != 5 || len(m.released) != 0 {
+		t.Errorf("Held addresses were not released, reservations:%v", len(pool.reservations))
+	}
+}
+
+// Tests that addresses are returned to the pool when DNC fails to release them.
+func TestReconcileReleaseFailure(t *testing.T) {
+	pool := newFakePool(40, 5)
+	controller := &fakeController{err: fmt.Errorf("DNC unavailable")}
+	m, err := NewManager(Config{BatchSize: 10, MinFree: 5, MaxFree: 20}, pool, controller)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	decision := m.Reconcile()
+	if decision.Error == "" {
+		t.Errorf("Failure was not recorded, decision:%+v", decision)
+	}
+
+	if len(pool.reservations) != 5 || len(m.released) != 0 {
+		t.Errorf("Reservations were not rolled back, reservations:%v", len(pool.reservations))
+	}
+}
@@ -21,7 +21,7 @@ func interfaceExists(iFaceName string) (bool, error) {
 	_, err := net.InterfaceByName(iFaceName)
 	if err != nil {
 		errMsg := fmt.Sprintf("[Azure CNS] Unable to get interface by name %v, %v", iFaceName, err)
-		log.Printf(errMsg)
+		log.Printf("%s", errMsg)
 		return false, errors.New(errMsg)
 	}
 
@@ -57,3 +57,46 @@ func (cn *NetworkContainers) Delete(networkContainerID string) error {
 	log.Printf("[Azure CNS] NetworkContainers.Delete finished.")
 	return err
 }
+
+// PlanCreateOrUpdate returns the operations Create or Update would perform for the network container,
+// without performing them. An error means that they would fail.
+func (cn *NetworkContainers) PlanCreateOrUpdate(createNetworkContainerRequest cns.CreateNetworkContainerRequest) ([]cns.DataplaneOperation, error) {
+	return planCreateOrUpdateInterface(createNetworkContainerRequest)
+}
+
+// SetIsolation isolates the given network containers from each other, replacing the isolation
+// programmed for any previous set, so that containers of different network containers on the node
+// cannot reach each other.
+func (cn *NetworkContainers) SetIsolation(createNetworkContainerRequests []cns.CreateNetworkContainerRequest) error {
+	subnets := isolationSubnets(createNetworkContainerRequests)
+
+	log.Printf("[Azure CNS] NetworkContainers.SetIsolation called for %v network containers", len(subnets))
+	err := setIsolation(subnets)
+	log.Printf("[Azure CNS] NetworkContainers.SetIsolation finished.")
+	return err
+}
+
+// PlanIsolation returns the operations SetIsolation would perform for the given network containers,
+// without performing them.
+func (cn *NetworkContainers) PlanIsolation(createNetworkContainerRequests []cns.CreateNetworkContainerRequest) []cns.DataplaneOperation {
+	return planIsolation(isolationSubnets(createNetworkContainerRequests))
+}
+
+// Returns the IPv4 subnets of the network containers by network container ID.
+func isolationSubnets(createNetworkContainerRequests []cns.CreateNetworkContainerRequest) map[string]*net.IPNet {
+	subnets := make(map[string]*net.IPNet)
+	for _, req := range createNetworkContainerRequests {
+		ipSubnet := req.IPConfiguration.IPSubnet
+		ip := net.ParseIP(ipSubnet.IPAddress).To4()
+		if ip == nil || ipSubnet.PrefixLength > 32 {
+			log.Printf("[Azure CNS] Skipping isolation of network container %v with subnet %+v.",
+				req.NetworkContainerid, ipSubnet)
+			continue
+		}
+
+		mask := net.CIDRMask(int(ipSubnet.PrefixLength), 32)
+		subnets[req.NetworkContainerid] = &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	}
+
+	return subnets
+}
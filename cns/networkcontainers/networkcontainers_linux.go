@@ -3,12 +3,39 @@
 
 package networkcontainers
 
-import "github.com/Azure/azure-container-networking/cns"
+import (
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Chain holding the rules that drop forwarded traffic between network containers.
+	ncIsolationChain = "AZURE-CNS-NC-ISOLATION"
+
+	// Priority of the policy routing rules selecting the route table of a network container.
+	ncIsolationRulePriority = 2000
+
+	// Route table of the first network container; the others follow in network container ID order.
+	ncIsolationTableBase = 2000
+)
+
+// Number of route tables programmed by the last call to setIsolation.
+var ncIsolationTables int
 
 func createOrUpdateInterface(createNetworkContainerRequest cns.CreateNetworkContainerRequest) error {
 	return nil
 }
 
+// Network containers need no interface on Linux.
+func planCreateOrUpdateInterface(createNetworkContainerRequest cns.CreateNetworkContainerRequest) ([]cns.DataplaneOperation, error) {
+	return nil, nil
+}
+
 func setWeakHostOnInterface(ipAddress string) error {
 	return nil
 }
@@ -20,3 +47,175 @@ func createOrUpdateWithOperation(createNetworkContainerRequest cns.CreateNetwork
 func deleteInterface(networkContainerID string) error {
 	return nil
 }
+
+// Isolates the subnets of network containers with a route table per network container, in which the
+// subnets of the other network containers are unreachable, and with forwarding rules dropping traffic
+// between them in case the routing is bypassed.
+func setIsolation(subnets map[string]*net.IPNet) error {
+	ids := sortedIDs(subnets)
+
+	// The chain may already exist.
+	platform.ExecWithTimeout("iptables", "-w", "-N", ncIsolationChain)
+
+	var commands [][]string
+	if _, err := platform.ExecWithTimeout("iptables", "-w", "-C", "FORWARD", "-j", ncIsolationChain); err != nil {
+		commands = append(commands, []string{"iptables", "-w", "-I", "FORWARD", "-j", ncIsolationChain})
+	}
+
+	commands = append(commands, isolationFilterCommands(ids, subnets)...)
+
+	if err := runIsolationCommands(commands); err != nil {
+		return err
+	}
+
+	// Remove the rules selecting the previous route tables.
+	priority := strconv.Itoa(ncIsolationRulePriority)
+	for {
+		if _, err := platform.ExecWithTimeout("ip", "rule", "del", "priority", priority); err != nil {
+			break
+		}
+	}
+
+	for i := range ids {
+		// The table may not exist yet.
+		platform.ExecWithTimeout("ip", "route", "flush", "table", strconv.Itoa(ncIsolationTableBase+i))
+
+		if err := runIsolationCommands(isolationRouteCommands(i, ids, subnets)); err != nil {
+			return err
+		}
+	}
+
+	// Remove the route tables of network containers that no longer exist.
+	for i := len(ids); i < ncIsolationTables; i++ {
+		platform.ExecWithTimeout("ip", "route", "flush", "table", strconv.Itoa(ncIsolationTableBase+i))
+	}
+
+	ncIsolationTables = len(ids)
+	return nil
+}
+
+// Returns the operations setIsolation performs for the given subnets, in order.
+func planIsolation(subnets map[string]*net.IPNet) []cns.DataplaneOperation {
+	ids := sortedIDs(subnets)
+
+	ops := []cns.DataplaneOperation{{
+		Description: "Create the network container isolation chain if it doesn't exist",
+		Command:     []string{"iptables", "-w", "-N", ncIsolationChain},
+	}}
+
+	if _, err := platform.ExecWithTimeout("iptables", "-w", "-C", "FORWARD", "-j", ncIsolationChain); err != nil {
+		ops = append(ops, cns.DataplaneOperation{
+			Description: "Jump to the network container isolation chain from the FORWARD chain",
+			Command:     []string{"iptables", "-w", "-I", "FORWARD", "-j", ncIsolationChain},
+		})
+	}
+
+	for _, command := range isolationFilterCommands(ids, subnets) {
+		ops = append(ops, cns.DataplaneOperation{Description: "Program forwarding between network containers", Command: command})
+	}
+
+	ops = append(ops, cns.DataplaneOperation{
+		Description: "Remove the rules selecting the previous route tables, until none is left",
+		Command:     []string{"ip", "rule", "del", "priority", strconv.Itoa(ncIsolationRulePriority)},
+	})
+
+	for i, id := range ids {
+		ops = append(ops, cns.DataplaneOperation{
+			Description: "Flush the route table of network container " + id,
+			Command:     []string{"ip", "route", "flush", "table", strconv.Itoa(ncIsolationTableBase + i)},
+		})
+
+		for _, command := range isolationRouteCommands(i, ids, subnets) {
+			ops = append(ops, cns.DataplaneOperation{Description: "Program the routing of network container " + id, Command: command})
+		}
+	}
+
+	for i := len(ids); i < ncIsolationTables; i++ {
+		ops = append(ops, cns.DataplaneOperation{
+			Description: "Flush the route table of a removed network container",
+			Command:     []string{"ip", "route", "flush", "table", strconv.Itoa(ncIsolationTableBase + i)},
+		})
+	}
+
+	return ops
+}
+
+// Returns the network container IDs of the subnets in order, which gives the route table of each.
+func sortedIDs(subnets map[string]*net.IPNet) []string {
+	var ids []string
+	for id := range subnets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Returns the distinct subnets of the network containers, in the order of their IDs.
+func distinctSubnets(ids []string, subnets map[string]*net.IPNet) []string {
+	var distinct []string
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		subnet := subnets[id].String()
+		if !seen[subnet] {
+			seen[subnet] = true
+			distinct = append(distinct, subnet)
+		}
+	}
+
+	return distinct
+}
+
+// Returns the commands flushing the isolation chain and dropping traffic between the subnets of network containers.
+func isolationFilterCommands(ids []string, subnets map[string]*net.IPNet) [][]string {
+	commands := [][]string{{"iptables", "-w", "-F", ncIsolationChain}}
+
+	// Network containers sharing a subnet get a single rule per subnet.
+	distinct := distinctSubnets(ids, subnets)
+	for _, src := range distinct {
+		for _, dst := range distinct {
+			if src == dst {
+				continue
+			}
+
+			commands = append(commands, []string{"iptables", "-w", "-A", ncIsolationChain,
+				"-s", src, "-d", dst, "-j", "DROP"})
+		}
+	}
+
+	return commands
+}
+
+// Returns the commands programming the route table of the i-th network container and selecting it for its subnet.
+func isolationRouteCommands(i int, ids []string, subnets map[string]*net.IPNet) [][]string {
+	var commands [][]string
+	src := ids[i]
+	table := strconv.Itoa(ncIsolationTableBase + i)
+
+	// Network containers sharing a subnet get a single route per subnet.
+	for _, dst := range distinctSubnets(ids, subnets) {
+		if dst == subnets[src].String() {
+			continue
+		}
+
+		commands = append(commands, []string{"ip", "route", "replace", "unreachable", dst, "table", table})
+	}
+
+	// Lookups not matching the table continue in the main table.
+	commands = append(commands, []string{"ip", "rule", "add", "from", subnets[src].String(),
+		"priority", strconv.Itoa(ncIsolationRulePriority), "table", table})
+
+	return commands
+}
+
+// Runs the commands programming network container isolation until one fails.
+func runIsolationCommands(commands [][]string) error {
+	for _, command := range commands {
+		if _, err := platform.ExecWithTimeout(command[0], command[1:]...); err != nil {
+			log.Printf("[Azure CNS] Failed to program network container isolation, err:%v", err)
+			return err
+		}
+	}
+
+	return nil
+}
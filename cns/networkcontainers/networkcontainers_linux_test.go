@@ -0,0 +1,110 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package networkcontainers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func newIsolationSubnets(t *testing.T, cidrs map[string]string) map[string]*net.IPNet {
+	subnets := make(map[string]*net.IPNet)
+	for id, cidr := range cidrs {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subnets[id] = subnet
+	}
+
+	return subnets
+}
+
+func TestIsolationFilterCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		subnets  map[string]string
+		commands [][]string
+	}{
+		{
+			name:     "single network container",
+			subnets:  map[string]string{"nc1": "10.1.0.0/24"},
+			commands: [][]string{{"iptables", "-w", "-F", ncIsolationChain}},
+		},
+		{
+			name:    "two network containers",
+			subnets: map[string]string{"nc2": "10.2.0.0/24", "nc1": "10.1.0.0/24"},
+			commands: [][]string{
+				{"iptables", "-w", "-F", ncIsolationChain},
+				{"iptables", "-w", "-A", ncIsolationChain, "-s", "10.1.0.0/24", "-d", "10.2.0.0/24", "-j", "DROP"},
+				{"iptables", "-w", "-A", ncIsolationChain, "-s", "10.2.0.0/24", "-d", "10.1.0.0/24", "-j", "DROP"},
+			},
+		},
+		{
+			name:     "network containers sharing a subnet",
+			subnets:  map[string]string{"nc1": "10.1.0.0/24", "nc2": "10.1.0.0/24"},
+			commands: [][]string{{"iptables", "-w", "-F", ncIsolationChain}},
+		},
+		{
+			name:    "network containers sharing a subnet with another one",
+			subnets: map[string]string{"nc1": "10.1.0.0/24", "nc2": "10.2.0.0/24", "nc3": "10.1.0.0/24"},
+			commands: [][]string{
+				{"iptables", "-w", "-F", ncIsolationChain},
+				{"iptables", "-w", "-A", ncIsolationChain, "-s", "10.1.0.0/24", "-d", "10.2.0.0/24", "-j", "DROP"},
+				{"iptables", "-w", "-A", ncIsolationChain, "-s", "10.2.0.0/24", "-d", "10.1.0.0/24", "-j", "DROP"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		subnets := newIsolationSubnets(t, test.subnets)
+		commands := isolationFilterCommands(sortedIDs(subnets), subnets)
+		if !reflect.DeepEqual(commands, test.commands) {
+			t.Errorf("TestIsolationFilterCommands failed @ %v: %v, expected %v", test.name, commands, test.commands)
+		}
+	}
+}
+
+func TestIsolationRouteCommands(t *testing.T) {
+	subnets := newIsolationSubnets(t, map[string]string{"nc2": "10.2.0.0/24", "nc1": "10.1.0.0/24", "nc3": "10.1.0.0/24"})
+	ids := sortedIDs(subnets)
+	if !reflect.DeepEqual(ids, []string{"nc1", "nc2", "nc3"}) {
+		t.Fatalf("TestIsolationRouteCommands failed, IDs %v", ids)
+	}
+
+	tests := []struct {
+		index    int
+		commands [][]string
+	}{
+		{
+			index: 0,
+			commands: [][]string{
+				{"ip", "route", "replace", "unreachable", "10.2.0.0/24", "table", "2000"},
+				{"ip", "rule", "add", "from", "10.1.0.0/24", "priority", "2000", "table", "2000"},
+			},
+		},
+		{
+			index: 1,
+			commands: [][]string{
+				{"ip", "route", "replace", "unreachable", "10.1.0.0/24", "table", "2001"},
+				{"ip", "rule", "add", "from", "10.2.0.0/24", "priority", "2000", "table", "2001"},
+			},
+		},
+		{
+			index: 2,
+			commands: [][]string{
+				{"ip", "route", "replace", "unreachable", "10.2.0.0/24", "table", "2002"},
+				{"ip", "rule", "add", "from", "10.1.0.0/24", "priority", "2000", "table", "2002"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		commands := isolationRouteCommands(test.index, ids, subnets)
+		if !reflect.DeepEqual(commands, test.commands) {
+			t.Errorf("TestIsolationRouteCommands failed @ %v: %v, expected %v", ids[test.index], commands, test.commands)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package networkcontainers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+)
+
+func newIsolationRequest(id string, address string, prefixLength uint8) cns.CreateNetworkContainerRequest {
+	return cns.CreateNetworkContainerRequest{
+		NetworkContainerid: id,
+		IPConfiguration: cns.IPConfiguration{
+			IPSubnet: cns.IPSubnet{IPAddress: address, PrefixLength: prefixLength},
+		},
+	}
+}
+
+func TestIsolationSubnets(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    cns.CreateNetworkContainerRequest
+		subnet string
+	}{
+		{name: "IPv4 subnet", req: newIsolationRequest("nc1", "10.1.0.5", 24), subnet: "10.1.0.0/24"},
+		{name: "host address", req: newIsolationRequest("nc2", "10.2.0.5", 32), subnet: "10.2.0.5/32"},
+		{name: "IPv6 subnet", req: newIsolationRequest("nc3", "fd00::5", 64)},
+		{name: "invalid address", req: newIsolationRequest("nc4", "10.4.0", 24)},
+		{name: "invalid prefix length", req: newIsolationRequest("nc5", "10.5.0.5", 33)},
+	}
+
+	for _, test := range tests {
+		subnets := isolationSubnets([]cns.CreateNetworkContainerRequest{test.req})
+
+		subnet, ok := subnets[test.req.NetworkContainerid]
+		if ok != (test.subnet != "") || (ok && subnet.String() != test.subnet) {
+			t.Errorf("TestIsolationSubnets failed @ %v: subnets %v, expected %v", test.name, subnets, test.subnet)
+		}
+	}
+}
@@ -17,20 +17,73 @@ import (
 )
 
 func createOrUpdateInterface(createNetworkContainerRequest cns.CreateNetworkContainerRequest) error {
+	return createOrUpdateWithOperation(createNetworkContainerRequest, interfaceOperation(createNetworkContainerRequest))
+}
+
+// Returns the operation of AzureNetworkContainer.exe creating or updating the loopback adapter of a network container.
+func interfaceOperation(createNetworkContainerRequest cns.CreateNetworkContainerRequest) string {
 	exists, _ := interfaceExists(createNetworkContainerRequest.NetworkContainerid)
 
 	if !exists {
-		return createOrUpdateWithOperation(createNetworkContainerRequest, "CREATE")
+		return "CREATE"
+	}
+
+	return "UPDATE"
+}
+
+// Returns the commands creating or updating the loopback adapter of a network container,
+// and enabling weak host send/receive on its primary interface.
+func planCreateOrUpdateInterface(createNetworkContainerRequest cns.CreateNetworkContainerRequest) ([]cns.DataplaneOperation, error) {
+	operation := interfaceOperation(createNetworkContainerRequest)
+	args, err := createOrUpdateArgs(createNetworkContainerRequest, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []cns.DataplaneOperation{{
+		Description: fmt.Sprintf("Run the %v operation on the network loopback adapter %v", operation, createNetworkContainerRequest.NetworkContainerid),
+		Command:     append([]string{"cmd"}, args...),
+	}}
+
+	args, err = weakHostArgs(createNetworkContainerRequest.PrimaryInterfaceIdentifier)
+	if err != nil {
+		return ops, err
 	}
 
-	return createOrUpdateWithOperation(createNetworkContainerRequest, "UPDATE")
+	ops = append(ops, cns.DataplaneOperation{
+		Description: "Enable weak host send/receive on the interface with IP " + createNetworkContainerRequest.PrimaryInterfaceIdentifier,
+		Command:     append([]string{"cmd"}, args...),
+	})
+
+	return ops, nil
 }
 
 func setWeakHostOnInterface(ipAddress string) error {
+	args, err := weakHostArgs(ipAddress)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[Azure CNS] Going to enable weak host send/receive on interface: %v", args)
+	c := exec.Command("cmd", args...)
+	bytes, err := c.Output()
+
+	if err == nil {
+		log.Printf("[Azure CNS] Successfully updated weak host send/receive on interface %v.\n", string(bytes))
+	} else {
+		log.Printf("[Azure CNS] Received error while enable weak host send/receive on interface. %v - %v", err.Error(), string(bytes))
+		return err
+	}
+
+	return nil
+}
+
+// Returns the arguments of the command enabling weak host send/receive on the interface with the given IP address.
+func weakHostArgs(ipAddress string) ([]string, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
 		log.Printf("[Azure CNS] Unable to retrieve interfaces on machine. %+v", err)
-		return err
+		return nil, err
 	}
 
 	var targetIface *net.Interface
@@ -58,7 +111,7 @@ func setWeakHostOnInterface(ipAddress string) error {
 	if targetIface == nil {
 		errval := "[Azerrvalure CNS] Was not able to find the interface with ip " + ipAddress + " to enable weak host send/receive"
 		log.Printf(errval)
-		return errors.New(errval)
+		return nil, errors.New(errval)
 	}
 
 	ethIndexString := strconv.Itoa(targetIface.Index)
@@ -74,34 +127,47 @@ func setWeakHostOnInterface(ipAddress string) error {
 		"/weakhostreceive",
 		"true"}
 
-	log.Printf("[Azure CNS] Going to enable weak host send/receive on interface: %v", args)
+	return args, nil
+}
+
+func createOrUpdateWithOperation(createNetworkContainerRequest cns.CreateNetworkContainerRequest, operation string) error {
+	args, err := createOrUpdateArgs(createNetworkContainerRequest, operation)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[Azure CNS] Going to create/update network loopback adapter: %v", args)
 	c := exec.Command("cmd", args...)
 	bytes, err := c.Output()
 
 	if err == nil {
-		log.Printf("[Azure CNS] Successfully updated weak host send/receive on interface %v.\n", string(bytes))
+		log.Printf("[Azure CNS] Successfully created network loopback adapter %v.\n", string(bytes))
 	} else {
-		log.Printf("[Azure CNS] Received error while enable weak host send/receive on interface. %v - %v", err.Error(), string(bytes))
-		return err
+		log.Printf("Received error while Creating a Network Container %v %v", err.Error(), string(bytes))
 	}
 
-	return nil
+	return err
 }
 
-func createOrUpdateWithOperation(createNetworkContainerRequest cns.CreateNetworkContainerRequest, operation string) error {
+// Returns the arguments of the command creating or updating the loopback adapter of a network container.
+func createOrUpdateArgs(createNetworkContainerRequest cns.CreateNetworkContainerRequest, operation string) ([]string, error) {
 	if _, err := os.Stat("./AzureNetworkContainer.exe"); err != nil {
 		if os.IsNotExist(err) {
-			return errors.New("[Azure CNS] Unable to find AzureNetworkContainer.exe. Cannot continue")
+			return nil, errors.New("[Azure CNS] Unable to find AzureNetworkContainer.exe. Cannot continue")
 		}
 	}
 
 	if createNetworkContainerRequest.IPConfiguration.IPSubnet.IPAddress == "" {
-		return errors.New("[Azure CNS] IPAddress in IPConfiguration of createNetworkContainerRequest is nil")
+		return nil, errors.New("[Azure CNS] IPAddress in IPConfiguration of createNetworkContainerRequest is nil")
 	}
 
 	ipv4AddrCidr := fmt.Sprintf("%v/%d", createNetworkContainerRequest.IPConfiguration.IPSubnet.IPAddress, createNetworkContainerRequest.IPConfiguration.IPSubnet.PrefixLength)
 	log.Printf("[Azure CNS] Created ipv4Cidr as %v", ipv4AddrCidr)
 	ipv4Addr, _, err := net.ParseCIDR(ipv4AddrCidr)
+	if err != nil {
+		return nil, err
+	}
+
 	ipv4NetInt := net.CIDRMask((int)(createNetworkContainerRequest.IPConfiguration.IPSubnet.PrefixLength), 32)
 	log.Printf("[Azure CNS] Created netmask as %v", ipv4NetInt)
 	ipv4NetStr := fmt.Sprintf("%d.%d.%d.%d", ipv4NetInt[0], ipv4NetInt[1], ipv4NetInt[2], ipv4NetInt[3])
@@ -123,17 +189,7 @@ func createOrUpdateWithOperation(createNetworkContainerRequest cns.CreateNetwork
 		"/weakhostreceive",
 		"true"}
 
-	log.Printf("[Azure CNS] Going to create/update network loopback adapter: %v", args)
-	c := exec.Command("cmd", args...)
-	bytes, err := c.Output()
-
-	if err == nil {
-		log.Printf("[Azure CNS] Successfully created network loopback adapter %v.\n", string(bytes))
-	} else {
-		log.Printf("Received error while Creating a Network Container %v %v", err.Error(), string(bytes))
-	}
-
-	return err
+	return args, nil
 }
 
 func deleteInterface(networkContainerID string) error {
@@ -166,3 +222,13 @@ func deleteInterface(networkContainerID string) error {
 	}
 	return nil
 }
+
+// Network containers on Windows are attached to separate HNS networks tagged with their VLAN,
+// which already isolates them from each other.
+func setIsolation(subnets map[string]*net.IPNet) error {
+	return nil
+}
+
+func planIsolation(subnets map[string]*net.IPNet) []cns.DataplaneOperation {
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package nodenetworkconfig
+
+import (
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Controller requests and releases the addresses of a node by updating its NodeNetworkConfig.
+type Controller struct {
+	client   Client
+	nodeName string
+}
+
+// NewController creates a new controller for the given node.
+func NewController(client Client, nodeName string) *Controller {
+	return &Controller{client: client, nodeName: nodeName}
+}
+
+// Returns the number of secondary addresses provisioned in the network containers.
+func provisionedIPCount(nnc *NodeNetworkConfig) int {
+	count := 0
+	for _, nc := range nnc.Status.NetworkContainers {
+		count += len(nc.IPAssignments)
+	}
+	return count
+}
+
+// Returns the number of addresses currently requested.
+func requestedIPCount(nnc *NodeNetworkConfig) int {
+	// Nothing was requested yet, start from what is provisioned.
+	if nnc.Spec.RequestedIPCount == 0 {
+		return provisionedIPCount(nnc)
+	}
+	return nnc.Spec.RequestedIPCount
+}
+
+// RequestIPBatch asks for additional addresses to be provisioned.
+func (c *Controller) RequestIPBatch(count int) error {
+	nnc, err := c.client.Get(c.nodeName)
+	if err != nil {
+		return err
+	}
+
+	nnc.Spec.RequestedIPCount = requestedIPCount(nnc) + count
+
+	log.Printf("[Azure CNS] Requesting %v addresses in NodeNetworkConfig %v.", nnc.Spec.RequestedIPCount, c.nodeName)
+	return c.client.Update(nnc)
+}
+
+// ReleaseIPBatch marks the given addresses as not in use so that they are removed from the node.
+func (c *Controller) ReleaseIPBatch(addresses []string) error {
+	nnc, err := c.client.Get(c.nodeName)
+	if err != nil {
+		return err
+	}
+
+	notInUse := make(map[string]bool)
+	for _, address := range nnc.Spec.IPsNotInUse {
+		notInUse[address] = true
+	}
+
+	released := 0
+	for _, address := range addresses {
+		if !notInUse[address] {
+			nnc.Spec.IPsNotInUse = append(nnc.Spec.IPsNotInUse, address)
+			notInUse[address] = true
+			released++
+		}
+	}
+
+	count := requestedIPCount(nnc) - released
+	if count < 0 {
+		count = 0
+	}
+	nnc.Spec.RequestedIPCount = count
+
+	log.Printf("[Azure CNS] Releasing %v addresses in NodeNetworkConfig %v, requesting %v.", released, c.nodeName, count)
+	return c.client.Update(nnc)
+}
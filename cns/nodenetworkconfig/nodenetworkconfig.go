@@ -0,0 +1,174 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package nodenetworkconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// NodeNetworkConfig custom resource API.
+	Group    = "acn.azure.com"
+	Version  = "v1alpha"
+	Resource = "nodenetworkconfigs"
+	Kind     = "NodeNetworkConfig"
+
+	// Namespace the NodeNetworkConfigs live in.
+	Namespace = "kube-system"
+)
+
+// NodeNetworkConfig describes the addresses requested for a node and the network containers provisioned for them.
+// It is named after the node.
+type NodeNetworkConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NodeNetworkConfigSpec   `json:"spec,omitempty"`
+	Status            NodeNetworkConfigStatus `json:"status,omitempty"`
+}
+
+// NodeNetworkConfigSpec is written by CNS to request addresses.
+type NodeNetworkConfigSpec struct {
+	// Number of secondary addresses the node should have.
+	RequestedIPCount int `json:"requestedIPCount"`
+	// Addresses CNS no longer uses, which may be removed from the node.
+	IPsNotInUse []string `json:"ipsNotInUse,omitempty"`
+}
+
+// NodeNetworkConfigStatus is written by the provisioning controller.
+type NodeNetworkConfigStatus struct {
+	NetworkContainers []NetworkContainer `json:"networkContainers,omitempty"`
+}
+
+// NetworkContainer describes a network container provisioned for the node.
+type NetworkContainer struct {
+	ID                 string         `json:"id"`
+	PrimaryIP          string         `json:"primaryIP"`
+	SubnetAddressSpace string         `json:"subnetAddressSpace"`
+	DefaultGateway     string         `json:"defaultGateway,omitempty"`
+	IPAssignments      []IPAssignment `json:"ipAssignments,omitempty"`
+	Version            int64          `json:"version"`
+}
+
+// IPAssignment is a secondary address of a network container.
+type IPAssignment struct {
+	Name      string `json:"name"`
+	IPAddress string `json:"ipAddress"`
+}
+
+// Client reads, updates and watches the NodeNetworkConfig of a node.
+type Client interface {
+	Get(name string) (*NodeNetworkConfig, error)
+	Update(nnc *NodeNetworkConfig) error
+	// Watch returns the changes of the NodeNetworkConfig made after the given resource version.
+	// The channel is closed when the watch ends, or once stop is called.
+	Watch(name string, resourceVersion string) (<-chan *NodeNetworkConfig, func(), error)
+}
+
+// watchEvent is an event of a watch of the API server.
+type watchEvent struct {
+	Type   string            `json:"type"`
+	Object NodeNetworkConfig `json:"object"`
+}
+
+// restClient accesses NodeNetworkConfigs through the Kubernetes API server.
+type restClient struct {
+	client rest.Interface
+}
+
+// NewClient creates a new client using the given REST client, which only needs to be configured with the API server.
+func NewClient(client rest.Interface) Client {
+	return &restClient{client: client}
+}
+
+// Returns the API path of the NodeNetworkConfigs.
+func collectionPath() string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, Namespace, Resource)
+}
+
+// Returns the API path of the given NodeNetworkConfig.
+func path(name string) string {
+	return collectionPath() + "/" + name
+}
+
+// Get returns the NodeNetworkConfig with the given name.
+func (c *restClient) Get(name string) (*NodeNetworkConfig, error) {
+	data, err := c.client.Get().AbsPath(path(name)).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var nnc NodeNetworkConfig
+	if err := json.Unmarshal(data, &nnc); err != nil {
+		return nil, err
+	}
+
+	return &nnc, nil
+}
+
+// Update replaces the NodeNetworkConfig. It fails if it was changed since it was read.
+func (c *restClient) Update(nnc *NodeNetworkConfig) error {
+	nnc.APIVersion = Group + "/" + Version
+	nnc.Kind = Kind
+
+	data, err := json.Marshal(nnc)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Put().AbsPath(path(nnc.Name)).Body(data).Do().Error()
+}
+
+// Watch streams the changes of the NodeNetworkConfig from the API server.
+func (c *restClient) Watch(name string, resourceVersion string) (<-chan *NodeNetworkConfig, func(), error) {
+	stream, err := c.client.Get().
+		AbsPath(collectionPath()).
+		Param("watch", "true").
+		Param("fieldSelector", "metadata.name="+name).
+		Param("resourceVersion", resourceVersion).
+		Stream()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make(chan *NodeNetworkConfig)
+	stopCh := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(stopCh)
+			stream.Close()
+		})
+	}
+
+	go func() {
+		defer close(changes)
+		defer stop()
+
+		decoder := json.NewDecoder(stream)
+		for {
+			var event watchEvent
+			if err := decoder.Decode(&event); err != nil {
+				return
+			}
+
+			// The watch of an expired resource version ends with an error event.
+			if event.Type == "ERROR" {
+				return
+			}
+
+			select {
+			case changes <- &event.Object:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	return changes, stop, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package nodenetworkconfig
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// fakeClient holds a single NodeNetworkConfig.
+type fakeClient struct {
+	nnc     NodeNetworkConfig
+	updates int
+}
+
+func (c *fakeClient) Get(name string) (*NodeNetworkConfig, error) {
+	if name != c.nnc.Name {
+		return nil, fmt.Errorf("NodeNetworkConfig %v not found", name)
+	}
+	nnc := c.nnc
+	nnc.Spec.IPsNotInUse = append([]string(nil), c.nnc.Spec.IPsNotInUse...)
+	return &nnc, nil
+}
+
+func (c *fakeClient) Update(nnc *NodeNetworkConfig) error {
+	c.nnc = *nnc
+	c.updates++
+	return nil
+}
+
+func (c *fakeClient) Watch(name string, resourceVersion string) (<-chan *NodeNetworkConfig, func(), error) {
+	return nil, nil, fmt.Errorf("Watch is not supported")
+}
+
+// fakeStore records the applied network containers.
+type fakeStore struct {
+	ncs map[string]cns.CreateNetworkContainerRequest
+}
+
+func (s *fakeStore) List() (map[string]string, error) {
+	versions := make(map[string]string)
+	for id, req := range s.ncs {
+		versions[id] = req.Version
+	}
+	return versions, nil
+}
+
+func (s *fakeStore) CreateOrUpdate(req cns.CreateNetworkContainerRequest) error {
+	s.ncs[req.NetworkContainerid] = req
+	return nil
+}
+
+func (s *fakeStore) Delete(networkContainerID string) error {
+	delete(s.ncs, networkContainerID)
+	return nil
+}
+
+func newNetworkContainer(id string, version int64, addresses ...string) NetworkContainer {
+	nc := NetworkContainer{
+		ID:                 id,
+		PrimaryIP:          "10.0.0.4",
+		SubnetAddressSpace: "10.0.0.0/16",
+		DefaultGateway:     "10.0.0.1",
+		Version:            version,
+	}
+	for i, address := range addresses {
+		nc.IPAssignments = append(nc.IPAssignments, IPAssignment{Name: fmt.Sprintf("ip-%d", i), IPAddress: address})
+	}
+	return nc
+}
+
+// Tests that requests and releases update the spec.
+func TestController(t *testing.T) {
+	client := &fakeClient{}
+	client.nnc.Name = "node"
+	client.nnc.Status.NetworkContainers = []NetworkContainer{newNetworkContainer("nc1", 1, "10.0.0.5", "10.0.0.6")}
+
+	c := NewController(client, "node")
+
+	if err := c.RequestIPBatch(10); err != nil || client.nnc.Spec.RequestedIPCount != 12 {
+		t.Errorf("Unexpected requested count %v, err:%v", client.nnc.Spec.RequestedIPCount, err)
+	}
+
+	if err := c.ReleaseIPBatch([]string{"10.0.0.5", "10.0.0.5"}); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+
+	if client.nnc.Spec.RequestedIPCount != 11 || len(client.nnc.Spec.IPsNotInUse) != 1 {
+		t.Errorf("Unexpected spec after release %+v", client.nnc.Spec)
+	}
+
+	if err := NewController(client, "other").RequestIPBatch(10); err == nil {
+		t.Errorf("Missing NodeNetworkConfig was not reported")
+	}
+}
+
+// Tests that network containers follow the status and released addresses are pruned.
+func TestReconciler(t *testing.T) {
+	client := &fakeClient{}
+	client.nnc.Name = "node"
+	client.nnc.Spec.IPsNotInUse = []string{"10.0.0.6", "10.0.0.9"}
+	client.nnc.Status.NetworkContainers = []NetworkContainer{newNetworkContainer("nc1", 1, "10.0.0.5", "10.0.0.6")}
+
+	store := &fakeStore{ncs: map[string]cns.CreateNetworkContainerRequest{
+		"stale": {NetworkContainerid: "stale", Version: "1"},
+	}}
+
+	r := NewReconciler(client, "node", store)
+	if _, err := r.Reconcile(); err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+
+	req, ok := store.ncs["nc1"]
+	if !ok || len(store.ncs) != 1 {
+		t.Fatalf("Unexpected network containers %+v", store.ncs)
+	}
+
+	if req.NetworkContainerType != cns.Docker || req.IPConfiguration.IPSubnet.PrefixLength != 16 ||
+		len(req.SecondaryIPAddresses) != 2 || req.Version != "1" {
+		t.Errorf("Unexpected network container request %+v", req)
+	}
+
+	if len(client.nnc.Spec.IPsNotInUse) != 1 || client.nnc.Spec.IPsNotInUse[0] != "10.0.0.6" {
+		t.Errorf("Unprovisioned address was not pruned, spec:%+v", client.nnc.Spec)
+	}
+
+	// Unchanged network containers are not applied again.
+	store.ncs["nc1"] = cns.CreateNetworkContainerRequest{NetworkContainerid: "nc1", Version: "marker"}
+	if _, err := r.Reconcile(); err != nil || store.ncs["nc1"].Version != "marker" {
+		t.Errorf("Unchanged network container was applied again, err:%v", err)
+	}
+
+	// New versions are applied.
+	client.nnc.Status.NetworkContainers = []NetworkContainer{newNetworkContainer("nc1", 2, "10.0.0.5")}
+	if _, err := r.Reconcile(); err != nil || store.ncs["nc1"].Version != "2" {
+		t.Errorf("Updated network container was not applied, err:%v", err)
+	}
+}
+
+// Tests that the watch streams the changes of the NodeNetworkConfig and ends with an error event.
+func TestWatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != collectionPath() || query.Get("watch") != "true" ||
+			query.Get("fieldSelector") != "metadata.name=node" || query.Get("resourceVersion") != "5" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintln(w, `{"type":"MODIFIED","object":{"metadata":{"name":"node","resourceVersion":"6"},"spec":{"requestedIPCount":3}}}`)
+		fmt.Fprintln(w, `{"type":"ERROR","object":{}}`)
+		fmt.Fprintln(w, `{"type":"MODIFIED","object":{"metadata":{"name":"node","resourceVersion":"7"}}}`)
+	}))
+	defer server.Close()
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create clientset: %v", err)
+	}
+
+	changes, stop, err := NewClient(clientset.Discovery().RESTClient()).Watch("node", "5")
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer stop()
+
+	var versions []string
+	for nnc := range changes {
+		versions = append(versions, nnc.ResourceVersion)
+		if nnc.Spec.RequestedIPCount != 3 {
+			t.Errorf("Unexpected change %+v", nnc)
+		}
+	}
+
+	if len(versions) != 1 || versions[0] != "6" {
+		t.Errorf("Watched versions %v, expected [6]", versions)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package nodenetworkconfig
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// NetworkContainerStore holds the network containers applied to the node.
+type NetworkContainerStore interface {
+	// List returns the versions of the network containers created from NodeNetworkConfigs, by network container ID.
+	List() (map[string]string, error)
+	CreateOrUpdate(req cns.CreateNetworkContainerRequest) error
+	Delete(networkContainerID string) error
+}
+
+// Reconciler applies the network containers provisioned in the NodeNetworkConfig of a node.
+type Reconciler struct {
+	client   Client
+	nodeName string
+	store    NetworkContainerStore
+	applied  map[string]string // Network container ID is key, version is value.
+}
+
+// NewReconciler creates a new reconciler for the given node.
+func NewReconciler(client Client, nodeName string, store NetworkContainerStore) *Reconciler {
+	return &Reconciler{
+		client:   client,
+		nodeName: nodeName,
+		store:    store,
+	}
+}
+
+// Reconcile creates, updates and deletes network containers to match the NodeNetworkConfig status,
+// and drops released addresses that are no longer provisioned from the spec.
+// It returns the resource version of the NodeNetworkConfig it reconciled, from which it can be watched.
+func (r *Reconciler) Reconcile() (string, error) {
+	if r.applied == nil {
+		applied, err := r.store.List()
+		if err != nil {
+			return "", err
+		}
+		r.applied = applied
+	}
+
+	nnc, err := r.client.Get(r.nodeName)
+	if err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	provisioned := make(map[string]bool)
+	current := make(map[string]bool)
+
+	for _, nc := range nnc.Status.NetworkContainers {
+		current[nc.ID] = true
+		for _, assignment := range nc.IPAssignments {
+			provisioned[assignment.IPAddress] = true
+		}
+
+		version := strconv.FormatInt(nc.Version, 10)
+		if applied, ok := r.applied[nc.ID]; ok && applied == version {
+			continue
+		}
+
+		req, err := newNetworkContainerRequest(&nc)
+		if err == nil {
+			err = r.store.CreateOrUpdate(req)
+		}
+
+		if err != nil {
+			log.Printf("[Azure CNS] Failed to apply network container %v version %v, err:%v.", nc.ID, version, err)
+			lastErr = err
+			continue
+		}
+
+		log.Printf("[Azure CNS] Applied network container %v version %v.", nc.ID, version)
+		r.applied[nc.ID] = version
+	}
+
+	for id := range r.applied {
+		if current[id] {
+			continue
+		}
+
+		if err := r.store.Delete(id); err != nil {
+			log.Printf("[Azure CNS] Failed to delete network container %v, err:%v.", id, err)
+			lastErr = err
+			continue
+		}
+
+		log.Printf("[Azure CNS] Deleted network container %v.", id)
+		delete(r.applied, id)
+	}
+
+	// Addresses removed from the node no longer need to be reported as not in use.
+	var notInUse []string
+	for _, address := range nnc.Spec.IPsNotInUse {
+		if provisioned[address] {
+			notInUse = append(notInUse, address)
+		}
+	}
+
+	if len(notInUse) != len(nnc.Spec.IPsNotInUse) {
+		nnc.Spec.IPsNotInUse = notInUse
+		if err := r.client.Update(nnc); err != nil {
+			lastErr = err
+		}
+	}
+
+	return nnc.ResourceVersion, lastErr
+}
+
+// Converts a provisioned network container to a CNS network container request.
+func newNetworkContainerRequest(nc *NetworkContainer) (cns.CreateNetworkContainerRequest, error) {
+	_, subnet, err := net.ParseCIDR(nc.SubnetAddressSpace)
+	if err != nil {
+		return cns.CreateNetworkContainerRequest{}, fmt.Errorf("Invalid subnet address space %v", nc.SubnetAddressSpace)
+	}
+
+	prefixLength, _ := subnet.Mask.Size()
+
+	req := cns.CreateNetworkContainerRequest{
+		Version:                    strconv.FormatInt(nc.Version, 10),
+		NetworkContainerType:       cns.Docker,
+		NetworkContainerid:         nc.ID,
+		PrimaryInterfaceIdentifier: nc.PrimaryIP,
+		IPConfiguration: cns.IPConfiguration{
+			IPSubnet: cns.IPSubnet{
+				IPAddress:    nc.PrimaryIP,
+				PrefixLength: uint8(prefixLength),
+			},
+			GatewayIPAddress: nc.DefaultGateway,
+		},
+	}
+
+	for _, assignment := range nc.IPAssignments {
+		req.SecondaryIPAddresses = append(req.SecondaryIPAddresses, assignment.IPAddress)
+	}
+
+	return req, nil
+}
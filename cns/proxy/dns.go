@@ -0,0 +1,113 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package proxy
+
+import (
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Azure DNS address.
+	azureDNSAddress = "168.63.129.16:53"
+
+	// Largest DNS message over UDP with EDNS.
+	maxDNSMessageSize = 4096
+
+	// Timeout for queries forwarded to the upstream server.
+	dnsQueryTimeout = 5 * time.Second
+)
+
+// DNSProxy forwards DNS queries over UDP from network containers to Azure DNS.
+type DNSProxy struct {
+	Upstream string
+	Validate SourceValidator
+	conn     *net.UDPConn
+}
+
+// NewDNSProxy creates a new DNS proxy forwarding to Azure DNS.
+func NewDNSProxy(validate SourceValidator) *DNSProxy {
+	return &DNSProxy{
+		Upstream: azureDNSAddress,
+		Validate: validate,
+	}
+}
+
+// Start starts serving the proxy on the given address.
+func (p *DNSProxy) Start(address string) error {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	p.conn = conn
+	go p.serve(conn)
+
+	log.Printf("[Azure CNS] DNS proxy listening on %v.", conn.LocalAddr())
+	return nil
+}
+
+// Stop stops serving the proxy.
+func (p *DNSProxy) Stop() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// Addr returns the address the proxy is listening on.
+func (p *DNSProxy) Addr() net.Addr {
+	return p.conn.LocalAddr()
+}
+
+// Reads queries until the connection is closed.
+func (p *DNSProxy) serve(conn *net.UDPConn) {
+	for {
+		buf := make([]byte, maxDNSMessageSize)
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		if p.Validate == nil || !p.Validate(src.IP) {
+			log.Printf("[Azure CNS] DNS proxy rejected query from unknown source %v.", src)
+			continue
+		}
+
+		go p.forward(conn, src, buf[:n])
+	}
+}
+
+// Forwards a query to the upstream server and relays the answer back to the client.
+func (p *DNSProxy) forward(conn *net.UDPConn, src *net.UDPAddr, query []byte) {
+	upstream, err := net.Dial("udp", p.Upstream)
+	if err != nil {
+		log.Printf("[Azure CNS] DNS proxy failed to reach %v, err:%v.", p.Upstream, err)
+		return
+	}
+	defer upstream.Close()
+
+	upstream.SetDeadline(time.Now().Add(dnsQueryTimeout))
+
+	if _, err := upstream.Write(query); err != nil {
+		log.Printf("[Azure CNS] DNS proxy failed to forward query, err:%v.", err)
+		return
+	}
+
+	answer := make([]byte, maxDNSMessageSize)
+	n, err := upstream.Read(answer)
+	if err != nil {
+		log.Printf("[Azure CNS] DNS proxy failed to read answer, err:%v.", err)
+		return
+	}
+
+	conn.WriteToUDP(answer[:n], src)
+}
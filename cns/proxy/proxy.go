@@ -0,0 +1,251 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Names of the endpoints reachable through the proxy.
+	TargetIMDS       = "imds"
+	TargetWireserver = "wireserver"
+
+	// Default endpoint addresses.
+	imdsURL       = "http://169.254.169.254"
+	wireserverURL = "http://168.63.129.16"
+
+	// Timeout for requests forwarded to an endpoint.
+	requestTimeout = 30 * time.Second
+
+	// Scope of the pod tokens allowing requests to restricted wireserver APIs.
+	TokenScopeWireserver = "wireserver"
+
+	// Header carrying the token issued to the pod by CNS. It is not forwarded.
+	PodTokenHeader = "X-Ms-Azure-Cns-Pod-Token"
+)
+
+// SourceValidator returns whether a client address is allowed to use the proxy.
+type SourceValidator func(ip net.IP) bool
+
+// TokenValidator returns whether a token was issued for a scope to the pod with the client address.
+type TokenValidator func(ip net.IP, scope string, token string) bool
+
+// Rule allows requests with the given method, path and query parameters to be forwarded to a target.
+// Paths are matched exactly, after they are cleaned.
+// Rules with a scope only allow the requests of pods presenting a token issued for the scope.
+type Rule struct {
+	Target string
+	Method string
+	Path   string
+	Query  map[string]string
+	Scope  string
+}
+
+// DefaultRules allow read-only access to the instance metadata and the wireserver version list,
+// and to the interface information of the NMAgent plugin for the pods holding a wireserver token.
+var DefaultRules = []Rule{
+	{Target: TargetIMDS, Method: "GET", Path: "/metadata/instance"},
+	{Target: TargetIMDS, Method: "GET", Path: "/metadata/versions"},
+	{Target: TargetWireserver, Method: "GET", Path: "/", Query: map[string]string{"comp": "versions"}},
+	{
+		Target: TargetWireserver,
+		Method: "GET",
+		Path:   "/machine/plugins",
+		Query:  map[string]string{"comp": "nmagent", "type": "getinterfaceinfov1"},
+		Scope:  TokenScopeWireserver,
+	},
+}
+
+// Headers forwarded to the endpoints. Anything else, including X-Forwarded-For, is dropped
+// since IMDS rejects proxied requests carrying it.
+var forwardedHeaders = []string{"Accept", "Content-Type", "Metadata", "X-Ms-Version"}
+
+// HTTPProxy forwards HTTP requests from network containers to IMDS and wireserver.
+// Requests take the form /<target>/<path>, for example /imds/metadata/instance.
+type HTTPProxy struct {
+	Targets       map[string]string
+	Rules         []Rule
+	Validate      SourceValidator
+	ValidateToken TokenValidator
+	client        *http.Client
+	listener      net.Listener
+	server        *http.Server
+}
+
+// NewHTTPProxy creates a new HTTP proxy with the default targets and rules.
+func NewHTTPProxy(validate SourceValidator) *HTTPProxy {
+	return &HTTPProxy{
+		Targets: map[string]string{
+			TargetIMDS:       imdsURL,
+			TargetWireserver: wireserverURL,
+		},
+		Rules:    DefaultRules,
+		Validate: validate,
+		client:   &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Start starts serving the proxy on the given address.
+func (p *HTTPProxy) Start(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	p.listener = l
+	p.server = &http.Server{Handler: p}
+
+	go func() {
+		if err := p.server.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Errorf("[Azure CNS] HTTP proxy stopped, err:%v.", err)
+		}
+	}()
+
+	log.Printf("[Azure CNS] HTTP proxy listening on %v.", l.Addr())
+	return nil
+}
+
+// Stop stops serving the proxy.
+func (p *HTTPProxy) Stop() {
+	if p.server != nil {
+		p.server.Close()
+		p.server = nil
+	}
+}
+
+// Addr returns the address the proxy is listening on.
+func (p *HTTPProxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// ServeHTTP validates and forwards a request.
+func (p *HTTPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	ip := net.ParseIP(host)
+	if err != nil || ip == nil || p.Validate == nil || !p.Validate(ip) {
+		log.Printf("[Azure CNS] HTTP proxy rejected request from unknown source %v.", r.RemoteAddr)
+		http.Error(w, "Source is not allowed", http.StatusForbidden)
+		return
+	}
+
+	requestPath, ok := getRequestPath(r)
+	if !ok {
+		log.Printf("[Azure CNS] HTTP proxy rejected path %v from %v.", r.URL.EscapedPath(), ip)
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	// Split the target name from the endpoint path.
+	parts := strings.SplitN(strings.TrimPrefix(requestPath, "/"), "/", 2)
+	target := parts[0]
+	path := "/"
+	if len(parts) > 1 {
+		path += parts[1]
+	}
+
+	baseURL, ok := p.Targets[target]
+	if !ok {
+		http.Error(w, "Unknown target", http.StatusNotFound)
+		return
+	}
+
+	if !p.isAllowed(ip, target, r.Method, path, r) {
+		log.Printf("[Azure CNS] HTTP proxy rejected %v %v from %v.", r.Method, r.URL, ip)
+		http.Error(w, "Request is not allowed", http.StatusForbidden)
+		return
+	}
+
+	url := baseURL + path
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequest(r.Method, url, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, name := range forwardedHeaders {
+		if value := r.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.Printf("[Azure CNS] HTTP proxy failed to forward request to %v, err:%v.", target, err)
+		http.Error(w, fmt.Sprintf("Failed to reach %v", target), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// Returns the cleaned path of a request, or false if the path has dot segments or encoded separators,
+// which could make the endpoint resolve a path other than the one matched by the rules.
+func getRequestPath(r *http.Request) (string, bool) {
+	escaped := strings.ToLower(r.URL.EscapedPath())
+	for _, s := range []string{"%2e", "%2f", "%5c", "\\"} {
+		if strings.Contains(escaped, s) {
+			return "", false
+		}
+	}
+
+	for _, segment := range strings.Split(r.URL.Path, "/") {
+		if segment == "." || segment == ".." {
+			return "", false
+		}
+	}
+
+	return path.Clean("/" + r.URL.Path), true
+}
+
+// Returns whether a rule allows the request.
+func (p *HTTPProxy) isAllowed(ip net.IP, target string, method string, path string, r *http.Request) bool {
+	query := r.URL.Query()
+	token := r.Header.Get(PodTokenHeader)
+
+	for _, rule := range p.Rules {
+		if rule.Target != target || rule.Method != method || path != rule.Path {
+			continue
+		}
+
+		if rule.Scope != "" && (token == "" || p.ValidateToken == nil || !p.ValidateToken(ip, rule.Scope, token)) {
+			continue
+		}
+
+		// Parameters of the rule given more than once are rejected, since the endpoint may read another value.
+		match := true
+		for k, v := range rule.Query {
+			if len(query[k]) != 1 || query.Get(k) != v {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
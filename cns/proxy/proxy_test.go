@@ -0,0 +1,212 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package proxy
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func allowAll(ip net.IP) bool {
+	return true
+}
+
+func denyAll(ip net.IP) bool {
+	return false
+}
+
+// Creates a proxy with both targets pointing at a test server echoing the request.
+func newTestProxy(t *testing.T, validate SourceValidator) (*HTTPProxy, func()) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Forwarded-For") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(r.URL.String() + " " + r.Header.Get("Metadata")))
+	}))
+
+	p := NewHTTPProxy(validate)
+	p.Targets[TargetIMDS] = endpoint.URL
+	p.Targets[TargetWireserver] = endpoint.URL
+
+	if err := p.Start("127.0.0.1:0"); err != nil {
+		endpoint.Close()
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+
+	return p, func() {
+		p.Stop()
+		endpoint.Close()
+	}
+}
+
+func get(t *testing.T, p *HTTPProxy, path string) (int, string) {
+	req, _ := http.NewRequest("GET", "http://"+p.Addr().String()+path, nil)
+	req.Header.Set("Metadata", "true")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request to proxy failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// Tests that allowed requests are forwarded to their target.
+func TestHTTPProxyForwardsAllowedRequests(t *testing.T) {
+	p, cleanup := newTestProxy(t, allowAll)
+	defer cleanup()
+
+	code, body := get(t, p, "/imds/metadata/instance?api-version=2017-08-01")
+	if code != http.StatusOK || body != "/metadata/instance?api-version=2017-08-01 true" {
+		t.Errorf("Unexpected IMDS response %v %v", code, body)
+	}
+
+	code, body = get(t, p, "/wireserver/?comp=versions")
+	if code != http.StatusOK || body != "/?comp=versions true" {
+		t.Errorf("Unexpected wireserver response %v %v", code, body)
+	}
+}
+
+// Tests that requests not matching a rule are rejected.
+func TestHTTPProxyFiltersRequests(t *testing.T) {
+	p, cleanup := newTestProxy(t, allowAll)
+	defer cleanup()
+
+	for _, path := range []string{
+		"/imds/metadata/identity/oauth2/token",
+		"/wireserver/machine?comp=goalstate",
+		"/unknown/metadata/instance",
+	} {
+		if code, _ := get(t, p, path); code == http.StatusOK {
+			t.Errorf("Request to %v was not rejected", path)
+		}
+	}
+}
+
+// Tests that paths escaping an allowed path through dot segments or encoded separators are rejected, and that
+// paths only starting with an allowed path are not forwarded.
+func TestHTTPProxyRejectsPathTraversal(t *testing.T) {
+	p, cleanup := newTestProxy(t, allowAll)
+	defer cleanup()
+
+	for _, path := range []string{
+		"/imds/metadata/instance/../identity/oauth2/token",
+		"/imds/metadata/instance/./../identity/oauth2/token",
+		"/imds/metadata/instance/%2e%2e/identity/oauth2/token",
+		"/imds/metadata/instance/%2E%2E/identity/oauth2/token",
+		"/imds/metadata/instance%2f..%2fidentity/oauth2/token",
+		"/imds/metadata/instance/..%5cidentity/oauth2/token",
+		"/imds/metadata/instance/identity/oauth2/token",
+		"/imds/metadata/instancefoo",
+		"/wireserver/machine?comp=versions",
+		"/wireserver/../machine?comp=versions",
+	} {
+		if code, body := get(t, p, path); code == http.StatusOK {
+			t.Errorf("Request to %v was not rejected: %v", path, body)
+		}
+	}
+
+	// Redundant slashes are cleaned before the path is matched and forwarded.
+	code, body := get(t, p, "/imds//metadata/instance?api-version=2017-08-01")
+	if code != http.StatusOK || body != "/metadata/instance?api-version=2017-08-01 true" {
+		t.Errorf("Unexpected IMDS response %v %v", code, body)
+	}
+}
+
+// Tests that requests from unknown sources are rejected.
+func TestHTTPProxyValidatesSource(t *testing.T) {
+	p, cleanup := newTestProxy(t, denyAll)
+	defer cleanup()
+
+	if code, _ := get(t, p, "/imds/metadata/instance"); code != http.StatusForbidden {
+		t.Errorf("Request from unknown source returned %v", code)
+	}
+}
+
+// Tests that scoped requests are only forwarded with a token issued for the scope.
+func TestHTTPProxyValidatesPodToken(t *testing.T) {
+	p, cleanup := newTestProxy(t, allowAll)
+	defer cleanup()
+
+	p.ValidateToken = func(ip net.IP, scope string, token string) bool {
+		return ip.IsLoopback() && scope == TokenScopeWireserver && token == "secret"
+	}
+
+	interfaceInfo := "/wireserver/machine/plugins?comp=nmagent&type=getinterfaceinfov1"
+	testCases := []struct {
+		path     string
+		token    string
+		expected int
+	}{
+		{interfaceInfo, "", http.StatusForbidden},
+		{interfaceInfo, "wrong", http.StatusForbidden},
+		{interfaceInfo, "secret", http.StatusOK},
+		// The token only allows reading the interface information.
+		{"/wireserver/machine/plugins?comp=nmagent&type=NetworkManagement/joinedVirtualNetworks", "secret", http.StatusForbidden},
+		{interfaceInfo + "&type=NetworkManagement/joinedVirtualNetworks", "secret", http.StatusForbidden},
+		{"/wireserver/machine/plugins/other?comp=nmagent&type=getinterfaceinfov1", "secret", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest("GET", "http://"+p.Addr().String()+tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set(PodTokenHeader, tc.token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request to proxy failed: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.expected {
+			t.Errorf("Request to %v with token %q returned %v, expected %v", tc.path, tc.token, resp.StatusCode, tc.expected)
+		}
+	}
+}
+
+// Tests that DNS queries are relayed to the upstream server and back.
+func TestDNSProxyRelaysQueries(t *testing.T) {
+	upstream, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("Failed to start upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	go func() {
+		buf := make([]byte, maxDNSMessageSize)
+		n, src, err := upstream.ReadFromUDP(buf)
+		if err == nil {
+			upstream.WriteToUDP(append([]byte("answer:"), buf[:n]...), src)
+		}
+	}()
+
+	p := NewDNSProxy(allowAll)
+	p.Upstream = upstream.LocalAddr().String()
+	if err := p.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+	defer p.Stop()
+
+	conn, err := net.Dial("udp", p.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("query"))
+
+	buf := make([]byte, maxDNSMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "answer:query" {
+		t.Errorf("Unexpected answer %q, err:%v", buf[:n], err)
+	}
+}
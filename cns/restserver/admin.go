@@ -0,0 +1,292 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	runtimepprof "runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Duration of an execution trace if none is requested, and the longest one.
+	defaultTraceDuration = 5 * time.Second
+	maxTraceDuration     = 60 * time.Second
+
+	// Longest time log levels can be changed for before being restored.
+	maxLogLevelDuration = 24 * time.Hour
+)
+
+var (
+	errAdminNotLocal = fmt.Errorf("The admin API only listens on unix sockets and loopback addresses")
+)
+
+// Log levels changed for a while through the admin API.
+type logLevelOverride struct {
+	previous   string
+	restoredAt time.Time
+	timer      *time.Timer
+}
+
+// Returns whether a listener URL only accepts connections from the node itself.
+func isLocalURL(u *url.URL) bool {
+	switch u.Scheme {
+	case "unix":
+		return true
+
+	case "tcp", "tcp4", "tcp6":
+		if u.Hostname() == "localhost" {
+			return true
+		}
+
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	}
+
+	return false
+}
+
+// Rejects requests that do not come from the node itself, in case the admin listener is reached through a proxy
+// or a port forward.
+func authenticateAdminRequest(r *http.Request) error {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Peers of unix sockets have no address.
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return errAdminNotLocal
+	}
+
+	return nil
+}
+
+// Starts the admin API if an admin URL is configured. It controls logging and profiling and exports the node state,
+// and is only served locally so that it needs no other authentication.
+func (service *HTTPRestService) startAdminServer() error {
+	urls, _ := service.GetOption(acn.OptCnsAdminURL).(string)
+	if urls == "" {
+		return nil
+	}
+
+	u, err := url.Parse(urls)
+	if err != nil {
+		return err
+	}
+
+	if !isLocalURL(u) {
+		return errAdminNotLocal
+	}
+
+	listener, err := acn.NewListener(u)
+	if err != nil {
+		return err
+	}
+
+	listener.SetAuthenticator(authenticateAdminRequest)
+
+	listener.AddHandler(cns.AdminLogLevelPath, service.logLevel)
+	listener.AddHandler(cns.AdminTracePath, service.captureTrace)
+	listener.AddHandler(cns.AdminGoroutinesPath, service.dumpGoroutines)
+	listener.AddHandler(cns.ExportStatePath, service.exportState)
+	registerProfilerHandlers(listener)
+
+	localAddress := u.Host + u.Path
+	if u.Scheme == "unix" {
+		os.Remove(localAddress)
+	}
+
+	// The admin server stopping does not stop CNS.
+	errChan := make(chan error, 1)
+	if err = listener.Start(errChan); err != nil {
+		return err
+	}
+
+	if u.Scheme == "unix" {
+		if err = os.Chmod(localAddress, 0600); err != nil {
+			listener.Stop()
+			return err
+		}
+	}
+
+	service.adminListener = listener
+
+	log.Printf("[Azure CNS] Admin API listening on %v.", urls)
+	return nil
+}
+
+// Stops the admin API if it is running.
+func (service *HTTPRestService) stopAdminServer() {
+	if service.adminListener != nil {
+		service.adminListener.Stop()
+		service.adminListener = nil
+	}
+
+	service.adminLock.Lock()
+	if service.logLevelOverride != nil {
+		service.logLevelOverride.timer.Stop()
+		service.logLevelOverride = nil
+	}
+	service.adminLock.Unlock()
+}
+
+// Handles requests to get or change the log levels.
+func (service *HTTPRestService) logLevel(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] logLevel")
+
+	var req cns.SetLogLevelRequest
+	var resp cns.LogLevelResponse
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		err := service.Listener.Decode(w, r, &req)
+		log.Request(service.Name, &req, err)
+
+		if err != nil {
+			return
+		}
+
+		if err = service.setLogLevel(req.Level, time.Duration(req.DurationSeconds)*time.Second); err != nil {
+			resp.Response.ReturnCode = InvalidParameter
+			resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. %v", err)
+		}
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. LogLevel did not receive a GET or POST."
+	}
+
+	service.adminLock.Lock()
+	resp.Level = log.GetLevels()
+	if service.logLevelOverride != nil {
+		restoredAt := service.logLevelOverride.restoredAt
+		resp.RestoredAt = &restoredAt
+	}
+	service.adminLock.Unlock()
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Changes the log levels, for the given duration if it is not 0. Levels changed for a while are restored to
+// those before the first change, even if they are changed again in between.
+func (service *HTTPRestService) setLogLevel(levels string, duration time.Duration) error {
+	if duration < 0 || duration > maxLogLevelDuration {
+		return fmt.Errorf("Invalid log level duration %v, the longest is %v", duration, maxLogLevelDuration)
+	}
+
+	service.adminLock.Lock()
+	defer service.adminLock.Unlock()
+
+	previous := log.GetLevels()
+	if err := log.SetLevels(levels); err != nil {
+		return err
+	}
+
+	override := service.logLevelOverride
+	if override != nil {
+		override.timer.Stop()
+		previous = override.previous
+		service.logLevelOverride = nil
+	}
+
+	log.Printf("[Azure CNS] Set log levels to %v for %v.", log.GetLevels(), duration)
+
+	if duration == 0 {
+		return nil
+	}
+
+	override = &logLevelOverride{
+		previous:   previous,
+		restoredAt: service.clock.Now().Add(duration),
+	}
+	override.timer = time.AfterFunc(duration, func() {
+		service.adminLock.Lock()
+		defer service.adminLock.Unlock()
+
+		if service.logLevelOverride != override {
+			return
+		}
+
+		service.logLevelOverride = nil
+		if err := log.SetLevels(override.previous); err != nil {
+			log.Errorf("[Azure CNS] Failed to restore log levels %v, err:%v.", override.previous, err)
+			return
+		}
+		log.Printf("[Azure CNS] Restored log levels to %v.", override.previous)
+	})
+	service.logLevelOverride = override
+
+	return nil
+}
+
+// Handles requests to capture an execution trace for the number of seconds in the query, e.g. ?seconds=10.
+func (service *HTTPRestService) captureTrace(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] captureTrace")
+
+	if r.Method != "GET" {
+		http.Error(w, "CaptureTrace did not receive a GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	duration := defaultTraceDuration
+	if seconds := r.URL.Query().Get("seconds"); seconds != "" {
+		n, err := strconv.Atoi(seconds)
+		if err != nil || n <= 0 || time.Duration(n)*time.Second > maxTraceDuration {
+			http.Error(w, fmt.Sprintf("Invalid trace duration %v, the longest is %v", seconds, maxTraceDuration), http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(n) * time.Second
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="cns.trace"`)
+
+	// Only one trace can be captured at a time.
+	if err := trace.Start(w); err != nil {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, fmt.Sprintf("Failed to start trace: %v", err), http.StatusConflict)
+		return
+	}
+
+	select {
+	case <-time.After(duration):
+	case <-r.Context().Done():
+	}
+
+	trace.Stop()
+	log.Printf("[Azure CNS] Captured execution trace for %v.", duration)
+}
+
+// Handles requests to dump the stacks of all goroutines. The query ?debug=1 groups identical stacks together.
+func (service *HTTPRestService) dumpGoroutines(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] dumpGoroutines")
+
+	if r.Method != "GET" {
+		http.Error(w, "DumpGoroutines did not receive a GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	debug := 2
+	if r.URL.Query().Get("debug") == "1" {
+		debug = 1
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := runtimepprof.Lookup("goroutine").WriteTo(w, debug); err != nil {
+		log.Errorf("[Azure CNS] Failed to dump goroutines, err:%v.", err)
+	}
+}
@@ -20,6 +20,11 @@ const (
 	CallToHostFailed             = 17
 	UnknownContainerID           = 18
 	UnsupportedOrchestratorType  = 19
+	ReadOnlyReplica              = 20
+	NodeDraining                 = 21
+	NetworkContainerNotUpdatable = 22
+	CNIExecutionNotEnabled       = 23
+	NetworkContainerVersionStale = 24
 	UnexpectedError              = 99
 )
 
@@ -53,6 +58,16 @@ func ReturnCodeToString(returnCode int) (s string) {
 		s = "UnknownContainerID"
 	case UnsupportedOrchestratorType:
 		s = "UnsupportedOrchestratorType"
+	case ReadOnlyReplica:
+		s = "ReadOnlyReplica"
+	case NodeDraining:
+		s = "NodeDraining"
+	case NetworkContainerNotUpdatable:
+		s = "NetworkContainerNotUpdatable"
+	case CNIExecutionNotEnabled:
+		s = "CNIExecutionNotEnabled"
+	case NetworkContainerVersionStale:
+		s = "NetworkContainerVersionStale"
 	case UnexpectedError:
 		s = "UnexpectedError"
 	default:
@@ -0,0 +1,383 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/google/uuid"
+)
+
+// Wraps a handler with behavior shared by the routes of the API.
+type middleware func(http.HandlerFunc) http.HandlerFunc
+
+// Route of the API, served on its path in all versions.
+type apiRoute struct {
+	path      string
+	handler   http.HandlerFunc
+	ownerOnly bool // Whether the route changes the state, and is rejected by read-only replicas.
+}
+
+// Key of the request ID in the context of requests.
+type requestIDKey struct{}
+
+// Returns the routes of the API.
+func (service *HTTPRestService) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{path: cns.SetEnvironmentPath, handler: service.setEnvironment, ownerOnly: true},
+		{path: cns.CreateNetworkPath, handler: service.createNetwork, ownerOnly: true},
+		{path: cns.DeleteNetworkPath, handler: service.deleteNetwork, ownerOnly: true},
+		{path: cns.ReserveIPAddressPath, handler: service.reserveIPAddress, ownerOnly: true},
+		{path: cns.ReleaseIPAddressPath, handler: service.releaseIPAddress, ownerOnly: true},
+		{path: cns.BatchReserveIPAddressPath, handler: service.batchReserveIPAddress, ownerOnly: true},
+		{path: cns.BatchReleaseIPAddressPath, handler: service.batchReleaseIPAddress, ownerOnly: true},
+		{path: cns.GetHostLocalIPPath, handler: service.getHostLocalIP},
+		{path: cns.GetIPAddressUtilizationPath, handler: service.getIPAddressUtilization},
+		{path: cns.GetUnhealthyIPAddressesPath, handler: service.getUnhealthyIPAddresses},
+		{path: cns.CreateOrUpdateNetworkContainer, handler: service.createOrUpdateNetworkContainer, ownerOnly: true},
+		{path: cns.DeleteNetworkContainer, handler: service.deleteNetworkContainer, ownerOnly: true},
+		{path: cns.GetNetworkContainerStatus, handler: service.getNetworkContainerStatus},
+		{path: cns.GetInterfaceForContainer, handler: service.getInterfaceForContainer},
+		{path: cns.SetOrchestratorType, handler: service.setOrchestratorType, ownerOnly: true},
+		{path: cns.GetNetworkContainerByOrchestratorContext, handler: service.getNetworkContainerByOrchestratorContext},
+		{path: cns.GetNetworkContainerVersion, handler: service.getNetworkContainerVersion},
+		{path: cns.UpdateNetworkContainer, handler: service.updateNetworkContainer, ownerOnly: true},
+		{path: cns.GetOperationPath, handler: service.getOperation},
+		{path: cns.GetIPPoolStatePath, handler: service.getIPPoolState},
+		{path: cns.GetHeartbeatStatePath, handler: service.getHeartbeatState},
+		{path: cns.GetHealthReportPath, handler: service.getHealthReport},
+		{path: cns.ReportPodNetworkFailurePath, handler: service.reportPodNetworkFailure},
+		{path: cns.GetPodAnnotationsPath, handler: service.getPodAnnotations},
+		{path: cns.IssuePodTokenPath, handler: service.issuePodToken, ownerOnly: true},
+		{path: cns.RevokePodTokensPath, handler: service.revokePodTokens, ownerOnly: true},
+		{path: cns.GetOverlayRoutesPath, handler: service.getOverlayRoutes},
+		{path: cns.GetInterfacesPath, handler: service.getInterfaces},
+		{path: cns.ExecuteCNIPath, handler: service.executeCNI, ownerOnly: true},
+		{path: cns.GetDebugStatePath, handler: service.getDebugState},
+		{path: cns.GetDebugIPAMPath, handler: service.getDebugIPAM},
+		{path: cns.GetDebugNCPath, handler: service.getDebugNetworkContainers},
+		{path: cns.GetAPIMetricsPath, handler: service.getAPIMetrics},
+		{path: cns.ImportStatePath, handler: service.importState, ownerOnly: true},
+		{path: cns.DrainModePath, handler: service.drainMode, ownerOnly: true},
+	}
+}
+
+// Adds the handlers of the routes of the API. Version 1 is served on the paths without prefix and on the legacy
+// v0.2 paths, and version 2 on the paths with the v2 prefix, or on the paths without prefix to clients negotiating it.
+func (service *HTTPRestService) addAPIHandlers(listener *acn.Listener) {
+	for _, route := range service.apiRoutes() {
+		handler := route.handler
+		if route.ownerOnly {
+			handler = service.ownerOnly(handler)
+		}
+
+		v1 := chain(handler, withRequestID, service.withAccessLog(route.path, cns.APIVersion1))
+		v2 := chain(handler, withRequestID, service.withAccessLog(route.path, cns.APIVersion2), service.serveV2)
+		legacy := chain(handler, withRequestID, service.withAccessLog(cns.V2Prefix+route.path, cns.APIVersion1))
+
+		listener.AddHandler(route.path, negotiateVersion(v1, v2))
+		listener.AddHandler(cns.V2Prefix+route.path, legacy)
+		listener.AddHandler(cns.V2APIPrefix+route.path, v2)
+	}
+}
+
+// Wraps a handler with middlewares, the first being the outermost.
+func chain(handler http.HandlerFunc, middlewares ...middleware) http.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// Returns the media types of an Accept or Content-Type header, without parameters.
+func parseMediaTypes(header string) []string {
+	var mediaTypes []string
+	for _, value := range strings.Split(header, ",") {
+		if mediaType, _, err := mime.ParseMediaType(value); err == nil {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+
+	return mediaTypes
+}
+
+// Returns whether a request negotiates version 2 of the API with its Accept or Content-Type header.
+func acceptsV2(r *http.Request) bool {
+	for _, header := range []string{"Accept", "Content-Type"} {
+		for _, mediaType := range parseMediaTypes(r.Header.Get(header)) {
+			if mediaType == cns.V2MediaType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns the media type of a version 2 response acceptable to the client, or false if there is none.
+func negotiateV2MediaType(r *http.Request) (string, bool) {
+	mediaTypes := parseMediaTypes(r.Header.Get("Accept"))
+	if len(mediaTypes) == 0 {
+		return cns.V2MediaType, true
+	}
+
+	for _, mediaType := range mediaTypes {
+		switch mediaType {
+		case cns.V2MediaType, "*/*", "application/*":
+			return cns.V2MediaType, true
+		case "application/json":
+			return mediaType, true
+		}
+	}
+
+	return "", false
+}
+
+// Serves the versioned handler negotiated by the request on a path without prefix.
+func negotiateVersion(v1 http.HandlerFunc, v2 http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if acceptsV2(r) {
+			v2(w, r)
+		} else {
+			v1(w, r)
+		}
+	}
+}
+
+// Returns the ID of a request.
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Returns a request with the given request ID, and returns the ID to the client.
+func setRequestID(w http.ResponseWriter, r *http.Request, requestID string) *http.Request {
+	w.Header().Set(cns.RequestIDHeader, requestID)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID))
+}
+
+// Propagates the request ID set by the client, or generates one.
+func withRequestID(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(cns.RequestIDHeader)
+		if requestID == "" {
+			if id, err := uuid.NewUUID(); err == nil {
+				requestID = id.String()
+			}
+		}
+
+		handler(w, setRequestID(w, r, requestID))
+	}
+}
+
+// Records the status and the body of a response. Writes are passed through to the client, unless buffered.
+type responseRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	buffered bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+
+	if !rec.buffered {
+		rec.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	rec.body.Write(b)
+	if rec.buffered {
+		return len(b), nil
+	}
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// Returns the return code and the message of a version 1 response, whether it is a cns.Response or embeds one.
+func getResponseReturnCode(body []byte) (int, string) {
+	var resp struct {
+		Response   *cns.Response
+		ReturnCode int
+		Message    string
+	}
+
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, ""
+	}
+
+	if resp.Response != nil {
+		return resp.Response.ReturnCode, resp.Response.Message
+	}
+
+	return resp.ReturnCode, resp.Message
+}
+
+// Logs the requests served on a path of a version of the API, and records their metrics.
+func (service *HTTPRestService) withAccessLog(path string, version string) middleware {
+	return func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := service.clock.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+
+			handler(rec, r)
+
+			latency := service.clock.Since(start)
+			// Version 2 envelopes carry the return code at the top level too.
+			returnCode, _ := getResponseReturnCode(rec.body.Bytes())
+
+			log.Printf("[Azure CNS] %v %v %v requestId:%v status:%v returnCode:%v took %v.",
+				version, r.Method, r.URL.Path, w.Header().Get(cns.RequestIDHeader), rec.status, returnCode, latency)
+
+			service.apiMetricsLock.Lock()
+			defer service.apiMetricsLock.Unlock()
+
+			if service.apiMetrics == nil {
+				service.apiMetrics = make(map[string]*cns.APIRouteMetrics)
+			}
+
+			metrics, ok := service.apiMetrics[version+" "+path]
+			if !ok {
+				metrics = &cns.APIRouteMetrics{Path: path, APIVersion: version}
+				service.apiMetrics[version+" "+path] = metrics
+			}
+
+			metrics.Requests++
+			if rec.status >= http.StatusBadRequest || returnCode != Success {
+				metrics.Errors++
+			}
+
+			latencyMs := latency.Nanoseconds() / int64(time.Millisecond)
+			metrics.TotalLatencyMs += latencyMs
+			if latencyMs > metrics.MaxLatencyMs {
+				metrics.MaxLatencyMs = latencyMs
+			}
+		}
+	}
+}
+
+// Serves version 2 of a route with its version 1 handler. The request is unwrapped from its envelope before calling
+// the handler, and the response is wrapped in an envelope carrying its return code and the request ID.
+func (service *HTTPRestService) serveV2(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := cns.APIEnvelope{APIVersion: cns.APIVersion2}
+
+		mediaType, ok := negotiateV2MediaType(r)
+		if !ok {
+			resp.RequestID = requestIDFromContext(r.Context())
+			resp.ReturnCode = InvalidParameter
+			resp.Message = "[Azure CNS] Error. Accept header does not allow " + cns.V2MediaType + "."
+			service.writeEnvelope(w, http.StatusNotAcceptable, "application/json", &resp)
+			return
+		}
+
+		if r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+
+			var req cns.APIEnvelope
+			if err == nil && len(body) > 0 {
+				err = json.Unmarshal(body, &req)
+			}
+
+			if err != nil {
+				resp.RequestID = requestIDFromContext(r.Context())
+				resp.ReturnCode = InvalidParameter
+				resp.Message = "[Azure CNS] Error. Failed to decode request envelope: " + err.Error()
+				service.writeEnvelope(w, http.StatusBadRequest, mediaType, &resp)
+				return
+			}
+
+			// The envelope sets the request ID of clients which can't set headers.
+			if req.RequestID != "" && r.Header.Get(cns.RequestIDHeader) == "" {
+				r = setRequestID(w, r, req.RequestID)
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(req.Data))
+			r.ContentLength = int64(len(req.Data))
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, buffered: true}
+		handler(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		resp.RequestID = requestIDFromContext(r.Context())
+		data := bytes.TrimSpace(rec.body.Bytes())
+		if json.Valid(data) {
+			resp.Data = data
+			resp.ReturnCode, resp.Message = getResponseReturnCode(data)
+		} else {
+			// Errors of the listener are plain text.
+			resp.Message = string(data)
+		}
+
+		if status >= http.StatusBadRequest && resp.ReturnCode == Success {
+			resp.ReturnCode = UnexpectedError
+		}
+
+		service.writeEnvelope(w, status, mediaType, &resp)
+	}
+}
+
+// Writes a version 2 response.
+func (service *HTTPRestService) writeEnvelope(w http.ResponseWriter, status int, mediaType string, resp *cns.APIEnvelope) {
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("[Azure CNS] Failed to encode response envelope: %v", err)
+	}
+}
+
+// Handles requests for the metrics of the API.
+func (service *HTTPRestService) getAPIMetrics(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getAPIMetrics")
+
+	var resp cns.GetAPIMetricsResponse
+
+	switch r.Method {
+	case "GET":
+		service.apiMetricsLock.Lock()
+		for _, metrics := range service.apiMetrics {
+			resp.Routes = append(resp.Routes, *metrics)
+		}
+		service.apiMetricsLock.Unlock()
+
+		sort.Slice(resp.Routes, func(i, j int) bool {
+			if resp.Routes[i].Path != resp.Routes[j].Path {
+				return resp.Routes[i].Path < resp.Routes[j].Path
+			}
+			return resp.Routes[i].APIVersion < resp.Routes[j].APIVersion
+		})
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. GetAPIMetrics did not receive a GET."
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp.Response, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/cns"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// SetCNIExecutor enables the execution of the CNI commands forwarded by the CNI plugin in thin client mode.
+// It must be called before the service starts.
+func (service *HTTPRestService) SetCNIExecutor(executor cns.CNIExecutor) {
+	service.cniExecutor = executor
+}
+
+// Handles CNI commands forwarded by the CNI plugin in thin client mode.
+func (service *HTTPRestService) executeCNI(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] executeCNI")
+
+	var req cns.CNIRequest
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	var resp *cns.CNIResponse
+
+	switch {
+	case r.Method != "POST":
+		resp = &cns.CNIResponse{}
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. ExecuteCNI did not receive a POST."
+
+	case service.cniExecutor == nil:
+		resp = &cns.CNIResponse{}
+		resp.Response.ReturnCode = CNIExecutionNotEnabled
+		resp.Response.Message = "[Azure CNS] Error. CNS does not execute CNI commands, start it with -" + acn.OptCnsCNIExecution + "."
+
+	default:
+		resp = service.cniExecutor.ExecuteCNI(&req)
+	}
+
+	err = service.Listener.Encode(w, resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/Azure/azure-container-networking/cns"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	// Value replacing secrets in debug responses.
+	redactedValue = "<redacted>"
+)
+
+// Fields holding secrets, at any depth of the state.
+var redactedFields = map[string]bool{
+	"AuthorizationToken": true,
+	"EncryptedToken":     true,
+}
+
+// Returns a generic copy of the given value with secrets redacted.
+func redact(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+
+	redactValue(out)
+	return out, nil
+}
+
+// Redacts secrets in a decoded JSON value in place.
+func redactValue(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if redactedFields[key] {
+				if value != "" {
+					t[key] = redactedValue
+				}
+				continue
+			}
+			redactValue(value)
+		}
+
+	case []interface{}:
+		for _, value := range t {
+			redactValue(value)
+		}
+	}
+}
+
+// Adds the profiler handlers if enabled.
+func (service *HTTPRestService) addProfilerHandlers(listener *acn.Listener) {
+	if enabled, _ := service.GetOption(acn.OptCnsEnablePprof).(bool); !enabled {
+		return
+	}
+
+	registerProfilerHandlers(listener)
+	log.Printf("[Azure CNS] Profiler is enabled.")
+}
+
+// Registers the profiler handlers on a listener.
+func registerProfilerHandlers(listener *acn.Listener) {
+	listener.AddHandler("/debug/pprof/", pprof.Index)
+	listener.AddHandler("/debug/pprof/cmdline", pprof.Cmdline)
+	listener.AddHandler("/debug/pprof/profile", pprof.Profile)
+	listener.AddHandler("/debug/pprof/symbol", pprof.Symbol)
+	listener.AddHandler("/debug/pprof/trace", pprof.Trace)
+}
+
+// Handles requests for the in-memory and persisted state.
+func (service *HTTPRestService) getDebugState(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getDebugState")
+
+	var resp cns.GetDebugStateResponse
+	var err error
+
+	switch r.Method {
+	case "GET":
+		service.lock.Lock()
+		resp.InMemory, err = redact(service.state)
+		service.lock.Unlock()
+
+		if err == nil && service.store != nil {
+			var persisted httpRestServiceState
+			err = service.store.Read(storeKey, &persisted)
+			if err == nil {
+				resp.Persisted, err = redact(&persisted)
+			} else if err == store.ErrKeyNotFound {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			resp.Response.ReturnCode = UnexpectedError
+			resp.Response.Message = "[Azure CNS] Error. " + err.Error()
+		}
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. GetDebugState did not receive a GET."
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp.Response, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Handles requests for the state of the primary interface address pool.
+func (service *HTTPRestService) getDebugIPAM(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getDebugIPAM")
+
+	var resp cns.GetDebugIPAMResponse
+
+	switch r.Method {
+	case "GET":
+		if err := service.fillDebugIPAM(&resp); err != nil {
+			resp.Response.ReturnCode = UnexpectedError
+			resp.Response.Message = "[Azure CNS] Error. " + err.Error()
+		}
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. GetDebugIPAM did not receive a GET."
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Queries the IPAM plugin for the primary interface address pool.
+func (service *HTTPRestService) fillDebugIPAM(resp *cns.GetDebugIPAMResponse) error {
+	var err error
+
+	if utilization := service.getSnapshot().utilization; utilization != nil {
+		resp.SnapshotTime = utilization.time
+	}
+
+	resp.AddressSpace, err = service.ipamClient.GetAddressSpace()
+	if err != nil {
+		return err
+	}
+
+	resp.PoolID, err = service.getPrimaryPoolID()
+	if err != nil {
+		return err
+	}
+
+	resp.Capacity, resp.Available, resp.UnhealthyAddresses, err = service.ipamClient.GetIPAddressUtilization(resp.PoolID)
+	return err
+}
+
+// Handles requests for the network containers known to CNS.
+func (service *HTTPRestService) getDebugNetworkContainers(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getDebugNetworkContainers")
+
+	var resp cns.GetDebugNCResponse
+	var err error
+
+	switch r.Method {
+	case "GET":
+		service.lock.Lock()
+		resp.NetworkContainers, err = redact(service.state.ContainerStatus)
+		resp.ContainerIDByOrchestratorContext = make(map[string]string)
+		for orchestratorContext, id := range service.state.ContainerIDByOrchestratorContext {
+			resp.ContainerIDByOrchestratorContext[orchestratorContext] = id
+		}
+		service.lock.Unlock()
+
+		if err != nil {
+			resp.Response.ReturnCode = UnexpectedError
+			resp.Response.Message = "[Azure CNS] Error. " + err.Error()
+		}
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. GetDebugNetworkContainers did not receive a GET."
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp.Response, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
@@ -0,0 +1,35 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"github.com/Azure/azure-container-networking/cns/dncclient"
+	acn "github.com/Azure/azure-container-networking/common"
+)
+
+// Returns the client of the configured DNC, authenticating as configured. The client is shared by the IP pool
+// manager and the node heartbeat, so that they share the managed identity token.
+func (service *HTTPRestService) getDncClient(dncURL string) (*dncclient.DncClient, error) {
+	if service.dncClient != nil {
+		return service.dncClient, nil
+	}
+
+	mode, _ := service.GetOption(acn.OptDncAuthMode).(string)
+	resource, _ := service.GetOption(acn.OptDncAuthResource).(string)
+	clientID, _ := service.GetOption(acn.OptDncAuthClientID).(string)
+	certFile, _ := service.GetOption(acn.OptDncClientCert).(string)
+
+	client, err := dncclient.NewDncClientWithAuth(dncURL, dncclient.AuthConfig{
+		Mode:     mode,
+		Resource: resource,
+		ClientID: clientID,
+		CertFile: certFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service.dncClient = client
+	return client, nil
+}
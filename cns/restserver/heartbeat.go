@@ -0,0 +1,151 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/heartbeat"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Defaults of the node heartbeat, in seconds.
+	defaultHeartbeatInterval   = 60
+	defaultHeartbeatMaxBackoff = 600
+)
+
+// Starts sending the node heartbeat to DNC if a DNC URL is configured.
+func (service *HTTPRestService) startHeartbeat() error {
+	dncURL, _ := service.GetOption(acn.OptDncURL).(string)
+	if dncURL == "" {
+		return nil
+	}
+
+	interval, _ := service.GetOption(acn.OptHeartbeatInterval).(int)
+	if interval == 0 {
+		interval = defaultHeartbeatInterval
+	}
+
+	maxBackoff, _ := service.GetOption(acn.OptHeartbeatMaxBackoff).(int)
+	if maxBackoff == 0 {
+		maxBackoff = defaultHeartbeatMaxBackoff
+	}
+
+	config := heartbeat.Config{
+		Interval:   time.Duration(interval) * time.Second,
+		MaxBackoff: time.Duration(maxBackoff) * time.Second,
+	}
+
+	client, err := service.getDncClient(dncURL)
+	if err != nil {
+		return err
+	}
+
+	reporter, err := heartbeat.NewReporter(config, service.nextHeartbeat, client)
+	if err != nil {
+		return err
+	}
+
+	service.heartbeat = reporter
+	service.heartbeatStop = make(chan struct{})
+	go reporter.Run(service.heartbeatStop)
+
+	log.Printf("[Azure CNS] Sending node heartbeat with %+v.", config)
+	return nil
+}
+
+// Stops sending the node heartbeat.
+func (service *HTTPRestService) stopHeartbeat() {
+	if service.heartbeatStop != nil {
+		close(service.heartbeatStop)
+		service.heartbeatStop = nil
+	}
+}
+
+// Returns the heartbeat to send to DNC. Only the owner of the node state reports it,
+// once the node registered with its orchestrator type and DNC partition key.
+func (service *HTTPRestService) nextHeartbeat() (cns.NodeHeartbeatRequest, bool) {
+	if service.isReadOnly() || service.GetPartitionKey() == "" {
+		return cns.NodeHeartbeatRequest{}, false
+	}
+
+	return service.GetNodeHeartbeat(), true
+}
+
+// GetNodeHeartbeat returns the health of the node, its network containers and its IP pool.
+func (service *HTTPRestService) GetNodeHeartbeat() cns.NodeHeartbeatRequest {
+	service.lock.Lock()
+	orchestratorType := service.state.OrchestratorType
+	service.lock.Unlock()
+
+	req := cns.NodeHeartbeatRequest{
+		DncPartitionKey:  service.GetPartitionKey(),
+		NodeName:         service.nodeName,
+		OrchestratorType: orchestratorType,
+		Healthy:          true,
+		Timestamp:        service.clock.Now().UTC(),
+	}
+
+	if req.NodeName == "" {
+		req.NodeName, _ = service.GetOption(acn.OptNodeName).(string)
+		if req.NodeName == "" {
+			req.NodeName, _ = os.Hostname()
+		}
+	}
+
+	if ifInfo, err := service.imdsClient.GetPrimaryInterfaceInfoFromMemory(); err == nil {
+		req.PrimaryInterfaceIdentifier = ifInfo.PrimaryIP
+	} else {
+		req.Healthy = false
+	}
+
+	for id, status := range service.getSnapshot().containerStatus {
+		req.NetworkContainers = append(req.NetworkContainers, cns.NetworkContainerHeartbeat{
+			NetworkContainerID: id,
+			VMVersion:          status.VMVersion,
+			HostVersion:        status.HostVersion,
+		})
+	}
+
+	sort.Slice(req.NetworkContainers, func(i, j int) bool {
+		return req.NetworkContainers[i].NetworkContainerID < req.NetworkContainers[j].NetworkContainerID
+	})
+
+	if utilization, err := service.getUtilization(); err == nil {
+		req.IPPoolCapacity = utilization.capacity
+		req.IPPoolAvailable = utilization.available
+		req.UnhealthyIPCount = len(utilization.unhealthyAddrs)
+	} else {
+		req.Healthy = false
+	}
+
+	return req
+}
+
+// Handles requests for the state of the node heartbeat.
+func (service *HTTPRestService) getHeartbeatState(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getHeartbeatState")
+
+	var resp cns.GetHeartbeatStateResponse
+
+	switch r.Method {
+	case "GET":
+		if service.heartbeat != nil {
+			resp.State = service.heartbeat.GetState()
+		}
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. GetHeartbeatState did not receive a GET."
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/dncclient"
+	"github.com/Azure/azure-container-networking/cns/ippool"
+	"github.com/Azure/azure-container-networking/cns/nodenetworkconfig"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Interval at which the IP pool is scaled.
+	ipPoolReconcileInterval = 30 * time.Second
+)
+
+// ipamPool exposes the primary interface address pool to the IP pool manager.
+type ipamPool struct {
+	service *HTTPRestService
+}
+
+// GetUtilization returns the capacity and the number of available addresses of the pool.
+func (p *ipamPool) GetUtilization() (int, int, error) {
+	poolID, err := p.service.getPrimaryPoolID()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	capacity, available, _, err := p.service.ipamClient.GetIPAddressUtilization(poolID)
+	return capacity, available, err
+}
+
+// Reserve reserves an address from the pool.
+func (p *ipamPool) Reserve(reservationID string) (string, error) {
+	resp := p.service.reserveIPAddressResponse(cns.ReserveIPAddressRequest{ReservationID: reservationID})
+	if resp.Response.ReturnCode != Success {
+		return "", errors.New(resp.Response.Message)
+	}
+
+	return resp.IPAddress, nil
+}
+
+// Release releases an address reservation.
+func (p *ipamPool) Release(reservationID string) error {
+	resp := p.service.releaseIPAddressResponse(cns.ReleaseIPAddressRequest{ReservationID: reservationID})
+	if resp.ReturnCode != Success {
+		return errors.New(resp.Message)
+	}
+
+	return nil
+}
+
+// dncController requests and releases addresses of the node from DNC.
+type dncController struct {
+	service *HTTPRestService
+	client  *dncclient.DncClient
+}
+
+// RequestIPBatch asks DNC to allocate more addresses to the primary interface.
+func (c *dncController) RequestIPBatch(count int) error {
+	ifInfo, err := c.service.imdsClient.GetPrimaryInterfaceInfoFromMemory()
+	if err != nil {
+		return err
+	}
+
+	return c.client.RequestIPBatch(cns.RequestIPBatchRequest{
+		DncPartitionKey:            c.service.GetPartitionKey(),
+		PrimaryInterfaceIdentifier: ifInfo.PrimaryIP,
+		Count:                      count,
+	})
+}
+
+// ReleaseIPBatch asks DNC to remove addresses from the primary interface.
+func (c *dncController) ReleaseIPBatch(addresses []string) error {
+	ifInfo, err := c.service.imdsClient.GetPrimaryInterfaceInfoFromMemory()
+	if err != nil {
+		return err
+	}
+
+	return c.client.ReleaseIPBatch(cns.ReleaseIPBatchRequest{
+		DncPartitionKey:            c.service.GetPartitionKey(),
+		PrimaryInterfaceIdentifier: ifInfo.PrimaryIP,
+		IPAddresses:                addresses,
+	})
+}
+
+// Starts scaling the IP pool if a batch size and either a DNC URL or CRD mode are configured.
+func (service *HTTPRestService) startIPPoolManager() error {
+	dncURL, _ := service.GetOption(acn.OptDncURL).(string)
+	batchSize, _ := service.GetOption(acn.OptIPPoolBatchSize).(int)
+	if batchSize == 0 {
+		return nil
+	}
+
+	var controller ippool.Controller
+	switch {
+	case service.nncClient != nil:
+		controller = nodenetworkconfig.NewController(service.nncClient, service.nodeName)
+	case dncURL != "":
+		client, err := service.getDncClient(dncURL)
+		if err != nil {
+			return err
+		}
+		controller = &dncController{service: service, client: client}
+	default:
+		return nil
+	}
+
+	minFree, _ := service.GetOption(acn.OptIPPoolMinFree).(int)
+	maxFree, _ := service.GetOption(acn.OptIPPoolMaxFree).(int)
+
+	config := ippool.Config{
+		BatchSize: batchSize,
+		MinFree:   minFree,
+		MaxFree:   maxFree,
+	}
+
+	manager, err := ippool.NewManager(config, &ipamPool{service: service}, controller)
+	if err != nil {
+		return err
+	}
+
+	service.ipPoolManager = manager
+	service.ipPoolStop = make(chan struct{})
+	go service.runIPPoolManager(service.ipPoolStop)
+
+	log.Printf("[Azure CNS] Scaling IP pool with %+v.", config)
+	return nil
+}
+
+// Stops scaling the IP pool.
+func (service *HTTPRestService) stopIPPoolManager() {
+	if service.ipPoolStop != nil {
+		close(service.ipPoolStop)
+		service.ipPoolStop = nil
+	}
+}
+
+// Scales the IP pool periodically while this instance owns the node state.
+func (service *HTTPRestService) runIPPoolManager(stop chan struct{}) {
+	ticker := service.clock.NewTicker(ipPoolReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+
+		// A draining node neither grows nor shrinks its pool, the addresses leave with the node.
+		if !service.isReadOnly() && !service.isDraining() {
+			service.ipPoolManager.Reconcile()
+		}
+	}
+}
+
+// Handles requests for the state of the IP pool manager.
+func (service *HTTPRestService) getIPPoolState(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getIPPoolState")
+
+	var resp cns.GetIPPoolStateResponse
+
+	switch r.Method {
+	case "GET":
+		if service.ipPoolManager != nil {
+			resp.State = service.ipPoolManager.GetState()
+		}
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. GetIPPoolState did not receive a GET."
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/common"
+	acn "github.com/Azure/azure-container-networking/common"
+)
+
+func TestIPAMPool(t *testing.T) {
+	pool := &ipamPool{service: service.(*HTTPRestService)}
+	ipam.Lock()
+	ipam.failures["pool-fail"] = true
+	ipam.Unlock()
+
+	capacity, available, err := pool.GetUtilization()
+	if err != nil {
+		t.Fatalf("TestIPAMPool failed, GetUtilization err %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		reserve bool
+		fail    bool
+	}{
+		{name: "reserve", id: "pool01", reserve: true},
+		{name: "reserve again", id: "pool01", reserve: true},
+		{name: "reserve failure", id: "pool-fail", reserve: true, fail: true},
+		{name: "release", id: "pool01"},
+		{name: "release unknown", id: "pool01", fail: true},
+	}
+
+	for _, test := range tests {
+		var err error
+		if test.reserve {
+			var address string
+			address, err = pool.Reserve(test.id)
+			if err == nil && address == "" {
+				t.Errorf("TestIPAMPool failed @ %v: no address", test.name)
+			}
+		} else {
+			err = pool.Release(test.id)
+		}
+
+		if (err != nil) != test.fail {
+			t.Errorf("TestIPAMPool failed @ %v: err %v", test.name, err)
+		}
+
+		if ipam.isReserved(test.id) != (test.reserve && !test.fail) {
+			t.Errorf("TestIPAMPool failed @ %v: reservation not updated", test.name)
+		}
+	}
+
+	// The pool is back to its initial utilization.
+	if c, a, err := pool.GetUtilization(); err != nil || c != capacity || a != available {
+		t.Errorf("TestIPAMPool failed, utilization %v/%v, expected %v/%v, err %v", a, c, available, capacity, err)
+	}
+}
+
+func TestStartIPPoolManagerDisabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+	}{
+		{name: "no options", options: map[string]interface{}{}},
+		{name: "no batch size", options: map[string]interface{}{acn.OptDncURL: "http://dnc"}},
+		{name: "no controller", options: map[string]interface{}{acn.OptIPPoolBatchSize: 16}},
+	}
+
+	for _, test := range tests {
+		svc := &HTTPRestService{Service: &cns.Service{Service: &common.Service{Options: test.options}}}
+		if err := svc.startIPPoolManager(); err != nil || svc.ipPoolManager != nil || svc.ipPoolStop != nil {
+			t.Errorf("TestStartIPPoolManagerDisabled failed @ %v: manager %v, err %v", test.name, svc.ipPoolManager, err)
+		}
+
+		// Stopping a manager that never started is a no-op.
+		svc.stopIPPoolManager()
+	}
+}
+
+func TestGetIPPoolState(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		returnCode int
+	}{
+		{name: "get", method: http.MethodGet, returnCode: Success},
+		{name: "post", method: http.MethodPost, returnCode: InvalidParameter},
+	}
+
+	for _, test := range tests {
+		var resp cns.GetIPPoolStateResponse
+
+		req, err := http.NewRequest(test.method, cns.GetIPPoolStatePath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		// The test service does not scale its pool, so its state is empty.
+		err = decodeResponse(w, &resp)
+		if err != nil || resp.Response.ReturnCode != test.returnCode || len(resp.State.Decisions) != 0 {
+			t.Errorf("TestGetIPPoolState failed @ %v: response %+v, err %v", test.name, resp, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"github.com/Azure/azure-container-networking/cns"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Isolates the network containers on the node from each other if isolation is enabled.
+// The caller must hold the service lock.
+func (service *HTTPRestService) setNetworkContainerIsolation() {
+	if enabled, _ := service.GetOption(acn.OptCnsNCIsolation).(bool); !enabled || service.networkContainer == nil {
+		return
+	}
+
+	var reqs []cns.CreateNetworkContainerRequest
+	for _, status := range service.state.ContainerStatus {
+		reqs = append(reqs, status.CreateNetworkContainerRequest)
+	}
+
+	if err := service.networkContainer.SetIsolation(reqs); err != nil {
+		log.Errorf("[Azure CNS] Failed to isolate network containers, err:%v.", err)
+	}
+}
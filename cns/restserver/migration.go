@@ -0,0 +1,253 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+var (
+	errNodeDraining = fmt.Errorf("The node is draining and takes no new allocations")
+)
+
+// Returns whether the node is draining.
+func (service *HTTPRestService) isDraining() bool {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	return service.state.Draining
+}
+
+// Records the addresses reserved for the given reservation IDs.
+func (service *HTTPRestService) recordIPReservations(addresses map[string]string) {
+	if len(addresses) == 0 {
+		return
+	}
+
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	if service.state.IPReservations == nil {
+		service.state.IPReservations = make(map[string]string)
+	}
+
+	for reservationID, address := range addresses {
+		service.state.IPReservations[reservationID] = address
+	}
+
+	service.saveState()
+}
+
+// Forgets the addresses reserved for the given reservation IDs once they are released.
+func (service *HTTPRestService) forgetIPReservations(reservationIDs []string) {
+	if len(reservationIDs) == 0 {
+		return
+	}
+
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	for _, reservationID := range reservationIDs {
+		delete(service.state.IPReservations, reservationID)
+	}
+
+	service.saveState()
+}
+
+// Returns a portable snapshot of the IPAM and network container state of the node.
+// The snapshot is not redacted, since the network containers are recreated from it on the replacement node,
+// so it is only served by the admin API.
+func (service *HTTPRestService) getNodeStateSnapshot() cns.NodeStateSnapshot {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	snapshot := cns.NodeStateSnapshot{
+		Location:         service.state.Location,
+		NetworkType:      service.state.NetworkType,
+		OrchestratorType: service.state.OrchestratorType,
+		IPReservations:   make(map[string]string),
+		Draining:         service.state.Draining,
+		TimeStamp:        service.clock.Now(),
+	}
+
+	for _, status := range service.state.ContainerStatus {
+		snapshot.NetworkContainers = append(snapshot.NetworkContainers, status.CreateNetworkContainerRequest)
+	}
+
+	sort.Slice(snapshot.NetworkContainers, func(i, j int) bool {
+		return snapshot.NetworkContainers[i].NetworkContainerid < snapshot.NetworkContainers[j].NetworkContainerid
+	})
+
+	for reservationID, address := range service.state.IPReservations {
+		snapshot.IPReservations[reservationID] = address
+	}
+
+	return snapshot
+}
+
+// Handles requests to export the state of the node.
+func (service *HTTPRestService) exportState(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] exportState")
+
+	var resp cns.ExportStateResponse
+
+	switch r.Method {
+	case "GET":
+		resp.Snapshot = service.getNodeStateSnapshot()
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. ExportState did not receive a GET."
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp.Response, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Handles requests to import the state exported by another node.
+func (service *HTTPRestService) importState(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] importState")
+
+	var req cns.ImportStateRequest
+	var resp cns.ImportStateResponse
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+
+	if err != nil {
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		resp = service.importStateResponse(req.Snapshot)
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. ImportState did not receive a POST."
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp.Response, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Imports the state exported by another node. The environment and orchestrator type are taken from the snapshot
+// unless already set, the network containers are recreated and the reservations are reserved again at the addresses
+// they had on the exported node.
+// Everything that can be imported is, and the failures are reported.
+func (service *HTTPRestService) importStateResponse(snapshot cns.NodeStateSnapshot) cns.ImportStateResponse {
+	var resp cns.ImportStateResponse
+
+	service.lock.Lock()
+	if service.state.Draining {
+		service.lock.Unlock()
+		resp.Response.ReturnCode = NodeDraining
+		resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. %v", errNodeDraining)
+		return resp
+	}
+
+	if !service.state.Initialized && snapshot.Location != "" {
+		service.state.Location = snapshot.Location
+		service.state.NetworkType = snapshot.NetworkType
+		service.state.Initialized = true
+	}
+
+	if service.state.OrchestratorType == "" {
+		service.state.OrchestratorType = snapshot.OrchestratorType
+	}
+
+	service.saveState()
+	service.lock.Unlock()
+
+	var failed []string
+	imported := 0
+
+	for _, req := range snapshot.NetworkContainers {
+		ncResp := service.createOrUpdateNetworkContainerResponse(req)
+		if ncResp.Response.ReturnCode != Success {
+			log.Errorf("[Azure CNS] Failed to import network container %v, err:%v", req.NetworkContainerid, ncResp.Response.Message)
+			failed = append(failed, req.NetworkContainerid)
+			resp.Response.ReturnCode = ncResp.Response.ReturnCode
+			continue
+		}
+		imported++
+	}
+
+	resp.IPAddresses = make(map[string]string)
+	for reservationID, exported := range snapshot.IPReservations {
+		// Reservations already on this node are kept, so that an import can be retried.
+		service.lock.Lock()
+		address, ok := service.state.IPReservations[reservationID]
+		service.lock.Unlock()
+
+		if !ok {
+			reserveResp := service.reserveIPAddressAt(cns.ReserveIPAddressRequest{ReservationID: reservationID}, exported)
+			if reserveResp.Response.ReturnCode != Success {
+				log.Errorf("[Azure CNS] Failed to import reservation %v, err:%v", reservationID, reserveResp.Response.Message)
+				failed = append(failed, reservationID)
+				resp.Response.ReturnCode = reserveResp.Response.ReturnCode
+				continue
+			}
+			address = reserveResp.IPAddress
+		}
+
+		resp.IPAddresses[reservationID] = address
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		resp.Response.Message = fmt.Sprintf("[Azure CNS] ImportState failed for %v", strings.Join(failed, ","))
+	}
+
+	log.Printf("[Azure CNS] Imported %d network containers and %d reservations exported at %v.",
+		imported, len(resp.IPAddresses), snapshot.TimeStamp)
+
+	return resp
+}
+
+// Handles requests to get or set the drain mode of the node.
+func (service *HTTPRestService) drainMode(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] drainMode")
+
+	var req cns.SetDrainModeRequest
+	var resp cns.DrainModeResponse
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		err := service.Listener.Decode(w, r, &req)
+		log.Request(service.Name, &req, err)
+
+		if err != nil {
+			return
+		}
+
+		service.lock.Lock()
+		if service.state.Draining != req.Drain {
+			service.state.Draining = req.Drain
+			service.saveState()
+			log.Printf("[Azure CNS] Set drain mode to %v.", req.Drain)
+		}
+		service.lock.Unlock()
+
+	default:
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = "[Azure CNS] Error. DrainMode did not receive a GET or POST."
+	}
+
+	service.lock.Lock()
+	resp.Draining = service.state.Draining
+	resp.IPReservations = len(service.state.IPReservations)
+	resp.NetworkContainers = len(service.state.ContainerStatus)
+	service.lock.Unlock()
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
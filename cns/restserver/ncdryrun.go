@@ -0,0 +1,252 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cns"
+	acn "github.com/Azure/azure-container-networking/common"
+)
+
+const (
+	maxVlanID  = 4094
+	maxVxlanID = 1<<24 - 1
+)
+
+// Returns whether the request asks to validate and plan the call without applying it.
+func isDryRunRequest(r *http.Request) bool {
+	return r.URL.Query().Get(cns.DryRunQueryParameter) == "true"
+}
+
+// Validates the goal state of a network container against the state of the node, and returns the operations
+// creating or updating it would perform, without performing them, so that DNC can check a goal state beforehand.
+func (service *HTTPRestService) dryRunNetworkContainerResponse(req cns.CreateNetworkContainerRequest) cns.CreateNetworkContainerResponse {
+	var resp cns.CreateNetworkContainerResponse
+
+	if req.NetworkContainerid == "" {
+		resp.Response.ReturnCode = NetworkContainerNotSpecified
+		resp.Response.Message = "[Azure CNS] Error. NetworkContainerid is empty"
+		return resp
+	}
+
+	service.lock.Lock()
+	existing, exists := service.state.ContainerStatus[req.NetworkContainerid]
+	draining := service.state.Draining
+	orchestratorType := service.state.OrchestratorType
+	others := service.otherNetworkContainers(req.NetworkContainerid)
+	service.lock.Unlock()
+
+	problems := validateNetworkContainer(req, others, orchestratorType)
+	if draining && !exists {
+		problems = append(problems, errNodeDraining.Error())
+	}
+
+	if req.NetworkContainerType == cns.WebApps && (!exists || existing.VMVersion != req.Version) {
+		ops, err := service.networkContainer.PlanCreateOrUpdate(req)
+		resp.Operations = append(resp.Operations, ops...)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("creating the network container would fail: %v", err))
+		}
+	}
+
+	resp.Operations = append(resp.Operations, cns.DataplaneOperation{
+		Description: fmt.Sprintf("Save the goal state of network container %v at version %v", req.NetworkContainerid, req.Version),
+	})
+
+	if podInfo, ok := podOfNetworkContainer(req, orchestratorType); ok {
+		resp.Operations = append(resp.Operations, cns.DataplaneOperation{
+			Description: fmt.Sprintf("Map pod %v/%v to network container %v", podInfo.PodNamespace, podInfo.PodName, req.NetworkContainerid),
+		})
+	}
+
+	if enabled, _ := service.GetOption(acn.OptCnsNCIsolation).(bool); enabled && service.networkContainer != nil {
+		resp.Operations = append(resp.Operations, service.networkContainer.PlanIsolation(append(others, req))...)
+	}
+
+	resp.ValidationErrors = problems
+	if len(problems) > 0 {
+		resp.Response.ReturnCode = InvalidParameter
+		resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Network container %v is invalid: %v",
+			req.NetworkContainerid, strings.Join(problems, "; "))
+	}
+
+	return resp
+}
+
+// Returns the goal states of the network containers of the node other than the given one.
+// The caller must hold the service lock.
+func (service *HTTPRestService) otherNetworkContainers(networkContainerID string) []cns.CreateNetworkContainerRequest {
+	var others []cns.CreateNetworkContainerRequest
+	for id, status := range service.state.ContainerStatus {
+		if id != networkContainerID {
+			others = append(others, status.CreateNetworkContainerRequest)
+		}
+	}
+
+	return others
+}
+
+// Returns the pod a network container is created for, if its type and the orchestrator map pods to network containers.
+func podOfNetworkContainer(req cns.CreateNetworkContainerRequest, orchestratorType string) (cns.KubernetesPodInfo, bool) {
+	var podInfo cns.KubernetesPodInfo
+
+	if req.NetworkContainerType != cns.AzureContainerInstance && req.NetworkContainerType != cns.ClearContainer {
+		return podInfo, false
+	}
+
+	if orchestratorType != cns.Kubernetes && orchestratorType != cns.ServiceFabric {
+		return podInfo, false
+	}
+
+	err := json.Unmarshal(req.OrchestratorContext, &podInfo)
+	return podInfo, err == nil
+}
+
+// Returns the problems of the goal state of a network container: addresses outside of its subnet,
+// addresses used by the other network containers of the node, and invalid routes or encapsulation.
+func validateNetworkContainer(req cns.CreateNetworkContainerRequest, others []cns.CreateNetworkContainerRequest, orchestratorType string) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch req.NetworkContainerType {
+	case "", cns.AzureContainerInstance, cns.WebApps, cns.ClearContainer, cns.Docker:
+	default:
+		add("unknown network container type %v", req.NetworkContainerType)
+	}
+
+	ipConfig := req.IPConfiguration
+	ip, subnet, err := parseIPSubnet(ipConfig.IPSubnet)
+	if err != nil {
+		add("IPConfiguration: %v", err)
+	}
+
+	gateway := net.ParseIP(ipConfig.GatewayIPAddress)
+	if ipConfig.GatewayIPAddress != "" {
+		if gateway == nil {
+			add("IPConfiguration: invalid gateway %v", ipConfig.GatewayIPAddress)
+		} else if subnet != nil && !subnet.Contains(gateway) {
+			add("IPConfiguration: gateway %v is outside of subnet %v", gateway, subnet)
+		} else if gateway.Equal(ip) {
+			add("IPConfiguration: gateway %v is the address of the network container", gateway)
+		}
+	}
+
+	for _, server := range ipConfig.DNSServers {
+		if net.ParseIP(server) == nil {
+			add("IPConfiguration: invalid DNS server %v", server)
+		}
+	}
+
+	// Addresses of the network container, to find those that are used twice.
+	used := make(map[string]string)
+	if ip != nil {
+		used[ip.String()] = "the address of the network container"
+	}
+
+	for _, address := range req.SecondaryIPAddresses {
+		secondaryIP := net.ParseIP(address)
+		switch {
+		case secondaryIP == nil:
+			add("SecondaryIPAddresses: invalid address %v", address)
+		case subnet != nil && !subnet.Contains(secondaryIP):
+			add("SecondaryIPAddresses: %v is outside of subnet %v", secondaryIP, subnet)
+		case gateway != nil && secondaryIP.Equal(gateway):
+			add("SecondaryIPAddresses: %v is the gateway", secondaryIP)
+		case used[secondaryIP.String()] != "":
+			add("SecondaryIPAddresses: %v is already %v", secondaryIP, used[secondaryIP.String()])
+		default:
+			used[secondaryIP.String()] = "a secondary address"
+		}
+	}
+
+	for _, other := range others {
+		addresses := append([]string{other.IPConfiguration.IPSubnet.IPAddress}, other.SecondaryIPAddresses...)
+		for _, address := range addresses {
+			if otherIP := net.ParseIP(address); otherIP != nil && used[otherIP.String()] != "" {
+				add("%v is already used by network container %v", otherIP, other.NetworkContainerid)
+			}
+		}
+	}
+
+	if req.LocalIPConfiguration.IPSubnet.IPAddress != "" {
+		if _, _, err = parseIPSubnet(req.LocalIPConfiguration.IPSubnet); err != nil {
+			add("LocalIPConfiguration: %v", err)
+		}
+	}
+
+	if req.PrimaryInterfaceIdentifier != "" && net.ParseIP(req.PrimaryInterfaceIdentifier) == nil {
+		add("PrimaryInterfaceIdentifier: invalid address %v", req.PrimaryInterfaceIdentifier)
+	}
+
+	for _, ipSubnet := range req.CnetAddressSpace {
+		if _, _, err = parseIPSubnet(ipSubnet); err != nil {
+			add("CnetAddressSpace: %v", err)
+		}
+	}
+
+	for _, route := range req.Routes {
+		if _, _, err = net.ParseCIDR(route.IPAddress); err != nil && net.ParseIP(route.IPAddress) == nil {
+			add("Routes: invalid destination %v", route.IPAddress)
+		}
+
+		if route.GatewayIPAddress != "" && net.ParseIP(route.GatewayIPAddress) == nil {
+			add("Routes: invalid gateway %v", route.GatewayIPAddress)
+		}
+	}
+
+	switch encap := req.MultiTenancyInfo; encap.EncapType {
+	case "":
+	case cns.Vlan:
+		if encap.ID < 1 || encap.ID > maxVlanID {
+			add("MultiTenancyInfo: VLAN ID %v is out of range", encap.ID)
+		}
+	case cns.Vxlan:
+		if encap.ID < 1 || encap.ID > maxVxlanID {
+			add("MultiTenancyInfo: VXLAN ID %v is out of range", encap.ID)
+		}
+	default:
+		add("MultiTenancyInfo: unknown encapsulation %v", encap.EncapType)
+	}
+
+	if req.NetworkContainerType == cns.AzureContainerInstance || req.NetworkContainerType == cns.ClearContainer {
+		if orchestratorType == cns.Kubernetes || orchestratorType == cns.ServiceFabric {
+			var podInfo cns.KubernetesPodInfo
+			if err = json.Unmarshal(req.OrchestratorContext, &podInfo); err != nil {
+				add("OrchestratorContext: %v", err)
+			} else if podInfo.PodName == "" {
+				add("OrchestratorContext: pod name is empty")
+			}
+		}
+	}
+
+	return problems
+}
+
+// Parses an IP subnet, returning its address and the subnet it is in.
+func parseIPSubnet(ipSubnet cns.IPSubnet) (net.IP, *net.IPNet, error) {
+	ip := net.ParseIP(ipSubnet.IPAddress)
+	if ip == nil {
+		return nil, nil, fmt.Errorf("invalid address %q", ipSubnet.IPAddress)
+	}
+
+	bits := 128
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+
+	if int(ipSubnet.PrefixLength) > bits {
+		return nil, nil, fmt.Errorf("invalid prefix length %v of %v", ipSubnet.PrefixLength, ip)
+	}
+
+	mask := net.CIDRMask(int(ipSubnet.PrefixLength), bits)
+	return ip, &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Returns an error if a network container can't be updated in place to the given goal state, because the change
+// would require tearing down the containers using it. Routes, the CNET address space, the DNS servers and the
+// secondary IP addresses can change in place.
+func checkInPlaceUpdate(current cns.CreateNetworkContainerRequest, goal cns.CreateNetworkContainerRequest) error {
+	switch {
+	case current.NetworkContainerType != goal.NetworkContainerType:
+		return fmt.Errorf("network container type changed from %v to %v", current.NetworkContainerType, goal.NetworkContainerType)
+
+	case current.PrimaryInterfaceIdentifier != goal.PrimaryInterfaceIdentifier:
+		return fmt.Errorf("primary interface changed from %v to %v", current.PrimaryInterfaceIdentifier, goal.PrimaryInterfaceIdentifier)
+
+	case current.IPConfiguration.IPSubnet != goal.IPConfiguration.IPSubnet ||
+		current.IPConfiguration.GatewayIPAddress != goal.IPConfiguration.GatewayIPAddress:
+		return fmt.Errorf("IP configuration changed from %+v to %+v", current.IPConfiguration, goal.IPConfiguration)
+
+	case !reflect.DeepEqual(current.LocalIPConfiguration, goal.LocalIPConfiguration):
+		return fmt.Errorf("local IP configuration changed from %+v to %+v", current.LocalIPConfiguration, goal.LocalIPConfiguration)
+
+	case current.MultiTenancyInfo != goal.MultiTenancyInfo:
+		return fmt.Errorf("multitenancy info changed from %+v to %+v", current.MultiTenancyInfo, goal.MultiTenancyInfo)
+
+	case !bytes.Equal(current.OrchestratorContext, goal.OrchestratorContext):
+		return fmt.Errorf("orchestrator context changed from %s to %s", current.OrchestratorContext, goal.OrchestratorContext)
+	}
+
+	return nil
+}
+
+// Compares two network container versions, which DNC sets to integers or dot-separated integers. Returns
+// -1, 0 or 1 if version is older than, equal to or newer than other, and false if they can't be ordered.
+func compareNetworkContainerVersions(version string, other string) (int, bool) {
+	a := strings.Split(version, ".")
+	b := strings.Split(other, ".")
+
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y uint64
+		var err error
+
+		if i < len(a) {
+			if x, err = strconv.ParseUint(a[i], 10, 64); err != nil {
+				return 0, false
+			}
+		}
+
+		if i < len(b) {
+			if y, err = strconv.ParseUint(b[i], 10, 64); err != nil {
+				return 0, false
+			}
+		}
+
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+	}
+
+	return 0, true
+}
+
+// Returns whether the goal version of a network container is newer than its programmed version. Versions that
+// can't be ordered are newer whenever they differ.
+func isNewerNetworkContainerVersion(goal string, current string) bool {
+	if goal == "" {
+		return false
+	}
+
+	if c, ok := compareNetworkContainerVersions(goal, current); ok {
+		return c > 0
+	}
+
+	return goal != current
+}
+
+// Handles requests to compare the version of a network container programmed by CNS with its goal version.
+func (service *HTTPRestService) getNetworkContainerVersion(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getNetworkContainerVersion")
+
+	var req cns.GetNetworkContainerVersionRequest
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	resp := cns.GetNetworkContainerVersionResponse{
+		NetworkContainerid: req.NetworkContainerid,
+		GoalVersion:        req.GoalVersion,
+	}
+
+	// Served from the snapshot so that frequent polling by DNC does not block changes.
+	if status, ok := service.getSnapshot().containerStatus[req.NetworkContainerid]; ok {
+		resp.Version = status.VMVersion
+		resp.UpdateRequired = isNewerNetworkContainerVersion(req.GoalVersion, status.VMVersion)
+	} else {
+		resp.Response.ReturnCode = UnknownContainerID
+		resp.Response.Message = "[Azure CNS] Never received call to create this container."
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Handles requests to update a network container in place to a new version of its goal state.
+func (service *HTTPRestService) updateNetworkContainer(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] updateNetworkContainer")
+
+	var req cns.CreateNetworkContainerRequest
+	var resp cns.UpdateNetworkContainerResponse
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		if isAsyncRequest(r) {
+			service.acceptAsyncOperation(w, operationUpdateNetworkContainer, req)
+			return
+		}
+
+		resp = service.updateNetworkContainerResponse(req)
+
+	default:
+		resp.Response.Message = "[Azure CNS] Error. UpdateNetworkContainer did not receive a POST."
+		resp.Response.ReturnCode = InvalidParameter
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Updates an existing network container in place to the given goal state, keeping the containers using it running.
+// Only network containers whose dataplane CNS programs can be updated in place. Versions older than the current one,
+// and changes that would require recreating the network container, are rejected and leave it unchanged.
+func (service *HTTPRestService) updateNetworkContainerResponse(req cns.CreateNetworkContainerRequest) cns.UpdateNetworkContainerResponse {
+	var resp cns.UpdateNetworkContainerResponse
+
+	if req.NetworkContainerid == "" {
+		resp.Response.ReturnCode = NetworkContainerNotSpecified
+		resp.Response.Message = "[Azure CNS] Error. NetworkContainerid is empty"
+		return resp
+	}
+
+	// The comparison, the update and the save are done under one lock, so that concurrent updates
+	// can't interleave and a stale version can't overwrite a newer one.
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	existing, ok := service.state.ContainerStatus[req.NetworkContainerid]
+	if !ok {
+		resp.Response.ReturnCode = UnknownContainerID
+		resp.Response.Message = "[Azure CNS] Error. Never received call to create this container."
+		return resp
+	}
+
+	resp.PreviousVersion = existing.VMVersion
+	resp.Version = existing.VMVersion
+	if existing.VMVersion == req.Version {
+		log.Printf("[Azure CNS] Network container %v is already at version %v.", req.NetworkContainerid, req.Version)
+		return resp
+	}
+
+	if c, ok := compareNetworkContainerVersions(req.Version, existing.VMVersion); ok && c < 0 {
+		resp.Response.ReturnCode = NetworkContainerVersionStale
+		resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Version %v of network container %v is older than version %v.",
+			req.Version, req.NetworkContainerid, existing.VMVersion)
+		return resp
+	}
+
+	if err := checkInPlaceUpdate(existing.CreateNetworkContainerRequest, req); err != nil {
+		resp.Response.ReturnCode = NetworkContainerNotUpdatable
+		resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Network container %v can't be updated in place: %v",
+			req.NetworkContainerid, err)
+		return resp
+	}
+
+	// The dataplane of the other types is programmed by the CNI plugin when their containers are set up,
+	// so saving their goal state would not update the running containers.
+	if req.NetworkContainerType != cns.WebApps {
+		resp.Response.ReturnCode = NetworkContainerNotUpdatable
+		resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Network containers of type %v can't be updated in place.",
+			req.NetworkContainerType)
+		return resp
+	}
+
+	log.Printf("[Azure CNS] Updating network container %v from version %v to %v.",
+		req.NetworkContainerid, existing.VMVersion, req.Version)
+
+	if err := service.networkContainer.Update(req); err != nil {
+		resp.Response.ReturnCode = UnexpectedError
+		resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. UpdateNetworkContainer failed %v", err)
+		return resp
+	}
+
+	resp.Response.ReturnCode, resp.Response.Message = service.setNetworkContainerGoalState(req)
+	if resp.Response.ReturnCode == Success {
+		resp.Version = req.Version
+		resp.Updated = true
+	}
+
+	return resp
+}
@@ -0,0 +1,310 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/nodenetworkconfig"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/nmagent"
+)
+
+const (
+	// Interval at which the NodeNetworkConfig is reconciled again, even if no change was watched.
+	nncResyncInterval = 10 * time.Minute
+
+	// Delay before the NodeNetworkConfig is watched again after the watch failed.
+	nncRetryInterval = 5 * time.Second
+)
+
+// ncStore applies the network containers of the NodeNetworkConfig to the service.
+type ncStore struct {
+	service *HTTPRestService
+}
+
+// List returns the versions of the network containers created from the NodeNetworkConfig.
+func (s *ncStore) List() (map[string]string, error) {
+	s.service.lock.Lock()
+	defer s.service.lock.Unlock()
+
+	versions := make(map[string]string)
+	for id, status := range s.service.state.ContainerStatus {
+		if status.CreateNetworkContainerRequest.NetworkContainerType == cns.Docker {
+			versions[id] = status.VMVersion
+		}
+	}
+
+	return versions, nil
+}
+
+// CreateOrUpdate creates or updates a network container.
+func (s *ncStore) CreateOrUpdate(req cns.CreateNetworkContainerRequest) error {
+	resp := s.service.createOrUpdateNetworkContainerResponse(req)
+	if resp.Response.ReturnCode != Success {
+		return errors.New(resp.Response.Message)
+	}
+
+	return nil
+}
+
+// Delete deletes a network container.
+func (s *ncStore) Delete(networkContainerID string) error {
+	resp := s.service.deleteNetworkContainerResponse(cns.DeleteNetworkContainerRequest{NetworkContainerid: networkContainerID})
+	if resp.Response.ReturnCode != Success {
+		return errors.New(resp.Response.Message)
+	}
+
+	return nil
+}
+
+// Returns the subnet the addresses of pods are allocated from. In CRD mode, these are the secondary addresses of
+// the network containers provisioned through the NodeNetworkConfig, otherwise those of the primary interface.
+func (service *HTTPRestService) getPodSubnet() (string, error) {
+	if service.nncClient == nil {
+		ifInfo, err := service.imdsClient.GetPrimaryInterfaceInfoFromMemory()
+		if err != nil {
+			return "", fmt.Errorf("GetPrimaryIfaceInfo failed %v", err.Error())
+		}
+
+		return ifInfo.Subnet, nil
+	}
+
+	ncs := getProvisionedNetworkContainers(service.getSnapshot().containerStatus)
+	if len(ncs) == 0 {
+		return "", fmt.Errorf("No network container is provisioned in NodeNetworkConfig %v", service.nodeName)
+	}
+
+	subnet, err := getNetworkContainerSubnet(&ncs[0])
+	if err != nil {
+		return "", err
+	}
+
+	return subnet.String(), nil
+}
+
+// Returns the network containers provisioned through the NodeNetworkConfig, ordered by ID.
+func getProvisionedNetworkContainers(containerStatus map[string]containerstatus) []cns.CreateNetworkContainerRequest {
+	var ncs []cns.CreateNetworkContainerRequest
+	for _, status := range containerStatus {
+		if status.CreateNetworkContainerRequest.NetworkContainerType == cns.Docker {
+			ncs = append(ncs, status.CreateNetworkContainerRequest)
+		}
+	}
+
+	sort.Slice(ncs, func(i, j int) bool { return ncs[i].NetworkContainerid < ncs[j].NetworkContainerid })
+	return ncs
+}
+
+// Returns the subnet of a network container.
+func getNetworkContainerSubnet(nc *cns.CreateNetworkContainerRequest) (*net.IPNet, error) {
+	ipSubnet := nc.IPConfiguration.IPSubnet
+	ip := net.ParseIP(ipSubnet.IPAddress).To4()
+	if ip == nil || ipSubnet.PrefixLength > 32 {
+		return nil, fmt.Errorf("Invalid address %v/%v of network container %v", ipSubnet.IPAddress, ipSubnet.PrefixLength, nc.NetworkContainerid)
+	}
+
+	mask := net.CIDRMask(int(ipSubnet.PrefixLength), 32)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// Adds the addresses of network containers to the primary interface of an NMAgent interface document.
+// Secondary addresses are added to the subnet of their network container, and the primary address of the network
+// container as a primary address, so that it isn't allocated to pods.
+func addNetworkContainerAddresses(doc *nmagent.Interfaces, ncs []cns.CreateNetworkContainerRequest) error {
+	var primary *nmagent.Interface
+	for i := range doc.Interface {
+		if doc.Interface[i].IsPrimary {
+			primary = &doc.Interface[i]
+			break
+		}
+	}
+
+	if primary == nil {
+		return fmt.Errorf("Unable to find primary NIC")
+	}
+
+	for i := range ncs {
+		subnet, err := getNetworkContainerSubnet(&ncs[i])
+		if err != nil {
+			return err
+		}
+
+		var ipSubnet *nmagent.IPSubnet
+		for j := range primary.IPSubnet {
+			if _, prefix, err := net.ParseCIDR(primary.IPSubnet[j].Prefix); err == nil && prefix.String() == subnet.String() {
+				ipSubnet = &primary.IPSubnet[j]
+				break
+			}
+		}
+
+		if ipSubnet == nil {
+			primary.IPSubnet = append(primary.IPSubnet, nmagent.IPSubnet{Prefix: subnet.String()})
+			ipSubnet = &primary.IPSubnet[len(primary.IPSubnet)-1]
+		}
+
+		known := make(map[string]bool)
+		for _, address := range ipSubnet.IPAddress {
+			known[address.Address] = true
+		}
+
+		add := func(address string, isPrimary bool) {
+			if !known[address] {
+				ipSubnet.IPAddress = append(ipSubnet.IPAddress, nmagent.IPAddress{Address: address, IsPrimary: isPrimary})
+				known[address] = true
+			}
+		}
+
+		add(ncs[i].IPConfiguration.IPSubnet.IPAddress, true)
+		for _, address := range ncs[i].SecondaryIPAddresses {
+			add(address, false)
+		}
+	}
+
+	return nil
+}
+
+// Handles requests for the interfaces of the node, in the format of NMAgent, with the addresses of the network
+// containers provisioned through the NodeNetworkConfig. In CRD mode, the IPAM plugin queries them from CNS instead
+// of NMAgent, so that the secondary addresses of the network containers are allocated to pods.
+func (service *HTTPRestService) getInterfaces(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getInterfaces")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "[Azure CNS] Error. GetInterfaces did not receive a GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	doc, err := service.imdsClient.GetInterfaceInfoFromHost()
+	if err == nil {
+		err = addNetworkContainerAddresses(doc, getProvisionedNetworkContainers(service.getSnapshot().containerStatus))
+	}
+
+	if err != nil {
+		log.Errorf("[Azure CNS] Failed to get interfaces, err:%v.", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	if err := xml.NewEncoder(w).Encode(doc); err != nil {
+		log.Errorf("[Azure CNS] Failed to encode interfaces, err:%v.", err)
+	}
+}
+
+// Starts reconciling the NodeNetworkConfig of the node if CRD mode is enabled.
+func (service *HTTPRestService) startNodeNetworkConfig() error {
+	if enabled, _ := service.GetOption(acn.OptCnsCRDMode).(bool); !enabled {
+		return nil
+	}
+
+	nodeName, _ := service.GetOption(acn.OptNodeName).(string)
+	if nodeName == "" {
+		var err error
+		if nodeName, err = os.Hostname(); err != nil {
+			return err
+		}
+	}
+
+	clientset, err := service.getKubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	service.nncClient = nodenetworkconfig.NewClient(clientset.Discovery().RESTClient())
+	service.nodeName = nodeName
+	service.nncStop = make(chan struct{})
+
+	reconciler := nodenetworkconfig.NewReconciler(service.nncClient, nodeName, &ncStore{service: service})
+	go service.runNodeNetworkConfig(reconciler, service.nncStop)
+
+	log.Printf("[Azure CNS] Reconciling NodeNetworkConfig %v.", nodeName)
+	return nil
+}
+
+// Stops reconciling the NodeNetworkConfig.
+func (service *HTTPRestService) stopNodeNetworkConfig() {
+	if service.nncStop != nil {
+		close(service.nncStop)
+		service.nncStop = nil
+	}
+}
+
+// Reconciles the NodeNetworkConfig whenever it changes while this instance owns the node state.
+// The NodeNetworkConfig is watched from the version last reconciled, and reconciled again from scratch when the
+// watch ends and every resync interval, in case a change was missed.
+func (service *HTTPRestService) runNodeNetworkConfig(reconciler *nodenetworkconfig.Reconciler, stop chan struct{}) {
+	for {
+		resync := service.clock.After(nncResyncInterval)
+		resourceVersion := ""
+		if !service.isReadOnly() {
+			var err error
+			if resourceVersion, err = reconciler.Reconcile(); err != nil {
+				log.Errorf("[Azure CNS] Failed to reconcile NodeNetworkConfig %v, err:%v.", service.nodeName, err)
+				resync = service.clock.After(nncRetryInterval)
+			}
+		}
+
+		changes, stopWatch, err := service.nncClient.Watch(service.nodeName, resourceVersion)
+		if err != nil {
+			log.Errorf("[Azure CNS] Failed to watch NodeNetworkConfig %v, err:%v.", service.nodeName, err)
+			resync = service.clock.After(nncRetryInterval)
+			stopWatch = func() {}
+		}
+
+		if !service.watchNodeNetworkConfig(reconciler, changes, resync, stop) {
+			stopWatch()
+			return
+		}
+
+		stopWatch()
+	}
+}
+
+// Reconciles the NodeNetworkConfig on each change until the watch ends or resync fires, which happens sooner
+// after a failure. Returns false if stop is closed.
+func (service *HTTPRestService) watchNodeNetworkConfig(
+	reconciler *nodenetworkconfig.Reconciler,
+	changes <-chan *nodenetworkconfig.NodeNetworkConfig,
+	resync <-chan time.Time,
+	stop chan struct{}) bool {
+	for {
+		select {
+		case <-stop:
+			return false
+
+		case <-resync:
+			return true
+
+		case _, ok := <-changes:
+			if !ok {
+				// Wait before watching again, the API server may be unavailable.
+				select {
+				case <-stop:
+					return false
+				case <-service.clock.After(nncRetryInterval):
+					return true
+				}
+			}
+
+			if service.isReadOnly() {
+				continue
+			}
+
+			if _, err := reconciler.Reconcile(); err != nil {
+				log.Errorf("[Azure CNS] Failed to reconcile NodeNetworkConfig %v, err:%v.", service.nodeName, err)
+				resync = service.clock.After(nncRetryInterval)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/nodenetworkconfig"
+	"github.com/Azure/azure-container-networking/nmagent"
+)
+
+// fakeNodeNetworkConfigClient enables CRD mode without reaching the API server.
+type fakeNodeNetworkConfigClient struct {
+	nodenetworkconfig.Client
+}
+
+// Returns a network container provisioned through a NodeNetworkConfig.
+func newProvisionedNetworkContainer(id string, primaryIP string, prefixLength uint8, secondaryIPs ...string) cns.CreateNetworkContainerRequest {
+	return cns.CreateNetworkContainerRequest{
+		NetworkContainerid:   id,
+		NetworkContainerType: cns.Docker,
+		IPConfiguration: cns.IPConfiguration{
+			IPSubnet: cns.IPSubnet{IPAddress: primaryIP, PrefixLength: prefixLength},
+		},
+		SecondaryIPAddresses: secondaryIPs,
+	}
+}
+
+// Tests that the addresses of network containers are added to the subnets of the primary interface.
+func TestAddNetworkContainerAddresses(t *testing.T) {
+	doc := &nmagent.Interfaces{Interface: []nmagent.Interface{
+		{MacAddress: "000D3A000001", IsPrimary: false},
+		{MacAddress: "000D3A000002", IsPrimary: true, IPSubnet: []nmagent.IPSubnet{
+			{Prefix: "10.0.0.0/16", IPAddress: []nmagent.IPAddress{{Address: "10.0.0.4", IsPrimary: true}}},
+		}},
+	}}
+
+	ncs := []cns.CreateNetworkContainerRequest{
+		newProvisionedNetworkContainer("nc1", "10.0.0.4", 16, "10.0.0.5", "10.0.0.6"),
+		newProvisionedNetworkContainer("nc2", "10.1.0.4", 24, "10.1.0.5", "10.1.0.5"),
+	}
+
+	if err := addNetworkContainerAddresses(doc, ncs); err != nil {
+		t.Fatalf("Failed to add addresses: %v", err)
+	}
+
+	if len(doc.Interface[0].IPSubnet) != 0 {
+		t.Errorf("Addresses added to a secondary interface: %+v", doc.Interface[0])
+	}
+
+	subnets := doc.Interface[1].IPSubnet
+	if len(subnets) != 2 || subnets[0].Prefix != "10.0.0.0/16" || subnets[1].Prefix != "10.1.0.0/24" {
+		t.Fatalf("Unexpected subnets %+v", subnets)
+	}
+
+	expected := [][]nmagent.IPAddress{
+		{{Address: "10.0.0.4", IsPrimary: true}, {Address: "10.0.0.5"}, {Address: "10.0.0.6"}},
+		{{Address: "10.1.0.4", IsPrimary: true}, {Address: "10.1.0.5"}},
+	}
+
+	for i, addresses := range expected {
+		if len(subnets[i].IPAddress) != len(addresses) {
+			t.Errorf("Subnet %v has addresses %+v, expected %+v", subnets[i].Prefix, subnets[i].IPAddress, addresses)
+			continue
+		}
+
+		for j, address := range addresses {
+			if subnets[i].IPAddress[j] != address {
+				t.Errorf("Subnet %v has addresses %+v, expected %+v", subnets[i].Prefix, subnets[i].IPAddress, addresses)
+				break
+			}
+		}
+	}
+
+	if err := addNetworkContainerAddresses(&nmagent.Interfaces{}, ncs); err == nil {
+		t.Errorf("Missing primary interface was not reported")
+	}
+}
+
+// Tests that in CRD mode, addresses are allocated from the subnet of the provisioned network containers.
+func TestGetPodSubnetCRDMode(t *testing.T) {
+	svc := &HTTPRestService{nncClient: &fakeNodeNetworkConfigClient{}, nodeName: "node"}
+
+	if _, err := svc.getPodSubnet(); err == nil {
+		t.Errorf("Pod subnet returned without provisioned network containers")
+	}
+
+	svc.updateSnapshot(func(snapshot *stateSnapshot) {
+		snapshot.containerStatus = map[string]containerstatus{
+			"nc2":   {CreateNetworkContainerRequest: newProvisionedNetworkContainer("nc2", "10.2.0.4", 24)},
+			"nc1":   {CreateNetworkContainerRequest: newProvisionedNetworkContainer("nc1", "10.1.3.4", 16)},
+			"other": {CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{NetworkContainerid: "other", NetworkContainerType: cns.WebApps}},
+		}
+	})
+
+	if subnet, err := svc.getPodSubnet(); err != nil || subnet != "10.1.0.0/16" {
+		t.Errorf("getPodSubnet returned %v, %v, expected 10.1.0.0/16", subnet, err)
+	}
+}
+
+// Tests that the interfaces of the node are served in the format of NMAgent.
+func TestGetInterfaces(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, cns.GetInterfacesPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var doc nmagent.Interfaces
+	if err := xml.NewDecoder(w.Body).Decode(&doc); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GetInterfaces returned %v, err:%v", w.Code, err)
+	}
+
+	if len(doc.Interface) != 1 || !doc.Interface[0].IsPrimary || doc.Interface[0].IPSubnet[0].Prefix != "10.0.0.0/16" {
+		t.Errorf("Unexpected interfaces %+v", doc)
+	}
+}
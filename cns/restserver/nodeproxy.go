@@ -0,0 +1,65 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/cns/proxy"
+	acn "github.com/Azure/azure-container-networking/common"
+)
+
+// Starts the IMDS/wireserver and DNS proxies for network containers if their addresses are configured.
+func (service *HTTPRestService) startProxies() error {
+	httpAddress, _ := service.GetOption(acn.OptCnsHTTPProxyAddress).(string)
+	if httpAddress != "" {
+		httpProxy := proxy.NewHTTPProxy(service.isNetworkContainerIP)
+		httpProxy.ValidateToken = service.isPodTokenValid
+		if err := httpProxy.Start(httpAddress); err != nil {
+			return err
+		}
+		service.httpProxy = httpProxy
+	}
+
+	dnsAddress, _ := service.GetOption(acn.OptCnsDNSProxyAddress).(string)
+	if dnsAddress != "" {
+		dnsProxy := proxy.NewDNSProxy(service.isNetworkContainerIP)
+		if err := dnsProxy.Start(dnsAddress); err != nil {
+			service.stopProxies()
+			return err
+		}
+		service.dnsProxy = dnsProxy
+	}
+
+	return nil
+}
+
+// Stops the proxies if they are running.
+func (service *HTTPRestService) stopProxies() {
+	if service.httpProxy != nil {
+		service.httpProxy.Stop()
+		service.httpProxy = nil
+	}
+
+	if service.dnsProxy != nil {
+		service.dnsProxy.Stop()
+		service.dnsProxy = nil
+	}
+}
+
+// Returns whether an address is assigned to one of the network containers on this node.
+// Only network containers are allowed to use the proxies.
+func (service *HTTPRestService) isNetworkContainerIP(ip net.IP) bool {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	for _, containerStatus := range service.state.ContainerStatus {
+		ncIP := net.ParseIP(containerStatus.CreateNetworkContainerRequest.IPConfiguration.IPSubnet.IPAddress)
+		if ncIP != nil && ncIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
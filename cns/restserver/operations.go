@@ -0,0 +1,243 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Operation types.
+	operationCreateOrUpdateNetworkContainer = "CreateOrUpdateNetworkContainer"
+	operationDeleteNetworkContainer         = "DeleteNetworkContainer"
+	operationUpdateNetworkContainer         = "UpdateNetworkContainer"
+
+	// Completed operations are forgotten after this long.
+	operationRetention = time.Hour
+)
+
+// operation is an asynchronous operation persisted in the CNS state.
+// The request is kept so that operations interrupted by a restart can be resumed.
+type operation struct {
+	Status  cns.OperationStatus
+	Request json.RawMessage
+}
+
+// Returns whether the request asks to be processed asynchronously.
+func isAsyncRequest(r *http.Request) bool {
+	return r.URL.Query().Get(cns.AsyncQueryParameter) == "true"
+}
+
+// Generates a new random operation ID.
+func newOperationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Accepts a request for asynchronous processing and replies with the operation ID.
+func (service *HTTPRestService) acceptAsyncOperation(w http.ResponseWriter, opType string, req interface{}) {
+	var asyncResp cns.AsyncOperationResponse
+
+	id, err := service.startOperation(opType, req)
+	if err != nil {
+		asyncResp.Response.ReturnCode = UnexpectedError
+		asyncResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Failed to start %v operation %v", opType, err.Error())
+	} else {
+		asyncResp.OperationID = id
+		w.WriteHeader(http.StatusAccepted)
+	}
+
+	resp := asyncResp.Response
+	err = service.Listener.Encode(w, &asyncResp)
+	log.Response(service.Name, asyncResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+}
+
+// Records a new operation and starts executing it in the background.
+func (service *HTTPRestService) startOperation(opType string, req interface{}) (string, error) {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := newOperationID()
+	if err != nil {
+		return "", err
+	}
+
+	now := service.clock.Now()
+	op := &operation{
+		Status: cns.OperationStatus{
+			OperationID: id,
+			Type:        opType,
+			State:       cns.OperationPending,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		Request: raw,
+	}
+
+	service.lock.Lock()
+	if service.state.Operations == nil {
+		service.state.Operations = make(map[string]*operation)
+	}
+	service.pruneOperations(now)
+	service.state.Operations[id] = op
+	service.saveState()
+	service.lock.Unlock()
+
+	log.Printf("[Azure CNS] Started %v operation %v.", opType, id)
+
+	go service.runOperation(id)
+
+	return id, nil
+}
+
+// Forgets operations that completed more than operationRetention ago. Called with the service lock held.
+func (service *HTTPRestService) pruneOperations(now time.Time) {
+	for id, op := range service.state.Operations {
+		completed := op.Status.State == cns.OperationSucceeded || op.Status.State == cns.OperationFailed
+		if completed && now.Sub(op.Status.UpdatedAt) > operationRetention {
+			delete(service.state.Operations, id)
+		}
+	}
+}
+
+// Sets the state of an operation and persists it.
+func (service *HTTPRestService) setOperationState(id string, state string, result cns.Response) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	op, ok := service.state.Operations[id]
+	if !ok {
+		return
+	}
+
+	op.Status.State = state
+	op.Status.Result = result
+	op.Status.UpdatedAt = service.clock.Now()
+
+	service.saveState()
+}
+
+// Executes an operation and records its result.
+func (service *HTTPRestService) runOperation(id string) {
+	service.lock.Lock()
+	op, ok := service.state.Operations[id]
+	var opType string
+	var raw json.RawMessage
+	if ok {
+		opType = op.Status.Type
+		raw = op.Request
+	}
+	service.lock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	service.setOperationState(id, cns.OperationRunning, cns.Response{})
+
+	var result cns.Response
+
+	switch opType {
+	case operationCreateOrUpdateNetworkContainer:
+		var req cns.CreateNetworkContainerRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			result = cns.Response{ReturnCode: UnexpectedError, Message: err.Error()}
+			break
+		}
+		result = service.createOrUpdateNetworkContainerResponse(req).Response
+
+	case operationDeleteNetworkContainer:
+		var req cns.DeleteNetworkContainerRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			result = cns.Response{ReturnCode: UnexpectedError, Message: err.Error()}
+			break
+		}
+		result = service.deleteNetworkContainerResponse(req).Response
+
+	case operationUpdateNetworkContainer:
+		var req cns.CreateNetworkContainerRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			result = cns.Response{ReturnCode: UnexpectedError, Message: err.Error()}
+			break
+		}
+		result = service.updateNetworkContainerResponse(req).Response
+
+	default:
+		result = cns.Response{ReturnCode: UnexpectedError, Message: fmt.Sprintf("Unknown operation type %v", opType)}
+	}
+
+	state := cns.OperationSucceeded
+	if result.ReturnCode != Success {
+		state = cns.OperationFailed
+	}
+
+	log.Printf("[Azure CNS] %v operation %v completed, state:%v result:%+v.", opType, id, state, result)
+
+	service.setOperationState(id, state, result)
+}
+
+// Resumes the operations that did not complete before the last restart.
+func (service *HTTPRestService) resumeOperations() {
+	var ids []string
+
+	service.lock.Lock()
+	for id, op := range service.state.Operations {
+		if op.Status.State == cns.OperationPending || op.Status.State == cns.OperationRunning {
+			ids = append(ids, id)
+		}
+	}
+	service.lock.Unlock()
+
+	for _, id := range ids {
+		log.Printf("[Azure CNS] Resuming operation %v.", id)
+		go service.runOperation(id)
+	}
+}
+
+// Handles requests for the status of an asynchronous operation.
+func (service *HTTPRestService) getOperation(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getOperation")
+
+	var opResp cns.GetOperationResponse
+
+	switch r.Method {
+	case "GET":
+		id := path.Base(r.URL.Path)
+
+		service.lock.Lock()
+		op, ok := service.state.Operations[id]
+		if ok {
+			opResp.Operation = op.Status
+		}
+		service.lock.Unlock()
+
+		if !ok {
+			opResp.Response.ReturnCode = NotFound
+			opResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Operation %v not found", id)
+		}
+
+	default:
+		opResp.Response.ReturnCode = InvalidParameter
+		opResp.Response.Message = "[Azure CNS] Error. GetOperation did not receive a GET."
+	}
+
+	resp := opResp.Response
+	err := service.Listener.Encode(w, &opResp)
+	log.Response(service.Name, opResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// Returns the status of an operation queried through the service mux.
+func getOperationStatus(t *testing.T, id string) cns.GetOperationResponse {
+	var resp cns.GetOperationResponse
+
+	req, err := http.NewRequest(http.MethodGet, cns.GetOperationPath+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if err = decodeResponse(w, &resp); err != nil {
+		t.Fatalf("GetOperation failed, err:%v", err)
+	}
+
+	return resp
+}
+
+func TestAsyncDeleteNetworkContainer(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		state      string
+		returnCode int
+	}{
+		{name: "not found", id: "nc-async-missing", state: cns.OperationSucceeded, returnCode: Success},
+		{name: "not specified", id: "", state: cns.OperationFailed, returnCode: NetworkContainerNotSpecified},
+	}
+
+	for _, test := range tests {
+		var body bytes.Buffer
+		var asyncResp cns.AsyncOperationResponse
+
+		json.NewEncoder(&body).Encode(&cns.DeleteNetworkContainerRequest{NetworkContainerid: test.id})
+		req, err := http.NewRequest(http.MethodPost, cns.DeleteNetworkContainer+"?async=true", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("TestAsyncDeleteNetworkContainer failed @ %v: HTTP status %v", test.name, w.Code)
+		}
+
+		if err = json.NewDecoder(w.Body).Decode(&asyncResp); err != nil || asyncResp.OperationID == "" {
+			t.Fatalf("TestAsyncDeleteNetworkContainer failed @ %v: response %+v, err %v", test.name, asyncResp, err)
+		}
+
+		// Poll until the operation completes.
+		var resp cns.GetOperationResponse
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			resp = getOperationStatus(t, asyncResp.OperationID)
+			if resp.Operation.State == cns.OperationSucceeded || resp.Operation.State == cns.OperationFailed {
+				break
+			}
+		}
+
+		op := resp.Operation
+		if op.OperationID != asyncResp.OperationID || op.Type != operationDeleteNetworkContainer ||
+			op.State != test.state || op.Result.ReturnCode != test.returnCode {
+			t.Errorf("TestAsyncDeleteNetworkContainer failed @ %v: operation %+v", test.name, op)
+		}
+	}
+}
+
+func TestGetOperationNotFound(t *testing.T) {
+	resp := getOperationStatus(t, "unknown-operation")
+	if resp.Response.ReturnCode != NotFound {
+		t.Errorf("TestGetOperationNotFound failed, response %+v", resp.Response)
+	}
+}
+
+func TestRunOperationInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		opType  string
+		request string
+	}{
+		{name: "unknown type", opType: "Unknown", request: `{}`},
+		{name: "malformed create", opType: operationCreateOrUpdateNetworkContainer, request: `[]`},
+		{name: "malformed delete", opType: operationDeleteNetworkContainer, request: `[]`},
+		{name: "malformed update", opType: operationUpdateNetworkContainer, request: `[]`},
+	}
+
+	for _, test := range tests {
+		clock := platform.NewFakeClock(time.Unix(1000, 0))
+		svc := &HTTPRestService{clock: clock, state: &httpRestServiceState{}}
+		svc.state.Operations = map[string]*operation{
+			"op1": {
+				Status:  cns.OperationStatus{OperationID: "op1", Type: test.opType, State: cns.OperationPending},
+				Request: json.RawMessage(test.request),
+			},
+		}
+
+		svc.runOperation("op1")
+
+		status := svc.state.Operations["op1"].Status
+		if status.State != cns.OperationFailed || status.Result.ReturnCode != UnexpectedError || !status.UpdatedAt.Equal(clock.Now()) {
+			t.Errorf("TestRunOperationInvalid failed @ %v: status %+v", test.name, status)
+		}
+	}
+}
+
+func TestPruneOperations(t *testing.T) {
+	now := time.Unix(10000, 0)
+
+	tests := []struct {
+		name   string
+		state  string
+		age    time.Duration
+		pruned bool
+	}{
+		{name: "recent success", state: cns.OperationSucceeded, age: time.Minute, pruned: false},
+		{name: "old success", state: cns.OperationSucceeded, age: operationRetention + time.Second, pruned: true},
+		{name: "old failure", state: cns.OperationFailed, age: operationRetention + time.Second, pruned: true},
+		{name: "retention", state: cns.OperationFailed, age: operationRetention, pruned: false},
+		{name: "old pending", state: cns.OperationPending, age: 2 * operationRetention, pruned: false},
+		{name: "old running", state: cns.OperationRunning, age: 2 * operationRetention, pruned: false},
+	}
+
+	svc := &HTTPRestService{state: &httpRestServiceState{Operations: make(map[string]*operation)}}
+	for _, test := range tests {
+		svc.state.Operations[test.name] = &operation{
+			Status: cns.OperationStatus{OperationID: test.name, State: test.state, UpdatedAt: now.Add(-test.age)},
+		}
+	}
+
+	svc.pruneOperations(now)
+
+	for _, test := range tests {
+		if _, ok := svc.state.Operations[test.name]; ok == test.pruned {
+			t.Errorf("TestPruneOperations failed @ %v: pruned %v, expected %v", test.name, !ok, test.pruned)
+		}
+	}
+}
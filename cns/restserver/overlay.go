@@ -0,0 +1,209 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// overlayRoutesCache holds the overlay routes of the nodes of the cluster, kept current by watching the nodes.
+type overlayRoutesCache struct {
+	sync.Mutex
+	routes map[string]cns.OverlayRoute // Node name is key, nil while the nodes aren't watched.
+	stop   chan struct{}
+}
+
+// Returns the pod CIDRs of the nodes of the cluster and their internal addresses. The nodes are listed on the
+// first request, or after the watch ended, and watched afterwards.
+func (service *HTTPRestService) listOverlayRoutes() ([]cns.OverlayRoute, error) {
+	service.kubeClientLock.Lock()
+	if service.overlayRoutes == nil {
+		service.overlayRoutes = &overlayRoutesCache{stop: make(chan struct{})}
+	}
+	cache := service.overlayRoutes
+	service.kubeClientLock.Unlock()
+
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.routes != nil {
+		return cache.getRoutes(), nil
+	}
+
+	client, err := service.getKubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string]cns.OverlayRoute)
+	for _, route := range getOverlayRoutes(nodes.Items) {
+		routes[route.NodeName] = route
+	}
+
+	// The nodes are listed again on the next request if they can't be watched.
+	changes, err := client.CoreV1().Nodes().Watch(metav1.ListOptions{ResourceVersion: nodes.ResourceVersion})
+	if err != nil {
+		log.Errorf("[Azure CNS] Failed to watch nodes for the overlay routes, err:%v.", err)
+		return getSortedOverlayRoutes(routes), nil
+	}
+
+	cache.routes = routes
+	go cache.watch(changes)
+
+	return cache.getRoutes(), nil
+}
+
+// Stops watching the nodes for the overlay routes.
+func (service *HTTPRestService) stopOverlayRoutes() {
+	service.kubeClientLock.Lock()
+	cache := service.overlayRoutes
+	service.overlayRoutes = nil
+	service.kubeClientLock.Unlock()
+
+	if cache != nil {
+		close(cache.stop)
+	}
+}
+
+// Applies the changes of the nodes to the cache until the watch ends, the cache is then emptied.
+func (cache *overlayRoutesCache) watch(changes watch.Interface) {
+	defer changes.Stop()
+
+	for {
+		select {
+		case <-cache.stop:
+			return
+
+		case event, ok := <-changes.ResultChan():
+			cache.Lock()
+			if !ok || !cache.apply(event) {
+				log.Printf("[Azure CNS] Watch of nodes for the overlay routes ended.")
+				cache.routes = nil
+				cache.Unlock()
+				return
+			}
+			cache.Unlock()
+		}
+	}
+}
+
+// Applies a change of a node to the cache. Returns false if the event isn't a change of a node.
+// This function should only be called when the cache is locked.
+func (cache *overlayRoutesCache) apply(event watch.Event) bool {
+	node, ok := event.Object.(*corev1.Node)
+	if !ok {
+		return false
+	}
+
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		delete(cache.routes, node.Name)
+		for _, route := range getOverlayRoutes([]corev1.Node{*node}) {
+			cache.routes[route.NodeName] = route
+		}
+		return true
+
+	case watch.Deleted:
+		delete(cache.routes, node.Name)
+		return true
+	}
+
+	return false
+}
+
+// Returns the overlay routes in the cache, sorted by node name.
+// This function should only be called when the cache is locked.
+func (cache *overlayRoutesCache) getRoutes() []cns.OverlayRoute {
+	return getSortedOverlayRoutes(cache.routes)
+}
+
+// Returns the given overlay routes sorted by node name.
+func getSortedOverlayRoutes(routes map[string]cns.OverlayRoute) []cns.OverlayRoute {
+	sorted := make([]cns.OverlayRoute, 0, len(routes))
+	for _, route := range routes {
+		sorted = append(sorted, route)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].NodeName < sorted[j].NodeName })
+	return sorted
+}
+
+// getOverlayRoutes returns the overlay routes to the given nodes, sorted by node name.
+// Nodes without a pod CIDR or an IPv4 internal address are skipped.
+func getOverlayRoutes(nodes []corev1.Node) []cns.OverlayRoute {
+	routes := []cns.OverlayRoute{}
+
+	for _, node := range nodes {
+		if _, _, err := net.ParseCIDR(node.Spec.PodCIDR); err != nil {
+			continue
+		}
+
+		for _, addr := range node.Status.Addresses {
+			ip := net.ParseIP(addr.Address)
+			if addr.Type != corev1.NodeInternalIP || ip == nil || ip.To4() == nil {
+				continue
+			}
+
+			routes = append(routes, cns.OverlayRoute{
+				NodeName: node.Name,
+				NodeIP:   ip.String(),
+				PodCIDR:  node.Spec.PodCIDR,
+			})
+			break
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool { return routes[i].NodeName < routes[j].NodeName })
+	return routes
+}
+
+// Handles requests for the overlay routes of the cluster, programmed by the CNI plugin on overlay networks.
+func (service *HTTPRestService) getOverlayRoutes(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getOverlayRoutes")
+	log.Request(service.Name, "getOverlayRoutes", nil)
+
+	var routes []cns.OverlayRoute
+	returnCode := 0
+	returnMessage := ""
+
+	switch r.Method {
+	case "GET", "POST":
+		var err error
+		if routes, err = service.listOverlayRoutes(); err != nil {
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. Failed to list the overlay routes: %v", err)
+			returnCode = UnexpectedError
+		}
+
+	default:
+		returnMessage = "[Azure CNS] Error. GetOverlayRoutes did not receive a GET or POST."
+		returnCode = InvalidParameter
+	}
+
+	resp := &cns.GetOverlayRoutesResponse{
+		Response: cns.Response{
+			ReturnCode: returnCode,
+			Message:    returnMessage,
+		},
+		Routes: routes,
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
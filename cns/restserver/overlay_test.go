@@ -0,0 +1,106 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Returns a node with the given pod CIDR and internal address.
+func newOverlayNode(name string, podCIDR string, internalIP string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{PodCIDR: podCIDR},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: name},
+				{Type: corev1.NodeInternalIP, Address: internalIP},
+			},
+		},
+	}
+}
+
+func TestGetOverlayRoutes(t *testing.T) {
+	nodes := []corev1.Node{
+		*newOverlayNode("node-b", "10.244.2.0/24", "10.0.0.5"),
+		*newOverlayNode("node-a", "10.244.1.0/24", "10.0.0.4"),
+		*newOverlayNode("no-cidr", "", "10.0.0.6"),
+		*newOverlayNode("ipv6", "10.244.3.0/24", "fd00::7"),
+	}
+
+	expected := []cns.OverlayRoute{
+		{NodeName: "node-a", NodeIP: "10.0.0.4", PodCIDR: "10.244.1.0/24"},
+		{NodeName: "node-b", NodeIP: "10.0.0.5", PodCIDR: "10.244.2.0/24"},
+	}
+
+	if routes := getOverlayRoutes(nodes); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("getOverlayRoutes returned %+v, expected %+v", routes, expected)
+	}
+}
+
+func TestOverlayRoutesCacheApply(t *testing.T) {
+	cache := &overlayRoutesCache{routes: map[string]cns.OverlayRoute{
+		"node-a": {NodeName: "node-a", NodeIP: "10.0.0.4", PodCIDR: "10.244.1.0/24"},
+	}}
+
+	tests := []struct {
+		name     string
+		event    watch.Event
+		applied  bool
+		expected []cns.OverlayRoute
+	}{
+		{
+			name:    "added",
+			event:   watch.Event{Type: watch.Added, Object: newOverlayNode("node-b", "10.244.2.0/24", "10.0.0.5")},
+			applied: true,
+			expected: []cns.OverlayRoute{
+				{NodeName: "node-a", NodeIP: "10.0.0.4", PodCIDR: "10.244.1.0/24"},
+				{NodeName: "node-b", NodeIP: "10.0.0.5", PodCIDR: "10.244.2.0/24"},
+			},
+		},
+		{
+			name:    "address changed",
+			event:   watch.Event{Type: watch.Modified, Object: newOverlayNode("node-a", "10.244.1.0/24", "10.0.0.8")},
+			applied: true,
+			expected: []cns.OverlayRoute{
+				{NodeName: "node-a", NodeIP: "10.0.0.8", PodCIDR: "10.244.1.0/24"},
+				{NodeName: "node-b", NodeIP: "10.0.0.5", PodCIDR: "10.244.2.0/24"},
+			},
+		},
+		{
+			name:     "pod CIDR removed",
+			event:    watch.Event{Type: watch.Modified, Object: newOverlayNode("node-a", "", "10.0.0.8")},
+			applied:  true,
+			expected: []cns.OverlayRoute{{NodeName: "node-b", NodeIP: "10.0.0.5", PodCIDR: "10.244.2.0/24"}},
+		},
+		{
+			name:     "deleted",
+			event:    watch.Event{Type: watch.Deleted, Object: newOverlayNode("node-b", "10.244.2.0/24", "10.0.0.5")},
+			applied:  true,
+			expected: []cns.OverlayRoute{},
+		},
+		{
+			name:     "error",
+			event:    watch.Event{Type: watch.Error, Object: &metav1.Status{}},
+			expected: []cns.OverlayRoute{},
+		},
+	}
+
+	for _, test := range tests {
+		if applied := cache.apply(test.event); applied != test.applied {
+			t.Errorf("%v: apply returned %v", test.name, applied)
+		}
+
+		if routes := cache.getRoutes(); !reflect.DeepEqual(routes, test.expected) {
+			t.Errorf("%v: cache holds %+v, expected %+v", test.name, routes, test.expected)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// Reason and source of the events recorded for pod network failures.
+	podNetworkFailureReason = "FailedNetworkSetup"
+	podEventComponent       = "azure-cns"
+
+	// The repeated failures of a pod with the same error code are counted in one event,
+	// which is updated at most once per interval.
+	podEventInterval = time.Minute
+
+	// Failure records of pods that stopped failing for this long are dropped.
+	podEventRecordTTL = time.Hour
+)
+
+// podEventRecord tracks the event counting the repeated failures of a pod.
+type podEventRecord struct {
+	eventName   string
+	podUID      string
+	count       int32     // Failures counted in the event, including those not posted yet.
+	posted      time.Time // When the event was last created or updated.
+	lastFailure time.Time
+}
+
+// podEventLimiter aggregates the failures of pods, which the runtime retries every few seconds,
+// so that a failing pod does not flood the API server with events.
+type podEventLimiter struct {
+	sync.Mutex
+	records map[string]*podEventRecord
+}
+
+// Counts a failure with the given key, and returns a copy of its record if its event is due to be posted.
+func (limiter *podEventLimiter) observe(key string, now time.Time) (podEventRecord, bool) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	if limiter.records == nil {
+		limiter.records = make(map[string]*podEventRecord)
+	}
+
+	for k, record := range limiter.records {
+		if now.Sub(record.lastFailure) > podEventRecordTTL {
+			delete(limiter.records, k)
+		}
+	}
+
+	record := limiter.records[key]
+	if record == nil {
+		record = &podEventRecord{}
+		limiter.records[key] = record
+	}
+
+	record.count++
+	record.lastFailure = now
+
+	if !record.posted.IsZero() && now.Sub(record.posted) < podEventInterval {
+		return podEventRecord{}, false
+	}
+
+	// Marked as posted now, so that concurrent failures wait for the next interval.
+	record.posted = now
+
+	return *record, true
+}
+
+// Records the event that counts the failures with the given key.
+func (limiter *podEventLimiter) setEvent(key string, eventName string, podUID string) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	if record := limiter.records[key]; record != nil {
+		record.eventName = eventName
+		record.podUID = podUID
+	}
+}
+
+// Returns the client of the cluster CNS runs in, created on first use.
+func (service *HTTPRestService) getKubernetesClient() (kubernetes.Interface, error) {
+	service.kubeClientLock.Lock()
+	defer service.kubeClientLock.Unlock()
+
+	if service.kubeClient != nil {
+		return service.kubeClient, nil
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	service.kubeClient = clientset
+	return clientset, nil
+}
+
+// Records a failure to set up the network of a pod as a warning event on the pod,
+// so that it shows up when describing the pod. Repeated failures with the same error code
+// increase the count of the event instead of adding events.
+func (service *HTTPRestService) recordPodNetworkFailure(req *cns.ReportPodNetworkFailureRequest) error {
+	key := fmt.Sprintf("%v/%v/%v", req.PodNamespace, req.PodName, req.ErrorCode)
+	record, due := service.podEvents.observe(key, service.clock.Now())
+	if !due {
+		return nil
+	}
+
+	client, err := service.getKubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	// Events are matched to the pod by UID when describing it.
+	pod, err := client.CoreV1().Pods(req.PodNamespace).Get(req.PodName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	now := metav1.NewTime(service.clock.Now())
+	message := fmt.Sprintf("Azure CNI error code %v for container %v: %v", req.ErrorCode, req.ContainerID, req.Message)
+
+	// Events of a pod that was recreated with the same name are not reused.
+	if record.eventName != "" && record.podUID == string(pod.UID) {
+		event, err := client.CoreV1().Events(pod.Namespace).Get(record.eventName, metav1.GetOptions{})
+		if err == nil {
+			event.Count = record.count
+			event.Message = message
+			event.LastTimestamp = now
+			if _, err = client.CoreV1().Events(pod.Namespace).Update(event); err == nil {
+				return nil
+			}
+		}
+
+		// The event expired or was deleted, a new one is created.
+		log.Printf("[Azure CNS] Failed to update event %v of pod %v/%v, err:%v.", record.eventName, pod.Namespace, pod.Name, err)
+	}
+
+	nodeName, _ := service.GetOption(acn.OptNodeName).(string)
+
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", pod.Name, now.UnixNano()),
+			Namespace: pod.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       pod.Name,
+			Namespace:  pod.Namespace,
+			UID:        pod.UID,
+		},
+		Reason:         podNetworkFailureReason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: podEventComponent, Host: nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          record.count,
+	}
+
+	if _, err = client.CoreV1().Events(pod.Namespace).Create(event); err != nil {
+		return err
+	}
+
+	service.podEvents.setEvent(key, event.Name, string(pod.UID))
+	return nil
+}
+
+// Handles failures to set up the network of pods reported by the CNI plugin.
+func (service *HTTPRestService) reportPodNetworkFailure(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] reportPodNetworkFailure")
+
+	var req cns.ReportPodNetworkFailureRequest
+	returnCode := 0
+	returnMessage := ""
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		if req.PodName == "" || req.PodNamespace == "" {
+			returnMessage = "[Azure CNS] Error. Pod name and namespace are required."
+			returnCode = InvalidParameter
+			break
+		}
+
+		if err := service.recordPodNetworkFailure(&req); err != nil {
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. Failed to record network failure of pod %v/%v: %v",
+				req.PodNamespace, req.PodName, err)
+			returnCode = UnexpectedError
+		}
+
+	default:
+		returnMessage = "[Azure CNS] Error. ReportPodNetworkFailure did not receive a POST."
+		returnCode = InvalidParameter
+	}
+
+	resp := &cns.Response{
+		ReturnCode: returnCode,
+		Message:    returnMessage,
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+}
+
+// Handles requests for the annotations of a pod, read by the CNI plugin which has no access to the cluster.
+func (service *HTTPRestService) getPodAnnotations(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] getPodAnnotations")
+
+	var req cns.GetPodAnnotationsRequest
+	var resp cns.GetPodAnnotationsResponse
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		if req.PodName == "" || req.PodNamespace == "" {
+			resp.Response.Message = "[Azure CNS] Error. Pod name and namespace are required."
+			resp.Response.ReturnCode = InvalidParameter
+			break
+		}
+
+		client, err := service.getKubernetesClient()
+		if err == nil {
+			var pod *corev1.Pod
+			if pod, err = client.CoreV1().Pods(req.PodNamespace).Get(req.PodName, metav1.GetOptions{}); err == nil {
+				resp.Annotations = pod.Annotations
+			}
+		}
+
+		if err != nil {
+			resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Failed to get annotations of pod %v/%v: %v",
+				req.PodNamespace, req.PodName, err)
+			resp.Response.ReturnCode = UnexpectedError
+		}
+
+	default:
+		resp.Response.Message = "[Azure CNS] Error. GetPodAnnotations did not receive a POST."
+		resp.Response.ReturnCode = InvalidParameter
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
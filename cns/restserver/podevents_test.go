@@ -0,0 +1,46 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPodEventLimiter(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name  string
+		key   string
+		after time.Duration
+		due   bool
+		count int32
+	}{
+		{name: "first failure", key: "ns/pod/104", due: true, count: 1},
+		{name: "retry", key: "ns/pod/104", after: 5 * time.Second},
+		{name: "other error code", key: "ns/pod/110", after: 6 * time.Second, due: true, count: 1},
+		{name: "retry within interval", key: "ns/pod/104", after: 50 * time.Second},
+		{name: "retry after interval", key: "ns/pod/104", after: podEventInterval + time.Second, due: true, count: 4},
+		{name: "retry after record expired", key: "ns/pod/110", after: 2 * podEventRecordTTL, due: true, count: 1},
+	}
+
+	var limiter podEventLimiter
+	for _, test := range tests {
+		record, due := limiter.observe(test.key, start.Add(test.after))
+		if due != test.due || record.count != test.count {
+			t.Errorf("TestPodEventLimiter failed @ %v: due %v count %v, expected due %v count %v",
+				test.name, due, record.count, test.due, test.count)
+		}
+
+		if due {
+			limiter.setEvent(test.key, "pod.1", "uid")
+		}
+	}
+
+	// The record of the pod that stopped failing was dropped along with the expired record.
+	if len(limiter.records) != 1 {
+		t.Errorf("TestPodEventLimiter failed, %v records kept", len(limiter.records))
+	}
+}
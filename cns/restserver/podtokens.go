@@ -0,0 +1,385 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Size of the random part of the pod tokens.
+	podTokenSize = 32
+	// Size of the AES-256 key encrypting the pod tokens in the state.
+	podTokenKeySize = 32
+)
+
+var (
+	// Node-local key encrypting the pod tokens in the state, next to the CNS store.
+	podTokenKeyFile = platform.CNMRuntimePath + "azure-cns-pod-tokens.key"
+)
+
+// podToken is a token issued to a pod sandbox for a scope, persisted encrypted.
+type podToken struct {
+	ContainerID    string
+	PodName        string
+	PodNamespace   string
+	IPAddresses    []string
+	Scope          string
+	EncryptedToken []byte
+}
+
+// Returns the key of the token of a pod sandbox for a scope in the state.
+// Tokens are keyed by sandbox, so that a late DEL of a replaced sandbox doesn't revoke the token of its successor.
+func podTokenKey(containerID string, scope string) string {
+	return containerID + "/" + scope
+}
+
+// Returns whether the token was issued to the sandbox with the given address.
+func (token *podToken) hasIPAddress(ip net.IP) bool {
+	for _, address := range token.IPAddresses {
+		if tokenIP := net.ParseIP(address); tokenIP != nil && tokenIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the AES-GCM cipher of the node-local key, creating the key on first use.
+func newPodTokenCipher() (cipher.AEAD, error) {
+	key, err := ioutil.ReadFile(podTokenKeyFile)
+	if os.IsNotExist(err) {
+		key = make([]byte, podTokenKeySize)
+		if _, err = io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+
+		if err = os.MkdirAll(filepath.Dir(podTokenKeyFile), 0700); err != nil {
+			return nil, err
+		}
+
+		err = ioutil.WriteFile(podTokenKeyFile, key, 0600)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != podTokenKeySize {
+		return nil, fmt.Errorf("Invalid key in %v", podTokenKeyFile)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Encrypts a pod token with the node-local key.
+func encryptPodToken(token string) ([]byte, error) {
+	gcm, err := newPodTokenCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, []byte(token), nil), nil
+}
+
+// Decrypts a pod token encrypted by encryptPodToken.
+func decryptPodToken(data []byte) (string, error) {
+	gcm, err := newPodTokenCipher()
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("Encrypted token is truncated")
+	}
+
+	token, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(token), nil
+}
+
+// Returns the token of a pod sandbox for a scope, issuing one if the sandbox has none.
+// Tokens of other sandboxes for the scope holding the same addresses are revoked, since their addresses were reused.
+// The caller must hold the service lock.
+func (service *HTTPRestService) getOrIssuePodToken(req cns.IssuePodTokenRequest) (string, error) {
+	key := podTokenKey(req.ContainerID, req.Scope)
+	if existing, ok := service.state.PodTokens[key]; ok {
+		return decryptPodToken(existing.EncryptedToken)
+	}
+
+	random := make([]byte, podTokenSize)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(random)
+	encrypted, err := encryptPodToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	if service.state.PodTokens == nil {
+		service.state.PodTokens = make(map[string]*podToken)
+	}
+
+	issued := &podToken{
+		ContainerID:    req.ContainerID,
+		PodName:        req.PodName,
+		PodNamespace:   req.PodNamespace,
+		IPAddresses:    req.IPAddresses,
+		Scope:          req.Scope,
+		EncryptedToken: encrypted,
+	}
+
+	stale := make(map[string]*podToken)
+	for otherKey, other := range service.state.PodTokens {
+		if other.Scope != req.Scope {
+			continue
+		}
+
+		for _, address := range req.IPAddresses {
+			if ip := net.ParseIP(address); ip != nil && other.hasIPAddress(ip) {
+				stale[otherKey] = other
+				delete(service.state.PodTokens, otherKey)
+				break
+			}
+		}
+	}
+
+	service.state.PodTokens[key] = issued
+
+	if err = service.saveState(); err != nil {
+		delete(service.state.PodTokens, key)
+		for otherKey, other := range stale {
+			service.state.PodTokens[otherKey] = other
+		}
+		return "", err
+	}
+
+	for _, other := range stale {
+		log.Printf("[Azure CNS] Revoked token for scope %v of sandbox %v of pod %v/%v, its addresses were reused.",
+			other.Scope, other.ContainerID, other.PodNamespace, other.PodName)
+	}
+
+	log.Printf("[Azure CNS] Issued token for scope %v to sandbox %v of pod %v/%v.", req.Scope, req.ContainerID, req.PodNamespace, req.PodName)
+	return token, nil
+}
+
+// Revokes the tokens of a pod sandbox, and returns whether it had any. The caller must hold the service lock.
+func (service *HTTPRestService) removeSandboxTokens(containerID string) bool {
+	revoked := false
+	for key, token := range service.state.PodTokens {
+		if token.ContainerID == containerID {
+			delete(service.state.PodTokens, key)
+			revoked = true
+		}
+	}
+
+	if revoked {
+		log.Printf("[Azure CNS] Revoked tokens of sandbox %v.", containerID)
+	}
+
+	return revoked
+}
+
+// Revokes the tokens of all sandboxes of a pod, and returns whether it had any. The caller must hold the service lock.
+func (service *HTTPRestService) removePodTokens(podName string, podNamespace string) bool {
+	revoked := false
+	for key, token := range service.state.PodTokens {
+		if token.PodName == podName && token.PodNamespace == podNamespace {
+			delete(service.state.PodTokens, key)
+			revoked = true
+		}
+	}
+
+	if revoked {
+		log.Printf("[Azure CNS] Revoked tokens of pod %v/%v.", podNamespace, podName)
+	}
+
+	return revoked
+}
+
+// Returns how many tokens a pod holds. The caller must hold the service lock.
+func (service *HTTPRestService) countPodTokens(podName string, podNamespace string) int {
+	count := 0
+	for _, token := range service.state.PodTokens {
+		if token.PodName == podName && token.PodNamespace == podNamespace {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Returns the pod of the network container assigned an address. The caller must hold the service lock.
+func (service *HTTPRestService) getPodByNetworkContainerIP(ip net.IP) (cns.KubernetesPodInfo, bool) {
+	var podInfo cns.KubernetesPodInfo
+	for _, containerStatus := range service.state.ContainerStatus {
+		req := containerStatus.CreateNetworkContainerRequest
+		ncIP := net.ParseIP(req.IPConfiguration.IPSubnet.IPAddress)
+		if ncIP == nil || !ncIP.Equal(ip) {
+			continue
+		}
+
+		if err := json.Unmarshal(req.OrchestratorContext, &podInfo); err != nil || podInfo.PodName == "" {
+			return podInfo, false
+		}
+
+		return podInfo, true
+	}
+
+	return podInfo, false
+}
+
+// Returns whether a token was issued for a scope to the pod sandbox assigned an address.
+// Tokens are bound to the addresses the sandbox was given, which the HTTP proxy requires for the requests
+// to restricted endpoints. Tokens issued without addresses are bound to the network container of the pod.
+func (service *HTTPRestService) isPodTokenValid(ip net.IP, scope string, token string) bool {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	var ncPod *cns.KubernetesPodInfo
+	if podInfo, ok := service.getPodByNetworkContainerIP(ip); ok {
+		ncPod = &podInfo
+	}
+
+	for _, issued := range service.state.PodTokens {
+		if issued.Scope != scope {
+			continue
+		}
+
+		bound := issued.hasIPAddress(ip)
+		if len(issued.IPAddresses) == 0 && ncPod != nil {
+			bound = issued.PodName == ncPod.PodName && issued.PodNamespace == ncPod.PodNamespace
+		}
+
+		if !bound {
+			continue
+		}
+
+		expected, err := decryptPodToken(issued.EncryptedToken)
+		if err != nil {
+			log.Errorf("[Azure CNS] Failed to decrypt token of sandbox %v, err:%v.", issued.ContainerID, err)
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handles requests from the CNI plugin for the token of a pod for a scope.
+func (service *HTTPRestService) issuePodToken(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] issuePodToken")
+
+	var req cns.IssuePodTokenRequest
+	var resp cns.IssuePodTokenResponse
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		if req.ContainerID == "" || req.PodName == "" || req.PodNamespace == "" || req.Scope == "" {
+			resp.Response.Message = "[Azure CNS] Error. Sandbox container ID, pod name, namespace and token scope are required."
+			resp.Response.ReturnCode = InvalidParameter
+			break
+		}
+
+		service.lock.Lock()
+		resp.Token, err = service.getOrIssuePodToken(req)
+		service.lock.Unlock()
+
+		if err != nil {
+			resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Failed to issue token for scope %v to sandbox %v of pod %v/%v: %v",
+				req.Scope, req.ContainerID, req.PodNamespace, req.PodName, err)
+			resp.Response.ReturnCode = UnexpectedError
+		}
+
+	default:
+		resp.Response.Message = "[Azure CNS] Error. IssuePodToken did not receive a POST."
+		resp.Response.ReturnCode = InvalidParameter
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	// The token is not logged.
+	log.Response(service.Name, resp.Response, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
+
+// Handles requests from the CNI plugin to revoke the tokens of a deleted pod sandbox.
+func (service *HTTPRestService) revokePodTokens(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] revokePodTokens")
+
+	var req cns.RevokePodTokensRequest
+	var resp cns.RevokePodTokensResponse
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		if req.ContainerID == "" {
+			resp.Response.Message = "[Azure CNS] Error. Sandbox container ID is required."
+			resp.Response.ReturnCode = InvalidParameter
+			break
+		}
+
+		service.lock.Lock()
+		if service.removeSandboxTokens(req.ContainerID) {
+			err = service.saveState()
+		}
+		resp.Remaining = service.countPodTokens(req.PodName, req.PodNamespace)
+		service.lock.Unlock()
+
+		if err != nil {
+			resp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Failed to revoke tokens of sandbox %v: %v", req.ContainerID, err)
+			resp.Response.ReturnCode = UnexpectedError
+		}
+
+	default:
+		resp.Response.Message = "[Azure CNS] Error. RevokePodTokens did not receive a POST."
+		resp.Response.ReturnCode = InvalidParameter
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp.Response, resp.Response.ReturnCode, ReturnCodeToString(resp.Response.ReturnCode), err)
+}
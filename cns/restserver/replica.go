@@ -0,0 +1,193 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+// During upgrades two CNS instances may run on the same node. The instance holding the
+// store lock owns the node state, the other one serves read-only requests until the
+// owner releases the lock or stops refreshing it, and then takes over.
+const (
+	// Interval at which the owner refreshes the store lock and the standby tries to acquire it.
+	replicaLeaseRefreshInterval = 5 * time.Second
+
+	// A store lock that was not refreshed for this long belongs to an instance that is gone.
+	replicaLeaseTimeout = 30 * time.Second
+)
+
+var (
+	errReadOnlyReplica = fmt.Errorf("Another CNS instance owns the node state")
+)
+
+// Tries to become the owner of the node state by acquiring the store lock.
+// Stale locks left behind by crashed instances or before a reboot are broken.
+func (service *HTTPRestService) acquireStoreLease() bool {
+	err := service.store.Lock(false)
+	if err == nil {
+		return true
+	}
+
+	if err != store.ErrNonBlockingLockIsAlreadyLocked {
+		log.Errorf("[Azure CNS] Failed to lock store, err:%v.", err)
+		return false
+	}
+
+	lockFileModTime, err := service.store.GetLockFileModificationTime()
+	if err != nil {
+		return false
+	}
+
+	stale := service.clock.Since(lockFileModTime) > replicaLeaseTimeout
+	if rebootTime, err := platform.GetLastRebootTime(); err == nil && rebootTime.After(lockFileModTime) {
+		stale = true
+	}
+
+	if !stale {
+		return false
+	}
+
+	log.Printf("[Azure CNS] Breaking stale store lock last refreshed at %v.", lockFileModTime)
+
+	if err := service.store.Unlock(true); err != nil {
+		log.Errorf("[Azure CNS] Failed to force unlock store, err:%v.", err)
+		return false
+	}
+
+	return service.store.Lock(false) == nil
+}
+
+// Starts tracking ownership of the node state and returns whether this instance is the owner.
+func (service *HTTPRestService) startStoreLease() bool {
+	owner := service.store == nil || service.acquireStoreLease()
+
+	service.setReadOnly(!owner)
+
+	if service.store == nil {
+		return owner
+	}
+
+	if !owner {
+		log.Printf("[Azure CNS] Another CNS instance owns the node state, running read-only until it hands off.")
+	}
+
+	service.leaseStop = make(chan struct{})
+	go service.manageStoreLease(service.leaseStop)
+
+	return owner
+}
+
+// Stops tracking ownership of the node state and hands it off by releasing the store lock.
+func (service *HTTPRestService) stopStoreLease() {
+	if service.leaseStop == nil {
+		return
+	}
+
+	close(service.leaseStop)
+	service.leaseStop = nil
+
+	if !service.isReadOnly() {
+		if err := service.store.Unlock(false); err != nil {
+			log.Errorf("[Azure CNS] Failed to unlock store, err:%v.", err)
+		}
+	}
+}
+
+// Refreshes the store lock while this instance is the owner, and takes over once the owner is gone otherwise.
+func (service *HTTPRestService) manageStoreLease(stop chan struct{}) {
+	ticker := service.clock.NewTicker(replicaLeaseRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+
+		if !service.isReadOnly() {
+			if err := service.store.RefreshLock(); err != nil {
+				log.Errorf("[Azure CNS] Failed to refresh store lock, err:%v.", err)
+			}
+			continue
+		}
+
+		if !service.acquireStoreLease() {
+			continue
+		}
+
+		log.Printf("[Azure CNS] Took over the node state from the previous CNS instance.")
+
+		if err := service.takeOver(); err != nil {
+			log.Errorf("[Azure CNS] Failed to take over the node state, err:%v.", err)
+		}
+	}
+}
+
+// Reloads the state written by the previous owner and starts accepting changes.
+func (service *HTTPRestService) takeOver() error {
+	service.lock.Lock()
+	service.state = &httpRestServiceState{}
+	service.state.Networks = make(map[string]*networkInfo)
+	err := service.restoreState()
+	service.lock.Unlock()
+
+	service.setReadOnly(false)
+
+	if err != nil {
+		return err
+	}
+
+	if err := service.restoreNetworkState(); err != nil {
+		return err
+	}
+
+	service.resumeOperations()
+
+	return nil
+}
+
+// Returns whether another instance owns the node state.
+// The flag is accessed atomically since it is checked by callers holding the service lock.
+func (service *HTTPRestService) isReadOnly() bool {
+	return atomic.LoadInt32(&service.readOnly) != 0
+}
+
+// Sets whether another instance owns the node state.
+func (service *HTTPRestService) setReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+
+	atomic.StoreInt32(&service.readOnly, v)
+}
+
+// Wraps a handler that changes the node state so it is rejected while running read-only.
+func (service *HTTPRestService) ownerOnly(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !service.isReadOnly() {
+			handler(w, r)
+			return
+		}
+
+		resp := cns.Response{
+			ReturnCode: ReadOnlyReplica,
+			Message:    fmt.Sprintf("[Azure CNS] Error. %v.", errReadOnlyReplica),
+		}
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+		err := service.Listener.Encode(w, &resp)
+		log.Response(service.Name, resp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+	}
+}
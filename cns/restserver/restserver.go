@@ -8,19 +8,29 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/common"
+	"github.com/Azure/azure-container-networking/cns/dncclient"
 	"github.com/Azure/azure-container-networking/cns/dockerclient"
+	"github.com/Azure/azure-container-networking/cns/heartbeat"
 	"github.com/Azure/azure-container-networking/cns/imdsclient"
 	"github.com/Azure/azure-container-networking/cns/ipamclient"
+	"github.com/Azure/azure-container-networking/cns/ippool"
 	"github.com/Azure/azure-container-networking/cns/networkcontainers"
+	"github.com/Azure/azure-container-networking/cns/nodenetworkconfig"
+	"github.com/Azure/azure-container-networking/cns/proxy"
 	"github.com/Azure/azure-container-networking/cns/routes"
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/store"
+	"google.golang.org/grpc"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
@@ -41,6 +51,34 @@ type HTTPRestService struct {
 	state            *httpRestServiceState
 	lock             sync.Mutex
 	dncPartitionKey  string
+	rpcServer        *grpc.Server
+	readOnly         int32
+	leaseStop        chan struct{}
+	httpProxy        *proxy.HTTPProxy
+	dnsProxy         *proxy.DNSProxy
+	ipPoolManager    *ippool.Manager
+	ipPoolStop       chan struct{}
+	heartbeat        *heartbeat.Reporter
+	heartbeatStop    chan struct{}
+	dncClient        *dncclient.DncClient
+	nncClient        nodenetworkconfig.Client
+	nodeName         string
+	nncStop          chan struct{}
+	clock            platform.Clock
+	kubeClient       kubernetes.Interface
+	kubeClientLock   sync.Mutex
+	podEvents        podEventLimiter
+	overlayRoutes    *overlayRoutesCache
+	// Snapshot of the state serving read-only queries, holds a *stateSnapshot.
+	snapshot              atomic.Value
+	snapshotLock          sync.Mutex
+	utilizationRefreshing int32
+	adminListener         *acn.Listener
+	adminLock             sync.Mutex
+	logLevelOverride      *logLevelOverride
+	apiMetrics            map[string]*cns.APIRouteMetrics // Version and path are key.
+	apiMetricsLock        sync.Mutex
+	cniExecutor           cns.CNIExecutor
 }
 
 // containerstatus is used to save status of an existing container
@@ -60,6 +98,10 @@ type httpRestServiceState struct {
 	ContainerIDByOrchestratorContext map[string]string          // OrchestratorContext is key and value is NetworkContainerID.
 	ContainerStatus                  map[string]containerstatus // NetworkContainerID is key.
 	Networks                         map[string]*networkInfo
+	Operations                       map[string]*operation // OperationID is key.
+	IPReservations                   map[string]string     // ReservationID is key and value is the reserved IP address.
+	PodTokens                        map[string]*podToken  // Sandbox container ID and token scope are key.
+	Draining                         bool
 	TimeStamp                        time.Time
 }
 
@@ -107,6 +149,7 @@ func NewHTTPRestService(config *common.ServiceConfig) (HTTPService, error) {
 		networkContainer: nc,
 		routingTable:     routingTable,
 		state:            serviceState,
+		clock:            platform.NewClock(),
 	}, nil
 
 }
@@ -120,51 +163,65 @@ func (service *HTTPRestService) Start(config *common.ServiceConfig) error {
 		return err
 	}
 
+	owner := service.startStoreLease()
+
 	err = service.restoreState()
 	if err != nil {
 		log.Errorf("[Azure CNS]  Failed to restore service state, err:%v.", err)
 		return err
 	}
 
-	err = service.restoreNetworkState()
-	if err != nil {
-		log.Errorf("[Azure CNS]  Failed to restore network state, err:%v.", err)
-		return err
+	// Only the owner of the node state restores the network state, the standby does it on takeover.
+	if owner {
+		err = service.restoreNetworkState()
+		if err != nil {
+			log.Errorf("[Azure CNS]  Failed to restore network state, err:%v.", err)
+			return err
+		}
+
+		service.resumeOperations()
 	}
 
 	// Add handlers.
 	listener := service.Listener
-	// default handlers
-	listener.AddHandler(cns.SetEnvironmentPath, service.setEnvironment)
-	listener.AddHandler(cns.CreateNetworkPath, service.createNetwork)
-	listener.AddHandler(cns.DeleteNetworkPath, service.deleteNetwork)
-	listener.AddHandler(cns.ReserveIPAddressPath, service.reserveIPAddress)
-	listener.AddHandler(cns.ReleaseIPAddressPath, service.releaseIPAddress)
-	listener.AddHandler(cns.GetHostLocalIPPath, service.getHostLocalIP)
-	listener.AddHandler(cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
-	listener.AddHandler(cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
-	listener.AddHandler(cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
-	listener.AddHandler(cns.DeleteNetworkContainer, service.deleteNetworkContainer)
-	listener.AddHandler(cns.GetNetworkContainerStatus, service.getNetworkContainerStatus)
-	listener.AddHandler(cns.GetInterfaceForContainer, service.getInterfaceForContainer)
-	listener.AddHandler(cns.SetOrchestratorType, service.setOrchestratorType)
-	listener.AddHandler(cns.GetNetworkContainerByOrchestratorContext, service.getNetworkContainerByOrchestratorContext)
-
-	// handlers for v0.2
-	listener.AddHandler(cns.V2Prefix+cns.SetEnvironmentPath, service.setEnvironment)
-	listener.AddHandler(cns.V2Prefix+cns.CreateNetworkPath, service.createNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.DeleteNetworkPath, service.deleteNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.ReserveIPAddressPath, service.reserveIPAddress)
-	listener.AddHandler(cns.V2Prefix+cns.ReleaseIPAddressPath, service.releaseIPAddress)
-	listener.AddHandler(cns.V2Prefix+cns.GetHostLocalIPPath, service.getHostLocalIP)
-	listener.AddHandler(cns.V2Prefix+cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
-	listener.AddHandler(cns.V2Prefix+cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
-	listener.AddHandler(cns.V2Prefix+cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
-	listener.AddHandler(cns.V2Prefix+cns.DeleteNetworkContainer, service.deleteNetworkContainer)
-	listener.AddHandler(cns.V2Prefix+cns.GetNetworkContainerStatus, service.getNetworkContainerStatus)
-	listener.AddHandler(cns.V2Prefix+cns.GetInterfaceForContainer, service.getInterfaceForContainer)
-	listener.AddHandler(cns.V2Prefix+cns.SetOrchestratorType, service.setOrchestratorType)
-	listener.AddHandler(cns.V2Prefix+cns.GetNetworkContainerByOrchestratorContext, service.getNetworkContainerByOrchestratorContext)
+	service.addAPIHandlers(listener)
+	service.addProfilerHandlers(listener)
+
+	err = service.startRPCServer()
+	if err != nil {
+		log.Errorf("[Azure CNS]  Failed to start gRPC server, err:%v.", err)
+		return err
+	}
+
+	err = service.startAdminServer()
+	if err != nil {
+		log.Errorf("[Azure CNS]  Failed to start admin API, err:%v.", err)
+		return err
+	}
+
+	err = service.startProxies()
+	if err != nil {
+		log.Errorf("[Azure CNS]  Failed to start proxies, err:%v.", err)
+		return err
+	}
+
+	err = service.startNodeNetworkConfig()
+	if err != nil {
+		log.Errorf("[Azure CNS]  Failed to start NodeNetworkConfig reconciliation, err:%v.", err)
+		return err
+	}
+
+	err = service.startIPPoolManager()
+	if err != nil {
+		log.Errorf("[Azure CNS]  Failed to start IP pool manager, err:%v.", err)
+		return err
+	}
+
+	err = service.startHeartbeat()
+	if err != nil {
+		log.Errorf("[Azure CNS]  Failed to start node heartbeat, err:%v.", err)
+		return err
+	}
 
 	log.Printf("[Azure CNS]  Listening.")
 	return nil
@@ -172,6 +229,14 @@ func (service *HTTPRestService) Start(config *common.ServiceConfig) error {
 
 // Stop stops the CNS.
 func (service *HTTPRestService) Stop() {
+	service.stopHeartbeat()
+	service.stopIPPoolManager()
+	service.stopNodeNetworkConfig()
+	service.stopOverlayRoutes()
+	service.stopProxies()
+	service.stopRPCServer()
+	service.stopAdminServer()
+	service.stopStoreLease()
 	service.Uninitialize()
 	log.Printf("[Azure CNS]  Service stopped.")
 }
@@ -288,7 +353,7 @@ func (service *HTTPRestService) createNetwork(w http.ResponseWriter, r *http.Req
 					}
 				} else {
 					returnMessage = fmt.Sprintf("[Azure CNS] Received a request to create an already existing network %v", req.NetworkName)
-					log.Printf(returnMessage)
+					log.Printf("%s", returnMessage)
 				}
 
 			default:
@@ -377,10 +442,8 @@ func (service *HTTPRestService) reserveIPAddress(w http.ResponseWriter, r *http.
 	log.Printf("[Azure CNS] reserveIPAddress")
 
 	var req cns.ReserveIPAddressRequest
-	returnMessage := ""
-	returnCode := 0
-	addr := ""
-	address := ""
+	var reserveResp cns.ReserveIPAddressResponse
+
 	err := service.Listener.Decode(w, r, &req)
 
 	log.Request(service.Name, &req, err)
@@ -389,55 +452,245 @@ func (service *HTTPRestService) reserveIPAddress(w http.ResponseWriter, r *http.
 		return
 	}
 
+	switch r.Method {
+	case "POST":
+		reserveResp = service.reserveIPAddressResponse(req)
+
+	default:
+		reserveResp.Response.Message = "[Azure CNS] Error. ReserveIP did not receive a POST."
+		reserveResp.Response.ReturnCode = InvalidParameter
+	}
+
+	resp := reserveResp.Response
+	err = service.Listener.Encode(w, &reserveResp)
+	log.Response(service.Name, reserveResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+}
+
+// Reserves an ip address from the primary interface subnet.
+func (service *HTTPRestService) reserveIPAddressResponse(req cns.ReserveIPAddressRequest) cns.ReserveIPAddressResponse {
+	return service.reserveIPAddressAt(req, "")
+}
+
+// Reserves the given address for the reservation, or any address if it is empty.
+func (service *HTTPRestService) reserveIPAddressAt(req cns.ReserveIPAddressRequest, address string) cns.ReserveIPAddressResponse {
+	var reserveResp cns.ReserveIPAddressResponse
+
 	if req.ReservationID == "" {
-		returnCode = ReservationNotFound
-		returnMessage = fmt.Sprintf("[Azure CNS] Error. ReservationId is empty")
+		reserveResp.Response.ReturnCode = ReservationNotFound
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. ReservationId is empty")
+		return reserveResp
+	}
+
+	if service.isDraining() {
+		reserveResp.Response.ReturnCode = NodeDraining
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. %v", errNodeDraining)
+		return reserveResp
+	}
+
+	poolID, err := service.getPrimaryPoolID()
+	if err != nil {
+		reserveResp.Response.ReturnCode = UnexpectedError
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. %v", err.Error())
+		return reserveResp
+	}
+
+	addr, err := service.ipamClient.ReserveIPAddressAt(poolID, req.ReservationID, address)
+	if err != nil {
+		reserveResp.Response.ReturnCode = AddressUnavailable
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] ReserveIpAddress failed with %+v", err.Error())
+		return reserveResp
+	}
+
+	addressIP, _, err := net.ParseCIDR(addr)
+	if err != nil {
+		reserveResp.Response.ReturnCode = UnexpectedError
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] ParseCIDR failed with %+v", err.Error())
+		return reserveResp
+	}
+
+	reserveResp.IPAddress = addressIP.String()
+	service.recordIPReservations(map[string]string{req.ReservationID: reserveResp.IPAddress})
+	service.invalidateUtilization()
+
+	return reserveResp
+}
+
+// Handles release ip reservation requests.
+func (service *HTTPRestService) releaseIPAddress(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] releaseIPAddress")
+
+	var req cns.ReleaseIPAddressRequest
+	var resp cns.Response
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+
+	if err != nil {
+		return
 	}
 
 	switch r.Method {
 	case "POST":
-		ic := service.ipamClient
+		resp = service.releaseIPAddressResponse(req)
 
-		ifInfo, err := service.imdsClient.GetPrimaryInterfaceInfoFromMemory()
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetPrimaryIfaceInfo failed %v", err.Error())
-			returnCode = UnexpectedError
+	default:
+		resp.Message = "[Azure CNS] Error. ReleaseIP did not receive a POST."
+		resp.ReturnCode = InvalidParameter
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+}
+
+// Releases an ip address reservation.
+func (service *HTTPRestService) releaseIPAddressResponse(req cns.ReleaseIPAddressRequest) cns.Response {
+	var resp cns.Response
+
+	if req.ReservationID == "" {
+		resp.ReturnCode = ReservationNotFound
+		resp.Message = fmt.Sprintf("[Azure CNS] Error. ReservationId is empty")
+		return resp
+	}
+
+	poolID, err := service.getPrimaryPoolID()
+	if err != nil {
+		resp.ReturnCode = UnexpectedError
+		resp.Message = fmt.Sprintf("[Azure CNS] Error. %v", err.Error())
+		return resp
+	}
+
+	err = service.ipamClient.ReleaseIPAddress(poolID, req.ReservationID)
+	if err != nil {
+		resp.ReturnCode = ReservationNotFound
+		resp.Message = fmt.Sprintf("[Azure CNS] ReleaseIpAddress failed with %+v", err.Error())
+	} else {
+		service.forgetIPReservations([]string{req.ReservationID})
+		service.invalidateUtilization()
+	}
+
+	return resp
+}
+
+// Returns the ID of the address pool the addresses of pods are allocated from.
+func (service *HTTPRestService) getPrimaryPoolID() (string, error) {
+	ic := service.ipamClient
+
+	subnet, err := service.getPodSubnet()
+	if err != nil {
+		return "", err
+	}
+
+	asID, err := ic.GetAddressSpace()
+	if err != nil {
+		return "", fmt.Errorf("GetAddressSpace failed %v", err.Error())
+	}
+
+	poolID, err := ic.GetPoolID(asID, subnet)
+	if err != nil {
+		return "", fmt.Errorf("GetPoolID failed %v", err.Error())
+	}
+
+	return poolID, nil
+}
+
+// Handles batch ip reservation requests.
+// The addresses are reserved atomically, either all of them are reserved or none.
+func (service *HTTPRestService) batchReserveIPAddress(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] batchReserveIPAddress")
+
+	var req cns.BatchReserveIPAddressRequest
+	returnMessage := ""
+	returnCode := 0
+	addresses := make(map[string]string)
+
+	err := service.Listener.Decode(w, r, &req)
+	log.Request(service.Name, &req, err)
+
+	if err != nil {
+		return
+	}
+
+	// A reservation ID repeated in the batch would be released twice by a rollback.
+	reservationIDs := make(map[string]bool)
+	for _, reservationID := range req.ReservationIDs {
+		if reservationID == "" {
+			returnCode = ReservationNotFound
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. ReservationId is empty")
 			break
 		}
 
-		asID, err := ic.GetAddressSpace()
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetAddressSpace failed %v", err.Error())
-			returnCode = UnexpectedError
+		if reservationIDs[reservationID] {
+			returnCode = InvalidParameter
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. ReservationId %v is duplicated", reservationID)
 			break
 		}
+		reservationIDs[reservationID] = true
+	}
 
-		poolID, err := ic.GetPoolID(asID, ifInfo.Subnet)
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetPoolID failed %v", err.Error())
-			returnCode = UnexpectedError
+	if len(req.ReservationIDs) == 0 {
+		returnCode = InvalidParameter
+		returnMessage = fmt.Sprintf("[Azure CNS] Error. ReservationIds are empty")
+	}
+
+	switch r.Method {
+	case "POST":
+		if returnCode != 0 {
 			break
 		}
 
-		addr, err = ic.ReserveIPAddress(poolID, req.ReservationID)
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] ReserveIpAddress failed with %+v", err.Error())
-			returnCode = AddressUnavailable
+		if service.isDraining() {
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. %v", errNodeDraining)
+			returnCode = NodeDraining
 			break
 		}
 
-		addressIP, _, err := net.ParseCIDR(addr)
+		ic := service.ipamClient
+
+		poolID, err := service.getPrimaryPoolID()
 		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] ParseCIDR failed with %+v", err.Error())
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. %v", err.Error())
 			returnCode = UnexpectedError
 			break
 		}
-		address = addressIP.String()
+
+		var reserved []string
+		for _, reservationID := range req.ReservationIDs {
+			addr, err := ic.ReserveIPAddress(poolID, reservationID)
+			if err != nil {
+				returnMessage = fmt.Sprintf("[Azure CNS] ReserveIpAddress failed for %v with %+v", reservationID, err.Error())
+				returnCode = AddressUnavailable
+				break
+			}
+			reserved = append(reserved, reservationID)
+
+			addressIP, _, err := net.ParseCIDR(addr)
+			if err != nil {
+				returnMessage = fmt.Sprintf("[Azure CNS] ParseCIDR failed with %+v", err.Error())
+				returnCode = UnexpectedError
+				break
+			}
+
+			addresses[reservationID] = addressIP.String()
+		}
+
+		// Roll back the partial reservation.
+		if returnCode != 0 {
+			for _, reservationID := range reserved {
+				if err := ic.ReleaseIPAddress(poolID, reservationID); err != nil {
+					log.Printf("[Azure CNS] Failed to release reservation %v, err:%v", reservationID, err)
+				}
+			}
+
+			addresses = nil
+		} else {
+			service.recordIPReservations(addresses)
+		}
+
+		service.invalidateUtilization()
 
 	default:
-		returnMessage = "[Azure CNS] Error. ReserveIP did not receive a POST."
+		returnMessage = "[Azure CNS] Error. BatchReserveIP did not receive a POST."
 		returnCode = InvalidParameter
-
 	}
 
 	resp := cns.Response{
@@ -445,16 +698,17 @@ func (service *HTTPRestService) reserveIPAddress(w http.ResponseWriter, r *http.
 		Message:    returnMessage,
 	}
 
-	reserveResp := &cns.ReserveIPAddressResponse{Response: resp, IPAddress: address}
+	reserveResp := &cns.BatchReserveIPAddressResponse{Response: resp, IPAddresses: addresses}
 	err = service.Listener.Encode(w, &reserveResp)
 	log.Response(service.Name, reserveResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
 }
 
-// Handles release ip reservation requests.
-func (service *HTTPRestService) releaseIPAddress(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[Azure CNS] releaseIPAddress")
+// Handles batch release ip reservation requests.
+// All reservations are attempted and the ones that failed are reported.
+func (service *HTTPRestService) batchReleaseIPAddress(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Azure CNS] batchReleaseIPAddress")
 
-	var req cns.ReleaseIPAddressRequest
+	var req cns.BatchReleaseIPAddressRequest
 	returnMessage := ""
 	returnCode := 0
 
@@ -465,44 +719,46 @@ func (service *HTTPRestService) releaseIPAddress(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if req.ReservationID == "" {
-		returnCode = ReservationNotFound
-		returnMessage = fmt.Sprintf("[Azure CNS] Error. ReservationId is empty")
+	if len(req.ReservationIDs) == 0 {
+		returnCode = InvalidParameter
+		returnMessage = fmt.Sprintf("[Azure CNS] Error. ReservationIds are empty")
 	}
 
 	switch r.Method {
 	case "POST":
-		ic := service.ipamClient
-
-		ifInfo, err := service.imdsClient.GetPrimaryInterfaceInfoFromMemory()
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetPrimaryIfaceInfo failed %v", err.Error())
-			returnCode = UnexpectedError
+		if returnCode != 0 {
 			break
 		}
 
-		asID, err := ic.GetAddressSpace()
+		ic := service.ipamClient
+
+		poolID, err := service.getPrimaryPoolID()
 		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetAddressSpace failed %v", err.Error())
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. %v", err.Error())
 			returnCode = UnexpectedError
 			break
 		}
 
-		poolID, err := ic.GetPoolID(asID, ifInfo.Subnet)
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetPoolID failed %v", err.Error())
-			returnCode = UnexpectedError
-			break
+		var failed, released []string
+		for _, reservationID := range req.ReservationIDs {
+			if err := ic.ReleaseIPAddress(poolID, reservationID); err != nil {
+				log.Printf("[Azure CNS] ReleaseIpAddress failed for %v with %+v", reservationID, err.Error())
+				failed = append(failed, reservationID)
+				continue
+			}
+			released = append(released, reservationID)
 		}
 
-		err = ic.ReleaseIPAddress(poolID, req.ReservationID)
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] ReleaseIpAddress failed with %+v", err.Error())
+		service.forgetIPReservations(released)
+		service.invalidateUtilization()
+
+		if len(failed) > 0 {
+			returnMessage = fmt.Sprintf("[Azure CNS] ReleaseIpAddress failed for %v", strings.Join(failed, ","))
 			returnCode = ReservationNotFound
 		}
 
 	default:
-		returnMessage = "[Azure CNS] Error. ReleaseIP did not receive a POST."
+		returnMessage = "[Azure CNS] Error. BatchReleaseIP did not receive a POST."
 		returnCode = InvalidParameter
 	}
 
@@ -580,35 +836,16 @@ func (service *HTTPRestService) getIPAddressUtilization(w http.ResponseWriter, r
 
 	switch r.Method {
 	case "GET":
-		ic := service.ipamClient
-
-		ifInfo, err := service.imdsClient.GetPrimaryInterfaceInfoFromMemory()
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetPrimaryIfaceInfo failed %v", err.Error())
-			returnCode = UnexpectedError
-			break
-		}
-
-		asID, err := ic.GetAddressSpace()
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetAddressSpace failed %v", err.Error())
-			returnCode = UnexpectedError
-			break
-		}
-
-		poolID, err := ic.GetPoolID(asID, ifInfo.Subnet)
-		if err != nil {
-			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetPoolID failed %v", err.Error())
-			returnCode = UnexpectedError
-			break
-		}
-
-		capacity, available, unhealthyAddrs, err = ic.GetIPAddressUtilization(poolID)
+		utilization, err := service.getUtilization()
 		if err != nil {
 			returnMessage = fmt.Sprintf("[Azure CNS] Error. GetIPUtilization failed %v", err.Error())
 			returnCode = UnexpectedError
 			break
 		}
+
+		capacity = utilization.capacity
+		available = utilization.available
+		unhealthyAddrs = utilization.unhealthyAddrs
 		log.Printf("[Azure CNS] Capacity %v Available %v UnhealthyAddrs %v", capacity, available, unhealthyAddrs)
 
 	default:
@@ -772,8 +1009,14 @@ func (service *HTTPRestService) saveState() error {
 		return nil
 	}
 
+	// Never overwrite the state of the instance owning the store.
+	if service.isReadOnly() {
+		log.Errorf("[Azure CNS]  Failed to save state, err:%v\n", errReadOnlyReplica)
+		return errReadOnlyReplica
+	}
+
 	// Update time stamp.
-	service.state.TimeStamp = time.Now()
+	service.state.TimeStamp = service.clock.Now()
 	err := service.store.Write(storeKey, &service.state)
 	if err == nil {
 		log.Printf("[Azure CNS]  State saved successfully.\n")
@@ -807,6 +1050,9 @@ func (service *HTTPRestService) restoreState() error {
 		return err
 	}
 
+	service.publishContainerStatus()
+	service.setNetworkContainerIsolation()
+
 	log.Printf("[Azure CNS]  Restored state, %+v\n", service.state)
 	return nil
 }
@@ -815,15 +1061,24 @@ func (service *HTTPRestService) setOrchestratorType(w http.ResponseWriter, r *ht
 	log.Printf("[Azure CNS] setOrchestratorType")
 
 	var req cns.SetOrchestratorTypeRequest
-	returnMessage := ""
-	returnCode := 0
 
 	err := service.Listener.Decode(w, r, &req)
 	if err != nil {
 		return
 	}
 
+	resp := service.setOrchestratorTypeResponse(req)
+
+	err = service.Listener.Encode(w, &resp)
+	log.Response(service.Name, resp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+}
+
+// Sets the orchestrator type and DNC partition key of the node.
+func (service *HTTPRestService) setOrchestratorTypeResponse(req cns.SetOrchestratorTypeRequest) cns.Response {
+	var resp cns.Response
+
 	service.lock.Lock()
+	defer service.lock.Unlock()
 
 	service.dncPartitionKey = req.DncPartitionKey
 
@@ -831,20 +1086,17 @@ func (service *HTTPRestService) setOrchestratorType(w http.ResponseWriter, r *ht
 	case cns.ServiceFabric, cns.Kubernetes, cns.WebApps:
 		service.state.OrchestratorType = req.OrchestratorType
 		service.saveState()
-	default:
-		returnMessage = fmt.Sprintf("Invalid Orchestrator type %v", req.OrchestratorType)
-		returnCode = UnsupportedOrchestratorType
-	}
-
-	service.lock.Unlock()
 
-	resp := cns.Response{
-		ReturnCode: returnCode,
-		Message:    returnMessage,
+		// Registration is reported to DNC right away instead of at the next heartbeat.
+		if service.heartbeat != nil {
+			service.heartbeat.Trigger()
+		}
+	default:
+		resp.Message = fmt.Sprintf("Invalid Orchestrator type %v", req.OrchestratorType)
+		resp.ReturnCode = UnsupportedOrchestratorType
 	}
 
-	err = service.Listener.Encode(w, &resp)
-	log.Response(service.Name, resp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+	return resp
 }
 
 func (service *HTTPRestService) saveNetworkContainerGoalState(req cns.CreateNetworkContainerRequest) (int, string) {
@@ -852,6 +1104,11 @@ func (service *HTTPRestService) saveNetworkContainerGoalState(req cns.CreateNetw
 	service.lock.Lock()
 	defer service.lock.Unlock()
 
+	return service.setNetworkContainerGoalState(req)
+}
+
+// Saves the goal state of a network container. The caller must hold the service lock.
+func (service *HTTPRestService) setNetworkContainerGoalState(req cns.CreateNetworkContainerRequest) (int, string) {
 	existing, ok := service.state.ContainerStatus[req.NetworkContainerid]
 	var hostVersion string
 	if ok {
@@ -894,6 +1151,8 @@ func (service *HTTPRestService) saveNetworkContainerGoalState(req cns.CreateNetw
 		}
 	}
 
+	service.publishContainerStatus()
+	service.setNetworkContainerIsolation()
 	service.saveState()
 	return 0, ""
 }
@@ -902,8 +1161,7 @@ func (service *HTTPRestService) createOrUpdateNetworkContainer(w http.ResponseWr
 	log.Printf("[Azure CNS] createOrUpdateNetworkContainer")
 
 	var req cns.CreateNetworkContainerRequest
-	returnMessage := ""
-	returnCode := 0
+	var reserveResp cns.CreateNetworkContainerResponse
 
 	err := service.Listener.Decode(w, r, &req)
 	log.Request(service.Name, &req, err)
@@ -911,47 +1169,85 @@ func (service *HTTPRestService) createOrUpdateNetworkContainer(w http.ResponseWr
 		return
 	}
 
-	if req.NetworkContainerid == "" {
-		returnCode = NetworkContainerNotSpecified
-		returnMessage = fmt.Sprintf("[Azure CNS] Error. NetworkContainerid is empty")
-	}
-
 	switch r.Method {
 	case "POST":
-		if req.NetworkContainerType == cns.WebApps {
-			// try to get the saved nc state if it exists
-			service.lock.Lock()
-			existing, ok := service.state.ContainerStatus[req.NetworkContainerid]
-			service.lock.Unlock()
-
-			// create/update nc only if it doesn't exist or it exists and the requested version is different from the saved version
-			if !ok || (ok && existing.VMVersion != req.Version) {
-				nc := service.networkContainer
-				if err = nc.Create(req); err != nil {
-					returnMessage = fmt.Sprintf("[Azure CNS] Error. CreateOrUpdateNetworkContainer failed %v", err.Error())
-					returnCode = UnexpectedError
-					break
-				}
-			}
+		if isDryRunRequest(r) {
+			reserveResp = service.dryRunNetworkContainerResponse(req)
+			break
 		}
 
-		returnCode, returnMessage = service.saveNetworkContainerGoalState(req)
+		if isAsyncRequest(r) {
+			service.acceptAsyncOperation(w, operationCreateOrUpdateNetworkContainer, req)
+			return
+		}
 
-	default:
-		returnMessage = "[Azure CNS] Error. CreateOrUpdateNetworkContainer did not receive a POST."
-		returnCode = InvalidParameter
-	}
+		reserveResp = service.createOrUpdateNetworkContainerResponse(req)
 
-	resp := cns.Response{
-		ReturnCode: returnCode,
-		Message:    returnMessage,
+	default:
+		reserveResp.Response.Message = "[Azure CNS] Error. CreateOrUpdateNetworkContainer did not receive a POST."
+		reserveResp.Response.ReturnCode = InvalidParameter
 	}
 
-	reserveResp := &cns.CreateNetworkContainerResponse{Response: resp}
+	resp := reserveResp.Response
 	err = service.Listener.Encode(w, &reserveResp)
 	log.Response(service.Name, reserveResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
 }
 
+// Creates or updates a network container and saves its goal state.
+func (service *HTTPRestService) createOrUpdateNetworkContainerResponse(req cns.CreateNetworkContainerRequest) cns.CreateNetworkContainerResponse {
+	var reserveResp cns.CreateNetworkContainerResponse
+
+	if req.NetworkContainerid == "" {
+		reserveResp.Response.ReturnCode = NetworkContainerNotSpecified
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. NetworkContainerid is empty")
+		return reserveResp
+	}
+
+	// A draining node keeps updating the network containers it has, but takes no new ones.
+	service.lock.Lock()
+	_, exists := service.state.ContainerStatus[req.NetworkContainerid]
+	draining := service.state.Draining
+	orchestratorType := service.state.OrchestratorType
+	others := service.otherNetworkContainers(req.NetworkContainerid)
+	service.lock.Unlock()
+
+	if draining && !exists {
+		reserveResp.Response.ReturnCode = NodeDraining
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. %v", errNodeDraining)
+		return reserveResp
+	}
+
+	// The goal state is validated as in a dry run before anything is applied.
+	if problems := validateNetworkContainer(req, others, orchestratorType); len(problems) > 0 {
+		reserveResp.ValidationErrors = problems
+		reserveResp.Response.ReturnCode = InvalidParameter
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. Network container %v is invalid: %v",
+			req.NetworkContainerid, strings.Join(problems, "; "))
+		return reserveResp
+	}
+
+	if req.NetworkContainerType == cns.WebApps {
+		// try to get the saved nc state if it exists
+		service.lock.Lock()
+		existing, ok := service.state.ContainerStatus[req.NetworkContainerid]
+		service.lock.Unlock()
+
+		// create/update nc only if it doesn't exist or it exists and the requested version is different from the saved version
+		if !ok || (ok && existing.VMVersion != req.Version) {
+			nc := service.networkContainer
+			if err := nc.Create(req); err != nil {
+				reserveResp.Response.ReturnCode = UnexpectedError
+				reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. CreateOrUpdateNetworkContainer failed %v", err.Error())
+				return reserveResp
+			}
+		}
+	}
+
+	reserveResp.Response.ReturnCode, reserveResp.Response.Message = service.saveNetworkContainerGoalState(req)
+
+	return reserveResp
+}
+
 func (service *HTTPRestService) getNetworkContainerByID(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[Azure CNS] getNetworkContainerByID")
 
@@ -1045,8 +1341,7 @@ func (service *HTTPRestService) deleteNetworkContainer(w http.ResponseWriter, r
 	log.Printf("[Azure CNS] deleteNetworkContainer")
 
 	var req cns.DeleteNetworkContainerRequest
-	returnMessage := ""
-	returnCode := 0
+	var reserveResp cns.DeleteNetworkContainerResponse
 
 	err := service.Listener.Decode(w, r, &req)
 	log.Request(service.Name, &req, err)
@@ -1054,65 +1349,80 @@ func (service *HTTPRestService) deleteNetworkContainer(w http.ResponseWriter, r
 		return
 	}
 
-	if req.NetworkContainerid == "" {
-		returnCode = NetworkContainerNotSpecified
-		returnMessage = fmt.Sprintf("[Azure CNS] Error. NetworkContainerid is empty")
-	}
-
 	switch r.Method {
 	case "POST":
-		var containerStatus containerstatus
-		var ok bool
+		if isAsyncRequest(r) {
+			service.acceptAsyncOperation(w, operationDeleteNetworkContainer, req)
+			return
+		}
 
-		service.lock.Lock()
-		containerStatus, ok = service.state.ContainerStatus[req.NetworkContainerid]
-		service.lock.Unlock()
+		reserveResp = service.deleteNetworkContainerResponse(req)
 
-		if !ok {
-			log.Printf("Not able to retrieve network container details for this container id %v", req.NetworkContainerid)
-			break
-		}
+	default:
+		reserveResp.Response.Message = "[Azure CNS] Error. DeleteNetworkContainer did not receive a POST."
+		reserveResp.Response.ReturnCode = InvalidParameter
+	}
 
-		if containerStatus.CreateNetworkContainerRequest.NetworkContainerType == cns.WebApps {
-			nc := service.networkContainer
-			if err := nc.Delete(req.NetworkContainerid); err != nil {
-				returnMessage = fmt.Sprintf("[Azure CNS] Error. DeleteNetworkContainer failed %v", err.Error())
-				returnCode = UnexpectedError
-				break
-			}
-		}
+	resp := reserveResp.Response
+	err = service.Listener.Encode(w, &reserveResp)
+	log.Response(service.Name, reserveResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+}
 
-		service.lock.Lock()
-		defer service.lock.Unlock()
+// Deletes a network container and removes it from the saved state.
+func (service *HTTPRestService) deleteNetworkContainerResponse(req cns.DeleteNetworkContainerRequest) cns.DeleteNetworkContainerResponse {
+	var reserveResp cns.DeleteNetworkContainerResponse
+
+	if req.NetworkContainerid == "" {
+		reserveResp.Response.ReturnCode = NetworkContainerNotSpecified
+		reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. NetworkContainerid is empty")
+		return reserveResp
+	}
+
+	service.lock.Lock()
+	containerStatus, ok := service.state.ContainerStatus[req.NetworkContainerid]
+	service.lock.Unlock()
 
-		if service.state.ContainerStatus != nil {
-			delete(service.state.ContainerStatus, req.NetworkContainerid)
+	if !ok {
+		log.Printf("Not able to retrieve network container details for this container id %v", req.NetworkContainerid)
+		return reserveResp
+	}
+
+	if containerStatus.CreateNetworkContainerRequest.NetworkContainerType == cns.WebApps {
+		nc := service.networkContainer
+		if err := nc.Delete(req.NetworkContainerid); err != nil {
+			reserveResp.Response.ReturnCode = UnexpectedError
+			reserveResp.Response.Message = fmt.Sprintf("[Azure CNS] Error. DeleteNetworkContainer failed %v", err.Error())
+			return reserveResp
 		}
+	}
 
-		if service.state.ContainerIDByOrchestratorContext != nil {
-			for orchestratorContext, networkContainerID := range service.state.ContainerIDByOrchestratorContext {
-				if networkContainerID == req.NetworkContainerid {
-					delete(service.state.ContainerIDByOrchestratorContext, orchestratorContext)
-					break
-				}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	if service.state.ContainerStatus != nil {
+		delete(service.state.ContainerStatus, req.NetworkContainerid)
+	}
+
+	if service.state.ContainerIDByOrchestratorContext != nil {
+		for orchestratorContext, networkContainerID := range service.state.ContainerIDByOrchestratorContext {
+			if networkContainerID == req.NetworkContainerid {
+				delete(service.state.ContainerIDByOrchestratorContext, orchestratorContext)
+				break
 			}
 		}
-
-		service.saveState()
-		break
-	default:
-		returnMessage = "[Azure CNS] Error. DeleteNetworkContainer did not receive a POST."
-		returnCode = InvalidParameter
 	}
 
-	resp := cns.Response{
-		ReturnCode: returnCode,
-		Message:    returnMessage,
+	// The tokens of the pod are revoked with its network container.
+	var podInfo cns.KubernetesPodInfo
+	if err := json.Unmarshal(containerStatus.CreateNetworkContainerRequest.OrchestratorContext, &podInfo); err == nil && podInfo.PodName != "" {
+		service.removePodTokens(podInfo.PodName, podInfo.PodNamespace)
 	}
 
-	reserveResp := &cns.DeleteNetworkContainerResponse{Response: resp}
-	err = service.Listener.Encode(w, &reserveResp)
-	log.Response(service.Name, reserveResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), err)
+	service.publishContainerStatus()
+	service.setNetworkContainerIsolation()
+	service.saveState()
+
+	return reserveResp
 }
 
 func (service *HTTPRestService) getNetworkContainerStatus(w http.ResponseWriter, r *http.Request) {
@@ -1128,17 +1438,8 @@ func (service *HTTPRestService) getNetworkContainerStatus(w http.ResponseWriter,
 		return
 	}
 
-	service.lock.Lock()
-	defer service.lock.Unlock()
-	var ok bool
-	var containerDetails containerstatus
-
-	containerInfo := service.state.ContainerStatus
-	if containerInfo != nil {
-		containerDetails, ok = containerInfo[req.NetworkContainerid]
-	} else {
-		ok = false
-	}
+	// Served from the snapshot so that the call to the host does not block changes.
+	containerDetails, ok := service.getSnapshot().containerStatus[req.NetworkContainerid]
 
 	var hostVersion string
 	var vmVersion string
@@ -8,75 +8,168 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	cnmIpam "github.com/Azure/azure-container-networking/cnm/ipam"
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/common"
+	"github.com/Azure/azure-container-networking/cns/dockerclient"
 	"github.com/Azure/azure-container-networking/cns/imdsclient"
-	acncommon "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/cns/ipamclient"
+	acnipam "github.com/Azure/azure-container-networking/ipam"
+	"github.com/Azure/azure-container-networking/nmagent"
 )
 
-type IPAddress struct {
-	XMLName   xml.Name `xml:"IPAddress"`
-	Address   string   `xml:"Address,attr"`
-	IsPrimary bool     `xml:"IsPrimary,attr"`
-}
-type IPSubnet struct {
-	XMLName   xml.Name `xml:"IPSubnet"`
-	Prefix    string   `xml:"Prefix,attr"`
-	IPAddress []IPAddress
+var (
+	service HTTPService
+	mux     *http.ServeMux
+	ipam    *fakeIpamPlugin
+)
+
+// Primary interface of the VM reported by the fake NMAgent.
+var hostQueryResponse = nmagent.Interfaces{
+	Interface: []nmagent.Interface{{
+		MacAddress: "*",
+		IsPrimary:  true,
+		IPSubnet: []nmagent.IPSubnet{{
+			Prefix:    "10.0.0.0/16",
+			IPAddress: []nmagent.IPAddress{{Address: "10.0.0.4", IsPrimary: true}},
+		}},
+	}},
 }
 
-type Interface struct {
-	XMLName    xml.Name `xml:"Interface"`
-	MacAddress string   `xml:"MacAddress,attr"`
-	IsPrimary  bool     `xml:"IsPrimary,attr"`
-	IPSubnet   []IPSubnet
+// Handles the requests of CNS to NMAgent.
+func handleNMAgentRequest(w http.ResponseWriter, r *http.Request) {
+	requestType := r.URL.Query().Get("type")
+
+	switch {
+	case requestType == "getinterfaceinfov1":
+		w.Header().Set("Content-Type", "application/xml")
+		output, _ := xml.Marshal(hostQueryResponse)
+		w.Write(output)
+
+	case strings.HasPrefix(requestType, "NetworkManagement/interfaces/"):
+		// NetworkManagement/interfaces/{address}/networkContainers/{id}/authenticationToken/{token}/api-version/{version}
+		parts := strings.Split(requestType, "/")
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		json.NewEncoder(w).Encode(nmagent.NetworkContainerVersion{
+			ResponseCode:       "200",
+			NetworkContainerID: parts[4],
+			ProgrammedVersion:  "0",
+		})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
 }
 
-type xmlDocument struct {
-	XMLName   xml.Name `xml:"Interfaces"`
-	Interface []Interface
+// fakeDocker serves the docker network API used by CNS.
+type fakeDocker struct {
+	sync.Mutex
+	networks map[string]bool
 }
 
-var (
-	service                               HTTPService
-	mux                                   *http.ServeMux
-	hostQueryForProgrammedVersionResponse = `{"httpStatusCode":"200","networkContainerId":"eab2470f-test-test-test-b3cd316979d5","version":"1"}`
-	hostQueryResponse                     = xmlDocument{
-		XMLName: xml.Name{Local: "Interfaces"},
-		Interface: []Interface{Interface{
-			XMLName:    xml.Name{Local: "Interface"},
-			MacAddress: "*",
-			IsPrimary:  true,
-			IPSubnet: []IPSubnet{
-				IPSubnet{XMLName: xml.Name{Local: "IPSubnet"},
-					Prefix: "10.0.0.0/16",
-					IPAddress: []IPAddress{
-						IPAddress{
-							XMLName:   xml.Name{Local: "IPAddress"},
-							Address:   "10.0.0.4",
-							IsPrimary: true},
-					}},
-			},
-		}},
+func (d *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.Lock()
+	defer d.Unlock()
+
+	if r.URL.Path == "/networks/create" {
+		var config struct{ Name string }
+		json.NewDecoder(r.Body).Decode(&config)
+		d.networks[config.Name] = true
+		w.WriteHeader(http.StatusCreated)
+		return
 	}
-)
 
-func getInterfaceInfo(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/xml")
-	output, _ := xml.Marshal(hostQueryResponse)
-	w.Write(output)
+	name := strings.TrimPrefix(r.URL.Path, "/networks/")
+	if !d.networks[name] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(d.networks, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// fakeIpamPlugin serves the requests of CNS to the IPAM plugin from an address pool of the primary subnet.
+type fakeIpamPlugin struct {
+	sync.Mutex
+	reservations map[string]string
+	next         int
+	// Reservation IDs whose reservation fails.
+	failures map[string]bool
+}
+
+func (p *fakeIpamPlugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.Lock()
+	defer p.Unlock()
+
+	switch r.URL.Path {
+	case cnmIpam.GetAddressSpacesPath:
+		json.NewEncoder(w).Encode(cnmIpam.GetDefaultAddressSpacesResponse{LocalDefaultAddressSpace: "local"})
+
+	case cnmIpam.RequestPoolPath:
+		var req cnmIpam.RequestPoolRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(cnmIpam.RequestPoolResponse{PoolID: req.Pool, Pool: req.Pool})
+
+	case cnmIpam.RequestAddressPath:
+		var req cnmIpam.RequestAddressRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		id := req.Options[acnipam.OptAddressID]
+
+		var resp cnmIpam.RequestAddressResponse
+		switch {
+		case p.failures[id]:
+			resp.Err = "No available addresses"
+		case p.reservations[id] != "":
+			resp.Address = p.reservations[id] + "/16"
+		default:
+			p.next++
+			p.reservations[id] = fmt.Sprintf("10.0.1.%d", p.next)
+			resp.Address = p.reservations[id] + "/16"
+		}
+		json.NewEncoder(w).Encode(resp)
+
+	case cnmIpam.ReleaseAddressPath:
+		var req cnmIpam.ReleaseAddressRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		id := req.Options[acnipam.OptAddressID]
+
+		var resp cnmIpam.ReleaseAddressResponse
+		if p.reservations[id] == "" {
+			resp.Err = "Address not found"
+		}
+		delete(p.reservations, id)
+		json.NewEncoder(w).Encode(resp)
+
+	case cnmIpam.GetPoolInfoPath:
+		json.NewEncoder(w).Encode(cnmIpam.GetPoolInfoResponse{Capacity: 254, Available: 254 - len(p.reservations)})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
 }
 
-func getContainerInfo(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(hostQueryForProgrammedVersionResponse))
+// Returns whether the IPAM plugin holds a reservation.
+func (p *fakeIpamPlugin) isReserved(id string) bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.reservations[id] != ""
 }
 
 // Wraps the test run with service setup and teardown.
@@ -91,13 +184,23 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	// Fake NMAgent, docker and IPAM plugin, so that the tests don't depend on the host.
+	nmAgentServer := httptest.NewServer(http.HandlerFunc(handleNMAgentRequest))
+	defer nmAgentServer.Close()
+
+	dockerServer := httptest.NewServer(&fakeDocker{networks: make(map[string]bool)})
+	defer dockerServer.Close()
+
+	ipam = &fakeIpamPlugin{reservations: make(map[string]string), failures: make(map[string]bool)}
+	ipamServer := httptest.NewServer(ipam)
+	defer ipamServer.Close()
+
 	// Configure test mode.
-	service.(*httpRestService).Name = "cns-test-server"
-	service.(*httpRestService).imdsClient.HostQueryURL = imdsclient.HostQueryURL
-	service.(*httpRestService).imdsClient.HostQueryURLForProgrammedVersion = imdsclient.HostQueryURLForProgrammedVersion
-	// Following HostQueryURL and HostQueryURLForProgrammedVersion are only for mock environment.
-	// service.(*httpRestService).imdsClient.HostQueryURL = "http://localhost:9000/getInterface"
-	// service.(*httpRestService).imdsClient.HostQueryURLForProgrammedVersion = "http://localhost:9000/machine/plugins/?comp=nmagent&type=NetworkManagement/interfaces/%s/networkContainers/%s/authenticationToken/%s/api-version/%s"
+	svc := service.(*HTTPRestService)
+	svc.Name = "cns-test-server"
+	svc.imdsClient = imdsclient.NewImdsClient(nmagent.Config{URL: nmAgentServer.URL})
+	svc.dockerClient, _ = dockerclient.NewDockerClient(dockerServer.URL, svc.imdsClient)
+	svc.ipamClient, _ = ipamclient.NewIpamClient(ipamServer.URL)
 
 	// Start the service.
 	err = service.Start(&config)
@@ -107,27 +210,7 @@ func TestMain(m *testing.M) {
 	}
 
 	// Get the internal http mux as test hook.
-	mux = service.(*httpRestService).Listener.GetMux()
-
-	// Setup mock nmagent server
-	u, err := url.Parse("tcp://localhost:9000")
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-
-	nmAgentServer, err := acncommon.NewListener(u)
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-
-	nmAgentServer.AddHandler("/getInterface", getInterfaceInfo)
-	nmAgentServer.AddHandler("machine/plugins/?comp=nmagent&type=NetworkManagement/interfaces/{interface}/networkContainers/{networkContainer}/authenticationToken/{authToken}/api-version/{version}", getContainerInfo)
-
-	err = nmAgentServer.Start(make(chan error, 1))
-	if err != nil {
-		fmt.Printf("Failed to start agent, err:%v.\n", err)
-		return
-	}
+	mux = svc.Listener.GetMux()
 
 	// Run tests.
 	exitCode := m.Run()
@@ -188,6 +271,8 @@ func TestCreateNetwork(t *testing.T) {
 	setEnv(t)
 	info := &cns.CreateNetworkRequest{
 		NetworkName: "azurenet",
+		// Leaves the SNAT rules of the host alone.
+		Options: map[string]interface{}{dockerclient.OptDisableSnat: ""},
 	}
 
 	json.NewEncoder(&body).Encode(info)
@@ -244,11 +329,9 @@ func TestReserveIPAddress(t *testing.T) {
 	reserveIPRequest := cns.ReserveIPAddressRequest{ReservationID: "ip01"}
 	reserveIPRequestJSON := new(bytes.Buffer)
 	json.NewEncoder(reserveIPRequestJSON).Encode(reserveIPRequest)
-	envRequest := cns.SetEnvironmentRequest{Location: "Azure", NetworkType: "Underlay"}
-	envRequestJSON := new(bytes.Buffer)
-	json.NewEncoder(envRequestJSON).Encode(envRequest)
+	setEnv(t)
 
-	req, err := http.NewRequest(http.MethodPost, cns.ReserveIPAddressPath, envRequestJSON)
+	req, err := http.NewRequest(http.MethodPost, cns.ReserveIPAddressPath, reserveIPRequestJSON)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -258,10 +341,10 @@ func TestReserveIPAddress(t *testing.T) {
 	var reserveIPAddressResponse cns.ReserveIPAddressResponse
 
 	err = decodeResponse(w, &reserveIPAddressResponse)
-	if err != nil || reserveIPAddressResponse.Response.ReturnCode != 0 {
-		t.Errorf("SetEnvironment failed with response %+v", reserveIPAddressResponse)
+	if err != nil || reserveIPAddressResponse.Response.ReturnCode != 0 || !ipam.isReserved("ip01") {
+		t.Errorf("ReserveIPAddress failed with response %+v", reserveIPAddressResponse)
 	} else {
-		fmt.Printf("SetEnvironment Responded with %+v\n", reserveIPAddressResponse)
+		fmt.Printf("ReserveIPAddress Responded with %+v\n", reserveIPAddressResponse)
 	}
 }
 
@@ -282,10 +365,271 @@ func TestReleaseIPAddress(t *testing.T) {
 	var releaseIPAddressResponse cns.Response
 
 	err = decodeResponse(w, &releaseIPAddressResponse)
-	if err != nil || releaseIPAddressResponse.ReturnCode != 0 {
-		t.Errorf("SetEnvironment failed with response %+v", releaseIPAddressResponse)
+	if err != nil || releaseIPAddressResponse.ReturnCode != 0 || ipam.isReserved("ip01") {
+		t.Errorf("ReleaseIPAddress failed with response %+v", releaseIPAddressResponse)
 	} else {
-		fmt.Printf("SetEnvironment Responded with %+v\n", releaseIPAddressResponse)
+		fmt.Printf("ReleaseIPAddress Responded with %+v\n", releaseIPAddressResponse)
+	}
+}
+
+func TestBatchReserveIPAddress(t *testing.T) {
+	fmt.Println("Test: BatchReserveIPAddress")
+
+	setEnv(t)
+	reserveIPRequest := cns.BatchReserveIPAddressRequest{ReservationIDs: []string{"ip02", "ip03"}}
+	reserveIPRequestJSON := new(bytes.Buffer)
+	json.NewEncoder(reserveIPRequestJSON).Encode(reserveIPRequest)
+
+	req, err := http.NewRequest(http.MethodPost, cns.BatchReserveIPAddressPath, reserveIPRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var reserveIPAddressResponse cns.BatchReserveIPAddressResponse
+
+	err = decodeResponse(w, &reserveIPAddressResponse)
+	if err != nil || reserveIPAddressResponse.Response.ReturnCode != 0 ||
+		len(reserveIPAddressResponse.IPAddresses) != len(reserveIPRequest.ReservationIDs) {
+		t.Errorf("BatchReserveIPAddress failed with response %+v", reserveIPAddressResponse)
+	} else {
+		fmt.Printf("BatchReserveIPAddress Responded with %+v\n", reserveIPAddressResponse)
+	}
+}
+
+func TestBatchReleaseIPAddress(t *testing.T) {
+	fmt.Println("Test: BatchReleaseIPAddress")
+
+	releaseIPRequest := cns.BatchReleaseIPAddressRequest{ReservationIDs: []string{"ip02", "ip03"}}
+	releaseIPAddressRequestJSON := new(bytes.Buffer)
+	json.NewEncoder(releaseIPAddressRequestJSON).Encode(releaseIPRequest)
+
+	req, err := http.NewRequest(http.MethodPost, cns.BatchReleaseIPAddressPath, releaseIPAddressRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var releaseIPAddressResponse cns.Response
+
+	err = decodeResponse(w, &releaseIPAddressResponse)
+	if err != nil || releaseIPAddressResponse.ReturnCode != 0 || ipam.isReserved("ip02") || ipam.isReserved("ip03") {
+		t.Errorf("BatchReleaseIPAddress failed with response %+v", releaseIPAddressResponse)
+	} else {
+		fmt.Printf("BatchReleaseIPAddress Responded with %+v\n", releaseIPAddressResponse)
+	}
+}
+
+func TestBatchReserveIPAddressRollback(t *testing.T) {
+	fmt.Println("Test: BatchReserveIPAddressRollback")
+
+	setEnv(t)
+	ipam.Lock()
+	ipam.failures["ip10"] = true
+	ipam.Unlock()
+	defer func() {
+		ipam.Lock()
+		delete(ipam.failures, "ip10")
+		ipam.Unlock()
+	}()
+
+	reserveIPRequest := cns.BatchReserveIPAddressRequest{ReservationIDs: []string{"ip08", "ip09", "ip10"}}
+	reserveIPRequestJSON := new(bytes.Buffer)
+	json.NewEncoder(reserveIPRequestJSON).Encode(reserveIPRequest)
+
+	req, err := http.NewRequest(http.MethodPost, cns.BatchReserveIPAddressPath, reserveIPRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var reserveIPAddressResponse cns.BatchReserveIPAddressResponse
+
+	err = decodeResponse(w, &reserveIPAddressResponse)
+	if err != nil || reserveIPAddressResponse.Response.ReturnCode != AddressUnavailable || len(reserveIPAddressResponse.IPAddresses) != 0 {
+		t.Errorf("BatchReserveIPAddress with a failing reservation responded with %+v", reserveIPAddressResponse)
+	}
+
+	// The reservations made before the failure are released, and not recorded.
+	if ipam.isReserved("ip08") || ipam.isReserved("ip09") {
+		t.Errorf("BatchReserveIPAddress left partial reservations")
+	}
+
+	svc := service.(*HTTPRestService)
+	svc.lock.Lock()
+	_, ok := svc.state.IPReservations["ip08"]
+	svc.lock.Unlock()
+	if ok {
+		t.Errorf("BatchReserveIPAddress recorded a rolled back reservation")
+	}
+}
+
+func TestBatchReserveIPAddressDuplicate(t *testing.T) {
+	fmt.Println("Test: BatchReserveIPAddressDuplicate")
+
+	setEnv(t)
+	reserveIPRequest := cns.BatchReserveIPAddressRequest{ReservationIDs: []string{"ip11", "ip12", "ip11"}}
+	reserveIPRequestJSON := new(bytes.Buffer)
+	json.NewEncoder(reserveIPRequestJSON).Encode(reserveIPRequest)
+
+	req, err := http.NewRequest(http.MethodPost, cns.BatchReserveIPAddressPath, reserveIPRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var reserveIPAddressResponse cns.BatchReserveIPAddressResponse
+
+	err = decodeResponse(w, &reserveIPAddressResponse)
+	if err != nil || reserveIPAddressResponse.Response.ReturnCode != InvalidParameter || len(reserveIPAddressResponse.IPAddresses) != 0 {
+		t.Errorf("BatchReserveIPAddress with a duplicate reservation responded with %+v", reserveIPAddressResponse)
+	}
+
+	// The batch is rejected before any address is reserved.
+	if ipam.isReserved("ip11") || ipam.isReserved("ip12") {
+		t.Errorf("BatchReserveIPAddress reserved addresses of a rejected batch")
+	}
+}
+
+func setDrainMode(t *testing.T, drain bool) cns.DrainModeResponse {
+	drainRequest := cns.SetDrainModeRequest{Drain: drain}
+	drainRequestJSON := new(bytes.Buffer)
+	json.NewEncoder(drainRequestJSON).Encode(drainRequest)
+
+	req, err := http.NewRequest(http.MethodPost, cns.DrainModePath, drainRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var drainModeResponse cns.DrainModeResponse
+
+	err = decodeResponse(w, &drainModeResponse)
+	if err != nil || drainModeResponse.Response.ReturnCode != 0 || drainModeResponse.Draining != drain {
+		t.Fatalf("SetDrainMode failed with response %+v", drainModeResponse)
+	}
+
+	return drainModeResponse
+}
+
+func TestDrainMode(t *testing.T) {
+	fmt.Println("Test: DrainMode")
+
+	setEnv(t)
+	reserveIPRequest := cns.BatchReserveIPAddressRequest{ReservationIDs: []string{"ip04"}}
+	reserveIPRequestJSON := new(bytes.Buffer)
+	json.NewEncoder(reserveIPRequestJSON).Encode(reserveIPRequest)
+
+	req, err := http.NewRequest(http.MethodPost, cns.BatchReserveIPAddressPath, reserveIPRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var reserveIPAddressResponse cns.BatchReserveIPAddressResponse
+
+	err = decodeResponse(w, &reserveIPAddressResponse)
+	if err != nil || reserveIPAddressResponse.Response.ReturnCode != 0 {
+		t.Fatalf("BatchReserveIPAddress failed with response %+v", reserveIPAddressResponse)
+	}
+	address := reserveIPAddressResponse.IPAddresses["ip04"]
+
+	drainModeResponse := setDrainMode(t, true)
+	defer setDrainMode(t, false)
+
+	if drainModeResponse.IPReservations == 0 {
+		t.Errorf("DrainMode did not count the reservation, response %+v", drainModeResponse)
+	}
+
+	// New reservations are rejected while draining.
+	reserveIPRequest = cns.BatchReserveIPAddressRequest{ReservationIDs: []string{"ip05"}}
+	reserveIPRequestJSON = new(bytes.Buffer)
+	json.NewEncoder(reserveIPRequestJSON).Encode(reserveIPRequest)
+
+	req, err = http.NewRequest(http.MethodPost, cns.BatchReserveIPAddressPath, reserveIPRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	reserveIPAddressResponse = cns.BatchReserveIPAddressResponse{}
+
+	err = decodeResponse(w, &reserveIPAddressResponse)
+	if err != nil || reserveIPAddressResponse.Response.ReturnCode != NodeDraining {
+		t.Errorf("BatchReserveIPAddress while draining responded with %+v", reserveIPAddressResponse)
+	}
+
+	// The exported snapshot holds the reservation. It is only served by the admin API.
+	req, err = http.NewRequest(http.MethodGet, cns.ExportStatePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("ExportState is served by the API with status %v", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	service.(*HTTPRestService).exportState(w, req)
+	var exportStateResponse cns.ExportStateResponse
+
+	err = decodeResponse(w, &exportStateResponse)
+	if err != nil || exportStateResponse.Response.ReturnCode != 0 || !exportStateResponse.Snapshot.Draining ||
+		exportStateResponse.Snapshot.IPReservations["ip04"] != address {
+		t.Errorf("ExportState failed with response %+v", exportStateResponse)
+	}
+
+	// Releases are served while draining.
+	releaseIPRequest := cns.BatchReleaseIPAddressRequest{ReservationIDs: []string{"ip04"}}
+	releaseIPAddressRequestJSON := new(bytes.Buffer)
+	json.NewEncoder(releaseIPAddressRequestJSON).Encode(releaseIPRequest)
+
+	req, err = http.NewRequest(http.MethodPost, cns.BatchReleaseIPAddressPath, releaseIPAddressRequestJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var releaseIPAddressResponse cns.Response
+
+	err = decodeResponse(w, &releaseIPAddressResponse)
+	if err != nil || releaseIPAddressResponse.ReturnCode != 0 {
+		t.Errorf("BatchReleaseIPAddress while draining failed with response %+v", releaseIPAddressResponse)
+	}
+}
+
+func TestIsLocalURL(t *testing.T) {
+	fmt.Println("Test: IsLocalURL")
+
+	testCases := map[string]bool{
+		"tcp://localhost:10091":                true,
+		"tcp://127.0.0.1:10091":                true,
+		"tcp://[::1]:10091":                    true,
+		"unix:///var/run/azure-cns-admin.sock": true,
+		"tcp://0.0.0.0:10091":                  false,
+		"tcp://10.0.0.4:10091":                 false,
+		"http://localhost:10091":               false,
+	}
+
+	for rawURL, expected := range testCases {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if isLocalURL(u) != expected {
+			t.Errorf("isLocalURL(%v) is not %v", rawURL, expected)
+		}
 	}
 }
 
@@ -351,6 +695,111 @@ func TestGetUnhealthyIPAddresses(t *testing.T) {
 	}
 }
 
+func TestV2API(t *testing.T) {
+	fmt.Println("Test: V2API")
+
+	setEnv(t)
+
+	// The request of version 1 is wrapped in the envelope, with the request ID.
+	reserveIPRequestJSON, _ := json.Marshal(cns.BatchReserveIPAddressRequest{ReservationIDs: []string{"ip06"}})
+	envelopeJSON := new(bytes.Buffer)
+	json.NewEncoder(envelopeJSON).Encode(cns.APIEnvelope{RequestID: "request06", Data: reserveIPRequestJSON})
+
+	req, err := http.NewRequest(http.MethodPost, cns.V2APIPrefix+cns.BatchReserveIPAddressPath, envelopeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var envelope cns.APIEnvelope
+
+	err = decodeResponse(w, &envelope)
+	if err != nil || envelope.APIVersion != cns.APIVersion2 || envelope.ReturnCode != 0 || envelope.RequestID != "request06" {
+		t.Fatalf("V2 BatchReserveIPAddress failed with response %+v", envelope)
+	}
+
+	if w.Header().Get(cns.RequestIDHeader) != "request06" || w.Header().Get("Content-Type") != cns.V2MediaType {
+		t.Errorf("V2 BatchReserveIPAddress responded with unexpected headers %+v", w.Header())
+	}
+
+	var reserveIPAddressResponse cns.BatchReserveIPAddressResponse
+	if err = json.Unmarshal(envelope.Data, &reserveIPAddressResponse); err != nil || reserveIPAddressResponse.IPAddresses["ip06"] == "" {
+		t.Errorf("V2 BatchReserveIPAddress responded with unexpected data %s", envelope.Data)
+	}
+
+	// Version 1 paths serve version 2 to clients accepting it, and echo the request ID of the header.
+	req, err = http.NewRequest(http.MethodGet, cns.GetHostLocalIPPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", cns.V2MediaType)
+	req.Header.Set(cns.RequestIDHeader, "request07")
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	envelope = cns.APIEnvelope{}
+
+	err = decodeResponse(w, &envelope)
+	if err != nil || envelope.APIVersion != cns.APIVersion2 || envelope.RequestID != "request07" || len(envelope.Data) == 0 {
+		t.Errorf("Negotiated V2 GetHostLocalIP failed with response %+v", envelope)
+	}
+
+	// Version 1 responses are unchanged.
+	req, err = http.NewRequest(http.MethodGet, cns.GetHostLocalIPPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var hostLocalIPAddressResponse cns.HostLocalIPAddressResponse
+
+	err = decodeResponse(w, &hostLocalIPAddressResponse)
+	if err != nil || hostLocalIPAddressResponse.Response.ReturnCode != 0 {
+		t.Errorf("GetHostLocalIP failed with response %+v", hostLocalIPAddressResponse)
+	}
+
+	// Clients accepting neither JSON nor version 2 are rejected.
+	req, err = http.NewRequest(http.MethodGet, cns.V2APIPrefix+cns.GetHostLocalIPPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/html")
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("V2 GetHostLocalIP accepting text/html responded with HTTP status %d", w.Code)
+	}
+
+	// The requests were recorded in the metrics of the API.
+	req, err = http.NewRequest(http.MethodGet, cns.GetAPIMetricsPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var apiMetricsResponse cns.GetAPIMetricsResponse
+
+	err = decodeResponse(w, &apiMetricsResponse)
+	if err != nil || apiMetricsResponse.Response.ReturnCode != 0 {
+		t.Fatalf("GetAPIMetrics failed with response %+v", apiMetricsResponse)
+	}
+
+	found := false
+	for _, route := range apiMetricsResponse.Routes {
+		if route.Path == cns.BatchReserveIPAddressPath && route.APIVersion == cns.APIVersion2 && route.Requests > 0 {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("GetAPIMetrics did not record the V2 requests, response %+v", apiMetricsResponse)
+	}
+}
+
 func setOrchestratorType(t *testing.T, orchestratorType string) error {
 	var body bytes.Buffer
 
@@ -430,7 +879,7 @@ func deleteNetworkAdapterWithName(t *testing.T, name string) error {
 	var resp cns.DeleteNetworkContainerResponse
 
 	deleteInfo := &cns.DeleteNetworkContainerRequest{
-		NetworkContainerid: "ethWebApp",
+		NetworkContainerid: name,
 	}
 
 	json.NewEncoder(&body).Encode(deleteInfo)
@@ -689,3 +1138,590 @@ func TestGetInterfaceForNetworkContainer(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func getNetworkContainerVersion(t *testing.T, name string, goalVersion string) cns.GetNetworkContainerVersionResponse {
+	var body bytes.Buffer
+	var resp cns.GetNetworkContainerVersionResponse
+
+	getReq := &cns.GetNetworkContainerVersionRequest{NetworkContainerid: name, GoalVersion: goalVersion}
+	json.NewEncoder(&body).Encode(getReq)
+	req, err := http.NewRequest(http.MethodPost, cns.GetNetworkContainerVersion, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if err = decodeResponse(w, &resp); err != nil {
+		t.Fatalf("GetNetworkContainerVersion failed with err:%+v", err)
+	}
+
+	return resp
+}
+
+func updateNetworkContainer(t *testing.T, info *cns.CreateNetworkContainerRequest) cns.UpdateNetworkContainerResponse {
+	var body bytes.Buffer
+	var resp cns.UpdateNetworkContainerResponse
+
+	json.NewEncoder(&body).Encode(info)
+	req, err := http.NewRequest(http.MethodPost, cns.UpdateNetworkContainer, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if err = decodeResponse(w, &resp); err != nil {
+		t.Fatalf("UpdateNetworkContainer failed with err:%+v", err)
+	}
+
+	return resp
+}
+
+func TestUpdateNetworkContainer(t *testing.T) {
+	fmt.Println("Test: TestUpdateNetworkContainer")
+
+	setEnv(t)
+	setOrchestratorType(t, cns.Kubernetes)
+
+	err := creatOrUpdateNetworkContainerWithName(t, "ethWebApp", "11.0.0.5", cns.WebApps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteNetworkAdapterWithName(t, "ethWebApp")
+
+	versionResp := getNetworkContainerVersion(t, "ethWebApp", "0.2")
+	if versionResp.Response.ReturnCode != 0 || versionResp.Version != "0.1" || !versionResp.UpdateRequired {
+		t.Fatalf("Wrong version response before update %+v", versionResp)
+	}
+
+	podInfo, _ := json.Marshal(cns.KubernetesPodInfo{PodName: "testpod", PodNamespace: "testpodnamespace"})
+	goal := &cns.CreateNetworkContainerRequest{
+		Version:              "0.2",
+		NetworkContainerType: cns.WebApps,
+		NetworkContainerid:   "ethWebApp",
+		OrchestratorContext:  podInfo,
+		IPConfiguration: cns.IPConfiguration{
+			IPSubnet:         cns.IPSubnet{IPAddress: "11.0.0.5", PrefixLength: 24},
+			DNSServers:       []string{"8.8.8.8"},
+			GatewayIPAddress: "11.0.0.1",
+		},
+		Routes:                     []cns.Route{{IPAddress: "12.0.0.0/8", GatewayIPAddress: "11.0.0.1"}},
+		PrimaryInterfaceIdentifier: "11.0.0.7",
+	}
+
+	updateResp := updateNetworkContainer(t, goal)
+	if updateResp.Response.ReturnCode != 0 || !updateResp.Updated || updateResp.PreviousVersion != "0.1" || updateResp.Version != "0.2" {
+		t.Fatalf("Wrong update response %+v", updateResp)
+	}
+
+	versionResp = getNetworkContainerVersion(t, "ethWebApp", "0.2")
+	if versionResp.Version != "0.2" || versionResp.UpdateRequired {
+		t.Errorf("Wrong version response after update %+v", versionResp)
+	}
+
+	// Updating to the same version again changes nothing.
+	if updateResp = updateNetworkContainer(t, goal); updateResp.Response.ReturnCode != 0 || updateResp.Updated {
+		t.Errorf("Wrong response to repeated update %+v", updateResp)
+	}
+
+	// Changing the address of the network container requires recreating it.
+	goal.Version = "0.3"
+	goal.IPConfiguration.IPSubnet.IPAddress = "11.0.0.6"
+	if updateResp = updateNetworkContainer(t, goal); updateResp.Response.ReturnCode != NetworkContainerNotUpdatable {
+		t.Errorf("Update changing the address returned %+v", updateResp)
+	}
+
+	if versionResp = getNetworkContainerVersion(t, "ethWebApp", ""); versionResp.Version != "0.2" {
+		t.Errorf("Rejected update changed the version %+v", versionResp)
+	}
+
+	// An older version is stale, and is not reported as an update to apply.
+	goal.Version = "0.1"
+	goal.IPConfiguration.IPSubnet.IPAddress = "11.0.0.5"
+	if updateResp = updateNetworkContainer(t, goal); updateResp.Response.ReturnCode != NetworkContainerVersionStale {
+		t.Errorf("Update to an older version returned %+v", updateResp)
+	}
+
+	if versionResp = getNetworkContainerVersion(t, "ethWebApp", "0.1"); versionResp.Version != "0.2" || versionResp.UpdateRequired {
+		t.Errorf("Wrong version response for an older goal version %+v", versionResp)
+	}
+}
+
+func TestUpdateNetworkContainerNotProgrammedByCNS(t *testing.T) {
+	fmt.Println("Test: TestUpdateNetworkContainerNotProgrammedByCNS")
+
+	setEnv(t)
+	setOrchestratorType(t, cns.Kubernetes)
+
+	err := creatOrUpdateNetworkContainerWithName(t, "ethAci", "11.0.0.5", cns.AzureContainerInstance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteNetworkAdapterWithName(t, "ethAci")
+
+	podInfo, _ := json.Marshal(cns.KubernetesPodInfo{PodName: "testpod", PodNamespace: "testpodnamespace"})
+	goal := &cns.CreateNetworkContainerRequest{
+		Version:              "0.2",
+		NetworkContainerType: cns.AzureContainerInstance,
+		NetworkContainerid:   "ethAci",
+		OrchestratorContext:  podInfo,
+		IPConfiguration: cns.IPConfiguration{
+			IPSubnet:         cns.IPSubnet{IPAddress: "11.0.0.5", PrefixLength: 24},
+			DNSServers:       []string{"8.8.8.8"},
+			GatewayIPAddress: "11.0.0.1",
+		},
+		PrimaryInterfaceIdentifier: "11.0.0.7",
+	}
+
+	// The CNI plugin programs the containers of this type, so saving the goal state would not update them.
+	if updateResp := updateNetworkContainer(t, goal); updateResp.Response.ReturnCode != NetworkContainerNotUpdatable || updateResp.Updated {
+		t.Errorf("Update of a network container CNS doesn't program returned %+v", updateResp)
+	}
+
+	if versionResp := getNetworkContainerVersion(t, "ethAci", ""); versionResp.Version != "0.1" {
+		t.Errorf("Rejected update changed the version %+v", versionResp)
+	}
+}
+
+func TestCompareNetworkContainerVersions(t *testing.T) {
+	tests := []struct {
+		version string
+		other   string
+		result  int
+		ordered bool
+	}{
+		{"1", "1", 0, true},
+		{"1", "2", -1, true},
+		{"10", "9", 1, true},
+		{"0.1", "0.2", -1, true},
+		{"0.10", "0.9", 1, true},
+		{"1", "1.0", 0, true},
+		{"1.0.1", "1", 1, true},
+		{"", "1", 0, false},
+		{"v2", "v1", 0, false},
+	}
+
+	for _, test := range tests {
+		result, ordered := compareNetworkContainerVersions(test.version, test.other)
+		if result != test.result || ordered != test.ordered {
+			t.Errorf("compareNetworkContainerVersions(%q, %q) returned %v %v, expected %v %v",
+				test.version, test.other, result, ordered, test.result, test.ordered)
+		}
+	}
+
+	if isNewerNetworkContainerVersion("", "1") || !isNewerNetworkContainerVersion("v2", "v1") ||
+		isNewerNetworkContainerVersion("1", "2") || !isNewerNetworkContainerVersion("2", "1") {
+		t.Errorf("isNewerNetworkContainerVersion returned wrong results")
+	}
+}
+
+func dryRunNetworkContainer(t *testing.T, ncReq *cns.CreateNetworkContainerRequest) cns.CreateNetworkContainerResponse {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(ncReq)
+
+	req, err := http.NewRequest(http.MethodPost, cns.CreateOrUpdateNetworkContainer+"?"+cns.DryRunQueryParameter+"=true", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp cns.CreateNetworkContainerResponse
+	if err = decodeResponse(w, &resp); err != nil {
+		t.Fatalf("Dry run failed to decode response: %v", err)
+	}
+
+	return resp
+}
+
+func TestDryRunNetworkContainer(t *testing.T) {
+	fmt.Println("Test: TestDryRunNetworkContainer")
+
+	setEnv(t)
+	setOrchestratorType(t, cns.Kubernetes)
+
+	err := creatOrUpdateNetworkContainerWithName(t, "ethWebApp", "11.0.0.5", "AzureContainerInstance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteNetworkAdapterWithName(t, "ethWebApp")
+
+	podInfo, _ := json.Marshal(cns.KubernetesPodInfo{PodName: "otherpod", PodNamespace: "testpodnamespace"})
+	goal := &cns.CreateNetworkContainerRequest{
+		Version:              "0.1",
+		NetworkContainerType: "AzureContainerInstance",
+		NetworkContainerid:   "ethDryRun",
+		OrchestratorContext:  podInfo,
+		IPConfiguration: cns.IPConfiguration{
+			IPSubnet:         cns.IPSubnet{IPAddress: "11.0.0.8", PrefixLength: 24},
+			GatewayIPAddress: "11.0.0.1",
+		},
+		SecondaryIPAddresses: []string{"11.0.0.9"},
+		Routes:               []cns.Route{{IPAddress: "12.0.0.0/8", GatewayIPAddress: "11.0.0.1"}},
+	}
+
+	resp := dryRunNetworkContainer(t, goal)
+	if resp.Response.ReturnCode != 0 || len(resp.ValidationErrors) != 0 || len(resp.Operations) == 0 {
+		t.Fatalf("Wrong dry run response for a valid goal state %+v", resp)
+	}
+
+	// The dry run applies nothing.
+	if _, ok := service.(*HTTPRestService).state.ContainerStatus["ethDryRun"]; ok {
+		t.Errorf("Dry run created the network container")
+	}
+
+	// Addresses outside of the subnet, used by another network container, and invalid routes are reported together.
+	goal.IPConfiguration.GatewayIPAddress = "12.0.0.1"
+	goal.SecondaryIPAddresses = []string{"11.0.0.5"}
+	goal.Routes = []cns.Route{{IPAddress: "not a prefix"}}
+	goal.MultiTenancyInfo = cns.MultiTenancyInfo{EncapType: cns.Vlan, ID: 5000}
+
+	resp = dryRunNetworkContainer(t, goal)
+	if resp.Response.ReturnCode != InvalidParameter || len(resp.ValidationErrors) != 4 {
+		t.Errorf("Wrong dry run response for an invalid goal state %+v", resp)
+	}
+}
+
+func TestCreateInvalidNetworkContainer(t *testing.T) {
+	fmt.Println("Test: TestCreateInvalidNetworkContainer")
+
+	setEnv(t)
+	setOrchestratorType(t, cns.Kubernetes)
+
+	err := creatOrUpdateNetworkContainerWithName(t, "ethWebApp", "11.0.0.5", "AzureContainerInstance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteNetworkAdapterWithName(t, "ethWebApp")
+
+	podInfo, _ := json.Marshal(cns.KubernetesPodInfo{PodName: "otherpod", PodNamespace: "testpodnamespace"})
+	tests := []struct {
+		name      string
+		gateway   string
+		secondary []string
+	}{
+		{name: "gateway outside of the subnet", gateway: "12.0.0.1"},
+		{name: "address of another network container", gateway: "11.0.0.1", secondary: []string{"11.0.0.5"}},
+		{name: "invalid secondary address", gateway: "11.0.0.1", secondary: []string{"not an address"}},
+	}
+
+	httpService := service.(*HTTPRestService)
+	for _, test := range tests {
+		req := cns.CreateNetworkContainerRequest{
+			Version:              "0.1",
+			NetworkContainerType: "AzureContainerInstance",
+			NetworkContainerid:   "ethInvalid",
+			OrchestratorContext:  podInfo,
+			IPConfiguration: cns.IPConfiguration{
+				IPSubnet:         cns.IPSubnet{IPAddress: "11.0.0.8", PrefixLength: 24},
+				GatewayIPAddress: test.gateway,
+			},
+			SecondaryIPAddresses: test.secondary,
+		}
+
+		resp := httpService.createOrUpdateNetworkContainerResponse(req)
+		if resp.Response.ReturnCode != InvalidParameter || len(resp.ValidationErrors) != 1 {
+			t.Errorf("TestCreateInvalidNetworkContainer failed @ %v: response %+v", test.name, resp)
+		}
+
+		if _, ok := httpService.state.ContainerStatus["ethInvalid"]; ok {
+			t.Errorf("TestCreateInvalidNetworkContainer failed @ %v: invalid network container saved", test.name)
+		}
+	}
+}
+
+// Executor answering CNI commands with a fixed result.
+type fakeCNIExecutor struct {
+	requests []cns.CNIRequest
+}
+
+func (executor *fakeCNIExecutor) ExecuteCNI(req *cns.CNIRequest) *cns.CNIResponse {
+	executor.requests = append(executor.requests, *req)
+	return &cns.CNIResponse{Result: json.RawMessage(`{"cniVersion":"0.3.0"}`)}
+}
+
+func executeCNI(t *testing.T, cniReq cns.CNIRequest) cns.CNIResponse {
+	body := new(bytes.Buffer)
+	json.NewEncoder(body).Encode(cniReq)
+
+	req, err := http.NewRequest(http.MethodPost, cns.ExecuteCNIPath, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp cns.CNIResponse
+	if err = decodeResponse(w, &resp); err != nil {
+		t.Fatalf("ExecuteCNI failed to decode response: %v", err)
+	}
+
+	return resp
+}
+
+func TestExecuteCNI(t *testing.T) {
+	fmt.Println("Test: ExecuteCNI")
+
+	setEnv(t)
+	cniReq := cns.CNIRequest{
+		Command:     "ADD",
+		ContainerID: "container-1",
+		Netns:       "/var/run/netns/test",
+		IfName:      "eth0",
+		StdinData:   []byte(`{"name":"azure","type":"azure-vnet","thinClient":true}`),
+	}
+
+	// Commands are rejected until an executor is set.
+	if resp := executeCNI(t, cniReq); resp.Response.ReturnCode != CNIExecutionNotEnabled {
+		t.Errorf("ExecuteCNI without executor responded with %+v", resp)
+	}
+
+	executor := &fakeCNIExecutor{}
+	service.(*HTTPRestService).SetCNIExecutor(executor)
+	defer service.(*HTTPRestService).SetCNIExecutor(nil)
+
+	resp := executeCNI(t, cniReq)
+	if resp.Response.ReturnCode != 0 || string(resp.Result) != `{"cniVersion":"0.3.0"}` {
+		t.Errorf("ExecuteCNI responded with %+v", resp)
+	}
+
+	if len(executor.requests) != 1 || executor.requests[0].ContainerID != cniReq.ContainerID ||
+		string(executor.requests[0].StdinData) != string(cniReq.StdinData) {
+		t.Errorf("ExecuteCNI forwarded %+v, expected %+v", executor.requests, cniReq)
+	}
+}
+
+func getPodAnnotations(t *testing.T, podReq cns.GetPodAnnotationsRequest) cns.GetPodAnnotationsResponse {
+	body := new(bytes.Buffer)
+	json.NewEncoder(body).Encode(podReq)
+
+	req, err := http.NewRequest(http.MethodPost, cns.GetPodAnnotationsPath, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp cns.GetPodAnnotationsResponse
+	if err = decodeResponse(w, &resp); err != nil {
+		t.Fatalf("GetPodAnnotations failed to decode response: %v", err)
+	}
+
+	return resp
+}
+
+func TestGetPodAnnotations(t *testing.T) {
+	fmt.Println("Test: GetPodAnnotations")
+
+	setEnv(t)
+
+	if resp := getPodAnnotations(t, cns.GetPodAnnotationsRequest{PodName: "pod-1"}); resp.Response.ReturnCode != InvalidParameter {
+		t.Errorf("GetPodAnnotations without namespace responded with %+v", resp)
+	}
+
+	// The tests don't run in a cluster.
+	resp := getPodAnnotations(t, cns.GetPodAnnotationsRequest{PodName: "pod-1", PodNamespace: "default"})
+	if resp.Response.ReturnCode != UnexpectedError || resp.Annotations != nil {
+		t.Errorf("GetPodAnnotations outside of a cluster responded with %+v", resp)
+	}
+}
+
+func postPodTokenRequest(t *testing.T, path string, payload interface{}, resp interface{}) {
+	body := new(bytes.Buffer)
+	json.NewEncoder(body).Encode(payload)
+
+	req, err := http.NewRequest(http.MethodPost, path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if err = decodeResponse(w, resp); err != nil {
+		t.Fatalf("Request to %v failed to decode response: %v", path, err)
+	}
+}
+
+func TestPodTokens(t *testing.T) {
+	fmt.Println("Test: PodTokens")
+
+	keyDir, err := ioutil.TempDir("", "cns-pod-tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keyDir)
+
+	keyFile := podTokenKeyFile
+	podTokenKeyFile = filepath.Join(keyDir, "pod-tokens.key")
+	defer func() { podTokenKeyFile = keyFile }()
+
+	setEnv(t)
+	setOrchestratorType(t, cns.Kubernetes)
+	creatOrUpdateNetworkContainerWithName(t, "ethWebApp", "11.0.0.5", "AzureContainerInstance")
+
+	svc := service.(*HTTPRestService)
+	ncIP := net.ParseIP("11.0.0.5")
+	podIP := net.ParseIP("10.240.0.7")
+	issueReq := cns.IssuePodTokenRequest{
+		ContainerID:  "sandbox1",
+		PodName:      "testpod",
+		PodNamespace: "testpodnamespace",
+		IPAddresses:  []string{podIP.String()},
+		Scope:        "wireserver",
+	}
+
+	var resp cns.IssuePodTokenResponse
+	postPodTokenRequest(t, cns.IssuePodTokenPath, cns.IssuePodTokenRequest{PodName: "testpod", PodNamespace: "testpodnamespace", Scope: "wireserver"}, &resp)
+	if resp.Response.ReturnCode != InvalidParameter {
+		t.Errorf("IssuePodToken without sandbox responded with %+v", resp.Response)
+	}
+
+	postPodTokenRequest(t, cns.IssuePodTokenPath, issueReq, &resp)
+	token := resp.Token
+	if resp.Response.ReturnCode != 0 || token == "" {
+		t.Fatalf("IssuePodToken responded with %+v", resp.Response)
+	}
+
+	// The same token is returned to the sandbox until it is revoked, and is not stored in the clear.
+	postPodTokenRequest(t, cns.IssuePodTokenPath, issueReq, &resp)
+	if resp.Token != token {
+		t.Errorf("IssuePodToken issued a new token to the same sandbox")
+	}
+
+	state, _ := json.Marshal(svc.state)
+	if bytes.Contains(state, []byte(token)) {
+		t.Errorf("Token is stored in the clear in the state")
+	}
+
+	if !svc.isPodTokenValid(podIP, "wireserver", token) {
+		t.Errorf("Issued token is not valid")
+	}
+
+	if svc.isPodTokenValid(podIP, "other", token) || svc.isPodTokenValid(net.ParseIP("10.240.0.8"), "wireserver", token) {
+		t.Errorf("Token is valid for another scope or address")
+	}
+
+	// A new sandbox of the pod gets its own token, and the late DEL of the old sandbox doesn't revoke it.
+	newReq := issueReq
+	newReq.ContainerID = "sandbox2"
+	newReq.IPAddresses = []string{"10.240.0.9"}
+	postPodTokenRequest(t, cns.IssuePodTokenPath, newReq, &resp)
+	newToken := resp.Token
+	if resp.Response.ReturnCode != 0 || newToken == token {
+		t.Errorf("IssuePodToken returned the token of the old sandbox with %+v", resp.Response)
+	}
+
+	var revokeResp cns.RevokePodTokensResponse
+	revokeReq := cns.RevokePodTokensRequest{ContainerID: "sandbox1", PodName: "testpod", PodNamespace: "testpodnamespace"}
+	postPodTokenRequest(t, cns.RevokePodTokensPath, revokeReq, &revokeResp)
+	if revokeResp.Response.ReturnCode != 0 || revokeResp.Remaining != 1 || svc.isPodTokenValid(podIP, "wireserver", token) {
+		t.Errorf("RevokePodTokens responded with %+v and left the token valid", revokeResp)
+	}
+
+	if !svc.isPodTokenValid(net.ParseIP("10.240.0.9"), "wireserver", newToken) {
+		t.Errorf("Revoking the tokens of the old sandbox revoked the token of the new sandbox")
+	}
+
+	// Tokens of sandboxes whose addresses are reused are revoked.
+	reuseReq := issueReq
+	reuseReq.ContainerID = "sandbox3"
+	reuseReq.PodName = "otherpod"
+	reuseReq.IPAddresses = []string{"10.240.0.9"}
+	postPodTokenRequest(t, cns.IssuePodTokenPath, reuseReq, &resp)
+	if resp.Response.ReturnCode != 0 || svc.isPodTokenValid(net.ParseIP("10.240.0.9"), "wireserver", newToken) {
+		t.Errorf("Token of a sandbox whose address was reused is still valid")
+	}
+
+	// Tokens issued without addresses are bound to the network container of the pod.
+	ncReq := issueReq
+	ncReq.ContainerID = "sandbox4"
+	ncReq.IPAddresses = nil
+	postPodTokenRequest(t, cns.IssuePodTokenPath, ncReq, &resp)
+	if !svc.isPodTokenValid(ncIP, "wireserver", resp.Token) {
+		t.Errorf("Token issued without addresses is not valid from the network container")
+	}
+
+	// The tokens are revoked with the network container of the pod.
+	svc.lock.Lock()
+	delete(svc.state.PodTokens, podTokenKey("sandbox3", "wireserver"))
+	svc.lock.Unlock()
+
+	deleteNetworkAdapterWithName(t, "ethWebApp")
+	if len(svc.state.PodTokens) != 0 {
+		t.Errorf("Tokens of the pod are left after deleting its network container: %+v", svc.state.PodTokens)
+	}
+}
+
+func postLogLevel(t *testing.T, logLevelReq cns.SetLogLevelRequest) cns.LogLevelResponse {
+	body := new(bytes.Buffer)
+	json.NewEncoder(body).Encode(logLevelReq)
+
+	req, err := http.NewRequest(http.MethodPost, cns.AdminLogLevelPath, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	service.(*HTTPRestService).logLevel(w, req)
+
+	var resp cns.LogLevelResponse
+	if err = decodeResponse(w, &resp); err != nil {
+		t.Fatalf("LogLevel failed to decode response: %v", err)
+	}
+
+	return resp
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	fmt.Println("Test: AdminLogLevel")
+
+	// The admin API is not served by the API.
+	req, err := http.NewRequest(http.MethodGet, cns.AdminLogLevelPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("LogLevel is served by the API with status %v", w.Code)
+	}
+
+	previous := postLogLevel(t, cns.SetLogLevelRequest{Level: "info"}).Level
+	defer postLogLevel(t, cns.SetLogLevelRequest{Level: previous})
+
+	resp := postLogLevel(t, cns.SetLogLevelRequest{Level: "debug", DurationSeconds: 600})
+	if resp.Response.ReturnCode != 0 || resp.Level != "debug" || resp.RestoredAt == nil {
+		t.Errorf("LogLevel for a while responded with %+v", resp)
+	}
+
+	// Changing the levels for good cancels the restore.
+	resp = postLogLevel(t, cns.SetLogLevelRequest{Level: "info"})
+	if resp.Response.ReturnCode != 0 || resp.Level != "info" || resp.RestoredAt != nil {
+		t.Errorf("LogLevel responded with %+v", resp)
+	}
+
+	for _, invalid := range []cns.SetLogLevelRequest{{Level: "loud"}, {Level: "debug", DurationSeconds: -1}} {
+		if resp = postLogLevel(t, invalid); resp.Response.ReturnCode != InvalidParameter || resp.Level != "info" {
+			t.Errorf("LogLevel %+v responded with %+v", invalid, resp)
+		}
+	}
+
+	req, err = http.NewRequest(http.MethodGet, cns.AdminGoroutinesPath+"?debug=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	service.(*HTTPRestService).dumpGoroutines(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("DumpGoroutines responded with status %v", w.Code)
+	}
+}
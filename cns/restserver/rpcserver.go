@@ -0,0 +1,241 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/rpc/v1"
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata key of the bearer token presented by gRPC clients.
+const rpcAuthorizationKey = "authorization"
+
+// rpcServer serves the CNS API over gRPC, sharing its implementation with the REST handlers.
+type rpcServer struct {
+	service *HTTPRestService
+}
+
+// Starts the gRPC server if a gRPC URL is configured.
+func (service *HTTPRestService) startRPCServer() error {
+	urls, _ := service.GetOption(acn.OptCnsGrpcURL).(string)
+	if urls == "" {
+		return nil
+	}
+
+	u, err := url.Parse(urls)
+	if err != nil {
+		return err
+	}
+
+	localAddress := u.Host + u.Path
+	if u.Scheme == "unix" {
+		os.Remove(localAddress)
+	}
+
+	l, err := net.Listen(u.Scheme, localAddress)
+	if err != nil {
+		return err
+	}
+
+	opts, err := service.rpcServerOptions()
+	if err != nil {
+		l.Close()
+		return err
+	}
+
+	server := grpc.NewServer(opts...)
+	v1.RegisterContainerNetworkingServiceServer(server, &rpcServer{service: service})
+
+	go func() {
+		if err := server.Serve(l); err != nil {
+			log.Errorf("[Azure CNS] gRPC server stopped, err:%v.", err)
+		}
+	}()
+
+	service.rpcServer = server
+
+	log.Printf("[Azure CNS] gRPC server listening on %v.", urls)
+	return nil
+}
+
+// Returns the gRPC server options securing the API like the REST listener,
+// with the TLS configuration and the token configured for CNS.
+func (service *HTTPRestService) rpcServerOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+
+	certDir, _ := service.GetOption(acn.OptCnsTLSCertDir).(string)
+	if certDir != "" {
+		config, err := cns.NewTLSConfig(certDir)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	tokenFile, _ := service.GetOption(acn.OptCnsAuthTokenFile).(string)
+	if tokenFile != "" {
+		token, err := cns.ReadAuthToken(tokenFile)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if err := authenticateRPC(ctx, token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := authenticateRPC(ss.Context(), token); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}))
+
+		log.Printf("[Azure CNS] gRPC calls must present the token from %v.", tokenFile)
+	}
+
+	return opts, nil
+}
+
+// Checks that the call carries the expected token in its metadata.
+func authenticateRPC(ctx context.Context, token string) error {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(rpcAuthorizationKey); len(values) > 0 {
+			header = values[0]
+		}
+	}
+
+	if err := cns.CheckBearerToken(header, token); err != nil {
+		log.Printf("[Azure CNS] Rejected gRPC call, err:%v.", err)
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return nil
+}
+
+// Stops the gRPC server if it is running.
+func (service *HTTPRestService) stopRPCServer() {
+	if service.rpcServer != nil {
+		service.rpcServer.GracefulStop()
+		service.rpcServer = nil
+	}
+}
+
+// Rejects calls changing the node state while another instance owns it.
+func (s *rpcServer) checkOwner() error {
+	if s.service.isReadOnly() {
+		return status.Error(codes.Unavailable, errReadOnlyReplica.Error())
+	}
+
+	return nil
+}
+
+// RegisterNode sets the orchestrator type and DNC partition key of the node.
+func (s *rpcServer) RegisterNode(ctx context.Context, req *v1.RegisterNodeRequest) (*v1.RegisterNodeResponse, error) {
+	log.Printf("[Azure CNS] gRPC RegisterNode")
+
+	if err := s.checkOwner(); err != nil {
+		return nil, err
+	}
+
+	resp := s.service.setOrchestratorTypeResponse(cns.SetOrchestratorTypeRequest{
+		OrchestratorType: req.GetOrchestratorType(),
+		DncPartitionKey:  req.GetDncPartitionKey(),
+	})
+
+	log.Response(s.service.Name, resp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), nil)
+
+	return &v1.RegisterNodeResponse{Response: v1.NewResponse(resp)}, nil
+}
+
+// CreateOrUpdateNetworkContainer creates or updates a network container.
+func (s *rpcServer) CreateOrUpdateNetworkContainer(ctx context.Context, req *v1.CreateNetworkContainerRequest) (*v1.CreateNetworkContainerResponse, error) {
+	log.Printf("[Azure CNS] gRPC CreateOrUpdateNetworkContainer")
+
+	if err := s.checkOwner(); err != nil {
+		return nil, err
+	}
+
+	cnsReq := req.ToCNS()
+	log.Request(s.service.Name, &cnsReq, nil)
+
+	cnsResp := s.service.createOrUpdateNetworkContainerResponse(cnsReq)
+	resp := cnsResp.Response
+
+	log.Response(s.service.Name, cnsResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), nil)
+
+	return &v1.CreateNetworkContainerResponse{Response: v1.NewResponse(resp)}, nil
+}
+
+// DeleteNetworkContainer deletes a network container.
+func (s *rpcServer) DeleteNetworkContainer(ctx context.Context, req *v1.DeleteNetworkContainerRequest) (*v1.DeleteNetworkContainerResponse, error) {
+	log.Printf("[Azure CNS] gRPC DeleteNetworkContainer")
+
+	if err := s.checkOwner(); err != nil {
+		return nil, err
+	}
+
+	cnsReq := cns.DeleteNetworkContainerRequest{NetworkContainerid: req.GetNetworkContainerId()}
+	log.Request(s.service.Name, &cnsReq, nil)
+
+	cnsResp := s.service.deleteNetworkContainerResponse(cnsReq)
+	resp := cnsResp.Response
+
+	log.Response(s.service.Name, cnsResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), nil)
+
+	return &v1.DeleteNetworkContainerResponse{Response: v1.NewResponse(resp)}, nil
+}
+
+// ReserveIPAddress reserves an IP address from the primary interface subnet.
+func (s *rpcServer) ReserveIPAddress(ctx context.Context, req *v1.ReserveIPAddressRequest) (*v1.ReserveIPAddressResponse, error) {
+	log.Printf("[Azure CNS] gRPC ReserveIPAddress")
+
+	if err := s.checkOwner(); err != nil {
+		return nil, err
+	}
+
+	cnsReq := cns.ReserveIPAddressRequest{ReservationID: req.GetReservationId()}
+	log.Request(s.service.Name, &cnsReq, nil)
+
+	cnsResp := s.service.reserveIPAddressResponse(cnsReq)
+	resp := cnsResp.Response
+
+	log.Response(s.service.Name, cnsResp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), nil)
+
+	return &v1.ReserveIPAddressResponse{Response: v1.NewResponse(resp), IpAddress: cnsResp.IPAddress}, nil
+}
+
+// ReleaseIPAddress releases an IP address reservation.
+func (s *rpcServer) ReleaseIPAddress(ctx context.Context, req *v1.ReleaseIPAddressRequest) (*v1.ReleaseIPAddressResponse, error) {
+	log.Printf("[Azure CNS] gRPC ReleaseIPAddress")
+
+	if err := s.checkOwner(); err != nil {
+		return nil, err
+	}
+
+	cnsReq := cns.ReleaseIPAddressRequest{ReservationID: req.GetReservationId()}
+	log.Request(s.service.Name, &cnsReq, nil)
+
+	resp := s.service.releaseIPAddressResponse(cnsReq)
+
+	log.Response(s.service.Name, resp, resp.ReturnCode, ReturnCodeToString(resp.ReturnCode), nil)
+
+	return &v1.ReleaseIPAddressResponse{Response: v1.NewResponse(resp)}, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticateRPC(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		expected codes.Code
+	}{
+		{name: "no metadata", md: nil, expected: codes.Unauthenticated},
+		{name: "no token", md: metadata.Pairs("other", "value"), expected: codes.Unauthenticated},
+		{name: "wrong token", md: metadata.Pairs(rpcAuthorizationKey, "Bearer wrong"), expected: codes.Unauthenticated},
+		{name: "missing bearer prefix", md: metadata.Pairs(rpcAuthorizationKey, "secret"), expected: codes.Unauthenticated},
+		{name: "valid token", md: metadata.Pairs(rpcAuthorizationKey, "Bearer secret"), expected: codes.OK},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.md != nil {
+			ctx = metadata.NewIncomingContext(ctx, test.md)
+		}
+
+		err := authenticateRPC(ctx, "secret")
+		if code := status.Code(err); code != test.expected {
+			t.Errorf("%v: got code %v, expected %v, err:%v", test.name, code, test.expected, err)
+		}
+	}
+}
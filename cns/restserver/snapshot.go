@@ -0,0 +1,116 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Age after which the IP address utilization is refreshed in the background.
+	utilizationMaxAge = 10 * time.Second
+)
+
+// stateSnapshot is an immutable view of the service state serving read-only queries
+// without taking the service lock. Changes replace the snapshot as a whole.
+type stateSnapshot struct {
+	containerStatus map[string]containerstatus // NetworkContainerID is key.
+	utilization     *utilizationSnapshot
+}
+
+// utilizationSnapshot records the utilization of the primary interface address pool.
+type utilizationSnapshot struct {
+	capacity       int
+	available      int
+	unhealthyAddrs []string
+	time           time.Time
+}
+
+// Returns the current snapshot.
+func (service *HTTPRestService) getSnapshot() *stateSnapshot {
+	snapshot, _ := service.snapshot.Load().(*stateSnapshot)
+	if snapshot == nil {
+		return &stateSnapshot{}
+	}
+	return snapshot
+}
+
+// Replaces the snapshot with a copy modified by the given function.
+func (service *HTTPRestService) updateSnapshot(update func(*stateSnapshot)) {
+	service.snapshotLock.Lock()
+	defer service.snapshotLock.Unlock()
+
+	snapshot := *service.getSnapshot()
+	update(&snapshot)
+	service.snapshot.Store(&snapshot)
+}
+
+// Publishes the network container status. The caller must hold the service lock.
+func (service *HTTPRestService) publishContainerStatus() {
+	containerStatus := make(map[string]containerstatus, len(service.state.ContainerStatus))
+	for id, status := range service.state.ContainerStatus {
+		containerStatus[id] = status
+	}
+
+	service.updateSnapshot(func(snapshot *stateSnapshot) {
+		snapshot.containerStatus = containerStatus
+	})
+}
+
+// Returns the utilization of the primary interface address pool.
+// The last known utilization is returned while a refresh runs in the background, so that
+// queries are not delayed by allocations in progress.
+func (service *HTTPRestService) getUtilization() (*utilizationSnapshot, error) {
+	utilization := service.getSnapshot().utilization
+	if utilization == nil {
+		return service.refreshUtilization()
+	}
+
+	if service.clock.Since(utilization.time) > utilizationMaxAge {
+		service.invalidateUtilization()
+	}
+
+	return utilization, nil
+}
+
+// Schedules a refresh of the address pool utilization after the pool changed.
+func (service *HTTPRestService) invalidateUtilization() {
+	// Only a single refresh runs at a time.
+	if !atomic.CompareAndSwapInt32(&service.utilizationRefreshing, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&service.utilizationRefreshing, 0)
+		if _, err := service.refreshUtilization(); err != nil {
+			log.Printf("[Azure CNS] Failed to refresh IP address utilization, err:%v.", err)
+		}
+	}()
+}
+
+// Queries the address pool utilization and publishes it.
+func (service *HTTPRestService) refreshUtilization() (*utilizationSnapshot, error) {
+	poolID, err := service.getPrimaryPoolID()
+	if err != nil {
+		return nil, err
+	}
+
+	utilization := &utilizationSnapshot{time: service.clock.Now()}
+	utilization.capacity, utilization.available, utilization.unhealthyAddrs, err = service.ipamClient.GetIPAddressUtilization(poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	service.updateSnapshot(func(snapshot *stateSnapshot) {
+		// Keep a more recent utilization published by a concurrent refresh.
+		if snapshot.utilization == nil || snapshot.utilization.time.Before(utilization.time) {
+			snapshot.utilization = utilization
+		}
+	})
+
+	return utilization, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package restserver
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+func TestPublishContainerStatus(t *testing.T) {
+	svc := &HTTPRestService{}
+	svc.state = &httpRestServiceState{ContainerStatus: map[string]containerstatus{"nc1": {ID: "nc1"}}}
+	utilization := &utilizationSnapshot{capacity: 8}
+	svc.updateSnapshot(func(snapshot *stateSnapshot) {
+		snapshot.utilization = utilization
+	})
+
+	svc.publishContainerStatus()
+	snapshot := svc.getSnapshot()
+
+	// Changes to the state are only visible once published again.
+	svc.state.ContainerStatus["nc2"] = containerstatus{ID: "nc2"}
+	delete(svc.state.ContainerStatus, "nc1")
+
+	if _, ok := snapshot.containerStatus["nc1"]; !ok || len(snapshot.containerStatus) != 1 {
+		t.Errorf("TestPublishContainerStatus failed, container status %+v", snapshot.containerStatus)
+	}
+
+	if snapshot.utilization != utilization {
+		t.Errorf("TestPublishContainerStatus failed, utilization %+v not kept", snapshot.utilization)
+	}
+
+	svc.publishContainerStatus()
+	if _, ok := svc.getSnapshot().containerStatus["nc2"]; !ok || len(svc.getSnapshot().containerStatus) != 1 {
+		t.Errorf("TestPublishContainerStatus failed, republished container status %+v", svc.getSnapshot().containerStatus)
+	}
+}
+
+func TestGetUtilization(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		name string
+		age  time.Duration
+	}{
+		{name: "fresh", age: 0},
+		{name: "max age", age: utilizationMaxAge},
+		{name: "stale", age: utilizationMaxAge + time.Second},
+	}
+
+	for _, test := range tests {
+		svc := &HTTPRestService{clock: platform.NewFakeClock(now)}
+		utilization := &utilizationSnapshot{capacity: 8, available: 4, time: now.Add(-test.age)}
+		svc.updateSnapshot(func(snapshot *stateSnapshot) {
+			snapshot.utilization = utilization
+		})
+
+		// Mark a refresh as running, so that an invalidation does not query the pool.
+		svc.utilizationRefreshing = 1
+
+		result, err := svc.getUtilization()
+		if err != nil || result != utilization {
+			t.Errorf("TestGetUtilization failed @ %v: utilization %+v, err %v", test.name, result, err)
+		}
+
+		// A stale utilization is still served while it is refreshed in the background.
+		if atomic.LoadInt32(&svc.utilizationRefreshing) != 1 {
+			t.Errorf("TestGetUtilization failed @ %v: refresh flag cleared", test.name)
+		}
+	}
+}
+
+func TestInvalidateUtilizationRunning(t *testing.T) {
+	svc := &HTTPRestService{utilizationRefreshing: 1}
+
+	// A refresh already in progress is not started again.
+	svc.invalidateUtilization()
+	if atomic.LoadInt32(&svc.utilizationRefreshing) != 1 {
+		t.Errorf("TestInvalidateUtilizationRunning failed, refresh flag cleared")
+	}
+}
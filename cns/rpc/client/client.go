@@ -0,0 +1,224 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/rpc/v1"
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	defaultCnsGrpcURL = "tcp://localhost:10091"
+
+	// Timeout for each call to CNS.
+	defaultTimeout = 30 * time.Second
+)
+
+// Client is a client for the CNS gRPC API.
+type Client struct {
+	conn    *grpc.ClientConn
+	client  v1.ContainerNetworkingServiceClient
+	timeout time.Duration
+}
+
+// Config specifies how the client authenticates to CNS. Zero values select an insecure connection.
+type Config struct {
+	// PEM file of the CA that issued the CNS certificate, enables TLS.
+	CAFile string
+	// Client certificate and key files presented to CNS requiring mTLS.
+	CertFile string
+	KeyFile  string
+	// File containing the bearer token presented to CNS.
+	TokenFile string
+}
+
+// tokenCredentials presents the bearer token with each call.
+type tokenCredentials struct {
+	token string
+}
+
+// GetRequestMetadata returns the authorization metadata of a call.
+func (c *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": cns.BearerPrefix + c.token}, nil
+}
+
+// RequireTransportSecurity returns false, as CNS may be reached over a local socket without TLS.
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// NewClient creates a new client connected to the CNS gRPC API at the given URL,
+// e.g. tcp://localhost:10091 or unix:///var/run/azure-cns.sock.
+func NewClient(urls string) (*Client, error) {
+	return NewClientWithConfig(urls, Config{})
+}
+
+// NewClientWithConfig creates a new client connected to the CNS gRPC API at the given URL,
+// authenticating with the given configuration.
+func NewClientWithConfig(urls string, config Config) (*Client, error) {
+	if urls == "" {
+		urls = defaultCnsGrpcURL
+	}
+
+	u, err := url.Parse(urls)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := u.Scheme
+	dialer := func(address string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout(protocol, address, timeout)
+	}
+
+	opts := []grpc.DialOption{grpc.WithDialer(dialer)}
+
+	if config.CAFile != "" || config.CertFile != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if config.CAFile != "" {
+			pool, err := cns.LoadCertPool(config.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		if config.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	if config.TokenFile != "" {
+		token, err := cns.ReadAuthToken(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.WithPerRPCCredentials(&tokenCredentials{token: token}))
+	}
+
+	conn, err := grpc.Dial(u.Host+u.Path, opts...)
+	if err != nil {
+		log.Errorf("[Azure CNSClient] Failed to dial %v: %v", urls, err)
+		return nil, err
+	}
+
+	return &Client{
+		conn:    conn,
+		client:  v1.NewContainerNetworkingServiceClient(conn),
+		timeout: defaultTimeout,
+	}, nil
+}
+
+// Close closes the connection to CNS.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// RegisterNode sets the orchestrator type and DNC partition key of the node.
+func (c *Client) RegisterNode(orchestratorType, dncPartitionKey string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	resp, err := c.client.RegisterNode(ctx, &v1.RegisterNodeRequest{
+		OrchestratorType: orchestratorType,
+		DncPartitionKey:  dncPartitionKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	return checkResponse("RegisterNode", resp.GetResponse())
+}
+
+// CreateOrUpdateNetworkContainer creates or updates a network container.
+func (c *Client) CreateOrUpdateNetworkContainer(req cns.CreateNetworkContainerRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	resp, err := c.client.CreateOrUpdateNetworkContainer(ctx, v1.NewCreateNetworkContainerRequest(req))
+	if err != nil {
+		return err
+	}
+
+	return checkResponse("CreateOrUpdateNetworkContainer", resp.GetResponse())
+}
+
+// DeleteNetworkContainer deletes a network container.
+func (c *Client) DeleteNetworkContainer(networkContainerID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	resp, err := c.client.DeleteNetworkContainer(ctx, &v1.DeleteNetworkContainerRequest{
+		NetworkContainerId: networkContainerID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return checkResponse("DeleteNetworkContainer", resp.GetResponse())
+}
+
+// ReserveIPAddress reserves an IP address and returns it.
+func (c *Client) ReserveIPAddress(reservationID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	resp, err := c.client.ReserveIPAddress(ctx, &v1.ReserveIPAddressRequest{
+		ReservationId: reservationID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkResponse("ReserveIPAddress", resp.GetResponse()); err != nil {
+		return "", err
+	}
+
+	return resp.GetIpAddress(), nil
+}
+
+// ReleaseIPAddress releases an IP address reservation.
+func (c *Client) ReleaseIPAddress(reservationID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	resp, err := c.client.ReleaseIPAddress(ctx, &v1.ReleaseIPAddressRequest{
+		ReservationId: reservationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return checkResponse("ReleaseIPAddress", resp.GetResponse())
+}
+
+// Returns an error if the CNS response carries a non-zero return code.
+func checkResponse(method string, resp *v1.Response) error {
+	if resp.GetReturnCode() != 0 {
+		err := fmt.Errorf("[Azure CNSClient] %v received error response :%v", method, resp.GetMessage())
+		log.Errorf("%v", err)
+		return err
+	}
+
+	return nil
+}
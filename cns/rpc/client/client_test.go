@@ -0,0 +1,155 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package client
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/rpc/v1"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeServer is an in-memory ContainerNetworkingServiceServer.
+type fakeServer struct {
+	orchestratorType  string
+	networkContainers map[string]cns.CreateNetworkContainerRequest
+	reservations      map[string]string
+}
+
+func (s *fakeServer) RegisterNode(ctx context.Context, req *v1.RegisterNodeRequest) (*v1.RegisterNodeResponse, error) {
+	s.orchestratorType = req.GetOrchestratorType()
+	return &v1.RegisterNodeResponse{Response: &v1.Response{}}, nil
+}
+
+func (s *fakeServer) CreateOrUpdateNetworkContainer(ctx context.Context, req *v1.CreateNetworkContainerRequest) (*v1.CreateNetworkContainerResponse, error) {
+	s.networkContainers[req.GetNetworkContainerId()] = req.ToCNS()
+	return &v1.CreateNetworkContainerResponse{Response: &v1.Response{}}, nil
+}
+
+func (s *fakeServer) DeleteNetworkContainer(ctx context.Context, req *v1.DeleteNetworkContainerRequest) (*v1.DeleteNetworkContainerResponse, error) {
+	delete(s.networkContainers, req.GetNetworkContainerId())
+	return &v1.DeleteNetworkContainerResponse{Response: &v1.Response{}}, nil
+}
+
+func (s *fakeServer) ReserveIPAddress(ctx context.Context, req *v1.ReserveIPAddressRequest) (*v1.ReserveIPAddressResponse, error) {
+	s.reservations[req.GetReservationId()] = "10.0.0.5"
+	return &v1.ReserveIPAddressResponse{Response: &v1.Response{}, IpAddress: "10.0.0.5"}, nil
+}
+
+func (s *fakeServer) ReleaseIPAddress(ctx context.Context, req *v1.ReleaseIPAddressRequest) (*v1.ReleaseIPAddressResponse, error) {
+	if _, ok := s.reservations[req.GetReservationId()]; !ok {
+		return &v1.ReleaseIPAddressResponse{Response: &v1.Response{ReturnCode: 22, Message: "not found"}}, nil
+	}
+
+	delete(s.reservations, req.GetReservationId())
+	return &v1.ReleaseIPAddressResponse{Response: &v1.Response{}}, nil
+}
+
+func TestClient(t *testing.T) {
+	fs := &fakeServer{
+		networkContainers: make(map[string]cns.CreateNetworkContainerRequest),
+		reservations:      make(map[string]string),
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := grpc.NewServer()
+	v1.RegisterContainerNetworkingServiceServer(server, fs)
+	go server.Serve(l)
+	defer server.Stop()
+
+	c, err := NewClient("tcp://" + l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.RegisterNode(cns.Kubernetes, "key"); err != nil || fs.orchestratorType != cns.Kubernetes {
+		t.Errorf("RegisterNode failed, orchestratorType:%v err:%v", fs.orchestratorType, err)
+	}
+
+	req := cns.CreateNetworkContainerRequest{NetworkContainerid: "nc1", NetworkContainerType: cns.WebApps}
+	if err := c.CreateOrUpdateNetworkContainer(req); err != nil {
+		t.Errorf("CreateOrUpdateNetworkContainer failed: %v", err)
+	}
+
+	if nc, ok := fs.networkContainers["nc1"]; !ok || nc.NetworkContainerType != cns.WebApps {
+		t.Errorf("Network container was not created: %+v", fs.networkContainers)
+	}
+
+	if err := c.DeleteNetworkContainer("nc1"); err != nil || len(fs.networkContainers) != 0 {
+		t.Errorf("DeleteNetworkContainer failed: %v", err)
+	}
+
+	addr, err := c.ReserveIPAddress("r1")
+	if err != nil || addr != "10.0.0.5" {
+		t.Errorf("ReserveIPAddress returned %v, err:%v", addr, err)
+	}
+
+	if err := c.ReleaseIPAddress("r1"); err != nil {
+		t.Errorf("ReleaseIPAddress failed: %v", err)
+	}
+
+	if err := c.ReleaseIPAddress("r1"); err == nil {
+		t.Errorf("ReleaseIPAddress of an unknown reservation succeeded")
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnsrpc")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+
+	fs := &fakeServer{
+		networkContainers: make(map[string]cns.CreateNetworkContainerRequest),
+		reservations:      make(map[string]string),
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	var authorization []string
+	server := grpc.NewServer(grpc.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			authorization = md.Get("authorization")
+			return handler(ctx, req)
+		}))
+	v1.RegisterContainerNetworkingServiceServer(server, fs)
+	go server.Serve(l)
+	defer server.Stop()
+
+	c, err := NewClientWithConfig("tcp://"+l.Addr().String(), Config{TokenFile: tokenFile})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.RegisterNode(cns.Kubernetes, "key"); err != nil {
+		t.Fatalf("RegisterNode failed: %v", err)
+	}
+
+	if len(authorization) != 1 || authorization[0] != cns.BearerPrefix+"secret" {
+		t.Errorf("Call presented authorization %v", authorization)
+	}
+}
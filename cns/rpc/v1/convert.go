@@ -0,0 +1,125 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package v1
+
+import (
+	"github.com/Azure/azure-container-networking/cns"
+)
+
+// NewResponse converts a CNS response to its gRPC representation.
+func NewResponse(resp cns.Response) *Response {
+	return &Response{
+		ReturnCode: int32(resp.ReturnCode),
+		Message:    resp.Message,
+	}
+}
+
+// ToCNS converts the response to its CNS representation.
+func (m *Response) ToCNS() cns.Response {
+	return cns.Response{
+		ReturnCode: int(m.GetReturnCode()),
+		Message:    m.GetMessage(),
+	}
+}
+
+// NewCreateNetworkContainerRequest converts a CNS network container request to its gRPC representation.
+func NewCreateNetworkContainerRequest(req cns.CreateNetworkContainerRequest) *CreateNetworkContainerRequest {
+	m := &CreateNetworkContainerRequest{
+		Version:                    req.Version,
+		NetworkContainerType:       req.NetworkContainerType,
+		NetworkContainerId:         req.NetworkContainerid,
+		PrimaryInterfaceIdentifier: req.PrimaryInterfaceIdentifier,
+		AuthorizationToken:         req.AuthorizationToken,
+		LocalIpConfiguration:       newIPConfiguration(req.LocalIPConfiguration),
+		OrchestratorContext:        []byte(req.OrchestratorContext),
+		IpConfiguration:            newIPConfiguration(req.IPConfiguration),
+		MultiTenancyInfo: &MultiTenancyInfo{
+			EncapType: req.MultiTenancyInfo.EncapType,
+			Id:        int32(req.MultiTenancyInfo.ID),
+		},
+	}
+
+	for _, subnet := range req.CnetAddressSpace {
+		m.CnetAddressSpace = append(m.CnetAddressSpace, newIPSubnet(subnet))
+	}
+
+	for _, route := range req.Routes {
+		m.Routes = append(m.Routes, &Route{
+			IpAddress:        route.IPAddress,
+			GatewayIpAddress: route.GatewayIPAddress,
+			InterfaceToUse:   route.InterfaceToUse,
+		})
+	}
+
+	return m
+}
+
+// ToCNS converts the request to its CNS representation.
+func (m *CreateNetworkContainerRequest) ToCNS() cns.CreateNetworkContainerRequest {
+	req := cns.CreateNetworkContainerRequest{
+		Version:                    m.GetVersion(),
+		NetworkContainerType:       m.GetNetworkContainerType(),
+		NetworkContainerid:         m.GetNetworkContainerId(),
+		PrimaryInterfaceIdentifier: m.GetPrimaryInterfaceIdentifier(),
+		AuthorizationToken:         m.GetAuthorizationToken(),
+		LocalIPConfiguration:       m.GetLocalIpConfiguration().toCNS(),
+		IPConfiguration:            m.GetIpConfiguration().toCNS(),
+		MultiTenancyInfo: cns.MultiTenancyInfo{
+			EncapType: m.GetMultiTenancyInfo().GetEncapType(),
+			ID:        int(m.GetMultiTenancyInfo().GetId()),
+		},
+	}
+
+	if len(m.GetOrchestratorContext()) > 0 {
+		req.OrchestratorContext = m.GetOrchestratorContext()
+	}
+
+	for _, subnet := range m.GetCnetAddressSpace() {
+		req.CnetAddressSpace = append(req.CnetAddressSpace, subnet.toCNS())
+	}
+
+	for _, route := range m.GetRoutes() {
+		req.Routes = append(req.Routes, cns.Route{
+			IPAddress:        route.GetIpAddress(),
+			GatewayIPAddress: route.GetGatewayIpAddress(),
+			InterfaceToUse:   route.GetInterfaceToUse(),
+		})
+	}
+
+	return req
+}
+
+// Converts a CNS ip configuration to its gRPC representation.
+func newIPConfiguration(config cns.IPConfiguration) *IPConfiguration {
+	return &IPConfiguration{
+		IpSubnet:         newIPSubnet(config.IPSubnet),
+		DnsServers:       config.DNSServers,
+		GatewayIpAddress: config.GatewayIPAddress,
+	}
+}
+
+// Converts the ip configuration to its CNS representation.
+func (m *IPConfiguration) toCNS() cns.IPConfiguration {
+	return cns.IPConfiguration{
+		IPSubnet:         m.GetIpSubnet().toCNS(),
+		DNSServers:       m.GetDnsServers(),
+		GatewayIPAddress: m.GetGatewayIpAddress(),
+	}
+}
+
+// Converts a CNS ip subnet to its gRPC representation.
+func newIPSubnet(subnet cns.IPSubnet) *IPSubnet {
+	return &IPSubnet{
+		IpAddress:    subnet.IPAddress,
+		PrefixLength: uint32(subnet.PrefixLength),
+	}
+}
+
+// Converts the ip subnet to its CNS representation.
+func (m *IPSubnet) toCNS() cns.IPSubnet {
+	return cns.IPSubnet{
+		IPAddress:    m.GetIpAddress(),
+		PrefixLength: uint8(m.GetPrefixLength()),
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package v1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestCreateNetworkContainerRequestRoundTrip(t *testing.T) {
+	req := cns.CreateNetworkContainerRequest{
+		Version:                    "0.1",
+		NetworkContainerType:       cns.AzureContainerInstance,
+		NetworkContainerid:         "nc1",
+		PrimaryInterfaceIdentifier: "10.0.0.4",
+		AuthorizationToken:         "token",
+		LocalIPConfiguration: cns.IPConfiguration{
+			IPSubnet:         cns.IPSubnet{IPAddress: "169.254.0.4", PrefixLength: 17},
+			GatewayIPAddress: "169.254.0.1",
+		},
+		OrchestratorContext: json.RawMessage(`{"PodName":"pod","PodNamespace":"default"}`),
+		IPConfiguration: cns.IPConfiguration{
+			IPSubnet:         cns.IPSubnet{IPAddress: "11.0.0.5", PrefixLength: 24},
+			DNSServers:       []string{"8.8.8.8", "8.8.4.4"},
+			GatewayIPAddress: "11.0.0.1",
+		},
+		MultiTenancyInfo: cns.MultiTenancyInfo{EncapType: cns.Vlan, ID: 10},
+		CnetAddressSpace: []cns.IPSubnet{{IPAddress: "168.63.129.16", PrefixLength: 32}},
+		Routes:           []cns.Route{{IPAddress: "0.0.0.0/0", GatewayIPAddress: "11.0.0.1", InterfaceToUse: "eth1"}},
+	}
+
+	// Go through the wire format to make sure every field is encoded.
+	b, err := proto.Marshal(NewCreateNetworkContainerRequest(req))
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	var m CreateNetworkContainerRequest
+	if err := proto.Unmarshal(b, &m); err != nil {
+		t.Fatalf("Failed to unmarshal request: %v", err)
+	}
+
+	if got := m.ToCNS(); !reflect.DeepEqual(got, req) {
+		t.Errorf("Round trip mismatch:\n got %+v\nwant %+v", got, req)
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	resp := cns.Response{ReturnCode: 18, Message: "error"}
+
+	if got := NewResponse(resp).ToCNS(); got != resp {
+		t.Errorf("Round trip mismatch: got %+v want %+v", got, resp)
+	}
+
+	var m *Response
+	if got := m.ToCNS(); got != (cns.Response{}) {
+		t.Errorf("Nil response converted to %+v", got)
+	}
+}
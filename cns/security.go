@@ -0,0 +1,144 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package cns
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	acn "github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Files in the TLS certificate directory.
+	TLSCertFileName     = "cns.crt"
+	TLSKeyFileName      = "cns.key"
+	TLSClientCAFileName = "ca.crt"
+
+	// Prefix of the authorization header value carrying the token.
+	BearerPrefix = "Bearer "
+)
+
+// Secures the listener with TLS and token authentication if configured.
+func (service *Service) secureListener(listener *acn.Listener) error {
+	certDir, _ := service.GetOption(acn.OptCnsTLSCertDir).(string)
+	if certDir != "" {
+		config, err := NewTLSConfig(certDir)
+		if err != nil {
+			return err
+		}
+
+		listener.SetTLSConfig(config)
+	}
+
+	tokenFile, _ := service.GetOption(acn.OptCnsAuthTokenFile).(string)
+	if tokenFile != "" {
+		token, err := ReadAuthToken(tokenFile)
+		if err != nil {
+			return err
+		}
+
+		listener.SetAuthenticator(func(r *http.Request) error {
+			return authenticateToken(r, token)
+		})
+
+		log.Printf("[Azure CNS] Requests must present the token from %v.", tokenFile)
+	}
+
+	return nil
+}
+
+// NewTLSConfig creates the server TLS configuration from the certificates in the given directory.
+// Client certificates are required if a client CA is present.
+func NewTLSConfig(certDir string) (*tls.Config, error) {
+	certFile := filepath.Join(certDir, TLSCertFileName)
+	keyFile := filepath.Join(certDir, TLSKeyFileName)
+
+	// Fail early on a missing or invalid certificate.
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return nil, fmt.Errorf("Failed to load CNS certificate: %v", err)
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// The certificate is read for each handshake, so it can be rotated by whoever provisions it.
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		},
+	}
+
+	caFile := filepath.Join(certDir, TLSClientCAFileName)
+	pool, err := LoadCertPool(caFile)
+	if err == nil {
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		log.Printf("[Azure CNS] Listener requires client certificates issued by %v.", caFile)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	log.Printf("[Azure CNS] Listener serves TLS with certificate %v.", certFile)
+
+	return config, nil
+}
+
+// LoadCertPool returns a pool containing the PEM encoded certificates in the given file.
+func LoadCertPool(fileName string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No certificates found in %v", fileName)
+	}
+
+	return pool, nil
+}
+
+// ReadAuthToken returns the token stored in the given file.
+func ReadAuthToken(fileName string) (string, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("Token file %v is empty", fileName)
+	}
+
+	return token, nil
+}
+
+// Checks that the request carries the expected token.
+func authenticateToken(r *http.Request, token string) error {
+	return CheckBearerToken(r.Header.Get("Authorization"), token)
+}
+
+// CheckBearerToken checks that the authorization header value carries the expected token.
+func CheckBearerToken(header string, token string) error {
+	if !strings.HasPrefix(header, BearerPrefix) {
+		return fmt.Errorf("Missing bearer token")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, BearerPrefix)), []byte(token)) != 1 {
+		return fmt.Errorf("Invalid bearer token")
+	}
+
+	return nil
+}
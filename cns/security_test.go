@@ -0,0 +1,207 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package cns
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	acn "github.com/Azure/azure-container-networking/common"
+)
+
+// Writes a self-signed certificate for localhost and its key to the TLS certificate directory.
+func writeTestCertificate(t *testing.T, certDir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	if err := ioutil.WriteFile(filepath.Join(certDir, TLSCertFileName), certPEM, 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(certDir, TLSKeyFileName), keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+// Returns a free local TCP address.
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		valid  bool
+	}{
+		{name: "missing header", header: "", valid: false},
+		{name: "missing bearer prefix", header: "secret", valid: false},
+		{name: "wrong token", header: "Bearer wrong", valid: false},
+		{name: "token prefix", header: "Bearer secre", valid: false},
+		{name: "valid token", header: "Bearer secret", valid: true},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodPost, "http://localhost/", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+
+		err := authenticateToken(r, "secret")
+		if (err == nil) != test.valid {
+			t.Errorf("%v: authenticateToken returned %v, expected valid:%v", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestSecureListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnssecurity")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestCertificate(t, dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+
+	service, err := NewService("cns", "test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	service.SetOption(acn.OptCnsTLSCertDir, dir)
+	service.SetOption(acn.OptCnsAuthTokenFile, tokenFile)
+
+	address := freeAddress(t)
+	listener, err := acn.NewListener(&url.URL{Scheme: "tcp", Host: address})
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+
+	if err := service.secureListener(listener); err != nil {
+		t.Fatalf("secureListener failed: %v", err)
+	}
+
+	listener.AddHandler("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	if err := listener.Start(make(chan error, 1)); err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	defer listener.Stop()
+
+	pool, err := LoadCertPool(filepath.Join(dir, TLSCertFileName))
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	tlsClient := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{name: "no token", token: "", expected: http.StatusUnauthorized},
+		{name: "wrong token", token: "wrong", expected: http.StatusUnauthorized},
+		{name: "valid token", token: "secret", expected: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "https://"+address+"/test", nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", BearerPrefix+test.token)
+		}
+
+		resp, err := tlsClient.Do(req)
+		if err != nil {
+			t.Errorf("%v: request failed: %v", test.name, err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("%v: got status %v, expected %v", test.name, resp.StatusCode, test.expected)
+		}
+	}
+
+	// A client not using TLS never reaches the handler, even with the token.
+	req, _ := http.NewRequest(http.MethodGet, "http://"+address+"/test", nil)
+	req.Header.Set("Authorization", BearerPrefix+"secret")
+
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("Plain HTTP request succeeded")
+		}
+	}
+}
+
+func TestNewTLSConfigMissingCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cnssecurity")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewTLSConfig(dir); err == nil {
+		t.Errorf("NewTLSConfig succeeded without a certificate")
+	}
+}
@@ -70,6 +70,15 @@ func (service *Service) Initialize(config *common.ServiceConfig) error {
 			return err
 		}
 
+		err = service.secureListener(listener)
+		if err != nil {
+			return err
+		}
+
+		if config.OnPanic != nil {
+			listener.SetPanicHandler(config.OnPanic)
+		}
+
 		// Start the listener.
 		err = listener.Start(config.ErrChan)
 		if err != nil {
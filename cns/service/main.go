@@ -11,6 +11,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/telemetry"
 
+	cninetwork "github.com/Azure/azure-container-networking/cni/network"
 	"github.com/Azure/azure-container-networking/cnm/ipam"
 	"github.com/Azure/azure-container-networking/cnm/network"
 	"github.com/Azure/azure-container-networking/cns/common"
@@ -108,6 +109,158 @@ var args = acn.ArgumentList{
 		Type:         "string",
 		DefaultValue: "",
 	},
+	{
+		Name:         acn.OptCnsGrpcURL,
+		Shorthand:    acn.OptCnsGrpcURLAlias,
+		Description:  "Set the URL for the CNS gRPC API to listen on",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptCnsHTTPProxyAddress,
+		Shorthand:    acn.OptCnsHTTPProxyAddressAlias,
+		Description:  "Set the address for the CNS IMDS and wireserver proxy to listen on",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptCnsDNSProxyAddress,
+		Shorthand:    acn.OptCnsDNSProxyAddressAlias,
+		Description:  "Set the address for the CNS DNS proxy to listen on",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptCnsTLSCertDir,
+		Shorthand:    acn.OptCnsTLSCertDirAlias,
+		Description:  "Set the directory containing the CNS server certificate, enables TLS and mTLS if a client CA is present",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptCnsAuthTokenFile,
+		Shorthand:    acn.OptCnsAuthTokenFileAlias,
+		Description:  "Set the file containing the bearer token clients of the REST and gRPC APIs must present",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptCnsEnablePprof,
+		Shorthand:    acn.OptCnsEnablePprofAlias,
+		Description:  "Serve the Go profiler under /debug/pprof/ on the CNS listener",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptCnsAdminURL,
+		Shorthand:    acn.OptCnsAdminURLAlias,
+		Description:  "Set the local-only URL for the CNS admin API to listen on, e.g. tcp://localhost:10091 or unix:///var/run/azure-cns-admin.sock",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptCnsCRDMode,
+		Shorthand:    acn.OptCnsCRDModeAlias,
+		Description:  "Request IP addresses and receive network containers through the NodeNetworkConfig of the node instead of DNC",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptNodeName,
+		Shorthand:    acn.OptNodeNameAlias,
+		Description:  "Set the Kubernetes node name, defaults to the host name",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptCnsNCIsolation,
+		Shorthand:    acn.OptCnsNCIsolationAlias,
+		Description:  "Isolate the network containers of different tenants on the node from each other",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptCnsCNIExecution,
+		Shorthand:    acn.OptCnsCNIExecutionAlias,
+		Description:  "Execute the ADD and DEL commands forwarded by the CNI plugin in thin client mode",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         acn.OptDncURL,
+		Shorthand:    acn.OptDncURLAlias,
+		Description:  "Set the DNC URL used to request and release IP address batches",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptIPPoolBatchSize,
+		Shorthand:    acn.OptIPPoolBatchSizeAlias,
+		Description:  "Set the number of IP addresses requested or released at once, 0 disables IP pool scaling",
+		Type:         "int",
+		DefaultValue: "0",
+	},
+	{
+		Name:         acn.OptIPPoolMinFree,
+		Shorthand:    acn.OptIPPoolMinFreeAlias,
+		Description:  "Set the number of free IP addresses below which more addresses are requested",
+		Type:         "int",
+		DefaultValue: "0",
+	},
+	{
+		Name:         acn.OptIPPoolMaxFree,
+		Shorthand:    acn.OptIPPoolMaxFreeAlias,
+		Description:  "Set the number of free IP addresses above which addresses are released",
+		Type:         "int",
+		DefaultValue: "0",
+	},
+	{
+		Name:         acn.OptDncAuthMode,
+		Shorthand:    acn.OptDncAuthModeAlias,
+		Description:  "Set how CNS authenticates to DNC, modes other than none require an https DNC URL",
+		Type:         "string",
+		DefaultValue: acn.OptDncAuthModeNone,
+		ValueMap: map[string]interface{}{
+			acn.OptDncAuthModeNone:            0,
+			acn.OptDncAuthModeManagedIdentity: 0,
+			acn.OptDncAuthModeCertificate:     0,
+		},
+	},
+	{
+		Name:         acn.OptDncAuthResource,
+		Shorthand:    acn.OptDncAuthResourceAlias,
+		Description:  "Set the resource the managed identity token presented to DNC is requested for",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptDncAuthClientID,
+		Shorthand:    acn.OptDncAuthClientIDAlias,
+		Description:  "Set the client ID of the user-assigned managed identity authenticating to DNC, empty for the system-assigned one",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptDncClientCert,
+		Shorthand:    acn.OptDncClientCertAlias,
+		Description:  "Set the PEM file holding the client certificate and key presented to DNC, reloaded when it changes",
+		Type:         "string",
+		DefaultValue: "",
+	},
+	{
+		Name:         acn.OptHeartbeatInterval,
+		Shorthand:    acn.OptHeartbeatIntervalAlias,
+		Description:  "Set the interval in seconds at which the node heartbeat is sent to DNC",
+		Type:         "int",
+		DefaultValue: "60",
+	},
+	{
+		Name:         acn.OptHeartbeatMaxBackoff,
+		Shorthand:    acn.OptHeartbeatMaxBackoffAlias,
+		Description:  "Set the maximum delay in seconds between node heartbeats while DNC is unreachable",
+		Type:         "int",
+		DefaultValue: "600",
+	},
 	{
 		Name:         acn.OptStopAzureVnet,
 		Shorthand:    acn.OptStopAzureVnetAlias,
@@ -146,6 +299,27 @@ func main() {
 	environment := acn.GetArg(acn.OptEnvironment).(string)
 	url := acn.GetArg(acn.OptAPIServerURL).(string)
 	cnsURL := acn.GetArg(acn.OptCnsURL).(string)
+	cnsGrpcURL := acn.GetArg(acn.OptCnsGrpcURL).(string)
+	httpProxyAddress := acn.GetArg(acn.OptCnsHTTPProxyAddress).(string)
+	dnsProxyAddress := acn.GetArg(acn.OptCnsDNSProxyAddress).(string)
+	tlsCertDir := acn.GetArg(acn.OptCnsTLSCertDir).(string)
+	authTokenFile := acn.GetArg(acn.OptCnsAuthTokenFile).(string)
+	enablePprof := acn.GetArg(acn.OptCnsEnablePprof).(bool)
+	adminURL := acn.GetArg(acn.OptCnsAdminURL).(string)
+	crdMode := acn.GetArg(acn.OptCnsCRDMode).(bool)
+	nodeName := acn.GetArg(acn.OptNodeName).(string)
+	ncIsolation := acn.GetArg(acn.OptCnsNCIsolation).(bool)
+	cniExecution := acn.GetArg(acn.OptCnsCNIExecution).(bool)
+	dncURL := acn.GetArg(acn.OptDncURL).(string)
+	dncAuthMode := acn.GetArg(acn.OptDncAuthMode).(string)
+	dncAuthResource := acn.GetArg(acn.OptDncAuthResource).(string)
+	dncAuthClientID := acn.GetArg(acn.OptDncAuthClientID).(string)
+	dncClientCert := acn.GetArg(acn.OptDncClientCert).(string)
+	ipPoolBatchSize, _ := acn.GetArg(acn.OptIPPoolBatchSize).(int)
+	ipPoolMinFree, _ := acn.GetArg(acn.OptIPPoolMinFree).(int)
+	ipPoolMaxFree, _ := acn.GetArg(acn.OptIPPoolMaxFree).(int)
+	heartbeatInterval, _ := acn.GetArg(acn.OptHeartbeatInterval).(int)
+	heartbeatMaxBackoff, _ := acn.GetArg(acn.OptHeartbeatMaxBackoff).(int)
 	logLevel := acn.GetArg(acn.OptLogLevel).(int)
 	logTarget := acn.GetArg(acn.OptLogTarget).(int)
 	logDirectory := acn.GetArg(acn.OptLogLocation).(string)
@@ -160,10 +334,16 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Report crashes of CNS and of its request handlers.
+	defer telemetry.CapturePanic(name, version)
+
 	// Initialize CNS.
 	var config common.ServiceConfig
 	config.Version = version
 	config.Name = name
+	config.OnPanic = func(r interface{}) {
+		telemetry.ReportPanic(name, version, r)
+	}
 
 	// Create a channel to receive unhandled errors from CNS.
 	config.ErrChan = make(chan error, 1)
@@ -182,6 +362,10 @@ func main() {
 		return
 	}
 
+	if err = log.ConfigureFromEnv(); err != nil {
+		log.Printf("[Azure CNS] Failed to apply log configuration from environment: %v", err)
+	}
+
 	if logger := log.GetStd(); logger != nil {
 		logger.SetChannel(reports)
 	}
@@ -196,7 +380,7 @@ func main() {
 	}
 
 	// Create the key value store.
-	config.Store, err = store.NewJsonFileStore(platform.CNMRuntimePath + name + ".json")
+	config.Store, err = store.NewBoltFileStore(platform.CNMRuntimePath+name+".db", platform.CNMRuntimePath+name+".json")
 	if err != nil {
 		log.Errorf("Failed to create store: %v\n", err)
 		return
@@ -211,6 +395,30 @@ func main() {
 
 	// Set CNS options.
 	httpRestService.SetOption(acn.OptCnsURL, cnsURL)
+	httpRestService.SetOption(acn.OptCnsGrpcURL, cnsGrpcURL)
+	httpRestService.SetOption(acn.OptCnsHTTPProxyAddress, httpProxyAddress)
+	httpRestService.SetOption(acn.OptCnsDNSProxyAddress, dnsProxyAddress)
+	httpRestService.SetOption(acn.OptCnsTLSCertDir, tlsCertDir)
+	httpRestService.SetOption(acn.OptCnsAuthTokenFile, authTokenFile)
+	httpRestService.SetOption(acn.OptCnsEnablePprof, enablePprof)
+	httpRestService.SetOption(acn.OptCnsAdminURL, adminURL)
+	httpRestService.SetOption(acn.OptCnsCRDMode, crdMode)
+	httpRestService.SetOption(acn.OptNodeName, nodeName)
+	httpRestService.SetOption(acn.OptCnsNCIsolation, ncIsolation)
+	httpRestService.SetOption(acn.OptDncURL, dncURL)
+	httpRestService.SetOption(acn.OptDncAuthMode, dncAuthMode)
+	httpRestService.SetOption(acn.OptDncAuthResource, dncAuthResource)
+	httpRestService.SetOption(acn.OptDncAuthClientID, dncAuthClientID)
+	httpRestService.SetOption(acn.OptDncClientCert, dncClientCert)
+	httpRestService.SetOption(acn.OptIPPoolBatchSize, ipPoolBatchSize)
+	httpRestService.SetOption(acn.OptIPPoolMinFree, ipPoolMinFree)
+	httpRestService.SetOption(acn.OptIPPoolMaxFree, ipPoolMaxFree)
+	httpRestService.SetOption(acn.OptHeartbeatInterval, heartbeatInterval)
+	httpRestService.SetOption(acn.OptHeartbeatMaxBackoff, heartbeatMaxBackoff)
+
+	if cniExecution {
+		httpRestService.(*restserver.HTTPRestService).SetCNIExecutor(cninetwork.NewExecutor())
+	}
 
 	// Start CNS.
 	if httpRestService != nil {
@@ -281,7 +489,7 @@ func main() {
 	// Wait until receiving a signal.
 	select {
 	case sig := <-osSignalChannel:
-		log.Printf("CNS Received OS signal <" + sig.String() + ">, shutting down.")
+		log.Printf("CNS Received OS signal <%v>, shutting down.", sig)
 	case err := <-config.ErrChan:
 		log.Printf("CNS Received unhandled error %v, shutting down.", err)
 	}
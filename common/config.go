@@ -16,6 +16,42 @@ const (
 	OptAPIServerURLAlias = "u"
 	OptCnsURL            = "cns-url"
 	OptCnsURLAlias       = "c"
+	OptCnsGrpcURL        = "cns-grpc-url"
+	OptCnsGrpcURLAlias   = "cg"
+
+	// CNS proxy addresses.
+	OptCnsHTTPProxyAddress      = "http-proxy-address"
+	OptCnsHTTPProxyAddressAlias = "hp"
+	OptCnsDNSProxyAddress       = "dns-proxy-address"
+	OptCnsDNSProxyAddressAlias  = "dp"
+
+	// CNS listener security.
+	OptCnsTLSCertDir         = "tls-cert-dir"
+	OptCnsTLSCertDirAlias    = "tlsdir"
+	OptCnsAuthTokenFile      = "auth-token-file"
+	OptCnsAuthTokenFileAlias = "tokenfile"
+
+	// CNS profiler.
+	OptCnsEnablePprof      = "enable-pprof"
+	OptCnsEnablePprofAlias = "pprof"
+
+	// CNS admin API.
+	OptCnsAdminURL      = "admin-url"
+	OptCnsAdminURLAlias = "au"
+
+	// CNS CRD mode.
+	OptCnsCRDMode      = "crd-mode"
+	OptCnsCRDModeAlias = "crd"
+	OptNodeName        = "node-name"
+	OptNodeNameAlias   = "node"
+
+	// CNS network container isolation.
+	OptCnsNCIsolation      = "nc-isolation"
+	OptCnsNCIsolationAlias = "nciso"
+
+	// CNS execution of the CNI commands forwarded by the CNI plugin in thin client mode.
+	OptCnsCNIExecution      = "cni-execution"
+	OptCnsCNIExecutionAlias = "cniexec"
 
 	// Logging level.
 	OptLogLevel      = "log-level"
@@ -44,14 +80,90 @@ const (
 	OptIpamQueryInterval      = "ipam-query-interval"
 	OptIpamQueryIntervalAlias = "i"
 
+	// IPAM address lease TTL in seconds, 0 to disable leases.
+	OptIpamLeaseTTL      = "ipam-lease-ttl"
+	OptIpamLeaseTTLAlias = "leasettl"
+
+	// IPAM excluded address ranges.
+	OptIpamExcludedRanges = "ipam-excluded-ranges"
+
 	// Don't Start CNM
 	OptStopAzureVnet      = "stop-azure-cnm"
 	OptStopAzureVnetAlias = "stopcnm"
 
+	// DNC URL and IP pool scaling.
+	OptDncURL               = "dnc-url"
+	OptDncURLAlias          = "dnc"
+	OptIPPoolBatchSize      = "ip-pool-batch-size"
+	OptIPPoolBatchSizeAlias = "ipbatch"
+	OptIPPoolMinFree        = "ip-pool-min-free"
+	OptIPPoolMinFreeAlias   = "ipmin"
+	OptIPPoolMaxFree        = "ip-pool-max-free"
+	OptIPPoolMaxFreeAlias   = "ipmax"
+
+	// Authentication to DNC.
+	OptDncAuthMode                = "dnc-auth-mode"
+	OptDncAuthModeAlias           = "dncauth"
+	OptDncAuthModeNone            = "none"
+	OptDncAuthModeManagedIdentity = "msi"
+	OptDncAuthModeCertificate     = "certificate"
+	OptDncAuthResource            = "dnc-auth-resource"
+	OptDncAuthResourceAlias       = "dncres"
+	OptDncAuthClientID            = "dnc-auth-client-id"
+	OptDncAuthClientIDAlias       = "dncclientid"
+	OptDncClientCert              = "dnc-client-cert"
+	OptDncClientCertAlias         = "dnccert"
+
+	// Node heartbeat to DNC, in seconds.
+	OptHeartbeatInterval        = "heartbeat-interval"
+	OptHeartbeatIntervalAlias   = "hbi"
+	OptHeartbeatMaxBackoff      = "heartbeat-max-backoff"
+	OptHeartbeatMaxBackoffAlias = "hbmax"
+
 	// Interval to send reports to host
 	OptReportToHostInterval      = "report-interval"
 	OptReportToHostIntervalAlias = "hostinterval"
 
+	// Time to wait for the store lock, in seconds.
+	OptStoreLockTimeout      = "store-lock-timeout"
+	OptStoreLockTimeoutAlias = "slt"
+
+	// Refill the warm pool of the network configuration in the environment.
+	OptRefillWarmPool      = "refill-warm-pool"
+	OptRefillWarmPoolAlias = "rwp"
+
+	// Verify the routes of the endpoint in the environment.
+	OptVerifyRoutes      = "verify-routes"
+	OptVerifyRoutesAlias = "vr"
+
+	// Watch the overlay routes of the network configuration in the environment.
+	OptWatchOverlayRoutes      = "watch-overlay-routes"
+	OptWatchOverlayRoutesAlias = "wor"
+
+	// Remove the ebtables rules of the plugin on uninstall.
+	OptRemoveL2Rules      = "remove-ebtables-rules"
+	OptRemoveL2RulesAlias = "rer"
+
+	// Print the endpoint a host interface belongs to.
+	OptLookupInterface      = "lookup-interface"
+	OptLookupInterfaceAlias = "li"
+
+	// Mirror the traffic of the endpoint a host interface belongs to, to a capture interface for a duration in seconds.
+	OptMirrorInterface      = "mirror-interface"
+	OptMirrorInterfaceAlias = "mi"
+	OptMirrorTo             = "mirror-to"
+	OptMirrorToAlias        = "mt"
+	OptMirrorDuration       = "mirror-duration"
+	OptMirrorDurationAlias  = "md"
+
+	// Stop the mirroring of the endpoint in the environment once it expires.
+	OptStopMirroring      = "stop-mirroring"
+	OptStopMirroringAlias = "sm"
+
+	// Serve the DNS64 proxy of a NAT64 translator configured in the environment.
+	OptDNS64Proxy      = "dns64-proxy"
+	OptDNS64ProxyAlias = "d64"
+
 	// Version.
 	OptVersion      = "version"
 	OptVersionAlias = "v"
@@ -0,0 +1,468 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+// Package config provides the configuration of the components, layered from their defaults, a JSON file,
+// environment variables and command line flags, in increasing order of precedence.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources of configuration values, in increasing order of precedence.
+const (
+	SourceDefault = iota
+	SourceFile
+	SourceEnv
+	SourceFlag
+	numSources
+)
+
+var sourceNames = []string{"default", "file", "environment", "flag"}
+
+// Key describes a configuration value. The type of its default value, string, bool, int, time.Duration
+// or []string, is the type of the value.
+type Key struct {
+	// Name of the value in the configuration file.
+	Name string
+	// Environment variable and command line flag setting the value, if any.
+	Env  string
+	Flag string
+	// Description printed in the usage of the flag.
+	Description string
+	// Default value.
+	Default interface{}
+	// Validate returns an error if the value is invalid.
+	Validate func(value interface{}) error
+}
+
+// ChangeFunc is called with the name and new value of a configuration value that changed.
+type ChangeFunc func(name string, value interface{})
+
+// Config is a set of layered configuration values.
+type Config struct {
+	sync.RWMutex
+	keys     map[string]*Key
+	names    []string
+	layers   [numSources]map[string]interface{}
+	filePath string
+	fileTime time.Time
+	flags    map[string]*string
+	watchers []ChangeFunc
+}
+
+// NewConfig creates a configuration of the given keys set to their default values.
+func NewConfig(keys []Key) (*Config, error) {
+	c := &Config{
+		keys:  make(map[string]*Key),
+		flags: make(map[string]*string),
+	}
+
+	for i := range c.layers {
+		c.layers[i] = make(map[string]interface{})
+	}
+
+	for i := range keys {
+		key := &keys[i]
+		if key.Name == "" || c.keys[key.Name] != nil {
+			return nil, fmt.Errorf("Invalid or duplicate configuration key %q", key.Name)
+		}
+
+		switch key.Default.(type) {
+		case string, bool, int, time.Duration, []string:
+		default:
+			return nil, fmt.Errorf("Configuration key %v has unsupported type %T", key.Name, key.Default)
+		}
+
+		if err := validate(key, key.Default); err != nil {
+			return nil, err
+		}
+
+		c.keys[key.Name] = key
+		c.names = append(c.names, key.Name)
+		c.layers[SourceDefault][key.Name] = key.Default
+	}
+
+	sort.Strings(c.names)
+
+	return c, nil
+}
+
+// RegisterFlags defines the command line flags of the keys in the given flag set.
+// The flags default to empty, so that only the flags set on the command line override the other sources.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	for _, name := range c.names {
+		key := c.keys[name]
+		if key.Flag == "" {
+			continue
+		}
+
+		usage := fmt.Sprintf("%v (default %v)", key.Description, formatValue(key.Default))
+		c.flags[name] = fs.String(key.Flag, "", usage)
+	}
+}
+
+// LoadFlags sets the values of the flags set in the given flag set, which must have been parsed.
+func (c *Config) LoadFlags(fs *flag.FlagSet) error {
+	values := make(map[string]interface{})
+	var err error
+
+	fs.Visit(func(f *flag.Flag) {
+		for name, value := range c.flags {
+			if c.keys[name].Flag == f.Name && err == nil {
+				values[name], err = parseValue(c.keys[name], *value)
+			}
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.setLayer(SourceFlag, values)
+}
+
+// LoadEnv sets the values of the environment variables that are set.
+func (c *Config) LoadEnv() error {
+	values := make(map[string]interface{})
+
+	for _, name := range c.names {
+		key := c.keys[name]
+		if key.Env == "" {
+			continue
+		}
+
+		str, ok := os.LookupEnv(key.Env)
+		if !ok {
+			continue
+		}
+
+		value, err := parseValue(key, str)
+		if err != nil {
+			return err
+		}
+		values[name] = value
+	}
+
+	return c.setLayer(SourceEnv, values)
+}
+
+// LoadFile sets the values of the JSON configuration file at the given path.
+// A missing file leaves the values of the file unset.
+func (c *Config) LoadFile(path string) error {
+	c.Lock()
+	c.filePath = path
+	c.Unlock()
+
+	return c.loadFile()
+}
+
+// Reload reads the configuration file again if it changed since it was last loaded, and notifies the changes.
+func (c *Config) Reload() error {
+	c.RLock()
+	path, fileTime := c.filePath, c.fileTime
+	c.RUnlock()
+
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err == nil && info.ModTime().Equal(fileTime) {
+		return nil
+	}
+
+	return c.loadFile()
+}
+
+// WatchFile reloads the configuration file every interval until stopCh is closed.
+func (c *Config) WatchFile(interval time.Duration, stopCh <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := c.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// loadFile reads the configuration file and sets its values.
+func (c *Config) loadFile() error {
+	c.RLock()
+	path := c.filePath
+	c.RUnlock()
+
+	values := make(map[string]interface{})
+	var modTime time.Time
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("Invalid configuration file %v: %v", path, err)
+		}
+
+		for name, rawValue := range raw {
+			key := c.keys[name]
+			if key == nil {
+				return fmt.Errorf("Unknown key %v in configuration file %v", name, path)
+			}
+
+			value, err := convertValue(key, rawValue)
+			if err != nil {
+				return err
+			}
+			values[name] = value
+		}
+	}
+
+	if err := c.setLayer(SourceFile, values); err != nil {
+		return err
+	}
+
+	c.Lock()
+	c.fileTime = modTime
+	c.Unlock()
+
+	return nil
+}
+
+// setLayer validates and replaces the values of a source, then notifies the values that changed.
+func (c *Config) setLayer(source int, values map[string]interface{}) error {
+	for name, value := range values {
+		if err := validate(c.keys[name], value); err != nil {
+			return fmt.Errorf("%v, set by %v", err, sourceNames[source])
+		}
+	}
+
+	c.Lock()
+	old := c.effective()
+	c.layers[source] = values
+	changed := c.effective()
+	watchers := c.watchers
+	c.Unlock()
+
+	for _, name := range c.names {
+		if reflect.DeepEqual(old[name], changed[name]) {
+			continue
+		}
+
+		for _, watcher := range watchers {
+			watcher(name, changed[name])
+		}
+	}
+
+	return nil
+}
+
+// effective returns the value of each key from the source with the highest precedence setting it.
+// This function should only be called when c is locked.
+func (c *Config) effective() map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, name := range c.names {
+		values[name], _ = c.lookup(name)
+	}
+
+	return values
+}
+
+// lookup returns the value of a key and the source setting it.
+// This function should only be called when c is locked.
+func (c *Config) lookup(name string) (interface{}, int) {
+	for source := numSources - 1; source > SourceDefault; source-- {
+		if value, ok := c.layers[source][name]; ok {
+			return value, source
+		}
+	}
+
+	return c.layers[SourceDefault][name], SourceDefault
+}
+
+// OnChange registers a function called when a value changes, e.g. after the configuration file is reloaded.
+func (c *Config) OnChange(f ChangeFunc) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.watchers = append(c.watchers, f)
+}
+
+// Get returns the value of the given key, or nil if there is no such key.
+func (c *Config) Get(name string) interface{} {
+	c.RLock()
+	defer c.RUnlock()
+
+	value, _ := c.lookup(name)
+	return value
+}
+
+// GetSource returns the name of the source setting the value of the given key.
+func (c *Config) GetSource(name string) string {
+	c.RLock()
+	defer c.RUnlock()
+
+	_, source := c.lookup(name)
+	return sourceNames[source]
+}
+
+// GetString returns the value of the given string key.
+func (c *Config) GetString(name string) string {
+	value, _ := c.Get(name).(string)
+	return value
+}
+
+// GetBool returns the value of the given bool key.
+func (c *Config) GetBool(name string) bool {
+	value, _ := c.Get(name).(bool)
+	return value
+}
+
+// GetInt returns the value of the given int key.
+func (c *Config) GetInt(name string) int {
+	value, _ := c.Get(name).(int)
+	return value
+}
+
+// GetDuration returns the value of the given duration key.
+func (c *Config) GetDuration(name string) time.Duration {
+	value, _ := c.Get(name).(time.Duration)
+	return value
+}
+
+// GetStringSlice returns the value of the given string list key.
+func (c *Config) GetStringSlice(name string) []string {
+	value, _ := c.Get(name).([]string)
+	return value
+}
+
+// String returns the values of all keys and their sources.
+func (c *Config) String() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	var values []string
+	for _, name := range c.names {
+		value, source := c.lookup(name)
+		values = append(values, fmt.Sprintf("%v=%v (%v)", name, formatValue(value), sourceNames[source]))
+	}
+
+	return strings.Join(values, " ")
+}
+
+// validate runs the validation of a key on a value.
+func validate(key *Key, value interface{}) error {
+	if key.Validate == nil {
+		return nil
+	}
+
+	if err := key.Validate(value); err != nil {
+		return fmt.Errorf("Invalid value %v of %v: %v", formatValue(value), key.Name, err)
+	}
+
+	return nil
+}
+
+// parseValue parses the string value of a key set by an environment variable or a flag.
+// Lists are comma separated.
+func parseValue(key *Key, str string) (interface{}, error) {
+	var (
+		value interface{}
+		err   error
+	)
+
+	switch key.Default.(type) {
+	case string:
+		value = str
+	case bool:
+		value, err = strconv.ParseBool(str)
+	case int:
+		value, err = strconv.Atoi(str)
+	case time.Duration:
+		value, err = time.ParseDuration(str)
+	case []string:
+		var list []string
+		for _, item := range strings.Split(str, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		value = list
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value %q of %v", str, key.Name)
+	}
+
+	return value, nil
+}
+
+// convertValue converts the JSON value of a key in the configuration file.
+// Durations are strings like "90s", or numbers of seconds.
+func convertValue(key *Key, raw interface{}) (interface{}, error) {
+	if str, ok := raw.(string); ok {
+		if _, isString := key.Default.(string); !isString {
+			return parseValue(key, str)
+		}
+	}
+
+	switch key.Default.(type) {
+	case string, bool:
+		if reflect.TypeOf(raw) == reflect.TypeOf(key.Default) {
+			return raw, nil
+		}
+	case int:
+		if n, ok := raw.(float64); ok && n == float64(int(n)) {
+			return int(n), nil
+		}
+	case time.Duration:
+		if n, ok := raw.(float64); ok {
+			return time.Duration(n * float64(time.Second)), nil
+		}
+	case []string:
+		if items, ok := raw.([]interface{}); ok {
+			list := []string{}
+			for _, item := range items {
+				str, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("Invalid value %v of %v", raw, key.Name)
+				}
+				list = append(list, str)
+			}
+			return list, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid value %v of %v", raw, key.Name)
+}
+
+// formatValue returns a value the way it is written in an environment variable or a flag.
+func formatValue(value interface{}) string {
+	if list, ok := value.([]string); ok {
+		return strings.Join(list, ",")
+	}
+
+	return fmt.Sprintf("%v", value)
+}
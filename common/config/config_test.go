@@ -0,0 +1,121 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestConfig(t *testing.T) *Config {
+	c, err := NewConfig([]Key{
+		{Name: "interval", Env: "TEST_CONFIG_INTERVAL", Flag: "interval", Default: time.Minute,
+			Validate: func(value interface{}) error {
+				if value.(time.Duration) <= 0 {
+					return fmt.Errorf("must be positive")
+				}
+				return nil
+			}},
+		{Name: "level", Env: "TEST_CONFIG_LEVEL", Flag: "level", Default: "info"},
+		{Name: "servers", Env: "TEST_CONFIG_SERVERS", Default: []string{}},
+		{Name: "ack", Flag: "ack", Default: false},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	return c
+}
+
+// Tests that flags override environment variables, which override the file, which overrides the defaults.
+func TestLayering(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	data := `{"interval": 30, "level": "warning", "servers": ["10.0.0.10"], "ack": true}`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestConfig(t)
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("Failed to load file: %v", err)
+	}
+
+	os.Setenv("TEST_CONFIG_LEVEL", "debug")
+	os.Setenv("TEST_CONFIG_INTERVAL", "2m")
+	defer os.Unsetenv("TEST_CONFIG_LEVEL")
+	defer os.Unsetenv("TEST_CONFIG_INTERVAL")
+	if err := c.LoadEnv(); err != nil {
+		t.Fatalf("Failed to load environment: %v", err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-interval", "5m"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadFlags(fs); err != nil {
+		t.Fatalf("Failed to load flags: %v", err)
+	}
+
+	if d := c.GetDuration("interval"); d != 5*time.Minute || c.GetSource("interval") != "flag" {
+		t.Errorf("Unexpected interval %v from %v", d, c.GetSource("interval"))
+	}
+
+	if level := c.GetString("level"); level != "debug" || c.GetSource("level") != "environment" {
+		t.Errorf("Unexpected level %v from %v", level, c.GetSource("level"))
+	}
+
+	if servers := c.GetStringSlice("servers"); !reflect.DeepEqual(servers, []string{"10.0.0.10"}) {
+		t.Errorf("Unexpected servers %v", servers)
+	}
+
+	if !c.GetBool("ack") || c.GetSource("ack") != "file" {
+		t.Errorf("Unexpected ack from %v", c.GetSource("ack"))
+	}
+}
+
+// Tests that invalid values are rejected and that changes are notified.
+func TestValidationAndChanges(t *testing.T) {
+	c := newTestConfig(t)
+
+	changes := make(map[string]interface{})
+	c.OnChange(func(name string, value interface{}) {
+		changes[name] = value
+	})
+
+	os.Setenv("TEST_CONFIG_INTERVAL", "-1s")
+	defer os.Unsetenv("TEST_CONFIG_INTERVAL")
+	if err := c.LoadEnv(); err == nil {
+		t.Errorf("Expected an error for a negative interval")
+	}
+
+	os.Setenv("TEST_CONFIG_INTERVAL", "1m")
+	os.Setenv("TEST_CONFIG_SERVERS", "10.0.0.10, 10.0.0.11")
+	defer os.Unsetenv("TEST_CONFIG_SERVERS")
+	if err := c.LoadEnv(); err != nil {
+		t.Fatalf("Failed to load environment: %v", err)
+	}
+
+	// The interval is unchanged.
+	expected := map[string]interface{}{"servers": []string{"10.0.0.10", "10.0.0.11"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+
+	if _, err := NewConfig([]Key{{Name: "bad", Default: 1.5}}); err == nil {
+		t.Errorf("Expected an error for an unsupported type")
+	}
+}
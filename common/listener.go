@@ -4,6 +4,7 @@
 package common
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -23,6 +24,9 @@ type Listener struct {
 	active       bool
 	l            net.Listener
 	mux          *http.ServeMux
+	tlsConfig    *tls.Config
+	authenticate func(*http.Request) error
+	onPanic      func(interface{})
 }
 
 // NewListener creates a new Listener.
@@ -53,17 +57,59 @@ func (listener *Listener) Start(errChan chan error) error {
 		return err
 	}
 
+	if listener.tlsConfig != nil {
+		listener.l = tls.NewListener(listener.l, listener.tlsConfig)
+	}
+
 	log.Printf("[Listener] Started listening on %s.", listener.localAddress)
 
 	// Launch goroutine for servicing requests.
 	go func() {
-		errChan <- http.Serve(listener.l, listener.mux)
+		errChan <- http.Serve(listener.l, http.HandlerFunc(listener.serveHTTP))
 	}()
 
 	listener.active = true
 	return nil
 }
 
+// Authenticates a request before passing it to the registered handler.
+func (listener *Listener) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if listener.onPanic != nil {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				listener.onPanic(recovered)
+				panic(recovered)
+			}
+		}()
+	}
+
+	if listener.authenticate != nil {
+		if err := listener.authenticate(r); err != nil {
+			log.Printf("[Listener] Rejected request %v from %v: %v", r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	listener.mux.ServeHTTP(w, r)
+}
+
+// SetTLSConfig makes the listener serve TLS with the given configuration. Must be called before Start.
+func (listener *Listener) SetTLSConfig(config *tls.Config) {
+	listener.tlsConfig = config
+}
+
+// SetAuthenticator sets the function authenticating each request, which rejects the request by returning an error.
+func (listener *Listener) SetAuthenticator(authenticate func(*http.Request) error) {
+	listener.authenticate = authenticate
+}
+
+// SetPanicHandler sets the function called with the value of a panic of a handler, while its stack is intact.
+// The panic goes on to the HTTP server, which logs it and closes the connection. Must be called before Start.
+func (listener *Listener) SetPanicHandler(onPanic func(interface{})) {
+	listener.onPanic = onPanic
+}
+
 // Stop stops listening for requests.
 func (listener *Listener) Stop() {
 	// Ignore if not active.
@@ -4,6 +4,8 @@
 package common
 
 import (
+	"time"
+
 	"github.com/Azure/azure-container-networking/store"
 )
 
@@ -35,12 +37,13 @@ type IpamApi interface {
 
 // Plugin common configuration.
 type PluginConfig struct {
-	Version  string
-	NetApi   NetApi
-	IpamApi  IpamApi
-	Listener *Listener
-	ErrChan  chan error
-	Store    store.KeyValueStore
+	Version          string
+	NetApi           NetApi
+	IpamApi          IpamApi
+	Listener         *Listener
+	ErrChan          chan error
+	Store            store.KeyValueStore
+	StoreLockTimeout time.Duration
 }
 
 // NewPlugin creates a new Plugin object.
@@ -5,34 +5,12 @@ package common
 
 import (
 	"encoding/binary"
-	"encoding/xml"
 	"net"
 	"os"
 
 	"github.com/Azure/azure-container-networking/log"
 )
 
-// XmlDocument - Azure host agent XML document format.
-type XmlDocument struct {
-	XMLName   xml.Name `xml:"Interfaces"`
-	Interface []struct {
-		XMLName    xml.Name `xml:"Interface"`
-		MacAddress string   `xml:"MacAddress,attr"`
-		IsPrimary  bool     `xml:"IsPrimary,attr"`
-
-		IPSubnet []struct {
-			XMLName xml.Name `xml:"IPSubnet"`
-			Prefix  string   `xml:"Prefix,attr"`
-
-			IPAddress []struct {
-				XMLName   xml.Name `xml:"IPAddress"`
-				Address   string   `xml:"Address,attr"`
-				IsPrimary bool     `xml:"IsPrimary,attr"`
-			}
-		}
-	}
-}
-
 // LogNetworkInterfaces logs the host's network interfaces in the default namespace.
 func LogNetworkInterfaces() {
 	interfaces, err := net.Interfaces()
@@ -115,7 +93,22 @@ func StartProcess(path string) error {
 	}
 
 	args := []string{path}
-	process, err := os.StartProcess(path, args, &attr)
+	return startDetachedProcess(path, args, &attr)
+}
+
+// StartProcessWithArgs starts a detached process with the given arguments and environment.
+// The process doesn't inherit the standard streams.
+func StartProcessWithArgs(path string, args []string, env []string) error {
+	var attr = os.ProcAttr{
+		Env:   env,
+		Files: []*os.File{nil, nil, nil},
+	}
+
+	return startDetachedProcess(path, append([]string{path}, args...), &attr)
+}
+
+func startDetachedProcess(path string, args []string, attr *os.ProcAttr) error {
+	process, err := os.StartProcess(path, args, attr)
 	if err == nil {
 		// Release detaches the process
 		return process.Release()
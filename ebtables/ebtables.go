@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/Azure/azure-container-networking/log"
 )
@@ -19,6 +22,49 @@ const (
 	Delete = "-D"
 )
 
+const (
+	// Built-in chains of the nat table the rules apply to.
+	Prerouting  = "PREROUTING"
+	Postrouting = "POSTROUTING"
+
+	// Chains holding the rules of the plugin, jumped to from the built-in chains. Rules in these chains are owned
+	// by the plugin, which tells them apart from the rules of other agents as ebtables rules can't carry comments.
+	PreroutingChain  = "AZURE-CNI-PREROUTING"
+	PostroutingChain = "AZURE-CNI-POSTROUTING"
+
+	natTable = "nat"
+)
+
+var (
+	// Managed chains by built-in chain.
+	managedChains = map[string]string{
+		Prerouting:  PreroutingChain,
+		Postrouting: PostroutingChain,
+	}
+
+	// Serializes changes to the managed chains.
+	chainsLock sync.Mutex
+
+	// Whether the managed chains were set up by this process.
+	chainsReady bool
+
+	// Runs a shell command and returns its output, replaced by tests.
+	runCommand = runShellCommand
+)
+
+// Rule is a rule of the nat table, held in the managed chain of a built-in chain.
+type Rule struct {
+	// Chain is the built-in chain the rule applies to, Prerouting or Postrouting.
+	Chain string
+	// Spec holds the matches and target of the rule.
+	Spec string
+}
+
+// String returns the rule as the arguments appending it to its built-in chain.
+func (rule Rule) String() string {
+	return fmt.Sprintf("-A %s %s", rule.Chain, rule.Spec)
+}
+
 // InstallEbtables installs the ebtables package.
 func installEbtables() {
 	version, _ := ioutil.ReadFile("/proc/version")
@@ -33,68 +79,387 @@ func installEbtables() {
 	}
 }
 
+// SnatForInterfaceRule returns the MAC SNAT rule of an interface.
+func SnatForInterfaceRule(interfaceName string, macAddress net.HardwareAddr) Rule {
+	return Rule{
+		Chain: Postrouting,
+		Spec: fmt.Sprintf("-s unicast -o %s -j snat --to-src %s --snat-arp --snat-target ACCEPT",
+			interfaceName, macAddress.String()),
+	}
+}
+
 // SetSnatForInterface sets a MAC SNAT rule for an interface.
 func SetSnatForInterface(interfaceName string, macAddress net.HardwareAddr, action string) error {
-	command := fmt.Sprintf(
-		"ebtables -t nat %s POSTROUTING -s unicast -o %s -j snat --to-src %s --snat-arp --snat-target ACCEPT",
-		action, interfaceName, macAddress.String())
+	return setRule(SnatForInterfaceRule(interfaceName, macAddress), action)
+}
 
-	return executeShellCommand(command)
+// ArpReplyRule returns the ARP reply rule of the given target IP address and MAC address.
+func ArpReplyRule(ipAddress net.IP, macAddress net.HardwareAddr) Rule {
+	return Rule{
+		Chain: Prerouting,
+		Spec: fmt.Sprintf("-p ARP --arp-op Request --arp-ip-dst %s -j arpreply --arpreply-mac %s --arpreply-target DROP",
+			ipAddress, macAddress.String()),
+	}
 }
 
 // SetArpReply sets an ARP reply rule for the given target IP address and MAC address.
 func SetArpReply(ipAddress net.IP, macAddress net.HardwareAddr, action string) error {
-	command := fmt.Sprintf(
-		"ebtables -t nat %s PREROUTING -p ARP --arp-op Request --arp-ip-dst %s -j arpreply --arpreply-mac %s --arpreply-target DROP",
-		action, ipAddress, macAddress.String())
+	return setRule(ArpReplyRule(ipAddress, macAddress), action)
+}
 
-	return executeShellCommand(command)
+// DnatForArpRepliesRule returns the MAC DNAT rule of the ARP replies received on an interface.
+func DnatForArpRepliesRule(interfaceName string) Rule {
+	return Rule{
+		Chain: Prerouting,
+		Spec: fmt.Sprintf("-p ARP -i %s --arp-op Reply -j dnat --to-dst ff:ff:ff:ff:ff:ff --dnat-target ACCEPT",
+			interfaceName),
+	}
 }
 
 // SetDnatForArpReplies sets a MAC DNAT rule for ARP replies received on an interface.
 func SetDnatForArpReplies(interfaceName string, action string) error {
-	command := fmt.Sprintf(
-		"ebtables -t nat %s PREROUTING -p ARP -i %s --arp-op Reply -j dnat --to-dst ff:ff:ff:ff:ff:ff --dnat-target ACCEPT",
-		action, interfaceName)
+	return setRule(DnatForArpRepliesRule(interfaceName), action)
+}
+
+// VepaModeRules returns the rules of the VEPA mode of a bridge and its ports.
+func VepaModeRules(bridgeName string, downstreamIfNamePrefix string, upstreamMacAddress string) []Rule {
+	var rules []Rule
+
+	if !strings.HasPrefix(bridgeName, downstreamIfNamePrefix) {
+		rules = append(rules, Rule{
+			Chain: Prerouting,
+			Spec:  fmt.Sprintf("-i %s -j dnat --to-dst %s --dnat-target ACCEPT", bridgeName, upstreamMacAddress),
+		})
+	}
 
-	return executeShellCommand(command)
+	return append(rules, Rule{
+		Chain: Prerouting,
+		Spec:  fmt.Sprintf("-i %s+ -j dnat --to-dst %s --dnat-target ACCEPT", downstreamIfNamePrefix, upstreamMacAddress),
+	})
 }
 
 // SetVepaMode sets the VEPA mode for a bridge and its ports.
 func SetVepaMode(bridgeName string, downstreamIfNamePrefix string, upstreamMacAddress string, action string) error {
-	if !strings.HasPrefix(bridgeName, downstreamIfNamePrefix) {
-		command := fmt.Sprintf(
-			"ebtables -t nat %s PREROUTING -i %s -j dnat --to-dst %s --dnat-target ACCEPT",
-			action, bridgeName, upstreamMacAddress)
-
-		err := executeShellCommand(command)
-		if err != nil {
+	for _, rule := range VepaModeRules(bridgeName, downstreamIfNamePrefix, upstreamMacAddress) {
+		if err := setRule(rule, action); err != nil {
 			return err
 		}
 	}
 
-	command := fmt.Sprintf(
-		"ebtables -t nat %s PREROUTING -i %s+ -j dnat --to-dst %s --dnat-target ACCEPT",
-		action, downstreamIfNamePrefix, upstreamMacAddress)
+	return nil
+}
 
-	return executeShellCommand(command)
+// DnatForIPAddressRule returns the MAC DNAT rule of an IP address.
+func DnatForIPAddressRule(interfaceName string, ipAddress net.IP, macAddress net.HardwareAddr) Rule {
+	return Rule{
+		Chain: Prerouting,
+		Spec: fmt.Sprintf("-p IPv4 -i %s --ip-dst %s -j dnat --to-dst %s --dnat-target ACCEPT",
+			interfaceName, ipAddress.String(), macAddress.String()),
+	}
 }
 
 // SetDnatForIPAddress sets a MAC DNAT rule for an IP address.
 func SetDnatForIPAddress(interfaceName string, ipAddress net.IP, macAddress net.HardwareAddr, action string) error {
-	command := fmt.Sprintf(
-		"ebtables -t nat %s PREROUTING -p IPv4 -i %s --ip-dst %s -j dnat --to-dst %s --dnat-target ACCEPT",
-		action, interfaceName, ipAddress.String(), macAddress.String())
+	return setRule(DnatForIPAddressRule(interfaceName, ipAddress, macAddress), action)
+}
+
+// Appends a rule to its managed chain, or deletes it. Deleting a rule also deletes its copy in the built-in chain,
+// where versions without managed chains added it.
+func setRule(rule Rule, action string) error {
+	managed := fmt.Sprintf("ebtables -t %s %s %s %s", natTable, action, managedChains[rule.Chain], rule.Spec)
+
+	if action != Delete {
+		if err := ensureChains(); err != nil {
+			return err
+		}
+
+		return executeShellCommand(managed)
+	}
+
+	err := executeShellCommand(managed)
+	legacyErr := executeShellCommand(fmt.Sprintf("ebtables -t %s %s %s %s", natTable, action, rule.Chain, rule.Spec))
+	if err != nil && legacyErr == nil {
+		return nil
+	}
 
-	return executeShellCommand(command)
+	return err
 }
 
-func executeShellCommand(command string) error {
-	log.Debugf("[ebtables] %s", command)
-	cmd := exec.Command("sh", "-c", command)
-	err := cmd.Start()
+// Creates the managed chains and the jumps to them, once per process.
+func ensureChains() error {
+	chainsLock.Lock()
+	defer chainsLock.Unlock()
+
+	if chainsReady {
+		return nil
+	}
+
+	chains, err := listChains(natTable)
+	if err != nil {
+		return err
+	}
+
+	if err = createChains("ebtables -t "+natTable, chains); err != nil {
+		return err
+	}
+
+	chainsReady = true
+	return nil
+}
+
+// Creates the managed chains missing from the listed chains of the nat table, and the jumps missing to them.
+// The commands start with the given prefix, selecting the table and possibly an atomic file.
+func createChains(prefix string, chains map[string][]string) error {
+	for _, builtin := range []string{Prerouting, Postrouting} {
+		chain := managedChains[builtin]
+
+		if _, ok := chains[chain]; !ok {
+			log.Printf("[ebtables] Creating chain %v.", chain)
+			if err := executeShellCommand(fmt.Sprintf("%s -N %s", prefix, chain)); err != nil {
+				return err
+			}
+
+			// Frames not matching the rules of the plugin continue through the built-in chain.
+			if err := executeShellCommand(fmt.Sprintf("%s -P %s RETURN", prefix, chain)); err != nil {
+				return err
+			}
+		}
+
+		if countJumps(chains[builtin], chain) == 0 {
+			log.Printf("[ebtables] Adding jump from %v to %v.", builtin, chain)
+			if err := executeShellCommand(fmt.Sprintf("%s -A %s -j %s", prefix, builtin, chain)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reconcile makes the managed chains hold exactly the given rules. The rules of the chains are compared with the given
+// ones, and the chains are rebuilt when rules were deleted or changed by other agents, left behind by missed cleanups,
+// or when their jumps are missing. Copies of the rules in the built-in chains, added by versions without managed
+// chains, are deleted.
+// The chains are rebuilt in an atomic file committed to the kernel at once, so frames never see them partially
+// filled.
+func Reconcile(rules []Rule) error {
+	chainsLock.Lock()
+	defer chainsLock.Unlock()
+
+	chains, err := listChains(natTable)
+	if err != nil {
+		return err
+	}
+
+	if isInSync(chains, rules) {
+		return nil
+	}
+
+	log.Printf("[ebtables] Rebuilding chains with %v rules.", len(rules))
+
+	file, err := ioutil.TempFile("", "ebtables-nat")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	prefix := fmt.Sprintf("ebtables -t %s --atomic-file %s", natTable, file.Name())
+	if err = executeShellCommand(prefix + " --atomic-save"); err != nil {
+		return err
+	}
+
+	if err = createChains(prefix, chains); err != nil {
+		return err
+	}
+
+	for _, chain := range managedChains {
+		if err = executeShellCommand(fmt.Sprintf("%s -F %s", prefix, chain)); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range rules {
+		// Move the copies added by versions without managed chains.
+		if containsRule(chains[rule.Chain], rule.Spec) {
+			if err = executeShellCommand(fmt.Sprintf("%s -D %s %s", prefix, rule.Chain, rule.Spec)); err != nil {
+				return err
+			}
+		}
+
+		if err = executeShellCommand(fmt.Sprintf("%s -A %s %s", prefix, managedChains[rule.Chain], rule.Spec)); err != nil {
+			return err
+		}
+	}
+
+	if err = executeShellCommand(prefix + " --atomic-commit"); err != nil {
+		return err
+	}
+
+	chainsReady = true
+	return nil
+}
+
+// Returns whether the listed chains of the nat table hold the given rules in the managed chains only.
+func isInSync(chains map[string][]string, rules []Rule) bool {
+	expected := make(map[string][]string)
+	for _, rule := range rules {
+		expected[rule.Chain] = append(expected[rule.Chain], normalizeRule(rule.Spec))
+
+		if containsRule(chains[rule.Chain], rule.Spec) {
+			log.Printf("[ebtables] Rule %v is in built-in chain %v.", rule.Spec, rule.Chain)
+			return false
+		}
+	}
+
+	for builtin, chain := range managedChains {
+		current, ok := chains[chain]
+		if len(expected[builtin]) > 0 && (!ok || countJumps(chains[builtin], chain) == 0) {
+			log.Printf("[ebtables] Chain %v or its jump is missing.", chain)
+			return false
+		}
+
+		normalized := make([]string, 0, len(current))
+		for _, rule := range current {
+			normalized = append(normalized, normalizeRule(rule))
+		}
+
+		sort.Strings(normalized)
+		sort.Strings(expected[builtin])
+		if strings.Join(normalized, "\n") != strings.Join(expected[builtin], "\n") {
+			log.Printf("[ebtables] Chain %v has rules %v, expected %v.", chain, current, expected[builtin])
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns whether the listed rules of a chain contain the given rule.
+func containsRule(listed []string, spec string) bool {
+	spec = normalizeRule(spec)
+	for _, rule := range listed {
+		if normalizeRule(rule) == spec {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns a rule in the form ebtables lists it, so that the rules of the plugin compare equal to their listing.
+// Ebtables lists keywords capitalized, MAC addresses without leading zeros and the broadcast address by name.
+func normalizeRule(spec string) string {
+	fields := strings.Fields(strings.ToLower(spec))
+	for i, field := range fields {
+		if field == "broadcast" {
+			fields[i] = "ff:ff:ff:ff:ff:ff"
+			continue
+		}
+
+		bytes := strings.Split(field, ":")
+		if len(bytes) != 6 {
+			continue
+		}
+
+		for j, b := range bytes {
+			if len(b) == 1 {
+				bytes[j] = "0" + b
+			}
+		}
+
+		if mac, err := net.ParseMAC(strings.Join(bytes, ":")); err == nil {
+			fields[i] = mac.String()
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// Teardown deletes the managed chains, their rules and the jumps to them. The rules of other agents are kept.
+func Teardown() error {
+	chainsLock.Lock()
+	defer chainsLock.Unlock()
+
+	chains, err := listChains(natTable)
 	if err != nil {
 		return err
 	}
-	return cmd.Wait()
+
+	for builtin, chain := range managedChains {
+		for i := countJumps(chains[builtin], chain); i > 0; i-- {
+			if err = executeShellCommand(fmt.Sprintf("ebtables -t %s -D %s -j %s", natTable, builtin, chain)); err != nil {
+				return err
+			}
+		}
+
+		if _, ok := chains[chain]; !ok {
+			continue
+		}
+
+		log.Printf("[ebtables] Deleting chain %v with %v rules.", chain, len(chains[chain]))
+		if err = executeShellCommand(fmt.Sprintf("ebtables -t %s -F %s", natTable, chain)); err != nil {
+			return err
+		}
+
+		if err = executeShellCommand(fmt.Sprintf("ebtables -t %s -X %s", natTable, chain)); err != nil {
+			return err
+		}
+	}
+
+	chainsReady = false
+	return nil
+}
+
+// Returns the number of rules of a chain jumping to the given chain.
+func countJumps(rules []string, chain string) int {
+	count := 0
+	for _, rule := range rules {
+		if rule == "-j "+chain {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Lists the rules of the chains of a table, by chain name.
+func listChains(table string) (map[string][]string, error) {
+	out, err := runCommand(fmt.Sprintf("ebtables -t %s -L", table))
+	if err != nil {
+		return nil, err
+	}
+
+	chains := make(map[string][]string)
+	chain := ""
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+
+		// Chains start with a header like "Bridge chain: PREROUTING, entries: 2, policy: ACCEPT".
+		if strings.HasPrefix(line, "Bridge chain: ") {
+			chain = strings.TrimPrefix(line, "Bridge chain: ")
+			if i := strings.Index(chain, ","); i >= 0 {
+				chain = chain[:i]
+			}
+			chains[chain] = []string{}
+			continue
+		}
+
+		if chain != "" && line != "" {
+			chains[chain] = append(chains[chain], line)
+		}
+	}
+
+	return chains, nil
+}
+
+func executeShellCommand(command string) error {
+	log.Debugf("[ebtables] %s", command)
+	_, err := runCommand(command)
+	return err
+}
+
+func runShellCommand(command string) (string, error) {
+	out, err := exec.Command("sh", "-c", command).Output()
+	return string(out), err
 }
@@ -0,0 +1,298 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ebtables
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeTable is an in-memory nat table, executing the ebtables commands of the package.
+type fakeTable struct {
+	order    []string
+	chains   map[string][]string
+	commands []string
+	files    map[string]*fakeTable
+}
+
+func newFakeTable() *fakeTable {
+	table := &fakeTable{chains: make(map[string][]string), files: make(map[string]*fakeTable)}
+	for _, chain := range []string{Prerouting, "OUTPUT", Postrouting} {
+		table.order = append(table.order, chain)
+		table.chains[chain] = nil
+	}
+
+	return table
+}
+
+func (table *fakeTable) run(command string) (string, error) {
+	fields := strings.Fields(command)
+	if len(fields) < 4 || fields[0] != "ebtables" || fields[2] != natTable {
+		return "", fmt.Errorf("unexpected command %v", command)
+	}
+
+	if fields[3] == "--atomic-file" && len(fields) > 5 {
+		return table.runAtomic(fields[4], append(fields[:3:3], fields[5:]...))
+	}
+
+	if fields[3] == "-L" {
+		var b strings.Builder
+		b.WriteString("Bridge table: nat\n\n")
+		for _, chain := range table.order {
+			fmt.Fprintf(&b, "Bridge chain: %s, entries: %d, policy: ACCEPT\n", chain, len(table.chains[chain]))
+			for _, rule := range table.chains[chain] {
+				b.WriteString(rule + " \n")
+			}
+			b.WriteString("\n")
+		}
+		return b.String(), nil
+	}
+
+	table.commands = append(table.commands, command)
+	chain := fields[4]
+	rules, ok := table.chains[chain]
+	if !ok && fields[3] != "-N" {
+		return "", fmt.Errorf("no chain %v", chain)
+	}
+	spec := strings.Join(fields[5:], " ")
+
+	switch fields[3] {
+	case "-N":
+		if ok {
+			return "", fmt.Errorf("chain %v exists", chain)
+		}
+		table.order = append(table.order, chain)
+		table.chains[chain] = nil
+	case "-A":
+		table.chains[chain] = append(rules, spec)
+	case "-D":
+		for i, rule := range rules {
+			if rule == spec {
+				table.chains[chain] = append(rules[:i], rules[i+1:]...)
+				return "", nil
+			}
+		}
+		return "", fmt.Errorf("no rule %v in chain %v", spec, chain)
+	case "-F":
+		table.chains[chain] = nil
+	case "-X":
+		delete(table.chains, chain)
+		for i, name := range table.order {
+			if name == chain {
+				table.order = append(table.order[:i], table.order[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Runs a command on an atomic file, saved from and committed to the table.
+func (table *fakeTable) runAtomic(name string, fields []string) (string, error) {
+	switch fields[3] {
+	case "--atomic-save":
+		table.commands = append(table.commands, strings.Join(fields, " "))
+		table.files[name] = table.copy()
+		return "", nil
+	case "--atomic-commit":
+		table.commands = append(table.commands, strings.Join(fields, " "))
+		file := table.files[name]
+		if file == nil {
+			return "", fmt.Errorf("no atomic file %v", name)
+		}
+		table.order, table.chains = file.order, file.chains
+		return "", nil
+	}
+
+	file := table.files[name]
+	if file == nil {
+		return "", fmt.Errorf("no atomic file %v", name)
+	}
+
+	return file.run(strings.Join(fields, " "))
+}
+
+// Returns a copy of the chains of the table.
+func (table *fakeTable) copy() *fakeTable {
+	copied := &fakeTable{order: append([]string(nil), table.order...), chains: make(map[string][]string)}
+	for chain, rules := range table.chains {
+		copied.chains[chain] = append([]string(nil), rules...)
+	}
+
+	return copied
+}
+
+// Replaces the commands of the package with a fake table.
+func useFakeTable() (*fakeTable, func()) {
+	table := newFakeTable()
+	runCommand = table.run
+	chainsReady = false
+
+	return table, func() {
+		runCommand = runShellCommand
+		chainsReady = false
+	}
+}
+
+// Tests that rules are added to the managed chains, and that their legacy copies are deleted with them.
+func TestSetRule(t *testing.T) {
+	table, restore := useFakeTable()
+	defer restore()
+
+	ip := net.ParseIP("10.0.0.4")
+	mac, _ := net.ParseMAC("12:34:56:78:9a:bc")
+	rule := ArpReplyRule(ip, mac)
+
+	if err := SetArpReply(ip, mac, Append); err != nil {
+		t.Fatalf("SetArpReply failed: %v", err)
+	}
+
+	if rules := table.chains[PreroutingChain]; len(rules) != 1 || rules[0] != rule.Spec {
+		t.Errorf("Unexpected rules %v in managed chain", rules)
+	}
+	if rules := table.chains[Prerouting]; len(rules) != 1 || rules[0] != "-j "+PreroutingChain {
+		t.Errorf("Unexpected rules %v in built-in chain", rules)
+	}
+
+	if err := SetArpReply(ip, mac, Delete); err != nil {
+		t.Errorf("SetArpReply failed to delete the rule: %v", err)
+	}
+	if len(table.chains[PreroutingChain]) != 0 {
+		t.Errorf("Rule not deleted")
+	}
+
+	// Rules added by versions without managed chains are deleted too.
+	table.chains[Prerouting] = append(table.chains[Prerouting], rule.Spec)
+	if err := SetArpReply(ip, mac, Delete); err != nil {
+		t.Errorf("SetArpReply failed to delete the legacy rule: %v", err)
+	}
+	if rules := table.chains[Prerouting]; len(rules) != 1 {
+		t.Errorf("Legacy rule not deleted, %v", rules)
+	}
+
+	if err := SetArpReply(ip, mac, Delete); err == nil {
+		t.Errorf("SetArpReply deleted a missing rule")
+	}
+}
+
+// Tests that reconciling rebuilds the managed chains only when they differ from the given rules.
+func TestReconcile(t *testing.T) {
+	table, restore := useFakeTable()
+	defer restore()
+
+	ip := net.ParseIP("10.0.0.4")
+	mac, _ := net.ParseMAC("12:34:56:78:9a:bc")
+	rules := []Rule{
+		SnatForInterfaceRule("eth0", mac),
+		ArpReplyRule(ip, mac),
+		DnatForArpRepliesRule("eth0"),
+	}
+
+	// Rules added by versions without managed chains are moved to them, other rules are kept.
+	table.chains[Prerouting] = []string{"-p IPv4 -j ACCEPT", rules[1].Spec}
+	if err := Reconcile(rules); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if got := table.chains[PreroutingChain]; len(got) != 2 || got[0] != rules[1].Spec || got[1] != rules[2].Spec {
+		t.Errorf("Unexpected rules %v in chain %v", got, PreroutingChain)
+	}
+	if got := table.chains[PostroutingChain]; len(got) != 1 || got[0] != rules[0].Spec {
+		t.Errorf("Unexpected rules %v in chain %v", got, PostroutingChain)
+	}
+	if got := table.chains[Prerouting]; len(got) != 2 || got[0] != "-p IPv4 -j ACCEPT" || got[1] != "-j "+PreroutingChain {
+		t.Errorf("Unexpected rules %v in chain %v", got, Prerouting)
+	}
+
+	// In sync, nothing changes.
+	table.commands = nil
+	if err := Reconcile(rules); err != nil || len(table.commands) != 0 {
+		t.Errorf("Reconcile in sync ran %v, err:%v", table.commands, err)
+	}
+
+	// A rule deleted by another agent is restored, the live chains are only replaced at once.
+	table.commands = nil
+	table.chains[PreroutingChain] = table.chains[PreroutingChain][1:]
+	if err := Reconcile(rules); err != nil || len(table.chains[PreroutingChain]) != 2 {
+		t.Errorf("Deleted rule not restored, rules %v, err:%v", table.chains[PreroutingChain], err)
+	}
+	if len(table.commands) != 2 || !strings.HasSuffix(table.commands[0], "--atomic-save") ||
+		!strings.HasSuffix(table.commands[1], "--atomic-commit") {
+		t.Errorf("Chains not rebuilt atomically, ran %v", table.commands)
+	}
+
+	// A rule changed by another agent is replaced, even though the number of rules is the same.
+	table.chains[PreroutingChain][0] = "-p ARP --arp-op Request --arp-ip-dst 10.0.0.5 -j arpreply --arpreply-mac 12:34:56:78:9a:bc --arpreply-target DROP"
+	if err := Reconcile(rules); err != nil || table.chains[PreroutingChain][0] == "-p ARP --arp-op Request --arp-ip-dst 10.0.0.5 -j arpreply --arpreply-mac 12:34:56:78:9a:bc --arpreply-target DROP" {
+		t.Errorf("Changed rule not replaced, rules %v, err:%v", table.chains[PreroutingChain], err)
+	}
+
+	// A rule cleanup missed is deleted.
+	if err := Reconcile(rules[:2]); err != nil || len(table.chains[PreroutingChain]) != 1 {
+		t.Errorf("Stale rule not deleted, rules %v, err:%v", table.chains[PreroutingChain], err)
+	}
+
+	// Rules listed the way ebtables formats them are in sync.
+	mac, _ = net.ParseMAC("02:0d:3a:00:01:0f")
+	rules = []Rule{SnatForInterfaceRule("eth0", mac), DnatForArpRepliesRule("eth0")}
+	table.chains[PostroutingChain] = []string{"-s Unicast -o eth0 -j snat --to-src 2:d:3a:0:1:f --snat-arp --snat-target ACCEPT"}
+	table.chains[PreroutingChain] = []string{"-p ARP -i eth0 --arp-op Reply -j dnat --to-dst Broadcast --dnat-target ACCEPT"}
+	table.commands = nil
+	if err := Reconcile(rules); err != nil || len(table.commands) != 0 {
+		t.Errorf("Reconcile of listed rules ran %v, err:%v", table.commands, err)
+	}
+}
+
+func TestNormalizeRule(t *testing.T) {
+	tests := []struct {
+		spec       string
+		normalized string
+	}{
+		{spec: "-p IPv4 -i eth0 -j ACCEPT", normalized: "-p ipv4 -i eth0 -j accept"},
+		{spec: "--to-src 2:d:3a:0:1:f", normalized: "--to-src 02:0d:3a:00:01:0f"},
+		{spec: "--to-dst  Broadcast", normalized: "--to-dst ff:ff:ff:ff:ff:ff"},
+		{spec: "--ip-dst 10.0.0.4", normalized: "--ip-dst 10.0.0.4"},
+		{spec: "-i fe80::1:2:3:4:5", normalized: "-i fe80::1:2:3:4:5"},
+	}
+
+	for _, test := range tests {
+		if normalized := normalizeRule(test.spec); normalized != test.normalized {
+			t.Errorf("normalizeRule(%v) returned %v, expected %v", test.spec, normalized, test.normalized)
+		}
+	}
+}
+
+// Tests that teardown deletes the managed chains and keeps the rules of other agents.
+func TestTeardown(t *testing.T) {
+	table, restore := useFakeTable()
+	defer restore()
+
+	mac, _ := net.ParseMAC("12:34:56:78:9a:bc")
+
+	if err := SetSnatForInterface("eth0", mac, Append); err != nil {
+		t.Fatalf("SetSnatForInterface failed: %v", err)
+	}
+	table.chains[Postrouting] = append(table.chains[Postrouting], "-j ACCEPT")
+
+	if err := Teardown(); err != nil {
+		t.Fatalf("Teardown failed: %v", err)
+	}
+
+	for _, chain := range []string{PreroutingChain, PostroutingChain} {
+		if _, ok := table.chains[chain]; ok {
+			t.Errorf("Chain %v not deleted", chain)
+		}
+	}
+	if got := table.chains[Postrouting]; len(got) != 1 || got[0] != "-j ACCEPT" {
+		t.Errorf("Unexpected rules %v in chain %v", got, Postrouting)
+	}
+
+	// Nothing left to delete.
+	if err := Teardown(); err != nil {
+		t.Errorf("Teardown failed without chains: %v", err)
+	}
+}
@@ -24,10 +24,18 @@ var (
 	errAddressInUse            = fmt.Errorf("Address already in use")
 	errAddressNotInUse         = fmt.Errorf("Address not in use")
 	errNoAvailableAddresses    = fmt.Errorf("No available addresses")
+	errAddressReserved         = fmt.Errorf("Address is reserved")
+	errInvalidAddressRange     = fmt.Errorf("Invalid address range")
 
 	// Options used by AddressManager.
 	OptInterfaceName      = "azure.interface.name"
 	OptAddressID          = "azure.address.id"
+	OptAddressOwner       = "azure.address.owner"
 	OptAddressType        = "azure.address.type"
 	OptAddressTypeGateway = "gateway"
 )
+
+// IsAddressExhausted checks if an error reports that no address or address pool is available.
+func IsAddressExhausted(err error) bool {
+	return err == errNoAvailableAddresses || err == errNoAvailableAddressPools
+}
@@ -4,20 +4,16 @@
 package ipam
 
 import (
-	"encoding/xml"
 	"net"
-	"net/http"
 	"strings"
 	"time"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/nmagent"
 )
 
 const (
-	// Host URL to query.
-	azureQueryUrl = "http://169.254.169.254/machine/plugins?comp=nmagent&type=getinterfaceinfov1"
-
 	// Minimum time interval between consecutive queries.
 	azureQueryInterval = 10 * time.Second
 )
@@ -26,17 +22,15 @@ const (
 type azureSource struct {
 	name          string
 	sink          addressConfigSink
-	queryUrl      string
+	nmagentClient *nmagent.Client
 	queryInterval time.Duration
 	lastRefresh   time.Time
 }
 
 // Creates the Azure source.
 func newAzureSource(options map[string]interface{}) (*azureSource, error) {
+	// An empty query URL selects the NMAgent default.
 	queryUrl, _ := options[common.OptIpamQueryUrl].(string)
-	if queryUrl == "" {
-		queryUrl = azureQueryUrl
-	}
 
 	i, _ := options[common.OptIpamQueryInterval].(int)
 	queryInterval := time.Duration(i) * time.Second
@@ -46,7 +40,7 @@ func newAzureSource(options map[string]interface{}) (*azureSource, error) {
 
 	return &azureSource{
 		name:          "Azure",
-		queryUrl:      queryUrl,
+		nmagentClient: nmagent.NewClient(nmagent.Config{InterfaceInfoURL: queryUrl}),
 		queryInterval: queryInterval,
 	}, nil
 }
@@ -85,17 +79,7 @@ func (s *azureSource) refresh() error {
 	}
 
 	// Fetch configuration.
-	resp, err := http.Get(s.queryUrl)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	// Decode XML document.
-	var doc common.XmlDocument
-	decoder := xml.NewDecoder(resp.Body)
-	err = decoder.Decode(&doc)
+	doc, err := s.nmagentClient.GetInterfaceInfo()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,102 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Represents an inclusive range of addresses that is never handed out.
+type addressRange struct {
+	start net.IP
+	end   net.IP
+}
+
+// Creates a new address range from a CIDR ("10.0.0.0/28") or a
+// dash separated range ("10.0.0.4-10.0.0.10") representation.
+func newAddressRange(s string) (*addressRange, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errInvalidAddressRange
+		}
+
+		end := make(net.IP, len(subnet.IP))
+		for i := range subnet.IP {
+			end[i] = subnet.IP[i] | ^subnet.Mask[i]
+		}
+
+		return &addressRange{start: subnet.IP.To16(), end: end.To16()}, nil
+	}
+
+	p := strings.SplitN(s, "-", 2)
+	start := net.ParseIP(strings.TrimSpace(p[0]))
+	end := start
+	if len(p) == 2 {
+		end = net.ParseIP(strings.TrimSpace(p[1]))
+	}
+
+	if start == nil || end == nil ||
+		(start.To4() == nil) != (end.To4() == nil) ||
+		bytes.Compare(start.To16(), end.To16()) > 0 {
+		return nil, errInvalidAddressRange
+	}
+
+	return &addressRange{start: start.To16(), end: end.To16()}, nil
+}
+
+// Returns whether the address range contains the given address.
+func (r *addressRange) contains(addr net.IP) bool {
+	addr = addr.To16()
+	if addr == nil {
+		return false
+	}
+
+	return bytes.Compare(addr, r.start) >= 0 && bytes.Compare(addr, r.end) <= 0
+}
+
+// Parses the excluded address ranges passed in IPAM options.
+func parseExcludedRanges(ranges []string) ([]*addressRange, error) {
+	var excluded []*addressRange
+
+	for _, s := range ranges {
+		r, err := newAddressRange(s)
+		if err != nil {
+			log.Printf("[ipam] Failed to parse excluded range %v, err:%v.", s, err)
+			return nil, err
+		}
+
+		excluded = append(excluded, r)
+	}
+
+	return excluded, nil
+}
+
+// Returns whether the given address falls in any of the excluded ranges.
+func (am *addressManager) isExcluded(addr net.IP) bool {
+	for _, r := range am.excludedRanges {
+		if r.contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Marks the address records in all pools that fall in an excluded range as reserved.
+func (am *addressManager) applyExcludedRanges() {
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			for _, ar := range ap.Addresses {
+				ar.reserved = am.isExcluded(ar.Addr)
+			}
+		}
+	}
+}
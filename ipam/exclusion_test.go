@@ -0,0 +1,95 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAddressRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        string
+		err      error
+		included []string
+		excluded []string
+	}{
+		{
+			name:     "IPv4 subnet",
+			r:        "10.0.0.0/30",
+			included: []string{"10.0.0.0", "10.0.0.3"},
+			excluded: []string{"9.255.255.255", "10.0.0.4"},
+		},
+		{
+			name:     "IPv4 range",
+			r:        " 10.0.0.4 - 10.0.0.10 ",
+			included: []string{"10.0.0.4", "10.0.0.7", "10.0.0.10"},
+			excluded: []string{"10.0.0.3", "10.0.0.11", "::ffff:10.0.0.11"},
+		},
+		{
+			name:     "single address",
+			r:        "10.0.0.4",
+			included: []string{"10.0.0.4"},
+			excluded: []string{"10.0.0.5"},
+		},
+		{
+			name:     "IPv6 subnet",
+			r:        "fd00::/126",
+			included: []string{"fd00::", "fd00::3"},
+			excluded: []string{"fd00::4", "10.0.0.0"},
+		},
+		{
+			name:     "IPv6 range",
+			r:        "fd00::10-fd00::20",
+			included: []string{"fd00::10", "fd00::1a", "fd00::20"},
+			excluded: []string{"fd00::f", "fd00::21"},
+		},
+		{name: "reversed range", r: "10.0.0.10-10.0.0.4", err: errInvalidAddressRange},
+		{name: "mixed families", r: "10.0.0.4-fd00::4", err: errInvalidAddressRange},
+		{name: "invalid address", r: "10.0.0.256", err: errInvalidAddressRange},
+		{name: "invalid subnet", r: "10.0.0.0/33", err: errInvalidAddressRange},
+		{name: "empty", r: "", err: errInvalidAddressRange},
+	}
+
+	for _, test := range tests {
+		r, err := newAddressRange(test.r)
+		if err != test.err {
+			t.Errorf("TestAddressRange failed @ %v: err %v, expected %v", test.name, err, test.err)
+			continue
+		}
+
+		for _, addr := range test.included {
+			if !r.contains(net.ParseIP(addr)) {
+				t.Errorf("TestAddressRange failed @ %v: %v not in range", test.name, addr)
+			}
+		}
+
+		for _, addr := range test.excluded {
+			if r.contains(net.ParseIP(addr)) {
+				t.Errorf("TestAddressRange failed @ %v: %v in range", test.name, addr)
+			}
+		}
+	}
+}
+
+func TestParseExcludedRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []string
+		count  int
+		err    error
+	}{
+		{name: "none", ranges: nil, count: 0},
+		{name: "valid", ranges: []string{"10.0.0.0/30", "10.0.1.1-10.0.1.5", "fd00::1"}, count: 3},
+		{name: "one invalid", ranges: []string{"10.0.0.0/30", "10.0.1.5-10.0.1.1"}, err: errInvalidAddressRange},
+	}
+
+	for _, test := range tests {
+		excluded, err := parseExcludedRanges(test.ranges)
+		if err != test.err || len(excluded) != test.count {
+			t.Errorf("TestParseExcludedRanges failed @ %v: %v ranges, err %v", test.name, len(excluded), err)
+		}
+	}
+}
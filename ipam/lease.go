@@ -0,0 +1,199 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// LeaseChecker reports whether the owners of address leases are alive, e.g. by asking the container runtime.
+type LeaseChecker interface {
+	// IsAlive returns whether the owner with the given ID is alive.
+	// An error means the owner couldn't be checked, and its leases are kept.
+	IsAlive(owner string) (bool, error)
+}
+
+// leaseCheck is the result of the liveness check of the owner of address leases.
+type leaseCheck struct {
+	alive bool
+	err   error
+}
+
+// StartLeases gives addresses allocated with an owner a lease of the given TTL. Leases are renewed while the checker
+// reports their owner alive, and the addresses of owners that are gone are released once their lease expires,
+// so that allocations restored from the store after a crash don't leak. Addresses allocated without an owner
+// don't get a lease, since nothing tells whether they are still in use.
+func (am *addressManager) StartLeases(ttl time.Duration, checker LeaseChecker) error {
+	if ttl <= 0 || checker == nil {
+		return fmt.Errorf("Invalid lease TTL %v", ttl)
+	}
+
+	am.Lock()
+	defer am.Unlock()
+
+	if am.clock == nil {
+		am.clock = platform.NewClock()
+	}
+
+	am.leaseTTL = ttl
+	am.leaseChecker = checker
+	am.leaseStop = make(chan struct{})
+
+	// Allocations restored from the store get a lease, so that they are released if their owner is gone.
+	expiry := am.clock.Now().Add(ttl)
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			for _, ar := range ap.Addresses {
+				if ar.Owner != "" && ar.LeaseExpiry.IsZero() {
+					ar.LeaseExpiry = expiry
+				}
+			}
+		}
+	}
+
+	go am.runLeases(am.clock, ttl, checker, am.leaseStop)
+
+	log.Printf("[ipam] Started address leases with TTL %v.", ttl)
+	return nil
+}
+
+// StopLeases stops renewing and expiring address leases.
+func (am *addressManager) StopLeases() {
+	am.Lock()
+	defer am.Unlock()
+
+	if am.leaseStop != nil {
+		close(am.leaseStop)
+		am.leaseStop = nil
+	}
+}
+
+// Renews and expires the leases three times per TTL, until stop is closed.
+// The owners are checked without holding the lock, so that slow checks don't block address requests.
+func (am *addressManager) runLeases(clock platform.Clock, ttl time.Duration, checker LeaseChecker, stop chan struct{}) {
+	ticker := clock.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			am.Lock()
+			owners := am.getLeaseOwners()
+			am.Unlock()
+
+			checks := checkLeaseOwners(checker, owners)
+
+			am.Lock()
+			if am.renewLeases(checks) > 0 {
+				am.save()
+			}
+			am.Unlock()
+		}
+	}
+}
+
+// Returns the owners of the leased addresses.
+// This function should only be called when am is locked.
+func (am *addressManager) getLeaseOwners() []string {
+	var owners []string
+	seen := make(map[string]bool)
+
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			for _, ar := range ap.Addresses {
+				if ar.Owner != "" && !ar.LeaseExpiry.IsZero() && !seen[ar.Owner] {
+					seen[ar.Owner] = true
+					owners = append(owners, ar.Owner)
+				}
+			}
+		}
+	}
+
+	return owners
+}
+
+// Checks whether the owners of leased addresses are alive.
+func checkLeaseOwners(checker LeaseChecker, owners []string) map[string]leaseCheck {
+	checks := make(map[string]leaseCheck, len(owners))
+	for _, owner := range owners {
+		alive, err := checker.IsAlive(owner)
+		checks[owner] = leaseCheck{alive: alive, err: err}
+	}
+
+	return checks
+}
+
+// Renews the leases of the addresses whose owner is alive, and releases the addresses whose owner is gone
+// and whose lease expired. Leases of owners that couldn't be checked, or that weren't checked because the address
+// was allocated since, are kept. Returns how many leases changed.
+// This function should only be called when am is locked.
+func (am *addressManager) renewLeases(checks map[string]leaseCheck) int {
+	now := am.clock.Now()
+	changed := 0
+
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			for _, ar := range ap.Addresses {
+				if ar.Owner == "" || ar.LeaseExpiry.IsZero() {
+					continue
+				}
+
+				check, checked := checks[ar.Owner]
+				switch {
+				case !checked:
+				case check.err != nil:
+					log.Printf("[ipam] Failed to check owner %v of address %v, keeping its lease, err:%v.",
+						ar.Owner, ar.Addr, check.err)
+				case check.alive:
+					ar.LeaseExpiry = now.Add(am.leaseTTL)
+					changed++
+				case now.After(ar.LeaseExpiry):
+					log.Printf("[ipam] Lease of address %v expired, owner %v is gone.", ar.Addr, ar.Owner)
+					ap.expireAddress(ar)
+					changed++
+				}
+			}
+		}
+	}
+
+	return changed
+}
+
+// Gives a lease to the address allocated with an owner, if leases are enabled.
+// This function should only be called when am is locked.
+func (am *addressManager) startLease(ap *addressPool, address string, options map[string]string) {
+	if am.leaseTTL == 0 || options[OptAddressOwner] == "" {
+		return
+	}
+
+	ip, _, err := net.ParseCIDR(address)
+	if err != nil {
+		return
+	}
+
+	if ar := ap.Addresses[ip.String()]; ar != nil {
+		ar.LeaseExpiry = am.clock.Now().Add(am.leaseTTL)
+	}
+}
+
+// Releases an address whose lease expired.
+func (ap *addressPool) expireAddress(ar *addressRecord) {
+	delete(ap.addrsByID, ar.ID)
+	ar.ID = ""
+	ar.InUse = false
+	ar.Owner = ""
+	ar.LeaseExpiry = time.Time{}
+
+	// Delete address record if it is no longer available.
+	if ar.epoch < ap.as.epoch {
+		delete(ap.Addresses, ar.Addr.String())
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// fakeLeaseChecker reports the owners in alive as alive, and fails the checks of the owners in failing.
+type fakeLeaseChecker struct {
+	alive   map[string]bool
+	failing map[string]bool
+}
+
+func (c *fakeLeaseChecker) IsAlive(owner string) (bool, error) {
+	if c.failing[owner] {
+		return false, fmt.Errorf("runtime unavailable")
+	}
+
+	return c.alive[owner], nil
+}
+
+// blockingLeaseChecker reports every owner alive once unblocked.
+type blockingLeaseChecker struct {
+	checking chan string
+	unblock  chan struct{}
+}
+
+func (c *blockingLeaseChecker) IsAlive(owner string) (bool, error) {
+	c.checking <- owner
+	<-c.unblock
+	return true, nil
+}
+
+// Tests that leases are renewed while their owner is alive, that the addresses of owners that are gone
+// are released once their lease expires, and that leases whose owner can't be checked are kept.
+func TestAddressLeases(t *testing.T) {
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	if err := am.StartLeases(0, &fakeLeaseChecker{}); err == nil {
+		t.Errorf("StartLeases succeeded with a zero TTL")
+	}
+
+	ttl := time.Minute
+	clock := platform.NewFakeClock(time.Now())
+	checker := &fakeLeaseChecker{
+		alive:   map[string]bool{"alive": true, "failing": true},
+		failing: map[string]bool{},
+	}
+
+	amImpl := am.(*addressManager)
+	amImpl.clock = clock
+	amImpl.leaseTTL = ttl
+	amImpl.leaseChecker = checker
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, "", "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	// Make room for one address per owner, and one for an address without owner.
+	ap, _ := amImpl.AddrSpaces[LocalDefaultAddressSpaceId].getAddressPool(poolId)
+	addr14 := net.IPv4(10, 0, 1, 4)
+	ap.newAddressRecord(&addr13)
+	ap.newAddressRecord(&addr14)
+
+	addresses := make(map[string]string)
+	for _, id := range []string{"alive", "gone", "failing", "unowned"} {
+		options := map[string]string{OptAddressID: "reservation-" + id}
+		if id != "unowned" {
+			options[OptAddressOwner] = id
+		}
+
+		address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options)
+		if err != nil {
+			t.Fatalf("RequestAddress failed, err:%v", err)
+		}
+
+		addr, _, _ := net.ParseCIDR(address)
+		addresses[id] = addr.String()
+	}
+
+	leased := func(id string) bool {
+		ar := ap.Addresses[addresses[id]]
+		return ar != nil && ar.ID == "reservation-"+id
+	}
+
+	renew := func() {
+		amImpl.renewLeases(checkLeaseOwners(checker, amImpl.getLeaseOwners()))
+	}
+
+	// Addresses are checked by owner, addresses without owner have no lease.
+	if owners := amImpl.getLeaseOwners(); len(owners) != 3 {
+		t.Errorf("Unexpected lease owners %v", owners)
+	}
+
+	if ar := ap.Addresses[addresses["unowned"]]; !ar.LeaseExpiry.IsZero() {
+		t.Errorf("Address without owner got a lease")
+	}
+
+	// Before the TTL, no lease expires.
+	clock.Advance(ttl / 2)
+	checker.failing["failing"] = true
+	renew()
+
+	for id := range addresses {
+		if !leased(id) {
+			t.Errorf("Lease of %v expired before its TTL", id)
+		}
+	}
+
+	// After the TTL, only the address of the owner that is gone is released.
+	clock.Advance(ttl)
+	renew()
+
+	if !leased("alive") || !leased("failing") || !leased("unowned") {
+		t.Errorf("Lease of a live or unchecked owner expired")
+	}
+
+	if leased("gone") {
+		t.Errorf("Lease of an owner that is gone did not expire")
+	}
+
+	if ar := ap.Addresses[addresses["alive"]]; !ar.LeaseExpiry.Equal(clock.Now().Add(ttl)) {
+		t.Errorf("Lease of a live owner not renewed, expires at %v", ar.LeaseExpiry)
+	}
+
+	// The released address can be allocated again.
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addresses["gone"], map[string]string{OptAddressID: "new"})
+	if err != nil {
+		t.Errorf("RequestAddress of an expired address failed, err:%v", err)
+	} else if addr, _, _ := net.ParseCIDR(address); addr.String() != addresses["gone"] {
+		t.Errorf("RequestAddress returned %v, expected %v", address, addresses["gone"])
+	}
+
+	// Leases of owners allocated since the check are kept.
+	clock.Advance(2 * ttl)
+	amImpl.renewLeases(map[string]leaseCheck{})
+	if !leased("alive") {
+		t.Errorf("Lease of an unchecked owner expired")
+	}
+}
+
+// Tests that the owners of leases are checked without holding the address manager lock.
+func TestAddressLeasesCheckWithoutLock(t *testing.T) {
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	amImpl := am.(*addressManager)
+	clock := platform.NewFakeClock(time.Now())
+	amImpl.clock = clock
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, "", "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	checker := &blockingLeaseChecker{checking: make(chan string, 1), unblock: make(chan struct{})}
+	if err := am.StartLeases(time.Minute, checker); err != nil {
+		t.Fatalf("StartLeases failed, err:%v", err)
+	}
+	defer am.StopLeases()
+
+	options := map[string]string{OptAddressID: "reservation", OptAddressOwner: "owner"}
+	if _, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", options); err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+
+	if owner := <-checker.checking; owner != "owner" {
+		t.Errorf("Checked unexpected owner %v", owner)
+	}
+
+	// Addresses can be requested while the check is pending.
+	if _, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", map[string]string{OptAddressID: "other"}); err != nil {
+		t.Errorf("RequestAddress during a lease check failed, err:%v", err)
+	}
+
+	close(checker.unblock)
+}
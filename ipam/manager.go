@@ -4,6 +4,7 @@
 package ipam
 
 import (
+	"net"
 	"sync"
 	"time"
 
@@ -20,12 +21,20 @@ const (
 
 // AddressManager manages the set of address spaces and pools allocated to containers.
 type addressManager struct {
-	Version    string
-	TimeStamp  time.Time
-	AddrSpaces map[string]*addressSpace `json:"AddressSpaces"`
-	store      store.KeyValueStore
-	source     addressConfigSource
-	netApi     common.NetApi
+	Version        string
+	TimeStamp      time.Time
+	SurveyTime     time.Time
+	AddrSpaces     map[string]*addressSpace `json:"AddressSpaces"`
+	store          store.KeyValueStore
+	source         addressConfigSource
+	netApi         common.NetApi
+	excludedRanges []*addressRange
+	leaseTTL       time.Duration
+	leaseChecker   LeaseChecker
+	leaseStop      chan struct{}
+	clock          platform.Clock
+	metrics        map[string]*OperationMetrics // Operation is key.
+	lastFailure    string                       // Cause of the last failed operation.
 	sync.Mutex
 }
 
@@ -45,6 +54,14 @@ type AddressManager interface {
 
 	RequestAddress(asId, poolId, address string, options map[string]string) (string, error)
 	ReleaseAddress(asId, poolId, address string, options map[string]string) error
+
+	StartLeases(ttl time.Duration, checker LeaseChecker) error
+	StopLeases()
+
+	AdoptAddresses(addrs []net.IP) ([]net.IP, error)
+	Surveyed() bool
+
+	GetMetrics() *Metrics
 }
 
 // AddressConfigSource configures the address pools managed by AddressManager.
@@ -89,6 +106,7 @@ func (am *addressManager) Initialize(config *common.PluginConfig, options map[st
 
 // Uninitialize cleans up address manager.
 func (am *addressManager) Uninitialize() {
+	am.StopLeases()
 	am.StopSource()
 }
 
@@ -114,6 +132,10 @@ func (am *addressManager) restore() error {
 		}
 	}
 
+	if err = am.restoreMetrics(); err != nil {
+		log.Printf("[ipam] Failed to restore metrics, err:%v\n", err)
+	}
+
 	// Read any persisted state.
 	err = am.store.Read(storeKey, am)
 	if err != nil {
@@ -185,6 +207,14 @@ func (am *addressManager) StartSource(options map[string]interface{}) error {
 
 	environment, _ := options[common.OptEnvironment].(string)
 
+	// Addresses in excluded ranges are never handed out.
+	excludedRanges, _ := options[common.OptIpamExcludedRanges].([]string)
+	am.excludedRanges, err = parseExcludedRanges(excludedRanges)
+	if err != nil {
+		return err
+	}
+	am.applyExcludedRanges()
+
 	switch environment {
 	case common.OptEnvironmentAzure:
 		am.source, err = newAzureSource(options)
@@ -262,10 +292,13 @@ func (am *addressManager) GetDefaultAddressSpaces() (string, string) {
 }
 
 // RequestPool reserves an address pool.
-func (am *addressManager) RequestPool(asId, poolId, subPoolId string, options map[string]string, v6 bool) (string, string, error) {
+func (am *addressManager) RequestPool(asId, poolId, subPoolId string, options map[string]string, v6 bool) (_ string, _ string, err error) {
 	am.Lock()
 	defer am.Unlock()
 
+	start := am.startOperation()
+	defer func() { am.endOperation(OperationRequestPool, start, err) }()
+
 	am.refreshSource()
 
 	as, err := am.getAddressSpace(asId)
@@ -287,10 +320,13 @@ func (am *addressManager) RequestPool(asId, poolId, subPoolId string, options ma
 }
 
 // ReleasePool releases a previously reserved address pool.
-func (am *addressManager) ReleasePool(asId string, poolId string) error {
+func (am *addressManager) ReleasePool(asId string, poolId string) (err error) {
 	am.Lock()
 	defer am.Unlock()
 
+	start := am.startOperation()
+	defer func() { am.endOperation(OperationReleasePool, start, err) }()
+
 	am.refreshSource()
 
 	as, err := am.getAddressSpace(asId)
@@ -330,10 +366,13 @@ func (am *addressManager) GetPoolInfo(asId string, poolId string) (*AddressPoolI
 }
 
 // RequestAddress reserves a new address from the address pool.
-func (am *addressManager) RequestAddress(asId, poolId, address string, options map[string]string) (string, error) {
+func (am *addressManager) RequestAddress(asId, poolId, address string, options map[string]string) (_ string, err error) {
 	am.Lock()
 	defer am.Unlock()
 
+	start := am.startOperation()
+	defer func() { am.endOperation(OperationRequestAddress, start, err) }()
+
 	am.refreshSource()
 
 	as, err := am.getAddressSpace(asId)
@@ -351,6 +390,8 @@ func (am *addressManager) RequestAddress(asId, poolId, address string, options m
 		return "", err
 	}
 
+	am.startLease(ap, addr, options)
+
 	err = am.save()
 	if err != nil {
 		return "", err
@@ -360,10 +401,13 @@ func (am *addressManager) RequestAddress(asId, poolId, address string, options m
 }
 
 // ReleaseAddress releases a previously reserved address.
-func (am *addressManager) ReleaseAddress(asId string, poolId string, address string, options map[string]string) error {
+func (am *addressManager) ReleaseAddress(asId string, poolId string, address string, options map[string]string) (err error) {
 	am.Lock()
 	defer am.Unlock()
 
+	start := am.startOperation()
+	defer func() { am.endOperation(OperationReleaseAddress, start, err) }()
+
 	am.refreshSource()
 
 	as, err := am.getAddressSpace(asId)
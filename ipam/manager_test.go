@@ -5,10 +5,14 @@ package ipam
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/store"
 )
 
 var (
@@ -371,3 +375,171 @@ func TestAddressRequestsFromTheSamePool(t *testing.T) {
 		t.Errorf("ReleasePool failed, err:%v", err)
 	}
 }
+
+// Tests that addresses in excluded ranges are never handed out.
+func TestExcludedRanges(t *testing.T) {
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	options := map[string]interface{}{
+		common.OptIpamExcludedRanges: []string{"10.0.1.1-10.0.1.1", "10.0.2.0/30"},
+	}
+	err = am.StartSource(options)
+	if err != nil {
+		t.Fatalf("StartSource failed, err:%+v.", err)
+	}
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	// Request the excluded address explicitly.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addr11.String(), nil)
+	if err != errAddressReserved {
+		t.Errorf("RequestAddress returned unexpected error, err:%v.", err)
+	}
+
+	// Only the address outside the excluded range is available.
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v.", err)
+	}
+
+	ip, _, _ := net.ParseCIDR(address)
+	if !ip.Equal(addr12) {
+		t.Errorf("RequestAddress returned unexpected address %v.", address)
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != errNoAvailableAddresses {
+		t.Errorf("RequestAddress returned unexpected error, err:%v.", err)
+	}
+
+	// The whole of subnet2 that is populated is excluded.
+	info, err := am.GetPoolInfo(LocalDefaultAddressSpaceId, subnet2.String())
+	if err != nil {
+		t.Fatalf("GetPoolInfo failed, err:%v.", err)
+	}
+
+	if info.Capacity != 0 || info.Available != 0 {
+		t.Errorf("GetPoolInfo returned unexpected info %+v.", info)
+	}
+
+	// Invalid ranges are rejected.
+	options[common.OptIpamExcludedRanges] = []string{"10.0.1.10-10.0.1.1"}
+	if err = am.StartSource(options); err != errInvalidAddressRange {
+		t.Errorf("StartSource returned unexpected error, err:%v.", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil); err != nil {
+			t.Fatalf("RequestAddress failed, err:%v.", err)
+		}
+	}
+
+	// Failures are counted by cause.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != errNoAvailableAddresses {
+		t.Errorf("RequestAddress returned unexpected error, err:%v.", err)
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, "10.0.9.0/24", "", nil)
+	if err != errInvalidPoolId {
+		t.Errorf("RequestAddress returned unexpected error, err:%v.", err)
+	}
+
+	metrics := am.GetMetrics()
+
+	var requestAddress *OperationMetrics
+	for i := range metrics.Operations {
+		if metrics.Operations[i].Operation == OperationRequestAddress {
+			requestAddress = &metrics.Operations[i]
+		}
+	}
+
+	if requestAddress == nil || requestAddress.Count != 4 ||
+		requestAddress.Failures[FailureCauseExhausted] != 1 || requestAddress.Failures[FailureCauseInvalidSubnet] != 1 {
+		t.Errorf("GetMetrics returned unexpected operations %+v.", metrics.Operations)
+	}
+
+	// Utilization is reported per pool.
+	found := false
+	for _, pool := range metrics.Pools {
+		if pool.PoolId == poolId {
+			found = pool.Capacity == 2 && pool.InUse == 2
+		}
+	}
+
+	if !found {
+		t.Errorf("GetMetrics returned unexpected pools %+v.", metrics.Pools)
+	}
+
+	var b strings.Builder
+	metrics.WriteMetrics(&b)
+	if !strings.Contains(b.String(), `ipam_operation_failures_total{operation="request_address",cause="exhausted"} 1`) {
+		t.Errorf("WriteMetrics wrote unexpected metrics:\n%v", b.String())
+	}
+}
+
+func TestMetricsPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipam")
+	if err != nil {
+		t.Fatalf("Failed to create directory, err:%v.", err)
+	}
+	defer os.RemoveAll(dir)
+
+	storePath := dir + "/azure-vnet-ipam.json"
+
+	// Each instance of the address manager fails one request, as CNI plugin commands do.
+	for i := 0; i < 2; i++ {
+		kvs, err := store.NewJsonFileStore(storePath)
+		if err != nil {
+			t.Fatalf("NewJsonFileStore failed, err:%v.", err)
+		}
+
+		if err = kvs.Lock(true); err != nil {
+			t.Fatalf("Lock failed, err:%v.", err)
+		}
+
+		am, _ := NewAddressManager()
+		if err = am.Initialize(&common.PluginConfig{Store: kvs}, nil); err != nil {
+			t.Fatalf("Initialize failed, err:%v.", err)
+		}
+
+		if _, err = am.RequestAddress(LocalDefaultAddressSpaceId, "10.0.9.0/24", "", nil); err == nil {
+			t.Errorf("RequestAddress succeeded in an unknown pool.")
+		}
+
+		if cause := am.GetMetrics().LastFailureCause; cause != FailureCauseInvalidSubnet {
+			t.Errorf("GetMetrics returned last failure cause %v.", cause)
+		}
+
+		kvs.Unlock(false)
+	}
+
+	kvs, _ := store.NewJsonFileStore(storePath)
+	metrics, err := ReadMetrics(kvs)
+	if err != nil {
+		t.Fatalf("ReadMetrics failed, err:%v.", err)
+	}
+
+	if len(metrics.Operations) != 1 || metrics.Operations[0].Count != 2 ||
+		metrics.Operations[0].Failures[FailureCauseInvalidSubnet] != 2 || metrics.LastFailureCause != "" {
+		t.Errorf("ReadMetrics returned unexpected metrics %+v.", metrics)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+// Key of the metrics in the store holding the state of the address manager.
+const metricsStoreKey = "IpamMetrics"
+
+// Operations of the address manager measured by its metrics.
+const (
+	OperationRequestPool    = "request_pool"
+	OperationReleasePool    = "release_pool"
+	OperationRequestAddress = "request_address"
+	OperationReleaseAddress = "release_address"
+)
+
+// Causes of failed operations.
+const (
+	FailureCauseExhausted      = "exhausted"
+	FailureCauseStoreLock      = "store_lock"
+	FailureCauseInvalidSubnet  = "invalid_subnet"
+	FailureCauseInvalidRequest = "invalid_request"
+	FailureCauseOther          = "other"
+)
+
+// latencyBuckets are the upper bounds in seconds of the operation latency histogram.
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// OperationMetrics describes the calls of an operation of the address manager.
+type OperationMetrics struct {
+	Operation      string
+	Count          uint64
+	Failures       map[string]uint64 `json:",omitempty"` // Failure cause is key.
+	LastLatencyMs  int64
+	MaxLatencyMs   int64
+	TotalLatencyMs int64
+	TotalLatency   time.Duration
+	Buckets        []uint64 // Count of calls at most as long as each latency bucket.
+}
+
+// PoolMetrics describes the utilization of an address pool.
+type PoolMetrics struct {
+	AddressSpace string
+	PoolId       string
+	Capacity     int
+	InUse        int
+}
+
+// Metrics describes the operations of the address manager since its state was created, and the current utilization
+// of its pools. LastFailureCause is the cause of the last failed operation of this instance, if any.
+type Metrics struct {
+	Operations       []OperationMetrics
+	Pools            []PoolMetrics
+	LastFailureCause string `json:",omitempty"`
+}
+
+// GetFailureCause returns the cause of an error returned by the address manager or by the store holding its state.
+func GetFailureCause(err error) string {
+	switch {
+	case IsAddressExhausted(err):
+		return FailureCauseExhausted
+
+	case err == store.ErrStoreLocked || err == store.ErrStoreNotLocked ||
+		err == store.ErrTimeoutLockingStore || err == store.ErrNonBlockingLockIsAlreadyLocked:
+		return FailureCauseStoreLock
+
+	case err == errInvalidAddressSpace || err == errInvalidPoolId || err == errInvalidScope ||
+		err == errAddressPoolNotFound || err == errInvalidAddressRange:
+		return FailureCauseInvalidSubnet
+
+	case err == errInvalidAddress || err == errAddressNotFound || err == errAddressInUse ||
+		err == errAddressNotInUse || err == errAddressReserved || err == errAddressPoolInUse ||
+		err == errAddressPoolNotInUse:
+		return FailureCauseInvalidRequest
+
+	default:
+		return FailureCauseOther
+	}
+}
+
+// observe records a call of an operation.
+func (metrics *OperationMetrics) observe(d time.Duration, err error) {
+	if len(metrics.Buckets) != len(latencyBuckets) {
+		metrics.Buckets = make([]uint64, len(latencyBuckets))
+	}
+
+	metrics.Count++
+	metrics.TotalLatency += d
+	metrics.TotalLatencyMs = int64(metrics.TotalLatency / time.Millisecond)
+	metrics.LastLatencyMs = int64(d / time.Millisecond)
+	if metrics.LastLatencyMs > metrics.MaxLatencyMs {
+		metrics.MaxLatencyMs = metrics.LastLatencyMs
+	}
+
+	for i, bound := range latencyBuckets {
+		if d.Seconds() <= bound {
+			metrics.Buckets[i]++
+		}
+	}
+
+	if err != nil {
+		if metrics.Failures == nil {
+			metrics.Failures = make(map[string]uint64)
+		}
+		metrics.Failures[GetFailureCause(err)]++
+	}
+}
+
+// Restores the metrics persisted by the previous instances of the address manager.
+// This function should only be called when am is locked.
+func (am *addressManager) restoreMetrics() error {
+	metrics := make(map[string]*OperationMetrics)
+	if err := am.store.Read(metricsStoreKey, &metrics); err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+
+	am.metrics = metrics
+	return nil
+}
+
+// Persists the metrics, separately from the state which is only saved once an operation succeeds, so that the
+// metrics of short-lived instances such as the CNI plugin accumulate.
+// This function should only be called when am is locked.
+func (am *addressManager) saveMetrics() {
+	if am.store == nil {
+		return
+	}
+
+	if err := am.store.Write(metricsStoreKey, am.metrics); err != nil {
+		log.Printf("[ipam] Failed to save metrics, err:%v.", err)
+	}
+}
+
+// ReadMetrics returns the metrics of the address manager whose state is persisted in the given store.
+func ReadMetrics(kvs store.KeyValueStore) (*Metrics, error) {
+	if err := kvs.Lock(true); err != nil {
+		return nil, err
+	}
+	defer kvs.Unlock(false)
+
+	am := &addressManager{store: kvs}
+	if err := kvs.Read(storeKey, am); err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	if err := am.restoreMetrics(); err != nil {
+		return nil, err
+	}
+
+	return am.getMetrics(), nil
+}
+
+// Returns the time an operation starts.
+// This function should only be called when am is locked.
+func (am *addressManager) startOperation() time.Time {
+	if am.clock == nil {
+		am.clock = platform.NewClock()
+	}
+
+	return am.clock.Now()
+}
+
+// Records a call of an operation started at the given time.
+// This function should only be called when am is locked.
+func (am *addressManager) endOperation(operation string, start time.Time, err error) {
+	if am.metrics == nil {
+		am.metrics = make(map[string]*OperationMetrics)
+	}
+
+	metrics, ok := am.metrics[operation]
+	if !ok {
+		metrics = &OperationMetrics{Operation: operation}
+		am.metrics[operation] = metrics
+	}
+
+	metrics.observe(am.clock.Since(start), err)
+
+	if err != nil {
+		am.lastFailure = GetFailureCause(err)
+	}
+
+	am.saveMetrics()
+}
+
+// GetMetrics returns the metrics of the address manager.
+func (am *addressManager) GetMetrics() *Metrics {
+	am.Lock()
+	defer am.Unlock()
+
+	return am.getMetrics()
+}
+
+// Returns the metrics of the address manager.
+// This function should only be called when am is locked.
+func (am *addressManager) getMetrics() *Metrics {
+	metrics := &Metrics{LastFailureCause: am.lastFailure}
+
+	for _, operation := range am.metrics {
+		m := *operation
+		m.Failures = make(map[string]uint64)
+		for cause, count := range operation.Failures {
+			m.Failures[cause] = count
+		}
+		m.Buckets = append([]uint64(nil), operation.Buckets...)
+		metrics.Operations = append(metrics.Operations, m)
+	}
+
+	for _, as := range am.AddrSpaces {
+		for _, ap := range as.Pools {
+			info := ap.getInfo()
+			metrics.Pools = append(metrics.Pools, PoolMetrics{
+				AddressSpace: as.Id,
+				PoolId:       ap.Id,
+				Capacity:     info.Capacity,
+				InUse:        info.Capacity - info.Available,
+			})
+		}
+	}
+
+	sort.Slice(metrics.Operations, func(i, j int) bool {
+		return metrics.Operations[i].Operation < metrics.Operations[j].Operation
+	})
+
+	sort.Slice(metrics.Pools, func(i, j int) bool {
+		if metrics.Pools[i].AddressSpace != metrics.Pools[j].AddressSpace {
+			return metrics.Pools[i].AddressSpace < metrics.Pools[j].AddressSpace
+		}
+		return metrics.Pools[i].PoolId < metrics.Pools[j].PoolId
+	})
+
+	return metrics
+}
+
+// WriteMetrics writes the metrics in the Prometheus text format.
+func (metrics *Metrics) WriteMetrics(w io.Writer) {
+	fmt.Fprintf(w, "# HELP ipam_operation_duration_seconds Latency of the operations of the address manager.\n")
+	fmt.Fprintf(w, "# TYPE ipam_operation_duration_seconds histogram\n")
+	for _, operation := range metrics.Operations {
+		for i, bound := range latencyBuckets {
+			var count uint64
+			if i < len(operation.Buckets) {
+				count = operation.Buckets[i]
+			}
+			fmt.Fprintf(w, "ipam_operation_duration_seconds_bucket{operation=\"%s\",le=\"%g\"} %d\n", operation.Operation, bound, count)
+		}
+		fmt.Fprintf(w, "ipam_operation_duration_seconds_bucket{operation=\"%s\",le=\"+Inf\"} %d\n", operation.Operation, operation.Count)
+		fmt.Fprintf(w, "ipam_operation_duration_seconds_sum{operation=\"%s\"} %g\n", operation.Operation, operation.TotalLatency.Seconds())
+		fmt.Fprintf(w, "ipam_operation_duration_seconds_count{operation=\"%s\"} %d\n", operation.Operation, operation.Count)
+	}
+
+	fmt.Fprintf(w, "# HELP ipam_operation_failures_total Failed operations of the address manager by cause.\n")
+	fmt.Fprintf(w, "# TYPE ipam_operation_failures_total counter\n")
+	for _, operation := range metrics.Operations {
+		var causes []string
+		for cause := range operation.Failures {
+			causes = append(causes, cause)
+		}
+		sort.Strings(causes)
+
+		for _, cause := range causes {
+			fmt.Fprintf(w, "ipam_operation_failures_total{operation=\"%s\",cause=\"%s\"} %d\n", operation.Operation, cause, operation.Failures[cause])
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP ipam_pool_addresses Addresses of an address pool that can be allocated.\n")
+	fmt.Fprintf(w, "# TYPE ipam_pool_addresses gauge\n")
+	for _, pool := range metrics.Pools {
+		fmt.Fprintf(w, "ipam_pool_addresses{address_space=\"%s\",pool=\"%s\"} %d\n", pool.AddressSpace, pool.PoolId, pool.Capacity)
+	}
+
+	fmt.Fprintf(w, "# HELP ipam_pool_addresses_in_use Addresses of an address pool allocated.\n")
+	fmt.Fprintf(w, "# TYPE ipam_pool_addresses_in_use gauge\n")
+	for _, pool := range metrics.Pools {
+		fmt.Fprintf(w, "ipam_pool_addresses_in_use{address_space=\"%s\",pool=\"%s\"} %d\n", pool.AddressSpace, pool.PoolId, pool.InUse)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
@@ -77,11 +78,14 @@ type AddressPoolInfo struct {
 
 // Represents an IP address in a pool.
 type addressRecord struct {
-	ID        string
-	Addr      net.IP
-	InUse     bool
-	unhealthy bool
-	epoch     int
+	ID          string
+	Addr        net.IP
+	InUse       bool
+	Owner       string
+	LeaseExpiry time.Time
+	unhealthy   bool
+	reserved    bool
+	epoch       int
 }
 
 //
@@ -162,6 +166,8 @@ func (am *addressManager) setAddressSpace(as *addressSpace) error {
 		as1.merge(as)
 	}
 
+	am.applyExcludedRanges()
+
 	// Notify NetPlugin of external interfaces.
 	if am.netApi != nil {
 		for _, ap := range as.Pools {
@@ -393,10 +399,14 @@ func (as *addressSpace) releasePool(poolId string) error {
 //
 // Returns address pool information.
 func (ap *addressPool) getInfo() *AddressPoolInfo {
-	var available int
+	var available, capacity int
 	var unhealthyAddrs []net.IP
 
 	for _, ar := range ap.Addresses {
+		if ar.reserved {
+			continue
+		}
+		capacity++
 		if !ar.InUse {
 			available++
 		}
@@ -412,7 +422,7 @@ func (ap *addressPool) getInfo() *AddressPoolInfo {
 		UnhealthyAddrs: unhealthyAddrs,
 		IsIPv6:         ap.IsIPv6,
 		Available:      available,
-		Capacity:       len(ap.Addresses),
+		Capacity:       capacity,
 	}
 
 	return info
@@ -463,6 +473,10 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 			err = errAddressNotFound
 			return "", err
 		}
+		if ar.reserved {
+			err = errAddressReserved
+			return "", err
+		}
 		if ar.InUse {
 			// Return the same address if IDs match.
 			if id == "" || id != ar.ID {
@@ -484,7 +498,7 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 	// If no address was found, return any available address.
 	if ar == nil {
 		for _, ar = range ap.Addresses {
-			if !ar.InUse && ar.ID == "" {
+			if !ar.InUse && !ar.reserved && ar.ID == "" {
 				break
 			}
 			ar = nil
@@ -502,6 +516,11 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 		ar.InUse = true
 	}
 
+	// The owner is the container holding the address, whose liveness renews the lease of the address.
+	if options[OptAddressType] != OptAddressTypeGateway {
+		ar.Owner = options[OptAddressOwner]
+	}
+
 	// Return address in CIDR notation.
 	addr = &net.IPNet{
 		IP:   ar.Addr,
@@ -552,6 +571,8 @@ func (ap *addressPool) releaseAddress(address string, options map[string]string)
 	}
 
 	ar.InUse = false
+	ar.Owner = ""
+	ar.LeaseExpiry = time.Time{}
 
 	if id != "" && ar.ID == id {
 		delete(ap.addrsByID, ar.ID)
@@ -0,0 +1,49 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// AdoptAddresses marks the given addresses, found assigned on the node, as in use in the pools holding them,
+// so that addresses assigned before the state was created, e.g. by a previous installation, are not handed out
+// again. The survey of the node is recorded in the state. Returns the addresses that were adopted.
+func (am *addressManager) AdoptAddresses(addrs []net.IP) ([]net.IP, error) {
+	am.Lock()
+	defer am.Unlock()
+
+	am.refreshSource()
+
+	var adopted []net.IP
+	for _, addr := range addrs {
+		for _, as := range am.AddrSpaces {
+			for _, ap := range as.Pools {
+				ar := ap.Addresses[addr.String()]
+				if ar == nil || ar.InUse || ar.ID != "" || ar.reserved {
+					continue
+				}
+
+				log.Printf("[ipam] Adopting address %v of pool %v assigned on the node.", ar.Addr, ap.Id)
+				ar.InUse = true
+				adopted = append(adopted, ar.Addr)
+			}
+		}
+	}
+
+	am.SurveyTime = time.Now()
+
+	return adopted, am.save()
+}
+
+// Surveyed returns whether the addresses assigned on the node were adopted since the state was created.
+func (am *addressManager) Surveyed() bool {
+	am.Lock()
+	defer am.Unlock()
+
+	return !am.SurveyTime.IsZero()
+}
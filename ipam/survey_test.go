@@ -0,0 +1,66 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"net"
+	"testing"
+)
+
+// Tests that addresses assigned on the node are adopted only when they are free in a pool.
+func TestAdoptAddresses(t *testing.T) {
+	am, err := createAddressManager()
+	if err != nil {
+		t.Fatalf("createAddressManager failed, err:%+v.", err)
+	}
+
+	if am.Surveyed() {
+		t.Errorf("New address manager is surveyed.")
+	}
+
+	poolId, _, err := am.RequestPool(LocalDefaultAddressSpaceId, subnet2.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	// addr21 is already allocated by the plugin.
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addr21.String(), nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	// addr13 is not in any pool.
+	adopted, err := am.AdoptAddresses([]net.IP{addr11, addr13, addr21})
+	if err != nil {
+		t.Fatalf("AdoptAddresses failed, err:%v", err)
+	}
+
+	if len(adopted) != 1 || !adopted[0].Equal(addr11) {
+		t.Errorf("AdoptAddresses adopted %v instead of %v.", adopted, addr11)
+	}
+
+	if !am.Surveyed() {
+		t.Errorf("Address manager is not surveyed after AdoptAddresses.")
+	}
+
+	// The adopted address is not handed out again.
+	poolId, _, err = am.RequestPool(LocalDefaultAddressSpaceId, subnet1.String(), "", nil, false)
+	if err != nil {
+		t.Fatalf("RequestPool failed, err:%v", err)
+	}
+
+	address, err := am.RequestAddress(LocalDefaultAddressSpaceId, poolId, "", nil)
+	if err != nil {
+		t.Fatalf("RequestAddress failed, err:%v", err)
+	}
+
+	if addr, _, _ := net.ParseCIDR(address); !addr.Equal(addr12) {
+		t.Errorf("RequestAddress returned %v instead of %v.", address, addr12)
+	}
+
+	_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolId, addr11.String(), nil)
+	if err == nil {
+		t.Errorf("RequestAddress returned adopted address %v.", addr11)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables overriding the log configuration of a process.
+const (
+	EnvLogFormat       = "ACN_LOG_FORMAT"
+	EnvLogLevel        = "ACN_LOG_LEVEL"
+	EnvLogMaxFileSize  = "ACN_LOG_MAX_FILE_SIZE_MB"
+	EnvLogMaxFileCount = "ACN_LOG_MAX_FILE_COUNT"
+	EnvLogMaxFileAge   = "ACN_LOG_MAX_FILE_AGE_HOURS"
+)
+
+// Config is the log configuration of a process. Zero values leave the current settings unchanged.
+type Config struct {
+	// Format is "text" or "json".
+	Format string `json:"format,omitempty"`
+
+	// Level is a default level, and levels of components, e.g. "info,net=debug,store=error".
+	Level string `json:"level,omitempty"`
+
+	MaxFileSizeMB   int `json:"maxFileSizeMB,omitempty"`
+	MaxFileCount    int `json:"maxFileCount,omitempty"`
+	MaxFileAgeHours int `json:"maxFileAgeHours,omitempty"`
+}
+
+// ParseLevel returns the log level with the given name.
+func ParseLevel(name string) (int, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Invalid log level %s", name)
+}
+
+// ParseLevels returns the default level, or -1 if it is not set, and the levels of components
+// in a level setting like "info,net=debug,store=error".
+func ParseLevels(levels string) (int, map[string]int, error) {
+	level := -1
+	componentLevels := make(map[string]int)
+	for _, setting := range strings.Split(levels, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+
+		component := ""
+		if i := strings.Index(setting, "="); i >= 0 {
+			component, setting = strings.TrimSpace(setting[:i]), strings.TrimSpace(setting[i+1:])
+		}
+
+		l, err := ParseLevel(setting)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if component == "" {
+			level = l
+		} else {
+			componentLevels[component] = l
+		}
+	}
+
+	return level, componentLevels, nil
+}
+
+// GetLevels returns the default level and the levels of components, in the form ParseLevels accepts.
+func (logger *Logger) GetLevels() string {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	levels := []string{levelNames[logger.level]}
+
+	var components []string
+	for component := range logger.componentLevels {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		levels = append(levels, component+"="+levelNames[logger.componentLevels[component]])
+	}
+
+	return strings.Join(levels, ",")
+}
+
+// SetLevels replaces the default level, if set, and the levels of all components with those in a level setting
+// like "info,net=debug,store=error". Components not in the setting follow the default level again.
+func (logger *Logger) SetLevels(levels string) error {
+	level, componentLevels, err := ParseLevels(levels)
+	if err != nil {
+		return err
+	}
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if level >= 0 {
+		logger.level = level
+	}
+	logger.componentLevels = componentLevels
+
+	return nil
+}
+
+// ParseFormat returns the log format with the given name.
+func ParseFormat(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+
+	return 0, fmt.Errorf("Invalid log format %s", name)
+}
+
+// Configure applies the given configuration. Nothing is applied if the configuration is invalid.
+func (logger *Logger) Configure(config *Config) error {
+	var format int
+	var err error
+	if config.Format != "" {
+		if format, err = ParseFormat(config.Format); err != nil {
+			return err
+		}
+	}
+
+	level, componentLevels, err := ParseLevels(config.Level)
+	if err != nil {
+		return err
+	}
+
+	if config.MaxFileSizeMB < 0 || config.MaxFileCount < 0 || config.MaxFileAgeHours < 0 {
+		return fmt.Errorf("Invalid log file limits %+v", *config)
+	}
+
+	if config.Format != "" {
+		logger.SetFormat(format)
+	}
+
+	if level >= 0 {
+		logger.SetLevel(level)
+	}
+
+	for component, l := range componentLevels {
+		logger.SetComponentLevel(component, l)
+	}
+
+	logger.mutex.Lock()
+	if config.MaxFileSizeMB > 0 {
+		logger.maxFileSize = config.MaxFileSizeMB * 1024 * 1024
+	}
+
+	if config.MaxFileCount > 0 {
+		logger.maxFileCount = config.MaxFileCount
+	}
+	logger.mutex.Unlock()
+
+	if config.MaxFileAgeHours > 0 {
+		logger.SetLogFileMaxAge(time.Duration(config.MaxFileAgeHours) * time.Hour)
+	}
+
+	return nil
+}
+
+// ConfigureFromEnv applies the configuration set in the log environment variables.
+func (logger *Logger) ConfigureFromEnv() error {
+	config := Config{
+		Format: os.Getenv(EnvLogFormat),
+		Level:  os.Getenv(EnvLogLevel),
+	}
+
+	limits := []struct {
+		name  string
+		value *int
+	}{
+		{EnvLogMaxFileSize, &config.MaxFileSizeMB},
+		{EnvLogMaxFileCount, &config.MaxFileCount},
+		{EnvLogMaxFileAge, &config.MaxFileAgeHours},
+	}
+
+	for _, limit := range limits {
+		value := os.Getenv(limit.name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value %s of %s", value, limit.name)
+		}
+		*limit.value = n
+	}
+
+	return logger.Configure(&config)
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names of common fields.
+const (
+	FieldContainerID  = "containerID"
+	FieldPodName      = "podName"
+	FieldPodNamespace = "podNamespace"
+	FieldOperation    = "operation"
+)
+
+// Fields are key-value pairs attached to log messages, so that logs can be filtered by them.
+type Fields map[string]interface{}
+
+// String returns the fields in key=value form, sorted by key.
+func (fields Fields) String() string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%+v", key, fields[key])
+	}
+
+	return b.String()
+}
+
+// Entry logs messages with a set of fields.
+type Entry struct {
+	logger *Logger
+	fields Fields
+}
+
+// WithFields returns an Entry that logs messages with the given fields.
+func (logger *Logger) WithFields(fields Fields) *Entry {
+	return &Entry{logger: logger, fields: fields}
+}
+
+// WithFields returns an Entry that logs messages with the fields of the entry and the given fields.
+func (entry *Entry) WithFields(fields Fields) *Entry {
+	merged := make(Fields, len(entry.fields)+len(fields))
+	for key, value := range entry.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+
+	return &Entry{logger: entry.logger, fields: merged}
+}
+
+// Printf logs a formatted string at info level.
+func (entry *Entry) Printf(format string, args ...interface{}) {
+	entry.logger.logf(LevelInfo, entry.fields, format, args...)
+}
+
+// Debugf logs a formatted string at debug level.
+func (entry *Entry) Debugf(format string, args ...interface{}) {
+	entry.logger.logf(LevelDebug, entry.fields, format, args...)
+}
+
+// Errorf logs a formatted string at error level and sends the string to TelemetryBuffer.
+func (entry *Entry) Errorf(format string, args ...interface{}) {
+	entry.logger.logf(LevelError, entry.fields, format, args...)
+	go func() {
+		entry.logger.reports <- fmt.Sprintf(format, args...)
+	}()
+}
@@ -4,12 +4,15 @@
 package log
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Log level
@@ -21,6 +24,12 @@ const (
 	LevelDebug
 )
 
+// Log format
+const (
+	FormatText = iota
+	FormatJSON
+)
+
 // Log target
 const (
 	TargetStderr = iota
@@ -42,19 +51,32 @@ const (
 	rotationCheckFrq = 8
 )
 
+// Names of the log levels in JSON output and configuration.
+var levelNames = map[int]string{
+	LevelAlert:   "alert",
+	LevelError:   "error",
+	LevelWarning: "warning",
+	LevelInfo:    "info",
+	LevelDebug:   "debug",
+}
+
 // Logger object
 type Logger struct {
-	l            *log.Logger
-	out          io.WriteCloser
-	name         string
-	level        int
-	target       int
-	maxFileSize  int
-	maxFileCount int
-	callCount    int
-	directory    string
-	reports      chan interface{}
-	mutex        *sync.Mutex
+	l               *log.Logger
+	out             io.WriteCloser
+	name            string
+	level           int
+	componentLevels map[string]int
+	format          int
+	target          int
+	maxFileSize     int
+	maxFileCount    int
+	maxFileAge      time.Duration
+	openedAt        time.Time
+	callCount       int
+	directory       string
+	reports         chan interface{}
+	mutex           *sync.Mutex
 }
 
 // NewLogger creates a new Logger.
@@ -64,6 +86,7 @@ func NewLogger(name string, level int, target int) *Logger {
 	logger.l = log.New(nil, logPrefix, log.LstdFlags)
 	logger.name = name
 	logger.level = level
+	logger.componentLevels = make(map[string]int)
 	logger.SetTarget(target)
 	logger.maxFileSize = maxLogFileSize
 	logger.maxFileCount = maxLogFileCount
@@ -80,13 +103,52 @@ func (logger *Logger) SetName(name string) {
 
 // SetLevel sets the log chattiness.
 func (logger *Logger) SetLevel(level int) {
+	logger.mutex.Lock()
 	logger.level = level
+	logger.mutex.Unlock()
+}
+
+// SetComponentLevel sets the log chattiness of messages tagged with the given component, e.g. "net" for "[net] ...".
+func (logger *Logger) SetComponentLevel(component string, level int) {
+	logger.mutex.Lock()
+	logger.componentLevels[component] = level
+	logger.mutex.Unlock()
+}
+
+// SetFormat sets the log format.
+func (logger *Logger) SetFormat(format int) error {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	switch format {
+	case FormatText:
+		logger.l.SetFlags(log.LstdFlags)
+	case FormatJSON:
+		// JSON messages carry their own timestamp.
+		logger.l.SetFlags(0)
+	default:
+		return fmt.Errorf("Invalid log format %d", format)
+	}
+
+	logger.format = format
+
+	return nil
 }
 
 // SetLogFileLimits sets the log file limits.
 func (logger *Logger) SetLogFileLimits(maxFileSize int, maxFileCount int) {
+	logger.mutex.Lock()
 	logger.maxFileSize = maxFileSize
 	logger.maxFileCount = maxFileCount
+	logger.mutex.Unlock()
+}
+
+// SetLogFileMaxAge sets the time after which log files are rotated and rotated log files are deleted.
+// Zero disables age based rotation.
+func (logger *Logger) SetLogFileMaxAge(maxFileAge time.Duration) {
+	logger.mutex.Lock()
+	logger.maxFileAge = maxFileAge
+	logger.mutex.Unlock()
 }
 
 // SetChannel sets the channel for error message reports.
@@ -138,12 +200,14 @@ func (logger *Logger) rotate() {
 	fileName := logger.getLogFileName()
 	fileInfo, err := os.Stat(fileName)
 	if err != nil {
-		logger.Printf("[log] Failed to query log file info %+v.", err)
+		logger.l.Printf("[log] Failed to query log file info %+v.", err)
 		return
 	}
 
-	// Rotate if size limit is reached.
-	if fileInfo.Size() >= int64(logger.maxFileSize) {
+	expired := logger.maxFileAge > 0 && time.Since(logger.openedAt) >= logger.maxFileAge
+
+	// Rotate if size or age limit is reached.
+	if fileInfo.Size() >= int64(logger.maxFileSize) || expired {
 		logger.out.Close()
 		var fn1, fn2 string
 
@@ -160,11 +224,27 @@ func (logger *Logger) rotate() {
 			}
 		}
 
+		logger.removeExpiredFiles(fileName)
+
 		// Create a new log file.
 		logger.SetTarget(TargetLogfile)
 	}
 }
 
+// Deletes the rotated log files last written before the age limit.
+func (logger *Logger) removeExpiredFiles(fileName string) {
+	if logger.maxFileAge <= 0 {
+		return
+	}
+
+	for n := 1; n < logger.maxFileCount; n++ {
+		fn := fmt.Sprintf("%v.%v", fileName, n)
+		if fileInfo, err := os.Stat(fn); err == nil && time.Since(fileInfo.ModTime()) >= logger.maxFileAge {
+			os.Remove(fn)
+		}
+	}
+}
+
 // Request logs a structured request.
 func (logger *Logger) Request(tag string, request interface{}, err error) {
 	if err == nil {
@@ -179,41 +259,97 @@ func (logger *Logger) Response(tag string, response interface{}, returnCode int,
 	if err == nil && returnCode == 0 {
 		logger.Printf("[%s] Sent %T %+v.", tag, response, response)
 	} else {
-		logger.Errorf("[%s] Code:%s, %+v %s.", tag, returnStr, response, err.Error())
+		logger.Errorf("[%s] Code:%s, %+v %v.", tag, returnStr, response, err)
 	}
 }
 
-// Logf logs a formatted string.
-func (logger *Logger) logf(format string, args ...interface{}) {
+// Returns the component a message format is tagged with, e.g. "net" for "[net] ...".
+func getComponent(format string) string {
+	if !strings.HasPrefix(format, "[") {
+		return ""
+	}
+
+	end := strings.Index(format, "]")
+	if end < 0 {
+		return ""
+	}
+
+	return format[1:end]
+}
+
+// Returns whether messages of the given level and component are logged.
+func (logger *Logger) isEnabled(level int, component string) bool {
+	if componentLevel, ok := logger.componentLevels[component]; ok {
+		return componentLevel >= level
+	}
+
+	return logger.level >= level
+}
+
+// Formats a message as a JSON object holding the message and its fields.
+func (logger *Logger) formatJSON(level int, component string, msg string, fields Fields) string {
+	entry := make(map[string]interface{}, len(fields)+4)
+	for key, value := range fields {
+		entry[key] = value
+	}
+
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = levelNames[level]
+	entry["msg"] = msg
+	if component != "" {
+		entry["component"] = component
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		// Fall back to the text form of values that can not be encoded.
+		for key, value := range fields {
+			entry[key] = fmt.Sprintf("%+v", value)
+		}
+		buf, _ = json.Marshal(entry)
+	}
+
+	return string(buf)
+}
+
+// Logf logs a formatted string with the given fields if its level is enabled.
+func (logger *Logger) logf(level int, fields Fields, format string, args ...interface{}) {
+	component := getComponent(format)
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if !logger.isEnabled(level, component) {
+		return
+	}
+
 	if logger.callCount%rotationCheckFrq == 0 {
 		logger.rotate()
 	}
 
 	logger.callCount++
-	logger.l.Printf(format, args...)
+
+	msg := fmt.Sprintf(format, args...)
+	if logger.format == FormatJSON {
+		logger.l.Print(logger.formatJSON(level, component, msg, fields))
+	} else {
+		logger.l.Print(msg + fields.String())
+	}
 }
 
 // Printf logs a formatted string at info level.
 func (logger *Logger) Printf(format string, args ...interface{}) {
-	if logger.level >= LevelInfo {
-		logger.mutex.Lock()
-		logger.logf(format, args...)
-		logger.mutex.Unlock()
-	}
+	logger.logf(LevelInfo, nil, format, args...)
 }
 
 // Debugf logs a formatted string at debug level.
 func (logger *Logger) Debugf(format string, args ...interface{}) {
-	if logger.level >= LevelDebug {
-		logger.mutex.Lock()
-		logger.logf(format, args...)
-		logger.mutex.Unlock()
-	}
+	logger.logf(LevelDebug, nil, format, args...)
 }
 
-// Errorf logs a formatted string at info level and sends the string to TelemetryBuffer.
+// Errorf logs a formatted string at error level and sends the string to TelemetryBuffer.
 func (logger *Logger) Errorf(format string, args ...interface{}) {
-	logger.Printf(format, args...)
+	logger.logf(LevelError, nil, format, args...)
 	go func() {
 		logger.reports <- fmt.Sprintf(format, args...)
 	}()
@@ -9,6 +9,7 @@ import (
 	"log"
 	"log/syslog"
 	"os"
+	"time"
 )
 
 const (
@@ -38,6 +39,7 @@ func (logger *Logger) SetTarget(target int) error {
 		if err == nil {
 			logger.l.SetOutput(io.MultiWriter(os.Stdout, logger.out))
 			logger.target = target
+			logger.openedAt = time.Now()
 			return nil
 		}
 
@@ -48,6 +50,7 @@ func (logger *Logger) SetTarget(target int) error {
 	if err == nil {
 		logger.l.SetOutput(logger.out)
 		logger.target = target
+		logger.openedAt = time.Now()
 	}
 
 	return err
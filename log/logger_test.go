@@ -4,8 +4,13 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -48,3 +53,158 @@ func TestLogFileRotatesWhenSizeLimitIsReached(t *testing.T) {
 	}
 	os.Remove(fn)
 }
+
+// Tests that JSON messages carry the level, component and fields.
+func TestJSONFormatIncludesFields(t *testing.T) {
+	l := NewLogger(logName, LevelInfo, TargetStderr)
+	var buf bytes.Buffer
+	l.l.SetOutput(&buf)
+
+	if err := l.SetFormat(FormatJSON); err != nil {
+		t.Fatalf("Failed to set format: %v", err)
+	}
+
+	l.WithFields(Fields{FieldContainerID: "c1", FieldOperation: "ADD"}).Printf("[net] Created endpoint %v.", "ep1")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode message %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"level":          "info",
+		"component":      "net",
+		"msg":            "[net] Created endpoint ep1.",
+		FieldContainerID: "c1",
+		FieldOperation:   "ADD",
+	}
+
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Unexpected %v %v in message %q", key, entry[key], buf.String())
+		}
+	}
+
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("Message %q has no time", buf.String())
+	}
+}
+
+// Tests that component levels override the level of the logger.
+func TestComponentLevelsOverrideLevel(t *testing.T) {
+	l := NewLogger(logName, LevelInfo, TargetStderr)
+	var buf bytes.Buffer
+	l.l.SetOutput(&buf)
+
+	l.SetComponentLevel("net", LevelDebug)
+	l.SetComponentLevel("store", LevelError)
+
+	l.Debugf("[net] debug net")
+	l.Debugf("[cni] debug cni")
+	l.Printf("[store] info store")
+	l.Printf("[cni] info cni")
+
+	out := buf.String()
+	if !strings.Contains(out, "debug net") || !strings.Contains(out, "info cni") {
+		t.Errorf("Missing enabled messages in %q", out)
+	}
+
+	if strings.Contains(out, "debug cni") || strings.Contains(out, "info store") {
+		t.Errorf("Found disabled messages in %q", out)
+	}
+}
+
+// Tests that levels can be changed while messages are logged, run with -race.
+func TestSetLevelWhileLogging(t *testing.T) {
+	l := NewLogger(logName, LevelInfo, TargetStderr)
+	l.l.SetOutput(ioutil.Discard)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.Printf("[net] message %d", i)
+		}
+	}()
+
+	levels, formats := []int{LevelInfo, LevelDebug}, []int{FormatText, FormatJSON}
+	for i := 0; i < 100; i++ {
+		l.SetLevel(levels[i%2])
+		l.SetFormat(formats[i%2])
+	}
+	<-done
+}
+
+// Tests that levels set at runtime replace the levels of all components.
+func TestSetLevelsReplacesComponentLevels(t *testing.T) {
+	l := NewLogger(logName, LevelInfo, TargetStderr)
+	l.SetComponentLevel("store", LevelError)
+
+	if err := l.SetLevels("warning,net=debug"); err != nil {
+		t.Fatalf("Failed to set levels: %v", err)
+	}
+
+	if levels := l.GetLevels(); levels != "warning,net=debug" {
+		t.Errorf("Unexpected levels %v", levels)
+	}
+
+	if err := l.SetLevels("net=verbose"); err == nil {
+		t.Errorf("Invalid levels were set")
+	}
+
+	if levels := l.GetLevels(); levels != "warning,net=debug" {
+		t.Errorf("Levels changed by invalid setting to %v", levels)
+	}
+}
+
+// Tests that the configuration is read from the environment.
+func TestConfigureFromEnv(t *testing.T) {
+	os.Setenv(EnvLogFormat, "json")
+	os.Setenv(EnvLogLevel, "error,net=debug")
+	os.Setenv(EnvLogMaxFileAge, "24")
+	defer func() {
+		os.Unsetenv(EnvLogFormat)
+		os.Unsetenv(EnvLogLevel)
+		os.Unsetenv(EnvLogMaxFileAge)
+	}()
+
+	l := NewLogger(logName, LevelInfo, TargetStderr)
+	if err := l.ConfigureFromEnv(); err != nil {
+		t.Fatalf("Failed to configure logger: %v", err)
+	}
+
+	if l.format != FormatJSON || l.level != LevelError || l.componentLevels["net"] != LevelDebug {
+		t.Errorf("Unexpected format %v, level %v and component levels %v", l.format, l.level, l.componentLevels)
+	}
+
+	if l.maxFileAge != 24*time.Hour {
+		t.Errorf("Unexpected max file age %v", l.maxFileAge)
+	}
+
+	os.Setenv(EnvLogLevel, "verbose")
+	if err := l.ConfigureFromEnv(); err == nil {
+		t.Errorf("Invalid level was accepted")
+	}
+}
+
+// Tests that the log file rotates when age limit is reached.
+func TestLogFileRotatesWhenAgeLimitIsReached(t *testing.T) {
+	l := NewLogger(logName, LevelInfo, TargetLogfile)
+	l.SetLogFileMaxAge(time.Hour)
+
+	l.Printf("LogText 1")
+	l.openedAt = time.Now().Add(-2 * time.Hour)
+	for i := 2; i <= rotationCheckFrq+1; i++ {
+		l.Printf("LogText %v", i)
+	}
+
+	l.Close()
+
+	fn := l.GetLogDirectory() + logName + ".log"
+	defer os.Remove(fn)
+	defer os.Remove(fn + ".1")
+
+	if _, err := os.Stat(fn + ".1"); err != nil {
+		t.Errorf("Failed to find the 1st rotated log file.")
+	}
+}
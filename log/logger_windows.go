@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 const (
@@ -30,6 +31,7 @@ func (logger *Logger) SetTarget(target int) error {
 		if err == nil {
 			logger.l.SetOutput(io.MultiWriter(os.Stdout, logger.out))
 			logger.target = target
+			logger.openedAt = time.Now()
 			return nil
 		}
 
@@ -40,6 +42,7 @@ func (logger *Logger) SetTarget(target int) error {
 	if err == nil {
 		logger.l.SetOutput(logger.out)
 		logger.target = target
+		logger.openedAt = time.Now()
 	}
 
 	return err
@@ -3,6 +3,8 @@
 
 package log
 
+import "time"
+
 // Standard logger is a pre-defined logger for convenience.
 var stdLog = NewLogger("azure-container-networking", LevelInfo, TargetStderr)
 
@@ -23,10 +25,38 @@ func SetLevel(level int) {
 	stdLog.SetLevel(level)
 }
 
+func SetComponentLevel(component string, level int) {
+	stdLog.SetComponentLevel(component, level)
+}
+
+func GetLevels() string {
+	return stdLog.GetLevels()
+}
+
+func SetLevels(levels string) error {
+	return stdLog.SetLevels(levels)
+}
+
+func SetFormat(format int) error {
+	return stdLog.SetFormat(format)
+}
+
 func SetLogFileLimits(maxFileSize int, maxFileCount int) {
 	stdLog.SetLogFileLimits(maxFileSize, maxFileCount)
 }
 
+func SetLogFileMaxAge(maxFileAge time.Duration) {
+	stdLog.SetLogFileMaxAge(maxFileAge)
+}
+
+func Configure(config *Config) error {
+	return stdLog.Configure(config)
+}
+
+func ConfigureFromEnv() error {
+	return stdLog.ConfigureFromEnv()
+}
+
 func Close() {
 	stdLog.Close()
 }
@@ -58,3 +88,7 @@ func Debugf(format string, args ...interface{}) {
 func Errorf(format string, args ...interface{}) {
 	stdLog.Errorf(format, args...)
 }
+
+func WithFields(fields Fields) *Entry {
+	return stdLog.WithFields(fields)
+}
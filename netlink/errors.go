@@ -0,0 +1,90 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+// +build linux
+
+package netlink
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Error is an error returned by the kernel in response to a netlink request.
+type Error struct {
+	Errno   syscall.Errno
+	MsgType uint16 // Type of the request message that failed.
+}
+
+// ErrDumpInterrupted is returned when the kernel state changed while it was dumped.
+// The dump is inconsistent and should be retried.
+var ErrDumpInterrupted = fmt.Errorf("Netlink dump interrupted")
+
+// Error returns the description of the error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v (netlink request type %d)", e.Errno, e.MsgType)
+}
+
+// Unwrap returns the errno of the error.
+func (e *Error) Unwrap() error {
+	return e.Errno
+}
+
+// GetErrno returns the errno of an error returned by the kernel.
+func GetErrno(err error) (syscall.Errno, bool) {
+	nlErr, ok := err.(*Error)
+	if !ok {
+		return 0, false
+	}
+
+	return nlErr.Errno, true
+}
+
+// IsExist checks if an error reports that the object to create already exists.
+func IsExist(err error) bool {
+	errno, ok := GetErrno(err)
+	return ok && errno == unix.EEXIST
+}
+
+// IsNotExist checks if an error reports that the object to change or delete doesn't exist.
+func IsNotExist(err error) bool {
+	errno, ok := GetErrno(err)
+	return ok && (errno == unix.ENOENT || errno == unix.ESRCH || errno == unix.ENODEV || errno == unix.EADDRNOTAVAIL)
+}
+
+// IsNetUnreachable checks if an error reports that the gateway of a route is unreachable.
+func IsNetUnreachable(err error) bool {
+	errno, ok := GetErrno(err)
+	return ok && errno == unix.ENETUNREACH
+}
+
+// decodeError decodes a netlink error message sent in response to a request.
+// An ack is an error message with error code set to zero, followed by the original request message header.
+func decodeError(msg *message, sent *message) error {
+	if len(msg.data) < 4 {
+		return &Error{Errno: unix.EBADMSG, MsgType: sent.Type}
+	}
+
+	errCode := int32(encoder.Uint32(msg.data[0:4]))
+	if errCode == 0 {
+		return nil
+	}
+
+	return &Error{Errno: syscall.Errno(-errCode), MsgType: sent.Type}
+}
+
+// decodeDone decodes the done message ending a multipart response.
+// The kernel sets its error code when the dump failed half way.
+func decodeDone(msg *message, sent *message) error {
+	if len(msg.data) < 4 {
+		return nil
+	}
+
+	if errCode := int32(encoder.Uint32(msg.data[0:4])); errCode < 0 {
+		return &Error{Errno: syscall.Errno(-errCode), MsgType: sent.Type}
+	}
+
+	return nil
+}
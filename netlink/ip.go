@@ -241,7 +241,13 @@ func setIpRoute(route *Route, add bool) error {
 
 	msg := newRtMsg(route.Family)
 	msg.Tos = uint8(route.Tos)
-	msg.Table = uint8(route.Table)
+
+	// Tables above 255 don't fit in the message header and are only set in the table attribute.
+	if route.Table < 256 {
+		msg.Table = uint8(route.Table)
+	} else {
+		msg.Table = unix.RT_TABLE_UNSPEC
+	}
 
 	if route.Protocol != 0 {
 		msg.Protocol = uint8(route.Protocol)
@@ -273,6 +279,10 @@ func setIpRoute(route *Route, add bool) error {
 		req.addPayload(newAttributeIpAddress(unix.RTA_GATEWAY, route.Gw))
 	}
 
+	if route.Table >= 256 {
+		req.addPayload(newAttributeUint32(unix.RTA_TABLE, uint32(route.Table)))
+	}
+
 	if route.Priority != 0 {
 		req.addPayload(newAttributeUint32(unix.RTA_PRIORITY, uint32(route.Priority)))
 	}
@@ -15,10 +15,13 @@ import (
 
 // Link types.
 const (
-	LINK_TYPE_BRIDGE = "bridge"
-	LINK_TYPE_VETH   = "veth"
-	LINK_TYPE_IPVLAN = "ipvlan"
-	LINK_TYPE_DUMMY  = "dummy"
+	LINK_TYPE_BRIDGE  = "bridge"
+	LINK_TYPE_VETH    = "veth"
+	LINK_TYPE_IPVLAN  = "ipvlan"
+	LINK_TYPE_MACVLAN = "macvlan"
+	LINK_TYPE_DUMMY   = "dummy"
+	LINK_TYPE_VRF     = "vrf"
+	LINK_TYPE_VXLAN   = "vxlan"
 )
 
 // IPVLAN link attributes.
@@ -31,6 +34,16 @@ const (
 	IPVLAN_MODE_MAX
 )
 
+// MACVLAN link attributes.
+type MacvlanMode uint32
+
+const (
+	MACVLAN_MODE_PRIVATE  MacvlanMode = 1
+	MACVLAN_MODE_VEPA     MacvlanMode = 2
+	MACVLAN_MODE_BRIDGE   MacvlanMode = 4
+	MACVLAN_MODE_PASSTHRU MacvlanMode = 8
+)
+
 const (
 	ADD = iota
 	REMOVE
@@ -72,11 +85,34 @@ type IPVlanLink struct {
 	Mode IPVlanMode
 }
 
+// MacvlanLink represents a MACVLAN network interface.
+type MacvlanLink struct {
+	LinkInfo
+	Mode MacvlanMode
+}
+
 // DummyLink represents a dummy network interface.
 type DummyLink struct {
 	LinkInfo
 }
 
+// VrfLink represents a VRF device binding its enslaved interfaces to a routing table.
+// Interfaces are enslaved to the VRF with SetLinkMaster.
+type VrfLink struct {
+	LinkInfo
+	Table uint32
+}
+
+// VxlanLink represents a VXLAN tunnel endpoint. Remote endpoints are programmed with AddOrRemoveFdbEntry,
+// the device doesn't learn them.
+type VxlanLink struct {
+	LinkInfo
+	VNI          uint32
+	Port         uint16
+	Local        net.IP
+	VtepDevIndex int
+}
+
 // AddLink adds a new network interface of a specified type.
 func AddLink(link Link) error {
 	var info *LinkInfo
@@ -143,6 +179,37 @@ func AddLink(link Link) error {
 		attrData := newAttribute(IFLA_INFO_DATA, nil)
 		attrData.addNested(newAttributeUint16(IFLA_IPVLAN_MODE, uint16(ipvlan.Mode)))
 
+		attrLinkInfo.addNested(attrData)
+
+	} else if macvlan, ok := link.(*MacvlanLink); ok {
+		// Set MACVLAN attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint32(IFLA_MACVLAN_MODE, uint32(macvlan.Mode)))
+
+		attrLinkInfo.addNested(attrData)
+
+	} else if vrf, ok := link.(*VrfLink); ok {
+		// Set VRF attributes.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint32(IFLA_VRF_TABLE, vrf.Table))
+
+		attrLinkInfo.addNested(attrData)
+
+	} else if vxlan, ok := link.(*VxlanLink); ok {
+		// Set VXLAN attributes. The port is in network byte order.
+		attrData := newAttribute(IFLA_INFO_DATA, nil)
+		attrData.addNested(newAttributeUint32(IFLA_VXLAN_ID, vxlan.VNI))
+		attrData.addNested(newAttribute(IFLA_VXLAN_PORT, []byte{byte(vxlan.Port >> 8), byte(vxlan.Port)}))
+		attrData.addNested(newAttribute(IFLA_VXLAN_LEARNING, []byte{0}))
+
+		if vxlan.VtepDevIndex != 0 {
+			attrData.addNested(newAttributeUint32(IFLA_VXLAN_LINK, uint32(vxlan.VtepDevIndex)))
+		}
+
+		if vxlan.Local != nil {
+			attrData.addNested(newAttribute(IFLA_VXLAN_LOCAL, vxlan.Local.To4()))
+		}
+
 		attrLinkInfo.addNested(attrData)
 	}
 
@@ -325,6 +392,32 @@ func SetLinkAddress(ifName string, hwAddress net.HardwareAddr) error {
 	return s.sendAndWaitForAck(req)
 }
 
+// SetLinkMTU sets the MTU of a network interface.
+func SetLinkMTU(ifName string, mtu int) error {
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+
+	req := newRequest(unix.RTM_SETLINK, unix.NLM_F_ACK)
+
+	ifInfo := newIfInfoMsg()
+	ifInfo.Type = unix.RTM_SETLINK
+	ifInfo.Index = int32(iface.Index)
+	ifInfo.Flags = unix.NLM_F_REQUEST
+	ifInfo.Change = DEFAULT_CHANGE
+	req.addPayload(ifInfo)
+
+	req.addPayload(newAttributeUint32(unix.IFLA_MTU, uint32(mtu)))
+
+	return s.sendAndWaitForAck(req)
+}
+
 // SetLinkPromisc sets the promiscuous mode of a network interface.
 func SetLinkPromisc(ifName string, on bool) error {
 	s, err := getSocket()
@@ -428,3 +521,37 @@ func AddOrRemoveStaticArp(mode int, name string, ipaddr net.IP, mac net.Hardware
 
 	return s.sendAndWaitForAck(req)
 }
+
+// AddOrRemoveFdbEntry sets/removes the static forwarding database entry sending the frames to the given MAC address
+// through a VXLAN device to the tunnel endpoint at the given IP address.
+func AddOrRemoveFdbEntry(mode int, name string, mac net.HardwareAddr, dst net.IP) error {
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	var req *message
+	if mode == ADD {
+		req = newRequest(unix.RTM_NEWNEIGH, unix.NLM_F_CREATE|unix.NLM_F_REPLACE|unix.NLM_F_ACK)
+	} else {
+		req = newRequest(unix.RTM_DELNEIGH, unix.NLM_F_ACK)
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+
+	msg := neighMsg{
+		Family: uint8(unix.AF_BRIDGE),
+		Index:  uint32(iface.Index),
+		State:  uint16(NUD_PERMANENT),
+		Flags:  uint8(NTF_SELF),
+	}
+	req.addPayload(&msg)
+
+	req.addPayload(newRtAttr(NDA_LLADDR, []byte(mac)))
+	req.addPayload(newRtAttr(NDA_DST, dst.To4()))
+
+	return s.sendAndWaitForAck(req)
+}
@@ -7,7 +7,11 @@ package netlink
 
 import (
 	"net"
+	"syscall"
 	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -259,3 +263,171 @@ func TestAddRemoveStaticArp(t *testing.T) {
 		t.Errorf("DeleteLink failed: %+v", err)
 	}
 }
+
+// TestAddDeleteVrf tests adding a VRF device, enslaving an interface to it and deleting it.
+func TestAddDeleteVrf(t *testing.T) {
+	link := VrfLink{
+		LinkInfo: LinkInfo{
+			Type: LINK_TYPE_VRF,
+			Name: ifName,
+		},
+		Table: 1000,
+	}
+
+	err := AddLink(&link)
+	if err != nil {
+		t.Errorf("AddLink failed: %+v", err)
+	}
+
+	_, err = addDummyInterface(dummyName)
+	if err != nil {
+		t.Errorf("addDummyInterface failed: %v", err)
+	}
+
+	err = SetLinkMaster(dummyName, ifName)
+	if err != nil {
+		t.Errorf("SetLinkMaster failed: %+v", err)
+	}
+
+	err = DeleteLink(dummyName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %v", err)
+	}
+
+	err = DeleteLink(ifName)
+	if err != nil {
+		t.Errorf("DeleteLink failed: %+v", err)
+	}
+
+	_, err = net.InterfaceByName(ifName)
+	if err == nil {
+		t.Errorf("Interface not deleted")
+	}
+}
+
+// TestAddGetDeleteIpRule tests adding, listing and deleting a source-based routing rule.
+func TestAddGetDeleteIpRule(t *testing.T) {
+	_, src, _ := net.ParseCIDR("10.240.0.0/16")
+	rule := &Rule{
+		Family:   unix.AF_INET,
+		Table:    1000,
+		Priority: 32000,
+		Src:      src,
+	}
+
+	err := AddIpRule(rule)
+	if err != nil {
+		t.Errorf("AddIpRule failed: %+v", err)
+	}
+
+	if err := AddIpRule(rule); !IsExist(err) {
+		t.Errorf("AddIpRule of existing rule returned %v.", err)
+	}
+
+	rules, err := GetIpRule(&Rule{Family: unix.AF_INET, Table: 1000})
+	if err != nil {
+		t.Errorf("GetIpRule failed: %+v", err)
+	}
+
+	if len(rules) != 1 || rules[0].Priority != 32000 || rules[0].Src == nil || rules[0].Src.String() != src.String() {
+		t.Errorf("GetIpRule returned %+v.", rules)
+	}
+
+	err = DeleteIpRule(rule)
+	if err != nil {
+		t.Errorf("DeleteIpRule failed: %+v", err)
+	}
+
+	if err := DeleteIpRule(rule); !IsNotExist(err) {
+		t.Errorf("DeleteIpRule of deleted rule returned %v.", err)
+	}
+}
+
+// TestSubscribe tests receiving address events from a subscription.
+func TestSubscribe(t *testing.T) {
+	sub, err := Subscribe(RTMGRP_IPV4_IFADDR)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %+v", err)
+	}
+	defer sub.Close()
+
+	ip, ipNet, _ := net.ParseCIDR("127.0.10.1/32")
+
+	err = AddIpAddress("lo", ip, ipNet)
+	if err != nil {
+		t.Fatalf("AddIpAddress failed: %+v", err)
+	}
+
+	err = DeleteIpAddress("lo", ip, ipNet)
+	if err != nil {
+		t.Errorf("DeleteIpAddress failed: %+v", err)
+	}
+
+	var types []uint16
+	timeout := time.After(5 * time.Second)
+	for len(types) < 2 {
+		select {
+		case event := <-sub.Events():
+			if event.Address != nil && event.Address.IPNet != nil && event.Address.IPNet.IP.Equal(ip) {
+				types = append(types, event.Type)
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for address events, received %v.", types)
+		}
+	}
+
+	if types[0] != unix.RTM_NEWADDR || types[1] != unix.RTM_DELADDR {
+		t.Errorf("Received address events %v.", types)
+	}
+}
+
+// TestDecodeError tests decoding acks and kernel errors into typed errors.
+func TestDecodeError(t *testing.T) {
+	sent := newRequest(unix.RTM_NEWROUTE, unix.NLM_F_ACK)
+
+	ack := &message{data: make([]byte, 20)}
+	if err := decodeError(ack, sent); err != nil {
+		t.Errorf("Ack decoded as error %v.", err)
+	}
+
+	errMsg := &message{data: make([]byte, 20)}
+	setErrno := func(errno syscall.Errno) {
+		encoder.PutUint32(errMsg.data[0:4], uint32(-int32(errno)))
+	}
+
+	setErrno(unix.EEXIST)
+	err := decodeError(errMsg, sent)
+	if !IsExist(err) || IsNotExist(err) {
+		t.Errorf("Error decoded as %v, expected EEXIST.", err)
+	}
+
+	if nlErr, ok := err.(*Error); !ok || nlErr.MsgType != unix.RTM_NEWROUTE {
+		t.Errorf("Error %v doesn't report the request type.", err)
+	}
+
+	setErrno(unix.ENETUNREACH)
+	if err := decodeError(errMsg, sent); !IsNetUnreachable(err) {
+		t.Errorf("Error decoded as %v, expected ENETUNREACH.", err)
+	}
+
+	if err := decodeError(&message{data: []byte{0}}, sent); err == nil {
+		t.Errorf("Truncated error message decoded as ack.")
+	}
+}
+
+// Tests decoding the packets logged to an NFLOG group.
+func TestDeserializeNflogPacket(t *testing.T) {
+	payload := []byte{0x45, 0, 0, 20}
+	data := []byte{unix.AF_INET, NFNETLINK_V0, 0, 100}
+	data = append(data, newAttributeStringZ(NFULA_PREFIX, "azure-npm-audit:ns").serialize()...)
+	data = append(data, newAttribute(NFULA_PAYLOAD, payload).serialize()...)
+
+	packet := deserializeNflogPacket(data)
+	if packet == nil || packet.Prefix != "azure-npm-audit:ns" || string(packet.Payload) != string(payload) {
+		t.Errorf("Unexpected NFLOG packet %+v", packet)
+	}
+
+	if packet = deserializeNflogPacket(data[:4]); packet != nil {
+		t.Errorf("Decoded NFLOG packet without payload %+v", packet)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/unix"
+)
+
+// Nfnetlink log constants that are not already defined in unix package.
+const (
+	NFULNL_MSG_PACKET   = 0
+	NFULNL_MSG_CONFIG   = 1
+	NFULA_CFG_CMD       = 1
+	NFULA_CFG_MODE      = 2
+	NFULNL_CFG_CMD_BIND = 1
+	NFULNL_COPY_PACKET  = 2
+	NFULA_PAYLOAD       = 9
+	NFULA_PREFIX        = 10
+	NFNETLINK_V0        = 0
+)
+
+// Size of the packet channel of an NFLOG subscription.
+const nflogPacketQueueLength = 256
+
+// NflogPacket is a packet logged by an iptables NFLOG rule.
+// Payload starts with the network header and is truncated to the copy range of the subscription.
+type NflogPacket struct {
+	Prefix  string
+	Payload []byte
+}
+
+// NflogSubscription delivers the packets logged to an NFLOG group.
+type NflogSubscription struct {
+	socket  *socket
+	packets chan *NflogPacket
+	stop    chan struct{}
+}
+
+// Nfnetlink message header.
+type nfGenMsg struct {
+	Family  uint8
+	Version uint8
+	ResID   uint16
+}
+
+// Serializes a nfnetlink message header. The resource id is in network byte order.
+func (msg *nfGenMsg) serialize() []byte {
+	b := make([]byte, msg.length())
+	b[0] = msg.Family
+	b[1] = msg.Version
+	binary.BigEndian.PutUint16(b[2:4], msg.ResID)
+	return b
+}
+
+// Returns the length of a nfnetlink message header.
+func (msg *nfGenMsg) length() int {
+	return 4
+}
+
+// SubscribeNflog binds to the given NFLOG group, and starts delivering its packets, with up to copyRange bytes
+// of their payload, until the subscription is closed. Packets logged while the subscriber is behind are dropped.
+func SubscribeNflog(group uint16, copyRange uint32) (*NflogSubscription, error) {
+	s, err := newSocket(unix.NETLINK_NETFILTER, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	configure := func(attr *attribute) error {
+		req := newRequest((unix.NFNL_SUBSYS_ULOG<<8)|NFULNL_MSG_CONFIG, 0)
+		req.addPayload(&nfGenMsg{Family: unix.AF_UNSPEC, Version: NFNETLINK_V0, ResID: group})
+		req.addPayload(attr)
+		return s.sendAndWaitForAck(req)
+	}
+
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode[0:4], copyRange)
+	mode[4] = NFULNL_COPY_PACKET
+
+	if err = configure(newAttribute(NFULA_CFG_CMD, []byte{NFULNL_CFG_CMD_BIND})); err == nil {
+		err = configure(newAttribute(NFULA_CFG_MODE, mode))
+	}
+
+	if err != nil {
+		s.close()
+		log.Printf("[netlink] Failed to bind to NFLOG group %v, err=%v\n", group, err)
+		return nil, err
+	}
+
+	// Wake up periodically to notice when the subscription is closed.
+	tv := unix.NsecToTimeval(receiveTimeout.Nanoseconds())
+	err = unix.SetsockoptTimeval(s.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	sub := &NflogSubscription{
+		socket:  s,
+		packets: make(chan *NflogPacket, nflogPacketQueueLength),
+		stop:    make(chan struct{}),
+	}
+
+	go sub.run()
+
+	log.Printf("[netlink] Subscribed to NFLOG group %v.\n", group)
+
+	return sub, nil
+}
+
+// Packets returns the channel delivering the packets. The channel is closed when the subscription ends.
+func (sub *NflogSubscription) Packets() <-chan *NflogPacket {
+	return sub.packets
+}
+
+// Close ends the subscription. Closing the socket unbinds it from the group.
+func (sub *NflogSubscription) Close() {
+	close(sub.stop)
+}
+
+// run receives and delivers packets until the subscription is closed.
+func (sub *NflogSubscription) run() {
+	defer close(sub.packets)
+	defer sub.socket.close()
+
+	for {
+		select {
+		case <-sub.stop:
+			return
+		default:
+		}
+
+		nlMsgs, err := sub.socket.receive()
+		switch err {
+		case nil:
+		case unix.EAGAIN, unix.EINTR:
+			continue
+		case unix.ENOBUFS:
+			log.Printf("[netlink] NFLOG subscription overrun, packets dropped.\n")
+			continue
+		default:
+			log.Printf("[netlink] NFLOG subscription receive err=%v\n", err)
+			return
+		}
+
+		for _, nlMsg := range nlMsgs {
+			if nlMsg.Header.Type != (unix.NFNL_SUBSYS_ULOG<<8)|NFULNL_MSG_PACKET {
+				continue
+			}
+
+			packet := deserializeNflogPacket(nlMsg.Data)
+			if packet == nil {
+				continue
+			}
+
+			select {
+			case sub.packets <- packet:
+			case <-sub.stop:
+				return
+			}
+		}
+	}
+}
+
+// deserializeNflogPacket decodes the body of an NFLOG packet message, or returns nil if it has no payload.
+func deserializeNflogPacket(data []byte) *NflogPacket {
+	var packet NflogPacket
+
+	// Skip the nfnetlink message header.
+	if len(data) < 4 {
+		return nil
+	}
+	b := data[4:]
+
+	for len(b) >= unix.SizeofNlAttr {
+		attrLen := int(encoder.Uint16(b[0:2]))
+		attrType := encoder.Uint16(b[2:4]) &^ (unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER)
+		if attrLen < unix.SizeofNlAttr || attrLen > len(b) {
+			break
+		}
+
+		value := b[unix.SizeofNlAttr:attrLen]
+		switch attrType {
+		case NFULA_PREFIX:
+			packet.Prefix = strings.TrimRight(string(value), "\x00")
+		case NFULA_PAYLOAD:
+			packet.Payload = append([]byte(nil), value...)
+		}
+
+		next := (attrLen + unix.NLA_ALIGNTO - 1) & ^(unix.NLA_ALIGNTO - 1)
+		if next > len(b) {
+			break
+		}
+		b = b[next:]
+	}
+
+	if packet.Payload == nil {
+		return nil
+	}
+
+	return &packet
+}
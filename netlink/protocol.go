@@ -50,13 +50,68 @@ const (
 
 // Netlink protocol constants that are not already defined in unix package.
 const (
-	IFLA_INFO_KIND   = 1
-	IFLA_INFO_DATA   = 2
-	IFLA_NET_NS_FD   = 28
-	IFLA_IPVLAN_MODE = 1
-	IFLA_BRPORT_MODE = 4
-	VETH_INFO_PEER   = 1
-	DEFAULT_CHANGE   = 0xFFFFFFFF
+	IFLA_INFO_KIND    = 1
+	IFLA_INFO_DATA    = 2
+	IFLA_NET_NS_FD    = 28
+	IFLA_IPVLAN_MODE  = 1
+	IFLA_MACVLAN_MODE = 1
+	IFLA_BRPORT_MODE  = 4
+	IFLA_VRF_TABLE    = 1
+	VETH_INFO_PEER    = 1
+	DEFAULT_CHANGE    = 0xFFFFFFFF
+)
+
+// VXLAN link attributes.
+const (
+	IFLA_VXLAN_ID       = 1
+	IFLA_VXLAN_LINK     = 3
+	IFLA_VXLAN_LOCAL    = 4
+	IFLA_VXLAN_LEARNING = 7
+	IFLA_VXLAN_PORT     = 15
+)
+
+// Rtnetlink multicast groups, as a bitmask of the groups to bind to.
+const (
+	RTMGRP_LINK        = 0x1
+	RTMGRP_IPV4_IFADDR = 0x10
+	RTMGRP_IPV4_ROUTE  = 0x40
+	RTMGRP_IPV6_IFADDR = 0x100
+	RTMGRP_IPV6_ROUTE  = 0x400
+)
+
+// Routing rule attributes.
+const (
+	FRA_UNSPEC = iota
+	FRA_DST
+	FRA_SRC
+	FRA_IIFNAME
+	FRA_GOTO
+	FRA_UNUSED2
+	FRA_PRIORITY
+	FRA_UNUSED3
+	FRA_UNUSED4
+	FRA_UNUSED5
+	FRA_FWMARK
+	FRA_FLOW
+	FRA_TUN_ID
+	FRA_SUPPRESS_IFGROUP
+	FRA_SUPPRESS_PREFIXLEN
+	FRA_TABLE
+	FRA_FWMASK
+	FRA_OIFNAME
+)
+
+// Routing rule actions.
+const (
+	FR_ACT_UNSPEC = iota
+	FR_ACT_TO_TBL
+	FR_ACT_GOTO
+	FR_ACT_NOP
+	FR_ACT_RES3
+	FR_ACT_RES4
+	FR_ACT_BLACKHOLE
+	FR_ACT_UNREACHABLE
+	FR_ACT_PROHIBIT
 )
 
 // Serializable types are used to construct netlink messages.
@@ -310,6 +365,11 @@ func (ifInfo *ifInfoMsg) length() int {
 	return unix.SizeofIfInfomsg
 }
 
+// Deserializes an interface info message.
+func deserializeIfInfoMsg(b []byte) *ifInfoMsg {
+	return (*ifInfoMsg)(unsafe.Pointer(&b[0:unix.SizeofIfInfomsg][0]))
+}
+
 //
 // IP address service module
 //
@@ -344,6 +404,11 @@ func (ifAddr *ifAddrMsg) length() int {
 	return unix.SizeofIfAddrmsg
 }
 
+// Deserializes an interface address message.
+func deserializeIfAddrMsg(b []byte) *ifAddrMsg {
+	return (*ifAddrMsg)(unsafe.Pointer(&b[0:unix.SizeofIfAddrmsg][0]))
+}
+
 //
 // Network route service module
 //
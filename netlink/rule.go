@@ -0,0 +1,197 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+// +build linux
+
+package netlink
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Rule represents a netlink routing policy rule looking up a route table.
+type Rule struct {
+	Family   int
+	Table    int
+	Priority int
+	Src      *net.IPNet
+	Dst      *net.IPNet
+	Tos      int
+	Mark     int
+	Mask     int
+	IifName  string
+	OifName  string
+}
+
+// deserializeRule decodes a netlink message into a Rule struct.
+// Rule messages share the layout of route messages, with the route type field holding the rule action.
+func deserializeRule(msg *message) (*Rule, error) {
+	// Parse rule message.
+	rtmsg := deserializeRtMsg(msg.data)
+	attrs := msg.getAttributes(rtmsg)
+
+	// Initialize a new rule object.
+	rule := Rule{
+		Family: int(rtmsg.Family),
+		Table:  int(rtmsg.Table),
+		Tos:    int(rtmsg.Tos),
+	}
+
+	// Populate rule attributes.
+	for _, attr := range attrs {
+		switch attr.Type {
+		case FRA_SRC:
+			rule.Src = &net.IPNet{
+				IP:   attr.value,
+				Mask: net.CIDRMask(int(rtmsg.Src_len), 8*len(attr.value)),
+			}
+		case FRA_DST:
+			rule.Dst = &net.IPNet{
+				IP:   attr.value,
+				Mask: net.CIDRMask(int(rtmsg.Dst_len), 8*len(attr.value)),
+			}
+		case FRA_TABLE:
+			rule.Table = int(encoder.Uint32(attr.value[0:4]))
+		case FRA_PRIORITY:
+			rule.Priority = int(encoder.Uint32(attr.value[0:4]))
+		case FRA_FWMARK:
+			rule.Mark = int(encoder.Uint32(attr.value[0:4]))
+		case FRA_FWMASK:
+			rule.Mask = int(encoder.Uint32(attr.value[0:4]))
+		case FRA_IIFNAME:
+			rule.IifName = strings.TrimRight(string(attr.value), "\x00")
+		case FRA_OIFNAME:
+			rule.OifName = strings.TrimRight(string(attr.value), "\x00")
+		}
+	}
+
+	return &rule, nil
+}
+
+// GetIpRule returns a list of routing rules matching the given filter.
+func GetIpRule(filter *Rule) ([]*Rule, error) {
+	s, err := getSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(unix.RTM_GETRULE, unix.NLM_F_DUMP)
+
+	msg := newRtMsg(filter.Family)
+	req.addPayload(msg)
+
+	msgs, err := s.sendAndWaitForResponse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+
+	// For each rule in the list...
+	for _, msg := range msgs {
+		rule, err := deserializeRule(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		// Filter by table.
+		if filter.Table != 0 && filter.Table != rule.Table {
+			continue
+		}
+
+		// Filter by priority.
+		if filter.Priority != 0 && filter.Priority != rule.Priority {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// setIpRule sends a routing rule set request.
+func setIpRule(rule *Rule, add bool) error {
+	var msgType, flags int
+
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	if add {
+		msgType = unix.RTM_NEWRULE
+		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL | unix.NLM_F_ACK
+	} else {
+		msgType = unix.RTM_DELRULE
+		flags = unix.NLM_F_ACK
+	}
+
+	req := newRequest(msgType, flags)
+
+	msg := newRtMsg(rule.Family)
+	msg.Tos = uint8(rule.Tos)
+	msg.Protocol = 0
+	msg.Scope = 0
+	msg.Type = FR_ACT_TO_TBL
+
+	// Tables above 255 don't fit in the message header and are only set in the table attribute.
+	if rule.Table < 256 {
+		msg.Table = uint8(rule.Table)
+	} else {
+		msg.Table = unix.RT_TABLE_UNSPEC
+	}
+
+	req.addPayload(msg)
+
+	if rule.Table != 0 {
+		req.addPayload(newAttributeUint32(FRA_TABLE, uint32(rule.Table)))
+	}
+
+	if rule.Src != nil {
+		prefixLength, _ := rule.Src.Mask.Size()
+		msg.Src_len = uint8(prefixLength)
+		req.addPayload(newAttributeIpAddress(FRA_SRC, rule.Src.IP))
+	}
+
+	if rule.Dst != nil {
+		prefixLength, _ := rule.Dst.Mask.Size()
+		msg.Dst_len = uint8(prefixLength)
+		req.addPayload(newAttributeIpAddress(FRA_DST, rule.Dst.IP))
+	}
+
+	if rule.Priority != 0 {
+		req.addPayload(newAttributeUint32(FRA_PRIORITY, uint32(rule.Priority)))
+	}
+
+	if rule.Mark != 0 {
+		req.addPayload(newAttributeUint32(FRA_FWMARK, uint32(rule.Mark)))
+	}
+
+	if rule.Mask != 0 {
+		req.addPayload(newAttributeUint32(FRA_FWMASK, uint32(rule.Mask)))
+	}
+
+	if rule.IifName != "" {
+		req.addPayload(newAttributeStringZ(FRA_IIFNAME, rule.IifName))
+	}
+
+	if rule.OifName != "" {
+		req.addPayload(newAttributeStringZ(FRA_OIFNAME, rule.OifName))
+	}
+
+	return s.sendAndWaitForAck(req)
+}
+
+// AddIpRule adds a routing rule to the routing policy database.
+func AddIpRule(rule *Rule) error {
+	return setIpRule(rule, true)
+}
+
+// DeleteIpRule deletes a routing rule from the routing policy database.
+func DeleteIpRule(rule *Rule) error {
+	return setIpRule(rule, false)
+}
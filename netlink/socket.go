@@ -36,7 +36,7 @@ func getSocket() (*socket, error) {
 	defer m.Unlock()
 
 	if s == nil {
-		s, err = newSocket()
+		s, err = newSocket(unix.NETLINK_ROUTE, 0)
 	}
 
 	return s, err
@@ -50,9 +50,9 @@ func ResetSocket() {
 	s = nil
 }
 
-// Creates a new netlink socket object.
-func newSocket() (*socket, error) {
-	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW, unix.NETLINK_ROUTE)
+// Creates a new netlink socket object of the given netlink protocol, joined to the given multicast groups.
+func newSocket(protocol int, groups uint32) (*socket, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW, protocol)
 	if err != nil {
 		log.Debugf("[netlink] Failed to create socket, err=%v\n", err)
 		return nil, err
@@ -66,13 +66,29 @@ func newSocket() (*socket, error) {
 
 	s.sa.Family = unix.AF_NETLINK
 
-	err = unix.Bind(fd, &s.sa)
+	// Bind to the multicast groups, while requests are still sent to the kernel.
+	sa := s.sa
+	sa.Groups = groups
+
+	err = unix.Bind(fd, &sa)
 	if err != nil {
 		unix.Close(fd)
 		log.Debugf("[netlink] Failed to bind socket, err=%v\n", err)
 		return nil, err
 	}
 
+	// The kernel assigns the process id to the first socket of the process only, and answers requests with the port id.
+	lsa, err := unix.Getsockname(fd)
+	if err != nil {
+		unix.Close(fd)
+		log.Debugf("[netlink] Failed to get socket name, err=%v\n", err)
+		return nil, err
+	}
+
+	if nlsa, ok := lsa.(*unix.SockaddrNetlink); ok {
+		s.pid = nlsa.Pid
+	}
+
 	log.Debugf("[netlink] Socket created.\n")
 	return s, nil
 }
@@ -86,6 +102,7 @@ func (s *socket) close() {
 // Sends a netlink message.
 func (s *socket) send(msg *message) error {
 	msg.Seq = atomic.AddUint32(&s.seq, 1)
+	msg.Pid = s.pid
 	err := unix.Sendto(s.fd, msg.serialize(), 0, &s.sa)
 	log.Debugf("[netlink] Sent %+v, err=%v\n", *msg, err)
 	return err
@@ -105,7 +122,9 @@ func (s *socket) sendAndWaitForResponse(msg *message) ([]*message, error) {
 }
 
 // Sends a netlink message and blocks until its ack is received.
+// An ack is always requested, so that errors returned by the kernel are never missed.
 func (s *socket) sendAndWaitForAck(msg *message) error {
+	msg.Flags |= unix.NLM_F_ACK
 	_, err := s.sendAndWaitForResponse(msg)
 	return err
 }
@@ -127,10 +146,14 @@ func (s *socket) receive() ([]syscall.NetlinkMessage, error) {
 	return syscall.ParseNetlinkMessage(buffer)
 }
 
-// Receives the response for the given sent message and returns the parsed message.
+// Receives the response for the given sent message and returns the parsed messages.
+// Requests with an ack are answered until the ack or error message, multipart responses until the done message,
+// and other requests by a single message.
 func (s *socket) receiveResponse(sent *message) ([]*message, error) {
 	var messages []*message
-	var multi, done bool
+	var interrupted bool
+
+	ack := (sent.Flags & unix.NLM_F_ACK) != 0
 
 	for {
 		// Receive all pending messages.
@@ -142,77 +165,91 @@ func (s *socket) receiveResponse(sent *message) ([]*message, error) {
 
 		// Process received messages.
 		for _, nlMsg := range nlMsgs {
-			// Convert to message object.
-			msg := message{
-				NlMsghdr: unix.NlMsghdr{
-					Len:   nlMsg.Header.Len,
-					Type:  nlMsg.Header.Type,
-					Flags: nlMsg.Header.Flags,
-					Seq:   nlMsg.Header.Seq,
-					Pid:   nlMsg.Header.Pid,
-				},
-				data: nlMsg.Data,
-			}
+			msg := newMessageFromNetlink(&nlMsg)
 
 			// Ignore if the message is not in response to the sent message.
 			if msg.Seq != sent.Seq || msg.Pid != sent.Pid {
-				log.Printf("[netlink] Ignoring unexpected message %+v\n", msg)
+				log.Printf("[netlink] Ignoring unexpected message %+v\n", *msg)
 				continue
 			}
 
-			// Return if this is an ack or an error message.
-			// An acknowledgement is an error message with error code set to
-			// zero, followed by the original request message header.
-			if msg.Type == unix.NLMSG_ERROR {
-				errCode := int32(encoder.Uint32(msg.data[0:4]))
-				if errCode == 0 {
-					log.Debugf("[netlink] Received %+v, ack\n", msg)
-				} else {
-					err = syscall.Errno(-errCode)
-					log.Printf("[netlink] Received %+v, err=%v\n", msg, err)
+			interrupted = interrupted || (msg.Flags&unix.NLM_F_DUMP_INTR) != 0
+
+			switch msg.Type {
+			case unix.NLMSG_ERROR:
+				// Return if this is an ack or an error message.
+				if err = decodeError(msg, sent); err != nil {
+					log.Printf("[netlink] Received %+v, err=%v\n", *msg, err)
+					return nil, err
 				}
-				return nil, err
-			}
 
-			// Log response message.
-			log.Debugf("[netlink] Received %+v\n", msg)
-
-			// Parse body.
-			msg.payload = append(msg.payload, nil)
-
-			// Parse attributes.
-			// Ignore failures as not all messages have attributes.
-			nlAttrs, _ := syscall.ParseNetlinkRouteAttr(&nlMsg)
-
-			// Convert to attribute objects.
-			for _, nlAttr := range nlAttrs {
-				attr := attribute{
-					NlAttr: unix.NlAttr{
-						Len:  nlAttr.Attr.Len,
-						Type: nlAttr.Attr.Type,
-					},
-					value: nlAttr.Value,
+				log.Debugf("[netlink] Received %+v, ack\n", *msg)
+				return messages, nil
+
+			case unix.NLMSG_DONE:
+				// Dumps are complete once done, the kernel doesn't ack them.
+				if err = decodeDone(msg, sent); err != nil {
+					log.Printf("[netlink] Received %+v, err=%v\n", *msg, err)
+					return nil, err
 				}
-				msg.payload = append(msg.payload, &attr)
-			}
 
-			multi = ((msg.Flags & unix.NLM_F_MULTI) != 0)
-			done = (msg.Type == unix.NLMSG_DONE)
+				if interrupted {
+					log.Printf("[netlink] Received %+v, dump interrupted\n", *msg)
+					return nil, ErrDumpInterrupted
+				}
 
-			// Exit if message completes a multipart response.
-			if multi && done {
-				break
+				return messages, nil
 			}
 
-			messages = append(messages, &msg)
+			// Log response message.
+			log.Debugf("[netlink] Received %+v\n", *msg)
+
+			messages = append(messages, msg)
+
+			// Return if the response is a single message and no ack is expected.
+			if !ack && (msg.Flags&unix.NLM_F_MULTI) == 0 {
+				return messages, nil
+			}
 		}
+	}
+}
+
+// Converts a received netlink message to a message object with parsed attributes.
+func newMessageFromNetlink(nlMsg *syscall.NetlinkMessage) *message {
+	msg := message{
+		NlMsghdr: unix.NlMsghdr{
+			Len:   nlMsg.Header.Len,
+			Type:  nlMsg.Header.Type,
+			Flags: nlMsg.Header.Flags,
+			Seq:   nlMsg.Header.Seq,
+			Pid:   nlMsg.Header.Pid,
+		},
+		data: nlMsg.Data,
+	}
+
+	// Parse body.
+	msg.payload = append(msg.payload, nil)
 
-		// Exit if response is a single message,
-		// or a completed multipart message.
-		if !multi || done {
-			break
+	// Parse attributes.
+	// Ignore failures as not all messages have attributes.
+	// Rule messages share the layout of route messages, but syscall only parses the latter.
+	attrMsg := *nlMsg
+	if attrMsg.Header.Type == unix.RTM_NEWRULE {
+		attrMsg.Header.Type = unix.RTM_NEWROUTE
+	}
+	nlAttrs, _ := syscall.ParseNetlinkRouteAttr(&attrMsg)
+
+	// Convert to attribute objects.
+	for _, nlAttr := range nlAttrs {
+		attr := attribute{
+			NlAttr: unix.NlAttr{
+				Len:  nlAttr.Attr.Len,
+				Type: nlAttr.Attr.Type,
+			},
+			value: nlAttr.Value,
 		}
+		msg.payload = append(msg.payload, &attr)
 	}
 
-	return messages, nil
+	return &msg
 }
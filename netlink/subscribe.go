@@ -0,0 +1,239 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+// +build linux
+
+package netlink
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Size of the event channel of a subscription.
+	eventQueueLength = 64
+
+	// Interval at which a subscription checks if it was closed while no events are received.
+	receiveTimeout = time.Second
+)
+
+// Event is a change of a link, address or route notified by the kernel.
+// Type is the rtnetlink message type, e.g. RTM_NEWLINK or RTM_DELADDR, and selects the field set.
+// An event of type NLMSG_OVERRUN reports that events were dropped, and that callers should resync their state.
+type Event struct {
+	Type    uint16
+	Link    *LinkState
+	Address *Address
+	Route   *Route
+}
+
+// LinkState is the state of a network interface reported in a link event.
+type LinkState struct {
+	Index       int
+	Name        string
+	Flags       uint32 // IFF_* flags, e.g. IFF_UP and IFF_LOWER_UP.
+	MTU         int
+	MasterIndex int
+	OperState   uint8
+}
+
+// Address is an IP address of a network interface reported in an address event.
+type Address struct {
+	Index  int
+	Family int
+	IPNet  *net.IPNet
+	Scope  int
+}
+
+// Subscription delivers the events of the rtnetlink multicast groups it joined.
+type Subscription struct {
+	socket *socket
+	events chan *Event
+	stop   chan struct{}
+}
+
+// Subscribe joins the given rtnetlink multicast groups, e.g. RTMGRP_LINK|RTMGRP_IPV4_IFADDR,
+// and starts delivering their events until the subscription is closed.
+func Subscribe(groups uint32) (*Subscription, error) {
+	s, err := newSocket(unix.NETLINK_ROUTE, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wake up periodically to notice when the subscription is closed.
+	tv := unix.NsecToTimeval(receiveTimeout.Nanoseconds())
+	err = unix.SetsockoptTimeval(s.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	sub := &Subscription{
+		socket: s,
+		events: make(chan *Event, eventQueueLength),
+		stop:   make(chan struct{}),
+	}
+
+	go sub.run()
+
+	log.Printf("[netlink] Subscribed to multicast groups %#x.\n", groups)
+
+	return sub, nil
+}
+
+// Events returns the channel delivering the events. The channel is closed when the subscription ends.
+func (sub *Subscription) Events() <-chan *Event {
+	return sub.events
+}
+
+// Close ends the subscription.
+func (sub *Subscription) Close() {
+	close(sub.stop)
+}
+
+// run receives and delivers events until the subscription is closed.
+func (sub *Subscription) run() {
+	defer close(sub.events)
+	defer sub.socket.close()
+
+	for {
+		select {
+		case <-sub.stop:
+			return
+		default:
+		}
+
+		nlMsgs, err := sub.socket.receive()
+		switch err {
+		case nil:
+		case unix.EAGAIN, unix.EINTR:
+			continue
+		case unix.ENOBUFS:
+			// The socket buffer overflowed and events were dropped.
+			log.Printf("[netlink] Subscription overrun, events dropped.\n")
+			if !sub.deliver(&Event{Type: unix.NLMSG_OVERRUN}) {
+				return
+			}
+			continue
+		default:
+			log.Printf("[netlink] Subscription receive err=%v\n", err)
+			return
+		}
+
+		for _, nlMsg := range nlMsgs {
+			event, err := deserializeEvent(newMessageFromNetlink(&nlMsg))
+			if err != nil {
+				log.Printf("[netlink] Failed to decode event, err=%v\n", err)
+				continue
+			}
+
+			if event != nil && !sub.deliver(event) {
+				return
+			}
+		}
+	}
+}
+
+// deliver queues an event to the subscriber, and returns false if the subscription was closed.
+func (sub *Subscription) deliver(event *Event) bool {
+	select {
+	case sub.events <- event:
+		return true
+	case <-sub.stop:
+		return false
+	}
+}
+
+// deserializeEvent decodes a netlink notification into an Event struct.
+// Messages that are not link, address or route notifications are ignored.
+func deserializeEvent(msg *message) (*Event, error) {
+	var err error
+	event := Event{Type: msg.Type}
+
+	switch msg.Type {
+	case unix.RTM_NEWLINK, unix.RTM_DELLINK:
+		if len(msg.data) < unix.SizeofIfInfomsg {
+			return nil, unix.EBADMSG
+		}
+		event.Link = deserializeLinkState(msg)
+	case unix.RTM_NEWADDR, unix.RTM_DELADDR:
+		if len(msg.data) < unix.SizeofIfAddrmsg {
+			return nil, unix.EBADMSG
+		}
+		event.Address = deserializeAddress(msg)
+	case unix.RTM_NEWROUTE, unix.RTM_DELROUTE:
+		if len(msg.data) < unix.SizeofRtMsg {
+			return nil, unix.EBADMSG
+		}
+		event.Route, err = deserializeRoute(msg)
+	default:
+		return nil, nil
+	}
+
+	return &event, err
+}
+
+// deserializeLinkState decodes a netlink message into a LinkState struct.
+func deserializeLinkState(msg *message) *LinkState {
+	ifInfo := deserializeIfInfoMsg(msg.data)
+	attrs := msg.getAttributes(ifInfo)
+
+	link := LinkState{
+		Index: int(ifInfo.Index),
+		Flags: ifInfo.Flags,
+	}
+
+	for _, attr := range attrs {
+		switch attr.Type {
+		case unix.IFLA_IFNAME:
+			link.Name = strings.TrimRight(string(attr.value), "\x00")
+		case unix.IFLA_MTU:
+			link.MTU = int(encoder.Uint32(attr.value[0:4]))
+		case unix.IFLA_MASTER:
+			link.MasterIndex = int(encoder.Uint32(attr.value[0:4]))
+		case unix.IFLA_OPERSTATE:
+			link.OperState = attr.value[0]
+		}
+	}
+
+	return &link
+}
+
+// deserializeAddress decodes a netlink message into an Address struct.
+func deserializeAddress(msg *message) *Address {
+	ifAddr := deserializeIfAddrMsg(msg.data)
+	attrs := msg.getAttributes(ifAddr)
+
+	addr := Address{
+		Index:  int(ifAddr.Index),
+		Family: int(ifAddr.Family),
+		Scope:  int(ifAddr.Scope),
+	}
+
+	// The local address is the address of the interface, which differs from the peer address on point-to-point links.
+	var ip net.IP
+	for _, attr := range attrs {
+		switch attr.Type {
+		case unix.IFA_LOCAL:
+			ip = net.IP(attr.value)
+		case unix.IFA_ADDRESS:
+			if ip == nil {
+				ip = net.IP(attr.value)
+			}
+		}
+	}
+
+	if ip != nil {
+		addr.IPNet = &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(int(ifAddr.Prefixlen), 8*len(ip)),
+		}
+	}
+
+	return &addr
+}
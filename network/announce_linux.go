@@ -0,0 +1,241 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Max number of announcements sent for each address of an endpoint.
+	maxAnnouncements = 10
+
+	// Max interval between two announcements of the same address.
+	maxAnnouncementInterval = 50 * time.Millisecond
+
+	// Max time the announcements of an endpoint delay its creation.
+	maxAnnouncementDelay = 100 * time.Millisecond
+
+	arpOpRequest              = 1
+	icmpv6NeighborAdvert      = 136
+	icmpv6OverrideFlag        = 0x20
+	ndOptTargetLinkLayerAddr  = 2
+	ipv6HeaderLength          = 40
+	neighborAdvertLength      = 32
+	ipv6NextHeaderICMPv6      = 58
+	ndHopLimit                = 255
+	ethernetHeaderLength      = 14
+	arpPacketLength           = 28
+	ipv6AllNodesMulticastAddr = "ff02::1"
+)
+
+var (
+	ethernetBroadcastAddr = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	ipv6AllNodesMacAddr   = net.HardwareAddr{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+)
+
+// announceEndpoint sends gratuitous ARPs and unsolicited neighbor advertisements for the addresses of a new
+// endpoint, so that switches holding the previous location of a reused address update it.
+// Announcements are best effort, failures are logged and don't fail the endpoint.
+func (nw *network) announceEndpoint(ep *endpoint) {
+	if nw.Arp == nil || nw.Arp.Announcements <= 0 {
+		return
+	}
+
+	switch nw.Mode {
+	case opModeOverlay:
+		// Pod addresses are not reachable on the underlay, there is nothing to announce.
+		return
+
+	case opModeTransparent:
+		// The host routes the traffic of the pods, the addresses are reached through the master interface.
+		nw.announceAddresses(nw.extIf.Name, nw.extIf.MacAddress, ep.IPAddresses)
+		return
+	}
+
+	if ep.NetworkNameSpace == "" {
+		nw.announceAddresses(ep.IfName, ep.MacAddress, ep.IPAddresses)
+		return
+	}
+
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		log.Printf("[net] Failed to open netns %v to announce endpoint %v, err:%v.", ep.NetworkNameSpace, ep.Id, err)
+		return
+	}
+	defer ns.Close()
+
+	if err = ns.Enter(); err != nil {
+		log.Printf("[net] Failed to enter netns %v to announce endpoint %v, err:%v.", ep.NetworkNameSpace, ep.Id, err)
+		return
+	}
+
+	nw.announceAddresses(ep.IfName, ep.MacAddress, ep.IPAddresses)
+
+	if err = ns.Exit(); err != nil {
+		log.Printf("[net] Failed to exit netns, err:%v.", err)
+	}
+}
+
+// announceAddresses sends the configured number of announcements for each address on the given interface.
+func (nw *network) announceAddresses(ifName string, macAddress net.HardwareAddr, addresses []net.IPNet) {
+	if len(macAddress) != 6 {
+		log.Printf("[net] Not announcing addresses on interface %v without an ethernet address.", ifName)
+		return
+	}
+
+	interval := getAnnouncementInterval(nw.Arp.Announcements)
+	for i := 0; i < nw.Arp.Announcements; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+
+		for _, address := range addresses {
+			if err := sendAnnouncement(ifName, macAddress, address.IP); err != nil {
+				log.Printf("[net] Failed to announce address %v on interface %v, err:%v.", address.IP, ifName, err)
+				return
+			}
+		}
+	}
+
+	log.Printf("[net] Announced addresses %v on interface %v with MAC address %v.", addresses, ifName, macAddress)
+}
+
+// getAnnouncementInterval returns the interval between the given number of announcements of an address,
+// spreading them over at most maxAnnouncementDelay so that they don't hold up the creation of the endpoint.
+func getAnnouncementInterval(announcements int) time.Duration {
+	if announcements <= 1 {
+		return 0
+	}
+
+	interval := maxAnnouncementDelay / time.Duration(announcements-1)
+	if interval > maxAnnouncementInterval {
+		interval = maxAnnouncementInterval
+	}
+
+	return interval
+}
+
+// sendAnnouncement sends a gratuitous ARP for an IPv4 address, or an unsolicited neighbor advertisement for an
+// IPv6 address, on the given interface.
+func sendAnnouncement(ifName string, macAddress net.HardwareAddr, ip net.IP) error {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+
+	var frame []byte
+	var protocol uint16
+	var dstMacAddress net.HardwareAddr
+
+	if ip.To4() != nil {
+		protocol = unix.ETH_P_ARP
+		dstMacAddress = ethernetBroadcastAddr
+		frame = newGratuitousArp(macAddress, ip.To4())
+	} else {
+		protocol = unix.ETH_P_IPV6
+		dstMacAddress = ipv6AllNodesMacAddr
+		frame = newUnsolicitedNeighborAdvert(macAddress, ip.To16())
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(protocol)))
+	if err != nil {
+		return fmt.Errorf("failed to open packet socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	addr := &unix.SockaddrLinklayer{
+		Protocol: htons(protocol),
+		Ifindex:  iface.Index,
+		Halen:    6,
+	}
+	copy(addr.Addr[:], dstMacAddress)
+
+	return unix.Sendto(fd, newEthernetFrame(dstMacAddress, macAddress, protocol, frame), 0, addr)
+}
+
+// newEthernetFrame returns an ethernet frame carrying the given payload.
+func newEthernetFrame(dst net.HardwareAddr, src net.HardwareAddr, protocol uint16, payload []byte) []byte {
+	b := make([]byte, ethernetHeaderLength, ethernetHeaderLength+len(payload))
+	copy(b[0:6], dst)
+	copy(b[6:12], src)
+	binary.BigEndian.PutUint16(b[12:14], protocol)
+	return append(b, payload...)
+}
+
+// newGratuitousArp returns an ARP request for the given address from itself, updating the caches of its receivers.
+func newGratuitousArp(macAddress net.HardwareAddr, ip net.IP) []byte {
+	b := make([]byte, arpPacketLength)
+	binary.BigEndian.PutUint16(b[0:2], 1) // Ethernet hardware type.
+	binary.BigEndian.PutUint16(b[2:4], unix.ETH_P_IP)
+	b[4] = 6
+	b[5] = 4
+	binary.BigEndian.PutUint16(b[6:8], arpOpRequest)
+	copy(b[8:14], macAddress)
+	copy(b[14:18], ip)
+	copy(b[24:28], ip)
+	return b
+}
+
+// newUnsolicitedNeighborAdvert returns an IPv6 packet advertising the given address to all nodes,
+// overriding the neighbor cache entries of its receivers.
+func newUnsolicitedNeighborAdvert(macAddress net.HardwareAddr, ip net.IP) []byte {
+	dst := net.ParseIP(ipv6AllNodesMulticastAddr)
+
+	b := make([]byte, ipv6HeaderLength+neighborAdvertLength)
+	b[0] = 6 << 4
+	binary.BigEndian.PutUint16(b[4:6], neighborAdvertLength)
+	b[6] = ipv6NextHeaderICMPv6
+	b[7] = ndHopLimit
+	copy(b[8:24], ip)
+	copy(b[24:40], dst)
+
+	na := b[ipv6HeaderLength:]
+	na[0] = icmpv6NeighborAdvert
+	na[4] = icmpv6OverrideFlag
+	copy(na[8:24], ip)
+	na[24] = ndOptTargetLinkLayerAddr
+	na[25] = 1 // Option length in units of 8 bytes.
+	copy(na[26:32], macAddress)
+
+	binary.BigEndian.PutUint16(na[2:4], icmpv6Checksum(ip, dst, na))
+	return b
+}
+
+// icmpv6Checksum returns the checksum of an ICMPv6 message, covering the IPv6 pseudo-header.
+func icmpv6Checksum(src net.IP, dst net.IP, msg []byte) uint16 {
+	var sum uint32
+
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(b[i : i+2]))
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+
+	add(src.To16())
+	add(dst.To16())
+	sum += uint32(len(msg))
+	sum += ipv6NextHeaderICMPv6
+	add(msg)
+
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+
+	return ^uint16(sum)
+}
+
+// htons converts a short from host to network byte order.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGetAnnouncementInterval(t *testing.T) {
+	tests := []struct {
+		announcements int
+		interval      time.Duration
+	}{
+		{announcements: 0, interval: 0},
+		{announcements: 1, interval: 0},
+		{announcements: 2, interval: maxAnnouncementInterval},
+		{announcements: 3, interval: maxAnnouncementInterval},
+		{announcements: 5, interval: maxAnnouncementDelay / 4},
+		{announcements: maxAnnouncements, interval: maxAnnouncementDelay / (maxAnnouncements - 1)},
+	}
+
+	for _, test := range tests {
+		interval := getAnnouncementInterval(test.announcements)
+		if interval != test.interval {
+			t.Errorf("TestGetAnnouncementInterval failed @ %v announcements: interval %v, expected %v",
+				test.announcements, interval, test.interval)
+		}
+
+		// The announcements never delay the endpoint by more than the max delay.
+		if test.announcements > 1 && time.Duration(test.announcements-1)*interval > maxAnnouncementDelay {
+			t.Errorf("TestGetAnnouncementInterval failed @ %v announcements: delay above %v",
+				test.announcements, maxAnnouncementDelay)
+		}
+	}
+}
+
+func TestNewGratuitousArp(t *testing.T) {
+	macAddress, _ := net.ParseMAC("12:34:56:78:9a:bc")
+	ip := net.ParseIP("10.0.0.4").To4()
+
+	frame := newEthernetFrame(ethernetBroadcastAddr, macAddress, 0x0806, newGratuitousArp(macAddress, ip))
+	if len(frame) != ethernetHeaderLength+arpPacketLength {
+		t.Fatalf("TestNewGratuitousArp failed, frame length %v", len(frame))
+	}
+
+	if !bytes.Equal(frame[0:6], ethernetBroadcastAddr) || !bytes.Equal(frame[6:12], macAddress) ||
+		binary.BigEndian.Uint16(frame[12:14]) != 0x0806 {
+		t.Errorf("TestNewGratuitousArp failed, ethernet header %x", frame[:ethernetHeaderLength])
+	}
+
+	// The sender and the target of the request are the announced address.
+	arp := frame[ethernetHeaderLength:]
+	if binary.BigEndian.Uint16(arp[6:8]) != arpOpRequest || !bytes.Equal(arp[8:14], macAddress) ||
+		!bytes.Equal(arp[14:18], ip) || !bytes.Equal(arp[24:28], ip) {
+		t.Errorf("TestNewGratuitousArp failed, ARP packet %x", arp)
+	}
+}
+
+func TestNewUnsolicitedNeighborAdvert(t *testing.T) {
+	macAddress, _ := net.ParseMAC("12:34:56:78:9a:bc")
+	ip := net.ParseIP("fd00::4")
+
+	packet := newUnsolicitedNeighborAdvert(macAddress, ip)
+	if len(packet) != ipv6HeaderLength+neighborAdvertLength {
+		t.Fatalf("TestNewUnsolicitedNeighborAdvert failed, packet length %v", len(packet))
+	}
+
+	if packet[6] != ipv6NextHeaderICMPv6 || packet[7] != ndHopLimit ||
+		!net.IP(packet[8:24]).Equal(ip) || !net.IP(packet[24:40]).Equal(net.ParseIP(ipv6AllNodesMulticastAddr)) {
+		t.Errorf("TestNewUnsolicitedNeighborAdvert failed, IPv6 header %x", packet[:ipv6HeaderLength])
+	}
+
+	na := packet[ipv6HeaderLength:]
+	if na[0] != icmpv6NeighborAdvert || na[4]&icmpv6OverrideFlag == 0 || !net.IP(na[8:24]).Equal(ip) ||
+		na[24] != ndOptTargetLinkLayerAddr || !bytes.Equal(na[26:32], macAddress) {
+		t.Errorf("TestNewUnsolicitedNeighborAdvert failed, neighbor advertisement %x", na)
+	}
+
+	// A message with a valid checksum sums up to zero.
+	if sum := icmpv6Checksum(ip, net.ParseIP(ipv6AllNodesMulticastAddr), na); sum != 0 {
+		t.Errorf("TestNewUnsolicitedNeighborAdvert failed, invalid checksum %x", sum)
+	}
+}
+
+func TestAnnounceEndpointSkipped(t *testing.T) {
+	ep := &endpoint{Id: "ep1", IfName: "does-not-exist", MacAddress: net.HardwareAddr{2, 0, 0, 0, 0, 1}}
+
+	tests := []struct {
+		name string
+		nw   *network
+	}{
+		{name: "no ARP config", nw: &network{Mode: opModeBridge}},
+		{name: "no announcements", nw: &network{Mode: opModeBridge, Arp: &ArpConfig{}}},
+		{name: "overlay", nw: &network{Mode: opModeOverlay, Arp: &ArpConfig{Announcements: maxAnnouncements}}},
+	}
+
+	// Skipped announcements return at once, without looking up the interface.
+	for _, test := range tests {
+		start := time.Now()
+		test.nw.announceEndpoint(ep)
+		if elapsed := time.Since(start); elapsed >= maxAnnouncementInterval {
+			t.Errorf("TestAnnounceEndpointSkipped failed @ %v: took %v", test.name, elapsed)
+		}
+	}
+}
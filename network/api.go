@@ -9,14 +9,36 @@ import (
 
 var (
 	// Error responses returned by NetworkManager.
-	errSubnetNotFound         = fmt.Errorf("Subnet not found")
-	errNetworkModeInvalid     = fmt.Errorf("Network mode is invalid")
-	errNetworkExists          = fmt.Errorf("Network already exists")
-	errNetworkNotFound        = fmt.Errorf("Network not found")
-	errEndpointExists         = fmt.Errorf("Endpoint already exists")
-	errEndpointNotFound       = fmt.Errorf("Endpoint not found")
-	errNamespaceNotFound      = fmt.Errorf("Namespace not found")
-	errMultipleEndpointsFound = fmt.Errorf("Multiple endpoints found")
-	errEndpointInUse          = fmt.Errorf("Endpoint is already joined to a sandbox")
-	errEndpointNotInUse       = fmt.Errorf("Endpoint is not joined to a sandbox")
+	errSubnetNotFound              = fmt.Errorf("Subnet not found")
+	errNetworkModeInvalid          = fmt.Errorf("Network mode is invalid")
+	errNetworkDataplaneInvalid     = fmt.Errorf("Network dataplane is invalid")
+	errNetworkExists               = fmt.Errorf("Network already exists")
+	errNetworkConfigDrift          = fmt.Errorf("Network config drift")
+	errSriovLinkInvalid            = fmt.Errorf("SR-IOV link is invalid")
+	errSriovVfNotFound             = fmt.Errorf("SR-IOV virtual function not found")
+	errPolicyRoutingNotSupported   = fmt.Errorf("Policy routing is not supported for the network mode")
+	errSnatIPBlockInvalid          = fmt.Errorf("SNAT IP block is invalid")
+	errNat64ConfigInvalid          = fmt.Errorf("NAT64 configuration is invalid")
+	errNat64NotSupported           = fmt.Errorf("NAT64 is not supported for the network")
+	errOverlayConfigInvalid        = fmt.Errorf("Overlay configuration is invalid")
+	errOverlayNotSupported         = fmt.Errorf("Overlay is not supported for the network")
+	errSnatIPBlockExhausted        = fmt.Errorf("SNAT IP block is exhausted")
+	errNetworkNotFound             = fmt.Errorf("Network not found")
+	errEndpointExists              = fmt.Errorf("Endpoint already exists")
+	errEndpointNotFound            = fmt.Errorf("Endpoint not found")
+	errNamespaceNotFound           = fmt.Errorf("Namespace not found")
+	errNamespaceInvalid            = fmt.Errorf("Network namespace is invalid")
+	errMultipleEndpointsFound      = fmt.Errorf("Multiple endpoints found")
+	errEndpointInUse               = fmt.Errorf("Endpoint is already joined to a sandbox")
+	errEndpointNotInUse            = fmt.Errorf("Endpoint is not joined to a sandbox")
+	errWarmPoolNotSupported        = fmt.Errorf("Warm pool is not supported for the network mode")
+	errWarmEndpointExists          = fmt.Errorf("Warm endpoint already exists")
+	errWarmEndpointNotFound        = fmt.Errorf("Warm endpoint not found")
+	errWarmEndpointAddressMismatch = fmt.Errorf("Warm endpoint address doesn't match endpoint address")
+	errPortMappingInvalid          = fmt.Errorf("Port mapping is invalid")
+	errPortMappingConflict         = fmt.Errorf("Host port is already mapped")
+	errEgressIPInvalid             = fmt.Errorf("Egress IP is invalid")
+	errEgressIPNotSupported        = fmt.Errorf("Egress IP is not supported for the network")
+	errMirrorInvalid               = fmt.Errorf("Mirroring is invalid")
+	errMirrorNotSupported          = fmt.Errorf("Mirroring is not supported for the network")
 )
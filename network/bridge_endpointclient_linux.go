@@ -75,8 +75,7 @@ func (client *LinuxBridgeEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 
 		if client.mode != opModeTunnel {
 			log.Printf("[net] Adding static arp for IP address %v and MAC %v in VM", ipAddr.String(), client.containerMac.String())
-			netlink.AddOrRemoveStaticArp(netlink.ADD, client.bridgeName, ipAddr.IP, client.containerMac)
-			if err != nil {
+			if err := netlink.AddOrRemoveStaticArp(netlink.ADD, client.bridgeName, ipAddr.IP, client.containerMac); err != nil {
 				log.Printf("Failed setting arp in vm: %v", err)
 			}
 		}
@@ -110,8 +109,7 @@ func (client *LinuxBridgeEndpointClient) DeleteEndpointRules(ep *endpoint) {
 
 		if client.mode != opModeTunnel {
 			log.Printf("[net] Removing static arp for IP address %v and MAC %v from VM", ipAddr.String(), ep.MacAddress.String())
-			netlink.AddOrRemoveStaticArp(netlink.REMOVE, client.bridgeName, ipAddr.IP, ep.MacAddress)
-			if err != nil {
+			if err := netlink.AddOrRemoveStaticArp(netlink.REMOVE, client.bridgeName, ipAddr.IP, ep.MacAddress); err != nil {
 				log.Printf("Failed removing arp from vm: %v", err)
 			}
 		}
@@ -133,6 +131,19 @@ func (client *LinuxBridgeEndpointClient) getArpReplyAddress(epMacAddress net.Har
 	return macAddress
 }
 
+// getEndpointRules returns the ebtables rules of the IP addresses of an endpoint.
+func (client *LinuxBridgeEndpointClient) getEndpointRules(ipAddresses []net.IPNet, epMacAddress net.HardwareAddr) []ebtables.Rule {
+	var rules []ebtables.Rule
+
+	for _, ipAddr := range ipAddresses {
+		rules = append(rules,
+			ebtables.ArpReplyRule(ipAddr.IP, client.getArpReplyAddress(epMacAddress)),
+			ebtables.DnatForIPAddressRule(client.hostPrimaryIfName, ipAddr.IP, epMacAddress))
+	}
+
+	return rules
+}
+
 func (client *LinuxBridgeEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
 	// Move the container interface to container's network namespace.
 	log.Printf("[net] Setting link %v netns %v.", client.containerVethName, epInfo.NetNsPath)
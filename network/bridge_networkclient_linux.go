@@ -105,3 +105,64 @@ func (client *LinuxBridgeClient) SetBridgeMasterToHostInterface() error {
 func (client *LinuxBridgeClient) SetHairpinOnHostInterface(enable bool) error {
 	return netlink.SetLinkHairpin(client.hostInterfaceName, enable)
 }
+
+// getL2Rules returns the ebtables rules of the Linux bridge networks in the state and of their endpoints.
+func (nm *networkManager) getL2Rules() []ebtables.Rule {
+	var rules []ebtables.Rule
+
+	for _, extIf := range nm.ExternalInterfaces {
+		if extIf.BridgeName == "" {
+			continue
+		}
+
+		var networks []*network
+		tunnel := false
+		for _, nw := range extIf.Networks {
+			if (nw.Mode == opModeBridge || nw.Mode == opModeTunnel) && !nw.isOVS() {
+				networks = append(networks, nw)
+				tunnel = tunnel || nw.Mode == opModeTunnel
+			}
+		}
+
+		if len(networks) == 0 {
+			continue
+		}
+
+		rules = append(rules, ebtables.SnatForInterfaceRule(extIf.Name, extIf.MacAddress))
+		if len(extIf.IPAddresses) > 0 {
+			rules = append(rules, ebtables.ArpReplyRule(extIf.IPAddresses[0].IP, extIf.MacAddress))
+		}
+		rules = append(rules, ebtables.DnatForArpRepliesRule(extIf.Name))
+		if tunnel {
+			rules = append(rules, ebtables.VepaModeRules(extIf.BridgeName, commonInterfacePrefix, virtualMacAddress)...)
+		}
+
+		for _, nw := range networks {
+			epClient := NewLinuxBridgeEndpointClient(extIf, "", "", nw.Mode)
+
+			for _, ep := range nw.Endpoints {
+				if ep.VlanID != 0 || ep.MacAddress == nil {
+					continue
+				}
+				rules = append(rules, epClient.getEndpointRules(ep.IPAddresses, ep.MacAddress)...)
+			}
+
+			for _, warmEp := range nw.WarmEndpoints {
+				if warmEp.MacAddress == nil {
+					continue
+				}
+				rules = append(rules, epClient.getEndpointRules([]net.IPNet{warmEp.IPAddress}, warmEp.MacAddress)...)
+			}
+		}
+	}
+
+	return rules
+}
+
+// reconcileL2Rules makes the ebtables chains of the plugin hold the rules of the state, restoring the rules other
+// agents deleted and deleting those left behind.
+func (nm *networkManager) reconcileL2Rules() {
+	if err := ebtables.Reconcile(nm.getL2Rules()); err != nil {
+		log.Printf("[net] Failed to reconcile ebtables rules, err:%v.", err)
+	}
+}
@@ -0,0 +1,528 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Environment variable passing the settings of the DNS64 proxy process.
+	dns64ProxyConfigEnv = "AZURE_CNI_DNS64_PROXY_CONFIG"
+
+	// DNS record types and class handled by the DNS64 proxy.
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeAAAA  = 28
+	dnsClassIN   = 1
+
+	// Bits of the flags in the DNS header.
+	dnsFlagTruncated = 1 << 9
+	dnsRcodeMask     = 0xf
+
+	dnsHeaderSize = 12
+
+	// Largest DNS message over UDP without EDNS, and with EDNS.
+	dnsMaxUDPSize     = 512
+	dnsMaxMessageSize = 65535
+
+	// Timeout for queries forwarded to the upstream server, and idle TCP connections of clients.
+	dns64QueryTimeout = 5 * time.Second
+)
+
+// dns64ProxyConfig holds the settings of the DNS64 proxy process of a NAT64 translator.
+type dns64ProxyConfig struct {
+	Address  string
+	Prefix   string
+	Upstream string
+}
+
+// DNS64Proxy answers the DNS queries of IPv6-only pods through an upstream server. AAAA queries for names that only
+// have A records are answered with AAAA records synthesized from the IPv4 addresses in the NAT64 prefix, as in
+// RFC 6147, so that the pods reach IPv4-only destinations through the NAT64 translator.
+type DNS64Proxy struct {
+	Prefix   *net.IPNet
+	Upstream string
+	udpConn  *net.UDPConn
+	tcpLn    net.Listener
+}
+
+// dnsRecord is a resource record of a DNS message, with its owner name uncompressed.
+type dnsRecord struct {
+	name  []byte
+	rtype uint16
+	class uint16
+	ttl   uint32
+	rdata []byte
+}
+
+// dnsMessage is the part of a DNS message the DNS64 proxy needs. Authority and additional records are ignored.
+type dnsMessage struct {
+	id        uint16
+	flags     uint16
+	questions []dnsRecord
+	answers   []dnsRecord
+}
+
+// RunDNS64Proxy serves the DNS64 proxy configured in the environment until the process is terminated.
+func RunDNS64Proxy() error {
+	var config dns64ProxyConfig
+	if err := json.Unmarshal([]byte(os.Getenv(dns64ProxyConfigEnv)), &config); err != nil {
+		return fmt.Errorf("Invalid DNS64 proxy configuration: %v", err)
+	}
+
+	_, prefix, err := net.ParseCIDR(config.Prefix)
+	if err != nil {
+		return err
+	}
+
+	proxy := &DNS64Proxy{Prefix: prefix, Upstream: config.Upstream}
+	if err = proxy.Start(config.Address); err != nil {
+		return err
+	}
+	defer proxy.Stop()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	<-signals
+
+	log.Printf("[net] DNS64 proxy on %v stopped.", config.Address)
+	return nil
+}
+
+// Start starts serving the proxy over UDP and TCP on the given address.
+func (p *DNS64Proxy) Start(address string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return err
+	}
+
+	p.udpConn, err = net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+
+	// Listen on the port picked for UDP, if any.
+	p.tcpLn, err = net.Listen("tcp", p.udpConn.LocalAddr().String())
+	if err != nil {
+		p.udpConn.Close()
+		return err
+	}
+
+	go p.serveUDP(p.udpConn)
+	go p.serveTCP(p.tcpLn)
+
+	log.Printf("[net] DNS64 proxy listening on %v for upstream %v and prefix %v.", p.udpConn.LocalAddr(), p.Upstream, p.Prefix)
+	return nil
+}
+
+// Stop stops serving the proxy.
+func (p *DNS64Proxy) Stop() {
+	if p.udpConn != nil {
+		p.udpConn.Close()
+		p.tcpLn.Close()
+		p.udpConn = nil
+		p.tcpLn = nil
+	}
+}
+
+// Addr returns the address the proxy is listening on.
+func (p *DNS64Proxy) Addr() net.Addr {
+	return p.udpConn.LocalAddr()
+}
+
+// Reads queries over UDP until the connection is closed.
+func (p *DNS64Proxy) serveUDP(conn *net.UDPConn) {
+	for {
+		buf := make([]byte, dnsMaxMessageSize)
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		go func(query []byte) {
+			answer, err := p.resolve(query, p.exchangeUDP)
+			if err != nil {
+				log.Printf("[net] DNS64 proxy failed to resolve query from %v, err:%v.", src, err)
+				return
+			}
+
+			conn.WriteToUDP(truncateDNSAnswer(query, answer), src)
+		}(buf[:n])
+	}
+}
+
+// Accepts TCP connections until the listener is closed.
+func (p *DNS64Proxy) serveTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go p.serveTCPConn(conn)
+	}
+}
+
+// Answers the queries of a TCP connection until the client closes it or stays idle.
+func (p *DNS64Proxy) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		conn.SetDeadline(time.Now().Add(dns64QueryTimeout))
+
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+
+		answer, err := p.resolve(query, p.exchangeTCP)
+		if err != nil {
+			log.Printf("[net] DNS64 proxy failed to resolve query from %v, err:%v.", conn.RemoteAddr(), err)
+			return
+		}
+
+		if err = writeTCPMessage(conn, answer); err != nil {
+			return
+		}
+	}
+}
+
+// Sends a query to the upstream server over UDP and returns its answer.
+func (p *DNS64Proxy) exchangeUDP(query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", p.Upstream, dns64QueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(dns64QueryTimeout))
+
+	if _, err = conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	// Skip answers to other queries, e.g. late answers from a previous connection using the same port.
+	for {
+		buf := make([]byte, dnsMaxMessageSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		if n >= dnsHeaderSize && buf[0] == query[0] && buf[1] == query[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+// Sends a query to the upstream server over TCP and returns its answer.
+func (p *DNS64Proxy) exchangeTCP(query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", p.Upstream, dns64QueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(dns64QueryTimeout))
+
+	if err = writeTCPMessage(conn, query); err != nil {
+		return nil, err
+	}
+
+	return readTCPMessage(conn)
+}
+
+// Resolves a query through the upstream server. AAAA queries answered without AAAA records are resolved again for
+// A records, and answered with AAAA records synthesized from them. Other queries and answers are passed through.
+func (p *DNS64Proxy) resolve(query []byte, exchange func([]byte) ([]byte, error)) ([]byte, error) {
+	answer, err := exchange(query)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := parseDNSMessage(query)
+	if err != nil || len(q.questions) != 1 || q.questions[0].rtype != dnsTypeAAAA || q.questions[0].class != dnsClassIN {
+		return answer, nil
+	}
+
+	// Names with IPv6 addresses are reached directly, and failures such as NXDOMAIN are returned as is.
+	a, err := parseDNSMessage(answer)
+	if err != nil || a.flags&dnsRcodeMask != 0 || a.flags&dnsFlagTruncated != 0 || a.hasAnswer(dnsTypeAAAA) {
+		return answer, nil
+	}
+
+	question := q.questions[0]
+	aQuery := &dnsMessage{
+		id:        uint16(rand.Intn(1 << 16)),
+		flags:     q.flags,
+		questions: []dnsRecord{{name: question.name, rtype: dnsTypeA, class: dnsClassIN}},
+	}
+
+	aAnswer, err := exchange(aQuery.encode())
+	if err != nil {
+		log.Printf("[net] DNS64 proxy failed to resolve A records, err:%v.", err)
+		return answer, nil
+	}
+
+	m, err := parseDNSMessage(aAnswer)
+	if err != nil || m.id != aQuery.id || m.flags&dnsRcodeMask != 0 || !m.hasAnswer(dnsTypeA) {
+		return answer, nil
+	}
+
+	synthesized := &dnsMessage{
+		id:        q.id,
+		flags:     a.flags &^ dnsFlagTruncated,
+		questions: q.questions,
+	}
+
+	for _, record := range m.answers {
+		switch {
+		case record.rtype == dnsTypeCNAME:
+			synthesized.answers = append(synthesized.answers, record)
+		case record.rtype == dnsTypeA && len(record.rdata) == net.IPv4len:
+			record.rtype = dnsTypeAAAA
+			record.rdata = synthesizeIPv6Address(p.Prefix, net.IP(record.rdata))
+			synthesized.answers = append(synthesized.answers, record)
+		}
+	}
+
+	return synthesized.encode(), nil
+}
+
+// synthesizeIPv6Address returns the IPv6 address embedding an IPv4 address in a NAT64 prefix, as in RFC 6052.
+// Bits 64 to 71 of the address are left zero.
+func synthesizeIPv6Address(prefix *net.IPNet, ipv4 net.IP) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP.To16())
+
+	ones, _ := prefix.Mask.Size()
+	i := ones / 8
+	for _, b := range ipv4.To4() {
+		if i == 8 {
+			i++
+		}
+		ip[i] = b
+		i++
+	}
+
+	return ip
+}
+
+// Returns an answer fitting a client over UDP. Answers larger than a client without EDNS accepts are replaced
+// with an empty answer flagged as truncated, so that the client retries over TCP.
+func truncateDNSAnswer(query []byte, answer []byte) []byte {
+	if len(answer) <= dnsMaxUDPSize || hasEDNS(query) {
+		return answer
+	}
+
+	m, err := parseDNSMessage(answer)
+	if err != nil {
+		return answer
+	}
+
+	m.flags |= dnsFlagTruncated
+	m.answers = nil
+	return m.encode()
+}
+
+// Returns whether the query carries additional records, such as the OPT record of EDNS.
+func hasEDNS(query []byte) bool {
+	return len(query) >= dnsHeaderSize && binary.BigEndian.Uint16(query[10:]) > 0
+}
+
+// Returns whether the message answers with a record of the given type.
+func (m *dnsMessage) hasAnswer(rtype uint16) bool {
+	for _, record := range m.answers {
+		if record.rtype == rtype {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parses the header, questions and answers of a DNS message.
+func parseDNSMessage(msg []byte) (*dnsMessage, error) {
+	if len(msg) < dnsHeaderSize {
+		return nil, fmt.Errorf("DNS message is too short")
+	}
+
+	m := &dnsMessage{
+		id:    binary.BigEndian.Uint16(msg[0:]),
+		flags: binary.BigEndian.Uint16(msg[2:]),
+	}
+
+	qdCount := int(binary.BigEndian.Uint16(msg[4:]))
+	anCount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := dnsHeaderSize
+	for i := 0; i < qdCount; i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+
+		if next+4 > len(msg) {
+			return nil, fmt.Errorf("DNS question is truncated")
+		}
+
+		m.questions = append(m.questions, dnsRecord{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(msg[next:]),
+			class: binary.BigEndian.Uint16(msg[next+2:]),
+		})
+		off = next + 4
+	}
+
+	for i := 0; i < anCount; i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+
+		if next+10 > len(msg) {
+			return nil, fmt.Errorf("DNS record is truncated")
+		}
+
+		record := dnsRecord{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(msg[next:]),
+			class: binary.BigEndian.Uint16(msg[next+2:]),
+			ttl:   binary.BigEndian.Uint32(msg[next+4:]),
+		}
+
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		if start+length > len(msg) {
+			return nil, fmt.Errorf("DNS record data is truncated")
+		}
+
+		record.rdata = msg[start : start+length]
+		if record.rtype == dnsTypeCNAME {
+			// The target name may point into the message, store it uncompressed.
+			if record.rdata, _, err = readDNSName(msg, start); err != nil {
+				return nil, err
+			}
+		}
+
+		m.answers = append(m.answers, record)
+		off = start + length
+	}
+
+	return m, nil
+}
+
+// Reads the possibly compressed name at the given offset of a message. Returns the name uncompressed, in wire format,
+// and the offset following the name.
+func readDNSName(msg []byte, off int) ([]byte, int, error) {
+	var name []byte
+	next := -1
+
+	// Each pointer must point backwards, so that reading ends.
+	for {
+		if off >= len(msg) {
+			return nil, 0, fmt.Errorf("DNS name is truncated")
+		}
+
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			name = append(name, 0)
+			if next < 0 {
+				next = off + 1
+			}
+			return name, next, nil
+
+		case length&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return nil, 0, fmt.Errorf("DNS name is truncated")
+			}
+
+			target := int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			if target >= off {
+				return nil, 0, fmt.Errorf("Invalid DNS name compression")
+			}
+
+			if next < 0 {
+				next = off + 2
+			}
+			off = target
+
+		case length&0xc0 != 0:
+			return nil, 0, fmt.Errorf("Invalid DNS label type")
+
+		default:
+			if off+1+length > len(msg) || len(name)+1+length > 255 {
+				return nil, 0, fmt.Errorf("Invalid DNS name length")
+			}
+
+			name = append(name, msg[off:off+1+length]...)
+			off += 1 + length
+		}
+	}
+}
+
+// Encodes the message without name compression.
+func (m *dnsMessage) encode() []byte {
+	msg := make([]byte, dnsHeaderSize)
+	binary.BigEndian.PutUint16(msg[0:], m.id)
+	binary.BigEndian.PutUint16(msg[2:], m.flags)
+	binary.BigEndian.PutUint16(msg[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(m.answers)))
+
+	var b [10]byte
+	for _, question := range m.questions {
+		msg = append(msg, question.name...)
+		binary.BigEndian.PutUint16(b[0:], question.rtype)
+		binary.BigEndian.PutUint16(b[2:], question.class)
+		msg = append(msg, b[:4]...)
+	}
+
+	for _, record := range m.answers {
+		msg = append(msg, record.name...)
+		binary.BigEndian.PutUint16(b[0:], record.rtype)
+		binary.BigEndian.PutUint16(b[2:], record.class)
+		binary.BigEndian.PutUint32(b[4:], record.ttl)
+		binary.BigEndian.PutUint16(b[8:], uint16(len(record.rdata)))
+		msg = append(msg, b[:10]...)
+		msg = append(msg, record.rdata...)
+	}
+
+	return msg
+}
+
+// Reads a DNS message prefixed with its length from a TCP connection.
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// Writes a DNS message prefixed with its length to a TCP connection.
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
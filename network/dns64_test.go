@@ -0,0 +1,278 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Encodes a name in DNS wire format.
+func encodeDNSName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// Returns a query for the given name and type.
+func newDNSQuery(id uint16, name string, rtype uint16) []byte {
+	q := &dnsMessage{
+		id:        id,
+		flags:     1 << 8, // Recursion desired.
+		questions: []dnsRecord{{name: encodeDNSName(name), rtype: rtype, class: dnsClassIN}},
+	}
+	return q.encode()
+}
+
+// fakeDNSServer answers queries from fixed records, as an upstream server of the DNS64 proxy.
+type fakeDNSServer struct {
+	records map[string][]dnsRecord
+	rcode   uint16
+	queries int
+}
+
+func (s *fakeDNSServer) exchange(query []byte) ([]byte, error) {
+	s.queries++
+
+	q, err := parseDNSMessage(query)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &dnsMessage{id: q.id, flags: q.flags | 1<<15 | 1<<7 | s.rcode, questions: q.questions}
+	question := q.questions[0]
+	for _, record := range s.records[string(question.name)] {
+		if record.rtype == question.rtype || record.rtype == dnsTypeCNAME {
+			a.answers = append(a.answers, record)
+		}
+	}
+
+	return a.encode(), nil
+}
+
+func newFakeDNSServer() *fakeDNSServer {
+	name := encodeDNSName("ipv4.example.com")
+	return &fakeDNSServer{
+		records: map[string][]dnsRecord{
+			string(name): {
+				{name: name, rtype: dnsTypeA, class: dnsClassIN, ttl: 60, rdata: []byte{192, 0, 2, 33}},
+			},
+			string(encodeDNSName("alias.example.com")): {
+				{name: encodeDNSName("alias.example.com"), rtype: dnsTypeCNAME, class: dnsClassIN, ttl: 30, rdata: name},
+				{name: name, rtype: dnsTypeA, class: dnsClassIN, ttl: 60, rdata: []byte{192, 0, 2, 34}},
+			},
+			string(encodeDNSName("dual.example.com")): {
+				{name: encodeDNSName("dual.example.com"), rtype: dnsTypeA, class: dnsClassIN, ttl: 60, rdata: []byte{192, 0, 2, 35}},
+				{name: encodeDNSName("dual.example.com"), rtype: dnsTypeAAAA, class: dnsClassIN, ttl: 60, rdata: net.ParseIP("2001:db8::35")},
+			},
+		},
+	}
+}
+
+func TestSynthesizeIPv6Address(t *testing.T) {
+	// Examples of RFC 6052, section 2.4.
+	ipv4 := net.ParseIP("192.0.2.33")
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122::/48", "2001:db8:122:c000:2:2100::"},
+		{"2001:db8:122:300::/56", "2001:db8:122:3c0:0:221::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+		{"2001:db8:122:344::/96", "2001:db8:122:344::192.0.2.33"},
+		{"64:ff9b::/96", "64:ff9b::192.0.2.33"},
+	}
+
+	for _, test := range tests {
+		_, prefix, _ := net.ParseCIDR(test.prefix)
+		ip := synthesizeIPv6Address(prefix, ipv4)
+		if !ip.Equal(net.ParseIP(test.expected)) {
+			t.Errorf("Prefix %v: got %v, expected %v", test.prefix, ip, test.expected)
+		}
+	}
+}
+
+func TestDNS64Resolve(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR(Nat64DefaultPrefix)
+	proxy := &DNS64Proxy{Prefix: prefix}
+
+	tests := []struct {
+		name     string
+		qname    string
+		qtype    uint16
+		rcode    uint16
+		expected []string
+		queries  int
+	}{
+		{name: "A query", qname: "ipv4.example.com", qtype: dnsTypeA, expected: []string{"192.0.2.33"}, queries: 1},
+		{name: "IPv4-only name", qname: "ipv4.example.com", qtype: dnsTypeAAAA, expected: []string{"64:ff9b::c000:221"}, queries: 2},
+		{name: "alias", qname: "alias.example.com", qtype: dnsTypeAAAA, expected: []string{"cname", "64:ff9b::c000:222"}, queries: 2},
+		{name: "dual stack name", qname: "dual.example.com", qtype: dnsTypeAAAA, expected: []string{"2001:db8::35"}, queries: 1},
+		{name: "unknown name", qname: "none.example.com", qtype: dnsTypeAAAA, expected: nil, queries: 2},
+		{name: "NXDOMAIN", qname: "ipv4.example.com", qtype: dnsTypeAAAA, rcode: 3, expected: nil, queries: 1},
+	}
+
+	for _, test := range tests {
+		server := newFakeDNSServer()
+		server.rcode = test.rcode
+
+		answer, err := proxy.resolve(newDNSQuery(0x1234, test.qname, test.qtype), server.exchange)
+		if err != nil {
+			t.Errorf("%v: resolve failed: %v", test.name, err)
+			continue
+		}
+
+		m, err := parseDNSMessage(answer)
+		if err != nil {
+			t.Errorf("%v: invalid answer: %v", test.name, err)
+			continue
+		}
+
+		var got []string
+		for _, record := range m.answers {
+			if record.rtype == dnsTypeCNAME {
+				got = append(got, "cname")
+			} else {
+				got = append(got, net.IP(record.rdata).String())
+			}
+		}
+
+		if m.id != 0x1234 || m.flags&dnsRcodeMask != test.rcode || fmt.Sprint(got) != fmt.Sprint(test.expected) ||
+			server.queries != test.queries {
+			t.Errorf("%v: got id %x rcode %v answers %v after %v queries, expected %v after %v queries",
+				test.name, m.id, m.flags&dnsRcodeMask, got, server.queries, test.expected, test.queries)
+		}
+
+		if len(m.questions) != 1 || !bytes.Equal(m.questions[0].name, encodeDNSName(test.qname)) || m.questions[0].rtype != test.qtype {
+			t.Errorf("%v: answer has question %+v", test.name, m.questions)
+		}
+	}
+}
+
+func TestParseDNSMessageCompression(t *testing.T) {
+	// Answer for example.com whose record points to the name of the question, and a pointer loop.
+	msg := []byte{0, 1, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0}
+	msg = append(msg, encodeDNSName("example.com")...)
+	msg = append(msg, 0, 1, 0, 1)
+	msg = append(msg, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 10, 0, 0, 1)
+
+	m, err := parseDNSMessage(msg)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+
+	if len(m.answers) != 1 || !bytes.Equal(m.answers[0].name, encodeDNSName("example.com")) || m.answers[0].ttl != 60 {
+		t.Errorf("Invalid answers %+v", m.answers)
+	}
+
+	loop := append([]byte{0, 1, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0}, 0xc0, 12, 0, 1, 0, 1)
+	if _, err := parseDNSMessage(loop); err == nil {
+		t.Errorf("Parsed message with a compression loop")
+	}
+
+	if _, err := parseDNSMessage(msg[:len(msg)-2]); err == nil {
+		t.Errorf("Parsed truncated message")
+	}
+}
+
+func TestDNS64ProxyUDPAndTCP(t *testing.T) {
+	server := newFakeDNSServer()
+
+	upstream, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer upstream.Close()
+
+	go func() {
+		buf := make([]byte, dnsMaxMessageSize)
+		for {
+			n, src, err := upstream.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			answer, _ := server.exchange(buf[:n])
+			upstream.WriteToUDP(answer, src)
+		}
+	}()
+
+	_, prefix, _ := net.ParseCIDR(Nat64DefaultPrefix)
+	proxy := &DNS64Proxy{Prefix: prefix, Upstream: upstream.LocalAddr().String()}
+	if err := proxy.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+	defer proxy.Stop()
+
+	conn, err := net.Dial("udp", proxy.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write(newDNSQuery(7, "ipv4.example.com", dnsTypeAAAA))
+
+	buf := make([]byte, dnsMaxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read answer: %v", err)
+	}
+
+	m, err := parseDNSMessage(buf[:n])
+	if err != nil || len(m.answers) != 1 || !net.IP(m.answers[0].rdata).Equal(net.ParseIP("64:ff9b::192.0.2.33")) {
+		t.Errorf("Invalid answer over UDP %+v, err:%v", m, err)
+	}
+
+	// Over TCP, the proxy resolves through the upstream server over TCP.
+	tcpServer := newFakeDNSServer()
+	upstreamTCP, err := net.Listen("tcp", upstream.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to listen over TCP: %v", err)
+	}
+	defer upstreamTCP.Close()
+
+	go func() {
+		for {
+			c, err := upstreamTCP.Accept()
+			if err != nil {
+				return
+			}
+			if query, err := readTCPMessage(c); err == nil {
+				answer, _ := tcpServer.exchange(query)
+				writeTCPMessage(c, answer)
+			}
+			c.Close()
+		}
+	}()
+
+	tcpConn, err := net.Dial("tcp", proxy.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy over TCP: %v", err)
+	}
+	defer tcpConn.Close()
+
+	tcpConn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := writeTCPMessage(tcpConn, newDNSQuery(8, "alias.example.com", dnsTypeAAAA)); err != nil {
+		t.Fatalf("Failed to write query over TCP: %v", err)
+	}
+
+	answer, err := readTCPMessage(tcpConn)
+	if err != nil {
+		t.Fatalf("Failed to read answer over TCP: %v", err)
+	}
+
+	m, err = parseDNSMessage(answer)
+	if err != nil || m.id != 8 || len(m.answers) != 2 || !net.IP(m.answers[1].rdata).Equal(net.ParseIP("64:ff9b::192.0.2.34")) {
+		t.Errorf("Invalid answer over TCP %+v, err:%v", m, err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+)
+
+var (
+	// Returns the addresses of the interfaces of the node. Overridden by tests.
+	interfaceAddrs = net.InterfaceAddrs
+)
+
+// Returns an error if the egress IP of a new endpoint is invalid or not assigned to the node, and adds the subnets
+// of the network to the destinations excluded from its SNAT, so that endpoints keep reaching each other with their
+// own addresses.
+func (nw *network) validateEgressIP(epInfo *EndpointInfo) error {
+	if epInfo.EgressIP == nil {
+		return nil
+	}
+
+	// The traffic of SR-IOV endpoints never reaches the host.
+	if nw.Mode == opModeSriov {
+		return errEgressIPNotSupported
+	}
+
+	if epInfo.EgressIP.To4() == nil || epInfo.EgressIP.IsUnspecified() || epInfo.EgressIP.IsLoopback() {
+		return fmt.Errorf("%v: %v is not a unicast IPv4 address", errEgressIPInvalid, epInfo.EgressIP)
+	}
+
+	// Replies to the traffic translated to an address the node doesn't hold would never come back.
+	assigned, err := isAssignedToNode(epInfo.EgressIP)
+	if err != nil {
+		return err
+	}
+
+	if !assigned {
+		return fmt.Errorf("%v: %v is not assigned to the node", errEgressIPInvalid, epInfo.EgressIP)
+	}
+
+	for _, exclusion := range epInfo.EgressIPExclusions {
+		if exclusion.IP.To4() == nil {
+			return fmt.Errorf("%v: exclusion %v is not an IPv4 prefix", errEgressIPInvalid, exclusion.String())
+		}
+	}
+
+	for _, subnet := range nw.Subnets {
+		if subnet.Prefix.IP.To4() == nil || containsPrefix(epInfo.EgressIPExclusions, subnet.Prefix) {
+			continue
+		}
+		epInfo.EgressIPExclusions = append(epInfo.EgressIPExclusions, subnet.Prefix)
+	}
+
+	return nil
+}
+
+// Returns whether an address is assigned to an interface of the node.
+func isAssignedToNode(ip net.IP) (bool, error) {
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return false, err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Returns whether a list of prefixes contains the given prefix.
+func containsPrefix(prefixes []net.IPNet, prefix net.IPNet) bool {
+	for _, p := range prefixes {
+		if p.String() == prefix.String() {
+			return true
+		}
+	}
+
+	return false
+}
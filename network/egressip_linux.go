@@ -0,0 +1,96 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Chain holding the SNAT rules of the endpoints with an egress IP.
+	egressIPChain = "AZURE-EGRESS"
+)
+
+// Creates the chain of the egress IPs, and jumps to it first from POSTROUTING, so that the egress IP of an endpoint
+// takes precedence over the masquerading of the node.
+func addEgressIPChain() error {
+	if _, err := platform.ExecWithTimeout("iptables", "-t", "nat", "-N", egressIPChain); err != nil {
+		if _, err = platform.ExecWithTimeout("iptables", "-t", "nat", "-L", egressIPChain); err != nil {
+			return err
+		}
+	}
+
+	if _, err := platform.ExecWithTimeout("iptables", "-t", "nat", "-C", "POSTROUTING", "-j", egressIPChain); err == nil {
+		return nil
+	}
+
+	_, err := platform.ExecWithTimeout("iptables", "-t", "nat", "-I", "POSTROUTING", "1", "-j", egressIPChain)
+	return err
+}
+
+// Returns the rules of the egress IP of an address of an endpoint. Traffic to the excluded destinations returns
+// before reaching the SNAT rule.
+func getEgressIPRules(endpointId string, ip net.IP, egressIP net.IP, exclusions []net.IPNet) [][]string {
+	var rules [][]string
+	for _, exclusion := range exclusions {
+		rules = append(rules, []string{
+			"-s", ip.String(), "-d", exclusion.String(),
+			"-m", "comment", "--comment", endpointId,
+			"-j", "RETURN",
+		})
+	}
+
+	return append(rules, []string{
+		"-s", ip.String(),
+		"-m", "comment", "--comment", endpointId,
+		"-j", "SNAT", "--to-source", egressIP.String(),
+	})
+}
+
+// addEgressIPRules translates the source of the traffic of an endpoint leaving the node to its egress IP.
+func (nw *network) addEgressIPRules(endpointId string, ipAddresses []net.IPNet, egressIP net.IP, exclusions []net.IPNet) error {
+	if egressIP == nil {
+		return nil
+	}
+
+	log.Printf("[net] Adding egress IP %v to endpoint %v.", egressIP, endpointId)
+	if err := addEgressIPChain(); err != nil {
+		return err
+	}
+
+	for _, ipAddr := range ipAddresses {
+		if ipAddr.IP.To4() == nil {
+			continue
+		}
+
+		for _, rule := range getEgressIPRules(endpointId, ipAddr.IP, egressIP, exclusions) {
+			if err := addIptablesRule("iptables", "nat", egressIPChain, rule...); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteEgressIPRules deletes the rules of the egress IP of an endpoint. The chain is kept for other endpoints.
+func (nw *network) deleteEgressIPRules(endpointId string, ipAddresses []net.IPNet, egressIP net.IP, exclusions []net.IPNet) {
+	if egressIP == nil {
+		return
+	}
+
+	log.Printf("[net] Deleting egress IP %v of endpoint %v.", egressIP, endpointId)
+	for _, ipAddr := range ipAddresses {
+		if ipAddr.IP.To4() == nil {
+			continue
+		}
+
+		for _, rule := range getEgressIPRules(endpointId, ipAddr.IP, egressIP, exclusions) {
+			deleteIptablesRule("iptables", "nat", egressIPChain, rule...)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestGetEgressIPRules(t *testing.T) {
+	_, exclusion, _ := net.ParseCIDR("10.240.0.0/16")
+
+	rules := getEgressIPRules("ep1", net.ParseIP("10.240.0.7"), net.ParseIP("10.0.0.100"), []net.IPNet{*exclusion})
+
+	// Excluded destinations return before the SNAT rule.
+	expected := [][]string{
+		{"-s", "10.240.0.7", "-d", "10.240.0.0/16", "-m", "comment", "--comment", "ep1", "-j", "RETURN"},
+		{"-s", "10.240.0.7", "-m", "comment", "--comment", "ep1", "-j", "SNAT", "--to-source", "10.0.0.100"},
+	}
+
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("getEgressIPRules returned %v, expected %v", rules, expected)
+	}
+
+	if rules = getEgressIPRules("ep1", net.ParseIP("10.240.0.7"), net.ParseIP("10.0.0.100"), nil); len(rules) != 1 {
+		t.Errorf("getEgressIPRules without exclusions returned %v", rules)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+// Makes the node hold the given addresses, until the returned function restores its own.
+func setInterfaceAddrs(t *testing.T, addresses ...string) func() {
+	saved := interfaceAddrs
+	interfaceAddrs = func() ([]net.Addr, error) {
+		var addrs []net.Addr
+		for _, address := range addresses {
+			ip, ipNet, err := net.ParseCIDR(address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addrs = append(addrs, &net.IPNet{IP: ip, Mask: ipNet.Mask})
+		}
+		return addrs, nil
+	}
+
+	return func() { interfaceAddrs = saved }
+}
+
+func TestValidateEgressIP(t *testing.T) {
+	defer setInterfaceAddrs(t, "10.0.0.4/24", "10.0.0.100/24")()
+
+	_, subnet, _ := net.ParseCIDR("10.240.0.0/16")
+	_, exclusion, _ := net.ParseCIDR("192.168.0.0/16")
+	_, v6Exclusion, _ := net.ParseCIDR("fd00::/64")
+
+	tests := []struct {
+		name       string
+		mode       string
+		egressIP   string
+		exclusions []net.IPNet
+		valid      bool
+	}{
+		{name: "assigned", egressIP: "10.0.0.100", exclusions: []net.IPNet{*exclusion}, valid: true},
+		{name: "not assigned to the node", egressIP: "10.0.0.101"},
+		{name: "IPv6", egressIP: "fd00::100"},
+		{name: "unspecified", egressIP: "0.0.0.0"},
+		{name: "IPv6 exclusion", egressIP: "10.0.0.100", exclusions: []net.IPNet{*v6Exclusion}},
+		{name: "SR-IOV", mode: opModeSriov, egressIP: "10.0.0.100"},
+	}
+
+	for _, test := range tests {
+		nw := &network{
+			Mode:    test.mode,
+			Subnets: []SubnetInfo{{Prefix: *subnet}},
+		}
+		epInfo := &EndpointInfo{
+			EgressIP:           net.ParseIP(test.egressIP),
+			EgressIPExclusions: test.exclusions,
+		}
+
+		err := nw.validateEgressIP(epInfo)
+		if (err == nil) != test.valid {
+			t.Errorf("validateEgressIP of %s returned err:%v", test.name, err)
+			continue
+		}
+
+		// The subnets of the network keep the pod address.
+		if test.valid && !containsPrefix(epInfo.EgressIPExclusions, *subnet) {
+			t.Errorf("validateEgressIP of %s didn't exclude the subnet of the network: %v", test.name, epInfo.EgressIPExclusions)
+		}
+	}
+}
+
+func TestValidateEgressIPWithoutEgressIP(t *testing.T) {
+	nw := &network{}
+	if err := nw.validateEgressIP(&EndpointInfo{}); err != nil {
+		t.Errorf("validateEgressIP of an endpoint without egress IP returned err:%v", err)
+	}
+}
@@ -33,9 +33,15 @@ type endpoint struct {
 	EnableMultitenancy    bool
 	NetworkNameSpace      string `json:",omitempty"`
 	ContainerID           string
-	PODName               string `json:",omitempty"`
-	PODNameSpace          string `json:",omitempty"`
-	InfraVnetAddressSpace string `json:",omitempty"`
+	PODName               string        `json:",omitempty"`
+	PODNameSpace          string        `json:",omitempty"`
+	InfraVnetAddressSpace string        `json:",omitempty"`
+	LocalIP               string        `json:",omitempty"`
+	SnatIP                net.IP        `json:",omitempty"`
+	PortMappings          []PortMapping `json:",omitempty"`
+	EgressIP              net.IP        `json:",omitempty"`
+	EgressIPExclusions    []net.IPNet   `json:",omitempty"`
+	Mirror                *MirrorInfo   `json:",omitempty"`
 }
 
 // EndpointInfo contains read-only information about an endpoint.
@@ -43,6 +49,7 @@ type EndpointInfo struct {
 	Id                    string
 	ContainerID           string
 	NetNsPath             string
+	NetNsPid              int // Alternative to NetNsPath, the PID of a process in the network namespace.
 	IfName                string
 	SandboxKey            string
 	IfIndex               int
@@ -52,6 +59,10 @@ type EndpointInfo struct {
 	InfraVnetIP           net.IPNet
 	Routes                []RouteInfo
 	Policies              []policy.Policy
+	PortMappings          []PortMapping
+	EgressIP              net.IP      // Source address of the traffic of the endpoint leaving the node.
+	EgressIPExclusions    []net.IPNet // Destinations reached with the address of the endpoint instead.
+	Mirror                *MirrorInfo // Mirroring of the traffic of the endpoint in progress, if any.
 	Gateways              []net.IP
 	EnableSnatOnHost      bool
 	EnableInfraVnet       bool
@@ -60,6 +71,7 @@ type EndpointInfo struct {
 	PODNameSpace          string
 	Data                  map[string]interface{}
 	InfraVnetAddressSpace string
+	Timer                 StageTimer `json:"-"`
 }
 
 // RouteInfo contains information about an IP route.
@@ -84,6 +96,10 @@ func (nw *network) newEndpoint(epInfo *EndpointInfo) (*endpoint, error) {
 		}
 	}()
 
+	if err = epInfo.resolveNamespace(); err != nil {
+		return nil, err
+	}
+
 	// Call the platform implementation.
 	ep, err = nw.newEndpointImpl(epInfo)
 	if err != nil {
@@ -93,9 +109,24 @@ func (nw *network) newEndpoint(epInfo *EndpointInfo) (*endpoint, error) {
 	nw.Endpoints[epInfo.Id] = ep
 	log.Printf("[net] Created endpoint %+v.", ep)
 
+	nw.announceEndpoint(ep)
+
 	return ep, nil
 }
 
+// CleanupEndpoint deletes the interfaces left behind by an endpoint whose creation was interrupted.
+// Endpoints of the network are left alone.
+func (nw *network) cleanupEndpoint(epInfo *EndpointInfo) error {
+	if nw.Endpoints[epInfo.Id] != nil {
+		return nil
+	}
+
+	log.Printf("[net] Cleaning up endpoint %v in network %v.", epInfo.Id, nw.Id)
+
+	// Call the platform implementation.
+	return nw.cleanupEndpointImpl(epInfo)
+}
+
 // DeleteEndpoint deletes an existing endpoint from the network.
 func (nw *network) deleteEndpoint(endpointId string) error {
 	var err error
@@ -164,6 +195,17 @@ func (nw *network) getEndpointByPOD(podName string, podNameSpace string) (*endpo
 	return ep, nil
 }
 
+// getEndpointByHostIfName returns the endpoint whose host interface has the given name.
+func (nw *network) getEndpointByHostIfName(hostIfName string) (*endpoint, error) {
+	for _, ep := range nw.Endpoints {
+		if ep.HostIfName == hostIfName {
+			return ep, nil
+		}
+	}
+
+	return nil, errEndpointNotFound
+}
+
 //
 // Endpoint
 //
@@ -197,6 +239,18 @@ func (ep *endpoint) getInfo() *EndpointInfo {
 		info.Gateways = append(info.Gateways, gw)
 	}
 
+	for _, mapping := range ep.PortMappings {
+		info.PortMappings = append(info.PortMappings, mapping)
+	}
+
+	info.EgressIP = ep.EgressIP
+	info.EgressIPExclusions = append(info.EgressIPExclusions, ep.EgressIPExclusions...)
+
+	if ep.Mirror != nil {
+		mirror := *ep.Mirror
+		info.Mirror = &mirror
+	}
+
 	// Call the platform implementation.
 	ep.getInfoImpl(info)
 
@@ -44,6 +44,43 @@ func ConstructEndpointID(containerID string, netNsPath string, ifName string) (s
 	return infraEpName, ""
 }
 
+// getVethNames returns the names of the host and container interfaces of the veth pair of an endpoint.
+func getVethNames(epInfo *EndpointInfo) (string, string) {
+	if key, ok := epInfo.Data[OptVethName].(string); ok {
+		log.Printf("Generate veth name based on the key provided %v", key)
+		vethname := generateVethName(key)
+		return fmt.Sprintf("%s%s", hostVEthInterfacePrefix, vethname), fmt.Sprintf("%s%s2", hostVEthInterfacePrefix, vethname)
+	}
+
+	// Create a veth pair.
+	log.Printf("Generate veth name based on endpoint id")
+	return fmt.Sprintf("%s%s", hostVEthInterfacePrefix, epInfo.Id[:7]), fmt.Sprintf("%s%s-2", hostVEthInterfacePrefix, epInfo.Id[:7])
+}
+
+// resolveNamespace sets the path of the network namespace of an endpoint given by the PID of a process in it,
+// for callers such as libvirt or custom sandboxes that manage namespaces themselves, and validates the path.
+// The path of a process namespace is only valid while the process runs.
+func (epInfo *EndpointInfo) resolveNamespace() error {
+	if epInfo.NetNsPid != 0 {
+		if epInfo.NetNsPid < 0 {
+			return fmt.Errorf("%v: invalid PID %v", errNamespaceInvalid, epInfo.NetNsPid)
+		}
+
+		if epInfo.NetNsPath != "" {
+			return fmt.Errorf("%v: both path %v and PID %v given", errNamespaceInvalid, epInfo.NetNsPath, epInfo.NetNsPid)
+		}
+
+		epInfo.NetNsPath = GetProcessNamespacePath(epInfo.NetNsPid)
+		log.Printf("[net] Using netns %v of process %v.", epInfo.NetNsPath, epInfo.NetNsPid)
+	}
+
+	if epInfo.NetNsPath == "" {
+		return nil
+	}
+
+	return validateNamespacePath(epInfo.NetNsPath)
+}
+
 // newEndpointImpl creates a new endpoint in the network.
 func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 	var containerIf *net.Interface
@@ -61,26 +98,60 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		return nil, err
 	}
 
+	// Pods of IPv6-only networks depend on the NAT64 translator to reach IPv4 destinations.
+	if err = nw.ensureNat64(); err != nil {
+		return nil, err
+	}
+
+	warmEp, err := nw.takeWarmEndpoint(epInfo)
+	if err != nil {
+		return nil, err
+	}
+
 	if epInfo.Data != nil {
 		if _, ok := epInfo.Data[VlanIDKey]; ok {
 			vlanid = epInfo.Data[VlanIDKey].(int)
 		}
 	}
 
-	if _, ok := epInfo.Data[OptVethName]; ok {
-		key := epInfo.Data[OptVethName].(string)
-		log.Printf("Generate veth name based on the key provided %v", key)
-		vethname := generateVethName(key)
-		hostIfName = fmt.Sprintf("%s%s", hostVEthInterfacePrefix, vethname)
-		contIfName = fmt.Sprintf("%s%s2", hostVEthInterfacePrefix, vethname)
+	if epInfo.EnableSnatOnHost && nw.SnatIPBlock != "" {
+		var snatIP net.IP
+		stopSnat := epInfo.startStage(StageSnat)
+		snatIP, err = nw.allocateSnatIP()
+		stopSnat()
+		if err != nil {
+			return nil, err
+		}
+
+		if epInfo.Data == nil {
+			epInfo.Data = make(map[string]interface{})
+		}
+
+		log.Printf("[net] Assigning SNAT IP %v to endpoint %v.", snatIP, epInfo.Id)
+		epInfo.Data[SnatIPKey] = snatIP.String()
+	}
+
+	if warmEp != nil {
+		log.Printf("Use veth pair of warm endpoint %v", warmEp.Id)
+		hostIfName = warmEp.HostIfName
+		contIfName = warmEp.IfName
 	} else {
-		// Create a veth pair.
-		log.Printf("Generate veth name based on endpoint id")
-		hostIfName = fmt.Sprintf("%s%s", hostVEthInterfacePrefix, epInfo.Id[:7])
-		contIfName = fmt.Sprintf("%s%s-2", hostVEthInterfacePrefix, epInfo.Id[:7])
+		hostIfName, contIfName = getVethNames(epInfo)
 	}
 
-	if vlanid != 0 {
+	if nw.Mode == opModeSriov {
+		log.Printf("SR-IOV client")
+		if hostIfName, err = getSriovVfName(nw.extIf, nw.Sriov); err != nil {
+			return nil, err
+		}
+
+		// The virtual function itself is moved to the container namespace.
+		if nw.Sriov.getLink() == SriovLinkDirect {
+			contIfName = hostIfName
+		}
+
+		epClient = NewSriovEndpointClient(nw.extIf, nw.Sriov, hostIfName, contIfName)
+	} else if vlanid != 0 || nw.isOVS() {
 		log.Printf("OVS client")
 		epClient = NewOVSEndpointClient(
 			nw.extIf,
@@ -88,12 +159,20 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 			hostIfName,
 			contIfName,
 			vlanid)
-	} else if nw.Mode != opModeTransparent {
+	} else if nw.Mode != opModeTransparent && nw.Mode != opModeOverlay {
 		log.Printf("Bridge client")
 		epClient = NewLinuxBridgeEndpointClient(nw.extIf, hostIfName, contIfName, nw.Mode)
 	} else {
 		log.Printf("Transparent client")
-		epClient = NewTransparentEndpointClient(nw.extIf, hostIfName, contIfName, nw.Mode)
+		transparentClient := NewTransparentEndpointClient(nw.extIf, hostIfName, contIfName, nw.Mode)
+		if nw.Overlay != nil {
+			transparentClient.mtu = nw.Overlay.MTU
+		}
+		epClient = transparentClient
+	}
+
+	if warmEp != nil {
+		epClient = &warmEndpointClient{EndpointClient: epClient}
 	}
 
 	// Cleanup on failure.
@@ -110,11 +189,15 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 				VlanID:             vlanid,
 				EnableSnatOnHost:   epInfo.EnableSnatOnHost,
 				EnableMultitenancy: epInfo.EnableMultiTenancy,
+				NetworkNameSpace:   epInfo.NetNsPath,
 			}
 
 			if containerIf != nil {
 				endpt.MacAddress = containerIf.HardwareAddr
 				epClient.DeleteEndpointRules(endpt)
+				nw.deleteEndpointPolicyRules(epInfo.IPAddresses)
+				nw.deletePortMappings(epInfo.Id, epInfo.IPAddresses, epInfo.PortMappings)
+				nw.deleteEgressIPRules(epInfo.Id, epInfo.IPAddresses, epInfo.EgressIP, epInfo.EgressIPExclusions)
 			}
 
 			epClient.DeleteEndpoints(endpt)
@@ -125,6 +208,13 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		return nil, err
 	}
 
+	// Let the host answer the ARP requests of the pod on the host side of the endpoint.
+	if nw.Arp != nil && nw.Arp.EndpointProxyArp != nil && nw.Mode != opModeSriov {
+		if err = setArpConfig(hostIfName, &ArpConfig{ProxyArp: nw.Arp.EndpointProxyArp}); err != nil {
+			return nil, err
+		}
+	}
+
 	containerIf, err = net.InterfaceByName(contIfName)
 	if err != nil {
 		return nil, err
@@ -135,6 +225,18 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		return nil, err
 	}
 
+	if err = nw.addEndpointPolicyRules(epInfo.IPAddresses); err != nil {
+		return nil, err
+	}
+
+	if err = nw.addPortMappings(epInfo.Id, epInfo.IPAddresses, epInfo.PortMappings); err != nil {
+		return nil, err
+	}
+
+	if err = nw.addEgressIPRules(epInfo.Id, epInfo.IPAddresses, epInfo.EgressIP, epInfo.EgressIPExclusions); err != nil {
+		return nil, err
+	}
+
 	// If a network namespace for the container interface is specified...
 	if epInfo.NetNsPath != "" {
 		// Open the network namespace.
@@ -193,15 +295,72 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		ContainerID:        epInfo.ContainerID,
 		PODName:            epInfo.PODName,
 		PODNameSpace:       epInfo.PODNameSpace,
+		PortMappings:       epInfo.PortMappings,
+		EgressIP:           epInfo.EgressIP,
+		EgressIPExclusions: epInfo.EgressIPExclusions,
 	}
 
 	for _, route := range epInfo.Routes {
 		ep.Routes = append(ep.Routes, route)
 	}
 
+	// Persist the addresses of the SNAT rules, which are deleted with the endpoint.
+	if localIP, ok := epInfo.Data[LocalIPKey].(string); ok {
+		ep.LocalIP = localIP
+	}
+
+	if snatIP, ok := epInfo.Data[SnatIPKey].(string); ok {
+		ep.SnatIP = net.ParseIP(snatIP)
+	}
+
 	return ep, nil
 }
 
+// cleanupEndpointImpl deletes the veth pair left behind by an endpoint whose creation was interrupted.
+func (nw *network) cleanupEndpointImpl(epInfo *EndpointInfo) error {
+	// Virtual functions are not created, and are reclaimed by the next endpoint using them.
+	if nw.Mode == opModeSriov {
+		return nil
+	}
+
+	hostIfName, contIfName := getVethNames(epInfo)
+	for _, ep := range nw.Endpoints {
+		if ep.HostIfName == hostIfName {
+			log.Printf("[net] Interface %v belongs to endpoint %v, not deleting it.", hostIfName, ep.Id)
+			return nil
+		}
+	}
+
+	if _, err := net.InterfaceByName(hostIfName); err != nil {
+		return nil
+	}
+
+	// The OVS ports and flows of the interface, e.g. of multitenant endpoints, are deleted first.
+	vlanid, _ := epInfo.Data[VlanIDKey].(int)
+	if (vlanid != 0 || nw.isOVS()) && nw.extIf != nil && len(epInfo.IPAddresses) > 0 {
+		ep := &endpoint{
+			Id:               epInfo.Id,
+			IfName:           contIfName,
+			HostIfName:       hostIfName,
+			InfraVnetIP:      epInfo.InfraVnetIP,
+			IPAddresses:      epInfo.IPAddresses,
+			VlanID:           vlanid,
+			EnableSnatOnHost: epInfo.EnableSnatOnHost,
+			EnableInfraVnet:  epInfo.EnableInfraVnet,
+		}
+
+		client := NewOVSEndpointClient(nw.extIf, epInfo, hostIfName, contIfName, vlanid)
+		client.DeleteEndpointRules(ep)
+
+		log.Printf("[net] Deleting interface %v left behind by endpoint %v.", hostIfName, epInfo.Id)
+		return client.DeleteEndpoints(ep)
+	}
+
+	// Deleting the host interface also deletes its peer, and the routes through them.
+	log.Printf("[net] Deleting interface %v left behind by endpoint %v.", hostIfName, epInfo.Id)
+	return netlink.DeleteLink(hostIfName)
+}
+
 // deleteEndpointImpl deletes an existing endpoint from the network.
 func (nw *network) deleteEndpointImpl(ep *endpoint) error {
 	var epClient EndpointClient
@@ -209,16 +368,21 @@ func (nw *network) deleteEndpointImpl(ep *endpoint) error {
 	// Delete the veth pair by deleting one of the peer interfaces.
 	// Deleting the host interface is more convenient since it does not require
 	// entering the container netns and hence works both for CNI and CNM.
-	if ep.VlanID != 0 {
+	if nw.Mode == opModeSriov {
+		epClient = NewSriovEndpointClient(nw.extIf, nw.Sriov, ep.HostIfName, ep.IfName)
+	} else if ep.VlanID != 0 || nw.isOVS() {
 		epInfo := ep.getInfo()
 		epClient = NewOVSEndpointClient(nw.extIf, epInfo, ep.HostIfName, "", ep.VlanID)
-	} else if nw.Mode != opModeTransparent {
+	} else if nw.Mode != opModeTransparent && nw.Mode != opModeOverlay {
 		epClient = NewLinuxBridgeEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode)
 	} else {
 		epClient = NewTransparentEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode)
 	}
 
 	epClient.DeleteEndpointRules(ep)
+	nw.deleteEndpointPolicyRules(ep.IPAddresses)
+	nw.deletePortMappings(ep.Id, ep.IPAddresses, ep.PortMappings)
+	nw.deleteEgressIPRules(ep.Id, ep.IPAddresses, ep.EgressIP, ep.EgressIPExclusions)
 	epClient.DeleteEndpoints(ep)
 
 	return nil
@@ -226,6 +390,13 @@ func (nw *network) deleteEndpointImpl(ep *endpoint) error {
 
 // getInfoImpl returns information about the endpoint.
 func (ep *endpoint) getInfoImpl(epInfo *EndpointInfo) {
+	if ep.LocalIP != "" {
+		epInfo.Data[LocalIPKey] = ep.LocalIP
+	}
+
+	if ep.SnatIP != nil {
+		epInfo.Data[SnatIPKey] = ep.SnatIP.String()
+	}
 }
 
 func addRoutes(interfaceName string, routes []RouteInfo) error {
@@ -250,7 +421,7 @@ func addRoutes(interfaceName string, routes []RouteInfo) error {
 		}
 
 		if err := netlink.AddIpRoute(nlRoute); err != nil {
-			if !strings.Contains(strings.ToLower(err.Error()), "file exists") {
+			if !netlink.IsExist(err) {
 				return err
 			} else {
 				log.Printf("[net] route already exists")
@@ -293,7 +464,11 @@ func deleteRoutes(interfaceName string, routes []RouteInfo) error {
 		}
 
 		if err := netlink.DeleteIpRoute(nlRoute); err != nil {
-			return err
+			if !netlink.IsNotExist(err) {
+				return err
+			}
+
+			log.Printf("[net] route already deleted")
 		}
 	}
 
@@ -0,0 +1,59 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"testing"
+)
+
+func TestGetEndpointInfoByHostIfName(t *testing.T) {
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {
+				Name: "eth0",
+				Networks: map[string]*network{
+					"azure": {Id: "azure", Endpoints: map[string]*endpoint{
+						"ep1": {Id: "ep1", HostIfName: "azv1"},
+						"ep2": {Id: "ep2", HostIfName: "azv2"},
+					}},
+					"empty": {Id: "empty", Endpoints: map[string]*endpoint{}},
+				},
+			},
+			"eth1": {
+				Name: "eth1",
+				Networks: map[string]*network{
+					"other": {Id: "other", Endpoints: map[string]*endpoint{
+						"ep3": {Id: "ep3", HostIfName: "azv3"},
+					}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		hostIfName string
+		networkId  string
+		endpointId string
+		err        error
+	}{
+		{hostIfName: "azv1", networkId: "azure", endpointId: "ep1"},
+		{hostIfName: "azv2", networkId: "azure", endpointId: "ep2"},
+		{hostIfName: "azv3", networkId: "other", endpointId: "ep3"},
+		{hostIfName: "azv4", err: errEndpointNotFound},
+		{hostIfName: "", err: errEndpointNotFound},
+	}
+
+	for _, test := range tests {
+		networkId, epInfo, err := nm.GetEndpointInfoByHostIfName(test.hostIfName)
+		if err != test.err {
+			t.Errorf("TestGetEndpointInfoByHostIfName failed @ %q: err %v, expected %v", test.hostIfName, err, test.err)
+			continue
+		}
+
+		if err == nil && (networkId != test.networkId || epInfo.Id != test.endpointId) {
+			t.Errorf("TestGetEndpointInfoByHostIfName failed @ %q: endpoint %v/%v, expected %v/%v",
+				test.hostIfName, networkId, epInfo.Id, test.networkId, test.endpointId)
+		}
+	}
+}
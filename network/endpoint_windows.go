@@ -5,6 +5,7 @@ package network
 
 import (
 	"encoding/json"
+	"fmt"
 	"net"
 	"strings"
 
@@ -15,7 +16,9 @@ import (
 
 // HotAttachEndpoint is a wrapper of hcsshim's HotAttachEndpoint.
 func (endpoint *EndpointInfo) HotAttachEndpoint(containerID string) error {
-	return hcsshim.HotAttachEndpoint(containerID, endpoint.Id)
+	return RetryHnsRequest("HotAttachEndpoint", func() error {
+		return hcsshim.HotAttachEndpoint(containerID, endpoint.Id)
+	})
 }
 
 // ConstructEndpointID constructs endpoint name from netNsPath.
@@ -42,10 +45,25 @@ func ConstructEndpointID(containerID string, netNsPath string, ifName string) (s
 	return infraEpName, workloadEpName
 }
 
+// resolveNamespace returns an error for network namespaces given by PID, as Windows processes have none.
+// The namespace path of Windows endpoints identifies the infrastructure container.
+func (epInfo *EndpointInfo) resolveNamespace() error {
+	if epInfo.NetNsPid != 0 {
+		return fmt.Errorf("%v: namespaces can't be given by PID on Windows", errNamespaceInvalid)
+	}
+
+	return nil
+}
+
 // newEndpointImpl creates a new endpoint in the network.
 func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 	var vlanid int
 
+	// HNS programs the outbound NAT of the endpoints.
+	if epInfo.EgressIP != nil {
+		return nil, errEgressIPNotSupported
+	}
+
 	if epInfo.Data != nil {
 		if _, ok := epInfo.Data[VlanIDKey]; ok {
 			vlanid = epInfo.Data[VlanIDKey].(int)
@@ -80,7 +98,18 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 
 	// Create the HNS endpoint.
 	log.Printf("[net] HNSEndpointRequest POST request:%+v", hnsRequest)
-	hnsResponse, err := hcsshim.HNSEndpointRequest("POST", "", hnsRequest)
+	var hnsResponse *hcsshim.HNSEndpoint
+	err = RetryHnsCreate("CreateEndpoint", func() (err error) {
+		hnsResponse, err = hcsshim.HNSEndpointRequest("POST", "", hnsRequest)
+		return err
+	}, func() bool {
+		existing, err := hcsshim.GetHNSEndpointByName(infraEpName)
+		if err != nil || existing == nil {
+			return false
+		}
+		hnsResponse = existing
+		return true
+	})
 	log.Printf("[net] HNSEndpointRequest POST response:%+v err:%v.", hnsResponse, err)
 	if err != nil {
 		return nil, err
@@ -96,7 +125,9 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 
 	// Attach the endpoint.
 	log.Printf("[net] Attaching endpoint %v to container %v.", hnsResponse.Id, epInfo.ContainerID)
-	err = hcsshim.HotAttachEndpoint(epInfo.ContainerID, hnsResponse.Id)
+	err = RetryHnsRequest("HotAttachEndpoint", func() error {
+		return hcsshim.HotAttachEndpoint(epInfo.ContainerID, hnsResponse.Id)
+	})
 	if err != nil {
 		log.Printf("[net] Failed to attach endpoint: %v.", err)
 		return nil, err
@@ -113,6 +144,7 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 		DNS:              epInfo.DNS,
 		VlanID:           vlanid,
 		EnableSnatOnHost: epInfo.EnableSnatOnHost,
+		PortMappings:     epInfo.PortMappings,
 	}
 
 	for _, route := range epInfo.Routes {
@@ -128,7 +160,39 @@ func (nw *network) newEndpointImpl(epInfo *EndpointInfo) (*endpoint, error) {
 func (nw *network) deleteEndpointImpl(ep *endpoint) error {
 	// Delete the HNS endpoint.
 	log.Printf("[net] HNSEndpointRequest DELETE id:%v", ep.HnsId)
-	hnsResponse, err := hcsshim.HNSEndpointRequest("DELETE", ep.HnsId, "")
+	var hnsResponse *hcsshim.HNSEndpoint
+	err := RetryHnsDelete("DeleteEndpoint", func() (err error) {
+		hnsResponse, err = hcsshim.HNSEndpointRequest("DELETE", ep.HnsId, "")
+		return err
+	})
+	log.Printf("[net] HNSEndpointRequest DELETE response:%+v err:%v.", hnsResponse, err)
+
+	return err
+}
+
+// cleanupEndpointImpl deletes the HNS endpoint left behind by an endpoint whose creation was interrupted.
+func (nw *network) cleanupEndpointImpl(epInfo *EndpointInfo) error {
+	infraEpName, _ := ConstructEndpointID(epInfo.ContainerID, epInfo.NetNsPath, epInfo.IfName)
+
+	hnsEndpoint, err := hcsshim.GetHNSEndpointByName(infraEpName)
+	if err != nil || hnsEndpoint == nil {
+		return nil
+	}
+
+	// Workload containers share the HNS endpoint of their infrastructure container.
+	for _, ep := range nw.Endpoints {
+		if ep.HnsId == hnsEndpoint.Id {
+			log.Printf("[net] HNS endpoint %v belongs to endpoint %v, not deleting it.", hnsEndpoint.Id, ep.Id)
+			return nil
+		}
+	}
+
+	log.Printf("[net] HNSEndpointRequest DELETE id:%v left behind by endpoint %v", hnsEndpoint.Id, epInfo.Id)
+	var hnsResponse *hcsshim.HNSEndpoint
+	err = RetryHnsDelete("DeleteEndpoint", func() (err error) {
+		hnsResponse, err = hcsshim.HNSEndpointRequest("DELETE", hnsEndpoint.Id, "")
+		return err
+	})
 	log.Printf("[net] HNSEndpointRequest DELETE response:%+v err:%v.", hnsResponse, err)
 
 	return err
@@ -143,3 +207,7 @@ func (ep *endpoint) getInfoImpl(epInfo *EndpointInfo) {
 func (nw *network) updateEndpointImpl(existingEpInfo *EndpointInfo, targetEpInfo *EndpointInfo) (*endpoint, error) {
 	return nil, nil
 }
+
+// announceEndpoint in windows does nothing, ARP configuration is not supported
+func (nw *network) announceEndpoint(ep *endpoint) {
+}
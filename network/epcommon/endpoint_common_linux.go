@@ -1,7 +1,6 @@
 package epcommon
 
 import (
-	"fmt"
 	"net"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -97,16 +96,15 @@ func AssignIPToInterface(interfaceName string, ipAddresses []net.IPNet) error {
 }
 
 func addOrDeleteFilterRule(bridgeName string, action string, ipAddress string, chainName string, target string) error {
-	var cmd string
-	option := "i"
+	var args []string
+	option := "-i"
 
 	if chainName == "OUTPUT" {
-		option = "o"
+		option = "-o"
 	}
 
 	if action != "D" {
-		cmd = fmt.Sprintf("iptables -t filter -C %v -%v %v -d %v -j %v", chainName, option, bridgeName, ipAddress, target)
-		_, err := platform.ExecuteCommand(cmd)
+		_, err := platform.ExecWithTimeout("iptables", "-t", "filter", "-C", chainName, option, bridgeName, "-d", ipAddress, "-j", target)
 		if err == nil {
 			log.Printf("Iptable filter for private ipaddr %v on %v chain %v target rule already exists", ipAddress, chainName, target)
 			return nil
@@ -114,13 +112,14 @@ func addOrDeleteFilterRule(bridgeName string, action string, ipAddress string, c
 	}
 
 	if target != "ACCEPT" {
-		cmd = fmt.Sprintf("iptables -t filter -%v %v -%v %v -d %v -j %v", action, chainName, option, bridgeName, ipAddress, target)
+		args = []string{"-t", "filter", "-" + action, chainName}
 	} else {
 		action = "I"
-		cmd = fmt.Sprintf("iptables -t filter -%v %v 1 -%v %v -d %v -j %v", action, chainName, option, bridgeName, ipAddress, target)
+		args = []string{"-t", "filter", "-" + action, chainName, "1"}
 	}
 
-	_, err := platform.ExecuteCommand(cmd)
+	args = append(args, option, bridgeName, "-d", ipAddress, "-j", target)
+	_, err := platform.ExecWithTimeout("iptables", args...)
 	if err != nil {
 		log.Printf("Iptable filter %v action for private ipaddr %v on %v chain %v target failed with %v", action, ipAddress, chainName, target, err)
 		return err
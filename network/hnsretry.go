@@ -0,0 +1,188 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// Kinds of transient HNS errors.
+const (
+	// HNS error 0x803b0003, returned while HNS is busy updating its state.
+	HnsErrorKindBusy = "Busy"
+
+	// The ports of the host available to endpoints are exhausted until other endpoints release theirs.
+	HnsErrorKindPortExhaustion = "PortExhaustion"
+
+	// The HNS service is restarting and doesn't answer RPCs.
+	HnsErrorKindServiceRestarting = "ServiceRestarting"
+)
+
+const (
+	// Retries of an HNS request failing with a transient error.
+	hnsMaxRetries = 4
+
+	// Delay before the first retry, doubled for each following retry up to hnsMaxRetryDelay.
+	hnsRetryDelay    = 500 * time.Millisecond
+	hnsMaxRetryDelay = 4 * time.Second
+)
+
+// Messages of the HNS errors returned for objects that don't exist, matched case insensitively.
+var hnsNotFoundErrors = []string{"0x80070490", "not found"}
+
+// Messages of the transient HNS errors by kind, matched case insensitively.
+var hnsTransientErrors = []struct {
+	kind     string
+	messages []string
+}{
+	{HnsErrorKindBusy, []string{"0x803b0003"}},
+	{HnsErrorKindPortExhaustion, []string{"port exhaustion", "ports are exhausted", "no available ports"}},
+	{HnsErrorKindServiceRestarting, []string{
+		"0x800706ba", "rpc server is unavailable",
+		"0x800706be", "remote procedure call failed",
+		"0x80070425", "service cannot accept control messages",
+		"0x80070426", "service has not been started",
+	}},
+}
+
+// Clock of the delays between retries.
+var hnsRetryClock = platform.NewClock()
+
+// HnsRetry describes an HNS request that failed with a transient error and was retried.
+type HnsRetry struct {
+	Operation string
+	ErrorKind string
+	Retries   int
+	Succeeded bool
+	Error     string // Last error, if the request didn't succeed.
+}
+
+var (
+	// HNS requests retried since the last call to TakeHnsRetries.
+	hnsRetries     []HnsRetry
+	hnsRetriesLock sync.Mutex
+)
+
+// recordHnsRetry remembers a retried HNS request until it is reported.
+func recordHnsRetry(retry HnsRetry) {
+	hnsRetriesLock.Lock()
+	defer hnsRetriesLock.Unlock()
+
+	hnsRetries = append(hnsRetries, retry)
+}
+
+// TakeHnsRetries returns the HNS requests retried since the last call, e.g. during a CNI command, and forgets them.
+// HNS requests are only made on Windows.
+func TakeHnsRetries() []HnsRetry {
+	hnsRetriesLock.Lock()
+	defer hnsRetriesLock.Unlock()
+
+	retries := hnsRetries
+	hnsRetries = nil
+	return retries
+}
+
+// classifyHnsError returns the kind of a transient HNS error, or an empty string if retrying won't help.
+func classifyHnsError(err error) string {
+	msg := strings.ToLower(err.Error())
+	for _, transient := range hnsTransientErrors {
+		for _, m := range transient.messages {
+			if strings.Contains(msg, m) {
+				return transient.kind
+			}
+		}
+	}
+
+	return ""
+}
+
+// isHnsNotFoundError checks if an HNS request failed because its object doesn't exist.
+func isHnsNotFoundError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, m := range hnsNotFoundErrors {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetryHnsRequest makes an HNS request, retrying it with backoff while it fails with a transient error.
+// The last error is returned unchanged, so that it is classified like an error of a request made once.
+// Retried requests are recorded for TakeHnsRetries.
+func RetryHnsRequest(operation string, request func() error) error {
+	return retryHnsRequest(operation, request, nil)
+}
+
+// RetryHnsCreate makes an HNS request creating an object, like RetryHnsRequest.
+// A request failing with a transient error may still have created its object, so before each retry lookup
+// is called to find the object by name. If it is found, the object isn't created twice and the request succeeds.
+func RetryHnsCreate(operation string, create func() error, lookup func() bool) error {
+	return retryHnsRequest(operation, create, lookup)
+}
+
+// RetryHnsDelete makes an HNS request deleting an object, like RetryHnsRequest.
+// The request succeeds if the object is not found, e.g. because a failed attempt deleted it before the retry.
+func RetryHnsDelete(operation string, del func() error) error {
+	return retryHnsRequest(operation, func() error {
+		err := del()
+		if err != nil && isHnsNotFoundError(err) {
+			log.Printf("[net] HNS request %v found no object to delete, err:%v.", operation, err)
+			return nil
+		}
+		return err
+	}, nil)
+}
+
+// retryHnsRequest retries a request while it fails with a transient error, calling lookup, if not nil,
+// before each retry to check if the failed attempt already did what the request does.
+func retryHnsRequest(operation string, request func() error, lookup func() bool) error {
+	var (
+		err   error
+		kind  string
+		delay = hnsRetryDelay
+	)
+
+	for retries := 0; ; retries++ {
+		if retries > 0 && lookup != nil && lookup() {
+			log.Printf("[net] HNS request %v found the object created by a failed attempt after %d retries.", operation, retries)
+			recordHnsRetry(HnsRetry{Operation: operation, ErrorKind: kind, Retries: retries, Succeeded: true})
+			return nil
+		}
+
+		if err = request(); err == nil {
+			if retries > 0 {
+				log.Printf("[net] HNS request %v succeeded after %d retries.", operation, retries)
+				recordHnsRetry(HnsRetry{Operation: operation, ErrorKind: kind, Retries: retries, Succeeded: true})
+			}
+			return nil
+		}
+
+		transient := classifyHnsError(err)
+		if transient != "" {
+			kind = transient
+		}
+
+		if transient == "" || retries == hnsMaxRetries {
+			if retries > 0 {
+				log.Printf("[net] HNS request %v failed after %d retries, err:%v.", operation, retries, err)
+				recordHnsRetry(HnsRetry{Operation: operation, ErrorKind: kind, Retries: retries, Error: err.Error()})
+			}
+			return err
+		}
+
+		log.Printf("[net] HNS request %v failed with transient error %v, retrying in %v, err:%v.", operation, kind, delay, err)
+		hnsRetryClock.Sleep(delay)
+
+		if delay *= 2; delay > hnsMaxRetryDelay {
+			delay = hnsMaxRetryDelay
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// Makes the HNS retries sleep on a fake clock, until the returned function restores the real one.
+func setHnsRetryClock() (*platform.FakeClock, func()) {
+	saved := hnsRetryClock
+	clock := platform.NewFakeClock(time.Unix(0, 0))
+	hnsRetryClock = clock
+	TakeHnsRetries()
+
+	return clock, func() {
+		hnsRetryClock = saved
+		TakeHnsRetries()
+	}
+}
+
+var (
+	errHnsBusy     = errors.New("HNS failed with error : 0x803b0003")
+	errHnsNotFound = errors.New("HNS failed with error : Element not found.")
+	errHnsInvalid  = errors.New("HNS failed with error : The parameter is incorrect.")
+)
+
+func TestRetryHnsRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		errors    []error
+		succeeded bool
+		attempts  int
+		delay     time.Duration
+		retries   []HnsRetry
+	}{
+		{
+			name:      "succeeded",
+			succeeded: true,
+			attempts:  1,
+		},
+		{
+			name:     "permanent error",
+			errors:   []error{errHnsInvalid},
+			attempts: 1,
+		},
+		{
+			name:      "transient error",
+			errors:    []error{errHnsBusy, errHnsBusy},
+			succeeded: true,
+			attempts:  3,
+			delay:     1500 * time.Millisecond,
+			retries:   []HnsRetry{{Operation: "test", ErrorKind: HnsErrorKindBusy, Retries: 2, Succeeded: true}},
+		},
+		{
+			name:     "transient then permanent error",
+			errors:   []error{errHnsBusy, errHnsInvalid},
+			attempts: 2,
+			delay:    500 * time.Millisecond,
+			retries:  []HnsRetry{{Operation: "test", ErrorKind: HnsErrorKindBusy, Retries: 1, Error: errHnsInvalid.Error()}},
+		},
+		{
+			name:     "retries exhausted",
+			errors:   []error{errHnsBusy, errHnsBusy, errHnsBusy, errHnsBusy, errHnsBusy, errHnsBusy},
+			attempts: hnsMaxRetries + 1,
+			delay:    7500 * time.Millisecond,
+			retries: []HnsRetry{{
+				Operation: "test", ErrorKind: HnsErrorKindBusy, Retries: hnsMaxRetries, Error: errHnsBusy.Error(),
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		clock, restore := setHnsRetryClock()
+
+		attempts := 0
+		err := RetryHnsRequest("test", func() error {
+			attempts++
+			if attempts <= len(test.errors) {
+				return test.errors[attempts-1]
+			}
+			return nil
+		})
+
+		if (err == nil) != test.succeeded {
+			t.Errorf("%v: request returned err:%v", test.name, err)
+		}
+
+		if attempts != test.attempts {
+			t.Errorf("%v: request attempted %d times, expected %d", test.name, attempts, test.attempts)
+		}
+
+		if delay := clock.Since(time.Unix(0, 0)); delay != test.delay {
+			t.Errorf("%v: retries waited %v, expected %v", test.name, delay, test.delay)
+		}
+
+		retries := TakeHnsRetries()
+		if len(retries) != len(test.retries) || (len(retries) > 0 && retries[0] != test.retries[0]) {
+			t.Errorf("%v: recorded retries %+v, expected %+v", test.name, retries, test.retries)
+		}
+
+		restore()
+	}
+}
+
+// Tests that a create request isn't retried once its object is found.
+func TestRetryHnsCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		created   bool // Whether the failed attempt created the object.
+		succeeded bool
+		creates   int
+		lookups   int
+	}{
+		{name: "created by the failed attempt", created: true, succeeded: true, creates: 1, lookups: 1},
+		{name: "not created by the failed attempt", succeeded: true, creates: 2, lookups: 1},
+	}
+
+	for _, test := range tests {
+		_, restore := setHnsRetryClock()
+
+		creates, lookups := 0, 0
+		err := RetryHnsCreate("test", func() error {
+			creates++
+			if creates == 1 {
+				return errHnsBusy
+			}
+			return nil
+		}, func() bool {
+			lookups++
+			return test.created
+		})
+
+		if (err == nil) != test.succeeded {
+			t.Errorf("%v: request returned err:%v", test.name, err)
+		}
+
+		if creates != test.creates || lookups != test.lookups {
+			t.Errorf("%v: created %d times and looked up %d times, expected %d and %d",
+				test.name, creates, lookups, test.creates, test.lookups)
+		}
+
+		restore()
+	}
+
+	// The object is not looked up before the first attempt.
+	_, restore := setHnsRetryClock()
+	defer restore()
+
+	err := RetryHnsCreate("test", func() error { return nil }, func() bool {
+		t.Errorf("Object looked up before the first attempt")
+		return true
+	})
+	if err != nil {
+		t.Errorf("Create request failed: %v", err)
+	}
+}
+
+// Tests that a delete request succeeds when its object is not found.
+func TestRetryHnsDelete(t *testing.T) {
+	tests := []struct {
+		name      string
+		errors    []error
+		succeeded bool
+		attempts  int
+	}{
+		{name: "deleted", succeeded: true, attempts: 1},
+		{name: "not found", errors: []error{errHnsNotFound}, succeeded: true, attempts: 1},
+		{name: "deleted by the failed attempt", errors: []error{errHnsBusy, errHnsNotFound}, succeeded: true, attempts: 2},
+		{name: "permanent error", errors: []error{errHnsInvalid}, attempts: 1},
+	}
+
+	for _, test := range tests {
+		_, restore := setHnsRetryClock()
+
+		attempts := 0
+		err := RetryHnsDelete("test", func() error {
+			attempts++
+			if attempts <= len(test.errors) {
+				return test.errors[attempts-1]
+			}
+			return nil
+		})
+
+		if (err == nil) != test.succeeded {
+			t.Errorf("%v: request returned err:%v", test.name, err)
+		}
+
+		if attempts != test.attempts {
+			t.Errorf("%v: request attempted %d times, expected %d", test.name, attempts, test.attempts)
+		}
+
+		restore()
+	}
+}
+
+func TestClassifyHnsError(t *testing.T) {
+	tests := []struct {
+		err  string
+		kind string
+	}{
+		{err: "HNS failed with error : 0x803B0003", kind: HnsErrorKindBusy},
+		{err: "No available ports for the endpoint", kind: HnsErrorKindPortExhaustion},
+		{err: "The RPC server is unavailable. (0x800706ba)", kind: HnsErrorKindServiceRestarting},
+		{err: "The parameter is incorrect.", kind: ""},
+	}
+
+	for _, test := range tests {
+		if kind := classifyHnsError(errors.New(test.err)); kind != test.kind {
+			t.Errorf("Error %q classified as %q, expected %q", test.err, kind, test.kind)
+		}
+	}
+}
@@ -18,6 +18,9 @@ const (
 	storeKey    = "Network"
 	VlanIDKey   = "VlanID"
 	genericData = "com.docker.network.generic"
+
+	// Interval between reconciliations of the L2 rules with the state.
+	l2RulesReconcileInterval = 5 * time.Minute
 )
 
 type NetworkClient interface {
@@ -42,9 +45,12 @@ type EndpointClient interface {
 // NetworkManager manages the set of container networking resources.
 type networkManager struct {
 	Version            string
+	SchemaVersion      int
 	TimeStamp          time.Time
 	ExternalInterfaces map[string]*externalInterface
-	store              store.KeyValueStore
+	// Time the L2 rules were last reconciled with the state.
+	L2RulesReconciledAt time.Time
+	store               store.KeyValueStore
 	sync.Mutex
 }
 
@@ -61,12 +67,27 @@ type NetworkManager interface {
 
 	CreateEndpoint(networkId string, epInfo *EndpointInfo) error
 	DeleteEndpoint(networkId string, endpointId string) error
+	CleanupEndpoint(networkId string, epInfo *EndpointInfo) error
 	GetEndpointInfo(networkId string, endpointId string) (*EndpointInfo, error)
 	GetEndpointInfoBasedOnPODDetails(networkId string, podName string, podNameSpace string) (*EndpointInfo, error)
+	GetEndpointInfoByHostIfName(hostIfName string) (string, *EndpointInfo, error)
 	AttachEndpoint(networkId string, endpointId string, sandboxKey string) (*endpoint, error)
 	DetachEndpoint(networkId string, endpointId string) error
 	UpdateEndpoint(networkId string, existingEpInfo *EndpointInfo, targetEpInfo *EndpointInfo) error
+	MirrorEndpoint(networkId string, endpointId string, mirror *MirrorInfo) error
+	StopEndpointMirroring(networkId string, endpointId string) error
 	GetNumberOfEndpoints(ifName string, networkId string) int
+
+	PrepareWarmEndpoint(networkId string, warmEpInfo *WarmEndpointInfo) (*WarmEndpointInfo, error)
+	AddWarmEndpoint(networkId string, warmEpInfo *WarmEndpointInfo) error
+	DiscardWarmEndpoint(networkId string, warmEpInfo *WarmEndpointInfo) error
+	DeleteWarmEndpoint(networkId string, warmEndpointId string) error
+	GetWarmEndpoints(networkId string) ([]*WarmEndpointInfo, error)
+
+	UpdateOverlayRoutes(networkId string, routes []OverlayRoute) error
+
+	ReconcileEndpointRoutes(networkId string, endpointId string) ([]RouteDrift, error)
+	RunRouteReconciler(interval time.Duration, stopCh <-chan struct{}, report func([]RouteDrift))
 }
 
 // Creates a new network manager.
@@ -105,7 +126,8 @@ func (nm *networkManager) restore() error {
 	// Ignore the persisted state if it is older than the last reboot time.
 
 	// Read any persisted state.
-	err := nm.store.Read(storeKey, nm)
+	var state map[string]interface{}
+	err := nm.store.Read(storeKey, &state)
 	if err != nil {
 		if err == store.ErrKeyNotFound {
 			log.Printf("[net] network store key not found")
@@ -117,8 +139,22 @@ func (nm *networkManager) restore() error {
 		}
 	}
 
-	modTime, err := nm.store.GetModificationTime()
-	if err == nil {
+	// Get the modification time before migrating the state, which writes a backup to the store.
+	modTime, modTimeErr := nm.store.GetModificationTime()
+
+	// Upgrade state persisted by older versions.
+	if err = nm.migrateState(state); err != nil {
+		log.Printf("[net] Failed to migrate state, err:%v\n", err)
+		return err
+	}
+
+	err = nm.decodeState(state)
+	if err != nil {
+		log.Printf("[net] Failed to restore state, err:%v\n", err)
+		return err
+	}
+
+	if modTimeErr == nil {
 		rebootTime, err := platform.GetLastRebootTime()
 		log.Printf("[net] reboot time %v store mod time %v", rebootTime, modTime)
 		if err == nil && rebootTime.After(modTime) {
@@ -169,6 +205,9 @@ func (nm *networkManager) restore() error {
 
 				extIf.BridgeName = ""
 
+				// The interfaces of warm endpoints did not survive the reboot.
+				nw.WarmEndpoints = nil
+
 				_, err = nm.newNetworkImpl(nwInfo, extIf)
 				if err != nil {
 					log.Printf("[net] Restoring network failed for nwInfo %v extif %v. This should not happen %v", nwInfo, extIf, err)
@@ -178,6 +217,26 @@ func (nm *networkManager) restore() error {
 		}
 	}
 
+	// Restore the ebtables rules other agents deleted, and delete those cleanup missed. Listing the rules is costly,
+	// so they are reconciled at most once per interval.
+	changed := false
+	if rebooted || time.Since(nm.L2RulesReconciledAt) >= l2RulesReconcileInterval {
+		nm.reconcileL2Rules()
+		nm.L2RulesReconciledAt = time.Now()
+		changed = true
+	}
+
+	// Stop the mirroring left behind by the processes that should have stopped it.
+	if nm.stopExpiredMirroring(time.Now()) {
+		changed = true
+	}
+
+	if changed {
+		if err := nm.save(); err != nil {
+			log.Printf("[net] Failed to save state after reconciling it, err:%v.", err)
+		}
+	}
+
 	log.Printf("[net] Restored state, %+v\n", nm)
 	for _, extIf := range nm.ExternalInterfaces {
 		log.Printf("External Interface %+v", extIf)
@@ -201,6 +260,7 @@ func (nm *networkManager) save() error {
 
 	// Update time stamp.
 	nm.TimeStamp = time.Now()
+	nm.SchemaVersion = stateSchemaVersion
 
 	err := nm.store.Write(storeKey, nm)
 	if err == nil {
@@ -282,15 +342,24 @@ func (nm *networkManager) GetNetworkInfo(networkId string) (*NetworkInfo, error)
 	}
 
 	nwInfo := &NetworkInfo{
-		Id:      networkId,
-		Subnets: nw.Subnets,
-		Mode:    nw.Mode,
-		Options: make(map[string]interface{}),
+		Id:               networkId,
+		Subnets:          nw.Subnets,
+		Mode:             nw.Mode,
+		EnableSnatOnHost: nw.EnableSnatOnHost,
+		Dataplane:        nw.Dataplane,
+		Sriov:            nw.Sriov,
+		SnatIPBlock:      nw.SnatIPBlock,
+		PolicyRouting:    nw.PolicyRouting,
+		Nat64:            nw.Nat64,
+		Overlay:          nw.Overlay,
+		OverlayRoutes:    nw.OverlayRoutes,
+		Options:          make(map[string]interface{}),
 	}
 
 	getNetworkInfoImpl(nwInfo, nw)
 
 	if nw.extIf != nil {
+		nwInfo.MasterIfName = nw.extIf.Name
 		nwInfo.BridgeName = nw.extIf.BridgeName
 	}
 
@@ -307,6 +376,14 @@ func (nm *networkManager) CreateEndpoint(networkId string, epInfo *EndpointInfo)
 		return err
 	}
 
+	if err = nm.validatePortMappings(epInfo); err != nil {
+		return err
+	}
+
+	if err = nw.validateEgressIP(epInfo); err != nil {
+		return err
+	}
+
 	if nw.VlanId != 0 {
 		if epInfo.Data[VlanIDKey] == nil {
 			log.Printf("overriding endpoint vlanid with network vlanid")
@@ -316,6 +393,10 @@ func (nm *networkManager) CreateEndpoint(networkId string, epInfo *EndpointInfo)
 
 	_, err = nw.newEndpoint(epInfo)
 	if err != nil {
+		// Persist the removal of a warm endpoint that failed to bind.
+		if _, ok := epInfo.Data[WarmEndpointKey]; ok {
+			nm.save()
+		}
 		return err
 	}
 
@@ -350,6 +431,19 @@ func (nm *networkManager) DeleteEndpoint(networkId string, endpointId string) er
 	return nil
 }
 
+// CleanupEndpoint deletes the interfaces left behind by an endpoint whose creation was interrupted.
+func (nm *networkManager) CleanupEndpoint(networkId string, epInfo *EndpointInfo) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return err
+	}
+
+	return nw.cleanupEndpoint(epInfo)
+}
+
 // GetEndpointInfo returns information about the given endpoint.
 func (nm *networkManager) GetEndpointInfo(networkId string, endpointId string) (*EndpointInfo, error) {
 	nm.Lock()
@@ -387,6 +481,23 @@ func (nm *networkManager) GetEndpointInfoBasedOnPODDetails(networkID string, pod
 	return ep.getInfo(), nil
 }
 
+// GetEndpointInfoByHostIfName returns the ID of the network and information about the endpoint
+// whose host interface has the given name, for tools mapping interfaces seen on the node to containers.
+func (nm *networkManager) GetEndpointInfoByHostIfName(hostIfName string) (string, *EndpointInfo, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			if ep, err := nw.getEndpointByHostIfName(hostIfName); err == nil {
+				return nw.Id, ep.getInfo(), nil
+			}
+		}
+	}
+
+	return "", nil, errEndpointNotFound
+}
+
 // AttachEndpoint attaches an endpoint to a sandbox.
 func (nm *networkManager) AttachEndpoint(networkId string, endpointId string, sandboxKey string) (*endpoint, error) {
 	nm.Lock()
@@ -488,3 +599,133 @@ func (nm *networkManager) GetNumberOfEndpoints(ifName string, networkId string)
 
 	return 0
 }
+
+// PrepareWarmEndpoint creates the interfaces of a warm endpoint with the given address in the network, without
+// adding it to the network. The state is left unchanged, so the store doesn't have to be locked.
+func (nm *networkManager) PrepareWarmEndpoint(networkId string, warmEpInfo *WarmEndpointInfo) (*WarmEndpointInfo, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return nil, err
+	}
+
+	warmEp, err := nw.newWarmEndpoint(warmEpInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	prepared := warmEp.getInfo()
+	prepared.prepared = warmEp
+
+	return prepared, nil
+}
+
+// AddWarmEndpoint adds a warm endpoint prepared by PrepareWarmEndpoint to the network.
+func (nm *networkManager) AddWarmEndpoint(networkId string, warmEpInfo *WarmEndpointInfo) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	if warmEpInfo.prepared == nil {
+		return errWarmEndpointNotFound
+	}
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return err
+	}
+
+	err = nw.addWarmEndpoint(warmEpInfo.prepared)
+	if err != nil {
+		return err
+	}
+
+	err = nm.save()
+	if err != nil {
+		delete(nw.WarmEndpoints, warmEpInfo.Id)
+		return err
+	}
+
+	return nil
+}
+
+// DiscardWarmEndpoint deletes the interfaces of a warm endpoint prepared by PrepareWarmEndpoint that could not be
+// added to the network.
+func (nm *networkManager) DiscardWarmEndpoint(networkId string, warmEpInfo *WarmEndpointInfo) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	if warmEpInfo.prepared == nil {
+		return errWarmEndpointNotFound
+	}
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[net] Discarding warm endpoint %v of network %v.", warmEpInfo.Id, networkId)
+
+	return nw.deleteWarmEndpointImpl(warmEpInfo.prepared)
+}
+
+// DeleteWarmEndpoint deletes a warm endpoint from the network.
+func (nm *networkManager) DeleteWarmEndpoint(networkId string, warmEndpointId string) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return err
+	}
+
+	err = nw.deleteWarmEndpoint(warmEndpointId)
+	if err == errWarmEndpointNotFound {
+		return err
+	} else if err != nil {
+		log.Printf("[net] Failed to delete warm endpoint %v, err:%v.", warmEndpointId, err)
+	}
+
+	return nm.save()
+}
+
+// GetWarmEndpoints returns the warm endpoints of the network, oldest first.
+func (nm *networkManager) GetWarmEndpoints(networkId string) ([]*WarmEndpointInfo, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return nil, err
+	}
+
+	return nw.getWarmEndpoints(), nil
+}
+
+// UpdateOverlayRoutes replaces the routes to the pod subnets of the other nodes of an overlay network.
+func (nm *networkManager) UpdateOverlayRoutes(networkId string, routes []OverlayRoute) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return err
+	}
+
+	if nw.Overlay == nil {
+		return errOverlayNotSupported
+	}
+
+	applied := nw.OverlayRoutes
+	if err = nw.updateOverlayRoutesImpl(routes); err != nil {
+		return err
+	}
+
+	// The routes are reprogrammed periodically, only changes are saved.
+	if overlayRoutesEqual(applied, nw.OverlayRoutes) {
+		return nil
+	}
+
+	return nm.save()
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Schema version of the persisted network manager state.
+	// State written before the schema was versioned is version 1.
+	stateSchemaVersion = 2
+
+	// Field of the persisted state holding its schema version.
+	schemaVersionKey = "SchemaVersion"
+)
+
+// stateMigration upgrades persisted state from one schema version to the next.
+// Migrations operate on the raw decoded JSON, so they can handle state that no longer parses into the current types.
+type stateMigration func(state map[string]interface{}) error
+
+// stateMigrations maps each schema version to the migration upgrading it to the next version.
+var stateMigrations = map[int]stateMigration{
+	1: migrateStateV1,
+}
+
+// getSchemaVersion returns the schema version of persisted state.
+func getSchemaVersion(state map[string]interface{}) int {
+	// JSON numbers decode to float64, migrated state holds an int.
+	switch version := state[schemaVersionKey].(type) {
+	case float64:
+		return int(version)
+	case int:
+		return version
+	}
+
+	return 1
+}
+
+// getStateBackupKey returns the store key of the backup of state with the given schema version.
+func getStateBackupKey(version int) string {
+	return fmt.Sprintf("%s.v%d", storeKey, version)
+}
+
+// migrateState upgrades persisted state to the current schema version, in place.
+// The state is backed up to the store first, so endpoints can be recovered if a migration fails.
+// The migrated state is not written back, the store keeps the format of the version that wrote it
+// until the state is saved with the next change.
+func (nm *networkManager) migrateState(state map[string]interface{}) error {
+	version := getSchemaVersion(state)
+	if state == nil || version == stateSchemaVersion {
+		return nil
+	}
+
+	backupKey := getStateBackupKey(version)
+	if err := nm.store.Write(backupKey, state); err != nil {
+		log.Printf("[net] Failed to back up state to %s, err:%v\n", backupKey, err)
+		return err
+	}
+
+	log.Printf("[net] Backed up state schema version %d to %s.\n", version, backupKey)
+
+	if version > stateSchemaVersion {
+		// State written by a newer version before a downgrade. Its unknown fields are dropped when the state is saved
+		// with the current schema version, which the newer version migrates again after an upgrade.
+		log.Printf("[net] State schema version %d is newer than supported version %d.\n", version, stateSchemaVersion)
+		return nil
+	}
+
+	for ; version < stateSchemaVersion; version++ {
+		migrate, ok := stateMigrations[version]
+		if !ok {
+			return fmt.Errorf("No migration from state schema version %d", version)
+		}
+
+		if err := migrate(state); err != nil {
+			log.Printf("[net] Failed to migrate state from schema version %d, err:%v\n", version, err)
+			return err
+		}
+
+		log.Printf("[net] Migrated state from schema version %d to %d.\n", version, version+1)
+	}
+
+	state[schemaVersionKey] = stateSchemaVersion
+
+	return nil
+}
+
+// decodeState decodes the network manager from persisted state.
+func (nm *networkManager) decodeState(state map[string]interface{}) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, nm)
+}
+
+// getStateMap returns the JSON object stored under the given key, replacing missing or null values with an empty object.
+func getStateMap(state map[string]interface{}, key string) (map[string]interface{}, error) {
+	switch value := state[key].(type) {
+	case map[string]interface{}:
+		return value, nil
+	case nil:
+		m := make(map[string]interface{})
+		state[key] = m
+		return m, nil
+	default:
+		return nil, fmt.Errorf("Invalid state field %s of type %T", key, value)
+	}
+}
+
+// migrateStateV1 upgrades unversioned state, which may hold null network and endpoint maps
+// for interfaces and networks persisted while they were empty.
+func migrateStateV1(state map[string]interface{}) error {
+	extIfs, err := getStateMap(state, "ExternalInterfaces")
+	if err != nil {
+		return err
+	}
+
+	for extIfName := range extIfs {
+		extIf, ok := extIfs[extIfName].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Invalid state of external interface %s", extIfName)
+		}
+
+		networks, err := getStateMap(extIf, "Networks")
+		if err != nil {
+			return err
+		}
+
+		for nwId := range networks {
+			nw, ok := networks[nwId].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("Invalid state of network %s", nwId)
+			}
+
+			if _, err := getStateMap(nw, "Endpoints"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
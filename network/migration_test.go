@@ -0,0 +1,129 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/store"
+)
+
+// Returns state decoded from JSON the way the network manager reads it from the store.
+func newTestState(t *testing.T, text string) map[string]interface{} {
+	var state map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &state); err != nil {
+		t.Fatalf("Invalid test state %v: %v", text, err)
+	}
+
+	return state
+}
+
+func TestMigrateState(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     string
+		version   int
+		backupKey string
+		valid     bool
+	}{
+		{
+			name:      "unversioned with null maps",
+			state:     `{"ExternalInterfaces":{"eth0":{"Name":"eth0","Networks":{"azure":{"Id":"azure","Endpoints":null}}}}}`,
+			version:   stateSchemaVersion,
+			backupKey: "Network.v1",
+			valid:     true,
+		},
+		{
+			name:      "unversioned without interfaces",
+			state:     `{"ExternalInterfaces":null}`,
+			version:   stateSchemaVersion,
+			backupKey: "Network.v1",
+			valid:     true,
+		},
+		{
+			name:    "current",
+			state:   `{"SchemaVersion":2,"ExternalInterfaces":{}}`,
+			version: stateSchemaVersion,
+			valid:   true,
+		},
+		{
+			name:      "newer after a downgrade",
+			state:     `{"SchemaVersion":9,"ExternalInterfaces":{},"Unknown":true}`,
+			version:   9,
+			backupKey: "Network.v9",
+			valid:     true,
+		},
+		{
+			name:      "invalid network",
+			state:     `{"ExternalInterfaces":{"eth0":{"Networks":{"azure":1}}}}`,
+			backupKey: "Network.v1",
+			valid:     false,
+		},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "migration")
+		if err != nil {
+			t.Fatalf("Failed to create temporary directory: %v", err)
+		}
+
+		kvs, err := store.NewJsonFileStore(dir + "/azure-vnet.json")
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+
+		nm := &networkManager{store: kvs}
+		state := newTestState(t, test.state)
+
+		err = nm.migrateState(state)
+		if (err == nil) != test.valid {
+			t.Errorf("TestMigrateState failed @ %v: migrateState returned %v, expected valid:%v", test.name, err, test.valid)
+		}
+
+		// The state is backed up whenever its version differs, and never rewritten.
+		var backup map[string]interface{}
+		if test.backupKey != "" {
+			if err := kvs.Read(test.backupKey, &backup); err != nil || !jsonEqual(backup, newTestState(t, test.state)) {
+				t.Errorf("TestMigrateState failed @ %v: backup %v, err %v", test.name, backup, err)
+			}
+		} else if err := kvs.Read("Network.v1", &backup); err != store.ErrKeyNotFound {
+			t.Errorf("TestMigrateState failed @ %v: unexpected backup", test.name)
+		}
+
+		var stored map[string]interface{}
+		if err := kvs.Read(storeKey, &stored); err != store.ErrKeyNotFound {
+			t.Errorf("TestMigrateState failed @ %v: state rewritten while migrating", test.name)
+		}
+
+		if test.valid {
+			if version := getSchemaVersion(state); version != test.version {
+				t.Errorf("TestMigrateState failed @ %v: version %v, expected %v", test.name, version, test.version)
+			}
+
+			if err := nm.decodeState(state); err != nil {
+				t.Errorf("TestMigrateState failed @ %v: decodeState returned %v", test.name, err)
+			}
+
+			for _, extIf := range nm.ExternalInterfaces {
+				for _, nw := range extIf.Networks {
+					if nw.Endpoints == nil {
+						t.Errorf("TestMigrateState failed @ %v: network %v without endpoint map", test.name, nw.Id)
+					}
+				}
+			}
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+// Returns whether two decoded JSON values are equal.
+func jsonEqual(a interface{}, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Longest mirroring of an endpoint, so that forgotten captures don't copy traffic forever.
+	MaxMirrorDuration = time.Hour
+)
+
+// MirrorInfo describes the mirroring of the traffic of an endpoint, in both directions, to a local capture interface
+// until a deadline. Mirroring is stopped by the caller once the deadline passed.
+type MirrorInfo struct {
+	Interface string
+	Until     time.Time
+}
+
+// Returns an error if mirroring can't be started now.
+func (mirror *MirrorInfo) validate(now time.Time) error {
+	if mirror.Interface == "" {
+		return fmt.Errorf("%v: capture interface is required", errMirrorInvalid)
+	}
+
+	if !mirror.Until.After(now) || mirror.Until.Sub(now) > MaxMirrorDuration {
+		return fmt.Errorf("%v: duration must be positive and at most %v", errMirrorInvalid, MaxMirrorDuration)
+	}
+
+	return nil
+}
+
+// startMirroring mirrors the traffic of an endpoint, replacing its previous mirroring.
+func (nw *network) startMirroring(ep *endpoint, mirror *MirrorInfo) error {
+	if err := mirror.validate(time.Now()); err != nil {
+		return err
+	}
+
+	log.Printf("[net] Mirroring endpoint %v to %v until %v.", ep.Id, mirror.Interface, mirror.Until)
+	if err := nw.startMirroringImpl(ep, mirror); err != nil {
+		return err
+	}
+
+	ep.Mirror = mirror
+	return nil
+}
+
+// stopMirroring stops mirroring the traffic of an endpoint.
+func (nw *network) stopMirroring(ep *endpoint) error {
+	if ep.Mirror == nil {
+		return nil
+	}
+
+	log.Printf("[net] Stopping mirroring of endpoint %v to %v.", ep.Id, ep.Mirror.Interface)
+	if err := nw.stopMirroringImpl(ep); err != nil {
+		return err
+	}
+
+	ep.Mirror = nil
+	return nil
+}
+
+// MirrorEndpoint starts mirroring the traffic of an endpoint to a capture interface until the deadline of mirror.
+func (nm *networkManager) MirrorEndpoint(networkId string, endpointId string, mirror *MirrorInfo) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return err
+	}
+
+	ep, err := nw.getEndpoint(endpointId)
+	if err != nil {
+		return err
+	}
+
+	if err = nw.startMirroring(ep, mirror); err != nil {
+		return err
+	}
+
+	return nm.save()
+}
+
+// StopEndpointMirroring stops mirroring the traffic of an endpoint.
+func (nm *networkManager) StopEndpointMirroring(networkId string, endpointId string) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return err
+	}
+
+	ep, err := nw.getEndpoint(endpointId)
+	if err != nil {
+		return err
+	}
+
+	if err = nw.stopMirroring(ep); err != nil {
+		return err
+	}
+
+	return nm.save()
+}
+
+// stopExpiredMirroring stops the mirroring of the endpoints whose deadline passed, e.g. because the process stopping
+// it was killed or the node rebooted. It returns whether the state changed.
+func (nm *networkManager) stopExpiredMirroring(now time.Time) bool {
+	changed := false
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				if ep.Mirror == nil || ep.Mirror.Until.After(now) {
+					continue
+				}
+
+				if err := nw.stopMirroring(ep); err != nil {
+					log.Printf("[net] Failed to stop expired mirroring of endpoint %v, err:%v.", ep.Id, err)
+					continue
+				}
+				changed = true
+			}
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Priority and handle of the matchall filters mirroring an endpoint, telling them apart from the filters other
+	// agents attach to the same clsact qdisc.
+	mirrorFilterPref   = "49152"
+	mirrorFilterHandle = "0x4d"
+)
+
+// Hooks of the clsact qdisc the traffic of an endpoint is mirrored from.
+var mirrorHooks = []string{"ingress", "egress"}
+
+// startMirroringImpl copies the traffic of the host interface of an endpoint to the capture interface, with tc mirred
+// filters on the ingress and egress hooks of a clsact qdisc. The filters of the previous mirroring are replaced, and
+// the qdisc and the filters of other agents are left alone.
+func (nw *network) startMirroringImpl(ep *endpoint, mirror *MirrorInfo) error {
+	// The traffic of SR-IOV endpoints never reaches the host.
+	if nw.Mode == opModeSriov || ep.HostIfName == "" {
+		return errMirrorNotSupported
+	}
+
+	if _, err := net.InterfaceByName(mirror.Interface); err != nil {
+		return fmt.Errorf("%v: capture interface %v: %v", errMirrorInvalid, mirror.Interface, err)
+	}
+
+	if err := addClsactQdisc(ep.HostIfName); err != nil {
+		return err
+	}
+
+	if err := deleteMirrorFilters(ep.HostIfName); err != nil {
+		return err
+	}
+
+	for _, hook := range mirrorHooks {
+		_, err := platform.ExecWithTimeout("tc", "filter", "add", "dev", ep.HostIfName, hook,
+			"pref", mirrorFilterPref, "handle", mirrorFilterHandle,
+			"matchall", "action", "mirred", "egress", "mirror", "dev", mirror.Interface)
+		if err != nil {
+			deleteMirrorFilters(ep.HostIfName)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopMirroringImpl deletes the mirroring filters of an endpoint.
+func (nw *network) stopMirroringImpl(ep *endpoint) error {
+	// The filters went away with the interface.
+	if _, err := net.InterfaceByName(ep.HostIfName); err != nil {
+		return nil
+	}
+
+	if err := deleteMirrorFilters(ep.HostIfName); err != nil {
+		log.Printf("[net] Failed to delete mirroring filters of %v, err:%v.", ep.HostIfName, err)
+		return err
+	}
+
+	return nil
+}
+
+// addClsactQdisc adds a clsact qdisc to an interface, unless it already has one.
+func addClsactQdisc(ifName string) error {
+	result, err := platform.ExecWithTimeout("tc", "qdisc", "show", "dev", ifName)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(result.Stdout, "qdisc clsact") {
+		return nil
+	}
+
+	_, err = platform.ExecWithTimeout("tc", "qdisc", "add", "dev", ifName, "clsact")
+	return err
+}
+
+// deleteMirrorFilters deletes the mirroring filters of an interface, if any.
+func deleteMirrorFilters(ifName string) error {
+	result, err := platform.ExecWithTimeout("tc", "qdisc", "show", "dev", ifName)
+	if err != nil || !strings.Contains(result.Stdout, "qdisc clsact") {
+		return err
+	}
+
+	for _, hook := range mirrorHooks {
+		result, err := platform.ExecWithTimeout("tc", "filter", "show", "dev", ifName, hook, "pref", mirrorFilterPref)
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(result.Stdout) == "" {
+			continue
+		}
+
+		_, err = platform.ExecWithTimeout("tc", "filter", "del", "dev", ifName, hook, "pref", mirrorFilterPref)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
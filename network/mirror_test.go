@@ -0,0 +1,72 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateMirror(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		name   string
+		mirror MirrorInfo
+		valid  bool
+	}{
+		{name: "valid", mirror: MirrorInfo{Interface: "capture0", Until: now.Add(time.Minute)}, valid: true},
+		{name: "longest", mirror: MirrorInfo{Interface: "capture0", Until: now.Add(MaxMirrorDuration)}, valid: true},
+		{name: "no capture interface", mirror: MirrorInfo{Until: now.Add(time.Minute)}},
+		{name: "expired", mirror: MirrorInfo{Interface: "capture0", Until: now.Add(-time.Minute)}},
+		{name: "zero duration", mirror: MirrorInfo{Interface: "capture0", Until: now}},
+		{name: "too long", mirror: MirrorInfo{Interface: "capture0", Until: now.Add(MaxMirrorDuration + time.Second)}},
+	}
+
+	for _, test := range tests {
+		if err := test.mirror.validate(now); (err == nil) != test.valid {
+			t.Errorf("%v: validate returned err:%v", test.name, err)
+		}
+	}
+}
+
+// Tests that only the mirroring whose deadline passed is stopped.
+func TestStopExpiredMirroring(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	expired := &endpoint{Id: "expired", HostIfName: "azvmirror0", Mirror: &MirrorInfo{Interface: "capture0", Until: now}}
+	active := &endpoint{Id: "active", HostIfName: "azvmirror1", Mirror: &MirrorInfo{Interface: "capture0", Until: now.Add(time.Second)}}
+	unmirrored := &endpoint{Id: "unmirrored", HostIfName: "azvmirror2"}
+
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {
+				Networks: map[string]*network{
+					"azure": {
+						Id: "azure",
+						Endpoints: map[string]*endpoint{
+							expired.Id: expired, active.Id: active, unmirrored.Id: unmirrored,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if !nm.stopExpiredMirroring(now) {
+		t.Errorf("Expired mirroring was not stopped")
+	}
+
+	if expired.Mirror != nil {
+		t.Errorf("Expired mirroring still set: %+v", expired.Mirror)
+	}
+
+	if active.Mirror == nil {
+		t.Errorf("Active mirroring was stopped")
+	}
+
+	if nm.stopExpiredMirroring(now) {
+		t.Errorf("State changed without expired mirroring")
+	}
+}
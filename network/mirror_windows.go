@@ -0,0 +1,78 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Microsoft/hcsshim"
+)
+
+const (
+	// Type of the HNS policy mirroring the traffic of an endpoint to another port of the switch.
+	hnsPortMirrorPolicyType = "PortMirror"
+)
+
+// hnsPortMirrorPolicy is the HNS endpoint policy copying the traffic of the endpoint to the destination port.
+type hnsPortMirrorPolicy struct {
+	Type        hcsshim.PolicyType
+	Destination string
+}
+
+// Returns the policies of an HNS endpoint without its mirroring policy.
+func withoutPortMirrorPolicy(policies []json.RawMessage) []json.RawMessage {
+	var kept []json.RawMessage
+	for _, raw := range policies {
+		var p struct{ Type hcsshim.PolicyType }
+		if err := json.Unmarshal(raw, &p); err == nil && p.Type == hnsPortMirrorPolicyType {
+			continue
+		}
+		kept = append(kept, raw)
+	}
+
+	return kept
+}
+
+// Updates the policies of the HNS endpoint of an endpoint.
+func updateHnsEndpointPolicies(ep *endpoint, update func([]json.RawMessage) []json.RawMessage) error {
+	return RetryHnsRequest("UpdateEndpoint", func() error {
+		hnsEndpoint, err := hcsshim.GetHNSEndpointByID(ep.HnsId)
+		if err != nil {
+			return err
+		}
+
+		hnsEndpoint.Policies = update(hnsEndpoint.Policies)
+		log.Printf("[net] Updating policies of HNS endpoint %v to %s.", ep.HnsId, hnsEndpoint.Policies)
+		_, err = hnsEndpoint.Update()
+		return err
+	})
+}
+
+// startMirroringImpl adds a port mirroring policy to the HNS endpoint, replacing the previous one.
+func (nw *network) startMirroringImpl(ep *endpoint, mirror *MirrorInfo) error {
+	if ep.HnsId == "" {
+		return errMirrorNotSupported
+	}
+
+	policy, err := json.Marshal(&hnsPortMirrorPolicy{Type: hnsPortMirrorPolicyType, Destination: mirror.Interface})
+	if err != nil {
+		return err
+	}
+
+	return updateHnsEndpointPolicies(ep, func(policies []json.RawMessage) []json.RawMessage {
+		return append(withoutPortMirrorPolicy(policies), policy)
+	})
+}
+
+// stopMirroringImpl removes the port mirroring policy from the HNS endpoint.
+func (nw *network) stopMirroringImpl(ep *endpoint) error {
+	if ep.HnsId == "" {
+		return nil
+	}
+
+	return updateHnsEndpointPolicies(ep, func(policies []json.RawMessage) []json.RawMessage {
+		return withoutPortMirrorPolicy(policies)
+	})
+}
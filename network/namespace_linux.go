@@ -13,6 +13,12 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+const (
+	// Magic numbers of the filesystems of network namespace files, on recent and older kernels.
+	nsfsMagic = 0x6e736673
+	procMagic = 0x9fa0
+)
+
 // Namespace represents a network namespace.
 type Namespace struct {
 	file   *os.File
@@ -29,6 +35,26 @@ func OpenNamespace(nsPath string) (*Namespace, error) {
 	return &Namespace{file: fd}, nil
 }
 
+// GetProcessNamespacePath returns the path of the network namespace of a process.
+func GetProcessNamespacePath(pid int) string {
+	return fmt.Sprintf("/proc/%d/ns/net", pid)
+}
+
+// validateNamespacePath returns an error if a path is not a network namespace, e.g. the path of a sandbox that
+// doesn't exist anymore, or a plain file a namespace was never bind mounted on.
+func validateNamespacePath(nsPath string) error {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(nsPath, &stat); err != nil {
+		return fmt.Errorf("%v: %v", errNamespaceInvalid, err)
+	}
+
+	if stat.Type != nsfsMagic && stat.Type != procMagic {
+		return fmt.Errorf("%v: %v is not a network namespace", errNamespaceInvalid, nsPath)
+	}
+
+	return nil
+}
+
 // GetCurrentThreadNamespace returns the caller thread's current namespace.
 func GetCurrentThreadNamespace() (*Namespace, error) {
 	nsPath := fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid())
@@ -0,0 +1,152 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strings"
+)
+
+const (
+	// Well-known NAT64 prefix of RFC 6052, used when the prefix is not set.
+	Nat64DefaultPrefix = "64:ff9b::/96"
+
+	// IPv4 addresses the NAT64 translator maps the pods to when the pool is not set.
+	Nat64DefaultPool = "192.168.255.0/24"
+
+	// Address TAYGA sends its ICMPv6 errors from. The well-known prefix can't embed the private address of TAYGA,
+	// so it needs an IPv6 address of its own, from a unique local range.
+	nat64TaygaIPv6Addr = "fd64:ff9b::1"
+
+	// Unique local range of the addresses the DNS64 proxies of the translators listen on.
+	nat64DNS64ProxyRange = "fd64:ff9b:0:53::"
+)
+
+// nat64Params are the parsed settings of a NAT64 configuration.
+type nat64Params struct {
+	prefix      *net.IPNet
+	pool        *net.IPNet
+	dns64Server net.IP
+}
+
+// dns64Upstream returns the address the DNS64 proxy forwards queries to.
+func (params *nat64Params) dns64Upstream() string {
+	return net.JoinHostPort(params.dns64Server.String(), "53")
+}
+
+// String returns the NAT64 configuration as compared by CheckConfigDrift.
+func (cfg *Nat64Config) String() string {
+	if cfg == nil {
+		return "disabled"
+	}
+
+	params, err := cfg.parse()
+	if err != nil {
+		return fmt.Sprintf("%v,%v,%v", cfg.Prefix, cfg.Pool, cfg.DNS64Server)
+	}
+
+	return fmt.Sprintf("%v,%v,%v", params.prefix, params.pool, cfg.DNS64Server)
+}
+
+// parse validates the NAT64 configuration and fills in its defaults.
+// The prefix must have one of the lengths of RFC 6052, and the pool must leave addresses to the pods.
+func (cfg *Nat64Config) parse() (*nat64Params, error) {
+	var params nat64Params
+	var err error
+
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = Nat64DefaultPrefix
+	}
+
+	_, params.prefix, err = net.ParseCIDR(prefix)
+	if err != nil || params.prefix.IP.To4() != nil {
+		return nil, fmt.Errorf("%v: invalid prefix %v", errNat64ConfigInvalid, prefix)
+	}
+
+	switch ones, _ := params.prefix.Mask.Size(); ones {
+	case 32, 40, 48, 56, 64, 96:
+	default:
+		return nil, fmt.Errorf("%v: prefix length of %v is not 32, 40, 48, 56, 64 or 96", errNat64ConfigInvalid, prefix)
+	}
+
+	pool := cfg.Pool
+	if pool == "" {
+		pool = Nat64DefaultPool
+	}
+
+	_, params.pool, err = net.ParseCIDR(pool)
+	if err != nil || params.pool.IP.To4() == nil {
+		return nil, fmt.Errorf("%v: invalid pool %v", errNat64ConfigInvalid, pool)
+	}
+
+	if ones, _ := params.pool.Mask.Size(); ones > 30 {
+		return nil, fmt.Errorf("%v: pool %v is smaller than a /30", errNat64ConfigInvalid, pool)
+	}
+
+	if cfg.DNS64Server != "" {
+		params.dns64Server = net.ParseIP(cfg.DNS64Server)
+		if params.dns64Server == nil || params.dns64Server.To4() != nil {
+			return nil, fmt.Errorf("%v: DNS64 server %v is not an IPv6 address", errNat64ConfigInvalid, cfg.DNS64Server)
+		}
+	}
+
+	return &params, nil
+}
+
+// getNat64DeviceName returns the name of the tunnel device of the NAT64 translator of a network.
+// Names are derived from a hash of the network ID to fit the length limit of interface names.
+func getNat64DeviceName(networkId string) string {
+	h := fnv.New32a()
+	h.Write([]byte(networkId))
+	return fmt.Sprintf("az64-%08x", h.Sum32())
+}
+
+// getDNS64ProxyAddress returns the address the DNS64 proxy of a NAT64 translator listens on.
+// Addresses are derived from a hash of the device name, so that the translators of several networks don't collide.
+func getDNS64ProxyAddress(device string) net.IP {
+	h := fnv.New32a()
+	h.Write([]byte(device))
+
+	ip := net.ParseIP(nat64DNS64ProxyRange)
+	binary.BigEndian.PutUint32(ip[12:], h.Sum32())
+	return ip
+}
+
+// getTaygaConfig returns the configuration of the TAYGA translator of a network.
+// TAYGA owns the first address of the pool and maps the pods to the other addresses.
+func getTaygaConfig(device string, params *nat64Params, dataDir string) string {
+	taygaIP := make(net.IP, net.IPv4len)
+	copy(taygaIP, params.pool.IP.To4())
+	taygaIP[3]++
+
+	lines := []string{
+		"tun-device " + device,
+		"ipv4-addr " + taygaIP.String(),
+		"ipv6-addr " + nat64TaygaIPv6Addr,
+		"prefix " + params.prefix.String(),
+		"dynamic-pool " + params.pool.String(),
+		"data-dir " + dataDir,
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// validateNat64Subnets returns an error if the network has IPv4 subnets. NAT64 serves IPv6-only pods.
+func validateNat64Subnets(subnets []SubnetInfo) error {
+	if len(subnets) == 0 {
+		return fmt.Errorf("%v: network has no subnets", errNat64ConfigInvalid)
+	}
+
+	for _, subnet := range subnets {
+		if subnet.Prefix.IP.To4() != nil {
+			return fmt.Errorf("%v: subnet %v is not IPv6", errNat64ConfigInvalid, subnet.Prefix.String())
+		}
+	}
+
+	return nil
+}
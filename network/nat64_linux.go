@@ -0,0 +1,385 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Directory holding the configuration, pid files and address mappings of the NAT64 translators.
+	nat64RuntimeDir = platform.CNIRuntimePath + "azure-nat64"
+
+	// NAT64 translator run on the node.
+	taygaBinary = "tayga"
+)
+
+// addNat64 starts a TAYGA translator for the IPv6-only subnets of the network. The translator owns a tunnel device
+// routed the NAT64 prefix, and the pool it maps the pods to is masqueraded to the addresses of the external interface.
+// If a DNS64 server is set, DNS queries of the pods are answered by a DNS64 proxy resolving them through the server.
+func (nw *network) addNat64(cfg *Nat64Config, subnets []SubnetInfo) error {
+	params, err := cfg.parse()
+	if err != nil {
+		return err
+	}
+
+	if err = validateNat64Subnets(subnets); err != nil {
+		return err
+	}
+
+	device := getNat64DeviceName(nw.Id)
+	log.Printf("[net] Adding NAT64 translator %v for prefix %v and pool %v.", device, params.prefix, params.pool)
+
+	nw.Nat64 = cfg
+	defer func() {
+		if err != nil {
+			nw.deleteNat64(subnets)
+		}
+	}()
+
+	dataDir := filepath.Join(nat64RuntimeDir, device)
+	if err = os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+
+	configFile := getTaygaConfigFile(device)
+	if err = ioutil.WriteFile(configFile, []byte(getTaygaConfig(device, params, dataDir)), 0600); err != nil {
+		return err
+	}
+
+	if _, err = platform.ExecWithTimeout(taygaBinary, "--config", configFile, "--mktun"); err != nil {
+		log.Printf("[net] Failed to create NAT64 device %v, err:%v.", device, err)
+		return err
+	}
+
+	if err = netlink.SetLinkState(device, true); err != nil {
+		return err
+	}
+
+	if err = addNat64Routes(device, params); err != nil {
+		return err
+	}
+
+	// Translated packets are forwarded between the tunnel and the external interface.
+	for _, path := range []string{"net/ipv4/ip_forward", "net/ipv6/conf/all/forwarding"} {
+		if err = platform.SetSysctl(path, "1"); err != nil {
+			return err
+		}
+	}
+
+	if err = addIptablesRule("iptables", "nat", "POSTROUTING", nw.getNat64MasqueradeRule(params)...); err != nil {
+		return err
+	}
+
+	if err = startTayga(device); err != nil {
+		return err
+	}
+
+	if params.dns64Server != nil {
+		proxyIP := getDNS64ProxyAddress(device)
+		proxyNet := &net.IPNet{IP: proxyIP, Mask: net.CIDRMask(128, 128)}
+		if err = netlink.AddIpAddress(device, proxyIP, proxyNet); err != nil && !netlink.IsExist(err) {
+			return err
+		}
+
+		if err = startDNS64Proxy(device, params); err != nil {
+			return err
+		}
+
+		if err = addDns64Rules(device, params.dns64Server, proxyIP, subnets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureNat64 restarts the processes of the NAT64 translator of the network that are no longer running,
+// e.g. after they crashed. Called as pods are added to the network.
+func (nw *network) ensureNat64() error {
+	if nw.Nat64 == nil {
+		return nil
+	}
+
+	params, err := nw.Nat64.parse()
+	if err != nil {
+		return err
+	}
+
+	device := getNat64DeviceName(nw.Id)
+
+	if !isNat64ProcessRunning(getTaygaPidFile(device), taygaBinary) {
+		log.Printf("[net] NAT64 translator %v is not running, restarting it.", device)
+		if err = startTayga(device); err != nil {
+			return err
+		}
+	}
+
+	if params.dns64Server != nil && !isNat64ProcessRunning(getDNS64ProxyPidFile(device), "-"+common.OptDNS64Proxy) {
+		log.Printf("[net] DNS64 proxy of NAT64 translator %v is not running, restarting it.", device)
+		if err = startDNS64Proxy(device, params); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteNat64 stops the NAT64 translator of the network and deletes its device and rules.
+func (nw *network) deleteNat64(subnets []SubnetInfo) {
+	if nw.Nat64 == nil {
+		return
+	}
+
+	params, err := nw.Nat64.parse()
+	if err != nil {
+		return
+	}
+
+	device := getNat64DeviceName(nw.Id)
+	log.Printf("[net] Deleting NAT64 translator %v.", device)
+
+	stopNat64Process(getTaygaPidFile(device), taygaBinary)
+
+	if params.dns64Server != nil {
+		deleteDns64Rules(device, subnets)
+		stopNat64Process(getDNS64ProxyPidFile(device), "-"+common.OptDNS64Proxy)
+	}
+
+	deleteIptablesRule("iptables", "nat", "POSTROUTING", nw.getNat64MasqueradeRule(params)...)
+
+	// Deleting the device deletes its routes and addresses.
+	configFile := getTaygaConfigFile(device)
+	if _, err := platform.ExecWithTimeout(taygaBinary, "--config", configFile, "--rmtun"); err != nil {
+		log.Printf("[net] Failed to delete NAT64 device %v, err:%v.", device, err)
+	}
+
+	os.Remove(configFile)
+	os.RemoveAll(filepath.Join(nat64RuntimeDir, device))
+	nw.Nat64 = nil
+}
+
+// Returns the rule masquerading the pool of the translator to the addresses of the interface leaving the node.
+// Bridge mode networks move the addresses of the external interface to the bridge.
+func (nw *network) getNat64MasqueradeRule(params *nat64Params) []string {
+	outIfName := nw.extIf.Name
+	if nw.extIf.BridgeName != "" {
+		outIfName = nw.extIf.BridgeName
+	}
+
+	return []string{"-s", params.pool.String(), "-o", outIfName, "-j", "MASQUERADE"}
+}
+
+// Starts the TAYGA translator of a device. TAYGA runs in the background once it started.
+func startTayga(device string) error {
+	configFile := getTaygaConfigFile(device)
+	if _, err := platform.ExecWithTimeout(taygaBinary, "--config", configFile, "--pidfile", getTaygaPidFile(device)); err != nil {
+		log.Printf("[net] Failed to start NAT64 translator %v, err:%v.", device, err)
+		return err
+	}
+
+	return nil
+}
+
+// Starts the DNS64 proxy of a translator in a background process running the executable of the plugin.
+func startDNS64Proxy(device string, params *nat64Params) error {
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	config, err := json.Marshal(&dns64ProxyConfig{
+		Address:  net.JoinHostPort(getDNS64ProxyAddress(device).String(), "53"),
+		Prefix:   params.prefix.String(),
+		Upstream: params.dns64Upstream(),
+	})
+	if err != nil {
+		return err
+	}
+
+	attr := &os.ProcAttr{
+		Env:   append(os.Environ(), fmt.Sprintf("%v=%s", dns64ProxyConfigEnv, config)),
+		Files: []*os.File{nil, nil, nil},
+		Sys:   &syscall.SysProcAttr{Setsid: true},
+	}
+
+	process, err := os.StartProcess(path, []string{path, "-" + common.OptDNS64Proxy}, attr)
+	if err != nil {
+		log.Printf("[net] Failed to start DNS64 proxy of NAT64 translator %v, err:%v.", device, err)
+		return err
+	}
+
+	pid := process.Pid
+	process.Release()
+
+	log.Printf("[net] Started DNS64 proxy of NAT64 translator %v with pid %v.", device, pid)
+	return ioutil.WriteFile(getDNS64ProxyPidFile(device), []byte(strconv.Itoa(pid)), 0600)
+}
+
+// Returns whether the process in the pid file is running, and its command line contains the given argument,
+// so that a recycled pid isn't mistaken for it.
+func isNat64ProcessRunning(pidFile string, arg string) bool {
+	pid, ok := readNat64Pid(pidFile, arg)
+	return ok && platform.IsProcessRunning(pid)
+}
+
+// Stops the process in the pid file, if it still runs the given command.
+func stopNat64Process(pidFile string, arg string) {
+	if pid, ok := readNat64Pid(pidFile, arg); ok {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+			log.Printf("[net] Failed to stop process %v, err:%v.", pid, err)
+		}
+	}
+
+	os.Remove(pidFile)
+}
+
+// Reads the pid file of a process of a translator, and checks that the command line of the process contains
+// the given argument.
+func readNat64Pid(pidFile string, arg string) (int, bool) {
+	data, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return 0, false
+	}
+
+	for _, s := range strings.Split(string(cmdline), "\x00") {
+		if s == arg || filepath.Base(s) == arg {
+			return pid, true
+		}
+	}
+
+	return 0, false
+}
+
+// Routes the NAT64 prefix and the pool of the translator to its device.
+func addNat64Routes(device string, params *nat64Params) error {
+	link, err := net.InterfaceByName(device)
+	if err != nil {
+		return err
+	}
+
+	routes := []*netlink.Route{
+		&netlink.Route{Family: unix.AF_INET6, Dst: params.prefix, LinkIndex: link.Index},
+		&netlink.Route{Family: unix.AF_INET, Dst: params.pool, LinkIndex: link.Index},
+	}
+
+	for _, route := range routes {
+		if err = netlink.AddIpRoute(route); err != nil && !netlink.IsExist(err) {
+			log.Printf("[net] Failed to add NAT64 route %+v, err:%v.", route, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sends the DNS queries of the pods to the DNS64 proxy, except the queries of the DNS64 server itself, which may run
+// in a pod of the network. The rules are kept in a chain of the translator.
+func addDns64Rules(device string, server net.IP, proxy net.IP, subnets []SubnetInfo) error {
+	chain := getDns64Chain(device)
+	if _, err := platform.ExecWithTimeout("ip6tables", "-t", "nat", "-N", chain); err != nil {
+		if _, err = platform.ExecWithTimeout("ip6tables", "-t", "nat", "-L", chain); err != nil {
+			return err
+		}
+	}
+
+	if err := addIptablesRule("ip6tables", "nat", chain, "-s", server.String(), "-j", "RETURN"); err != nil {
+		return err
+	}
+
+	destination := "[" + proxy.String() + "]:53"
+	for _, protocol := range []string{"udp", "tcp"} {
+		err := addIptablesRule("ip6tables", "nat", chain, "-p", protocol, "--dport", "53", "-j", "DNAT", "--to-destination", destination)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, subnet := range subnets {
+		if err := addIptablesRule("ip6tables", "nat", "PREROUTING", "-s", subnet.Prefix.String(), "-j", chain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deletes the rules added by addDns64Rules.
+func deleteDns64Rules(device string, subnets []SubnetInfo) {
+	chain := getDns64Chain(device)
+	for _, subnet := range subnets {
+		deleteIptablesRule("ip6tables", "nat", "PREROUTING", "-s", subnet.Prefix.String(), "-j", chain)
+	}
+
+	platform.ExecWithTimeout("ip6tables", "-t", "nat", "-F", chain)
+	platform.ExecWithTimeout("ip6tables", "-t", "nat", "-X", chain)
+}
+
+// Appends a rule to a chain unless it already exists.
+func addIptablesRule(binary string, table string, chain string, rule ...string) error {
+	check := append([]string{"-t", table, "-C", chain}, rule...)
+	if _, err := platform.ExecWithTimeout(binary, check...); err == nil {
+		return nil
+	}
+
+	add := append([]string{"-t", table, "-A", chain}, rule...)
+	if _, err := platform.ExecWithTimeout(binary, add...); err != nil {
+		log.Printf("[net] Failed to add %v rule %v to chain %v, err:%v.", binary, rule, chain, err)
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a rule from a chain.
+func deleteIptablesRule(binary string, table string, chain string, rule ...string) {
+	args := append([]string{"-t", table, "-D", chain}, rule...)
+	if _, err := platform.ExecWithTimeout(binary, args...); err != nil {
+		log.Printf("[net] Failed to delete %v rule %v from chain %v, err:%v.", binary, rule, chain, err)
+	}
+}
+
+// Returns the path of the configuration file of a NAT64 translator.
+func getTaygaConfigFile(device string) string {
+	return filepath.Join(nat64RuntimeDir, device+".conf")
+}
+
+// Returns the path of the pid file of a NAT64 translator.
+func getTaygaPidFile(device string) string {
+	return filepath.Join(nat64RuntimeDir, device+".pid")
+}
+
+// Returns the path of the pid file of the DNS64 proxy of a NAT64 translator.
+func getDNS64ProxyPidFile(device string) string {
+	return filepath.Join(nat64RuntimeDir, device+"-dns64.pid")
+}
+
+// Returns the name of the chain forwarding the DNS queries of the pods of a NAT64 translator.
+func getDns64Chain(device string) string {
+	return "AZURE-DNS64-" + strings.TrimPrefix(device, "az64-")
+}
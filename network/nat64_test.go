@@ -0,0 +1,98 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNat64ConfigParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Nat64Config
+		prefix string
+		pool   string
+		valid  bool
+	}{
+		{name: "defaults", cfg: Nat64Config{}, prefix: Nat64DefaultPrefix, pool: Nat64DefaultPool, valid: true},
+		{name: "custom", cfg: Nat64Config{Prefix: "2001:db8:64::/64", Pool: "10.64.0.0/16", DNS64Server: "fd00::10"},
+			prefix: "2001:db8:64::/64", pool: "10.64.0.0/16", valid: true},
+		{name: "IPv4 prefix", cfg: Nat64Config{Prefix: "10.0.0.0/8"}},
+		{name: "prefix length", cfg: Nat64Config{Prefix: "64:ff9b::/80"}},
+		{name: "IPv6 pool", cfg: Nat64Config{Pool: "fd00::/64"}},
+		{name: "small pool", cfg: Nat64Config{Pool: "10.0.0.0/31"}},
+		{name: "IPv4 DNS64 server", cfg: Nat64Config{DNS64Server: "10.0.0.10"}},
+	}
+
+	for _, test := range tests {
+		params, err := test.cfg.parse()
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%v: parse succeeded", test.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: parse failed: %v", test.name, err)
+			continue
+		}
+
+		if params.prefix.String() != test.prefix || params.pool.String() != test.pool {
+			t.Errorf("%v: got prefix %v pool %v, expected %v %v", test.name, params.prefix, params.pool, test.prefix, test.pool)
+		}
+	}
+}
+
+func TestValidateNat64Subnets(t *testing.T) {
+	_, v6, _ := net.ParseCIDR("fd00::/64")
+	_, v4, _ := net.ParseCIDR("10.0.0.0/24")
+
+	if err := validateNat64Subnets([]SubnetInfo{{Prefix: *v6}}); err != nil {
+		t.Errorf("IPv6 subnet rejected: %v", err)
+	}
+
+	if err := validateNat64Subnets([]SubnetInfo{{Prefix: *v6}, {Prefix: *v4}}); err == nil {
+		t.Errorf("IPv4 subnet accepted")
+	}
+
+	if err := validateNat64Subnets(nil); err == nil {
+		t.Errorf("Network without subnets accepted")
+	}
+}
+
+func TestGetTaygaConfig(t *testing.T) {
+	params, err := (&Nat64Config{}).parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := getTaygaConfig("az64-1", params, "/data")
+	for _, line := range []string{
+		"tun-device az64-1",
+		"ipv4-addr 192.168.255.1",
+		"prefix 64:ff9b::/96",
+		"dynamic-pool 192.168.255.0/24",
+		"data-dir /data",
+	} {
+		if !strings.Contains(config, line+"\n") {
+			t.Errorf("TAYGA configuration lacks %q:\n%v", line, config)
+		}
+	}
+}
+
+func TestNat64Names(t *testing.T) {
+	device := getNat64DeviceName("azure")
+	if len(device) > 15 || device != getNat64DeviceName("azure") || device == getNat64DeviceName("other") {
+		t.Errorf("Invalid device name %v", device)
+	}
+
+	proxy := getDNS64ProxyAddress(device)
+	_, proxyRange, _ := net.ParseCIDR(nat64DNS64ProxyRange + "/96")
+	if !proxyRange.Contains(proxy) || proxy.Equal(getDNS64ProxyAddress(getNat64DeviceName("other"))) {
+		t.Errorf("Invalid DNS64 proxy address %v", proxy)
+	}
+}
@@ -4,7 +4,9 @@
 package network
 
 import (
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -17,9 +19,24 @@ const (
 	opModeBridge      = "bridge"
 	opModeTunnel      = "tunnel"
 	opModeTransparent = "transparent"
+	opModeSriov       = "sriov"
+	opModeOverlay     = "overlay"
 	opModeDefault     = opModeTunnel
 )
 
+const (
+	// Dataplanes implementing bridge and tunnel mode networks on Linux.
+	DataplaneLinuxBridge = "linuxbridge"
+	DataplaneOVS         = "ovs"
+)
+
+const (
+	// Links connecting the SR-IOV virtual function of the network to pods.
+	SriovLinkDirect  = "direct"
+	SriovLinkMacvlan = "macvlan"
+	SriovLinkIPVlan  = "ipvlan"
+)
+
 // ExternalInterface is a host network interface that bridges containers to external networks.
 type externalInterface struct {
 	Name        string
@@ -44,6 +61,17 @@ type network struct {
 	extIf            *externalInterface
 	DNS              DNSInfo
 	EnableSnatOnHost bool
+	Dataplane        string                   `json:",omitempty"`
+	Sriov            *SriovConfig             `json:",omitempty"`
+	SnatIPBlock      string                   `json:",omitempty"`
+	WarmEndpoints    map[string]*warmEndpoint `json:",omitempty"`
+	PolicyRouting    bool                     `json:",omitempty"`
+	RoutingTable     int                      `json:",omitempty"`
+	Nat64            *Nat64Config             `json:",omitempty"`
+	Overlay          *OverlayConfig           `json:",omitempty"`
+	OverlayLocalIP   net.IP                   `json:",omitempty"`
+	OverlayRoutes    []OverlayRoute           `json:",omitempty"`
+	Arp              *ArpConfig               `json:",omitempty"`
 }
 
 // NetworkInfo contains read-only information about a container network.
@@ -56,9 +84,55 @@ type NetworkInfo struct {
 	Policies         []policy.Policy
 	BridgeName       string
 	EnableSnatOnHost bool
+	Dataplane        string
+	Arp              *ArpConfig
+	Sriov            *SriovConfig
+	SnatIPBlock      string
+	PolicyRouting    bool
+	Nat64            *Nat64Config
+	Overlay          *OverlayConfig
+	OverlayRoutes    []OverlayRoute
 	Options          map[string]interface{}
 }
 
+// ArpConfig contains the ARP settings applied to the master interface when a network is created,
+// and to the endpoints created in the network. Settings that are not set leave the interfaces unchanged.
+// Announcements is the number of gratuitous ARPs or unsolicited neighbor advertisements sent for each address
+// of a new endpoint, so that switches forget the previous location of a reused address.
+type ArpConfig struct {
+	ProxyArp         *bool
+	Announce         *int
+	Ignore           *int
+	EndpointProxyArp *bool
+	Announcements    int
+}
+
+// SriovConfig selects the virtual function of the master interface passed to the pods of an SR-IOV network.
+// The virtual function is moved into the pod, or shared by pods through MACVLAN or IPVLAN interfaces on top of it.
+type SriovConfig struct {
+	VfName string
+	Link   string
+}
+
+// getLink returns the link connecting pods to the virtual function.
+func (cfg *SriovConfig) getLink() string {
+	if cfg == nil || cfg.Link == "" {
+		return SriovLinkDirect
+	}
+
+	return cfg.Link
+}
+
+// Nat64Config lets the pods of IPv6-only networks reach IPv4-only destinations through a NAT64 translator on the node.
+// Pods reach an IPv4 address at its IPv6 address in Prefix, which the node translates to an address of Pool and
+// masquerades. Optionally, DNS queries of the pods are answered by a DNS64 proxy on the node, which resolves them
+// through DNS64Server and synthesizes those addresses for names without IPv6 addresses.
+type Nat64Config struct {
+	Prefix      string
+	Pool        string
+	DNS64Server string `json:",omitempty"`
+}
+
 // SubnetInfo contains subnet information for a container network.
 type SubnetInfo struct {
 	Family  platform.AddressFamily
@@ -68,8 +142,72 @@ type SubnetInfo struct {
 
 // DNSInfo contains DNS information for a container network or endpoint.
 type DNSInfo struct {
-	Suffix  string
-	Servers []string
+	Suffix   string
+	Servers  []string
+	Searches []string `json:",omitempty"`
+	Options  []string `json:",omitempty"`
+}
+
+// ConfigDrift describes a network setting whose recorded value differs from the requested value.
+type ConfigDrift struct {
+	Field     string
+	Recorded  string
+	Requested string
+}
+
+// ConfigDriftError is returned when an existing network was created with a different configuration.
+type ConfigDriftError struct {
+	NetworkId string
+	Drifts    []ConfigDrift
+}
+
+// Error returns the description of the config drift.
+func (e *ConfigDriftError) Error() string {
+	var drifts []string
+	for _, d := range e.Drifts {
+		drifts = append(drifts, fmt.Sprintf("%s recorded:%q requested:%q", d.Field, d.Recorded, d.Requested))
+	}
+
+	return fmt.Sprintf("%v for network %v: %v", errNetworkConfigDrift, e.NetworkId, strings.Join(drifts, ", "))
+}
+
+// CheckConfigDrift compares the recorded configuration of an existing network with the
+// requested configuration and returns a ConfigDriftError describing any differences.
+// Settings that are not recorded or not requested are not compared.
+func CheckConfigDrift(recorded *NetworkInfo, requested *NetworkInfo) error {
+	var drifts []ConfigDrift
+
+	check := func(field, rec, req string) {
+		if rec != "" && req != "" && rec != req {
+			drifts = append(drifts, ConfigDrift{Field: field, Recorded: rec, Requested: req})
+		}
+	}
+
+	mode := requested.Mode
+	if mode == "" {
+		mode = opModeDefault
+	}
+	check("mode", recorded.Mode, mode)
+	check("bridge", recorded.BridgeName, requested.BridgeName)
+	check("master", recorded.MasterIfName, requested.MasterIfName)
+	check("enableSnatOnHost", strconv.FormatBool(recorded.EnableSnatOnHost), strconv.FormatBool(requested.EnableSnatOnHost))
+	check("dataplane", recorded.Dataplane, requested.Dataplane)
+	check("snatIPBlock", recorded.SnatIPBlock, requested.SnatIPBlock)
+	check("policyRouting", strconv.FormatBool(recorded.PolicyRouting), strconv.FormatBool(requested.PolicyRouting))
+	check("nat64", recorded.Nat64.String(), requested.Nat64.String())
+	if recorded.Overlay != nil && requested.Overlay != nil {
+		check("overlayVNI", strconv.Itoa(recorded.Overlay.VNI), strconv.Itoa(requested.Overlay.VNI))
+	}
+
+	if len(requested.Subnets) > 0 && len(recorded.Subnets) > 0 {
+		check("subnet", recorded.Subnets[0].Prefix.String(), requested.Subnets[0].Prefix.String())
+	}
+
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	return &ConfigDriftError{NetworkId: recorded.Id, Drifts: drifts}
 }
 
 // NewExternalInterface adds a host interface to the list of available external interfaces.
@@ -178,6 +316,7 @@ func (nm *networkManager) newNetwork(nwInfo *NetworkInfo) (*network, error) {
 
 	// Add the network object.
 	nw.Subnets = nwInfo.Subnets
+	nw.PolicyRouting = nwInfo.PolicyRouting
 	extIf.Networks[nwInfo.Id] = nw
 
 	log.Printf("[net] Created network %v on interface %v.", nwInfo.Id, extIf.Name)
@@ -201,6 +340,11 @@ func (nm *networkManager) deleteNetwork(networkId string) error {
 		return err
 	}
 
+	// Delete the warm endpoints of the network.
+	for warmEndpointId := range nw.WarmEndpoints {
+		nw.deleteWarmEndpoint(warmEndpointId)
+	}
+
 	// Call the OS-specific implementation.
 	err = nm.deleteNetworkImpl(nw)
 	if err != nil {
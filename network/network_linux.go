@@ -7,10 +7,10 @@ import (
 	"fmt"
 	"net"
 	"strconv"
-	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
 	"golang.org/x/sys/unix"
 )
 
@@ -25,6 +25,8 @@ const (
 
 	LocalIPKey = "localIP"
 
+	SnatIPKey = "snatIP"
+
 	InfraVnetIPKey = "infraVnetIP"
 
 	OptVethName = "vethname"
@@ -40,6 +42,20 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 	opt, _ := nwInfo.Options[genericData].(map[string]interface{})
 	log.Printf("opt %+v options %+v", opt, nwInfo.Options)
 
+	switch nwInfo.Dataplane {
+	case "", DataplaneLinuxBridge, DataplaneOVS:
+	default:
+		return nil, errNetworkDataplaneInvalid
+	}
+
+	// Networks on the same external interface share its bridge, so they must use the same dataplane.
+	for _, nw := range extIf.Networks {
+		if nwInfo.Dataplane != "" && nw.Dataplane != "" && nw.Dataplane != nwInfo.Dataplane {
+			log.Printf("[net] Network %v on interface %v uses dataplane %v.", nw.Id, extIf.Name, nw.Dataplane)
+			return nil, errNetworkDataplaneInvalid
+		}
+	}
+
 	switch nwInfo.Mode {
 	case opModeTunnel:
 		fallthrough
@@ -53,11 +69,60 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 			vlanid, _ = strconv.Atoi(opt[VlanIDKey].(string))
 		}
 	case opModeTransparent:
-		break
+		// Transparent mode routes traffic on the host without a bridge.
+		if nwInfo.Dataplane == DataplaneOVS {
+			return nil, errNetworkDataplaneInvalid
+		}
+	case opModeOverlay:
+		// Overlay mode routes traffic on the host like transparent mode, and tunnels it to the other nodes.
+		if nwInfo.Dataplane == DataplaneOVS {
+			return nil, errNetworkDataplaneInvalid
+		}
+
+		if nwInfo.Overlay == nil {
+			return nil, errOverlayConfigInvalid
+		}
+
+		if nwInfo.PolicyRouting || nwInfo.Nat64 != nil {
+			return nil, errOverlayNotSupported
+		}
+	case opModeSriov:
+		// SR-IOV mode connects pods to a virtual function of the external interface without a bridge.
+		if nwInfo.Dataplane == DataplaneOVS {
+			return nil, errNetworkDataplaneInvalid
+		}
+
+		switch nwInfo.Sriov.getLink() {
+		case SriovLinkDirect, SriovLinkMacvlan, SriovLinkIPVlan:
+		default:
+			return nil, errSriovLinkInvalid
+		}
+
+		// Pods own the virtual functions, their traffic never reaches the routing tables of the host.
+		if nwInfo.PolicyRouting {
+			return nil, errPolicyRoutingNotSupported
+		}
+
+		if nwInfo.Nat64 != nil {
+			return nil, errNat64NotSupported
+		}
 	default:
 		return nil, errNetworkModeInvalid
 	}
 
+	// Endpoints with SNAT on host are each assigned an IPv4 address from the SNAT IP block.
+	if nwInfo.SnatIPBlock != "" {
+		ip, _, err := net.ParseCIDR(nwInfo.SnatIPBlock)
+		if err != nil || ip.To4() == nil {
+			log.Printf("[net] Invalid SNAT IP block %v.", nwInfo.SnatIPBlock)
+			return nil, errSnatIPBlockInvalid
+		}
+	}
+
+	if err := setArpConfig(extIf.Name, nwInfo.Arp); err != nil {
+		return nil, err
+	}
+
 	// Create the network object.
 	nw := &network{
 		Id:               nwInfo.Id,
@@ -67,6 +132,30 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		VlanId:           vlanid,
 		DNS:              nwInfo.DNS,
 		EnableSnatOnHost: nwInfo.EnableSnatOnHost,
+		Dataplane:        nwInfo.Dataplane,
+		Sriov:            nwInfo.Sriov,
+		SnatIPBlock:      nwInfo.SnatIPBlock,
+		Arp:              nwInfo.Arp,
+	}
+
+	// Route the traffic of the network through its own external interface on nodes with multiple NICs.
+	if nwInfo.PolicyRouting {
+		if err := nw.addPolicyRoutes(nwInfo.Subnets); err != nil {
+			return nil, err
+		}
+	}
+
+	if nwInfo.Mode == opModeOverlay {
+		if err := nw.addOverlay(nwInfo.Overlay, nwInfo.Subnets, nwInfo.OverlayRoutes); err != nil {
+			return nil, err
+		}
+	}
+
+	// Translate the traffic of IPv6-only pods to IPv4-only destinations.
+	if nwInfo.Nat64 != nil {
+		if err := nw.addNat64(nwInfo.Nat64, nwInfo.Subnets); err != nil {
+			return nil, err
+		}
 	}
 
 	return nw, nil
@@ -76,7 +165,20 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 	var networkClient NetworkClient
 
-	if nw.VlanId != 0 {
+	nw.deleteNat64(nw.Subnets)
+
+	// SR-IOV networks don't connect the external interface.
+	if nw.Mode == opModeSriov {
+		return nil
+	}
+
+	// Overlay networks don't connect the external interface either, they tunnel through it.
+	if nw.Mode == opModeOverlay {
+		nw.deleteOverlay(nw.Subnets)
+		return nil
+	}
+
+	if nw.isOVS() {
 		networkClient = NewOVSClient(nw.extIf.BridgeName, nw.extIf.Name, "", nw.DNS.Servers, nw.EnableSnatOnHost)
 	} else {
 		networkClient = NewLinuxBridgeClient(nw.extIf.BridgeName, nw.extIf.Name, nw.Mode)
@@ -84,12 +186,18 @@ func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 
 	// Disconnect the interface if this was the last network using it.
 	if len(nw.extIf.Networks) == 1 {
+		nw.deletePolicyRoutes()
 		nm.disconnectExternalInterface(nw.extIf, networkClient)
 	}
 
 	return nil
 }
 
+// isOVS returns whether the network uses the OVS dataplane, either requested or required by its VLAN.
+func (nw *network) isOVS() bool {
+	return nw.Dataplane == DataplaneOVS || nw.VlanId != 0
+}
+
 //  SaveIPConfig saves the IP configuration of an interface.
 func (nm *networkManager) saveIPConfig(hostIf *net.Interface, extIf *externalInterface) error {
 	// Save the default routes on the interface.
@@ -146,7 +254,7 @@ func (nm *networkManager) applyIPConfig(extIf *externalInterface, targetIf *net.
 		log.Printf("[net] Adding IP address %v to interface %v.", addr, targetIf.Name)
 
 		err := netlink.AddIpAddress(targetIf.Name, addr.IP, addr)
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "file exists") {
+		if err != nil && !netlink.IsExist(err) {
 			log.Printf("[net] Failed to add IP address %v: %v.", addr, err)
 			return err
 		}
@@ -159,7 +267,7 @@ func (nm *networkManager) applyIPConfig(extIf *externalInterface, targetIf *net.
 		log.Printf("[net] Adding IP route %+v.", route)
 
 		err := netlink.AddIpRoute((*netlink.Route)(route))
-		if err != nil {
+		if err != nil && !netlink.IsExist(err) {
 			log.Printf("[net] Failed to add IP route %v: %v.", route, err)
 			return err
 		}
@@ -194,7 +302,7 @@ func (nm *networkManager) connectExternalInterface(extIf *externalInterface, nwI
 	}
 
 	opt, _ := nwInfo.Options[genericData].(map[string]interface{})
-	if opt != nil && opt[VlanIDKey] != nil {
+	if nwInfo.Dataplane == DataplaneOVS || (opt != nil && opt[VlanIDKey] != nil) {
 		snatBridgeIP := ""
 
 		if opt != nil && opt[SnatBridgeIPKey] != nil {
@@ -318,6 +426,64 @@ func (nm *networkManager) disconnectExternalInterface(extIf *externalInterface,
 	log.Printf("[net] Disconnected interface %v.", extIf.Name)
 }
 
+// SetArpConfig programs the ARP sysctls of the given interface.
+func setArpConfig(ifName string, arp *ArpConfig) error {
+	if arp == nil {
+		return nil
+	}
+
+	if arp.Announce != nil && (*arp.Announce < 0 || *arp.Announce > 2) {
+		return fmt.Errorf("Invalid arp_announce value %v", *arp.Announce)
+	}
+
+	if arp.Ignore != nil && ((*arp.Ignore < 0 || *arp.Ignore > 3) && *arp.Ignore != 8) {
+		return fmt.Errorf("Invalid arp_ignore value %v", *arp.Ignore)
+	}
+
+	if arp.Announcements < 0 || arp.Announcements > maxAnnouncements {
+		return fmt.Errorf("Invalid announcements value %v", arp.Announcements)
+	}
+
+	var settings []struct {
+		name  string
+		value int
+	}
+
+	add := func(name string, value int) {
+		settings = append(settings, struct {
+			name  string
+			value int
+		}{name, value})
+	}
+
+	if arp.ProxyArp != nil {
+		proxyArp := 0
+		if *arp.ProxyArp {
+			proxyArp = 1
+		}
+		add("proxy_arp", proxyArp)
+	}
+
+	if arp.Announce != nil {
+		add("arp_announce", *arp.Announce)
+	}
+
+	if arp.Ignore != nil {
+		add("arp_ignore", *arp.Ignore)
+	}
+
+	for _, setting := range settings {
+		log.Printf("[net] Setting %v to %v on interface %v.", setting.name, setting.value, ifName)
+		path := fmt.Sprintf("net/ipv4/conf/%v/%v", ifName, setting.name)
+		if err := platform.SetSysctl(path, strconv.Itoa(setting.value)); err != nil {
+			log.Printf("[net] Failed to set %v on interface %v: %v.", setting.name, ifName, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
 func getNetworkInfoImpl(nwInfo *NetworkInfo, nw *network) {
 	if nw.VlanId != 0 {
 		vlanMap := make(map[string]interface{})
@@ -334,7 +500,7 @@ func AddStaticRoute(ip string, interfaceName string) error {
 	route := RouteInfo{Dst: *ipNet, Gw: gwIP}
 	routes = append(routes, route)
 	if err := addRoutes(interfaceName, routes); err != nil {
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "file exists") {
+		if err != nil && !netlink.IsExist(err) {
 			log.Printf("addroutes failed with error %v", err)
 			return err
 		}
@@ -0,0 +1,97 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCheckConfigDrift(t *testing.T) {
+	newSubnets := func(prefix string) []SubnetInfo {
+		_, ipNet, _ := net.ParseCIDR(prefix)
+		return []SubnetInfo{SubnetInfo{Prefix: *ipNet}}
+	}
+
+	tests := []struct {
+		name      string
+		recorded  NetworkInfo
+		requested NetworkInfo
+		fields    []string
+	}{
+		{
+			name:      "same config",
+			recorded:  NetworkInfo{Mode: opModeBridge, BridgeName: "azure0", Subnets: newSubnets("10.0.0.0/16")},
+			requested: NetworkInfo{Mode: opModeBridge, BridgeName: "azure0", Subnets: newSubnets("10.0.0.0/16")},
+		},
+		{
+			name:      "default mode requested",
+			recorded:  NetworkInfo{Mode: opModeDefault},
+			requested: NetworkInfo{},
+		},
+		{
+			name:      "default mode requested, other mode recorded",
+			recorded:  NetworkInfo{Mode: opModeBridge},
+			requested: NetworkInfo{},
+			fields:    []string{"mode"},
+		},
+		{
+			name:      "subnet changed",
+			recorded:  NetworkInfo{Mode: opModeBridge, Subnets: newSubnets("10.0.0.0/16")},
+			requested: NetworkInfo{Mode: opModeBridge, Subnets: newSubnets("10.1.0.0/16")},
+			fields:    []string{"subnet"},
+		},
+		{
+			name:      "snat changed",
+			recorded:  NetworkInfo{Mode: opModeBridge},
+			requested: NetworkInfo{Mode: opModeBridge, EnableSnatOnHost: true},
+			fields:    []string{"enableSnatOnHost"},
+		},
+		{
+			name:      "several settings changed",
+			recorded:  NetworkInfo{Mode: opModeBridge, MasterIfName: "eth0", EnableSnatOnHost: true},
+			requested: NetworkInfo{Mode: opModeTransparent, MasterIfName: "eth1"},
+			fields:    []string{"mode", "master", "enableSnatOnHost"},
+		},
+		{
+			name:      "unrecorded settings",
+			recorded:  NetworkInfo{Mode: opModeBridge},
+			requested: NetworkInfo{Mode: opModeBridge, BridgeName: "azure0", Dataplane: "ebpf", SnatIPBlock: "10.64.0.0/16", Subnets: newSubnets("10.0.0.0/16")},
+		},
+		{
+			name:      "unrequested settings",
+			recorded:  NetworkInfo{Mode: opModeBridge, BridgeName: "azure0", Dataplane: "ebpf", Subnets: newSubnets("10.0.0.0/16")},
+			requested: NetworkInfo{Mode: opModeBridge},
+		},
+	}
+
+	for _, test := range tests {
+		test.recorded.Id = "azure"
+		err := CheckConfigDrift(&test.recorded, &test.requested)
+
+		if len(test.fields) == 0 {
+			if err != nil {
+				t.Errorf("TestCheckConfigDrift failed @ %v: unexpected drift %v", test.name, err)
+			}
+			continue
+		}
+
+		driftErr, ok := err.(*ConfigDriftError)
+		if !ok {
+			t.Errorf("TestCheckConfigDrift failed @ %v: expected a ConfigDriftError, got %v", test.name, err)
+			continue
+		}
+
+		if driftErr.NetworkId != "azure" || len(driftErr.Drifts) != len(test.fields) {
+			t.Errorf("TestCheckConfigDrift failed @ %v: unexpected drift %v", test.name, err)
+			continue
+		}
+
+		for i, field := range test.fields {
+			if driftErr.Drifts[i].Field != field {
+				t.Errorf("TestCheckConfigDrift failed @ %v: drift %d is %+v, expected field %v", test.name, i, driftErr.Drifts[i], field)
+			}
+		}
+	}
+}
@@ -55,6 +55,20 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		vlanid = (int)(vlanPolicy.VLAN)
 	}
 
+	if nwInfo.Nat64 != nil {
+		return nil, errNat64NotSupported
+	}
+
+	// The dataplane of Windows networks is always HNS.
+	if nwInfo.Dataplane != "" && nwInfo.Dataplane != DataplaneLinuxBridge {
+		return nil, errNetworkDataplaneInvalid
+	}
+
+	// SNAT on host is not supported on Windows.
+	if nwInfo.SnatIPBlock != "" {
+		return nil, errSnatIPBlockInvalid
+	}
+
 	// Set network mode.
 	switch nwInfo.Mode {
 	case opModeBridge:
@@ -65,6 +79,10 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		return nil, errNetworkModeInvalid
 	}
 
+	if nwInfo.Arp != nil {
+		log.Printf("[net] ARP configuration is not supported on Windows, ignoring it.")
+	}
+
 	// Populate subnets.
 	for _, subnet := range nwInfo.Subnets {
 		hnsSubnet := hcsshim.Subnet{
@@ -84,7 +102,18 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 
 	// Create the HNS network.
 	log.Printf("[net] HNSNetworkRequest POST request:%+v", hnsRequest)
-	hnsResponse, err := hcsshim.HNSNetworkRequest("POST", "", hnsRequest)
+	var hnsResponse *hcsshim.HNSNetwork
+	err = RetryHnsCreate("CreateNetwork", func() (err error) {
+		hnsResponse, err = hcsshim.HNSNetworkRequest("POST", "", hnsRequest)
+		return err
+	}, func() bool {
+		existing, err := hcsshim.GetHNSNetworkByName(hnsNetwork.Name)
+		if err != nil || existing == nil {
+			return false
+		}
+		hnsResponse = existing
+		return true
+	})
 	log.Printf("[net] HNSNetworkRequest POST response:%+v err:%v.", hnsResponse, err)
 	if err != nil {
 		return nil, err
@@ -116,7 +145,11 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 	// Delete the HNS network.
 	log.Printf("[net] HNSNetworkRequest DELETE id:%v", nw.HnsId)
-	hnsResponse, err := hcsshim.HNSNetworkRequest("DELETE", nw.HnsId, "")
+	var hnsResponse *hcsshim.HNSNetwork
+	err := RetryHnsDelete("DeleteNetwork", func() (err error) {
+		hnsResponse, err = hcsshim.HNSNetworkRequest("DELETE", nw.HnsId, "")
+		return err
+	})
 	log.Printf("[net] HNSNetworkRequest DELETE response:%+v err:%v.", hnsResponse, err)
 
 	return err
@@ -124,3 +157,7 @@ func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 
 func getNetworkInfoImpl(nwInfo *NetworkInfo, nw *network) {
 }
+
+// reconcileL2Rules is a no-op on Windows, HNS programs the networks.
+func (nm *networkManager) reconcileL2Rules() {
+}
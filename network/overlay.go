@@ -0,0 +1,99 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	// IANA assigned VXLAN UDP port, used when the port is not set.
+	OverlayDefaultPort = 4789
+
+	// Bytes added by the VXLAN, UDP, IPv4 and inner Ethernet headers to the packets of the pods.
+	vxlanOverhead = 50
+
+	// Largest VXLAN network identifier.
+	maxVNI = 1<<24 - 1
+)
+
+// OverlayConfig configures overlay mode networks, whose pods get addresses from a private CIDR instead of the VNET.
+// Traffic between the pods of different nodes is VXLAN encapsulated between the addresses of the nodes,
+// and traffic to other destinations is masqueraded to the address of the node.
+type OverlayConfig struct {
+	VNI         int
+	Port        int    `json:",omitempty"`
+	MTU         int    `json:",omitempty"`
+	ClusterCIDR string `json:",omitempty"`
+}
+
+// OverlayRoute is the pod subnet of a node of an overlay network, reached through the VXLAN tunnel to the node.
+type OverlayRoute struct {
+	NodeIP  net.IP
+	PodCIDR net.IPNet
+}
+
+// validate returns an error if the overlay configuration is invalid.
+func (cfg *OverlayConfig) validate() error {
+	if cfg.VNI <= 0 || cfg.VNI > maxVNI {
+		return fmt.Errorf("%v: VNI %v is not between 1 and %v", errOverlayConfigInvalid, cfg.VNI, maxVNI)
+	}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return fmt.Errorf("%v: invalid port %v", errOverlayConfigInvalid, cfg.Port)
+	}
+
+	if cfg.ClusterCIDR != "" {
+		if _, _, err := net.ParseCIDR(cfg.ClusterCIDR); err != nil {
+			return fmt.Errorf("%v: invalid cluster CIDR %v", errOverlayConfigInvalid, cfg.ClusterCIDR)
+		}
+	}
+
+	return nil
+}
+
+// getPort returns the UDP port of the VXLAN tunnels.
+func (cfg *OverlayConfig) getPort() int {
+	if cfg.Port == 0 {
+		return OverlayDefaultPort
+	}
+
+	return cfg.Port
+}
+
+// getOverlayDeviceName returns the name of the VXLAN device of the given VNI.
+func getOverlayDeviceName(vni int) string {
+	return fmt.Sprintf("azvx%d", vni)
+}
+
+// getOverlayMac returns the MAC address of the VXLAN device of the node with the given address.
+// Deriving it from the address of the node lets nodes program the tunnels to each other from their addresses only.
+func getOverlayMac(nodeIP net.IP) net.HardwareAddr {
+	ip := nodeIP.To4()
+	return net.HardwareAddr{0x0a, 0x58, ip[0], ip[1], ip[2], ip[3]}
+}
+
+// getOverlayGateway returns the address of the VXLAN device of the node owning a pod subnet, its first address.
+func getOverlayGateway(podCIDR *net.IPNet) net.IP {
+	gw := make(net.IP, net.IPv4len)
+	copy(gw, podCIDR.IP.To4())
+	gw[3]++
+	return gw
+}
+
+// overlayRoutesEqual returns whether both sorted lists have the same routes.
+func overlayRoutesEqual(routes []OverlayRoute, other []OverlayRoute) bool {
+	if len(routes) != len(other) {
+		return false
+	}
+
+	for i := range routes {
+		if !routes[i].NodeIP.Equal(other[i].NodeIP) || routes[i].PodCIDR.String() != other[i].PodCIDR.String() {
+			return false
+		}
+	}
+
+	return true
+}
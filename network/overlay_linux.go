@@ -0,0 +1,243 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"golang.org/x/sys/unix"
+)
+
+// addOverlay creates the VXLAN device of an overlay network on its external interface. The device holds the first
+// address of each pod subnet of the node, the gateway of its pods, and its MTU leaves room for the VXLAN headers.
+// Traffic of the pods leaving the cluster CIDR is masqueraded to the address of the node.
+func (nw *network) addOverlay(cfg *OverlayConfig, subnets []SubnetInfo, routes []OverlayRoute) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	hostIf, err := net.InterfaceByName(nw.extIf.Name)
+	if err != nil {
+		return err
+	}
+
+	localIP, err := getOverlayLocalIP(hostIf)
+	if err != nil {
+		return err
+	}
+
+	overlay := *cfg
+	overlay.Port = cfg.getPort()
+	if overlay.MTU == 0 {
+		overlay.MTU = hostIf.MTU - vxlanOverhead
+	}
+
+	device := getOverlayDeviceName(overlay.VNI)
+	log.Printf("[net] Adding overlay device %v with VNI %v on %v, local address %v.", device, overlay.VNI, hostIf.Name, localIP)
+
+	nw.Overlay = &overlay
+	nw.OverlayLocalIP = localIP
+	defer func() {
+		if err != nil {
+			nw.deleteOverlay(subnets)
+		}
+	}()
+
+	link := &netlink.VxlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type: netlink.LINK_TYPE_VXLAN,
+			Name: device,
+			MTU:  uint(overlay.MTU),
+		},
+		VNI:          uint32(overlay.VNI),
+		Port:         uint16(overlay.Port),
+		Local:        localIP,
+		VtepDevIndex: hostIf.Index,
+	}
+
+	if err = netlink.AddLink(link); err != nil && !netlink.IsExist(err) {
+		log.Printf("[net] Failed to create overlay device %v, err:%v.", device, err)
+		return err
+	}
+
+	if err = netlink.SetLinkAddress(device, getOverlayMac(localIP)); err != nil {
+		return err
+	}
+
+	if err = netlink.SetLinkState(device, true); err != nil {
+		return err
+	}
+
+	for _, subnet := range subnets {
+		gw := getOverlayGateway(&subnet.Prefix)
+		if err = netlink.AddIpAddress(device, gw, &net.IPNet{IP: gw, Mask: net.CIDRMask(32, 32)}); err != nil && !netlink.IsExist(err) {
+			log.Printf("[net] Failed to add gateway %v to overlay device %v, err:%v.", gw, device, err)
+			return err
+		}
+
+		if err = addIptablesRule("iptables", "nat", "POSTROUTING", getOverlayMasqueradeRule(&overlay, subnet)...); err != nil {
+			return err
+		}
+	}
+
+	if err = platform.SetSysctl("net/ipv4/ip_forward", "1"); err != nil {
+		return err
+	}
+
+	err = nw.updateOverlayRoutesImpl(routes)
+	return err
+}
+
+// deleteOverlay deletes the VXLAN device of an overlay network, with its routes, and the masquerade rules.
+func (nw *network) deleteOverlay(subnets []SubnetInfo) {
+	if nw.Overlay == nil {
+		return
+	}
+
+	device := getOverlayDeviceName(nw.Overlay.VNI)
+	log.Printf("[net] Deleting overlay device %v.", device)
+
+	for _, subnet := range subnets {
+		deleteIptablesRule("iptables", "nat", "POSTROUTING", getOverlayMasqueradeRule(nw.Overlay, subnet)...)
+	}
+
+	if err := netlink.DeleteLink(device); err != nil {
+		log.Printf("[net] Failed to delete overlay device %v, err:%v.", device, err)
+	}
+
+	nw.Overlay = nil
+	nw.OverlayLocalIP = nil
+	nw.OverlayRoutes = nil
+}
+
+// updateOverlayRoutesImpl programs the tunnels to the pod subnets of the other nodes of an overlay network,
+// and deletes the tunnels to the nodes that left or whose address changed. The subnet of each node is routed
+// through its gateway, whose MAC address is derived from the address of the node and forwarded to the node.
+func (nw *network) updateOverlayRoutesImpl(routes []OverlayRoute) error {
+	device := getOverlayDeviceName(nw.Overlay.VNI)
+
+	desired := make(map[string]OverlayRoute)
+	for _, route := range routes {
+		if route.NodeIP.To4() == nil || route.PodCIDR.IP.To4() == nil || route.NodeIP.Equal(nw.OverlayLocalIP) {
+			continue
+		}
+
+		desired[route.PodCIDR.String()] = route
+	}
+
+	for _, route := range nw.OverlayRoutes {
+		if current, ok := desired[route.PodCIDR.String()]; ok && current.NodeIP.Equal(route.NodeIP) {
+			continue
+		}
+
+		deleteOverlayRoute(device, route)
+	}
+
+	var applied []OverlayRoute
+	for _, route := range desired {
+		if err := addOverlayRoute(device, route); err != nil {
+			return err
+		}
+
+		applied = append(applied, route)
+	}
+
+	sort.Slice(applied, func(i, j int) bool { return applied[i].PodCIDR.String() < applied[j].PodCIDR.String() })
+	nw.OverlayRoutes = applied
+
+	log.Printf("[net] Updated overlay device %v with routes to %v nodes.", device, len(applied))
+	return nil
+}
+
+// Programs the tunnel to the pod subnet of a node.
+func addOverlayRoute(device string, route OverlayRoute) error {
+	link, err := net.InterfaceByName(device)
+	if err != nil {
+		return err
+	}
+
+	gw := getOverlayGateway(&route.PodCIDR)
+	mac := getOverlayMac(route.NodeIP)
+
+	if err = netlink.AddOrRemoveFdbEntry(netlink.ADD, device, mac, route.NodeIP); err != nil {
+		log.Printf("[net] Failed to add overlay FDB entry %v to %v, err:%v.", mac, route.NodeIP, err)
+		return err
+	}
+
+	if err = netlink.AddOrRemoveStaticArp(netlink.ADD, device, gw, mac); err != nil {
+		log.Printf("[net] Failed to add overlay neighbor %v at %v, err:%v.", gw, mac, err)
+		return err
+	}
+
+	dst := route.PodCIDR
+	nlRoute := &netlink.Route{
+		Family:    unix.AF_INET,
+		Dst:       &dst,
+		Gw:        gw,
+		LinkIndex: link.Index,
+		Flags:     unix.RTNH_F_ONLINK,
+	}
+
+	if err = netlink.AddIpRoute(nlRoute); err != nil && !netlink.IsExist(err) {
+		log.Printf("[net] Failed to add overlay route %+v, err:%v.", nlRoute, err)
+		return err
+	}
+
+	return nil
+}
+
+// Deletes the tunnel to the pod subnet of a node.
+func deleteOverlayRoute(device string, route OverlayRoute) {
+	link, err := net.InterfaceByName(device)
+	if err != nil {
+		return
+	}
+
+	gw := getOverlayGateway(&route.PodCIDR)
+	mac := getOverlayMac(route.NodeIP)
+	dst := route.PodCIDR
+
+	if err = netlink.DeleteIpRoute(&netlink.Route{Family: unix.AF_INET, Dst: &dst, Gw: gw, LinkIndex: link.Index}); err != nil {
+		log.Printf("[net] Failed to delete overlay route to %v, err:%v.", dst.String(), err)
+	}
+
+	if err = netlink.AddOrRemoveStaticArp(netlink.REMOVE, device, gw, mac); err != nil {
+		log.Printf("[net] Failed to delete overlay neighbor %v, err:%v.", gw, err)
+	}
+
+	if err = netlink.AddOrRemoveFdbEntry(netlink.REMOVE, device, mac, route.NodeIP); err != nil {
+		log.Printf("[net] Failed to delete overlay FDB entry %v, err:%v.", mac, err)
+	}
+}
+
+// Returns the rule masquerading the traffic of a pod subnet leaving the cluster CIDR, or the subnet if not set.
+func getOverlayMasqueradeRule(cfg *OverlayConfig, subnet SubnetInfo) []string {
+	destination := cfg.ClusterCIDR
+	if destination == "" {
+		destination = subnet.Prefix.String()
+	}
+
+	return []string{"-s", subnet.Prefix.String(), "!", "-d", destination, "-j", "MASQUERADE"}
+}
+
+// Returns the IPv4 address of the external interface the VXLAN tunnels are terminated on.
+func getOverlayLocalIP(hostIf *net.Interface) (net.IP, error) {
+	addrs, err := hostIf.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err == nil && ip.To4() != nil && ip.IsGlobalUnicast() {
+			return ip.To4(), nil
+		}
+	}
+
+	return nil, errOverlayConfigInvalid
+}
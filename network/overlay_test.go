@@ -0,0 +1,71 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestOverlayConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   OverlayConfig
+		valid bool
+	}{
+		{name: "valid", cfg: OverlayConfig{VNI: 4096, ClusterCIDR: "10.244.0.0/16"}, valid: true},
+		{name: "no VNI", cfg: OverlayConfig{}},
+		{name: "VNI too large", cfg: OverlayConfig{VNI: maxVNI + 1}},
+		{name: "invalid port", cfg: OverlayConfig{VNI: 1, Port: 65536}},
+		{name: "invalid cluster CIDR", cfg: OverlayConfig{VNI: 1, ClusterCIDR: "10.244.0.0"}},
+	}
+
+	for _, test := range tests {
+		if err := test.cfg.validate(); (err == nil) != test.valid {
+			t.Errorf("%v: validate returned %v", test.name, err)
+		}
+	}
+}
+
+func TestGetOverlayGateway(t *testing.T) {
+	_, podCIDR, _ := net.ParseCIDR("10.244.3.0/24")
+
+	if gw := getOverlayGateway(podCIDR); gw.String() != "10.244.3.1" {
+		t.Errorf("getOverlayGateway returned %v", gw)
+	}
+
+	if mac := getOverlayMac(net.ParseIP("10.0.0.4")); mac.String() != "0a:58:0a:00:00:04" {
+		t.Errorf("getOverlayMac returned %v", mac)
+	}
+}
+
+func TestOverlayRoutesEqual(t *testing.T) {
+	newRoute := func(nodeIP string, podCIDR string) OverlayRoute {
+		_, cidr, _ := net.ParseCIDR(podCIDR)
+		return OverlayRoute{NodeIP: net.ParseIP(nodeIP), PodCIDR: *cidr}
+	}
+
+	routes := []OverlayRoute{newRoute("10.0.0.4", "10.244.1.0/24"), newRoute("10.0.0.5", "10.244.2.0/24")}
+
+	// Restored routes hold 16 byte addresses.
+	restored := []OverlayRoute{newRoute("10.0.0.4", "10.244.1.0/24"), newRoute("10.0.0.5", "10.244.2.0/24")}
+	restored[0].PodCIDR.IP = restored[0].PodCIDR.IP.To16()
+
+	tests := []struct {
+		name  string
+		other []OverlayRoute
+		equal bool
+	}{
+		{name: "restored", other: restored, equal: true},
+		{name: "node left", other: routes[:1]},
+		{name: "node address changed", other: []OverlayRoute{routes[0], newRoute("10.0.0.6", "10.244.2.0/24")}},
+		{name: "pod CIDR changed", other: []OverlayRoute{routes[0], newRoute("10.0.0.5", "10.244.3.0/24")}},
+	}
+
+	for _, test := range tests {
+		if equal := overlayRoutesEqual(routes, test.other); equal != test.equal {
+			t.Errorf("%v: overlayRoutesEqual returned %v", test.name, equal)
+		}
+	}
+}
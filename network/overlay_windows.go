@@ -0,0 +1,10 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+// updateOverlayRoutesImpl programs the tunnels to the other nodes of an overlay network.
+// Overlay networks are not supported on Windows.
+func (nw *network) updateOverlayRoutesImpl(routes []OverlayRoute) error {
+	return errOverlayNotSupported
+}
@@ -8,7 +8,7 @@ import (
 
 func NewSnatClient(client *OVSEndpointClient, epInfo *EndpointInfo) {
 	if client.enableSnatOnHost {
-		var localIP, snatBridgeIP string
+		var localIP, snatBridgeIP, snatIP string
 
 		hostIfName := fmt.Sprintf("%s%s", snatVethInterfacePrefix, epInfo.Id[:7])
 		contIfName := fmt.Sprintf("%s%s-2", snatVethInterfacePrefix, epInfo.Id[:7])
@@ -21,7 +21,11 @@ func NewSnatClient(client *OVSEndpointClient, epInfo *EndpointInfo) {
 			snatBridgeIP = epInfo.Data[SnatBridgeIPKey].(string)
 		}
 
-		client.snatClient = ovssnat.NewSnatClient(hostIfName, contIfName, localIP, snatBridgeIP, epInfo.DNS.Servers)
+		if _, ok := epInfo.Data[SnatIPKey]; ok {
+			snatIP = epInfo.Data[SnatIPKey].(string)
+		}
+
+		client.snatClient = ovssnat.NewSnatClient(hostIfName, contIfName, localIP, snatBridgeIP, snatIP, epInfo.DNS.Servers)
 	}
 }
 
@@ -39,12 +43,22 @@ func AddSnatEndpointRules(client *OVSEndpointClient) error {
 			return err
 		}
 
+		if err := client.snatClient.AddEndpointSnatRule(); err != nil {
+			return err
+		}
+
 		return AddStaticRoute(ovssnat.ImdsIP, client.bridgeName)
 	}
 
 	return nil
 }
 
+func DeleteSnatEndpointRules(client *OVSEndpointClient) {
+	if client.enableSnatOnHost {
+		client.snatClient.DeleteEndpointSnatRule()
+	}
+}
+
 func MoveSnatEndpointToContainerNS(client *OVSEndpointClient, netnsPath string, nsID uintptr) error {
 	if client.enableSnatOnHost {
 		return client.snatClient.MoveSnatEndpointToContainerNS(netnsPath, nsID)
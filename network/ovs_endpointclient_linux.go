@@ -68,7 +68,10 @@ func (client *OVSEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
 
 	client.containerMac = containerIf.HardwareAddr.String()
 
-	if err := AddSnatEndpoint(client); err != nil {
+	stopSnat := epInfo.startStage(StageSnat)
+	err = AddSnatEndpoint(client)
+	stopSnat()
+	if err != nil {
 		return err
 	}
 
@@ -101,7 +104,10 @@ func (client *OVSEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
 
 	// IP SNAT Rule
 	log.Printf("[ovs] Adding IP SNAT rule for egress traffic on %v.", containerPort)
-	if err := ovsctl.AddIpSnatRule(client.bridgeName, containerPort, client.hostPrimaryMac, ""); err != nil {
+	stopSnat := epInfo.startStage(StageSnat)
+	err = ovsctl.AddIpSnatRule(client.bridgeName, containerPort, client.hostPrimaryMac, "")
+	stopSnat()
+	if err != nil {
 		return err
 	}
 
@@ -123,6 +129,8 @@ func (client *OVSEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
 		return err
 	}
 
+	defer epInfo.startStage(StageSnat)()
+
 	return AddSnatEndpointRules(client)
 }
 
@@ -156,6 +164,7 @@ func (client *OVSEndpointClient) DeleteEndpointRules(ep *endpoint) {
 	ovsctl.DeletePortFromOVS(client.bridgeName, client.hostVethName)
 
 	DeleteInfraVnetEndpointRules(client, ep, hostPort)
+	DeleteSnatEndpointRules(client)
 }
 
 func (client *OVSEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
@@ -165,7 +174,10 @@ func (client *OVSEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo
 		return err
 	}
 
-	if err := MoveSnatEndpointToContainerNS(client, epInfo.NetNsPath, nsID); err != nil {
+	stopSnat := epInfo.startStage(StageSnat)
+	err := MoveSnatEndpointToContainerNS(client, epInfo.NetNsPath, nsID)
+	stopSnat()
+	if err != nil {
 		return err
 	}
 
@@ -181,7 +193,10 @@ func (client *OVSEndpointClient) SetupContainerInterfaces(epInfo *EndpointInfo)
 
 	client.containerVethName = epInfo.IfName
 
-	if err := SetupSnatContainerInterface(client); err != nil {
+	stopSnat := epInfo.startStage(StageSnat)
+	err := SetupSnatContainerInterface(client)
+	stopSnat()
+	if err != nil {
 		return err
 	}
 
@@ -193,7 +208,10 @@ func (client *OVSEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *E
 		return err
 	}
 
-	if err := ConfigureSnatContainerInterface(client); err != nil {
+	stopSnat := epInfo.startStage(StageSnat)
+	err := ConfigureSnatContainerInterface(client)
+	stopSnat()
+	if err != nil {
 		return err
 	}
 
@@ -1,7 +1,6 @@
 package ovssnat
 
 import (
-	"fmt"
 	"net"
 	"strings"
 
@@ -25,16 +24,18 @@ type OVSSnatClient struct {
 	containerSnatVethName  string
 	localIP                string
 	snatBridgeIP           string
+	snatIP                 string
 	SkipAddressesFromBlock []string
 }
 
-func NewSnatClient(hostIfName string, contIfName string, localIP string, snatBridgeIP string, skipAddressesFromBlock []string) OVSSnatClient {
+func NewSnatClient(hostIfName string, contIfName string, localIP string, snatBridgeIP string, snatIP string, skipAddressesFromBlock []string) OVSSnatClient {
 	log.Printf("Initialize new snat client")
 	snatClient := OVSSnatClient{}
 	snatClient.hostSnatVethName = hostIfName
 	snatClient.containerSnatVethName = contIfName
 	snatClient.localIP = localIP
 	snatClient.snatBridgeIP = snatBridgeIP
+	snatClient.snatIP = snatIP
 
 	for _, address := range skipAddressesFromBlock {
 		snatClient.SkipAddressesFromBlock = append(snatClient.SkipAddressesFromBlock, address)
@@ -78,6 +79,53 @@ func (client *OVSSnatClient) AddPrivateIPBlockRule() error {
 	return nil
 }
 
+// AddEndpointSnatRule translates the traffic of the endpoint to its own SNAT IP instead of the host IP.
+// The rule is inserted ahead of the masquerade rule of the snat bridge.
+func (client *OVSSnatClient) AddEndpointSnatRule() error {
+	if client.snatIP == "" {
+		return nil
+	}
+
+	localIP, _, err := net.ParseCIDR(client.localIP)
+	if err != nil {
+		log.Printf("Invalid local IP %v for snat rule: %v", client.localIP, err)
+		return err
+	}
+
+	_, err = platform.ExecWithTimeout("iptables", "-t", "nat", "-C", "POSTROUTING",
+		"-s", localIP.String(), "-j", "SNAT", "--to-source", client.snatIP)
+	if err == nil {
+		log.Printf("iptable endpoint snat rule already exists")
+		return nil
+	}
+
+	log.Printf("Adding iptable endpoint snat rule for %v", localIP)
+	_, err = platform.ExecWithTimeout("iptables", "-t", "nat", "-I", "POSTROUTING", "1",
+		"-s", localIP.String(), "-j", "SNAT", "--to-source", client.snatIP)
+	return err
+}
+
+func (client *OVSSnatClient) DeleteEndpointSnatRule() error {
+	if client.snatIP == "" {
+		return nil
+	}
+
+	localIP, _, err := net.ParseCIDR(client.localIP)
+	if err != nil {
+		log.Printf("Invalid local IP %v for snat rule: %v", client.localIP, err)
+		return err
+	}
+
+	log.Printf("Deleting iptable endpoint snat rule for %v", localIP)
+	_, err = platform.ExecWithTimeout("iptables", "-t", "nat", "-D", "POSTROUTING",
+		"-s", localIP.String(), "-j", "SNAT", "--to-source", client.snatIP)
+	if err != nil {
+		log.Printf("Deleting iptable endpoint snat rule failed with error %v", err)
+	}
+
+	return err
+}
+
 func (client *OVSSnatClient) MoveSnatEndpointToContainerNS(netnsPath string, nsID uintptr) error {
 	log.Printf("[ovs] Setting link %v netns %v.", client.containerSnatVethName, netnsPath)
 	return netlink.SetLinkNetNs(client.containerSnatVethName, nsID)
@@ -183,8 +231,7 @@ func CreateSnatBridge(snatBridgeIP string, mainInterface string) error {
 }
 
 func DeleteSnatBridge(bridgeName string) error {
-	cmd := "ebtables -t nat -D PREROUTING -p 802_1Q -j DROP"
-	_, err := platform.ExecuteCommand(cmd)
+	_, err := platform.ExecWithTimeout("ebtables", "-t", "nat", "-D", "PREROUTING", "-p", "802_1Q", "-j", "DROP")
 	if err != nil {
 		log.Printf("Deleting ebtable vlan drop rule failed with error %v", err)
 	}
@@ -208,16 +255,14 @@ func DeleteSnatBridge(bridgeName string) error {
 
 func AddMasqueradeRule(snatBridgeIPWithPrefix string) error {
 	_, ipNet, _ := net.ParseCIDR(snatBridgeIPWithPrefix)
-	cmd := fmt.Sprintf("iptables -t nat -C POSTROUTING -s %v -j MASQUERADE", ipNet.String())
-	_, err := platform.ExecuteCommand(cmd)
+	_, err := platform.ExecWithTimeout("iptables", "-t", "nat", "-C", "POSTROUTING", "-s", ipNet.String(), "-j", "MASQUERADE")
 	if err == nil {
 		log.Printf("iptable snat rule already exists")
 		return nil
 	}
 
-	cmd = fmt.Sprintf("iptables -t nat -A POSTROUTING -s %v -j MASQUERADE", ipNet.String())
-	log.Printf("Adding iptable snat rule %v", cmd)
-	_, err = platform.ExecuteCommand(cmd)
+	log.Printf("Adding iptable snat rule for %v", ipNet)
+	_, err = platform.ExecWithTimeout("iptables", "-t", "nat", "-A", "POSTROUTING", "-s", ipNet.String(), "-j", "MASQUERADE")
 	return err
 }
 
@@ -236,9 +281,8 @@ func DeleteMasqueradeRule() error {
 		}
 
 		if ipAddr.To4() != nil {
-			cmd := fmt.Sprintf("iptables -t nat -D POSTROUTING -s %v -j MASQUERADE", ipNet.String())
-			log.Printf("Deleting iptable snat rule %v", cmd)
-			_, err = platform.ExecuteCommand(cmd)
+			log.Printf("Deleting iptable snat rule for %v", ipNet)
+			_, err = platform.ExecWithTimeout("iptables", "-t", "nat", "-D", "POSTROUTING", "-s", ipNet.String(), "-j", "MASQUERADE")
 			return err
 		}
 	}
@@ -247,21 +291,19 @@ func DeleteMasqueradeRule() error {
 }
 
 func AddVlanDropRule() error {
-	cmd := "ebtables -t nat -L PREROUTING"
-	out, err := platform.ExecuteCommand(cmd)
+	result, err := platform.ExecWithTimeout("ebtables", "-t", "nat", "-L", "PREROUTING")
 	if err != nil {
 		log.Printf("Error while listing ebtable rules %v", err)
 		return err
 	}
 
-	out = strings.TrimSpace(out)
+	out := strings.TrimSpace(result.Stdout)
 	if strings.Contains(out, "-p 802_1Q -j DROP") {
 		log.Printf("vlan drop rule already exists")
 		return nil
 	}
 
-	cmd = "ebtables -t nat -A PREROUTING -p 802_1Q -j DROP"
-	log.Printf("Adding ebtable rule to drop vlan traffic on snat bridge %v", cmd)
-	_, err = platform.ExecuteCommand(cmd)
+	log.Printf("Adding ebtable rule to drop vlan traffic on snat bridge")
+	_, err = platform.ExecWithTimeout("ebtables", "-t", "nat", "-A", "PREROUTING", "-p", "802_1Q", "-j", "DROP")
 	return err
 }
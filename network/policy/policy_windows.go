@@ -9,11 +9,18 @@ import (
 )
 
 // SerializePolicies serializes policies to json.
+// OutBoundNAT policies are merged into a single policy, as HNS applies only one per endpoint.
 func SerializePolicies(policyType CNIPolicyType, policies []Policy, epInfoData map[string]interface{}) []json.RawMessage {
 	var jsonPolicies []json.RawMessage
+	var outBoundNatSerialized bool
 	for _, policy := range policies {
 		if policy.Type == policyType {
 			if isPolicyTypeOutBoundNAT := IsPolicyTypeOutBoundNAT(policy); isPolicyTypeOutBoundNAT {
+				if outBoundNatSerialized {
+					continue
+				}
+
+				outBoundNatSerialized = true
 				if serializedOutboundNatPolicy, err := SerializeOutBoundNATPolicy(policies, epInfoData); err != nil {
 					log.Printf("Failed to serialize OutBoundNAT policy")
 				} else {
@@ -28,12 +35,16 @@ func SerializePolicies(policyType CNIPolicyType, policies []Policy, epInfoData m
 }
 
 // GetOutBoundNatExceptionList returns exception list for outbound nat policy
+// The exception lists of all OutBoundNAT policies are combined.
 func GetOutBoundNatExceptionList(policies []Policy) ([]string, error) {
 	type KVPair struct {
 		Type          CNIPolicyType   `json:"Type"`
 		ExceptionList json.RawMessage `json:"ExceptionList"`
 	}
 
+	var exceptionList []string
+	var found bool
+
 	for _, policy := range policies {
 		if policy.Type == EndpointPolicy {
 			var data KVPair
@@ -42,18 +53,22 @@ func GetOutBoundNatExceptionList(policies []Policy) ([]string, error) {
 			}
 
 			if data.Type == OutBoundNatPolicy {
-				var exceptionList []string
-				if err := json.Unmarshal(data.ExceptionList, &exceptionList); err != nil {
+				var exceptions []string
+				if err := json.Unmarshal(data.ExceptionList, &exceptions); err != nil {
 					return nil, err
 				}
 
-				return exceptionList, nil
+				exceptionList = append(exceptionList, exceptions...)
+				found = true
 			}
 		}
 	}
 
-	log.Printf("OutBoundNAT policy not set")
-	return nil, nil
+	if !found {
+		log.Printf("OutBoundNAT policy not set")
+	}
+
+	return exceptionList, nil
 }
 
 // IsPolicyTypeOutBoundNAT return true if the policy type is OutBoundNAT
@@ -0,0 +1,190 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Routing tables of external interfaces with policy routing are numbered from this base plus the interface index.
+	policyRoutingTableBase = 1000
+
+	// Priority of the rules selecting the routing table of an external interface, ahead of the main table.
+	policyRoutingRulePriority = 2000
+)
+
+// Returns the name of the interface holding the addresses of the external interface.
+// Bridge mode networks move them to the bridge.
+func (extIf *externalInterface) getRoutingIfName() string {
+	if extIf.BridgeName != "" {
+		return extIf.BridgeName
+	}
+
+	return extIf.Name
+}
+
+// addPolicyRoutes sets up the routing table of the external interface of a network, so that traffic from its
+// endpoints and from the addresses of the interface egresses the interface instead of following the main table.
+// The table holds the routes to the subnets of the network and the default route through their gateway.
+func (nw *network) addPolicyRoutes(subnets []SubnetInfo) error {
+	ifName := nw.extIf.getRoutingIfName()
+	hostIf, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+
+	table := policyRoutingTableBase + hostIf.Index
+	log.Printf("[net] Adding policy routes of interface %v to table %v.", ifName, table)
+
+	for _, subnet := range subnets {
+		family := netlink.GetIpAddressFamily(subnet.Prefix.IP)
+		prefix := subnet.Prefix
+
+		routes := []*netlink.Route{
+			&netlink.Route{
+				Family:    family,
+				Dst:       &prefix,
+				Table:     table,
+				Scope:     unix.RT_SCOPE_LINK,
+				LinkIndex: hostIf.Index,
+			},
+		}
+
+		if subnet.Gateway != nil {
+			routes = append(routes, &netlink.Route{
+				Family:    family,
+				Gw:        subnet.Gateway,
+				Table:     table,
+				LinkIndex: hostIf.Index,
+			})
+		}
+
+		for _, route := range routes {
+			if err = netlink.AddIpRoute(route); err != nil && !netlink.IsExist(err) {
+				log.Printf("[net] Failed to add policy route %+v, err:%v.", route, err)
+				return err
+			}
+		}
+	}
+
+	// Traffic from the node itself on the interface, e.g. storage or management traffic, uses the table too.
+	addrs, _ := hostIf.Addrs()
+	for _, addr := range addrs {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err != nil || !ip.IsGlobalUnicast() || !isInSubnets(ip, subnets) {
+			continue
+		}
+
+		if err = addPolicyRule(table, ip); err != nil {
+			return err
+		}
+	}
+
+	nw.RoutingTable = table
+	return nil
+}
+
+// deletePolicyRoutes deletes the routing table of the external interface of a network, and its rules.
+func (nw *network) deletePolicyRoutes() {
+	if nw.RoutingTable == 0 {
+		return
+	}
+
+	log.Printf("[net] Deleting policy routes of table %v.", nw.RoutingTable)
+
+	for _, family := range []int{unix.AF_INET, unix.AF_INET6} {
+		rules, _ := netlink.GetIpRule(&netlink.Rule{Family: family, Table: nw.RoutingTable})
+		for _, rule := range rules {
+			if err := netlink.DeleteIpRule(rule); err != nil {
+				log.Printf("[net] Failed to delete policy rule %+v, err:%v.", rule, err)
+			}
+		}
+
+		routes, _ := netlink.GetIpRoute(&netlink.Route{Family: family, Table: nw.RoutingTable})
+		for _, route := range routes {
+			if route.Table != nw.RoutingTable {
+				continue
+			}
+
+			if err := netlink.DeleteIpRoute(route); err != nil {
+				log.Printf("[net] Failed to delete policy route %+v, err:%v.", route, err)
+			}
+		}
+	}
+}
+
+// addEndpointPolicyRules sends the traffic from the addresses of an endpoint to the routing table of the network.
+func (nw *network) addEndpointPolicyRules(ipAddresses []net.IPNet) error {
+	if nw.RoutingTable == 0 {
+		return nil
+	}
+
+	for _, ipAddr := range ipAddresses {
+		if err := addPolicyRule(nw.RoutingTable, ipAddr.IP); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteEndpointPolicyRules deletes the rules added by addEndpointPolicyRules.
+func (nw *network) deleteEndpointPolicyRules(ipAddresses []net.IPNet) {
+	if nw.RoutingTable == 0 {
+		return
+	}
+
+	for _, ipAddr := range ipAddresses {
+		rule := newPolicyRule(nw.RoutingTable, ipAddr.IP)
+		if err := netlink.DeleteIpRule(rule); err != nil {
+			log.Printf("[net] Failed to delete policy rule %+v, err:%v.", rule, err)
+		}
+	}
+}
+
+// Returns the rule looking up the given table for traffic from the given address.
+func newPolicyRule(table int, ip net.IP) *netlink.Rule {
+	family := netlink.GetIpAddressFamily(ip)
+	bits := 8 * net.IPv6len
+	if family == unix.AF_INET {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+
+	return &netlink.Rule{
+		Family:   family,
+		Table:    table,
+		Priority: policyRoutingRulePriority,
+		Src:      &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)},
+	}
+}
+
+// Adds the rule looking up the given table for traffic from the given address.
+func addPolicyRule(table int, ip net.IP) error {
+	rule := newPolicyRule(table, ip)
+	log.Printf("[net] Adding policy rule from %v lookup %v.", rule.Src, table)
+
+	if err := netlink.AddIpRule(rule); err != nil && !netlink.IsExist(err) {
+		log.Printf("[net] Failed to add policy rule %+v, err:%v.", rule, err)
+		return err
+	}
+
+	return nil
+}
+
+// Returns whether the address is in one of the subnets.
+func isInSubnets(ip net.IP, subnets []SubnetInfo) bool {
+	for _, subnet := range subnets {
+		if subnet.Prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// Protocol of port mappings that don't set one.
+	portMappingDefaultProtocol = "tcp"
+)
+
+// PortMapping forwards a port of the node to a port of an endpoint, e.g. the hostPort of a pod.
+// An empty HostIP maps the port on all addresses of the node.
+type PortMapping struct {
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+	HostIP        net.IP `json:",omitempty"`
+}
+
+// validate returns an error if the port mapping is invalid, and sets its default protocol.
+func (mapping *PortMapping) validate() error {
+	if mapping.HostPort <= 0 || mapping.HostPort > 65535 || mapping.ContainerPort <= 0 || mapping.ContainerPort > 65535 {
+		return fmt.Errorf("%v: invalid port in %v", errPortMappingInvalid, mapping)
+	}
+
+	mapping.Protocol = strings.ToLower(mapping.Protocol)
+	switch mapping.Protocol {
+	case "":
+		mapping.Protocol = portMappingDefaultProtocol
+	case "tcp", "udp", "sctp":
+	default:
+		return fmt.Errorf("%v: invalid protocol in %v", errPortMappingInvalid, mapping)
+	}
+
+	return nil
+}
+
+// conflicts returns whether two port mappings use the same port of the node.
+func (mapping *PortMapping) conflicts(other *PortMapping) bool {
+	if mapping.HostPort != other.HostPort || mapping.Protocol != other.Protocol {
+		return false
+	}
+
+	return mapping.HostIP == nil || other.HostIP == nil || mapping.HostIP.Equal(other.HostIP)
+}
+
+// String returns the port mapping in the format of the runtime, e.g. 0.0.0.0:8080->80/tcp.
+func (mapping *PortMapping) String() string {
+	hostIP := "0.0.0.0"
+	if mapping.HostIP != nil {
+		hostIP = mapping.HostIP.String()
+	}
+
+	return fmt.Sprintf("%v->%d/%v", net.JoinHostPort(hostIP, fmt.Sprint(mapping.HostPort)), mapping.ContainerPort, mapping.Protocol)
+}
+
+// Returns an error if a port mapping of a new endpoint is invalid, or uses a port of the node already mapped to
+// another endpoint of any network, or to the endpoint itself.
+func (nm *networkManager) validatePortMappings(epInfo *EndpointInfo) error {
+	for i := range epInfo.PortMappings {
+		mapping := &epInfo.PortMappings[i]
+		if err := mapping.validate(); err != nil {
+			return err
+		}
+
+		for j := 0; j < i; j++ {
+			if mapping.conflicts(&epInfo.PortMappings[j]) {
+				return fmt.Errorf("%v: %v conflicts with %v", errPortMappingConflict, mapping, &epInfo.PortMappings[j])
+			}
+		}
+
+		for _, extIf := range nm.ExternalInterfaces {
+			for _, nw := range extIf.Networks {
+				for _, ep := range nw.Endpoints {
+					for k := range ep.PortMappings {
+						if ep.Id != epInfo.Id && mapping.conflicts(&ep.PortMappings[k]) {
+							return fmt.Errorf("%v: %v is mapped to endpoint %v", errPortMappingConflict, mapping, ep.Id)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Chain holding the DNAT rules of the port mappings of all endpoints.
+	portMappingChain = "AZURE-HOSTPORTS"
+)
+
+// Returns the iptables binary for the family of an address.
+func getIptablesBinary(ip net.IP) string {
+	if ip.To4() != nil {
+		return "iptables"
+	}
+
+	return "ip6tables"
+}
+
+// Creates the chain of the port mappings, and jumps to it for packets to the addresses of the node, whether they
+// come from outside or from the node itself.
+func addPortMappingChain(binary string) error {
+	if _, err := platform.ExecWithTimeout(binary, "-t", "nat", "-N", portMappingChain); err != nil {
+		if _, err = platform.ExecWithTimeout(binary, "-t", "nat", "-L", portMappingChain); err != nil {
+			return err
+		}
+	}
+
+	for _, chain := range []string{"PREROUTING", "OUTPUT"} {
+		if err := addIptablesRule(binary, "nat", chain, "-m", "addrtype", "--dst-type", "LOCAL", "-j", portMappingChain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the DNAT rule of a port mapping to an address of an endpoint.
+func getPortMappingRule(endpointId string, ip net.IP, mapping *PortMapping) []string {
+	var rule []string
+	if mapping.HostIP != nil {
+		rule = append(rule, "-d", mapping.HostIP.String())
+	}
+
+	destination := net.JoinHostPort(ip.String(), fmt.Sprint(mapping.ContainerPort))
+	return append(rule,
+		"-p", mapping.Protocol, "--dport", fmt.Sprint(mapping.HostPort),
+		"-m", "comment", "--comment", endpointId,
+		"-j", "DNAT", "--to-destination", destination)
+}
+
+// Returns the rule masquerading the packets of an endpoint to its own mapped port, so that the replies are sent
+// back through the node instead of directly to itself.
+func getPortMappingHairpinRule(endpointId string, ip net.IP, mapping *PortMapping) []string {
+	return []string{
+		"-s", ip.String(), "-d", ip.String(),
+		"-p", mapping.Protocol, "--dport", fmt.Sprint(mapping.ContainerPort),
+		"-m", "comment", "--comment", endpointId,
+		"-j", "MASQUERADE",
+	}
+}
+
+// Returns the addresses of an endpoint a port mapping applies to, those of the family of its host address.
+func getPortMappingAddresses(ipAddresses []net.IPNet, mapping *PortMapping) []net.IP {
+	var ips []net.IP
+	for _, ipAddr := range ipAddresses {
+		if mapping.HostIP != nil && (mapping.HostIP.To4() == nil) != (ipAddr.IP.To4() == nil) {
+			continue
+		}
+		ips = append(ips, ipAddr.IP)
+	}
+
+	return ips
+}
+
+// addPortMappings forwards the mapped ports of the node to the addresses of an endpoint.
+func (nw *network) addPortMappings(endpointId string, ipAddresses []net.IPNet, mappings []PortMapping) error {
+	for i := range mappings {
+		mapping := &mappings[i]
+		log.Printf("[net] Adding port mapping %v to endpoint %v.", mapping, endpointId)
+
+		for _, ip := range getPortMappingAddresses(ipAddresses, mapping) {
+			binary := getIptablesBinary(ip)
+			if err := addPortMappingChain(binary); err != nil {
+				return err
+			}
+
+			if err := addIptablesRule(binary, "nat", portMappingChain, getPortMappingRule(endpointId, ip, mapping)...); err != nil {
+				return err
+			}
+
+			if err := addIptablesRule(binary, "nat", "POSTROUTING", getPortMappingHairpinRule(endpointId, ip, mapping)...); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deletePortMappings deletes the rules of the port mappings of an endpoint. The chain is kept for other endpoints.
+func (nw *network) deletePortMappings(endpointId string, ipAddresses []net.IPNet, mappings []PortMapping) {
+	for i := range mappings {
+		mapping := &mappings[i]
+		log.Printf("[net] Deleting port mapping %v of endpoint %v.", mapping, endpointId)
+
+		for _, ip := range getPortMappingAddresses(ipAddresses, mapping) {
+			binary := getIptablesBinary(ip)
+			deleteIptablesRule(binary, "nat", portMappingChain, getPortMappingRule(endpointId, ip, mapping)...)
+			deleteIptablesRule(binary, "nat", "POSTROUTING", getPortMappingHairpinRule(endpointId, ip, mapping)...)
+		}
+	}
+}
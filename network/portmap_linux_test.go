@@ -0,0 +1,73 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestGetPortMappingRules(t *testing.T) {
+	ip := net.ParseIP("10.240.0.7")
+	mapping := &PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", HostIP: net.ParseIP("10.0.0.4")}
+
+	expected := []string{
+		"-d", "10.0.0.4", "-p", "tcp", "--dport", "8080",
+		"-m", "comment", "--comment", "ep1",
+		"-j", "DNAT", "--to-destination", "10.240.0.7:80",
+	}
+	if rule := getPortMappingRule("ep1", ip, mapping); !reflect.DeepEqual(rule, expected) {
+		t.Errorf("getPortMappingRule returned %v, expected %v", rule, expected)
+	}
+
+	// Mappings on all addresses of the node don't match the destination, IPv6 destinations are bracketed.
+	expected = []string{
+		"-p", "udp", "--dport", "53",
+		"-m", "comment", "--comment", "ep1",
+		"-j", "DNAT", "--to-destination", "[fd00::7]:53",
+	}
+	rule := getPortMappingRule("ep1", net.ParseIP("fd00::7"), &PortMapping{HostPort: 53, ContainerPort: 53, Protocol: "udp"})
+	if !reflect.DeepEqual(rule, expected) {
+		t.Errorf("getPortMappingRule returned %v, expected %v", rule, expected)
+	}
+
+	expected = []string{
+		"-s", "10.240.0.7", "-d", "10.240.0.7", "-p", "tcp", "--dport", "80",
+		"-m", "comment", "--comment", "ep1", "-j", "MASQUERADE",
+	}
+	if rule = getPortMappingHairpinRule("ep1", ip, mapping); !reflect.DeepEqual(rule, expected) {
+		t.Errorf("getPortMappingHairpinRule returned %v, expected %v", rule, expected)
+	}
+}
+
+func TestGetPortMappingAddresses(t *testing.T) {
+	_, ipv4, _ := net.ParseCIDR("10.240.0.7/16")
+	_, ipv6, _ := net.ParseCIDR("fd00::7/64")
+	ipv4.IP, ipv6.IP = net.ParseIP("10.240.0.7"), net.ParseIP("fd00::7")
+	addresses := []net.IPNet{*ipv4, *ipv6}
+
+	tests := []struct {
+		name   string
+		hostIP net.IP
+		ips    []net.IP
+	}{
+		{name: "all addresses of the node", ips: []net.IP{ipv4.IP, ipv6.IP}},
+		{name: "IPv4 host address", hostIP: net.ParseIP("10.0.0.4"), ips: []net.IP{ipv4.IP}},
+		{name: "IPv6 host address", hostIP: net.ParseIP("fd00::4"), ips: []net.IP{ipv6.IP}},
+	}
+
+	for _, test := range tests {
+		ips := getPortMappingAddresses(addresses, &PortMapping{HostPort: 8080, ContainerPort: 80, HostIP: test.hostIP})
+		if !reflect.DeepEqual(ips, test.ips) {
+			t.Errorf("TestGetPortMappingAddresses failed @ %v: %v, expected %v", test.name, ips, test.ips)
+		}
+
+		for _, ip := range ips {
+			if binary := getIptablesBinary(ip); (binary == "iptables") != (ip.To4() != nil) {
+				t.Errorf("TestGetPortMappingAddresses failed @ %v: %v for %v", test.name, binary, ip)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPortMappingValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  PortMapping
+		protocol string
+		valid    bool
+	}{
+		{name: "default protocol", mapping: PortMapping{HostPort: 8080, ContainerPort: 80}, protocol: "tcp", valid: true},
+		{name: "upper case protocol", mapping: PortMapping{HostPort: 53, ContainerPort: 53, Protocol: "UDP"}, protocol: "udp", valid: true},
+		{name: "sctp", mapping: PortMapping{HostPort: 9000, ContainerPort: 9000, Protocol: "sctp"}, protocol: "sctp", valid: true},
+		{name: "no host port", mapping: PortMapping{ContainerPort: 80}},
+		{name: "host port out of range", mapping: PortMapping{HostPort: 65536, ContainerPort: 80}},
+		{name: "negative container port", mapping: PortMapping{HostPort: 8080, ContainerPort: -1}},
+		{name: "unknown protocol", mapping: PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "icmp"}},
+	}
+
+	for _, test := range tests {
+		mapping := test.mapping
+		err := mapping.validate()
+		if (err == nil) != test.valid {
+			t.Errorf("TestPortMappingValidate failed @ %v: validate returned %v, expected valid:%v", test.name, err, test.valid)
+		}
+
+		if test.valid && mapping.Protocol != test.protocol {
+			t.Errorf("TestPortMappingValidate failed @ %v: protocol %v, expected %v", test.name, mapping.Protocol, test.protocol)
+		}
+	}
+}
+
+func TestPortMappingConflicts(t *testing.T) {
+	mapping := PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", HostIP: net.ParseIP("10.0.0.4")}
+
+	tests := []struct {
+		name      string
+		other     PortMapping
+		conflicts bool
+	}{
+		{name: "same host address", other: PortMapping{HostPort: 8080, ContainerPort: 81, Protocol: "tcp", HostIP: net.ParseIP("10.0.0.4")}, conflicts: true},
+		{name: "all host addresses", other: PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}, conflicts: true},
+		{name: "other host address", other: PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", HostIP: net.ParseIP("10.0.0.5")}},
+		{name: "other protocol", other: PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "udp"}},
+		{name: "other host port", other: PortMapping{HostPort: 8081, ContainerPort: 80, Protocol: "tcp"}},
+	}
+
+	for _, test := range tests {
+		if conflicts := mapping.conflicts(&test.other); conflicts != test.conflicts {
+			t.Errorf("TestPortMappingConflicts failed @ %v: conflicts %v, expected %v", test.name, conflicts, test.conflicts)
+		}
+
+		if conflicts := test.other.conflicts(&mapping); conflicts != test.conflicts {
+			t.Errorf("TestPortMappingConflicts failed @ %v: reversed conflicts %v, expected %v", test.name, conflicts, test.conflicts)
+		}
+	}
+}
+
+func TestPortMappingString(t *testing.T) {
+	tests := []struct {
+		mapping PortMapping
+		str     string
+	}{
+		{mapping: PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}, str: "0.0.0.0:8080->80/tcp"},
+		{mapping: PortMapping{HostPort: 53, ContainerPort: 53, Protocol: "udp", HostIP: net.ParseIP("fd00::4")}, str: "[fd00::4]:53->53/udp"},
+	}
+
+	for _, test := range tests {
+		if str := test.mapping.String(); str != test.str {
+			t.Errorf("TestPortMappingString failed, %v, expected %v", str, test.str)
+		}
+	}
+}
+
+func TestValidatePortMappings(t *testing.T) {
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {
+				Networks: map[string]*network{
+					"azure": {
+						Endpoints: map[string]*endpoint{
+							"ep1": {Id: "ep1", PortMappings: []PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		mappings []PortMapping
+		valid    bool
+	}{
+		{name: "free host port", id: "ep2", mappings: []PortMapping{{HostPort: 8081, ContainerPort: 80}}, valid: true},
+		{name: "same port on another protocol", id: "ep2", mappings: []PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "udp"}}, valid: true},
+		{name: "endpoint recreated", id: "ep1", mappings: []PortMapping{{HostPort: 8080, ContainerPort: 80}}, valid: true},
+		{name: "host port of another endpoint", id: "ep2", mappings: []PortMapping{{HostPort: 8080, ContainerPort: 80}}},
+		{name: "host port mapped twice", id: "ep2", mappings: []PortMapping{{HostPort: 9000, ContainerPort: 80}, {HostPort: 9000, ContainerPort: 81}}},
+		{name: "invalid mapping", id: "ep2", mappings: []PortMapping{{HostPort: 9000}}},
+	}
+
+	for _, test := range tests {
+		err := nm.validatePortMappings(&EndpointInfo{Id: test.id, PortMappings: test.mappings})
+		if (err == nil) != test.valid {
+			t.Errorf("TestValidatePortMappings failed @ %v: validatePortMappings returned %v, expected valid:%v", test.name, err, test.valid)
+		}
+	}
+}
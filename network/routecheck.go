@@ -0,0 +1,113 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// Kinds of entries programmed for an endpoint that can disappear from the dataplane.
+const (
+	RouteDriftContainerRoute = "ContainerRoute"
+	RouteDriftHostRoute      = "HostRoute"
+	RouteDriftStaticArp      = "StaticArp"
+)
+
+// RouteDrift is a route or ARP entry programmed for an endpoint that was found missing, e.g. because another agent
+// flushed the table, and whether it was restored.
+type RouteDrift struct {
+	NetworkId  string
+	EndpointId string
+	Kind       string
+	Entry      string
+	Repaired   bool
+	Error      string `json:",omitempty"`
+}
+
+// String returns a one line description of the drift for logs and telemetry events.
+func (drift *RouteDrift) String() string {
+	s := fmt.Sprintf("%v %v of endpoint %v in network %v was missing", drift.Kind, drift.Entry, drift.EndpointId, drift.NetworkId)
+	if drift.Repaired {
+		return s + ", restored"
+	}
+
+	return s + ", not restored: " + drift.Error
+}
+
+// ReconcileEndpointRoutes verifies that the routes and ARP entries programmed for an endpoint are still in the
+// dataplane and restores those that are missing. An empty endpointId reconciles all endpoints of the network.
+// It returns the entries that were missing.
+func (nm *networkManager) ReconcileEndpointRoutes(networkId string, endpointId string) ([]RouteDrift, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkId)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpointId != "" {
+		ep, err := nw.getEndpoint(endpointId)
+		if err != nil {
+			return nil, err
+		}
+
+		return nw.reconcileEndpointRoutesImpl(ep), nil
+	}
+
+	var drifts []RouteDrift
+	for _, ep := range nw.Endpoints {
+		drifts = append(drifts, nw.reconcileEndpointRoutesImpl(ep)...)
+	}
+
+	return drifts, nil
+}
+
+// RunRouteReconciler reconciles the routes and ARP entries of all endpoints of all networks every interval until
+// stopCh is closed, and passes the entries found missing to report.
+func (nm *networkManager) RunRouteReconciler(interval time.Duration, stopCh <-chan struct{}, report func([]RouteDrift)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		drifts := nm.reconcileAllRoutes()
+		if len(drifts) == 0 {
+			continue
+		}
+
+		for i := range drifts {
+			log.Printf("[net] Route drift: %v.", drifts[i].String())
+		}
+
+		if report != nil {
+			report(drifts)
+		}
+	}
+}
+
+// Reconciles the routes and ARP entries of all endpoints of all networks.
+func (nm *networkManager) reconcileAllRoutes() []RouteDrift {
+	nm.Lock()
+	defer nm.Unlock()
+
+	var drifts []RouteDrift
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				drifts = append(drifts, nw.reconcileEndpointRoutesImpl(ep)...)
+			}
+		}
+	}
+
+	return drifts
+}
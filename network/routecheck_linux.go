@@ -0,0 +1,210 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+const (
+	// Flag of permanent entries in the ARP table.
+	arpFlagPermanent = 0x4
+)
+
+// IPv4 neighbor table of the host, there is no netlink neighbor dump. Replaced by tests.
+var arpTablePath = "/proc/net/arp"
+
+// Returns whether the ARP table has a permanent entry of the IP address to the MAC address on the interface.
+func hasStaticArp(ifName string, ip net.IP, mac net.HardwareAddr) (bool, error) {
+	file, err := os.Open(arpTablePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	// Skip the header.
+	scanner.Scan()
+
+	for scanner.Scan() {
+		// IP address, HW type, flags, HW address, mask, device.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[5] != ifName || !ip.Equal(net.ParseIP(fields[0])) {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[2], 0, 32)
+		if err != nil || flags&arpFlagPermanent == 0 {
+			continue
+		}
+
+		if entryMac, err := net.ParseMAC(fields[3]); err == nil && entryMac.String() == mac.String() {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// Returns whether the main table has a route to the destination through the interface.
+func hasRoute(ifName string, dst *net.IPNet) (bool, error) {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return false, err
+	}
+
+	routes, err := netlink.GetIpRoute(&netlink.Route{
+		Family:    netlink.GetIpAddressFamily(dst.IP),
+		Dst:       dst,
+		LinkIndex: iface.Index,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(routes) > 0, nil
+}
+
+// Restores a route through the interface. The address family is taken from the destination, as host routes
+// have no gateway.
+func restoreRoute(ifName string, route RouteInfo) error {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+
+	err = netlink.AddIpRoute(&netlink.Route{
+		Family:    netlink.GetIpAddressFamily(route.Dst.IP),
+		Dst:       &route.Dst,
+		Gw:        route.Gw,
+		LinkIndex: iface.Index,
+	})
+	if err != nil && !netlink.IsExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the host route destination of an endpoint IP address, a /32 or a /128.
+func hostRouteDst(ip net.IP) net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+
+	return net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// Verifies the routes and ARP entries of an endpoint and restores those that are missing.
+func (nw *network) reconcileEndpointRoutesImpl(ep *endpoint) []RouteDrift {
+	var drifts []RouteDrift
+
+	newDrift := func(kind string, entry string, err error) {
+		drift := RouteDrift{
+			NetworkId:  nw.Id,
+			EndpointId: ep.Id,
+			Kind:       kind,
+			Entry:      entry,
+			Repaired:   err == nil,
+		}
+		if err != nil {
+			drift.Error = err.Error()
+		}
+		drifts = append(drifts, drift)
+	}
+
+	switch {
+	case nw.Mode == opModeSriov || ep.VlanID != 0 || nw.isOVS():
+		// Entries of these endpoints are not programmed on the host by the endpoint itself.
+
+	case nw.Mode == opModeTransparent || nw.Mode == opModeOverlay:
+		// Incoming packets to the endpoint are routed to its host veth.
+		for _, ipAddr := range ep.IPAddresses {
+			dst := hostRouteDst(ipAddr.IP)
+			exists, err := hasRoute(ep.HostIfName, &dst)
+			if err != nil {
+				log.Printf("[net] Failed to verify host route %v of endpoint %v, err:%v.", dst.String(), ep.Id, err)
+				continue
+			}
+
+			if !exists {
+				entry := fmt.Sprintf("%v dev %v", dst.String(), ep.HostIfName)
+				newDrift(RouteDriftHostRoute, entry, restoreRoute(ep.HostIfName, RouteInfo{Dst: dst}))
+			}
+		}
+
+	case nw.Mode != opModeTunnel && nw.extIf != nil:
+		for _, ipAddr := range ep.IPAddresses {
+			if ipAddr.IP.To4() == nil {
+				continue
+			}
+
+			exists, err := hasStaticArp(nw.extIf.BridgeName, ipAddr.IP, ep.MacAddress)
+			if err != nil {
+				log.Printf("[net] Failed to verify static arp %v of endpoint %v, err:%v.", ipAddr.IP.String(), ep.Id, err)
+				continue
+			}
+
+			if !exists {
+				entry := fmt.Sprintf("%v lladdr %v dev %v", ipAddr.IP.String(), ep.MacAddress.String(), nw.extIf.BridgeName)
+				err = netlink.AddOrRemoveStaticArp(netlink.ADD, nw.extIf.BridgeName, ipAddr.IP, ep.MacAddress)
+				newDrift(RouteDriftStaticArp, entry, err)
+			}
+		}
+	}
+
+	if ep.NetworkNameSpace == "" || len(ep.Routes) == 0 {
+		return drifts
+	}
+
+	// Routes of the container are in its network namespace.
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		// The namespace is gone with the container, the endpoint is deleted next.
+		log.Printf("[net] Failed to open netns %v of endpoint %v, err:%v.", ep.NetworkNameSpace, ep.Id, err)
+		return drifts
+	}
+	defer ns.Close()
+
+	if err = ns.Enter(); err != nil {
+		log.Printf("[net] Failed to enter netns %v of endpoint %v, err:%v.", ep.NetworkNameSpace, ep.Id, err)
+		return drifts
+	}
+
+	defer func() {
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns, err:%v.", err)
+		}
+	}()
+
+	for _, route := range ep.Routes {
+		ifName := ep.IfName
+		if route.DevName != "" {
+			ifName = route.DevName
+		}
+
+		dst := route.Dst
+		exists, err := hasRoute(ifName, &dst)
+		if err != nil {
+			log.Printf("[net] Failed to verify route %+v of endpoint %v, err:%v.", route, ep.Id, err)
+			continue
+		}
+
+		if !exists {
+			entry := fmt.Sprintf("%v via %v dev %v", dst.String(), route.Gw, ifName)
+			newDrift(RouteDriftContainerRoute, entry, restoreRoute(ifName, route))
+		}
+	}
+
+	return drifts
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestHasStaticArp(t *testing.T) {
+	file, err := ioutil.TempFile("", "arp")
+	if err != nil {
+		t.Fatalf("Failed to create ARP table: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString("IP address       HW type     Flags       HW address            Mask     Device\n" +
+		"169.254.1.1      0x1         0x6         12:34:56:78:9a:bc     *        eth0\n" +
+		"10.0.0.1         0x1         0x2         12:34:56:78:9a:bd     *        eth0\n")
+	file.Close()
+
+	defer func(path string) { arpTablePath = path }(arpTablePath)
+	arpTablePath = file.Name()
+
+	mac, _ := net.ParseMAC("12:34:56:78:9a:bc")
+	dynamicMac, _ := net.ParseMAC("12:34:56:78:9a:bd")
+
+	tests := []struct {
+		name   string
+		ifName string
+		ip     string
+		mac    net.HardwareAddr
+		found  bool
+	}{
+		{name: "permanent", ifName: "eth0", ip: "169.254.1.1", mac: mac, found: true},
+		{name: "other interface", ifName: "eth1", ip: "169.254.1.1", mac: mac},
+		{name: "other MAC", ifName: "eth0", ip: "169.254.1.1", mac: dynamicMac},
+		{name: "dynamic", ifName: "eth0", ip: "10.0.0.1", mac: dynamicMac},
+		{name: "missing", ifName: "eth0", ip: "10.0.0.2", mac: mac},
+	}
+
+	for _, test := range tests {
+		found, err := hasStaticArp(test.ifName, net.ParseIP(test.ip), test.mac)
+		if err != nil {
+			t.Errorf("%v: hasStaticArp failed: %v", test.name, err)
+		}
+
+		if found != test.found {
+			t.Errorf("%v: hasStaticArp returned %v, expected %v", test.name, found, test.found)
+		}
+	}
+}
+
+func TestHostRouteDst(t *testing.T) {
+	tests := []struct {
+		ip  string
+		dst string
+	}{
+		{ip: "10.0.0.4", dst: "10.0.0.4/32"},
+		{ip: "::ffff:10.0.0.4", dst: "10.0.0.4/32"},
+		{ip: "fd00::4", dst: "fd00::4/128"},
+	}
+
+	for _, test := range tests {
+		dst := hostRouteDst(net.ParseIP(test.ip))
+		if dst.String() != test.dst {
+			t.Errorf("hostRouteDst(%v) returned %v, expected %v", test.ip, dst.String(), test.dst)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+// Verifies the routes and ARP entries of an endpoint and restores those that are missing.
+// HNS programs and owns the routes of endpoints on Windows, so there is nothing to reconcile.
+func (nw *network) reconcileEndpointRoutesImpl(ep *endpoint) []RouteDrift {
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// allocateSnatIP returns the first address of the SNAT IP block of the network that is not assigned to an endpoint.
+// The network and broadcast addresses of the block are not assigned.
+func (nw *network) allocateSnatIP() (net.IP, error) {
+	_, block, err := net.ParseCIDR(nw.SnatIPBlock)
+	if err != nil || block.IP.To4() == nil {
+		return nil, errSnatIPBlockInvalid
+	}
+
+	inUse := make(map[uint32]bool)
+	for _, ep := range nw.Endpoints {
+		if ip := ep.SnatIP.To4(); ip != nil {
+			inUse[binary.BigEndian.Uint32(ip)] = true
+		}
+	}
+
+	ones, bits := block.Mask.Size()
+	first := binary.BigEndian.Uint32(block.IP.To4())
+	last := first | (1<<uint(bits-ones) - 1)
+
+	if bits-ones > 1 {
+		first++
+		last--
+	}
+
+	for addr := first; addr <= last && addr >= first; addr++ {
+		if !inUse[addr] {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, addr)
+			return ip, nil
+		}
+	}
+
+	log.Printf("[net] No free address in SNAT IP block %v of network %v.", nw.SnatIPBlock, nw.Id)
+	return nil, errSnatIPBlockExhausted
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAllocateSnatIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		block  string
+		inUse  []string
+		snatIP string
+		err    error
+	}{
+		{name: "first address", block: "169.254.0.0/24", snatIP: "169.254.0.1"},
+		{name: "skips used", block: "169.254.0.0/24", inUse: []string{"169.254.0.1", "169.254.0.2"}, snatIP: "169.254.0.3"},
+		{name: "reuses released", block: "169.254.0.0/24", inUse: []string{"169.254.0.2"}, snatIP: "169.254.0.1"},
+		{name: "point to point", block: "169.254.0.0/31", inUse: []string{"169.254.0.0"}, snatIP: "169.254.0.1"},
+		{name: "single address", block: "169.254.0.8/32", snatIP: "169.254.0.8"},
+		{name: "exhausted", block: "169.254.0.0/30", inUse: []string{"169.254.0.1", "169.254.0.2"}, err: errSnatIPBlockExhausted},
+		{name: "end of address space", block: "255.255.255.255/32", inUse: []string{"255.255.255.255"}, err: errSnatIPBlockExhausted},
+		{name: "IPv6", block: "fd00::/120", err: errSnatIPBlockInvalid},
+		{name: "invalid", block: "169.254.0.0", err: errSnatIPBlockInvalid},
+	}
+
+	for _, test := range tests {
+		nw := &network{Id: "net1", SnatIPBlock: test.block, Endpoints: make(map[string]*endpoint)}
+		for i, ip := range test.inUse {
+			id := string(rune('a' + i))
+			nw.Endpoints[id] = &endpoint{Id: id, SnatIP: net.ParseIP(ip)}
+		}
+
+		// Endpoints without a SNAT IP do not use an address.
+		nw.Endpoints["none"] = &endpoint{Id: "none"}
+
+		snatIP, err := nw.allocateSnatIP()
+		if err != test.err {
+			t.Errorf("TestAllocateSnatIP failed @ %v: err %v, expected %v", test.name, err, test.err)
+			continue
+		}
+
+		if test.err == nil && !snatIP.Equal(net.ParseIP(test.snatIP)) {
+			t.Errorf("TestAllocateSnatIP failed @ %v: SNAT IP %v, expected %v", test.name, snatIP, test.snatIP)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"bytes"
+	"net"
+	"os"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/epcommon"
+)
+
+// SriovEndpointClient connects pods to an SR-IOV virtual function, bypassing the bridge.
+type SriovEndpointClient struct {
+	hostPrimaryIfName string
+	vfName            string
+	containerIfName   string
+	link              string
+}
+
+func NewSriovEndpointClient(
+	extIf *externalInterface,
+	cfg *SriovConfig,
+	vfName string,
+	containerIfName string,
+) *SriovEndpointClient {
+
+	client := &SriovEndpointClient{
+		hostPrimaryIfName: extIf.Name,
+		vfName:            vfName,
+		containerIfName:   containerIfName,
+		link:              cfg.getLink(),
+	}
+
+	return client
+}
+
+// getSriovVfName returns the name of the virtual function of an SR-IOV network in the host namespace.
+// Unless configured, it is the interface sharing the MAC address of the external interface,
+// which is how Accelerated Networking pairs the virtual function with the synthetic interface.
+func getSriovVfName(extIf *externalInterface, cfg *SriovConfig) (string, error) {
+	if cfg != nil && cfg.VfName != "" {
+		if _, err := net.InterfaceByName(cfg.VfName); err != nil {
+			log.Printf("[net] Failed to find virtual function %v: %v.", cfg.VfName, err)
+			return "", errSriovVfNotFound
+		}
+
+		return cfg.VfName, nil
+	}
+
+	vf := findSriovVf(extIf.Name, extIf.MacAddress)
+	if vf == nil {
+		return "", errSriovVfNotFound
+	}
+
+	return vf.Name, nil
+}
+
+// findSriovVf returns the host interface other than the primary interface with the given MAC address.
+func findSriovVf(hostPrimaryIfName string, mac net.HardwareAddr) *net.Interface {
+	if len(mac) == 0 {
+		return nil
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Printf("[net] Failed to list interfaces: %v.", err)
+		return nil
+	}
+
+	for _, iface := range interfaces {
+		if iface.Name != hostPrimaryIfName && bytes.Equal(iface.HardwareAddr, mac) {
+			return &iface
+		}
+	}
+
+	return nil
+}
+
+func (client *SriovEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
+	if client.link == SriovLinkDirect {
+		return nil
+	}
+
+	vf, err := net.InterfaceByName(client.vfName)
+	if err != nil {
+		return err
+	}
+
+	// Interfaces on top of the virtual function only pass traffic while it is up.
+	log.Printf("[net] Setting link %v state up.", client.vfName)
+	if err := netlink.SetLinkState(client.vfName, true); err != nil {
+		return err
+	}
+
+	linkInfo := netlink.LinkInfo{
+		Name:        client.containerIfName,
+		ParentIndex: vf.Index,
+	}
+
+	log.Printf("[net] Creating %v link %v on virtual function %v.", client.link, client.containerIfName, client.vfName)
+	if client.link == SriovLinkMacvlan {
+		linkInfo.Type = netlink.LINK_TYPE_MACVLAN
+		return netlink.AddLink(&netlink.MacvlanLink{LinkInfo: linkInfo, Mode: netlink.MACVLAN_MODE_BRIDGE})
+	}
+
+	linkInfo.Type = netlink.LINK_TYPE_IPVLAN
+	return netlink.AddLink(&netlink.IPVlanLink{LinkInfo: linkInfo, Mode: netlink.IPVLAN_MODE_L2})
+}
+
+func (client *SriovEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
+	return nil
+}
+
+func (client *SriovEndpointClient) DeleteEndpointRules(ep *endpoint) {
+}
+
+func (client *SriovEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
+	// Move the container interface to container's network namespace.
+	log.Printf("[net] Setting link %v netns %v.", client.containerIfName, epInfo.NetNsPath)
+	if err := netlink.SetLinkNetNs(client.containerIfName, nsID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (client *SriovEndpointClient) SetupContainerInterfaces(epInfo *EndpointInfo) error {
+	if err := epcommon.SetupContainerInterface(client.containerIfName, epInfo.IfName); err != nil {
+		return err
+	}
+
+	client.containerIfName = epInfo.IfName
+
+	return nil
+}
+
+func (client *SriovEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error {
+	if err := epcommon.AssignIPToInterface(client.containerIfName, epInfo.IPAddresses); err != nil {
+		return err
+	}
+
+	return addRoutes(client.containerIfName, epInfo.Routes)
+}
+
+func (client *SriovEndpointClient) DeleteEndpoints(ep *endpoint) error {
+	if client.link != SriovLinkDirect {
+		return client.deleteContainerLink(ep)
+	}
+
+	if err := client.returnVf(ep); err != nil {
+		log.Printf("[net] Failed to return virtual function %v to host: %v.", client.vfName, err)
+		return err
+	}
+
+	return client.resetVf(ep.MacAddress)
+}
+
+// deleteContainerLink deletes the MACVLAN or IPVLAN interface of the endpoint in the container namespace.
+// The interface is already gone when the namespace was deleted.
+func (client *SriovEndpointClient) deleteContainerLink(ep *endpoint) error {
+	if ep.NetworkNameSpace == "" {
+		return netlink.DeleteLink(ep.IfName)
+	}
+
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer ns.Close()
+
+	if err := ns.Enter(); err != nil {
+		return err
+	}
+
+	log.Printf("[net] Deleting link %v in netns %v.", ep.IfName, ep.NetworkNameSpace)
+	err = netlink.DeleteLink(ep.IfName)
+
+	if exitErr := ns.Exit(); exitErr != nil {
+		log.Printf("[net] Failed to exit netns, err:%v.", exitErr)
+	}
+
+	return err
+}
+
+// returnVf moves the virtual function of the endpoint from the container namespace back to the host namespace.
+// When the namespace was deleted, the kernel already moved the virtual function back.
+func (client *SriovEndpointClient) returnVf(ep *endpoint) error {
+	if ep.NetworkNameSpace == "" {
+		return nil
+	}
+
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer ns.Close()
+
+	hostNs, err := GetCurrentThreadNamespace()
+	if err != nil {
+		return err
+	}
+	defer hostNs.Close()
+
+	if err := ns.Enter(); err != nil {
+		return err
+	}
+
+	// The interface is renamed back in the container namespace, where its host name is free.
+	log.Printf("[net] Moving virtual function %v in netns %v back to host.", ep.IfName, ep.NetworkNameSpace)
+	err = netlink.SetLinkState(ep.IfName, false)
+	if err == nil {
+		err = netlink.SetLinkName(ep.IfName, client.vfName)
+	}
+	if err == nil {
+		err = netlink.SetLinkNetNs(client.vfName, hostNs.GetFd())
+	}
+
+	if exitErr := ns.Exit(); exitErr != nil {
+		log.Printf("[net] Failed to exit netns, err:%v.", exitErr)
+	}
+
+	return err
+}
+
+// resetVf restores the name and state the virtual function had in the host namespace before it was passed to a pod.
+// Virtual functions returned by the kernel may have been renamed, and are found by their MAC address.
+func (client *SriovEndpointClient) resetVf(mac net.HardwareAddr) error {
+	vf, err := net.InterfaceByName(client.vfName)
+	if err != nil {
+		vf = findSriovVf(client.hostPrimaryIfName, mac)
+		if vf == nil {
+			log.Printf("[net] Failed to find virtual function %v with MAC address %v.", client.vfName, mac)
+			return errSriovVfNotFound
+		}
+	}
+
+	log.Printf("[net] Resetting virtual function %v.", vf.Name)
+	if err := netlink.SetLinkState(vf.Name, false); err != nil {
+		return err
+	}
+
+	if vf.Name != client.vfName {
+		log.Printf("[net] Setting link %v name %v.", vf.Name, client.vfName)
+		if err := netlink.SetLinkName(vf.Name, client.vfName); err != nil {
+			return err
+		}
+	}
+
+	return netlink.SetLinkState(client.vfName, true)
+}
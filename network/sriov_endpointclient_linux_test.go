@@ -0,0 +1,84 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSriovConfigGetLink(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *SriovConfig
+		link string
+	}{
+		{name: "no config", link: SriovLinkDirect},
+		{name: "no link", cfg: &SriovConfig{VfName: "eth1"}, link: SriovLinkDirect},
+		{name: "macvlan", cfg: &SriovConfig{Link: SriovLinkMacvlan}, link: SriovLinkMacvlan},
+		{name: "ipvlan", cfg: &SriovConfig{Link: SriovLinkIPVlan}, link: SriovLinkIPVlan},
+	}
+
+	for _, test := range tests {
+		if link := test.cfg.getLink(); link != test.link {
+			t.Errorf("TestSriovConfigGetLink failed @ %v: link %v, expected %v", test.name, link, test.link)
+		}
+
+		client := NewSriovEndpointClient(&externalInterface{Name: "eth0"}, test.cfg, "eth1", "eth2")
+		if client.link != test.link || client.hostPrimaryIfName != "eth0" {
+			t.Errorf("TestSriovConfigGetLink failed @ %v: client %+v", test.name, client)
+		}
+	}
+}
+
+func TestGetSriovVfName(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("No loopback interface: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		extIf  *externalInterface
+		cfg    *SriovConfig
+		vfName string
+		valid  bool
+	}{
+		{name: "configured virtual function", extIf: &externalInterface{Name: "eth0"}, cfg: &SriovConfig{VfName: lo.Name}, vfName: lo.Name, valid: true},
+		{name: "missing virtual function", extIf: &externalInterface{Name: "eth0"}, cfg: &SriovConfig{VfName: "does-not-exist"}},
+		{name: "external interface without MAC address", extIf: &externalInterface{Name: "eth0"}},
+		{name: "no interface paired by MAC address", extIf: &externalInterface{Name: "eth0", MacAddress: net.HardwareAddr{0x02, 0, 0, 0, 0xfe, 0xfe}}},
+	}
+
+	for _, test := range tests {
+		vfName, err := getSriovVfName(test.extIf, test.cfg)
+		if (err == nil) != test.valid || vfName != test.vfName {
+			t.Errorf("TestGetSriovVfName failed @ %v: %v %v, expected %v valid:%v", test.name, vfName, err, test.vfName, test.valid)
+		}
+
+		if !test.valid && err != errSriovVfNotFound {
+			t.Errorf("TestGetSriovVfName failed @ %v: error %v, expected %v", test.name, err, errSriovVfNotFound)
+		}
+	}
+}
+
+func TestNewSriovNetworkInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		nwInfo *NetworkInfo
+		err    error
+	}{
+		{name: "OVS dataplane", nwInfo: &NetworkInfo{Mode: opModeSriov, Dataplane: DataplaneOVS}, err: errNetworkDataplaneInvalid},
+		{name: "unknown link", nwInfo: &NetworkInfo{Mode: opModeSriov, Sriov: &SriovConfig{Link: "vxlan"}}, err: errSriovLinkInvalid},
+	}
+
+	// Invalid networks are rejected before the external interface is changed.
+	nm := &networkManager{}
+	for _, test := range tests {
+		extIf := &externalInterface{Name: "eth0", Networks: make(map[string]*network)}
+		if _, err := nm.newNetworkImpl(test.nwInfo, extIf); err != test.err {
+			t.Errorf("TestNewSriovNetworkInvalid failed @ %v: error %v, expected %v", test.name, err, test.err)
+		}
+	}
+}
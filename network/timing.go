@@ -0,0 +1,24 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+const (
+	// Stage of setting up the source NAT of an endpoint.
+	StageSnat = "SNAT"
+)
+
+// StageTimer measures the time spent in the stages of a command, e.g. for the verbose mode of the CNI plugin.
+type StageTimer interface {
+	// StartStage starts measuring a stage, and returns the function that stops measuring it.
+	StartStage(stage string) func()
+}
+
+// startStage starts measuring a stage of setting up the endpoint, if its caller measures stages.
+func (epInfo *EndpointInfo) startStage(stage string) func() {
+	if epInfo.Timer == nil {
+		return func() {}
+	}
+
+	return epInfo.Timer.StartStage(stage)
+}
@@ -0,0 +1,43 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fakeStageTimer records the stages started and stopped.
+type fakeStageTimer struct {
+	events []string
+}
+
+func (timer *fakeStageTimer) StartStage(stage string) func() {
+	timer.events = append(timer.events, "start "+stage)
+	return func() { timer.events = append(timer.events, "stop "+stage) }
+}
+
+func TestEndpointStartStage(t *testing.T) {
+	tests := []struct {
+		name   string
+		timer  *fakeStageTimer
+		events []string
+	}{
+		{name: "not measured"},
+		{name: "measured", timer: &fakeStageTimer{}, events: []string{"start " + StageSnat, "stop " + StageSnat}},
+	}
+
+	for _, test := range tests {
+		epInfo := &EndpointInfo{}
+		if test.timer != nil {
+			epInfo.Timer = test.timer
+		}
+
+		epInfo.startStage(StageSnat)()
+
+		if test.timer != nil && !reflect.DeepEqual(test.timer.events, test.events) {
+			t.Errorf("TestEndpointStartStage failed @ %v: events %v, expected %v", test.name, test.timer.events, test.events)
+		}
+	}
+}
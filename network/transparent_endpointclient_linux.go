@@ -24,6 +24,7 @@ type TransparentEndpointClient struct {
 	containerMac      net.HardwareAddr
 	hostVethMac       net.HardwareAddr
 	mode              string
+	mtu               int
 }
 
 func NewTransparentEndpointClient(
@@ -46,9 +47,7 @@ func NewTransparentEndpointClient(
 }
 
 func setArpProxy(ifName string) error {
-	cmd := fmt.Sprintf("echo 1 > /proc/sys/net/ipv4/conf/%v/proxy_arp", ifName)
-	_, err := platform.ExecuteCommand(cmd)
-	return err
+	return platform.SetSysctl(fmt.Sprintf("net/ipv4/conf/%v/proxy_arp", ifName), "1")
 }
 
 func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
@@ -79,6 +78,16 @@ func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 
 	client.hostVethMac = hostVethIf.HardwareAddr
 
+	// Overlay networks leave room for the VXLAN headers in the packets of the pods.
+	if client.mtu > 0 {
+		for _, ifName := range []string{client.hostVethName, client.containerVethName} {
+			if err := netlink.SetLinkMTU(ifName, client.mtu); err != nil {
+				log.Printf("[net] Failed to set MTU of %v to %v: %v.", ifName, client.mtu, err)
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,137 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Endpoint data key of the warm endpoint bound to a new endpoint.
+	WarmEndpointKey = "warmEndpoint"
+)
+
+// WarmEndpoint is a container interface prepared ahead of time with an address allocated to it,
+// so that creating an endpoint only has to move it into the container network namespace.
+type warmEndpoint struct {
+	Id         string
+	IfName     string
+	HostIfName string
+	MacAddress net.HardwareAddr
+	IPAddress  net.IPNet
+	Gateway    net.IP
+	DNS        DNSInfo
+	CreatedAt  time.Time
+}
+
+// WarmEndpointInfo contains read-only information about a warm endpoint.
+type WarmEndpointInfo struct {
+	Id        string
+	IPAddress net.IPNet
+	Gateway   net.IP
+	DNS       DNSInfo
+	CreatedAt time.Time
+
+	// Warm endpoint prepared but not yet added to the network.
+	prepared *warmEndpoint
+}
+
+// NewWarmEndpoint creates the interfaces of a new warm endpoint, without adding it to the network.
+func (nw *network) newWarmEndpoint(info *WarmEndpointInfo) (*warmEndpoint, error) {
+	log.Printf("[net] Creating warm endpoint for address %v in network %v.", info.IPAddress.String(), nw.Id)
+
+	// Call the platform implementation.
+	warmEp, err := nw.newWarmEndpointImpl(info)
+	if err != nil {
+		log.Printf("[net] Failed to create warm endpoint for address %v, err:%v.", info.IPAddress.String(), err)
+		return nil, err
+	}
+
+	log.Printf("[net] Created warm endpoint %+v.", warmEp)
+
+	return warmEp, nil
+}
+
+// AddWarmEndpoint adds a warm endpoint whose interfaces are created to the network.
+func (nw *network) addWarmEndpoint(warmEp *warmEndpoint) error {
+	if nw.WarmEndpoints[warmEp.Id] != nil {
+		return errWarmEndpointExists
+	}
+
+	if nw.WarmEndpoints == nil {
+		nw.WarmEndpoints = make(map[string]*warmEndpoint)
+	}
+
+	nw.WarmEndpoints[warmEp.Id] = warmEp
+
+	return nil
+}
+
+// DeleteWarmEndpoint deletes a warm endpoint from the network.
+func (nw *network) deleteWarmEndpoint(warmEndpointId string) error {
+	log.Printf("[net] Deleting warm endpoint %v from network %v.", warmEndpointId, nw.Id)
+
+	warmEp := nw.WarmEndpoints[warmEndpointId]
+	if warmEp == nil {
+		return errWarmEndpointNotFound
+	}
+
+	// The warm endpoint is removed even if its interfaces are already gone.
+	delete(nw.WarmEndpoints, warmEndpointId)
+
+	return nw.deleteWarmEndpointImpl(warmEp)
+}
+
+// GetWarmEndpoints returns the warm endpoints of the network, oldest first.
+func (nw *network) getWarmEndpoints() []*WarmEndpointInfo {
+	warmEpInfos := make([]*WarmEndpointInfo, 0, len(nw.WarmEndpoints))
+	for _, warmEp := range nw.WarmEndpoints {
+		warmEpInfos = append(warmEpInfos, warmEp.getInfo())
+	}
+
+	sort.Slice(warmEpInfos, func(i, j int) bool {
+		return warmEpInfos[i].CreatedAt.Before(warmEpInfos[j].CreatedAt)
+	})
+
+	return warmEpInfos
+}
+
+// TakeWarmEndpoint removes the warm endpoint to bind to the given endpoint from the network.
+// It returns nil if the endpoint is not created from a warm endpoint.
+func (nw *network) takeWarmEndpoint(epInfo *EndpointInfo) (*warmEndpoint, error) {
+	warmEndpointId, ok := epInfo.Data[WarmEndpointKey].(string)
+	if !ok {
+		return nil, nil
+	}
+
+	warmEp := nw.WarmEndpoints[warmEndpointId]
+	if warmEp == nil {
+		return nil, errWarmEndpointNotFound
+	}
+
+	// A warm endpoint is used at most once, even if binding it fails.
+	delete(nw.WarmEndpoints, warmEndpointId)
+
+	if len(epInfo.IPAddresses) == 0 || !epInfo.IPAddresses[0].IP.Equal(warmEp.IPAddress.IP) {
+		nw.deleteWarmEndpointImpl(warmEp)
+		return nil, errWarmEndpointAddressMismatch
+	}
+
+	return warmEp, nil
+}
+
+// GetInfo returns information about the warm endpoint.
+func (warmEp *warmEndpoint) getInfo() *WarmEndpointInfo {
+	return &WarmEndpointInfo{
+		Id:        warmEp.Id,
+		IPAddress: warmEp.IPAddress,
+		Gateway:   warmEp.Gateway,
+		DNS:       warmEp.DNS,
+		CreatedAt: warmEp.CreatedAt,
+	}
+}
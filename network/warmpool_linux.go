@@ -0,0 +1,100 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Prefix for warm endpoint interface names.
+	warmVEthInterfacePrefix = commonInterfacePrefix + "w"
+)
+
+// warmEndpointClient binds a warm endpoint, whose interfaces and rules already exist.
+type warmEndpointClient struct {
+	EndpointClient
+}
+
+func (client *warmEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
+	return nil
+}
+
+func (client *warmEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
+	return nil
+}
+
+// newWarmEndpointImpl creates the veth pair of a warm endpoint, and sets up the rules for its address.
+func (nw *network) newWarmEndpointImpl(info *WarmEndpointInfo) (*warmEndpoint, error) {
+	var err error
+
+	if nw.Mode == opModeSriov || nw.Mode == opModeTransparent || nw.Mode == opModeOverlay || nw.isOVS() {
+		return nil, errWarmPoolNotSupported
+	}
+
+	id := generateVethName(nw.Id + info.IPAddress.String())
+	if nw.WarmEndpoints[id] != nil {
+		return nil, errWarmEndpointExists
+	}
+
+	warmEp := &warmEndpoint{
+		Id:         id,
+		IfName:     fmt.Sprintf("%s%s2", warmVEthInterfacePrefix, id),
+		HostIfName: fmt.Sprintf("%s%s", warmVEthInterfacePrefix, id),
+		IPAddress:  info.IPAddress,
+		Gateway:    info.Gateway,
+		DNS:        info.DNS,
+		CreatedAt:  time.Now(),
+	}
+
+	epInfo := &EndpointInfo{
+		Id:          id,
+		IPAddresses: []net.IPNet{info.IPAddress},
+	}
+
+	epClient := NewLinuxBridgeEndpointClient(nw.extIf, warmEp.HostIfName, warmEp.IfName, nw.Mode)
+
+	// Cleanup on failure.
+	defer func() {
+		if err != nil {
+			log.Printf("[net] Deleting warm endpoint %v and rules that are created.", id)
+			warmEp.MacAddress = epClient.containerMac
+			nw.deleteWarmEndpointImpl(warmEp)
+		}
+	}()
+
+	if err = epClient.AddEndpoints(epInfo); err != nil {
+		return nil, err
+	}
+
+	if err = epClient.AddEndpointRules(epInfo); err != nil {
+		return nil, err
+	}
+
+	warmEp.MacAddress = epClient.containerMac
+
+	return warmEp, nil
+}
+
+// deleteWarmEndpointImpl deletes the veth pair and rules of a warm endpoint.
+func (nw *network) deleteWarmEndpointImpl(warmEp *warmEndpoint) error {
+	ep := &endpoint{
+		Id:          warmEp.Id,
+		IfName:      warmEp.IfName,
+		HostIfName:  warmEp.HostIfName,
+		MacAddress:  warmEp.MacAddress,
+		IPAddresses: []net.IPNet{warmEp.IPAddress},
+	}
+
+	epClient := NewLinuxBridgeEndpointClient(nw.extIf, warmEp.HostIfName, "", nw.Mode)
+	if ep.MacAddress != nil {
+		epClient.DeleteEndpointRules(ep)
+	}
+
+	return epClient.DeleteEndpoints(ep)
+}
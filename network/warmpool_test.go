@@ -0,0 +1,46 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+// Tests that only prepared warm endpoints are added, at most once.
+func TestAddWarmEndpoint(t *testing.T) {
+	nw := &network{Id: "azure"}
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {Networks: map[string]*network{nw.Id: nw}},
+		},
+	}
+
+	warmEp := &warmEndpoint{
+		Id:        "1a2b3c4d",
+		IPAddress: net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)},
+	}
+	prepared := warmEp.getInfo()
+	prepared.prepared = warmEp
+
+	if err := nm.AddWarmEndpoint(nw.Id, warmEp.getInfo()); err != errWarmEndpointNotFound {
+		t.Errorf("Added a warm endpoint that was not prepared, err:%v", err)
+	}
+
+	if err := nm.AddWarmEndpoint("other", prepared); err == nil {
+		t.Errorf("Added a warm endpoint to a missing network")
+	}
+
+	if err := nm.AddWarmEndpoint(nw.Id, prepared); err != nil {
+		t.Fatalf("AddWarmEndpoint failed: %v", err)
+	}
+
+	if nw.WarmEndpoints[warmEp.Id] != warmEp {
+		t.Errorf("Warm endpoint not added: %+v", nw.WarmEndpoints)
+	}
+
+	if err := nm.AddWarmEndpoint(nw.Id, prepared); err != errWarmEndpointExists {
+		t.Errorf("Added a warm endpoint twice, err:%v", err)
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+// newWarmEndpointImpl creates a warm endpoint. Warm endpoints are not supported on Windows.
+func (nw *network) newWarmEndpointImpl(info *WarmEndpointInfo) (*warmEndpoint, error) {
+	return nil, errWarmPoolNotSupported
+}
+
+// deleteWarmEndpointImpl deletes a warm endpoint.
+func (nw *network) deleteWarmEndpointImpl(warmEp *warmEndpoint) error {
+	return nil
+}
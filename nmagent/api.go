@@ -0,0 +1,93 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package nmagent
+
+import (
+	"encoding/xml"
+)
+
+const (
+	// Wireserver plugin endpoint forwarding requests to NMAgent.
+	defaultURL = "http://169.254.169.254/machine/plugins"
+
+	// NMAgent request types.
+	getInterfaceInfoType        = "getinterfaceinfov1"
+	joinNetworkType             = "NetworkManagement/joinedVirtualNetworks/%s/api-version/1"
+	networkContainerType        = "NetworkManagement/interfaces/%s/networkContainers/%s/authenticationToken/%s/api-version/1"
+	deleteNetworkContainerType  = networkContainerType + "/method/DELETE"
+	networkContainerVersionType = "NetworkManagement/interfaces/%s/networkContainers/%s/authenticationToken/%s/api-version/%s"
+	successfulResponseCode      = "200"
+
+	// Path segment of request URLs followed by the authentication token of a network container, and its replacement in logs.
+	authTokenSegment = "/authenticationToken/"
+	redactedToken    = "REDACTED"
+)
+
+// Interfaces is the list of interfaces of the VM, as returned by NMAgent.
+type Interfaces struct {
+	XMLName   xml.Name    `xml:"Interfaces"`
+	Interface []Interface `xml:"Interface"`
+}
+
+// Interface is an interface of the VM with the subnets and addresses assigned to it.
+type Interface struct {
+	MacAddress string     `xml:"MacAddress,attr"`
+	IsPrimary  bool       `xml:"IsPrimary,attr"`
+	IPSubnet   []IPSubnet `xml:"IPSubnet"`
+}
+
+// IPSubnet is a subnet of an interface.
+type IPSubnet struct {
+	Prefix    string      `xml:"Prefix,attr"`
+	IPAddress []IPAddress `xml:"IPAddress"`
+}
+
+// IPAddress is an address assigned to an interface.
+type IPAddress struct {
+	Address   string `xml:"Address,attr"`
+	IsPrimary bool   `xml:"IsPrimary,attr"`
+}
+
+// JoinNetworkRequest asks NMAgent to join the VM to a virtual network.
+type JoinNetworkRequest struct {
+	NetworkID string
+}
+
+// PublishNetworkContainerRequest asks NMAgent to program a network container on the interface with the primary address.
+type PublishNetworkContainerRequest struct {
+	NetworkContainerID string
+	PrimaryAddress     string
+	AuthToken          string
+	// Network container document, passed to NMAgent as is.
+	Body []byte
+}
+
+// UnpublishNetworkContainerRequest asks NMAgent to remove a network container.
+type UnpublishNetworkContainerRequest struct {
+	NetworkContainerID string
+	PrimaryAddress     string
+	AuthToken          string
+}
+
+// NetworkContainerResponse is the response of NMAgent to a network container request.
+type NetworkContainerResponse struct {
+	ResponseCode       string `json:"httpStatusCode"`
+	NetworkContainerID string `json:"networkContainerId"`
+	Version            string `json:"version"`
+}
+
+// NetworkContainerVersionRequest asks NMAgent for the version of a network container programmed on the host.
+type NetworkContainerVersionRequest struct {
+	NetworkContainerID string
+	PrimaryAddress     string
+	AuthToken          string
+	APIVersion         string
+}
+
+// NetworkContainerVersion is the version of a network container programmed on the host.
+type NetworkContainerVersion struct {
+	ResponseCode       string `json:"httpResponseCode"`
+	NetworkContainerID string `json:"networkContainerId"`
+	ProgrammedVersion  string `json:"Version"`
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package nmagent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// circuitBreaker fails requests fast after consecutive requests found wireserver unavailable.
+// Once the cool down expired, a single failed request opens the circuit again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	sync.Mutex
+}
+
+// Creates a circuit breaker.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Returns whether a request may be sent, and otherwise until when the circuit is open.
+func (b *circuitBreaker) allow() (bool, time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	if clock.Now().Before(b.openUntil) {
+		return false, b.openUntil
+	}
+
+	return true, time.Time{}
+}
+
+// Records a request that reached wireserver.
+func (b *circuitBreaker) recordSuccess() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// Records a request that found wireserver unavailable.
+func (b *circuitBreaker) recordFailure() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = clock.Now().Add(b.cooldown)
+		log.Printf("[NMAgent] Wireserver is unavailable after %d failed requests, failing requests until %v.",
+			b.failures, b.openUntil)
+	}
+}
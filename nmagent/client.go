@@ -0,0 +1,323 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package nmagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// FaultInjector is called before each request is sent. Returning a response or an error
+// fails the request with it instead of sending it, and returning neither sends the request.
+type FaultInjector func(op string, req *http.Request) (*http.Response, error)
+
+// Config specifies where the client reaches NMAgent, and how it handles failed requests.
+// Zero values select the defaults.
+type Config struct {
+	// Wireserver plugin endpoint forwarding requests to NMAgent.
+	URL string
+	// URL queried for the interfaces of the VM, overriding the one derived from URL.
+	InterfaceInfoURL string
+
+	// Time after which a request is abandoned.
+	Timeout time.Duration
+	// Number of times a request is retried while wireserver is unavailable, and the delay before the first retry.
+	// The delay doubles with each retry.
+	MaxRetries int
+	RetryDelay time.Duration
+	// Number of consecutive failed requests after which requests fail fast for the cool down.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Hook failing requests on purpose, for testing.
+	FaultInjector FaultInjector
+}
+
+// Client sends requests to NMAgent through wireserver.
+type Client struct {
+	url              string
+	interfaceInfoURL string
+	httpc            *http.Client
+	maxRetries       int
+	retryDelay       time.Duration
+	breaker          *circuitBreaker
+	metrics          metrics
+	faultInjector    FaultInjector
+}
+
+const (
+	// Defaults of Config.
+	defaultTimeout          = 10 * time.Second
+	defaultMaxRetries       = 3
+	defaultRetryDelay       = 200 * time.Millisecond
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// Clock used to wait between retries and to time the circuit breaker.
+var clock = platform.NewClock()
+
+// NewClient creates a new NMAgent client.
+func NewClient(config Config) *Client {
+	if config.URL == "" {
+		config.URL = defaultURL
+	}
+
+	if config.InterfaceInfoURL == "" {
+		config.InterfaceInfoURL = typeURL(config.URL, getInterfaceInfoType)
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+
+	if config.BreakerThreshold <= 0 {
+		config.BreakerThreshold = defaultBreakerThreshold
+	}
+
+	if config.BreakerCooldown <= 0 {
+		config.BreakerCooldown = defaultBreakerCooldown
+	}
+
+	return &Client{
+		url:              config.URL,
+		interfaceInfoURL: config.InterfaceInfoURL,
+		httpc:            &http.Client{Timeout: config.Timeout},
+		maxRetries:       config.MaxRetries,
+		retryDelay:       config.RetryDelay,
+		breaker:          newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown),
+		faultInjector:    config.FaultInjector,
+	}
+}
+
+// Returns the URL of an NMAgent request type.
+func typeURL(url string, requestType string) string {
+	return url + "/?comp=nmagent&type=" + requestType
+}
+
+// Returns the URL of a request with the authentication token of the network container it carries redacted, to log it.
+func redactURL(url string) string {
+	i := strings.Index(url, authTokenSegment)
+	if i < 0 {
+		return url
+	}
+
+	start := i + len(authTokenSegment)
+	end := strings.IndexByte(url[start:], '/')
+	if end < 0 {
+		return url[:start] + redactedToken
+	}
+
+	return url[:start] + redactedToken + url[start+end:]
+}
+
+// Returns the error of sending a request, whose description includes the URL of the request, with the token redacted.
+func redactError(err error) error {
+	if urlErr, ok := err.(*neturl.Error); ok {
+		return &neturl.Error{Op: urlErr.Op, URL: redactURL(urlErr.URL), Err: urlErr.Err}
+	}
+
+	return err
+}
+
+// Metrics returns the request counters of the client, by operation.
+func (c *Client) Metrics() map[string]OpMetrics {
+	return c.metrics.snapshot()
+}
+
+// Returns whether a request answered with the given HTTP status may succeed when retried.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
+
+// Sends a request to NMAgent and decodes its response, retrying with backoff while wireserver is unavailable.
+// Requests fail fast while the circuit breaker is open.
+func (c *Client) request(op string, method string, url string, body []byte, decode func(io.Reader) error) error {
+	log.Printf("[NMAgent] %s %s %s", op, method, redactURL(url))
+
+	if ok, openUntil := c.breaker.allow(); !ok {
+		nmaErr := &Error{Op: op, Kind: KindCircuitOpen, OpenUntil: openUntil}
+		c.metrics.record(op, 0, 0, nmaErr)
+		log.Printf("%v", nmaErr)
+		return nmaErr
+	}
+
+	start := clock.Now()
+	delay := c.retryDelay
+	attempt := 0
+	var nmaErr *Error
+	for ; ; attempt++ {
+		if attempt > 0 {
+			log.Printf("[NMAgent] Retrying %s in %v after: %v", op, delay, nmaErr)
+			clock.Sleep(delay)
+			delay *= 2
+		}
+
+		nmaErr = c.attempt(op, method, url, body, decode)
+		if nmaErr == nil || nmaErr.Kind != KindUnavailable || attempt >= c.maxRetries {
+			break
+		}
+	}
+
+	if nmaErr == nil {
+		c.breaker.recordSuccess()
+		c.metrics.record(op, attempt, clock.Since(start), nil)
+		return nil
+	}
+
+	if nmaErr.Kind == KindUnavailable {
+		c.breaker.recordFailure()
+	} else {
+		c.breaker.recordSuccess()
+	}
+
+	c.metrics.record(op, attempt, clock.Since(start), nmaErr)
+	log.Errorf("%v", nmaErr)
+
+	return nmaErr
+}
+
+// Sends a request to NMAgent once and decodes its response.
+func (c *Client) attempt(op string, method string, url string, body []byte, decode func(io.Reader) error) *Error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return &Error{Op: op, Kind: KindRejected, Err: redactError(err)}
+	}
+
+	var res *http.Response
+	if c.faultInjector != nil {
+		res, err = c.faultInjector(op, req)
+	}
+
+	if res == nil && err == nil {
+		res, err = c.httpc.Do(req)
+	}
+
+	if err != nil {
+		return &Error{Op: op, Kind: KindUnavailable, Err: redactError(err)}
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		kind := KindRejected
+		if isRetryableStatus(res.StatusCode) {
+			kind = KindUnavailable
+		}
+		return &Error{Op: op, Kind: kind, StatusCode: res.StatusCode}
+	}
+
+	if err := decode(res.Body); err != nil {
+		return &Error{Op: op, Kind: KindInvalidResponse, StatusCode: res.StatusCode, Err: err}
+	}
+
+	return nil
+}
+
+// Returns a decoder of a JSON response.
+func decodeJSON(v interface{}) func(io.Reader) error {
+	return func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(v)
+	}
+}
+
+// Decodes nothing, for requests answered with a status code only.
+func discardResponse(r io.Reader) error {
+	return nil
+}
+
+// Returns the error for a response of NMAgent rejecting a request.
+func newResponseError(op string, responseCode string) error {
+	nmaErr := &Error{Op: op, Kind: KindRejected, ResponseCode: responseCode}
+	log.Errorf("%v", nmaErr)
+	return nmaErr
+}
+
+// GetInterfaceInfo returns the interfaces of the VM with the subnets and addresses assigned to them.
+func (c *Client) GetInterfaceInfo() (*Interfaces, error) {
+	var interfaces Interfaces
+	decode := func(r io.Reader) error {
+		return xml.NewDecoder(r).Decode(&interfaces)
+	}
+
+	if err := c.request("GetInterfaceInfo", http.MethodGet, c.interfaceInfoURL, nil, decode); err != nil {
+		return nil, err
+	}
+
+	return &interfaces, nil
+}
+
+// JoinNetwork joins the VM to a virtual network, so that network containers of the network can be published.
+func (c *Client) JoinNetwork(req *JoinNetworkRequest) error {
+	url := typeURL(c.url, fmt.Sprintf(joinNetworkType, req.NetworkID))
+	return c.request("JoinNetwork", http.MethodPost, url, []byte(""), discardResponse)
+}
+
+// PublishNetworkContainer programs a network container on the host.
+func (c *Client) PublishNetworkContainer(req *PublishNetworkContainerRequest) (*NetworkContainerResponse, error) {
+	url := typeURL(c.url, fmt.Sprintf(networkContainerType, req.PrimaryAddress, req.NetworkContainerID, req.AuthToken))
+
+	var resp NetworkContainerResponse
+	if err := c.request("PublishNetworkContainer", http.MethodPost, url, req.Body, decodeJSON(&resp)); err != nil {
+		return nil, err
+	}
+
+	if resp.ResponseCode != successfulResponseCode {
+		return nil, newResponseError("PublishNetworkContainer", resp.ResponseCode)
+	}
+
+	return &resp, nil
+}
+
+// UnpublishNetworkContainer removes a network container from the host.
+func (c *Client) UnpublishNetworkContainer(req *UnpublishNetworkContainerRequest) (*NetworkContainerResponse, error) {
+	url := typeURL(c.url, fmt.Sprintf(deleteNetworkContainerType, req.PrimaryAddress, req.NetworkContainerID, req.AuthToken))
+
+	var resp NetworkContainerResponse
+	if err := c.request("UnpublishNetworkContainer", http.MethodPost, url, []byte(""), decodeJSON(&resp)); err != nil {
+		return nil, err
+	}
+
+	if resp.ResponseCode != successfulResponseCode {
+		return nil, newResponseError("UnpublishNetworkContainer", resp.ResponseCode)
+	}
+
+	return &resp, nil
+}
+
+// GetNetworkContainerVersion returns the version of a network container programmed on the host.
+func (c *Client) GetNetworkContainerVersion(req *NetworkContainerVersionRequest) (*NetworkContainerVersion, error) {
+	url := typeURL(c.url, fmt.Sprintf(networkContainerVersionType,
+		req.PrimaryAddress, req.NetworkContainerID, req.AuthToken, req.APIVersion))
+
+	var resp NetworkContainerVersion
+	if err := c.request("GetNetworkContainerVersion", http.MethodGet, url, nil, decodeJSON(&resp)); err != nil {
+		return nil, err
+	}
+
+	// Older hosts do not report a status code in the response.
+	if resp.ResponseCode != "" && resp.ResponseCode != successfulResponseCode {
+		return nil, newResponseError("GetNetworkContainerVersion", resp.ResponseCode)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package nmagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const interfaceInfoResponse = `<Interfaces>
+	<Interface MacAddress="000D3A6E1825" IsPrimary="true">
+		<IPSubnet Prefix="10.0.0.0/16">
+			<IPAddress Address="10.0.0.4" IsPrimary="true"/>
+			<IPAddress Address="10.0.0.5" IsPrimary="false"/>
+		</IPSubnet>
+	</Interface>
+</Interfaces>`
+
+// Uses a fake clock for the duration of a test.
+func useFakeClock() (*platform.FakeClock, func()) {
+	fakeClock := platform.NewFakeClock(time.Now())
+	clock = fakeClock
+	return fakeClock, func() { clock = platform.NewClock() }
+}
+
+// Returns a fault injector answering requests with the given status codes in turn,
+// and sending them once they are used up.
+func injectStatusCodes(statusCodes []int) FaultInjector {
+	return func(op string, req *http.Request) (*http.Response, error) {
+		if len(statusCodes) == 0 {
+			return nil, nil
+		}
+
+		statusCode := statusCodes[0]
+		statusCodes = statusCodes[1:]
+		return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	}
+}
+
+// Tests that the interfaces of the VM are decoded.
+func TestGetInterfaceInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("type") != getInterfaceInfoType {
+			t.Errorf("Unexpected request %v", r.URL)
+		}
+		w.Write([]byte(interfaceInfoResponse))
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{URL: server.URL + "/machine/plugins"})
+
+	interfaces, err := client.GetInterfaceInfo()
+	if err != nil {
+		t.Fatalf("Failed to get interface info: %v", err)
+	}
+
+	if len(interfaces.Interface) != 1 || !interfaces.Interface[0].IsPrimary ||
+		interfaces.Interface[0].IPSubnet[0].Prefix != "10.0.0.0/16" ||
+		interfaces.Interface[0].IPSubnet[0].IPAddress[1].Address != "10.0.0.5" {
+		t.Errorf("Unexpected interfaces %+v", interfaces)
+	}
+}
+
+// Tests that requests are retried with backoff while wireserver is unavailable, and counted in the metrics.
+func TestRequestIsRetriedWithBackoff(t *testing.T) {
+	fakeClock, restore := useFakeClock()
+	defer restore()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&NetworkContainerResponse{ResponseCode: successfulResponseCode})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{
+		URL:           server.URL,
+		RetryDelay:    time.Second,
+		FaultInjector: injectStatusCodes([]int{http.StatusServiceUnavailable, http.StatusInternalServerError}),
+	})
+
+	start := fakeClock.Now()
+	req := &PublishNetworkContainerRequest{NetworkContainerID: "nc", PrimaryAddress: "10.0.0.4", AuthToken: "token"}
+	if _, err := client.PublishNetworkContainer(req); err != nil {
+		t.Fatalf("Failed to publish network container: %v", err)
+	}
+
+	if waited := fakeClock.Now().Sub(start); waited != 3*time.Second {
+		t.Errorf("Unexpected backoff %v", waited)
+	}
+
+	metrics := client.Metrics()["PublishNetworkContainer"]
+	if metrics.Requests != 1 || metrics.Retries != 2 || metrics.Failures != 0 {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+}
+
+// Tests that a status code reported by NMAgent fails the request without retrying it.
+func TestNMAgentErrorIsNotRetried(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !strings.HasSuffix(r.URL.Query().Get("type"), "/method/DELETE") {
+			t.Errorf("Unexpected request %v", r.URL)
+		}
+		json.NewEncoder(w).Encode(&NetworkContainerResponse{ResponseCode: "404"})
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{URL: server.URL})
+
+	req := &UnpublishNetworkContainerRequest{NetworkContainerID: "nc", PrimaryAddress: "10.0.0.4", AuthToken: "token"}
+	_, err := client.UnpublishNetworkContainer(req)
+
+	nmaErr, ok := err.(*Error)
+	if !ok || nmaErr.Kind != KindRejected || nmaErr.ResponseCode != "404" || IsUnavailable(err) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("Unexpected number of requests %d", requests)
+	}
+}
+
+// Tests that requests fail fast while the circuit is open, and are sent again after the cool down.
+func TestCircuitBreaker(t *testing.T) {
+	fakeClock, restore := useFakeClock()
+	defer restore()
+
+	var requests int
+	unavailable := errors.New("connection refused")
+	client := NewClient(Config{
+		MaxRetries:       1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+		FaultInjector: func(op string, req *http.Request) (*http.Response, error) {
+			requests++
+			return nil, unavailable
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := client.JoinNetwork(&JoinNetworkRequest{NetworkID: "vnet"}); !IsUnavailable(err) {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+
+	err := client.JoinNetwork(&JoinNetworkRequest{NetworkID: "vnet"})
+	if nmaErr, ok := err.(*Error); !ok || nmaErr.Kind != KindCircuitOpen {
+		t.Fatalf("Expected circuit to be open, got %v", err)
+	}
+
+	if requests != 4 {
+		t.Errorf("Unexpected number of requests %d", requests)
+	}
+
+	fakeClock.Sleep(time.Minute)
+	client.JoinNetwork(&JoinNetworkRequest{NetworkID: "vnet"})
+	if requests != 6 {
+		t.Errorf("Expected requests to be sent after the cool down, got %d", requests)
+	}
+
+	if metrics := client.Metrics()["JoinNetwork"]; metrics.Requests != 4 || metrics.Failures != 4 {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{
+			url:      typeURL(defaultURL, fmt.Sprintf(networkContainerType, "10.0.0.4", "nc", "secret")),
+			expected: typeURL(defaultURL, fmt.Sprintf(networkContainerType, "10.0.0.4", "nc", redactedToken)),
+		},
+		{
+			url:      typeURL(defaultURL, fmt.Sprintf(networkContainerVersionType, "10.0.0.4", "nc", "secret", "2")),
+			expected: typeURL(defaultURL, fmt.Sprintf(networkContainerVersionType, "10.0.0.4", "nc", redactedToken, "2")),
+		},
+		{url: "http://host/authenticationToken/secret", expected: "http://host/authenticationToken/" + redactedToken},
+		{url: typeURL(defaultURL, getInterfaceInfoType), expected: typeURL(defaultURL, getInterfaceInfoType)},
+	}
+
+	for _, test := range tests {
+		if redacted := redactURL(test.url); redacted != test.expected {
+			t.Errorf("TestRedactURL failed, %v redacted to %v, expected %v", test.url, redacted, test.expected)
+		}
+	}
+}
+
+// Tests that the errors of requests that could not be sent don't carry the token of the network container.
+func TestRequestErrorIsRedacted(t *testing.T) {
+	_, restore := useFakeClock()
+	defer restore()
+
+	// Errors returned by the HTTP client name the URL of the request.
+	client := NewClient(Config{URL: defaultURL})
+	client.httpc = &http.Client{Transport: failingTransport{}}
+
+	req := &UnpublishNetworkContainerRequest{NetworkContainerID: "nc", PrimaryAddress: "10.0.0.4", AuthToken: "secret"}
+	_, err := client.UnpublishNetworkContainer(req)
+	if err == nil || strings.Contains(err.Error(), "secret") || !strings.Contains(err.Error(), redactedToken) {
+		t.Errorf("TestRequestErrorIsRedacted failed, unexpected error %v", err)
+	}
+}
+
+// failingTransport fails every request without sending it.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package nmagent
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrorKind classifies why a request to NMAgent failed.
+type ErrorKind int
+
+const (
+	// KindUnavailable is a request that wireserver did not answer, or answered with a server error, after all retries.
+	KindUnavailable ErrorKind = iota
+	// KindCircuitOpen is a request that was not sent because recent requests found wireserver unavailable.
+	KindCircuitOpen
+	// KindRejected is a request that wireserver or NMAgent answered with an error code.
+	KindRejected
+	// KindInvalidResponse is a request whose response could not be decoded.
+	KindInvalidResponse
+)
+
+// Error is returned by Client methods when a request to NMAgent fails.
+type Error struct {
+	Op           string
+	Kind         ErrorKind
+	StatusCode   int       // HTTP status code of the wireserver response, if any.
+	ResponseCode string    // Status code in the NMAgent response, if any.
+	OpenUntil    time.Time // End of the cool down of an open circuit.
+	Err          error
+}
+
+// Error returns the description of the error.
+func (e *Error) Error() string {
+	switch {
+	case e.Kind == KindCircuitOpen:
+		return fmt.Sprintf("[NMAgent] %s not sent, wireserver is unavailable until %v", e.Op, e.OpenUntil.Format(time.RFC3339))
+	case e.ResponseCode != "":
+		return fmt.Sprintf("[NMAgent] %s failed with NMAgent status code: %s", e.Op, e.ResponseCode)
+	case e.StatusCode != 0 && e.Err == nil:
+		return fmt.Sprintf("[NMAgent] %s invalid http status code: %v", e.Op, e.StatusCode)
+	}
+
+	return fmt.Sprintf("[NMAgent] %s failed: %v", e.Op, e.Err)
+}
+
+// IsUnavailable checks if an error reports that wireserver could not be reached.
+func IsUnavailable(err error) bool {
+	nmaErr, ok := err.(*Error)
+	return ok && (nmaErr.Kind == KindUnavailable || nmaErr.Kind == KindCircuitOpen)
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package nmagent
+
+import (
+	"sync"
+	"time"
+)
+
+// OpMetrics counts the requests of an operation.
+type OpMetrics struct {
+	Requests  int
+	Retries   int
+	Failures  int
+	Latency   time.Duration // Total time spent in requests, including retries.
+	LastError string
+}
+
+// metrics holds the counters of all operations of a client.
+type metrics struct {
+	ops map[string]*OpMetrics
+	sync.Mutex
+}
+
+// Records a request, which failed if err is not nil.
+func (m *metrics) record(op string, retries int, latency time.Duration, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.ops == nil {
+		m.ops = make(map[string]*OpMetrics)
+	}
+
+	opMetrics := m.ops[op]
+	if opMetrics == nil {
+		opMetrics = &OpMetrics{}
+		m.ops[op] = opMetrics
+	}
+
+	opMetrics.Requests++
+	opMetrics.Retries += retries
+	opMetrics.Latency += latency
+	if err != nil {
+		opMetrics.Failures++
+		opMetrics.LastError = err.Error()
+	}
+}
+
+// Returns a copy of the counters, by operation.
+func (m *metrics) snapshot() map[string]OpMetrics {
+	m.Lock()
+	defer m.Unlock()
+
+	ops := make(map[string]OpMetrics, len(m.ops))
+	for op, opMetrics := range m.ops {
+		ops[op] = *opMetrics
+	}
+
+	return ops
+}
@@ -0,0 +1,356 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/hnsm"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// HNS ACL priorities, lower values are evaluated first.
+// Traffic with kube-system pods is always allowed, as it is by the AZURE-NPM chain on Linux.
+const (
+	aclPriorityKubeSystem uint16 = 100
+	aclPriorityAllow      uint16 = 300
+	aclPriorityDefault    uint16 = 65000
+)
+
+// aclInventory is the cluster state the HNS ACLs of the pods are computed from.
+type aclInventory struct {
+	pods       []*corev1.Pod
+	namespaces []*corev1.Namespace
+	policies   []*networkingv1.NetworkPolicy
+	exempted   map[string]bool
+}
+
+// newAclInventory creates an inventory, ordering the policies so that the computed ACLs are stable.
+// The policies of the namespaces exempted from enforcement are left out, and their pods are never selected
+// as peers, as they are in no ipset on Linux.
+func newAclInventory(pods []*corev1.Pod, namespaces []*corev1.Namespace, policies []*networkingv1.NetworkPolicy) *aclInventory {
+	exempted := make(map[string]bool)
+	for _, nsObj := range namespaces {
+		if isEnforcementDisabled(nsObj) {
+			exempted[nsObj.ObjectMeta.Name] = true
+		}
+	}
+
+	var sorted []*networkingv1.NetworkPolicy
+	for _, npObj := range policies {
+		if !exempted[npObj.ObjectMeta.Namespace] {
+			sorted = append(sorted, npObj)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ObjectMeta.Namespace != sorted[j].ObjectMeta.Namespace {
+			return sorted[i].ObjectMeta.Namespace < sorted[j].ObjectMeta.Namespace
+		}
+		return sorted[i].ObjectMeta.Name < sorted[j].ObjectMeta.Name
+	})
+
+	return &aclInventory{
+		pods:       pods,
+		namespaces: namespaces,
+		policies:   sorted,
+		exempted:   exempted,
+	}
+}
+
+// selectorMatches checks if a label selector matches the labels of an object.
+func selectorMatches(selector *metav1.LabelSelector, objLabels map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		log.Printf("Error parsing label selector %+v\n", selector)
+		return false
+	}
+
+	return s.Matches(labels.Set(objLabels))
+}
+
+// getPolicyTypes returns whether a network policy applies to the ingress and egress traffic of the pods it selects.
+func getPolicyTypes(npObj *networkingv1.NetworkPolicy) (bool, bool) {
+	if len(npObj.Spec.PolicyTypes) == 0 {
+		return true, len(npObj.Spec.Egress) > 0
+	}
+
+	var ingress, egress bool
+	for _, ptype := range npObj.Spec.PolicyTypes {
+		switch ptype {
+		case networkingv1.PolicyTypeIngress:
+			ingress = true
+		case networkingv1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+
+	return ingress, egress
+}
+
+// getProtocolNumber returns the HNS protocol number of a policy port protocol.
+func getProtocolNumber(protocol string) uint16 {
+	switch corev1.Protocol(protocol) {
+	case corev1.ProtocolUDP:
+		return hnsm.ProtocolUDP
+	default:
+		return hnsm.ProtocolTCP
+	}
+}
+
+// resolveNamedPort returns the number of the container port with the given name and protocol of a pod.
+func resolveNamedPort(podObj *corev1.Pod, info *portsInfo) (string, bool) {
+	for _, port := range getNamedPorts(podObj, podObj.Status.PodIP) {
+		if port.set != util.NamedPortIPSetPrefix+info.namedPort {
+			continue
+		}
+
+		// Elements are formatted as ip,protocol:port.
+		suffix := "," + strings.ToLower(info.protocol) + ":"
+		if i := strings.Index(port.element, suffix); i >= 0 {
+			return port.element[i+len(suffix):], true
+		}
+	}
+
+	return "", false
+}
+
+// aclPeer is a remote address allowed by a policy rule, with the pod owning it if any.
+type aclPeer struct {
+	address string
+	podObj  *corev1.Pod
+}
+
+// getPeers returns the peers selected by the peers of a policy rule. A rule without peers allows any address.
+func (inv *aclInventory) getPeers(npNs string, rulePeers []networkingv1.NetworkPolicyPeer) []aclPeer {
+	if len(rulePeers) == 0 {
+		return []aclPeer{{}}
+	}
+
+	var peers []aclPeer
+
+	for _, rulePeer := range rulePeers {
+		if rulePeer.IPBlock != nil {
+			for _, address := range getIPBlockAddresses(rulePeer.IPBlock) {
+				peers = append(peers, aclPeer{address: address})
+			}
+			continue
+		}
+
+		nsMatches := func(nsName string) bool { return nsName == npNs }
+		if rulePeer.NamespaceSelector != nil {
+			nsLabels := make(map[string]map[string]string)
+			for _, nsObj := range inv.namespaces {
+				nsLabels[nsObj.ObjectMeta.Name] = nsObj.ObjectMeta.Labels
+			}
+
+			nsMatches = func(nsName string) bool {
+				nsObjLabels, exists := nsLabels[nsName]
+				return exists && selectorMatches(rulePeer.NamespaceSelector, nsObjLabels)
+			}
+		}
+
+		for _, podObj := range inv.pods {
+			if !isValidPod(podObj) || inv.exempted[podObj.ObjectMeta.Namespace] || !nsMatches(podObj.ObjectMeta.Namespace) {
+				continue
+			}
+
+			if rulePeer.PodSelector != nil && !selectorMatches(rulePeer.PodSelector, podObj.ObjectMeta.Labels) {
+				continue
+			}
+
+			peers = append(peers, aclPeer{address: podObj.Status.PodIP, podObj: podObj})
+		}
+	}
+
+	return peers
+}
+
+// getIPBlockAddresses returns the CIDRs an ipBlock allows, its CIDR without its except ranges. HNS ACLs have no
+// exceptions, and Block ACLs would take precedence over the addresses allowed by the other rules of the pod.
+func getIPBlockAddresses(ipBlock *networkingv1.IPBlock) []string {
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		log.Printf("Error parsing ipBlock CIDR %s\n", ipBlock.CIDR)
+		return []string{ipBlock.CIDR}
+	}
+
+	remaining := []*net.IPNet{cidr}
+	for _, except := range ipBlock.Except {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err != nil {
+			log.Printf("Error parsing ipBlock except %s\n", except)
+			continue
+		}
+
+		var next []*net.IPNet
+		for _, ipNet := range remaining {
+			next = append(next, util.SubtractCIDR(ipNet, exceptNet)...)
+		}
+		remaining = next
+	}
+
+	var addresses []string
+	for _, ipNet := range remaining {
+		addresses = append(addresses, ipNet.String())
+	}
+
+	return addresses
+}
+
+// getRuleACLs returns the ACLs of an ingress or egress rule of a policy selecting a pod.
+// Named ports are resolved on the pod for ingress rules, and on the peer pods for egress rules.
+func (inv *aclInventory) getRuleACLs(direction string, podObj *corev1.Pod, npNs string, rulePeers []networkingv1.NetworkPolicyPeer, rulePorts []networkingv1.NetworkPolicyPort) []*hnsm.ACL {
+	var acls []*hnsm.ACL
+
+	peers := inv.getPeers(npNs, rulePeers)
+
+	ports := []*portsInfo{nil}
+	if len(rulePorts) > 0 {
+		ports = nil
+		for _, portRule := range rulePorts {
+			ports = append(ports, getPortsInfo(portRule))
+		}
+	}
+
+	newACL := func(action string, priority uint16, info *portsInfo, port string, addresses []string) *hnsm.ACL {
+		acl := &hnsm.ACL{
+			Action:          action,
+			Direction:       direction,
+			Protocol:        hnsm.ProtocolAny,
+			RemoteAddresses: strings.Join(util.UniqueStrSlice(addresses), ","),
+			Priority:        priority,
+		}
+
+		if info != nil {
+			acl.Protocol = getProtocolNumber(info.protocol)
+			if direction == hnsm.DirectionIn {
+				acl.LocalPorts = port
+			} else {
+				acl.RemotePorts = port
+			}
+		}
+
+		return acl
+	}
+
+	for _, info := range ports {
+		// Group the allowed addresses by destination port.
+		portMap := make(map[string][]string)
+		for _, peer := range peers {
+			port := ""
+			if info != nil {
+				port = info.port
+			}
+
+			if info != nil && info.namedPort != "" {
+				target := podObj
+				if direction == hnsm.DirectionOut {
+					target = peer.podObj
+				}
+
+				var resolved bool
+				if target != nil {
+					port, resolved = resolveNamedPort(target, info)
+				}
+
+				if !resolved {
+					continue
+				}
+			}
+
+			portMap[port] = append(portMap[port], peer.address)
+		}
+
+		var portList []string
+		for port := range portMap {
+			portList = append(portList, port)
+		}
+		sort.Strings(portList)
+
+		for _, port := range portList {
+			addresses := portMap[port]
+			sort.Strings(addresses)
+			acls = append(acls, newACL(hnsm.ActionAllow, aclPriorityAllow, info, port, addresses))
+		}
+	}
+
+	return acls
+}
+
+// getEndpointACLs returns the HNS ACLs enforcing the network policies that select a pod.
+// A pod selected by no policy gets no ACLs, allowing all its traffic.
+func (inv *aclInventory) getEndpointACLs(podObj *corev1.Pod) []*hnsm.ACL {
+	var (
+		acls            []*hnsm.ACL
+		ingress, egress bool
+	)
+
+	for _, npObj := range inv.policies {
+		npNs := npObj.ObjectMeta.Namespace
+		if npNs != podObj.ObjectMeta.Namespace || !selectorMatches(&npObj.Spec.PodSelector, podObj.ObjectMeta.Labels) {
+			continue
+		}
+
+		policyIngress, policyEgress := getPolicyTypes(npObj)
+		if policyIngress {
+			ingress = true
+			for _, rule := range npObj.Spec.Ingress {
+				acls = append(acls, inv.getRuleACLs(hnsm.DirectionIn, podObj, npNs, rule.From, rule.Ports)...)
+			}
+		}
+
+		if policyEgress {
+			egress = true
+			for _, rule := range npObj.Spec.Egress {
+				acls = append(acls, inv.getRuleACLs(hnsm.DirectionOut, podObj, npNs, rule.To, rule.Ports)...)
+			}
+		}
+	}
+
+	if !ingress && !egress {
+		return nil
+	}
+
+	var kubeSystemAddresses []string
+	for _, p := range inv.pods {
+		if isSystemPod(p) && isValidPod(p) {
+			kubeSystemAddresses = append(kubeSystemAddresses, p.Status.PodIP)
+		}
+	}
+	sort.Strings(kubeSystemAddresses)
+
+	directions := map[string]bool{
+		hnsm.DirectionIn:  ingress,
+		hnsm.DirectionOut: egress,
+	}
+
+	for _, direction := range []string{hnsm.DirectionIn, hnsm.DirectionOut} {
+		if !directions[direction] {
+			// The pod has ACLs for the other direction only.
+			acls = append(acls, &hnsm.ACL{Action: hnsm.ActionAllow, Direction: direction, Protocol: hnsm.ProtocolAny, Priority: aclPriorityDefault})
+			continue
+		}
+
+		if len(kubeSystemAddresses) > 0 {
+			acls = append(acls, &hnsm.ACL{
+				Action:          hnsm.ActionAllow,
+				Direction:       direction,
+				Protocol:        hnsm.ProtocolAny,
+				RemoteAddresses: strings.Join(kubeSystemAddresses, ","),
+				Priority:        aclPriorityKubeSystem,
+			})
+		}
+
+		acls = append(acls, &hnsm.ACL{Action: hnsm.ActionBlock, Direction: direction, Protocol: hnsm.ProtocolAny, Priority: aclPriorityDefault})
+	}
+
+	return acls
+}
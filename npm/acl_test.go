@@ -0,0 +1,199 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/hnsm"
+	"github.com/Azure/azure-container-networking/npm/util"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newAclTestPod(ns, name, ip string, podLabels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      name,
+			Labels:    podLabels,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Ports: []corev1.ContainerPort{
+						{Name: "http", ContainerPort: 8080},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: ip,
+		},
+	}
+}
+
+func TestGetEndpointACLs(t *testing.T) {
+	target := newAclTestPod("test", "target", "10.0.0.4", map[string]string{"app": "db"})
+	frontend := newAclTestPod("test", "frontend", "10.0.0.5", map[string]string{"app": "frontend"})
+	other := newAclTestPod("other", "frontend", "10.0.0.6", map[string]string{"app": "frontend"})
+	dns := newAclTestPod(util.KubeSystemFlag, "dns", "10.0.0.10", nil)
+
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "test"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"team": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: util.KubeSystemFlag}},
+	}
+
+	namedPort := intstr.FromString("http")
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test",
+			Name:      "allow-frontend",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "db"},
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{"app": "frontend"},
+							},
+						},
+						{
+							NamespaceSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{"team": "web"},
+							},
+						},
+					},
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Port: &namedPort},
+					},
+				},
+			},
+		},
+	}
+
+	inv := newAclInventory([]*corev1.Pod{target, frontend, other, dns}, namespaces, []*networkingv1.NetworkPolicy{npObj})
+
+	if acls := inv.getEndpointACLs(frontend); len(acls) != 0 {
+		t.Errorf("TestGetEndpointACLs failed @ pod selected by no policy: %+v", acls)
+	}
+
+	expected := []hnsm.ACL{
+		{Action: hnsm.ActionAllow, Direction: hnsm.DirectionIn, Protocol: hnsm.ProtocolTCP, LocalPorts: "8080", RemoteAddresses: "10.0.0.5,10.0.0.6", Priority: aclPriorityAllow},
+		{Action: hnsm.ActionAllow, Direction: hnsm.DirectionIn, Protocol: hnsm.ProtocolAny, RemoteAddresses: "10.0.0.10", Priority: aclPriorityKubeSystem},
+		{Action: hnsm.ActionBlock, Direction: hnsm.DirectionIn, Protocol: hnsm.ProtocolAny, Priority: aclPriorityDefault},
+		{Action: hnsm.ActionAllow, Direction: hnsm.DirectionOut, Protocol: hnsm.ProtocolAny, Priority: aclPriorityDefault},
+	}
+
+	acls := inv.getEndpointACLs(target)
+	if len(acls) != len(expected) {
+		t.Fatalf("TestGetEndpointACLs failed @ ACL count: %d", len(acls))
+	}
+
+	for i := range expected {
+		if *acls[i] != expected[i] {
+			t.Errorf("TestGetEndpointACLs failed @ ACL %d: %+v, expected %+v", i, *acls[i], expected[i])
+		}
+	}
+}
+
+func TestGetEndpointACLsIPBlockEgress(t *testing.T) {
+	target := newAclTestPod("test", "target", "10.0.0.4", nil)
+	port := intstr.FromInt(443)
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test",
+			Name:      "egress",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{
+					To: []networkingv1.NetworkPolicyPeer{
+						{
+							IPBlock: &networkingv1.IPBlock{
+								CIDR:   "10.1.0.0/16",
+								Except: []string{"10.1.1.0/24"},
+							},
+						},
+					},
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Port: &port},
+					},
+				},
+			},
+		},
+	}
+
+	inv := newAclInventory([]*corev1.Pod{target}, nil, []*networkingv1.NetworkPolicy{npObj})
+
+	expected := []hnsm.ACL{
+		{Action: hnsm.ActionAllow, Direction: hnsm.DirectionOut, Protocol: hnsm.ProtocolTCP, RemotePorts: "443", RemoteAddresses: "10.1.0.0/24,10.1.128.0/17,10.1.16.0/20,10.1.2.0/23,10.1.32.0/19,10.1.4.0/22,10.1.64.0/18,10.1.8.0/21", Priority: aclPriorityAllow},
+		{Action: hnsm.ActionAllow, Direction: hnsm.DirectionIn, Protocol: hnsm.ProtocolAny, Priority: aclPriorityDefault},
+		{Action: hnsm.ActionBlock, Direction: hnsm.DirectionOut, Protocol: hnsm.ProtocolAny, Priority: aclPriorityDefault},
+	}
+
+	acls := inv.getEndpointACLs(target)
+	if len(acls) != len(expected) {
+		t.Fatalf("TestGetEndpointACLsIPBlockEgress failed @ ACL count: %d", len(acls))
+	}
+
+	for i := range expected {
+		if *acls[i] != expected[i] {
+			t.Errorf("TestGetEndpointACLsIPBlockEgress failed @ ACL %d: %+v, expected %+v", i, *acls[i], expected[i])
+		}
+	}
+}
+
+func TestGetIPBlockAddresses(t *testing.T) {
+	tests := []struct {
+		ipBlock  networkingv1.IPBlock
+		expected []string
+	}{
+		{
+			ipBlock:  networkingv1.IPBlock{CIDR: "10.0.0.0/8"},
+			expected: []string{"10.0.0.0/8"},
+		},
+		{
+			ipBlock:  networkingv1.IPBlock{CIDR: "10.0.0.0/24", Except: []string{"10.0.0.128/25"}},
+			expected: []string{"10.0.0.0/25"},
+		},
+		{
+			ipBlock:  networkingv1.IPBlock{CIDR: "10.0.0.0/24", Except: []string{"10.0.0.64/26"}},
+			expected: []string{"10.0.0.0/26", "10.0.0.128/25"},
+		},
+		{
+			ipBlock:  networkingv1.IPBlock{CIDR: "10.0.0.0/24", Except: []string{"10.0.0.0/26", "10.0.0.192/26"}},
+			expected: []string{"10.0.0.64/26", "10.0.0.128/26"},
+		},
+		{
+			ipBlock:  networkingv1.IPBlock{CIDR: "10.0.0.0/24", Except: []string{"10.1.0.0/16"}},
+			expected: []string{"10.0.0.0/24"},
+		},
+		{
+			ipBlock:  networkingv1.IPBlock{CIDR: "10.0.0.0/24", Except: []string{"10.0.0.0/16"}},
+			expected: nil,
+		},
+		{
+			ipBlock:  networkingv1.IPBlock{CIDR: "fd00::/64", Except: []string{"fd00::/65"}},
+			expected: []string{"fd00::8000:0:0:0/65"},
+		},
+	}
+
+	for _, test := range tests {
+		addresses := getIPBlockAddresses(&test.ipBlock)
+		if !reflect.DeepEqual(addresses, test.expected) {
+			t.Errorf("TestGetIPBlockAddresses failed @ %+v: %v, expected %v", test.ipBlock, addresses, test.expected)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+const (
+	// Bytes of the logged packets copied to npm, enough for the network and transport headers.
+	auditCopyRange = 128
+
+	// Max number of distinct flows counted between two reports, the packets of other flows are only counted in total.
+	maxAuditFlows = 1000
+
+	// Max number of flows in a report, the ones with the most packets.
+	maxReportedAuditFlows = 100
+)
+
+// auditLog counts the packets network policies would have dropped in audit mode, by flow.
+type auditLog struct {
+	sync.Mutex
+	flows   map[telemetry.BlockedFlow]int
+	packets int
+}
+
+// parseAuditPacket returns the flow of a packet logged by an audit rule, or false if the packet wasn't logged
+// by npm or can't be decoded.
+func parseAuditPacket(prefix string, payload []byte) (telemetry.BlockedFlow, bool) {
+	var (
+		flow      telemetry.BlockedFlow
+		protocol  byte
+		transport []byte
+	)
+
+	if !strings.HasPrefix(prefix, util.NpmAuditNflogPrefix) || len(payload) == 0 {
+		return flow, false
+	}
+	flow.Target = strings.TrimPrefix(prefix, util.NpmAuditNflogPrefix)
+
+	switch payload[0] >> 4 {
+	case 4:
+		headerLen := int(payload[0]&0x0f) * 4
+		if len(payload) < 20 || headerLen < 20 {
+			return flow, false
+		}
+		protocol = payload[9]
+		flow.SrcIP = net.IP(payload[12:16]).String()
+		flow.DstIP = net.IP(payload[16:20]).String()
+		if len(payload) > headerLen {
+			transport = payload[headerLen:]
+		}
+	case 6:
+		if len(payload) < 40 {
+			return flow, false
+		}
+		protocol = payload[6]
+		flow.SrcIP = net.IP(payload[8:24]).String()
+		flow.DstIP = net.IP(payload[24:40]).String()
+		transport = payload[40:]
+	default:
+		return flow, false
+	}
+
+	switch protocol {
+	case 1:
+		flow.Protocol = util.IcmpProtocol
+	case 58:
+		flow.Protocol = util.Icmpv6Protocol
+	case 6:
+		flow.Protocol = "tcp"
+	case 17:
+		flow.Protocol = "udp"
+	case 132:
+		flow.Protocol = "sctp"
+	default:
+		flow.Protocol = strconv.Itoa(int(protocol))
+	}
+
+	// TCP, UDP and SCTP headers start with the source and destination ports.
+	if (protocol == 6 || protocol == 17 || protocol == 132) && len(transport) >= 4 {
+		flow.DstPort = int(binary.BigEndian.Uint16(transport[2:4]))
+	}
+
+	return flow, true
+}
+
+// recordAuditPacket counts a packet logged by an audit rule in its flow.
+func (npMgr *NetworkPolicyManager) recordAuditPacket(prefix string, payload []byte) {
+	flow, ok := parseAuditPacket(prefix, payload)
+	if !ok {
+		return
+	}
+
+	npMgr.audit.Lock()
+	defer npMgr.audit.Unlock()
+
+	if npMgr.audit.flows == nil {
+		npMgr.audit.flows = make(map[telemetry.BlockedFlow]int)
+	}
+
+	npMgr.audit.packets++
+	if _, ok := npMgr.audit.flows[flow]; ok || len(npMgr.audit.flows) < maxAuditFlows {
+		npMgr.audit.flows[flow]++
+	}
+}
+
+// getAuditInfo returns the flows counted since it was last called, the ones with the most packets first,
+// and starts counting again.
+func (npMgr *NetworkPolicyManager) getAuditInfo() telemetry.AuditInfo {
+	npMgr.audit.Lock()
+	defer npMgr.audit.Unlock()
+
+	info := telemetry.AuditInfo{
+		Enabled:        util.IsAuditModeEnabled,
+		BlockedPackets: npMgr.audit.packets,
+	}
+
+	for flow, count := range npMgr.audit.flows {
+		flow.Count = count
+		info.BlockedFlows = append(info.BlockedFlows, flow)
+	}
+
+	sort.Slice(info.BlockedFlows, func(i, j int) bool {
+		a, b := info.BlockedFlows[i], info.BlockedFlows[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Target+a.SrcIP+a.DstIP < b.Target+b.SrcIP+b.DstIP
+	})
+
+	if len(info.BlockedFlows) > maxReportedAuditFlows {
+		info.BlockedFlows = info.BlockedFlows[:maxReportedAuditFlows]
+	}
+
+	npMgr.audit.flows = nil
+	npMgr.audit.packets = 0
+
+	return info
+}
+
+// setAuditReport sets the flows policies would have dropped since the last report in the telemetry report.
+func (npMgr *NetworkPolicyManager) setAuditReport() {
+	reflect.ValueOf(npMgr.reportManager.Report).Elem().FieldByName("Audit").Set(reflect.ValueOf(npMgr.getAuditInfo()))
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// StartAuditLogger subscribes to the packets the audit rules log instead of dropping them, and counts them
+// by flow for telemetry until stopCh is closed.
+func (npMgr *NetworkPolicyManager) StartAuditLogger(stopCh <-chan struct{}) error {
+	sub, err := netlink.SubscribeNflog(uint16(util.NpmAuditNflogGroup), auditCopyRange)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer sub.Close()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case packet, ok := <-sub.Packets():
+				if !ok {
+					log.Printf("Audit log subscription ended, packets policies would drop are no longer reported\n")
+					return
+				}
+				npMgr.recordAuditPacket(packet.Prefix, packet.Payload)
+			}
+		}
+	}()
+
+	return nil
+}
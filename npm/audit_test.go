@@ -0,0 +1,74 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// Returns an IPv4 packet of the given protocol with a transport header to the given port.
+func newAuditPacket(src, dst string, protocol byte, port uint16) []byte {
+	packet := make([]byte, 28)
+	packet[0] = 0x45
+	packet[9] = protocol
+	copy(packet[12:16], net.ParseIP(src).To4())
+	copy(packet[16:20], net.ParseIP(dst).To4())
+	packet[22], packet[23] = byte(port>>8), byte(port)
+	return packet
+}
+
+func TestParseAuditPacket(t *testing.T) {
+	prefix := util.NpmAuditNflogPrefix + "all-namespace-app:web"
+
+	flow, ok := parseAuditPacket(prefix, newAuditPacket("10.240.0.5", "10.240.0.6", 6, 8080))
+	expected := telemetry.BlockedFlow{Target: "all-namespace-app:web", Protocol: "tcp", SrcIP: "10.240.0.5", DstIP: "10.240.0.6", DstPort: 8080}
+	if !ok || flow != expected {
+		t.Errorf("TestParseAuditPacket failed, unexpected flow %+v", flow)
+	}
+
+	ipv6 := make([]byte, 40)
+	ipv6[0], ipv6[6] = 0x60, 58
+	copy(ipv6[8:24], net.ParseIP("fd00::5"))
+	copy(ipv6[24:40], net.ParseIP("fd00::6"))
+	flow, ok = parseAuditPacket(prefix, ipv6)
+	if !ok || flow.Protocol != util.Icmpv6Protocol || flow.SrcIP != "fd00::5" || flow.DstIP != "fd00::6" || flow.DstPort != 0 {
+		t.Errorf("TestParseAuditPacket failed, unexpected IPv6 flow %+v", flow)
+	}
+
+	if _, ok = parseAuditPacket("other-prefix", newAuditPacket("10.240.0.5", "10.240.0.6", 6, 80)); ok {
+		t.Errorf("TestParseAuditPacket failed, packet of another prefix decoded")
+	}
+
+	if _, ok = parseAuditPacket(prefix, []byte{0x45, 0}); ok {
+		t.Errorf("TestParseAuditPacket failed, truncated packet decoded")
+	}
+}
+
+func TestAuditInfo(t *testing.T) {
+	npMgr := &NetworkPolicyManager{}
+	prefix := util.NpmAuditNflogPrefix + "ns-default"
+
+	for i := 0; i < 3; i++ {
+		npMgr.recordAuditPacket(prefix, newAuditPacket("10.240.0.5", "10.240.0.6", 17, 53))
+	}
+	npMgr.recordAuditPacket(prefix, newAuditPacket("10.240.0.7", "10.240.0.6", 6, 443))
+	npMgr.recordAuditPacket("other-prefix", newAuditPacket("10.240.0.7", "10.240.0.6", 6, 443))
+
+	info := npMgr.getAuditInfo()
+	if info.BlockedPackets != 4 || len(info.BlockedFlows) != 2 {
+		t.Fatalf("TestAuditInfo failed, unexpected info %+v", info)
+	}
+
+	if info.BlockedFlows[0].Count != 3 || info.BlockedFlows[0].DstPort != 53 || info.BlockedFlows[1].Count != 1 {
+		t.Errorf("TestAuditInfo failed, flows not sorted by count %+v", info.BlockedFlows)
+	}
+
+	// Flows are counted again from the report on.
+	if info = npMgr.getAuditInfo(); info.BlockedPackets != 0 || len(info.BlockedFlows) != 0 {
+		t.Errorf("TestAuditInfo failed, flows not reset %+v", info)
+	}
+}
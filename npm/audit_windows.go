@@ -0,0 +1,12 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"fmt"
+)
+
+// StartAuditLogger returns an error as audit mode is not supported with HNS ACLs.
+func (npMgr *NetworkPolicyManager) StartAuditLogger(stopCh <-chan struct{}) error {
+	return fmt.Errorf("Audit mode is not supported on Windows")
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// RunConsistencyReconciler repairs the drift of the dataplane from the state programmed by npm every interval,
+// e.g. chains flushed by an admin or by another agent restarting, until stopCh is closed.
+func (npMgr *NetworkPolicyManager) RunConsistencyReconciler(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := npMgr.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C():
+			npMgr.ReconcileDataplane()
+		}
+	}
+}
+
+// ReconcileDataplane compares the dataplane with the state programmed by npm and repairs the drift.
+// It returns how much drift was found, which is reported to telemetry.
+func (npMgr *NetworkPolicyManager) ReconcileDataplane() (int, error) {
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	drift, err := npMgr.repairDataplaneDrift()
+
+	npMgr.dataplaneDrift.ReconcileCount++
+	npMgr.dataplaneDrift.LastDriftCount = drift
+	npMgr.dataplaneDrift.TotalDriftCount += drift
+	npMgr.dataplaneDrift.LastReconcileError = ""
+	if drift > 0 {
+		npMgr.dataplaneDrift.DriftedCount++
+	}
+
+	if err != nil {
+		log.Printf("Error reconciling dataplane: %v\n", err)
+		npMgr.dataplaneDrift.LastReconcileError = err.Error()
+	}
+
+	if drift == 0 && err == nil {
+		return 0, nil
+	}
+
+	log.Printf("Dataplane reconcile found %d drifted entries\n", drift)
+	if sendErr := npMgr.UpdateAndSendReport(err, util.ReconcileDataplaneEvent); sendErr != nil {
+		log.Printf("Error sending NPM telemetry report")
+	}
+
+	return drift, err
+}
+
+// setDriftReport sets the dataplane drift found so far in the telemetry report.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) setDriftReport() {
+	reflect.ValueOf(npMgr.reportManager.Report).Elem().FieldByName("DataplaneDrift").Set(reflect.ValueOf(npMgr.dataplaneDrift))
+}
@@ -0,0 +1,205 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/telemetry"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Policy programming states written to the policy status annotation.
+const (
+	PolicyStateApplied = "Applied"
+	PolicyStateFailed  = "Failed"
+)
+
+// convergenceBuckets are the upper bounds in seconds of the policy convergence histogram.
+var convergenceBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+// PolicyStatus is the outcome of programming a network policy on a node.
+type PolicyStatus struct {
+	State         string
+	Error         string `json:",omitempty"`
+	ConvergenceMs int64
+	Time          time.Time
+}
+
+// convergenceStats measures the time from network policy events to the completion of their dataplane programming.
+type convergenceStats struct {
+	count          uint64
+	failures       uint64
+	sum            time.Duration
+	max            time.Duration
+	last           time.Duration
+	buckets        []uint64
+	failedPolicies map[string]PolicyStatus // namespace/name -> status of the policies whose last programming failed.
+}
+
+// observe records the convergence time and the outcome of a policy event.
+func (stats *convergenceStats) observe(key string, status PolicyStatus, d time.Duration) {
+	if stats.buckets == nil {
+		stats.buckets = make([]uint64, len(convergenceBuckets))
+		stats.failedPolicies = make(map[string]PolicyStatus)
+	}
+
+	stats.count++
+	stats.sum += d
+	stats.last = d
+	if d > stats.max {
+		stats.max = d
+	}
+
+	for i, bound := range convergenceBuckets {
+		if d.Seconds() <= bound {
+			stats.buckets[i]++
+		}
+	}
+
+	if status.State == PolicyStateFailed {
+		stats.failures++
+		stats.failedPolicies[key] = status
+	} else {
+		delete(stats.failedPolicies, key)
+	}
+}
+
+// getFailedPolicyCount returns the number of policies whose last programming failed.
+func (stats *convergenceStats) getFailedPolicyCount() int {
+	return len(stats.failedPolicies)
+}
+
+// getPolicyStatusAnnotationValue returns the value of the policy status annotation of the node, the status of
+// the policies whose last programming failed. The others are applied.
+func (stats *convergenceStats) getPolicyStatusAnnotationValue() (string, error) {
+	failed := stats.failedPolicies
+	if failed == nil {
+		failed = make(map[string]PolicyStatus)
+	}
+
+	value, err := json.Marshal(failed)
+	return string(value), err
+}
+
+// recordPolicyConvergence records the convergence of an event of a network policy received at eventTime,
+// and updates the policy status annotation of the node when the set of failed policies changed.
+func (npMgr *NetworkPolicyManager) recordPolicyConvergence(npNs, npName string, eventTime time.Time, err error) {
+	npMgr.Lock()
+	d := npMgr.clock.Since(eventTime)
+	status := PolicyStatus{
+		State:         PolicyStateApplied,
+		ConvergenceMs: int64(d / time.Millisecond),
+		Time:          npMgr.clock.Now().UTC(),
+	}
+	if err != nil {
+		status.State = PolicyStateFailed
+		status.Error = err.Error()
+	}
+	npMgr.policyConvergence.observe(npNs+"/"+npName, status, d)
+	npMgr.Unlock()
+
+	log.Printf("Network policy %s/%s converged in %v, err: %v\n", npNs, npName, d, err)
+
+	if npMgr.clientset == nil {
+		return
+	}
+
+	if patchErr := npMgr.patchPolicyStatus(); patchErr != nil {
+		log.Printf("Error updating the policy status of node %s: %v\n", npMgr.nodeName, patchErr)
+	}
+}
+
+// patchPolicyStatus writes the status of the policies on this node to the annotation of its node object.
+// Each node only writes its own object, which npm doesn't watch, and only when the failed policies changed, so
+// the writes don't grow with the number of nodes times the number of policies.
+func (npMgr *NetworkPolicyManager) patchPolicyStatus() error {
+	npMgr.policyStatusLock.Lock()
+	defer npMgr.policyStatusLock.Unlock()
+
+	npMgr.Lock()
+	value, err := npMgr.policyConvergence.getPolicyStatusAnnotationValue()
+	npMgr.Unlock()
+	if err != nil || value == npMgr.writtenPolicyStatus {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{util.PolicyStatusAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err = npMgr.clientset.CoreV1().Nodes().Patch(npMgr.nodeName, types.MergePatchType, patch); err != nil {
+		return err
+	}
+
+	npMgr.writtenPolicyStatus = value
+	return nil
+}
+
+// getConvergenceInfo returns the policy convergence statistics reported to telemetry.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) getConvergenceInfo() telemetry.ConvergenceInfo {
+	stats := &npMgr.policyConvergence
+	info := telemetry.ConvergenceInfo{
+		PolicyEventCount:   int(stats.count),
+		FailedEventCount:   int(stats.failures),
+		FailedPolicyCount:  stats.getFailedPolicyCount(),
+		LastConvergenceMs:  int64(stats.last / time.Millisecond),
+		MaxConvergenceMs:   int64(stats.max / time.Millisecond),
+		TotalConvergenceMs: int64(stats.sum / time.Millisecond),
+	}
+
+	return info
+}
+
+// writeMetrics writes the policy convergence metrics in the Prometheus text format.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) writeMetrics(w io.Writer) {
+	stats := &npMgr.policyConvergence
+
+	fmt.Fprintf(w, "# HELP npm_policy_convergence_seconds Time from a network policy event to the completion of its dataplane programming.\n")
+	fmt.Fprintf(w, "# TYPE npm_policy_convergence_seconds histogram\n")
+	for i, bound := range convergenceBuckets {
+		var count uint64
+		if stats.buckets != nil {
+			count = stats.buckets[i]
+		}
+		fmt.Fprintf(w, "npm_policy_convergence_seconds_bucket{le=\"%g\"} %d\n", bound, count)
+	}
+	fmt.Fprintf(w, "npm_policy_convergence_seconds_bucket{le=\"+Inf\"} %d\n", stats.count)
+	fmt.Fprintf(w, "npm_policy_convergence_seconds_sum %g\n", stats.sum.Seconds())
+	fmt.Fprintf(w, "npm_policy_convergence_seconds_count %d\n", stats.count)
+
+	fmt.Fprintf(w, "# HELP npm_policy_programming_failures_total Network policy events whose dataplane programming failed.\n")
+	fmt.Fprintf(w, "# TYPE npm_policy_programming_failures_total counter\n")
+	fmt.Fprintf(w, "npm_policy_programming_failures_total %d\n", stats.failures)
+
+	fmt.Fprintf(w, "# HELP npm_failed_policies Network policies whose last dataplane programming failed.\n")
+	fmt.Fprintf(w, "# TYPE npm_failed_policies gauge\n")
+	fmt.Fprintf(w, "npm_failed_policies %d\n", stats.getFailedPolicyCount())
+}
+
+// ServeMetrics handles Prometheus scrapes of the policy convergence metrics.
+func (npMgr *NetworkPolicyManager) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	npMgr.writeMetrics(w)
+}
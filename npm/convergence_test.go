@@ -0,0 +1,77 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+func TestRecordPolicyConvergence(t *testing.T) {
+	clock := platform.NewFakeClock(time.Now())
+	npMgr := &NetworkPolicyManager{
+		clock: clock,
+	}
+
+	eventTime := clock.Now()
+	clock.Advance(2 * time.Second)
+	npMgr.recordPolicyConvergence("test-ns", "allow-frontend", eventTime, nil)
+
+	eventTime = clock.Now()
+	clock.Advance(200 * time.Millisecond)
+	npMgr.recordPolicyConvergence("test-ns", "allow-frontend", eventTime, fmt.Errorf("test error"))
+
+	info := npMgr.getConvergenceInfo()
+	if info.PolicyEventCount != 2 || info.FailedEventCount != 1 || info.FailedPolicyCount != 1 {
+		t.Errorf("TestRecordPolicyConvergence failed @ event counts: %+v", info)
+	}
+
+	if info.LastConvergenceMs != 200 || info.MaxConvergenceMs != 2000 || info.TotalConvergenceMs != 2200 {
+		t.Errorf("TestRecordPolicyConvergence failed @ convergence times: %+v", info)
+	}
+
+	var buf bytes.Buffer
+	npMgr.writeMetrics(&buf)
+	for _, line := range []string{
+		"npm_policy_convergence_seconds_bucket{le=\"0.1\"} 0\n",
+		"npm_policy_convergence_seconds_bucket{le=\"0.5\"} 1\n",
+		"npm_policy_convergence_seconds_bucket{le=\"5\"} 2\n",
+		"npm_policy_convergence_seconds_count 2\n",
+		"npm_policy_programming_failures_total 1\n",
+		"npm_failed_policies 1\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("TestRecordPolicyConvergence failed @ metrics missing %q:\n%s", line, buf.String())
+		}
+	}
+
+	npMgr.recordPolicyConvergence("test-ns", "allow-frontend", clock.Now(), nil)
+	if info := npMgr.getConvergenceInfo(); info.FailedPolicyCount != 0 {
+		t.Errorf("TestRecordPolicyConvergence failed @ recovered policy: %+v", info)
+	}
+}
+
+func TestGetPolicyStatusAnnotationValue(t *testing.T) {
+	clock := platform.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	npMgr := &NetworkPolicyManager{
+		clock: clock,
+	}
+
+	if value, err := npMgr.policyConvergence.getPolicyStatusAnnotationValue(); err != nil || value != "{}" {
+		t.Errorf("TestGetPolicyStatusAnnotationValue failed @ no policies: %s, %v", value, err)
+	}
+
+	// Applied policies are not listed, only the failed ones are.
+	npMgr.recordPolicyConvergence("test-ns", "allow-frontend", clock.Now(), nil)
+	npMgr.recordPolicyConvergence("test-ns", "deny-all", clock.Now(), fmt.Errorf("test error"))
+
+	expected := `{"test-ns/deny-all":{"State":"Failed","Error":"test error","ConvergenceMs":0,"Time":"2020-01-01T00:00:00Z"}}`
+	if value, err := npMgr.policyConvergence.getPolicyStatusAnnotationValue(); err != nil || value != expected {
+		t.Errorf("TestGetPolicyStatusAnnotationValue failed @ failed policy: %s, %v", value, err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PodState reports the ipsets a pod is a member of.
+type PodState struct {
+	Namespace string
+	Name      string
+	IP        string
+	Ipsets    []string
+}
+
+// PolicyState reports the ipsets a network policy refers to and the rules it adds to each iptables chain.
+type PolicyState struct {
+	Namespace string
+	Name      string
+	Ipsets    []string
+	Chains    map[string]int
+}
+
+// DebugState reports the internal caches of npm.
+type DebugState struct {
+	Pods     []PodState
+	Policies []PolicyState
+}
+
+// VerifyResult reports the drift of the dataplane from the state programmed by npm.
+type VerifyResult struct {
+	Consistent bool
+	Drift      []string `json:",omitempty"`
+	Error      string   `json:",omitempty"`
+}
+
+// GetDebugState returns the ipset membership of the pods known to npm and the dataplane state of the network policies.
+func (npMgr *NetworkPolicyManager) GetDebugState() (*DebugState, error) {
+	pods, err := npMgr.podInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	return npMgr.getDebugState(pods), nil
+}
+
+// getDebugState computes the debug state given the pods in the cluster.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) getDebugState(pods []*corev1.Pod) *DebugState {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+	state := &DebugState{}
+
+	// Set members are pod IPs, optionally followed by the protocol and port of a named port.
+	setsByIP := make(map[string][]string)
+	for set, members := range allNs.ipsMgr.GetSetMembers() {
+		for _, member := range members {
+			ip := strings.Split(member, ",")[0]
+			setsByIP[ip] = append(setsByIP[ip], set)
+		}
+	}
+
+	for _, podObj := range pods {
+		if !isValidPod(podObj) {
+			continue
+		}
+
+		podState := PodState{
+			Namespace: podObj.ObjectMeta.Namespace,
+			Name:      podObj.ObjectMeta.Name,
+			IP:        podObj.Status.PodIP,
+			Ipsets:    setsByIP[podObj.Status.PodIP],
+		}
+		sort.Strings(podState.Ipsets)
+		state.Pods = append(state.Pods, podState)
+	}
+
+	for _, npObj := range allNs.npMap {
+		policy := optimizePolicy(npObj)
+
+		policyState := PolicyState{
+			Namespace: npObj.ObjectMeta.Namespace,
+			Name:      npObj.ObjectMeta.Name,
+			Ipsets:    append(policy.podSets, policy.nsLists...),
+			Chains:    make(map[string]int),
+		}
+		for _, entry := range policy.entries {
+			policyState.Chains[entry.Chain]++
+		}
+		sort.Strings(policyState.Ipsets)
+		state.Policies = append(state.Policies, policyState)
+	}
+
+	sort.Slice(state.Pods, func(i, j int) bool {
+		if state.Pods[i].Namespace != state.Pods[j].Namespace {
+			return state.Pods[i].Namespace < state.Pods[j].Namespace
+		}
+		return state.Pods[i].Name < state.Pods[j].Name
+	})
+
+	sort.Slice(state.Policies, func(i, j int) bool {
+		if state.Policies[i].Namespace != state.Policies[j].Namespace {
+			return state.Policies[i].Namespace < state.Policies[j].Namespace
+		}
+		return state.Policies[i].Name < state.Policies[j].Name
+	})
+
+	return state
+}
+
+// VerifyDataplane compares the dataplane with the state programmed by npm without repairing it.
+func (npMgr *NetworkPolicyManager) VerifyDataplane() VerifyResult {
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	drift, err := npMgr.verifyDataplane()
+	if err != nil {
+		log.Printf("Error verifying dataplane: %v\n", err)
+		return VerifyResult{Error: err.Error()}
+	}
+
+	return VerifyResult{Consistent: len(drift) == 0, Drift: drift}
+}
+
+// ServeDebugState handles requests for the internal caches of npm.
+func (npMgr *NetworkPolicyManager) ServeDebugState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state, err := npMgr.GetDebugState()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		log.Printf("Error encoding debug state: %v\n", err)
+	}
+}
+
+// ServeVerify handles requests to verify the dataplane against the state programmed by npm.
+func (npMgr *NetworkPolicyManager) ServeVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(w).Encode(npMgr.VerifyDataplane()); err != nil {
+		log.Printf("Error encoding dataplane verification: %v\n", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDebugState(t *testing.T) {
+	npMgr := &NetworkPolicyManager{
+		nsMap: make(map[string]*namespace),
+	}
+
+	allNs, err := newNs(util.KubeAllNamespacesFlag)
+	if err != nil {
+		panic(err.Error)
+	}
+	npMgr.nsMap[util.KubeAllNamespacesFlag] = allNs
+
+	allNs.npMap["allow-frontend"] = &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-ns",
+			Name:      "allow-frontend",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "frontend",
+				},
+			},
+		},
+	}
+
+	if err := allNs.ipsMgr.AddToSet("test-ns", "1.2.3.4"); err != nil {
+		t.Fatalf("TestGetDebugState failed @ ipsMgr.AddToSet")
+	}
+
+	if err := allNs.ipsMgr.AddToSet("namedport:http", "1.2.3.4,tcp:80"); err != nil {
+		t.Fatalf("TestGetDebugState failed @ ipsMgr.AddToSet")
+	}
+
+	backend := newTestPod("test-ns", "backend", nil)
+	backend.Status.PodIP = "5.6.7.8"
+	pods := []*corev1.Pod{
+		newTestPod("test-ns", "frontend", map[string]string{"app": "frontend"}),
+		backend,
+	}
+
+	state := npMgr.getDebugState(pods)
+	if len(state.Pods) != 2 {
+		t.Fatalf("TestGetDebugState failed @ pods: %+v", state.Pods)
+	}
+
+	// Pods are sorted by namespace and name.
+	if state.Pods[0].Name != "backend" || len(state.Pods[0].Ipsets) != 0 {
+		t.Errorf("TestGetDebugState failed @ backend pod: %+v", state.Pods[0])
+	}
+
+	if !reflect.DeepEqual(state.Pods[1].Ipsets, []string{"namedport:http", "test-ns"}) {
+		t.Errorf("TestGetDebugState failed @ frontend pod: %+v", state.Pods[1])
+	}
+
+	if len(state.Policies) != 1 {
+		t.Fatalf("TestGetDebugState failed @ policies: %+v", state.Policies)
+	}
+
+	policy := state.Policies[0]
+	if policy.Namespace != "test-ns" || policy.Name != "allow-frontend" || len(policy.Chains) == 0 {
+		t.Errorf("TestGetDebugState failed @ policy: %+v", policy)
+	}
+}
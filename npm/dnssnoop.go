@@ -0,0 +1,228 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+const (
+	// Bytes of the logged DNS answers copied to npm, enough for the answers of most names over UDP with EDNS.
+	dnsSnoopCopyRange = 4096
+
+	// DNS record types and flags read from the answers.
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeAAAA  = 28
+	dnsFlagQR    = 1 << 15
+	dnsRcodeMask = 0xf
+
+	dnsHeaderSize = 12
+	udpHeaderSize = 8
+
+	// Max number of CNAME records followed from the name of a question.
+	maxDNSCnameChain = 8
+)
+
+// dnsAnswer holds the addresses a DNS answer resolved a name to, and the shortest TTL of their records.
+type dnsAnswer struct {
+	name      string
+	addresses []net.IP
+	ttl       time.Duration
+}
+
+// parseDNSPacket returns the DNS answer carried by an IPv4 or IPv6 UDP packet.
+func parseDNSPacket(payload []byte) (*dnsAnswer, error) {
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("Empty packet")
+	}
+
+	var udp []byte
+	switch payload[0] >> 4 {
+	case 4:
+		headerLen := int(payload[0]&0x0f) * 4
+		if len(payload) < 20 || headerLen < 20 || len(payload) < headerLen || payload[9] != 17 {
+			return nil, fmt.Errorf("Not an IPv4 UDP packet")
+		}
+		udp = payload[headerLen:]
+	case 6:
+		// Extension headers are not expected in DNS answers.
+		if len(payload) < 40 || payload[6] != 17 {
+			return nil, fmt.Errorf("Not an IPv6 UDP packet")
+		}
+		udp = payload[40:]
+	default:
+		return nil, fmt.Errorf("Unknown IP version %d", payload[0]>>4)
+	}
+
+	if len(udp) < udpHeaderSize {
+		return nil, fmt.Errorf("UDP header is truncated")
+	}
+
+	return parseDNSAnswer(udp[udpHeaderSize:])
+}
+
+// parseDNSAnswer returns the addresses of the A and AAAA records a DNS response answers its question with,
+// following the CNAME records of the answer section.
+func parseDNSAnswer(msg []byte) (*dnsAnswer, error) {
+	if len(msg) < dnsHeaderSize {
+		return nil, fmt.Errorf("DNS message is too short")
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&dnsFlagQR == 0 || flags&dnsRcodeMask != 0 {
+		return nil, fmt.Errorf("Not a successful DNS response")
+	}
+
+	if qdCount := binary.BigEndian.Uint16(msg[4:]); qdCount != 1 {
+		return nil, fmt.Errorf("Unexpected number of DNS questions %d", qdCount)
+	}
+	anCount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	qname, off, err := readDNSName(msg, dnsHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	off += 4
+
+	cnames := make(map[string]string)
+	addresses := make(map[string][]net.IP)
+	ttls := make(map[string]uint32)
+	for i := 0; i < anCount; i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+
+		if next+10 > len(msg) {
+			return nil, fmt.Errorf("DNS record is truncated")
+		}
+
+		rtype := binary.BigEndian.Uint16(msg[next:])
+		ttl := binary.BigEndian.Uint32(msg[next+4:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		if start+length > len(msg) {
+			return nil, fmt.Errorf("DNS record data is truncated")
+		}
+
+		switch {
+		case rtype == dnsTypeA && length == net.IPv4len, rtype == dnsTypeAAAA && length == net.IPv6len:
+			addresses[name] = append(addresses[name], net.IP(append([]byte(nil), msg[start:start+length]...)))
+			if current, exists := ttls[name]; !exists || ttl < current {
+				ttls[name] = ttl
+			}
+		case rtype == dnsTypeCNAME:
+			if cnames[name], _, err = readDNSName(msg, start); err != nil {
+				return nil, err
+			}
+		}
+
+		off = start + length
+	}
+
+	answer := &dnsAnswer{name: qname}
+	var minTTL uint32
+	name := qname
+	for i := 0; i <= maxDNSCnameChain && name != ""; i++ {
+		if ips, exists := addresses[name]; exists {
+			if len(answer.addresses) == 0 || ttls[name] < minTTL {
+				minTTL = ttls[name]
+			}
+			answer.addresses = append(answer.addresses, ips...)
+		}
+		name = cnames[name]
+	}
+	answer.ttl = time.Duration(minTTL) * time.Second
+
+	return answer, nil
+}
+
+// readDNSName reads the possibly compressed name at the given offset of a message. It returns the name in lower case,
+// without the trailing dot, and the offset following the name.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next, length := -1, 0
+
+	// Each pointer must point backwards, so that reading ends.
+	for {
+		if off >= len(msg) {
+			return "", 0, fmt.Errorf("DNS name is truncated")
+		}
+
+		labelLen := int(msg[off])
+		switch {
+		case labelLen == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")), next, nil
+		case labelLen&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, fmt.Errorf("DNS name is truncated")
+			}
+
+			target := int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			if target >= off {
+				return "", 0, fmt.Errorf("Invalid DNS name compression")
+			}
+
+			if next < 0 {
+				next = off + 2
+			}
+			off = target
+		case labelLen&0xc0 != 0:
+			return "", 0, fmt.Errorf("Invalid DNS label type")
+		default:
+			length += 1 + labelLen
+			if off+1+labelLen > len(msg) || length > 255 {
+				return "", 0, fmt.Errorf("Invalid DNS name length")
+			}
+
+			labels = append(labels, string(msg[off+1:off+1+labelLen]))
+			off += 1 + labelLen
+		}
+	}
+}
+
+// recordDNSPacket learns the addresses of a name of an FQDN egress rule from a DNS answer logged on its way to a pod.
+// The addresses stay allowed for the TTL of their records, or ttl if longer.
+func (npMgr *NetworkPolicyManager) recordDNSPacket(payload []byte, ttl time.Duration) {
+	answer, err := parseDNSPacket(payload)
+	if err != nil || len(answer.addresses) == 0 {
+		return
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	// Only the names of FQDN egress rules are tracked.
+	if _, exists := npMgr.fqdnCache[answer.name]; !exists {
+		return
+	}
+
+	if answer.ttl > ttl {
+		ttl = answer.ttl
+	}
+
+	added, err := npMgr.addFqdnAddresses(answer.name, getFqdnAddresses(answer.addresses), npMgr.clock.Now().Add(ttl))
+	if err != nil {
+		log.Printf("Error adding the addresses of %s from a DNS answer: %v\n", answer.name, err)
+		return
+	}
+
+	if len(added) == 0 {
+		return
+	}
+
+	if err = npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr.Apply(); err != nil {
+		log.Printf("Error applying the addresses of %s from a DNS answer: %v\n", answer.name, err)
+	}
+}
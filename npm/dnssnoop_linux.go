@@ -0,0 +1,39 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// StartDNSSnooper subscribes to the DNS answers logged on their way to the pods, and adds the addresses they resolve
+// the names of FQDN egress rules to, until stopCh is closed. The addresses stay allowed for at least ttl.
+func (npMgr *NetworkPolicyManager) StartDNSSnooper(ttl time.Duration, stopCh <-chan struct{}) error {
+	sub, err := netlink.SubscribeNflog(uint16(util.NpmDNSNflogGroup), dnsSnoopCopyRange)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer sub.Close()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case packet, ok := <-sub.Packets():
+				if !ok {
+					log.Printf("DNS snooping subscription ended, the names of FQDN egress rules are only resolved by npm\n")
+					return
+				}
+				npMgr.recordDNSPacket(packet.Payload, ttl)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/ipsm"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// getTestDNSAnswer returns a response resolving WWW.Bing.com through a CNAME to an IPv4 and an IPv6 address.
+func getTestDNSAnswer(flags uint16) []byte {
+	record := func(msg []byte, namePtr int, rtype uint16, ttl uint32, rdata []byte) []byte {
+		b := make([]byte, 12)
+		binary.BigEndian.PutUint16(b[0:], 0xc000|uint16(namePtr))
+		binary.BigEndian.PutUint16(b[2:], rtype)
+		binary.BigEndian.PutUint16(b[4:], 1)
+		binary.BigEndian.PutUint32(b[6:], ttl)
+		binary.BigEndian.PutUint16(b[10:], uint16(len(rdata)))
+		return append(append(msg, b...), rdata...)
+	}
+
+	msg := []byte{0x12, 0x34, byte(flags >> 8), byte(flags), 0, 1, 0, 3, 0, 0, 0, 0}
+	msg = append(msg, "\x03WWW\x04Bing\x03com\x00\x00\x01\x00\x01"...)
+	msg = record(msg, dnsHeaderSize, dnsTypeCNAME, 300, []byte("\x04edge\x06akamai\x03net\x00"))
+	msg = record(msg, 42, dnsTypeA, 60, net.ParseIP("10.0.0.1").To4())
+	msg = record(msg, 42, dnsTypeAAAA, 30, net.ParseIP("fd00::1"))
+
+	return msg
+}
+
+func TestParseDNSAnswer(t *testing.T) {
+	answer, err := parseDNSAnswer(getTestDNSAnswer(0x8180))
+	if err != nil {
+		t.Fatalf("TestParseDNSAnswer failed @ parseDNSAnswer: %v", err)
+	}
+
+	expected := &dnsAnswer{
+		name:      "www.bing.com",
+		addresses: []net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("fd00::1")},
+		ttl:       30 * time.Second,
+	}
+	if !reflect.DeepEqual(answer, expected) {
+		t.Errorf("TestParseDNSAnswer failed, unexpected answer %+v", answer)
+	}
+
+	// Queries and failed responses are ignored.
+	for _, flags := range []uint16{0x0100, 0x8183} {
+		if _, err := parseDNSAnswer(getTestDNSAnswer(flags)); err == nil {
+			t.Errorf("TestParseDNSAnswer failed, expected error for flags %x", flags)
+		}
+	}
+
+	if _, err := parseDNSAnswer(getTestDNSAnswer(0x8180)[:50]); err == nil {
+		t.Errorf("TestParseDNSAnswer failed, expected error for truncated answer")
+	}
+}
+
+func TestParseDNSPacket(t *testing.T) {
+	ipHeader := make([]byte, 20)
+	ipHeader[0], ipHeader[9] = 0x45, 17
+	packet := append(append(ipHeader, make([]byte, udpHeaderSize)...), getTestDNSAnswer(0x8180)...)
+
+	answer, err := parseDNSPacket(packet)
+	if err != nil || answer.name != "www.bing.com" || len(answer.addresses) != 2 {
+		t.Errorf("TestParseDNSPacket failed, unexpected answer %+v, err %v", answer, err)
+	}
+
+	packet[9] = 6
+	if _, err := parseDNSPacket(packet); err == nil {
+		t.Errorf("TestParseDNSPacket failed, expected error for TCP packet")
+	}
+}
+
+func TestAddFqdnAddresses(t *testing.T) {
+	ips := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")}
+
+	// IPv6 addresses are skipped unless IPv6 is enabled.
+	if addresses := getFqdnAddresses(ips); !reflect.DeepEqual(addresses, []string{"10.0.0.1"}) {
+		t.Errorf("TestAddFqdnAddresses failed @ IPv4 addresses %v", addresses)
+	}
+
+	defer func() { util.IsIPv6Enabled = false }()
+	util.IsIPv6Enabled = true
+
+	ipsMgr := ipsm.NewIpsetManager()
+	npMgr := &NetworkPolicyManager{
+		nsMap:     map[string]*namespace{util.KubeAllNamespacesFlag: {ipsMgr: ipsMgr}},
+		fqdnCache: make(fqdnCache),
+		clock:     platform.NewFakeClock(time.Now()),
+	}
+
+	addresses := getFqdnAddresses(ips)
+	added, err := npMgr.addFqdnAddresses("www.bing.com", addresses, npMgr.clock.Now().Add(time.Minute))
+	if err != nil || !reflect.DeepEqual(added, []string{"10.0.0.1", "fd00::1"}) {
+		t.Fatalf("TestAddFqdnAddresses failed @ addFqdnAddresses: %v, %v", added, err)
+	}
+
+	// The IPv6 address goes to the IPv6 counterpart of the set, not to the IPv4 set.
+	set := getFqdnSetName("www.bing.com")
+	if !ipsMgr.Exists(set, "10.0.0.1", util.IpsetNetHashFlag) || ipsMgr.Exists(set, "fd00::1", util.IpsetNetHashFlag) {
+		t.Errorf("TestAddFqdnAddresses failed, IPv6 address added to the IPv4 set")
+	}
+}
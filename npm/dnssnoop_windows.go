@@ -0,0 +1,13 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"fmt"
+	"time"
+)
+
+// StartDNSSnooper returns an error as DNS answers can't be logged with HNS ACLs.
+func (npMgr *NetworkPolicyManager) StartDNSSnooper(ttl time.Duration, stopCh <-chan struct{}) error {
+	return fmt.Errorf("DNS snooping is not supported on Windows")
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"sort"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// nsEnforcement tracks whether npm enforces network policies in a namespace, and the pods and policies
+// of the namespace, so that they are programmed again when enforcement is turned back on.
+type nsEnforcement struct {
+	disabled bool
+	pods     map[types.UID]*corev1.Pod
+	policies map[string]*networkingv1.NetworkPolicy
+}
+
+// isEnforcementDisabled returns whether a namespace is exempted from network policy enforcement by its label.
+func isEnforcementDisabled(nsObj *corev1.Namespace) bool {
+	return nsObj.ObjectMeta.Labels[util.NpmEnforcementLabel] == util.NpmEnforcementDisabled
+}
+
+// getNsEnforcement returns the enforcement state of a namespace, creating it if needed.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) getNsEnforcement(nsName string) *nsEnforcement {
+	if npMgr.nsEnforcement == nil {
+		npMgr.nsEnforcement = make(map[string]*nsEnforcement)
+	}
+
+	enforcement, exists := npMgr.nsEnforcement[nsName]
+	if !exists {
+		enforcement = &nsEnforcement{
+			pods:     make(map[types.UID]*corev1.Pod),
+			policies: make(map[string]*networkingv1.NetworkPolicy),
+		}
+		npMgr.nsEnforcement[nsName] = enforcement
+	}
+
+	return enforcement
+}
+
+// setNsEnforcement turns network policy enforcement on or off in a namespace. Turning it off removes the rules
+// of the policies of the namespace and its pods from the ipsets, turning it back on programs them again.
+// The change is recorded first and every pod and policy is processed even if some fail, so that an error
+// leaves the remaining ones to the dataplane reconciliation rather than to a retry of the namespace event.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) setNsEnforcement(nsName string, enforced bool) error {
+	enforcement := npMgr.getNsEnforcement(nsName)
+	if enforcement.disabled != enforced {
+		return nil
+	}
+
+	enforcement.disabled = !enforced
+
+	var err error
+	record := func(opErr error) {
+		if opErr != nil {
+			err = opErr
+		}
+	}
+
+	pods := enforcement.getPods()
+	policies := enforcement.getPolicies()
+
+	if !enforced {
+		log.Printf("Disabling enforcement in namespace %s, removing %d pods and %d network policies\n", nsName, len(pods), len(policies))
+
+		// The rules are removed before the ipsets they refer to are emptied.
+		for _, npObj := range policies {
+			_, deleteErr := npMgr.deleteNetworkPolicy(npObj, false)
+			record(deleteErr)
+		}
+
+		for _, podObj := range pods {
+			record(npMgr.deletePod(podObj))
+		}
+
+		return err
+	}
+
+	log.Printf("Enabling enforcement in namespace %s, adding %d pods and %d network policies\n", nsName, len(pods), len(policies))
+
+	// The ipsets are filled before the rules referring to them are added.
+	for _, podObj := range pods {
+		record(npMgr.addPod(podObj))
+	}
+
+	for _, npObj := range policies {
+		record(npMgr.addNetworkPolicy(npObj))
+	}
+
+	return err
+}
+
+// getPods returns the pods of a namespace, ordered by name.
+func (enforcement *nsEnforcement) getPods() []*corev1.Pod {
+	var pods []*corev1.Pod
+	for _, podObj := range enforcement.pods {
+		pods = append(pods, podObj)
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].ObjectMeta.Name < pods[j].ObjectMeta.Name
+	})
+
+	return pods
+}
+
+// getPolicies returns the network policies of a namespace, ordered by name.
+func (enforcement *nsEnforcement) getPolicies() []*networkingv1.NetworkPolicy {
+	var policies []*networkingv1.NetworkPolicy
+	for _, npObj := range enforcement.policies {
+		policies = append(policies, npObj)
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].ObjectMeta.Name < policies[j].ObjectMeta.Name
+	})
+
+	return policies
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/telemetry"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsEnforcementDisabled(t *testing.T) {
+	nsObj := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	if isEnforcementDisabled(nsObj) {
+		t.Errorf("TestIsEnforcementDisabled failed @ unlabeled namespace")
+	}
+
+	nsObj.ObjectMeta.Labels = map[string]string{util.NpmEnforcementLabel: "enabled"}
+	if isEnforcementDisabled(nsObj) {
+		t.Errorf("TestIsEnforcementDisabled failed @ enabled namespace")
+	}
+
+	nsObj.ObjectMeta.Labels[util.NpmEnforcementLabel] = util.NpmEnforcementDisabled
+	if !isEnforcementDisabled(nsObj) {
+		t.Errorf("TestIsEnforcementDisabled failed @ disabled namespace")
+	}
+}
+
+// Tests that the pods and policies of an exempted namespace are tracked without being programmed.
+func TestExemptedNamespaceTracking(t *testing.T) {
+	npMgr := &NetworkPolicyManager{
+		nsMap: make(map[string]*namespace),
+		reportManager: &telemetry.ReportManager{
+			HostNetAgentURL: hostNetAgentURLForNpm,
+			ContentType:     contentType,
+			Report:          &telemetry.NPMReport{},
+		},
+	}
+
+	allNs, err := newNs(util.KubeAllNamespacesFlag)
+	if err != nil {
+		panic(err.Error)
+	}
+	npMgr.nsMap[util.KubeAllNamespacesFlag] = allNs
+
+	// Enforcement is already off, so turning it off again programs nothing.
+	npMgr.getNsEnforcement("test").disabled = true
+	if err = npMgr.setNsEnforcement("test", false); err != nil {
+		t.Fatalf("TestExemptedNamespaceTracking failed @ setNsEnforcement")
+	}
+
+	podObj := newAclTestPod("test", "frontend", "10.0.0.5", map[string]string{"app": "frontend"})
+	podObj.ObjectMeta.UID = "frontend-uid"
+	if err = npMgr.AddPod(podObj); err != nil {
+		t.Errorf("TestExemptedNamespaceTracking failed @ AddPod")
+	}
+
+	npObj := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "deny-all"}}
+	if err = npMgr.AddNetworkPolicy(npObj); err != nil {
+		t.Errorf("TestExemptedNamespaceTracking failed @ AddNetworkPolicy")
+	}
+
+	newNpObj := npObj.DeepCopy()
+	newNpObj.ObjectMeta.Name = "deny-ingress"
+	if err = npMgr.UpdateNetworkPolicy(npObj, newNpObj); err != nil {
+		t.Errorf("TestExemptedNamespaceTracking failed @ UpdateNetworkPolicy")
+	}
+
+	enforcement := npMgr.getNsEnforcement("test")
+	if len(enforcement.pods) != 1 || enforcement.pods["frontend-uid"] != podObj {
+		t.Errorf("TestExemptedNamespaceTracking failed @ tracked pods %+v", enforcement.pods)
+	}
+
+	if len(enforcement.policies) != 1 || enforcement.policies["deny-ingress"] != newNpObj {
+		t.Errorf("TestExemptedNamespaceTracking failed @ tracked policies %+v", enforcement.policies)
+	}
+
+	if len(allNs.npMap) != 0 || npMgr.clusterState.PodCount != 0 || npMgr.clusterState.NwPolicyCount != 0 {
+		t.Errorf("TestExemptedNamespaceTracking failed @ exempted objects were programmed")
+	}
+
+	stats := npMgr.getNamespaceStats(nil)
+	if len(stats) != 1 || !stats[0].EnforcementDisabled {
+		t.Errorf("TestExemptedNamespaceTracking failed @ namespace stats %+v", stats)
+	}
+
+	if err = npMgr.DeletePod(podObj); err != nil {
+		t.Errorf("TestExemptedNamespaceTracking failed @ DeletePod")
+	}
+
+	if err = npMgr.DeleteNetworkPolicy(newNpObj); err != nil {
+		t.Errorf("TestExemptedNamespaceTracking failed @ DeleteNetworkPolicy")
+	}
+
+	if len(enforcement.pods) != 0 || len(enforcement.policies) != 0 {
+		t.Errorf("TestExemptedNamespaceTracking failed @ deleted objects are still tracked")
+	}
+}
+
+// Tests that exempted namespaces get no ACLs and their pods are not selected as peers.
+func TestAclInventoryExemptedNamespace(t *testing.T) {
+	target := newAclTestPod("test", "target", "10.0.0.4", map[string]string{"app": "db"})
+	frontend := newAclTestPod("test", "frontend", "10.0.0.5", map[string]string{"app": "frontend"})
+	exempted := newAclTestPod("legacy", "frontend", "10.0.0.6", map[string]string{"app": "frontend"})
+
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "test"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Labels: map[string]string{util.NpmEnforcementLabel: util.NpmEnforcementDisabled}}},
+	}
+
+	allowFrontend := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "allow-frontend"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							NamespaceSelector: &metav1.LabelSelector{},
+							PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	denyAll := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "legacy", Name: "deny-all"},
+	}
+
+	inv := newAclInventory([]*corev1.Pod{target, frontend, exempted}, namespaces, []*networkingv1.NetworkPolicy{allowFrontend, denyAll})
+
+	if len(inv.policies) != 1 || inv.policies[0] != allowFrontend {
+		t.Errorf("TestAclInventoryExemptedNamespace failed @ policies %+v", inv.policies)
+	}
+
+	if acls := inv.getEndpointACLs(exempted); acls != nil {
+		t.Errorf("TestAclInventoryExemptedNamespace failed @ exempted pod has ACLs %+v", acls)
+	}
+
+	for _, acl := range inv.getEndpointACLs(target) {
+		if strings.Contains(acl.RemoteAddresses, exempted.Status.PodIP) {
+			t.Errorf("TestAclInventoryExemptedNamespace failed @ exempted pod is a peer %+v", acl)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/iptm"
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	// Timeout of resolving a single name.
+	fqdnResolveTimeout = 5 * time.Second
+)
+
+// fqdnCache holds the addresses a name resolved to, with the time each address expires at.
+type fqdnCache map[string]map[string]time.Time
+
+// parseFqdns parses the value of the FQDN egress annotation.
+// The value is a comma separated list of fully qualified domain names, e.g. "login.microsoftonline.com,bing.com".
+func parseFqdns(value string) ([]string, error) {
+	var fqdns []string
+
+	for _, item := range strings.Split(value, ",") {
+		fqdn := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(item)), ".")
+		if fqdn == "" {
+			continue
+		}
+
+		if !isValidFqdn(fqdn) {
+			return nil, fmt.Errorf("Invalid FQDN %s", item)
+		}
+
+		fqdns = append(fqdns, fqdn)
+	}
+
+	return util.UniqueStrSlice(fqdns), nil
+}
+
+// isValidFqdn checks whether s is a valid lower case domain name. Wildcards are not supported.
+func isValidFqdn(s string) bool {
+	if len(s) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(s, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// getFqdns returns the names a network policy allows egress traffic to.
+func getFqdns(npObj *networkingv1.NetworkPolicy) []string {
+	value, ok := npObj.ObjectMeta.Annotations[util.AllowFqdnEgressAnnotation]
+	if !ok {
+		return nil
+	}
+
+	fqdns, err := parseFqdns(value)
+	if err != nil {
+		log.Printf("Ignoring annotation %s in namespace %s: %v\n", util.AllowFqdnEgressAnnotation, npObj.ObjectMeta.Namespace, err)
+		return nil
+	}
+
+	return fqdns
+}
+
+// getFqdnSetName returns the name of the ipset of the addresses a name resolves to.
+func getFqdnSetName(fqdn string) string {
+	return util.FqdnIPSetPrefix + fqdn
+}
+
+// getFqdnEntries returns iptables entries allowing egress traffic to the addresses of the names listed in the policy annotation.
+func getFqdnEntries(npObj *networkingv1.NetworkPolicy, targetSets []string) []*iptm.IptEntry {
+	var entries []*iptm.IptEntry
+
+	if len(targetSets) == 0 {
+		targetSets = []string{npObj.ObjectMeta.Namespace}
+	}
+
+	for _, fqdn := range getFqdns(npObj) {
+		fqdnSet := getFqdnSetName(fqdn)
+		hashedFqdnSetName := util.GetHashedName(fqdnSet)
+
+		for _, targetSet := range targetSets {
+			hashedTargetSetName := util.GetHashedName(targetSet)
+			entry := &iptm.IptEntry{
+				Name:       fqdnSet,
+				HashedName: hashedFqdnSetName,
+				Chain:      util.IptablesAzureEgressPortChain,
+				Specs: []string{
+					util.IptablesMatchFlag,
+					util.IptablesSetFlag,
+					util.IptablesMatchSetFlag,
+					hashedTargetSetName,
+					util.IptablesSrcFlag,
+					util.IptablesMatchFlag,
+					util.IptablesSetFlag,
+					util.IptablesMatchSetFlag,
+					hashedFqdnSetName,
+					util.IptablesDstFlag,
+					util.IptablesJumpFlag,
+					util.IptablesAccept,
+				},
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// update records the addresses a name resolved to, which expire at the given time.
+// It returns the addresses that were not known before.
+func (cache fqdnCache) update(fqdn string, addresses []string, expiry time.Time) []string {
+	var added []string
+
+	if cache[fqdn] == nil {
+		cache[fqdn] = make(map[string]time.Time)
+	}
+
+	for _, address := range addresses {
+		if _, exists := cache[fqdn][address]; !exists {
+			added = append(added, address)
+		}
+
+		cache[fqdn][address] = expiry
+	}
+
+	return added
+}
+
+// expire removes the addresses that expired at the given time.
+// It returns the removed addresses, by name.
+func (cache fqdnCache) expire(now time.Time) map[string][]string {
+	expired := make(map[string][]string)
+
+	for fqdn, addresses := range cache {
+		for address, expiry := range addresses {
+			if now.Before(expiry) {
+				continue
+			}
+
+			delete(addresses, address)
+			expired[fqdn] = append(expired[fqdn], address)
+		}
+
+		sort.Strings(expired[fqdn])
+	}
+
+	return expired
+}
+
+// addresses returns the known addresses of a name.
+func (cache fqdnCache) addresses(fqdn string) []string {
+	var addresses []string
+
+	for address := range cache[fqdn] {
+		addresses = append(addresses, address)
+	}
+
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// addFqdnSets creates the ipsets of the names of a network policy, filled with the addresses already known.
+// Names that were never resolved are resolved by the next refresh, which is triggered right away.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) addFqdnSets(npObj *networkingv1.NetworkPolicy) error {
+	ipsMgr := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr
+
+	unresolved := false
+	for _, fqdn := range getFqdns(npObj) {
+		set := getFqdnSetName(fqdn)
+		if err := ipsMgr.CreateSet(set); err != nil {
+			return err
+		}
+
+		// The cache tracks the names in use, the snooped DNS answers of other names are ignored.
+		if _, exists := npMgr.fqdnCache[fqdn]; !exists {
+			npMgr.fqdnCache[fqdn] = make(map[string]time.Time)
+			unresolved = true
+			continue
+		}
+
+		for _, address := range npMgr.fqdnCache.addresses(fqdn) {
+			if err := ipsMgr.AddToSet(set, address); err != nil {
+				return err
+			}
+		}
+	}
+
+	if unresolved {
+		select {
+		case npMgr.fqdnRefreshCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// deleteFqdnSets destroys the ipsets of the names of a deleted network policy that no other policy uses.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deleteFqdnSets(npObj *networkingv1.NetworkPolicy) error {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+
+	inUse := make(map[string]bool)
+	for _, otherNpObj := range allNs.npMap {
+		for _, fqdn := range getFqdns(otherNpObj) {
+			inUse[fqdn] = true
+		}
+	}
+
+	ipsMgr := allNs.ipsMgr
+	for _, fqdn := range getFqdns(npObj) {
+		if inUse[fqdn] {
+			continue
+		}
+
+		set := getFqdnSetName(fqdn)
+		for _, address := range npMgr.fqdnCache.addresses(fqdn) {
+			if err := ipsMgr.DeleteFromSet(set, address); err != nil {
+				return err
+			}
+		}
+
+		if err := ipsMgr.DeleteSet(set); err != nil {
+			return err
+		}
+
+		delete(npMgr.fqdnCache, fqdn)
+	}
+
+	return ipsMgr.Apply()
+}
+
+// RunFqdnResolver resolves the names of the FQDN egress rules every interval, and whenever a policy adds a new name,
+// until stopCh is closed. Resolved addresses stay allowed for ttl after they were last resolved,
+// so that connections to addresses rotated out of the DNS answers are not cut off.
+func (npMgr *NetworkPolicyManager) RunFqdnResolver(interval time.Duration, ttl time.Duration, stopCh <-chan struct{}) {
+	ticker := npMgr.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C():
+		case <-npMgr.fqdnRefreshCh:
+		}
+
+		if err := npMgr.refreshFqdnSets(ttl); err != nil {
+			log.Printf("Error refreshing FQDN ipsets: %v\n", err)
+		}
+	}
+}
+
+// refreshFqdnSets resolves the names of the FQDN egress rules and updates their ipsets.
+func (npMgr *NetworkPolicyManager) refreshFqdnSets(ttl time.Duration) error {
+	// The names are resolved without holding the lock, as resolving may be slow.
+	npMgr.Lock()
+	var fqdns []string
+	for _, npObj := range npMgr.nsMap[util.KubeAllNamespacesFlag].npMap {
+		fqdns = append(fqdns, getFqdns(npObj)...)
+	}
+	npMgr.Unlock()
+
+	resolved := make(map[string][]string)
+	for _, fqdn := range util.UniqueStrSlice(fqdns) {
+		addresses, err := resolveFqdn(fqdn)
+		if err != nil {
+			// The addresses of the name stay allowed until they expire.
+			log.Printf("Error resolving %s: %v\n", fqdn, err)
+			continue
+		}
+
+		resolved[fqdn] = addresses
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+
+	// Policies deleted while resolving have already destroyed the sets of their names.
+	inUse := make(map[string]bool)
+	for _, npObj := range allNs.npMap {
+		for _, fqdn := range getFqdns(npObj) {
+			inUse[fqdn] = true
+		}
+	}
+
+	ipsMgr := allNs.ipsMgr
+	now := npMgr.clock.Now()
+	for fqdn, addresses := range resolved {
+		if !inUse[fqdn] {
+			continue
+		}
+
+		if _, err := npMgr.addFqdnAddresses(fqdn, addresses, now.Add(ttl)); err != nil {
+			return err
+		}
+	}
+
+	for fqdn, addresses := range npMgr.fqdnCache.expire(now) {
+		for _, address := range addresses {
+			if err := ipsMgr.DeleteFromSet(getFqdnSetName(fqdn), address); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ipsMgr.Apply()
+}
+
+// addFqdnAddresses adds the addresses a name resolved to, which expire at the given time, to the ipset of the name.
+// It returns the addresses that were not known before.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) addFqdnAddresses(fqdn string, addresses []string, expiry time.Time) ([]string, error) {
+	ipsMgr := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr
+
+	added := npMgr.fqdnCache.update(fqdn, addresses, expiry)
+	for _, address := range added {
+		// IPv6 addresses go to the IPv6 counterpart of the ipset.
+		if err := ipsMgr.AddToSet(getFqdnSetName(fqdn), address); err != nil {
+			return nil, err
+		}
+	}
+
+	return added, nil
+}
+
+// getFqdnAddresses returns the addresses of a name that its ipsets can hold. IPv6 addresses are skipped unless IPv6
+// is enabled, as the IPv4 ipsets can't hold them.
+func getFqdnAddresses(ips []net.IP) []string {
+	var addresses []string
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			addresses = append(addresses, ip4.String())
+		} else if util.IsIPv6Enabled {
+			addresses = append(addresses, ip.String())
+		}
+	}
+
+	return addresses
+}
+
+// resolveFqdn returns the addresses a name resolves to, see getFqdnAddresses.
+func resolveFqdn(fqdn string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fqdnResolveTimeout)
+	defer cancel()
+
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, fqdn+".")
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, ipAddr := range ipAddrs {
+		ips = append(ips, ipAddr.IP)
+	}
+
+	return getFqdnAddresses(ips), nil
+}
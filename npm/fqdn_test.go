@@ -0,0 +1,89 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseFqdns(t *testing.T) {
+	fqdns, err := parseFqdns("Bing.com., login.microsoftonline.com,,bing.com")
+	if err != nil {
+		t.Fatalf("TestParseFqdns failed @ parseFqdns: %v", err)
+	}
+
+	if !reflect.DeepEqual(fqdns, []string{"bing.com", "login.microsoftonline.com"}) {
+		t.Errorf("TestParseFqdns failed, unexpected names %v", fqdns)
+	}
+
+	invalid := []string{"*.bing.com", "bing..com", "-bing.com", "bing_com", "http://bing.com"}
+	for _, value := range invalid {
+		if _, err := parseFqdns(value); err == nil {
+			t.Errorf("TestParseFqdns failed, expected error for %s", value)
+		}
+	}
+}
+
+func TestGetFqdnEntries(t *testing.T) {
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "test",
+			Annotations: map[string]string{util.AllowFqdnEgressAnnotation: "bing.com,microsoft.com"},
+		},
+	}
+
+	entries := getFqdnEntries(npObj, []string{"app:frontend"})
+	if len(entries) != 2 {
+		t.Fatalf("TestGetFqdnEntries failed, expected 2 entries, got %d", len(entries))
+	}
+
+	for _, entry := range entries {
+		if entry.Chain != util.IptablesAzureEgressPortChain {
+			t.Errorf("TestGetFqdnEntries failed, unexpected chain %s", entry.Chain)
+		}
+
+		if entry.Specs[3] != util.GetHashedName("app:frontend") || entry.Specs[8] != entry.HashedName {
+			t.Errorf("TestGetFqdnEntries failed, unexpected specs %+v", entry.Specs)
+		}
+	}
+
+	npObj.ObjectMeta.Annotations[util.AllowFqdnEgressAnnotation] = "*.bing.com"
+	if entries = getFqdnEntries(npObj, []string{"app:frontend"}); len(entries) != 0 {
+		t.Errorf("TestGetFqdnEntries failed, expected invalid annotation to be ignored, got %d entries", len(entries))
+	}
+}
+
+func TestFqdnCache(t *testing.T) {
+	cache := make(fqdnCache)
+	now := time.Now()
+
+	added := cache.update("bing.com", []string{"10.0.0.1", "10.0.0.2"}, now.Add(time.Minute))
+	if len(added) != 2 {
+		t.Fatalf("TestFqdnCache failed, expected 2 added addresses, got %v", added)
+	}
+
+	// The name rotated to a new address, the old one stays until it expires.
+	added = cache.update("bing.com", []string{"10.0.0.2", "10.0.0.3"}, now.Add(2*time.Minute))
+	if !reflect.DeepEqual(added, []string{"10.0.0.3"}) {
+		t.Fatalf("TestFqdnCache failed, unexpected added addresses %v", added)
+	}
+
+	if expired := cache.expire(now.Add(30 * time.Second)); len(expired) != 0 {
+		t.Errorf("TestFqdnCache failed, unexpected expired addresses %v", expired)
+	}
+
+	expired := cache.expire(now.Add(time.Minute))
+	if !reflect.DeepEqual(expired["bing.com"], []string{"10.0.0.1"}) {
+		t.Errorf("TestFqdnCache failed, unexpected expired addresses %v", expired)
+	}
+
+	if addresses := cache.addresses("bing.com"); !reflect.DeepEqual(addresses, []string{"10.0.0.2", "10.0.0.3"}) {
+		t.Errorf("TestFqdnCache failed, unexpected addresses %v", addresses)
+	}
+}
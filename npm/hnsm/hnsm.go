@@ -0,0 +1,165 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package hnsm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// ACL actions.
+const (
+	ActionAllow = "Allow"
+	ActionBlock = "Block"
+)
+
+// ACL directions, relative to the pod endpoint.
+const (
+	DirectionIn  = "In"
+	DirectionOut = "Out"
+)
+
+// ACL protocol numbers.
+const (
+	ProtocolTCP = 6
+	ProtocolUDP = 17
+	ProtocolAny = 256
+)
+
+// ACL is an HNS ACL policy applied to the endpoint of a pod.
+// Addresses and ports are comma separated lists, empty to match any.
+type ACL struct {
+	Action          string
+	Direction       string
+	Protocol        uint16
+	LocalPorts      string
+	RemoteAddresses string
+	RemotePorts     string
+	Priority        uint16
+}
+
+// Replaced by tests.
+var (
+	applyACLs = applyEndpointACLs
+	getACLs   = getEndpointACLs
+)
+
+// HnsManager stores the ACLs applied to the pod endpoints of the node.
+type HnsManager struct {
+	endpointMap map[string][]*ACL // pod ip -> applied ACLs.
+}
+
+// NewHnsManager creates a new instance for HnsManager object.
+func NewHnsManager() *HnsManager {
+	return &HnsManager{
+		endpointMap: make(map[string][]*ACL),
+	}
+}
+
+// Apply replaces the ACLs of the endpoint with the given pod ip.
+// The endpoint is left untouched when the same ACLs are already applied.
+func (hnsMgr *HnsManager) Apply(podIP string, acls []*ACL) error {
+	if applied, exists := hnsMgr.endpointMap[podIP]; exists && reflect.DeepEqual(applied, acls) {
+		return nil
+	}
+
+	log.Printf("Applying %d ACLs to endpoint %s\n", len(acls), podIP)
+	if err := applyACLs(podIP, acls); err != nil {
+		log.Printf("Error applying ACLs to endpoint %s: %v\n", podIP, err)
+		return err
+	}
+
+	hnsMgr.endpointMap[podIP] = acls
+
+	return nil
+}
+
+// Forget stops tracking the endpoint with the given pod ip, once its pod is gone.
+func (hnsMgr *HnsManager) Forget(podIP string) {
+	delete(hnsMgr.endpointMap, podIP)
+}
+
+// GetEndpoints returns the pod ips of the tracked endpoints.
+func (hnsMgr *HnsManager) GetEndpoints() []string {
+	var podIPs []string
+	for podIP := range hnsMgr.endpointMap {
+		podIPs = append(podIPs, podIP)
+	}
+
+	return podIPs
+}
+
+// Reconcile reapplies the ACLs of the tracked endpoints whose HNS ACLs drifted from the applied ones,
+// and returns the number of repaired endpoints.
+func (hnsMgr *HnsManager) Reconcile() (int, error) {
+	var (
+		repaired int
+		lastErr  error
+	)
+
+	for podIP, acls := range hnsMgr.endpointMap {
+		current, err := getACLs(podIP)
+		if err != nil {
+			log.Printf("Error getting the ACLs of endpoint %s: %v\n", podIP, err)
+			lastErr = err
+			continue
+		}
+
+		if aclsEqual(current, acls) {
+			continue
+		}
+
+		log.Printf("Reapplying %d drifted ACLs to endpoint %s\n", len(acls), podIP)
+		if err := applyACLs(podIP, acls); err != nil {
+			log.Printf("Error reapplying ACLs to endpoint %s: %v\n", podIP, err)
+			lastErr = err
+			continue
+		}
+
+		repaired++
+	}
+
+	return repaired, lastErr
+}
+
+// Verify returns the tracked endpoints whose HNS ACLs drifted from the applied ones.
+func (hnsMgr *HnsManager) Verify() ([]string, error) {
+	var drifts []string
+	for podIP, acls := range hnsMgr.endpointMap {
+		current, err := getACLs(podIP)
+		if err != nil {
+			return nil, err
+		}
+
+		if !aclsEqual(current, acls) {
+			drifts = append(drifts, fmt.Sprintf("endpoint %s: %d ACLs applied, %d expected", podIP, len(current), len(acls)))
+		}
+	}
+	sort.Strings(drifts)
+
+	return drifts, nil
+}
+
+// aclsEqual returns whether both lists hold the same ACLs, in any order.
+func aclsEqual(a, b []*ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := make(map[ACL]int)
+	for _, acl := range a {
+		count[*acl]++
+	}
+
+	for _, acl := range b {
+		if count[*acl] == 0 {
+			return false
+		}
+		count[*acl]--
+	}
+
+	return true
+}
@@ -0,0 +1,17 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package hnsm
+
+import (
+	"fmt"
+)
+
+// applyEndpointACLs is not supported, HNS is only available on Windows.
+func applyEndpointACLs(podIP string, acls []*ACL) error {
+	return fmt.Errorf("HNS ACLs are not supported on this platform")
+}
+
+// getEndpointACLs is not supported, HNS is only available on Windows.
+func getEndpointACLs(podIP string) ([]*ACL, error) {
+	return nil, fmt.Errorf("HNS ACLs are not supported on this platform")
+}
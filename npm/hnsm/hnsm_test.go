@@ -0,0 +1,58 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package hnsm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	allow := &ACL{Action: ActionAllow, Direction: DirectionIn, Protocol: ProtocolTCP, RemotePorts: "80", Priority: 300}
+	block := &ACL{Action: ActionBlock, Direction: DirectionIn, Protocol: ProtocolAny, Priority: 1000}
+
+	current := map[string][]*ACL{
+		"10.0.0.4": {block, allow},
+		"10.0.0.5": {block},
+	}
+	var applied []string
+
+	defer func(apply func(string, []*ACL) error, get func(string) ([]*ACL, error)) {
+		applyACLs = apply
+		getACLs = get
+	}(applyACLs, getACLs)
+
+	applyACLs = func(podIP string, acls []*ACL) error {
+		applied = append(applied, podIP)
+		current[podIP] = acls
+		return nil
+	}
+	getACLs = func(podIP string) ([]*ACL, error) {
+		return current[podIP], nil
+	}
+
+	hnsMgr := NewHnsManager()
+	hnsMgr.endpointMap["10.0.0.4"] = []*ACL{allow, block}
+	hnsMgr.endpointMap["10.0.0.5"] = []*ACL{allow, block}
+
+	drifts, err := hnsMgr.Verify()
+	if err != nil {
+		t.Fatalf("TestReconcile failed @ Verify: %v", err)
+	}
+	if len(drifts) != 1 {
+		t.Errorf("TestReconcile failed @ Verify drifts: %v", drifts)
+	}
+
+	repaired, err := hnsMgr.Reconcile()
+	if err != nil || repaired != 1 {
+		t.Errorf("TestReconcile failed @ Reconcile: %d, %v", repaired, err)
+	}
+	if !reflect.DeepEqual(applied, []string{"10.0.0.5"}) {
+		t.Errorf("TestReconcile failed @ reapplied endpoints: %v", applied)
+	}
+
+	repaired, err = hnsMgr.Reconcile()
+	if err != nil || repaired != 0 {
+		t.Errorf("TestReconcile failed @ second Reconcile: %d, %v", repaired, err)
+	}
+}
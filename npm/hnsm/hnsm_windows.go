@@ -0,0 +1,92 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package hnsm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Microsoft/hcsshim"
+)
+
+// applyEndpointACLs replaces the ACL policies of the HNS endpoint with the given ip.
+// The other policies of the endpoint, such as outbound NAT, are kept.
+func applyEndpointACLs(podIP string, acls []*ACL) error {
+	endpoint, err := getEndpoint(podIP)
+	if err != nil {
+		return err
+	}
+
+	var policies []json.RawMessage
+	for _, policy := range endpoint.Policies {
+		var p hcsshim.Policy
+		if err := json.Unmarshal(policy, &p); err == nil && p.Type == hcsshim.ACL {
+			continue
+		}
+
+		policies = append(policies, policy)
+	}
+	endpoint.Policies = policies
+
+	aclPolicies := make([]*hcsshim.ACLPolicy, 0, len(acls))
+	for _, acl := range acls {
+		aclPolicies = append(aclPolicies, &hcsshim.ACLPolicy{
+			Type:            hcsshim.ACL,
+			Action:          hcsshim.ActionType(acl.Action),
+			Direction:       hcsshim.DirectionType(acl.Direction),
+			Protocol:        acl.Protocol,
+			LocalPorts:      acl.LocalPorts,
+			RemoteAddresses: acl.RemoteAddresses,
+			RemotePorts:     acl.RemotePorts,
+			RuleType:        hcsshim.Switch,
+			Priority:        acl.Priority,
+		})
+	}
+
+	return endpoint.ApplyACLPolicy(aclPolicies...)
+}
+
+// getEndpointACLs returns the ACL policies of the HNS endpoint with the given ip.
+func getEndpointACLs(podIP string) ([]*ACL, error) {
+	endpoint, err := getEndpoint(podIP)
+	if err != nil {
+		return nil, err
+	}
+
+	var acls []*ACL
+	for _, policy := range endpoint.Policies {
+		var p hcsshim.ACLPolicy
+		if err := json.Unmarshal(policy, &p); err != nil || p.Type != hcsshim.ACL {
+			continue
+		}
+
+		acls = append(acls, &ACL{
+			Action:          string(p.Action),
+			Direction:       string(p.Direction),
+			Protocol:        p.Protocol,
+			LocalPorts:      p.LocalPorts,
+			RemoteAddresses: p.RemoteAddresses,
+			RemotePorts:     p.RemotePorts,
+			Priority:        p.Priority,
+		})
+	}
+
+	return acls, nil
+}
+
+// getEndpoint returns the local HNS endpoint with the given ip.
+func getEndpoint(podIP string) (*hcsshim.HNSEndpoint, error) {
+	endpoints, err := hcsshim.HNSListEndpointRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		if !endpoint.IsRemoteEndpoint && endpoint.IPAddress != nil && endpoint.IPAddress.String() == podIP {
+			return endpoint, nil
+		}
+	}
+
+	return nil, fmt.Errorf("HNS endpoint with ip %s not found", podIP)
+}
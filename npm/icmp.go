@@ -0,0 +1,146 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/iptm"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// icmpRule represents an ICMP type/code pair allowed by a network policy annotation.
+type icmpRule struct {
+	protocol string
+	icmpType string
+}
+
+// parseIcmpRules parses the value of an ICMP annotation.
+// The value is a comma separated list of <protocol>:<type>[/<code>] items, e.g.
+// "icmp:echo-request,icmp:3/4,icmpv6:packet-too-big".
+func parseIcmpRules(value string) ([]*icmpRule, error) {
+	var rules []*icmpRule
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		s := strings.SplitN(item, ":", 2)
+		if len(s) != 2 {
+			return nil, fmt.Errorf("Invalid ICMP rule %s", item)
+		}
+
+		protocol, icmpType := strings.ToLower(s[0]), strings.ToLower(s[1])
+		if protocol != util.IcmpProtocol && protocol != util.Icmpv6Protocol {
+			return nil, fmt.Errorf("Invalid ICMP protocol %s", protocol)
+		}
+
+		if !isValidIcmpType(icmpType) {
+			return nil, fmt.Errorf("Invalid ICMP type %s", icmpType)
+		}
+
+		rules = append(rules, &icmpRule{protocol: protocol, icmpType: icmpType})
+	}
+
+	return rules, nil
+}
+
+// isValidIcmpType checks whether s is a numeric type, a numeric type/code pair or a type name.
+func isValidIcmpType(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	if s[0] >= '0' && s[0] <= '9' {
+		for _, v := range strings.SplitN(s, "/", 2) {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 255 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && c != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getIcmpEntries returns iptables entries allowing the ICMP types listed in the policy annotations.
+func getIcmpEntries(ns string, targetSets []string, annotations map[string]string) []*iptm.IptEntry {
+	var entries []*iptm.IptEntry
+
+	if len(targetSets) == 0 {
+		targetSets = []string{ns}
+	}
+
+	directions := []struct {
+		annotation string
+		chain      string
+		flag       string
+	}{
+		{util.AllowIcmpIngressAnnotation, util.IptablesAzureIngressPortChain, util.IptablesDstFlag},
+		{util.AllowIcmpEgressAnnotation, util.IptablesAzureEgressPortChain, util.IptablesSrcFlag},
+	}
+
+	for _, d := range directions {
+		value, ok := annotations[d.annotation]
+		if !ok {
+			continue
+		}
+
+		rules, err := parseIcmpRules(value)
+		if err != nil {
+			log.Printf("Ignoring annotation %s in namespace %s: %v\n", d.annotation, ns, err)
+			continue
+		}
+
+		for _, rule := range rules {
+			protocol, typeFlag := util.IptablesIcmpProtocol, util.IptablesIcmpTypeFlag
+			if rule.protocol == util.Icmpv6Protocol {
+				// ICMPv6 rules only have something to match against when ip6tables is programmed.
+				if !util.IsIPv6Enabled {
+					log.Printf("Skipping ICMPv6 rule %s, IPv6 is not enabled.\n", rule.icmpType)
+					continue
+				}
+
+				protocol, typeFlag = util.Ip6tablesIcmpProtocol, util.Ip6tablesIcmpTypeFlag
+			}
+
+			for _, targetSet := range targetSets {
+				hashedTargetSetName := util.GetHashedName(targetSet)
+				entry := &iptm.IptEntry{
+					Name:       targetSet,
+					HashedName: hashedTargetSetName,
+					Chain:      d.chain,
+					Specs: []string{
+						util.IptablesProtFlag,
+						protocol,
+						typeFlag,
+						rule.icmpType,
+						util.IptablesMatchFlag,
+						util.IptablesSetFlag,
+						util.IptablesMatchSetFlag,
+						hashedTargetSetName,
+						d.flag,
+						util.IptablesJumpFlag,
+						util.IptablesAccept,
+					},
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	return entries
+}
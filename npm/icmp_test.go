@@ -0,0 +1,73 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+func TestParseIcmpRules(t *testing.T) {
+	rules, err := parseIcmpRules("icmp:echo-request, icmp:3/4,ICMPv6:128")
+	if err != nil {
+		t.Fatalf("TestParseIcmpRules failed @ parseIcmpRules: %v", err)
+	}
+
+	if len(rules) != 3 {
+		t.Fatalf("TestParseIcmpRules failed, expected 3 rules, got %d", len(rules))
+	}
+
+	if rules[1].protocol != util.IcmpProtocol || rules[1].icmpType != "3/4" {
+		t.Errorf("TestParseIcmpRules failed, unexpected rule %+v", rules[1])
+	}
+
+	if rules[2].protocol != util.Icmpv6Protocol || rules[2].icmpType != "128" {
+		t.Errorf("TestParseIcmpRules failed, unexpected rule %+v", rules[2])
+	}
+
+	invalid := []string{"echo-request", "tcp:80", "icmp:256", "icmp:3/x", "icmp:echo request"}
+	for _, value := range invalid {
+		if _, err := parseIcmpRules(value); err == nil {
+			t.Errorf("TestParseIcmpRules failed, expected error for %s", value)
+		}
+	}
+}
+
+func TestGetIcmpEntries(t *testing.T) {
+	annotations := map[string]string{
+		util.AllowIcmpIngressAnnotation: "icmp:echo-request,icmpv6:echo-request",
+		util.AllowIcmpEgressAnnotation:  "icmp:3/4",
+	}
+
+	entries := getIcmpEntries("test", []string{"app:frontend", "tier:web"}, annotations)
+	if len(entries) != 4 {
+		t.Fatalf("TestGetIcmpEntries failed, expected 4 entries, got %d", len(entries))
+	}
+
+	for _, entry := range entries {
+		if entry.Specs[1] != util.IptablesIcmpProtocol {
+			t.Errorf("TestGetIcmpEntries failed, unexpected specs %+v", entry.Specs)
+		}
+
+		if entry.Chain == util.IptablesAzureEgressPortChain && entry.Specs[3] != "3/4" {
+			t.Errorf("TestGetIcmpEntries failed, unexpected egress specs %+v", entry.Specs)
+		}
+	}
+
+	util.IsIPv6Enabled = true
+	entries = getIcmpEntries("test", []string{"app:frontend", "tier:web"}, annotations)
+	util.IsIPv6Enabled = false
+	if len(entries) != 6 || entries[2].Specs[1] != util.Ip6tablesIcmpProtocol || entries[2].Specs[2] != util.Ip6tablesIcmpTypeFlag {
+		t.Errorf("TestGetIcmpEntries failed, expected ICMPv6 entries with IPv6 enabled, got %+v", entries)
+	}
+
+	if entries := getIcmpEntries("test", nil, map[string]string{}); len(entries) != 0 {
+		t.Errorf("TestGetIcmpEntries failed, expected no entries without annotations")
+	}
+
+	entries = getIcmpEntries("test", nil, map[string]string{util.AllowIcmpIngressAnnotation: "icmp:0"})
+	if len(entries) != 1 || entries[0].Name != "test" {
+		t.Errorf("TestGetIcmpEntries failed, expected namespace target set, got %+v", entries)
+	}
+}
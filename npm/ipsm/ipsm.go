@@ -3,9 +3,13 @@
 package ipsm
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -17,17 +21,31 @@ type ipsEntry struct {
 	name          string
 	set           string
 	spec          string
+	commands      string // nft commands of the operation with the nftables backend, rendered when it is queued.
 }
 
 // IpsetManager stores ipset states.
 type IpsetManager struct {
 	listMap map[string]*Ipset //tracks all set lists.
 	setMap  map[string]*Ipset //label -> []ip
+	ipv6    bool
+	ip6sMgr *IpsetManager // Manages the IPv6 counterparts of the sets when IPv6 is enabled.
+	pending []*ipsEntry   // create, add and delete operations batched until Apply.
+
+	// Apply runs without the lock of the caller, pendingLock guards the batched operations and applyLock keeps
+	// the restores in the order of the operations.
+	pendingLock sync.Mutex
+	applyLock   sync.Mutex
+
+	// Sets found when npm started, named by an earlier version or not, shared with ip6sMgr.
+	// Legacy sets are renamed when their selector is created again, and the others destroyed by DestroyLegacySets.
+	startupSets map[string]bool
 }
 
 // Ipset represents one ipset entry.
 type Ipset struct {
 	name       string
+	kind       string
 	elements   []string
 	referCount int
 }
@@ -41,10 +59,20 @@ func NewIpset(setName string) *Ipset {
 
 // NewIpsetManager creates a new instance for IpsetManager object.
 func NewIpsetManager() *IpsetManager {
-	return &IpsetManager{
+	ipsMgr := &IpsetManager{
 		listMap: make(map[string]*Ipset),
 		setMap:  make(map[string]*Ipset),
 	}
+
+	if util.IsIPv6Enabled {
+		ipsMgr.ip6sMgr = &IpsetManager{
+			listMap: make(map[string]*Ipset),
+			setMap:  make(map[string]*Ipset),
+			ipv6:    true,
+		}
+	}
+
+	return ipsMgr
 }
 
 // Exists checks if an element exists in setMap/listMap.
@@ -67,16 +95,39 @@ func (ipsMgr *IpsetManager) Exists(key string, val string, kind string) bool {
 	return false
 }
 
+// GetSetMemberCount returns the number of elements tracked in an ipset, or zero if the set does not exist.
+func (ipsMgr *IpsetManager) GetSetMemberCount(setName string) int {
+	count := 0
+	if ipsMgr.ip6sMgr != nil {
+		count = ipsMgr.ip6sMgr.GetSetMemberCount(setName)
+	}
+
+	set, exists := ipsMgr.setMap[setName]
+	if !exists {
+		return count
+	}
+
+	return count + len(set.elements)
+}
+
 func isNsSet(setName string) bool {
 	return !strings.Contains(setName, "-") && !strings.Contains(setName, ":")
 }
 
 // CreateList creates an ipset list. npm maintains one setlist per namespace label.
 func (ipsMgr *IpsetManager) CreateList(listName string) error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.CreateList(listName); err != nil {
+			return err
+		}
+	}
+
 	if _, exists := ipsMgr.listMap[listName]; exists {
 		return nil
 	}
 
+	ipsMgr.migrateLegacySet(listName)
+
 	entry := &ipsEntry{
 		name:          listName,
 		operationFlag: util.IpsetCreationFlag,
@@ -84,18 +135,27 @@ func (ipsMgr *IpsetManager) CreateList(listName string) error {
 		spec:          util.IpsetSetListFlag,
 	}
 	log.Printf("Creating List: %+v\n", entry)
-	if _, err := ipsMgr.Run(entry); err != nil {
-		log.Printf("Error creating ipset list %s.\n", listName)
-		return err
-	}
+	ipsMgr.queue(entry)
 
 	ipsMgr.listMap[listName] = NewIpset(listName)
+	ipsMgr.listMap[listName].kind = util.IpsetSetListFlag
 
 	return nil
 }
 
 // DeleteList removes an ipset list.
 func (ipsMgr *IpsetManager) DeleteList(listName string) error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.DeleteList(listName); err != nil {
+			return err
+		}
+	}
+
+	// The list is destroyed right away, after the batched operations on it.
+	if err := ipsMgr.Apply(); err != nil {
+		return err
+	}
+
 	entry := &ipsEntry{
 		operationFlag: util.IpsetDestroyFlag,
 		set:           util.GetHashedName(listName),
@@ -114,12 +174,19 @@ func (ipsMgr *IpsetManager) DeleteList(listName string) error {
 	}
 
 	delete(ipsMgr.listMap, listName)
+	ipsMgr.releaseName(listName)
 
 	return nil
 }
 
 // AddToList inserts an ipset to an ipset list.
 func (ipsMgr *IpsetManager) AddToList(listName string, setName string) error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.AddToList(listName, setName); err != nil {
+			return err
+		}
+	}
+
 	if ipsMgr.Exists(listName, setName, util.IpsetSetListFlag) {
 		return nil
 	}
@@ -129,15 +196,12 @@ func (ipsMgr *IpsetManager) AddToList(listName string, setName string) error {
 	}
 
 	entry := &ipsEntry{
+		name:          listName,
 		operationFlag: util.IpsetAppendFlag,
 		set:           util.GetHashedName(listName),
 		spec:          util.GetHashedName(setName),
 	}
-
-	if _, err := ipsMgr.Run(entry); err != nil {
-		log.Printf("Error creating ipset rules. rule: %+v", entry)
-		return err
-	}
+	ipsMgr.queue(entry)
 
 	ipsMgr.listMap[listName].elements = append(ipsMgr.listMap[listName].elements, setName)
 
@@ -146,6 +210,12 @@ func (ipsMgr *IpsetManager) AddToList(listName string, setName string) error {
 
 // DeleteFromList removes an ipset to an ipset list.
 func (ipsMgr *IpsetManager) DeleteFromList(listName string, setName string) error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.DeleteFromList(listName, setName); err != nil {
+			return err
+		}
+	}
+
 	if _, exists := ipsMgr.listMap[listName]; !exists {
 		log.Printf("ipset list with name %s not found", listName)
 		return nil
@@ -159,16 +229,12 @@ func (ipsMgr *IpsetManager) DeleteFromList(listName string, setName string) erro
 
 	hashedListName, hashedSetName := util.GetHashedName(listName), util.GetHashedName(setName)
 	entry := &ipsEntry{
+		name:          listName,
 		operationFlag: util.IpsetDeletionFlag,
 		set:           hashedListName,
 		spec:          hashedSetName,
 	}
-	errCode, err := ipsMgr.Run(entry)
-	if errCode > 1 && err != nil {
-		log.Printf("Error deleting ipset entry.\n")
-		log.Printf("%+v\n", entry)
-		return err
-	}
+	ipsMgr.queue(entry)
 
 	if len(ipsMgr.listMap[listName].elements) == 0 {
 		if err := ipsMgr.DeleteList(listName); err != nil {
@@ -182,30 +248,47 @@ func (ipsMgr *IpsetManager) DeleteFromList(listName string, setName string) erro
 
 // CreateSet creates an ipset.
 func (ipsMgr *IpsetManager) CreateSet(setName string) error {
+	return ipsMgr.CreateSetWithType(setName, util.IpsetNetHashFlag)
+}
+
+// CreateSetWithType creates an ipset of the given type, e.g. nethash or hash:ip,port.
+func (ipsMgr *IpsetManager) CreateSetWithType(setName string, setType string) error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.CreateSetWithType(setName, setType); err != nil {
+			return err
+		}
+	}
+
 	if _, exists := ipsMgr.setMap[setName]; exists {
 		return nil
 	}
 
+	ipsMgr.migrateLegacySet(setName)
+
 	entry := &ipsEntry{
 		name:          setName,
 		operationFlag: util.IpsetCreationFlag,
 		// Use hashed string for set name to avoid string length limit of ipset.
 		set:  util.GetHashedName(setName),
-		spec: util.IpsetNetHashFlag,
+		spec: setType,
 	}
 	log.Printf("Creating Set: %+v\n", entry)
-	if _, err := ipsMgr.Run(entry); err != nil {
-		log.Printf("Error creating ipset.\n")
-		return err
-	}
+	ipsMgr.queue(entry)
 
 	ipsMgr.setMap[setName] = NewIpset(setName)
+	ipsMgr.setMap[setName].kind = setType
 
 	return nil
 }
 
 // DeleteSet removes a set from ipset.
 func (ipsMgr *IpsetManager) DeleteSet(setName string) error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.DeleteSet(setName); err != nil {
+			return err
+		}
+	}
+
 	if _, exists := ipsMgr.setMap[setName]; !exists {
 		log.Printf("ipset with name %s not found", setName)
 		return nil
@@ -215,6 +298,11 @@ func (ipsMgr *IpsetManager) DeleteSet(setName string) error {
 		return nil
 	}
 
+	// The set is destroyed right away, after the batched operations on it.
+	if err := ipsMgr.Apply(); err != nil {
+		return err
+	}
+
 	entry := &ipsEntry{
 		operationFlag: util.IpsetDestroyFlag,
 		set:           util.GetHashedName(setName),
@@ -231,12 +319,22 @@ func (ipsMgr *IpsetManager) DeleteSet(setName string) error {
 	}
 
 	delete(ipsMgr.setMap, setName)
+	ipsMgr.releaseName(setName)
 
 	return nil
 }
 
 // AddToSet inserts an ip to an entry in setMap, and creates/updates the corresponding ipset.
 func (ipsMgr *IpsetManager) AddToSet(setName string, ip string) error {
+	// IPv6 addresses go to the IPv6 counterpart of the set.
+	if ipsMgr.ip6sMgr != nil && util.IsIPv6(ip) {
+		if err := ipsMgr.CreateSet(setName); err != nil {
+			return err
+		}
+
+		return ipsMgr.ip6sMgr.AddToSet(setName, ip)
+	}
+
 	if ipsMgr.Exists(setName, ip, util.IpsetNetHashFlag) {
 		return nil
 	}
@@ -246,16 +344,12 @@ func (ipsMgr *IpsetManager) AddToSet(setName string, ip string) error {
 	}
 
 	entry := &ipsEntry{
+		name:          setName,
 		operationFlag: util.IpsetAppendFlag,
 		set:           util.GetHashedName(setName),
 		spec:          ip,
 	}
-
-	if _, err := ipsMgr.Run(entry); err != nil {
-		log.Printf("Error creating ipset rules.\n")
-		log.Printf("rule: %+v\n", entry)
-		return err
-	}
+	ipsMgr.queue(entry)
 
 	ipsMgr.setMap[setName].elements = append(ipsMgr.setMap[setName].elements, ip)
 
@@ -264,6 +358,10 @@ func (ipsMgr *IpsetManager) AddToSet(setName string, ip string) error {
 
 // DeleteFromSet removes an ip from an entry in setMap, and delete/update the corresponding ipset.
 func (ipsMgr *IpsetManager) DeleteFromSet(setName string, ip string) error {
+	if ipsMgr.ip6sMgr != nil && util.IsIPv6(ip) {
+		return ipsMgr.ip6sMgr.DeleteFromSet(setName, ip)
+	}
+
 	if _, exists := ipsMgr.setMap[setName]; !exists {
 		log.Printf("ipset with name %s not found", setName)
 		return nil
@@ -275,21 +373,26 @@ func (ipsMgr *IpsetManager) DeleteFromSet(setName string, ip string) error {
 		}
 	}
 
+	// Options of an element such as nomatch are not part of it.
 	entry := &ipsEntry{
+		name:          setName,
 		operationFlag: util.IpsetDeletionFlag,
 		set:           util.GetHashedName(setName),
-		spec:          ip,
-	}
-	if _, err := ipsMgr.Run(entry); err != nil {
-		log.Printf("Error deleting ipset entry.\n Entry: %+v", entry)
-		return err
+		spec:          strings.Fields(ip)[0],
 	}
+	ipsMgr.queue(entry)
 
 	return nil
 }
 
 // Clean removes all the empty sets & lists under the namespace.
 func (ipsMgr *IpsetManager) Clean() error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.Clean(); err != nil {
+			return err
+		}
+	}
+
 	for setName, set := range ipsMgr.setMap {
 		if len(set.elements) > 0 {
 			continue
@@ -315,8 +418,20 @@ func (ipsMgr *IpsetManager) Clean() error {
 	return nil
 }
 
-// Destroy completely cleans ipset.
+// Destroy completely cleans ipset, or the nftables sets with the nftables backend.
 func (ipsMgr *IpsetManager) Destroy() error {
+	if util.IsNftablesEnabled {
+		if err := destroyNftSets(); err != nil {
+			return err
+		}
+
+		ipsMgr.pendingLock.Lock()
+		ipsMgr.pending = nil
+		ipsMgr.pendingLock.Unlock()
+
+		return nil
+	}
+
 	entry := &ipsEntry{
 		operationFlag: util.IpsetFlushFlag,
 	}
@@ -331,18 +446,377 @@ func (ipsMgr *IpsetManager) Destroy() error {
 		return err
 	}
 
+	// The batched operations are dropped with the sets, once they are destroyed.
+	ipsMgr.pendingLock.Lock()
+	ipsMgr.pending = nil
+	ipsMgr.pendingLock.Unlock()
+
 	return nil
 }
 
+// toIPv6Entry returns the entry operating on the IPv6 counterparts of the sets it refers to.
+func toIPv6Entry(entry *ipsEntry) *ipsEntry {
+	v6Entry := *entry
+	if v6Entry.set != "" {
+		v6Entry.set = util.GetIPv6SetName(v6Entry.set)
+	}
+
+	switch {
+	case v6Entry.operationFlag == util.IpsetCreationFlag && v6Entry.spec != util.IpsetSetListFlag:
+		v6Entry.spec += " " + util.IpsetFamilyFlag + " " + util.IpsetInet6Family
+	case strings.HasPrefix(v6Entry.spec, util.AzureNpmPrefix):
+		// Members of lists are sets.
+		v6Entry.spec = util.GetIPv6SetName(v6Entry.spec)
+	}
+
+	return &v6Entry
+}
+
+// queue batches an operation until the next Apply.
+// With the nftables backend, the operation is translated to nft commands from the tracked state.
+func (ipsMgr *IpsetManager) queue(entry *ipsEntry) {
+	if util.IsNftablesEnabled && entry.commands == "" {
+		entry.commands = ipsMgr.getNftCommands(entry)
+	}
+
+	ipsMgr.pendingLock.Lock()
+	defer ipsMgr.pendingLock.Unlock()
+
+	ipsMgr.pending = append(ipsMgr.pending, entry)
+}
+
+// Apply programs the batched create, add and delete operations with a single ipset restore, or nft transaction.
+// Operations are applied in the order they were made. If the restore fails, the operations stay pending
+// and are applied again by the next Apply.
+// Sets may be changed while a restore runs, the operations made meanwhile are applied by the next Apply. An Apply
+// waiting for a restore in progress then applies the operations of all callers at once.
+func (ipsMgr *IpsetManager) Apply() error {
+	if ipsMgr.ip6sMgr != nil {
+		if err := ipsMgr.ip6sMgr.Apply(); err != nil {
+			return err
+		}
+	}
+
+	ipsMgr.applyLock.Lock()
+	defer ipsMgr.applyLock.Unlock()
+
+	ipsMgr.pendingLock.Lock()
+	pending := ipsMgr.pending
+	ipsMgr.pending = nil
+	ipsMgr.pendingLock.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := ipsMgr.restore(pending); err != nil {
+		ipsMgr.pendingLock.Lock()
+		ipsMgr.pending = append(pending, ipsMgr.pending...)
+		ipsMgr.pendingLock.Unlock()
+		return err
+	}
+
+	// Persist the names of the sets now in the dataplane, so that they are found again after a restart.
+	if err := util.SaveSetNames(); err != nil {
+		log.Printf("Error saving ipset names: %v\n", err)
+	}
+
+	return nil
+}
+
+// restore programs the given operations with a single ipset restore, or a single nft transaction with the
+// nftables backend.
+func (ipsMgr *IpsetManager) restore(pending []*ipsEntry) error {
+	if util.IsNftablesEnabled {
+		return ipsMgr.applyNft(pending)
+	}
+
+	var input bytes.Buffer
+	for _, entry := range pending {
+		if ipsMgr.ipv6 {
+			entry = toIPv6Entry(entry)
+		}
+
+		line := append([]string{entry.operationFlag, entry.set}, strings.Fields(entry.spec)...)
+		input.WriteString(strings.Join(line, " ") + "\n")
+	}
+
+	log.Printf("Applying %d ipset operations\n", len(pending))
+
+	cmd := exec.Command(util.Ipset, util.IpsetRestoreFlag, util.IpsetExistFlag)
+	cmd.Stdin = &input
+	if cmdOut, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Error running ipset restore: %v\n%s\nInput:\n%s", err, string(cmdOut), input.String())
+		return err
+	}
+
+	return nil
+}
+
+// parseIpsetSave returns the members of each set in the output of ipset save.
+func parseIpsetSave(out string) map[string]map[string]bool {
+	sets := make(map[string]map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "create":
+			if _, exists := sets[fields[1]]; !exists {
+				sets[fields[1]] = make(map[string]bool)
+			}
+		case "add":
+			if len(fields) < 3 {
+				continue
+			}
+			if _, exists := sets[fields[1]]; !exists {
+				sets[fields[1]] = make(map[string]bool)
+			}
+
+			member := fields[2]
+			for _, option := range fields[3:] {
+				if option == util.IpsetNomatchFlag {
+					member += " " + util.IpsetNomatchFlag
+				}
+			}
+			sets[fields[1]][member] = true
+		}
+	}
+
+	return sets
+}
+
+// normalizeMember returns a set member the way ipset save prints it, which drops host prefix lengths.
+func normalizeMember(member string) string {
+	fields := strings.Fields(member)
+	for _, suffix := range []string{"/32", "/128"} {
+		fields[0] = strings.TrimSuffix(fields[0], suffix)
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// findDrift returns the operations bringing the actual sets of the manager's family back to the tracked state.
+// Missing sets are created with their members, missing members are added and unknown members are removed.
+func (ipsMgr *IpsetManager) findDrift(actual map[string]map[string]bool) []*ipsEntry {
+	if util.IsNftablesEnabled {
+		return ipsMgr.findNftDrift(actual)
+	}
+
+	var entries []*ipsEntry
+
+	// The actual name of a set or list member, and the member as tracked.
+	actualName := func(hashedName string) string {
+		if ipsMgr.ipv6 {
+			return util.GetIPv6SetName(hashedName)
+		}
+		return hashedName
+	}
+	trackedMember := func(member string, isList bool) string {
+		if isList && ipsMgr.ipv6 {
+			return strings.TrimSuffix(member, util.IpsetIPv6Suffix)
+		}
+		return member
+	}
+
+	// Sets go first, so that they exist by the time they are added to lists.
+	for _, m := range []map[string]*Ipset{ipsMgr.setMap, ipsMgr.listMap} {
+		var names []string
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			set := m[name]
+			isList := set.kind == util.IpsetSetListFlag
+			hashedName := util.GetHashedName(name)
+
+			// Tracked members keyed by how ipset save prints them.
+			desired := make(map[string]string)
+			for _, elem := range set.elements {
+				if isList {
+					desired[actualName(util.GetHashedName(elem))] = util.GetHashedName(elem)
+					continue
+				}
+				desired[normalizeMember(elem)] = elem
+			}
+
+			members, exists := actual[actualName(hashedName)]
+			if !exists {
+				entries = append(entries, &ipsEntry{
+					name:          name,
+					operationFlag: util.IpsetCreationFlag,
+					set:           hashedName,
+					spec:          set.kind,
+				})
+			}
+
+			for member, elem := range desired {
+				if !members[member] {
+					entries = append(entries, &ipsEntry{
+						name:          name,
+						operationFlag: util.IpsetAppendFlag,
+						set:           hashedName,
+						spec:          elem,
+					})
+				}
+			}
+
+			// Members only differing by their options are updated by the add above.
+			desiredElements := make(map[string]bool)
+			for member := range desired {
+				desiredElements[strings.Fields(member)[0]] = true
+			}
+
+			for member := range members {
+				if _, exists := desired[member]; !exists && !desiredElements[strings.Fields(member)[0]] {
+					entries = append(entries, &ipsEntry{
+						name:          name,
+						operationFlag: util.IpsetDeletionFlag,
+						set:           hashedName,
+						spec:          strings.Fields(trackedMember(member, isList))[0],
+					})
+				}
+			}
+		}
+	}
+
+	return entries
+}
+
+// repairDrift queues the operations bringing the actual sets back to the tracked state, and returns how many sets
+// and members drifted.
+func (ipsMgr *IpsetManager) repairDrift(actual map[string]map[string]bool) int {
+	drift := 0
+	if ipsMgr.ip6sMgr != nil {
+		drift += ipsMgr.ip6sMgr.repairDrift(actual)
+	}
+
+	for _, entry := range ipsMgr.findDrift(actual) {
+		if entry.operationFlag == util.IpsetCreationFlag {
+			log.Printf("Recreating missing ipset %s\n", entry.name)
+		}
+
+		ipsMgr.queue(entry)
+		drift++
+	}
+
+	return drift
+}
+
+// describeDrift returns a description of each set and member that drifted from the tracked state.
+func (ipsMgr *IpsetManager) describeDrift(actual map[string]map[string]bool) []string {
+	var drift []string
+	if ipsMgr.ip6sMgr != nil {
+		drift = ipsMgr.ip6sMgr.describeDrift(actual)
+	}
+
+	for _, entry := range ipsMgr.findDrift(actual) {
+		if ipsMgr.ipv6 {
+			entry = toIPv6Entry(entry)
+		}
+
+		switch entry.operationFlag {
+		case util.IpsetCreationFlag:
+			drift = append(drift, fmt.Sprintf("ipset %s (%s) is missing", entry.set, entry.name))
+		case util.IpsetAppendFlag:
+			drift = append(drift, fmt.Sprintf("ipset %s (%s) is missing member %s", entry.set, entry.name, entry.spec))
+		case util.IpsetDeletionFlag:
+			drift = append(drift, fmt.Sprintf("ipset %s (%s) has unknown member %s", entry.set, entry.name, entry.spec))
+		}
+	}
+
+	return drift
+}
+
+// save returns the members of each actual set, ipsets or nftables sets with the nftables backend.
+func (ipsMgr *IpsetManager) save() (map[string]map[string]bool, error) {
+	if util.IsNftablesEnabled {
+		sets, _, err := listNftSets()
+		return sets, err
+	}
+
+	out, err := exec.Command(util.Ipset, util.IpsetSaveFlag).Output()
+	if err != nil {
+		log.Printf("Error running ipset save: %v\n", err)
+		return nil, err
+	}
+
+	return parseIpsetSave(string(out)), nil
+}
+
+// Verify compares the tracked sets with the actual ones without repairing them, and describes the drift.
+func (ipsMgr *IpsetManager) Verify() ([]string, error) {
+	actual, err := ipsMgr.save()
+	if err != nil {
+		return nil, err
+	}
+
+	return ipsMgr.describeDrift(actual), nil
+}
+
+// GetSetMembers returns the tracked members of each set, IPv6 sets included.
+func (ipsMgr *IpsetManager) GetSetMembers() map[string][]string {
+	members := make(map[string][]string)
+	if ipsMgr.ip6sMgr != nil {
+		members = ipsMgr.ip6sMgr.GetSetMembers()
+	}
+
+	for name, set := range ipsMgr.setMap {
+		members[name] = append(members[name], set.elements...)
+	}
+
+	return members
+}
+
+// Reconcile compares the tracked sets with the actual ones and repairs the drift, e.g. sets destroyed
+// by another tool. It returns how many sets and members drifted.
+func (ipsMgr *IpsetManager) Reconcile() (int, error) {
+	actual, err := ipsMgr.save()
+	if err != nil {
+		return 0, err
+	}
+
+	drift := ipsMgr.repairDrift(actual)
+	if drift == 0 {
+		return 0, nil
+	}
+
+	log.Printf("Repairing %d drifted ipsets and members\n", drift)
+	if err := ipsMgr.Apply(); err != nil {
+		return drift, err
+	}
+
+	return drift, nil
+}
+
 // Run execute an ipset command to update ipset.
+// With the nftables backend, sets are destroyed with nft, which fails with 1 like ipset when the set is referred to.
 func (ipsMgr *IpsetManager) Run(entry *ipsEntry) (int, error) {
+	if ipsMgr.ipv6 {
+		entry = toIPv6Entry(entry)
+	}
+
 	cmdName := util.Ipset
 	cmdArgs := []string{entry.operationFlag, util.IpsetExistFlag}
 	if len(entry.set) > 0 {
 		cmdArgs = append(cmdArgs, entry.set)
 	}
 	if len(entry.spec) > 0 {
-		cmdArgs = append(cmdArgs, entry.spec)
+		cmdArgs = append(cmdArgs, strings.Fields(entry.spec)...)
+	}
+
+	if util.IsNftablesEnabled {
+		if entry.operationFlag != util.IpsetDestroyFlag || len(entry.set) == 0 {
+			return 2, fmt.Errorf("Unsupported nftables set operation %s", entry.operationFlag)
+		}
+
+		cmdName = util.Nft
+		cmdArgs = append([]string{"delete", "set"}, strings.Fields(ipsMgr.getNftTable())...)
+		cmdArgs = append(cmdArgs, entry.set)
 	}
 
 	cmdOut, err := exec.Command(cmdName, cmdArgs...).Output()
@@ -360,8 +834,12 @@ func (ipsMgr *IpsetManager) Run(entry *ipsEntry) (int, error) {
 	return 0, nil
 }
 
-// Save saves ipset to file.
+// Save saves ipset to file. The nftables sets are saved with the azure-npm table by iptm.
 func (ipsMgr *IpsetManager) Save(configFile string) error {
+	if util.IsNftablesEnabled {
+		return nil
+	}
+
 	if len(configFile) == 0 {
 		configFile = util.IpsetConfigFile
 	}
@@ -376,8 +854,12 @@ func (ipsMgr *IpsetManager) Save(configFile string) error {
 	return nil
 }
 
-// Restore restores ipset from file.
+// Restore restores ipset from file. The nftables sets are restored with the azure-npm table by iptm.
 func (ipsMgr *IpsetManager) Restore(configFile string) error {
+	if util.IsNftablesEnabled {
+		return nil
+	}
+
 	if len(configFile) == 0 {
 		configFile = util.IpsetConfigFile
 	}
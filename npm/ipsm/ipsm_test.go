@@ -3,6 +3,7 @@
 package ipsm
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -242,6 +243,313 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestToIPv6Entry(t *testing.T) {
+	entry := toIPv6Entry(&ipsEntry{operationFlag: util.IpsetCreationFlag, set: "azure-npm-1", spec: util.IpsetNetHashFlag})
+	if entry.set != "azure-npm-1"+util.IpsetIPv6Suffix || entry.spec != "nethash family inet6" {
+		t.Errorf("TestToIPv6Entry failed, unexpected set entry %+v", entry)
+	}
+
+	entry = toIPv6Entry(&ipsEntry{operationFlag: util.IpsetCreationFlag, set: "azure-npm-2", spec: util.IpsetSetListFlag})
+	if entry.spec != util.IpsetSetListFlag {
+		t.Errorf("TestToIPv6Entry failed, unexpected list entry %+v", entry)
+	}
+
+	entry = toIPv6Entry(&ipsEntry{operationFlag: util.IpsetAppendFlag, set: "azure-npm-2", spec: "azure-npm-1"})
+	if entry.spec != "azure-npm-1"+util.IpsetIPv6Suffix {
+		t.Errorf("TestToIPv6Entry failed, unexpected list member entry %+v", entry)
+	}
+
+	entry = toIPv6Entry(&ipsEntry{operationFlag: util.IpsetAppendFlag, set: "azure-npm-1", spec: "fd00::4"})
+	if entry.spec != "fd00::4" {
+		t.Errorf("TestToIPv6Entry failed, unexpected member entry %+v", entry)
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	ipsMgr := NewIpsetManager()
+	if err := ipsMgr.Save(util.IpsetTestConfigFile); err != nil {
+		t.Errorf("TestApplyBatch failed @ ipsMgr.Save")
+	}
+
+	defer func() {
+		if err := ipsMgr.Restore(util.IpsetTestConfigFile); err != nil {
+			t.Errorf("TestApplyBatch failed @ ipsMgr.Restore")
+		}
+	}()
+
+	if err := ipsMgr.AddToSet("test-set", "1.2.3.4"); err != nil {
+		t.Errorf("TestApplyBatch failed @ ipsMgr.AddToSet")
+	}
+
+	if err := ipsMgr.AddToList("test-list", "test-set"); err != nil {
+		t.Errorf("TestApplyBatch failed @ ipsMgr.AddToList")
+	}
+
+	// Creating the set and list and adding their members is batched.
+	if len(ipsMgr.pending) != 4 {
+		t.Errorf("TestApplyBatch failed, unexpected pending operations %+v", ipsMgr.pending)
+	}
+
+	if err := ipsMgr.Apply(); err != nil {
+		t.Errorf("TestApplyBatch failed @ ipsMgr.Apply")
+	}
+
+	if len(ipsMgr.pending) != 0 {
+		t.Errorf("TestApplyBatch failed, operations still pending after apply")
+	}
+}
+
+func TestApplyFailedRestore(t *testing.T) {
+	ipsMgr := NewIpsetManager()
+
+	// Adding to a set that doesn't exist fails the restore.
+	entry := &ipsEntry{operationFlag: util.IpsetAppendFlag, set: "azure-npm-test-missing", spec: "1.2.3.4"}
+	ipsMgr.pending = append(ipsMgr.pending, entry)
+
+	if err := ipsMgr.Apply(); err == nil {
+		t.Errorf("TestApplyFailedRestore failed, restore of a missing set succeeded")
+	}
+
+	// The operations are kept to be applied again.
+	if len(ipsMgr.pending) != 1 || ipsMgr.pending[0] != entry {
+		t.Errorf("TestApplyFailedRestore failed, unexpected pending operations %+v after failed apply", ipsMgr.pending)
+	}
+}
+
+func TestRepairDrift(t *testing.T) {
+	ipsMgr := NewIpsetManager()
+	if err := ipsMgr.AddToSet("test-set", "1.2.3.4"); err != nil {
+		t.Errorf("TestRepairDrift failed @ ipsMgr.AddToSet")
+	}
+
+	if err := ipsMgr.AddToSet("test-set", "10.0.0.0/32"); err != nil {
+		t.Errorf("TestRepairDrift failed @ ipsMgr.AddToSet")
+	}
+
+	if err := ipsMgr.AddToList("test-list", "test-set"); err != nil {
+		t.Errorf("TestRepairDrift failed @ ipsMgr.AddToList")
+	}
+	ipsMgr.pending = nil
+
+	set, list := util.GetHashedName("test-set"), util.GetHashedName("test-list")
+	save := "create " + set + " hash:net family inet hashsize 1024 maxelem 65536\n" +
+		"add " + set + " 10.0.0.0\n" +
+		"add " + set + " 5.6.7.8\n"
+
+	// The list is missing, the set lacks 1.2.3.4 and has an unknown member.
+	if drift := ipsMgr.repairDrift(parseIpsetSave(save)); drift != 4 {
+		t.Errorf("TestRepairDrift failed, unexpected drift %d", drift)
+	}
+
+	expected := map[string]bool{
+		util.IpsetCreationFlag + " " + list: true,
+		util.IpsetAppendFlag + " " + list:   true,
+		util.IpsetAppendFlag + " " + set:    true,
+		util.IpsetDeletionFlag + " " + set:  true,
+	}
+	for _, entry := range ipsMgr.pending {
+		if !expected[entry.operationFlag+" "+entry.set] {
+			t.Errorf("TestRepairDrift failed, unexpected operation %+v", entry)
+		}
+	}
+
+	if drift := NewIpsetManager().repairDrift(parseIpsetSave(save)); drift != 0 {
+		t.Errorf("TestRepairDrift failed, untracked sets reported as drift")
+	}
+}
+
+func TestDescribeDrift(t *testing.T) {
+	ipsMgr := NewIpsetManager()
+	if err := ipsMgr.AddToSet("test-set", "1.2.3.4"); err != nil {
+		t.Errorf("TestDescribeDrift failed @ ipsMgr.AddToSet")
+	}
+	ipsMgr.pending = nil
+
+	set := util.GetHashedName("test-set")
+	save := "create " + set + " hash:net family inet hashsize 1024 maxelem 65536\n" +
+		"add " + set + " 5.6.7.8\n"
+
+	drift := ipsMgr.describeDrift(parseIpsetSave(save))
+	expected := []string{
+		"ipset " + set + " (test-set) is missing member 1.2.3.4",
+		"ipset " + set + " (test-set) has unknown member 5.6.7.8",
+	}
+	if !reflect.DeepEqual(drift, expected) {
+		t.Errorf("TestDescribeDrift failed, unexpected drift %v", drift)
+	}
+
+	// Describing the drift doesn't repair it.
+	if len(ipsMgr.pending) != 0 {
+		t.Errorf("TestDescribeDrift failed, unexpected operations %+v", ipsMgr.pending)
+	}
+}
+
+func TestMigrateLegacySet(t *testing.T) {
+	ipsMgr := NewIpsetManager()
+	ipsMgr.setStartupSets([]string{
+		util.GetLegacyHashedName("test-set"),
+		util.GetLegacyHashedName("test-list"),
+		util.GetHashedName("test-list"),
+		"kube-proxy-set",
+	})
+
+	if err := ipsMgr.CreateSet("test-set"); err != nil {
+		t.Errorf("TestMigrateLegacySet failed @ ipsMgr.CreateSet")
+	}
+
+	if err := ipsMgr.CreateList("test-list"); err != nil {
+		t.Errorf("TestMigrateLegacySet failed @ ipsMgr.CreateList")
+	}
+
+	// The legacy set is renamed before it is created, the legacy list is not as the list exists already.
+	if len(ipsMgr.pending) != 3 {
+		t.Fatalf("TestMigrateLegacySet failed, unexpected operations %+v", ipsMgr.pending)
+	}
+
+	rename := ipsMgr.pending[0]
+	if rename.operationFlag != util.IpsetRenameFlag || rename.set != util.GetLegacyHashedName("test-set") ||
+		rename.spec != util.GetHashedName("test-set") {
+		t.Errorf("TestMigrateLegacySet failed, unexpected operation %+v", rename)
+	}
+
+	if ipsMgr.pending[1].operationFlag != util.IpsetCreationFlag || ipsMgr.pending[2].operationFlag != util.IpsetCreationFlag {
+		t.Errorf("TestMigrateLegacySet failed, unexpected operations %+v", ipsMgr.pending[1:])
+	}
+
+	if !ipsMgr.startupSets[util.GetLegacyHashedName("test-list")] || ipsMgr.startupSets[util.GetLegacyHashedName("test-set")] {
+		t.Errorf("TestMigrateLegacySet failed, unexpected legacy sets %v", ipsMgr.startupSets)
+	}
+
+	if _, exists := ipsMgr.startupSets["kube-proxy-set"]; exists {
+		t.Errorf("TestMigrateLegacySet failed, set not owned by npm recorded")
+	}
+	ipsMgr.pending = nil
+}
+
+func TestGetNftElements(t *testing.T) {
+	tests := []struct {
+		members  []string
+		expected []string
+	}{
+		{
+			members:  []string{"10.0.0.4", "10.0.0.5,tcp:80", "10.0.0.4"},
+			expected: []string{"10.0.0.4", "10.0.0.5 . tcp . 80"},
+		},
+		{
+			members:  []string{"10.1.0.0/16", "10.1.0.0/17 nomatch"},
+			expected: []string{"10.1.128.0/17"},
+		},
+		{
+			members:  []string{"10.1.0.0/16", "10.1.0.0/18 nomatch", "10.1.128.0/17 nomatch"},
+			expected: []string{"10.1.64.0/18"},
+		},
+	}
+
+	for _, test := range tests {
+		if elements := getNftElements(test.members); !reflect.DeepEqual(elements, test.expected) {
+			t.Errorf("TestGetNftElements failed @ %v: %v, expected %v", test.members, elements, test.expected)
+		}
+	}
+}
+
+func TestGetNftCommands(t *testing.T) {
+	defer func() { util.IsNftablesEnabled = false }()
+	util.IsNftablesEnabled = true
+
+	ipsMgr := &IpsetManager{
+		listMap: map[string]*Ipset{
+			"ns-all": {name: "ns-all", elements: []string{"ns-a", "ns-b"}},
+		},
+		setMap: map[string]*Ipset{
+			"ns-a": {name: "ns-a", elements: []string{"10.0.0.4", "10.0.0.5"}},
+			"ns-b": {name: "ns-b", elements: []string{"10.0.0.5"}},
+		},
+	}
+	list, setA := util.GetHashedName("ns-all"), util.GetHashedName("ns-a")
+
+	tests := []struct {
+		entry    *ipsEntry
+		expected string
+	}{
+		{
+			entry: &ipsEntry{operationFlag: util.IpsetCreationFlag, name: "ns-c", set: util.GetHashedName("ns-c"), spec: util.IpsetNetHashFlag},
+			expected: "add set ip azure-npm " + util.GetHashedName("ns-c") +
+				" { type ipv4_addr; flags interval; }\n",
+		},
+		{
+			entry: &ipsEntry{operationFlag: util.IpsetAppendFlag, name: "ns-a", set: setA, spec: "10.0.0.6"},
+			expected: "add element ip azure-npm " + setA + " { 10.0.0.6 }\n" +
+				"add element ip azure-npm " + list + " { 10.0.0.6 }\n",
+		},
+		{
+			// 10.0.0.5 stays in the list, ns-b holds it.
+			entry: &ipsEntry{operationFlag: util.IpsetDeletionFlag, name: "ns-all", set: list, spec: setA},
+			expected: "add element ip azure-npm " + list + " { 10.0.0.4 }\n" +
+				"delete element ip azure-npm " + list + " { 10.0.0.4 }\n",
+		},
+	}
+
+	for _, test := range tests {
+		if commands := ipsMgr.getNftCommands(test.entry); commands != test.expected {
+			t.Errorf("TestGetNftCommands failed @ %+v:\n%s\nexpected:\n%s", test.entry, commands, test.expected)
+		}
+	}
+}
+
+func TestParseNftSets(t *testing.T) {
+	out := `table ip filter {
+	set other {
+		type ipv4_addr
+		elements = { 10.0.0.1 }
+	}
+}
+table ip azure-npm {
+	set azure-npm-1 {
+		type ipv4_addr
+		flags interval
+		elements = { 10.0.0.4, 10.1.0.0/16,
+			     10.2.0.0/16 }
+	}
+
+	set azure-npm-2 {
+		type ipv4_addr . inet_proto . inet_service
+		elements = { 10.0.0.4 . tcp . 80 }
+	}
+
+	set azure-npm-3 {
+		type ipv4_addr
+		flags interval
+	}
+
+	chain AZURE-NPM {
+		ip daddr @azure-npm-1 accept
+	}
+}
+table ip6 azure-npm {
+	set azure-npm-1-v6 {
+		type ipv6_addr
+		flags interval
+		elements = { fd00::4 }
+	}
+}
+`
+
+	sets, families := parseNftSets(out)
+	expected := map[string]map[string]bool{
+		"azure-npm-1":    {"10.0.0.4": true, "10.1.0.0/16": true, "10.2.0.0/16": true},
+		"azure-npm-2":    {"10.0.0.4 . tcp . 80": true},
+		"azure-npm-3":    {},
+		"azure-npm-1-v6": {"fd00::4": true},
+	}
+	if !reflect.DeepEqual(sets, expected) {
+		t.Errorf("TestParseNftSets failed, unexpected sets %v", sets)
+	}
+
+	if families["azure-npm-1"] != util.NftIPv4Family || families["azure-npm-1-v6"] != util.NftIPv6Family {
+		t.Errorf("TestParseNftSets failed, unexpected families %v", families)
+	}
+}
+
 func TestMain(m *testing.M) {
 	ipsMgr := NewIpsetManager()
 	ipsMgr.Save(util.IpsetConfigFile)
@@ -0,0 +1,149 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package ipsm
+
+import (
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// LoadStartupSets records the sets of npm found in the dataplane when it starts, so that the sets named by an
+// earlier version are migrated to the current names. No earlier version programmed nftables sets.
+func (ipsMgr *IpsetManager) LoadStartupSets() error {
+	if util.IsNftablesEnabled {
+		ipsMgr.setStartupSets(nil)
+		return nil
+	}
+
+	out, err := exec.Command(util.Ipset, util.IpsetListFlag, util.IpsetNameFlag).Output()
+	if err != nil {
+		log.Printf("Error listing ipsets: %v\n", err)
+		return err
+	}
+
+	ipsMgr.setStartupSets(strings.Fields(string(out)))
+
+	return nil
+}
+
+// setStartupSets records the given sets of npm as found at startup.
+func (ipsMgr *IpsetManager) setStartupSets(setNames []string) {
+	ipsMgr.startupSets = make(map[string]bool)
+	legacy := 0
+	for _, setName := range setNames {
+		if !strings.HasPrefix(setName, util.AzureNpmPrefix) {
+			continue
+		}
+
+		ipsMgr.startupSets[setName] = true
+		if util.IsLegacySetName(setName) {
+			legacy++
+		}
+	}
+
+	if ipsMgr.ip6sMgr != nil {
+		ipsMgr.ip6sMgr.startupSets = ipsMgr.startupSets
+	}
+
+	if legacy > 0 {
+		log.Printf("Found %d ipsets named by an earlier version to migrate\n", legacy)
+	}
+}
+
+// migrateLegacySet renames the set an earlier version created for a selector, if it is still there and the set
+// with the current name is not. Rules of the earlier version keep referring to the renamed set, so that policies
+// stay enforced until the rules are programmed again, and its members are reconciled like those of any set.
+func (ipsMgr *IpsetManager) migrateLegacySet(name string) {
+	if len(ipsMgr.startupSets) == 0 {
+		return
+	}
+
+	legacyName, hashedName := util.GetLegacyHashedName(name), util.GetHashedName(name)
+	actualLegacyName, actualName := legacyName, hashedName
+	if ipsMgr.ipv6 {
+		actualLegacyName, actualName = util.GetIPv6SetName(legacyName), util.GetIPv6SetName(hashedName)
+	}
+
+	if !ipsMgr.startupSets[actualLegacyName] || ipsMgr.startupSets[actualName] {
+		return
+	}
+
+	entry := &ipsEntry{
+		name:          name,
+		operationFlag: util.IpsetRenameFlag,
+		set:           legacyName,
+		spec:          hashedName,
+	}
+	log.Printf("Migrating Set: %+v\n", entry)
+	ipsMgr.queue(entry)
+
+	delete(ipsMgr.startupSets, actualLegacyName)
+	ipsMgr.startupSets[actualName] = true
+}
+
+// DestroyLegacySets destroys the sets named by an earlier version that were not migrated, once the rules no longer
+// refer to them. Sets still referred to are kept and destroyed by a later call. It returns how many are left.
+func (ipsMgr *IpsetManager) DestroyLegacySets() int {
+	var legacyNames []string
+	for setName := range ipsMgr.startupSets {
+		if util.IsLegacySetName(setName) {
+			legacyNames = append(legacyNames, setName)
+		}
+	}
+	sort.Strings(legacyNames)
+
+	// Sets in a legacy list can only be destroyed after the list, so destroy until no more sets can be.
+	for destroyed := true; destroyed && len(legacyNames) > 0; {
+		destroyed = false
+
+		var left []string
+		for _, setName := range legacyNames {
+			// The set is named as it is in the dataplane, IPv6 counterparts included.
+			if err := exec.Command(util.Ipset, util.IpsetDestroyFlag, setName).Run(); err != nil {
+				left = append(left, setName)
+				continue
+			}
+
+			log.Printf("Destroyed legacy ipset %s\n", setName)
+			delete(ipsMgr.startupSets, setName)
+			destroyed = true
+		}
+
+		legacyNames = left
+	}
+
+	if len(legacyNames) > 0 {
+		log.Printf("Cannot destroy legacy ipsets %v yet, they are still referred to\n", legacyNames)
+	}
+
+	return len(legacyNames)
+}
+
+// releaseName forgets the name of the set of a selector once neither family tracks it.
+func (ipsMgr *IpsetManager) releaseName(name string) {
+	if ipsMgr.ipv6 {
+		return
+	}
+
+	for _, m := range ipsMgr.trackedMaps() {
+		if _, exists := m[name]; exists {
+			return
+		}
+	}
+
+	util.ReleaseHashedName(name)
+}
+
+// trackedMaps returns the sets and lists tracked by both families.
+func (ipsMgr *IpsetManager) trackedMaps() []map[string]*Ipset {
+	maps := []map[string]*Ipset{ipsMgr.setMap, ipsMgr.listMap}
+	if ipsMgr.ip6sMgr != nil {
+		maps = append(maps, ipsMgr.ip6sMgr.setMap, ipsMgr.ip6sMgr.listMap)
+	}
+
+	return maps
+}
@@ -0,0 +1,474 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package ipsm
+
+import (
+	"bytes"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// With the nftables backend, sets are nftables sets of the azure-npm table of their family, which iptm programs
+// the AZURE-NPM chains in. nftables sets can't hold sets nor nomatch elements, so a list holds the elements of its
+// sets, and the set of an ipBlock holds its CIDRs without their except blocks. Operations are translated to nft
+// commands when they are queued, from the tracked state at that time.
+
+// getNftTable returns the azure-npm table of the manager's family, as nft commands refer to it.
+func (ipsMgr *IpsetManager) getNftTable() string {
+	if ipsMgr.ipv6 {
+		return util.NftIPv6Family + " " + util.NftTable
+	}
+
+	return util.NftIPv4Family + " " + util.NftTable
+}
+
+// getNftSetName returns the name of the nftables set of a hashed set name in the manager's family.
+func (ipsMgr *IpsetManager) getNftSetName(hashedName string) string {
+	if ipsMgr.ipv6 {
+		return util.GetIPv6SetName(hashedName)
+	}
+
+	return hashedName
+}
+
+// getNftSetSpec returns the nftables declaration of a set of the given kind.
+// Sets of addresses are interval sets, so that they hold CIDRs.
+func (ipsMgr *IpsetManager) getNftSetSpec(kind string) string {
+	addrType := "ipv4_addr"
+	if ipsMgr.ipv6 {
+		addrType = "ipv6_addr"
+	}
+
+	if kind == util.IpsetIPPortHashFlag {
+		return "{ type " + addrType + " . inet_proto . inet_service; }"
+	}
+
+	return "{ type " + addrType + "; flags interval; }"
+}
+
+// getNftElement returns the nftables element of a set member, e.g. "10.0.0.4 . tcp . 80" for "10.0.0.4,tcp:80".
+func getNftElement(member string) string {
+	member = normalizeMember(strings.Fields(member)[0])
+
+	parts := strings.SplitN(member, ",", 2)
+	if len(parts) == 1 {
+		return member
+	}
+
+	protoPort := strings.SplitN(parts[1], ":", 2)
+	if len(protoPort) == 1 {
+		return parts[0] + " . " + protoPort[0]
+	}
+
+	return parts[0] + " . " + protoPort[0] + " . " + protoPort[1]
+}
+
+// getNftElements returns the nftables elements of a set with the given members. Nomatch members are removed from
+// the CIDRs of the other members.
+func getNftElements(members []string) []string {
+	var elements []string
+	if !hasNomatch(members) {
+		for _, member := range members {
+			elements = append(elements, getNftElement(member))
+		}
+
+		return util.UniqueStrSlice(elements)
+	}
+
+	var nets, excepts []*net.IPNet
+	for _, member := range members {
+		fields := strings.Fields(member)
+		ipNet := parseIPNet(fields[0])
+		if ipNet == nil {
+			log.Printf("Error parsing set member %s\n", member)
+			continue
+		}
+
+		if len(fields) > 1 && fields[1] == util.IpsetNomatchFlag {
+			excepts = append(excepts, ipNet)
+		} else {
+			nets = append(nets, ipNet)
+		}
+	}
+
+	for _, except := range excepts {
+		var remaining []*net.IPNet
+		for _, ipNet := range nets {
+			remaining = append(remaining, util.SubtractCIDR(ipNet, except)...)
+		}
+		nets = remaining
+	}
+
+	for _, ipNet := range nets {
+		elements = append(elements, normalizeMember(ipNet.String()))
+	}
+
+	return util.UniqueStrSlice(elements)
+}
+
+// parseIPNet parses a CIDR or an address, which is a host CIDR.
+func parseIPNet(s string) *net.IPNet {
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		return ipNet
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// hasNomatch checks if any of the members of a set is a nomatch one.
+func hasNomatch(members []string) bool {
+	for _, member := range members {
+		if strings.Contains(member, util.IpsetNomatchFlag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getNftAddCommand returns the nft command adding elements to a set, or nothing if there are none.
+func getNftAddCommand(table string, set string, elements []string) string {
+	if len(elements) == 0 {
+		return ""
+	}
+
+	return "add element " + table + " " + set + " { " + strings.Join(elements, ", ") + " }\n"
+}
+
+// getNftDeleteCommand returns the nft commands deleting elements from a set, or nothing if there are none.
+// The elements are added first, as deleting a missing element fails the whole transaction.
+func getNftDeleteCommand(table string, set string, elements []string) string {
+	if len(elements) == 0 {
+		return ""
+	}
+
+	return getNftAddCommand(table, set, elements) +
+		"delete element " + table + " " + set + " { " + strings.Join(elements, ", ") + " }\n"
+}
+
+// getSetByHashedName returns the tracked set with the given hashed name.
+func (ipsMgr *IpsetManager) getSetByHashedName(hashedName string) *Ipset {
+	for name, set := range ipsMgr.setMap {
+		if util.GetHashedName(name) == hashedName {
+			return set
+		}
+	}
+
+	return nil
+}
+
+// getListElements returns the nftables elements of a list, those of its sets.
+func (ipsMgr *IpsetManager) getListElements(list *Ipset) []string {
+	var elements []string
+	for _, setName := range list.elements {
+		if set, exists := ipsMgr.setMap[setName]; exists {
+			elements = append(elements, getNftElements(set.elements)...)
+		}
+	}
+	sort.Strings(elements)
+
+	return util.UniqueStrSlice(elements)
+}
+
+// getListedElements returns the given elements of a set that no other set of a list holds, which the list holds
+// because of this set only.
+func (ipsMgr *IpsetManager) getListedElements(list *Ipset, setName string, elements []string) []string {
+	others := make(map[string]bool)
+	for _, other := range list.elements {
+		if set, exists := ipsMgr.setMap[other]; exists && other != setName {
+			for _, element := range getNftElements(set.elements) {
+				others[element] = true
+			}
+		}
+	}
+
+	var listed []string
+	for _, element := range elements {
+		if !others[element] {
+			listed = append(listed, element)
+		}
+	}
+
+	return listed
+}
+
+// getNftCommands returns the nft commands of an operation, before the tracked state is updated for additions
+// and after it is for deletions, as the operations are queued.
+func (ipsMgr *IpsetManager) getNftCommands(entry *ipsEntry) string {
+	table, set := ipsMgr.getNftTable(), ipsMgr.getNftSetName(entry.set)
+
+	list, isList := ipsMgr.listMap[entry.name]
+	tracked, isSet := ipsMgr.setMap[entry.name]
+
+	switch entry.operationFlag {
+	case util.IpsetCreationFlag:
+		return "add set " + table + " " + set + " " + ipsMgr.getNftSetSpec(entry.spec) + "\n"
+	case util.IpsetAppendFlag, util.IpsetDeletionFlag:
+		add := entry.operationFlag == util.IpsetAppendFlag
+
+		if isList {
+			member := ipsMgr.getSetByHashedName(entry.spec)
+			if member == nil {
+				return ""
+			}
+
+			elements := getNftElements(member.elements)
+			if add {
+				return getNftAddCommand(table, set, elements)
+			}
+
+			return getNftDeleteCommand(table, set, ipsMgr.getListedElements(list, member.name, elements))
+		}
+
+		if !isSet {
+			return ""
+		}
+
+		// The elements of the set of an ipBlock depend on all its members, it is programmed again as a whole.
+		members := tracked.elements
+		if add {
+			members = append(append([]string(nil), members...), entry.spec)
+		}
+		if strings.HasPrefix(entry.name, util.IPBlockIPSetPrefix) || hasNomatch(members) {
+			return "flush set " + table + " " + set + "\n" + getNftAddCommand(table, set, getNftElements(members))
+		}
+
+		element := getNftElement(entry.spec)
+		if add {
+			commands := getNftAddCommand(table, set, []string{element})
+			for _, list := range ipsMgr.getListsOf(entry.name) {
+				commands += getNftAddCommand(table, ipsMgr.getNftSetName(util.GetHashedName(list.name)), []string{element})
+			}
+			return commands
+		}
+
+		commands := getNftDeleteCommand(table, set, []string{element})
+		for _, list := range ipsMgr.getListsOf(entry.name) {
+			listed := ipsMgr.getListedElements(list, entry.name, []string{element})
+			commands += getNftDeleteCommand(table, ipsMgr.getNftSetName(util.GetHashedName(list.name)), listed)
+		}
+		return commands
+	}
+
+	// Sets are not renamed, no earlier version programmed nftables sets.
+	return ""
+}
+
+// getListsOf returns the tracked lists holding a set.
+func (ipsMgr *IpsetManager) getListsOf(setName string) []*Ipset {
+	var lists []*Ipset
+	for _, list := range ipsMgr.listMap {
+		for _, elem := range list.elements {
+			if elem == setName {
+				lists = append(lists, list)
+				break
+			}
+		}
+	}
+
+	return lists
+}
+
+// applyNft programs the given operations with a single nft transaction, creating the azure-npm table if needed.
+func (ipsMgr *IpsetManager) applyNft(pending []*ipsEntry) error {
+	var input bytes.Buffer
+	input.WriteString("add table " + ipsMgr.getNftTable() + "\n")
+	for _, entry := range pending {
+		input.WriteString(entry.commands)
+	}
+
+	log.Printf("Applying %d nftables set operations\n", len(pending))
+
+	cmd := exec.Command(util.Nft, util.NftFileFlag, util.NftStdin)
+	cmd.Stdin = &input
+	if cmdOut, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Error running nft: %v\n%s\nInput:\n%s", err, string(cmdOut), input.String())
+		return err
+	}
+
+	return nil
+}
+
+// parseNftSets returns the elements of each set of the azure-npm tables in the output of nft list ruleset, and the
+// family of each set.
+func parseNftSets(out string) (map[string]map[string]bool, map[string]string) {
+	var (
+		family     string
+		set        string
+		inTable    bool
+		inElements bool
+		depth      int
+		elements   strings.Builder
+	)
+	sets := make(map[string]map[string]bool)
+	families := make(map[string]string)
+
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		text := line
+		switch {
+		case depth == 0 && fields[0] == "table" && len(fields) >= 3:
+			family, inTable = fields[1], fields[2] == util.NftTable
+		case inTable && depth == 1 && fields[0] == "set" && len(fields) >= 2:
+			set = fields[1]
+			sets[set] = make(map[string]bool)
+			families[set] = family
+		case set != "" && depth == 2 && fields[0] == "elements":
+			inElements = true
+			elements.Reset()
+			text = line[strings.Index(line, "{")+1:]
+		}
+
+		if inElements {
+			if end := strings.Index(text, "}"); end < 0 {
+				elements.WriteString(text + " ")
+			} else {
+				elements.WriteString(text[:end])
+				for _, element := range strings.Split(elements.String(), ",") {
+					if element = strings.Join(strings.Fields(element), " "); element != "" {
+						sets[set][element] = true
+					}
+				}
+				inElements = false
+			}
+		}
+
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 1 {
+			set = ""
+		}
+	}
+
+	return sets, families
+}
+
+// listNftSets returns the elements of each set of the azure-npm tables, and the family of each set.
+func listNftSets() (map[string]map[string]bool, map[string]string, error) {
+	out, err := exec.Command(util.Nft, "list", "ruleset").Output()
+	if err != nil {
+		log.Printf("Error listing nftables ruleset: %v\n", err)
+		return nil, nil, err
+	}
+
+	sets, families := parseNftSets(string(out))
+
+	return sets, families, nil
+}
+
+// findNftDrift returns the operations bringing the actual nftables sets of the manager's family back to the
+// tracked state, like findDrift.
+func (ipsMgr *IpsetManager) findNftDrift(actual map[string]map[string]bool) []*ipsEntry {
+	var entries []*ipsEntry
+	table := ipsMgr.getNftTable()
+
+	for _, m := range []map[string]*Ipset{ipsMgr.setMap, ipsMgr.listMap} {
+		var names []string
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			set := m[name]
+			hashedName := util.GetHashedName(name)
+			nftName := ipsMgr.getNftSetName(hashedName)
+
+			desired := getNftElements(set.elements)
+			if set.kind == util.IpsetSetListFlag {
+				desired = ipsMgr.getListElements(set)
+			}
+
+			members, exists := actual[nftName]
+			if !exists {
+				entries = append(entries, &ipsEntry{
+					name:          name,
+					operationFlag: util.IpsetCreationFlag,
+					set:           hashedName,
+					spec:          set.kind,
+					commands:      "add set " + table + " " + nftName + " " + ipsMgr.getNftSetSpec(set.kind) + "\n",
+				})
+			}
+
+			desiredElements := make(map[string]bool)
+			for _, element := range desired {
+				desiredElements[element] = true
+				if !members[element] {
+					entries = append(entries, &ipsEntry{
+						name:          name,
+						operationFlag: util.IpsetAppendFlag,
+						set:           hashedName,
+						spec:          element,
+						commands:      getNftAddCommand(table, nftName, []string{element}),
+					})
+				}
+			}
+
+			var unknown []string
+			for element := range members {
+				if !desiredElements[element] {
+					unknown = append(unknown, element)
+				}
+			}
+			sort.Strings(unknown)
+
+			for _, element := range unknown {
+				entries = append(entries, &ipsEntry{
+					name:          name,
+					operationFlag: util.IpsetDeletionFlag,
+					set:           hashedName,
+					spec:          element,
+					commands:      getNftDeleteCommand(table, nftName, []string{element}),
+				})
+			}
+		}
+	}
+
+	return entries
+}
+
+// destroyNftSets flushes the sets of the azure-npm tables and deletes those the rules don't refer to.
+func destroyNftSets() error {
+	sets, families, err := listNftSets()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		table := families[name] + " " + util.NftTable
+		if err := exec.Command(util.Nft, "flush", "set", families[name], util.NftTable, name).Run(); err != nil {
+			log.Printf("Error flushing nftables set %s %s: %v\n", table, name, err)
+			return err
+		}
+
+		if err := exec.Command(util.Nft, "delete", "set", families[name], util.NftTable, name).Run(); err != nil {
+			log.Printf("Cannot delete nftables set %s %s as it's being referred.\n", table, name)
+		}
+	}
+
+	return nil
+}
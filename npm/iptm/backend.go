@@ -0,0 +1,144 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package iptm
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// Backend programs the AZURE-NPM chains, either with a variant of the iptables binaries, for the legacy xtables or the
+// nftables kernel backend, or natively with nft.
+// Both iptables variants take the same rules and match ipsets, so the AZURE-NPM chains are programmed the same way on
+// either. The nftables backend translates the rules to nftables rules, matching the nftables sets of ipsm.
+type Backend struct {
+	Name     string
+	suffix   string // Suffix of the binaries of the variant, e.g. iptables-nft-restore.
+	nftables bool   // Whether the rules are programmed with nft rather than with the iptables binaries.
+}
+
+// Supported backends.
+var (
+	defaultBackend  = &Backend{Name: util.IptablesBackendDefault}
+	legacyBackend   = &Backend{Name: util.IptablesBackendLegacy, suffix: "-" + util.IptablesBackendLegacy}
+	nftBackend      = &Backend{Name: util.IptablesBackendNft, suffix: "-" + util.IptablesBackendNft}
+	nftablesBackend = &Backend{Name: util.IptablesBackendNftables, nftables: true}
+)
+
+// backend is the backend programming the rules of all the IptablesManagers.
+var backend = defaultBackend
+
+// command returns the binary of the backend for an iptables command, e.g. iptables-nft-save for iptables-save.
+func (b *Backend) command(cmd string) string {
+	if b.suffix == "" {
+		return cmd
+	}
+
+	parts := strings.SplitN(cmd, "-", 2)
+	if len(parts) == 1 {
+		return cmd + b.suffix
+	}
+
+	return parts[0] + b.suffix + "-" + parts[1]
+}
+
+// countRules returns the number of rules programmed with the backend, and whether the backend is usable.
+// The legacy backend is not usable on hosts whose kernel lacks it, even if its binaries are installed.
+func (b *Backend) countRules() (int, bool) {
+	out, err := exec.Command(b.command(util.IptablesSave)).Output()
+	if err != nil {
+		return 0, false
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, util.IptablesAppendFlag+" ") {
+			count++
+		}
+	}
+
+	return count, true
+}
+
+// isInstalled checks if the binaries of the backend are installed.
+func (b *Backend) isInstalled() bool {
+	cmd := b.command(util.IptablesRestore)
+	if b.nftables {
+		cmd = util.Nft
+	}
+
+	_, err := exec.LookPath(cmd)
+	return err == nil
+}
+
+// DetectBackend returns the backend to program the rules with. The legacy backend is kept on hosts whose rules,
+// those of kube-proxy among others, are programmed with it, so that the AZURE-NPM chains live next to them.
+// Otherwise, notably on hosts without the legacy backend, the rules are programmed natively with nftables, or with
+// the nft variant of the iptables binaries if nft is not installed.
+func DetectBackend() *Backend {
+	legacyRules, hasLegacy := 0, false
+	if legacyBackend.isInstalled() {
+		legacyRules, hasLegacy = legacyBackend.countRules()
+	}
+
+	nftRules, hasNft := 0, false
+	if nftBackend.isInstalled() {
+		nftRules, hasNft = nftBackend.countRules()
+	}
+
+	switch {
+	case hasLegacy && legacyRules > nftRules:
+		return legacyBackend
+	case hasLegacy && legacyRules == 0 && nftRules == 0 && isLegacyDefault():
+		// Neither backend has rules yet, keep the one the default binaries point to.
+		return legacyBackend
+	case nftablesBackend.isInstalled():
+		return nftablesBackend
+	case hasNft:
+		return nftBackend
+	case hasLegacy:
+		return legacyBackend
+	}
+
+	return defaultBackend
+}
+
+// isLegacyDefault checks if the default iptables binaries program the legacy backend.
+func isLegacyDefault() bool {
+	out, err := exec.Command(util.Iptables, util.IptablesVersionFlag).Output()
+	return err == nil && !strings.Contains(string(out), util.IptablesNfTablesVersion)
+}
+
+// SetBackend selects the backend programming the rules, either by name or detected when auto.
+func SetBackend(name string) error {
+	switch name {
+	case util.IptablesBackendAuto:
+		backend = DetectBackend()
+	case util.IptablesBackendLegacy:
+		backend = legacyBackend
+	case util.IptablesBackendNft:
+		backend = nftBackend
+	case util.IptablesBackendNftables:
+		backend = nftablesBackend
+	case util.IptablesBackendDefault:
+		backend = defaultBackend
+	default:
+		return fmt.Errorf("Unknown iptables backend %s", name)
+	}
+
+	// ipsm programs nftables sets rather than ipsets for the rules of the nftables backend.
+	util.IsNftablesEnabled = backend.nftables
+
+	log.Printf("Using %s iptables backend\n", backend.Name)
+
+	return nil
+}
+
+// GetBackend returns the backend programming the rules.
+func GetBackend() *Backend {
+	return backend
+}
@@ -1,8 +1,15 @@
 package iptm
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -19,8 +26,25 @@ type IptEntry struct {
 }
 
 // IptablesManager stores iptables entries.
+// Rules of the AZURE-NPM chains are kept in memory and programmed in batches by Apply.
 type IptablesManager struct {
 	OperationFlag string
+	ipv6          bool
+	ip6tMgr       *IptablesManager       // Programs the ip6tables counterparts of the rules when IPv6 is enabled.
+	chainMap      map[string][]*IptEntry // AZURE-NPM chain -> desired rules, in order.
+	dirtyChains   map[string]bool        // AZURE-NPM chains changed since they were last applied.
+	appliedChains map[string]string      // AZURE-NPM chain -> rules as last applied by iptables-restore.
+	shadow        bool                   // Whether the AZURE-NPM chains are programmed under their shadow names.
+}
+
+// npmChains are the chains owned by npm.
+var npmChains = []string{
+	util.IptablesAzureChain,
+	util.IptablesAzureIngressPortChain,
+	util.IptablesAzureIngressFromChain,
+	util.IptablesAzureEgressPortChain,
+	util.IptablesAzureEgressToChain,
+	util.IptablesAzureTargetSetsChain,
 }
 
 // NewIptablesManager creates a new instance for IptablesManager object.
@@ -29,6 +53,10 @@ func NewIptablesManager() *IptablesManager {
 		OperationFlag: "",
 	}
 
+	if util.IsIPv6Enabled {
+		iptMgr.ip6tMgr = &IptablesManager{ipv6: true}
+	}
+
 	return iptMgr
 }
 
@@ -36,217 +64,312 @@ func NewIptablesManager() *IptablesManager {
 func (iptMgr *IptablesManager) InitNpmChains() error {
 	log.Printf("Initializing AZURE-NPM chains")
 
-	if err := iptMgr.AddChain(util.IptablesAzureChain); err != nil {
-		return err
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.InitNpmChains(); err != nil {
+			return err
+		}
 	}
 
-	// Insert AZURE-NPM chain to FORWARD chain.
-	entry := &IptEntry{
-		Chain: util.IptablesForwardChain,
-		Specs: []string{
-			util.IptablesJumpFlag,
-			util.IptablesAzureChain,
-		},
-	}
-	exists, err := iptMgr.Exists(entry)
-	if err != nil {
-		return err
-	}
+	// While bootstrapping, the FORWARD chain jumps to the shadow chains once they are complete.
+	if !iptMgr.shadow {
+		if err := iptMgr.AddChain(util.IptablesAzureChain); err != nil {
+			return err
+		}
 
-	if !exists {
-		iptMgr.OperationFlag = util.IptablesInsertionFlag
-		if _, err = iptMgr.Run(entry); err != nil {
-			log.Printf("Error adding AZURE-NPM chain to FORWARD chain\n")
+		// Insert AZURE-NPM chain to FORWARD chain.
+		entry := &IptEntry{
+			Chain: util.IptablesForwardChain,
+			Specs: []string{
+				util.IptablesJumpFlag,
+				util.IptablesAzureChain,
+			},
+		}
+		exists, err := iptMgr.Exists(entry)
+		if err != nil {
 			return err
 		}
+
+		if !exists {
+			iptMgr.OperationFlag = util.IptablesInsertionFlag
+			if _, err = iptMgr.Run(entry); err != nil {
+				log.Printf("Error adding AZURE-NPM chain to FORWARD chain\n")
+				return err
+			}
+		}
 	}
 
-	// Add default allow CONNECTED/RELATED rule to AZURE-NPM chain.
-	entry.Chain = util.IptablesAzureChain
-	entry.Specs = []string{
-		util.IptablesMatchFlag,
-		util.IptablesStateFlag,
-		util.IPtablesMatchStateFlag,
-		util.IptablesRelatedState + "," + util.IptablesEstablishedState,
-		util.IptablesJumpFlag,
-		util.IptablesAccept,
+	// The AZURE-NPM chains are programmed in one batch, and so are the policy rules added to them later on.
+	iptMgr.chainMap, iptMgr.dirtyChains, iptMgr.appliedChains = nil, nil, nil
+	iptMgr.initChains()
+	for _, chain := range npmChains {
+		iptMgr.dirtyChains[chain] = true
 	}
-	exists, err = iptMgr.Exists(entry)
-	if err != nil {
-		return err
+
+	iptMgr.chainMap[util.IptablesAzureChain] = []*IptEntry{
+		// Default allow CONNECTED/RELATED rule.
+		{
+			Chain: util.IptablesAzureChain,
+			Specs: []string{
+				util.IptablesMatchFlag,
+				util.IptablesStateFlag,
+				util.IPtablesMatchStateFlag,
+				util.IptablesRelatedState + "," + util.IptablesEstablishedState,
+				util.IptablesJumpFlag,
+				util.IptablesAccept,
+			},
+		},
+		// Default allow kube-system rules.
+		{
+			Chain: util.IptablesAzureChain,
+			Specs: []string{
+				util.IptablesMatchFlag,
+				util.IptablesSetFlag,
+				util.IptablesMatchSetFlag,
+				util.GetHashedName(util.KubeSystemFlag),
+				util.IptablesDstFlag,
+				util.IptablesJumpFlag,
+				util.IptablesAccept,
+			},
+		},
+		{
+			Chain: util.IptablesAzureChain,
+			Specs: []string{
+				util.IptablesMatchFlag,
+				util.IptablesSetFlag,
+				util.IptablesMatchSetFlag,
+				util.GetHashedName(util.KubeSystemFlag),
+				util.IptablesSrcFlag,
+				util.IptablesJumpFlag,
+				util.IptablesAccept,
+			},
+		},
+		{
+			Chain: util.IptablesAzureChain,
+			Specs: []string{util.IptablesJumpFlag, util.IptablesAzureIngressPortChain},
+		},
+		{
+			Chain: util.IptablesAzureChain,
+			Specs: []string{util.IptablesJumpFlag, util.IptablesAzureEgressPortChain},
+		},
+		{
+			Chain: util.IptablesAzureChain,
+			Specs: []string{util.IptablesJumpFlag, util.IptablesAzureTargetSetsChain},
+		},
 	}
 
-	if !exists {
-		iptMgr.OperationFlag = util.IptablesInsertionFlag
-		if _, err = iptMgr.Run(entry); err != nil {
-			log.Printf("Error adding default allow CONNECTED/RELATED rule to AZURE-NPM chain\n")
-			return err
+	// DNS answers are logged before the default allow CONNECTED/RELATED rule accepts them, so that npm learns
+	// the addresses of the names of FQDN egress rules the pods resolve.
+	if util.IsFqdnSnoopingEnabled {
+		dnsEntry := &IptEntry{
+			Chain: util.IptablesAzureChain,
+			Specs: []string{
+				util.IptablesProtFlag,
+				"udp",
+				util.IptablesSrcPortFlag,
+				util.DNSPort,
+				util.IptablesJumpFlag,
+				util.IptablesNflog,
+				util.IptablesNflogGroupFlag,
+				strconv.Itoa(util.NpmDNSNflogGroup),
+			},
 		}
+		iptMgr.chainMap[util.IptablesAzureChain] = append([]*IptEntry{dnsEntry}, iptMgr.chainMap[util.IptablesAzureChain]...)
 	}
 
-	// Add default allow kube-system rules to AZURE-NPM chain.
-	entry.Specs = []string{
-		util.IptablesMatchFlag,
-		util.IptablesSetFlag,
-		util.IptablesMatchSetFlag,
-		util.GetHashedName(util.KubeSystemFlag),
-		util.IptablesDstFlag,
-		util.IptablesJumpFlag,
-		util.IptablesAccept,
-	}
-	exists, err = iptMgr.Exists(entry)
-	if err != nil {
+	if err := iptMgr.Apply(); err != nil {
+		log.Printf("Error initializing AZURE-NPM chains\n")
 		return err
 	}
 
-	if !exists {
-		iptMgr.OperationFlag = util.IptablesAppendFlag
-		if _, err := iptMgr.Run(entry); err != nil {
-			log.Printf("Error adding default allow kube-system rule to AZURE-NPM chain\n")
+	return nil
+}
+
+// UninitNpmChains uninitializes Azure NPM chains in iptables.
+// While bootstrapping, only the shadow chains are removed, the chains of the previous instance are kept until
+// the bootstrap finishes.
+func (iptMgr *IptablesManager) UninitNpmChains() error {
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.UninitNpmChains(); err != nil {
 			return err
 		}
 	}
 
-	entry.Specs = []string{
-		util.IptablesMatchFlag,
-		util.IptablesSetFlag,
-		util.IptablesMatchSetFlag,
-		util.GetHashedName(util.KubeSystemFlag),
-		util.IptablesSrcFlag,
-		util.IptablesJumpFlag,
-		util.IptablesAccept,
-	}
-	exists, err = iptMgr.Exists(entry)
-	if err != nil {
-		return err
-	}
+	return iptMgr.uninitChains()
+}
 
-	if !exists {
-		iptMgr.OperationFlag = util.IptablesAppendFlag
-		if _, err := iptMgr.Run(entry); err != nil {
-			log.Printf("Error adding default allow kube-system rule to AZURE-NPM chain\n")
+// uninitChains removes the AZURE-NPM chains of the manager's family.
+func (iptMgr *IptablesManager) uninitChains() error {
+	if !iptMgr.shadow {
+		// Remove AZURE-NPM chain from FORWARD chain.
+		entry := &IptEntry{
+			Chain: util.IptablesForwardChain,
+			Specs: []string{
+				util.IptablesJumpFlag,
+				util.IptablesAzureChain,
+			},
+		}
+		iptMgr.OperationFlag = util.IptablesDeletionFlag
+		errCode, err := iptMgr.Run(entry)
+		if errCode != 1 && err != nil {
+			log.Printf("Error removing default rule from FORWARD chain\n")
 			return err
 		}
 	}
-	// Create AZURE-NPM-INGRESS-PORT chain.
-	if err := iptMgr.AddChain(util.IptablesAzureIngressPortChain); err != nil {
+
+	if err := iptMgr.deleteChains(iptMgr.shadow); err != nil {
 		return err
 	}
 
-	// Insert AZURE-NPM-INGRESS-PORT chain to AZURE-NPM chain.
-	entry.Chain = util.IptablesAzureChain
-	entry.Specs = []string{util.IptablesJumpFlag, util.IptablesAzureIngressPortChain}
-	exists, err = iptMgr.Exists(entry)
-	if err != nil {
-		return err
+	iptMgr.chainMap, iptMgr.dirtyChains, iptMgr.appliedChains = nil, nil, nil
+
+	return nil
+}
+
+// deleteChains flushes and deletes the AZURE-NPM chains and the peer chains, or their shadows.
+// Peer chains are also looked up in iptables, since those of a previous instance are not in memory.
+func (iptMgr *IptablesManager) deleteChains(shadow bool) error {
+	chains := iptMgr.getChains()
+	if out, err := iptMgr.save(); err == nil {
+		counts, _ := parseSave(out)
+		known := make(map[string]bool)
+		for _, chain := range chains {
+			known[chain] = true
+		}
+
+		for _, chain := range getSavedPeerChains(counts, shadow) {
+			if !known[chain] {
+				chains = append(chains, chain)
+			}
+		}
 	}
 
-	if !exists {
-		iptMgr.OperationFlag = util.IptablesAppendFlag
-		if _, err := iptMgr.Run(entry); err != nil {
-			log.Printf("Error adding AZURE-NPM-INGRESS-PORT chain to AZURE-NPM chain\n")
-			return err
+	for i, chain := range chains {
+		if shadow {
+			chains[i] = getShadowChain(chain)
 		}
 	}
 
-	// Create AZURE-NPM-INGRESS-FROM chain.
-	if err := iptMgr.AddChain(util.IptablesAzureIngressFromChain); err != nil {
-		return err
+	iptMgr.OperationFlag = util.IptablesFlushFlag
+	for _, chain := range chains {
+		entry := &IptEntry{
+			Chain: chain,
+		}
+		if _, err := iptMgr.Run(entry); err != nil {
+			log.Printf("Error flushing iptables chain %s\n", chain)
+		}
 	}
 
-	// Create AZURE-NPM-EGRESS-PORT chain.
-	if err := iptMgr.AddChain(util.IptablesAzureEgressPortChain); err != nil {
-		return err
+	for _, chain := range chains {
+		if err := iptMgr.DeleteChain(chain); err != nil {
+			return err
+		}
 	}
 
-	// Insert AZURE-NPM-EGRESS-PORT chain to AZURE-NPM chain.
-	entry.Chain = util.IptablesAzureChain
-	entry.Specs = []string{util.IptablesJumpFlag, util.IptablesAzureEgressPortChain}
-	exists, err = iptMgr.Exists(entry)
-	if err != nil {
-		return err
+	return nil
+}
+
+// StartBootstrap makes the manager program the AZURE-NPM chains under their shadow names, so that the chains
+// left by a previous instance keep filtering traffic while the chains of this instance are built.
+func (iptMgr *IptablesManager) StartBootstrap() {
+	if iptMgr.ip6tMgr != nil {
+		iptMgr.ip6tMgr.StartBootstrap()
 	}
 
-	if !exists {
-		iptMgr.OperationFlag = util.IptablesAppendFlag
-		if _, err := iptMgr.Run(entry); err != nil {
-			log.Printf("Error adding AZURE-NPM-EGRESS-PORT chain to AZURE-NPM chain\n")
+	log.Printf("Bootstrapping AZURE-NPM chains under shadow names\n")
+	iptMgr.shadow = true
+}
+
+// FinishBootstrap swaps the shadow chains built since the bootstrap started for the AZURE-NPM chains.
+// Once the shadow chains are applied, a single iptables-restore makes the FORWARD chain jump to them, deletes the
+// chains of the previous instance and renames the shadow chains, so that traffic is always filtered by a complete
+// set of chains and the rules are only programmed once.
+// If no chain was built, no network policy exists and the chains of the previous instance are removed.
+func (iptMgr *IptablesManager) FinishBootstrap() error {
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.FinishBootstrap(); err != nil {
 			return err
 		}
 	}
 
-	// Create AZURE-NPM-EGRESS-FROM chain.
-	if err := iptMgr.AddChain(util.IptablesAzureEgressToChain); err != nil {
-		return err
+	if !iptMgr.shadow {
+		return nil
 	}
 
-	// Create AZURE-NPM-TARGET-SETS chain.
-	if err := iptMgr.AddChain(util.IptablesAzureTargetSetsChain); err != nil {
+	if iptMgr.chainMap == nil {
+		log.Printf("No AZURE-NPM chains were built while bootstrapping, removing those of the previous instance\n")
+		iptMgr.shadow = false
+		return iptMgr.uninitChains()
+	}
+
+	if err := iptMgr.apply(nil); err != nil {
 		return err
 	}
 
-	// Insert AZURE-NPM-TARGET-SETS chain to AZURE-NPM chain.
-	entry.Chain = util.IptablesAzureChain
-	entry.Specs = []string{util.IptablesJumpFlag, util.IptablesAzureTargetSetsChain}
-	exists, err = iptMgr.Exists(entry)
+	out, err := iptMgr.save()
 	if err != nil {
 		return err
 	}
 
-	if !exists {
-		iptMgr.OperationFlag = util.IptablesAppendFlag
-		if _, err := iptMgr.Run(entry); err != nil {
-			log.Printf("Error adding AZURE-NPM-TARGET-SETS chain to AZURE-NPM chain\n")
-			return err
-		}
+	counts, hasJump := parseSave(out)
+
+	log.Printf("Swapping in shadow AZURE-NPM chains\n")
+	if err := iptMgr.restore(nil, nil, iptMgr.getSwapCommands(counts, hasJump)); err != nil {
+		return err
 	}
 
+	iptMgr.finishShadow()
+
 	return nil
 }
 
-// UninitNpmChains uninitializes Azure NPM chains in iptables.
-func (iptMgr *IptablesManager) UninitNpmChains() error {
-	IptablesAzureChainList := []string{
-		util.IptablesAzureChain,
-		util.IptablesAzureIngressPortChain,
-		util.IptablesAzureIngressFromChain,
-		util.IptablesAzureEgressPortChain,
-		util.IptablesAzureEgressToChain,
-		util.IptablesAzureTargetSetsChain,
+// getSwapCommands returns the iptables-restore commands replacing the chains listed by iptables-save with the
+// applied shadow chains. The chains of the previous instance and the shadow chains left by an interrupted bootstrap
+// are flushed before any of them is deleted, as they may jump to each other.
+func (iptMgr *IptablesManager) getSwapCommands(counts map[string]int, hasJump bool) []string {
+	var applied, stale []string
+	shadows := make(map[string]bool)
+	for chain := range iptMgr.appliedChains {
+		applied = append(applied, chain)
+		shadows[getShadowChain(chain)] = true
 	}
+	sort.Strings(applied)
 
-	// Remove AZURE-NPM chain from FORWARD chain.
-	entry := &IptEntry{
-		Chain: util.IptablesForwardChain,
-		Specs: []string{
-			util.IptablesJumpFlag,
-			util.IptablesAzureChain,
-		},
-	}
-	iptMgr.OperationFlag = util.IptablesDeletionFlag
-	errCode, err := iptMgr.Run(entry)
-	if errCode != 1 && err != nil {
-		log.Printf("Error removing default rule from FORWARD chain\n")
-		return err
+	for chain := range counts {
+		if isNpmChain(chain) && !shadows[chain] {
+			stale = append(stale, chain)
+		}
 	}
+	sort.Strings(stale)
 
-	iptMgr.OperationFlag = util.IptablesFlushFlag
-	for _, chain := range IptablesAzureChainList {
-		entry := &IptEntry{
-			Chain: chain,
-		}
-		if _, err := iptMgr.Run(entry); err != nil {
-			log.Printf("Error flushing iptables chain %s\n", chain)
-		}
+	commands := []string{iptMgr.getRestoreCommand(util.IptablesInsertionFlag, util.IptablesForwardChain, "1", util.IptablesJumpFlag, getShadowChain(util.IptablesAzureChain))}
+	switch {
+	case backend.nftables:
+		// The FORWARD chain of the azure-npm table only jumps to the AZURE-NPM chain.
+		commands = append([]string{iptMgr.getRestoreCommand(util.IptablesFlushFlag, util.IptablesForwardChain)}, commands...)
+	case hasJump:
+		commands = append(commands, getRestoreRule(util.IptablesDeletionFlag, util.IptablesForwardChain, util.IptablesJumpFlag, util.IptablesAzureChain))
 	}
 
-	for _, chain := range IptablesAzureChainList {
-		if err := iptMgr.DeleteChain(chain); err != nil {
-			return err
-		}
+	for _, chain := range stale {
+		commands = append(commands, iptMgr.getRestoreCommand(util.IptablesFlushFlag, chain))
+	}
+	for _, chain := range stale {
+		commands = append(commands, iptMgr.getRestoreCommand(util.IptablesDestroyFlag, chain))
+	}
+	for _, chain := range applied {
+		commands = append(commands, iptMgr.getRestoreCommand(util.IptablesRenameChainFlag, getShadowChain(chain), chain))
 	}
 
-	return nil
+	return commands
+}
+
+// finishShadow records that the shadow chains were renamed to the AZURE-NPM chains, whose applied rules are those
+// of the shadow chains under the names they now have.
+func (iptMgr *IptablesManager) finishShadow() {
+	iptMgr.shadow = false
+	for chain := range iptMgr.appliedChains {
+		iptMgr.appliedChains[chain] = iptMgr.renderChain(chain)
+	}
 }
 
 // Exists checks if a rule exists in iptables.
@@ -306,9 +429,27 @@ func (iptMgr *IptablesManager) DeleteChain(chain string) error {
 }
 
 // Add adds a rule in iptables.
+// Rules of the AZURE-NPM chains are inserted at the top of the chain by the next Apply.
 func (iptMgr *IptablesManager) Add(entry *IptEntry) error {
 	log.Printf("Add iptables entry: %+v\n", entry)
 
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.Add(entry); err != nil {
+			return err
+		}
+	}
+
+	if isNpmChain(entry.Chain) {
+		iptMgr.initChains()
+		if iptMgr.findInChain(entry) >= 0 {
+			return nil
+		}
+
+		iptMgr.chainMap[entry.Chain] = append([]*IptEntry{entry}, iptMgr.chainMap[entry.Chain]...)
+		iptMgr.dirtyChains[entry.Chain] = true
+		return nil
+	}
+
 	exists, err := iptMgr.Exists(entry)
 	if err != nil {
 		return err
@@ -328,9 +469,27 @@ func (iptMgr *IptablesManager) Add(entry *IptEntry) error {
 }
 
 // Delete removes a rule in iptables.
+// Rules of the AZURE-NPM chains are removed by the next Apply.
 func (iptMgr *IptablesManager) Delete(entry *IptEntry) error {
 	log.Printf("Deleting iptables entry: %+v\n", entry)
 
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.Delete(entry); err != nil {
+			return err
+		}
+	}
+
+	if isNpmChain(entry.Chain) {
+		iptMgr.initChains()
+		if i := iptMgr.findInChain(entry); i >= 0 {
+			rules := iptMgr.chainMap[entry.Chain]
+			iptMgr.chainMap[entry.Chain] = append(rules[:i:i], rules[i+1:]...)
+			iptMgr.dirtyChains[entry.Chain] = true
+		}
+
+		return nil
+	}
+
 	exists, err := iptMgr.Exists(entry)
 	if err != nil {
 		return err
@@ -349,10 +508,563 @@ func (iptMgr *IptablesManager) Delete(entry *IptEntry) error {
 	return nil
 }
 
-// Run execute an iptables command to update iptables.
+// isNpmChain checks if a chain is owned by npm.
+func isNpmChain(chain string) bool {
+	return strings.HasPrefix(chain, util.IptablesAzureChain)
+}
+
+// isPeerChain checks if a chain holds the peer rules shared by the target sets of network policies.
+func isPeerChain(chain string) bool {
+	return strings.HasPrefix(chain, util.IptablesAzurePeerChainPrefix)
+}
+
+// getChains returns the AZURE-NPM chains, followed by the peer chains in memory or applied.
+func (iptMgr *IptablesManager) getChains() []string {
+	peerChains := make(map[string]bool)
+	for chain := range iptMgr.chainMap {
+		peerChains[chain] = isPeerChain(chain)
+	}
+	for chain := range iptMgr.appliedChains {
+		peerChains[chain] = isPeerChain(chain)
+	}
+
+	var chains []string
+	for chain, isPeer := range peerChains {
+		if isPeer {
+			chains = append(chains, chain)
+		}
+	}
+	sort.Strings(chains)
+
+	return append(append([]string(nil), npmChains...), chains...)
+}
+
+// getSavedPeerChains returns the peer chains in the chains of iptables-save, or the peer chains whose shadow is in them.
+func getSavedPeerChains(counts map[string]int, shadow bool) []string {
+	var chains []string
+	for chain := range counts {
+		if !isPeerChain(chain) || strings.HasSuffix(chain, util.IptablesShadowChainSuffix) != shadow {
+			continue
+		}
+
+		chains = append(chains, strings.TrimSuffix(chain, util.IptablesShadowChainSuffix))
+	}
+	sort.Strings(chains)
+
+	return chains
+}
+
+// getShadowChain returns the name an AZURE-NPM chain is programmed under while bootstrapping.
+func getShadowChain(chain string) string {
+	return chain + util.IptablesShadowChainSuffix
+}
+
+// getChainName returns the name an AZURE-NPM chain is currently programmed under.
+func (iptMgr *IptablesManager) getChainName(chain string) string {
+	if iptMgr.shadow {
+		return getShadowChain(chain)
+	}
+
+	return chain
+}
+
+// getRestoreRule returns a line of iptables-restore input running an iptables command.
+func getRestoreRule(flag string, chain string, specs ...string) string {
+	return strings.Join(append([]string{flag, chain}, specs...), " ") + "\n"
+}
+
+// getRestoreCommand returns the command of the backend running an iptables command within a restore,
+// a line of iptables-restore input or an nft command.
+func (iptMgr *IptablesManager) getRestoreCommand(flag string, chain string, specs ...string) string {
+	if !backend.nftables {
+		return getRestoreRule(flag, chain, specs...)
+	}
+
+	command, err := iptMgr.getNftCommand(flag, chain, specs...)
+	if err != nil {
+		log.Printf("Error translating iptables command %s %s %v to nftables: %v\n", flag, chain, specs, err)
+	}
+
+	return command
+}
+
+// initChains makes sure the in memory state of the AZURE-NPM chains is initialized.
+func (iptMgr *IptablesManager) initChains() {
+	if iptMgr.chainMap == nil {
+		iptMgr.chainMap = make(map[string][]*IptEntry)
+		iptMgr.dirtyChains = make(map[string]bool)
+		iptMgr.appliedChains = make(map[string]string)
+	}
+}
+
+// findInChain returns the index of a rule in its AZURE-NPM chain, or -1 if the chain doesn't have it.
+func (iptMgr *IptablesManager) findInChain(entry *IptEntry) int {
+	for i, rule := range iptMgr.chainMap[entry.Chain] {
+		if reflect.DeepEqual(rule.Specs, entry.Specs) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// renderChain returns the iptables-restore rules of an AZURE-NPM chain, or its nft rules with the nftables backend.
+// While bootstrapping, the chain and the AZURE-NPM chains its rules jump to are renamed to their shadows.
+// Rules nftables can't express are skipped.
+func (iptMgr *IptablesManager) renderChain(chain string) string {
+	var b strings.Builder
+
+	for _, rule := range iptMgr.chainMap[chain] {
+		specs, ok := iptMgr.getFamilySpecs(rule.Specs)
+		if !ok {
+			continue
+		}
+		specs = getAuditSpecs(rule, specs)
+
+		for i, spec := range specs {
+			if i > 0 && specs[i-1] == util.IptablesJumpFlag && isNpmChain(spec) {
+				specs[i] = iptMgr.getChainName(spec)
+			}
+		}
+
+		if backend.nftables {
+			command, err := iptMgr.getNftCommand(util.IptablesAppendFlag, iptMgr.getChainName(chain), specs...)
+			if err != nil {
+				log.Printf("Error translating rule %+v to nftables: %v\n", rule, err)
+				continue
+			}
+			b.WriteString(command)
+			continue
+		}
+
+		b.WriteString(util.IptablesAppendFlag + " " + iptMgr.getChainName(chain))
+		for _, spec := range specs {
+			if strings.ContainsAny(spec, " \t\"") {
+				spec = strconv.Quote(spec)
+			}
+			b.WriteString(" " + spec)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// GetDirtyChains returns the AZURE-NPM chains changed since they were last applied.
+func (iptMgr *IptablesManager) GetDirtyChains() []string {
+	var chains []string
+	for chain := range iptMgr.dirtyChains {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+
+	return chains
+}
+
+// Apply programs the AZURE-NPM chains changed since they were last applied with a single iptables-restore.
+// Each of these chains is replaced as a whole, chains whose rules end up unchanged are left alone.
+func (iptMgr *IptablesManager) Apply() error {
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.Apply(); err != nil {
+			return err
+		}
+	}
+
+	return iptMgr.apply(nil)
+}
+
+// apply programs the changed AZURE-NPM chains of the manager's family, and runs the given iptables-restore
+// commands after them within the same iptables-restore.
+// Peer chains left without rules are deleted once the chains jumping to them are replaced.
+func (iptMgr *IptablesManager) apply(commands []string) error {
+	var (
+		chains  []string
+		rules   []string
+		removed []string
+	)
+
+	for _, chain := range iptMgr.GetDirtyChains() {
+		if isPeerChain(chain) && len(iptMgr.chainMap[chain]) == 0 {
+			if _, applied := iptMgr.appliedChains[chain]; applied {
+				removed = append(removed, chain)
+				commands = append(commands, iptMgr.getRestoreCommand(util.IptablesDestroyFlag, iptMgr.getChainName(chain)))
+			} else {
+				delete(iptMgr.chainMap, chain)
+				delete(iptMgr.dirtyChains, chain)
+			}
+			continue
+		}
+
+		rendered := iptMgr.renderChain(chain)
+		if applied, exists := iptMgr.appliedChains[chain]; exists && applied == rendered {
+			delete(iptMgr.dirtyChains, chain)
+			continue
+		}
+
+		chains = append(chains, chain)
+		rules = append(rules, rendered)
+	}
+
+	if len(chains) == 0 && len(commands) == 0 {
+		return nil
+	}
+
+	if err := iptMgr.restore(chains, rules, commands); err != nil {
+		return err
+	}
+
+	for i, chain := range chains {
+		iptMgr.appliedChains[chain] = rules[i]
+		delete(iptMgr.dirtyChains, chain)
+	}
+
+	for _, chain := range removed {
+		delete(iptMgr.chainMap, chain)
+		delete(iptMgr.appliedChains, chain)
+		delete(iptMgr.dirtyChains, chain)
+	}
+
+	return nil
+}
+
+// restore replaces the given AZURE-NPM chains with their rendered rules and runs the given commands
+// with a single iptables-restore, or a single nft transaction with the nftables backend.
+func (iptMgr *IptablesManager) restore(chains []string, rules []string, commands []string) error {
+	if backend.nftables {
+		var input strings.Builder
+		for _, chain := range chains {
+			input.WriteString("add chain " + iptMgr.getNftTable() + " " + iptMgr.getChainName(chain) + "\n")
+			input.WriteString("flush chain " + iptMgr.getNftTable() + " " + iptMgr.getChainName(chain) + "\n")
+		}
+		for _, rendered := range rules {
+			input.WriteString(rendered)
+		}
+		for _, command := range commands {
+			input.WriteString(command)
+		}
+
+		log.Printf("Applying nftables chains %v\n", chains)
+		return iptMgr.runNft(input.String())
+	}
+
+	// With --noflush, declaring a chain creates or flushes it while the other chains are kept.
+	var input bytes.Buffer
+	input.WriteString("*filter\n")
+	for _, chain := range chains {
+		input.WriteString(":" + iptMgr.getChainName(chain) + " - [0:0]\n")
+	}
+	for _, rendered := range rules {
+		input.WriteString(rendered)
+	}
+	for _, command := range commands {
+		input.WriteString(command)
+	}
+	input.WriteString("COMMIT\n")
+
+	restoreCmd := backend.command(util.IptablesRestore)
+	if iptMgr.ipv6 {
+		restoreCmd = backend.command(util.Ip6tablesRestore)
+	}
+
+	log.Printf("Applying iptables chains %v\n", chains)
+	cmd := exec.Command(restoreCmd, util.IptablesRestoreNoFlushFlag)
+	cmd.Stdin = &input
+	if cmdOut, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Error running %s: %v\n%s\nInput:\n%s", restoreCmd, err, string(cmdOut), input.String())
+		return err
+	}
+
+	return nil
+}
+
+// parseSave returns the number of rules of each AZURE-NPM chain in the output of save, and whether the FORWARD
+// chain jumps to the AZURE-NPM chain.
+func parseSave(out string) (map[string]int, bool) {
+	if backend.nftables {
+		return parseNftSave(out)
+	}
+
+	return parseIptablesSave(out)
+}
+
+// parseIptablesSave returns the number of rules of each chain of the filter table in the output of iptables-save,
+// and whether the FORWARD chain jumps to the AZURE-NPM chain.
+func parseIptablesSave(out string) (map[string]int, bool) {
+	var (
+		table   string
+		hasJump bool
+	)
+	counts := make(map[string]int)
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(fields[0], "*"):
+			table = strings.TrimPrefix(fields[0], "*")
+		case table != "filter":
+		case strings.HasPrefix(fields[0], ":"):
+			counts[strings.TrimPrefix(fields[0], ":")] = 0
+		case fields[0] == util.IptablesAppendFlag && len(fields) > 1:
+			counts[fields[1]]++
+			if fields[1] == util.IptablesForwardChain && reflect.DeepEqual(fields[2:], []string{util.IptablesJumpFlag, util.IptablesAzureChain}) {
+				hasJump = true
+			}
+		}
+	}
+
+	return counts, hasJump
+}
+
+// chainDrift describes an applied AZURE-NPM chain that is missing or whose rule count changed.
+type chainDrift struct {
+	chain    string
+	exists   bool
+	expected int
+	actual   int
+}
+
+// findDrift returns the applied AZURE-NPM chains that drifted, and how many chains and rules drifted.
+// iptables-save prints rules in a normalized form, so rules are compared by count rather than by text.
+func (iptMgr *IptablesManager) findDrift(counts map[string]int) ([]chainDrift, int) {
+	var (
+		chains  []string
+		drifted []chainDrift
+	)
+	drift := 0
+
+	for chain := range iptMgr.appliedChains {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+
+	for _, chain := range chains {
+		expected := strings.Count(iptMgr.appliedChains[chain], "\n")
+		actual, exists := counts[chain]
+		switch {
+		case !exists:
+			drift += expected + 1
+		case actual > expected:
+			drift += actual - expected
+		case actual < expected:
+			drift += expected - actual
+		default:
+			continue
+		}
+
+		drifted = append(drifted, chainDrift{chain: chain, exists: exists, expected: expected, actual: actual})
+	}
+
+	return drifted, drift
+}
+
+// String describes how a chain drifted.
+func (d chainDrift) String() string {
+	if !d.exists {
+		return fmt.Sprintf("Chain %s is missing", d.chain)
+	}
+
+	return fmt.Sprintf("Chain %s has %d rules, expected %d", d.chain, d.actual, d.expected)
+}
+
+// repairDrift marks the applied AZURE-NPM chains that are missing or whose rule count changed as dirty,
+// so that the next Apply reprograms them, and returns how many chains and rules drifted.
+func (iptMgr *IptablesManager) repairDrift(counts map[string]int) int {
+	drifted, drift := iptMgr.findDrift(counts)
+	for _, d := range drifted {
+		log.Printf("%s\n", d)
+		delete(iptMgr.appliedChains, d.chain)
+		iptMgr.dirtyChains[d.chain] = true
+	}
+
+	return drift
+}
+
+// save returns the output of iptables-save of the manager's family, or the nftables ruleset of the family with the
+// nftables backend.
+func (iptMgr *IptablesManager) save() (string, error) {
+	if backend.nftables {
+		return iptMgr.listNft()
+	}
+
+	saveCmd := backend.command(util.IptablesSave)
+	if iptMgr.ipv6 {
+		saveCmd = backend.command(util.Ip6tablesSave)
+	}
+
+	out, err := exec.Command(saveCmd).Output()
+	if err != nil {
+		log.Printf("Error running %s: %v\n", saveCmd, err)
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// Verify compares the applied AZURE-NPM chains with the actual ones without repairing them, and describes the drift.
+func (iptMgr *IptablesManager) Verify() ([]string, error) {
+	var drift []string
+	if iptMgr.ip6tMgr != nil {
+		ip6Drift, err := iptMgr.ip6tMgr.Verify()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range ip6Drift {
+			drift = append(drift, "IPv6 "+d)
+		}
+	}
+
+	// The chains are not initialized until a network policy is added, nor swapped in until the bootstrap finishes.
+	if iptMgr.chainMap == nil || iptMgr.shadow {
+		return drift, nil
+	}
+
+	out, err := iptMgr.save()
+	if err != nil {
+		return nil, err
+	}
+
+	counts, hasJump := parseSave(out)
+	drifted, _ := iptMgr.findDrift(counts)
+	for _, d := range drifted {
+		drift = append(drift, d.String())
+	}
+
+	if !hasJump {
+		drift = append(drift, "Chain "+util.IptablesForwardChain+" doesn't jump to "+util.IptablesAzureChain)
+	}
+
+	return drift, nil
+}
+
+// Reconcile compares the applied AZURE-NPM chains with the actual ones and repairs the drift, e.g. chains
+// flushed by another tool. It returns how many chains and rules drifted.
+func (iptMgr *IptablesManager) Reconcile() (int, error) {
+	drift := 0
+	if iptMgr.ip6tMgr != nil {
+		ip6Drift, err := iptMgr.ip6tMgr.Reconcile()
+		drift += ip6Drift
+		if err != nil {
+			return drift, err
+		}
+	}
+
+	// The chains are not initialized until a network policy is added, nor swapped in until the bootstrap finishes.
+	if iptMgr.chainMap == nil || iptMgr.shadow {
+		return drift, nil
+	}
+
+	out, err := iptMgr.save()
+	if err != nil {
+		return drift, err
+	}
+
+	counts, hasJump := parseSave(out)
+	chainDrift := iptMgr.repairDrift(counts)
+	if !hasJump {
+		chainDrift++
+	}
+
+	if chainDrift == 0 {
+		return drift, nil
+	}
+
+	log.Printf("Repairing %d drifted iptables chains and rules\n", chainDrift)
+	drift += chainDrift
+
+	// The chains are recreated before the FORWARD chain jumps to them.
+	if err := iptMgr.Apply(); err != nil {
+		return drift, err
+	}
+
+	if !hasJump {
+		// Insert AZURE-NPM chain to FORWARD chain.
+		entry := &IptEntry{
+			Chain: util.IptablesForwardChain,
+			Specs: []string{
+				util.IptablesJumpFlag,
+				util.IptablesAzureChain,
+			},
+		}
+		iptMgr.OperationFlag = util.IptablesInsertionFlag
+		if _, err := iptMgr.Run(entry); err != nil {
+			log.Printf("Error adding AZURE-NPM chain to FORWARD chain\n")
+			return drift, err
+		}
+	}
+
+	return drift, nil
+}
+
+// getFamilySpecs returns the specs of a rule for the address family of the manager,
+// or false if the rule only applies to the other family.
+func (iptMgr *IptablesManager) getFamilySpecs(specs []string) ([]string, bool) {
+	familySpecs := make([]string, 0, len(specs))
+
+	for i, spec := range specs {
+		switch {
+		case i > 0 && (specs[i-1] == util.IptablesSFlag || specs[i-1] == util.IptablesDFlag):
+			if util.IsIPv6(spec) != iptMgr.ipv6 {
+				return nil, false
+			}
+		case i > 0 && specs[i-1] == util.IptablesProtFlag:
+			if (spec == util.IptablesIcmpProtocol && iptMgr.ipv6) || (spec == util.Ip6tablesIcmpProtocol && !iptMgr.ipv6) {
+				return nil, false
+			}
+		case i > 0 && specs[i-1] == util.IptablesMatchSetFlag && iptMgr.ipv6:
+			spec = util.GetIPv6SetName(spec)
+		}
+
+		familySpecs = append(familySpecs, spec)
+	}
+
+	return familySpecs, true
+}
+
+// getAuditSpecs returns the specs of a rule in audit mode, where rules dropping packets log them to the audit
+// NFLOG group instead, prefixed with the name of the set they would be dropped for.
+func getAuditSpecs(rule *IptEntry, specs []string) []string {
+	n := len(specs)
+	if !util.IsAuditModeEnabled || n < 2 || specs[n-2] != util.IptablesJumpFlag || specs[n-1] != util.IptablesDrop {
+		return specs
+	}
+
+	prefix := util.NpmAuditNflogPrefix + rule.Name
+	if len(prefix) > util.NflogPrefixMaxLength {
+		prefix = prefix[:util.NflogPrefixMaxLength]
+	}
+
+	return append(specs[:n-2:n-2],
+		util.IptablesJumpFlag,
+		util.IptablesNflog,
+		util.IptablesNflogGroupFlag,
+		strconv.Itoa(util.NpmAuditNflogGroup),
+		util.IptablesNflogPrefixFlag,
+		prefix,
+	)
+}
+
+// Run execute an iptables command to update iptables, or its nft counterpart with the nftables backend.
+// Rules that only apply to the other address family are skipped, which is reported as success.
 func (iptMgr *IptablesManager) Run(entry *IptEntry) (int, error) {
-	cmdName := util.Iptables
-	cmdArgs := append([]string{iptMgr.OperationFlag, entry.Chain}, entry.Specs...)
+	specs, ok := iptMgr.getFamilySpecs(entry.Specs)
+	if !ok {
+		return 0, nil
+	}
+
+	if backend.nftables {
+		return iptMgr.runNftEntry(iptMgr.OperationFlag, entry.Chain, specs)
+	}
+
+	cmdName := backend.command(util.Iptables)
+	if iptMgr.ipv6 {
+		cmdName = backend.command(util.Ip6tables)
+	}
+	cmdArgs := append([]string{iptMgr.OperationFlag, entry.Chain}, specs...)
 
 	cmdOut, err := exec.Command(cmdName, cmdArgs...).Output()
 	log.Printf("%s\n", string(cmdOut))
@@ -371,6 +1083,17 @@ func (iptMgr *IptablesManager) Run(entry *IptEntry) (int, error) {
 
 // Save saves current iptables configuration to /var/log/iptables.conf
 func (iptMgr *IptablesManager) Save(configFile string) error {
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.Save(getIp6tablesConfigFile(configFile)); err != nil {
+			return err
+		}
+	}
+
+	saveCmd := backend.command(util.IptablesSave)
+	if iptMgr.ipv6 {
+		saveCmd = backend.command(util.Ip6tablesSave)
+	}
+
 	if len(configFile) == 0 {
 		configFile = util.IptablesConfigFile
 	}
@@ -383,7 +1106,10 @@ func (iptMgr *IptablesManager) Save(configFile string) error {
 	}
 	defer f.Close()
 
-	cmd := exec.Command(util.IptablesSave)
+	cmd := exec.Command(saveCmd)
+	if backend.nftables {
+		cmd = exec.Command(util.Nft, "list", "table", iptMgr.getNftFamily(), util.NftTable)
+	}
 	cmd.Stdout = f
 	if err := cmd.Start(); err != nil {
 		log.Printf("Error running iptables-save.\n")
@@ -396,10 +1122,32 @@ func (iptMgr *IptablesManager) Save(configFile string) error {
 
 // Restore restores iptables configuration from /var/log/iptables.conf
 func (iptMgr *IptablesManager) Restore(configFile string) error {
+	if iptMgr.ip6tMgr != nil {
+		if err := iptMgr.ip6tMgr.Restore(getIp6tablesConfigFile(configFile)); err != nil {
+			return err
+		}
+	}
+
+	restoreCmd := backend.command(util.IptablesRestore)
+	if iptMgr.ipv6 {
+		restoreCmd = backend.command(util.Ip6tablesRestore)
+	}
+
 	if len(configFile) == 0 {
 		configFile = util.IptablesConfigFile
 	}
 
+	// The nftables table saved is loaded again in place of the current one.
+	if backend.nftables {
+		saved, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			log.Printf("Error reading file: %s.", configFile)
+			return err
+		}
+
+		return iptMgr.runNft("flush table " + iptMgr.getNftTable() + "\n" + string(saved))
+	}
+
 	// open the config file for reading
 	f, err := os.Open(configFile)
 	if err != nil {
@@ -408,7 +1156,7 @@ func (iptMgr *IptablesManager) Restore(configFile string) error {
 	}
 	defer f.Close()
 
-	cmd := exec.Command(util.IptablesRestore)
+	cmd := exec.Command(restoreCmd)
 	cmd.Stdin = f
 	if err := cmd.Start(); err != nil {
 		log.Printf("Error running iptables-restore.\n")
@@ -418,3 +1166,15 @@ func (iptMgr *IptablesManager) Restore(configFile string) error {
 
 	return nil
 }
+
+// getIp6tablesConfigFile returns the file the ip6tables configuration is saved to alongside the given iptables one.
+func getIp6tablesConfigFile(configFile string) string {
+	switch configFile {
+	case "", util.IptablesConfigFile:
+		return util.Ip6tablesConfigFile
+	case util.IptablesTestConfigFile:
+		return util.Ip6tablesTestConfigFile
+	default:
+		return configFile + ".ipv6"
+	}
+}
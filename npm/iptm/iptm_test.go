@@ -1,6 +1,8 @@
 package iptm
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -24,6 +26,46 @@ func TestRestore(t *testing.T) {
 	}
 }
 
+func TestGetFamilySpecs(t *testing.T) {
+	iptMgr := &IptablesManager{}
+	ip6tMgr := &IptablesManager{ipv6: true}
+
+	setSpecs := []string{util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-1", util.IptablesDstFlag}
+	if specs, ok := iptMgr.getFamilySpecs(setSpecs); !ok || specs[3] != "azure-npm-1" {
+		t.Errorf("TestGetFamilySpecs failed, unexpected IPv4 specs %+v", specs)
+	}
+
+	if specs, ok := ip6tMgr.getFamilySpecs(setSpecs); !ok || specs[3] != util.GetIPv6SetName("azure-npm-1") {
+		t.Errorf("TestGetFamilySpecs failed, unexpected IPv6 specs %+v", specs)
+	}
+
+	v4Only := [][]string{
+		{util.IptablesSFlag, "10.0.0.0/8"},
+		{util.IptablesProtFlag, util.IptablesIcmpProtocol},
+	}
+	for _, specs := range v4Only {
+		if _, ok := iptMgr.getFamilySpecs(specs); !ok {
+			t.Errorf("TestGetFamilySpecs failed, %+v skipped for IPv4", specs)
+		}
+		if _, ok := ip6tMgr.getFamilySpecs(specs); ok {
+			t.Errorf("TestGetFamilySpecs failed, %+v not skipped for IPv6", specs)
+		}
+	}
+
+	v6Only := [][]string{
+		{util.IptablesDFlag, "fd00::/64"},
+		{util.IptablesProtFlag, util.Ip6tablesIcmpProtocol},
+	}
+	for _, specs := range v6Only {
+		if _, ok := iptMgr.getFamilySpecs(specs); ok {
+			t.Errorf("TestGetFamilySpecs failed, %+v not skipped for IPv4", specs)
+		}
+		if _, ok := ip6tMgr.getFamilySpecs(specs); !ok {
+			t.Errorf("TestGetFamilySpecs failed, %+v skipped for IPv6", specs)
+		}
+	}
+}
+
 func TestInitNpmChains(t *testing.T) {
 	iptMgr := &IptablesManager{}
 
@@ -200,6 +242,466 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestNpmChainBatch(t *testing.T) {
+	iptMgr := &IptablesManager{}
+
+	drop := &IptEntry{
+		Chain: util.IptablesAzureTargetSetsChain,
+		Specs: []string{util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-1", util.IptablesDstFlag, util.IptablesJumpFlag, util.IptablesDrop},
+	}
+	accept := &IptEntry{
+		Chain: util.IptablesAzureTargetSetsChain,
+		Specs: []string{util.IptablesSFlag, "10.0.0.0/8", util.IptablesJumpFlag, util.IptablesAccept},
+	}
+
+	for _, entry := range []*IptEntry{drop, accept, drop} {
+		if err := iptMgr.Add(entry); err != nil {
+			t.Errorf("TestNpmChainBatch failed @ iptMgr.Add")
+		}
+	}
+
+	if chains := iptMgr.GetDirtyChains(); len(chains) != 1 || chains[0] != util.IptablesAzureTargetSetsChain {
+		t.Errorf("TestNpmChainBatch failed, unexpected dirty chains %+v", chains)
+	}
+
+	// Rules are inserted at the top of the chain, duplicates are ignored.
+	expected := "-A AZURE-NPM-TARGET-SETS -s 10.0.0.0/8 -j ACCEPT\n" +
+		"-A AZURE-NPM-TARGET-SETS -m set --match-set azure-npm-1 dst -j DROP\n"
+	if rendered := iptMgr.renderChain(util.IptablesAzureTargetSetsChain); rendered != expected {
+		t.Errorf("TestNpmChainBatch failed, unexpected rules:\n%s", rendered)
+	}
+
+	if err := iptMgr.Delete(accept); err != nil {
+		t.Errorf("TestNpmChainBatch failed @ iptMgr.Delete")
+	}
+
+	expected = "-A AZURE-NPM-TARGET-SETS -m set --match-set azure-npm-1 dst -j DROP\n"
+	if rendered := iptMgr.renderChain(util.IptablesAzureTargetSetsChain); rendered != expected {
+		t.Errorf("TestNpmChainBatch failed, unexpected rules after delete:\n%s", rendered)
+	}
+
+	// A chain applied with the same rules is no longer dirty and isn't rewritten.
+	iptMgr.appliedChains[util.IptablesAzureTargetSetsChain] = expected
+	if err := iptMgr.Apply(); err != nil {
+		t.Errorf("TestNpmChainBatch failed @ iptMgr.Apply")
+	}
+
+	if chains := iptMgr.GetDirtyChains(); len(chains) != 0 {
+		t.Errorf("TestNpmChainBatch failed, unchanged chains still dirty %+v", chains)
+	}
+}
+
+func TestShadowChains(t *testing.T) {
+	iptMgr := &IptablesManager{}
+	iptMgr.StartBootstrap()
+
+	jump := &IptEntry{
+		Chain: util.IptablesAzureChain,
+		Specs: []string{util.IptablesJumpFlag, util.IptablesAzureTargetSetsChain},
+	}
+	if err := iptMgr.Add(jump); err != nil {
+		t.Errorf("TestShadowChains failed @ iptMgr.Add")
+	}
+
+	allow := &IptEntry{
+		Chain: util.IptablesAzureIngressPortChain,
+		Specs: []string{
+			util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-123", util.IptablesDstFlag,
+			util.IptablesJumpFlag, util.IptablesAccept,
+		},
+	}
+	if err := iptMgr.Add(allow); err != nil {
+		t.Errorf("TestShadowChains failed @ iptMgr.Add")
+	}
+
+	// The chain and the AZURE-NPM chains it jumps to are renamed, the rules in memory and their other specs are not.
+	expected := "-A AZURE-NPM-NEXT -j AZURE-NPM-TARGET-SETS-NEXT\n"
+	if rendered := iptMgr.renderChain(util.IptablesAzureChain); rendered != expected {
+		t.Errorf("TestShadowChains failed, unexpected rules:\n%s", rendered)
+	}
+
+	expected = "-A AZURE-NPM-INGRESS-PORT-NEXT -m set --match-set azure-npm-123 dst -j ACCEPT\n"
+	if rendered := iptMgr.renderChain(util.IptablesAzureIngressPortChain); rendered != expected {
+		t.Errorf("TestShadowChains failed, unexpected rules:\n%s", rendered)
+	}
+
+	if chains := iptMgr.GetDirtyChains(); len(chains) != 2 || chains[0] != util.IptablesAzureChain {
+		t.Errorf("TestShadowChains failed, unexpected dirty chains %+v", chains)
+	}
+
+	for _, chain := range npmChains {
+		if shadow := getShadowChain(chain); len(shadow) > 28 {
+			t.Errorf("TestShadowChains failed, shadow chain name %s is too long", shadow)
+		}
+	}
+
+	// Once the shadow chains are renamed, the applied rules are those of the chains under their final names.
+	iptMgr.appliedChains = make(map[string]string)
+	for _, chain := range iptMgr.GetDirtyChains() {
+		iptMgr.appliedChains[chain] = iptMgr.renderChain(chain)
+		delete(iptMgr.dirtyChains, chain)
+	}
+	iptMgr.finishShadow()
+
+	expected = "-A AZURE-NPM -j AZURE-NPM-TARGET-SETS\n"
+	if rendered := iptMgr.renderChain(util.IptablesAzureChain); rendered != expected || iptMgr.appliedChains[util.IptablesAzureChain] != expected {
+		t.Errorf("TestShadowChains failed, unexpected rules after bootstrap:\n%s", rendered)
+	}
+
+	expected = "-A AZURE-NPM-INGRESS-PORT -m set --match-set azure-npm-123 dst -j ACCEPT\n"
+	if applied := iptMgr.appliedChains[util.IptablesAzureIngressPortChain]; applied != expected {
+		t.Errorf("TestShadowChains failed, unexpected applied rules after bootstrap:\n%s", applied)
+	}
+
+	if chains := iptMgr.GetDirtyChains(); len(chains) != 0 {
+		t.Errorf("TestShadowChains failed, chains dirty after bootstrap %+v", chains)
+	}
+}
+
+func TestGetSwapCommands(t *testing.T) {
+	iptMgr := &IptablesManager{
+		appliedChains: map[string]string{
+			util.IptablesAzureChain:           "-A AZURE-NPM -j AZURE-NPM-TARGET-SETS\n",
+			util.IptablesAzureTargetSetsChain: "",
+		},
+	}
+
+	// The previous instance left its chains, and an interrupted bootstrap left a shadow chain.
+	counts := map[string]int{
+		util.IptablesForwardChain:                         3,
+		util.IptablesAzureChain:                           1,
+		util.IptablesAzureTargetSetsChain:                 2,
+		"AZURE-NPM-INGRESS-FROM-NEXT":                     1,
+		getShadowChain(util.IptablesAzureChain):           1,
+		getShadowChain(util.IptablesAzureTargetSetsChain): 0,
+		"KUBE-FORWARD":                                    4,
+	}
+
+	expected := []string{
+		"-I FORWARD 1 -j AZURE-NPM-NEXT\n",
+		"-D FORWARD -j AZURE-NPM\n",
+		"-F AZURE-NPM\n",
+		"-F AZURE-NPM-INGRESS-FROM-NEXT\n",
+		"-F AZURE-NPM-TARGET-SETS\n",
+		"-X AZURE-NPM\n",
+		"-X AZURE-NPM-INGRESS-FROM-NEXT\n",
+		"-X AZURE-NPM-TARGET-SETS\n",
+		"-E AZURE-NPM-NEXT AZURE-NPM\n",
+		"-E AZURE-NPM-TARGET-SETS-NEXT AZURE-NPM-TARGET-SETS\n",
+	}
+	if commands := iptMgr.getSwapCommands(counts, true); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("TestGetSwapCommands failed, unexpected commands %q", commands)
+	}
+
+	// Without a previous instance, the shadow chains are only renamed.
+	counts = map[string]int{
+		util.IptablesForwardChain:                         1,
+		getShadowChain(util.IptablesAzureChain):           1,
+		getShadowChain(util.IptablesAzureTargetSetsChain): 0,
+	}
+	expected = []string{
+		"-I FORWARD 1 -j AZURE-NPM-NEXT\n",
+		"-E AZURE-NPM-NEXT AZURE-NPM\n",
+		"-E AZURE-NPM-TARGET-SETS-NEXT AZURE-NPM-TARGET-SETS\n",
+	}
+	if commands := iptMgr.getSwapCommands(counts, false); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("TestGetSwapCommands failed without previous chains, unexpected commands %q", commands)
+	}
+}
+
+func TestPeerChains(t *testing.T) {
+	iptMgr := &IptablesManager{}
+
+	peerChain := util.IptablesAzurePeerChainPrefix + "00000000001"
+	jump := &IptEntry{
+		Chain: util.IptablesAzureIngressFromChain,
+		Specs: []string{util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-1", util.IptablesDstFlag, util.IptablesJumpFlag, peerChain},
+	}
+	accept := &IptEntry{
+		Chain: peerChain,
+		Specs: []string{util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-2", util.IptablesSrcFlag, util.IptablesJumpFlag, util.IptablesAccept},
+	}
+
+	for _, entry := range []*IptEntry{jump, accept} {
+		if err := iptMgr.Add(entry); err != nil {
+			t.Errorf("TestPeerChains failed @ iptMgr.Add")
+		}
+	}
+
+	// Peer chains follow the AZURE-NPM chains.
+	chains := iptMgr.getChains()
+	if len(chains) != len(npmChains)+1 || chains[len(chains)-1] != peerChain {
+		t.Errorf("TestPeerChains failed, unexpected chains %+v", chains)
+	}
+
+	// Peer chains removed before they are applied are forgotten without being programmed.
+	iptMgr.dirtyChains = map[string]bool{peerChain: true}
+	if err := iptMgr.Delete(accept); err != nil {
+		t.Errorf("TestPeerChains failed @ iptMgr.Delete")
+	}
+
+	if err := iptMgr.Apply(); err != nil {
+		t.Errorf("TestPeerChains failed @ iptMgr.Apply")
+	}
+
+	if _, exists := iptMgr.chainMap[peerChain]; exists || len(iptMgr.GetDirtyChains()) != 0 {
+		t.Errorf("TestPeerChains failed, removed peer chain %s is still in memory", peerChain)
+	}
+}
+
+func TestGetSavedPeerChains(t *testing.T) {
+	out := `*filter
+:FORWARD ACCEPT [0:0]
+:AZURE-NPM - [0:0]
+:AZURE-NPM-P-00000000001 - [0:0]
+:AZURE-NPM-P-00000000002-NEXT - [0:0]
+-A AZURE-NPM-P-00000000001 -m set --match-set azure-npm-2 src -j ACCEPT
+COMMIT
+`
+	counts, _ := parseIptablesSave(out)
+
+	if chains := getSavedPeerChains(counts, false); !reflect.DeepEqual(chains, []string{"AZURE-NPM-P-00000000001"}) {
+		t.Errorf("TestGetSavedPeerChains failed, unexpected peer chains %+v", chains)
+	}
+
+	if chains := getSavedPeerChains(counts, true); !reflect.DeepEqual(chains, []string{"AZURE-NPM-P-00000000002"}) {
+		t.Errorf("TestGetSavedPeerChains failed, unexpected shadow peer chains %+v", chains)
+	}
+}
+
+func TestAuditMode(t *testing.T) {
+	iptMgr := &IptablesManager{}
+
+	drop := &IptEntry{
+		Name:  "all-namespace-app:web",
+		Chain: util.IptablesAzureTargetSetsChain,
+		Specs: []string{util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-1", util.IptablesDstFlag, util.IptablesJumpFlag, util.IptablesDrop},
+	}
+	if err := iptMgr.Add(drop); err != nil {
+		t.Errorf("TestAuditMode failed @ iptMgr.Add")
+	}
+
+	util.IsAuditModeEnabled = true
+	defer func() { util.IsAuditModeEnabled = false }()
+
+	expected := "-A AZURE-NPM-TARGET-SETS -m set --match-set azure-npm-1 dst -j NFLOG --nflog-group 100 --nflog-prefix azure-npm-audit:all-namespace-app:web\n"
+	if rendered := iptMgr.renderChain(util.IptablesAzureTargetSetsChain); rendered != expected {
+		t.Errorf("TestAuditMode failed, unexpected rules:\n%s", rendered)
+	}
+
+	// The entry itself still drops, so that the rule is found when it is deleted.
+	if drop.Specs[len(drop.Specs)-1] != util.IptablesDrop {
+		t.Errorf("TestAuditMode failed, entry specs changed %+v", drop.Specs)
+	}
+
+	long := &IptEntry{Name: strings.Repeat("a", 100), Specs: []string{util.IptablesJumpFlag, util.IptablesDrop}}
+	if specs := getAuditSpecs(long, long.Specs); len(specs[len(specs)-1]) != util.NflogPrefixMaxLength {
+		t.Errorf("TestAuditMode failed, prefix not truncated %+v", specs)
+	}
+}
+
+func TestRepairDrift(t *testing.T) {
+	iptMgr := NewIptablesManager()
+	iptMgr.initChains()
+	iptMgr.appliedChains[util.IptablesAzureChain] = "-A AZURE-NPM -j ACCEPT\n-A AZURE-NPM -j DROP\n"
+	iptMgr.appliedChains[util.IptablesAzureIngressPortChain] = "-A AZURE-NPM-INGRESS-PORT -j ACCEPT\n"
+	iptMgr.appliedChains[util.IptablesAzureTargetSetsChain] = ""
+
+	// AZURE-NPM was flushed, AZURE-NPM-TARGET-SETS was deleted and FORWARD doesn't jump to AZURE-NPM.
+	save := "*filter\n" +
+		":FORWARD ACCEPT [0:0]\n" +
+		":AZURE-NPM - [0:0]\n" +
+		":AZURE-NPM-INGRESS-PORT - [0:0]\n" +
+		"-A AZURE-NPM-INGRESS-PORT -j ACCEPT\n" +
+		"COMMIT\n"
+
+	counts, hasJump := parseIptablesSave(save)
+	if hasJump {
+		t.Errorf("TestRepairDrift failed @ parseIptablesSave, unexpected jump to AZURE-NPM")
+	}
+
+	if drift := iptMgr.repairDrift(counts); drift != 3 {
+		t.Errorf("TestRepairDrift failed, unexpected drift %d", drift)
+	}
+
+	dirty := iptMgr.GetDirtyChains()
+	if !reflect.DeepEqual(dirty, []string{util.IptablesAzureChain, util.IptablesAzureTargetSetsChain}) {
+		t.Errorf("TestRepairDrift failed, unexpected dirty chains %v", dirty)
+	}
+
+	if _, hasJump := parseIptablesSave("*filter\n-A FORWARD -j AZURE-NPM\nCOMMIT\n"); !hasJump {
+		t.Errorf("TestRepairDrift failed @ parseIptablesSave, jump to AZURE-NPM not found")
+	}
+}
+
+func TestBackendCommand(t *testing.T) {
+	commands := map[string]string{
+		util.Iptables:         "iptables-nft",
+		util.IptablesSave:     "iptables-nft-save",
+		util.Ip6tablesRestore: "ip6tables-nft-restore",
+	}
+
+	for cmd, expected := range commands {
+		if nftCmd := nftBackend.command(cmd); nftCmd != expected {
+			t.Errorf("TestBackendCommand failed, expected %s for %s, got %s", expected, cmd, nftCmd)
+		}
+
+		if defaultCmd := defaultBackend.command(cmd); defaultCmd != cmd {
+			t.Errorf("TestBackendCommand failed, expected %s for %s, got %s", cmd, cmd, defaultCmd)
+		}
+	}
+
+	if err := SetBackend("unknown"); err == nil {
+		t.Errorf("TestBackendCommand failed, unknown backend accepted")
+	}
+
+	if err := SetBackend(util.IptablesBackendLegacy); err != nil || GetBackend() != legacyBackend {
+		t.Errorf("TestBackendCommand failed @ SetBackend")
+	}
+
+	backend = defaultBackend
+}
+
+func TestGetNftRule(t *testing.T) {
+	iptMgr := &IptablesManager{}
+	ip6tMgr := &IptablesManager{ipv6: true}
+
+	tests := []struct {
+		iptMgr   *IptablesManager
+		specs    []string
+		expected string
+	}{
+		{
+			iptMgr:   iptMgr,
+			specs:    []string{util.IptablesMatchFlag, util.IptablesStateFlag, util.IPtablesMatchStateFlag, "RELATED,ESTABLISHED", util.IptablesJumpFlag, util.IptablesAccept},
+			expected: "ct state related,established accept",
+		},
+		{
+			iptMgr:   iptMgr,
+			specs:    []string{util.IptablesProtFlag, "TCP", util.IptablesDstPortFlag, "8000:8080", util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-1", util.IptablesSrcFlag, util.IptablesJumpFlag, "AZURE-NPM-P-1"},
+			expected: "meta l4proto tcp th dport 8000-8080 ip saddr @azure-npm-1 jump AZURE-NPM-P-1",
+		},
+		{
+			iptMgr:   ip6tMgr,
+			specs:    []string{util.IptablesProtFlag, "TCP", util.IptablesMatchFlag, util.IptablesSetFlag, util.IptablesMatchSetFlag, "azure-npm-2-v6", "dst,dst", util.IptablesJumpFlag, util.IptablesAccept},
+			expected: "meta l4proto tcp ip6 daddr . meta l4proto . th dport @azure-npm-2-v6 accept",
+		},
+		{
+			iptMgr:   ip6tMgr,
+			specs:    []string{util.IptablesProtFlag, util.Ip6tablesIcmpProtocol, util.Ip6tablesIcmpTypeFlag, "128/0", util.IptablesDFlag, "fd00::/64", util.IptablesJumpFlag, util.IptablesDrop},
+			expected: "meta l4proto ipv6-icmp icmpv6 type 128 icmpv6 code 0 ip6 daddr fd00::/64 drop",
+		},
+		{
+			iptMgr:   iptMgr,
+			specs:    []string{util.IptablesSFlag, "10.0.0.0/8", util.IptablesJumpFlag, util.IptablesNflog, util.IptablesNflogGroupFlag, "100", util.IptablesNflogPrefixFlag, "azure-npm-audit:app"},
+			expected: "ip saddr 10.0.0.0/8 log prefix \"azure-npm-audit:app\" group 100",
+		},
+		{
+			iptMgr:   iptMgr,
+			specs:    []string{util.IptablesProtFlag, "udp", util.IptablesSrcPortFlag, util.DNSPort, util.IptablesJumpFlag, util.IptablesNflog, util.IptablesNflogGroupFlag, "101"},
+			expected: "meta l4proto udp th sport 53 log group 101",
+		},
+	}
+
+	for _, test := range tests {
+		rule, err := test.iptMgr.getNftRule(test.specs)
+		if err != nil || rule != test.expected {
+			t.Errorf("TestGetNftRule failed @ %v: %q, %v, expected %q", test.specs, rule, err, test.expected)
+		}
+	}
+
+	invalid := [][]string{
+		{util.IptablesMatchFlag, "comment"},
+		{util.IptablesMatchSetFlag, "azure-npm-1"},
+		{"--tcp-flags", "SYN"},
+		{util.IptablesJumpFlag},
+	}
+	for _, specs := range invalid {
+		if rule, err := iptMgr.getNftRule(specs); err == nil {
+			t.Errorf("TestGetNftRule failed @ %v, unsupported specs translated to %q", specs, rule)
+		}
+	}
+}
+
+func TestParseNftSave(t *testing.T) {
+	out := `table ip filter { # handle 1
+	chain FORWARD { # handle 1
+		type filter hook forward priority filter; policy accept;
+		jump AZURE-NPM # handle 4
+	}
+}
+table ip azure-npm { # handle 2
+	set azure-npm-1 { # handle 3
+		type ipv4_addr
+		flags interval
+		elements = { 10.0.0.4, 10.0.0.5,
+			     10.0.0.6 }
+	}
+
+	chain FORWARD { # handle 1
+		type filter hook forward priority filter; policy accept;
+		jump AZURE-NPM # handle 7
+	}
+
+	chain AZURE-NPM { # handle 2
+		ct state established,related accept # handle 5
+		ip daddr @azure-npm-1 accept # handle 6
+	}
+
+	chain AZURE-NPM-TARGET-SETS { # handle 4
+	}
+}
+`
+
+	counts, hasJump := parseNftSave(out)
+	expected := map[string]int{
+		util.IptablesForwardChain:         1,
+		util.IptablesAzureChain:           2,
+		util.IptablesAzureTargetSetsChain: 0,
+	}
+	if !reflect.DeepEqual(counts, expected) || !hasJump {
+		t.Errorf("TestParseNftSave failed, unexpected counts %v, jump %t", counts, hasJump)
+	}
+
+	rules := parseNftList(out)[util.IptablesAzureChain]
+	if len(rules) != 2 || rules[1].expr != "ip daddr @azure-npm-1 accept" || rules[1].handle != "6" {
+		t.Errorf("TestParseNftSave failed, unexpected rules %+v", rules)
+	}
+
+	// The jump of the FORWARD chain of another table doesn't count.
+	if _, hasJump := parseNftSave(strings.Replace(out, "jump AZURE-NPM # handle 7", "", 1)); hasJump {
+		t.Errorf("TestParseNftSave failed, jump of another table found")
+	}
+}
+
+func TestGetNftSwapCommands(t *testing.T) {
+	defer func(b *Backend) { backend = b }(backend)
+	backend = nftablesBackend
+
+	iptMgr := &IptablesManager{
+		appliedChains: map[string]string{
+			util.IptablesAzureChain: "add rule ip azure-npm AZURE-NPM-NEXT jump AZURE-NPM-TARGET-SETS-NEXT\n",
+		},
+	}
+
+	counts := map[string]int{
+		util.IptablesForwardChain:               1,
+		util.IptablesAzureChain:                 1,
+		getShadowChain(util.IptablesAzureChain): 1,
+	}
+
+	expected := []string{
+		"flush chain ip azure-npm FORWARD\n",
+		"insert rule ip azure-npm FORWARD jump AZURE-NPM-NEXT\n",
+		"flush chain ip azure-npm AZURE-NPM\n",
+		"delete chain ip azure-npm AZURE-NPM\n",
+		"rename chain ip azure-npm AZURE-NPM-NEXT AZURE-NPM\n",
+	}
+	if commands := iptMgr.getSwapCommands(counts, true); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("TestGetNftSwapCommands failed, unexpected commands %q", commands)
+	}
+}
+
 func TestMain(m *testing.M) {
 	iptMgr := NewIptablesManager()
 	iptMgr.Save(util.IptablesConfigFile)
@@ -0,0 +1,367 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package iptm
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// With the nftables backend, the AZURE-NPM chains live in the azure-npm table of the ip and ip6 families, next to
+// the sets of ipsm. The FORWARD chain of the table is a base chain hooked to forwarded packets, which only jumps to
+// the AZURE-NPM chain. Its policy accepts packets, as the drops of the AZURE-NPM chains are final.
+
+// nftHandleComment separates a rule from its handle in the output of nft -a.
+const nftHandleComment = " # handle "
+
+// nftRule is a rule of a chain of the azure-npm table, as listed by nft -a.
+type nftRule struct {
+	expr   string
+	handle string
+}
+
+// getNftFamily returns the nftables family of the manager's address family.
+func (iptMgr *IptablesManager) getNftFamily() string {
+	if iptMgr.ipv6 {
+		return util.NftIPv6Family
+	}
+
+	return util.NftIPv4Family
+}
+
+// getNftTable returns the azure-npm table of the manager's address family, as nft commands refer to it.
+func (iptMgr *IptablesManager) getNftTable() string {
+	return iptMgr.getNftFamily() + " " + util.NftTable
+}
+
+// getNftTableCommands returns the nft commands creating the azure-npm table and its FORWARD base chain if they don't
+// exist, so that every nft transaction of the manager finds them.
+func (iptMgr *IptablesManager) getNftTableCommands() string {
+	return "add table " + iptMgr.getNftTable() + "\n" +
+		"add chain " + iptMgr.getNftTable() + " " + util.IptablesForwardChain + " " + util.NftForwardChain + "\n"
+}
+
+// getNftRule translates the iptables specs of a rule to an nftables rule.
+// Ipsets are matched with the nftables sets of the same name, which ipsm programs in the same table.
+func (iptMgr *IptablesManager) getNftRule(specs []string) (string, error) {
+	var (
+		exprs       []string
+		nflogGroup  string
+		nflogPrefix string
+		nflog       bool
+	)
+
+	addr := util.NftIPv4Family
+	if iptMgr.ipv6 {
+		addr = util.NftIPv6Family
+	}
+
+	for i := 0; i < len(specs); i++ {
+		spec := specs[i]
+		if i+1 >= len(specs) {
+			return "", fmt.Errorf("Missing value of %s in %v", spec, specs)
+		}
+		value := specs[i+1]
+		i++
+
+		switch spec {
+		case util.IptablesProtFlag:
+			protocol := strings.ToLower(value)
+			if protocol == util.Ip6tablesIcmpProtocol {
+				protocol = util.NftIcmpv6Proto
+			}
+			exprs = append(exprs, "meta l4proto "+protocol)
+		case util.IptablesDstPortFlag:
+			exprs = append(exprs, "th dport "+strings.Replace(value, ":", "-", 1))
+		case util.IptablesSrcPortFlag:
+			exprs = append(exprs, "th sport "+strings.Replace(value, ":", "-", 1))
+		case util.IptablesIcmpTypeFlag, util.Ip6tablesIcmpTypeFlag:
+			icmp := util.IptablesIcmpProtocol
+			if spec == util.Ip6tablesIcmpTypeFlag {
+				icmp = util.Ip6tablesIcmpProtocol
+			}
+			typeCode := strings.SplitN(value, "/", 2)
+			exprs = append(exprs, icmp+" type "+typeCode[0])
+			if len(typeCode) == 2 {
+				exprs = append(exprs, icmp+" code "+typeCode[1])
+			}
+		case util.IptablesSFlag:
+			exprs = append(exprs, addr+" saddr "+value)
+		case util.IptablesDFlag:
+			exprs = append(exprs, addr+" daddr "+value)
+		case util.IptablesMatchFlag:
+			if value != util.IptablesSetFlag && value != util.IptablesStateFlag {
+				return "", fmt.Errorf("Unsupported match %s in %v", value, specs)
+			}
+		case util.IptablesMatchSetFlag:
+			if i+1 >= len(specs) {
+				return "", fmt.Errorf("Missing direction of set %s in %v", value, specs)
+			}
+			i++
+			expr, err := getNftSetMatch(addr, value, specs[i])
+			if err != nil {
+				return "", err
+			}
+			exprs = append(exprs, expr)
+		case util.IPtablesMatchStateFlag:
+			exprs = append(exprs, "ct state "+strings.ToLower(value))
+		case util.IptablesNflogGroupFlag:
+			nflogGroup = value
+		case util.IptablesNflogPrefixFlag:
+			nflogPrefix = value
+		case util.IptablesJumpFlag:
+			switch value {
+			case util.IptablesAccept:
+				exprs = append(exprs, "accept")
+			case util.IptablesDrop:
+				exprs = append(exprs, "drop")
+			case util.IptablesReject:
+				exprs = append(exprs, "reject")
+			case util.IptablesNflog:
+				nflog = true
+			default:
+				exprs = append(exprs, "jump "+value)
+			}
+		default:
+			return "", fmt.Errorf("Unsupported spec %s in %v", spec, specs)
+		}
+	}
+
+	// Like the NFLOG target, the log statement doesn't end the evaluation of the chain.
+	if nflog {
+		expr := "log"
+		if nflogPrefix != "" {
+			expr += " prefix " + strconv.Quote(nflogPrefix)
+		}
+		if nflogGroup != "" {
+			expr += " group " + nflogGroup
+		}
+		exprs = append(exprs, expr)
+	}
+
+	return strings.Join(exprs, " "), nil
+}
+
+// getNftSetMatch returns the nftables match of the set of an iptables set match, given the directions of its
+// dimensions, e.g. dst for the sets of addresses or dst,dst for those of the addresses and ports of named ports.
+func getNftSetMatch(addr string, set string, directions string) (string, error) {
+	dims := strings.Split(directions, ",")
+	var keys []string
+	for i, dir := range dims {
+		var key string
+		switch {
+		case i == 0 && dir == util.IptablesSrcFlag:
+			key = addr + " saddr"
+		case i == 0 && dir == util.IptablesDstFlag:
+			key = addr + " daddr"
+		case i == 1 && dir == util.IptablesSrcFlag:
+			key = "meta l4proto . th sport"
+		case i == 1 && dir == util.IptablesDstFlag:
+			key = "meta l4proto . th dport"
+		default:
+			return "", fmt.Errorf("Unsupported direction %s of set %s", directions, set)
+		}
+		keys = append(keys, key)
+	}
+
+	return strings.Join(keys, " . ") + " @" + set, nil
+}
+
+// getNftCommand returns the nft command of an iptables-restore command, see getRestoreRule.
+func (iptMgr *IptablesManager) getNftCommand(flag string, chain string, specs ...string) (string, error) {
+	table := iptMgr.getNftTable()
+
+	switch flag {
+	case util.IptablesFlushFlag:
+		return "flush chain " + table + " " + chain + "\n", nil
+	case util.IptablesDestroyFlag:
+		return "delete chain " + table + " " + chain + "\n", nil
+	case util.IptablesRenameChainFlag:
+		return "rename chain " + table + " " + chain + " " + specs[0] + "\n", nil
+	case util.IptablesInsertionFlag, util.IptablesAppendFlag:
+		op := "add"
+		if flag == util.IptablesInsertionFlag {
+			op = "insert"
+			// Rules are inserted at the top of the chain, whatever their position.
+			if len(specs) > 0 {
+				if _, err := strconv.Atoi(specs[0]); err == nil {
+					specs = specs[1:]
+				}
+			}
+		}
+
+		rule, err := iptMgr.getNftRule(specs)
+		if err != nil {
+			return "", err
+		}
+
+		return op + " rule " + table + " " + chain + " " + rule + "\n", nil
+	}
+
+	return "", fmt.Errorf("Unsupported nftables command %s", flag)
+}
+
+// parseNftList returns the rules of each chain of the azure-npm table in the output of nft -a list, without their
+// handles. The sets of the table are skipped.
+func parseNftList(out string) map[string][]nftRule {
+	var (
+		inTable bool
+		chain   string
+		depth   int
+	)
+	chains := make(map[string][]nftRule)
+
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case depth == 0 && len(fields) >= 3 && fields[0] == "table":
+			inTable = fields[2] == util.NftTable
+		case inTable && depth == 1 && fields[0] == "chain" && len(fields) >= 2:
+			chain = fields[1]
+			chains[chain] = nil
+		case inTable && depth == 2 && chain != "" && !strings.HasPrefix(line, "type ") && line != "}":
+			rule := nftRule{expr: line}
+			if i := strings.Index(line, nftHandleComment); i >= 0 {
+				rule = nftRule{expr: line[:i], handle: line[i+len(nftHandleComment):]}
+			}
+			chains[chain] = append(chains[chain], rule)
+		}
+
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 1 {
+			chain = ""
+		}
+	}
+
+	return chains
+}
+
+// parseNftSave returns the number of rules of each chain of the azure-npm table in the output of nft -a list,
+// and whether the FORWARD chain jumps to the AZURE-NPM chain, like parseIptablesSave.
+func parseNftSave(out string) (map[string]int, bool) {
+	hasJump := false
+	counts := make(map[string]int)
+
+	for chain, rules := range parseNftList(out) {
+		counts[chain] = len(rules)
+		if chain != util.IptablesForwardChain {
+			continue
+		}
+
+		for _, rule := range rules {
+			if rule.expr == "jump "+util.IptablesAzureChain {
+				hasJump = true
+			}
+		}
+	}
+
+	return counts, hasJump
+}
+
+// runNft runs an nft transaction on the azure-npm table of the manager's family, creating the table if needed.
+func (iptMgr *IptablesManager) runNft(commands string) error {
+	var input bytes.Buffer
+	input.WriteString(iptMgr.getNftTableCommands())
+	input.WriteString(commands)
+
+	cmd := exec.Command(util.Nft, util.NftFileFlag, util.NftStdin)
+	cmd.Stdin = &input
+	if cmdOut, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Error running nft: %v\n%s\nInput:\n%s", err, string(cmdOut), input.String())
+		return err
+	}
+
+	return nil
+}
+
+// listNft returns the output of nft -a list of the manager's family.
+func (iptMgr *IptablesManager) listNft() (string, error) {
+	out, err := exec.Command(util.Nft, util.NftHandleFlag, "list", "ruleset", iptMgr.getNftFamily()).Output()
+	if err != nil {
+		log.Printf("Error listing nftables ruleset: %v\n", err)
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// runNftEntry runs the nft counterpart of an iptables command on a chain of the azure-npm table.
+// Like iptables, it returns 1 when the chain to create already exists, or when the chain or rule it refers to
+// doesn't exist.
+func (iptMgr *IptablesManager) runNftEntry(flag string, chain string, specs []string) (int, error) {
+	out, err := iptMgr.listNft()
+	if err != nil {
+		return 2, err
+	}
+
+	rules, exists := parseNftList(out)[chain]
+
+	switch flag {
+	case util.IptablesChainCreationFlag:
+		if exists {
+			return 1, fmt.Errorf("Chain %s already exists", chain)
+		}
+		return getNftErrCode(iptMgr.runNft("add chain " + iptMgr.getNftTable() + " " + chain + "\n"))
+	case util.IptablesDestroyFlag, util.IptablesFlushFlag:
+		if !exists {
+			return 1, fmt.Errorf("Chain %s doesn't exist", chain)
+		}
+		command, _ := iptMgr.getNftCommand(flag, chain)
+		return getNftErrCode(iptMgr.runNft(command))
+	case util.IptablesInsertionFlag, util.IptablesAppendFlag:
+		command, err := iptMgr.getNftCommand(flag, chain, specs...)
+		if err != nil {
+			return 2, err
+		}
+		return getNftErrCode(iptMgr.runNft(command))
+	case util.IptablesCheckFlag, util.IptablesDeletionFlag:
+		expr, err := iptMgr.getNftRule(specs)
+		if err != nil {
+			return 2, err
+		}
+
+		for _, rule := range rules {
+			if rule.expr != expr {
+				continue
+			}
+
+			if flag == util.IptablesCheckFlag {
+				return 0, nil
+			}
+
+			return getNftErrCode(iptMgr.runNft("delete rule " + iptMgr.getNftTable() + " " + chain + " handle " + rule.handle + "\n"))
+		}
+
+		return 1, fmt.Errorf("Rule %s not found in chain %s", expr, chain)
+	}
+
+	return 2, fmt.Errorf("Unsupported nftables command %s", flag)
+}
+
+// getNftErrCode returns the exit code of a failed nft transaction, which is above 1 so that it is not mistaken for
+// a missing chain or rule.
+func getNftErrCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+
+	if msg, failed := err.(*exec.ExitError); failed {
+		if errCode := msg.Sys().(syscall.WaitStatus).ExitStatus(); errCode > 1 {
+			return errCode, err
+		}
+	}
+
+	return 2, err
+}
@@ -18,6 +18,7 @@ type namespace struct {
 	setMap map[string]string
 	podMap map[types.UID]*corev1.Pod
 	npMap  map[string]*networkingv1.NetworkPolicy
+	npRefs *policyRefs
 	ipsMgr *ipsm.IpsetManager
 	iptMgr *iptm.IptablesManager
 }
@@ -29,6 +30,7 @@ func newNs(name string) (*namespace, error) {
 		setMap: make(map[string]string),
 		podMap: make(map[types.UID]*corev1.Pod),
 		npMap:  make(map[string]*networkingv1.NetworkPolicy),
+		npRefs: newPolicyRefs(),
 		ipsMgr: ipsm.NewIpsetManager(),
 		iptMgr: iptm.NewIptablesManager(),
 	}
@@ -94,6 +96,10 @@ func (npMgr *NetworkPolicyManager) AddNamespace(nsObj *corev1.Namespace) error {
 	nsName, nsNs := nsObj.ObjectMeta.Name, nsObj.ObjectMeta.Namespace
 	log.Printf("NAMESPACE CREATING: %s/%s\n", nsName, nsNs)
 
+	defer func() {
+		npMgr.recordReconcile(nsName, err)
+	}()
+
 	ipsMgr := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr
 	// Create ipset for the namespace.
 	if err = ipsMgr.CreateSet(nsName); err != nil {
@@ -119,6 +125,23 @@ func (npMgr *NetworkPolicyManager) AddNamespace(nsObj *corev1.Namespace) error {
 		labelKeys = append(labelKeys, labelKey)
 	}
 
+	// Add the namespace to the lists of the namespace selectors with matchExpressions it matches.
+	if err = npMgr.addNsToSelectorLists(nsName, nsLabels); err != nil {
+		log.Printf("Error adding namespace %s to namespace selector ipset lists\n", nsName)
+		return err
+	}
+
+	if err = ipsMgr.Apply(); err != nil {
+		log.Printf("Error applying ipsets for namespace %s.\n", nsName)
+		return err
+	}
+
+	// Remove or program again the pods and policies of the namespace if its enforcement label changed.
+	if err = npMgr.setNsEnforcement(nsName, !isEnforcementDisabled(nsObj)); err != nil {
+		log.Printf("Error changing enforcement of namespace %s\n", nsName)
+		return err
+	}
+
 	ns, err := newNs(nsName)
 	if err != nil {
 		log.Printf("Error creating namespace %s\n", nsName)
@@ -174,6 +197,15 @@ func (npMgr *NetworkPolicyManager) DeleteNamespace(nsObj *corev1.Namespace) erro
 	nsName, nsNs := nsObj.ObjectMeta.Name, nsObj.ObjectMeta.Namespace
 	log.Printf("NAMESPACE DELETING: %s/%s\n", nsName, nsNs)
 
+	defer func() {
+		// Stop reporting namespaces that are gone.
+		if err == nil {
+			delete(npMgr.reconcileMap, nsName)
+			return
+		}
+		npMgr.recordReconcile(nsName, err)
+	}()
+
 	_, exists := npMgr.nsMap[nsName]
 	if !exists {
 		return nil
@@ -193,6 +225,11 @@ func (npMgr *NetworkPolicyManager) DeleteNamespace(nsObj *corev1.Namespace) erro
 		labelKeys = append(labelKeys, labelKey)
 	}
 
+	if err = npMgr.deleteNsFromSelectorLists(nsName); err != nil {
+		log.Printf("Error deleting namespace %s from namespace selector ipset lists\n", nsName)
+		return err
+	}
+
 	// Delete the namespace from all-namespace ipset list.
 	if err = ipsMgr.DeleteFromList(util.KubeAllNamespacesFlag, nsName); err != nil {
 		log.Printf("Error deleting namespace %s from ipset list %s\n", nsName, util.KubeAllNamespacesFlag)
@@ -205,6 +242,11 @@ func (npMgr *NetworkPolicyManager) DeleteNamespace(nsObj *corev1.Namespace) erro
 		return err
 	}
 
+	if err = ipsMgr.Apply(); err != nil {
+		log.Printf("Error applying ipsets for namespace %s.\n", nsName)
+		return err
+	}
+
 	delete(npMgr.nsMap, nsName)
 
 	npMgr.clusterState.NsCount--
@@ -10,10 +10,10 @@ import (
 	"time"
 
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/hnsm"
 	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/telemetry"
-	corev1 "k8s.io/api/core/v1"
-	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/client-go/informers"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	networkinginformers "k8s.io/client-go/informers/networking/v1"
@@ -38,7 +38,23 @@ type NetworkPolicyManager struct {
 
 	nodeName               string
 	nsMap                  map[string]*namespace
+	nsLabels               map[string]map[string]string
+	nsEnforcement          map[string]*nsEnforcement
 	isAzureNpmChainCreated bool
+	reconcileMap           map[string]*reconcileStatus
+	queue                  *reconcileQueue
+	reconciledObjs         map[string]interface{}
+	policyEvents           map[string]time.Time
+	syncPolicyKeys         []string
+	clock                  platform.Clock
+	hnsMgr                 *hnsm.HnsManager
+	policyConvergence      convergenceStats
+	policyStatusLock       sync.Mutex // Serializes the writes of the policy status annotation of the node.
+	writtenPolicyStatus    string     // Value of the policy status annotation of the node last written.
+	dataplaneDrift         telemetry.DataplaneDriftInfo
+	audit                  auditLog
+	fqdnCache              fqdnCache
+	fqdnRefreshCh          chan struct{}
 
 	clusterState  telemetry.ClusterState
 	reportManager *telemetry.ReportManager
@@ -60,6 +76,8 @@ func (npMgr *NetworkPolicyManager) UpdateAndSendReport(err error, eventMsg strin
 		v.FieldByName("NwPolicyCount").SetInt(int64(clusterState.NwPolicyCount))
 	}
 
+	reflect.ValueOf(npMgr.reportManager.Report).Elem().FieldByName("PolicyConvergence").Set(reflect.ValueOf(npMgr.getConvergenceInfo()))
+	npMgr.setDriftReport()
 	reflect.ValueOf(npMgr.reportManager.Report).Elem().FieldByName("EventMessage").SetString(eventMsg)
 
 	if err != nil {
@@ -71,6 +89,11 @@ func (npMgr *NetworkPolicyManager) UpdateAndSendReport(err error, eventMsg strin
 
 // Run starts shared informers and waits for the shared informer cache to sync.
 func (npMgr *NetworkPolicyManager) Run(stopCh <-chan struct{}) error {
+	npMgr.initDataplane()
+
+	// Start the reconcile workers before the informers deliver events.
+	npMgr.queue.run(stopCh, npMgr.reconcile, npMgr.reconciled)
+
 	// Starts all informers manufactured by npMgr's informerFactory.
 	npMgr.informerFactory.Start(stopCh)
 
@@ -87,7 +110,27 @@ func (npMgr *NetworkPolicyManager) Run(stopCh <-chan struct{}) error {
 		return fmt.Errorf("Namespace informer failed to sync")
 	}
 
-	return nil
+	// The dataplane built from the initial state of the cluster replaces the one of the previous instance at once,
+	// once every object in the caches is reconciled. The objects are added to the queue here, as the informers may
+	// not have delivered all of them to the event handlers yet.
+	if err := npMgr.enqueueCachedObjects(); err != nil {
+		return err
+	}
+
+	failed, ok := npMgr.queue.flush(stopCh)
+	if !ok {
+		return fmt.Errorf("Stopped before the initial events were reconciled")
+	}
+
+	// A dataplane missing some objects would drop or allow traffic the previous instance handled correctly.
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to reconcile %v, keeping the dataplane of the previous instance", failed)
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	return npMgr.finishDataplaneBootstrap()
 }
 
 // RunReportManager starts NPMReportManager and send telemetry periodically.
@@ -101,16 +144,24 @@ func (npMgr *NetworkPolicyManager) RunReportManager() {
 			v.FieldByName("NwPolicyCount").SetInt(int64(clusterState.NwPolicyCount))
 		}
 
+		npMgr.Lock()
+		reflect.ValueOf(npMgr.reportManager.Report).Elem().FieldByName("PolicyConvergence").Set(reflect.ValueOf(npMgr.getConvergenceInfo()))
+		npMgr.setDriftReport()
+		npMgr.setAuditReport()
+		npMgr.Unlock()
+
 		if err := npMgr.reportManager.SendReport(nil); err != nil {
 			log.Printf("Error sending NPM telemetry report")
 		}
 
-		time.Sleep(1 * time.Minute)
+		npMgr.clock.Sleep(1 * time.Minute)
 	}
 }
 
-// NewNetworkPolicyManager creates a NetworkPolicyManager
-func NewNetworkPolicyManager(clientset *kubernetes.Clientset, informerFactory informers.SharedInformerFactory, npmVersion string) *NetworkPolicyManager {
+// NewNetworkPolicyManager creates a NetworkPolicyManager.
+// The objects of the events are reconciled by reconcileWorkers workers sharded by namespace, and retried up to
+// reconcileRetries times when they fail.
+func NewNetworkPolicyManager(clientset *kubernetes.Clientset, informerFactory informers.SharedInformerFactory, npmVersion string, reconcileWorkers int, reconcileRetries int) *NetworkPolicyManager {
 
 	podInformer := informerFactory.Core().V1().Pods()
 	nsInformer := informerFactory.Core().V1().Namespaces()
@@ -124,7 +175,16 @@ func NewNetworkPolicyManager(clientset *kubernetes.Clientset, informerFactory in
 		npInformer:      npInformer,
 		nodeName:        os.Getenv("HOSTNAME"),
 		nsMap:           make(map[string]*namespace),
+		nsEnforcement:   make(map[string]*nsEnforcement),
 		isAzureNpmChainCreated: false,
+		reconcileMap:           make(map[string]*reconcileStatus),
+		queue:                  newReconcileQueue(reconcileWorkers, reconcileRetries, util.NpmReconcileRetryInitialDelay, util.NpmReconcileRetryMaxDelay),
+		reconciledObjs:         make(map[string]interface{}),
+		policyEvents:           make(map[string]time.Time),
+		clock:                  platform.NewClock(),
+		hnsMgr:                 hnsm.NewHnsManager(),
+		fqdnCache:              make(fqdnCache),
+		fqdnRefreshCh:          make(chan struct{}, 1),
 		clusterState: telemetry.ClusterState{
 			PodCount:      0,
 			NsCount:       0,
@@ -133,6 +193,7 @@ func NewNetworkPolicyManager(clientset *kubernetes.Clientset, informerFactory in
 		reportManager: &telemetry.ReportManager{
 			HostNetAgentURL: hostNetAgentURLForNpm,
 			ContentType:     contentType,
+			SourceID:        "azure-npm",
 			Report:          &telemetry.NPMReport{},
 		},
 	}
@@ -154,50 +215,7 @@ func NewNetworkPolicyManager(clientset *kubernetes.Clientset, informerFactory in
 	}
 	npMgr.nsMap[util.KubeAllNamespacesFlag] = allNs
 
-	podInformer.Informer().AddEventHandler(
-		// Pod event handlers
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				npMgr.AddPod(obj.(*corev1.Pod))
-			},
-			UpdateFunc: func(old, new interface{}) {
-				npMgr.UpdatePod(old.(*corev1.Pod), new.(*corev1.Pod))
-			},
-			DeleteFunc: func(obj interface{}) {
-				npMgr.DeletePod(obj.(*corev1.Pod))
-			},
-		},
-	)
-
-	nsInformer.Informer().AddEventHandler(
-		// Namespace event handlers
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				npMgr.AddNamespace(obj.(*corev1.Namespace))
-			},
-			UpdateFunc: func(old, new interface{}) {
-				npMgr.UpdateNamespace(old.(*corev1.Namespace), new.(*corev1.Namespace))
-			},
-			DeleteFunc: func(obj interface{}) {
-				npMgr.DeleteNamespace(obj.(*corev1.Namespace))
-			},
-		},
-	)
-
-	npInformer.Informer().AddEventHandler(
-		// Network policy event handlers
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				npMgr.AddNetworkPolicy(obj.(*networkingv1.NetworkPolicy))
-			},
-			UpdateFunc: func(old, new interface{}) {
-				npMgr.UpdateNetworkPolicy(old.(*networkingv1.NetworkPolicy), new.(*networkingv1.NetworkPolicy))
-			},
-			DeleteFunc: func(obj interface{}) {
-				npMgr.DeleteNetworkPolicy(obj.(*networkingv1.NetworkPolicy))
-			},
-		},
-	)
+	npMgr.addEventHandlers()
 
 	return npMgr
 }
@@ -0,0 +1,337 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// addEventHandlers enforces network policies with ipsets and iptables rules.
+// Events only schedule the reconciliation of their object, which is read again from the informer caches.
+func (npMgr *NetworkPolicyManager) addEventHandlers() {
+	npMgr.podInformer.Informer().AddEventHandler(
+		// Pod event handlers
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				npMgr.enqueue(podKind, obj)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				npMgr.enqueue(podKind, new)
+			},
+			DeleteFunc: func(obj interface{}) {
+				npMgr.enqueue(podKind, obj)
+			},
+		},
+	)
+
+	npMgr.nsInformer.Informer().AddEventHandler(
+		// Namespace event handlers
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				npMgr.enqueue(namespaceKind, obj)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				npMgr.enqueue(namespaceKind, new)
+			},
+			DeleteFunc: func(obj interface{}) {
+				npMgr.enqueue(namespaceKind, obj)
+			},
+		},
+	)
+
+	npMgr.npInformer.Informer().AddEventHandler(
+		// Network policy event handlers
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				npMgr.enqueuePolicy(obj)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				npMgr.enqueuePolicy(new)
+			},
+			DeleteFunc: func(obj interface{}) {
+				npMgr.enqueuePolicy(obj)
+			},
+		},
+	)
+}
+
+// enqueuePolicy schedules the reconciliation of a network policy, recording when its event was received.
+func (npMgr *NetworkPolicyManager) enqueuePolicy(obj interface{}) {
+	key, err := objectKey(networkPolicyKind, obj)
+	if err != nil {
+		log.Printf("Error getting the key of network policy %+v: %v\n", obj, err)
+		return
+	}
+
+	npMgr.addPolicyEvent(key, npMgr.clock.Now())
+	npMgr.queue.add(key)
+}
+
+// enqueueCachedObjects schedules the reconciliation of every object in the informer caches.
+func (npMgr *NetworkPolicyManager) enqueueCachedObjects() error {
+	pods, err := npMgr.podInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := npMgr.nsInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	policies, err := npMgr.npInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	for _, nsObj := range namespaces {
+		npMgr.enqueue(namespaceKind, nsObj)
+	}
+
+	for _, podObj := range pods {
+		npMgr.enqueue(podKind, podObj)
+	}
+
+	for _, npObj := range policies {
+		npMgr.enqueuePolicy(npObj)
+	}
+
+	return nil
+}
+
+// reconcile programs the current state of the object of a queue key, read from the informer caches, in place
+// of the state the object was last programmed with.
+func (npMgr *NetworkPolicyManager) reconcile(key string) error {
+	kind, objNs, objName, err := splitObjectKey(key)
+	if err != nil {
+		// The key can't be reconciled again.
+		log.Printf("Error reconciling %v: %v\n", key, err)
+		return nil
+	}
+
+	switch kind {
+	case podKind:
+		return npMgr.reconcilePod(key, objNs, objName)
+	case namespaceKind:
+		return npMgr.reconcileNamespace(key, objName)
+	case networkPolicyKind:
+		return npMgr.reconcileNetworkPolicy(key, objNs, objName)
+	}
+
+	log.Printf("Error reconciling %v: unknown kind\n", key)
+	return nil
+}
+
+// reconciled records the convergence of the network policy of a queue key once it is reconciled or given up on.
+func (npMgr *NetworkPolicyManager) reconciled(key string, err error) {
+	if strings.HasPrefix(key, networkPolicyKind+"/") {
+		npMgr.completePolicyEvent(key, err)
+	}
+}
+
+// getReconciledObject returns the object a queue key was last programmed with, or nil.
+func (npMgr *NetworkPolicyManager) getReconciledObject(key string) interface{} {
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	return npMgr.reconciledObjs[key]
+}
+
+// setReconciledObject records the object a queue key was programmed with, or that it was removed if obj is nil.
+func (npMgr *NetworkPolicyManager) setReconciledObject(key string, obj interface{}) {
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	if obj == nil {
+		delete(npMgr.reconciledObjs, key)
+		return
+	}
+
+	npMgr.reconciledObjs[key] = obj
+}
+
+// reconcilePod programs the current state of a pod. Pods that are deleted, being deleted or without a valid ip
+// are removed from the ipsets.
+func (npMgr *NetworkPolicyManager) reconcilePod(key, podNs, podName string) error {
+	podObj, err := npMgr.podInformer.Lister().Pods(podNs).Get(podName)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if podObj != nil && (isDeleting(podObj.ObjectMeta) || !isValidPod(podObj)) {
+		podObj = nil
+	}
+
+	oldPodObj, _ := npMgr.getReconciledObject(key).(*corev1.Pod)
+
+	switch {
+	case oldPodObj == nil && podObj == nil:
+		return nil
+	case oldPodObj == nil:
+		err = npMgr.AddPod(podObj)
+	case podObj == nil:
+		err = npMgr.DeletePod(oldPodObj)
+	case oldPodObj.ObjectMeta.ResourceVersion == podObj.ObjectMeta.ResourceVersion:
+		return nil
+	default:
+		err = npMgr.UpdatePod(oldPodObj, podObj)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if podObj == nil {
+		npMgr.setReconciledObject(key, nil)
+	} else {
+		npMgr.setReconciledObject(key, podObj)
+	}
+
+	return nil
+}
+
+// reconcileNamespace programs the current state of a namespace.
+func (npMgr *NetworkPolicyManager) reconcileNamespace(key, nsName string) error {
+	nsObj, err := npMgr.nsInformer.Lister().Get(nsName)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if nsObj != nil && isDeleting(nsObj.ObjectMeta) {
+		nsObj = nil
+	}
+
+	oldNsObj, _ := npMgr.getReconciledObject(key).(*corev1.Namespace)
+
+	switch {
+	case oldNsObj == nil && nsObj == nil:
+		return nil
+	case oldNsObj == nil:
+		err = npMgr.AddNamespace(nsObj)
+	case nsObj == nil:
+		err = npMgr.DeleteNamespace(oldNsObj)
+	case oldNsObj.ObjectMeta.ResourceVersion == nsObj.ObjectMeta.ResourceVersion:
+		return nil
+	default:
+		err = npMgr.UpdateNamespace(oldNsObj, nsObj)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if nsObj == nil {
+		npMgr.setReconciledObject(key, nil)
+	} else {
+		npMgr.setReconciledObject(key, nsObj)
+	}
+
+	return nil
+}
+
+// reconcileNetworkPolicy programs the current state of a network policy.
+func (npMgr *NetworkPolicyManager) reconcileNetworkPolicy(key, npNs, npName string) error {
+	npObj, err := npMgr.npInformer.Lister().NetworkPolicies(npNs).Get(npName)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if npObj != nil && isDeleting(npObj.ObjectMeta) {
+		npObj = nil
+	}
+
+	oldNpObj, _ := npMgr.getReconciledObject(key).(*networkingv1.NetworkPolicy)
+
+	switch {
+	case oldNpObj == nil && npObj == nil:
+		return nil
+	case oldNpObj == nil:
+		err = npMgr.AddNetworkPolicy(npObj)
+	case npObj == nil:
+		err = npMgr.DeleteNetworkPolicy(oldNpObj)
+	default:
+		err = npMgr.UpdateNetworkPolicy(oldNpObj, npObj)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if npObj == nil {
+		npMgr.setReconciledObject(key, nil)
+	} else {
+		npMgr.setReconciledObject(key, npObj)
+	}
+
+	return nil
+}
+
+// initDataplane loads the names given to the ipsets before npm restarted, and the ipsets left by the previous
+// instance, so that those named by an earlier version are migrated. The iptables chains are built under shadow
+// names, while the chains of the previous instance keep filtering traffic.
+func (npMgr *NetworkPolicyManager) initDataplane() {
+	if err := util.LoadSetNames(util.IpsetNamesFile); err != nil {
+		// Sets are named again, those of the previous instance are reconciled or left unused.
+		log.Printf("Error loading ipset names, naming sets from scratch: %v\n", err)
+	}
+
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+	if err := allNs.ipsMgr.LoadStartupSets(); err != nil {
+		log.Printf("Error loading existing ipsets, legacy ipsets won't be migrated: %v\n", err)
+	}
+
+	allNs.iptMgr.StartBootstrap()
+}
+
+// finishDataplaneBootstrap swaps the iptables chains built from the initial state of the cluster for those of
+// the previous instance.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) finishDataplaneBootstrap() error {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+
+	return allNs.iptMgr.FinishBootstrap()
+}
+
+// repairDataplaneDrift repairs the ipsets and iptables chains that drifted from the state programmed by npm.
+// Ipsets go first, as the rules refer to them. Legacy ipsets the rules no longer refer to are destroyed.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) repairDataplaneDrift() (int, error) {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+	allNs.ipsMgr.DestroyLegacySets()
+
+	ipsetDrift, err := allNs.ipsMgr.Reconcile()
+	if err != nil {
+		return ipsetDrift, err
+	}
+
+	iptablesDrift, err := allNs.iptMgr.Reconcile()
+
+	return ipsetDrift + iptablesDrift, err
+}
+
+// verifyDataplane describes the ipsets and iptables chains that drifted from the state programmed by npm,
+// without repairing them.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) verifyDataplane() ([]string, error) {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+
+	ipsetDrift, err := allNs.ipsMgr.Verify()
+	if err != nil {
+		return nil, err
+	}
+
+	iptablesDrift, err := allNs.iptMgr.Verify()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ipsetDrift, iptablesDrift...), nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// hnsSyncKey is the queue key of the HNS ACL syncs, so that events received while a sync runs are synced at once.
+const hnsSyncKey = "hns"
+
+// addEventHandlers enforces network policies with HNS ACL policies on the pod endpoints.
+// There are no ipsets on Windows, so every event schedules a sync of the ACLs of the pods of the node,
+// recomputed from the informer caches.
+func (npMgr *NetworkPolicyManager) addEventHandlers() {
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			npMgr.queue.add(hnsSyncKey)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			npMgr.queue.add(hnsSyncKey)
+		},
+		DeleteFunc: func(obj interface{}) {
+			npMgr.queue.add(hnsSyncKey)
+		},
+	}
+
+	npMgr.podInformer.Informer().AddEventHandler(handler)
+	npMgr.nsInformer.Informer().AddEventHandler(handler)
+	npMgr.npInformer.Informer().AddEventHandler(
+		// Network policy events also record the convergence of the policy.
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				npMgr.enqueuePolicy(obj)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				npMgr.enqueuePolicy(new)
+			},
+			DeleteFunc: func(obj interface{}) {
+				npMgr.enqueuePolicy(obj)
+			},
+		},
+	)
+}
+
+// enqueuePolicy schedules a sync for a network policy event, recording when it was received.
+func (npMgr *NetworkPolicyManager) enqueuePolicy(obj interface{}) {
+	if key, err := objectKey(networkPolicyKind, obj); err == nil {
+		npMgr.addPolicyEvent(key, npMgr.clock.Now())
+	}
+
+	npMgr.queue.add(hnsSyncKey)
+}
+
+// reconcile syncs the HNS ACLs of the endpoints. The network policy events received so far are converged by the sync.
+func (npMgr *NetworkPolicyManager) reconcile(key string) error {
+	npMgr.Lock()
+	npMgr.syncPolicyKeys = npMgr.syncPolicyKeys[:0]
+	for policyKey := range npMgr.policyEvents {
+		npMgr.syncPolicyKeys = append(npMgr.syncPolicyKeys, policyKey)
+	}
+	npMgr.Unlock()
+
+	return npMgr.SyncEndpointACLs(util.UpdateNetworkPolicyEvent)
+}
+
+// enqueueCachedObjects schedules a sync of the HNS ACLs with every object in the informer caches.
+func (npMgr *NetworkPolicyManager) enqueueCachedObjects() error {
+	npMgr.queue.add(hnsSyncKey)
+	return nil
+}
+
+// reconciled records the convergence of the network policy events converged by the last sync.
+func (npMgr *NetworkPolicyManager) reconciled(key string, err error) {
+	npMgr.Lock()
+	keys := append([]string(nil), npMgr.syncPolicyKeys...)
+	npMgr.Unlock()
+
+	for _, policyKey := range keys {
+		npMgr.completePolicyEvent(policyKey, err)
+	}
+}
+
+// SyncEndpointACLs applies the HNS ACLs of the network policies to the endpoints of the pods of the node.
+func (npMgr *NetworkPolicyManager) SyncEndpointACLs(eventMsg string) error {
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	var err error
+
+	defer func() {
+		if err = npMgr.UpdateAndSendReport(err, eventMsg); err != nil {
+			log.Printf("Error sending NPM telemetry report")
+		}
+	}()
+
+	pods, err := npMgr.podInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := npMgr.nsInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	policies, err := npMgr.npInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	npMgr.clusterState.PodCount = len(pods)
+	npMgr.clusterState.NsCount = len(namespaces)
+	npMgr.clusterState.NwPolicyCount = len(policies)
+
+	// Keep the policies known to the namespace statistics.
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+	allNs.npMap = make(map[string]*networkingv1.NetworkPolicy)
+	for _, npObj := range policies {
+		allNs.npMap[npObj.ObjectMeta.Name] = npObj
+	}
+
+	inv := newAclInventory(pods, namespaces, policies)
+	nsErrors := make(map[string]error)
+	localPods := make(map[string]bool)
+	for _, podObj := range pods {
+		podNs := podObj.ObjectMeta.Namespace
+		if podObj.Spec.NodeName != npMgr.nodeName || podObj.Spec.HostNetwork || !isValidPod(podObj) {
+			continue
+		}
+
+		podIP := podObj.Status.PodIP
+		localPods[podIP] = true
+		if applyErr := npMgr.hnsMgr.Apply(podIP, inv.getEndpointACLs(podObj)); applyErr != nil {
+			log.Printf("Error applying ACLs to pod %s/%s\n", podNs, podObj.ObjectMeta.Name)
+			nsErrors[podNs] = applyErr
+			err = applyErr
+			continue
+		}
+
+		if _, exists := nsErrors[podNs]; !exists {
+			nsErrors[podNs] = nil
+		}
+	}
+
+	for nsName, nsErr := range nsErrors {
+		npMgr.recordReconcile(nsName, nsErr)
+	}
+
+	// The endpoints of deleted pods are gone along with their ACLs.
+	for _, podIP := range npMgr.hnsMgr.GetEndpoints() {
+		if !localPods[podIP] {
+			npMgr.hnsMgr.Forget(podIP)
+		}
+	}
+
+	return err
+}
+
+// initDataplane is a no-op on Windows, where there are no ipsets.
+func (npMgr *NetworkPolicyManager) initDataplane() {
+}
+
+// finishDataplaneBootstrap is a no-op on Windows, where the HNS ACLs of the endpoints are applied in place.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) finishDataplaneBootstrap() error {
+	return nil
+}
+
+// repairDataplaneDrift reapplies the HNS ACLs of the endpoints that drifted from the applied ones.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) repairDataplaneDrift() (int, error) {
+	return npMgr.hnsMgr.Reconcile()
+}
+
+// verifyDataplane returns the endpoints whose HNS ACLs drifted from the applied ones.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) verifyDataplane() ([]string, error) {
+	return npMgr.hnsMgr.Verify()
+}
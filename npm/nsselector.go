@@ -0,0 +1,179 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// getNsSelectorListName returns the name of the ipset list of the namespaces matching a namespace selector.
+// Selectors are named after their canonical form, so that policies with the same selector share the list.
+func getNsSelectorListName(selector *metav1.LabelSelector) string {
+	return util.NsSelectorIPSetPrefix + metav1.FormatLabelSelector(selector)
+}
+
+// getNsSelectorLists returns the ipset lists of the namespaces selected by a namespace selector.
+// A selector with matchExpressions gets a single list of the namespace sets matching the whole selector.
+func getNsSelectorLists(selector *metav1.LabelSelector) []string {
+	if len(selector.MatchExpressions) > 0 {
+		return []string{getNsSelectorListName(selector)}
+	}
+
+	if len(selector.MatchLabels) == 0 {
+		return []string{util.KubeAllNamespacesFlag}
+	}
+
+	var lists []string
+	for nsLabelKey, nsLabelVal := range selector.MatchLabels {
+		lists = append(lists, getNsIpsetName(nsLabelKey, nsLabelVal))
+	}
+
+	return lists
+}
+
+// getNsSelectors returns the namespace selectors with matchExpressions of a network policy, by list name.
+func getNsSelectors(npObj *networkingv1.NetworkPolicy) map[string]labels.Selector {
+	selectors := make(map[string]labels.Selector)
+
+	addPeers := func(peers []networkingv1.NetworkPolicyPeer) {
+		for _, peer := range peers {
+			if peer.NamespaceSelector == nil || len(peer.NamespaceSelector.MatchExpressions) == 0 {
+				continue
+			}
+
+			selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+			if err != nil {
+				log.Printf("Error parsing namespace selector %+v, it selects no namespace\n", peer.NamespaceSelector)
+				selector = labels.Nothing()
+			}
+
+			selectors[getNsSelectorListName(peer.NamespaceSelector)] = selector
+		}
+	}
+
+	for _, rule := range npObj.Spec.Ingress {
+		addPeers(rule.From)
+	}
+
+	for _, rule := range npObj.Spec.Egress {
+		addPeers(rule.To)
+	}
+
+	return selectors
+}
+
+// addNsSelectorLists adds the namespaces matching the namespace selectors of a network policy to their lists.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) addNsSelectorLists(npObj *networkingv1.NetworkPolicy) error {
+	ipsMgr := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr
+	for list, selector := range getNsSelectors(npObj) {
+		if err := ipsMgr.CreateList(list); err != nil {
+			return err
+		}
+
+		for nsName, nsLabels := range npMgr.nsLabels {
+			if !selector.Matches(labels.Set(nsLabels)) {
+				continue
+			}
+
+			log.Printf("Adding namespace %s to ipset list %s\n", nsName, list)
+			if err := ipsMgr.AddToList(list, nsName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteNsSelectorLists destroys the namespace selector lists of a deleted network policy that no other policy uses.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deleteNsSelectorLists(npObj *networkingv1.NetworkPolicy) error {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+
+	inUse := make(map[string]bool)
+	for _, otherNpObj := range allNs.npMap {
+		for list := range getNsSelectors(otherNpObj) {
+			inUse[list] = true
+		}
+	}
+
+	ipsMgr := allNs.ipsMgr
+	for list := range getNsSelectors(npObj) {
+		if inUse[list] {
+			continue
+		}
+
+		// The list is destroyed with its last member.
+		hasMembers := false
+		for nsName := range npMgr.nsLabels {
+			if !ipsMgr.Exists(list, nsName, util.IpsetSetListFlag) {
+				continue
+			}
+
+			hasMembers = true
+			if err := ipsMgr.DeleteFromList(list, nsName); err != nil {
+				return err
+			}
+		}
+
+		if !hasMembers {
+			if err := ipsMgr.DeleteList(list); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ipsMgr.Apply()
+}
+
+// addNsToSelectorLists records the labels of a namespace, and adds it to the namespace selector lists it matches.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) addNsToSelectorLists(nsName string, nsLabels map[string]string) error {
+	if npMgr.nsLabels == nil {
+		npMgr.nsLabels = make(map[string]map[string]string)
+	}
+	npMgr.nsLabels[nsName] = nsLabels
+
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+	for _, npObj := range allNs.npMap {
+		for list, selector := range getNsSelectors(npObj) {
+			if !selector.Matches(labels.Set(nsLabels)) {
+				continue
+			}
+
+			log.Printf("Adding namespace %s to ipset list %s\n", nsName, list)
+			if err := allNs.ipsMgr.AddToList(list, nsName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteNsFromSelectorLists deletes a namespace from the namespace selector lists, and forgets its labels.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deleteNsFromSelectorLists(nsName string) error {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+	for _, npObj := range allNs.npMap {
+		for list := range getNsSelectors(npObj) {
+			if !allNs.ipsMgr.Exists(list, nsName, util.IpsetSetListFlag) {
+				continue
+			}
+
+			log.Printf("Deleting namespace %s from ipset list %s\n", nsName, list)
+			if err := allNs.ipsMgr.DeleteFromList(list, nsName); err != nil {
+				return err
+			}
+		}
+	}
+
+	delete(npMgr.nsLabels, nsName)
+
+	return nil
+}
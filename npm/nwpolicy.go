@@ -3,6 +3,8 @@
 package npm
 
 import (
+	"strings"
+
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm/util"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -21,6 +23,27 @@ func (npMgr *NetworkPolicyManager) AddNetworkPolicy(npObj *networkingv1.NetworkP
 		}
 	}()
 
+	defer func() {
+		npMgr.recordReconcile(npObj.ObjectMeta.Namespace, err)
+	}()
+
+	// The policies of namespaces exempted from enforcement are only tracked.
+	enforcement := npMgr.getNsEnforcement(npObj.ObjectMeta.Namespace)
+	if !enforcement.disabled {
+		if err = npMgr.addNetworkPolicy(npObj); err != nil {
+			return err
+		}
+	}
+	enforcement.policies[npObj.ObjectMeta.Name] = npObj
+
+	return nil
+}
+
+// addNetworkPolicy programs the ipsets and the iptables rules of a network policy.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) addNetworkPolicy(npObj *networkingv1.NetworkPolicy) error {
+	var err error
+
 	npNs, npName := npObj.ObjectMeta.Namespace, npObj.ObjectMeta.Name
 	log.Printf("NETWORK POLICY CREATING: %s/%s\n", npNs, npName)
 
@@ -32,6 +55,12 @@ func (npMgr *NetworkPolicyManager) AddNetworkPolicy(npObj *networkingv1.NetworkP
 			return err
 		}
 
+		// The AZURE-NPM chain refers to the kube-system ipset.
+		if err = allNs.ipsMgr.Apply(); err != nil {
+			log.Printf("Error applying kube-system ipset.\n")
+			return err
+		}
+
 		if err = allNs.iptMgr.InitNpmChains(); err != nil {
 			log.Printf("Error initialize azure-npm chains.\n")
 			return err
@@ -40,37 +69,73 @@ func (npMgr *NetworkPolicyManager) AddNetworkPolicy(npObj *networkingv1.NetworkP
 		npMgr.isAzureNpmChainCreated = true
 	}
 
-	podSets, nsLists, iptEntries := parsePolicy(npObj)
+	policy := optimizePolicy(npObj)
 
 	ipsMgr := allNs.ipsMgr
-	for _, set := range podSets {
-		if err = ipsMgr.CreateSet(set); err != nil {
+	for _, set := range policy.podSets {
+		setType := util.IpsetNetHashFlag
+		if strings.HasPrefix(set, util.NamedPortIPSetPrefix) {
+			setType = util.IpsetIPPortHashFlag
+		}
+
+		if err = ipsMgr.CreateSetWithType(set, setType); err != nil {
 			log.Printf("Error creating ipset %s-%s\n", npNs, set)
 			return err
 		}
 	}
 
-	for _, list := range nsLists {
+	for set, members := range policy.ipBlockSets {
+		for _, member := range members {
+			if err = ipsMgr.AddToSet(set, member); err != nil {
+				log.Printf("Error adding %s to ipset %s-%s\n", member, npNs, set)
+				return err
+			}
+		}
+	}
+
+	for _, list := range policy.nsLists {
 		if err = ipsMgr.CreateList(list); err != nil {
 			log.Printf("Error creating ipset list %s-%s\n", npNs, list)
 			return err
 		}
 	}
 
+	if err = npMgr.addNsSelectorLists(npObj); err != nil {
+		log.Printf("Error filling namespace selector ipset lists of network policy %s-%s\n", npNs, npName)
+		return err
+	}
+
+	if err = npMgr.addFqdnSets(npObj); err != nil {
+		log.Printf("Error creating FQDN ipsets of network policy %s-%s\n", npNs, npName)
+		return err
+	}
+
 	if err = npMgr.InitAllNsList(); err != nil {
 		log.Printf("Error initializing all-namespace ipset list.\n")
 		return err
 	}
 
+	// The ipsets must exist before the rules referring to them are applied.
+	if err = ipsMgr.Apply(); err != nil {
+		log.Printf("Error applying ipsets of network policy %s-%s\n", npNs, npName)
+		return err
+	}
+
 	iptMgr := allNs.iptMgr
-	for _, iptEntry := range iptEntries {
+	for _, iptEntry := range policy.entries {
 		if err = iptMgr.Add(iptEntry); err != nil {
 			log.Printf("Error applying iptables rule\n. Rule: %+v", iptEntry)
 			return err
 		}
 	}
 
+	if err = iptMgr.Apply(); err != nil {
+		log.Printf("Error applying iptables rules of network policy %s-%s\n", npNs, npName)
+		return err
+	}
+
 	allNs.npMap[npName] = npObj
+	allNs.npRefs.add(npName, policy)
 
 	npMgr.clusterState.NwPolicyCount++
 
@@ -83,32 +148,62 @@ func (npMgr *NetworkPolicyManager) AddNetworkPolicy(npObj *networkingv1.NetworkP
 	return nil
 }
 
-// UpdateNetworkPolicy handles updateing network policy in iptables.
+// UpdateNetworkPolicy handles updating network policy in iptables.
 func (npMgr *NetworkPolicyManager) UpdateNetworkPolicy(oldNpObj *networkingv1.NetworkPolicy, newNpObj *networkingv1.NetworkPolicy) error {
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
 	var err error
 
 	defer func() {
-		npMgr.Lock()
 		if err = npMgr.UpdateAndSendReport(err, util.UpdateNetworkPolicyEvent); err != nil {
 			log.Printf("Error sending NPM telemetry report")
 		}
-		npMgr.Unlock()
 	}()
 
 	oldNpNs, oldNpName := oldNpObj.ObjectMeta.Namespace, oldNpObj.ObjectMeta.Name
 	log.Printf("NETWORK POLICY UPDATING: %s/%s\n", oldNpNs, oldNpName)
 
-	if err = npMgr.DeleteNetworkPolicy(oldNpObj); err != nil {
-		return err
+	defer func() {
+		npMgr.recordReconcile(oldNpNs, err)
+	}()
+
+	deleted := newNpObj.ObjectMeta.DeletionTimestamp != nil || newNpObj.ObjectMeta.DeletionGracePeriodSeconds != nil
+
+	// The policies of namespaces exempted from enforcement are only tracked.
+	enforcement := npMgr.getNsEnforcement(oldNpNs)
+	if enforcement.disabled {
+		delete(enforcement.policies, oldNpName)
+		if !deleted {
+			enforcement.policies[newNpObj.ObjectMeta.Name] = newNpObj
+		}
+		return nil
 	}
 
-	if newNpObj.ObjectMeta.DeletionTimestamp == nil && newNpObj.ObjectMeta.DeletionGracePeriodSeconds == nil {
-		if err = npMgr.AddNetworkPolicy(newNpObj); err != nil {
+	if deleted {
+		if _, err = npMgr.deleteNetworkPolicy(oldNpObj, false); err != nil {
 			return err
 		}
+		delete(enforcement.policies, oldNpName)
+		return nil
 	}
 
-	return nil
+	// The rules of the old policy are replaced by those of the new one in a single Apply, so that the traffic both
+	// allow is never dropped in between. The ipsets of the old policy are destroyed once no rule refers to them.
+	oldPolicy, err := npMgr.deleteNetworkPolicy(oldNpObj, true)
+	if err != nil {
+		return err
+	}
+	delete(enforcement.policies, oldNpName)
+
+	if err = npMgr.addNetworkPolicy(newNpObj); err != nil {
+		return err
+	}
+	enforcement.policies[newNpObj.ObjectMeta.Name] = newNpObj
+
+	err = npMgr.deletePolicySets(oldNpObj, oldPolicy)
+
+	return err
 }
 
 // DeleteNetworkPolicy handles deleting network policy from iptables.
@@ -124,18 +219,57 @@ func (npMgr *NetworkPolicyManager) DeleteNetworkPolicy(npObj *networkingv1.Netwo
 		}
 	}()
 
+	defer func() {
+		npMgr.recordReconcile(npObj.ObjectMeta.Namespace, err)
+	}()
+
+	// The policies of namespaces exempted from enforcement have no rules.
+	enforcement := npMgr.getNsEnforcement(npObj.ObjectMeta.Namespace)
+	if !enforcement.disabled {
+		if _, err = npMgr.deleteNetworkPolicy(npObj, false); err != nil {
+			return err
+		}
+	}
+	delete(enforcement.policies, npObj.ObjectMeta.Name)
+
+	return nil
+}
+
+// deleteNetworkPolicy removes the iptables rules of a network policy and destroys the ipsets no other policy uses.
+// The rules shared with other policies, e.g. those of peer chains, are kept until the last of them is deleted.
+// The rules of a policy being replaced are only removed in memory, and applied with those of the new policy.
+// Returns the translation the policy was programmed with.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deleteNetworkPolicy(npObj *networkingv1.NetworkPolicy, replaced bool) (*optimizedPolicy, error) {
+	var err error
+
 	npNs, npName := npObj.ObjectMeta.Namespace, npObj.ObjectMeta.Name
 	log.Printf("NETWORK POLICY DELETING: %s/%s\n", npNs, npName)
 
 	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
 
-	_, _, iptEntries := parsePolicy(npObj)
+	// Policies that failed to be programmed are not counted, and only their rules no other policy uses are deleted.
+	policy := allNs.npRefs.remove(npName)
+	if policy == nil {
+		policy = optimizePolicy(npObj)
+	}
 
 	iptMgr := allNs.iptMgr
-	for _, iptEntry := range iptEntries {
+	for _, iptEntry := range policy.entries {
+		if allNs.npRefs.rules[getEntryKey(iptEntry)] > 0 {
+			continue
+		}
+
 		if err = iptMgr.Delete(iptEntry); err != nil {
 			log.Printf("Error applying iptables rule.\n Rule: %+v", iptEntry)
-			return err
+			return policy, err
+		}
+	}
+
+	// The peer chains no policy uses are deleted by the next Apply.
+	for _, chain := range policy.peerChains() {
+		if allNs.npRefs.chains[chain] == 0 {
+			peerChainNames.release(chain)
 		}
 	}
 
@@ -143,13 +277,69 @@ func (npMgr *NetworkPolicyManager) DeleteNetworkPolicy(npObj *networkingv1.Netwo
 
 	npMgr.clusterState.NwPolicyCount--
 
+	if replaced {
+		return policy, nil
+	}
+
 	if len(allNs.npMap) == 0 {
 		if err = iptMgr.UninitNpmChains(); err != nil {
 			log.Printf("Error uninitialize azure-npm chains.\n")
-			return err
+			return policy, err
 		}
 		npMgr.isAzureNpmChainCreated = false
+	} else if err = iptMgr.Apply(); err != nil {
+		log.Printf("Error applying iptables rules of network policy %s-%s\n", npNs, npName)
+		return policy, err
+	}
+
+	return policy, npMgr.deletePolicySets(npObj, policy)
+}
+
+// deletePolicySets destroys the ipsets of a deleted network policy that no other policy uses.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deletePolicySets(npObj *networkingv1.NetworkPolicy, policy *optimizedPolicy) error {
+	npNs, npName := npObj.ObjectMeta.Namespace, npObj.ObjectMeta.Name
+
+	// The ipBlock sets are destroyed once no rule refers to them.
+	if err := npMgr.deleteIPBlockSets(policy); err != nil {
+		log.Printf("Error deleting ipBlock ipsets of network policy %s-%s\n", npNs, npName)
+		return err
+	}
+
+	if err := npMgr.deleteNsSelectorLists(npObj); err != nil {
+		log.Printf("Error deleting namespace selector ipset lists of network policy %s-%s\n", npNs, npName)
+		return err
+	}
+
+	if err := npMgr.deleteFqdnSets(npObj); err != nil {
+		log.Printf("Error deleting FQDN ipsets of network policy %s-%s\n", npNs, npName)
+		return err
 	}
 
 	return nil
 }
+
+// deleteIPBlockSets destroys the ipBlock sets of a deleted network policy that no other policy uses.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deleteIPBlockSets(policy *optimizedPolicy) error {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+
+	ipsMgr := allNs.ipsMgr
+	for set, members := range policy.ipBlockSets {
+		if allNs.npRefs.sets[set] > 0 {
+			continue
+		}
+
+		for _, member := range members {
+			if err := ipsMgr.DeleteFromSet(set, member); err != nil {
+				return err
+			}
+		}
+
+		if err := ipsMgr.DeleteSet(set); err != nil {
+			return err
+		}
+	}
+
+	return ipsMgr.Apply()
+}
@@ -0,0 +1,520 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/npm/iptm"
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// iptables chain names are limited to 28 characters. A peer chain is named IptablesAzurePeerChainPrefix followed by
+// the hash of its rules, zero padded to peerChainHashLength base 36 digits, which leaves room for
+// IptablesShadowChainSuffix.
+const (
+	peerChainHashLength = 11
+
+	// Least number of peers a target set is allowed from or to for its rules to be moved to a peer chain.
+	minPeerChainRules = 2
+)
+
+// optimizedPolicy is the translation of a network policy as it is programmed.
+type optimizedPolicy struct {
+	podSets     []string
+	nsLists     []string
+	ipBlockSets map[string][]string // ipBlock set -> members.
+	entries     []*iptm.IptEntry
+}
+
+// optimizePolicy translates a network policy, and compresses its rules before they are programmed:
+//   - the ipBlocks a target set is allowed from or to without except blocks are merged into a single ipset, whose
+//     members are their CIDRs with the overlapping and adjacent ones merged.
+//   - the rules allowing a target set from or to several peers are moved to a peer chain named after them, which the
+//     target set jumps to. The rules of a policy take the number of its target sets plus the number of its peers,
+//     instead of their product, and the policies allowing the same peers share the chain.
+//   - identical rules are only kept once.
+//
+// ipBlock sets are named after their members and peer chains after their rules, so that policies with identical peers
+// share them. The policies using each rule, peer chain and ipBlock set are counted, so that they are only deleted with
+// the last of them.
+func optimizePolicy(npObj *networkingv1.NetworkPolicy) *optimizedPolicy {
+	podSets, nsLists, entries := parsePolicy(npObj)
+	ipBlockSets := getIPBlockSets(npObj)
+
+	entries = uniqueEntries(aggregateIPBlocks(entries, ipBlockSets))
+	entries = uniqueEntries(sharePeerChains(entries))
+
+	return &optimizedPolicy{
+		podSets:     podSets,
+		nsLists:     nsLists,
+		ipBlockSets: ipBlockSets,
+		entries:     entries,
+	}
+}
+
+// getEntryKey returns the key identifying a rule in its chain.
+func getEntryKey(entry *iptm.IptEntry) string {
+	return entry.Chain + " " + strings.Join(entry.Specs, " ")
+}
+
+// uniqueEntries returns the rules without those identical to an earlier one.
+func uniqueEntries(entries []*iptm.IptEntry) []*iptm.IptEntry {
+	var unique []*iptm.IptEntry
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if key := getEntryKey(entry); !seen[key] {
+			seen[key] = true
+			unique = append(unique, entry)
+		}
+	}
+
+	return unique
+}
+
+// getIPBlockRuleKey returns the key of a rule matching an ipBlock set without except blocks, which is the rule with
+// the set left out, so that the rules differing only by the ipBlock they match have the same key.
+func getIPBlockRuleKey(entry *iptm.IptEntry, ipBlockSets map[string][]string) (string, bool) {
+	if members, exists := ipBlockSets[entry.Name]; !exists || len(members) != 1 {
+		return "", false
+	}
+
+	specs := make([]string, len(entry.Specs))
+	found := false
+	for i, spec := range entry.Specs {
+		if i > 0 && entry.Specs[i-1] == util.IptablesMatchSetFlag && spec == entry.HashedName {
+			spec, found = "", true
+		}
+		specs[i] = spec
+	}
+
+	return entry.Chain + " " + strings.Join(specs, " "), found
+}
+
+// aggregateIPBlocks merges the ipBlock sets of the rules that only differ by the ipBlock they match, and returns the
+// rules matching the merged sets instead. The merged sets are added to ipBlockSets, and the sets no rule matches
+// anymore are removed from it.
+func aggregateIPBlocks(entries []*iptm.IptEntry, ipBlockSets map[string][]string) []*iptm.IptEntry {
+	var keys []string
+	groups := make(map[string][]*iptm.IptEntry)
+	for _, entry := range entries {
+		if key, ok := getIPBlockRuleKey(entry, ipBlockSets); ok {
+			if _, exists := groups[key]; !exists {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], entry)
+		}
+	}
+
+	aggregated := make(map[*iptm.IptEntry]*iptm.IptEntry)
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		var cidrs []string
+		for _, entry := range group {
+			cidrs = append(cidrs, ipBlockSets[entry.Name]...)
+		}
+
+		members := mergeCIDRs(cidrs)
+		set := util.IPBlockIPSetPrefix + strings.Join(members, ",")
+		hashedSet := util.GetHashedName(set)
+		ipBlockSets[set] = members
+
+		specs := make([]string, len(group[0].Specs))
+		for i, spec := range group[0].Specs {
+			if i > 0 && group[0].Specs[i-1] == util.IptablesMatchSetFlag && spec == group[0].HashedName {
+				spec = hashedSet
+			}
+			specs[i] = spec
+		}
+
+		rule := &iptm.IptEntry{
+			Name:       set,
+			HashedName: hashedSet,
+			Chain:      group[0].Chain,
+			Specs:      specs,
+		}
+		for _, entry := range group {
+			aggregated[entry] = rule
+		}
+	}
+
+	var result []*iptm.IptEntry
+	used := make(map[string]bool)
+	for _, entry := range entries {
+		if rule, exists := aggregated[entry]; exists {
+			entry = rule
+		}
+		result = append(result, entry)
+		used[entry.Name] = true
+	}
+
+	for set := range ipBlockSets {
+		if !used[set] {
+			delete(ipBlockSets, set)
+		}
+	}
+
+	return result
+}
+
+// compareIPNets orders networks by address family, address, and prefix length, so that a network comes before the
+// networks it contains.
+func compareIPNets(a *net.IPNet, b *net.IPNet) int {
+	if len(a.IP) != len(b.IP) {
+		return len(a.IP) - len(b.IP)
+	}
+
+	if c := bytes.Compare(a.IP, b.IP); c != 0 {
+		return c
+	}
+
+	aOnes, _ := a.Mask.Size()
+	bOnes, _ := b.Mask.Size()
+	return aOnes - bOnes
+}
+
+// containsIPNet checks if network a contains network b.
+func containsIPNet(a *net.IPNet, b *net.IPNet) bool {
+	aOnes, _ := a.Mask.Size()
+	bOnes, _ := b.Mask.Size()
+	return len(a.IP) == len(b.IP) && aOnes <= bOnes && a.Contains(b.IP)
+}
+
+// getParentIPNet returns the network made of two adjacent networks of the same size, or nil if they aren't.
+// The two halves of the address space are not merged, since hash:net ipsets don't accept /0 networks.
+func getParentIPNet(a *net.IPNet, b *net.IPNet) *net.IPNet {
+	aOnes, bits := a.Mask.Size()
+	bOnes, _ := b.Mask.Size()
+	if len(a.IP) != len(b.IP) || aOnes != bOnes || aOnes <= 1 || a.IP.Equal(b.IP) {
+		return nil
+	}
+
+	mask := net.CIDRMask(aOnes-1, bits)
+	if !a.IP.Mask(mask).Equal(b.IP.Mask(mask)) {
+		return nil
+	}
+
+	return &net.IPNet{IP: a.IP.Mask(mask), Mask: mask}
+}
+
+// mergeCIDRs returns the smallest list of CIDRs covering the same addresses as the given ones, by dropping the CIDRs
+// contained in others and merging adjacent CIDRs. Invalid CIDRs are returned as they are, after the merged ones.
+func mergeCIDRs(cidrs []string) []string {
+	var (
+		ipNets  []*net.IPNet
+		invalid []string
+	)
+
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			invalid = append(invalid, cidr)
+			continue
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+
+	// Merging two networks may make their parent adjacent to another network, so networks are merged until none is.
+	for merged := true; merged; {
+		sort.Slice(ipNets, func(i, j int) bool { return compareIPNets(ipNets[i], ipNets[j]) < 0 })
+
+		merged = false
+		var result []*net.IPNet
+		for _, ipNet := range ipNets {
+			if n := len(result); n > 0 {
+				if containsIPNet(result[n-1], ipNet) {
+					continue
+				}
+
+				if parent := getParentIPNet(result[n-1], ipNet); parent != nil {
+					result[n-1] = parent
+					merged = true
+					continue
+				}
+			}
+			result = append(result, ipNet)
+		}
+		ipNets = result
+	}
+
+	var merged []string
+	for _, ipNet := range ipNets {
+		merged = append(merged, ipNet.String())
+	}
+	sort.Strings(invalid)
+
+	return append(merged, util.UniqueStrSlice(invalid)...)
+}
+
+// peerRule is a rule accepting the packets of a target set from or to a peer.
+type peerRule struct {
+	entry  *iptm.IptEntry
+	target []string // Specs matching the target set.
+	peer   []string // Specs matching the peer.
+}
+
+// isSetMatch checks if specs match an ipset.
+func isSetMatch(specs []string) bool {
+	return specs[0] == util.IptablesMatchFlag && specs[1] == util.IptablesSetFlag && specs[2] == util.IptablesMatchSetFlag
+}
+
+// getPeerRule returns the target set and peer of a rule accepting the packets of a target set from or to a peer set.
+func getPeerRule(entry *iptm.IptEntry) (*peerRule, bool) {
+	var targetFlag string
+	switch entry.Chain {
+	case util.IptablesAzureIngressFromChain:
+		targetFlag = util.IptablesDstFlag
+	case util.IptablesAzureEgressToChain:
+		targetFlag = util.IptablesSrcFlag
+	default:
+		return nil, false
+	}
+
+	specs := entry.Specs
+	if len(specs) != 12 || !isSetMatch(specs[0:5]) || !isSetMatch(specs[5:10]) ||
+		specs[10] != util.IptablesJumpFlag || specs[11] != util.IptablesAccept {
+		return nil, false
+	}
+
+	target, peer := specs[0:5], specs[5:10]
+	if peer[4] == targetFlag {
+		target, peer = peer, target
+	}
+
+	if target[4] != targetFlag || peer[4] == targetFlag {
+		return nil, false
+	}
+
+	return &peerRule{entry: entry, target: target, peer: peer}, true
+}
+
+// sharePeerChains moves the rules accepting the packets of a target set from or to several peers to a peer chain,
+// and returns the rules with a jump of the target set to the peer chain, followed by the rules of the peer chain,
+// in place of the rules of the target set.
+func sharePeerChains(entries []*iptm.IptEntry) []*iptm.IptEntry {
+	var keys []string
+	groups := make(map[string][]*peerRule)
+	for _, entry := range entries {
+		if rule, ok := getPeerRule(entry); ok {
+			key := entry.Chain + " " + strings.Join(rule.target, " ")
+			if _, exists := groups[key]; !exists {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], rule)
+		}
+	}
+
+	shared := make(map[*iptm.IptEntry][]*iptm.IptEntry)
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < minPeerChainRules {
+			continue
+		}
+
+		// Peer chains are named after their rules, whatever the order of the peers in the policy.
+		sort.Slice(group, func(i, j int) bool {
+			return strings.Join(group[i].peer, " ") < strings.Join(group[j].peer, " ")
+		})
+
+		peers := make([]string, len(group))
+		for i, rule := range group {
+			peers[i] = strings.Join(rule.peer, " ")
+		}
+
+		first := group[0]
+		chain := peerChainNames.get(first.entry.Chain + "\n" + strings.Join(peers, "\n"))
+
+		rules := []*iptm.IptEntry{
+			{
+				Name:       chain,
+				HashedName: first.target[3],
+				Chain:      first.entry.Chain,
+				Specs:      append(append([]string(nil), first.target...), util.IptablesJumpFlag, chain),
+			},
+		}
+
+		for _, rule := range group {
+			rules = append(rules, &iptm.IptEntry{
+				Name:       rule.entry.Name,
+				HashedName: rule.peer[3],
+				Chain:      chain,
+				Specs:      append(append([]string(nil), rule.peer...), util.IptablesJumpFlag, util.IptablesAccept),
+			})
+			shared[rule.entry] = nil
+		}
+		shared[first.entry] = rules
+	}
+
+	var result []*iptm.IptEntry
+	for _, entry := range entries {
+		if rules, exists := shared[entry]; exists {
+			result = append(result, rules...)
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	return result
+}
+
+// peerChainNameStore names the peer chains after their rules. Rules whose hash is taken by other rules are hashed
+// again with a counter until a free name is found, like the names of the ipsets.
+type peerChainNameStore struct {
+	sync.Mutex
+	names  map[string]string // rules -> chain.
+	chains map[string]string // chain -> rules.
+}
+
+// peerChainNames is the store the peer chains are named with.
+var peerChainNames = &peerChainNameStore{
+	names:  make(map[string]string),
+	chains: make(map[string]string),
+}
+
+// hashPeerChainName returns the name of the peer chain of rules for the given collision count.
+func hashPeerChainName(rules string, collisions int) string {
+	h := fnv.New64a()
+	h.Write([]byte(rules))
+	if collisions > 0 {
+		h.Write([]byte("\x00" + strconv.Itoa(collisions)))
+	}
+
+	// 36^peerChainHashLength is less than 2^64.
+	modulus := uint64(1)
+	for i := 0; i < peerChainHashLength; i++ {
+		modulus *= 36
+	}
+
+	return util.IptablesAzurePeerChainPrefix + fmt.Sprintf("%0*s", peerChainHashLength, strconv.FormatUint(h.Sum64()%modulus, 36))
+}
+
+// get returns the name of the peer chain of rules, giving it one if it has none.
+func (store *peerChainNameStore) get(rules string) string {
+	store.Lock()
+	defer store.Unlock()
+
+	if chain, exists := store.names[rules]; exists {
+		return chain
+	}
+
+	chain := hashPeerChainName(rules, 0)
+	for collisions := 1; store.chains[chain] != ""; collisions++ {
+		chain = hashPeerChainName(rules, collisions)
+	}
+
+	store.names[rules] = chain
+	store.chains[chain] = rules
+
+	return chain
+}
+
+// release forgets the rules of a peer chain no policy uses anymore, so that its name can be given to other rules.
+func (store *peerChainNameStore) release(chain string) {
+	store.Lock()
+	defer store.Unlock()
+
+	rules, exists := store.chains[chain]
+	if !exists {
+		return
+	}
+
+	delete(store.names, rules)
+	delete(store.chains, chain)
+}
+
+// policyRefs counts the programmed network policies using each rule, peer chain and ipBlock set, and keeps the
+// translation each policy was programmed with, so that deleting a policy doesn't translate the other ones again.
+type policyRefs struct {
+	policies map[string]*optimizedPolicy // policy name -> programmed translation.
+	rules    map[string]int              // rule key -> policies.
+	chains   map[string]int              // peer chain -> policies.
+	sets     map[string]int              // ipBlock set -> policies.
+}
+
+// newPolicyRefs creates the counts of a namespace without programmed policies.
+func newPolicyRefs() *policyRefs {
+	return &policyRefs{
+		policies: make(map[string]*optimizedPolicy),
+		rules:    make(map[string]int),
+		chains:   make(map[string]int),
+		sets:     make(map[string]int),
+	}
+}
+
+// add counts the rules, peer chains and ipBlock sets of a programmed policy, replacing those it was programmed with.
+func (refs *policyRefs) add(name string, policy *optimizedPolicy) {
+	refs.remove(name)
+
+	refs.policies[name] = policy
+	for _, key := range policy.ruleKeys() {
+		refs.rules[key]++
+	}
+	for _, chain := range policy.peerChains() {
+		refs.chains[chain]++
+	}
+	for set := range policy.ipBlockSets {
+		refs.sets[set]++
+	}
+}
+
+// remove stops counting the rules, peer chains and ipBlock sets of a deleted policy, and returns the translation it
+// was programmed with, or nil if it wasn't.
+func (refs *policyRefs) remove(name string) *optimizedPolicy {
+	policy, exists := refs.policies[name]
+	if !exists {
+		return nil
+	}
+
+	delete(refs.policies, name)
+	for _, key := range policy.ruleKeys() {
+		if refs.rules[key]--; refs.rules[key] <= 0 {
+			delete(refs.rules, key)
+		}
+	}
+	for _, chain := range policy.peerChains() {
+		if refs.chains[chain]--; refs.chains[chain] <= 0 {
+			delete(refs.chains, chain)
+		}
+	}
+	for set := range policy.ipBlockSets {
+		if refs.sets[set]--; refs.sets[set] <= 0 {
+			delete(refs.sets, set)
+		}
+	}
+
+	return policy
+}
+
+// ruleKeys returns the keys of the rules of a policy.
+func (policy *optimizedPolicy) ruleKeys() []string {
+	keys := make([]string, len(policy.entries))
+	for i, entry := range policy.entries {
+		keys[i] = getEntryKey(entry)
+	}
+
+	return util.UniqueStrSlice(keys)
+}
+
+// peerChains returns the peer chains the rules of a policy are in.
+func (policy *optimizedPolicy) peerChains() []string {
+	var chains []string
+	for _, entry := range policy.entries {
+		if strings.HasPrefix(entry.Chain, util.IptablesAzurePeerChainPrefix) {
+			chains = append(chains, entry.Chain)
+		}
+	}
+
+	return util.UniqueStrSlice(chains)
+}
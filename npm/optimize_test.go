@@ -0,0 +1,215 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeCIDRs(t *testing.T) {
+	tests := []struct {
+		cidrs    []string
+		expected []string
+	}{
+		{[]string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/24"}},
+		{[]string{"10.0.1.0/24", "10.0.0.0/24", "10.0.2.0/23"}, []string{"10.0.0.0/22"}},
+		{[]string{"10.0.0.0/8", "10.1.2.0/24", "10.0.0.0/8"}, []string{"10.0.0.0/8"}},
+		{[]string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.1.0/24", "10.0.2.0/24"}},
+		{[]string{"192.168.0.1/24", "fd00::/65", "fd00:0:0:0:8000::/65"}, []string{"192.168.0.0/24", "fd00::/64"}},
+		{[]string{"not-a-cidr", "10.0.0.0/32"}, []string{"10.0.0.0/32", "not-a-cidr"}},
+		// hash:net ipsets don't accept /0 networks, so the halves of the address space are kept.
+		{[]string{"0.0.0.0/1", "128.0.0.0/1"}, []string{"0.0.0.0/1", "128.0.0.0/1"}},
+		{[]string{"0.0.0.0/2", "64.0.0.0/2", "128.0.0.0/1"}, []string{"0.0.0.0/1", "128.0.0.0/1"}},
+		{[]string{"::/1", "8000::/1"}, []string{"::/1", "8000::/1"}},
+	}
+
+	for _, test := range tests {
+		if merged := mergeCIDRs(test.cidrs); !reflect.DeepEqual(merged, test.expected) {
+			t.Errorf("TestMergeCIDRs failed, %v merged to %v, expected %v", test.cidrs, merged, test.expected)
+		}
+	}
+}
+
+// newPeerPolicy returns a policy allowing ingress of the pods labeled app=<app> from the given peers.
+func newPeerPolicy(name string, app string, peers []networkingv1.NetworkPolicyPeer) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-nwpolicy",
+			Name:      name,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": app},
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{From: peers},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func TestOptimizePolicyIPBlocks(t *testing.T) {
+	npObj := newPeerPolicy("allow-ranges", "backend", []networkingv1.NetworkPolicyPeer{
+		{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/25"}},
+		{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.128/25"}},
+		{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.1.0/24"}},
+		{IPBlock: &networkingv1.IPBlock{CIDR: "172.16.0.0/12", Except: []string{"172.16.1.0/24"}}},
+	})
+
+	policy := optimizePolicy(npObj)
+
+	// The ipBlocks without except blocks are merged into one set, the others are kept.
+	merged := util.IPBlockIPSetPrefix + "10.0.0.0/23"
+	exceptSet := util.IPBlockIPSetPrefix + "172.16.0.0/12" + util.IPBlockExceptSeparator + "172.16.1.0/24"
+	if len(policy.ipBlockSets) != 2 || !reflect.DeepEqual(policy.ipBlockSets[merged], []string{"10.0.0.0/23"}) ||
+		policy.ipBlockSets[exceptSet] == nil {
+		t.Errorf("TestOptimizePolicyIPBlocks failed, unexpected ipBlock sets %v", policy.ipBlockSets)
+	}
+
+	ipBlockEntries := 0
+	for _, entry := range policy.entries {
+		if strings.HasPrefix(entry.Name, util.IPBlockIPSetPrefix) {
+			ipBlockEntries++
+			if entry.Name != merged && entry.Name != exceptSet {
+				t.Errorf("TestOptimizePolicyIPBlocks failed, unexpected rule %+v", entry)
+			}
+		}
+	}
+
+	if ipBlockEntries != 2 {
+		t.Errorf("TestOptimizePolicyIPBlocks failed, expected 2 ipBlock rules, got %d", ipBlockEntries)
+	}
+}
+
+func TestOptimizePolicyPeerChains(t *testing.T) {
+	peers := []networkingv1.NetworkPolicyPeer{
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}},
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "monitoring"}}},
+		{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/24"}},
+	}
+	reversed := []networkingv1.NetworkPolicyPeer{peers[2], peers[1], peers[0]}
+
+	backend := optimizePolicy(newPeerPolicy("allow-backend", "backend", peers))
+	database := optimizePolicy(newPeerPolicy("allow-database", "database", reversed))
+
+	var (
+		backendChain  string
+		databaseChain string
+	)
+	counts := make(map[string]int)
+	for _, entry := range backend.entries {
+		counts[entry.Chain]++
+		if entry.Chain == util.IptablesAzureIngressFromChain {
+			backendChain = entry.Specs[len(entry.Specs)-1]
+		}
+	}
+
+	for _, entry := range database.entries {
+		if entry.Chain == util.IptablesAzureIngressFromChain {
+			databaseChain = entry.Specs[len(entry.Specs)-1]
+		}
+	}
+
+	// The target set jumps to a peer chain accepting the packets of the peers.
+	if !strings.HasPrefix(backendChain, util.IptablesAzurePeerChainPrefix) || counts[util.IptablesAzureIngressFromChain] != 1 ||
+		counts[backendChain] != len(peers) {
+		t.Errorf("TestOptimizePolicyPeerChains failed, unexpected rules %v", counts)
+	}
+
+	if len(backendChain+util.IptablesShadowChainSuffix) > 28 {
+		t.Errorf("TestOptimizePolicyPeerChains failed, peer chain name %s is too long", backendChain)
+	}
+
+	// Policies allowing the same peers share the peer chain, whatever their order.
+	if databaseChain != backendChain {
+		t.Errorf("TestOptimizePolicyPeerChains failed, policies with the same peers use chains %s and %s", backendChain, databaseChain)
+	}
+
+	other := optimizePolicy(newPeerPolicy("allow-other", "backend", peers[:2]))
+	for _, entry := range other.entries {
+		if entry.Chain == util.IptablesAzureIngressFromChain && entry.Specs[len(entry.Specs)-1] == backendChain {
+			t.Errorf("TestOptimizePolicyPeerChains failed, policies with different peers share chain %s", backendChain)
+		}
+	}
+
+	// Target sets allowed from a single peer keep their rule.
+	single := optimizePolicy(newPeerPolicy("allow-single", "backend", peers[:1]))
+	for _, entry := range single.entries {
+		if strings.HasPrefix(entry.Chain, util.IptablesAzurePeerChainPrefix) {
+			t.Errorf("TestOptimizePolicyPeerChains failed, unexpected peer chain rule %+v", entry)
+		}
+	}
+}
+
+func TestPeerChainNames(t *testing.T) {
+	store := &peerChainNameStore{
+		names:  make(map[string]string),
+		chains: make(map[string]string),
+	}
+
+	first := store.get("rules")
+	if again := store.get("rules"); again != first {
+		t.Errorf("TestPeerChainNames failed, rules named %s and %s", first, again)
+	}
+
+	// Rules whose hash is taken get another name.
+	store.chains[hashPeerChainName("other rules", 0)] = "colliding rules"
+	if other := store.get("other rules"); other != hashPeerChainName("other rules", 1) {
+		t.Errorf("TestPeerChainNames failed, colliding rules named %s", other)
+	}
+
+	store.release(first)
+	if _, exists := store.names["rules"]; exists {
+		t.Errorf("TestPeerChainNames failed, released chain %s is still named", first)
+	}
+}
+
+func TestPolicyRefs(t *testing.T) {
+	peers := []networkingv1.NetworkPolicyPeer{
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}},
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "monitoring"}}},
+		{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/24"}},
+	}
+
+	backend := optimizePolicy(newPeerPolicy("allow-backend", "backend", peers))
+	database := optimizePolicy(newPeerPolicy("allow-database", "database", peers))
+
+	refs := newPolicyRefs()
+	refs.add("allow-backend", backend)
+	refs.add("allow-database", database)
+
+	// Adding a policy again replaces its counts.
+	refs.add("allow-database", database)
+
+	chains := backend.peerChains()
+	if len(chains) != 1 || refs.chains[chains[0]] != 2 {
+		t.Errorf("TestPolicyRefs failed, unexpected peer chain counts %v", refs.chains)
+	}
+
+	if removed := refs.remove("allow-database"); removed != database {
+		t.Errorf("TestPolicyRefs failed, removed policy %+v instead of the programmed one", removed)
+	}
+
+	// The peer chain and ipBlock set are still used by the other policy.
+	if refs.chains[chains[0]] != 1 || len(refs.sets) != 1 {
+		t.Errorf("TestPolicyRefs failed, unexpected counts %v %v after removing a policy", refs.chains, refs.sets)
+	}
+
+	for _, key := range backend.ruleKeys() {
+		if refs.rules[key] != 1 {
+			t.Errorf("TestPolicyRefs failed, rule %s counted %d times", key, refs.rules[key])
+		}
+	}
+
+	refs.remove("allow-backend")
+	if refs.remove("allow-backend") != nil || len(refs.rules) != 0 || len(refs.chains) != 0 || len(refs.sets) != 0 {
+		t.Errorf("TestPolicyRefs failed, counts left after removing all policies: %v %v %v", refs.rules, refs.chains, refs.sets)
+	}
+}
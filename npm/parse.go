@@ -4,19 +4,161 @@ package npm
 
 import (
 	"fmt"
+	"net"
+	"sort"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm/iptm"
 	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // azureNpmPrefix defines prefix for ipset.
 const azureNpmPrefix string = "azure-npm-"
 
 type portsInfo struct {
-	protocol string
-	port     string
+	protocol  string
+	port      string
+	namedPort string
+}
+
+// getPortsInfo returns the protocol and port of a port rule.
+// The protocol defaults to TCP and a missing port matches all ports.
+func getPortsInfo(portRule networkingv1.NetworkPolicyPort) *portsInfo {
+	info := &portsInfo{protocol: string(corev1.ProtocolTCP)}
+	if portRule.Protocol != nil {
+		info.protocol = string(*portRule.Protocol)
+	}
+
+	if portRule.Port != nil {
+		if portRule.Port.Type == intstr.String {
+			info.namedPort = portRule.Port.StrVal
+		} else {
+			info.port = fmt.Sprint(portRule.Port.IntVal)
+		}
+	}
+
+	return info
+}
+
+// getPortSpecs returns the iptables specs matching the protocol and destination port of a port rule.
+// Named ports are resolved by matching the ipset of the pod addresses and ports using that name.
+func getPortSpecs(protPortPair *portsInfo) []string {
+	specs := []string{
+		util.IptablesProtFlag,
+		protPortPair.protocol,
+	}
+
+	if protPortPair.namedPort != "" {
+		return append(specs,
+			util.IptablesMatchFlag,
+			util.IptablesSetFlag,
+			util.IptablesMatchSetFlag,
+			util.GetHashedName(util.NamedPortIPSetPrefix+protPortPair.namedPort),
+			util.IptablesDstFlag+","+util.IptablesDstFlag,
+		)
+	}
+
+	if protPortPair.port != "" {
+		specs = append(specs, util.IptablesDstPortFlag, protPortPair.port)
+	}
+
+	return specs
+}
+
+// getNamedPortSets returns the ipsets of the named ports used by a network policy.
+func getNamedPortSets(npObj *networkingv1.NetworkPolicy) []string {
+	var sets []string
+
+	addPorts := func(ports []networkingv1.NetworkPolicyPort) {
+		for _, portRule := range ports {
+			if info := getPortsInfo(portRule); info.namedPort != "" {
+				sets = append(sets, util.NamedPortIPSetPrefix+info.namedPort)
+			}
+		}
+	}
+
+	for _, rule := range npObj.Spec.Ingress {
+		addPorts(rule.Ports)
+	}
+
+	for _, rule := range npObj.Spec.Egress {
+		addPorts(rule.Ports)
+	}
+
+	return sets
+}
+
+// getIPBlockSetName returns the name of the ipset of an ipBlock peer.
+// Sets are named after the CIDR and except blocks, so that policies with the same ipBlock share them.
+func getIPBlockSetName(ipblock *networkingv1.IPBlock) string {
+	name := util.IPBlockIPSetPrefix + ipblock.CIDR
+	if len(ipblock.Except) > 0 {
+		excepts := append([]string(nil), ipblock.Except...)
+		sort.Strings(excepts)
+		name += util.IPBlockExceptSeparator + strings.Join(excepts, ",")
+	}
+
+	return name
+}
+
+// splitIPBlockCIDR returns the CIDRs hash:net sets hold for the CIDR of an ipBlock. The sets don't accept /0 networks,
+// so the whole address space is split into its two halves.
+func splitIPBlockCIDR(cidr string) []string {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return []string{cidr}
+	}
+
+	if ones, _ := ipNet.Mask.Size(); ones != 0 {
+		return []string{cidr}
+	}
+
+	if ipNet.IP.To4() != nil {
+		return []string{"0.0.0.0/1", "128.0.0.0/1"}
+	}
+
+	return []string{"::/1", "8000::/1"}
+}
+
+// getIPBlockSetMembers returns the members of the ipset of an ipBlock peer.
+// Except blocks are nomatch entries, which hash:net sets match before the broader CIDR,
+// so that an ipBlock takes a single rule whatever the number of except blocks.
+func getIPBlockSetMembers(ipblock *networkingv1.IPBlock) []string {
+	members := splitIPBlockCIDR(ipblock.CIDR)
+	for _, except := range ipblock.Except {
+		for _, cidr := range splitIPBlockCIDR(except) {
+			members = append(members, cidr+" "+util.IpsetNomatchFlag)
+		}
+	}
+
+	return members
+}
+
+// getIPBlockSets returns the ipsets of the ipBlock peers of a network policy, with their members.
+func getIPBlockSets(npObj *networkingv1.NetworkPolicy) map[string][]string {
+	sets := make(map[string][]string)
+
+	addPeers := func(peers []networkingv1.NetworkPolicyPeer) {
+		for _, peer := range peers {
+			if peer.IPBlock != nil && len(peer.IPBlock.CIDR) > 0 {
+				sets[getIPBlockSetName(peer.IPBlock)] = getIPBlockSetMembers(peer.IPBlock)
+			}
+		}
+	}
+
+	for _, rule := range npObj.Spec.Ingress {
+		addPeers(rule.From)
+	}
+
+	for _, rule := range npObj.Spec.Egress {
+		addPeers(rule.To)
+	}
+
+	return sets
 }
 
 func parseIngress(ns string, targetSets []string, rules []networkingv1.NetworkPolicyIngressRule) ([]string, []string, []*iptm.IptEntry) {
@@ -28,7 +170,7 @@ func parseIngress(ns string, targetSets []string, rules []networkingv1.NetworkPo
 		PodNsRuleSets     []string // pod sets listed in Ingress rules.
 		nsRuleLists       []string // namespace sets listed in Ingress rules
 		entries           []*iptm.IptEntry
-		ipblock           *networkingv1.IPBlock
+		ipBlockSets       []string // ipBlock sets listed in Ingress rules.
 	)
 
 	if len(targetSets) == 0 {
@@ -38,12 +180,7 @@ func parseIngress(ns string, targetSets []string, rules []networkingv1.NetworkPo
 
 	for _, rule := range rules {
 		for _, portRule := range rule.Ports {
-			protPortPairSlice = append(protPortPairSlice,
-				&portsInfo{
-					protocol: string(*portRule.Protocol),
-					port:     fmt.Sprint(portRule.Port.IntVal),
-				})
-
+			protPortPairSlice = append(protPortPairSlice, getPortsInfo(portRule))
 			portRuleExists = true
 		}
 
@@ -59,17 +196,11 @@ func parseIngress(ns string, targetSets []string, rules []networkingv1.NetworkPo
 			}
 
 			if fromRule.NamespaceSelector != nil {
-				if len(fromRule.NamespaceSelector.MatchLabels) == 0 {
-					nsRuleLists = append(nsRuleLists, util.KubeAllNamespacesFlag)
-				}
-
-				for nsLabelKey, nsLabelVal := range fromRule.NamespaceSelector.MatchLabels {
-					nsRuleLists = append(nsRuleLists, "ns-"+nsLabelKey+":"+nsLabelVal)
-				}
+				nsRuleLists = append(nsRuleLists, getNsSelectorLists(fromRule.NamespaceSelector)...)
 			}
 
-			if fromRule.IPBlock != nil {
-				ipblock = fromRule.IPBlock
+			if fromRule.IPBlock != nil && len(fromRule.IPBlock.CIDR) > 0 {
+				ipBlockSets = append(ipBlockSets, getIPBlockSetName(fromRule.IPBlock))
 			}
 
 			fromRuleExists = true
@@ -162,11 +293,7 @@ func parseIngress(ns string, targetSets []string, rules []networkingv1.NetworkPo
 					Name:       targetSet,
 					HashedName: hashedTargetSetName,
 					Chain:      util.IptablesAzureIngressPortChain,
-					Specs: []string{
-						util.IptablesProtFlag,
-						protPortPair.protocol,
-						util.IptablesDstPortFlag,
-						protPortPair.port,
+					Specs: append(getPortSpecs(protPortPair),
 						util.IptablesMatchFlag,
 						util.IptablesSetFlag,
 						util.IptablesMatchSetFlag,
@@ -174,7 +301,7 @@ func parseIngress(ns string, targetSets []string, rules []networkingv1.NetworkPo
 						util.IptablesDstFlag,
 						util.IptablesJumpFlag,
 						util.IptablesAzureIngressFromChain,
-					},
+					),
 				}
 				entries = append(entries, entry)
 			}
@@ -199,45 +326,29 @@ func parseIngress(ns string, targetSets []string, rules []networkingv1.NetworkPo
 			continue
 		}
 
-		if ipblock != nil {
-			// Handle ipblock field of NetworkPolicyPeer
-			if len(ipblock.Except) > 0 {
-				for _, except := range ipblock.Except {
-					entry := &iptm.IptEntry{
-						Chain: util.IptablesAzureIngressFromChain,
-						Specs: []string{
-							util.IptablesMatchFlag,
-							util.IptablesSetFlag,
-							util.IptablesMatchSetFlag,
-							hashedTargetSetName,
-							util.IptablesDstFlag,
-							util.IptablesSFlag,
-							except,
-							util.IptablesJumpFlag,
-							util.IptablesDrop,
-						},
-					}
-					entries = append(entries, entry)
-				}
-			}
-
-			if len(ipblock.CIDR) > 0 {
-				cidrEntry := &iptm.IptEntry{
-					Chain: util.IptablesAzureIngressFromChain,
-					Specs: []string{
-						util.IptablesMatchFlag,
-						util.IptablesSetFlag,
-						util.IptablesMatchSetFlag,
-						hashedTargetSetName,
-						util.IptablesDstFlag,
-						util.IptablesSFlag,
-						ipblock.CIDR,
-						util.IptablesJumpFlag,
-						util.IptablesAccept,
-					},
-				}
-				entries = append(entries, cidrEntry)
+		// Handle ipblock field of NetworkPolicyPeer, the except blocks are nomatch entries of the ipBlock set.
+		for _, ipBlockSet := range util.UniqueStrSlice(ipBlockSets) {
+			hashedRuleSetName := util.GetHashedName(ipBlockSet)
+			entry := &iptm.IptEntry{
+				Name:       ipBlockSet,
+				HashedName: hashedRuleSetName,
+				Chain:      util.IptablesAzureIngressFromChain,
+				Specs: []string{
+					util.IptablesMatchFlag,
+					util.IptablesSetFlag,
+					util.IptablesMatchSetFlag,
+					hashedTargetSetName,
+					util.IptablesDstFlag,
+					util.IptablesMatchFlag,
+					util.IptablesSetFlag,
+					util.IptablesMatchSetFlag,
+					hashedRuleSetName,
+					util.IptablesSrcFlag,
+					util.IptablesJumpFlag,
+					util.IptablesAccept,
+				},
 			}
+			entries = append(entries, entry)
 		}
 
 		// Handle PodSelector field of NetworkPolicyPeer.
@@ -303,7 +414,7 @@ func parseEgress(ns string, targetSets []string, rules []networkingv1.NetworkPol
 		PodNsRuleSets     []string // pod sets listed in Egress rules.
 		nsRuleLists       []string // namespace sets listed in Egress rules
 		entries           []*iptm.IptEntry
-		ipblock           *networkingv1.IPBlock
+		ipBlockSets       []string // ipBlock sets listed in Egress rules.
 	)
 
 	if len(targetSets) == 0 {
@@ -313,12 +424,7 @@ func parseEgress(ns string, targetSets []string, rules []networkingv1.NetworkPol
 
 	for _, rule := range rules {
 		for _, portRule := range rule.Ports {
-			protPortPairSlice = append(protPortPairSlice,
-				&portsInfo{
-					protocol: string(*portRule.Protocol),
-					port:     fmt.Sprint(portRule.Port.IntVal),
-				})
-
+			protPortPairSlice = append(protPortPairSlice, getPortsInfo(portRule))
 			portRuleExists = true
 		}
 
@@ -334,17 +440,11 @@ func parseEgress(ns string, targetSets []string, rules []networkingv1.NetworkPol
 			}
 
 			if toRule.NamespaceSelector != nil {
-				if len(toRule.NamespaceSelector.MatchLabels) == 0 {
-					nsRuleLists = append(nsRuleLists, util.KubeAllNamespacesFlag)
-				}
-
-				for nsLabelKey, nsLabelVal := range toRule.NamespaceSelector.MatchLabels {
-					nsRuleLists = append(nsRuleLists, "ns-"+nsLabelKey+":"+nsLabelVal)
-				}
+				nsRuleLists = append(nsRuleLists, getNsSelectorLists(toRule.NamespaceSelector)...)
 			}
 
-			if toRule.IPBlock != nil {
-				ipblock = toRule.IPBlock
+			if toRule.IPBlock != nil && len(toRule.IPBlock.CIDR) > 0 {
+				ipBlockSets = append(ipBlockSets, getIPBlockSetName(toRule.IPBlock))
 			}
 
 			toRuleExists = true
@@ -435,11 +535,7 @@ func parseEgress(ns string, targetSets []string, rules []networkingv1.NetworkPol
 					Name:       targetSet,
 					HashedName: hashedTargetSetName,
 					Chain:      util.IptablesAzureEgressPortChain,
-					Specs: []string{
-						util.IptablesProtFlag,
-						protPortPair.protocol,
-						util.IptablesDstPortFlag,
-						protPortPair.port,
+					Specs: append(getPortSpecs(protPortPair),
 						util.IptablesMatchFlag,
 						util.IptablesSetFlag,
 						util.IptablesMatchSetFlag,
@@ -447,7 +543,7 @@ func parseEgress(ns string, targetSets []string, rules []networkingv1.NetworkPol
 						util.IptablesSrcFlag,
 						util.IptablesJumpFlag,
 						util.IptablesAzureEgressToChain,
-					},
+					),
 				}
 				entries = append(entries, entry)
 			}
@@ -472,45 +568,29 @@ func parseEgress(ns string, targetSets []string, rules []networkingv1.NetworkPol
 			continue
 		}
 
-		if ipblock != nil {
-			// Handle ipblock field of NetworkPolicyPeer
-			if len(ipblock.Except) > 0 {
-				for _, except := range ipblock.Except {
-					entry := &iptm.IptEntry{
-						Chain: util.IptablesAzureEgressToChain,
-						Specs: []string{
-							util.IptablesMatchFlag,
-							util.IptablesSetFlag,
-							util.IptablesMatchSetFlag,
-							hashedTargetSetName,
-							util.IptablesSrcFlag,
-							util.IptablesDFlag,
-							except,
-							util.IptablesJumpFlag,
-							util.IptablesDrop,
-						},
-					}
-					entries = append(entries, entry)
-				}
-			}
-
-			if len(ipblock.CIDR) > 0 {
-				cidrEntry := &iptm.IptEntry{
-					Chain: util.IptablesAzureEgressToChain,
-					Specs: []string{
-						util.IptablesMatchFlag,
-						util.IptablesSetFlag,
-						util.IptablesMatchSetFlag,
-						hashedTargetSetName,
-						util.IptablesSrcFlag,
-						util.IptablesDFlag,
-						ipblock.CIDR,
-						util.IptablesJumpFlag,
-						util.IptablesAccept,
-					},
-				}
-				entries = append(entries, cidrEntry)
+		// Handle ipblock field of NetworkPolicyPeer, the except blocks are nomatch entries of the ipBlock set.
+		for _, ipBlockSet := range util.UniqueStrSlice(ipBlockSets) {
+			hashedRuleSetName := util.GetHashedName(ipBlockSet)
+			entry := &iptm.IptEntry{
+				Name:       ipBlockSet,
+				HashedName: hashedRuleSetName,
+				Chain:      util.IptablesAzureEgressToChain,
+				Specs: []string{
+					util.IptablesMatchFlag,
+					util.IptablesSetFlag,
+					util.IptablesMatchSetFlag,
+					hashedTargetSetName,
+					util.IptablesSrcFlag,
+					util.IptablesMatchFlag,
+					util.IptablesSetFlag,
+					util.IptablesMatchSetFlag,
+					hashedRuleSetName,
+					util.IptablesDstFlag,
+					util.IptablesJumpFlag,
+					util.IptablesAccept,
+				},
 			}
+			entries = append(entries, entry)
 		}
 
 		// Handle PodSelector field of NetworkPolicyPeer.
@@ -625,6 +705,13 @@ func parsePolicy(npObj *networkingv1.NetworkPolicy) ([]string, []string, []*iptm
 		affectedSets = append(affectedSets, affectedSet)
 	}
 
+	// Named ports are resolved through the ipsets the pods using them are added to.
+	resultPodSets = append(resultPodSets, getNamedPortSets(npObj)...)
+
+	// ICMP rules accept in the port chains, before the target sets chain drops the traffic.
+	entries = append(entries, getIcmpEntries(npNs, affectedSets, npObj.ObjectMeta.Annotations)...)
+	entries = append(entries, getFqdnEntries(npObj, affectedSets)...)
+
 	if len(npObj.Spec.PolicyTypes) == 0 {
 		ingressPodSets, ingressNsSets, ingressEntries := parseIngress(npNs, affectedSets, npObj.Spec.Ingress)
 		resultPodSets = append(resultPodSets, ingressPodSets...)
@@ -0,0 +1,243 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetNamedPorts(t *testing.T) {
+	podObj := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Ports: []corev1.ContainerPort{
+						{Name: "http", ContainerPort: 8080},
+						{ContainerPort: 9090},
+						{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP},
+					},
+				},
+			},
+		},
+	}
+
+	namedPorts := getNamedPorts(podObj, "1.2.3.4")
+	if len(namedPorts) != 2 {
+		t.Fatalf("TestGetNamedPorts failed, expected 2 named ports, got %+v", namedPorts)
+	}
+
+	if namedPorts[0].set != util.NamedPortIPSetPrefix+"http" || namedPorts[0].element != "1.2.3.4,tcp:8080" {
+		t.Errorf("TestGetNamedPorts failed, unexpected named port %+v", namedPorts[0])
+	}
+
+	if namedPorts[1].set != util.NamedPortIPSetPrefix+"dns" || namedPorts[1].element != "1.2.3.4,udp:53" {
+		t.Errorf("TestGetNamedPorts failed, unexpected named port %+v", namedPorts[1])
+	}
+}
+
+func TestParsePolicyPorts(t *testing.T) {
+	udp := corev1.ProtocolUDP
+	httpPort := intstr.FromString("http")
+	dnsPort := intstr.FromInt(53)
+
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-nwpolicy",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "backend"},
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Port: &httpPort},
+						{Protocol: &udp, Port: &dnsPort},
+						{Protocol: &udp},
+					},
+				},
+			},
+		},
+	}
+
+	podSets, _, entries := parsePolicy(npObj)
+
+	found := false
+	for _, set := range podSets {
+		found = found || set == util.NamedPortIPSetPrefix+"http"
+	}
+	if !found {
+		t.Errorf("TestParsePolicyPorts failed, named port set missing from %v", podSets)
+	}
+
+	expected := []string{
+		"-p TCP -m set --match-set " + util.GetHashedName(util.NamedPortIPSetPrefix+"http") + " dst,dst ",
+		"-p UDP --dport 53 -m set ",
+		"-p UDP -m set --match-set " + util.GetHashedName(util.KubeAllNamespacesFlag+"-app:backend") + " dst ",
+	}
+
+	for _, prefix := range expected {
+		found := false
+		for _, entry := range entries {
+			if entry.Chain == util.IptablesAzureIngressPortChain && strings.HasPrefix(strings.Join(entry.Specs, " "), prefix) {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("TestParsePolicyPorts failed, no ingress port entry starting with %q", prefix)
+		}
+	}
+}
+
+func TestParsePolicyIPBlock(t *testing.T) {
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-nwpolicy",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "backend"},
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							IPBlock: &networkingv1.IPBlock{
+								CIDR:   "10.0.0.0/8",
+								Except: []string{"10.2.0.0/16", "10.1.0.0/16"},
+							},
+						},
+						{
+							IPBlock: &networkingv1.IPBlock{
+								CIDR: "192.168.0.0/24",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	sets := getIPBlockSets(npObj)
+	exceptSet := util.IPBlockIPSetPrefix + "10.0.0.0/8" + util.IPBlockExceptSeparator + "10.1.0.0/16,10.2.0.0/16"
+	expectedMembers := "10.0.0.0/8,10.2.0.0/16 nomatch,10.1.0.0/16 nomatch"
+	if members := strings.Join(sets[exceptSet], ","); members != expectedMembers {
+		t.Errorf("TestParsePolicyIPBlock failed, unexpected members %q of set %s", members, exceptSet)
+	}
+
+	if len(sets) != 2 {
+		t.Errorf("TestParsePolicyIPBlock failed, unexpected sets %v", sets)
+	}
+
+	// Each ipBlock takes one rule, whatever the number of except blocks.
+	_, _, entries := parsePolicy(npObj)
+	ipBlockEntries := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name, util.IPBlockIPSetPrefix) {
+			ipBlockEntries++
+			if entry.Chain != util.IptablesAzureIngressFromChain || entry.Specs[len(entry.Specs)-1] != util.IptablesAccept {
+				t.Errorf("TestParsePolicyIPBlock failed, unexpected rule %+v", entry)
+			}
+		}
+	}
+
+	if ipBlockEntries != 2 {
+		t.Errorf("TestParsePolicyIPBlock failed, expected 2 ipBlock rules, got %d", ipBlockEntries)
+	}
+}
+
+func TestGetIPBlockSetMembersWholeAddressSpace(t *testing.T) {
+	tests := []struct {
+		ipBlock  networkingv1.IPBlock
+		expected []string
+	}{
+		{networkingv1.IPBlock{CIDR: "0.0.0.0/0"}, []string{"0.0.0.0/1", "128.0.0.0/1"}},
+		{networkingv1.IPBlock{CIDR: "::/0"}, []string{"::/1", "8000::/1"}},
+		{
+			networkingv1.IPBlock{CIDR: "0.0.0.0/0", Except: []string{"10.0.0.0/8"}},
+			[]string{"0.0.0.0/1", "128.0.0.0/1", "10.0.0.0/8 nomatch"},
+		},
+		{networkingv1.IPBlock{CIDR: "10.0.0.0/8"}, []string{"10.0.0.0/8"}},
+	}
+
+	for _, test := range tests {
+		if members := getIPBlockSetMembers(&test.ipBlock); !reflect.DeepEqual(members, test.expected) {
+			t.Errorf("TestGetIPBlockSetMembersWholeAddressSpace failed, members of %+v are %v, expected %v", test.ipBlock, members, test.expected)
+		}
+	}
+}
+
+func TestParsePolicyNsSelectorExpressions(t *testing.T) {
+	nsSelector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"tier": "web"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod", "staging"}},
+			{Key: "team", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"test"}},
+			{Key: "owner", Operator: metav1.LabelSelectorOpExists},
+		},
+	}
+
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-nwpolicy",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "backend"},
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{NamespaceSelector: nsSelector},
+					},
+				},
+			},
+		},
+	}
+
+	// The whole selector is a single list, instead of a list per label.
+	list := getNsSelectorListName(nsSelector)
+	_, nsLists, _ := parsePolicy(npObj)
+	if len(nsLists) != 1 || nsLists[0] != list {
+		t.Errorf("TestParsePolicyNsSelectorExpressions failed, unexpected lists %v", nsLists)
+	}
+
+	selectors := getNsSelectors(npObj)
+	selector, ok := selectors[list]
+	if len(selectors) != 1 || !ok {
+		t.Fatalf("TestParsePolicyNsSelectorExpressions failed, unexpected selectors %v", selectors)
+	}
+
+	nsLabels := []struct {
+		labels  map[string]string
+		matches bool
+	}{
+		{map[string]string{"tier": "web", "env": "prod", "owner": "a"}, true},
+		{map[string]string{"tier": "web", "env": "staging", "team": "dev", "owner": "b"}, true},
+		{map[string]string{"tier": "web", "env": "dev", "owner": "a"}, false},
+		{map[string]string{"tier": "web", "env": "prod", "team": "test", "owner": "a"}, false},
+		{map[string]string{"tier": "web", "env": "prod"}, false},
+		{map[string]string{"env": "prod", "owner": "a"}, false},
+	}
+
+	for _, ns := range nsLabels {
+		if matches := selector.Matches(labels.Set(ns.labels)); matches != ns.matches {
+			t.Errorf("TestParsePolicyNsSelectorExpressions failed, selector matches %v: %v", ns.labels, matches)
+		}
+	}
+
+	// Selectors with labels only keep a list per label.
+	if lists := getNsSelectorLists(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}); len(lists) != 1 || lists[0] != "ns-env:prod" {
+		t.Errorf("TestParsePolicyNsSelectorExpressions failed, unexpected label lists %v", lists)
+	}
+}
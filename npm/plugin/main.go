@@ -3,10 +3,12 @@
 package main
 
 import (
-	"time"
+	"flag"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm"
+	"github.com/Azure/azure-container-networking/npm/iptm"
+	"github.com/Azure/azure-container-networking/npm/util"
 
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
@@ -25,12 +27,34 @@ func initLogging() error {
 		return err
 	}
 
+	if err := log.ConfigureFromEnv(); err != nil {
+		log.Printf("[cni-npm] Failed to apply log configuration from environment, err:%v.\n", err)
+	}
+
 	return nil
 }
 
 func main() {
 	var err error
 
+	statsAddress := flag.String("stats-address", util.NpmStatsAddress, "Address to serve namespace enforcement statistics and the debug state on, empty to disable")
+	enableIPv6 := flag.Bool("ipv6", false, "Also enforce policies for IPv6 pods with ip6tables and IPv6 ipsets, for dual-stack clusters")
+	reconcileWorkers := flag.Int("reconcile-workers", util.NpmDefaultReconcileWorkers, "Number of workers reconciling the objects of events, the objects of a namespace being reconciled in order by one worker")
+	reconcileRetries := flag.Int("reconcile-retries", util.NpmDefaultReconcileRetries, "Number of times a failed object is reconciled again, with an exponential backoff per object")
+	resyncPeriod := flag.Duration("resync-period", util.NpmDefaultResyncPeriod, "Period at which the informers reconcile their whole cache again, 0 to disable")
+	kubeAPIQPS := flag.Float64("kube-api-qps", util.NpmDefaultKubeAPIQPS, "Sustained rate of the requests to the kube-apiserver")
+	kubeAPIBurst := flag.Int("kube-api-burst", util.NpmDefaultKubeAPIBurst, "Burst of the requests to the kube-apiserver")
+	iptablesBackend := flag.String("iptables-backend", util.IptablesBackendAuto, "Backend programming the rules: legacy or nft for the variants of the iptables binaries, nftables to program rules and sets natively with nft, or auto to detect the one to use")
+	consistencyInterval := flag.Duration("consistency-interval", util.NpmDefaultConsistencyInterval, "Interval at which ipsets and iptables chains, or the HNS ACLs of the endpoints on Windows, are checked for drift from the programmed state and repaired, 0 to disable")
+	fqdnRefreshInterval := flag.Duration("fqdn-refresh-interval", util.NpmDefaultFqdnRefreshInterval, "Interval at which the names of FQDN egress rules are resolved again")
+	fqdnAddressTTL := flag.Duration("fqdn-address-ttl", util.NpmDefaultFqdnAddressTTL, "Time the addresses of a name of an FQDN egress rule stay allowed after they were last resolved")
+	fqdnSnooping := flag.Bool("fqdn-dns-snooping", true, "Also learn the addresses of the names of FQDN egress rules from the DNS answers received by pods, so that the addresses they connect to are allowed")
+	auditMode := flag.Bool("audit", false, "Log the packets policies would drop instead of dropping them, and report them to telemetry, to validate policies before enforcing them")
+	flag.Parse()
+
+	util.IsIPv6Enabled = *enableIPv6
+	util.IsAuditModeEnabled = *auditMode
+
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[cni-npm] recovered from error: %v", err)
@@ -41,12 +65,20 @@ func main() {
 		panic(err.Error())
 	}
 
+	if err = iptm.SetBackend(*iptablesBackend); err != nil {
+		panic(err.Error())
+	}
+
 	// Creates the in-cluster config
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		panic(err.Error())
 	}
 
+	// Limits the requests to the kube-apiserver, so that npm on every node of a large cluster doesn't overload it.
+	config.QPS = float32(*kubeAPIQPS)
+	config.Burst = *kubeAPIBurst
+
 	// Creates the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -54,9 +86,25 @@ func main() {
 		panic(err.Error())
 	}
 
-	factory := informers.NewSharedInformerFactory(clientset, time.Hour*24)
+	factory := informers.NewSharedInformerFactory(clientset, *resyncPeriod)
+
+	npMgr := npm.NewNetworkPolicyManager(clientset, factory, version, *reconcileWorkers, *reconcileRetries)
+	if *auditMode {
+		if err = npMgr.StartAuditLogger(wait.NeverStop); err != nil {
+			log.Printf("[Azure-NPM] audit logger failed to start with error %v.", err)
+			panic(err.Error())
+		}
+	}
+
+	// The DNS answers are only logged to the snooper once it listens.
+	if *fqdnSnooping {
+		if err = npMgr.StartDNSSnooper(*fqdnAddressTTL, wait.NeverStop); err != nil {
+			log.Printf("[Azure-NPM] DNS snooper failed to start with error %v, the names of FQDN egress rules are only resolved by npm.", err)
+		} else {
+			util.IsFqdnSnoopingEnabled = true
+		}
+	}
 
-	npMgr := npm.NewNetworkPolicyManager(clientset, factory, version)
 	err = npMgr.Run(wait.NeverStop)
 	if err != nil {
 		log.Printf("[Azure-NPM] npm failed with error %v.", err)
@@ -65,5 +113,19 @@ func main() {
 
 	go npMgr.RunReportManager()
 
+	if *consistencyInterval > 0 {
+		go npMgr.RunConsistencyReconciler(*consistencyInterval, wait.NeverStop)
+	}
+
+	go npMgr.RunFqdnResolver(*fqdnRefreshInterval, *fqdnAddressTTL, wait.NeverStop)
+
+	if *statsAddress != "" {
+		go func() {
+			if err := npMgr.RunStatsServer(*statsAddress); err != nil {
+				log.Printf("[Azure-NPM] stats server failed with error %v.", err)
+			}
+		}()
+	}
+
 	select {}
 }
@@ -3,6 +3,7 @@
 package npm
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -22,22 +23,96 @@ func isSystemPod(podObj *corev1.Pod) bool {
 	return podObj.ObjectMeta.Namespace == util.KubeSystemFlag
 }
 
+// namedPort is the ipset entry of a named container port of a pod.
+type namedPort struct {
+	set     string
+	element string
+}
+
+// getNamedPorts returns the ipset entries of the named container ports of a pod.
+func getNamedPorts(podObj *corev1.Pod, podIP string) []namedPort {
+	var namedPorts []namedPort
+
+	for _, container := range podObj.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == "" {
+				continue
+			}
+
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+
+			namedPorts = append(namedPorts, namedPort{
+				set:     util.NamedPortIPSetPrefix + port.Name,
+				element: fmt.Sprintf("%s,%s:%d", podIP, strings.ToLower(string(protocol)), port.ContainerPort),
+			})
+		}
+	}
+
+	return namedPorts
+}
+
 // AddPod handles adding pod ip to its label's ipset.
+// The ipsets are applied without holding the lock, so that the pods reconciled by the other workers are changed
+// meanwhile and applied with the same ipset restore.
 func (npMgr *NetworkPolicyManager) AddPod(podObj *corev1.Pod) error {
-	npMgr.Lock()
-	defer npMgr.Unlock()
-
 	if !isValidPod(podObj) {
 		return nil
 	}
 
+	podNs := podObj.ObjectMeta.Namespace
+
+	npMgr.Lock()
 	var err error
+	// The pods of namespaces exempted from enforcement are only tracked.
+	enforcement := npMgr.getNsEnforcement(podNs)
+	if !enforcement.disabled {
+		err = npMgr.addPodToSets(podObj)
+	}
+	if err == nil {
+		enforcement.pods[podObj.ObjectMeta.UID] = podObj
+	}
+	ipsMgr := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr
+	npMgr.Unlock()
 
-	defer func() {
-		if err = npMgr.UpdateAndSendReport(err, util.AddPodEvent); err != nil {
-			log.Printf("Error sending NPM telemetry report")
+	if err == nil {
+		if err = ipsMgr.Apply(); err != nil {
+			log.Printf("Error applying pod ipsets.\n")
 		}
-	}()
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	npMgr.recordReconcile(podNs, err)
+	if reportErr := npMgr.UpdateAndSendReport(err, util.AddPodEvent); reportErr != nil {
+		log.Printf("Error sending NPM telemetry report")
+	}
+
+	return err
+}
+
+// addPod adds the pod ip to the ipsets of its namespace, labels and named ports, and applies them.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) addPod(podObj *corev1.Pod) error {
+	if err := npMgr.addPodToSets(podObj); err != nil {
+		return err
+	}
+
+	if err := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr.Apply(); err != nil {
+		log.Printf("Error applying pod ipsets.\n")
+		return err
+	}
+
+	return nil
+}
+
+// addPodToSets adds the pod ip to the ipsets of its namespace, labels and named ports, without applying them.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) addPodToSets(podObj *corev1.Pod) error {
+	var err error
 
 	podNs := podObj.ObjectMeta.Namespace
 	podName := podObj.ObjectMeta.Name
@@ -72,6 +147,20 @@ func (npMgr *NetworkPolicyManager) AddPod(podObj *corev1.Pod) error {
 		labelKeys = append(labelKeys, labelKey)
 	}
 
+	// Add the pod to the ipsets of its named ports.
+	for _, namedPort := range getNamedPorts(podObj, podIP) {
+		if err = ipsMgr.CreateSetWithType(namedPort.set, util.IpsetIPPortHashFlag); err != nil {
+			log.Printf("Error creating named port ipset.\n")
+			return err
+		}
+
+		log.Printf("Adding pod %s to ipset %s\n", namedPort.element, namedPort.set)
+		if err = ipsMgr.AddToSet(namedPort.set, namedPort.element); err != nil {
+			log.Printf("Error adding pod to named port ipset.\n")
+			return err
+		}
+	}
+
 	npMgr.clusterState.PodCount++
 
 	ns, err := newNs(podNs)
@@ -128,21 +217,64 @@ func (npMgr *NetworkPolicyManager) UpdatePod(oldPodObj, newPodObj *corev1.Pod) e
 }
 
 // DeletePod handles deleting pod from its label's ipset.
+// The ipsets are applied without holding the lock, like those of AddPod.
 func (npMgr *NetworkPolicyManager) DeletePod(podObj *corev1.Pod) error {
-	npMgr.Lock()
-	defer npMgr.Unlock()
-
 	if !isValidPod(podObj) {
 		return nil
 	}
 
+	podNs := podObj.ObjectMeta.Namespace
+
+	npMgr.Lock()
 	var err error
+	// The pods of namespaces exempted from enforcement are not in any ipset.
+	enforcement := npMgr.getNsEnforcement(podNs)
+	if !enforcement.disabled {
+		err = npMgr.deletePodFromSets(podObj)
+	}
+	if err == nil {
+		delete(enforcement.pods, podObj.ObjectMeta.UID)
+	}
+	ipsMgr := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr
+	npMgr.Unlock()
 
-	defer func() {
-		if err = npMgr.UpdateAndSendReport(err, util.DeletePodEvent); err != nil {
-			log.Printf("Error sending NPM telemetry report")
+	if err == nil {
+		if err = ipsMgr.Apply(); err != nil {
+			log.Printf("Error applying pod ipsets.\n")
 		}
-	}()
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	npMgr.recordReconcile(podNs, err)
+	if reportErr := npMgr.UpdateAndSendReport(err, util.DeletePodEvent); reportErr != nil {
+		log.Printf("Error sending NPM telemetry report")
+	}
+
+	return err
+}
+
+// deletePod deletes the pod ip from the ipsets of its namespace, labels and named ports, and applies them.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deletePod(podObj *corev1.Pod) error {
+	if err := npMgr.deletePodFromSets(podObj); err != nil {
+		return err
+	}
+
+	if err := npMgr.nsMap[util.KubeAllNamespacesFlag].ipsMgr.Apply(); err != nil {
+		log.Printf("Error applying pod ipsets.\n")
+		return err
+	}
+
+	return nil
+}
+
+// deletePodFromSets deletes the pod ip from the ipsets of its namespace, labels and named ports, without applying
+// them.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) deletePodFromSets(podObj *corev1.Pod) error {
+	var err error
 
 	podNs := podObj.ObjectMeta.Namespace
 	podName := podObj.ObjectMeta.Name
@@ -172,6 +304,14 @@ func (npMgr *NetworkPolicyManager) DeletePod(podObj *corev1.Pod) error {
 		}
 	}
 
+	// Delete the pod from the ipsets of its named ports.
+	for _, namedPort := range getNamedPorts(podObj, podIP) {
+		if err = ipsMgr.DeleteFromSet(namedPort.set, namedPort.element); err != nil {
+			log.Printf("Error deleting pod from named port ipset.\n")
+			return err
+		}
+	}
+
 	npMgr.clusterState.PodCount--
 
 	return nil
@@ -0,0 +1,189 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// reconcileQueue dispatches the keys of the objects to reconcile to a pool of workers, sharded by namespace.
+// Each shard is a workqueue processed by one worker, so the keys of a namespace are reconciled in order, while
+// the keys of other namespaces make progress on the other shards, and one large namespace can only slow down its
+// own shard. A key added again before it is processed is reconciled once, from the current state of its object.
+// A key that fails is added back after a per key exponential backoff, without blocking its worker, up to the
+// retries of the queue. The object is read again when it is retried, so a retry never replays a stale event.
+type reconcileQueue struct {
+	sync.Mutex
+	shards    []workqueue.RateLimitingInterface
+	retries   int
+	reconcile func(key string) error
+	done      func(key string, err error)
+	pending   map[string]bool
+	flushes   []*queueFlush
+}
+
+// queueFlush tracks the keys a flush waits for, and those given up on.
+type queueFlush struct {
+	keys   map[string]bool
+	failed []string
+	doneCh chan struct{}
+}
+
+// newReconcileQueue creates a queue with the given number of workers, one per shard. Failed keys are retried up
+// to retries times, waiting from initialDelay up to maxDelay before each retry. The delay doubles with each
+// failure of the key, and is reset once the key is reconciled.
+func newReconcileQueue(workers int, retries int, initialDelay, maxDelay time.Duration) *reconcileQueue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	q := &reconcileQueue{
+		shards:  make([]workqueue.RateLimitingInterface, workers),
+		retries: retries,
+		pending: make(map[string]bool),
+	}
+	for i := range q.shards {
+		q.shards[i] = workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(initialDelay, maxDelay))
+	}
+
+	return q
+}
+
+// run starts the workers, which reconcile the keys with reconcile and stop when stopCh is closed.
+// done, if not nil, is called with the result of the last attempt of a key.
+func (q *reconcileQueue) run(stopCh <-chan struct{}, reconcile func(key string) error, done func(key string, err error)) {
+	q.reconcile = reconcile
+	q.done = done
+
+	for _, shard := range q.shards {
+		shard := shard
+		go wait.Until(func() {
+			for q.processNextKey(shard) {
+			}
+		}, time.Second, stopCh)
+	}
+
+	go func() {
+		<-stopCh
+		for _, shard := range q.shards {
+			shard.ShutDown()
+		}
+	}()
+}
+
+// add schedules the reconciliation of a key.
+func (q *reconcileQueue) add(key string) {
+	q.Lock()
+	q.pending[key] = true
+	q.Unlock()
+
+	q.shards[q.shardIndex(key)].Add(key)
+}
+
+// shardIndex returns the shard of a key, from the hash of the namespace of its object.
+// A namespace is on the same shard as the objects it contains.
+func (q *reconcileQueue) shardIndex(key string) int {
+	shardKey := key
+	if kind, objNs, objName, err := splitObjectKey(key); err == nil {
+		shardKey = objNs
+		if kind == namespaceKind {
+			shardKey = objName
+		}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(shardKey))
+	return int(h.Sum32() % uint32(len(q.shards)))
+}
+
+// processNextKey reconciles the next key of a shard, and adds it back with a backoff if it fails.
+// It returns false once the shard is shut down.
+func (q *reconcileQueue) processNextKey(shard workqueue.RateLimitingInterface) bool {
+	item, shutdown := shard.Get()
+	if shutdown {
+		return false
+	}
+	defer shard.Done(item)
+
+	key := item.(string)
+	err := q.reconcile(key)
+	if err != nil {
+		if requeues := shard.NumRequeues(key); requeues < q.retries {
+			log.Printf("[Azure-NPM] Reconciling %v failed, retrying: %v", key, err)
+			shard.AddRateLimited(key)
+			return true
+		}
+
+		log.Printf("[Azure-NPM] Giving up on reconciling %v after %d attempts: %v", key, q.retries+1, err)
+	}
+
+	shard.Forget(key)
+	q.finish(key, err)
+
+	return true
+}
+
+// finish records that a key was reconciled, or given up on.
+func (q *reconcileQueue) finish(key string, err error) {
+	q.Lock()
+	delete(q.pending, key)
+	flushes := q.flushes[:0]
+	for _, f := range q.flushes {
+		if !f.keys[key] {
+			flushes = append(flushes, f)
+			continue
+		}
+
+		delete(f.keys, key)
+		if err != nil {
+			f.failed = append(f.failed, key)
+		}
+		if len(f.keys) == 0 {
+			close(f.doneCh)
+			continue
+		}
+		flushes = append(flushes, f)
+	}
+	q.flushes = flushes
+	q.Unlock()
+
+	if q.done != nil {
+		q.done(key, err)
+	}
+}
+
+// flush waits until the keys added so far are reconciled, or given up on, and returns the keys given up on.
+// It returns false if stopCh is closed first.
+func (q *reconcileQueue) flush(stopCh <-chan struct{}) ([]string, bool) {
+	q.Lock()
+	f := &queueFlush{keys: make(map[string]bool), doneCh: make(chan struct{})}
+	for key := range q.pending {
+		f.keys[key] = true
+	}
+
+	if len(f.keys) == 0 {
+		close(f.doneCh)
+	} else {
+		q.flushes = append(q.flushes, f)
+	}
+	q.Unlock()
+
+	select {
+	case <-stopCh:
+		return nil, false
+	case <-f.doneCh:
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	sort.Strings(f.failed)
+	return f.failed, true
+}
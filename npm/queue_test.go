@@ -0,0 +1,327 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Tests that a key is never reconciled by two workers at once, and that adding it again while it is reconciled
+// reconciles it once more.
+func TestReconcileQueueKeys(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	var lock sync.Mutex
+	active := make(map[string]bool)
+	reconciled := make(map[string]int)
+	concurrent := false
+
+	q := newReconcileQueue(4, 0, time.Millisecond, time.Millisecond)
+	q.run(stopCh, func(key string) error {
+		lock.Lock()
+		if active[key] {
+			concurrent = true
+		}
+		active[key] = true
+		lock.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		lock.Lock()
+		active[key] = false
+		reconciled[key]++
+		lock.Unlock()
+		return nil
+	}, nil)
+
+	for i := 0; i < 50; i++ {
+		for _, key := range []string{"pod/ns-a/a", "pod/ns-a/b", "pod/ns-b/a"} {
+			q.add(key)
+		}
+	}
+
+	if _, ok := q.flush(stopCh); !ok {
+		t.Fatalf("Flush stopped unexpectedly")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if concurrent {
+		t.Errorf("A key was reconciled by two workers at once")
+	}
+
+	for _, key := range []string{"pod/ns-a/a", "pod/ns-a/b", "pod/ns-b/a"} {
+		if reconciled[key] == 0 || reconciled[key] > 50 {
+			t.Errorf("Key %v reconciled %d times", key, reconciled[key])
+		}
+	}
+}
+
+// Tests that a key waiting for its retry doesn't delay the other keys.
+func TestReconcileQueueIsolation(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	done := make(chan struct{})
+	q := newReconcileQueue(1, 5, time.Hour, time.Hour)
+	q.run(stopCh, func(key string) error {
+		if key == "pod/ns/failing" {
+			return fmt.Errorf("transient failure")
+		}
+		close(done)
+		return nil
+	}, nil)
+
+	q.add("pod/ns/failing")
+	q.add("pod/ns/other")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Key was delayed by the retry of another key")
+	}
+}
+
+// Tests that the keys of a namespace are reconciled in the order they were added.
+func TestReconcileQueueOrdering(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	var lock sync.Mutex
+	reconciled := make(map[string][]string)
+
+	q := newReconcileQueue(4, 0, time.Millisecond, time.Millisecond)
+	q.run(stopCh, func(key string) error {
+		_, objNs, objName, _ := splitObjectKey(key)
+		lock.Lock()
+		reconciled[objNs] = append(reconciled[objNs], objName)
+		lock.Unlock()
+		return nil
+	}, nil)
+
+	for i := 0; i < 100; i++ {
+		for _, objNs := range []string{"ns-a", "ns-b", "ns-c"} {
+			q.add(fmt.Sprintf("pod/%v/pod-%03d", objNs, i))
+		}
+	}
+
+	if _, ok := q.flush(stopCh); !ok {
+		t.Fatalf("Flush stopped unexpectedly")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	for objNs, names := range reconciled {
+		if len(names) != 100 {
+			t.Errorf("Namespace %v reconciled %d of 100 keys", objNs, len(names))
+		}
+		for i, name := range names {
+			if name != fmt.Sprintf("pod-%03d", i) {
+				t.Fatalf("Keys of namespace %v reconciled out of order: %v", objNs, names)
+			}
+		}
+	}
+}
+
+// Tests that a key blocked in one namespace doesn't delay the namespaces of the other shards.
+func TestReconcileQueueShardIsolation(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	q := newReconcileQueue(2, 0, time.Millisecond, time.Millisecond)
+
+	// Find a namespace on the other shard.
+	blocked := "pod/ns-big/pod-1"
+	other := ""
+	for i := 0; other == ""; i++ {
+		if key := fmt.Sprintf("pod/ns-%d/pod-1", i); q.shardIndex(key) != q.shardIndex(blocked) {
+			other = key
+		}
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	done := make(chan struct{})
+	q.run(stopCh, func(key string) error {
+		if key == blocked {
+			<-release
+			return nil
+		}
+		close(done)
+		return nil
+	}, nil)
+
+	q.add(blocked)
+	q.add(other)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Key %v was delayed by %v", other, blocked)
+	}
+}
+
+// Tests that the objects of a namespace, and the namespace itself, are on the same shard.
+func TestReconcileQueueShardIndex(t *testing.T) {
+	q := newReconcileQueue(8, 0, time.Millisecond, time.Millisecond)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "namespace", key: "namespace/ns-a"},
+		{name: "pod", key: "pod/ns-a/pod-1"},
+		{name: "other pod", key: "pod/ns-a/pod-2"},
+		{name: "network policy", key: "networkpolicy/ns-a/allow-all"},
+	}
+
+	shard := q.shardIndex("pod/ns-a/pod-0")
+	for _, test := range tests {
+		if index := q.shardIndex(test.key); index != shard {
+			t.Errorf("TestReconcileQueueShardIndex failed @ %v: key %v on shard %d, expected %d", test.name, test.key, index, shard)
+		}
+	}
+}
+
+// Tests that flush waits for the keys added before it, and gives up when stopped.
+func TestReconcileQueueFlush(t *testing.T) {
+	stopCh := make(chan struct{})
+
+	var lock sync.Mutex
+	reconciled := 0
+	release := make(chan struct{})
+
+	q := newReconcileQueue(4, 0, time.Millisecond, time.Millisecond)
+	q.run(stopCh, func(key string) error {
+		if key == "pod/ns/blocked" {
+			<-release
+			return nil
+		}
+
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		reconciled++
+		lock.Unlock()
+		return nil
+	}, nil)
+	defer close(release)
+
+	if _, ok := q.flush(stopCh); !ok {
+		t.Fatalf("Flush of an empty queue failed")
+	}
+
+	for i := 0; i < 100; i++ {
+		q.add(fmt.Sprintf("pod/ns/pod-%d", i))
+	}
+
+	if _, ok := q.flush(stopCh); !ok {
+		t.Fatalf("Flush stopped unexpectedly")
+	}
+
+	lock.Lock()
+	if reconciled != 100 {
+		t.Errorf("Flush returned after %d of 100 keys", reconciled)
+	}
+	lock.Unlock()
+
+	q.add("pod/ns/blocked")
+	close(stopCh)
+
+	if _, ok := q.flush(stopCh); ok {
+		t.Errorf("Flush of a stopped queue succeeded")
+	}
+}
+
+// Tests that failed keys are retried, reading the state of the object again, up to the retries of the queue.
+func TestReconcileQueueRetry(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	var lock sync.Mutex
+	attempts := make(map[string]int)
+	state := map[string]string{"pod/ns/flaky": "v1"}
+	var reconciledStates []string
+	results := make(map[string]error)
+
+	q := newReconcileQueue(1, 2, time.Millisecond, 4*time.Millisecond)
+	q.run(stopCh, func(key string) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		attempts[key]++
+		if key == "pod/ns/broken" {
+			return fmt.Errorf("permanent failure")
+		}
+
+		reconciledStates = append(reconciledStates, state[key])
+		if attempts[key] < 2 {
+			// The object changes before the retry.
+			state[key] = "v2"
+			return fmt.Errorf("transient failure")
+		}
+		return nil
+	}, func(key string, err error) {
+		lock.Lock()
+		results[key] = err
+		lock.Unlock()
+	})
+
+	q.add("pod/ns/flaky")
+	q.add("pod/ns/broken")
+
+	failed, ok := q.flush(stopCh)
+	if !ok {
+		t.Fatalf("Flush stopped unexpectedly")
+	}
+
+	if fmt.Sprint(failed) != fmt.Sprint([]string{"pod/ns/broken"}) {
+		t.Errorf("Flush returned failed keys %v, expected the key given up on", failed)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err, exists := results["pod/ns/flaky"]; !exists || err != nil {
+		t.Errorf("Retried key failed: %v", err)
+	}
+
+	if err := results["pod/ns/broken"]; err == nil {
+		t.Errorf("Key failing after its retries succeeded")
+	}
+
+	if attempts["pod/ns/broken"] != 3 {
+		t.Errorf("Failing key attempted %d times, expected 3", attempts["pod/ns/broken"])
+	}
+
+	if fmt.Sprint(reconciledStates) != fmt.Sprint([]string{"v1", "v2"}) {
+		t.Errorf("Retries reconciled %v, expected the current state of the object", reconciledStates)
+	}
+}
+
+// Tests the keys of the informer objects.
+func TestObjectKey(t *testing.T) {
+	podObj := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pod-1"}}
+	key, err := objectKey(podKind, podObj)
+	if err != nil || key != "pod/ns/pod-1" {
+		t.Fatalf("objectKey returned %v, %v", key, err)
+	}
+
+	kind, objNs, objName, err := splitObjectKey(key)
+	if err != nil || kind != podKind || objNs != "ns" || objName != "pod-1" {
+		t.Errorf("splitObjectKey returned %v %v %v %v", kind, objNs, objName, err)
+	}
+
+	if _, _, _, err := splitObjectKey("pod"); err == nil {
+		t.Errorf("splitObjectKey of an invalid key succeeded")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Kinds of the objects reconciled by npm, prefixing their queue keys.
+const (
+	podKind           = "pod"
+	namespaceKind     = "namespace"
+	networkPolicyKind = "networkpolicy"
+)
+
+// objectKey returns the queue key of an informer object, e.g. pod/<namespace>/<name>.
+func objectKey(kind string, obj interface{}) (string, error) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return "", err
+	}
+
+	return kind + "/" + key, nil
+}
+
+// splitObjectKey returns the kind, namespace and name of the object of a queue key.
+func splitObjectKey(key string) (string, string, string, error) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("Invalid key %v", key)
+	}
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(parts[1])
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return parts[0], namespace, name, nil
+}
+
+// isDeleting checks if an object is being deleted.
+func isDeleting(meta metav1.ObjectMeta) bool {
+	return meta.DeletionTimestamp != nil || meta.DeletionGracePeriodSeconds != nil
+}
+
+// addPolicyEvent records when the first event of a network policy not reconciled yet was received.
+func (npMgr *NetworkPolicyManager) addPolicyEvent(key string, eventTime time.Time) {
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	if _, exists := npMgr.policyEvents[key]; !exists {
+		npMgr.policyEvents[key] = eventTime
+	}
+}
+
+// completePolicyEvent records the convergence of the events of the network policy of a key, with the result of
+// its reconciliation.
+func (npMgr *NetworkPolicyManager) completePolicyEvent(key string, err error) {
+	npMgr.Lock()
+	eventTime, exists := npMgr.policyEvents[key]
+	delete(npMgr.policyEvents, key)
+	npMgr.Unlock()
+
+	if !exists {
+		return
+	}
+
+	_, npNs, npName, splitErr := splitObjectKey(key)
+	if splitErr != nil {
+		return
+	}
+
+	npMgr.recordPolicyConvergence(npNs, npName, eventTime, err)
+}
+
+// enqueue schedules the reconciliation of an informer object.
+func (npMgr *NetworkPolicyManager) enqueue(kind string, obj interface{}) {
+	key, err := objectKey(kind, obj)
+	if err != nil {
+		log.Printf("Error getting the key of %s %+v: %v\n", kind, obj, err)
+		return
+	}
+
+	npMgr.queue.add(key)
+}
@@ -0,0 +1,207 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/platform"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NamespaceStats reports how the network policies of a namespace are enforced on this node.
+type NamespaceStats struct {
+	Namespace           string
+	PolicyCount         int
+	TargetPodCount      int
+	IpsetMemberCount    int
+	EnforcementDisabled bool      `json:",omitempty"`
+	LastReconcileTime   time.Time `json:",omitempty"`
+	LastReconcileError  string    `json:",omitempty"`
+}
+
+// reconcileStatus records the outcome of the last event handled for a namespace.
+type reconcileStatus struct {
+	time time.Time
+	err  error
+}
+
+// recordReconcile records the outcome of an event handled for a namespace.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) recordReconcile(nsName string, err error) {
+	if npMgr.reconcileMap == nil {
+		npMgr.reconcileMap = make(map[string]*reconcileStatus)
+	}
+
+	if npMgr.clock == nil {
+		npMgr.clock = platform.NewClock()
+	}
+
+	npMgr.reconcileMap[nsName] = &reconcileStatus{
+		time: npMgr.clock.Now(),
+		err:  err,
+	}
+}
+
+// GetNamespaceStats returns the enforcement statistics of all namespaces known to npm.
+func (npMgr *NetworkPolicyManager) GetNamespaceStats() ([]NamespaceStats, error) {
+	pods, err := npMgr.podInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	npMgr.Lock()
+	defer npMgr.Unlock()
+
+	return npMgr.getNamespaceStats(pods), nil
+}
+
+// getNamespaceStats computes the enforcement statistics of all namespaces given the pods in the cluster.
+// This function should only be called when npMgr is locked.
+func (npMgr *NetworkPolicyManager) getNamespaceStats(pods []*corev1.Pod) []NamespaceStats {
+	allNs := npMgr.nsMap[util.KubeAllNamespacesFlag]
+	statsMap := make(map[string]*NamespaceStats)
+
+	getStats := func(nsName string) *NamespaceStats {
+		stats, exists := statsMap[nsName]
+		if !exists {
+			stats = &NamespaceStats{Namespace: nsName}
+			statsMap[nsName] = stats
+		}
+		return stats
+	}
+
+	for nsName := range npMgr.nsMap {
+		if nsName != util.KubeAllNamespacesFlag {
+			getStats(nsName)
+		}
+	}
+
+	for nsName, enforcement := range npMgr.nsEnforcement {
+		if enforcement.disabled {
+			getStats(nsName).EnforcementDisabled = true
+		}
+	}
+
+	for nsName, status := range npMgr.reconcileMap {
+		stats := getStats(nsName)
+		stats.LastReconcileTime = status.time
+		if status.err != nil {
+			stats.LastReconcileError = status.err.Error()
+		}
+	}
+
+	// Collect the pod selectors and ipsets of the policies in each namespace.
+	selectorMap := make(map[string][]labels.Selector)
+	setMap := make(map[string]map[string]bool)
+	for _, npObj := range allNs.npMap {
+		npNs := npObj.ObjectMeta.Namespace
+		getStats(npNs).PolicyCount++
+
+		selector, err := metav1.LabelSelectorAsSelector(&npObj.Spec.PodSelector)
+		if err != nil {
+			log.Printf("Error parsing pod selector of network policy %s/%s\n", npNs, npObj.ObjectMeta.Name)
+			continue
+		}
+		selectorMap[npNs] = append(selectorMap[npNs], selector)
+
+		if setMap[npNs] == nil {
+			setMap[npNs] = map[string]bool{npNs: true}
+		}
+
+		podSets, _, _ := parsePolicy(npObj)
+		for _, set := range podSets {
+			setMap[npNs][set] = true
+		}
+	}
+
+	for _, podObj := range pods {
+		podNs := podObj.ObjectMeta.Namespace
+		if !isValidPod(podObj) {
+			continue
+		}
+
+		for _, selector := range selectorMap[podNs] {
+			if selector.Matches(labels.Set(podObj.ObjectMeta.Labels)) {
+				statsMap[podNs].TargetPodCount++
+				break
+			}
+		}
+	}
+
+	for nsName, sets := range setMap {
+		for set := range sets {
+			statsMap[nsName].IpsetMemberCount += allNs.ipsMgr.GetSetMemberCount(set)
+		}
+	}
+
+	var result []NamespaceStats
+	for _, stats := range statsMap {
+		result = append(result, *stats)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Namespace < result[j].Namespace
+	})
+
+	return result
+}
+
+// ServeNamespaceStats handles requests for the enforcement statistics of all namespaces, or of the
+// namespace named by the last path element.
+func (npMgr *NetworkPolicyManager) ServeNamespaceStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := npMgr.GetNamespaceStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var resp interface{} = stats
+
+	nsName := strings.TrimPrefix(r.URL.Path, util.NpmNamespaceStatsPath)
+	if nsName != "" {
+		resp = nil
+		for _, nsStats := range stats {
+			if nsStats.Namespace == nsName {
+				resp = nsStats
+				break
+			}
+		}
+
+		if resp == nil {
+			http.Error(w, "Namespace "+nsName+" not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding namespace stats: %v\n", err)
+	}
+}
+
+// RunStatsServer serves the namespace enforcement statistics, the metrics and the debug state on the given address.
+func (npMgr *NetworkPolicyManager) RunStatsServer(address string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(util.NpmNamespaceStatsPath, npMgr.ServeNamespaceStats)
+	mux.HandleFunc(util.NpmMetricsPath, npMgr.ServeMetrics)
+	mux.HandleFunc(util.NpmDebugStatePath, npMgr.ServeDebugState)
+	mux.HandleFunc(util.NpmDebugVerifyPath, npMgr.ServeVerify)
+
+	log.Printf("Serving namespace stats on %s%s\n", address, util.NpmNamespaceStatsPath)
+
+	return http.ListenAndServe(address, mux)
+}
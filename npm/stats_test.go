@@ -0,0 +1,87 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package npm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestPod(ns, name string, podLabels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      name,
+			Labels:    podLabels,
+		},
+		Status: corev1.PodStatus{
+			Phase: "Running",
+			PodIP: "1.2.3.4",
+		},
+	}
+}
+
+func TestGetNamespaceStats(t *testing.T) {
+	npMgr := &NetworkPolicyManager{
+		nsMap: make(map[string]*namespace),
+	}
+
+	allNs, err := newNs(util.KubeAllNamespacesFlag)
+	if err != nil {
+		panic(err.Error)
+	}
+	npMgr.nsMap[util.KubeAllNamespacesFlag] = allNs
+
+	allNs.npMap["allow-frontend"] = &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-ns",
+			Name:      "allow-frontend",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "frontend",
+				},
+			},
+		},
+	}
+
+	npMgr.recordReconcile("test-ns", nil)
+	npMgr.recordReconcile("other-ns", fmt.Errorf("test error"))
+
+	pods := []*corev1.Pod{
+		newTestPod("test-ns", "frontend", map[string]string{"app": "frontend"}),
+		newTestPod("test-ns", "backend", map[string]string{"app": "backend"}),
+		newTestPod("other-ns", "frontend", map[string]string{"app": "frontend"}),
+	}
+
+	stats := npMgr.getNamespaceStats(pods)
+	if len(stats) != 2 {
+		t.Fatalf("TestGetNamespaceStats failed @ getNamespaceStats, stats: %+v", stats)
+	}
+
+	// Stats are sorted by namespace.
+	other, test := stats[0], stats[1]
+
+	if test.Namespace != "test-ns" || test.PolicyCount != 1 || test.TargetPodCount != 1 {
+		t.Errorf("TestGetNamespaceStats failed @ test-ns stats: %+v", test)
+	}
+
+	if test.LastReconcileTime.IsZero() || test.LastReconcileError != "" {
+		t.Errorf("TestGetNamespaceStats failed @ test-ns reconcile status: %+v", test)
+	}
+
+	if other.Namespace != "other-ns" || other.PolicyCount != 0 || other.TargetPodCount != 0 {
+		t.Errorf("TestGetNamespaceStats failed @ other-ns stats: %+v", other)
+	}
+
+	if other.LastReconcileError != "test error" {
+		t.Errorf("TestGetNamespaceStats failed @ other-ns reconcile status: %+v", other)
+	}
+}
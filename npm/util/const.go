@@ -2,6 +2,8 @@
 // MIT License
 package util
 
+import "time"
+
 //kubernetes related constants.
 const (
 	KubeSystemFlag          string = "kube-system"
@@ -15,6 +17,9 @@ const (
 	Iptables                      string = "iptables"
 	IptablesSave                  string = "iptables-save"
 	IptablesRestore               string = "iptables-restore"
+	IptablesRestoreNoFlushFlag    string = "--noflush"
+	IptablesVersionFlag           string = "--version"
+	IptablesNfTablesVersion       string = "nf_tables"
 	IptablesConfigFile            string = "/var/log/iptables.conf"
 	IptablesTestConfigFile        string = "/var/log/iptables-test.conf"
 	IptablesChainCreationFlag     string = "-N"
@@ -24,16 +29,23 @@ const (
 	IptablesFlushFlag             string = "-F"
 	IptablesCheckFlag             string = "-C"
 	IptablesDestroyFlag           string = "-X"
+	IptablesRenameChainFlag       string = "-E"
 	IptablesJumpFlag              string = "-j"
 	IptablesAccept                string = "ACCEPT"
 	IptablesReject                string = "REJECT"
 	IptablesDrop                  string = "DROP"
+	IptablesNflog                 string = "NFLOG"
+	IptablesNflogGroupFlag        string = "--nflog-group"
+	IptablesNflogPrefixFlag       string = "--nflog-prefix"
 	IptablesSrcFlag               string = "src"
 	IptablesDstFlag               string = "dst"
 	IptablesProtFlag              string = "-p"
 	IptablesSFlag                 string = "-s"
 	IptablesDFlag                 string = "-d"
 	IptablesDstPortFlag           string = "--dport"
+	IptablesSrcPortFlag           string = "--sport"
+	IptablesIcmpTypeFlag          string = "--icmp-type"
+	IptablesIcmpProtocol          string = "icmp"
 	IptablesMatchFlag             string = "-m"
 	IptablesSetFlag               string = "set"
 	IptablesMatchSetFlag          string = "--match-set"
@@ -47,7 +59,41 @@ const (
 	IptablesAzureEgressPortChain  string = "AZURE-NPM-EGRESS-PORT"
 	IptablesAzureEgressToChain    string = "AZURE-NPM-EGRESS-TO"
 	IptablesAzureTargetSetsChain  string = "AZURE-NPM-TARGET-SETS"
+	IptablesAzurePeerChainPrefix  string = "AZURE-NPM-P-"
+	IptablesShadowChainSuffix     string = "-NEXT"
 	IptablesForwardChain          string = "FORWARD"
+
+	// Backends of iptables, auto detects the one the host uses. The nftables backend programs the rules and sets
+	// with nft instead of the iptables and ipset binaries.
+	IptablesBackendAuto     string = "auto"
+	IptablesBackendDefault  string = "iptables"
+	IptablesBackendLegacy   string = "legacy"
+	IptablesBackendNft      string = "nft"
+	IptablesBackendNftables string = "nftables"
+)
+
+//nftables related constants.
+const (
+	Nft             string = "nft"
+	NftFileFlag     string = "-f"
+	NftHandleFlag   string = "-a"
+	NftStdin        string = "-"
+	NftTable        string = "azure-npm"
+	NftIPv4Family   string = "ip"
+	NftIPv6Family   string = "ip6"
+	NftIcmpv6Proto  string = "ipv6-icmp"
+	NftForwardChain string = "{ type filter hook forward priority 0; policy accept; }"
+)
+
+//ip6tables related constants.
+const (
+	Ip6tables               string = "ip6tables"
+	Ip6tablesSave           string = "ip6tables-save"
+	Ip6tablesRestore        string = "ip6tables-restore"
+	Ip6tablesConfigFile     string = "/var/log/ip6tables.conf"
+	Ip6tablesTestConfigFile string = "/var/log/ip6tables-test.conf"
+	Ip6tablesIcmpTypeFlag   string = "--icmpv6-type"
+	Ip6tablesIcmpProtocol   string = "icmpv6"
 )
 
 //ipset related constants.
@@ -57,18 +103,108 @@ const (
 	IpsetRestoreFlag    string = "restore"
 	IpsetConfigFile     string = "/var/log/ipset.conf"
 	IpsetTestConfigFile string = "/var/log/ipset-test.conf"
+	IpsetNamesFile      string = "/var/log/ipset-names.json"
 	IpsetCreationFlag   string = "-N"
 	IpsetAppendFlag     string = "-A"
 	IpsetDeletionFlag   string = "-D"
 	IpsetFlushFlag      string = "-F"
 	IpsetDestroyFlag    string = "-X"
+	IpsetRenameFlag     string = "-E"
+	IpsetListFlag       string = "-L"
+	IpsetNameFlag       string = "-n"
 
 	IpsetExistFlag string = "-exist"
 	IpsetFileFlag  string = "-file"
 
-	IpsetSetListFlag string = "setlist"
-	IpsetNetHashFlag string = "nethash"
-	AzureNpmPrefix   string = "azure-npm-"
+	IpsetSetListFlag     string = "setlist"
+	IpsetNetHashFlag     string = "nethash"
+	IpsetIPPortHashFlag  string = "hash:ip,port"
+	NamedPortIPSetPrefix string = "namedport:"
+	AzureNpmPrefix       string = "azure-npm-"
+	IpsetFamilyFlag      string = "family"
+	IpsetInet6Family     string = "inet6"
+	IpsetIPv6Suffix      string = "-v6"
+
+	IpsetNomatchFlag       string = "nomatch"
+	IPBlockIPSetPrefix     string = "ipblock:"
+	IPBlockExceptSeparator string = "-except:"
+	NsSelectorIPSetPrefix  string = "nsselector:"
+	FqdnIPSetPrefix        string = "fqdn:"
+)
+
+//NPM annotation constants.
+const (
+	AllowIcmpIngressAnnotation string = "azure-npm/allow-icmp-ingress"
+	AllowIcmpEgressAnnotation  string = "azure-npm/allow-icmp-egress"
+	IcmpProtocol               string = "icmp"
+	Icmpv6Protocol             string = "icmpv6"
+	AllowFqdnEgressAnnotation  string = "azure-npm/allow-fqdn-egress"
+
+	// Annotation of a node holding the status of the network policies whose programming failed on it.
+	PolicyStatusAnnotation string = "azure-npm/policy-status"
+)
+
+//NPM stats constants.
+const (
+	NpmStatsAddress       string = "localhost:10092"
+	NpmNamespaceStatsPath string = "/npm/v1/namespaces/"
+	NpmMetricsPath        string = "/metrics"
+	NpmDebugStatePath     string = "/npm/v1/debug/state"
+	NpmDebugVerifyPath    string = "/npm/v1/debug/verify"
+
+	// Default number of workers reconciling the objects of events, each owning a shard of the namespaces.
+	NpmDefaultReconcileWorkers int = 4
+
+	// Default number of times a failed object is reconciled again.
+	NpmDefaultReconcileRetries int = 5
+
+	// Delay before the first retry of a failed object, doubled by each failure of the object up to the max.
+	NpmReconcileRetryInitialDelay time.Duration = 100 * time.Millisecond
+	NpmReconcileRetryMaxDelay     time.Duration = 5 * time.Second
+
+	// Default period at which the informers deliver their whole cache again as update events.
+	NpmDefaultResyncPeriod time.Duration = 24 * time.Hour
+
+	// Default rate and burst of the requests to the kube-apiserver.
+	NpmDefaultKubeAPIQPS   float64 = 5
+	NpmDefaultKubeAPIBurst int     = 10
+
+	// Default interval of the dataplane consistency reconciler.
+	NpmDefaultConsistencyInterval time.Duration = 5 * time.Minute
+
+	// Default interval at which the names of FQDN egress rules are resolved again.
+	NpmDefaultFqdnRefreshInterval time.Duration = 30 * time.Second
+
+	// Default time the addresses of a name stay allowed after they were last resolved.
+	NpmDefaultFqdnAddressTTL time.Duration = 5 * time.Minute
+)
+
+//NPM audit mode constants.
+const (
+	// NFLOG group the packets policies would drop are logged to in audit mode.
+	NpmAuditNflogGroup int = 100
+
+	// Prefix of the NFLOG prefix of audit rules, followed by the name of the set the packets would be dropped for.
+	NpmAuditNflogPrefix string = "azure-npm-audit:"
+
+	// Max length of an NFLOG prefix.
+	NflogPrefixMaxLength int = 63
+)
+
+//NPM DNS snooping constants.
+const (
+	// NFLOG group the DNS answers received by pods are logged to when DNS snooping is enabled.
+	NpmDNSNflogGroup int = 101
+
+	// Port DNS servers answer from.
+	DNSPort string = "53"
+)
+
+//NPM enforcement toggle constants.
+const (
+	// Label of the namespaces exempted from network policy enforcement when set to NpmEnforcementDisabled.
+	NpmEnforcementLabel    string = "npm.azure.com/enforcement"
+	NpmEnforcementDisabled string = "disabled"
 )
 
 //NPM telemetry constants.
@@ -84,4 +220,6 @@ const (
 	AddNetworkPolicyEvent    string = "Add network policy"
 	UpdateNetworkPolicyEvent string = "Update network policy"
 	DeleteNetworkPolicyEvent string = "Delete network policy"
+
+	ReconcileDataplaneEvent string = "Reconcile dataplane"
 )
@@ -0,0 +1,210 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Ipset names are limited to 31 characters, and npm names sets after selectors of unbounded length.
+// A set is named AzureNpmPrefix followed by the 64-bit hash of its selector, zero padded to setNameHashLength
+// base 36 digits, which leaves room for IpsetIPv6Suffix. Selectors whose hash is taken by another selector are
+// hashed again with a counter until a free name is found. Which of two colliding selectors gets the plain hash
+// depends on the order they are seen, so the mapping is persisted to keep the names stable across restarts.
+//
+// Sets named by earlier versions are AzureNpmPrefix followed by the decimal 32-bit hash of their selector, which
+// is at most legacyHashLength digits, so legacy and current names can't be confused.
+const (
+	IpsetMaxNameLength = 31
+
+	setNameHashLength = 13
+	legacyHashLength  = 10
+)
+
+// SetNameStore maps the selectors of npm to the names of their ipsets and back.
+type SetNameStore struct {
+	sync.Mutex
+	file  string
+	names map[string]string // selector -> set name.
+	sets  map[string]string // set name -> selector.
+	dirty bool
+}
+
+// setNames is the store GetHashedName names sets with.
+var setNames = NewSetNameStore("")
+
+// NewSetNameStore creates a store persisted to file, or kept in memory if file is empty.
+func NewSetNameStore(file string) *SetNameStore {
+	return &SetNameStore{
+		file:  file,
+		names: make(map[string]string),
+		sets:  make(map[string]string),
+	}
+}
+
+// LoadSetNames makes GetHashedName use the mapping persisted to file, and persist the names it gives to it.
+// A missing file starts an empty mapping.
+func LoadSetNames(file string) error {
+	store := NewSetNameStore(file)
+	if err := store.Load(); err != nil {
+		return err
+	}
+
+	setNames = store
+	return nil
+}
+
+// SaveSetNames persists the names given by GetHashedName since they were last saved.
+func SaveSetNames() error {
+	return setNames.Save()
+}
+
+// ReleaseHashedName forgets the name of the set of a selector once the set is destroyed.
+func ReleaseHashedName(name string) {
+	setNames.Release(name)
+}
+
+// hashSetName returns the set name of a selector for the given collision count.
+func hashSetName(name string, collisions int) string {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	if collisions > 0 {
+		h.Write([]byte("\x00" + strconv.Itoa(collisions)))
+	}
+
+	return AzureNpmPrefix + fmt.Sprintf("%0*s", setNameHashLength, strconv.FormatUint(h.Sum64(), 36))
+}
+
+// GetLegacyHashedName returns the name earlier versions gave to the set of a selector.
+func GetLegacyHashedName(name string) string {
+	return AzureNpmPrefix + Hash(name)
+}
+
+// IsLegacySetName checks whether an ipset, or its IPv6 counterpart, was named by an earlier version.
+func IsLegacySetName(setName string) bool {
+	if !strings.HasPrefix(setName, AzureNpmPrefix) {
+		return false
+	}
+
+	hash := strings.TrimSuffix(strings.TrimPrefix(setName, AzureNpmPrefix), IpsetIPv6Suffix)
+	if len(hash) == 0 || len(hash) > legacyHashLength {
+		return false
+	}
+
+	_, err := strconv.ParseUint(hash, 10, 32)
+	return err == nil
+}
+
+// Get returns the name of the set of a selector, giving it one if it has none.
+func (store *SetNameStore) Get(name string) string {
+	store.Lock()
+	defer store.Unlock()
+
+	if setName, exists := store.names[name]; exists {
+		return setName
+	}
+
+	setName := hashSetName(name, 0)
+	for collisions := 1; store.sets[setName] != ""; collisions++ {
+		setName = hashSetName(name, collisions)
+	}
+
+	store.names[name] = setName
+	store.sets[setName] = name
+	store.dirty = true
+
+	return setName
+}
+
+// Lookup returns the selector of a set name, if the set was named by the store.
+func (store *SetNameStore) Lookup(setName string) (string, bool) {
+	store.Lock()
+	defer store.Unlock()
+
+	name, exists := store.sets[setName]
+	return name, exists
+}
+
+// Release forgets the name of the set of a selector, so that it can be given to another one.
+func (store *SetNameStore) Release(name string) {
+	store.Lock()
+	defer store.Unlock()
+
+	setName, exists := store.names[name]
+	if !exists {
+		return
+	}
+
+	delete(store.names, name)
+	delete(store.sets, setName)
+	store.dirty = true
+}
+
+// Load reads the mapping persisted to the file of the store.
+func (store *SetNameStore) Load() error {
+	store.Lock()
+	defer store.Unlock()
+
+	if store.file == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(store.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var names map[string]string
+	if err = json.Unmarshal(b, &names); err != nil {
+		return fmt.Errorf("failed to parse ipset names in %s: %v", store.file, err)
+	}
+
+	for name, setName := range names {
+		if len(setName)+len(IpsetIPv6Suffix) > IpsetMaxNameLength || store.sets[setName] != "" {
+			return fmt.Errorf("invalid ipset name %s of %s in %s", setName, name, store.file)
+		}
+
+		store.names[name] = setName
+		store.sets[setName] = name
+	}
+
+	return nil
+}
+
+// Save writes the mapping to the file of the store if it changed since it was last saved.
+func (store *SetNameStore) Save() error {
+	store.Lock()
+	defer store.Unlock()
+
+	if store.file == "" || !store.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(store.names)
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file and rename it, so that the mapping is never left half written.
+	tmpFile := store.file + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, b, 0644); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpFile, store.file); err != nil {
+		return err
+	}
+
+	store.dirty = false
+	return nil
+}
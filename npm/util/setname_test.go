@@ -0,0 +1,101 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetNameLength(t *testing.T) {
+	name := "podlabel-" + strings.Repeat("a-very-long-label-key-and-value", 10)
+	setName := NewSetNameStore("").Get(name)
+	if len(setName) != len(AzureNpmPrefix)+setNameHashLength {
+		t.Errorf("TestSetNameLength failed, unexpected name %s", setName)
+	}
+
+	if len(setName)+len(IpsetIPv6Suffix) > IpsetMaxNameLength {
+		t.Errorf("TestSetNameLength failed, name %s too long for its IPv6 counterpart", setName)
+	}
+
+	if IsLegacySetName(setName) {
+		t.Errorf("TestSetNameLength failed, name %s taken for a legacy name", setName)
+	}
+}
+
+func TestSetNameCollision(t *testing.T) {
+	store := NewSetNameStore("")
+
+	// Take the plain hash of the second selector, as a colliding selector would.
+	store.sets[hashSetName("ns-b", 0)] = "ns-a"
+	store.names["ns-a"] = hashSetName("ns-b", 0)
+
+	setName := store.Get("ns-b")
+	if setName != hashSetName("ns-b", 1) {
+		t.Errorf("TestSetNameCollision failed, unexpected name %s", setName)
+	}
+
+	if name, _ := store.Lookup(setName); name != "ns-b" {
+		t.Errorf("TestSetNameCollision failed, unexpected selector %s", name)
+	}
+
+	if store.Get("ns-b") != setName {
+		t.Errorf("TestSetNameCollision failed, name not stable")
+	}
+
+	store.Release("ns-b")
+	if _, exists := store.Lookup(setName); exists {
+		t.Errorf("TestSetNameCollision failed, name not released")
+	}
+}
+
+func TestSetNamePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "setnames")
+	if err != nil {
+		t.Fatalf("TestSetNamePersistence failed @ ioutil.TempDir")
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "ipset-names.json")
+	store := NewSetNameStore(file)
+	store.sets[hashSetName("ns-b", 0)] = "ns-a"
+	store.names["ns-a"] = hashSetName("ns-b", 0)
+	setName := store.Get("ns-b")
+
+	if err := store.Save(); err != nil {
+		t.Errorf("TestSetNamePersistence failed @ store.Save: %v", err)
+	}
+
+	// Selectors seen in another order keep their names.
+	loaded := NewSetNameStore(file)
+	if err := loaded.Load(); err != nil {
+		t.Errorf("TestSetNamePersistence failed @ loaded.Load: %v", err)
+	}
+
+	if loaded.Get("ns-b") != setName {
+		t.Errorf("TestSetNamePersistence failed, name of ns-b changed to %s", loaded.Get("ns-b"))
+	}
+
+	if loaded.Get("ns-a") != hashSetName("ns-b", 0) {
+		t.Errorf("TestSetNamePersistence failed, name of ns-a changed to %s", loaded.Get("ns-a"))
+	}
+}
+
+func TestIsLegacySetName(t *testing.T) {
+	testCases := map[string]bool{
+		GetLegacyHashedName("ns-a"):                   true,
+		GetLegacyHashedName("ns-a") + IpsetIPv6Suffix: true,
+		GetHashedName("ns-a"):                         false,
+		AzureNpmPrefix:                                false,
+		"kube-system":                                 false,
+	}
+
+	for setName, expected := range testCases {
+		if IsLegacySetName(setName) != expected {
+			t.Errorf("TestIsLegacySetName failed for %s", setName)
+		}
+	}
+}
@@ -5,9 +5,25 @@ package util
 import (
 	"fmt"
 	"hash/fnv"
+	"net"
 	"strings"
 )
 
+// IsIPv6Enabled is set when NPM programs IPv6 ipsets and ip6tables rules in addition to IPv4 ones,
+// so that policies are enforced for IPv6 pods in dual-stack clusters.
+var IsIPv6Enabled = false
+
+// IsAuditModeEnabled is set when NPM logs the packets policies would drop instead of dropping them,
+// so that policies can be validated before they are enforced.
+var IsAuditModeEnabled = false
+
+// IsFqdnSnoopingEnabled is set when NPM learns the addresses of the names of FQDN egress rules from the DNS answers
+// received by pods, in addition to resolving the names itself.
+var IsFqdnSnoopingEnabled = false
+
+// IsNftablesEnabled is set when NPM programs its rules and sets with nftables instead of iptables and ipset.
+var IsNftablesEnabled = false
+
 // GetClusterID retrieves cluster ID through node name. (Azure-specific)
 func GetClusterID(nodeName string) string {
 	s := strings.Split(nodeName, "-")
@@ -51,5 +67,41 @@ func AppendMap(base, new map[string]string) map[string]string {
 
 // GetHashedName returns hashed ipset name.
 func GetHashedName(name string) string {
-	return AzureNpmPrefix + Hash(name)
+	return setNames.Get(name)
+}
+
+// GetIPv6SetName returns the name of the IPv6 counterpart of a hashed ipset name.
+func GetIPv6SetName(hashedName string) string {
+	return hashedName + IpsetIPv6Suffix
+}
+
+// IsIPv6 checks whether an address, CIDR or ipset element such as "ip,tcp:80" or "cidr nomatch" is IPv6.
+func IsIPv6(s string) bool {
+	s = strings.SplitN(s, " ", 2)[0]
+	s = strings.SplitN(s, ",", 2)[0]
+	s = strings.SplitN(s, "/", 2)[0]
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() == nil
+}
+
+// SubtractCIDR returns the CIDRs covering ipNet without the addresses of except, by splitting ipNet in halves
+// until they no longer overlap except.
+func SubtractCIDR(ipNet *net.IPNet, except *net.IPNet) []*net.IPNet {
+	ones, bits := ipNet.Mask.Size()
+	exceptOnes, exceptBits := except.Mask.Size()
+
+	if bits != exceptBits || (!ipNet.Contains(except.IP) && !except.Contains(ipNet.IP)) {
+		return []*net.IPNet{ipNet}
+	}
+
+	if exceptOnes <= ones {
+		return nil
+	}
+
+	mask := net.CIDRMask(ones+1, bits)
+	low := &net.IPNet{IP: ipNet.IP.Mask(mask), Mask: mask}
+	high := &net.IPNet{IP: append(net.IP(nil), low.IP...), Mask: mask}
+	high.IP[ones/8] |= 0x80 >> uint(ones%8)
+
+	return append(SubtractCIDR(low, except), SubtractCIDR(high, except)...)
 }
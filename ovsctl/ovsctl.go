@@ -17,8 +17,7 @@ const (
 func CreateOVSBridge(bridgeName string) error {
 	log.Printf("[ovs] Creating OVS Bridge %v", bridgeName)
 
-	ovsCreateCmd := fmt.Sprintf("ovs-vsctl add-br %s", bridgeName)
-	_, err := platform.ExecuteCommand(ovsCreateCmd)
+	_, err := platform.ExecWithTimeout("ovs-vsctl", "add-br", bridgeName)
 	if err != nil {
 		log.Printf("[ovs] Error while creating OVS bridge %v", err)
 		return err
@@ -30,8 +29,7 @@ func CreateOVSBridge(bridgeName string) error {
 func DeleteOVSBridge(bridgeName string) error {
 	log.Printf("[ovs] Deleting OVS Bridge %v", bridgeName)
 
-	ovsCreateCmd := fmt.Sprintf("ovs-vsctl del-br %s", bridgeName)
-	_, err := platform.ExecuteCommand(ovsCreateCmd)
+	_, err := platform.ExecWithTimeout("ovs-vsctl", "del-br", bridgeName)
 	if err != nil {
 		log.Printf("[ovs] Error while deleting OVS bridge %v", err)
 		return err
@@ -41,14 +39,11 @@ func DeleteOVSBridge(bridgeName string) error {
 }
 
 func AddPortOnOVSBridge(hostIfName string, bridgeName string, vlanID int) error {
-	cmd := ""
-
-	if vlanID == 0 {
-		cmd = fmt.Sprintf("ovs-vsctl add-port %s %s", bridgeName, hostIfName)
-	} else {
-		cmd = fmt.Sprintf("ovs-vsctl add-port %s %s tag=%d", bridgeName, hostIfName, vlanID)
+	args := []string{"add-port", bridgeName, hostIfName}
+	if vlanID != 0 {
+		args = append(args, fmt.Sprintf("tag=%d", vlanID))
 	}
-	_, err := platform.ExecuteCommand(cmd)
+	_, err := platform.ExecWithTimeout("ovs-vsctl", args...)
 	if err != nil {
 		log.Printf("[ovs] Error while setting OVS as master to primary interface %v", err)
 		return err
@@ -58,19 +53,18 @@ func AddPortOnOVSBridge(hostIfName string, bridgeName string, vlanID int) error
 }
 
 func GetOVSPortNumber(interfaceName string) (string, error) {
-	cmd := fmt.Sprintf("ovs-vsctl get Interface %s ofport", interfaceName)
-	ofport, err := platform.ExecuteCommand(cmd)
+	result, err := platform.ExecWithTimeout("ovs-vsctl", "get", "Interface", interfaceName, "ofport")
 	if err != nil {
 		log.Printf("[ovs] Get ofport failed with error %v", err)
 		return "", err
 	}
 
-	return strings.Trim(ofport, "\n"), nil
+	return strings.Trim(result.Stdout, "\n"), nil
 }
 
 func AddVMIpAcceptRule(bridgeName string, primaryIP string, mac string) error {
-	cmd := fmt.Sprintf("ovs-ofctl add-flow %s ip,nw_dst=%s,dl_dst=%s,priority=20,actions=normal", bridgeName, primaryIP, mac)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("ip,nw_dst=%s,dl_dst=%s,priority=20,actions=normal", primaryIP, mac)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding SNAT rule failed with error %v", err)
 		return err
@@ -80,9 +74,9 @@ func AddVMIpAcceptRule(bridgeName string, primaryIP string, mac string) error {
 }
 
 func AddArpSnatRule(bridgeName string, mac string, macHex string, ofport string) error {
-	cmd := fmt.Sprintf(`ovs-ofctl add-flow %v table=1,priority=10,arp,arp_op=1,actions='mod_dl_src:%s,
-		load:0x%s->NXM_NX_ARP_SHA[],output:%s'`, bridgeName, mac, macHex, ofport)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("table=1,priority=10,arp,arp_op=1,actions=mod_dl_src:%s,"+
+		"load:0x%s->NXM_NX_ARP_SHA[],output:%s", mac, macHex, ofport)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding ARP SNAT rule failed with error %v", err)
 		return err
@@ -96,17 +90,15 @@ func AddIpSnatRule(bridgeName string, port string, mac string, outport string) e
 		outport = "normal"
 	}
 
-	cmd := fmt.Sprintf("ovs-ofctl add-flow %v priority=20,ip,in_port=%s,vlan_tci=0,actions=mod_dl_src:%s,strip_vlan,%v",
-		bridgeName, port, mac, outport)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("priority=20,ip,in_port=%s,vlan_tci=0,actions=mod_dl_src:%s,strip_vlan,%v", port, mac, outport)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding IP SNAT rule failed with error %v", err)
 		return err
 	}
 
-	cmd = fmt.Sprintf("ovs-ofctl add-flow %v priority=10,ip,in_port=%s,actions=drop",
-		bridgeName, port)
-	_, err = platform.ExecuteCommand(cmd)
+	flow = fmt.Sprintf("priority=10,ip,in_port=%s,actions=drop", port)
+	_, err = platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Dropping vlantag packet rule failed with error %v", err)
 		return err
@@ -117,9 +109,9 @@ func AddIpSnatRule(bridgeName string, port string, mac string, outport string) e
 
 func AddArpDnatRule(bridgeName string, port string, mac string) error {
 	// Add DNAT rule to forward ARP replies to container interfaces.
-	cmd := fmt.Sprintf(`ovs-ofctl add-flow %s arp,arp_op=2,in_port=%s,actions='mod_dl_dst:ff:ff:ff:ff:ff:ff,
-		load:0x%s->NXM_NX_ARP_THA[],normal'`, bridgeName, port, mac)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("arp,arp_op=2,in_port=%s,actions=mod_dl_dst:ff:ff:ff:ff:ff:ff,"+
+		"load:0x%s->NXM_NX_ARP_THA[],normal", port, mac)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding DNAT rule failed with error %v", err)
 		return err
@@ -134,12 +126,12 @@ func AddFakeArpReply(bridgeName string, ip net.IP) error {
 	ipAddrInt := common.IpToInt(ip)
 
 	log.Printf("[ovs] Adding ARP reply rule for IP address %v ", ip.String())
-	cmd := fmt.Sprintf(`ovs-ofctl add-flow %s arp,arp_op=1,priority=20,actions='load:0x2->NXM_OF_ARP_OP[],
-			move:NXM_OF_ETH_SRC[]->NXM_OF_ETH_DST[],mod_dl_src:%s,
-			move:NXM_NX_ARP_SHA[]->NXM_NX_ARP_THA[],move:NXM_OF_ARP_TPA[]->NXM_OF_ARP_SPA[],
-			load:0x%s->NXM_NX_ARP_SHA[],load:0x%x->NXM_OF_ARP_TPA[],IN_PORT'`,
-		bridgeName, defaultMacForArpResponse, macAddrHex, ipAddrInt)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("arp,arp_op=1,priority=20,actions=load:0x2->NXM_OF_ARP_OP[],"+
+		"move:NXM_OF_ETH_SRC[]->NXM_OF_ETH_DST[],mod_dl_src:%s,"+
+		"move:NXM_NX_ARP_SHA[]->NXM_NX_ARP_THA[],move:NXM_OF_ARP_TPA[]->NXM_OF_ARP_SPA[],"+
+		"load:0x%s->NXM_NX_ARP_SHA[],load:0x%x->NXM_OF_ARP_TPA[],IN_PORT",
+		defaultMacForArpResponse, macAddrHex, ipAddrInt)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding ARP reply rule failed with error %v", err)
 		return err
@@ -153,9 +145,8 @@ func AddArpReplyRule(bridgeName string, port string, ip net.IP, mac string, vlan
 	macAddrHex := strings.Replace(mac, ":", "", -1)
 
 	log.Printf("[ovs] Adding ARP reply rule to add vlan %v and forward packet to table 1 for port %v", vlanid, port)
-	cmd := fmt.Sprintf(`ovs-ofctl add-flow %s arp,arp_op=1,in_port=%s,actions='mod_vlan_vid:%v,resubmit(,1)'`,
-		bridgeName, port, vlanid)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("arp,arp_op=1,in_port=%s,actions=mod_vlan_vid:%v,resubmit(,1)", port, vlanid)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding ARP reply rule failed with error %v", err)
 		return err
@@ -163,12 +154,12 @@ func AddArpReplyRule(bridgeName string, port string, ip net.IP, mac string, vlan
 
 	// If arp fields matches, set arp reply rule for the request
 	log.Printf("[ovs] Adding ARP reply rule for IP address %v and vlanid %v.", ip, vlanid)
-	cmd = fmt.Sprintf(`ovs-ofctl add-flow %s table=1,arp,arp_tpa=%s,dl_vlan=%v,arp_op=1,priority=20,actions='load:0x2->NXM_OF_ARP_OP[],
-			move:NXM_OF_ETH_SRC[]->NXM_OF_ETH_DST[],mod_dl_src:%s,
-			move:NXM_NX_ARP_SHA[]->NXM_NX_ARP_THA[],move:NXM_OF_ARP_SPA[]->NXM_OF_ARP_TPA[],
-			load:0x%s->NXM_NX_ARP_SHA[],load:0x%x->NXM_OF_ARP_SPA[],strip_vlan,IN_PORT'`,
-		bridgeName, ip.String(), vlanid, mac, macAddrHex, ipAddrInt)
-	_, err = platform.ExecuteCommand(cmd)
+	flow = fmt.Sprintf("table=1,arp,arp_tpa=%s,dl_vlan=%v,arp_op=1,priority=20,actions=load:0x2->NXM_OF_ARP_OP[],"+
+		"move:NXM_OF_ETH_SRC[]->NXM_OF_ETH_DST[],mod_dl_src:%s,"+
+		"move:NXM_NX_ARP_SHA[]->NXM_NX_ARP_THA[],move:NXM_OF_ARP_SPA[]->NXM_OF_ARP_TPA[],"+
+		"load:0x%s->NXM_NX_ARP_SHA[],load:0x%x->NXM_OF_ARP_SPA[],strip_vlan,IN_PORT",
+		ip.String(), vlanid, mac, macAddrHex, ipAddrInt)
+	_, err = platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding ARP reply rule failed with error %v", err)
 		return err
@@ -178,16 +169,16 @@ func AddArpReplyRule(bridgeName string, port string, ip net.IP, mac string, vlan
 }
 
 func AddMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlanid int) error {
-	var cmd string
+	var flow string
 
 	if vlanid != 0 {
-		cmd = fmt.Sprintf("ovs-ofctl add-flow %s ip,nw_dst=%s,dl_vlan=%v,in_port=%s,actions=mod_dl_dst:%s,normal",
-			bridgeName, ip.String(), vlanid, port, mac)
+		flow = fmt.Sprintf("ip,nw_dst=%s,dl_vlan=%v,in_port=%s,actions=mod_dl_dst:%s,normal",
+			ip.String(), vlanid, port, mac)
 	} else {
-		cmd = fmt.Sprintf("ovs-ofctl add-flow %s ip,nw_dst=%s,in_port=%s,actions=mod_dl_dst:%s,normal",
-			bridgeName, ip.String(), port, mac)
+		flow = fmt.Sprintf("ip,nw_dst=%s,in_port=%s,actions=mod_dl_dst:%s,normal",
+			ip.String(), port, mac)
 	}
-	_, err := platform.ExecuteCommand(cmd)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "add-flow", bridgeName, flow)
 	if err != nil {
 		log.Printf("[ovs] Adding MAC DNAT rule failed with error %v", err)
 		return err
@@ -197,42 +188,37 @@ func AddMacDnatRule(bridgeName string, port string, ip net.IP, mac string, vlani
 }
 
 func DeleteArpReplyRule(bridgeName string, port string, ip net.IP, vlanid int) {
-	cmd := fmt.Sprintf("ovs-ofctl del-flows %s arp,arp_op=1,in_port=%s",
-		bridgeName, port)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("arp,arp_op=1,in_port=%s", port)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "del-flows", bridgeName, flow)
 	if err != nil {
 		log.Printf("[net] Deleting ARP reply rule failed with error %v", err)
 	}
 
-	cmd = fmt.Sprintf("ovs-ofctl del-flows %s table=1,arp,arp_tpa=%s,dl_vlan=%v,arp_op=1",
-		bridgeName, ip.String(), vlanid)
-	_, err = platform.ExecuteCommand(cmd)
+	flow = fmt.Sprintf("table=1,arp,arp_tpa=%s,dl_vlan=%v,arp_op=1", ip.String(), vlanid)
+	_, err = platform.ExecWithTimeout("ovs-ofctl", "del-flows", bridgeName, flow)
 	if err != nil {
 		log.Printf("[net] Deleting ARP reply rule failed with error %v", err)
 	}
 }
 
 func DeleteIPSnatRule(bridgeName string, port string) {
-	cmd := fmt.Sprintf("ovs-ofctl del-flows %v ip,in_port=%s",
-		bridgeName, port)
-	_, err := platform.ExecuteCommand(cmd)
+	flow := fmt.Sprintf("ip,in_port=%s", port)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "del-flows", bridgeName, flow)
 	if err != nil {
-		log.Printf("Error while deleting ovs rule %v error %v", cmd, err)
+		log.Printf("Error while deleting ovs rule %v error %v", flow, err)
 	}
 }
 
 func DeleteMacDnatRule(bridgeName string, port string, ip net.IP, vlanid int) {
-	var cmd string
+	var flow string
 
 	if vlanid != 0 {
-		cmd = fmt.Sprintf("ovs-ofctl del-flows %s ip,nw_dst=%s,dl_vlan=%v,in_port=%s",
-			bridgeName, ip.String(), vlanid, port)
+		flow = fmt.Sprintf("ip,nw_dst=%s,dl_vlan=%v,in_port=%s", ip.String(), vlanid, port)
 	} else {
-		cmd = fmt.Sprintf("ovs-ofctl del-flows %s ip,nw_dst=%s,in_port=%s",
-			bridgeName, ip.String(), port)
+		flow = fmt.Sprintf("ip,nw_dst=%s,in_port=%s", ip.String(), port)
 	}
 
-	_, err := platform.ExecuteCommand(cmd)
+	_, err := platform.ExecWithTimeout("ovs-ofctl", "del-flows", bridgeName, flow)
 	if err != nil {
 		log.Printf("[net] Deleting MAC DNAT rule failed with error %v", err)
 	}
@@ -240,8 +226,7 @@ func DeleteMacDnatRule(bridgeName string, port string, ip net.IP, vlanid int) {
 
 func DeletePortFromOVS(bridgeName string, interfaceName string) error {
 	// Disconnect external interface from its bridge.
-	cmd := fmt.Sprintf("ovs-vsctl del-port %s %s", bridgeName, interfaceName)
-	_, err := platform.ExecuteCommand(cmd)
+	_, err := platform.ExecWithTimeout("ovs-vsctl", "del-port", bridgeName, interfaceName)
 	if err != nil {
 		log.Printf("[ovs] Failed to disconnect interface %v from bridge, err:%v.", interfaceName, err)
 		return err
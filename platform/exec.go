@@ -0,0 +1,97 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Time after which commands run by ExecWithTimeout are killed.
+	DefaultExecTimeout = 30 * time.Second
+)
+
+// ExecResult is the output of a command run by Exec.
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ExecError describes a command that failed to start, exited with a non-zero code or was killed.
+type ExecError struct {
+	Command  string
+	ExitCode int // -1 if the command did not exit by itself.
+	Stderr   string
+	Err      error
+}
+
+// Error returns the error message.
+func (e *ExecError) Error() string {
+	stderr := strings.TrimSpace(e.Stderr)
+	if stderr == "" {
+		return fmt.Sprintf("%s: %v", e.Command, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %v: %s", e.Command, e.Err, stderr)
+}
+
+// Exec runs a command without a shell, and kills it when the context is done before it exits.
+// The result holds the output and exit code of the command also when an *ExecError is returned.
+func Exec(ctx context.Context, name string, args ...string) (*ExecResult, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	log.Printf("[Azure-Utils] %s", command)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	result := &ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: -1,
+	}
+
+	if cmd.ProcessState != nil {
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			result.ExitCode = status.ExitStatus()
+		}
+	}
+
+	if err == nil {
+		return result, nil
+	}
+
+	// A killed command fails with the signal, report why it was killed instead.
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	return result, &ExecError{
+		Command:  command,
+		ExitCode: result.ExitCode,
+		Stderr:   result.Stderr,
+		Err:      err,
+	}
+}
+
+// ExecWithTimeout runs a command with Exec, and kills it if it does not exit within DefaultExecTimeout.
+func ExecWithTimeout(name string, args ...string) (*ExecResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultExecTimeout)
+	defer cancel()
+
+	return Exec(ctx, name, args...)
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package platform
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a Clock whose time only moves when advanced, for deterministic tests.
+type FakeClock struct {
+	now     time.Time
+	waiters []*fakeWaiter
+	sync.Mutex
+}
+
+// fakeWaiter is a pending timer or ticker of a FakeClock.
+type fakeWaiter struct {
+	deadline time.Time
+	period   time.Duration // Zero for timers.
+	c        chan time.Time
+}
+
+// fakeTicker is a Ticker of a FakeClock.
+type fakeTicker struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+// NewFakeClock creates a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (clock *FakeClock) Now() time.Time {
+	clock.Lock()
+	defer clock.Unlock()
+	return clock.now
+}
+
+// Since returns the time elapsed on the clock since t.
+func (clock *FakeClock) Since(t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
+
+// Sleep advances the clock by d instead of blocking.
+func (clock *FakeClock) Sleep(d time.Duration) {
+	clock.Advance(d)
+}
+
+// After returns a channel receiving the time once the clock was advanced by d.
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	return clock.addWaiter(d, 0).c
+}
+
+// NewTicker returns a Ticker ticking each time the clock was advanced by d.
+func (clock *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	return &fakeTicker{clock: clock, waiter: clock.addWaiter(d, d)}
+}
+
+// Advance moves the clock forward by d and fires the timers and tickers that expired.
+// Like time.Ticker, a ticker whose tick was not received drops the following ticks.
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.Lock()
+	defer clock.Unlock()
+
+	clock.now = clock.now.Add(d)
+
+	var waiters []*fakeWaiter
+	for _, w := range clock.waiters {
+		if w.deadline.After(clock.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+
+		select {
+		case w.c <- clock.now:
+		default:
+		}
+
+		if w.period > 0 {
+			for !w.deadline.After(clock.now) {
+				w.deadline = w.deadline.Add(w.period)
+			}
+			waiters = append(waiters, w)
+		}
+	}
+
+	clock.waiters = waiters
+}
+
+// Waiters returns the number of pending timers and tickers.
+// Tests use it to wait until the code under test is blocked on the clock before advancing it.
+func (clock *FakeClock) Waiters() int {
+	clock.Lock()
+	defer clock.Unlock()
+	return len(clock.waiters)
+}
+
+// Adds a timer or ticker expiring after d.
+func (clock *FakeClock) addWaiter(d time.Duration, period time.Duration) *fakeWaiter {
+	clock.Lock()
+	defer clock.Unlock()
+
+	w := &fakeWaiter{
+		deadline: clock.now.Add(d),
+		period:   period,
+		c:        make(chan time.Time, 1),
+	}
+
+	if d <= 0 && period == 0 {
+		w.c <- clock.now
+		return w
+	}
+
+	clock.waiters = append(clock.waiters, w)
+	return w
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.Lock()
+	defer t.clock.Unlock()
+
+	for i, w := range t.clock.waiters {
+		if w == t.waiter {
+			t.clock.waiters = append(t.clock.waiters[:i], t.clock.waiters[i+1:]...)
+			break
+		}
+	}
+}
@@ -4,10 +4,9 @@
 package platform
 
 import (
-	"bytes"
-	"fmt"
 	"io/ioutil"
 	"os/exec"
+	"syscall"
 	"time"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -29,6 +28,9 @@ const (
 
 	// DNCRuntimePath is the path where DNC logging files are stored.
 	DNCRuntimePath = "/var/run/"
+
+	// Directory of the kernel parameters.
+	sysctlRoot = "/proc/sys/"
 )
 
 // GetOSInfo returns OS version information.
@@ -61,27 +63,16 @@ func GetLastRebootTime() (time.Time, error) {
 	return rebootTime.UTC(), nil
 }
 
-func ExecuteCommand(command string) (string, error) {
-	log.Printf("[Azure-Utils] %s", command)
-
-	var stderr bytes.Buffer
-	var out bytes.Buffer
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Stderr = &stderr
-	cmd.Stdout = &out
-
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("%s:%s", err.Error(), stderr.String())
-	}
-
-	return out.String(), nil
+// SetSysctl writes the value of a kernel parameter, given by its path under /proc/sys, e.g. net/ipv4/ip_forward.
+func SetSysctl(path string, value string) error {
+	log.Printf("[Azure-Utils] sysctl %v=%v", path, value)
+	return ioutil.WriteFile(sysctlRoot+path, []byte(value), 0644)
 }
 
 func SetOutboundSNAT(subnet string) error {
-	cmd := fmt.Sprintf("iptables -t nat -A POSTROUTING -m iprange ! --dst-range 168.63.129.16 -m addrtype ! --dst-type local ! -d %v -j MASQUERADE",
-		subnet)
-	_, err := ExecuteCommand(cmd)
+	_, err := ExecWithTimeout("iptables", "-t", "nat", "-A", "POSTROUTING",
+		"-m", "iprange", "!", "--dst-range", "168.63.129.16", "-m", "addrtype", "!", "--dst-type", "local",
+		"!", "-d", subnet, "-j", "MASQUERADE")
 	if err != nil {
 		log.Printf("SNAT Iptable rule was not set")
 		return err
@@ -96,7 +87,13 @@ func ClearNetworkConfiguration() (bool, error) {
 }
 
 func KillProcessByName(processName string) error {
-	cmd := fmt.Sprintf("pkill -f %v", processName)
-	_, err := ExecuteCommand(cmd)
+	_, err := ExecWithTimeout("pkill", "-f", processName)
 	return err
 }
+
+// IsProcessRunning returns whether a process with the given ID exists.
+func IsProcessRunning(pid int) bool {
+	// Signal 0 only checks whether the process exists, and fails with EPERM for processes of other users.
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
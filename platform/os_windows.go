@@ -4,16 +4,27 @@
 package platform
 
 import (
-	"bytes"
 	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 )
 
+const (
+	// Access right to query the exit code of a process.
+	processQueryLimitedInformation = 0x1000
+
+	// Exit code of processes that did not exit yet.
+	stillActive = 259
+
+	// Error opening a process that does not exist.
+	errorInvalidParameter = syscall.Errno(87)
+)
+
 const (
 
 	// CNMRuntimePath is the path where CNM state files are stored.
@@ -103,23 +114,6 @@ func GetLastRebootTime() (time.Time, error) {
 	return rebootTime.UTC(), nil
 }
 
-func ExecuteCommand(command string) (string, error) {
-	log.Printf("[Azure-Utils] %s", command)
-
-	var stderr bytes.Buffer
-	var out bytes.Buffer
-	cmd := exec.Command("cmd", "/c", command)
-	cmd.Stderr = &stderr
-	cmd.Stdout = &out
-
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("%s:%s", err.Error(), stderr.String())
-	}
-
-	return out.String(), nil
-}
-
 func SetOutboundSNAT(subnet string) error {
 	return nil
 }
@@ -140,6 +134,22 @@ func ClearNetworkConfiguration() (bool, error) {
 }
 
 func KillProcessByName(processName string) {
-	cmd := fmt.Sprintf("taskkill /IM %v /F", processName)
-	ExecuteCommand(cmd)
+	ExecWithTimeout("taskkill", "/IM", processName, "/F")
+}
+
+// IsProcessRunning returns whether a process with the given ID exists.
+func IsProcessRunning(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Processes that exist but can not be opened are still running.
+		return err != errorInvalidParameter
+	}
+	defer syscall.CloseHandle(handle)
+
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return true
+	}
+
+	return exitCode == stillActive
 }
@@ -0,0 +1,434 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// Default file name for backing bolt database.
+	defaultBoltFileName = "azure-container-networking.db"
+
+	// Current schema version of the bolt database.
+	boltSchemaVersion = 1
+
+	// Maximum time to wait for another process to release the database.
+	boltOpenTimeout = lockMaxRetries * lockRetryDelay
+
+	// Databases smaller than this number of pages are never compacted.
+	boltCompactMinPages = 64
+
+	// Databases are compacted when at least this fraction of their pages are free.
+	boltCompactFreeRatio = 0.5
+
+	// Extension added to the file name for the compacted database copy.
+	compactExtension = ".compact"
+
+	// Extension added to the legacy JSON file name once its contents are migrated.
+	migratedExtension = ".migrated"
+)
+
+var (
+	// Bucket holding the (key,value) pairs.
+	boltDataBucket = []byte("data")
+
+	// Bucket holding the database metadata.
+	boltMetaBucket = []byte("meta")
+
+	// Metadata key for the database schema version.
+	boltSchemaVersionKey = []byte("schemaVersion")
+)
+
+// boltFileStore is an implementation of KeyValueStore using a local bolt database.
+// Every write is committed in its own transaction, so an unclean shutdown never leaves
+// the store partially written.
+type boltFileStore struct {
+	fileName    string
+	locked      bool
+	lockTimeout time.Duration
+	lockStats   LockStats
+	sync.Mutex
+}
+
+// NewBoltFileStore creates a new boltFileStore object, accessed as a KeyValueStore.
+// If the database is new and legacyFileName names an existing JSON file store,
+// the contents of the JSON file store are migrated to the database.
+func NewBoltFileStore(fileName string, legacyFileName string) (KeyValueStore, error) {
+	if fileName == "" {
+		fileName = defaultBoltFileName
+	}
+
+	kvs := &boltFileStore{
+		fileName: fileName,
+	}
+
+	if err := kvs.initialize(legacyFileName); err != nil {
+		return nil, err
+	}
+
+	// A failed compaction leaves the original database intact.
+	if err := kvs.compact(); err != nil {
+		log.Printf("[store] Failed to compact %v: %v", kvs.fileName, err)
+	}
+
+	return kvs, nil
+}
+
+// Opens the backing database.
+func (kvs *boltFileStore) open(readOnly bool) (*bolt.DB, error) {
+	return bolt.Open(kvs.fileName, 0644, &bolt.Options{Timeout: boltOpenTimeout, ReadOnly: readOnly})
+}
+
+// Creates the buckets, checks the schema version and migrates the legacy JSON file store.
+func (kvs *boltFileStore) initialize(legacyFileName string) error {
+	db, err := kvs.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if version > boltSchemaVersion {
+		return fmt.Errorf("store schema version %d is newer than supported version %d", version, boltSchemaVersion)
+	}
+
+	// Leave up-to-date databases untouched, the modification time is used to detect reboots.
+	if version == boltSchemaVersion {
+		return nil
+	}
+
+	migrated := false
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		if err != nil {
+			return err
+		}
+
+		data, err := tx.CreateBucketIfNotExists(boltDataBucket)
+		if err != nil {
+			return err
+		}
+
+		// Version 0 is a new database, seed it from the legacy JSON file store.
+		if version == 0 && legacyFileName != "" {
+			migrated, err = migrateJsonFile(legacyFileName, data)
+			if err != nil {
+				return err
+			}
+		}
+
+		return meta.Put(boltSchemaVersionKey, []byte(strconv.Itoa(boltSchemaVersion)))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// Keep the legacy file around for reference, but make sure it is never loaded again.
+	if migrated {
+		if err := os.Rename(legacyFileName, legacyFileName+migratedExtension); err != nil {
+			log.Printf("[store] Failed to rename migrated file %v: %v", legacyFileName, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the schema version of the database, zero if the database is new.
+func getSchemaVersion(db *bolt.DB) (int, error) {
+	version := 0
+
+	err := db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMetaBucket)
+		if meta == nil {
+			return nil
+		}
+
+		v := meta.Get(boltSchemaVersionKey)
+		if v == nil {
+			return nil
+		}
+
+		var err error
+		if version, err = strconv.Atoi(string(v)); err != nil {
+			return fmt.Errorf("invalid store schema version %q", v)
+		}
+
+		return nil
+	})
+
+	return version, err
+}
+
+// Copies the (key,value) pairs of a JSON file store to the given bucket.
+// Returns whether the file existed and was migrated.
+func migrateJsonFile(fileName string, bucket *bolt.Bucket) (bool, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	var data map[string]*json.RawMessage
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		// A corrupted legacy file cannot be recovered, start with an empty store instead.
+		log.Printf("[store] Failed to decode %v, skipping migration: %v", fileName, err)
+		return false, nil
+	}
+
+	for key, raw := range data {
+		if raw == nil {
+			continue
+		}
+
+		if err := bucket.Put([]byte(key), *raw); err != nil {
+			return false, err
+		}
+	}
+
+	log.Printf("[store] Migrated %d keys from %v.", len(data), fileName)
+
+	return true, nil
+}
+
+// Rewrites the database without its free pages if they make up most of the file.
+func (kvs *boltFileStore) compact() error {
+	db, err := kvs.open(false)
+	if err != nil {
+		return err
+	}
+
+	var totalPages int
+	err = db.View(func(tx *bolt.Tx) error {
+		totalPages = int(tx.Size() / int64(db.Info().PageSize))
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	stats := db.Stats()
+	freePages := stats.FreePageN + stats.PendingPageN
+
+	if totalPages < boltCompactMinPages || float64(freePages) < boltCompactFreeRatio*float64(totalPages) {
+		return db.Close()
+	}
+
+	log.Printf("[store] Compacting %v, %d of %d pages are free.", kvs.fileName, freePages, totalPages)
+
+	compactFileName := kvs.fileName + compactExtension
+	os.Remove(compactFileName)
+
+	compactDb, err := bolt.Open(compactFileName, 0644, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	err = db.View(func(srcTx *bolt.Tx) error {
+		return compactDb.Update(func(dstTx *bolt.Tx) error {
+			return srcTx.ForEach(func(name []byte, srcBucket *bolt.Bucket) error {
+				dstBucket, err := dstTx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+
+				return srcBucket.ForEach(func(k, v []byte) error {
+					return dstBucket.Put(k, v)
+				})
+			})
+		})
+	})
+
+	compactDb.Close()
+	db.Close()
+
+	if err != nil {
+		os.Remove(compactFileName)
+		return err
+	}
+
+	// Carry over the modification time, it is used to detect reboots.
+	if info, err := os.Stat(kvs.fileName); err == nil {
+		os.Chtimes(compactFileName, info.ModTime(), info.ModTime())
+	}
+
+	return os.Rename(compactFileName, kvs.fileName)
+}
+
+// Read restores the value for the given key from persistent store.
+func (kvs *boltFileStore) Read(key string, value interface{}) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	db, err := kvs.open(true)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrKeyNotFound
+		}
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDataBucket)
+		if bucket == nil {
+			return ErrKeyNotFound
+		}
+
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return ErrKeyNotFound
+		}
+
+		if err := json.Unmarshal(raw, value); err != nil {
+			return &CorruptedError{FileName: kvs.fileName, Err: err}
+		}
+
+		return nil
+	})
+}
+
+// Write saves the given key value pair to persistent store.
+func (kvs *boltFileStore) Write(key string, value interface{}) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	db, err := kvs.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltDataBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), raw)
+	})
+}
+
+// Flush commits in-memory state to persistent store.
+// Writes are committed as they happen, so there is nothing to flush.
+func (kvs *boltFileStore) Flush() error {
+	return nil
+}
+
+// Lock locks the store for exclusive access.
+func (kvs *boltFileStore) Lock(block bool) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if kvs.locked {
+		return ErrStoreLocked
+	}
+
+	if err := acquireLockFile(kvs.fileName+lockExtension, block, kvs.lockTimeout, &kvs.lockStats); err != nil {
+		return err
+	}
+
+	kvs.locked = true
+
+	return nil
+}
+
+// Unlock unlocks the store.
+func (kvs *boltFileStore) Unlock(forceUnlock bool) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if !forceUnlock && !kvs.locked {
+		return ErrStoreNotLocked
+	}
+
+	if err := releaseLockFile(kvs.fileName + lockExtension); err != nil {
+		return err
+	}
+
+	kvs.locked = false
+
+	return nil
+}
+
+// SetLockTimeout sets the time a blocking lock call waits for a lock file that is not refreshed by its owner.
+func (kvs *boltFileStore) SetLockTimeout(timeout time.Duration) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	kvs.lockTimeout = timeout
+}
+
+// GetLockStats returns the statistics of the lock calls of the store.
+func (kvs *boltFileStore) GetLockStats() LockStats {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return kvs.lockStats
+}
+
+// RefreshLock updates the modification time of the lock file held by the store.
+func (kvs *boltFileStore) RefreshLock() error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if !kvs.locked {
+		return ErrStoreNotLocked
+	}
+
+	return refreshLockFile(kvs.fileName + lockExtension)
+}
+
+// GetModificationTime returns the modification time of the persistent store.
+func (kvs *boltFileStore) GetModificationTime() (time.Time, error) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	info, err := os.Stat(kvs.fileName)
+	if err != nil {
+		log.Printf("os.stat() for file %v failed: %v", kvs.fileName, err)
+		return time.Time{}.UTC(), err
+	}
+
+	return info.ModTime().UTC(), nil
+}
+
+// GetLockFileModificationTime returns the modification time of the lock file of the persistent store.
+func (kvs *boltFileStore) GetLockFileModificationTime() (time.Time, error) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	lockFileName := kvs.fileName + lockExtension
+
+	info, err := os.Stat(lockFileName)
+	if err != nil {
+		return time.Time{}.UTC(), err
+	}
+
+	return info.ModTime().UTC(), nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	// File names used for test bolt store.
+	testBoltFileName       = "test.db"
+	testLegacyJsonFileName = "test-legacy.json"
+)
+
+// Removes all files created by the bolt store tests.
+func cleanupBoltTestFiles() {
+	os.Remove(testBoltFileName)
+	os.Remove(testBoltFileName + lockExtension)
+	os.Remove(testBoltFileName + compactExtension)
+	os.Remove(testLegacyJsonFileName)
+	os.Remove(testLegacyJsonFileName + migratedExtension)
+}
+
+// Tests that the key value pairs are written, persisted and read back correctly.
+func TestBoltKeyValuePairsAreWrittenAndReadCorrectly(t *testing.T) {
+	var writtenValue = testType1{"test", 42}
+	var readValue testType1
+
+	cleanupBoltTestFiles()
+	defer cleanupBoltTestFiles()
+
+	kvs, err := NewBoltFileStore(testBoltFileName, "")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	err = kvs.Read(testKey1, &readValue)
+	if err != ErrKeyNotFound {
+		t.Errorf("Read of a missing key returned %v", err)
+	}
+
+	err = kvs.Write(testKey1, &writtenValue)
+	if err != nil {
+		t.Fatalf("Failed to write to store: %v", err)
+	}
+
+	// Reopen the store to make sure the value was persisted.
+	kvs, err = NewBoltFileStore(testBoltFileName, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+
+	err = kvs.Read(testKey1, &readValue)
+	if err != nil {
+		t.Fatalf("Failed to read from store: %v", err)
+	}
+
+	if readValue != writtenValue {
+		t.Errorf("Read value %+v does not match written value %+v", readValue, writtenValue)
+	}
+}
+
+// Tests that the contents of a legacy JSON file store are migrated to a new bolt store.
+func TestBoltStoreMigratesLegacyJsonFile(t *testing.T) {
+	var readValue testType1
+
+	cleanupBoltTestFiles()
+	defer cleanupBoltTestFiles()
+
+	file, err := os.Create(testLegacyJsonFileName)
+	if err != nil {
+		t.Fatalf("Failed to create legacy file: %v", err)
+	}
+	file.WriteString(`{"key1":{"Field1":"test","Field2":42},"key2":"value2"}`)
+	file.Close()
+
+	kvs, err := NewBoltFileStore(testBoltFileName, testLegacyJsonFileName)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	err = kvs.Read(testKey1, &readValue)
+	if err != nil {
+		t.Fatalf("Failed to read migrated key: %v", err)
+	}
+
+	if readValue.Field1 != "test" || readValue.Field2 != 42 {
+		t.Errorf("Migrated value %+v is not correct", readValue)
+	}
+
+	if _, err := os.Stat(testLegacyJsonFileName); !os.IsNotExist(err) {
+		t.Errorf("Legacy file was not renamed after migration")
+	}
+
+	// Recreating the legacy file must not override the migrated state.
+	file, err = os.Create(testLegacyJsonFileName)
+	if err != nil {
+		t.Fatalf("Failed to create legacy file: %v", err)
+	}
+	file.WriteString(`{"key1":{"Field1":"stale","Field2":0}}`)
+	file.Close()
+
+	kvs, err = NewBoltFileStore(testBoltFileName, testLegacyJsonFileName)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+
+	err = kvs.Read(testKey1, &readValue)
+	if err != nil || readValue.Field1 != "test" {
+		t.Errorf("Migration ran twice, value:%+v err:%v", readValue, err)
+	}
+}
+
+// Tests that reopening a store compacts it once most of its pages are free.
+func TestBoltStoreIsCompacted(t *testing.T) {
+	cleanupBoltTestFiles()
+	defer cleanupBoltTestFiles()
+
+	kvs, err := NewBoltFileStore(testBoltFileName, "")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Grow the database with a large value, then shrink the value.
+	err = kvs.Write(testKey1, strings.Repeat("x", 1<<20))
+	if err != nil {
+		t.Fatalf("Failed to write to store: %v", err)
+	}
+
+	err = kvs.Write(testKey1, "x")
+	if err != nil {
+		t.Fatalf("Failed to write to store: %v", err)
+	}
+
+	info, err := os.Stat(testBoltFileName)
+	if err != nil {
+		t.Fatalf("Failed to stat store: %v", err)
+	}
+	sizeBefore := info.Size()
+
+	kvs, err = NewBoltFileStore(testBoltFileName, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+
+	info, err = os.Stat(testBoltFileName)
+	if err != nil {
+		t.Fatalf("Failed to stat store: %v", err)
+	}
+
+	if info.Size() >= sizeBefore {
+		t.Errorf("Store was not compacted, size before:%v after:%v", sizeBefore, info.Size())
+	}
+
+	var readValue string
+	err = kvs.Read(testKey1, &readValue)
+	if err != nil || readValue != "x" {
+		t.Errorf("Compacted store returned value:%v err:%v", readValue, err)
+	}
+}
+
+// Tests that locking a bolt store gives the caller exclusive access.
+func TestBoltLockingStoreGivesExclusiveAccess(t *testing.T) {
+	cleanupBoltTestFiles()
+	defer cleanupBoltTestFiles()
+
+	kvs, err := NewBoltFileStore(testBoltFileName, "")
+	if err != nil {
+		t.Fatalf("Failed to create first store: %v", err)
+	}
+
+	kvs2, err := NewBoltFileStore(testBoltFileName, "")
+	if err != nil {
+		t.Fatalf("Failed to create second store: %v", err)
+	}
+
+	err = kvs.Lock(false)
+	if err != nil {
+		t.Errorf("Failed to lock store: %v", err)
+	}
+
+	err = kvs2.Lock(false)
+	if err == nil {
+		t.Errorf("Locking an already-locked store succeeded")
+	}
+
+	err = kvs.Unlock(false)
+	if err != nil {
+		t.Errorf("Failed to unlock first store: %v", err)
+	}
+
+	err = kvs2.Lock(false)
+	if err != nil {
+		t.Errorf("Failed to re-lock an unlocked store: %v", err)
+	}
+
+	err = kvs2.Unlock(false)
+	if err != nil {
+		t.Errorf("Failed to unlock second store: %v", err)
+	}
+}
@@ -6,7 +6,6 @@ package store
 import (
 	"encoding/json"
 	"os"
-	"strconv"
 	"sync"
 	"time"
 
@@ -29,10 +28,12 @@ const (
 
 // jsonFileStore is an implementation of KeyValueStore using a local JSON file.
 type jsonFileStore struct {
-	fileName string
-	data     map[string]*json.RawMessage
-	inSync   bool
-	locked   bool
+	fileName    string
+	data        map[string]*json.RawMessage
+	inSync      bool
+	locked      bool
+	lockTimeout time.Duration
+	lockStats   LockStats
 	sync.Mutex
 }
 
@@ -69,7 +70,7 @@ func (kvs *jsonFileStore) Read(key string, value interface{}) error {
 
 		// Decode to raw JSON messages.
 		if err := json.NewDecoder(file).Decode(&kvs.data); err != nil {
-			return err
+			return &CorruptedError{FileName: kvs.fileName, Err: err}
 		}
 
 		kvs.inSync = true
@@ -80,7 +81,11 @@ func (kvs *jsonFileStore) Read(key string, value interface{}) error {
 		return ErrKeyNotFound
 	}
 
-	return json.Unmarshal(*raw, value)
+	if err := json.Unmarshal(*raw, value); err != nil {
+		return &CorruptedError{FileName: kvs.fileName, Err: err}
+	}
+
+	return nil
 }
 
 // Write saves the given key value pair to persistent store.
@@ -135,47 +140,7 @@ func (kvs *jsonFileStore) Lock(block bool) error {
 		return ErrStoreLocked
 	}
 
-	var lockFile *os.File
-	var err error
-	lockName := kvs.fileName + lockExtension
-	lockPerm := os.FileMode(0664) + os.FileMode(os.ModeExclusive)
-
-	// Try to acquire the lock file.
-	var lockRetryCount uint
-	var modTimeCur time.Time
-	var modTimePrev time.Time
-	for lockRetryCount < lockMaxRetries {
-		lockFile, err = os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_RDWR, lockPerm)
-		if err == nil {
-			break
-		}
-
-		if !block {
-			return ErrNonBlockingLockIsAlreadyLocked
-		}
-
-		// Reset the lock retry count if the timestamp for the lock file changes.
-		if fileInfo, err := os.Stat(lockName); err == nil {
-			modTimeCur = fileInfo.ModTime()
-			if !modTimeCur.Equal(modTimePrev) {
-				lockRetryCount = 0
-			}
-			modTimePrev = modTimeCur
-		}
-
-		time.Sleep(lockRetryDelay)
-
-		lockRetryCount++
-	}
-
-	if lockRetryCount == lockMaxRetries {
-		return ErrTimeoutLockingStore
-	}
-
-	defer lockFile.Close()
-
-	// Write the process ID for easy identification.
-	if _, err = lockFile.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+	if err := acquireLockFile(kvs.fileName+lockExtension, block, kvs.lockTimeout, &kvs.lockStats); err != nil {
 		return err
 	}
 
@@ -193,7 +158,7 @@ func (kvs *jsonFileStore) Unlock(forceUnlock bool) error {
 		return ErrStoreNotLocked
 	}
 
-	err := os.Remove(kvs.fileName + lockExtension)
+	err := releaseLockFile(kvs.fileName + lockExtension)
 	if err != nil {
 		return err
 	}
@@ -204,6 +169,34 @@ func (kvs *jsonFileStore) Unlock(forceUnlock bool) error {
 	return nil
 }
 
+// SetLockTimeout sets the time a blocking lock call waits for a lock file that is not refreshed by its owner.
+func (kvs *jsonFileStore) SetLockTimeout(timeout time.Duration) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	kvs.lockTimeout = timeout
+}
+
+// GetLockStats returns the statistics of the lock calls of the store.
+func (kvs *jsonFileStore) GetLockStats() LockStats {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return kvs.lockStats
+}
+
+// RefreshLock updates the modification time of the lock file held by the store.
+func (kvs *jsonFileStore) RefreshLock() error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if !kvs.locked {
+		return ErrStoreNotLocked
+	}
+
+	return refreshLockFile(kvs.fileName + lockExtension)
+}
+
 // GetModificationTime returns the modification time of the persistent store.
 func (kvs *jsonFileStore) GetModificationTime() (time.Time, error) {
 	kvs.Mutex.Lock()
@@ -4,9 +4,15 @@
 package store
 
 import (
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
 )
 
 const (
@@ -206,3 +212,188 @@ func TestLockingStoreGivesExclusiveAccess(t *testing.T) {
 	// Cleanup.
 	os.Remove(testFileName)
 }
+
+// Tests that refreshing the lock updates the lock file and requires the lock to be held.
+func TestRefreshLockUpdatesLockFile(t *testing.T) {
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if err := kvs.RefreshLock(); err != ErrStoreNotLocked {
+		t.Errorf("Refreshing an unlocked store returned %v", err)
+	}
+
+	if err := kvs.Lock(false); err != nil {
+		t.Fatalf("Failed to lock store: %v", err)
+	}
+	defer kvs.Unlock(false)
+
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(testFileName+lockExtension, past, past); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+
+	if err := kvs.RefreshLock(); err != nil {
+		t.Fatalf("Failed to refresh lock: %v", err)
+	}
+
+	modTime, err := kvs.GetLockFileModificationTime()
+	if err != nil {
+		t.Fatalf("Failed to get lock file modification time: %v", err)
+	}
+
+	if !modTime.After(past.Add(time.Minute)) {
+		t.Errorf("Lock file modification time %v was not refreshed", modTime)
+	}
+}
+
+// Tests that a blocking lock gives up once the retries are exhausted.
+func TestBlockingLockTimesOut(t *testing.T) {
+	fakeClock := platform.NewFakeClock(time.Now())
+	clock = fakeClock
+	defer func() { clock = platform.NewClock() }()
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create first store: %v", err)
+	}
+
+	if err := kvs.Lock(false); err != nil {
+		t.Fatalf("Failed to lock store: %v", err)
+	}
+	defer kvs.Unlock(false)
+
+	kvs2, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create second store: %v", err)
+	}
+
+	start := fakeClock.Now()
+	if err := kvs2.Lock(true); err != ErrTimeoutLockingStore {
+		t.Errorf("Unexpected error locking an already-locked store: %v", err)
+	}
+
+	if waited := fakeClock.Since(start); waited != lockMaxRetries*lockRetryDelay {
+		t.Errorf("Unexpected time waited for the lock: %v", waited)
+	}
+
+	os.Remove(testFileName)
+}
+
+// Tests that a blocking lock gives up after the configured timeout.
+func TestBlockingLockTimesOutAfterConfiguredTimeout(t *testing.T) {
+	fakeClock := platform.NewFakeClock(time.Now())
+	clock = fakeClock
+	defer func() { clock = platform.NewClock() }()
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create first store: %v", err)
+	}
+
+	if err := kvs.Lock(false); err != nil {
+		t.Fatalf("Failed to lock store: %v", err)
+	}
+	defer kvs.Unlock(false)
+
+	kvs2, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create second store: %v", err)
+	}
+
+	kvs2.SetLockTimeout(time.Second)
+
+	start := fakeClock.Now()
+	if err := kvs2.Lock(true); err != ErrTimeoutLockingStore {
+		t.Errorf("Unexpected error locking an already-locked store: %v", err)
+	}
+
+	if waited := fakeClock.Since(start); waited != time.Second {
+		t.Errorf("Unexpected time waited for the lock: %v", waited)
+	}
+
+	stats := kvs2.GetLockStats()
+	if stats.Timeouts != 1 || stats.Acquisitions != 0 || stats.MaxWaitTime != time.Second {
+		t.Errorf("Unexpected lock stats: %+v", stats)
+	}
+
+	os.Remove(testFileName)
+}
+
+// Tests that a lock file left behind by a process that exited is broken.
+func TestStaleLockIsBroken(t *testing.T) {
+	// Get the ID of a process that no longer runs.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run process: %v", err)
+	}
+
+	lockName := testFileName + lockExtension
+	if err := ioutil.WriteFile(lockName, []byte(strconv.Itoa(cmd.Process.Pid)), 0664); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	defer os.Remove(lockName)
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if err := kvs.Lock(false); err != nil {
+		t.Fatalf("Failed to lock store with stale lock file: %v", err)
+	}
+
+	if stats := kvs.GetLockStats(); stats.StaleLocksBroken != 1 || stats.Acquisitions != 1 {
+		t.Errorf("Unexpected lock stats: %+v", stats)
+	}
+
+	if pid, err := readLockOwner(lockName); err != nil || pid != os.Getpid() {
+		t.Errorf("Lock file is owned by %v instead of %v: %v", pid, os.Getpid(), err)
+	}
+
+	if err := kvs.Unlock(false); err != nil {
+		t.Errorf("Failed to unlock store: %v", err)
+	}
+}
+
+// Tests that a lock file of a running process is not broken.
+func TestLockOfRunningProcessIsNotBroken(t *testing.T) {
+	lockName := testFileName + lockExtension
+	if err := ioutil.WriteFile(lockName, []byte(strconv.Itoa(os.Getppid())), 0664); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	defer os.Remove(lockName)
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if err := kvs.Lock(false); err != ErrNonBlockingLockIsAlreadyLocked {
+		t.Errorf("Unexpected error locking a store locked by a running process: %v", err)
+	}
+
+	if stats := kvs.GetLockStats(); stats.StaleLocksBroken != 0 {
+		t.Errorf("Unexpected lock stats: %+v", stats)
+	}
+}
+
+// Tests that reading a store whose file can not be decoded reports the store as corrupted.
+func TestCorruptedStoreIsReported(t *testing.T) {
+	if err := ioutil.WriteFile(testFileName, []byte(`{"key1": {"Field1": `), 0664); err != nil {
+		t.Fatalf("Failed to write store file: %v", err)
+	}
+	defer os.Remove(testFileName)
+
+	kvs, err := NewJsonFileStore(testFileName)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	var value testType1
+	err = kvs.Read(testKey1, &value)
+	if corruptedErr, ok := err.(*CorruptedError); !ok || corruptedErr.FileName != testFileName {
+		t.Errorf("Unexpected error reading corrupted store: %v", err)
+	}
+}
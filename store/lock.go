@@ -0,0 +1,181 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Default time a blocking lock call waits for a lock file that is not refreshed by its owner.
+	defaultLockTimeout = lockMaxRetries * lockRetryDelay
+
+	// Extension added to a stale lock file while it is being broken.
+	staleLockExtension = ".stale."
+
+	// Time a lock file without an owner process ID may still be written by its owner.
+	lockOwnerGracePeriod = 10 * time.Second
+)
+
+// Clock used to wait between attempts to acquire a lock.
+var clock = platform.NewClock()
+
+// LockStats counts the lock calls of a store, and the time they waited for the lock.
+type LockStats struct {
+	Acquisitions     int
+	Timeouts         int
+	StaleLocksBroken int
+	TotalWaitTime    time.Duration
+	MaxWaitTime      time.Duration
+}
+
+// Records a lock call that waited for the given time.
+func (stats *LockStats) addWait(waitTime time.Duration) {
+	stats.TotalWaitTime += waitTime
+	if waitTime > stats.MaxWaitTime {
+		stats.MaxWaitTime = waitTime
+	}
+}
+
+// Acquires the given lock file, retrying while it is held by someone else if block is set.
+// A blocking call gives up once the lock file was not refreshed for the given timeout.
+// Lock files left behind by processes that exited are broken.
+func acquireLockFile(lockName string, block bool, timeout time.Duration, stats *LockStats) error {
+	var lockFile *os.File
+	var err error
+	lockPerm := os.FileMode(0664) + os.FileMode(os.ModeExclusive)
+
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+
+	maxRetries := uint(timeout / lockRetryDelay)
+	if maxRetries == 0 {
+		maxRetries = 1
+	}
+
+	// Try to acquire the lock file.
+	start := clock.Now()
+	var lockRetryCount uint
+	var modTimeCur time.Time
+	var modTimePrev time.Time
+	for lockRetryCount < maxRetries {
+		lockFile, err = os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_RDWR, lockPerm)
+		if err == nil {
+			break
+		}
+
+		if breakStaleLockFile(lockName) {
+			stats.StaleLocksBroken++
+			continue
+		}
+
+		if !block {
+			return ErrNonBlockingLockIsAlreadyLocked
+		}
+
+		// Reset the lock retry count if the timestamp for the lock file changes.
+		if fileInfo, err := os.Stat(lockName); err == nil {
+			modTimeCur = fileInfo.ModTime()
+			if !modTimeCur.Equal(modTimePrev) {
+				lockRetryCount = 0
+			}
+			modTimePrev = modTimeCur
+		}
+
+		clock.Sleep(lockRetryDelay)
+
+		lockRetryCount++
+	}
+
+	stats.addWait(clock.Since(start))
+
+	if lockRetryCount == maxRetries {
+		stats.Timeouts++
+		pid, _ := readLockOwner(lockName)
+		log.Printf("[store] Timed out waiting %v for lock %v held by process %v.", timeout, lockName, pid)
+		return ErrTimeoutLockingStore
+	}
+
+	stats.Acquisitions++
+
+	defer lockFile.Close()
+
+	// Write the process ID for easy identification.
+	if _, err = lockFile.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Removes the given lock file if its owner is gone, and returns whether it was removed.
+func breakStaleLockFile(lockName string) bool {
+	pid, err := readLockOwner(lockName)
+	if err != nil || !isLockOwnerGone(lockName, pid) {
+		return false
+	}
+
+	// Move the lock file aside before checking its owner again,
+	// so that a lock acquired by another process in the meantime is not removed.
+	staleName := lockName + staleLockExtension + strconv.Itoa(os.Getpid())
+	if err := os.Rename(lockName, staleName); err != nil {
+		return false
+	}
+	defer os.Remove(staleName)
+
+	if stalePid, err := readLockOwner(staleName); err != nil || stalePid != pid {
+		// Restore the lock unless it was acquired again in the meantime.
+		os.Link(staleName, lockName)
+		return false
+	}
+
+	log.Printf("[store] Broke stale lock %v of process %v.", lockName, pid)
+
+	return true
+}
+
+// Returns the ID of the process owning the given lock file, or zero if it was not written yet.
+func readLockOwner(lockName string) (int, error) {
+	content, err := ioutil.ReadFile(lockName)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, nil
+	}
+
+	return pid, nil
+}
+
+// Returns whether the owner of the given lock file exited without releasing it.
+func isLockOwnerGone(lockName string, pid int) bool {
+	if pid == 0 {
+		// The owner writes its process ID right after creating the lock file.
+		fileInfo, err := os.Stat(lockName)
+		return err == nil && time.Since(fileInfo.ModTime()) > lockOwnerGracePeriod
+	}
+
+	return pid != os.Getpid() && !platform.IsProcessRunning(pid)
+}
+
+// Releases the given lock file.
+func releaseLockFile(lockName string) error {
+	return os.Remove(lockName)
+}
+
+// Updates the modification time of the given lock file to show that its owner is alive.
+func refreshLockFile(lockName string) error {
+	now := time.Now()
+	return os.Chtimes(lockName, now, now)
+}
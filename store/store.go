@@ -15,6 +15,9 @@ type KeyValueStore interface {
 	Flush() error
 	Lock(block bool) error
 	Unlock(forceUnlock bool) error
+	RefreshLock() error
+	SetLockTimeout(timeout time.Duration)
+	GetLockStats() LockStats
 	GetModificationTime() (time.Time, error)
 	GetLockFileModificationTime() (time.Time, error)
 }
@@ -27,3 +30,14 @@ var (
 	ErrTimeoutLockingStore            = fmt.Errorf("timed out locking store")
 	ErrNonBlockingLockIsAlreadyLocked = fmt.Errorf("attempted to perform non-blocking lock on an already locked store")
 )
+
+// CorruptedError is returned by KeyValueStore methods when the contents of the store can not be decoded.
+type CorruptedError struct {
+	FileName string
+	Err      error
+}
+
+// Error returns the description of the error.
+func (e *CorruptedError) Error() string {
+	return fmt.Sprintf("store %s is corrupted: %v", e.FileName, e.Err)
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"encoding/json"
+)
+
+// Severities of buffered reports. When the payload exceeds its memory budget, the oldest reports of the lowest
+// severity are evicted first.
+const (
+	// Successful CNI reports, and the periodic reports of the other components.
+	severityLow = iota
+	// Failed CNI reports.
+	severityMedium
+	// Incident and crash reports.
+	severityHigh
+)
+
+// Lists of the payload holding the reports of the built-in types.
+const (
+	listDNCReports      = "DNCReports"
+	listCNIReports      = "CNIReports"
+	listNPMReports      = "NPMReports"
+	listCNSReports      = "CNSReports"
+	listIncidentReports = "IncidentReports"
+	listCrashReports    = "CrashReports"
+)
+
+// payloadEntry - a report buffered in the payload, in the order reports were pushed. Summary reports are not
+// tracked as they are never evicted.
+type payloadEntry struct {
+	list       string // List of the payload holding the report, or the name of its registered type.
+	registered bool
+	severity   int
+	size       int // Bytes of the encoded report.
+}
+
+// reportSeverity - get the severity of a report
+func reportSeverity(report interface{}) int {
+	switch r := report.(type) {
+	case CNIReport:
+		if !r.CniSucceeded && r.ErrorMessage != "" {
+			return severityMedium
+		}
+	case IncidentReport, CrashReport:
+		return severityHigh
+	}
+
+	return severityLow
+}
+
+// track - account for a report pushed to the end of a list of the payload
+func (pl *Payload) track(list string, registered bool, report interface{}) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		telemetryLogger.Printf("[Telemetry] Failed to encode report for size accounting: %v", err)
+	}
+
+	entry := payloadEntry{list: list, registered: registered, severity: reportSeverity(report), size: len(b)}
+	pl.entries = append(pl.entries, entry)
+	pl.bytes += entry.size
+}
+
+// retrack - account for the reports of a payload decoded from the delivery state, which doesn't keep the order
+// in which they were pushed
+func (pl *Payload) retrack() {
+	pl.entries = nil
+	pl.bytes = 0
+
+	for _, r := range pl.DNCReports {
+		pl.track(listDNCReports, false, r)
+	}
+	for _, r := range pl.CNIReports {
+		pl.track(listCNIReports, false, r)
+	}
+	for _, r := range pl.NPMReports {
+		pl.track(listNPMReports, false, r)
+	}
+	for _, r := range pl.CNSReports {
+		pl.track(listCNSReports, false, r)
+	}
+	for _, r := range pl.IncidentReports {
+		pl.track(listIncidentReports, false, r)
+	}
+	for _, r := range pl.CrashReports {
+		pl.track(listCrashReports, false, r)
+	}
+	for name, reports := range pl.RegisteredReports {
+		for _, r := range reports {
+			pl.track(name, true, r)
+		}
+	}
+}
+
+// evict - evict the oldest reports of the lowest severity until the payload fits in maxBytes,
+// returning the number of evicted reports
+func (pl *Payload) evict(maxBytes int) int {
+	evicted := 0
+	for pl.bytes > maxBytes && len(pl.entries) > 0 {
+		victim := 0
+		for i, entry := range pl.entries {
+			if entry.severity < pl.entries[victim].severity {
+				victim = i
+			}
+		}
+
+		pl.remove(victim)
+		evicted++
+	}
+
+	return evicted
+}
+
+// remove - remove the report of an entry from its list
+func (pl *Payload) remove(index int) {
+	entry := pl.entries[index]
+
+	// The report is at the position of its entry among the entries of its list.
+	i := 0
+	for _, e := range pl.entries[:index] {
+		if e.list == entry.list && e.registered == entry.registered {
+			i++
+		}
+	}
+
+	if entry.registered {
+		reports := append(pl.RegisteredReports[entry.list][:i], pl.RegisteredReports[entry.list][i+1:]...)
+		if len(reports) == 0 {
+			delete(pl.RegisteredReports, entry.list)
+		} else {
+			pl.RegisteredReports[entry.list] = reports
+		}
+	} else {
+		switch entry.list {
+		case listDNCReports:
+			pl.DNCReports = append(pl.DNCReports[:i], pl.DNCReports[i+1:]...)
+		case listCNIReports:
+			pl.CNIReports = append(pl.CNIReports[:i], pl.CNIReports[i+1:]...)
+		case listNPMReports:
+			pl.NPMReports = append(pl.NPMReports[:i], pl.NPMReports[i+1:]...)
+		case listCNSReports:
+			pl.CNSReports = append(pl.CNSReports[:i], pl.CNSReports[i+1:]...)
+		case listIncidentReports:
+			pl.IncidentReports = append(pl.IncidentReports[:i], pl.IncidentReports[i+1:]...)
+		case listCrashReports:
+			pl.CrashReports = append(pl.CrashReports[:i], pl.CrashReports[i+1:]...)
+		}
+	}
+
+	pl.entries = append(pl.entries[:index], pl.entries[index+1:]...)
+	pl.bytes -= entry.size
+}
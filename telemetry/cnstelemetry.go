@@ -4,6 +4,7 @@
 package telemetry
 
 import (
+	"fmt"
 	"reflect"
 	"regexp"
 	"time"
@@ -26,20 +27,26 @@ const (
 func SendCnsTelemetry(interval int, reports chan interface{}, service *restserver.HTTPRestService, telemetryStopProcessing chan bool) {
 
 CONNECT:
+	if IsDisabled() {
+		discardCnsTelemetry(reports, telemetryStopProcessing)
+		return
+	}
+
 	telemetryBuffer := NewTelemetryBuffer("")
 	err := telemetryBuffer.StartServer()
 	if err == nil || telemetryBuffer.FdExists {
 		if err := telemetryBuffer.Connect(); err != nil {
 			log.Printf("[CNS-Telemetry] Failed to establish telemetry manager connection.")
-			time.Sleep(time.Second * retryWaitTimeInSeconds)
+			clock.Sleep(time.Second * retryWaitTimeInSeconds)
 			goto CONNECT
 		}
 
 		go telemetryBuffer.BufferAndPushData(time.Duration(0))
 
-		heartbeat := time.NewTicker(time.Minute * heartbeatIntervalInMinutes).C
+		heartbeat := clock.NewTicker(time.Minute * heartbeatIntervalInMinutes).C()
 		reportMgr := ReportManager{
 			ContentType: ContentType,
+			SourceID:    "azure-cns",
 			Report:      &CNSReport{},
 		}
 
@@ -54,8 +61,14 @@ CONNECT:
 
 			select {
 			case <-heartbeat:
-				reflect.ValueOf(reportMgr.Report).Elem().FieldByName("EventMessage").SetString("Heartbeat")
+				reflect.ValueOf(reportMgr.Report).Elem().FieldByName("EventMessage").SetString(heartbeatMessage(service))
 			case msg := <-reports:
+				if IsDisabled() {
+					telemetryBuffer.Cancel()
+					discardCnsTelemetry(reports, telemetryStopProcessing)
+					return
+				}
+
 				codeStr := regexp.MustCompile(`Code:(\w*)`).FindString(msg.(string))
 				if len(codeStr) > errorcodePrefix {
 					reflect.ValueOf(reportMgr.Report).Elem().FieldByName("Errorcode").SetString(codeStr[errorcodePrefix:])
@@ -67,7 +80,7 @@ CONNECT:
 				return
 			}
 
-			reflect.ValueOf(reportMgr.Report).Elem().FieldByName("Timestamp").SetString(time.Now().UTC().String())
+			reflect.ValueOf(reportMgr.Report).Elem().FieldByName("Timestamp").SetString(clock.Now().UTC().String())
 			if id, err := uuid.NewUUID(); err == nil {
 				reflect.ValueOf(reportMgr.Report).Elem().FieldByName("UUID").SetString(id.String())
 			}
@@ -87,7 +100,28 @@ CONNECT:
 		}
 	} else {
 		log.Printf("[CNS-Telemetry] Failed to start telemetry manager server.")
-		time.Sleep(time.Second * retryWaitTimeInSeconds)
+		clock.Sleep(time.Second * retryWaitTimeInSeconds)
 		goto CONNECT
 	}
 }
+
+// discardCnsTelemetry - drop the reports of CNS while telemetry is disabled, so that the loggers sending them
+// don't block, until CNS stops
+func discardCnsTelemetry(reports chan interface{}, telemetryStopProcessing chan bool) {
+	log.Printf("[CNS-Telemetry] Telemetry is disabled on this machine.")
+
+	for {
+		select {
+		case <-reports:
+		case <-telemetryStopProcessing:
+			return
+		}
+	}
+}
+
+// Summarizes the health of the node reported with each heartbeat.
+func heartbeatMessage(service *restserver.HTTPRestService) string {
+	hb := service.GetNodeHeartbeat()
+	return fmt.Sprintf("Heartbeat Healthy:%v NetworkContainers:%d IPPool:%d/%d UnhealthyIPs:%d",
+		hb.Healthy, len(hb.NetworkContainers), hb.IPPoolCapacity-hb.IPPoolAvailable, hb.IPPoolCapacity, hb.UnhealthyIPCount)
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	// Frames of the panicking goroutine fingerprinted, innermost first.
+	maxFingerprintFrames = 16
+
+	// Frames of the panicking goroutine included in the stack of the report.
+	maxStackFrames = 64
+
+	// Hex digits of the fingerprint.
+	fingerprintLength = 16
+)
+
+// CrashReport - panic of a component. Its fingerprint hashes the functions of the stack of the panic without
+// file paths, line numbers or arguments, so that the backend groups crashes by code location across versions.
+type CrashReport struct {
+	Component    string
+	Version      string
+	PanicMessage string
+	Fingerprint  string
+	Location     string
+	Stack        string
+	Timestamp    string
+	Metadata     Metadata `json:"compute"`
+}
+
+// CapturePanic - deferred by the entry points of components, reports a panic as a crash report and panics again,
+// so that the component crashes as it would have
+func CapturePanic(component string, version string) {
+	if r := recover(); r != nil {
+		ReportPanic(component, version, r)
+		panic(r)
+	}
+}
+
+// ReportPanic - send a crash report for a recovered panic to the telemetry service, or queue it until the service
+// runs. It must be called by the deferred function that recovered the panic, while the stack of the panic is intact.
+func ReportPanic(component string, version string, recovered interface{}) {
+	report := newCrashReport(component, version, recovered, panicStack())
+	log.Printf("[Telemetry] %v panicked at %v: %v, fingerprint %v\n%v",
+		component, report.Location, report.PanicMessage, report.Fingerprint, report.Stack)
+
+	reportMgr := &ReportManager{
+		ContentType: ContentType,
+		QueueDir:    ReportQueueDir,
+		Report:      report,
+	}
+
+	tb := NewTelemetryBuffer("")
+	if err := tb.Connect(); err == nil {
+		tb.Connected = true
+		defer tb.client.Close()
+	}
+
+	if err := reportMgr.SendReport(tb); err != nil {
+		log.Printf("[Telemetry] Sending crash report failed with err %v", err)
+	}
+}
+
+// panicStack - get the frames of the goroutine from the function that panicked outwards, skipping the deferred
+// functions handling the panic and the runtime functions raising it
+func panicStack() []runtime.Frame {
+	pcs := make([]uintptr, maxStackFrames+16)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []runtime.Frame
+	panicking := false
+	for {
+		frame, more := frames.Next()
+
+		if !panicking {
+			panicking = frame.Function == "runtime.gopanic"
+		} else if len(stack) > 0 || !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, frame)
+		}
+
+		if !more || len(stack) == maxStackFrames {
+			break
+		}
+	}
+
+	return stack
+}
+
+// newCrashReport - create the crash report of a panic with the given stack
+func newCrashReport(component string, version string, recovered interface{}, stack []runtime.Frame) *CrashReport {
+	report := &CrashReport{
+		Component:    component,
+		Version:      version,
+		PanicMessage: fmt.Sprint(recovered),
+		Fingerprint:  fingerprintStack(stack),
+		Timestamp:    clock.Now().UTC().Format(time.RFC3339),
+	}
+
+	if len(stack) > 0 {
+		report.Location = stack[0].Function
+	}
+
+	var b strings.Builder
+	for _, frame := range stack {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	report.Stack = b.String()
+
+	return report
+}
+
+// fingerprintStack - hash the functions of the innermost frames of a stack
+func fingerprintStack(stack []runtime.Frame) string {
+	h := sha256.New()
+	for i, frame := range stack {
+		if i == maxFingerprintFrames {
+			break
+		}
+		h.Write([]byte(frame.Function + "\n"))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:fingerprintLength]
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Header of the files encrypted with the node-local key.
+	encryptedFileHeader = "ACN-ENCRYPTED-1\n"
+)
+
+// File holding the node-local key encrypting files on Linux. Windows protects them with DPAPI instead.
+var encryptionKeyFile = platform.CNMRuntimePath + "AzureTelemetry.key"
+
+// writeEncryptedFile encrypts data with the node-local key and writes it to a file readable by its owner only,
+// since reports can contain subscription IDs, VM names and orchestrator details.
+// The file is replaced at once, so that a crash never leaves a truncated file behind.
+func writeEncryptedFile(filename string, data []byte) error {
+	sealed, err := protectData(data)
+	if err != nil {
+		return fmt.Errorf("[Telemetry] Encrypting %s failed with err %v", filename, err)
+	}
+
+	tmpFile := filename + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, append([]byte(encryptedFileHeader), sealed...), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, filename)
+}
+
+// readEncryptedFile reads and decrypts a file written by writeEncryptedFile.
+// Files written in plain text by earlier versions are returned as is.
+func readEncryptedFile(filename string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(content, []byte(encryptedFileHeader)) {
+		return content, nil
+	}
+
+	data, err := unprotectData(content[len(encryptedFileHeader):])
+	if err != nil {
+		return nil, fmt.Errorf("[Telemetry] Decrypting %s failed with err %v", filename, err)
+	}
+
+	return data, nil
+}
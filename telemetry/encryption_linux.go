@@ -0,0 +1,113 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// Size of the AES-256 key.
+	encryptionKeySize = 32
+)
+
+// protectData encrypts data with AES-GCM under the node-local key, which is created on first use.
+func protectData(data []byte) ([]byte, error) {
+	gcm, err := newCipher(true)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// unprotectData decrypts data encrypted by protectData.
+func unprotectData(data []byte) ([]byte, error) {
+	gcm, err := newCipher(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("Encrypted data is truncated")
+	}
+
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+// newCipher returns the AES-GCM cipher of the node-local key, creating the key if asked to.
+func newCipher(create bool) (cipher.AEAD, error) {
+	key, err := loadEncryptionKey(create)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// loadEncryptionKey reads the node-local key, creating it if it does not exist and create is set.
+func loadEncryptionKey(create bool) ([]byte, error) {
+	key, err := ioutil.ReadFile(encryptionKeyFile)
+	if err == nil {
+		if len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("Invalid key in %s", encryptionKeyFile)
+		}
+
+		return key, nil
+	}
+
+	if !os.IsNotExist(err) || !create {
+		return nil, err
+	}
+
+	key = make([]byte, encryptionKeySize)
+	if _, err = io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(encryptionKeyFile), 0700); err != nil {
+		return nil, err
+	}
+
+	// Another process may have created the key concurrently, the first one wins.
+	f, err := os.OpenFile(encryptionKeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return loadEncryptionKey(false)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Write(key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(encryptionKeyFile)
+		return nil, err
+	}
+
+	return key, nil
+}
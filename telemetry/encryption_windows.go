@@ -0,0 +1,80 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// Protects data for any process of the machine, as the CNI plugin and the telemetry service may run as different users.
+	cryptProtectLocalMachine = 0x4
+	// Fails instead of prompting the user.
+	cryptProtectUIForbidden = 0x1
+)
+
+var (
+	crypt32                = windows.NewLazySystemDLL("crypt32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+)
+
+// dataBlob is the DATA_BLOB structure of DPAPI.
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+// Returns the blob pointing to data.
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+
+	return &dataBlob{size: uint32(len(data)), data: &data[0]}
+}
+
+// Copies the blob allocated by DPAPI and frees it.
+func (blob *dataBlob) bytes() []byte {
+	if blob.data == nil {
+		return []byte{}
+	}
+
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.data)))
+
+	data := make([]byte, blob.size)
+	copy(data, (*[1 << 30]byte)(unsafe.Pointer(blob.data))[:blob.size:blob.size])
+
+	return data
+}
+
+// protectData encrypts data with DPAPI under the machine key.
+func protectData(data []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptProtectData.Call(
+		uintptr(unsafe.Pointer(newDataBlob(data))), 0, 0, 0, 0,
+		cryptProtectLocalMachine|cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, err
+	}
+
+	return out.bytes(), nil
+}
+
+// unprotectData decrypts data encrypted by protectData.
+func unprotectData(data []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptUnprotectData.Call(
+		uintptr(unsafe.Pointer(newDataBlob(data))), 0, 0, 0, 0,
+		cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, err
+	}
+
+	return out.bytes(), nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Size at which a file sink is rotated, and number of files kept, the active one included.
+const (
+	fileSinkMaxSize  = 5 * 1024 * 1024
+	fileSinkMaxCount = 4
+)
+
+// Time a failed backend is skipped for, doubled at each consecutive failure up to the max.
+const (
+	backendRetryInitialDelay = time.Minute
+	backendRetryMaxDelay     = 30 * time.Minute
+)
+
+// reportBackend is a destination of the payloads. A backend that fails is skipped for a while, so that payloads
+// go to the next backend of the list, and is tried again once the delay elapsed, so that they go back to it.
+type reportBackend struct {
+	url       string
+	failures  int
+	downUntil time.Time
+}
+
+// isDown returns whether the backend is skipped.
+func (b *reportBackend) isDown(now time.Time) bool {
+	return now.Before(b.downUntil)
+}
+
+// succeeded marks the backend as healthy.
+func (b *reportBackend) succeeded() {
+	b.failures = 0
+	b.downUntil = time.Time{}
+}
+
+// failed skips the backend for a delay growing with its consecutive failures.
+func (b *reportBackend) failed(now time.Time) {
+	delay := backendRetryInitialDelay
+	for i := 0; i < b.failures && delay < backendRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > backendRetryMaxDelay {
+		delay = backendRetryMaxDelay
+	}
+
+	b.failures++
+	b.downUntil = now.Add(delay)
+}
+
+// EnableFailover - send the payloads to the given URLs, in order, while the host report URL fails.
+// A URL is either an HTTP endpoint receiving payloads like the host, e.g. a proxy, or a file:// URL of a file
+// the payloads are appended to, one per line.
+func (tb *TelemetryBuffer) EnableFailover(urls []string) error {
+	backends, err := newBackends(tb.azureHostReportURL, urls)
+	if err != nil {
+		return err
+	}
+
+	tb.backends = backends
+	return nil
+}
+
+// newBackends - create the backends of the host report URL followed by the given failover URLs
+func newBackends(hostReportURL string, urls []string) ([]*reportBackend, error) {
+	backends := []*reportBackend{{url: hostReportURL}}
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+
+		switch u.Scheme {
+		case "http", "https":
+		case "file":
+			if u.Path == "" {
+				return nil, fmt.Errorf("[Telemetry] File sink URL %v has no path", rawURL)
+			}
+		default:
+			return nil, fmt.Errorf("[Telemetry] Unsupported report URL %v", rawURL)
+		}
+
+		backends = append(backends, &reportBackend{url: rawURL})
+	}
+
+	return backends, nil
+}
+
+// getBackends - get the backends of the buffer, the host report URL alone unless failover is enabled
+func (tb *TelemetryBuffer) getBackends() []*reportBackend {
+	if tb.backends == nil {
+		tb.backends = []*reportBackend{{url: tb.azureHostReportURL}}
+	}
+
+	return tb.backends
+}
+
+// sendToBackends - send the encoded payload to the first backend accepting it, skipping those that failed
+// recently. When all of them failed recently, all of them are tried again.
+func (tb *TelemetryBuffer) sendToBackends(body []byte, contentHash string) error {
+	now := clock.Now()
+	backends := tb.getBackends()
+
+	var available []*reportBackend
+	for _, backend := range backends {
+		if !backend.isDown(now) {
+			available = append(available, backend)
+		}
+	}
+
+	if len(available) == 0 {
+		available = backends
+	}
+
+	var err error
+	for _, backend := range available {
+		if err = tb.sendToBackend(backend.url, body, contentHash); err == nil {
+			if backend != backends[0] {
+				telemetryLogger.Printf("[Telemetry] Payload sent to failover backend %v", backend.url)
+			}
+			backend.succeeded()
+			return nil
+		}
+
+		backend.failed(now)
+		telemetryLogger.Printf("[Telemetry] Sending payload to %v failed, skipping it until %v: %v",
+			backend.url, backend.downUntil.Format(time.RFC3339), err)
+	}
+
+	return err
+}
+
+// sendToBackend - send the encoded payload to an HTTP endpoint, or append it to a file sink
+func (tb *TelemetryBuffer) sendToBackend(rawURL string, body []byte, contentHash string) error {
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme == "file" {
+		return appendToFileSink(u.Path, body)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("[Telemetry] Creating HTTP request failed with error %v", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+
+	resp, err := tb.getSender().Do(req)
+	if err != nil {
+		return fmt.Errorf("[Telemetry] HTTP Post returned error %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("[Telemetry] HTTP Post returned statuscode %d", resp.StatusCode)
+	}
+
+	if tb.ackRequired {
+		var ack HostAck
+		if err = json.NewDecoder(resp.Body).Decode(&ack); err != nil {
+			return fmt.Errorf("[Telemetry] Unable to decode host acknowledgement due to error: %v", err)
+		}
+
+		if ack.SequenceNumber != tb.payload.SequenceNumber && ack.ContentHash != contentHash {
+			return fmt.Errorf("[Telemetry] Host acknowledgement %+v doesn't match payload %d", ack, tb.payload.SequenceNumber)
+		}
+	}
+
+	return nil
+}
+
+// appendToFileSink - append the encoded payload, ending with a newline, to a file
+func appendToFileSink(path string, body []byte) error {
+	if err := rotateFileSink(path, int64(len(body)), fileSinkMaxSize, fileSinkMaxCount); err != nil {
+		return fmt.Errorf("[Telemetry] Rotating file sink failed with error %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("[Telemetry] Opening file sink failed with error %v", err)
+	}
+
+	if _, err = file.Write(body); err != nil {
+		file.Close()
+		return fmt.Errorf("[Telemetry] Writing to file sink failed with error %v", err)
+	}
+
+	return file.Close()
+}
+
+// rotateFileSink - rotate a file sink that would grow past maxSize with the next payload, keeping the last
+// maxCount files like the log files, as path.1 for the most recent rotated file up to path.<maxCount-1>
+func rotateFileSink(path string, size int64, maxSize int64, maxCount int) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// A payload larger than the limit still goes to an empty file.
+	if info.Size() == 0 || info.Size()+size <= maxSize {
+		return nil
+	}
+
+	if maxCount <= 1 {
+		return os.Remove(path)
+	}
+
+	for n := maxCount - 1; n > 0; n-- {
+		from := path
+		if n > 1 {
+			from = fmt.Sprintf("%v.%v", path, n-1)
+		}
+
+		if err = os.Rename(from, fmt.Sprintf("%v.%v", path, n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
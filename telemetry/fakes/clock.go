@@ -0,0 +1,20 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package fakes
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// UseClock replaces the clock of the telemetry package with a fake clock set to the given time, and returns it
+// with a function restoring the previous clock. Report intervals only elapse when the fake clock is advanced.
+func UseClock(now time.Time) (*platform.FakeClock, func()) {
+	fakeClock := platform.NewFakeClock(now)
+	previous := telemetry.SetClock(fakeClock)
+
+	return fakeClock, func() { telemetry.SetClock(previous) }
+}
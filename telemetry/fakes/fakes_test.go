@@ -0,0 +1,163 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package fakes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// Waits for a condition, failing the test if it doesn't hold in time.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %v", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that a report sent by a client reaches the host through the fakes, without sockets, network or sleeping
+// for report intervals.
+func TestReportDelivery(t *testing.T) {
+	fakeClock, restore := UseClock(time.Unix(0, 0))
+	defer restore()
+
+	transport := NewTransport()
+	sender := NewHTTPSender()
+
+	server := telemetry.NewTelemetryBuffer("http://host/report")
+	server.SetTransport(transport)
+	server.SetHTTPSender(sender)
+	if err := server.StartServer(); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+
+	// Stop the server before restoring the clock it reads.
+	done := make(chan struct{})
+	go func() {
+		server.BufferAndPushData(telemetry.DefaultInterval)
+		close(done)
+	}()
+	defer func() {
+		server.Cancel()
+		<-done
+	}()
+
+	client := telemetry.NewTelemetryBuffer("")
+	client.SetTransport(transport)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	reportMgr := &telemetry.ReportManager{
+		ContentType: telemetry.ContentType,
+		Report:      &telemetry.CNIReport{ContainerName: "fake-container", CniSucceeded: true},
+	}
+	if err := reportMgr.SendReport(client); err != nil {
+		t.Fatalf("SendReport failed: %v", err)
+	}
+
+	waitFor(t, "report interval", func() bool { return fakeClock.Waiters() > 0 })
+
+	delivered := func() bool {
+		for i := range sender.Requests() {
+			payload, err := sender.Payload(i)
+			if err == nil && len(payload.CNIReports) > 0 {
+				return payload.CNIReports[0].ContainerName == "fake-container"
+			}
+		}
+		return false
+	}
+
+	// The report may still be on its way to the buffer when the interval elapses, advance until it is sent.
+	waitFor(t, "report delivery", func() bool {
+		fakeClock.Advance(telemetry.DefaultInterval)
+		return delivered()
+	})
+
+	req := sender.Requests()[0]
+	if req.Method != "POST" || req.URL != "http://host/report" || req.ContentType != telemetry.ContentType {
+		t.Errorf("Unexpected request %v %v %v", req.Method, req.URL, req.ContentType)
+	}
+}
+
+// Tests that a running buffer applies new settings, and keeps its settings when new ones are invalid.
+func TestReconfigure(t *testing.T) {
+	fakeClock, restore := UseClock(time.Unix(0, 0))
+	defer restore()
+
+	sender := NewHTTPSender()
+	server := telemetry.NewTelemetryBuffer("http://host/report")
+	server.SetHTTPSender(sender)
+
+	done := make(chan struct{})
+	go func() {
+		server.BufferAndPushData(telemetry.DefaultInterval)
+		close(done)
+	}()
+
+	invalid := telemetry.BufferConfig{HostReportURL: "http://invalid/report", FailoverURLs: []string{"ftp://proxy"}}
+	if err := server.Reconfigure(invalid); err == nil {
+		t.Errorf("Reconfigure accepted an unsupported failover URL")
+	}
+
+	config := telemetry.BufferConfig{HostReportURL: "http://other/report", ReportInterval: 2 * telemetry.DefaultInterval}
+	if err := server.Reconfigure(config); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+
+	// Payloads go to the new host report URL only, the invalid settings were not applied.
+	waitFor(t, "payload", func() bool {
+		fakeClock.Advance(config.ReportInterval)
+		return len(sender.Requests()) > 0
+	})
+
+	for _, req := range sender.Requests() {
+		if req.URL != config.HostReportURL {
+			t.Errorf("Payload sent to %v, expected %v", req.URL, config.HostReportURL)
+		}
+	}
+
+	server.Cancel()
+	<-done
+
+	if err := server.Reconfigure(config); err == nil {
+		t.Errorf("Reconfigure of a stopped buffer succeeded")
+	}
+}
+
+// Tests that the fake transport rejects dials without listener and names already listened on.
+func TestTransport(t *testing.T) {
+	transport := NewTransport()
+
+	if _, err := transport.Dial("name"); err == nil {
+		t.Errorf("Dial succeeded without listener")
+	}
+
+	listener, err := transport.Listen("name")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	if _, err = transport.Listen("name"); err == nil {
+		t.Errorf("Listen succeeded on a name already listened on")
+	}
+
+	transport.Remove("name")
+	if _, err = listener.Accept(); err == nil {
+		t.Errorf("Accept succeeded on a removed listener")
+	}
+
+	if _, err = transport.Listen("name"); err != nil {
+		t.Errorf("Listen failed after remove: %v", err)
+	}
+
+	if transport.Dials() != 1 {
+		t.Errorf("Unexpected number of dials %v", transport.Dials())
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package fakes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// Request is a request received by an HTTPSender.
+type Request struct {
+	Method      string
+	URL         string
+	ContentType string
+	Body        []byte
+}
+
+// response is a programmed response of an HTTPSender.
+type response struct {
+	statusCode int
+	body       []byte
+	err        error
+}
+
+// HTTPSender is an in-memory telemetry.HTTPSender recording the requests sent to it. It answers with the programmed
+// responses in order, then with 200 OK and an empty body.
+type HTTPSender struct {
+	sync.Mutex
+	requests  []Request
+	responses []response
+}
+
+// NewHTTPSender creates an HTTPSender answering with 200 OK.
+func NewHTTPSender() *HTTPSender {
+	return &HTTPSender{}
+}
+
+// Do records the request and answers it.
+func (sender *HTTPSender) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	sender.Lock()
+	defer sender.Unlock()
+
+	sender.requests = append(sender.requests, Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		ContentType: req.Header.Get("Content-Type"),
+		Body:        body,
+	})
+
+	resp := response{statusCode: http.StatusOK}
+	if len(sender.responses) > 0 {
+		resp = sender.responses[0]
+		sender.responses = sender.responses[1:]
+	}
+
+	if resp.err != nil {
+		return nil, resp.err
+	}
+
+	return &http.Response{
+		StatusCode: resp.statusCode,
+		Status:     http.StatusText(resp.statusCode),
+		Body:       ioutil.NopCloser(bytes.NewReader(resp.body)),
+		Request:    req,
+	}, nil
+}
+
+// Respond answers the next request with the given status code and body.
+func (sender *HTTPSender) Respond(statusCode int, body []byte) {
+	sender.Lock()
+	defer sender.Unlock()
+
+	sender.responses = append(sender.responses, response{statusCode: statusCode, body: body})
+}
+
+// RespondJSON answers the next request with 200 OK and the JSON encoding of v, e.g. a telemetry.HostAck.
+func (sender *HTTPSender) RespondJSON(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sender.Respond(http.StatusOK, body)
+	return nil
+}
+
+// Fail fails the next request with the given error, as if the host could not be reached.
+func (sender *HTTPSender) Fail(err error) {
+	sender.Lock()
+	defer sender.Unlock()
+
+	sender.responses = append(sender.responses, response{err: err})
+}
+
+// Requests returns the requests received so far.
+func (sender *HTTPSender) Requests() []Request {
+	sender.Lock()
+	defer sender.Unlock()
+
+	return append([]Request(nil), sender.requests...)
+}
+
+// Payload decodes the payload of the request at the given index.
+func (sender *HTTPSender) Payload(index int) (telemetry.Payload, error) {
+	var payload telemetry.Payload
+
+	requests := sender.Requests()
+	if index >= len(requests) {
+		return payload, fmt.Errorf("Request %d not received, %d received", index, len(requests))
+	}
+
+	err := json.Unmarshal(requests[index].Body, &payload)
+	return payload, err
+}
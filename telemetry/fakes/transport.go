@@ -0,0 +1,140 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+// Package fakes provides in-memory fakes of the transport, HTTP sender and clock of the telemetry package, so that
+// the components sending reports can test their reporting paths deterministically.
+package fakes
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+var (
+	errNotListening = fmt.Errorf("No fake listener on the name")
+	errListening    = fmt.Errorf("Name is already listened on")
+	errClosed       = fmt.Errorf("Fake listener closed")
+)
+
+// Transport is an in-memory telemetry.Transport connecting clients to listeners by name through net.Pipe.
+type Transport struct {
+	sync.Mutex
+	listeners map[string]*listener
+	dials     int
+}
+
+// NewTransport creates a Transport without listeners.
+func NewTransport() *Transport {
+	return &Transport{listeners: make(map[string]*listener)}
+}
+
+// Dial connects to the listener of the given name.
+func (transport *Transport) Dial(name string) (net.Conn, error) {
+	transport.Lock()
+	l, ok := transport.listeners[name]
+	transport.dials++
+	transport.Unlock()
+
+	if !ok {
+		return nil, errNotListening
+	}
+
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		client.Close()
+		server.Close()
+		return nil, errNotListening
+	}
+}
+
+// Listen listens on the given name, failing if it is already listened on.
+func (transport *Transport) Listen(name string) (net.Listener, error) {
+	transport.Lock()
+	defer transport.Unlock()
+
+	if _, ok := transport.listeners[name]; ok {
+		return nil, errListening
+	}
+
+	l := &listener{
+		transport: transport,
+		name:      name,
+		conns:     make(chan net.Conn),
+		closed:    make(chan struct{}),
+	}
+	transport.listeners[name] = l
+
+	return l, nil
+}
+
+// Remove removes the listener of the given name, like the socket left by a dead instance.
+func (transport *Transport) Remove(name string) error {
+	transport.Lock()
+	l, ok := transport.listeners[name]
+	transport.Unlock()
+
+	if ok {
+		l.Close()
+	}
+
+	return nil
+}
+
+// Dials returns the number of connection attempts.
+func (transport *Transport) Dials() int {
+	transport.Lock()
+	defer transport.Unlock()
+
+	return transport.dials
+}
+
+// listener is a net.Listener of a Transport.
+type listener struct {
+	transport *Transport
+	name      string
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+
+		l.transport.Lock()
+		if l.transport.listeners[l.name] == l {
+			delete(l.transport.listeners, l.name)
+		}
+		l.transport.Unlock()
+	})
+
+	return nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return fakeAddr(l.name)
+}
+
+// fakeAddr is the address of a listener, its name.
+type fakeAddr string
+
+func (addr fakeAddr) Network() string {
+	return "fake"
+}
+
+func (addr fakeAddr) String() string {
+	return string(addr)
+}
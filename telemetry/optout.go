@@ -0,0 +1,51 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// EnvTelemetryDisabled - environment variable disabling telemetry when set to true
+const EnvTelemetryDisabled = "ACN_TELEMETRY_DISABLED"
+
+// ErrTelemetryDisabled - returned instead of connecting when telemetry is disabled on the machine
+var ErrTelemetryDisabled = fmt.Errorf("[Telemetry] Telemetry is disabled on this machine")
+
+// optOutFile - file checked by IsDisabled, replaced by tests
+var optOutFile = OptOutFile
+
+// OptOutConfig - content of the machine-wide telemetry configuration file
+type OptOutConfig struct {
+	DisableTelemetry bool `json:"disableTelemetry"`
+}
+
+// IsDisabled - check whether telemetry is disabled on the machine, by the environment variable or the
+// configuration file. Every component checks it before collecting or sending data, and drops the data silently
+// when it is disabled, so that disabling it in one place covers the whole machine.
+// Either one disables telemetry, the environment can't enable it again. A value that can't be read or parsed
+// disables telemetry, as the intent is clearly to configure it.
+func IsDisabled() bool {
+	if value := os.Getenv(EnvTelemetryDisabled); value != "" {
+		if disabled, err := strconv.ParseBool(value); err != nil || disabled {
+			return true
+		}
+	}
+
+	b, err := ioutil.ReadFile(optOutFile)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+
+	var config OptOutConfig
+	if err = json.Unmarshal(b, &config); err != nil {
+		return true
+	}
+
+	return config.DisableTelemetry
+}
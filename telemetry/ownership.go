@@ -0,0 +1,176 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Control messages sent to the instance owning the socket instead of a report.
+const (
+	controlProbe    = "probe"
+	controlTakeover = "takeover"
+)
+
+const (
+	// How long the owner of the socket has to answer a control message.
+	ownerProbeTimeout = 5 * time.Second
+
+	// How many times, and how often, a new instance tries to listen while the previous owner releases the socket.
+	takeoverRetries       = 25
+	takeoverRetryInterval = 200 * time.Millisecond
+)
+
+var (
+	errOwnerNotFound      = fmt.Errorf("[Telemetry] No instance is listening on the socket")
+	errOwnerNotResponding = fmt.Errorf("[Telemetry] The instance listening on the socket is not responding")
+)
+
+// OwnerInfo - identity of the instance owning the socket, returned by its main loop as proof that it is alive
+type OwnerInfo struct {
+	Pid       int
+	StartTime time.Time
+}
+
+// controlMessage - message of a starting instance to the instance owning the socket
+type controlMessage struct {
+	TelemetryControl string
+}
+
+// controlRequest - control message handed by a connection to the main loop, which answers it on the connection
+type controlRequest struct {
+	control string
+	conn    net.Conn
+}
+
+// EnableForceTakeover - take the socket over from an instance owning it even if it is alive
+func (tb *TelemetryBuffer) EnableForceTakeover() {
+	tb.forceTakeover = true
+}
+
+// ProbeOwner - ask the instance owning the socket with 'name' who it is
+func (tb *TelemetryBuffer) ProbeOwner(name string) (OwnerInfo, error) {
+	return tb.sendControl(name, controlProbe)
+}
+
+// sendControl - send a control message to the instance owning the socket with 'name' and wait for its answer
+func (tb *TelemetryBuffer) sendControl(name string, control string) (OwnerInfo, error) {
+	var info OwnerInfo
+
+	conn := &TelemetryBuffer{transport: tb.transport}
+	if err := conn.Dial(name); err != nil {
+		return info, errOwnerNotFound
+	}
+	defer conn.client.Close()
+
+	// Deadlines of connections are in wall clock time.
+	conn.client.SetDeadline(time.Now().Add(ownerProbeTimeout))
+
+	b, err := json.Marshal(controlMessage{TelemetryControl: control})
+	if err != nil {
+		return info, err
+	}
+
+	if _, err = conn.Write(b); err != nil {
+		return info, errOwnerNotResponding
+	}
+
+	reply, err := read(conn.client)
+	if err != nil {
+		return info, errOwnerNotResponding
+	}
+
+	if err = json.Unmarshal(reply, &info); err != nil || info.Pid == 0 {
+		return info, errOwnerNotResponding
+	}
+
+	return info, nil
+}
+
+// takeOver - decide what to do when the socket with 'name' can't be listened on.
+// A live owner keeps the socket unless a takeover is forced, in which case it is asked to release it.
+// A socket nobody listens on is left behind by an instance that died and is removed.
+// An owner that accepts connections without answering is either hung or predates the control messages,
+// and is assumed to be alive unless a takeover is forced.
+func (tb *TelemetryBuffer) takeOver(name string, listenErr error) error {
+	info, err := tb.ProbeOwner(name)
+
+	switch {
+	case err == nil && !tb.forceTakeover:
+		telemetryLogger.Printf("[Telemetry] Socket owned by instance %d started at %v", info.Pid, info.StartTime)
+		tb.FdExists = true
+		return listenErr
+
+	case err == nil:
+		telemetryLogger.Printf("[Telemetry] Taking the socket over from instance %d started at %v", info.Pid, info.StartTime)
+		if _, err = tb.sendControl(name, controlTakeover); err != nil {
+			telemetryLogger.Printf("[Telemetry] Instance %d did not release the socket: %v", info.Pid, err)
+		}
+
+	case err == errOwnerNotResponding && !tb.forceTakeover:
+		telemetryLogger.Printf("[Telemetry] Socket owner is not responding, assuming it is alive")
+		tb.FdExists = true
+		return listenErr
+
+	default:
+		telemetryLogger.Printf("[Telemetry] Removing socket of dead or unresponsive owner: %v", err)
+		tb.Cleanup(name)
+	}
+
+	for i := 0; i < takeoverRetries; i++ {
+		if err = tb.Listen(name); err == nil {
+			telemetryLogger.Printf("[Telemetry] Took the socket over")
+			return nil
+		}
+
+		clock.Sleep(takeoverRetryInterval)
+	}
+
+	return err
+}
+
+// decodeControl - get the control message of a line received from a client, if it is one
+func decodeControl(b []byte) (string, bool) {
+	var msg controlMessage
+	if err := json.Unmarshal(b, &msg); err != nil || msg.TelemetryControl == "" {
+		return "", false
+	}
+
+	return msg.TelemetryControl, true
+}
+
+// handleControl - answer a control message in the main loop, returning true if the socket was released
+func (tb *TelemetryBuffer) handleControl(req controlRequest) bool {
+	b, err := json.Marshal(OwnerInfo{Pid: os.Getpid(), StartTime: tb.startTime})
+	if err == nil {
+		_, err = req.conn.Write(append(b, Delimiter))
+	}
+
+	if err != nil {
+		telemetryLogger.Printf("[Telemetry] Answering control message failed with err %v", err)
+	}
+
+	if req.control != controlTakeover {
+		return false
+	}
+
+	telemetryLogger.Printf("[Telemetry] Releasing the socket to a new instance")
+	close(tb.stopAccept)
+	tb.listener.Close()
+
+	// Hand the buffered reports to the host before leaving, the new instance starts empty.
+	tb.pushSummary(clock.Now())
+	if err := tb.sendToHost(); err == nil {
+		tb.payload.reset()
+	} else {
+		telemetryLogger.Printf("[Telemetry] sending to host failed with error %+v", err)
+	}
+	tb.saveState()
+
+	return true
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ReportTypeField is the field of a report naming its registered report type.
+const ReportTypeField = "ReportType"
+
+// ReportFactory returns a pointer to a new report of a registered type, which reports are decoded into.
+type ReportFactory func() interface{}
+
+// registeredReport is a report of a registered type received by the telemetry server.
+type registeredReport struct {
+	name   string
+	report interface{}
+}
+
+var (
+	reportFactories     = make(map[string]ReportFactory)
+	reportFactoriesLock sync.RWMutex
+)
+
+// RegisterReportType lets components outside this package send their own kinds of reports through the telemetry
+// server. Reports of the type carry its name in their ReportType field, and are decoded with the factory, buffered,
+// and sent to the host under the name. A Metadata field tagged `json:"compute"` is set to the host metadata,
+// like in the built-in reports.
+func RegisterReportType(name string, factory ReportFactory) error {
+	if name == "" || factory == nil {
+		return fmt.Errorf("[Telemetry] Invalid report type %q", name)
+	}
+
+	reportFactoriesLock.Lock()
+	defer reportFactoriesLock.Unlock()
+
+	if _, ok := reportFactories[name]; ok {
+		return fmt.Errorf("[Telemetry] Report type %v is already registered", name)
+	}
+
+	reportFactories[name] = factory
+	return nil
+}
+
+// decodeRegisteredReport decodes a report of the registered type of the given name.
+func decodeRegisteredReport(name string, b []byte) (registeredReport, error) {
+	reportFactoriesLock.RLock()
+	factory := reportFactories[name]
+	reportFactoriesLock.RUnlock()
+
+	if factory == nil {
+		return registeredReport{}, fmt.Errorf("[Telemetry] Report type %v is not registered", name)
+	}
+
+	report := factory()
+	if err := json.Unmarshal(b, report); err != nil {
+		return registeredReport{}, err
+	}
+
+	return registeredReport{name: name, report: report}, nil
+}
+
+// setMetadata sets the Metadata field of a registered report, if it has one.
+func (r registeredReport) setMetadata(metadata Metadata) {
+	v := reflect.ValueOf(r.report)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	field := v.Elem().FieldByName("Metadata")
+	if field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(metadata) {
+		field.Set(reflect.ValueOf(metadata))
+	}
+}
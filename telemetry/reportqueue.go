@@ -0,0 +1,108 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// ReportQueueDir holds the reports of clients that could not reach the telemetry service,
+	// until the service ingests them.
+	ReportQueueDir = platform.CNIRuntimePath + "AzureTelemetryQueue"
+
+	// Max number of queued reports. The oldest reports are dropped beyond it.
+	maxQueuedReports = 200
+
+	// Extension of the queued report files.
+	queuedReportExt = ".report"
+)
+
+// QueueReport persists a report in the queue directory, so that the telemetry service ingests it once it runs.
+// Each report is a file named after the time it was queued, so that clients never write the same file.
+func QueueReport(dir string, report []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("[Telemetry] Creating report queue %s failed with err %v", dir, err)
+	}
+
+	name := fmt.Sprintf("%020d-%d%s", clock.Now().UnixNano(), os.Getpid(), queuedReportExt)
+	if err := writeEncryptedFile(filepath.Join(dir, name), report); err != nil {
+		return err
+	}
+
+	files, _ := getQueuedReports(dir)
+	for len(files) > maxQueuedReports {
+		telemetryLogger.Printf("[Telemetry] Report queue is full, dropping %s", files[0])
+		os.Remove(files[0])
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// getQueuedReports returns the files of the reports in the queue directory, oldest first.
+func getQueuedReports(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), queuedReportExt) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// ingestQueuedReports buffers the reports queued while the service was down, oldest first, and removes them
+// from the queue. Reports that can't be read or decoded are dropped.
+func (tb *TelemetryBuffer) ingestQueuedReports(dir string) {
+	files, err := getQueuedReports(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			telemetryLogger.Printf("[Telemetry] Reading report queue %s failed with err %v", dir, err)
+		}
+		return
+	}
+
+	if len(files) == 0 {
+		return
+	}
+
+	telemetryLogger.Printf("[Telemetry] Ingesting %d queued reports", len(files))
+
+	for _, file := range files {
+		data, err := readEncryptedFile(file)
+		if err == nil {
+			var report interface{}
+			if report, err = decodeReport(data); err == nil {
+				tb.handleReport(report)
+			}
+		}
+
+		if err != nil {
+			telemetryLogger.Printf("[Telemetry] Dropping queued report %s: %v", file, err)
+		}
+	}
+
+	// The reports are saved once, before they are removed from the queue.
+	tb.flushState()
+
+	for _, file := range files {
+		if err = os.Remove(file); err != nil {
+			telemetryLogger.Printf("[Telemetry] Removing queued report %s failed with err %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/telemetry"
+	"github.com/Azure/azure-container-networking/telemetry/rpc/v1"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const defaultTelemetryGrpcURL = "tcp://localhost:10092"
+
+// Client streams reports to the telemetry gRPC API.
+// It implements telemetry.ReportBuffer, so that a ReportManager sends its reports through it.
+type Client struct {
+	conn     *grpc.ClientConn
+	stream   v1.TelemetryService_ReportClient
+	cancel   context.CancelFunc
+	sequence uint64
+	closed   bool
+	sync.Mutex
+}
+
+// NewClient creates a new client streaming reports to the telemetry gRPC API at the given URL,
+// e.g. tcp://localhost:10092 or unix:///var/run/azure-vnet-telemetry.sock.
+func NewClient(urls string) (*Client, error) {
+	if urls == "" {
+		urls = defaultTelemetryGrpcURL
+	}
+
+	u, err := url.Parse(urls)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := u.Scheme
+	dialer := func(address string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout(protocol, address, timeout)
+	}
+
+	conn, err := grpc.Dial(u.Host+u.Path, grpc.WithInsecure(), grpc.WithDialer(dialer))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := v1.NewTelemetryServiceClient(conn).Report(ctx)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, err
+	}
+
+	return &Client{
+		conn:   conn,
+		stream: stream,
+		cancel: cancel,
+	}, nil
+}
+
+// IsConnected returns whether the client can still send reports.
+func (c *Client) IsConnected() bool {
+	if c == nil {
+		return false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	return !c.closed
+}
+
+// Write sends a JSON encoded report, e.g. written by a ReportManager, as the message of its type.
+func (c *Client) Write(b []byte) (int, error) {
+	req, err := telemetry.NewReportRequest(b)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = c.Send(req); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Send sends a report, numbering it with the next sequence number of the client,
+// and waits until the telemetry service acknowledges it.
+func (c *Client) Send(req *v1.ReportRequest) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("[Telemetry] Client is closed")
+	}
+
+	c.sequence++
+	req.Sequence = c.sequence
+	if err := c.stream.Send(req); err != nil {
+		return err
+	}
+
+	ack, err := c.stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	if ack.GetSequence() != c.sequence {
+		return fmt.Errorf("[Telemetry] Received acknowledgement of report %v instead of %v", ack.GetSequence(), c.sequence)
+	}
+
+	if !ack.GetAccepted() {
+		return fmt.Errorf("[Telemetry] Report was rejected: %v", ack.GetError())
+	}
+
+	return nil
+}
+
+// Cancel closes the client.
+func (c *Client) Cancel() {
+	c.Close()
+}
+
+// Close ends the stream and closes the connection to the telemetry service.
+func (c *Client) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	c.stream.CloseSend()
+	c.cancel()
+	return c.conn.Close()
+}
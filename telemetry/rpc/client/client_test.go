@@ -0,0 +1,164 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/telemetry"
+	"github.com/Azure/azure-container-networking/telemetry/fakes"
+	"github.com/Azure/azure-container-networking/telemetry/rpc/v1"
+)
+
+// Tests that reports streamed over gRPC are acknowledged, buffered and sent to the host,
+// and that reports the service can't decode are rejected without ending the stream.
+func TestClient(t *testing.T) {
+	fakeClock, restore := fakes.UseClock(time.Unix(0, 0))
+	defer restore()
+
+	dir, err := ioutil.TempDir("", "telemetry-rpc")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sender := fakes.NewHTTPSender()
+
+	server := telemetry.NewTelemetryBuffer("http://host/report")
+	server.SetTransport(fakes.NewTransport())
+	server.SetHTTPSender(sender)
+	if err = server.StartServer(); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+
+	urls := "unix://" + filepath.Join(dir, "telemetry.sock")
+	if err = server.StartRPCServer(urls); err != nil {
+		t.Fatalf("StartRPCServer failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		server.BufferAndPushData(telemetry.DefaultInterval)
+		close(done)
+	}()
+	defer func() {
+		server.Cancel()
+		<-done
+	}()
+
+	c, err := NewClient(urls)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	if err = c.Send(&v1.ReportRequest{Type: v1.ReportType_REGISTERED, TypeName: "unregistered", Report: []byte("{}")}); err == nil {
+		t.Errorf("Report of an unregistered type was accepted")
+	}
+
+	if err = c.Send(&v1.ReportRequest{Type: v1.ReportType_CNS}); err == nil {
+		t.Errorf("Report without its message was accepted")
+	}
+
+	reportMgr := &telemetry.ReportManager{
+		ContentType: telemetry.ContentType,
+		Report:      &telemetry.CNIReport{ContainerName: "rpc-container", CniSucceeded: true},
+	}
+	if err = reportMgr.SendReport(c); err != nil {
+		t.Fatalf("SendReport failed: %v", err)
+	}
+
+	if err = c.Send(&v1.ReportRequest{Type: v1.ReportType_NPM, Npm: &v1.NPMReport{NodeName: "node"}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	// Both reports were acknowledged, so they are buffered by the time the interval elapses.
+	deadline := time.Now().Add(5 * time.Second)
+	for fakeClock.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for report interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fakeClock.Advance(telemetry.DefaultInterval)
+
+	for len(sender.Requests()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for report delivery")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	payload, err := sender.Payload(0)
+	if err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+
+	if len(payload.CNIReports) != 1 || payload.CNIReports[0].ContainerName != "rpc-container" {
+		t.Errorf("Unexpected CNI reports %+v", payload.CNIReports)
+	}
+
+	if len(payload.NPMReports) != 1 || payload.NPMReports[0].NodeName != "node" {
+		t.Errorf("Unexpected NPM reports %+v", payload.NPMReports)
+	}
+
+	c.Close()
+	if c.IsConnected() {
+		t.Errorf("Client is connected after Close")
+	}
+
+	if _, err = c.Write([]byte("{}")); err == nil {
+		t.Errorf("Write succeeded after Close")
+	}
+}
+
+// Tests that the telemetry service stops while a client keeps its stream open without sending reports.
+func TestStopWithIdleStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry-rpc")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := telemetry.NewTelemetryBuffer("http://host/report")
+	server.SetTransport(fakes.NewTransport())
+	server.SetHTTPSender(fakes.NewHTTPSender())
+	if err = server.StartServer(); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+
+	urls := "unix://" + filepath.Join(dir, "telemetry.sock")
+	if err = server.StartRPCServer(urls); err != nil {
+		t.Fatalf("StartRPCServer failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		server.BufferAndPushData(telemetry.DefaultInterval)
+		close(done)
+	}()
+
+	c, err := NewClient(urls)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer c.Close()
+
+	// The stream is opened by the first report.
+	if err = c.Send(&v1.ReportRequest{Type: v1.ReportType_NPM, Npm: &v1.NPMReport{NodeName: "node"}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	server.Cancel()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("Telemetry service didn't stop with an idle stream")
+	}
+}
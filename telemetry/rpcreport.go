@@ -0,0 +1,569 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/telemetry/rpc/v1"
+)
+
+// NewReportRequest returns the gRPC request carrying a JSON encoded report, e.g. written by a ReportManager.
+// The reports of the types defined by the API are sent as typed messages, those of registered types as JSON.
+func NewReportRequest(b []byte) (*v1.ReportRequest, error) {
+	report, err := decodeReport(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if r, ok := report.(registeredReport); ok {
+		return &v1.ReportRequest{Type: v1.ReportType_REGISTERED, TypeName: r.name, Report: b}, nil
+	}
+
+	return toReportRequest(report)
+}
+
+// toReportRequest returns the gRPC request carrying a report of one of the types defined by the API.
+func toReportRequest(report interface{}) (*v1.ReportRequest, error) {
+	switch r := report.(type) {
+	case CNIReport:
+		return &v1.ReportRequest{Type: v1.ReportType_CNI, Cni: cniReportToProto(&r)}, nil
+	case NPMReport:
+		return &v1.ReportRequest{Type: v1.ReportType_NPM, Npm: npmReportToProto(&r)}, nil
+	case DNCReport:
+		return &v1.ReportRequest{Type: v1.ReportType_DNC, Dnc: dncReportToProto(&r)}, nil
+	case CNSReport:
+		return &v1.ReportRequest{Type: v1.ReportType_CNS, Cns: cnsReportToProto(&r)}, nil
+	case CrashReport:
+		return &v1.ReportRequest{Type: v1.ReportType_CRASH, Crash: crashReportToProto(&r)}, nil
+	default:
+		return nil, fmt.Errorf("[Telemetry] Report type %T is not defined by the gRPC API", report)
+	}
+}
+
+// fromReportRequest returns the report carried by the typed message of a gRPC request.
+func fromReportRequest(req *v1.ReportRequest) (interface{}, error) {
+	var report interface{}
+
+	switch req.Type {
+	case v1.ReportType_CNI:
+		if req.Cni != nil {
+			report = cniReportFromProto(req.Cni)
+		}
+	case v1.ReportType_NPM:
+		if req.Npm != nil {
+			report = npmReportFromProto(req.Npm)
+		}
+	case v1.ReportType_DNC:
+		if req.Dnc != nil {
+			report = dncReportFromProto(req.Dnc)
+		}
+	case v1.ReportType_CNS:
+		if req.Cns != nil {
+			report = cnsReportFromProto(req.Cns)
+		}
+	case v1.ReportType_CRASH:
+		if req.Crash != nil {
+			report = crashReportFromProto(req.Crash)
+		}
+	default:
+		return nil, fmt.Errorf("[Telemetry] Unknown report type %v", req.Type)
+	}
+
+	if report == nil {
+		return nil, fmt.Errorf("[Telemetry] %v report is missing", req.Type)
+	}
+
+	return report, nil
+}
+
+func metadataToProto(m *Metadata) *v1.Metadata {
+	return &v1.Metadata{
+		Location:             m.Location,
+		Name:                 m.VMName,
+		Offer:                m.Offer,
+		OsType:               m.OsType,
+		PlacementGroupId:     m.PlacementGroupID,
+		PlatformFaultDomain:  m.PlatformFaultDomain,
+		PlatformUpdateDomain: m.PlatformUpdateDomain,
+		Publisher:            m.Publisher,
+		ResourceGroupName:    m.ResourceGroupName,
+		Sku:                  m.Sku,
+		SubscriptionId:       m.SubscriptionID,
+		Tags:                 m.Tags,
+		Version:              m.OSVersion,
+		VmId:                 m.VMID,
+		VmSize:               m.VMSize,
+		KernelVersion:        m.KernelVersion,
+	}
+}
+
+func metadataFromProto(m *v1.Metadata) Metadata {
+	return Metadata{
+		Location:             m.GetLocation(),
+		VMName:               m.GetName(),
+		Offer:                m.GetOffer(),
+		OsType:               m.GetOsType(),
+		PlacementGroupID:     m.GetPlacementGroupId(),
+		PlatformFaultDomain:  m.GetPlatformFaultDomain(),
+		PlatformUpdateDomain: m.GetPlatformUpdateDomain(),
+		Publisher:            m.GetPublisher(),
+		ResourceGroupName:    m.GetResourceGroupName(),
+		Sku:                  m.GetSku(),
+		SubscriptionID:       m.GetSubscriptionId(),
+		Tags:                 m.GetTags(),
+		OSVersion:            m.GetVersion(),
+		VMID:                 m.GetVmId(),
+		VMSize:               m.GetVmSize(),
+		KernelVersion:        m.GetKernelVersion(),
+	}
+}
+
+func cniReportToProto(r *CNIReport) *v1.CNIReport {
+	p := &v1.CNIReport{
+		IsNewInstance:     r.IsNewInstance,
+		CniSucceeded:      r.CniSucceeded,
+		Name:              r.Name,
+		Version:           r.Version,
+		ErrorMessage:      r.ErrorMessage,
+		ErrorCode:         uint64(r.ErrorCode),
+		ErrorCodeName:     r.ErrorCodeName,
+		EventMessage:      r.EventMessage,
+		OperationType:     r.OperationType,
+		OperationDuration: int64(r.OperationDuration),
+		Context:           r.Context,
+		SubContext:        r.SubContext,
+		VmUptime:          r.VMUptime,
+		Timestamp:         r.Timestamp,
+		ContainerName:     r.ContainerName,
+		InfraVnetId:       r.InfraVnetID,
+		VnetAddressSpace:  r.VnetAddressSpace,
+		OrchestratorDetails: &v1.OrchestratorInfo{
+			OrchestratorName:    r.OrchestratorDetails.OrchestratorName,
+			OrchestratorVersion: r.OrchestratorDetails.OrchestratorVersion,
+			ErrorMessage:        r.OrchestratorDetails.ErrorMessage,
+		},
+		OsDetails: &v1.OSInfo{
+			OsType:         r.OSDetails.OSType,
+			OsVersion:      r.OSDetails.OSVersion,
+			KernelVersion:  r.OSDetails.KernelVersion,
+			OsDistribution: r.OSDetails.OSDistribution,
+			ErrorMessage:   r.OSDetails.ErrorMessage,
+		},
+		SystemDetails: &v1.SystemInfo{
+			MemVmTotal:       r.SystemDetails.MemVMTotal,
+			MemVmFree:        r.SystemDetails.MemVMFree,
+			MemUsedByProcess: r.SystemDetails.MemUsedByProcess,
+			DiskVmTotal:      r.SystemDetails.DiskVMTotal,
+			DiskVmFree:       r.SystemDetails.DiskVMFree,
+			CpuCount:         int64(r.SystemDetails.CPUCount),
+			ErrorMessage:     r.SystemDetails.ErrorMessage,
+		},
+		InterfaceDetails: &v1.InterfaceInfo{
+			InterfaceType:         r.InterfaceDetails.InterfaceType,
+			Subnet:                r.InterfaceDetails.Subnet,
+			PrimaryCa:             r.InterfaceDetails.PrimaryCA,
+			Mac:                   r.InterfaceDetails.MAC,
+			Name:                  r.InterfaceDetails.Name,
+			SecondaryCaTotalCount: int64(r.InterfaceDetails.SecondaryCATotalCount),
+			SecondaryCaUsedCount:  int64(r.InterfaceDetails.SecondaryCAUsedCount),
+			ErrorMessage:          r.InterfaceDetails.ErrorMessage,
+		},
+		BridgeDetails: &v1.BridgeInfo{
+			NetworkMode:  r.BridgeDetails.NetworkMode,
+			BridgeName:   r.BridgeDetails.BridgeName,
+			ErrorMessage: r.BridgeDetails.ErrorMessage,
+		},
+		StoreLockDetails: &v1.StoreLockInfo{
+			WaitTimeMs:       r.StoreLockDetails.WaitTimeMs,
+			Timeouts:         int64(r.StoreLockDetails.Timeouts),
+			StaleLocksBroken: int64(r.StoreLockDetails.StaleLocksBroken),
+		},
+		SourceId: r.SourceID,
+		Sequence: r.Sequence,
+		Metadata: metadataToProto(&r.Metadata),
+	}
+
+	for _, d := range r.RouteDriftDetails {
+		p.RouteDriftDetails = append(p.RouteDriftDetails, &v1.RouteDriftInfo{
+			EndpointId: d.EndpointID,
+			Kind:       d.Kind,
+			Entry:      d.Entry,
+			Repaired:   d.Repaired,
+			Error:      d.Error,
+		})
+	}
+
+	if r.IPAMDetails != nil {
+		p.IpamDetails = &v1.IPAMInfo{FailureCause: r.IPAMDetails.FailureCause}
+		for _, op := range r.IPAMDetails.Operations {
+			pop := &v1.IPAMOperationInfo{
+				Operation:      op.Operation,
+				Count:          int64(op.Count),
+				MaxLatencyMs:   op.MaxLatencyMs,
+				TotalLatencyMs: op.TotalLatencyMs,
+			}
+			for cause, count := range op.Failures {
+				if pop.Failures == nil {
+					pop.Failures = make(map[string]int64)
+				}
+				pop.Failures[cause] = int64(count)
+			}
+			p.IpamDetails.Operations = append(p.IpamDetails.Operations, pop)
+		}
+		for _, pool := range r.IPAMDetails.Pools {
+			p.IpamDetails.Pools = append(p.IpamDetails.Pools, &v1.IPAMPoolInfo{
+				PoolId:   pool.PoolID,
+				Capacity: int64(pool.Capacity),
+				InUse:    int64(pool.InUse),
+			})
+		}
+	}
+
+	for _, s := range r.StageTimings {
+		p.StageTimings = append(p.StageTimings, &v1.StageTimingInfo{
+			Stage:      s.Stage,
+			Calls:      int64(s.Calls),
+			DurationMs: s.DurationMs,
+		})
+	}
+
+	for _, h := range r.HNSRetryDetails {
+		p.HnsRetryDetails = append(p.HnsRetryDetails, &v1.HNSRetryInfo{
+			Operation: h.Operation,
+			ErrorKind: h.ErrorKind,
+			Retries:   int64(h.Retries),
+			Succeeded: h.Succeeded,
+			Error:     h.Error,
+		})
+	}
+
+	return p
+}
+
+func cniReportFromProto(p *v1.CNIReport) CNIReport {
+	r := CNIReport{
+		IsNewInstance:     p.GetIsNewInstance(),
+		CniSucceeded:      p.GetCniSucceeded(),
+		Name:              p.GetName(),
+		Version:           p.GetVersion(),
+		ErrorMessage:      p.GetErrorMessage(),
+		ErrorCode:         uint(p.GetErrorCode()),
+		ErrorCodeName:     p.GetErrorCodeName(),
+		EventMessage:      p.GetEventMessage(),
+		OperationType:     p.GetOperationType(),
+		OperationDuration: int(p.GetOperationDuration()),
+		Context:           p.GetContext(),
+		SubContext:        p.GetSubContext(),
+		VMUptime:          p.GetVmUptime(),
+		Timestamp:         p.GetTimestamp(),
+		ContainerName:     p.GetContainerName(),
+		InfraVnetID:       p.GetInfraVnetId(),
+		VnetAddressSpace:  p.GetVnetAddressSpace(),
+		OrchestratorDetails: OrchestratorInfo{
+			OrchestratorName:    p.GetOrchestratorDetails().GetOrchestratorName(),
+			OrchestratorVersion: p.GetOrchestratorDetails().GetOrchestratorVersion(),
+			ErrorMessage:        p.GetOrchestratorDetails().GetErrorMessage(),
+		},
+		OSDetails: OSInfo{
+			OSType:         p.GetOsDetails().GetOsType(),
+			OSVersion:      p.GetOsDetails().GetOsVersion(),
+			KernelVersion:  p.GetOsDetails().GetKernelVersion(),
+			OSDistribution: p.GetOsDetails().GetOsDistribution(),
+			ErrorMessage:   p.GetOsDetails().GetErrorMessage(),
+		},
+		SystemDetails: SystemInfo{
+			MemVMTotal:       p.GetSystemDetails().GetMemVmTotal(),
+			MemVMFree:        p.GetSystemDetails().GetMemVmFree(),
+			MemUsedByProcess: p.GetSystemDetails().GetMemUsedByProcess(),
+			DiskVMTotal:      p.GetSystemDetails().GetDiskVmTotal(),
+			DiskVMFree:       p.GetSystemDetails().GetDiskVmFree(),
+			CPUCount:         int(p.GetSystemDetails().GetCpuCount()),
+			ErrorMessage:     p.GetSystemDetails().GetErrorMessage(),
+		},
+		InterfaceDetails: InterfaceInfo{
+			InterfaceType:         p.GetInterfaceDetails().GetInterfaceType(),
+			Subnet:                p.GetInterfaceDetails().GetSubnet(),
+			PrimaryCA:             p.GetInterfaceDetails().GetPrimaryCa(),
+			MAC:                   p.GetInterfaceDetails().GetMac(),
+			Name:                  p.GetInterfaceDetails().GetName(),
+			SecondaryCATotalCount: int(p.GetInterfaceDetails().GetSecondaryCaTotalCount()),
+			SecondaryCAUsedCount:  int(p.GetInterfaceDetails().GetSecondaryCaUsedCount()),
+			ErrorMessage:          p.GetInterfaceDetails().GetErrorMessage(),
+		},
+		BridgeDetails: BridgeInfo{
+			NetworkMode:  p.GetBridgeDetails().GetNetworkMode(),
+			BridgeName:   p.GetBridgeDetails().GetBridgeName(),
+			ErrorMessage: p.GetBridgeDetails().GetErrorMessage(),
+		},
+		StoreLockDetails: StoreLockInfo{
+			WaitTimeMs:       p.GetStoreLockDetails().GetWaitTimeMs(),
+			Timeouts:         int(p.GetStoreLockDetails().GetTimeouts()),
+			StaleLocksBroken: int(p.GetStoreLockDetails().GetStaleLocksBroken()),
+		},
+		SourceID: p.GetSourceId(),
+		Sequence: p.GetSequence(),
+		Metadata: metadataFromProto(p.GetMetadata()),
+	}
+
+	for _, d := range p.GetRouteDriftDetails() {
+		r.RouteDriftDetails = append(r.RouteDriftDetails, RouteDriftInfo{
+			EndpointID: d.GetEndpointId(),
+			Kind:       d.GetKind(),
+			Entry:      d.GetEntry(),
+			Repaired:   d.GetRepaired(),
+			Error:      d.GetError(),
+		})
+	}
+
+	if ipam := p.GetIpamDetails(); ipam != nil {
+		r.IPAMDetails = &IPAMInfo{FailureCause: ipam.GetFailureCause()}
+		for _, pop := range ipam.GetOperations() {
+			op := IPAMOperationInfo{
+				Operation:      pop.GetOperation(),
+				Count:          int(pop.GetCount()),
+				MaxLatencyMs:   pop.GetMaxLatencyMs(),
+				TotalLatencyMs: pop.GetTotalLatencyMs(),
+			}
+			for cause, count := range pop.GetFailures() {
+				if op.Failures == nil {
+					op.Failures = make(map[string]int)
+				}
+				op.Failures[cause] = int(count)
+			}
+			r.IPAMDetails.Operations = append(r.IPAMDetails.Operations, op)
+		}
+		for _, pool := range ipam.GetPools() {
+			r.IPAMDetails.Pools = append(r.IPAMDetails.Pools, IPAMPoolInfo{
+				PoolID:   pool.GetPoolId(),
+				Capacity: int(pool.GetCapacity()),
+				InUse:    int(pool.GetInUse()),
+			})
+		}
+	}
+
+	for _, s := range p.GetStageTimings() {
+		r.StageTimings = append(r.StageTimings, StageTimingInfo{
+			Stage:      s.GetStage(),
+			Calls:      int(s.GetCalls()),
+			DurationMs: s.GetDurationMs(),
+		})
+	}
+
+	for _, h := range p.GetHnsRetryDetails() {
+		r.HNSRetryDetails = append(r.HNSRetryDetails, HNSRetryInfo{
+			Operation: h.GetOperation(),
+			ErrorKind: h.GetErrorKind(),
+			Retries:   int(h.GetRetries()),
+			Succeeded: h.GetSucceeded(),
+			Error:     h.GetError(),
+		})
+	}
+
+	return r
+}
+
+func npmReportToProto(r *NPMReport) *v1.NPMReport {
+	p := &v1.NPMReport{
+		IsNewInstance:     r.IsNewInstance,
+		ClusterId:         r.ClusterID,
+		NodeName:          r.NodeName,
+		InstanceName:      r.InstanceName,
+		NpmVersion:        r.NpmVersion,
+		KubernetesVersion: r.KubernetesVersion,
+		ErrorMessage:      r.ErrorMessage,
+		EventMessage:      r.EventMessage,
+		UpTime:            r.UpTime,
+		ClusterState: &v1.ClusterState{
+			PodCount:      int64(r.ClusterState.PodCount),
+			NsCount:       int64(r.ClusterState.NsCount),
+			NwPolicyCount: int64(r.ClusterState.NwPolicyCount),
+		},
+		PolicyConvergence: &v1.ConvergenceInfo{
+			PolicyEventCount:   int64(r.PolicyConvergence.PolicyEventCount),
+			FailedEventCount:   int64(r.PolicyConvergence.FailedEventCount),
+			FailedPolicyCount:  int64(r.PolicyConvergence.FailedPolicyCount),
+			LastConvergenceMs:  r.PolicyConvergence.LastConvergenceMs,
+			MaxConvergenceMs:   r.PolicyConvergence.MaxConvergenceMs,
+			TotalConvergenceMs: r.PolicyConvergence.TotalConvergenceMs,
+		},
+		DataplaneDrift: &v1.DataplaneDriftInfo{
+			ReconcileCount:     int64(r.DataplaneDrift.ReconcileCount),
+			DriftedCount:       int64(r.DataplaneDrift.DriftedCount),
+			LastDriftCount:     int64(r.DataplaneDrift.LastDriftCount),
+			TotalDriftCount:    int64(r.DataplaneDrift.TotalDriftCount),
+			LastReconcileError: r.DataplaneDrift.LastReconcileError,
+		},
+		Audit: &v1.AuditInfo{
+			Enabled:        r.Audit.Enabled,
+			BlockedPackets: int64(r.Audit.BlockedPackets),
+		},
+		SourceId: r.SourceID,
+		Sequence: r.Sequence,
+		Metadata: metadataToProto(&r.Metadata),
+	}
+
+	for _, flow := range r.Audit.BlockedFlows {
+		p.Audit.BlockedFlows = append(p.Audit.BlockedFlows, &v1.BlockedFlow{
+			Target:   flow.Target,
+			Protocol: flow.Protocol,
+			SrcIp:    flow.SrcIP,
+			DstIp:    flow.DstIP,
+			DstPort:  int64(flow.DstPort),
+			Count:    int64(flow.Count),
+		})
+	}
+
+	return p
+}
+
+func npmReportFromProto(p *v1.NPMReport) NPMReport {
+	r := NPMReport{
+		IsNewInstance:     p.GetIsNewInstance(),
+		ClusterID:         p.GetClusterId(),
+		NodeName:          p.GetNodeName(),
+		InstanceName:      p.GetInstanceName(),
+		NpmVersion:        p.GetNpmVersion(),
+		KubernetesVersion: p.GetKubernetesVersion(),
+		ErrorMessage:      p.GetErrorMessage(),
+		EventMessage:      p.GetEventMessage(),
+		UpTime:            p.GetUpTime(),
+		ClusterState: ClusterState{
+			PodCount:      int(p.GetClusterState().GetPodCount()),
+			NsCount:       int(p.GetClusterState().GetNsCount()),
+			NwPolicyCount: int(p.GetClusterState().GetNwPolicyCount()),
+		},
+		PolicyConvergence: ConvergenceInfo{
+			PolicyEventCount:   int(p.GetPolicyConvergence().GetPolicyEventCount()),
+			FailedEventCount:   int(p.GetPolicyConvergence().GetFailedEventCount()),
+			FailedPolicyCount:  int(p.GetPolicyConvergence().GetFailedPolicyCount()),
+			LastConvergenceMs:  p.GetPolicyConvergence().GetLastConvergenceMs(),
+			MaxConvergenceMs:   p.GetPolicyConvergence().GetMaxConvergenceMs(),
+			TotalConvergenceMs: p.GetPolicyConvergence().GetTotalConvergenceMs(),
+		},
+		DataplaneDrift: DataplaneDriftInfo{
+			ReconcileCount:     int(p.GetDataplaneDrift().GetReconcileCount()),
+			DriftedCount:       int(p.GetDataplaneDrift().GetDriftedCount()),
+			LastDriftCount:     int(p.GetDataplaneDrift().GetLastDriftCount()),
+			TotalDriftCount:    int(p.GetDataplaneDrift().GetTotalDriftCount()),
+			LastReconcileError: p.GetDataplaneDrift().GetLastReconcileError(),
+		},
+		Audit: AuditInfo{
+			Enabled:        p.GetAudit().GetEnabled(),
+			BlockedPackets: int(p.GetAudit().GetBlockedPackets()),
+		},
+		SourceID: p.GetSourceId(),
+		Sequence: p.GetSequence(),
+		Metadata: metadataFromProto(p.GetMetadata()),
+	}
+
+	for _, flow := range p.GetAudit().GetBlockedFlows() {
+		r.Audit.BlockedFlows = append(r.Audit.BlockedFlows, BlockedFlow{
+			Target:   flow.GetTarget(),
+			Protocol: flow.GetProtocol(),
+			SrcIP:    flow.GetSrcIp(),
+			DstIP:    flow.GetDstIp(),
+			DstPort:  int(flow.GetDstPort()),
+			Count:    int(flow.GetCount()),
+		})
+	}
+
+	return r
+}
+
+func dncReportToProto(r *DNCReport) *v1.DNCReport {
+	return &v1.DNCReport{
+		IsNewInstance: r.IsNewInstance,
+		CpuUsage:      r.CPUUsage,
+		MemoryUsage:   r.MemoryUsage,
+		Processes:     r.Processes,
+		EventMessage:  r.EventMessage,
+		PartitionKey:  r.PartitionKey,
+		Allocations:   r.Allocations,
+		Timestamp:     r.Timestamp,
+		Uuid:          r.UUID,
+		Errorcode:     r.Errorcode,
+		SourceId:      r.SourceID,
+		Sequence:      r.Sequence,
+		Metadata:      metadataToProto(&r.Metadata),
+	}
+}
+
+func dncReportFromProto(p *v1.DNCReport) DNCReport {
+	return DNCReport{
+		IsNewInstance: p.GetIsNewInstance(),
+		CPUUsage:      p.GetCpuUsage(),
+		MemoryUsage:   p.GetMemoryUsage(),
+		Processes:     p.GetProcesses(),
+		EventMessage:  p.GetEventMessage(),
+		PartitionKey:  p.GetPartitionKey(),
+		Allocations:   p.GetAllocations(),
+		Timestamp:     p.GetTimestamp(),
+		UUID:          p.GetUuid(),
+		Errorcode:     p.GetErrorcode(),
+		SourceID:      p.GetSourceId(),
+		Sequence:      p.GetSequence(),
+		Metadata:      metadataFromProto(p.GetMetadata()),
+	}
+}
+
+func cnsReportToProto(r *CNSReport) *v1.CNSReport {
+	return &v1.CNSReport{
+		IsNewInstance:   r.IsNewInstance,
+		CpuUsage:        r.CPUUsage,
+		MemoryUsage:     r.MemoryUsage,
+		Processes:       r.Processes,
+		EventMessage:    r.EventMessage,
+		DncPartitionKey: r.DncPartitionKey,
+		Timestamp:       r.Timestamp,
+		Uuid:            r.UUID,
+		Errorcode:       r.Errorcode,
+		SourceId:        r.SourceID,
+		Sequence:        r.Sequence,
+		Metadata:        metadataToProto(&r.Metadata),
+	}
+}
+
+func cnsReportFromProto(p *v1.CNSReport) CNSReport {
+	return CNSReport{
+		IsNewInstance:   p.GetIsNewInstance(),
+		CPUUsage:        p.GetCpuUsage(),
+		MemoryUsage:     p.GetMemoryUsage(),
+		Processes:       p.GetProcesses(),
+		EventMessage:    p.GetEventMessage(),
+		DncPartitionKey: p.GetDncPartitionKey(),
+		Timestamp:       p.GetTimestamp(),
+		UUID:            p.GetUuid(),
+		Errorcode:       p.GetErrorcode(),
+		SourceID:        p.GetSourceId(),
+		Sequence:        p.GetSequence(),
+		Metadata:        metadataFromProto(p.GetMetadata()),
+	}
+}
+
+func crashReportToProto(r *CrashReport) *v1.CrashReport {
+	return &v1.CrashReport{
+		Component:    r.Component,
+		Version:      r.Version,
+		PanicMessage: r.PanicMessage,
+		Fingerprint:  r.Fingerprint,
+		Location:     r.Location,
+		Stack:        r.Stack,
+		Timestamp:    r.Timestamp,
+		Metadata:     metadataToProto(&r.Metadata),
+	}
+}
+
+func crashReportFromProto(p *v1.CrashReport) CrashReport {
+	return CrashReport{
+		Component:    p.GetComponent(),
+		Version:      p.GetVersion(),
+		PanicMessage: p.GetPanicMessage(),
+		Fingerprint:  p.GetFingerprint(),
+		Location:     p.GetLocation(),
+		Stack:        p.GetStack(),
+		Timestamp:    p.GetTimestamp(),
+		Metadata:     metadataFromProto(p.GetMetadata()),
+	}
+}
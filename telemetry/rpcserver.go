@@ -0,0 +1,158 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-container-networking/telemetry/rpc/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Time the gRPC server waits for the streams to end when it stops, before closing them.
+	rpcStopTimeout = 5 * time.Second
+)
+
+// rpcServer ingests the reports streamed over gRPC into the telemetry buffer.
+type rpcServer struct {
+	tb   *TelemetryBuffer
+	stop chan struct{}
+}
+
+// StartRPCServer starts serving the telemetry gRPC API at the given URL,
+// e.g. tcp://localhost:10092 or unix:///var/run/azure-vnet-telemetry.sock,
+// next to the socket of the legacy clients. It must be called after StartServer,
+// and only by the instance buffering the reports.
+func (tb *TelemetryBuffer) StartRPCServer(urls string) error {
+	if tb.FdExists {
+		return fmt.Errorf("[Telemetry] Reports are buffered by another instance")
+	}
+
+	u, err := url.Parse(urls)
+	if err != nil {
+		return err
+	}
+
+	localAddress := u.Host + u.Path
+	if u.Scheme == "unix" {
+		os.Remove(localAddress)
+	}
+
+	l, err := net.Listen(u.Scheme, localAddress)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer()
+	srv := &rpcServer{tb: tb, stop: make(chan struct{})}
+	v1.RegisterTelemetryServiceServer(server, srv)
+
+	go func() {
+		if err := server.Serve(l); err != nil {
+			telemetryLogger.Printf("[Telemetry] gRPC server stopped, err:%v", err)
+		}
+	}()
+
+	tb.rpcServer = server
+	tb.rpcStop = srv.stop
+
+	telemetryLogger.Printf("[Telemetry] gRPC server listening on %v", urls)
+	return nil
+}
+
+// rpcReport is a report received over gRPC, handed to the main loop with the channel it closes once the report
+// is buffered.
+type rpcReport struct {
+	report   interface{}
+	buffered chan struct{}
+}
+
+// stopRPCServer stops the gRPC server if it is running.
+// Streams waiting for the buffer are released first, since it no longer reads reports. The streams of idle clients
+// don't end by themselves, so they are closed if they are still open after rpcStopTimeout.
+func (tb *TelemetryBuffer) stopRPCServer() {
+	if tb.rpcServer == nil {
+		return
+	}
+
+	close(tb.rpcStop)
+
+	server := tb.rpcServer
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(rpcStopTimeout):
+		telemetryLogger.Printf("[Telemetry] gRPC streams still open after %v, closing them", rpcStopTimeout)
+		server.Stop()
+		<-stopped
+	}
+
+	tb.rpcServer = nil
+}
+
+// Report acknowledges each report of the stream once it is buffered, so that clients
+// sending faster than the buffer reads are held back, and rejects the reports it can't decode.
+func (s *rpcServer) Report(stream v1.TelemetryService_ReportServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &v1.ReportAck{Sequence: req.Sequence, Accepted: true}
+
+		report, err := decodeTypedReport(req)
+		if err != nil {
+			telemetryLogger.Printf("[Telemetry] Rejecting report %v: %v", req.Sequence, err)
+			ack.Accepted = false
+			ack.Error = err.Error()
+		} else {
+			r := rpcReport{report: report, buffered: make(chan struct{})}
+			select {
+			case s.tb.data <- r:
+			case <-s.stop:
+				return status.Error(codes.Unavailable, "telemetry service is stopping")
+			}
+
+			select {
+			case <-r.buffered:
+			case <-s.stop:
+				return status.Error(codes.Unavailable, "telemetry service is stopping")
+			}
+		}
+
+		if err = stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeTypedReport decodes a report received over gRPC from the message of its type,
+// or from its JSON encoding like the reports of legacy clients when its type is unspecified or registered.
+func decodeTypedReport(req *v1.ReportRequest) (interface{}, error) {
+	switch req.Type {
+	case v1.ReportType_UNSPECIFIED:
+		return decodeReport(req.Report)
+	case v1.ReportType_REGISTERED:
+		return decodeRegisteredReport(req.TypeName, req.Report)
+	default:
+		return fromReportRequest(req)
+	}
+}
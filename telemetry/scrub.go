@@ -0,0 +1,192 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"regexp"
+)
+
+// Actions of a scrub policy.
+const (
+	// ScrubRedact replaces a value with RedactedValue.
+	ScrubRedact = "redact"
+
+	// ScrubHash replaces a value with a salted hash of it, so that reports about the same value can still be
+	// grouped without revealing it.
+	ScrubHash = "hash"
+)
+
+const (
+	// RedactedValue - replacement of redacted values
+	RedactedValue = "REDACTED"
+
+	// Hex digits of hashed values.
+	scrubHashLength = 16
+)
+
+var (
+	// Candidates for IP addresses in strings, validated with net.ParseIP.
+	ipCandidateRegex = regexp.MustCompile(`[0-9A-Fa-f:.]*[:.][0-9A-Fa-f:.]+`)
+
+	// Container IDs of Docker and containerd.
+	containerIDRegex = regexp.MustCompile(`\b[0-9a-f]{64}\b`)
+)
+
+// ScrubPolicy - fields of reports scrubbed by the telemetry service before buffering them, so that the reports
+// sent to the host carry no personal or customer identifiable data
+type ScrubPolicy struct {
+	// Fields maps the names of the fields to scrub, e.g. ContainerName, to their action.
+	// The fields are matched at any depth of the reports.
+	Fields map[string]string `json:"fields"`
+	// IPAddresses is the action applied to the IP addresses found in any string of the reports.
+	IPAddresses string `json:"ipAddresses"`
+	// ContainerIDs is the action applied to the container IDs found in any string of the reports.
+	ContainerIDs string `json:"containerIds"`
+	// Salt is prepended to the values before hashing them.
+	Salt string `json:"salt"`
+}
+
+// LoadScrubPolicy - read and validate the scrub policy in a JSON file
+func LoadScrubPolicy(path string) (*ScrubPolicy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy ScrubPolicy
+	if err = json.Unmarshal(b, &policy); err != nil {
+		return nil, fmt.Errorf("[Telemetry] Decoding scrub policy %v failed with err %v", path, err)
+	}
+
+	if err = policy.validate(); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// validate - check the actions of the policy
+func (policy *ScrubPolicy) validate() error {
+	for name, action := range policy.Fields {
+		if action != ScrubRedact && action != ScrubHash {
+			return fmt.Errorf("[Telemetry] Invalid scrub action %q of field %v", action, name)
+		}
+	}
+
+	for _, action := range []string{policy.IPAddresses, policy.ContainerIDs} {
+		if action != "" && action != ScrubRedact && action != ScrubHash {
+			return fmt.Errorf("[Telemetry] Invalid scrub action %q", action)
+		}
+	}
+
+	return nil
+}
+
+// Scrub - return a report with the fields of the policy scrubbed. The values the report points to, like those of
+// registered reports, are scrubbed in place, as the service decodes each report into its own values.
+func (policy *ScrubPolicy) Scrub(report interface{}) interface{} {
+	if r, ok := report.(registeredReport); ok {
+		policy.scrubValue(reflect.ValueOf(r.report), "")
+		return r
+	}
+
+	v := reflect.New(reflect.TypeOf(report)).Elem()
+	v.Set(reflect.ValueOf(report))
+	policy.scrubValue(v, "")
+
+	return v.Interface()
+}
+
+// scrubValue - scrub a value held in the field of the given name, and the values it holds
+func (policy *ScrubPolicy) scrubValue(v reflect.Value, field string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+
+		elem := v.Elem()
+		if v.Kind() == reflect.Interface {
+			// Values held by interfaces can't be set, scrub a copy.
+			cp := reflect.New(elem.Type()).Elem()
+			cp.Set(elem)
+			policy.scrubValue(cp, field)
+			if v.CanSet() {
+				v.Set(cp)
+			}
+			return
+		}
+		policy.scrubValue(elem, field)
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			policy.scrubValue(v.Field(i), t.Field(i).Name)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && !v.IsNil() {
+			// Don't modify the backing array of the original report.
+			cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			reflect.Copy(cp, v)
+			v.Set(cp)
+		}
+		for i := 0; i < v.Len(); i++ {
+			policy.scrubValue(v.Index(i), field)
+		}
+
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(policy.scrubString(field, v.String()))
+		}
+	}
+}
+
+// scrubString - apply the policy to a string held in the field of the given name
+func (policy *ScrubPolicy) scrubString(field string, s string) string {
+	if s == "" {
+		return s
+	}
+
+	if action, ok := policy.Fields[field]; ok {
+		return policy.apply(action, s)
+	}
+
+	if policy.ContainerIDs != "" {
+		s = containerIDRegex.ReplaceAllStringFunc(s, func(id string) string {
+			return policy.apply(policy.ContainerIDs, id)
+		})
+	}
+
+	if policy.IPAddresses != "" {
+		s = ipCandidateRegex.ReplaceAllStringFunc(s, func(candidate string) string {
+			if net.ParseIP(candidate) == nil {
+				return candidate
+			}
+			return policy.apply(policy.IPAddresses, candidate)
+		})
+	}
+
+	return s
+}
+
+// apply - redact or hash a value
+func (policy *ScrubPolicy) apply(action string, s string) string {
+	if action == ScrubHash {
+		h := sha256.Sum256([]byte(policy.Salt + s))
+		return hex.EncodeToString(h[:])[:scrubHashLength]
+	}
+
+	return RedactedValue
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	// CNISequenceFile persists the sequence numbers of the CNI plugin across its invocations.
+	CNISequenceFile = platform.CNIRuntimePath + "AzureTelemetrySequence.json"
+
+	// Max number of missing sequence numbers of a source waiting for a late report.
+	// Larger gaps are counted as missing right away.
+	maxPendingSequences = 1000
+)
+
+// nextSequence - stamp the report with the next sequence number of the source of the report manager
+func (reportMgr *ReportManager) nextSequence() error {
+	if reportMgr.SourceID == "" {
+		return nil
+	}
+
+	sequence := reportMgr.sequence + 1
+	if reportMgr.SequenceFile != "" {
+		var err error
+		if sequence, err = incrementSequenceFile(reportMgr.SequenceFile, reportMgr.SourceID); err != nil {
+			return err
+		}
+	}
+
+	reportMgr.sequence = sequence
+	setReportSequence(reportMgr.Report, reportMgr.SourceID, sequence)
+	return nil
+}
+
+// incrementSequenceFile - increment the sequence number of the source in the file, shared by the processes of the source
+func incrementSequenceFile(fileName string, sourceID string) (uint64, error) {
+	kvs, err := store.NewJsonFileStore(fileName)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = kvs.Lock(true); err != nil {
+		return 0, fmt.Errorf("[Telemetry] Locking sequence file %s failed with err %v", fileName, err)
+	}
+	defer kvs.Unlock(false)
+
+	var sequence uint64
+	if err = kvs.Read(sourceID, &sequence); err != nil && err != store.ErrKeyNotFound {
+		// A corrupted file restarts the sequence, which the telemetry service reports as a restart.
+		telemetryLogger.Printf("[Telemetry] Reading sequence file %s failed with err %v", fileName, err)
+	}
+
+	sequence++
+	if err = kvs.Write(sourceID, sequence); err != nil {
+		return 0, fmt.Errorf("[Telemetry] Writing sequence file %s failed with err %v", fileName, err)
+	}
+
+	return sequence, nil
+}
+
+// setReportSequence - set the SourceID and Sequence fields of a report, if it has them
+func setReportSequence(report interface{}, sourceID string, sequence uint64) {
+	v := reflect.ValueOf(report)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	source := v.Elem().FieldByName("SourceID")
+	seq := v.Elem().FieldByName("Sequence")
+	if source.IsValid() && source.CanSet() && source.Kind() == reflect.String &&
+		seq.IsValid() && seq.CanSet() && seq.Kind() == reflect.Uint64 {
+		source.SetString(sourceID)
+		seq.SetUint(sequence)
+	}
+}
+
+// getReportSequence - get the source and sequence number a report was stamped with, if any
+func getReportSequence(report interface{}) (string, uint64) {
+	if r, ok := report.(registeredReport); ok {
+		report = r.report
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(report))
+	if v.Kind() != reflect.Struct {
+		return "", 0
+	}
+
+	source := v.FieldByName("SourceID")
+	seq := v.FieldByName("Sequence")
+	if !source.IsValid() || source.Kind() != reflect.String || !seq.IsValid() || seq.Kind() != reflect.Uint64 {
+		return "", 0
+	}
+
+	return source.String(), seq.Uint()
+}
+
+// sequenceTracker checks the sequence numbers of the reports of each source for gaps.
+// Reports of concurrent senders, like CNI plugin invocations, may arrive out of order, so a missing sequence
+// number is counted as missing only if its report didn't arrive by the end of the next interval.
+type sequenceTracker struct {
+	sources  map[string]*sourceSequence
+	interval uint64
+}
+
+// sourceSequence is the highest sequence number received from a source and the missing ones below it,
+// with the interval they went missing in.
+type sourceSequence struct {
+	last    uint64
+	pending map[uint64]uint64
+}
+
+// newSequenceTracker creates a tracker that has not received reports yet.
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{sources: make(map[string]*sourceSequence)}
+}
+
+// track - check the sequence number of a report, counting duplicates and restarts of its source in the summary
+func (t *sequenceTracker) track(report interface{}, s *summary) {
+	sourceID, sequence := getReportSequence(report)
+	if sourceID == "" || sequence == 0 {
+		return
+	}
+
+	src, ok := t.sources[sourceID]
+	if !ok {
+		// The first report from a source since the service started is the baseline.
+		t.sources[sourceID] = &sourceSequence{last: sequence, pending: make(map[uint64]uint64)}
+		return
+	}
+
+	switch {
+	case sequence > src.last:
+		if gap := sequence - src.last - 1; gap > maxPendingSequences-uint64(len(src.pending)) {
+			s.missing += int(gap)
+		} else {
+			for missing := src.last + 1; missing < sequence; missing++ {
+				src.pending[missing] = t.interval
+			}
+		}
+		src.last = sequence
+	case hasPending(src, sequence):
+		delete(src.pending, sequence)
+	case sequence == 1:
+		telemetryLogger.Printf("[Telemetry] Sequence of source %s restarted after %d", sourceID, src.last)
+		s.restarts++
+		s.missing += len(src.pending)
+		t.sources[sourceID] = &sourceSequence{last: sequence, pending: make(map[uint64]uint64)}
+	default:
+		s.duplicates++
+	}
+}
+
+// hasPending - check if a sequence number of a source is missing
+func hasPending(src *sourceSequence, sequence uint64) bool {
+	_, ok := src.pending[sequence]
+	return ok
+}
+
+// endInterval - count the sequence numbers missing since before the interval that ends as missing in its summary
+func (t *sequenceTracker) endInterval(s *summary) {
+	for _, src := range t.sources {
+		for sequence, interval := range src.pending {
+			if interval < t.interval {
+				s.missing++
+				delete(src.pending, sequence)
+			}
+		}
+	}
+
+	t.interval++
+}
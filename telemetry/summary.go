@@ -0,0 +1,135 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// Error code name of failed CNI reports without an error code.
+	unclassifiedErrorCode = "Unclassified"
+
+	// Format of the interval bounds of summary reports.
+	summaryTimeFormat = "2006-01-02 15:04:05"
+)
+
+// SummaryReport aggregates the reports received by a TelemetryBuffer during an interval.
+type SummaryReport struct {
+	IntervalStart string
+	IntervalEnd   string
+	// ReportCounts counts the received reports by type, e.g. "CNIReport".
+	ReportCounts map[string]int
+	// CNIOperations counts the CNI reports by operation type, and CNIErrorCodes the failed ones by error code name.
+	CNIOperations   map[string]int
+	CNIFailures     int
+	CNIErrorCodes   map[string]int
+	CNILatencyP50Ms int
+	CNILatencyP99Ms int
+	// DroppedReports counts the reports dropped because the payload was full.
+	DroppedReports int
+	// EvictedReports counts the buffered reports evicted to keep the payload within its memory budget.
+	EvictedReports int
+	// MissingReports counts the sequence numbers of report sources whose reports never arrived,
+	// DuplicateReports the reports received twice, and SourceRestarts the sources whose sequence restarted.
+	MissingReports   int
+	DuplicateReports int
+	SourceRestarts   int
+	Metadata         Metadata `json:"compute"`
+}
+
+// summary accumulates the reports received during the current interval.
+type summary struct {
+	start         time.Time
+	reportCounts  map[string]int
+	cniOperations map[string]int
+	cniFailures   int
+	cniErrorCodes map[string]int
+	cniLatencies  []int
+	dropped       int
+	evicted       int
+	missing       int
+	duplicates    int
+	restarts      int
+}
+
+// newSummary creates an empty summary of the interval starting at the given time.
+func newSummary(start time.Time) *summary {
+	return &summary{
+		start:         start,
+		reportCounts:  make(map[string]int),
+		cniOperations: make(map[string]int),
+		cniErrorCodes: make(map[string]int),
+	}
+}
+
+// add - count a received report
+func (s *summary) add(report interface{}) {
+	switch r := report.(type) {
+	case CNIReport:
+		s.reportCounts["CNIReport"]++
+		s.cniOperations[r.OperationType]++
+		s.cniLatencies = append(s.cniLatencies, r.OperationDuration)
+		if !r.CniSucceeded && r.ErrorMessage != "" {
+			s.cniFailures++
+			errorCode := r.ErrorCodeName
+			if errorCode == "" {
+				errorCode = unclassifiedErrorCode
+			}
+			s.cniErrorCodes[errorCode]++
+		}
+	case CNSReport:
+		s.reportCounts["CNSReport"]++
+	case NPMReport:
+		s.reportCounts["NPMReport"]++
+	case DNCReport:
+		s.reportCounts["DNCReport"]++
+	case CrashReport:
+		s.reportCounts["CrashReport"]++
+	case registeredReport:
+		s.reportCounts[r.name]++
+	}
+}
+
+// isEmpty - check if no report was received, dropped, evicted or missed during the interval
+func (s *summary) isEmpty() bool {
+	return len(s.reportCounts) == 0 && s.dropped == 0 && s.evicted == 0 && s.missing == 0 && s.restarts == 0
+}
+
+// report - create the summary report of the interval ending at the given time
+func (s *summary) report(end time.Time) SummaryReport {
+	latencies := append([]int(nil), s.cniLatencies...)
+	sort.Ints(latencies)
+
+	return SummaryReport{
+		IntervalStart:    s.start.Format(summaryTimeFormat),
+		IntervalEnd:      end.Format(summaryTimeFormat),
+		ReportCounts:     s.reportCounts,
+		CNIOperations:    s.cniOperations,
+		CNIFailures:      s.cniFailures,
+		CNIErrorCodes:    s.cniErrorCodes,
+		CNILatencyP50Ms:  percentile(latencies, 50),
+		CNILatencyP99Ms:  percentile(latencies, 99),
+		DroppedReports:   s.dropped,
+		EvictedReports:   s.evicted,
+		MissingReports:   s.missing,
+		DuplicateReports: s.duplicates,
+		SourceRestarts:   s.restarts,
+	}
+}
+
+// percentile - get the nearest-rank percentile of sorted values, or zero if there are none
+func percentile(sorted []int, p int) int {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
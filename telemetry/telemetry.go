@@ -6,17 +6,15 @@ package telemetry
 import (
 	"bufio"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"reflect"
 	"strings"
 
-	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/nmagent"
 	"github.com/Azure/azure-container-networking/platform"
 )
 
@@ -69,6 +67,63 @@ type BridgeInfo struct {
 	ErrorMessage string
 }
 
+// Store lock Details structure.
+type StoreLockInfo struct {
+	WaitTimeMs       int64
+	Timeouts         int
+	StaleLocksBroken int
+}
+
+// Route drift Details structure, a route or ARP entry of an endpoint found missing after it was programmed.
+type RouteDriftInfo struct {
+	EndpointID string
+	Kind       string
+	Entry      string
+	Repaired   bool
+	Error      string `json:",omitempty"`
+}
+
+// IPAM Details structure, the operations of the address manager since its state was created and the utilization of
+// its pools.
+// FailureCause is the cause of the failure of the command, e.g. "exhausted" or "store_lock".
+type IPAMInfo struct {
+	Operations   []IPAMOperationInfo
+	Pools        []IPAMPoolInfo
+	FailureCause string `json:",omitempty"`
+}
+
+// IPAM operation Details structure, failures are counted by cause.
+type IPAMOperationInfo struct {
+	Operation      string
+	Count          int
+	Failures       map[string]int `json:",omitempty"`
+	MaxLatencyMs   int64
+	TotalLatencyMs int64
+}
+
+// IPAM pool Details structure.
+type IPAMPoolInfo struct {
+	PoolID   string
+	Capacity int
+	InUse    int
+}
+
+// Stage timing Details structure, the time a command spent in one of its stages, e.g. "IPAM" or "Netlink".
+type StageTimingInfo struct {
+	Stage      string
+	Calls      int
+	DurationMs int64
+}
+
+// HNS retry Details structure, an HNS request retried after transient errors during a command.
+type HNSRetryInfo struct {
+	Operation string
+	ErrorKind string
+	Retries   int
+	Succeeded bool
+	Error     string `json:",omitempty"`
+}
+
 // Orchestrator Details structure.
 type OrchestratorInfo struct {
 	OrchestratorName    string
@@ -107,6 +162,8 @@ type CNIReport struct {
 	Name                string
 	Version             string
 	ErrorMessage        string
+	ErrorCode           uint
+	ErrorCodeName       string
 	EventMessage        string
 	OperationType       string
 	OperationDuration   int
@@ -122,7 +179,22 @@ type CNIReport struct {
 	SystemDetails       SystemInfo
 	InterfaceDetails    InterfaceInfo
 	BridgeDetails       BridgeInfo
-	Metadata            Metadata `json:"compute"`
+	StoreLockDetails    StoreLockInfo
+	RouteDriftDetails   []RouteDriftInfo  `json:",omitempty"`
+	IPAMDetails         *IPAMInfo         `json:",omitempty"`
+	StageTimings        []StageTimingInfo `json:",omitempty"`
+	HNSRetryDetails     []HNSRetryInfo    `json:",omitempty"`
+	SourceID            string            `json:",omitempty"`
+	Sequence            uint64            `json:",omitempty"`
+	Metadata            Metadata          `json:"compute"`
+}
+
+// IncidentReport correlates a CNI failure with the CNS and NPM reports received shortly before it.
+type IncidentReport struct {
+	CNIReport  CNIReport
+	CNSReports []CNSReport
+	NPMReports []NPMReport
+	Metadata   Metadata `json:"compute"`
 }
 
 // Azure CNS Telemetry Report structure.
@@ -136,6 +208,8 @@ type CNSReport struct {
 	Timestamp       string
 	UUID            string
 	Errorcode       string
+	SourceID        string   `json:",omitempty"`
+	Sequence        uint64   `json:",omitempty"`
 	Metadata        Metadata `json:"compute"`
 }
 
@@ -146,6 +220,43 @@ type ClusterState struct {
 	NwPolicyCount int
 }
 
+// ConvergenceInfo contains the time taken to program the network policy events in the dataplane.
+type ConvergenceInfo struct {
+	PolicyEventCount   int
+	FailedEventCount   int
+	FailedPolicyCount  int
+	LastConvergenceMs  int64
+	MaxConvergenceMs   int64
+	TotalConvergenceMs int64
+}
+
+// DataplaneDriftInfo contains the drift of the dataplane from the state programmed by npm found by the consistency reconciler.
+type DataplaneDriftInfo struct {
+	ReconcileCount     int
+	DriftedCount       int
+	LastDriftCount     int
+	TotalDriftCount    int
+	LastReconcileError string
+}
+
+// BlockedFlow is a flow network policies would have dropped, logged while npm runs in audit mode.
+// Target is the pod or namespace set the packets would have been dropped for.
+type BlockedFlow struct {
+	Target   string
+	Protocol string
+	SrcIP    string
+	DstIP    string
+	DstPort  int
+	Count    int
+}
+
+// AuditInfo contains the flows network policies would have dropped since the last report while npm runs in audit mode.
+type AuditInfo struct {
+	Enabled        bool
+	BlockedPackets int
+	BlockedFlows   []BlockedFlow
+}
+
 // NPMReport structure.
 type NPMReport struct {
 	IsNewInstance     bool
@@ -158,6 +269,11 @@ type NPMReport struct {
 	EventMessage      string
 	UpTime            string
 	ClusterState      ClusterState
+	PolicyConvergence ConvergenceInfo
+	DataplaneDrift    DataplaneDriftInfo
+	Audit             AuditInfo
+	SourceID          string   `json:",omitempty"`
+	Sequence          uint64   `json:",omitempty"`
 	Metadata          Metadata `json:"compute"`
 }
 
@@ -173,14 +289,33 @@ type DNCReport struct {
 	Timestamp     string
 	UUID          string
 	Errorcode     string
+	SourceID      string   `json:",omitempty"`
+	Sequence      uint64   `json:",omitempty"`
 	Metadata      Metadata `json:"compute"`
 }
 
+// ReportBuffer is the transport used by ReportManager to send serialized reports.
+// TelemetryBuffer is the production implementation.
+type ReportBuffer interface {
+	IsConnected() bool
+	Write(b []byte) (int, error)
+	Cancel()
+}
+
 // ReportManager structure.
 type ReportManager struct {
 	HostNetAgentURL string
 	ContentType     string
 	Report          interface{}
+	// QueueDir, if set, is where reports are queued when the telemetry service can't be reached,
+	// so that the service ingests them once it runs.
+	QueueDir string
+	// SourceID, if set, identifies the sender of the reports. Each report is stamped with the next sequence number
+	// of the source, so that the telemetry service detects the reports lost on the way.
+	SourceID string
+	// SequenceFile, if set, persists the sequence number of the source across its processes.
+	SequenceFile string
+	sequence     uint64
 }
 
 // ReadFileByLines reads file line by line and return array of lines.
@@ -234,9 +369,18 @@ func (report *NPMReport) GetReport(clusterID, nodeName, npmVersion, kubernetesVe
 }
 
 // SendReport will send telemetry report to HostNetAgent.
-func (reportMgr *ReportManager) SendReport(tb *TelemetryBuffer) error {
+// Reports are dropped without error when telemetry is disabled on the machine.
+func (reportMgr *ReportManager) SendReport(tb ReportBuffer) error {
+	if IsDisabled() {
+		return nil
+	}
+
 	var err error
-	if tb != nil && tb.Connected {
+	if seqErr := reportMgr.nextSequence(); seqErr != nil {
+		telemetryLogger.Printf("[Telemetry] Stamping report sequence failed with err %v", seqErr)
+	}
+
+	if tb != nil && tb.IsConnected() {
 		telemetryLogger.Printf("[Telemetry] Going to send Telemetry report to hostnetagent")
 
 		switch reportMgr.Report.(type) {
@@ -246,11 +390,14 @@ func (reportMgr *ReportManager) SendReport(tb *TelemetryBuffer) error {
 			telemetryLogger.Printf("[Telemetry] %+v", reportMgr.Report.(*NPMReport))
 		case *DNCReport:
 			telemetryLogger.Printf("[Telemetry] %+v", reportMgr.Report.(*DNCReport))
+		case *CrashReport:
+			telemetryLogger.Printf("[Telemetry] %+v", reportMgr.Report.(*CrashReport))
 		default:
 			telemetryLogger.Printf("[Telemetry] Invalid report type")
 		}
 
-		report, err := reportMgr.ReportToBytes()
+		var report []byte
+		report, err = reportMgr.ReportToBytes()
 		if err == nil {
 			// If write fails, try to re-establish connections as server/client
 			if _, err = tb.Write(report); err != nil {
@@ -261,6 +408,16 @@ func (reportMgr *ReportManager) SendReport(tb *TelemetryBuffer) error {
 		err = fmt.Errorf("Not connected to telemetry server or tb is nil")
 	}
 
+	if err != nil && reportMgr.QueueDir != "" {
+		if report, marshalErr := reportMgr.ReportToBytes(); marshalErr == nil {
+			if queueErr := QueueReport(reportMgr.QueueDir, report); queueErr != nil {
+				telemetryLogger.Printf("[Telemetry] Queueing report failed with err %v", queueErr)
+			} else {
+				telemetryLogger.Printf("[Telemetry] Queued report until the telemetry service runs")
+			}
+		}
+	}
+
 	return err
 }
 
@@ -327,27 +484,11 @@ func (report *CNIReport) GetInterfaceDetails(queryUrl string) {
 		return
 	}
 
-	resp, err := http.Get(queryUrl)
-	if err != nil {
-		report.InterfaceDetails.ErrorMessage = "Http get failed in getting interface details " + err.Error()
-		return
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		errMsg := fmt.Sprintf("Error while getting interface details. http code :%d", resp.StatusCode)
-		report.InterfaceDetails.ErrorMessage = errMsg
-		telemetryLogger.Printf(errMsg)
-		return
-	}
-
-	// Decode XML document.
-	var doc common.XmlDocument
-	decoder := xml.NewDecoder(resp.Body)
-	err = decoder.Decode(&doc)
+	client := nmagent.NewClient(nmagent.Config{InterfaceInfoURL: queryUrl})
+	doc, err := client.GetInterfaceInfo()
 	if err != nil {
-		report.InterfaceDetails.ErrorMessage = "xml decode failed due to " + err.Error()
+		report.InterfaceDetails.ErrorMessage = "Getting interface details failed due to " + err.Error()
+		telemetryLogger.Printf("%s", report.InterfaceDetails.ErrorMessage)
 		return
 	}
 
@@ -436,6 +577,7 @@ func (reportMgr *ReportManager) ReportToBytes() (report []byte, err error) {
 	case *NPMReport:
 	case *DNCReport:
 	case *CNSReport:
+	case *CrashReport:
 	default:
 		err = fmt.Errorf("[Telemetry] Invalid report type")
 	}
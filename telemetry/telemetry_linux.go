@@ -12,6 +12,9 @@ import (
 	"syscall"
 )
 
+// OptOutFile - machine-wide configuration file disabling telemetry
+const OptOutFile = "/etc/azure-container-networking/telemetry.json"
+
 // Memory Info structure.
 type MemInfo struct {
 	MemTotal uint64
@@ -4,16 +4,26 @@
 package telemetry
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/telemetry/rpc/v1"
+	"github.com/golang/protobuf/proto"
 )
 
 var reportManager *ReportManager
@@ -36,6 +46,13 @@ var ipamQueryResponse = "" +
 	"</Interfaces>"
 
 func TestMain(m *testing.M) {
+	// Keep the key encrypting spooled files out of the node-local location.
+	encryptionKeyFile = "telemetry.key"
+
+	// Ignore the telemetry configuration of the machine running the tests.
+	optOutFile = "telemetry-optout.json"
+	os.Unsetenv(EnvTelemetryDisabled)
+
 	u, _ := url.Parse("tcp://" + ipamQueryUrl)
 	ipamAgent, err := common.NewListener(u)
 	if err != nil {
@@ -71,7 +88,7 @@ func TestMain(m *testing.M) {
 	reportManager.ContentType = "application/json"
 	reportManager.Report = &CNIReport{}
 
-	tb = NewTelemetryBuffer(hostAgentUrl)
+	tb = NewTelemetryBuffer("http://" + hostAgentUrl)
 	err = tb.StartServer()
 	if err == nil {
 		go tb.BufferAndPushData(0)
@@ -84,6 +101,7 @@ func TestMain(m *testing.M) {
 	exitCode := m.Run()
 	tb.Cancel()
 	tb.Cleanup(FdName)
+	os.Remove(encryptionKeyFile)
 	os.Exit(exitCode)
 }
 
@@ -155,3 +173,789 @@ func TestSetReportState(t *testing.T) {
 		t.Errorf("Error removing telemetry file due to %v", err)
 	}
 }
+
+func TestIncidentReport(t *testing.T) {
+	buffer := &TelemetryBuffer{}
+	now := time.Now()
+
+	buffer.correlate(CNSReport{EventMessage: "stale"}, now.Add(-2*incidentWindow))
+	for i := 0; i < maxIncidentReports+1; i++ {
+		buffer.correlate(CNSReport{EventMessage: fmt.Sprintf("cns-%d", i)}, now)
+	}
+	buffer.correlate(NPMReport{EventMessage: "npm"}, now)
+
+	incident := buffer.newIncidentReport(CNIReport{ErrorMessage: "failed"})
+
+	if incident.CNIReport.ErrorMessage != "failed" {
+		t.Errorf("Incident doesn't contain the CNI report")
+	}
+
+	if len(incident.CNSReports) != maxIncidentReports || incident.CNSReports[0].EventMessage != "cns-1" ||
+		incident.CNSReports[maxIncidentReports-1].EventMessage != fmt.Sprintf("cns-%d", maxIncidentReports) {
+		t.Errorf("Incident doesn't contain the most recent CNS reports: %+v", incident.CNSReports)
+	}
+
+	if len(incident.NPMReports) != 1 || incident.NPMReports[0].EventMessage != "npm" {
+		t.Errorf("Incident doesn't contain the NPM report: %+v", incident.NPMReports)
+	}
+}
+
+func TestAckMode(t *testing.T) {
+	acked := true
+	host := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		if acked {
+			json.NewEncoder(w).Encode(HostAck{SequenceNumber: payload.SequenceNumber})
+		} else {
+			json.NewEncoder(w).Encode(HostAck{})
+		}
+	}))
+	defer host.Close()
+
+	dir, err := ioutil.TempDir("", "telemetry-ack")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := dir + "/delivery.json"
+
+	buffer := NewTelemetryBuffer(host.URL)
+	if err := buffer.EnableAckMode(stateFile); err != nil {
+		t.Fatalf("EnableAckMode failed due to %v", err)
+	}
+
+	buffer.payload.CNIReports = append(buffer.payload.CNIReports, CNIReport{ErrorMessage: "failed"})
+	buffer.saveState()
+
+	acked = false
+	if err := buffer.sendToHost(); err == nil {
+		t.Errorf("sendToHost succeeded without a matching acknowledgement")
+	}
+
+	// Reports must survive a restart until they are acknowledged.
+	restarted := NewTelemetryBuffer(host.URL)
+	if err := restarted.EnableAckMode(stateFile); err != nil {
+		t.Fatalf("EnableAckMode failed due to %v", err)
+	}
+
+	if len(restarted.payload.CNIReports) != 1 || restarted.sequenceNumber != 1 {
+		t.Fatalf("Delivery state not restored: %+v", restarted.payload)
+	}
+
+	acked = true
+	if err := restarted.sendToHost(); err != nil {
+		t.Errorf("sendToHost failed due to %v", err)
+	}
+
+	if restarted.payload.SequenceNumber != 2 {
+		t.Errorf("Wrong sequence number %d", restarted.payload.SequenceNumber)
+	}
+}
+
+// Tests that the reports buffered in acknowledged delivery mode are saved in batches.
+func TestAckModeStateBatching(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry-ack")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := dir + "/delivery.json"
+
+	buffer := NewTelemetryBuffer("")
+	if err := buffer.EnableAckMode(stateFile); err != nil {
+		t.Fatalf("EnableAckMode failed due to %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		buffer.handleReport(CNIReport{ErrorMessage: "failed"})
+	}
+
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("Delivery state saved for each report, err %v", err)
+	}
+
+	buffer.flushState()
+	restarted := NewTelemetryBuffer("")
+	if err := restarted.EnableAckMode(stateFile); err != nil {
+		t.Fatalf("EnableAckMode failed due to %v", err)
+	}
+
+	if len(restarted.payload.CNIReports) != 3 {
+		t.Errorf("Delivery state not saved by flushState: %+v", restarted.payload.CNIReports)
+	}
+
+	if buffer.stateDirty {
+		t.Errorf("Delivery state still marked as changed after it was saved")
+	}
+}
+
+func TestSummaryReport(t *testing.T) {
+	start := time.Now()
+	s := newSummary(start)
+	if !s.isEmpty() {
+		t.Errorf("New summary isn't empty")
+	}
+
+	for i := 1; i <= 100; i++ {
+		s.add(CNIReport{OperationType: "Add", OperationDuration: i, CniSucceeded: true})
+	}
+	s.add(CNIReport{OperationType: "Delete", ErrorMessage: "failed", ErrorCodeName: "IpamFailure"})
+	s.add(CNIReport{OperationType: "Add", ErrorMessage: "failed"})
+	s.add(CNSReport{})
+	s.dropped = 2
+
+	report := s.report(start.Add(time.Minute))
+
+	if report.ReportCounts["CNIReport"] != 102 || report.ReportCounts["CNSReport"] != 1 {
+		t.Errorf("Wrong report counts %+v", report.ReportCounts)
+	}
+
+	if report.CNIOperations["Add"] != 101 || report.CNIOperations["Delete"] != 1 {
+		t.Errorf("Wrong operation counts %+v", report.CNIOperations)
+	}
+
+	if report.CNIFailures != 2 || report.CNIErrorCodes["IpamFailure"] != 1 || report.CNIErrorCodes[unclassifiedErrorCode] != 1 {
+		t.Errorf("Wrong failure counts %d %+v", report.CNIFailures, report.CNIErrorCodes)
+	}
+
+	if report.CNILatencyP50Ms != 49 || report.CNILatencyP99Ms != 99 {
+		t.Errorf("Wrong latencies p50 %d p99 %d", report.CNILatencyP50Ms, report.CNILatencyP99Ms)
+	}
+
+	if report.DroppedReports != 2 {
+		t.Errorf("Wrong dropped count %d", report.DroppedReports)
+	}
+
+	if !isSuccessfulCNIReport(CNIReport{CniSucceeded: true}) || isSuccessfulCNIReport(CNIReport{}) || isSuccessfulCNIReport(CNSReport{}) {
+		t.Errorf("isSuccessfulCNIReport returned wrong result")
+	}
+}
+
+func TestReportSequence(t *testing.T) {
+	filename := "sequence.json"
+	defer os.Remove(filename)
+
+	// Each report manager stands for an invocation of the plugin, sharing the sequence file.
+	var sequences []uint64
+	for i := 0; i < 3; i++ {
+		reportMgr := &ReportManager{SourceID: "plugin", SequenceFile: filename, Report: &CNIReport{}}
+		if err := reportMgr.nextSequence(); err != nil {
+			t.Fatalf("nextSequence failed due to %v", err)
+		}
+		sourceID, sequence := getReportSequence(*reportMgr.Report.(*CNIReport))
+		if sourceID != "plugin" {
+			t.Errorf("Wrong source %q", sourceID)
+		}
+		sequences = append(sequences, sequence)
+	}
+
+	if sequences[0] != 1 || sequences[1] != 2 || sequences[2] != 3 {
+		t.Errorf("Wrong sequence numbers %v", sequences)
+	}
+
+	tracker := newSequenceTracker()
+	s := newSummary(time.Now())
+	for _, sequence := range []uint64{1, 2, 5, 3, 3} {
+		tracker.track(CNIReport{SourceID: "plugin", Sequence: sequence}, s)
+	}
+	tracker.track(CNSReport{SourceID: "cns", Sequence: 7}, s)
+	tracker.track(CNSReport{}, s)
+
+	// Report 4 may still arrive during the next interval.
+	tracker.endInterval(s)
+	if s.missing != 0 || s.duplicates != 1 {
+		t.Errorf("Wrong counts missing %d duplicates %d", s.missing, s.duplicates)
+	}
+
+	s = newSummary(time.Now())
+	tracker.track(CNIReport{SourceID: "plugin", Sequence: 7}, s)
+	tracker.track(CNIReport{SourceID: "plugin", Sequence: 6}, s)
+	tracker.track(CNSReport{SourceID: "cns", Sequence: 1}, s)
+	tracker.endInterval(s)
+
+	report := s.report(time.Now())
+	if report.MissingReports != 1 || report.DuplicateReports != 0 || report.SourceRestarts != 1 {
+		t.Errorf("Wrong counts missing %d duplicates %d restarts %d", report.MissingReports, report.DuplicateReports, report.SourceRestarts)
+	}
+
+	s = newSummary(time.Now())
+	tracker.endInterval(s)
+	if !s.isEmpty() {
+		t.Errorf("Summary without gaps isn't empty")
+	}
+}
+
+func TestEncryptedFile(t *testing.T) {
+	filename := "encrypted.json"
+	defer os.Remove(filename)
+
+	data := []byte(`{"subscriptionId":"subscription"}`)
+	if err := writeEncryptedFile(filename, data); err != nil {
+		t.Fatalf("writeEncryptedFile failed due to %v", err)
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Reading encrypted file failed due to %v", err)
+	}
+
+	if bytes.Contains(content, []byte("subscription")) {
+		t.Errorf("File is not encrypted: %s", content)
+	}
+
+	decrypted, err := readEncryptedFile(filename)
+	if err != nil || !bytes.Equal(decrypted, data) {
+		t.Errorf("readEncryptedFile returned %s, err %v", decrypted, err)
+	}
+
+	// Files written in plain text by earlier versions are still read.
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		t.Fatalf("Writing plain text file failed due to %v", err)
+	}
+
+	if decrypted, err = readEncryptedFile(filename); err != nil || !bytes.Equal(decrypted, data) {
+		t.Errorf("readEncryptedFile returned %s, err %v", decrypted, err)
+	}
+}
+
+// nodeAgentReport is a report type registered by a component outside this package.
+type nodeAgentReport struct {
+	ReportType string
+	Message    string
+	Metadata   Metadata `json:"compute"`
+}
+
+func TestRegisteredReportType(t *testing.T) {
+	factory := func() interface{} { return &nodeAgentReport{} }
+	if err := RegisterReportType("NodeAgentReport", factory); err != nil {
+		t.Fatalf("Failed to register report type: %v", err)
+	}
+
+	if err := RegisterReportType("NodeAgentReport", factory); err == nil {
+		t.Errorf("Registering a report type twice didn't fail")
+	}
+
+	if _, err := decodeRegisteredReport("UnknownReport", []byte(`{}`)); err == nil {
+		t.Errorf("Decoding a report of an unregistered type didn't fail")
+	}
+
+	registered, err := decodeRegisteredReport("NodeAgentReport", []byte(`{"ReportType":"NodeAgentReport","Message":"hello"}`))
+	if err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+
+	registered.setMetadata(Metadata{VMName: "vm"})
+	report, ok := registered.report.(*nodeAgentReport)
+	if !ok || report.Message != "hello" || report.Metadata.VMName != "vm" {
+		t.Errorf("Wrong decoded report %+v", registered.report)
+	}
+
+	s := newSummary(time.Now())
+	s.add(registered)
+	if s.report(time.Now()).ReportCounts["NodeAgentReport"] != 1 {
+		t.Errorf("Registered report isn't counted in the summary")
+	}
+}
+
+func TestReportQueue(t *testing.T) {
+	queueDir := "reportqueue"
+	defer os.RemoveAll(queueDir)
+
+	reportMgr := &ReportManager{
+		QueueDir: queueDir,
+		Report:   &CNIReport{ErrorMessage: "failed"},
+	}
+
+	// The service can't be reached, so the report is queued.
+	if err := reportMgr.SendReport(nil); err == nil {
+		t.Errorf("SendReport succeeded without a telemetry service")
+	}
+
+	files, err := getQueuedReports(queueDir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Report not queued: %v %v", files, err)
+	}
+
+	if err := QueueReport(queueDir, []byte("not a report")); err != nil {
+		t.Fatalf("QueueReport failed due to %v", err)
+	}
+
+	buffer := NewTelemetryBuffer("")
+	buffer.ingestQueuedReports(queueDir)
+
+	if len(buffer.payload.CNIReports) != 1 || buffer.payload.CNIReports[0].ErrorMessage != "failed" {
+		t.Errorf("Queued report not ingested: %+v", buffer.payload.CNIReports)
+	}
+
+	// Reports that can't be decoded are dropped too.
+	if files, _ := getQueuedReports(queueDir); len(files) != 0 {
+		t.Errorf("Ingested reports not removed from the queue: %v", files)
+	}
+}
+
+func TestProbeOwner(t *testing.T) {
+	info, err := tb.ProbeOwner(FdName)
+	if err != nil {
+		t.Fatalf("ProbeOwner failed with err %v", err)
+	}
+
+	if info.Pid != os.Getpid() {
+		t.Errorf("ProbeOwner returned pid %d, expected %d", info.Pid, os.Getpid())
+	}
+
+	// A second instance leaves the socket to the live owner.
+	second := NewTelemetryBuffer("")
+	if err = second.StartServer(); err == nil || !second.FdExists {
+		t.Errorf("StartServer of second instance returned err %v, FdExists %v", err, second.FdExists)
+	}
+}
+
+func TestTakeOverStaleSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Named pipes are not left behind by dead instances")
+	}
+
+	const name = "azure-vnet-telemetry-stale"
+
+	// Leave a socket behind, as an instance that died would.
+	stale := NewTelemetryBuffer("")
+	if err := stale.Listen(name); err != nil {
+		t.Fatalf("Listen failed with err %v", err)
+	}
+	stale.listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.listener.Close()
+	defer stale.Cleanup(name)
+
+	next := NewTelemetryBuffer("")
+	err := next.Listen(name)
+	if err == nil {
+		t.Fatalf("Listen on stale socket succeeded")
+	}
+
+	if err = next.takeOver(name, err); err != nil || next.FdExists {
+		t.Fatalf("takeOver returned err %v, FdExists %v", err, next.FdExists)
+	}
+	next.listener.Close()
+}
+
+func TestTelemetryOptOut(t *testing.T) {
+	defer os.Remove(optOutFile)
+	defer os.Unsetenv(EnvTelemetryDisabled)
+
+	if IsDisabled() {
+		t.Errorf("Telemetry disabled without configuration")
+	}
+
+	os.Setenv(EnvTelemetryDisabled, "true")
+	if !IsDisabled() {
+		t.Errorf("Telemetry not disabled by %v", EnvTelemetryDisabled)
+	}
+
+	if err := NewTelemetryBuffer("").Connect(); err != ErrTelemetryDisabled {
+		t.Errorf("Connect returned %v while telemetry is disabled", err)
+	}
+
+	// Reports are dropped silently instead of being queued for a retry.
+	queueDir := "optoutqueue"
+	defer os.RemoveAll(queueDir)
+
+	reportMgr := &ReportManager{QueueDir: queueDir, Report: &CNIReport{}}
+	if err := reportMgr.SendReport(nil); err != nil {
+		t.Errorf("SendReport failed while telemetry is disabled: %v", err)
+	}
+
+	if files, _ := getQueuedReports(queueDir); len(files) != 0 {
+		t.Errorf("Report queued while telemetry is disabled: %v", files)
+	}
+
+	// The environment can't enable telemetry disabled by the configuration file.
+	os.Setenv(EnvTelemetryDisabled, "false")
+	files := map[string]bool{
+		`{"disableTelemetry": true}`:  true,
+		`{"disableTelemetry": false}`: false,
+		`not json`:                    true,
+	}
+
+	for content, disabled := range files {
+		if err := ioutil.WriteFile(optOutFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %v: %v", optOutFile, err)
+		}
+
+		if IsDisabled() != disabled {
+			t.Errorf("IsDisabled is not %v with configuration file %v", disabled, content)
+		}
+	}
+}
+
+func panicWithValue() {
+	panic("failed")
+}
+
+func panicWithNilMap() {
+	var m map[string]int
+	m["key"] = 1
+}
+
+// Recovers the panic of a function and creates its crash report, like ReportPanic.
+func crashReportOf(f func()) (report *CrashReport) {
+	defer func() {
+		report = newCrashReport("test", "v1", recover(), panicStack())
+	}()
+
+	f()
+	return nil
+}
+
+func TestCrashReport(t *testing.T) {
+	first, second := crashReportOf(panicWithValue), crashReportOf(panicWithValue)
+	if first.Fingerprint == "" || first.Fingerprint != second.Fingerprint {
+		t.Errorf("Panics at the same location have fingerprints %v and %v", first.Fingerprint, second.Fingerprint)
+	}
+
+	if first.PanicMessage != "failed" || !strings.HasSuffix(first.Location, "telemetry.panicWithValue") {
+		t.Errorf("Wrong crash report %+v", first)
+	}
+
+	// Runtime functions raising the panic are not the location.
+	runtimeCrash := crashReportOf(panicWithNilMap)
+	if !strings.HasSuffix(runtimeCrash.Location, "telemetry.panicWithNilMap") || runtimeCrash.Fingerprint == first.Fingerprint {
+		t.Errorf("Wrong crash report of runtime error %+v", runtimeCrash)
+	}
+
+	b, err := json.Marshal(first)
+	if err != nil {
+		t.Fatalf("Failed to marshal crash report: %v", err)
+	}
+
+	decoded, err := decodeReport(b)
+	if crash, ok := decoded.(CrashReport); err != nil || !ok || crash.Fingerprint != first.Fingerprint {
+		t.Errorf("Wrong decoded crash report %+v, err:%v", decoded, err)
+	}
+}
+
+func TestScrubPolicy(t *testing.T) {
+	policyFile := "scrubpolicy.json"
+	defer os.Remove(policyFile)
+
+	content := `{"fields": {"ContainerName": "hash", "NodeName": "redact"}, "ipAddresses": "redact", "containerIds": "hash", "salt": "s"}`
+	if err := ioutil.WriteFile(policyFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %v: %v", policyFile, err)
+	}
+
+	tb := NewTelemetryBuffer("")
+	if err := tb.EnableScrubbing(policyFile); err != nil {
+		t.Fatalf("EnableScrubbing failed: %v", err)
+	}
+
+	containerID := strings.Repeat("ab", 32)
+	report := CNIReport{
+		ContainerName:    "pod1:default",
+		ErrorMessage:     "Failed to add " + containerID + " with 10.0.0.4/16 and fe80::1, at 12:30:45",
+		VnetAddressSpace: []string{"10.0.0.0/8"},
+		IPAMDetails:      &IPAMInfo{},
+	}
+
+	scrubbed := tb.scrubPolicy.Scrub(report).(CNIReport)
+	hashedName := tb.scrubPolicy.apply(ScrubHash, "pod1:default")
+	if scrubbed.ContainerName != hashedName || len(hashedName) != scrubHashLength {
+		t.Errorf("Container name not hashed: %v", scrubbed.ContainerName)
+	}
+
+	expected := "Failed to add " + tb.scrubPolicy.apply(ScrubHash, containerID) + " with REDACTED/16 and REDACTED, at 12:30:45"
+	if scrubbed.ErrorMessage != expected {
+		t.Errorf("Wrong scrubbed error message %q, expected %q", scrubbed.ErrorMessage, expected)
+	}
+
+	if scrubbed.VnetAddressSpace[0] != "REDACTED/8" || report.VnetAddressSpace[0] != "10.0.0.0/8" {
+		t.Errorf("Wrong scrubbed address space %v, original %v", scrubbed.VnetAddressSpace, report.VnetAddressSpace)
+	}
+
+	npmReport := tb.scrubPolicy.Scrub(NPMReport{NodeName: "node1", NpmVersion: "v1.0.0"}).(NPMReport)
+	if npmReport.NodeName != RedactedValue || npmReport.NpmVersion != "v1.0.0" {
+		t.Errorf("Wrong scrubbed NPM report %+v", npmReport)
+	}
+
+	// Reports are scrubbed before they are buffered.
+	tb.handleReport(report)
+	if len(tb.payload.CNIReports) != 1 || tb.payload.CNIReports[0].ContainerName != hashedName {
+		t.Errorf("Buffered report not scrubbed: %+v", tb.payload.CNIReports)
+	}
+
+	if err := ioutil.WriteFile(policyFile, []byte(`{"ipAddresses": "encrypt"}`), 0644); err != nil {
+		t.Fatalf("Failed to write %v: %v", policyFile, err)
+	}
+
+	if _, err := LoadScrubPolicy(policyFile); err == nil {
+		t.Errorf("Policy with invalid action loaded")
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	tb := NewTelemetryBuffer("")
+
+	size := func(report interface{}) int {
+		var pl Payload
+		pl.track("", false, report)
+		return pl.bytes
+	}
+
+	succeeded := CNIReport{CniSucceeded: true, EventMessage: strings.Repeat("a", 100)}
+	failed := CNIReport{ErrorMessage: "failed"}
+	crash := CrashReport{Fingerprint: "f"}
+
+	// The budget fits the failed and crash reports, and one successful report.
+	tb.EnableMemoryBudget(size(succeeded) + size(failed) + size(crash))
+
+	tb.push(NPMReport{NpmVersion: "v1", ErrorMessage: strings.Repeat("e", 200)})
+	tb.push(failed)
+	tb.push(crash)
+	tb.push(succeeded)
+	if tb.summary.evicted != 1 || len(tb.payload.NPMReports) != 0 || len(tb.payload.CNIReports) != 2 {
+		t.Fatalf("Wrong eviction of the oldest report of low severity, evicted %d, payload %+v", tb.summary.evicted, tb.payload)
+	}
+
+	// Failed CNI reports are evicted before crash reports.
+	tb.push(CrashReport{Fingerprint: strings.Repeat("g", size(succeeded))})
+	if len(tb.payload.CrashReports) != 2 || len(tb.payload.CNIReports) != 0 || tb.summary.evicted != 3 {
+		t.Errorf("Wrong eviction by severity, evicted %d, payload %+v", tb.summary.evicted, tb.payload)
+	}
+
+	if tb.payload.bytes > tb.maxPayloadBytes || len(tb.payload.entries) != tb.payload.len() {
+		t.Errorf("Wrong accounting of %d bytes in %d entries for %d reports", tb.payload.bytes, len(tb.payload.entries), tb.payload.len())
+	}
+
+	// Payloads decoded from the delivery state are accounted for too.
+	b, _ := json.Marshal(tb.payload)
+	var restored Payload
+	json.Unmarshal(b, &restored)
+	restored.restore()
+	if restored.bytes != tb.payload.bytes || len(restored.entries) != 2 {
+		t.Errorf("Wrong accounting of restored payload, %d bytes in %d entries", restored.bytes, len(restored.entries))
+	}
+
+	if report := tb.summary.report(clock.Now()); report.EvictedReports != 3 {
+		t.Errorf("Wrong evicted count %d", report.EvictedReports)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	fakeClock := platform.NewFakeClock(time.Unix(0, 0))
+	previous := SetClock(fakeClock)
+	defer SetClock(previous)
+
+	primaryUp, primaryRequests := false, 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests++
+		if !primaryUp {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer primary.Close()
+
+	dir, err := ioutil.TempDir("", "telemetry-failover")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sink := dir + "/payloads.json"
+
+	buffer := NewTelemetryBuffer(primary.URL)
+	if err = buffer.EnableFailover([]string{"ftp://proxy"}); err == nil {
+		t.Errorf("EnableFailover accepted an unsupported URL")
+	}
+
+	if err = buffer.EnableFailover([]string{"http://127.0.0.1:1/unreachable", "file://" + sink}); err != nil {
+		t.Fatalf("EnableFailover failed due to %v", err)
+	}
+
+	// The payload fails over to the file sink, past the unreachable proxy.
+	if err = buffer.sendToHost(); err != nil {
+		t.Fatalf("sendToHost failed due to %v", err)
+	}
+
+	// The failed backends are skipped until their delay elapses.
+	if err = buffer.sendToHost(); err != nil {
+		t.Fatalf("sendToHost failed due to %v", err)
+	}
+
+	b, _ := ioutil.ReadFile(sink)
+	if lines := strings.Count(string(b), "\n"); lines != 2 || primaryRequests != 1 {
+		t.Errorf("Wrong failover, %d payloads in file sink, %d requests to primary", lines, primaryRequests)
+	}
+
+	// The payloads fail back to the primary once it recovered.
+	primaryUp = true
+	fakeClock.Advance(backendRetryInitialDelay)
+	if err = buffer.sendToHost(); err != nil {
+		t.Fatalf("sendToHost failed due to %v", err)
+	}
+
+	b, _ = ioutil.ReadFile(sink)
+	if lines := strings.Count(string(b), "\n"); lines != 2 || primaryRequests != 2 {
+		t.Errorf("Wrong fail-back, %d payloads in file sink, %d requests to primary", lines, primaryRequests)
+	}
+
+	// When every backend failed recently, all of them are tried again.
+	primaryUp = false
+	os.RemoveAll(dir)
+	if err = buffer.sendToHost(); err == nil {
+		t.Errorf("sendToHost succeeded without any backend")
+	}
+
+	if err = buffer.sendToHost(); err == nil || primaryRequests != 4 {
+		t.Errorf("Wrong retry of failed backends, %d requests to primary", primaryRequests)
+	}
+}
+
+// Tests that the reports of the types defined by the gRPC API are sent as typed messages without losing fields.
+func TestSetClockConcurrently(t *testing.T) {
+	fakeClock := platform.NewFakeClock(time.Unix(0, 0))
+	previous := SetClock(fakeClock)
+	defer SetClock(previous)
+
+	// The clock is swapped while a goroutine uses it, as tests do while buffers are running.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			clock.Now()
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		SetClock(fakeClock)
+	}
+	<-done
+
+	if now := clock.Now(); !now.Equal(time.Unix(0, 0)) {
+		t.Errorf("Wrong time %v of fake clock", now)
+	}
+}
+
+func TestRotateFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry-sink")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sink := dir + "/payloads.json"
+
+	tests := []struct {
+		name    string
+		payload string
+		files   []string
+	}{
+		{name: "first payload", payload: "aaaaaaa\n", files: []string{"aaaaaaa\n"}},
+		{name: "below the limit", payload: "b\n", files: []string{"aaaaaaa\nb\n"}},
+		{name: "past the limit", payload: "cc\n", files: []string{"cc\n", "aaaaaaa\nb\n"}},
+		{name: "larger than the limit", payload: "dddddddddddd\n", files: []string{"dddddddddddd\n", "cc\n", "aaaaaaa\nb\n"}},
+		{name: "oldest file dropped", payload: "e\n", files: []string{"e\n", "dddddddddddd\n", "cc\n"}},
+	}
+
+	for _, test := range tests {
+		if err = rotateFileSink(sink, int64(len(test.payload)), 10, 3); err != nil {
+			t.Fatalf("TestRotateFileSink failed @ %v: %v", test.name, err)
+		}
+
+		file, _ := os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		file.WriteString(test.payload)
+		file.Close()
+
+		for i, expected := range test.files {
+			path := sink
+			if i > 0 {
+				path = fmt.Sprintf("%v.%v", sink, i)
+			}
+
+			if b, err := ioutil.ReadFile(path); err != nil || string(b) != expected {
+				t.Errorf("TestRotateFileSink failed @ %v: %v is %q, expected %q, err:%v", test.name, path, b, expected, err)
+			}
+		}
+
+		if _, err := os.Stat(fmt.Sprintf("%v.%v", sink, 3)); !os.IsNotExist(err) {
+			t.Errorf("TestRotateFileSink failed @ %v: more files than the limit kept", test.name)
+		}
+	}
+}
+
+func TestReportRequest(t *testing.T) {
+	metadata := Metadata{Location: "westus2", VMName: "node-0", OSVersion: "18.04", VMID: "vm", KernelVersion: "4.15"}
+
+	reports := []interface{}{
+		CNIReport{
+			CniSucceeded:      true,
+			Name:              "CNI",
+			ErrorCode:         4,
+			OperationDuration: 120,
+			VnetAddressSpace:  []string{"10.0.0.0/16"},
+			OSDetails:         OSInfo{OSType: "linux", OSDistribution: "Ubuntu"},
+			SystemDetails:     SystemInfo{MemVMTotal: 8192, CPUCount: 4},
+			InterfaceDetails:  InterfaceInfo{PrimaryCA: "10.0.0.4", SecondaryCATotalCount: 30},
+			StoreLockDetails:  StoreLockInfo{WaitTimeMs: 15, Timeouts: 1},
+			RouteDriftDetails: []RouteDriftInfo{{EndpointID: "ep", Kind: "route", Repaired: true}},
+			IPAMDetails: &IPAMInfo{
+				Operations: []IPAMOperationInfo{{
+					Operation: "RequestAddress", Count: 2, Failures: map[string]int{"exhausted": 1}, MaxLatencyMs: 9,
+				}},
+				Pools:        []IPAMPoolInfo{{PoolID: "pool", Capacity: 30, InUse: 29}},
+				FailureCause: "exhausted",
+			},
+			StageTimings:    []StageTimingInfo{{Stage: "IPAM", Calls: 2, DurationMs: 11}},
+			HNSRetryDetails: []HNSRetryInfo{{Operation: "CreateEndpoint", ErrorKind: "Busy", Retries: 2}},
+			SourceID:        "cni",
+			Sequence:        7,
+			Metadata:        metadata,
+		},
+		NPMReport{
+			NodeName:          "node-0",
+			ClusterState:      ClusterState{PodCount: 12, NwPolicyCount: 3},
+			PolicyConvergence: ConvergenceInfo{PolicyEventCount: 5, MaxConvergenceMs: 40},
+			DataplaneDrift:    DataplaneDriftInfo{DriftedCount: 1, LastReconcileError: "drift"},
+			Audit:             AuditInfo{Enabled: true, BlockedFlows: []BlockedFlow{{Target: "ns/np", DstPort: 80, Count: 2}}},
+			Metadata:          metadata,
+		},
+		DNCReport{CPUUsage: "10%", PartitionKey: "key", Allocations: "3", Metadata: metadata},
+		CNSReport{MemoryUsage: "20MB", DncPartitionKey: "key", UUID: "id", Metadata: metadata},
+		CrashReport{Component: "cns", Fingerprint: "abc", Stack: "main.main()", Metadata: metadata},
+	}
+
+	for _, report := range reports {
+		b, err := json.Marshal(report)
+		if err != nil {
+			t.Fatalf("Failed to encode %T: %v", report, err)
+		}
+
+		req, err := NewReportRequest(b)
+		if err != nil {
+			t.Fatalf("NewReportRequest of %T failed: %v", report, err)
+		}
+
+		if len(req.Report) != 0 {
+			t.Errorf("%T was sent as JSON", report)
+		}
+
+		wire, err := proto.Marshal(req)
+		if err != nil {
+			t.Fatalf("Failed to marshal request of %T: %v", report, err)
+		}
+
+		var received v1.ReportRequest
+		if err = proto.Unmarshal(wire, &received); err != nil {
+			t.Fatalf("Failed to unmarshal request of %T: %v", report, err)
+		}
+
+		decoded, err := decodeTypedReport(&received)
+		if err != nil {
+			t.Fatalf("Failed to decode request of %T: %v", report, err)
+		}
+
+		if !reflect.DeepEqual(decoded, report) {
+			t.Errorf("Report changed over gRPC:\n%+v\nexpected:\n%+v", decoded, report)
+		}
+	}
+
+	if _, err := decodeTypedReport(&v1.ReportRequest{Type: v1.ReportType_CNI}); err == nil {
+		t.Errorf("Request without its report was decoded")
+	}
+}
@@ -15,6 +15,9 @@ const (
 	versionCmd = "ver"
 )
 
+// OptOutFile - machine-wide configuration file disabling telemetry
+const OptOutFile = "C:\\ProgramData\\azure-container-networking\\telemetry.json"
+
 type MemInfo struct {
 	MemTotal uint64
 	MemFree  uint64
@@ -41,9 +44,10 @@ func (report *CNIReport) GetSystemDetails() {
 
 func (report *CNIReport) GetOSDetails() {
 	report.OSDetails = OSInfo{OSType: runtime.GOOS}
-	out, err := platform.ExecuteCommand(versionCmd)
+	// ver is a builtin of cmd, not an executable.
+	result, err := platform.ExecWithTimeout("cmd", "/c", versionCmd)
 	if err == nil {
-		report.OSDetails.OSVersion = strings.Replace(out, delimiter, "", -1)
+		report.OSDetails.OSVersion = strings.Replace(result.Stdout, delimiter, "", -1)
 	}
 }
 
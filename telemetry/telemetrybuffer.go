@@ -6,17 +6,19 @@ package telemetry
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"net/http"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
+	"google.golang.org/grpc"
 )
 
 // FdName - file descriptor name
@@ -27,17 +29,28 @@ import (
 // DefaultNpmReportsSize - default NPM report slice size
 // DefaultInterval - default interval for sending payload to host
 // MaxPayloadSize - max payload size (~2MB)
+// incidentWindow - how far back CNS/NPM reports are attached to a CNI failure
+// maxIncidentReports - max number of reports of each kind attached to a CNI failure
+// DeliveryStateFile - buffered reports and sequence number kept in acknowledged delivery mode
 const (
 	FdName             = "azure-vnet-telemetry"
 	Delimiter          = '\n'
 	azureHostReportURL = "http://169.254.169.254/machine/plugins?comp=netagent&type=payload"
 	DefaultInterval    = 60 * time.Second
 	logName            = "azure-vnet-telemetry"
-  MaxPayloadSize     = 2097
+	MaxPayloadSize     = 2097
+	incidentWindow     = 5 * time.Minute
+	maxIncidentReports = 10
+	DeliveryStateFile  = platform.CNIRuntimePath + "AzureTelemetryDelivery.json"
+	// Interval at which the reports buffered in acknowledged delivery mode are saved to the delivery state file.
+	stateSaveInterval = time.Second
 )
 
 var telemetryLogger = log.NewLogger(logName, log.LevelInfo, log.TargetStderr)
 
+// Clock used for report intervals and retries.
+var clock = &syncClock{clock: platform.NewClock()}
+
 // TelemetryBuffer object
 type TelemetryBuffer struct {
 	client             net.Conn
@@ -46,9 +59,65 @@ type TelemetryBuffer struct {
 	azureHostReportURL string
 	payload            Payload
 	FdExists           bool
+	forceTakeover      bool
+	startTime          time.Time
+	stopAccept         chan struct{}
 	Connected          bool
 	data               chan interface{}
 	cancel             chan bool
+	recentReports      []recentReport
+	ackRequired        bool
+	stateFile          string
+	stateDirty         bool
+	sequenceNumber     uint64
+	summary            *summary
+	sequences          *sequenceTracker
+	summaryOnly        bool
+	queueDir           string
+	scrubPolicy        *ScrubPolicy
+	maxPayloadBytes    int
+	transport          Transport
+	sender             HTTPSender
+	backends           []*reportBackend
+	rpcServer          *grpc.Server
+	rpcStop            chan struct{}
+	done               chan struct{}
+}
+
+// BufferConfig holds the settings of a running buffer that Reconfigure changes.
+type BufferConfig struct {
+	HostReportURL  string
+	ReportInterval time.Duration
+	SummaryOnly    bool
+	MemoryBudget   int
+	FailoverURLs   []string
+	ScrubPolicy    string
+}
+
+// configUpdate - settings handed to the main loop, which applies them and returns the result on applied
+type configUpdate struct {
+	config  BufferConfig
+	applied chan error
+}
+
+// HostAck is the acknowledgement returned by the host for a payload.
+// Either field identifies the payload that was received.
+type HostAck struct {
+	SequenceNumber uint64
+	ContentHash    string
+}
+
+// deliveryState is persisted in acknowledged delivery mode so that reports
+// survive a restart until the host acknowledges them.
+type deliveryState struct {
+	SequenceNumber uint64
+	Payload        Payload
+}
+
+// recentReport is a CNS or NPM report kept to give context to CNI failures.
+type recentReport struct {
+	received time.Time
+	report   interface{}
 }
 
 // Payload object holds the different types of reports
@@ -57,82 +126,264 @@ type Payload struct {
 	CNIReports []CNIReport
 	NPMReports []NPMReport
 	CNSReports []CNSReport
+	// IncidentReports hold CNI failures with the CNS and NPM reports received shortly before them.
+	IncidentReports []IncidentReport
+	// CrashReports hold the panics of components, with the fingerprints of their stacks.
+	CrashReports []CrashReport
+	// SummaryReports aggregate the reports received during each interval.
+	SummaryReports []SummaryReport
+	// RegisteredReports hold the reports of the types registered with RegisterReportType, by type name.
+	RegisteredReports map[string][]interface{} `json:",omitempty"`
+	// SequenceNumber identifies the payload in acknowledged delivery mode.
+	SequenceNumber uint64 `json:",omitempty"`
+	entries        []payloadEntry
+	bytes          int // Encoded size of the reports of entries.
 }
 
 // NewTelemetryBuffer - create a new TelemetryBuffer
 func NewTelemetryBuffer(hostReportURL string) *TelemetryBuffer {
 	var tb TelemetryBuffer
 
+	tb.queueDir = ReportQueueDir
+	tb.azureHostReportURL = hostReportURL
 	if hostReportURL == "" {
 		tb.azureHostReportURL = azureHostReportURL
 	}
 
 	tb.data = make(chan interface{})
+	tb.done = make(chan struct{})
 	tb.cancel = make(chan bool, 1)
 	tb.connections = make([]net.Conn, 1)
 	tb.payload.DNCReports = make([]DNCReport, 0)
 	tb.payload.CNIReports = make([]CNIReport, 0)
 	tb.payload.NPMReports = make([]NPMReport, 0)
 	tb.payload.CNSReports = make([]CNSReport, 0)
+	tb.payload.IncidentReports = make([]IncidentReport, 0)
+	tb.payload.CrashReports = make([]CrashReport, 0)
+	tb.payload.SummaryReports = make([]SummaryReport, 0)
+	tb.summary = newSummary(clock.Now())
+	tb.sequences = newSequenceTracker()
 
 	err := telemetryLogger.SetTarget(log.TargetLogfile)
 	if err != nil {
 		fmt.Printf("Failed to configure logging: %v\n", err)
 	}
 
+	if err := telemetryLogger.ConfigureFromEnv(); err != nil {
+		fmt.Printf("Failed to apply log configuration from environment: %v\n", err)
+	}
+
 	return &tb
 }
 
+// EnableAckMode - keep buffered reports until the host acknowledges them.
+// Reports and the sequence number are persisted in stateFile and restored from it,
+// giving at-least-once delivery across restarts. Reports are persisted in batches
+// every stateSaveInterval, and before reports queued on disk are removed.
+func (tb *TelemetryBuffer) EnableAckMode(stateFile string) error {
+	tb.ackRequired = true
+	tb.stateFile = stateFile
+	if stateFile == "" {
+		tb.stateFile = DeliveryStateFile
+	}
+
+	content, err := readEncryptedFile(tb.stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("[Telemetry] Reading delivery state failed with err %v", err)
+	}
+
+	var state deliveryState
+	if err = json.Unmarshal(content, &state); err != nil {
+		return fmt.Errorf("[Telemetry] Decoding delivery state failed with err %v", err)
+	}
+
+	tb.sequenceNumber = state.SequenceNumber
+	tb.payload = state.Payload
+	tb.payload.restore()
+	telemetryLogger.Printf("[Telemetry] Restored %d buffered reports, sequence number %d", tb.payload.len(), tb.sequenceNumber)
+
+	return nil
+}
+
+// EnableSummaryOnlyMode - buffer only failed CNI reports besides the interval summaries,
+// reducing the payload size on nodes creating and deleting many pods.
+func (tb *TelemetryBuffer) EnableSummaryOnlyMode() {
+	tb.summaryOnly = true
+}
+
+// EnableMemoryBudget - limit the encoded size of the buffered reports to maxBytes, evicting the oldest reports of
+// the lowest severity first when it is exceeded, as the number of reports is limited by MaxPayloadSize regardless
+// of their size. Summary reports are not counted and never evicted.
+func (tb *TelemetryBuffer) EnableMemoryBudget(maxBytes int) {
+	tb.maxPayloadBytes = maxBytes
+	tb.evict()
+}
+
+// EnableScrubbing - scrub the reports according to the policy in policyFile before counting and buffering them,
+// so that no data the policy scrubs leaves the service.
+func (tb *TelemetryBuffer) EnableScrubbing(policyFile string) error {
+	policy, err := LoadScrubPolicy(policyFile)
+	if err != nil {
+		return err
+	}
+
+	tb.scrubPolicy = policy
+	return nil
+}
+
+// Reconfigure - apply new settings to a running buffer, e.g. after the configuration file of the service changed.
+// The settings are applied by the main loop between two reports, all of them or none if one is invalid. The report
+// interval restarts when it changes. Settings only apply to the instance buffering the reports.
+func (tb *TelemetryBuffer) Reconfigure(config BufferConfig) error {
+	if tb.FdExists {
+		return nil
+	}
+
+	update := configUpdate{config: config, applied: make(chan error, 1)}
+	select {
+	case tb.data <- update:
+	case <-tb.done:
+		return fmt.Errorf("[Telemetry] Buffer is stopped")
+	}
+
+	return <-update.applied
+}
+
+// applyConfig - apply new settings in the main loop, returning the report interval
+func (tb *TelemetryBuffer) applyConfig(config BufferConfig) (time.Duration, error) {
+	hostReportURL := config.HostReportURL
+	if hostReportURL == "" {
+		hostReportURL = azureHostReportURL
+	}
+
+	backends, err := newBackends(hostReportURL, config.FailoverURLs)
+	if err != nil {
+		return 0, err
+	}
+
+	var policy *ScrubPolicy
+	if config.ScrubPolicy != "" {
+		if policy, err = LoadScrubPolicy(config.ScrubPolicy); err != nil {
+			return 0, err
+		}
+	}
+
+	tb.azureHostReportURL = hostReportURL
+	tb.backends = backends
+	tb.summaryOnly = config.SummaryOnly
+	tb.scrubPolicy = policy
+	tb.maxPayloadBytes = config.MemoryBudget
+	tb.evict()
+	tb.saveState()
+
+	interval := config.ReportInterval
+	if interval < DefaultInterval {
+		interval = DefaultInterval
+	}
+
+	telemetryLogger.Printf("[Telemetry] Applied configuration %+v", config)
+	return interval, nil
+}
+
 // Starts Telemetry server listening on unix domain socket
+// If the socket is taken, FdExists is set when its owner is alive, see takeOver.
 func (tb *TelemetryBuffer) StartServer() error {
 	err := tb.Listen(FdName)
 	if err != nil {
-		tb.FdExists = strings.Contains(err.Error(), "in use") || strings.Contains(err.Error(), "Access is denied")
-		return err
+		if err = tb.takeOver(FdName, err); err != nil {
+			return err
+		}
 	}
 
+	tb.startTime = time.Now()
+	tb.stopAccept = make(chan struct{})
+
 	// Spawn server goroutine to handle incoming connections
-	go func() {
+	go func(stop chan struct{}) {
 		for {
 			// Spawn worker goroutines to communicate with client
 			conn, err := tb.listener.Accept()
-			if err == nil {
-				tb.connections = append(tb.connections, conn)
-				go func() {
-					for {
-						reportStr, err := read(conn)
-						if err == nil {
-							var tmp map[string]interface{}
-							json.Unmarshal(reportStr, &tmp)
-							if _, ok := tmp["NpmVersion"]; ok {
-								var npmReport NPMReport
-								json.Unmarshal([]byte(reportStr), &npmReport)
-								tb.data <- npmReport
-							} else if _, ok := tmp["CniSucceeded"]; ok {
-								telemetryLogger.Printf("[Telemetry] Got cni report")
-								var cniReport CNIReport
-								json.Unmarshal([]byte(reportStr), &cniReport)
-								tb.data <- cniReport
-							} else if _, ok := tmp["Allocations"]; ok {
-								var dncReport DNCReport
-								json.Unmarshal([]byte(reportStr), &dncReport)
-								tb.data <- dncReport
-							} else if _, ok := tmp["DncPartitionKey"]; ok {
-								var cnsReport CNSReport
-								json.Unmarshal([]byte(reportStr), &cnsReport)
-								tb.data <- cnsReport
-							}
-						}
-					}
-				}()
+			if err != nil {
+				select {
+				case <-stop:
+					return
+				default:
+					continue
+				}
 			}
+
+			tb.connections = append(tb.connections, conn)
+			go func() {
+				for {
+					reportStr, err := read(conn)
+					if err != nil {
+						conn.Close()
+						return
+					}
+
+					if control, ok := decodeControl(reportStr); ok {
+						tb.data <- controlRequest{control: control, conn: conn}
+						continue
+					}
+
+					report, err := decodeReport(reportStr)
+					if err != nil {
+						telemetryLogger.Printf("[Telemetry] Dropping report: %v", err)
+						continue
+					}
+					tb.data <- report
+				}
+			}()
 		}
-	}()
+	}(tb.stopAccept)
 
 	return nil
 }
 
+// decodeReport decodes a report received from a client, by its registered type or by the fields of its type.
+func decodeReport(reportStr []byte) (interface{}, error) {
+	var tmp map[string]interface{}
+	if err := json.Unmarshal(reportStr, &tmp); err != nil {
+		return nil, err
+	}
+
+	if name, ok := tmp[ReportTypeField].(string); ok && name != "" {
+		return decodeRegisteredReport(name, reportStr)
+	} else if _, ok := tmp["Fingerprint"]; ok {
+		var crashReport CrashReport
+		json.Unmarshal([]byte(reportStr), &crashReport)
+		return crashReport, nil
+	} else if _, ok := tmp["NpmVersion"]; ok {
+		var npmReport NPMReport
+		json.Unmarshal([]byte(reportStr), &npmReport)
+		return npmReport, nil
+	} else if _, ok := tmp["CniSucceeded"]; ok {
+		telemetryLogger.Printf("[Telemetry] Got cni report")
+		var cniReport CNIReport
+		json.Unmarshal([]byte(reportStr), &cniReport)
+		return cniReport, nil
+	} else if _, ok := tmp["Allocations"]; ok {
+		var dncReport DNCReport
+		json.Unmarshal([]byte(reportStr), &dncReport)
+		return dncReport, nil
+	} else if _, ok := tmp["DncPartitionKey"]; ok {
+		var cnsReport CNSReport
+		json.Unmarshal([]byte(reportStr), &cnsReport)
+		return cnsReport, nil
+	}
+
+	return nil, fmt.Errorf("[Telemetry] Unknown report type")
+}
+
 func (tb *TelemetryBuffer) Connect() error {
+	if IsDisabled() {
+		return ErrTelemetryDisabled
+	}
+
 	err := tb.Dial(FdName)
 	if err == nil {
 		tb.Connected = true
@@ -143,8 +394,14 @@ func (tb *TelemetryBuffer) Connect() error {
 	return err
 }
 
+// IsConnected - check whether the buffer is connected to the telemetry server
+func (tb *TelemetryBuffer) IsConnected() bool {
+	return tb != nil && tb.Connected
+}
+
 // BufferAndPushData - BufferAndPushData running an instance if it isn't already being run elsewhere
 func (tb *TelemetryBuffer) BufferAndPushData(intervalms time.Duration) {
+	defer close(tb.done)
 	defer tb.close()
 	if !tb.FdExists {
 		telemetryLogger.Printf("[Telemetry] Buffer telemetry data and send it to host")
@@ -152,21 +409,72 @@ func (tb *TelemetryBuffer) BufferAndPushData(intervalms time.Duration) {
 			intervalms = DefaultInterval
 		}
 
-		interval := time.NewTicker(intervalms).C
+		// Reports queued by clients while the service was down are ingested at start and at every interval.
+		tb.ingestQueuedReports(tb.queueDir)
+
+		ticker := clock.NewTicker(intervalms)
+		defer func() { ticker.Stop() }()
+		interval := ticker.C()
+
+		// Reports are saved in batches, rather than rewriting the delivery state for every report.
+		var stateSave <-chan time.Time
+		if tb.ackRequired {
+			stateTicker := clock.NewTicker(stateSaveInterval)
+			defer stateTicker.Stop()
+			stateSave = stateTicker.C()
+		}
+
 		for {
 			select {
 			case <-interval:
+				if IsDisabled() {
+					telemetryLogger.Printf("[Telemetry] Telemetry was disabled, dropping buffered reports")
+					tb.payload.reset()
+					tb.saveState()
+					goto EXIT
+				}
+
+				tb.ingestQueuedReports(tb.queueDir)
+
 				// Send payload to host and clear cache when sent successfully
 				// To-do : if we hit max slice size in payload, write to disk and process the logs on disk on future sends
+				tb.pushSummary(clock.Now())
 				telemetryLogger.Printf("[Telemetry] send data to host")
 				if err := tb.sendToHost(); err == nil {
 					tb.payload.reset()
+					tb.saveState()
 				} else {
 					telemetryLogger.Printf("[Telemetry] sending to host failed with error %+v", err)
 				}
 			case report := <-tb.data:
+				if req, ok := report.(controlRequest); ok {
+					if tb.handleControl(req) {
+						goto EXIT
+					}
+					continue
+				}
+
+				if update, ok := report.(configUpdate); ok {
+					next, err := tb.applyConfig(update.config)
+					if err == nil && next != intervalms {
+						intervalms = next
+						ticker.Stop()
+						ticker = clock.NewTicker(intervalms)
+						interval = ticker.C()
+					}
+					update.applied <- err
+					continue
+				}
+
 				telemetryLogger.Printf("[Telemetry] Got data..Append it to buffer")
-				tb.payload.push(report)
+				if r, ok := report.(rpcReport); ok {
+					tb.handleReport(r.report)
+					close(r.buffered)
+					continue
+				}
+				tb.handleReport(report)
+			case <-stateSave:
+				tb.flushState()
 			case <-tb.cancel:
 				goto EXIT
 			}
@@ -176,6 +484,62 @@ func (tb *TelemetryBuffer) BufferAndPushData(intervalms time.Duration) {
 	}
 
 EXIT:
+	tb.flushState()
+}
+
+// handleReport - scrub a report, count it in the summary and buffer it
+func (tb *TelemetryBuffer) handleReport(report interface{}) {
+	if tb.scrubPolicy != nil {
+		report = tb.scrubPolicy.Scrub(report)
+	}
+
+	tb.summary.add(report)
+	tb.sequences.track(report, tb.summary)
+	if !tb.summaryOnly || !isSuccessfulCNIReport(report) {
+		tb.push(report)
+	}
+	tb.correlate(report, clock.Now())
+	tb.stateDirty = true
+}
+
+// correlate - remember CNS/NPM reports and turn CNI failures into incident reports
+func (tb *TelemetryBuffer) correlate(report interface{}, now time.Time) {
+	// Forget reports that fell out of the window.
+	i := 0
+	for i < len(tb.recentReports) && now.Sub(tb.recentReports[i].received) > incidentWindow {
+		i++
+	}
+	tb.recentReports = tb.recentReports[i:]
+
+	switch r := report.(type) {
+	case CNSReport, NPMReport:
+		tb.recentReports = append(tb.recentReports, recentReport{received: now, report: report})
+	case CNIReport:
+		if !r.CniSucceeded && r.ErrorMessage != "" {
+			telemetryLogger.Printf("[Telemetry] Creating incident report for CNI failure: %v", r.ErrorMessage)
+			tb.push(tb.newIncidentReport(r))
+		}
+	}
+}
+
+// newIncidentReport - attach the most recent CNS/NPM reports to a CNI failure
+func (tb *TelemetryBuffer) newIncidentReport(cniReport CNIReport) IncidentReport {
+	incident := IncidentReport{CNIReport: cniReport}
+
+	for i := len(tb.recentReports) - 1; i >= 0; i-- {
+		switch r := tb.recentReports[i].report.(type) {
+		case CNSReport:
+			if len(incident.CNSReports) < maxIncidentReports {
+				incident.CNSReports = append([]CNSReport{r}, incident.CNSReports...)
+			}
+		case NPMReport:
+			if len(incident.NPMReports) < maxIncidentReports {
+				incident.NPMReports = append([]NPMReport{r}, incident.NPMReports...)
+			}
+		}
+	}
+
+	return incident
 }
 
 // read - read from the file descriptor
@@ -207,6 +571,8 @@ func (tb *TelemetryBuffer) Cancel() {
 
 // close - close all connections
 func (tb *TelemetryBuffer) close() {
+	tb.stopRPCServer()
+
 	if tb.client != nil {
 		tb.client.Close()
 	}
@@ -222,28 +588,94 @@ func (tb *TelemetryBuffer) close() {
 	}
 }
 
+// saveState - persist buffered reports and sequence number in acknowledged delivery mode
+func (tb *TelemetryBuffer) saveState() {
+	if !tb.ackRequired {
+		return
+	}
+
+	dataBytes, err := json.Marshal(deliveryState{SequenceNumber: tb.sequenceNumber, Payload: tb.payload})
+	if err != nil {
+		telemetryLogger.Printf("[Telemetry] marshal delivery state failed with err %+v", err)
+		return
+	}
+
+	if err = writeEncryptedFile(tb.stateFile, dataBytes); err != nil {
+		telemetryLogger.Printf("[Telemetry] Writing delivery state to file failed: %v", err)
+		return
+	}
+
+	tb.stateDirty = false
+}
+
+// flushState - persist the reports buffered since the delivery state was last saved
+func (tb *TelemetryBuffer) flushState() {
+	if tb.stateDirty {
+		tb.saveState()
+	}
+}
+
 // sendToHost - send payload to host
 func (tb *TelemetryBuffer) sendToHost() error {
-	httpc := &http.Client{}
+	if tb.ackRequired {
+		// Every attempt gets a new sequence number, persisted before sending,
+		// so that an acknowledgement can never match an earlier attempt.
+		tb.sequenceNumber++
+		tb.payload.SequenceNumber = tb.sequenceNumber
+		tb.saveState()
+	}
+
 	var body bytes.Buffer
 	telemetryLogger.Printf("Sending payload %+v", tb.payload)
 	json.NewEncoder(&body).Encode(tb.payload)
-	resp, err := httpc.Post(tb.azureHostReportURL, ContentType, &body)
-	if err != nil {
-		return fmt.Errorf("[Telemetry] HTTP Post returned error %v", err)
+	contentHash := sha256.Sum256(body.Bytes())
+
+	return tb.sendToBackends(body.Bytes(), hex.EncodeToString(contentHash[:]))
+}
+
+// push - push the report to the payload, counting it in the summary if it was dropped
+func (tb *TelemetryBuffer) push(report interface{}) {
+	if !tb.payload.push(report) {
+		tb.summary.dropped++
+		return
 	}
 
-	defer resp.Body.Close()
+	tb.evict()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("[Telemetry] HTTP Post returned statuscode %d", resp.StatusCode)
+// evict - evict reports until the payload fits in the memory budget, counting them in the summary
+func (tb *TelemetryBuffer) evict() {
+	if tb.maxPayloadBytes <= 0 {
+		return
 	}
 
-	return nil
+	if evicted := tb.payload.evict(tb.maxPayloadBytes); evicted > 0 {
+		telemetryLogger.Printf("[Telemetry] Evicted %d reports exceeding the memory budget of %d bytes", evicted, tb.maxPayloadBytes)
+		tb.summary.evicted += evicted
+	}
 }
 
-// push - push the report (x) to corresponding slice
-func (pl *Payload) push(x interface{}) {
+// pushSummary - push the summary of the interval ending now and start a new interval
+func (tb *TelemetryBuffer) pushSummary(now time.Time) {
+	tb.sequences.endInterval(tb.summary)
+	if tb.summary.isEmpty() {
+		return
+	}
+
+	tb.payload.push(tb.summary.report(now))
+	tb.summary = newSummary(now)
+	tb.saveState()
+}
+
+// isSuccessfulCNIReport - check if the report is of a CNI command that succeeded
+func isSuccessfulCNIReport(report interface{}) bool {
+	cniReport, ok := report.(CNIReport)
+	return ok && cniReport.CniSucceeded
+}
+
+// push - push the report (x) to corresponding slice, returning false if the payload is full
+// Summary reports are always pushed as they account for the reports that were dropped.
+func (pl *Payload) push(x interface{}) bool {
 	metadata, err := getHostMetadata()
 	if err != nil {
 		telemetryLogger.Printf("Error getting metadata %v", err)
@@ -254,26 +686,58 @@ func (pl *Payload) push(x interface{}) {
 		}
 	}
 
-  if pl.len() < MaxPayloadSize {
-    switch x.(type) {
-    case DNCReport:
-      dncReport := x.(DNCReport)
-      dncReport.Metadata = metadata
-      pl.DNCReports = append(pl.DNCReports, dncReport)
-    case CNIReport:
-      cniReport := x.(CNIReport)
-      cniReport.Metadata = metadata
-      pl.CNIReports = append(pl.CNIReports, cniReport)
-    case NPMReport:
-      npmReport := x.(NPMReport)
-      npmReport.Metadata = metadata
-      pl.NPMReports = append(pl.NPMReports, npmReport)
-    case CNSReport:
-      cnsReport := x.(CNSReport)
-      cnsReport.Metadata = metadata
-      pl.CNSReports = append(pl.CNSReports, cnsReport)
-    }
-  }
+	if summaryReport, ok := x.(SummaryReport); ok {
+		summaryReport.Metadata = metadata
+		pl.SummaryReports = append(pl.SummaryReports, summaryReport)
+		return true
+	}
+
+	if pl.len() >= MaxPayloadSize {
+		return false
+	}
+
+	switch x.(type) {
+	case DNCReport:
+		dncReport := x.(DNCReport)
+		dncReport.Metadata = metadata
+		pl.DNCReports = append(pl.DNCReports, dncReport)
+		pl.track(listDNCReports, false, dncReport)
+	case CNIReport:
+		cniReport := x.(CNIReport)
+		cniReport.Metadata = metadata
+		pl.CNIReports = append(pl.CNIReports, cniReport)
+		pl.track(listCNIReports, false, cniReport)
+	case NPMReport:
+		npmReport := x.(NPMReport)
+		npmReport.Metadata = metadata
+		pl.NPMReports = append(pl.NPMReports, npmReport)
+		pl.track(listNPMReports, false, npmReport)
+	case CNSReport:
+		cnsReport := x.(CNSReport)
+		cnsReport.Metadata = metadata
+		pl.CNSReports = append(pl.CNSReports, cnsReport)
+		pl.track(listCNSReports, false, cnsReport)
+	case IncidentReport:
+		incidentReport := x.(IncidentReport)
+		incidentReport.Metadata = metadata
+		pl.IncidentReports = append(pl.IncidentReports, incidentReport)
+		pl.track(listIncidentReports, false, incidentReport)
+	case CrashReport:
+		crashReport := x.(CrashReport)
+		crashReport.Metadata = metadata
+		pl.CrashReports = append(pl.CrashReports, crashReport)
+		pl.track(listCrashReports, false, crashReport)
+	case registeredReport:
+		registered := x.(registeredReport)
+		registered.setMetadata(metadata)
+		if pl.RegisteredReports == nil {
+			pl.RegisteredReports = make(map[string][]interface{})
+		}
+		pl.RegisteredReports[registered.name] = append(pl.RegisteredReports[registered.name], registered.report)
+		pl.track(registered.name, true, registered.report)
+	}
+
+	return true
 }
 
 // reset - reset payload slices
@@ -286,11 +750,59 @@ func (pl *Payload) reset() {
 	pl.NPMReports = make([]NPMReport, 0)
 	pl.CNSReports = nil
 	pl.CNSReports = make([]CNSReport, 0)
+	pl.IncidentReports = nil
+	pl.IncidentReports = make([]IncidentReport, 0)
+	pl.CrashReports = nil
+	pl.CrashReports = make([]CrashReport, 0)
+	pl.SummaryReports = nil
+	pl.SummaryReports = make([]SummaryReport, 0)
+	pl.RegisteredReports = nil
+	pl.entries = nil
+	pl.bytes = 0
+}
+
+// restore - make sure payload slices decoded from the delivery state are not nil
+func (pl *Payload) restore() {
+	if pl.DNCReports == nil {
+		pl.DNCReports = make([]DNCReport, 0)
+	}
+
+	if pl.CNIReports == nil {
+		pl.CNIReports = make([]CNIReport, 0)
+	}
+
+	if pl.NPMReports == nil {
+		pl.NPMReports = make([]NPMReport, 0)
+	}
+
+	if pl.CNSReports == nil {
+		pl.CNSReports = make([]CNSReport, 0)
+	}
+
+	if pl.IncidentReports == nil {
+		pl.IncidentReports = make([]IncidentReport, 0)
+	}
+
+	if pl.CrashReports == nil {
+		pl.CrashReports = make([]CrashReport, 0)
+	}
+
+	if pl.SummaryReports == nil {
+		pl.SummaryReports = make([]SummaryReport, 0)
+	}
+
+	pl.retrack()
 }
 
 // len - get number of payload items
 func (pl *Payload) len() int {
-	return len(pl.CNIReports) + len(pl.CNSReports) + len(pl.DNCReports) + len(pl.NPMReports)
+	n := len(pl.CNIReports) + len(pl.CNSReports) + len(pl.DNCReports) + len(pl.NPMReports) + len(pl.IncidentReports) +
+		len(pl.CrashReports)
+	for _, reports := range pl.RegisteredReports {
+		n += len(reports)
+	}
+
+	return n
 }
 
 // saveHostMetadata - save metadata got from wireserver to json file
@@ -300,7 +812,7 @@ func saveHostMetadata(metadata Metadata) error {
 		return fmt.Errorf("[Telemetry] marshal data failed with err %+v", err)
 	}
 
-	if err = ioutil.WriteFile(metadataFile, dataBytes, 0644); err != nil {
+	if err = writeEncryptedFile(metadataFile, dataBytes); err != nil {
 		telemetryLogger.Printf("[Telemetry] Writing metadata to file failed: %v", err)
 	}
 
@@ -309,7 +821,7 @@ func saveHostMetadata(metadata Metadata) error {
 
 // getHostMetadata - retrieve metadata from host
 func getHostMetadata() (Metadata, error) {
-	content, err := ioutil.ReadFile(metadataFile)
+	content, err := readEncryptedFile(metadataFile)
 	if err == nil {
 		var metadata Metadata
 		if err = json.Unmarshal(content, &metadata); err == nil {
@@ -350,6 +862,10 @@ func getHostMetadata() (Metadata, error) {
 
 // StartTelemetryService - Kills if any telemetry service runs and start new telemetry service
 func StartTelemetryService() error {
+	if IsDisabled() {
+		return ErrTelemetryDisabled
+	}
+
 	platform.KillProcessByName(telemetryServiceProcessName)
 
 	telemetryLogger.Printf("[Telemetry] Starting telemetry service process")
@@ -366,7 +882,7 @@ func StartTelemetryService() error {
 			break
 		}
 
-		time.Sleep(200 * time.Millisecond)
+		clock.Sleep(200 * time.Millisecond)
 	}
 
 	return nil
@@ -16,28 +16,21 @@ const (
 	metadataFile                = "/tmp/azuremetadata.json"
 )
 
-// Dial - try to connect to/create a socket with 'name'
-func (tb *TelemetryBuffer) Dial(name string) (err error) {
-	conn, err := net.Dial("unix", fmt.Sprintf(fdTemplate, name))
-	if err == nil {
-		tb.client = conn
-	}
+// platformTransport - transport over unix domain sockets
+type platformTransport struct{}
 
-	return err
+// Dial - connect to the socket with 'name'
+func (platformTransport) Dial(name string) (net.Conn, error) {
+	return net.Dial("unix", fmt.Sprintf(fdTemplate, name))
 }
 
-// Listen - try to create and listen on socket with 'name'
-func (tb *TelemetryBuffer) Listen(name string) (err error) {
-	conn, err := net.Listen("unix", fmt.Sprintf(fdTemplate, name))
-	if err == nil {
-		tb.listener = conn
-	}
-
-	return err
+// Listen - create and listen on the socket with 'name'
+func (platformTransport) Listen(name string) (net.Listener, error) {
+	return net.Listen("unix", fmt.Sprintf(fdTemplate, name))
 }
 
-// cleanup - manually remove socket
-func (tb *TelemetryBuffer) Cleanup(name string) error {
+// Remove - manually remove socket
+func (platformTransport) Remove(name string) error {
 	return os.Remove(fmt.Sprintf(fdTemplate, name))
 }
 
@@ -5,6 +5,7 @@ package telemetry
 
 import (
 	"fmt"
+	"net"
 	"os"
 
 	"github.com/Microsoft/go-winio"
@@ -17,28 +18,21 @@ const (
 	metadataFile                = "azuremetadata.json"
 )
 
-// Dial - try to connect to a named pipe with 'name'
-func (tb *TelemetryBuffer) Dial(name string) (err error) {
-	conn, err := winio.DialPipe(fmt.Sprintf(fdTemplate, name), nil)
-	if err == nil {
-		tb.client = conn
-	}
+// platformTransport - transport over named pipes
+type platformTransport struct{}
 
-	return err
+// Dial - connect to the named pipe with 'name'
+func (platformTransport) Dial(name string) (net.Conn, error) {
+	return winio.DialPipe(fmt.Sprintf(fdTemplate, name), nil)
 }
 
-// Listen - try to create and listen on named pipe with 'name'
-func (tb *TelemetryBuffer) Listen(name string) (err error) {
-	listener, err := winio.ListenPipe(fmt.Sprintf(fdTemplate, name), nil)
-	if err == nil {
-		tb.listener = listener
-	}
-
-	return err
+// Listen - create and listen on the named pipe with 'name'
+func (platformTransport) Listen(name string) (net.Listener, error) {
+	return winio.ListenPipe(fmt.Sprintf(fdTemplate, name), nil)
 }
 
-// Cleanup - cleanup named pipe
-func (tb *TelemetryBuffer) Cleanup(name string) error {
+// Remove - named pipes are removed with their last handle
+func (platformTransport) Remove(name string) error {
 	return nil
 }
 
@@ -0,0 +1,116 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package testutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+var (
+	errReportNotFound = fmt.Errorf("Report not found")
+)
+
+// MemoryBuffer is an in-memory telemetry.ReportBuffer that records every report written to it.
+type MemoryBuffer struct {
+	sync.Mutex
+	connected bool
+	writeErr  error
+	cancelled int
+	reports   [][]byte
+}
+
+// NewMemoryBuffer creates a new connected MemoryBuffer.
+func NewMemoryBuffer() *MemoryBuffer {
+	return &MemoryBuffer{
+		connected: true,
+	}
+}
+
+// IsConnected returns whether the buffer accepts reports.
+func (mb *MemoryBuffer) IsConnected() bool {
+	mb.Lock()
+	defer mb.Unlock()
+
+	return mb.connected
+}
+
+// SetConnected sets the connection state reported by the buffer.
+func (mb *MemoryBuffer) SetConnected(connected bool) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	mb.connected = connected
+}
+
+// SetWriteError makes subsequent writes fail with err. A nil err makes writes succeed again.
+func (mb *MemoryBuffer) SetWriteError(err error) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	mb.writeErr = err
+}
+
+// Write records a serialized report.
+func (mb *MemoryBuffer) Write(b []byte) (int, error) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	if mb.writeErr != nil {
+		return 0, mb.writeErr
+	}
+
+	report := make([]byte, len(b))
+	copy(report, b)
+	mb.reports = append(mb.reports, report)
+
+	return len(b), nil
+}
+
+// Cancel records a request to tear down the buffer.
+func (mb *MemoryBuffer) Cancel() {
+	mb.Lock()
+	defer mb.Unlock()
+
+	mb.cancelled++
+}
+
+// CancelCount returns the number of times the buffer was cancelled.
+func (mb *MemoryBuffer) CancelCount() int {
+	mb.Lock()
+	defer mb.Unlock()
+
+	return mb.cancelled
+}
+
+// Reports returns the serialized reports written so far.
+func (mb *MemoryBuffer) Reports() [][]byte {
+	mb.Lock()
+	defer mb.Unlock()
+
+	reports := make([][]byte, len(mb.reports))
+	copy(reports, mb.reports)
+
+	return reports
+}
+
+// DecodeReport decodes the i'th report written to the buffer into v.
+func (mb *MemoryBuffer) DecodeReport(i int, v interface{}) error {
+	reports := mb.Reports()
+	if i < 0 || i >= len(reports) {
+		return errReportNotFound
+	}
+
+	return json.Unmarshal(reports[i], v)
+}
+
+// Reset discards all recorded reports.
+func (mb *MemoryBuffer) Reset() {
+	mb.Lock()
+	defer mb.Unlock()
+
+	mb.reports = nil
+	mb.cancelled = 0
+}
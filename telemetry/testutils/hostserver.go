@@ -0,0 +1,110 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package testutils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// FakeHostServer is an httptest based fake of the host net agent report endpoint.
+// It records received payloads and can be programmed to fail requests.
+type FakeHostServer struct {
+	sync.Mutex
+	server     *httptest.Server
+	payloads   []telemetry.Payload
+	failures   []int
+	requests   int
+	statusCode int
+}
+
+// NewFakeHostServer starts a new FakeHostServer.
+func NewFakeHostServer() *FakeHostServer {
+	fs := &FakeHostServer{
+		statusCode: http.StatusOK,
+	}
+
+	fs.server = httptest.NewServer(http.HandlerFunc(fs.handle))
+
+	return fs
+}
+
+// URL returns the URL reports should be posted to.
+func (fs *FakeHostServer) URL() string {
+	return fs.server.URL
+}
+
+// Close shuts down the server.
+func (fs *FakeHostServer) Close() {
+	fs.server.Close()
+}
+
+// FailNext makes the next n requests fail with the given HTTP status code.
+func (fs *FakeHostServer) FailNext(n int, statusCode int) {
+	fs.Lock()
+	defer fs.Unlock()
+
+	for i := 0; i < n; i++ {
+		fs.failures = append(fs.failures, statusCode)
+	}
+}
+
+// SetStatusCode sets the HTTP status code returned once programmed failures are exhausted.
+func (fs *FakeHostServer) SetStatusCode(statusCode int) {
+	fs.Lock()
+	defer fs.Unlock()
+
+	fs.statusCode = statusCode
+}
+
+// Payloads returns the payloads accepted so far.
+func (fs *FakeHostServer) Payloads() []telemetry.Payload {
+	fs.Lock()
+	defer fs.Unlock()
+
+	payloads := make([]telemetry.Payload, len(fs.payloads))
+	copy(payloads, fs.payloads)
+
+	return payloads
+}
+
+// RequestCount returns the number of requests received, including failed ones.
+func (fs *FakeHostServer) RequestCount() int {
+	fs.Lock()
+	defer fs.Unlock()
+
+	return fs.requests
+}
+
+// Handles payload posts.
+func (fs *FakeHostServer) handle(w http.ResponseWriter, r *http.Request) {
+	fs.Lock()
+	defer fs.Unlock()
+
+	fs.requests++
+
+	statusCode := fs.statusCode
+	if len(fs.failures) > 0 {
+		statusCode = fs.failures[0]
+		fs.failures = fs.failures[1:]
+	}
+
+	if statusCode != http.StatusOK {
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	var payload telemetry.Payload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	fs.payloads = append(fs.payloads, payload)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+func TestMemoryBuffer(t *testing.T) {
+	mb := NewMemoryBuffer()
+	reportMgr := &telemetry.ReportManager{
+		ContentType: telemetry.ContentType,
+		Report:      &telemetry.CNIReport{Name: "azure-vnet", CniSucceeded: true},
+	}
+
+	if err := reportMgr.SendReport(mb); err != nil {
+		t.Fatalf("SendReport failed due to %v", err)
+	}
+
+	var report telemetry.CNIReport
+	if err := mb.DecodeReport(0, &report); err != nil || report.Name != "azure-vnet" {
+		t.Errorf("Unexpected report %+v err %v", report, err)
+	}
+
+	// A failed write tears down the buffer.
+	mb.SetWriteError(fmt.Errorf("write failed"))
+	if err := reportMgr.SendReport(mb); err == nil {
+		t.Errorf("SendReport succeeded on a failing buffer")
+	}
+
+	if mb.CancelCount() != 1 || len(mb.Reports()) != 1 {
+		t.Errorf("Unexpected buffer state after failed write, cancelled %d reports %d", mb.CancelCount(), len(mb.Reports()))
+	}
+
+	mb.SetConnected(false)
+	if err := reportMgr.SendReport(mb); err == nil {
+		t.Errorf("SendReport succeeded on a disconnected buffer")
+	}
+}
+
+func TestFakeHostServer(t *testing.T) {
+	fs := NewFakeHostServer()
+	defer fs.Close()
+
+	fs.FailNext(2, http.StatusServiceUnavailable)
+
+	post := func() int {
+		var body bytes.Buffer
+		payload := telemetry.Payload{CNIReports: []telemetry.CNIReport{{Name: "azure-vnet"}}}
+		json.NewEncoder(&body).Encode(payload)
+
+		resp, err := http.Post(fs.URL(), telemetry.ContentType, &body)
+		if err != nil {
+			t.Fatalf("Post failed due to %v", err)
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	for i, expected := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		if statusCode := post(); statusCode != expected {
+			t.Errorf("Request %d returned %d, expected %d", i, statusCode, expected)
+		}
+	}
+
+	payloads := fs.Payloads()
+	if fs.RequestCount() != 3 || len(payloads) != 1 || payloads[0].CNIReports[0].Name != "azure-vnet" {
+		t.Errorf("Unexpected server state, requests %d payloads %+v", fs.RequestCount(), payloads)
+	}
+}
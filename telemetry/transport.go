@@ -0,0 +1,128 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+package telemetry
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// Transport connects the clients of the telemetry service to the instance listening on a name.
+// The default transport uses unix domain sockets on Linux and named pipes on Windows.
+type Transport interface {
+	Dial(name string) (net.Conn, error)
+	Listen(name string) (net.Listener, error)
+	// Remove removes the socket left by an instance that stopped without closing it.
+	Remove(name string) error
+}
+
+// HTTPSender sends the payloads of the telemetry service to the host. *http.Client implements it.
+type HTTPSender interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// syncClock is the clock of the package, which tests can replace while the goroutines of running buffers use it.
+type syncClock struct {
+	sync.RWMutex
+	clock platform.Clock
+}
+
+// get - get the current clock
+func (c *syncClock) get() platform.Clock {
+	c.RLock()
+	defer c.RUnlock()
+	return c.clock
+}
+
+// set - set the current clock, returning the previous one
+func (c *syncClock) set(clock platform.Clock) platform.Clock {
+	c.Lock()
+	defer c.Unlock()
+	previous := c.clock
+	c.clock = clock
+	return previous
+}
+
+func (c *syncClock) Now() time.Time {
+	return c.get().Now()
+}
+
+func (c *syncClock) Since(t time.Time) time.Duration {
+	return c.get().Since(t)
+}
+
+func (c *syncClock) Sleep(d time.Duration) {
+	c.get().Sleep(d)
+}
+
+func (c *syncClock) After(d time.Duration) <-chan time.Time {
+	return c.get().After(d)
+}
+
+func (c *syncClock) NewTicker(d time.Duration) platform.Ticker {
+	return c.get().NewTicker(d)
+}
+
+// SetClock - set the clock of report intervals, retries and timestamps, returning the previous one.
+// It is safe to call while buffers are running, which use the new clock for their next intervals and retries.
+func SetClock(c platform.Clock) platform.Clock {
+	return clock.set(c)
+}
+
+// SetTransport - set the transport connecting the buffer to the telemetry service
+func (tb *TelemetryBuffer) SetTransport(transport Transport) {
+	tb.transport = transport
+}
+
+// SetHTTPSender - set the sender of the payloads to the host
+func (tb *TelemetryBuffer) SetHTTPSender(sender HTTPSender) {
+	tb.sender = sender
+}
+
+// getTransport - get the transport of the buffer, the platform transport unless one was set
+func (tb *TelemetryBuffer) getTransport() Transport {
+	if tb.transport == nil {
+		return platformTransport{}
+	}
+
+	return tb.transport
+}
+
+// getSender - get the sender of the buffer, a default HTTP client unless one was set
+func (tb *TelemetryBuffer) getSender() HTTPSender {
+	if tb.sender == nil {
+		return &http.Client{}
+	}
+
+	return tb.sender
+}
+
+// Dial - try to connect to the telemetry service listening on 'name'
+func (tb *TelemetryBuffer) Dial(name string) error {
+	conn, err := tb.getTransport().Dial(name)
+	if err == nil {
+		tb.client = conn
+	}
+
+	return err
+}
+
+// Listen - try to listen on 'name'
+func (tb *TelemetryBuffer) Listen(name string) error {
+	listener, err := tb.getTransport().Listen(name)
+	if err == nil {
+		tb.listener = listener
+	}
+
+	return err
+}
+
+// Cleanup - remove the socket of 'name'
+func (tb *TelemetryBuffer) Cleanup(name string) error {
+	return tb.getTransport().Remove(name)
+}